@@ -10,7 +10,7 @@ from database import init_db, check_db_connection
 import redis
 
 # Import routers
-from routers import services, routes, consumers, plugins
+from routers import services, routes, route_groups, consumers, plugins, portal, redirects, stream_listeners, tenants, workspaces, settings as gateway_settings
 
 # Configure logging
 logging.basicConfig(
@@ -75,10 +75,17 @@ app.add_middleware(
 )
 
 # Include routers
+app.include_router(workspaces.router, prefix="/workspaces", tags=["Workspaces"])
 app.include_router(services.router, prefix="/services", tags=["Services"])
 app.include_router(routes.router, prefix="/routes", tags=["Routes"])
+app.include_router(route_groups.router, prefix="/route-groups", tags=["Route Groups"])
 app.include_router(consumers.router, prefix="/consumers", tags=["Consumers"])
 app.include_router(plugins.router, prefix="/plugins", tags=["Plugins"])
+app.include_router(redirects.router, prefix="/redirects", tags=["Redirects"])
+app.include_router(stream_listeners.router, prefix="/stream-listeners", tags=["Stream Listeners"])
+app.include_router(tenants.router, prefix="/tenants", tags=["Tenants"])
+app.include_router(gateway_settings.router, prefix="/settings", tags=["Gateway Settings"])
+app.include_router(portal.router, prefix="/portal", tags=["Developer Portal"])
 
 
 @app.get("/")
@@ -115,7 +122,7 @@ async def health():
     }
 
 # TODO: Import and include routers here in next sessions
-# from routers import services, routes, consumers, plugins
+# from routers import services, routes, consumers, plugins, portal
 # app.include_router(services.router, prefix="/services", tags=["Services"])
 # app.include_router(routes.router, prefix="/routes", tags=["Routes"])
 # app.include_router(consumers.router, prefix="/consumers", tags=["Consumers"])
@@ -18,6 +18,25 @@ class Settings(BaseSettings):
     # Redis
     redis_url: str = "redis://localhost:6379/0"
     
+    # Encryption at rest (must match the gateway)
+    config_encryption_key: str = ""
+    config_encryption_key_file: str = ""
+    config_encrypted_fields: str = "secret,password,api_key,private_key,client_secret,token,credentials,bind_password"
+    api_key_pepper: str = ""
+    
+    # Developer self-service portal (/portal). Developers are identified by
+    # a header set by the SSO proxy in front of the Admin API.
+    portal_enabled: bool = False
+    portal_identity_header: str = "X-Forwarded-Email"
+    portal_proxy_secret: str = ""  # if set, required in X-Portal-Proxy-Secret
+    portal_allowed_domains: str = ""  # comma-separated email domains (empty = any)
+    portal_max_consumers: int = 5
+    portal_max_keys_per_consumer: int = 3
+    portal_allowed_scopes: str = "read,write"
+    portal_key_max_ttl_days: int = 365
+    portal_rotation_grace_hours: int = 24
+    portal_workspace: str = "default"  # workspace portal consumers are created in
+    
     # Server
     host: str = "0.0.0.0"
     port: int = 8000
@@ -3,7 +3,7 @@
 import redis
 import json
 import logging
-from typing import Optional
+from typing import Optional, Union
 from uuid import UUID
 
 from config import get_settings
@@ -26,7 +26,7 @@ def get_redis():
 def publish_config_change(
     event_type: str,
     entity_type: str,
-    entity_id: UUID,
+    entity_id: Union[UUID, str],
     action: str,
     metadata: Optional[dict] = None
 ):
@@ -35,8 +35,8 @@ def publish_config_change(
     
     Args:
         event_type: Type of event (config_change)
-        entity_type: What was changed (service, route, consumer, plugin)
-        entity_id: ID of the changed entity
+        entity_type: What was changed (service, route, route_group, consumer, plugin, redirect, stream_listener, tenant, setting)
+        entity_id: ID of the changed entity (key for settings)
         action: What happened (created, updated, deleted)
         metadata: Additional context
     """
@@ -95,6 +95,11 @@ def publish_route_change(route_id: UUID, action: str, metadata: Optional[dict] =
     return publish_config_change("config_change", "route", route_id, action, metadata)
 
 
+def publish_route_group_change(group_id: UUID, action: str, metadata: Optional[dict] = None):
+    """Publish route group change event."""
+    return publish_config_change("config_change", "route_group", group_id, action, metadata)
+
+
 def publish_consumer_change(consumer_id: UUID, action: str, metadata: Optional[dict] = None):
     """Publish consumer change event."""
     return publish_config_change("config_change", "consumer", consumer_id, action, metadata)
@@ -102,4 +107,24 @@ def publish_consumer_change(consumer_id: UUID, action: str, metadata: Optional[d
 
 def publish_plugin_change(plugin_id: UUID, action: str, metadata: Optional[dict] = None):
     """Publish plugin change event."""
-    return publish_config_change("config_change", "plugin", plugin_id, action, metadata)
\ No newline at end of file
+    return publish_config_change("config_change", "plugin", plugin_id, action, metadata)
+
+
+def publish_redirect_change(redirect_id: UUID, action: str, metadata: Optional[dict] = None):
+    """Publish redirect change event."""
+    return publish_config_change("config_change", "redirect", redirect_id, action, metadata)
+
+
+def publish_stream_listener_change(listener_id: UUID, action: str, metadata: Optional[dict] = None):
+    """Publish stream listener change event."""
+    return publish_config_change("config_change", "stream_listener", listener_id, action, metadata)
+
+
+def publish_tenant_change(tenant_id: str, action: str, metadata: Optional[dict] = None):
+    """Publish tenant change event."""
+    return publish_config_change("config_change", "tenant", tenant_id, action, metadata)
+
+
+def publish_setting_change(key: str, action: str, metadata: Optional[dict] = None):
+    """Publish gateway setting change event."""
+    return publish_config_change("config_change", "setting", key, action, metadata)
@@ -0,0 +1,128 @@
+"""Field-level encryption for sensitive plugin config values.
+
+Mirrors the gateway's internal/fieldcrypt package: values are AES-256-GCM
+encrypted and stored as ``enc:v1:<base64(nonce || ciphertext || tag)>``.
+The admin API encrypts designated keys on write; the gateway decrypts them
+when loading plugins. Both sides must share CONFIG_ENCRYPTION_KEY.
+"""
+
+import base64
+import binascii
+import hashlib
+import hmac
+import os
+from functools import lru_cache
+from typing import Any, Optional
+
+from cryptography.hazmat.primitives.ciphers.aead import AESGCM
+
+from config import get_settings
+
+PREFIX = "enc:v1:"
+KEY_SIZE = 32
+NONCE_SIZE = 12
+
+
+def parse_key(encoded: str) -> bytes:
+    """Decode a base64 or hex encoded 32-byte key."""
+    encoded = encoded.strip()
+
+    if len(encoded) == KEY_SIZE * 2:
+        try:
+            return bytes.fromhex(encoded)
+        except ValueError:
+            pass
+
+    padded = encoded + "=" * (-len(encoded) % 4)
+    for decode in (base64.b64decode, base64.urlsafe_b64decode):
+        try:
+            key = decode(padded)
+        except (binascii.Error, ValueError):
+            continue
+        if len(key) == KEY_SIZE:
+            return key
+
+    raise ValueError(f"encryption key must be {KEY_SIZE} bytes encoded as base64 or hex")
+
+
+@lru_cache()
+def get_cipher() -> Optional[AESGCM]:
+    """Return the configured cipher, or None if encryption is disabled."""
+    settings = get_settings()
+    encoded = settings.config_encryption_key
+    if settings.config_encryption_key_file:
+        with open(settings.config_encryption_key_file) as f:
+            encoded = f.read()
+    if not encoded:
+        return None
+    return AESGCM(parse_key(encoded))
+
+
+def is_encrypted(value: Any) -> bool:
+    return isinstance(value, str) and value.startswith(PREFIX)
+
+
+def encrypt_value(cipher: AESGCM, value: str) -> str:
+    """Encrypt a plaintext string (already-encrypted values are unchanged)."""
+    if is_encrypted(value):
+        return value
+    nonce = os.urandom(NONCE_SIZE)
+    sealed = nonce + cipher.encrypt(nonce, value.encode(), None)
+    return PREFIX + base64.b64encode(sealed).decode()
+
+
+def decrypt_value(cipher: Optional[AESGCM], value: str) -> str:
+    """Decrypt an encrypted string; plaintext passes through."""
+    if not is_encrypted(value):
+        return value
+    if cipher is None:
+        raise ValueError("encrypted value found but CONFIG_ENCRYPTION_KEY is not set")
+    sealed = base64.b64decode(value[len(PREFIX):])
+    return cipher.decrypt(sealed[:NONCE_SIZE], sealed[NONCE_SIZE:], None).decode()
+
+
+def _encrypt_all(cipher: AESGCM, value: Any) -> Any:
+    if isinstance(value, str):
+        return encrypt_value(cipher, value) if value else value
+    if isinstance(value, list):
+        return [_encrypt_all(cipher, v) for v in value]
+    if isinstance(value, dict):
+        return {k: _encrypt_all(cipher, v) for k, v in value.items()}
+    return value
+
+
+def _encrypt_fields(cipher: AESGCM, config: Any, fields: set[str]) -> Any:
+    if isinstance(config, dict):
+        return {
+            k: _encrypt_all(cipher, v) if k.lower() in fields else _encrypt_fields(cipher, v, fields)
+            for k, v in config.items()
+        }
+    if isinstance(config, list):
+        return [_encrypt_fields(cipher, v, fields) for v in config]
+    return config
+
+
+def encrypt_config(config: Optional[dict]) -> Optional[dict]:
+    """Encrypt designated keys of a plugin config (no-op when disabled)."""
+    cipher = get_cipher()
+    if cipher is None or not config:
+        return config
+    fields = {f.strip().lower() for f in get_settings().config_encrypted_fields.split(",") if f.strip()}
+    return _encrypt_fields(cipher, config, fields)
+
+
+@lru_cache()
+def get_api_key_pepper() -> bytes:
+    """Return the API key pepper (decrypted if stored encrypted)."""
+    return decrypt_value(get_cipher(), get_settings().api_key_pepper).encode()
+
+
+def hash_api_key(plaintext_key: str) -> str:
+    """Hash an API key the same way the gateway does.
+
+    HMAC-SHA256 keyed by API_KEY_PEPPER, or plain SHA256 without a pepper.
+    """
+    pepper = get_api_key_pepper()
+    if not pepper:
+        return hashlib.sha256(plaintext_key.encode()).hexdigest()
+    return hmac.new(pepper, plaintext_key.encode(), hashlib.sha256).hexdigest()
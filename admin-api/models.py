@@ -2,7 +2,7 @@
 
 from sqlalchemy import (
     Column, String, Integer, Boolean, DateTime, Text, 
-    ForeignKey, ARRAY, JSON, CheckConstraint
+    ForeignKey, ARRAY, JSON, CheckConstraint, Numeric, UniqueConstraint
 )
 from sqlalchemy.dialects.postgresql import UUID
 from sqlalchemy.orm import relationship
@@ -12,13 +12,30 @@ import uuid
 from database import Base
 
 
+DEFAULT_WORKSPACE = "default"
+
+
+class Workspace(Base):
+    """Workspace model - a tenant owning services, routes, consumers and plugins."""
+    
+    __tablename__ = "workspaces"
+    
+    name = Column(String(100), primary_key=True)
+    description = Column(Text, nullable=True)
+    
+    # Timestamps
+    created_at = Column(DateTime(timezone=True), server_default=func.now())
+    updated_at = Column(DateTime(timezone=True), server_default=func.now(), onupdate=func.now())
+
+
 class Service(Base):
     """Service model - represents backend services."""
     
     __tablename__ = "services"
     
     id = Column(UUID(as_uuid=True), primary_key=True, default=uuid.uuid4)
-    name = Column(String(100), unique=True, nullable=False)
+    workspace = Column(String(100), ForeignKey("workspaces.name"), nullable=False, default=DEFAULT_WORKSPACE)
+    name = Column(String(100), nullable=False)
     
     # Connection
     protocol = Column(String(10), nullable=False, default="http")
@@ -34,6 +51,21 @@ class Service(Base):
     
     # Load balancing
     load_balancer_type = Column(String(50), default="round-robin")
+    hash_on = Column(String(20), nullable=False, default="ip")
+    hash_on_key = Column(String(100), nullable=True)
+    hash_balance_factor = Column(Numeric(4, 2), nullable=False, default=1.25)
+    
+    # Address family for upstream dials ("default" = UPSTREAM_IP_FAMILY)
+    ip_family = Column(String(20), nullable=False, default="default")
+    
+    # Active health checks of the targets (NULL = outlier detection only)
+    health_check = Column(JSON, nullable=True)
+    
+    # API documentation (OpenAPI 3.x), merged into GET /admin/specs on the gateway
+    openapi_spec = Column(JSON, nullable=True)
+    
+    # Labels for filtering and per-team metrics
+    tags = Column(ARRAY(Text), nullable=False, default=[])
     
     # Status
     enabled = Column(Boolean, default=True)
@@ -46,6 +78,10 @@ class Service(Base):
     routes = relationship("Route", back_populates="service", cascade="all, delete-orphan")
     targets = relationship("ServiceTarget", back_populates="service", cascade="all, delete-orphan")
     plugins = relationship("Plugin", back_populates="service", cascade="all, delete-orphan")
+    
+    __table_args__ = (
+        UniqueConstraint("workspace", "name", name="services_workspace_name_key"),
+    )
 
 
 class ServiceTarget(Base):
@@ -58,6 +94,7 @@ class ServiceTarget(Base):
     target = Column(String(255), nullable=False)
     weight = Column(Integer, default=100)
     health_check_path = Column(String(255), default="/health")
+    region = Column(String(100), nullable=False, default="")
     enabled = Column(Boolean, default=True)
     created_at = Column(DateTime(timezone=True), server_default=func.now())
     
@@ -65,6 +102,37 @@ class ServiceTarget(Base):
     service = relationship("Service", back_populates="targets")
 
 
+class RouteGroup(Base):
+    """Route group model - routes sharing a service, base path and plugins."""
+    
+    __tablename__ = "route_groups"
+    
+    id = Column(UUID(as_uuid=True), primary_key=True, default=uuid.uuid4)
+    workspace = Column(String(100), ForeignKey("workspaces.name"), nullable=False, default=DEFAULT_WORKSPACE)
+    service_id = Column(UUID(as_uuid=True), ForeignKey("services.id", ondelete="CASCADE"), nullable=False)
+    name = Column(String(100), nullable=False)
+    description = Column(Text, nullable=True)
+    
+    # Prefix of member routes' paths ("" = none)
+    base_path = Column(String(255), nullable=False, default="")
+    
+    # Status (a disabled group disables all its routes)
+    enabled = Column(Boolean, default=True)
+    
+    # Timestamps
+    created_at = Column(DateTime(timezone=True), server_default=func.now())
+    updated_at = Column(DateTime(timezone=True), server_default=func.now(), onupdate=func.now())
+    
+    # Relationships
+    service = relationship("Service")
+    routes = relationship("Route", back_populates="group")
+    plugins = relationship("Plugin", back_populates="group", cascade="all, delete-orphan")
+    
+    __table_args__ = (
+        UniqueConstraint("workspace", "name", name="route_groups_workspace_name_key"),
+    )
+
+
 class Route(Base):
     """Route model - maps requests to services."""
     
@@ -72,6 +140,7 @@ class Route(Base):
     
     id = Column(UUID(as_uuid=True), primary_key=True, default=uuid.uuid4)
     service_id = Column(UUID(as_uuid=True), ForeignKey("services.id", ondelete="CASCADE"), nullable=False)
+    workspace = Column(String(100), ForeignKey("workspaces.name"), nullable=False, default=DEFAULT_WORKSPACE)
     name = Column(String(100), nullable=True)
     
     # Matching
@@ -83,6 +152,29 @@ class Route(Base):
     strip_path = Column(Boolean, default=False)
     preserve_host = Column(Boolean, default=False)
     
+    # Admission control
+    priority_class = Column(String(20), nullable=False, default="normal")
+    
+    # Total time per request, plugins and upstream call included
+    # (0 = the service's read_timeout_ms)
+    timeout_ms = Column(Integer, nullable=False, default=0)
+    
+    # Time-based scheduling
+    schedule_start = Column(DateTime(timezone=True), nullable=True)
+    schedule_end = Column(DateTime(timezone=True), nullable=True)
+    schedule_cron = Column(String(100), nullable=True)
+    schedule_mode = Column(String(10), nullable=False, default="active")
+    schedule_timezone = Column(String(64), nullable=False, default="UTC")
+    
+    # Service level objectives (tracked by the gateway, reported at /status)
+    slo = Column(JSON, nullable=True)
+    
+    # Route group (paths are relative to the group's base path)
+    group_id = Column(UUID(as_uuid=True), ForeignKey("route_groups.id"), nullable=True)
+    
+    # Labels for filtering and per-team metrics
+    tags = Column(ARRAY(Text), nullable=False, default=[])
+    
     # Status
     enabled = Column(Boolean, default=True)
     
@@ -92,20 +184,110 @@ class Route(Base):
     
     # Relationships
     service = relationship("Service", back_populates="routes")
+    group = relationship("RouteGroup", back_populates="routes")
     plugins = relationship("Plugin", back_populates="route", cascade="all, delete-orphan")
 
 
+class Redirect(Base):
+    """Redirect model - answers matching requests with a 30x instead of proxying."""
+    
+    __tablename__ = "redirects"
+    
+    id = Column(UUID(as_uuid=True), primary_key=True, default=uuid.uuid4)
+    name = Column(String(100), unique=True, nullable=True)
+    
+    # Matching (same patterns as routes)
+    hosts = Column(ARRAY(Text), nullable=True)
+    paths = Column(ARRAY(Text), nullable=False)
+    
+    # Response
+    location = Column(Text, nullable=False)
+    status_code = Column(Integer, nullable=False, default=301)
+    preserve_query = Column(Boolean, nullable=False, default=True)
+    
+    # Status
+    enabled = Column(Boolean, default=True)
+    
+    # Timestamps
+    created_at = Column(DateTime(timezone=True), server_default=func.now())
+    updated_at = Column(DateTime(timezone=True), server_default=func.now(), onupdate=func.now())
+
+
+class StreamListener(Base):
+    """Stream listener model - a TCP/UDP listener proxied to a service's targets."""
+    
+    __tablename__ = "stream_listeners"
+    
+    id = Column(UUID(as_uuid=True), primary_key=True, default=uuid.uuid4)
+    workspace = Column(String(100), ForeignKey("workspaces.name"), nullable=False, default=DEFAULT_WORKSPACE)
+    service_id = Column(UUID(as_uuid=True), ForeignKey("services.id", ondelete="CASCADE"), nullable=False)
+    name = Column(String(100), nullable=True)
+    
+    # Listening socket
+    protocol = Column(String(10), nullable=False, default="tcp")
+    listen_address = Column(String(255), nullable=False)
+    
+    # Limits (max_connections: 0 = unlimited; UDP counts client sessions)
+    max_connections = Column(Integer, nullable=False, default=0)
+    idle_timeout_ms = Column(Integer, nullable=False, default=300000)
+    
+    # Status
+    enabled = Column(Boolean, default=True)
+    
+    # Timestamps
+    created_at = Column(DateTime(timezone=True), server_default=func.now())
+    updated_at = Column(DateTime(timezone=True), server_default=func.now(), onupdate=func.now())
+    
+    # Relationships
+    service = relationship("Service")
+    
+    __table_args__ = (
+        UniqueConstraint("workspace", "name", name="stream_listeners_workspace_name_key"),
+        UniqueConstraint("protocol", "listen_address", name="stream_listeners_protocol_listen_address_key"),
+    )
+
+
+class Tenant(Base):
+    """Tenant model - tenant registry for per-tenant service templates."""
+    
+    __tablename__ = "tenants"
+    
+    id = Column(String(63), primary_key=True)  # DNS label, substituted for {tenant}
+    name = Column(String(255), nullable=True)
+    enabled = Column(Boolean, default=True)
+    
+    # Timestamps
+    created_at = Column(DateTime(timezone=True), server_default=func.now())
+    updated_at = Column(DateTime(timezone=True), server_default=func.now(), onupdate=func.now())
+
+
+class GatewaySetting(Base):
+    """Gateway setting model - runtime overrides applied on hot reload."""
+    
+    __tablename__ = "gateway_settings"
+    
+    key = Column(String(100), primary_key=True)
+    value = Column(Text, nullable=False)
+    
+    # Timestamps
+    updated_at = Column(DateTime(timezone=True), server_default=func.now(), onupdate=func.now())
+
+
 class Consumer(Base):
     """Consumer model - API clients/applications."""
     
     __tablename__ = "consumers"
     
     id = Column(UUID(as_uuid=True), primary_key=True, default=uuid.uuid4)
-    username = Column(String(100), unique=True, nullable=False)
+    workspace = Column(String(100), ForeignKey("workspaces.name"), nullable=False, default=DEFAULT_WORKSPACE)
+    username = Column(String(100), nullable=False)
     email = Column(String(255), nullable=True)
     custom_id = Column(String(100), nullable=True)
     custom_metadata = Column("metadata", JSON, default={})
     
+    # Developer who created the consumer via the self-service portal
+    owner = Column(String(255), nullable=True, index=True)
+    
     # Timestamps
     created_at = Column(DateTime(timezone=True), server_default=func.now())
     updated_at = Column(DateTime(timezone=True), server_default=func.now(), onupdate=func.now())
@@ -113,6 +295,10 @@ class Consumer(Base):
     # Relationships
     api_keys = relationship("APIKey", back_populates="consumer", cascade="all, delete-orphan")
     plugins = relationship("Plugin", back_populates="consumer", cascade="all, delete-orphan")
+    
+    __table_args__ = (
+        UniqueConstraint("workspace", "username", name="consumers_workspace_username_key"),
+    )
 
 
 class APIKey(Base):
@@ -125,6 +311,7 @@ class APIKey(Base):
     key_hash = Column(String(64), unique=True, nullable=False)  # SHA256 hash
     name = Column(String(100), nullable=True)
     enabled = Column(Boolean, default=True)
+    scopes = Column(ARRAY(Text), default=[])
     
     # Timestamps
     created_at = Column(DateTime(timezone=True), server_default=func.now())
@@ -142,15 +329,18 @@ class Plugin(Base):
     
     id = Column(UUID(as_uuid=True), primary_key=True, default=uuid.uuid4)
     name = Column(String(50), nullable=False)
-    scope = Column(String(20), nullable=False)  # global, service, route, consumer
+    scope = Column(String(20), nullable=False)  # global, service, route, group, consumer
+    workspace = Column(String(100), ForeignKey("workspaces.name"), nullable=False, default=DEFAULT_WORKSPACE)
     
     # Foreign keys (nullable based on scope)
     service_id = Column(UUID(as_uuid=True), ForeignKey("services.id", ondelete="CASCADE"), nullable=True)
     route_id = Column(UUID(as_uuid=True), ForeignKey("routes.id", ondelete="CASCADE"), nullable=True)
+    group_id = Column(UUID(as_uuid=True), ForeignKey("route_groups.id", ondelete="CASCADE"), nullable=True)
     consumer_id = Column(UUID(as_uuid=True), ForeignKey("consumers.id", ondelete="CASCADE"), nullable=True)
     
     # Configuration
     config = Column(JSON, nullable=False, default={})
+    tags = Column(ARRAY(Text), nullable=False, default=[])
     enabled = Column(Boolean, default=True)
     priority = Column(Integer, default=100)
     
@@ -161,16 +351,18 @@ class Plugin(Base):
     # Relationships
     service = relationship("Service", back_populates="plugins")
     route = relationship("Route", back_populates="plugins")
+    group = relationship("RouteGroup", back_populates="plugins")
     consumer = relationship("Consumer", back_populates="plugins")
     
     # Constraint to enforce scope rules
     __table_args__ = (
         CheckConstraint(
             """
-            (scope = 'global' AND service_id IS NULL AND route_id IS NULL AND consumer_id IS NULL) OR
-            (scope = 'service' AND service_id IS NOT NULL AND route_id IS NULL AND consumer_id IS NULL) OR
-            (scope = 'route' AND route_id IS NOT NULL AND service_id IS NULL AND consumer_id IS NULL) OR
-            (scope = 'consumer' AND consumer_id IS NOT NULL AND service_id IS NULL AND route_id IS NULL)
+            (scope = 'global' AND service_id IS NULL AND route_id IS NULL AND group_id IS NULL AND consumer_id IS NULL) OR
+            (scope = 'service' AND service_id IS NOT NULL AND route_id IS NULL AND group_id IS NULL AND consumer_id IS NULL) OR
+            (scope = 'route' AND route_id IS NOT NULL AND service_id IS NULL AND group_id IS NULL AND consumer_id IS NULL) OR
+            (scope = 'group' AND group_id IS NOT NULL AND service_id IS NULL AND route_id IS NULL AND consumer_id IS NULL) OR
+            (scope = 'consumer' AND consumer_id IS NOT NULL AND service_id IS NULL AND route_id IS NULL AND group_id IS NULL)
             """,
             name="plugins_scope_check"
         ),
@@ -6,12 +6,13 @@ from typing import List
 import logging
 from uuid import UUID
 import secrets
-import hashlib
 from datetime import datetime
 
 from database import get_db
 from models import Consumer as ConsumerModel, APIKey as APIKeyModel
 from schemas import ConsumerCreate, ConsumerUpdate, ConsumerResponse
+from fieldcrypt import hash_api_key
+from workspace import get_workspace
 
 logger = logging.getLogger(__name__)
 
@@ -25,7 +26,8 @@ router = APIRouter()
 @router.post("", response_model=ConsumerResponse, status_code=status.HTTP_201_CREATED)
 def create_consumer(
     consumer: ConsumerCreate,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Create a new consumer.
@@ -42,6 +44,7 @@ def create_consumer(
     
     # Check if consumer with this username already exists
     existing = db.query(ConsumerModel).filter(
+        ConsumerModel.workspace == workspace,
         ConsumerModel.username == consumer.username
     ).first()
     
@@ -56,7 +59,7 @@ def create_consumer(
         )
     
     # Create consumer
-    db_consumer = ConsumerModel(**consumer.model_dump())
+    db_consumer = ConsumerModel(**consumer.model_dump(), workspace=workspace)
     
     try:
         db.add(db_consumer)
@@ -90,10 +93,11 @@ def create_consumer(
 def list_consumers(
     skip: int = 0,
     limit: int = 100,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
-    List all consumers.
+    List all consumers in the workspace.
     
     Query parameters:
     - skip: Number of records to skip (pagination)
@@ -104,7 +108,9 @@ def list_consumers(
         extra={"skip": skip, "limit": limit}
     )
     
-    consumers = db.query(ConsumerModel).offset(skip).limit(limit).all()
+    consumers = db.query(ConsumerModel).filter(
+        ConsumerModel.workspace == workspace
+    ).offset(skip).limit(limit).all()
     
     logger.info(
         "Consumers retrieved",
@@ -117,7 +123,8 @@ def list_consumers(
 @router.get("/{consumer_id}", response_model=ConsumerResponse)
 def get_consumer(
     consumer_id: UUID,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Get a specific consumer by ID.
@@ -127,7 +134,10 @@ def get_consumer(
         extra={"consumer_id": str(consumer_id)}
     )
     
-    consumer = db.query(ConsumerModel).filter(ConsumerModel.id == consumer_id).first()
+    consumer = db.query(ConsumerModel).filter(
+        ConsumerModel.id == consumer_id,
+        ConsumerModel.workspace == workspace
+    ).first()
     
     if not consumer:
         logger.warning(
@@ -154,7 +164,8 @@ def get_consumer(
 def update_consumer(
     consumer_id: UUID,
     consumer_update: ConsumerUpdate,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Update a consumer.
@@ -167,7 +178,10 @@ def update_consumer(
     )
     
     # Get existing consumer
-    db_consumer = db.query(ConsumerModel).filter(ConsumerModel.id == consumer_id).first()
+    db_consumer = db.query(ConsumerModel).filter(
+        ConsumerModel.id == consumer_id,
+        ConsumerModel.workspace == workspace
+    ).first()
     
     if not db_consumer:
         logger.warning(
@@ -182,6 +196,7 @@ def update_consumer(
     # Check if new username conflicts
     if consumer_update.username and consumer_update.username != db_consumer.username:
         existing = db.query(ConsumerModel).filter(
+            ConsumerModel.workspace == workspace,
             ConsumerModel.username == consumer_update.username,
             ConsumerModel.id != consumer_id
         ).first()
@@ -235,7 +250,8 @@ def update_consumer(
 @router.delete("/{consumer_id}", status_code=status.HTTP_204_NO_CONTENT)
 def delete_consumer(
     consumer_id: UUID,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Delete a consumer.
@@ -249,7 +265,10 @@ def delete_consumer(
     )
     
     # Get consumer
-    db_consumer = db.query(ConsumerModel).filter(ConsumerModel.id == consumer_id).first()
+    db_consumer = db.query(ConsumerModel).filter(
+        ConsumerModel.id == consumer_id,
+        ConsumerModel.workspace == workspace
+    ).first()
     
     if not db_consumer:
         logger.warning(
@@ -315,8 +334,8 @@ def generate_api_key(environment: str = "prod") -> tuple[str, str]:
     # Create key with format: gw_prod_...
     plaintext_key = f"gw_{environment}_{random_bytes}"
     
-    # Hash the key (SHA256)
-    hashed_key = hashlib.sha256(plaintext_key.encode()).hexdigest()
+    # Hash the key (SHA256, or HMAC-SHA256 with API_KEY_PEPPER)
+    hashed_key = hash_api_key(plaintext_key)
     
     return plaintext_key, hashed_key
 
@@ -325,7 +344,8 @@ def generate_api_key(environment: str = "prod") -> tuple[str, str]:
 def create_api_key(
     consumer_id: UUID,
     name: str = None,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Generate a new API key for a consumer.
@@ -344,7 +364,10 @@ def create_api_key(
     )
     
     # Verify consumer exists
-    consumer = db.query(ConsumerModel).filter(ConsumerModel.id == consumer_id).first()
+    consumer = db.query(ConsumerModel).filter(
+        ConsumerModel.id == consumer_id,
+        ConsumerModel.workspace == workspace
+    ).first()
     
     if not consumer:
         logger.warning(
@@ -414,7 +437,8 @@ def create_api_key(
 @router.get("/{consumer_id}/keys")
 def list_api_keys(
     consumer_id: UUID,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     List all API keys for a consumer.
@@ -427,7 +451,10 @@ def list_api_keys(
     )
     
     # Verify consumer exists
-    consumer = db.query(ConsumerModel).filter(ConsumerModel.id == consumer_id).first()
+    consumer = db.query(ConsumerModel).filter(
+        ConsumerModel.id == consumer_id,
+        ConsumerModel.workspace == workspace
+    ).first()
     
     if not consumer:
         raise HTTPException(
@@ -464,7 +491,8 @@ def list_api_keys(
 def revoke_api_key(
     consumer_id: UUID,
     key_id: UUID,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Revoke (delete) an API key.
@@ -480,9 +508,10 @@ def revoke_api_key(
     )
     
     # Get API key
-    api_key = db.query(APIKeyModel).filter(
+    api_key = db.query(APIKeyModel).join(APIKeyModel.consumer).filter(
         APIKeyModel.id == key_id,
-        APIKeyModel.consumer_id == consumer_id
+        APIKeyModel.consumer_id == consumer_id,
+        ConsumerModel.workspace == workspace
     ).first()
     
     if not api_key:
@@ -538,7 +567,8 @@ def revoke_api_key(
 def disable_api_key(
     consumer_id: UUID,
     key_id: UUID,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Disable an API key (without deleting it).
@@ -555,9 +585,10 @@ def disable_api_key(
     )
     
     # Get API key
-    api_key = db.query(APIKeyModel).filter(
+    api_key = db.query(APIKeyModel).join(APIKeyModel.consumer).filter(
         APIKeyModel.id == key_id,
-        APIKeyModel.consumer_id == consumer_id
+        APIKeyModel.consumer_id == consumer_id,
+        ConsumerModel.workspace == workspace
     ).first()
     
     if not api_key:
@@ -606,7 +637,8 @@ def disable_api_key(
 def enable_api_key(
     consumer_id: UUID,
     key_id: UUID,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Re-enable a previously disabled API key.
@@ -620,9 +652,10 @@ def enable_api_key(
     )
     
     # Get API key
-    api_key = db.query(APIKeyModel).filter(
+    api_key = db.query(APIKeyModel).join(APIKeyModel.consumer).filter(
         APIKeyModel.id == key_id,
-        APIKeyModel.consumer_id == consumer_id
+        APIKeyModel.consumer_id == consumer_id,
+        ConsumerModel.workspace == workspace
     ).first()
     
     if not api_key:
@@ -11,10 +11,13 @@ from models import (
     Plugin as PluginModel,
     Service as ServiceModel,
     Route as RouteModel,
+    RouteGroup as RouteGroupModel,
     Consumer as ConsumerModel
 )
-from schemas import PluginCreate, PluginUpdate, PluginResponse
+from schemas import PluginCreate, PluginUpdate, PluginResponse, split_tags
 from events import publish_plugin_change
+from fieldcrypt import encrypt_config
+from workspace import get_workspace
 
 
 logger = logging.getLogger(__name__)
@@ -27,11 +30,16 @@ def validate_plugin_scope(
     service_id: Optional[UUID],
     route_id: Optional[UUID],
     consumer_id: Optional[UUID],
-    db: Session
+    db: Session,
+    workspace: str,
+    group_id: Optional[UUID] = None
 ) -> dict:
     """
     Validate plugin scope and associated entities.
     
+    The service, route, route group or consumer must belong to the
+    plugin's workspace.
+    
     Returns dict with validation results and entity names for logging.
     """
     result = {
@@ -42,19 +50,22 @@ def validate_plugin_scope(
     
     # Validate scope rules
     if scope == "global":
-        if service_id or route_id or consumer_id:
+        if service_id or route_id or group_id or consumer_id:
             result["valid"] = False
-            result["error"] = "Global plugins cannot be associated with service, route, or consumer"
+            result["error"] = "Global plugins cannot be associated with service, route, group, or consumer"
             return result
     
     elif scope == "service":
-        if not service_id or route_id or consumer_id:
+        if not service_id or route_id or group_id or consumer_id:
             result["valid"] = False
             result["error"] = "Service plugins must have service_id only"
             return result
         
         # Verify service exists
-        service = db.query(ServiceModel).filter(ServiceModel.id == service_id).first()
+        service = db.query(ServiceModel).filter(
+            ServiceModel.id == service_id,
+            ServiceModel.workspace == workspace
+        ).first()
         if not service:
             result["valid"] = False
             result["error"] = f"Service with id '{service_id}' not found"
@@ -62,13 +73,16 @@ def validate_plugin_scope(
         result["entity_names"]["service"] = service.name
     
     elif scope == "route":
-        if not route_id or service_id or consumer_id:
+        if not route_id or service_id or group_id or consumer_id:
             result["valid"] = False
             result["error"] = "Route plugins must have route_id only"
             return result
         
         # Verify route exists
-        route = db.query(RouteModel).filter(RouteModel.id == route_id).first()
+        route = db.query(RouteModel).filter(
+            RouteModel.id == route_id,
+            RouteModel.workspace == workspace
+        ).first()
         if not route:
             result["valid"] = False
             result["error"] = f"Route with id '{route_id}' not found"
@@ -76,14 +90,35 @@ def validate_plugin_scope(
         result["entity_names"]["route"] = route.name
         result["entity_names"]["service"] = route.service.name
     
+    elif scope == "group":
+        if not group_id or service_id or route_id or consumer_id:
+            result["valid"] = False
+            result["error"] = "Group plugins must have group_id only"
+            return result
+        
+        # Verify route group exists
+        group = db.query(RouteGroupModel).filter(
+            RouteGroupModel.id == group_id,
+            RouteGroupModel.workspace == workspace
+        ).first()
+        if not group:
+            result["valid"] = False
+            result["error"] = f"Route group with id '{group_id}' not found"
+            return result
+        result["entity_names"]["group"] = group.name
+        result["entity_names"]["service"] = group.service.name
+    
     elif scope == "consumer":
-        if not consumer_id or service_id or route_id:
+        if not consumer_id or service_id or route_id or group_id:
             result["valid"] = False
             result["error"] = "Consumer plugins must have consumer_id only"
             return result
         
         # Verify consumer exists
-        consumer = db.query(ConsumerModel).filter(ConsumerModel.id == consumer_id).first()
+        consumer = db.query(ConsumerModel).filter(
+            ConsumerModel.id == consumer_id,
+            ConsumerModel.workspace == workspace
+        ).first()
         if not consumer:
             result["valid"] = False
             result["error"] = f"Consumer with id '{consumer_id}' not found"
@@ -92,7 +127,7 @@ def validate_plugin_scope(
     
     else:
         result["valid"] = False
-        result["error"] = f"Invalid scope: {scope}. Must be one of: global, service, route, consumer"
+        result["error"] = f"Invalid scope: {scope}. Must be one of: global, service, route, group, consumer"
     
     return result
 
@@ -100,7 +135,8 @@ def validate_plugin_scope(
 @router.post("", response_model=PluginResponse, status_code=status.HTTP_201_CREATED)
 def create_plugin(
     plugin: PluginCreate,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Create a new plugin.
@@ -108,9 +144,10 @@ def create_plugin(
     Plugins add functionality to the gateway (auth, rate limiting, caching, etc).
     
     Scopes:
-    - global: Applies to all requests
+    - global: Applies to all routes in the workspace
     - service: Applies to all routes of a service
     - route: Applies to a specific route
+    - group: Applies to all routes of a route group
     - consumer: Applies to a specific consumer
     """
     logger.info(
@@ -128,7 +165,9 @@ def create_plugin(
         plugin.service_id,
         plugin.route_id,
         plugin.consumer_id,
-        db
+        db,
+        workspace,
+        group_id=plugin.group_id
     )
     
     if not validation["valid"]:
@@ -145,8 +184,10 @@ def create_plugin(
             detail=validation["error"]
         )
     
-    # Create plugin
-    db_plugin = PluginModel(**plugin.model_dump())
+    # Create plugin (designated config secrets are encrypted at rest)
+    plugin_data = plugin.model_dump()
+    plugin_data["config"] = encrypt_config(plugin_data.get("config"))
+    db_plugin = PluginModel(**plugin_data, workspace=workspace)
     
     try:
         db.add(db_plugin)
@@ -155,6 +196,7 @@ def create_plugin(
         
         # Publish config change event
         publish_plugin_change(db_plugin.id, "created", {
+            "workspace": workspace,
             "name": db_plugin.name,
             "scope": db_plugin.scope,
             "priority": db_plugin.priority
@@ -201,22 +243,27 @@ def list_plugins(
     name: Optional[str] = None,
     service_id: Optional[UUID] = None,
     route_id: Optional[UUID] = None,
+    group_id: Optional[UUID] = None,
     consumer_id: Optional[UUID] = None,
     enabled_only: bool = False,
-    db: Session = Depends(get_db)
+    tags: Optional[str] = None,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
-    List all plugins.
+    List all plugins in the workspace.
     
     Query parameters:
     - skip: Number of records to skip (pagination)
     - limit: Maximum number of records to return
-    - scope: Filter by scope (global, service, route, consumer)
+    - scope: Filter by scope (global, service, route, group, consumer)
     - name: Filter by plugin name
     - service_id: Filter by service
     - route_id: Filter by route
+    - group_id: Filter by route group
     - consumer_id: Filter by consumer
     - enabled_only: If true, only return enabled plugins
+    - tags: Comma-separated tags; only plugins with all of them are returned
     """
     logger.debug(
         "Listing plugins",
@@ -229,7 +276,7 @@ def list_plugins(
         }
     )
     
-    query = db.query(PluginModel)
+    query = db.query(PluginModel).filter(PluginModel.workspace == workspace)
     
     # Apply filters
     if scope:
@@ -243,6 +290,8 @@ def list_plugins(
     
     if route_id:
         query = query.filter(PluginModel.route_id == route_id)
+    if group_id:
+        query = query.filter(PluginModel.group_id == group_id)
     
     if consumer_id:
         query = query.filter(PluginModel.consumer_id == consumer_id)
@@ -250,6 +299,9 @@ def list_plugins(
     if enabled_only:
         query = query.filter(PluginModel.enabled == True)
     
+    for tag in split_tags(tags):
+        query = query.filter(PluginModel.tags.any(tag))
+    
     # Order by priority (lower = runs first)
     query = query.order_by(PluginModel.priority.asc())
     
@@ -304,6 +356,101 @@ def list_available_plugins():
                 "config_schema": {
                     "hide_credentials": True
                 }
+            },
+            {
+                "name": "paseto-auth",
+                "description": "PASETO v2/v4 public token authentication",
+                "config_schema": {
+                    "versions": ["v4"],
+                    "public_keys": ["<ed25519 public key>"],
+                    "issuer": "",
+                    "audiences": [],
+                    "consumer_claim": "sub",
+                    "leeway": "30s",
+                    "required_scopes": [],
+                    "required_roles": [],
+                    "roles_claim": "roles",
+                    "claims_to_headers": {}
+                }
+            },
+            {
+                "name": "opaque-token-auth",
+                "description": "Opaque bearer token lookup (Redis or database)",
+                "config_schema": {
+                    "backend": "redis",
+                    "redis_url": "redis://localhost:6379/0",
+                    "key_prefix": "opaque-token:",
+                    "cache_ttl": "10s",
+                    "hide_credentials": True
+                }
+            },
+            {
+                "name": "ldap-auth",
+                "description": "LDAP / Active Directory authentication (HTTP Basic)",
+                "config_schema": {
+                    "url": "ldaps://ldap.internal:636",
+                    "bind_dn_template": "uid={username},ou=people,dc=example,dc=com",
+                    "group_attribute": "memberOf",
+                    "allowed_groups": [],
+                    "cache_ttl": "5m",
+                    "pool_size": 10
+                }
+            },
+            {
+                "name": "session-auth",
+                "description": "Browser session cookie validated in Redis, forwarded upstream as a bearer token",
+                "config_schema": {
+                    "cookie_name": "sb_session",
+                    "redis_url": "redis://localhost:6379/0",
+                    "key_prefix": "session:",
+                    "hash_session_id": True,
+                    "cache_ttl": "10s",
+                    "idle_timeout": "",
+                    "header_name": "Authorization",
+                    "hide_cookie": True
+                }
+            },
+            {
+                "name": "token-exchange",
+                "description": "Replace the client credential with a short-lived gateway-signed JWT (needs TOKEN_MINT_KEY_FILES)",
+                "config_schema": {
+                    "ttl": "60s",
+                    "audience": "",
+                    "claims": [],
+                    "header_name": "Authorization",
+                    "allow_anonymous": False
+                }
+            },
+            {
+                "name": "signed-url",
+                "description": "Admit time-limited gateway-signed links (needs SIGNED_URL_SECRETS)",
+                "config_schema": {
+                    "methods": ["GET", "HEAD"],
+                    "strip_params": True
+                }
+            },
+            {
+                "name": "auth-summary",
+                "description": "Tell backends how a request was authenticated (mechanism, scopes, selected claims)",
+                "config_schema": {
+                    "claims": [],
+                    "mechanism_header": "X-Auth-Mechanism",
+                    "scopes_header": "X-Auth-Scopes",
+                    "claims_header": "X-Auth-Claims"
+                }
+            },
+            {
+                "name": "upstream-auth",
+                "description": "Sign requests to the backend (AWS SigV4, bearer token, HMAC)",
+                "config_schema": {
+                    "type": "aws_sigv4",
+                    "region": "us-east-1",
+                    "service": "execute-api",
+                    "credentials": {
+                        "access_key_id": "AKIA...",
+                        "secret_access_key": "..."
+                    }
+                }
             }
         ],
         "traffic_control": [
@@ -317,6 +464,31 @@ def list_available_plugins():
                     "key_by": "consumer"
                 }
             },
+            {
+                "name": "idempotency",
+                "description": "Replay stored responses for duplicate Idempotency-Key requests",
+                "config_schema": {
+                    "header_name": "Idempotency-Key",
+                    "methods": ["POST", "PATCH"],
+                    "required": False,
+                    "ttl": "24h",
+                    "lock_ttl": "30s",
+                    "max_body_bytes": 1048576,
+                    "scope": "auto"
+                }
+            },
+            {
+                "name": "header-limits",
+                "description": "Tighter request header size/count limits and duplicate header handling",
+                "config_schema": {
+                    "max_bytes": 8192,
+                    "max_count": 30,
+                    "max_value_bytes": 4096,
+                    "oversized": "reject",
+                    "single_valued": ["Authorization", "Content-Type"],
+                    "duplicates": "reject"
+                }
+            },
             {
                 "name": "request-size-limit",
                 "description": "Limit request body size",
@@ -355,6 +527,40 @@ def list_available_plugins():
                     "half_open_requests": 3
                 }
             },
+            {
+                "name": "fault-injection",
+                "description": "Inject latency and errors for chaos testing (non-production only)",
+                "config_schema": {
+                    "delay": "200ms",
+                    "delay_jitter": "100ms",
+                    "delay_percentage": 100,
+                    "abort_percentage": 10,
+                    "abort_status": 503,
+                    "consumers": [],
+                    "override_header": ""
+                }
+            },
+            {
+                "name": "response-validator",
+                "description": "Reject upstream responses with unexpected content types or status codes",
+                "config_schema": {
+                    "content_types": ["application/json"],
+                    "status_codes": ["2xx", "4xx"],
+                    "action": "reject",
+                    "status": 502,
+                    "message": "Upstream returned an unexpected response"
+                }
+            },
+            {
+                "name": "response-size-limit",
+                "description": "Cap upstream response sizes; oversized responses get a 502/507 or are truncated",
+                "config_schema": {
+                    "max_bytes": 10485760,
+                    "status": 502,
+                    "message": "Upstream response too large",
+                    "buffer": True
+                }
+            },
             {
                 "name": "timeout",
                 "description": "Request timeout enforcement",
@@ -364,6 +570,32 @@ def list_available_plugins():
                 }
             }
         ],
+        "debugging": [
+            {
+                "name": "request-recorder",
+                "description": "Sample requests for replay with `gateway replay`",
+                "config_schema": {
+                    "sample_rate": 1,
+                    "record_body": False,
+                    "max_body_bytes": 65536,
+                    "redact_headers": ["Authorization", "Cookie", "X-API-Key"]
+                }
+            }
+        ],
+        "billing": [
+            {
+                "name": "metering",
+                "description": "Emit per-consumer billing records to the metering sink (METERING_SINK)",
+                "config_schema": {
+                    "product": "search-api",
+                    "measure": "requests",
+                    "unit": "requests",
+                    "units_header": "X-Billing-Units",
+                    "bill_errors": False,
+                    "consumer_key": "consumer_id"
+                }
+            }
+        ],
         "transformation": [
             {
                 "name": "cors",
@@ -383,6 +615,213 @@ def list_available_plugins():
                     "remove_headers": [],
                     "replace_headers": {}
                 }
+            },
+            {
+                "name": "grpc-transcode",
+                "description": "Expose a gRPC service as REST/JSON using google.api.http annotations",
+                "config_schema": {
+                    "descriptor_file": "/etc/gateway/orders.pb",
+                    "services": ["shop.v1.Orders"],
+                    "path_prefix": "/api",
+                    "use_proto_names": False,
+                    "emit_unpopulated": False
+                }
+            },
+            {
+                "name": "xml-transform",
+                "description": "Edit XML/SOAP bodies with XPath and convert between JSON and XML",
+                "config_schema": {
+                    "namespaces": {"soap": "http://schemas.xmlsoap.org/soap/envelope/"},
+                    "request": {
+                        "remove": ["//soap:Header/Debug"],
+                        "set": [{"path": "/soap:Envelope/soap:Header/Consumer", "value": "{consumer_id}"}]
+                    },
+                    "response": {"remove": ["//InternalId"]},
+                    "to_json": False,
+                    "unwrap_soap": False,
+                    "from_json": False,
+                    "xml_content_type": "application/xml",
+                    "max_body_bytes": 1048576
+                }
+            },
+            {
+                "name": "upstream-timing",
+                "description": "Add X-Timing and Server-Timing headers with the upstream dns/connect/tls/ttfb latency breakdown",
+                "config_schema": {}
+            },
+            {
+                "name": "hedging",
+                "description": "Send slow idempotent requests to a second target and use the first response",
+                "config_schema": {
+                    "percentile": 0.95,
+                    "min_delay_ms": 10,
+                    "max_delay_ms": 1000
+                }
+            },
+            {
+                "name": "route-permission",
+                "description": "Restrict consumers or groups to allow-listed routes, paths and methods",
+                "config_schema": {
+                    "consumers": {"<consumer id or username>": [{"paths": ["/orders/*"], "methods": ["GET"]}]},
+                    "groups": {"<group>": [{"routes": ["<route name>"]}]},
+                    "groups_key": "ldap_groups",
+                    "allow_unlisted": False
+                }
+            },
+            {
+                "name": "batch-schedule",
+                "description": "Hold or reject batch consumers during peak hours with Retry-After pointing at off-peak",
+                "config_schema": {
+                    "consumers": ["<consumer id or username>"],
+                    "groups": ["<group>"],
+                    "groups_key": "ldap_groups",
+                    "peak": "* 8-17 * * 1-5",
+                    "timezone": "UTC",
+                    "peak_quota": 0,
+                    "max_defer_ms": 0,
+                    "max_deferred": 100,
+                    "message": "Batch requests are not accepted during peak hours"
+                }
+            },
+            {
+                "name": "replay-protection",
+                "description": "Reject stale or replayed requests using a nonce and timestamp checked in Redis",
+                "config_schema": {
+                    "nonce_header": "X-Nonce",
+                    "timestamp_header": "X-Timestamp",
+                    "timestamp_format": "unix",
+                    "max_skew": "5m",
+                    "min_nonce_length": 16,
+                    "scope": "auto",
+                    "critical": True,
+                    "redis_url": "redis://localhost:6379/0",
+                    "key_prefix": "replay:"
+                }
+            },
+            {
+                "name": "webhook-verify",
+                "description": "Verify Stripe, GitHub or Slack webhook signatures and mark verified requests",
+                "config_schema": {
+                    "provider": "stripe",
+                    "credentials": {"secrets": ["<signing secret>"]},
+                    "tolerance": "5m",
+                    "max_body_bytes": 1048576
+                }
+            },
+            {
+                "name": "waf",
+                "description": "Block or report SQLi, XSS and path traversal signatures in path, query, headers and body",
+                "config_schema": {
+                    "action": "block",
+                    "sensitivity": "medium",
+                    "rule_sets": ["sqli", "xss", "path_traversal"],
+                    "targets": ["path", "query", "headers"],
+                    "disabled_rules": [],
+                    "rules": [],
+                    "max_body_bytes": 65536
+                }
+            },
+            {
+                "name": "json-firewall",
+                "description": "Reject JSON bodies over nesting, array, key and string limits or with disallowed keys",
+                "config_schema": {
+                    "max_body_bytes": 1048576,
+                    "max_depth": 20,
+                    "max_array_length": 1000,
+                    "max_object_keys": 1000,
+                    "max_string_length": 65536,
+                    "disallowed_keys": ["__proto__", "constructor", "prototype"],
+                    "require_json": False
+                }
+            },
+            {
+                "name": "static-files",
+                "description": "Serve the route from a local directory or S3-compatible bucket instead of a backend",
+                "config_schema": {
+                    "source": "directory",
+                    "root": "/var/www/assets",
+                    "strip_prefix": "/assets",
+                    "index": "index.html",
+                    "cache_control": "public, max-age=300",
+                    "fallthrough": False
+                }
+            },
+            {
+                "name": "request-aggregator",
+                "description": "Fan a request out to several backends in parallel and merge their JSON responses",
+                "config_schema": {
+                    "branches": [
+                        {"name": "user", "url": "http://users.svc/users/{id}", "timeout": "500ms", "required": True},
+                        {"name": "orders", "url": "http://orders.svc/orders?user={id}", "timeout": "1s"}
+                    ],
+                    "timeout": "2s",
+                    "forward_headers": ["Authorization", "Accept-Language"],
+                    "errors_key": "_errors"
+                }
+            },
+            {
+                "name": "pagination",
+                "description": "Rewrite backend pagination into a standard envelope or Link headers",
+                "config_schema": {
+                    "items": "results",
+                    "next": "meta.next_token",
+                    "prev": "meta.prev_token",
+                    "total": "meta.count",
+                    "link_header": False,
+                    "cursor_param": "cursor",
+                    "format": "envelope"
+                }
+            },
+            {
+                "name": "etag",
+                "description": "Compute ETags for responses and answer If-None-Match / If-Modified-Since with 304",
+                "config_schema": {
+                    "weak": False,
+                    "override": False,
+                    "max_body_bytes": 1048576
+                }
+            },
+            {
+                "name": "negative-cache",
+                "description": "Replay 404/401 and other 4xx responses for a short TTL instead of hitting the backend",
+                "config_schema": {
+                    "ttls": {"404": "30s", "410": "30s", "401": "5s", "403": "5s"},
+                    "methods": ["GET", "HEAD"],
+                    "credential_headers": ["Authorization", "X-API-Key"],
+                    "credential_cookies": ["sb_session"],
+                    "max_entries": 10000,
+                    "max_body_bytes": 65536
+                }
+            },
+            {
+                "name": "cache",
+                "description": "Serve repeated requests from cached upstream responses, and expired copies when the upstream fails; purge by route, path or Surrogate-Key tag",
+                "config_schema": {
+                    "ttl": "1m",
+                    "methods": ["GET", "HEAD"],
+                    "statuses": [200],
+                    "vary_headers": ["Accept", "Accept-Encoding"],
+                    "credential_headers": ["Authorization", "X-API-Key"],
+                    "credential_cookies": ["sb_session"],
+                    "max_body_bytes": 1048576,
+                    "stale_if_error": ""
+                }
+            },
+            {
+                "name": "experiments",
+                "description": "Deterministic A/B variant assignment with X-Experiment-* headers and an optional sticky cookie",
+                "config_schema": {
+                    "experiments": [
+                        {"name": "checkout-redesign", "variants": [
+                            {"name": "control", "percent": 50},
+                            {"name": "new-flow", "percent": 50}
+                        ]}
+                    ],
+                    "key": "consumer_or_ip",
+                    "header_prefix": "X-Experiment-",
+                    "sticky_cookie": "",
+                    "cookie_max_age": "720h"
+                }
             }
         ]
     }
@@ -394,7 +833,8 @@ def list_available_plugins():
 @router.get("/{plugin_id}", response_model=PluginResponse)
 def get_plugin(
     plugin_id: UUID,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Get a specific plugin by ID.
@@ -404,7 +844,10 @@ def get_plugin(
         extra={"plugin_id": str(plugin_id)}
     )
     
-    plugin = db.query(PluginModel).filter(PluginModel.id == plugin_id).first()
+    plugin = db.query(PluginModel).filter(
+        PluginModel.id == plugin_id,
+        PluginModel.workspace == workspace
+    ).first()
     
     if not plugin:
         logger.warning(
@@ -432,7 +875,8 @@ def get_plugin(
 def update_plugin(
     plugin_id: UUID,
     plugin_update: PluginUpdate,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Update a plugin.
@@ -447,7 +891,10 @@ def update_plugin(
     )
     
     # Get existing plugin
-    db_plugin = db.query(PluginModel).filter(PluginModel.id == plugin_id).first()
+    db_plugin = db.query(PluginModel).filter(
+        PluginModel.id == plugin_id,
+        PluginModel.workspace == workspace
+    ).first()
     
     if not db_plugin:
         logger.warning(
@@ -461,21 +908,26 @@ def update_plugin(
     
     # Get update data
     update_data = plugin_update.model_dump(exclude_unset=True)
+    if "config" in update_data:
+        update_data["config"] = encrypt_config(update_data["config"])
     
     # Determine final scope and IDs (use updated values or existing)
     final_scope = update_data.get("scope", db_plugin.scope)
     final_service_id = update_data.get("service_id", db_plugin.service_id)
     final_route_id = update_data.get("route_id", db_plugin.route_id)
     final_consumer_id = update_data.get("consumer_id", db_plugin.consumer_id)
+    final_group_id = update_data.get("group_id", db_plugin.group_id)
     
     # Validate scope if any scope-related field is being updated
-    if any(key in update_data for key in ["scope", "service_id", "route_id", "consumer_id"]):
+    if any(key in update_data for key in ["scope", "service_id", "route_id", "group_id", "consumer_id"]):
         validation = validate_plugin_scope(
             final_scope,
             final_service_id,
             final_route_id,
             final_consumer_id,
-            db
+            db,
+            workspace,
+            group_id=final_group_id
         )
         
         if not validation["valid"]:
@@ -502,6 +954,7 @@ def update_plugin(
         
         # Publish config change event
         publish_plugin_change(plugin_id, "updated", {
+            "workspace": workspace,
             "name": db_plugin.name,
             "updated_fields": list(update_data.keys())
         })
@@ -534,7 +987,8 @@ def update_plugin(
 @router.delete("/{plugin_id}", status_code=status.HTTP_204_NO_CONTENT)
 def delete_plugin(
     plugin_id: UUID,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Delete a plugin.
@@ -545,7 +999,10 @@ def delete_plugin(
     )
     
     # Get plugin
-    db_plugin = db.query(PluginModel).filter(PluginModel.id == plugin_id).first()
+    db_plugin = db.query(PluginModel).filter(
+        PluginModel.id == plugin_id,
+        PluginModel.workspace == workspace
+    ).first()
     
     if not db_plugin:
         logger.warning(
@@ -566,6 +1023,7 @@ def delete_plugin(
         
         # Publish config change event
         publish_plugin_change(plugin_id, "deleted", {
+            "workspace": workspace,
             "name": plugin_name,
             "scope": plugin_scope
         })
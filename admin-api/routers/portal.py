@@ -0,0 +1,454 @@
+"""Developer self-service portal endpoints.
+
+Lets a developer create their own consumers and issue, rotate and revoke
+their own API keys within the limits set by PORTAL_* settings, without
+going through the operators who own the rest of the Admin API.
+
+Developers are identified by a header (PORTAL_IDENTITY_HEADER, default
+X-Forwarded-Email) that the SSO proxy in front of the Admin API sets. The
+portal must only be reachable through that proxy; set PORTAL_PROXY_SECRET
+so requests that bypass it are rejected.
+
+Portal consumers live in the PORTAL_WORKSPACE workspace (default "default").
+"""
+
+from fastapi import APIRouter, Depends, HTTPException, Request, status
+from sqlalchemy.orm import Session
+from typing import List
+import logging
+import hmac
+from uuid import UUID
+from datetime import datetime, timedelta, timezone
+
+from config import get_settings
+from database import get_db
+from models import Consumer as ConsumerModel, APIKey as APIKeyModel
+from schemas import ConsumerResponse, PortalConsumerCreate, PortalKeyCreate
+from routers.consumers import generate_api_key
+
+logger = logging.getLogger(__name__)
+
+router = APIRouter()
+
+settings = get_settings()
+
+
+def _split(value: str) -> list[str]:
+    """Split a comma-separated setting."""
+    return [item.strip() for item in value.split(",") if item.strip()]
+
+
+# ============================================================================
+# Developer identity
+# ============================================================================
+
+def get_developer(request: Request) -> str:
+    """
+    Dependency that returns the calling developer's identity (email).
+    
+    Raises 404 when the portal is disabled, so it is not discoverable.
+    """
+    if not settings.portal_enabled:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Not Found")
+    
+    if settings.portal_proxy_secret:
+        supplied = request.headers.get("X-Portal-Proxy-Secret", "")
+        if not hmac.compare_digest(supplied.encode(), settings.portal_proxy_secret.encode()):
+            logger.warning("Portal request without a valid proxy secret")
+            raise HTTPException(status_code=status.HTTP_401_UNAUTHORIZED, detail="Not authenticated")
+    
+    developer = request.headers.get(settings.portal_identity_header, "").strip().lower()
+    if not developer:
+        raise HTTPException(status_code=status.HTTP_401_UNAUTHORIZED, detail="Not authenticated")
+    
+    domains = [d.lower() for d in _split(settings.portal_allowed_domains)]
+    if domains and developer.rsplit("@", 1)[-1] not in domains:
+        logger.warning("Portal access denied - domain not allowed", extra={"developer": developer})
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="Developer not allowed")
+    
+    return developer
+
+
+def get_owned_consumer(db: Session, consumer_id: UUID, developer: str) -> ConsumerModel:
+    """Get a consumer owned by the developer (404 otherwise, never 403)."""
+    consumer = db.query(ConsumerModel).filter(
+        ConsumerModel.id == consumer_id,
+        ConsumerModel.workspace == settings.portal_workspace,
+        ConsumerModel.owner == developer
+    ).first()
+    
+    if not consumer:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"Consumer with id '{consumer_id}' not found"
+        )
+    return consumer
+
+
+def active_keys(db: Session, consumer_id: UUID) -> List[APIKeyModel]:
+    """Enabled, unexpired keys of a consumer (what counts toward the limit)."""
+    now = datetime.now(timezone.utc)
+    keys = db.query(APIKeyModel).filter(
+        APIKeyModel.consumer_id == consumer_id,
+        APIKeyModel.enabled == True  # noqa: E712
+    ).all()
+    return [k for k in keys if k.expires_at is None or _aware(k.expires_at) > now]
+
+
+def _aware(dt: datetime) -> datetime:
+    """Treat naive timestamps (TIMESTAMP columns) as UTC."""
+    return dt if dt.tzinfo else dt.replace(tzinfo=timezone.utc)
+
+
+def key_response(key: APIKeyModel) -> dict:
+    """Key info without the plaintext key."""
+    return {
+        "id": str(key.id),
+        "name": key.name,
+        "scopes": key.scopes or [],
+        "enabled": key.enabled,
+        "created_at": key.created_at.isoformat() if key.created_at else None,
+        "last_used_at": key.last_used_at.isoformat() if key.last_used_at else None,
+        "expires_at": key.expires_at.isoformat() if key.expires_at else None,
+        "key_preview": key.key_hash[:8] + "..."
+    }
+
+
+def issue_key(
+    db: Session,
+    consumer: ConsumerModel,
+    name: str,
+    scopes: List[str],
+    expires_in_days: int = None
+) -> tuple[APIKeyModel, str]:
+    """Validate scopes and lifetime against policy and add a new key."""
+    allowed = set(_split(settings.portal_allowed_scopes))
+    denied = sorted(set(scopes) - allowed)
+    if denied:
+        raise HTTPException(
+            status_code=status.HTTP_422_UNPROCESSABLE_ENTITY,
+            detail=f"Scopes not allowed: {denied} (allowed: {sorted(allowed)})"
+        )
+    
+    max_days = settings.portal_key_max_ttl_days
+    days = expires_in_days or max_days
+    if days > max_days:
+        raise HTTPException(
+            status_code=status.HTTP_422_UNPROCESSABLE_ENTITY,
+            detail=f"expires_in_days cannot exceed {max_days}"
+        )
+    
+    plaintext_key, hashed_key = generate_api_key()
+    db_key = APIKeyModel(
+        consumer_id=consumer.id,
+        key_hash=hashed_key,
+        name=name,
+        scopes=sorted(set(scopes)),
+        enabled=True,
+        expires_at=datetime.now(timezone.utc) + timedelta(days=days)
+    )
+    db.add(db_key)
+    return db_key, plaintext_key
+
+
+def issued_key_response(key: APIKeyModel, plaintext_key: str, consumer: ConsumerModel) -> dict:
+    """Response for a newly issued key (the only time it is shown)."""
+    response = key_response(key)
+    response.update({
+        "key": plaintext_key,
+        "consumer_id": str(consumer.id),
+        "consumer_username": consumer.username,
+        "warning": "Save this key now! It cannot be retrieved later."
+    })
+    return response
+
+
+# ============================================================================
+# Portal endpoints
+# ============================================================================
+
+@router.get("/me")
+def get_me(
+    developer: str = Depends(get_developer),
+    db: Session = Depends(get_db)
+):
+    """
+    Get the calling developer's identity, usage and policy limits.
+    """
+    consumer_count = db.query(ConsumerModel).filter(
+        ConsumerModel.workspace == settings.portal_workspace,
+        ConsumerModel.owner == developer
+    ).count()
+    
+    return {
+        "developer": developer,
+        "consumers": consumer_count,
+        "policy": {
+            "max_consumers": settings.portal_max_consumers,
+            "max_keys_per_consumer": settings.portal_max_keys_per_consumer,
+            "allowed_scopes": _split(settings.portal_allowed_scopes),
+            "key_max_ttl_days": settings.portal_key_max_ttl_days,
+            "rotation_grace_hours": settings.portal_rotation_grace_hours
+        }
+    }
+
+
+@router.post("/consumers", response_model=ConsumerResponse, status_code=status.HTTP_201_CREATED)
+def create_consumer(
+    consumer: PortalConsumerCreate,
+    developer: str = Depends(get_developer),
+    db: Session = Depends(get_db)
+):
+    """
+    Create a consumer owned by the calling developer.
+    """
+    logger.info(
+        "Portal: creating consumer",
+        extra={"developer": developer, "username": consumer.username}
+    )
+    
+    owned = db.query(ConsumerModel).filter(
+        ConsumerModel.workspace == settings.portal_workspace,
+        ConsumerModel.owner == developer
+    ).count()
+    if owned >= settings.portal_max_consumers:
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail=f"Consumer limit reached ({settings.portal_max_consumers})"
+        )
+    
+    existing = db.query(ConsumerModel).filter(
+        ConsumerModel.workspace == settings.portal_workspace,
+        ConsumerModel.username == consumer.username
+    ).first()
+    if existing:
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail=f"Consumer with username '{consumer.username}' already exists"
+        )
+    
+    db_consumer = ConsumerModel(
+        workspace=settings.portal_workspace,
+        username=consumer.username,
+        custom_id=consumer.custom_id,
+        email=developer,
+        owner=developer,
+        custom_metadata={"created_via": "portal"}
+    )
+    
+    try:
+        db.add(db_consumer)
+        db.commit()
+        db.refresh(db_consumer)
+        
+        logger.info(
+            "Portal: consumer created",
+            extra={"developer": developer, "consumer_id": str(db_consumer.id)}
+        )
+        
+        return db_consumer
+        
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Portal: failed to create consumer",
+            extra={"developer": developer, "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to create consumer"
+        )
+
+
+@router.get("/consumers", response_model=List[ConsumerResponse])
+def list_consumers(
+    developer: str = Depends(get_developer),
+    db: Session = Depends(get_db)
+):
+    """
+    List the calling developer's consumers.
+    """
+    return db.query(ConsumerModel).filter(
+        ConsumerModel.workspace == settings.portal_workspace,
+        ConsumerModel.owner == developer
+    ).all()
+
+
+@router.post("/consumers/{consumer_id}/keys", status_code=status.HTTP_201_CREATED)
+def create_key(
+    consumer_id: UUID,
+    key: PortalKeyCreate,
+    developer: str = Depends(get_developer),
+    db: Session = Depends(get_db)
+):
+    """
+    Issue an API key for one of the developer's consumers.
+    
+    ⚠️ The plaintext key is only returned once.
+    
+    Keys always expire (at most PORTAL_KEY_MAX_TTL_DAYS) and may only carry
+    scopes from PORTAL_ALLOWED_SCOPES.
+    """
+    consumer = get_owned_consumer(db, consumer_id, developer)
+    
+    if len(active_keys(db, consumer_id)) >= settings.portal_max_keys_per_consumer:
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail=f"Key limit reached ({settings.portal_max_keys_per_consumer}); rotate or revoke a key"
+        )
+    
+    db_key, plaintext_key = issue_key(db, consumer, key.name, key.scopes, key.expires_in_days)
+    
+    try:
+        db.commit()
+        db.refresh(db_key)
+        
+        logger.info(
+            "Portal: API key issued",
+            extra={
+                "developer": developer,
+                "consumer_id": str(consumer_id),
+                "key_id": str(db_key.id),
+                "scopes": db_key.scopes
+            }
+        )
+        
+        return issued_key_response(db_key, plaintext_key, consumer)
+        
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Portal: failed to issue API key",
+            extra={"developer": developer, "consumer_id": str(consumer_id), "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to generate API key"
+        )
+
+
+@router.get("/consumers/{consumer_id}/keys")
+def list_keys(
+    consumer_id: UUID,
+    developer: str = Depends(get_developer),
+    db: Session = Depends(get_db)
+):
+    """
+    List the API keys of one of the developer's consumers.
+    """
+    get_owned_consumer(db, consumer_id, developer)
+    keys = db.query(APIKeyModel).filter(APIKeyModel.consumer_id == consumer_id).all()
+    return [key_response(k) for k in keys]
+
+
+@router.post("/consumers/{consumer_id}/keys/{key_id}/rotate", status_code=status.HTTP_201_CREATED)
+def rotate_key(
+    consumer_id: UUID,
+    key_id: UUID,
+    developer: str = Depends(get_developer),
+    db: Session = Depends(get_db)
+):
+    """
+    Rotate an API key.
+    
+    Issues a new key with the same name and scopes, and shortens the old
+    key's lifetime to PORTAL_ROTATION_GRACE_HOURS so clients can switch
+    over without downtime. Rotation is allowed at the key limit, since the
+    old key is on its way out.
+    """
+    consumer = get_owned_consumer(db, consumer_id, developer)
+    
+    old_key = db.query(APIKeyModel).filter(
+        APIKeyModel.id == key_id,
+        APIKeyModel.consumer_id == consumer_id
+    ).first()
+    if not old_key or not old_key.enabled:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"API key with id '{key_id}' not found for this consumer"
+        )
+    
+    new_key, plaintext_key = issue_key(db, consumer, old_key.name, old_key.scopes or [])
+    
+    grace_end = datetime.now(timezone.utc) + timedelta(hours=settings.portal_rotation_grace_hours)
+    if old_key.expires_at is None or _aware(old_key.expires_at) > grace_end:
+        old_key.expires_at = grace_end
+    
+    try:
+        db.commit()
+        db.refresh(new_key)
+        
+        logger.info(
+            "Portal: API key rotated",
+            extra={
+                "developer": developer,
+                "consumer_id": str(consumer_id),
+                "old_key_id": str(key_id),
+                "new_key_id": str(new_key.id)
+            }
+        )
+        
+        response = issued_key_response(new_key, plaintext_key, consumer)
+        response["rotated_key_id"] = str(key_id)
+        response["rotated_key_expires_at"] = old_key.expires_at.isoformat()
+        return response
+        
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Portal: failed to rotate API key",
+            extra={"developer": developer, "key_id": str(key_id), "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to rotate API key"
+        )
+
+
+@router.delete("/consumers/{consumer_id}/keys/{key_id}", status_code=status.HTTP_204_NO_CONTENT)
+def revoke_key(
+    consumer_id: UUID,
+    key_id: UUID,
+    developer: str = Depends(get_developer),
+    db: Session = Depends(get_db)
+):
+    """
+    Revoke (delete) one of the developer's API keys immediately.
+    """
+    get_owned_consumer(db, consumer_id, developer)
+    
+    api_key = db.query(APIKeyModel).filter(
+        APIKeyModel.id == key_id,
+        APIKeyModel.consumer_id == consumer_id
+    ).first()
+    if not api_key:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"API key with id '{key_id}' not found for this consumer"
+        )
+    
+    try:
+        db.delete(api_key)
+        db.commit()
+        
+        logger.info(
+            "Portal: API key revoked",
+            extra={"developer": developer, "consumer_id": str(consumer_id), "key_id": str(key_id)}
+        )
+        
+        return None
+        
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Portal: failed to revoke API key",
+            extra={"developer": developer, "key_id": str(key_id), "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to revoke API key"
+        )
@@ -0,0 +1,243 @@
+"""Redirects CRUD API endpoints."""
+
+from fastapi import APIRouter, Depends, HTTPException, status
+from sqlalchemy.orm import Session
+from typing import List
+import logging
+from uuid import UUID
+
+from database import get_db
+from models import Redirect as RedirectModel
+from schemas import RedirectCreate, RedirectUpdate, RedirectResponse
+from events import publish_redirect_change
+
+
+logger = logging.getLogger(__name__)
+
+router = APIRouter()
+
+
+def _get_redirect_or_404(db: Session, redirect_id: UUID) -> RedirectModel:
+    """Load a redirect or raise 404."""
+    redirect = db.query(RedirectModel).filter(RedirectModel.id == redirect_id).first()
+    if not redirect:
+        logger.warning(
+            "Redirect not found",
+            extra={"redirect_id": str(redirect_id)}
+        )
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"Redirect with id '{redirect_id}' not found"
+        )
+    return redirect
+
+
+@router.post("", response_model=RedirectResponse, status_code=status.HTTP_201_CREATED)
+def create_redirect(
+    redirect: RedirectCreate,
+    db: Session = Depends(get_db)
+):
+    """
+    Create a new redirect rule.
+
+    Matching requests are answered with status_code and a Location rendered
+    from the template ({host}, {path}, {<param>}, {*}) instead of being proxied.
+    """
+    logger.info(
+        "Creating redirect",
+        extra={
+            "redirect_name": redirect.name,
+            "paths": redirect.paths,
+            "status_code": redirect.status_code
+        }
+    )
+
+    if redirect.name:
+        existing = db.query(RedirectModel).filter(RedirectModel.name == redirect.name).first()
+        if existing:
+            raise HTTPException(
+                status_code=status.HTTP_409_CONFLICT,
+                detail=f"Redirect with name '{redirect.name}' already exists"
+            )
+
+    db_redirect = RedirectModel(**redirect.model_dump())
+
+    try:
+        db.add(db_redirect)
+        db.commit()
+        db.refresh(db_redirect)
+
+        publish_redirect_change(db_redirect.id, "created", {
+            "name": db_redirect.name,
+            "paths": db_redirect.paths
+        })
+
+        logger.info(
+            "Redirect created successfully",
+            extra={"redirect_id": str(db_redirect.id), "redirect_name": db_redirect.name}
+        )
+
+        return db_redirect
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to create redirect",
+            extra={"redirect_name": redirect.name, "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to create redirect"
+        )
+
+
+@router.get("", response_model=List[RedirectResponse])
+def list_redirects(
+    skip: int = 0,
+    limit: int = 100,
+    enabled_only: bool = False,
+    db: Session = Depends(get_db)
+):
+    """
+    List redirect rules in evaluation order (oldest first).
+
+    Query parameters:
+    - skip: Number of records to skip (pagination)
+    - limit: Maximum number of records to return
+    - enabled_only: If true, only return enabled redirects
+    """
+    query = db.query(RedirectModel)
+
+    if enabled_only:
+        query = query.filter(RedirectModel.enabled == True)
+
+    redirects = query.order_by(RedirectModel.created_at).offset(skip).limit(limit).all()
+
+    logger.info(
+        "Redirects retrieved",
+        extra={"count": len(redirects), "enabled_only": enabled_only}
+    )
+
+    return redirects
+
+
+@router.get("/{redirect_id}", response_model=RedirectResponse)
+def get_redirect(
+    redirect_id: UUID,
+    db: Session = Depends(get_db)
+):
+    """
+    Get a specific redirect by ID.
+    """
+    return _get_redirect_or_404(db, redirect_id)
+
+
+@router.put("/{redirect_id}", response_model=RedirectResponse)
+def update_redirect(
+    redirect_id: UUID,
+    redirect_update: RedirectUpdate,
+    db: Session = Depends(get_db)
+):
+    """
+    Update a redirect.
+
+    Only provided fields will be updated. Omitted fields remain unchanged.
+    """
+    logger.info(
+        "Updating redirect",
+        extra={"redirect_id": str(redirect_id)}
+    )
+
+    db_redirect = _get_redirect_or_404(db, redirect_id)
+
+    if redirect_update.name and redirect_update.name != db_redirect.name:
+        existing = db.query(RedirectModel).filter(
+            RedirectModel.name == redirect_update.name,
+            RedirectModel.id != redirect_id
+        ).first()
+        if existing:
+            raise HTTPException(
+                status_code=status.HTTP_409_CONFLICT,
+                detail=f"Redirect with name '{redirect_update.name}' already exists"
+            )
+
+    update_data = redirect_update.model_dump(exclude_unset=True)
+
+    try:
+        for field, value in update_data.items():
+            setattr(db_redirect, field, value)
+
+        db.commit()
+        db.refresh(db_redirect)
+
+        publish_redirect_change(redirect_id, "updated", {
+            "name": db_redirect.name,
+            "updated_fields": list(update_data.keys())
+        })
+
+        logger.info(
+            "Redirect updated successfully",
+            extra={
+                "redirect_id": str(redirect_id),
+                "updated_fields": list(update_data.keys())
+            }
+        )
+
+        return db_redirect
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to update redirect",
+            extra={"redirect_id": str(redirect_id), "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to update redirect"
+        )
+
+
+@router.delete("/{redirect_id}", status_code=status.HTTP_204_NO_CONTENT)
+def delete_redirect(
+    redirect_id: UUID,
+    db: Session = Depends(get_db)
+):
+    """
+    Delete a redirect.
+    """
+    logger.info(
+        "Deleting redirect",
+        extra={"redirect_id": str(redirect_id)}
+    )
+
+    db_redirect = _get_redirect_or_404(db, redirect_id)
+    redirect_name = db_redirect.name
+
+    try:
+        db.delete(db_redirect)
+        db.commit()
+
+        publish_redirect_change(redirect_id, "deleted", {
+            "name": redirect_name
+        })
+
+        logger.info(
+            "Redirect deleted successfully",
+            extra={"redirect_id": str(redirect_id), "redirect_name": redirect_name}
+        )
+
+        return None
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to delete redirect",
+            extra={"redirect_id": str(redirect_id), "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to delete redirect"
+        )
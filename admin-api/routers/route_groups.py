@@ -0,0 +1,531 @@
+"""Route groups API endpoints.
+
+A route group is a named set of routes sharing a service, a base path and
+a plugin set. Member routes' paths are relative to the base path, the
+group's enabled flag switches all of them at once (their own flags are
+kept), and group-scoped plugins apply to every member. Moving a group to
+another service moves its routes along.
+"""
+
+from fastapi import APIRouter, Depends, HTTPException, status
+from sqlalchemy.orm import Session
+from typing import List, Optional
+import logging
+from uuid import UUID
+
+from database import get_db
+from models import (
+    Route as RouteModel,
+    RouteGroup as RouteGroupModel,
+    Service as ServiceModel,
+    Plugin as PluginModel,
+)
+from schemas import (
+    RouteGroupCreate,
+    RouteGroupUpdate,
+    RouteGroupResponse,
+    RouteGroupRoutes,
+    RouteGroupPluginCreate,
+    RouteResponse,
+    PluginResponse,
+)
+from events import publish_route_group_change, publish_plugin_change
+from fieldcrypt import encrypt_config
+from routers.routes import effective_paths, find_duplicate_paths, get_route_group
+from workspace import get_workspace
+
+
+logger = logging.getLogger(__name__)
+
+router = APIRouter()
+
+
+def _get_service_or_404(db: Session, workspace: str, service_id: UUID) -> ServiceModel:
+    """Load a service of the workspace or raise 404."""
+    service = db.query(ServiceModel).filter(
+        ServiceModel.id == service_id,
+        ServiceModel.workspace == workspace
+    ).first()
+    if not service:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"Service with id '{service_id}' not found"
+        )
+    return service
+
+
+def _check_member_paths(
+    db: Session,
+    workspace: str,
+    routes: List[RouteModel],
+    base_path: str,
+    exclude_route_ids: List[UUID]
+):
+    """
+    Reject (409) a group change that would make enabled member routes
+    duplicate the paths of routes outside the change.
+    """
+    conflicts = []
+    for route in routes:
+        if not route.enabled:
+            continue
+        paths = effective_paths(route.paths, base_path)
+        conflicts.extend(find_duplicate_paths(
+            db, workspace, paths, route.methods, route.hosts, exclude_route_ids=exclude_route_ids
+        ))
+    if conflicts:
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail={"message": "Route paths conflict with existing routes", "conflicts": conflicts}
+        )
+
+
+@router.post("", response_model=RouteGroupResponse, status_code=status.HTTP_201_CREATED)
+def create_route_group(
+    group: RouteGroupCreate,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    Create a new route group.
+
+    Add routes with POST /route-groups/{id}/routes, or by creating routes
+    with its group_id.
+    """
+    logger.info(
+        "Creating route group",
+        extra={"group_name": group.name, "service_id": str(group.service_id)}
+    )
+
+    _get_service_or_404(db, workspace, group.service_id)
+
+    existing = db.query(RouteGroupModel).filter(
+        RouteGroupModel.workspace == workspace,
+        RouteGroupModel.name == group.name
+    ).first()
+    if existing:
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail=f"Route group with name '{group.name}' already exists"
+        )
+
+    db_group = RouteGroupModel(**group.model_dump(), workspace=workspace)
+
+    try:
+        db.add(db_group)
+        db.commit()
+        db.refresh(db_group)
+
+        publish_route_group_change(db_group.id, "created", {
+            "workspace": workspace,
+            "name": db_group.name,
+            "service_id": str(db_group.service_id)
+        })
+
+        logger.info(
+            "Route group created successfully",
+            extra={"group_id": str(db_group.id), "group_name": db_group.name}
+        )
+
+        return db_group
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to create route group",
+            extra={"group_name": group.name, "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to create route group"
+        )
+
+
+@router.get("", response_model=List[RouteGroupResponse])
+def list_route_groups(
+    skip: int = 0,
+    limit: int = 100,
+    service_id: Optional[UUID] = None,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    List all route groups in the workspace.
+
+    Query parameters:
+    - skip: Number of records to skip (pagination)
+    - limit: Maximum number of records to return
+    - service_id: Filter by service ID
+    """
+    query = db.query(RouteGroupModel).filter(RouteGroupModel.workspace == workspace)
+    if service_id:
+        query = query.filter(RouteGroupModel.service_id == service_id)
+
+    return query.order_by(RouteGroupModel.name).offset(skip).limit(limit).all()
+
+
+@router.get("/{group_id}")
+def get_route_group_details(
+    group_id: UUID,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    Get a route group with counts of its routes and plugins.
+    """
+    group = get_route_group(db, workspace, group_id)
+
+    return {
+        **RouteGroupResponse.model_validate(group).model_dump(),
+        "counts": {
+            "routes": len(group.routes),
+            "enabled_routes": sum(1 for r in group.routes if r.enabled),
+            "plugins": len(group.plugins),
+        },
+    }
+
+
+@router.put("/{group_id}", response_model=RouteGroupResponse)
+def update_route_group(
+    group_id: UUID,
+    group_update: RouteGroupUpdate,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    Update a route group.
+
+    Only provided fields will be updated. Changing service_id moves every
+    member route to the new service; changing base_path moves their paths.
+    """
+    logger.info(
+        "Updating route group",
+        extra={"group_id": str(group_id)}
+    )
+
+    db_group = get_route_group(db, workspace, group_id)
+    update_data = group_update.model_dump(exclude_unset=True)
+
+    if update_data.get("service_id"):
+        _get_service_or_404(db, workspace, update_data["service_id"])
+
+    if update_data.get("name") and update_data["name"] != db_group.name:
+        existing = db.query(RouteGroupModel).filter(
+            RouteGroupModel.workspace == workspace,
+            RouteGroupModel.name == update_data["name"],
+            RouteGroupModel.id != group_id
+        ).first()
+        if existing:
+            raise HTTPException(
+                status_code=status.HTTP_409_CONFLICT,
+                detail=f"Route group with name '{update_data['name']}' already exists"
+            )
+
+    # Moved or re-enabled member paths must not collide with other routes
+    enabled = update_data.get("enabled", db_group.enabled)
+    base_path = update_data.get("base_path", db_group.base_path)
+    if enabled and update_data.keys() & {"base_path", "enabled"}:
+        _check_member_paths(db, workspace, db_group.routes, base_path, [r.id for r in db_group.routes])
+
+    try:
+        for field, value in update_data.items():
+            setattr(db_group, field, value)
+
+        db.commit()
+        db.refresh(db_group)
+
+        publish_route_group_change(group_id, "updated", {
+            "workspace": workspace,
+            "name": db_group.name,
+            "updated_fields": list(update_data.keys())
+        })
+
+        return db_group
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to update route group",
+            extra={"group_id": str(group_id), "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to update route group"
+        )
+
+
+@router.post("/{group_id}/enable", response_model=RouteGroupResponse)
+def enable_route_group(
+    group_id: UUID,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    Enable every route of the group (routes disabled on their own stay off).
+    """
+    return update_route_group(group_id, RouteGroupUpdate(enabled=True), db, workspace)
+
+
+@router.post("/{group_id}/disable", response_model=RouteGroupResponse)
+def disable_route_group(
+    group_id: UUID,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    Disable every route of the group without changing their own enabled flags.
+    """
+    return update_route_group(group_id, RouteGroupUpdate(enabled=False), db, workspace)
+
+
+@router.delete("/{group_id}", status_code=status.HTTP_204_NO_CONTENT)
+def delete_route_group(
+    group_id: UUID,
+    delete_routes: bool = False,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    Delete a route group and its group-scoped plugins.
+
+    A group that still has routes is not deleted (409) unless
+    delete_routes=true, which deletes the routes too: removing them from
+    the group instead would silently move their paths off the base path.
+    """
+    logger.info(
+        "Deleting route group",
+        extra={"group_id": str(group_id), "delete_routes": delete_routes}
+    )
+
+    db_group = get_route_group(db, workspace, group_id)
+    group_name = db_group.name
+
+    if db_group.routes and not delete_routes:
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail=f"Route group '{group_name}' still has {len(db_group.routes)} routes (pass delete_routes=true to delete them too)"
+        )
+
+    try:
+        for route in list(db_group.routes):
+            db.delete(route)
+        db.delete(db_group)
+        db.commit()
+
+        publish_route_group_change(group_id, "deleted", {
+            "workspace": workspace,
+            "name": group_name
+        })
+
+        return None
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to delete route group",
+            extra={"group_id": str(group_id), "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to delete route group"
+        )
+
+
+@router.get("/{group_id}/routes", response_model=List[RouteResponse])
+def list_route_group_routes(
+    group_id: UUID,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    List the routes of a group (paths relative to the base path).
+    """
+    group = get_route_group(db, workspace, group_id)
+    return group.routes
+
+
+@router.post("/{group_id}/routes", response_model=List[RouteResponse])
+def add_route_group_routes(
+    group_id: UUID,
+    body: RouteGroupRoutes,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    Move routes into the group.
+
+    The routes take the group's service, and their paths become relative
+    to its base path.
+    """
+    group = get_route_group(db, workspace, group_id)
+
+    routes = db.query(RouteModel).filter(
+        RouteModel.id.in_(body.route_ids),
+        RouteModel.workspace == workspace
+    ).all()
+    missing = set(body.route_ids) - {r.id for r in routes}
+    if missing:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"Routes not found: {sorted(str(m) for m in missing)}"
+        )
+
+    if group.enabled:
+        members = [r.id for r in group.routes] + list(body.route_ids)
+        _check_member_paths(db, workspace, routes, group.base_path, members)
+
+    try:
+        for route in routes:
+            route.service_id = group.service_id
+            route.group_id = group.id
+        db.commit()
+
+        publish_route_group_change(group_id, "updated", {
+            "workspace": workspace,
+            "name": group.name,
+            "added_routes": [str(r.id) for r in routes]
+        })
+
+        db.refresh(group)
+        return group.routes
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to add routes to route group",
+            extra={"group_id": str(group_id), "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to add routes to route group"
+        )
+
+
+@router.delete("/{group_id}/routes/{route_id}", status_code=status.HTTP_204_NO_CONTENT)
+def remove_route_group_route(
+    group_id: UUID,
+    route_id: UUID,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    Take a route out of the group.
+
+    The route keeps its service but loses the group's base path and
+    plugins; its paths are served as stored.
+    """
+    group = get_route_group(db, workspace, group_id)
+
+    route = next((r for r in group.routes if r.id == route_id), None)
+    if route is None:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"Route with id '{route_id}' is not in route group '{group.name}'"
+        )
+
+    if route.enabled:
+        conflicts = find_duplicate_paths(
+            db, workspace, route.paths, route.methods, route.hosts, exclude_route_ids=[route_id]
+        )
+        if conflicts:
+            raise HTTPException(
+                status_code=status.HTTP_409_CONFLICT,
+                detail={"message": "Route paths conflict with existing routes", "conflicts": conflicts}
+            )
+
+    try:
+        route.group_id = None
+        db.commit()
+
+        publish_route_group_change(group_id, "updated", {
+            "workspace": workspace,
+            "name": group.name,
+            "removed_routes": [str(route_id)]
+        })
+
+        return None
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to remove route from route group",
+            extra={"group_id": str(group_id), "route_id": str(route_id), "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to remove route from route group"
+        )
+
+
+@router.get("/{group_id}/plugins", response_model=List[PluginResponse])
+def list_route_group_plugins(
+    group_id: UUID,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    List the plugins attached to the group.
+    """
+    group = get_route_group(db, workspace, group_id)
+    return sorted(group.plugins, key=lambda p: p.priority)
+
+
+@router.post("/{group_id}/plugins", response_model=PluginResponse, status_code=status.HTTP_201_CREATED)
+def attach_route_group_plugin(
+    group_id: UUID,
+    plugin: RouteGroupPluginCreate,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    Attach a plugin to every route of the group.
+
+    Creates one group-scoped plugin (same as POST /plugins with
+    scope "group"); routes added to the group later get it too.
+    """
+    group = get_route_group(db, workspace, group_id)
+
+    plugin_data = plugin.model_dump()
+    plugin_data["config"] = encrypt_config(plugin_data.get("config"))
+    db_plugin = PluginModel(**plugin_data, scope="group", group_id=group.id, workspace=workspace)
+
+    try:
+        db.add(db_plugin)
+        db.commit()
+        db.refresh(db_plugin)
+
+        publish_plugin_change(db_plugin.id, "created", {
+            "workspace": workspace,
+            "name": db_plugin.name,
+            "scope": db_plugin.scope,
+            "priority": db_plugin.priority
+        })
+
+        logger.info(
+            "Plugin attached to route group",
+            extra={
+                "plugin_id": str(db_plugin.id),
+                "plugin_name": db_plugin.name,
+                "group": group.name,
+                "routes": len(group.routes)
+            }
+        )
+
+        return db_plugin
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to attach plugin to route group",
+            extra={"group_id": str(group_id), "plugin_name": plugin.name, "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to attach plugin to route group"
+        )
@@ -7,19 +7,114 @@ import logging
 from uuid import UUID
 
 from database import get_db
-from models import Route as RouteModel, Service as ServiceModel
-from schemas import RouteCreate, RouteUpdate, RouteResponse
+from models import Route as RouteModel, RouteGroup as RouteGroupModel, Service as ServiceModel
+from schemas import RouteCreate, RouteUpdate, RouteResponse, split_tags
 from events import publish_route_change
+from workspace import get_workspace
 
 logger = logging.getLogger(__name__)
 
 router = APIRouter()
 
 
+def _path_shape(path: str) -> str:
+    """
+    Normalize a path pattern the way the gateway's radix tree sees it:
+    trailing slash dropped and parameter names erased, so "/users/:id"
+    and "/users/:user_id/" have the same shape.
+    """
+    segments = [s for s in path.strip("/").split("/") if s]
+    return "/" + "/".join(":" if s.startswith(":") else s for s in segments)
+
+
+def _host_patterns_overlap(a: str, b: str) -> bool:
+    """Whether two host patterns ("api.example.com", "*.example.com") share a host."""
+    def covered_by(host: str, pattern: str) -> bool:
+        if not pattern.startswith("*."):
+            return False
+        suffix = pattern[2:]
+        return host == suffix or host.endswith("." + suffix)
+
+    return a == b or covered_by(b.removeprefix("*."), a) or covered_by(a.removeprefix("*."), b)
+
+
+def _routes_overlap(methods_a, hosts_a, methods_b, hosts_b) -> bool:
+    """Whether some request method and host is accepted by both routes (empty means any)."""
+    if methods_a and methods_b and not set(methods_a) & set(methods_b):
+        return False
+    if hosts_a and hosts_b:
+        return any(_host_patterns_overlap(a, b) for a in hosts_a for b in hosts_b)
+    return True
+
+
+def effective_paths(paths: List[str], base_path: Optional[str]) -> List[str]:
+    """Paths as the gateway routes them: under the route group's base path, if any."""
+    base = (base_path or "").rstrip("/")
+    if not base:
+        return list(paths)
+    return [base if p == "/" else base + p for p in paths]
+
+
+def get_route_group(db: Session, workspace: str, group_id: UUID) -> RouteGroupModel:
+    """Load a route group of the workspace or raise 404."""
+    group = db.query(RouteGroupModel).filter(
+        RouteGroupModel.id == group_id,
+        RouteGroupModel.workspace == workspace
+    ).first()
+    if not group:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"Route group with id '{group_id}' not found"
+        )
+    return group
+
+
+def find_duplicate_paths(
+    db: Session,
+    workspace: str,
+    paths: List[str],
+    methods: Optional[List[str]] = None,
+    hosts: Optional[List[str]] = None,
+    exclude_route_ids: Optional[List[UUID]] = None,
+) -> List[str]:
+    """
+    Return conflicts between paths and the paths of other enabled routes.
+
+    Paths are compared as the gateway routes them, under their route
+    group's base path, so callers pass effective paths too. Routes may
+    share a path when their methods or hosts tell them apart; otherwise
+    the gateway only ever serves one of them for the overlap. All
+    workspaces share the gateway's routing tree, so routes in other
+    workspaces are checked too, but reported without their details.
+    """
+    wanted = {_path_shape(p): p for p in paths}
+
+    query = db.query(RouteModel).filter(RouteModel.enabled == True)
+    if exclude_route_ids:
+        query = query.filter(RouteModel.id.notin_(exclude_route_ids))
+
+    conflicts = []
+    for other in query.all():
+        if other.group is not None and not other.group.enabled:
+            continue
+        if not _routes_overlap(methods, hosts, other.methods, other.hosts):
+            continue
+        for other_path in effective_paths(other.paths or [], other.group.base_path if other.group else None):
+            path = wanted.get(_path_shape(other_path))
+            if path is not None:
+                if other.workspace != workspace:
+                    conflicts.append(f"path '{path}' is already routed by another workspace")
+                    continue
+                label = other.name or str(other.id)
+                conflicts.append(f"path '{path}' duplicates '{other_path}' of route '{label}'")
+    return conflicts
+
+
 @router.post("", response_model=RouteResponse, status_code=status.HTTP_201_CREATED)
 def create_route(
     route: RouteCreate,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Create a new route.
@@ -37,8 +132,11 @@ def create_route(
         }
     )
     
-    # Verify service exists
-    service = db.query(ServiceModel).filter(ServiceModel.id == route.service_id).first()
+    # Verify service exists in this workspace
+    service = db.query(ServiceModel).filter(
+        ServiceModel.id == route.service_id,
+        ServiceModel.workspace == workspace
+    ).first()
     if not service:
         logger.warning(
             "Route creation failed - service not found",
@@ -51,7 +149,10 @@ def create_route(
     
     # Check if route name already exists (if name provided)
     if route.name:
-        existing = db.query(RouteModel).filter(RouteModel.name == route.name).first()
+        existing = db.query(RouteModel).filter(
+            RouteModel.workspace == workspace,
+            RouteModel.name == route.name
+        ).first()
         if existing:
             logger.warning(
                 "Route creation failed - name already exists",
@@ -62,8 +163,32 @@ def create_route(
                 detail=f"Route with name '{route.name}' already exists"
             )
     
+    # A grouped route uses the group's service, under its base path
+    group = None
+    if route.group_id:
+        group = get_route_group(db, workspace, route.group_id)
+        if group.service_id != route.service_id:
+            raise HTTPException(
+                status_code=status.HTTP_400_BAD_REQUEST,
+                detail=f"Route group '{group.name}' routes to service '{group.service_id}'; its routes must too"
+            )
+    
+    # Reject paths another enabled route already registers
+    if route.enabled and (group is None or group.enabled):
+        paths = effective_paths(route.paths, group.base_path if group else None)
+        conflicts = find_duplicate_paths(db, workspace, paths, route.methods, route.hosts)
+        if conflicts:
+            logger.warning(
+                "Route creation failed - duplicate paths",
+                extra={"route_name": route.name, "conflicts": conflicts}
+            )
+            raise HTTPException(
+                status_code=status.HTTP_409_CONFLICT,
+                detail={"message": "Route paths conflict with existing routes", "conflicts": conflicts}
+            )
+    
     # Create route
-    db_route = RouteModel(**route.model_dump())
+    db_route = RouteModel(**route.model_dump(), workspace=workspace)
     
     try:
         db.add(db_route)
@@ -72,6 +197,7 @@ def create_route(
         
         # Publish config change event
         publish_route_change(db_route.id, "created", {
+            "workspace": workspace,
             "name": db_route.name,
             "paths": db_route.paths,
             "service_id": str(db_route.service_id)
@@ -112,17 +238,22 @@ def list_routes(
     skip: int = 0,
     limit: int = 100,
     service_id: Optional[UUID] = None,
+    group_id: Optional[UUID] = None,
     enabled_only: bool = False,
-    db: Session = Depends(get_db)
+    tags: Optional[str] = None,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
-    List all routes.
+    List all routes in the workspace.
     
     Query parameters:
     - skip: Number of records to skip (pagination)
     - limit: Maximum number of records to return
     - service_id: Filter by service ID
+    - group_id: Filter by route group ID
     - enabled_only: If true, only return enabled routes
+    - tags: Comma-separated tags; only routes with all of them are returned
     """
     logger.debug(
         "Listing routes",
@@ -134,16 +265,24 @@ def list_routes(
         }
     )
     
-    query = db.query(RouteModel)
+    query = db.query(RouteModel).filter(RouteModel.workspace == workspace)
     
     # Filter by service
     if service_id:
         query = query.filter(RouteModel.service_id == service_id)
     
+    # Filter by route group
+    if group_id:
+        query = query.filter(RouteModel.group_id == group_id)
+    
     # Filter by enabled
     if enabled_only:
         query = query.filter(RouteModel.enabled == True)
     
+    # Filter by tags (all must match)
+    for tag in split_tags(tags):
+        query = query.filter(RouteModel.tags.any(tag))
+    
     routes = query.offset(skip).limit(limit).all()
     
     logger.info(
@@ -161,7 +300,8 @@ def list_routes(
 @router.get("/{route_id}", response_model=RouteResponse)
 def get_route(
     route_id: UUID,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Get a specific route by ID.
@@ -171,7 +311,10 @@ def get_route(
         extra={"route_id": str(route_id)}
     )
     
-    route = db.query(RouteModel).filter(RouteModel.id == route_id).first()
+    route = db.query(RouteModel).filter(
+        RouteModel.id == route_id,
+        RouteModel.workspace == workspace
+    ).first()
     
     if not route:
         logger.warning(
@@ -199,7 +342,8 @@ def get_route(
 def update_route(
     route_id: UUID,
     route_update: RouteUpdate,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Update a route.
@@ -212,7 +356,10 @@ def update_route(
     )
     
     # Get existing route
-    db_route = db.query(RouteModel).filter(RouteModel.id == route_id).first()
+    db_route = db.query(RouteModel).filter(
+        RouteModel.id == route_id,
+        RouteModel.workspace == workspace
+    ).first()
     
     if not db_route:
         logger.warning(
@@ -227,7 +374,8 @@ def update_route(
     # Verify new service exists (if changing service)
     if route_update.service_id:
         service = db.query(ServiceModel).filter(
-            ServiceModel.id == route_update.service_id
+            ServiceModel.id == route_update.service_id,
+            ServiceModel.workspace == workspace
         ).first()
         if not service:
             logger.warning(
@@ -245,6 +393,7 @@ def update_route(
     # Check if new name conflicts
     if route_update.name and route_update.name != db_route.name:
         existing = db.query(RouteModel).filter(
+            RouteModel.workspace == workspace,
             RouteModel.name == route_update.name,
             RouteModel.id != route_id
         ).first()
@@ -264,6 +413,33 @@ def update_route(
     # Update fields
     update_data = route_update.model_dump(exclude_unset=True)
     
+    # A grouped route uses the group's service, under its base path
+    group_id = update_data["group_id"] if "group_id" in update_data else db_route.group_id
+    group = get_route_group(db, workspace, group_id) if group_id else None
+    service_id = update_data.get("service_id") or db_route.service_id
+    if group and group.service_id != service_id:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail=f"Route group '{group.name}' routes to service '{group.service_id}'; its routes must too"
+        )
+    
+    # Reject paths another enabled route already registers
+    enabled = update_data.get("enabled", db_route.enabled) and (group is None or group.enabled)
+    paths = effective_paths(update_data.get("paths") or db_route.paths, group.base_path if group else None)
+    methods = update_data.get("methods") or db_route.methods
+    hosts = update_data["hosts"] if "hosts" in update_data else db_route.hosts
+    if enabled and update_data.keys() & {"paths", "methods", "hosts", "enabled", "group_id"}:
+        conflicts = find_duplicate_paths(db, workspace, paths, methods, hosts, exclude_route_ids=[route_id])
+        if conflicts:
+            logger.warning(
+                "Route update failed - duplicate paths",
+                extra={"route_id": str(route_id), "conflicts": conflicts}
+            )
+            raise HTTPException(
+                status_code=status.HTTP_409_CONFLICT,
+                detail={"message": "Route paths conflict with existing routes", "conflicts": conflicts}
+            )
+    
     try:
         for field, value in update_data.items():
             setattr(db_route, field, value)
@@ -273,6 +449,7 @@ def update_route(
         
         # Publish config change event
         publish_route_change(route_id, "updated", {
+            "workspace": workspace,
             "name": db_route.name,
             "updated_fields": list(update_data.keys())
         })
@@ -304,7 +481,8 @@ def update_route(
 @router.delete("/{route_id}", status_code=status.HTTP_204_NO_CONTENT)
 def delete_route(
     route_id: UUID,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Delete a route.
@@ -318,7 +496,10 @@ def delete_route(
     )
     
     # Get route
-    db_route = db.query(RouteModel).filter(RouteModel.id == route_id).first()
+    db_route = db.query(RouteModel).filter(
+        RouteModel.id == route_id,
+        RouteModel.workspace == workspace
+    ).first()
     
     if not db_route:
         logger.warning(
@@ -339,6 +520,7 @@ def delete_route(
         
         # Publish config change event
         publish_route_change(route_id, "deleted", {
+            "workspace": workspace,
             "name": route_name,
             "service_id": service_id
         })
@@ -374,7 +556,8 @@ def delete_route(
 @router.get("/{route_id}/details")
 def get_route_details(
     route_id: UUID,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Get detailed information about a route including service details.
@@ -385,7 +568,10 @@ def get_route_details(
     )
     
     # Get route with service
-    route = db.query(RouteModel).filter(RouteModel.id == route_id).first()
+    route = db.query(RouteModel).filter(
+        RouteModel.id == route_id,
+        RouteModel.workspace == workspace
+    ).first()
     
     if not route:
         raise HTTPException(
@@ -397,12 +583,23 @@ def get_route_details(
     details = {
         "route": {
             "id": str(route.id),
+            "workspace": route.workspace,
             "name": route.name,
             "paths": route.paths,
             "methods": route.methods,
             "hosts": route.hosts,
             "strip_path": route.strip_path,
             "preserve_host": route.preserve_host,
+            "priority_class": route.priority_class,
+            "timeout_ms": route.timeout_ms,
+            "schedule_start": route.schedule_start.isoformat() if route.schedule_start else None,
+            "schedule_end": route.schedule_end.isoformat() if route.schedule_end else None,
+            "schedule_cron": route.schedule_cron,
+            "schedule_mode": route.schedule_mode,
+            "schedule_timezone": route.schedule_timezone,
+            "slo": route.slo,
+            "group_id": str(route.group_id) if route.group_id else None,
+            "effective_paths": effective_paths(route.paths, route.group.base_path if route.group else None),
             "enabled": route.enabled,
             "created_at": route.created_at.isoformat(),
             "updated_at": route.updated_at.isoformat()
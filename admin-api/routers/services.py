@@ -2,14 +2,15 @@
 
 from fastapi import APIRouter, Depends, HTTPException, status
 from sqlalchemy.orm import Session
-from typing import List
+from typing import List, Optional
 import logging
 from uuid import UUID
 
 from database import get_db
-from models import Service as ServiceModel
-from schemas import ServiceCreate, ServiceUpdate, ServiceResponse
+from models import Route as RouteModel, Service as ServiceModel
+from schemas import ServiceCreate, ServiceUpdate, ServiceResponse, ServiceUpdateResponse, OrphanedRoute, split_tags
 from events import publish_service_change
+from workspace import get_workspace
 
 
 logger = logging.getLogger(__name__)
@@ -20,7 +21,8 @@ router = APIRouter()
 @router.post("", response_model=ServiceResponse, status_code=status.HTTP_201_CREATED)
 def create_service(
     service: ServiceCreate,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Create a new service.
@@ -38,7 +40,10 @@ def create_service(
     )
     
     # Check if service with this name already exists
-    existing = db.query(ServiceModel).filter(ServiceModel.name == service.name).first()
+    existing = db.query(ServiceModel).filter(
+        ServiceModel.workspace == workspace,
+        ServiceModel.name == service.name
+    ).first()
     if existing:
         logger.warning(
             "Service creation failed - name already exists",
@@ -50,7 +55,7 @@ def create_service(
         )
     
     # Create service
-    db_service = ServiceModel(**service.model_dump())
+    db_service = ServiceModel(**service.model_dump(), workspace=workspace)
     
     try:
         db.add(db_service)
@@ -59,6 +64,7 @@ def create_service(
         
         # Publish config change event
         publish_service_change(db_service.id, "created", {
+            "workspace": workspace,
             "name": db_service.name,
             "host": db_service.host,
             "port": db_service.port
@@ -93,26 +99,32 @@ def list_services(
     skip: int = 0,
     limit: int = 100,
     enabled_only: bool = False,
-    db: Session = Depends(get_db)
+    tags: Optional[str] = None,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
-    List all services.
+    List all services in the workspace.
     
     Query parameters:
     - skip: Number of records to skip (pagination)
     - limit: Maximum number of records to return
     - enabled_only: If true, only return enabled services
+    - tags: Comma-separated tags; only services with all of them are returned
     """
     logger.debug(
         "Listing services",
         extra={"skip": skip, "limit": limit, "enabled_only": enabled_only}
     )
     
-    query = db.query(ServiceModel)
+    query = db.query(ServiceModel).filter(ServiceModel.workspace == workspace)
     
     if enabled_only:
         query = query.filter(ServiceModel.enabled == True)
     
+    for tag in split_tags(tags):
+        query = query.filter(ServiceModel.tags.any(tag))
+    
     services = query.offset(skip).limit(limit).all()
     
     logger.info(
@@ -126,7 +138,8 @@ def list_services(
 @router.get("/{service_id}", response_model=ServiceResponse)
 def get_service(
     service_id: UUID,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Get a specific service by ID.
@@ -136,7 +149,10 @@ def get_service(
         extra={"service_id": str(service_id)}
     )
     
-    service = db.query(ServiceModel).filter(ServiceModel.id == service_id).first()
+    service = db.query(ServiceModel).filter(
+        ServiceModel.id == service_id,
+        ServiceModel.workspace == workspace
+    ).first()
     
     if not service:
         logger.warning(
@@ -159,16 +175,20 @@ def get_service(
     return service
 
 
-@router.put("/{service_id}", response_model=ServiceResponse)
+@router.put("/{service_id}", response_model=ServiceUpdateResponse)
 def update_service(
     service_id: UUID,
     service_update: ServiceUpdate,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Update a service.
     
     Only provided fields will be updated. Omitted fields remain unchanged.
+    
+    While the service is disabled, its enabled routes are not served and
+    are listed in orphaned_routes.
     """
     logger.info(
         "Updating service",
@@ -176,7 +196,10 @@ def update_service(
     )
     
     # Get existing service
-    db_service = db.query(ServiceModel).filter(ServiceModel.id == service_id).first()
+    db_service = db.query(ServiceModel).filter(
+        ServiceModel.id == service_id,
+        ServiceModel.workspace == workspace
+    ).first()
     
     if not db_service:
         logger.warning(
@@ -191,6 +214,7 @@ def update_service(
     # Check if new name conflicts with existing service
     if service_update.name and service_update.name != db_service.name:
         existing = db.query(ServiceModel).filter(
+            ServiceModel.workspace == workspace,
             ServiceModel.name == service_update.name,
             ServiceModel.id != service_id
         ).first()
@@ -219,6 +243,7 @@ def update_service(
         
         # Publish config change event
         publish_service_change(service_id, "updated", {
+            "workspace": workspace,
             "name": db_service.name,
             "updated_fields": list(update_data.keys())
         })
@@ -232,7 +257,24 @@ def update_service(
             }
         )
         
-        return db_service
+        response = ServiceUpdateResponse.model_validate(db_service)
+        if not db_service.enabled:
+            orphaned = db.query(RouteModel).filter(
+                RouteModel.service_id == service_id,
+                RouteModel.enabled == True
+            ).all()
+            response.orphaned_routes = [OrphanedRoute.model_validate(route) for route in orphaned]
+            if orphaned:
+                logger.warning(
+                    "Service is disabled - its enabled routes are not served",
+                    extra={
+                        "service_id": str(service_id),
+                        "service_name": db_service.name,
+                        "orphaned_routes": [str(route.id) for route in orphaned]
+                    }
+                )
+        
+        return response
         
     except Exception as e:
         db.rollback()
@@ -250,7 +292,8 @@ def update_service(
 @router.delete("/{service_id}", status_code=status.HTTP_204_NO_CONTENT)
 def delete_service(
     service_id: UUID,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Delete a service.
@@ -264,7 +307,10 @@ def delete_service(
     )
     
     # Get service
-    db_service = db.query(ServiceModel).filter(ServiceModel.id == service_id).first()
+    db_service = db.query(ServiceModel).filter(
+        ServiceModel.id == service_id,
+        ServiceModel.workspace == workspace
+    ).first()
     
     if not db_service:
         logger.warning(
@@ -278,12 +324,20 @@ def delete_service(
     
     service_name = db_service.name
     
+    # Routes go with the service (ON DELETE CASCADE); name them in the logs
+    deleted_routes = [
+        str(route_id) for (route_id,) in db.query(RouteModel.id).filter(
+            RouteModel.service_id == service_id
+        ).all()
+    ]
+    
     try:
         db.delete(db_service)
         db.commit()
         
         # Publish config change event
         publish_service_change(service_id, "deleted", {
+            "workspace": workspace,
             "name": service_name
         })
         
@@ -294,6 +348,15 @@ def delete_service(
                 "service_name": service_name
             }
         )
+        if deleted_routes:
+            logger.warning(
+                "Deleted the service's routes with it",
+                extra={
+                    "service_id": str(service_id),
+                    "service_name": service_name,
+                    "deleted_routes": deleted_routes
+                }
+            )
         
         return None
         
@@ -317,7 +380,8 @@ def delete_service(
 @router.get("/{service_id}/stats")
 def get_service_stats(
     service_id: UUID,
-    db: Session = Depends(get_db)
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
 ):
     """
     Get statistics for a service.
@@ -330,7 +394,10 @@ def get_service_stats(
     )
     
     # Get service
-    service = db.query(ServiceModel).filter(ServiceModel.id == service_id).first()
+    service = db.query(ServiceModel).filter(
+        ServiceModel.id == service_id,
+        ServiceModel.workspace == workspace
+    ).first()
     
     if not service:
         raise HTTPException(
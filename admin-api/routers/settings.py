@@ -0,0 +1,149 @@
+"""Gateway settings API endpoints.
+
+Settings override the gateway's environment configuration at runtime
+(upstream connection pools and timeouts) and are applied on hot reload.
+"""
+
+from fastapi import APIRouter, Depends, HTTPException, status
+from sqlalchemy.orm import Session
+from typing import List
+import logging
+
+from database import get_db
+from models import GatewaySetting as GatewaySettingModel
+from schemas import GatewaySettingUpdate, GatewaySettingResponse, validate_gateway_setting
+from events import publish_setting_change
+
+
+logger = logging.getLogger(__name__)
+
+router = APIRouter()
+
+
+@router.get("", response_model=List[GatewaySettingResponse])
+def list_settings(db: Session = Depends(get_db)):
+    """
+    List the gateway settings overridden at runtime.
+
+    Settings not listed use the gateway's environment configuration.
+    """
+    settings = db.query(GatewaySettingModel).order_by(GatewaySettingModel.key).all()
+
+    logger.info(
+        "Gateway settings retrieved",
+        extra={"count": len(settings)}
+    )
+
+    return settings
+
+
+@router.get("/{key}", response_model=GatewaySettingResponse)
+def get_setting(key: str, db: Session = Depends(get_db)):
+    """
+    Get a specific gateway setting.
+    """
+    setting = db.query(GatewaySettingModel).filter(GatewaySettingModel.key == key).first()
+    if not setting:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"Setting '{key}' is not overridden"
+        )
+    return setting
+
+
+@router.put("/{key}", response_model=GatewaySettingResponse)
+def put_setting(
+    key: str,
+    setting_update: GatewaySettingUpdate,
+    db: Session = Depends(get_db)
+):
+    """
+    Set a gateway setting, e.g. PUT /settings/upstream_dial_timeout {"value": "5s"}.
+
+    Counts are integers and timeouts are Go durations ("500ms", "30s").
+    Gateways build new upstream transports with the value; requests in
+    flight finish on the old ones.
+    """
+    try:
+        value = validate_gateway_setting(key, setting_update.value)
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_422_UNPROCESSABLE_ENTITY,
+            detail=str(e)
+        )
+
+    logger.info(
+        "Updating gateway setting",
+        extra={"key": key, "value": value}
+    )
+
+    setting = db.query(GatewaySettingModel).filter(GatewaySettingModel.key == key).first()
+    action = "updated" if setting else "created"
+
+    try:
+        if setting:
+            setting.value = value
+        else:
+            setting = GatewaySettingModel(key=key, value=value)
+            db.add(setting)
+        db.commit()
+        db.refresh(setting)
+
+        publish_setting_change(key, action, {"value": value})
+
+        logger.info(
+            "Gateway setting updated successfully",
+            extra={"key": key, "action": action}
+        )
+
+        return setting
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to update gateway setting",
+            extra={"key": key, "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to update gateway setting"
+        )
+
+
+@router.delete("/{key}", status_code=status.HTTP_204_NO_CONTENT)
+def delete_setting(key: str, db: Session = Depends(get_db)):
+    """
+    Remove a setting override; gateways fall back to their environment value.
+    """
+    setting = db.query(GatewaySettingModel).filter(GatewaySettingModel.key == key).first()
+    if not setting:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"Setting '{key}' is not overridden"
+        )
+
+    try:
+        db.delete(setting)
+        db.commit()
+
+        publish_setting_change(key, "deleted")
+
+        logger.info(
+            "Gateway setting deleted successfully",
+            extra={"key": key}
+        )
+
+        return None
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to delete gateway setting",
+            extra={"key": key, "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to delete gateway setting"
+        )
@@ -0,0 +1,308 @@
+"""Stream listeners API endpoints.
+
+A stream listener is a TCP or UDP listen address on the gateway whose
+connections are proxied to a service's targets (load balanced like HTTP
+traffic), for non-HTTP backends such as MQTT brokers or DNS servers.
+Listen addresses are global: two listeners of the same protocol can't
+share one, whatever their workspace.
+"""
+
+from fastapi import APIRouter, Depends, HTTPException, status
+from sqlalchemy.orm import Session
+from typing import List, Optional
+import logging
+from uuid import UUID
+
+from database import get_db
+from models import StreamListener as StreamListenerModel, Service as ServiceModel
+from schemas import StreamListenerCreate, StreamListenerUpdate, StreamListenerResponse
+from events import publish_stream_listener_change
+from workspace import get_workspace
+
+
+logger = logging.getLogger(__name__)
+
+router = APIRouter()
+
+
+def _get_listener_or_404(db: Session, workspace: str, listener_id: UUID) -> StreamListenerModel:
+    """Load a stream listener of the workspace or raise 404."""
+    listener = db.query(StreamListenerModel).filter(
+        StreamListenerModel.id == listener_id,
+        StreamListenerModel.workspace == workspace
+    ).first()
+    if not listener:
+        logger.warning(
+            "Stream listener not found",
+            extra={"listener_id": str(listener_id)}
+        )
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"Stream listener with id '{listener_id}' not found"
+        )
+    return listener
+
+
+def _check_service(db: Session, workspace: str, service_id: UUID):
+    """Raise 404 unless the service exists in the workspace."""
+    service = db.query(ServiceModel).filter(
+        ServiceModel.id == service_id,
+        ServiceModel.workspace == workspace
+    ).first()
+    if not service:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"Service with id '{service_id}' not found"
+        )
+
+
+def _check_unique(
+    db: Session,
+    workspace: str,
+    name: Optional[str],
+    protocol: str,
+    listen_address: str,
+    exclude_id: Optional[UUID] = None
+):
+    """Raise 409 if the name is taken in the workspace or the address anywhere."""
+    others = db.query(StreamListenerModel)
+    if exclude_id:
+        others = others.filter(StreamListenerModel.id != exclude_id)
+
+    if name and others.filter(
+        StreamListenerModel.workspace == workspace,
+        StreamListenerModel.name == name
+    ).first():
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail=f"Stream listener with name '{name}' already exists"
+        )
+
+    if others.filter(
+        StreamListenerModel.protocol == protocol,
+        StreamListenerModel.listen_address == listen_address
+    ).first():
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail=f"A {protocol} stream listener on '{listen_address}' already exists"
+        )
+
+
+@router.post("", response_model=StreamListenerResponse, status_code=status.HTTP_201_CREATED)
+def create_stream_listener(
+    listener: StreamListenerCreate,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    Create a new stream listener.
+
+    The gateway opens it on the next reload; an address it can't bind is
+    logged and skipped.
+    """
+    logger.info(
+        "Creating stream listener",
+        extra={
+            "listener_name": listener.name,
+            "protocol": listener.protocol,
+            "listen_address": listener.listen_address
+        }
+    )
+
+    _check_service(db, workspace, listener.service_id)
+    _check_unique(db, workspace, listener.name, listener.protocol, listener.listen_address)
+
+    db_listener = StreamListenerModel(**listener.model_dump(), workspace=workspace)
+
+    try:
+        db.add(db_listener)
+        db.commit()
+        db.refresh(db_listener)
+
+        publish_stream_listener_change(db_listener.id, "created", {
+            "workspace": workspace,
+            "name": db_listener.name,
+            "protocol": db_listener.protocol,
+            "listen_address": db_listener.listen_address
+        })
+
+        logger.info(
+            "Stream listener created successfully",
+            extra={"listener_id": str(db_listener.id), "listener_name": db_listener.name}
+        )
+
+        return db_listener
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to create stream listener",
+            extra={"listener_name": listener.name, "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to create stream listener"
+        )
+
+
+@router.get("", response_model=List[StreamListenerResponse])
+def list_stream_listeners(
+    skip: int = 0,
+    limit: int = 100,
+    service_id: Optional[UUID] = None,
+    enabled_only: bool = False,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    List the stream listeners of the workspace.
+
+    Query parameters:
+    - skip: Number of records to skip (pagination)
+    - limit: Maximum number of records to return
+    - service_id: Only return listeners of this service
+    - enabled_only: If true, only return enabled listeners
+    """
+    query = db.query(StreamListenerModel).filter(StreamListenerModel.workspace == workspace)
+
+    if service_id:
+        query = query.filter(StreamListenerModel.service_id == service_id)
+    if enabled_only:
+        query = query.filter(StreamListenerModel.enabled == True)
+
+    listeners = query.order_by(StreamListenerModel.created_at).offset(skip).limit(limit).all()
+
+    logger.info(
+        "Stream listeners retrieved",
+        extra={"count": len(listeners), "enabled_only": enabled_only}
+    )
+
+    return listeners
+
+
+@router.get("/{listener_id}", response_model=StreamListenerResponse)
+def get_stream_listener(
+    listener_id: UUID,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    Get a specific stream listener by ID.
+    """
+    return _get_listener_or_404(db, workspace, listener_id)
+
+
+@router.put("/{listener_id}", response_model=StreamListenerResponse)
+def update_stream_listener(
+    listener_id: UUID,
+    listener_update: StreamListenerUpdate,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    Update a stream listener.
+
+    Only provided fields will be updated. Changing the protocol or address
+    reopens the listener, closing its open connections; other changes
+    apply to new connections.
+    """
+    logger.info(
+        "Updating stream listener",
+        extra={"listener_id": str(listener_id)}
+    )
+
+    db_listener = _get_listener_or_404(db, workspace, listener_id)
+    update_data = listener_update.model_dump(exclude_unset=True)
+
+    if update_data.get("service_id"):
+        _check_service(db, workspace, update_data["service_id"])
+    _check_unique(
+        db,
+        workspace,
+        update_data.get("name", db_listener.name),
+        update_data.get("protocol", db_listener.protocol),
+        update_data.get("listen_address", db_listener.listen_address),
+        exclude_id=listener_id
+    )
+
+    try:
+        for field, value in update_data.items():
+            setattr(db_listener, field, value)
+
+        db.commit()
+        db.refresh(db_listener)
+
+        publish_stream_listener_change(listener_id, "updated", {
+            "workspace": workspace,
+            "name": db_listener.name,
+            "updated_fields": list(update_data.keys())
+        })
+
+        logger.info(
+            "Stream listener updated successfully",
+            extra={
+                "listener_id": str(listener_id),
+                "updated_fields": list(update_data.keys())
+            }
+        )
+
+        return db_listener
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to update stream listener",
+            extra={"listener_id": str(listener_id), "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to update stream listener"
+        )
+
+
+@router.delete("/{listener_id}", status_code=status.HTTP_204_NO_CONTENT)
+def delete_stream_listener(
+    listener_id: UUID,
+    db: Session = Depends(get_db),
+    workspace: str = Depends(get_workspace)
+):
+    """
+    Delete a stream listener (closing its open connections).
+    """
+    logger.info(
+        "Deleting stream listener",
+        extra={"listener_id": str(listener_id)}
+    )
+
+    db_listener = _get_listener_or_404(db, workspace, listener_id)
+    listener_name = db_listener.name
+
+    try:
+        db.delete(db_listener)
+        db.commit()
+
+        publish_stream_listener_change(listener_id, "deleted", {
+            "workspace": workspace,
+            "name": listener_name
+        })
+
+        logger.info(
+            "Stream listener deleted successfully",
+            extra={"listener_id": str(listener_id), "listener_name": listener_name}
+        )
+
+        return None
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to delete stream listener",
+            extra={"listener_id": str(listener_id), "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to delete stream listener"
+        )
@@ -0,0 +1,207 @@
+"""Tenant registry CRUD API endpoints.
+
+Tenants are gateway-wide. Requests are resolved to a tenant by header or
+subdomain (TENANT_SOURCE on the gateway), and services whose host or path
+contain {tenant} are proxied to that tenant's own backend.
+"""
+
+from fastapi import APIRouter, Depends, HTTPException, status
+from sqlalchemy.orm import Session
+from typing import List
+import logging
+
+from database import get_db
+from models import Tenant as TenantModel
+from schemas import TenantCreate, TenantUpdate, TenantResponse
+from events import publish_tenant_change
+
+
+logger = logging.getLogger(__name__)
+
+router = APIRouter()
+
+
+def _get_tenant_or_404(db: Session, tenant_id: str) -> TenantModel:
+    """Load a tenant or raise 404."""
+    tenant = db.query(TenantModel).filter(TenantModel.id == tenant_id).first()
+    if not tenant:
+        logger.warning(
+            "Tenant not found",
+            extra={"tenant_id": tenant_id}
+        )
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"Tenant '{tenant_id}' not found"
+        )
+    return tenant
+
+
+@router.post("", response_model=TenantResponse, status_code=status.HTTP_201_CREATED)
+def create_tenant(
+    tenant: TenantCreate,
+    db: Session = Depends(get_db)
+):
+    """
+    Register a tenant.
+
+    The ID is substituted for {tenant} in templated service hosts and
+    paths, so it must be a DNS label (lower-case letters, digits, hyphens).
+    """
+    logger.info(
+        "Creating tenant",
+        extra={"tenant_id": tenant.id}
+    )
+
+    if db.query(TenantModel).filter(TenantModel.id == tenant.id).first():
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail=f"Tenant '{tenant.id}' already exists"
+        )
+
+    db_tenant = TenantModel(**tenant.model_dump())
+
+    try:
+        db.add(db_tenant)
+        db.commit()
+        db.refresh(db_tenant)
+
+        publish_tenant_change(db_tenant.id, "created", {
+            "enabled": db_tenant.enabled
+        })
+
+        logger.info(
+            "Tenant created successfully",
+            extra={"tenant_id": db_tenant.id}
+        )
+
+        return db_tenant
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to create tenant",
+            extra={"tenant_id": tenant.id, "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to create tenant"
+        )
+
+
+@router.get("", response_model=List[TenantResponse])
+def list_tenants(
+    skip: int = 0,
+    limit: int = 100,
+    enabled_only: bool = False,
+    db: Session = Depends(get_db)
+):
+    """
+    List registered tenants.
+
+    Query parameters:
+    - skip: Number of records to skip (pagination)
+    - limit: Maximum number of records to return
+    - enabled_only: If true, only return enabled tenants
+    """
+    query = db.query(TenantModel)
+
+    if enabled_only:
+        query = query.filter(TenantModel.enabled == True)
+
+    return query.order_by(TenantModel.id).offset(skip).limit(limit).all()
+
+
+@router.get("/{tenant_id}", response_model=TenantResponse)
+def get_tenant(
+    tenant_id: str,
+    db: Session = Depends(get_db)
+):
+    """
+    Get a specific tenant by ID.
+    """
+    return _get_tenant_or_404(db, tenant_id)
+
+
+@router.put("/{tenant_id}", response_model=TenantResponse)
+def update_tenant(
+    tenant_id: str,
+    tenant_update: TenantUpdate,
+    db: Session = Depends(get_db)
+):
+    """
+    Update a tenant. Disabled tenants are rejected like unknown ones.
+    """
+    logger.info(
+        "Updating tenant",
+        extra={"tenant_id": tenant_id}
+    )
+
+    db_tenant = _get_tenant_or_404(db, tenant_id)
+    update_data = tenant_update.model_dump(exclude_unset=True)
+
+    try:
+        for field, value in update_data.items():
+            setattr(db_tenant, field, value)
+
+        db.commit()
+        db.refresh(db_tenant)
+
+        publish_tenant_change(tenant_id, "updated", {
+            "updated_fields": list(update_data.keys())
+        })
+
+        return db_tenant
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to update tenant",
+            extra={"tenant_id": tenant_id, "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to update tenant"
+        )
+
+
+@router.delete("/{tenant_id}", status_code=status.HTTP_204_NO_CONTENT)
+def delete_tenant(
+    tenant_id: str,
+    db: Session = Depends(get_db)
+):
+    """
+    Remove a tenant from the registry.
+    """
+    logger.info(
+        "Deleting tenant",
+        extra={"tenant_id": tenant_id}
+    )
+
+    db_tenant = _get_tenant_or_404(db, tenant_id)
+
+    try:
+        db.delete(db_tenant)
+        db.commit()
+
+        publish_tenant_change(tenant_id, "deleted")
+
+        logger.info(
+            "Tenant deleted successfully",
+            extra={"tenant_id": tenant_id}
+        )
+
+        return None
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to delete tenant",
+            extra={"tenant_id": tenant_id, "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to delete tenant"
+        )
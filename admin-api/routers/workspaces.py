@@ -0,0 +1,217 @@
+"""Workspaces CRUD API endpoints.
+
+A workspace owns services, routes, consumers and plugins; the other routers
+scope every request to the workspace named in the X-Workspace header.
+Workspaces hold no gateway config of their own, so changing one publishes
+no config change event.
+"""
+
+from fastapi import APIRouter, Depends, HTTPException, status
+from sqlalchemy.orm import Session
+from typing import List
+import logging
+
+from database import get_db
+from models import (
+    Workspace as WorkspaceModel,
+    Service as ServiceModel,
+    Route as RouteModel,
+    RouteGroup as RouteGroupModel,
+    StreamListener as StreamListenerModel,
+    Consumer as ConsumerModel,
+    Plugin as PluginModel,
+    DEFAULT_WORKSPACE,
+)
+from schemas import WorkspaceCreate, WorkspaceUpdate, WorkspaceResponse
+
+
+logger = logging.getLogger(__name__)
+
+router = APIRouter()
+
+
+def _get_workspace_or_404(db: Session, name: str) -> WorkspaceModel:
+    """Load a workspace or raise 404."""
+    workspace = db.query(WorkspaceModel).filter(WorkspaceModel.name == name).first()
+    if not workspace:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"Workspace '{name}' not found"
+        )
+    return workspace
+
+
+def _entity_counts(db: Session, name: str) -> dict:
+    """Count the entities a workspace owns."""
+    return {
+        "services": db.query(ServiceModel).filter(ServiceModel.workspace == name).count(),
+        "routes": db.query(RouteModel).filter(RouteModel.workspace == name).count(),
+        "route_groups": db.query(RouteGroupModel).filter(RouteGroupModel.workspace == name).count(),
+        "stream_listeners": db.query(StreamListenerModel).filter(StreamListenerModel.workspace == name).count(),
+        "consumers": db.query(ConsumerModel).filter(ConsumerModel.workspace == name).count(),
+        "plugins": db.query(PluginModel).filter(PluginModel.workspace == name).count(),
+    }
+
+
+@router.post("", response_model=WorkspaceResponse, status_code=status.HTTP_201_CREATED)
+def create_workspace(
+    workspace: WorkspaceCreate,
+    db: Session = Depends(get_db)
+):
+    """
+    Create a new workspace.
+    """
+    logger.info(
+        "Creating workspace",
+        extra={"workspace": workspace.name}
+    )
+
+    existing = db.query(WorkspaceModel).filter(WorkspaceModel.name == workspace.name).first()
+    if existing:
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail=f"Workspace '{workspace.name}' already exists"
+        )
+
+    db_workspace = WorkspaceModel(**workspace.model_dump())
+
+    try:
+        db.add(db_workspace)
+        db.commit()
+        db.refresh(db_workspace)
+
+        logger.info(
+            "Workspace created successfully",
+            extra={"workspace": db_workspace.name}
+        )
+
+        return db_workspace
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to create workspace",
+            extra={"workspace": workspace.name, "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to create workspace"
+        )
+
+
+@router.get("", response_model=List[WorkspaceResponse])
+def list_workspaces(
+    db: Session = Depends(get_db)
+):
+    """
+    List all workspaces.
+    """
+    return db.query(WorkspaceModel).order_by(WorkspaceModel.name).all()
+
+
+@router.get("/{name}")
+def get_workspace(
+    name: str,
+    db: Session = Depends(get_db)
+):
+    """
+    Get a workspace with counts of the entities it owns.
+    """
+    workspace = _get_workspace_or_404(db, name)
+
+    return {
+        **WorkspaceResponse.model_validate(workspace).model_dump(),
+        "counts": _entity_counts(db, name),
+    }
+
+
+@router.put("/{name}", response_model=WorkspaceResponse)
+def update_workspace(
+    name: str,
+    workspace_update: WorkspaceUpdate,
+    db: Session = Depends(get_db)
+):
+    """
+    Update a workspace's description.
+    """
+    db_workspace = _get_workspace_or_404(db, name)
+
+    update_data = workspace_update.model_dump(exclude_unset=True)
+
+    try:
+        for field, value in update_data.items():
+            setattr(db_workspace, field, value)
+
+        db.commit()
+        db.refresh(db_workspace)
+
+        return db_workspace
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to update workspace",
+            extra={"workspace": name, "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to update workspace"
+        )
+
+
+@router.delete("/{name}", status_code=status.HTTP_204_NO_CONTENT)
+def delete_workspace(
+    name: str,
+    db: Session = Depends(get_db)
+):
+    """
+    Delete an empty workspace.
+
+    Workspaces that still own services, routes, route groups, consumers
+    or plugins are not deleted (409), and the default workspace cannot be
+    deleted.
+    """
+    logger.info(
+        "Deleting workspace",
+        extra={"workspace": name}
+    )
+
+    if name == DEFAULT_WORKSPACE:
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail="The default workspace cannot be deleted"
+        )
+
+    db_workspace = _get_workspace_or_404(db, name)
+
+    counts = _entity_counts(db, name)
+    if any(counts.values()):
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail={"message": f"Workspace '{name}' is not empty", "counts": counts}
+        )
+
+    try:
+        db.delete(db_workspace)
+        db.commit()
+
+        logger.info(
+            "Workspace deleted successfully",
+            extra={"workspace": name}
+        )
+
+        return None
+
+    except Exception as e:
+        db.rollback()
+        logger.error(
+            "Failed to delete workspace",
+            extra={"workspace": name, "error": str(e)},
+            exc_info=True
+        )
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to delete workspace"
+        )
@@ -1,15 +1,135 @@
 """Pydantic schemas for request/response validation."""
 
 from pydantic import BaseModel, Field, validator
-from typing import Optional, List
+from typing import Optional, List, Dict, Any
 from datetime import datetime
+import re
 from uuid import UUID
+from zoneinfo import ZoneInfo
+
+
+# ============================================================================
+# Workspace Schemas
+# ============================================================================
+
+class WorkspaceCreate(BaseModel):
+    """Schema for creating a workspace."""
+    name: str = Field(..., min_length=1, max_length=100, pattern="^[a-z0-9][a-z0-9-]*$")
+    description: Optional[str] = None
+
+
+class WorkspaceUpdate(BaseModel):
+    """Schema for updating a workspace (the name is immutable)."""
+    description: Optional[str] = None
+
+
+class WorkspaceResponse(BaseModel):
+    """Schema for workspace response."""
+    name: str
+    description: Optional[str] = None
+    created_at: datetime
+    updated_at: datetime
+    
+    class Config:
+        from_attributes = True
 
 
 # ============================================================================
 # Service Schemas
 # ============================================================================
 
+def validate_openapi_document(v):
+    """Validate an OpenAPI 3.x document (structure only)."""
+    if v is None:
+        return v
+    version = v.get("openapi")
+    if not isinstance(version, str) or not version.startswith("3."):
+        raise ValueError("openapi_spec must be an OpenAPI 3.x document")
+    if not isinstance(v.get("paths"), dict):
+        raise ValueError("openapi_spec must contain a paths object")
+    return v
+
+
+
+def validate_plugin_mode(v):
+    """Validate a plugin config's "mode" (enforce, or shadow for dry-run)
+    and "enabled_flag" (feature flag gating the plugin)."""
+    if v is not None and v.get("mode") not in (None, "enforce", "shadow"):
+        raise ValueError('mode must be "enforce" or "shadow"')
+    if v is not None and "enabled_flag" in v:
+        flag = v["enabled_flag"]
+        if not isinstance(flag, str) or not flag.strip():
+            raise ValueError("enabled_flag must be a non-empty flag name")
+    return v
+
+
+TAG_PATTERN = re.compile(r"^[a-z0-9][a-z0-9._:-]{0,63}$")
+MAX_TAGS = 20
+
+
+def validate_tags(v):
+    """Validate tags: lowercase letters, digits, '.', '_', ':' and '-', at
+    most 64 characters each and MAX_TAGS per entity (null clears them)."""
+    if v is None:
+        return []
+    if len(v) > MAX_TAGS:
+        raise ValueError(f"At most {MAX_TAGS} tags are allowed")
+    for tag in v:
+        if not TAG_PATTERN.match(tag):
+            raise ValueError(f"Invalid tag: {tag}")
+    if len(set(v)) != len(v):
+        raise ValueError("Tags must be unique")
+    return v
+
+
+def split_tags(tags):
+    """Split a ?tags=a,b list filter into its tags."""
+    if not tags:
+        return []
+    return [tag.strip() for tag in tags.split(",") if tag.strip()]
+
+
+SLO_FIELDS = {"latency_ms", "error_budget", "request_size_bytes", "response_size_bytes", "percentile", "window"}
+
+
+def validate_route_slo(v):
+    """Validate a route's SLO object (full window parsing happens in the gateway)."""
+    if not v:
+        return v
+    unknown = set(v) - SLO_FIELDS
+    if unknown:
+        raise ValueError(f"Unknown SLO fields: {sorted(unknown)}")
+    for key in ("latency_ms", "request_size_bytes", "response_size_bytes"):
+        if key in v and (not isinstance(v[key], int) or v[key] <= 0):
+            raise ValueError(f"{key} must be a positive integer")
+    for key in ("error_budget", "percentile"):
+        if key in v and not (isinstance(v[key], (int, float)) and 0 < v[key] < 1):
+            raise ValueError(f"{key} must be between 0 and 1 (exclusive)")
+    return v
+
+HEALTH_CHECK_FIELDS = {
+    "interval", "timeout", "unhealthy_threshold", "healthy_threshold",
+    "grpc_service", "tls", "tls_server_name", "tls_skip_verify",
+}
+
+
+def validate_service_health_check(v):
+    """Validate a service's health check object (durations are parsed by the gateway)."""
+    if not v:
+        return v
+    unknown = set(v) - HEALTH_CHECK_FIELDS
+    if unknown:
+        raise ValueError(f"Unknown health check fields: {sorted(unknown)}")
+    if not v.get("interval"):
+        raise ValueError("interval is required")
+    for key in ("unhealthy_threshold", "healthy_threshold"):
+        if key in v and (not isinstance(v[key], int) or v[key] <= 0):
+            raise ValueError(f"{key} must be a positive integer")
+    for key in ("tls", "tls_skip_verify"):
+        if key in v and not isinstance(v[key], bool):
+            raise ValueError(f"{key} must be a boolean")
+    return v
+
 class ServiceBase(BaseModel):
     """Base service schema with common fields."""
     name: str = Field(..., min_length=1, max_length=100)
@@ -21,8 +141,36 @@ class ServiceBase(BaseModel):
     read_timeout_ms: int = Field(default=60000, ge=100)
     write_timeout_ms: int = Field(default=60000, ge=100)
     retries: int = Field(default=0, ge=0, le=10)
-    load_balancer_type: str = Field(default="round-robin")
+    load_balancer_type: str = Field(
+        default="round-robin",
+        pattern="^(round-robin|least-connections|weighted|ip-hash|consistent-hash|locality-aware)$"
+    )
+    hash_on: str = Field(default="ip", pattern="^(ip|header|cookie|path-param)$")
+    hash_on_key: Optional[str] = Field(None, max_length=100)
+    hash_balance_factor: float = Field(default=1.25, ge=1.0, le=99.99)
+    ip_family: str = Field(
+        default="default",
+        pattern="^(default|auto|ipv4|ipv6|prefer_ipv4|prefer_ipv6)$"
+    )
+    health_check: Optional[Dict[str, Any]] = None
+    openapi_spec: Optional[Dict[str, Any]] = None
+    tags: List[str] = Field(default=[])
     enabled: bool = Field(default=True)
+    
+    @validator("health_check")
+    def validate_health_check(cls, v):
+        """Validate the active health check settings."""
+        return validate_service_health_check(v)
+    
+    @validator("openapi_spec")
+    def validate_openapi_spec(cls, v):
+        """Validate the spec is an OpenAPI 3.x document."""
+        return validate_openapi_document(v)
+    
+    @validator("tags")
+    def validate_service_tags(cls, v):
+        """Validate tags."""
+        return validate_tags(v)
 
 
 class ServiceCreate(ServiceBase):
@@ -41,13 +189,42 @@ class ServiceUpdate(BaseModel):
     read_timeout_ms: Optional[int] = Field(None, ge=100)
     write_timeout_ms: Optional[int] = Field(None, ge=100)
     retries: Optional[int] = Field(None, ge=0, le=10)
-    load_balancer_type: Optional[str] = None
+    load_balancer_type: Optional[str] = Field(
+        None,
+        pattern="^(round-robin|least-connections|weighted|ip-hash|consistent-hash|locality-aware)$"
+    )
+    hash_on: Optional[str] = Field(None, pattern="^(ip|header|cookie|path-param)$")
+    hash_on_key: Optional[str] = Field(None, max_length=100)
+    hash_balance_factor: Optional[float] = Field(None, ge=1.0, le=99.99)
+    ip_family: Optional[str] = Field(
+        None,
+        pattern="^(default|auto|ipv4|ipv6|prefer_ipv4|prefer_ipv6)$"
+    )
+    health_check: Optional[Dict[str, Any]] = None
+    openapi_spec: Optional[Dict[str, Any]] = None
+    tags: Optional[List[str]] = None
     enabled: Optional[bool] = None
+    
+    @validator("health_check")
+    def validate_health_check(cls, v):
+        """Validate the active health check settings."""
+        return validate_service_health_check(v)
+    
+    @validator("openapi_spec")
+    def validate_openapi_spec(cls, v):
+        """Validate the spec is an OpenAPI 3.x document."""
+        return validate_openapi_document(v)
+    
+    @validator("tags")
+    def validate_service_tags(cls, v):
+        """Validate tags."""
+        return validate_tags(v)
 
 
 class ServiceResponse(ServiceBase):
     """Schema for service response."""
     id: UUID
+    workspace: str
     created_at: datetime
     updated_at: datetime
     
@@ -55,6 +232,109 @@ class ServiceResponse(ServiceBase):
         from_attributes = True
 
 
+class OrphanedRoute(BaseModel):
+    """An enabled route the gateway stops serving with its service."""
+    id: UUID
+    name: Optional[str] = None
+    paths: List[str]
+    
+    class Config:
+        from_attributes = True
+
+
+class ServiceUpdateResponse(ServiceResponse):
+    """Schema for service update response.
+    
+    orphaned_routes lists the service's enabled routes while it is
+    disabled: the gateway leaves them out of routing until the service is
+    enabled again.
+    """
+    orphaned_routes: List[OrphanedRoute] = []
+
+
+# ============================================================================
+# Route Group Schemas
+# ============================================================================
+
+def validate_route_group_base_path(v):
+    """Validate a route group base path ("" or an absolute path, no wildcard)."""
+    if not v:
+        return v
+    if not v.startswith("/"):
+        raise ValueError(f"base_path must start with /: {v}")
+    if "*" in v:
+        raise ValueError("base_path cannot contain a wildcard")
+    return v.rstrip("/")
+
+
+class RouteGroupBase(BaseModel):
+    """Base route group schema with common fields."""
+    service_id: UUID
+    name: str = Field(..., min_length=1, max_length=100)
+    description: Optional[str] = None
+    base_path: str = Field(default="", max_length=255)
+    enabled: bool = Field(default=True)
+    
+    @validator("base_path")
+    def validate_base_path(cls, v):
+        """Validate the base path."""
+        return validate_route_group_base_path(v)
+
+
+class RouteGroupCreate(RouteGroupBase):
+    """Schema for creating a route group."""
+    pass
+
+
+class RouteGroupUpdate(BaseModel):
+    """Schema for updating a route group (all fields optional)."""
+    service_id: Optional[UUID] = None
+    name: Optional[str] = Field(None, min_length=1, max_length=100)
+    description: Optional[str] = None
+    base_path: Optional[str] = Field(None, max_length=255)
+    enabled: Optional[bool] = None
+    
+    @validator("base_path")
+    def validate_base_path(cls, v):
+        """Validate the base path."""
+        return validate_route_group_base_path(v)
+
+
+class RouteGroupResponse(RouteGroupBase):
+    """Schema for route group response."""
+    id: UUID
+    workspace: str
+    created_at: datetime
+    updated_at: datetime
+    
+    class Config:
+        from_attributes = True
+
+
+class RouteGroupRoutes(BaseModel):
+    """Schema for adding routes to, or removing them from, a route group."""
+    route_ids: List[UUID] = Field(..., min_length=1)
+
+
+class RouteGroupPluginCreate(BaseModel):
+    """Schema for attaching a plugin to every route of a group."""
+    name: str = Field(..., min_length=1, max_length=50)
+    config: dict = Field(default={})
+    tags: List[str] = Field(default=[])
+    enabled: bool = Field(default=True)
+    priority: int = Field(default=100, ge=1, le=1000)
+
+    @validator("config")
+    def validate_config_mode(cls, v):
+        """Validate the gateway-level "mode" option (enforce or shadow)."""
+        return validate_plugin_mode(v)
+
+    @validator("tags")
+    def validate_plugin_tags(cls, v):
+        """Validate tags."""
+        return validate_tags(v)
+
+
 # ============================================================================
 # Route Schemas
 # ============================================================================
@@ -68,6 +348,16 @@ class RouteBase(BaseModel):
     methods: List[str] = Field(default=["GET", "POST", "PUT", "DELETE", "PATCH"])
     strip_path: bool = Field(default=False)
     preserve_host: bool = Field(default=False)
+    priority_class: str = Field(default="normal", pattern="^(critical|normal|bulk)$")
+    timeout_ms: int = Field(default=0, ge=0)  # 0 = the service's read_timeout_ms
+    schedule_start: Optional[datetime] = None
+    schedule_end: Optional[datetime] = None
+    schedule_cron: Optional[str] = Field(None, max_length=100)
+    schedule_mode: str = Field(default="active", pattern="^(active|inactive)$")
+    schedule_timezone: str = Field(default="UTC", max_length=64)
+    slo: Optional[Dict[str, Any]] = None
+    group_id: Optional[UUID] = None
+    tags: List[str] = Field(default=[])
     enabled: bool = Field(default=True)
     
     @validator("methods")
@@ -86,6 +376,32 @@ class RouteBase(BaseModel):
             if not path.startswith("/"):
                 raise ValueError(f"Path must start with /: {path}")
         return v
+    
+    @validator("schedule_cron")
+    def validate_schedule_cron(cls, v):
+        """Validate cron expression has 5 fields (full parsing happens in the gateway)."""
+        if v is not None and len(v.split()) != 5:
+            raise ValueError("schedule_cron must have 5 fields: minute hour day month weekday")
+        return v
+    
+    @validator("slo")
+    def validate_slo(cls, v):
+        """Validate service level objectives."""
+        return validate_route_slo(v)
+    
+    @validator("tags")
+    def validate_route_tags(cls, v):
+        """Validate tags."""
+        return validate_tags(v)
+    
+    @validator("schedule_timezone")
+    def validate_schedule_timezone(cls, v):
+        """Validate timezone is a known IANA name."""
+        try:
+            ZoneInfo(v)
+        except Exception:
+            raise ValueError(f"Unknown timezone: {v}")
+        return v
 
 
 class RouteCreate(RouteBase):
@@ -102,12 +418,127 @@ class RouteUpdate(BaseModel):
     methods: Optional[List[str]] = None
     strip_path: Optional[bool] = None
     preserve_host: Optional[bool] = None
+    priority_class: Optional[str] = Field(None, pattern="^(critical|normal|bulk)$")
+    timeout_ms: Optional[int] = Field(None, ge=0)
+    schedule_start: Optional[datetime] = None
+    schedule_end: Optional[datetime] = None
+    schedule_cron: Optional[str] = Field(None, max_length=100)
+    schedule_mode: Optional[str] = Field(None, pattern="^(active|inactive)$")
+    schedule_timezone: Optional[str] = Field(None, max_length=64)
+    slo: Optional[Dict[str, Any]] = None
+    group_id: Optional[UUID] = None
+    tags: Optional[List[str]] = None
     enabled: Optional[bool] = None
 
+    @validator("slo")
+    def validate_slo(cls, v):
+        """Validate service level objectives."""
+        return validate_route_slo(v)
+
+    @validator("tags")
+    def validate_route_tags(cls, v):
+        """Validate tags."""
+        return validate_tags(v)
+
 
 class RouteResponse(RouteBase):
     """Schema for route response."""
     id: UUID
+    workspace: str
+    created_at: datetime
+    updated_at: datetime
+    
+    class Config:
+        from_attributes = True
+
+
+# ============================================================================
+# Redirect Schemas
+# ============================================================================
+
+REDIRECT_STATUS_CODES = (301, 302, 303, 307, 308)
+
+
+def validate_redirect_location(v):
+    """Validate a Location template has balanced, non-empty {placeholders}."""
+    if v is None:
+        return v
+    rest = v
+    while "{" in rest:
+        start = rest.index("{")
+        end = rest.find("}", start)
+        if end < 0:
+            raise ValueError("location has an unclosed {")
+        if end == start + 1:
+            raise ValueError("location has an empty {} placeholder")
+        rest = rest[end + 1:]
+    return v
+
+
+class RedirectBase(BaseModel):
+    """Base redirect schema with common fields."""
+    name: Optional[str] = Field(None, max_length=100)
+    hosts: Optional[List[str]] = None
+    paths: List[str] = Field(..., min_length=1)
+    location: str = Field(..., min_length=1)
+    status_code: int = Field(default=301)
+    preserve_query: bool = Field(default=True)
+    enabled: bool = Field(default=True)
+    
+    @validator("paths")
+    def validate_paths(cls, v):
+        """Validate paths start with / and only end with a wildcard."""
+        for path in v:
+            if not path.startswith("/"):
+                raise ValueError(f"Path must start with /: {path}")
+            if "*" in path.split("/")[:-1]:
+                raise ValueError(f"* must be the last segment: {path}")
+        return v
+    
+    @validator("status_code")
+    def validate_status_code(cls, v):
+        """Validate the redirect status code."""
+        if v not in REDIRECT_STATUS_CODES:
+            raise ValueError("status_code must be 301, 302, 303, 307 or 308")
+        return v
+    
+    @validator("location")
+    def validate_location(cls, v):
+        """Validate the Location template."""
+        return validate_redirect_location(v)
+
+
+class RedirectCreate(RedirectBase):
+    """Schema for creating a redirect."""
+    pass
+
+
+class RedirectUpdate(BaseModel):
+    """Schema for updating a redirect (all fields optional)."""
+    name: Optional[str] = Field(None, max_length=100)
+    hosts: Optional[List[str]] = None
+    paths: Optional[List[str]] = Field(None, min_length=1)
+    location: Optional[str] = Field(None, min_length=1)
+    status_code: Optional[int] = None
+    preserve_query: Optional[bool] = None
+    enabled: Optional[bool] = None
+    
+    @validator("status_code")
+    def validate_status_code(cls, v):
+        """Validate the redirect status code."""
+        if v is not None and v not in REDIRECT_STATUS_CODES:
+            raise ValueError("status_code must be 301, 302, 303, 307 or 308")
+        return v
+    
+    @validator("location")
+    def validate_location(cls, v):
+        """Validate the Location template."""
+        return validate_redirect_location(v)
+
+
+class RedirectResponse(RedirectBase):
+    """Schema for redirect response."""
+    id: UUID
     created_at: datetime
     updated_at: datetime
     
@@ -115,6 +546,160 @@ class RouteResponse(RouteBase):
         from_attributes = True
 
 
+# ============================================================================
+# Stream Listener Schemas
+# ============================================================================
+
+def validate_stream_listen_address(v):
+    """Validate a listen address: [host]:port, e.g. ':1883' or '[::]:5353'."""
+    if v is None:
+        return v
+    host, sep, port = v.rpartition(":")
+    if not sep or not port.isdigit() or not 1 <= int(port) <= 65535:
+        raise ValueError("listen_address must be [host]:port with a port between 1 and 65535")
+    if ":" in host and not (host.startswith("[") and host.endswith("]")):
+        raise ValueError("IPv6 listen addresses must be bracketed, e.g. '[::1]:1883'")
+    return v
+
+
+class StreamListenerBase(BaseModel):
+    """Base stream listener schema with common fields."""
+    service_id: UUID
+    name: Optional[str] = Field(None, max_length=100)
+    protocol: str = Field(default="tcp", pattern="^(tcp|udp)$")
+    listen_address: str = Field(..., min_length=2, max_length=255)
+    max_connections: int = Field(default=0, ge=0)
+    idle_timeout_ms: int = Field(default=300000, ge=0)
+    enabled: bool = Field(default=True)
+    
+    @validator("listen_address")
+    def validate_listen_address(cls, v):
+        """Validate the listen address."""
+        return validate_stream_listen_address(v)
+
+
+class StreamListenerCreate(StreamListenerBase):
+    """Schema for creating a stream listener."""
+    pass
+
+
+class StreamListenerUpdate(BaseModel):
+    """Schema for updating a stream listener (all fields optional)."""
+    service_id: Optional[UUID] = None
+    name: Optional[str] = Field(None, max_length=100)
+    protocol: Optional[str] = Field(None, pattern="^(tcp|udp)$")
+    listen_address: Optional[str] = Field(None, min_length=2, max_length=255)
+    max_connections: Optional[int] = Field(None, ge=0)
+    idle_timeout_ms: Optional[int] = Field(None, ge=0)
+    enabled: Optional[bool] = None
+    
+    @validator("listen_address")
+    def validate_listen_address(cls, v):
+        """Validate the listen address."""
+        return validate_stream_listen_address(v)
+
+
+class StreamListenerResponse(StreamListenerBase):
+    """Schema for stream listener response."""
+    id: UUID
+    workspace: str
+    created_at: datetime
+    updated_at: datetime
+    
+    class Config:
+        from_attributes = True
+
+
+# ============================================================================
+# Tenant Schemas
+# ============================================================================
+
+class TenantBase(BaseModel):
+    """Base tenant schema with common fields."""
+    name: Optional[str] = Field(None, max_length=255)
+    enabled: bool = Field(default=True)
+
+
+class TenantCreate(TenantBase):
+    """Schema for creating a tenant (the ID is a DNS label)."""
+    id: str = Field(..., min_length=1, max_length=63, pattern="^[a-z0-9]([a-z0-9-]*[a-z0-9])?$")
+
+
+class TenantUpdate(BaseModel):
+    """Schema for updating a tenant (the ID is immutable)."""
+    name: Optional[str] = Field(None, max_length=255)
+    enabled: Optional[bool] = None
+
+
+class TenantResponse(TenantBase):
+    """Schema for tenant response."""
+    id: str
+    created_at: datetime
+    updated_at: datetime
+    
+    class Config:
+        from_attributes = True
+
+
+# ============================================================================
+# Gateway Setting Schemas
+# ============================================================================
+
+# Settings the gateway applies at runtime (lower-cased env var names)
+GATEWAY_INT_SETTINGS = (
+    "upstream_max_idle_conns",
+    "upstream_max_idle_conns_per_host",
+    "upstream_max_conns_per_host",
+)
+GATEWAY_DURATION_SETTINGS = (
+    "upstream_dial_timeout",
+    "upstream_keep_alive",
+    "upstream_idle_conn_timeout",
+    "upstream_tls_handshake_timeout",
+    "upstream_response_header_timeout",
+    "upstream_expect_continue_timeout",
+    "upstream_happy_eyeballs_delay",
+)
+GATEWAY_CHOICE_SETTINGS = {
+    "upstream_ip_family": ("auto", "ipv4", "ipv6", "prefer_ipv4", "prefer_ipv6"),
+}
+
+GO_DURATION_PATTERN = re.compile(r"^(\d+(\.\d+)?(ns|us|µs|ms|s|m|h))+$|^0$")
+
+
+def validate_gateway_setting(key: str, value: str) -> str:
+    """Validate a setting value for its key (integer count, Go duration or choice)."""
+    value = value.strip()
+    if key in GATEWAY_INT_SETTINGS:
+        if not value.isdigit():
+            raise ValueError(f"{key} must be a non-negative integer")
+    elif key in GATEWAY_DURATION_SETTINGS:
+        if not GO_DURATION_PATTERN.match(value):
+            raise ValueError(f'{key} must be a duration such as "500ms" or "30s"')
+    elif key in GATEWAY_CHOICE_SETTINGS:
+        value = value.lower()
+        if value not in GATEWAY_CHOICE_SETTINGS[key]:
+            raise ValueError(f"{key} must be one of: {', '.join(GATEWAY_CHOICE_SETTINGS[key])}")
+    else:
+        raise ValueError(f"unknown setting: {key}")
+    return value
+
+
+class GatewaySettingUpdate(BaseModel):
+    """Schema for setting a gateway setting's value."""
+    value: str = Field(..., min_length=1)
+
+
+class GatewaySettingResponse(BaseModel):
+    """Schema for gateway setting response."""
+    key: str
+    value: str
+    updated_at: Optional[datetime] = None
+    
+    class Config:
+        from_attributes = True
+
+
 # ============================================================================
 # Health Check Schema
 # ============================================================================
@@ -154,6 +739,8 @@ class ConsumerUpdate(BaseModel):
 class ConsumerResponse(ConsumerBase):
     """Schema for consumer response."""
     id: UUID
+    workspace: str
+    owner: Optional[str] = None
     created_at: datetime
     updated_at: datetime
     
@@ -168,14 +755,26 @@ class ConsumerResponse(ConsumerBase):
 class PluginBase(BaseModel):
     """Base plugin schema with common fields."""
     name: str = Field(..., min_length=1, max_length=50)
-    scope: str = Field(..., pattern="^(global|service|route|consumer)$")
+    scope: str = Field(..., pattern="^(global|service|route|group|consumer)$")
     service_id: Optional[UUID] = None
     route_id: Optional[UUID] = None
+    group_id: Optional[UUID] = None
     consumer_id: Optional[UUID] = None
     config: dict = Field(default={})
+    tags: List[str] = Field(default=[])
     enabled: bool = Field(default=True)
     priority: int = Field(default=100, ge=1, le=1000)
 
+    @validator("config")
+    def validate_config_mode(cls, v):
+        """Validate the gateway-level "mode" option (enforce or shadow)."""
+        return validate_plugin_mode(v)
+
+    @validator("tags")
+    def validate_plugin_tags(cls, v):
+        """Validate tags."""
+        return validate_tags(v)
+
 
 class PluginCreate(PluginBase):
     """Schema for creating a plugin."""
@@ -185,20 +784,49 @@ class PluginCreate(PluginBase):
 class PluginUpdate(BaseModel):
     """Schema for updating a plugin (all fields optional)."""
     name: Optional[str] = Field(None, min_length=1, max_length=50)
-    scope: Optional[str] = Field(None, pattern="^(global|service|route|consumer)$")
+    scope: Optional[str] = Field(None, pattern="^(global|service|route|group|consumer)$")
     service_id: Optional[UUID] = None
     route_id: Optional[UUID] = None
+    group_id: Optional[UUID] = None
     consumer_id: Optional[UUID] = None
     config: Optional[dict] = None
+    tags: Optional[List[str]] = None
     enabled: Optional[bool] = None
     priority: Optional[int] = Field(None, ge=1, le=1000)
 
+    @validator("config")
+    def validate_config_mode(cls, v):
+        """Validate the gateway-level "mode" option (enforce or shadow)."""
+        return validate_plugin_mode(v)
+
+    @validator("tags")
+    def validate_plugin_tags(cls, v):
+        """Validate tags."""
+        return validate_tags(v)
+
 
 class PluginResponse(PluginBase):
     """Schema for plugin response."""
     id: UUID
+    workspace: str
     created_at: datetime
     updated_at: datetime
     
     class Config:
-        from_attributes = True
\ No newline at end of file
+        from_attributes = True
+
+# ============================================================================
+# Developer Portal Schemas
+# ============================================================================
+
+class PortalConsumerCreate(BaseModel):
+    """Schema for a developer creating their own consumer."""
+    username: str = Field(..., min_length=1, max_length=100, pattern="^[A-Za-z0-9._-]+$")
+    custom_id: Optional[str] = Field(None, max_length=100)
+
+
+class PortalKeyCreate(BaseModel):
+    """Schema for a developer issuing an API key."""
+    name: Optional[str] = Field(None, max_length=100)
+    scopes: List[str] = Field(default=[])
+    expires_in_days: Optional[int] = Field(None, ge=1)
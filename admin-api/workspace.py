@@ -0,0 +1,33 @@
+"""Workspace scoping for Admin API requests.
+
+Every service, route, consumer and plugin belongs to a workspace. Requests
+select their workspace with the X-Workspace header (default: "default");
+the Admin API has no auth of its own, so deployments that give teams their
+own workspace should set or validate this header in the proxy in front of it.
+"""
+
+from fastapi import Depends, Header, HTTPException, status
+from sqlalchemy.orm import Session
+from typing import Optional
+
+from database import get_db
+from models import Workspace as WorkspaceModel, DEFAULT_WORKSPACE
+
+
+WORKSPACE_HEADER = "X-Workspace"
+
+
+def get_workspace(
+    x_workspace: Optional[str] = Header(default=None, alias=WORKSPACE_HEADER),
+    db: Session = Depends(get_db)
+) -> str:
+    """Resolve the request's workspace, or 404 if it does not exist."""
+    name = (x_workspace or "").strip() or DEFAULT_WORKSPACE
+
+    if not db.query(WorkspaceModel).filter(WorkspaceModel.name == name).first():
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"Workspace '{name}' not found"
+        )
+
+    return name
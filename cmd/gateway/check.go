@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/clientip"
+	"github.com/saidutt46/switchboard-gateway/internal/config"
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+	"github.com/saidutt46/switchboard-gateway/internal/signedurl"
+	"github.com/saidutt46/switchboard-gateway/internal/tokenmint"
+)
+
+// Check step statuses. A warning fails the check only with -strict.
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// checkReport is the machine-readable result of `gateway --check`.
+type checkReport struct {
+	Status    string      `json:"status"` // "pass" or "fail"
+	Version   string      `json:"version"`
+	Strict    bool        `json:"strict"`
+	CheckedAt time.Time   `json:"checked_at"`
+	Steps     []checkStep `json:"steps"`
+}
+
+// checkStep is one step of the self-test.
+type checkStep struct {
+	Name       string                 `json:"name"`
+	Status     string                 `json:"status"` // pass, warn, fail or skip
+	Message    string                 `json:"message,omitempty"`
+	Problems   []string               `json:"problems,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	DurationMs int64                  `json:"duration_ms"`
+}
+
+// runCheck implements `gateway --check`: a startup self-test for CI and
+// deployment gates. It goes through startup without serving traffic -
+// loads the configuration, connects to Postgres and Redis, builds every
+// enabled plugin, validates routes and their references, and builds the
+// radix tree - then prints a JSON report on stdout and exits 0 if every
+// step passed, 1 otherwise.
+//
+// Usage:
+//
+//	gateway --check [-strict] [-timeout 30s]
+//
+// Redis being unreachable, route conflicts and routes orphaned by a
+// disabled or missing service are warnings, since the gateway starts with
+// them; -strict fails the check on warnings too.
+// Logs go to stderr.
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	strict := fs.Bool("strict", false, "Fail on warnings (Redis unreachable, route conflicts, orphaned routes)")
+	timeout := fs.Duration("timeout", 30*time.Second, "Time allowed for the database queries")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Keep stdout for the report
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}).With().Timestamp().Logger()
+
+	report := selfTest(*timeout)
+	report.Strict = *strict
+	report.Status = checkPass
+
+	var failed []string
+	for _, step := range report.Steps {
+		if step.Status == checkFail || (*strict && step.Status == checkWarn) {
+			failed = append(failed, step.Name)
+		}
+	}
+	if len(failed) > 0 {
+		report.Status = checkFail
+	}
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	os.Stdout.Write(append(out, '\n'))
+
+	if len(failed) > 0 {
+		return fmt.Errorf("self-test failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// selfTest runs the check steps in startup order. Steps that depend on a
+// failed one are skipped.
+func selfTest(timeout time.Duration) checkReport {
+	report := checkReport{Version: Version, CheckedAt: time.Now().UTC()}
+	step := func(name string, fn func(s *checkStep)) bool {
+		s := checkStep{Name: name, Status: checkPass}
+		start := time.Now()
+		fn(&s)
+		s.DurationMs = time.Since(start).Milliseconds()
+		report.Steps = append(report.Steps, s)
+		return s.Status != checkFail
+	}
+	skip := func(reason string, names ...string) {
+		for _, name := range names {
+			report.Steps = append(report.Steps, checkStep{Name: name, Status: checkSkip, Message: reason})
+		}
+	}
+
+	_ = godotenv.Load()
+
+	var cfg *config.Config
+	var tokenSigner *tokenmint.Signer
+	var urlSigner *signedurl.Signer
+	ok := step("config", func(s *checkStep) {
+		var err error
+		if cfg, err = config.Load(); err != nil {
+			s.fail(err)
+			return
+		}
+		tokenSigner, urlSigner = checkStartupConfig(s, cfg)
+	})
+	if !ok {
+		skip("configuration invalid", "database", "redis", "plugins", "routes", "router")
+		return report
+	}
+
+	var db *database.DB
+	dbOK := step("database", func(s *checkStep) {
+		var err error
+		if db, err = database.NewDB(cfg.Database); err != nil {
+			s.fail(err)
+			return
+		}
+		s.Message = "connected"
+	})
+
+	step("redis", func(s *checkStep) {
+		client, err := initializeRedis(cfg)
+		if err != nil {
+			s.Status = checkWarn
+			s.Message = fmt.Sprintf("%v - hot reload would fall back to Postgres LISTEN/NOTIFY", err)
+			return
+		}
+		client.Close()
+		s.Message = "connected"
+	})
+
+	if !dbOK {
+		skip("database unavailable", "plugins", "routes", "router")
+		return report
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	repo := database.NewRepository(db)
+	if cipher, pepper, err := loadEncryption(cfg.Encryption); err == nil {
+		repo.SetCipher(cipher)
+		repo.SetAPIKeyPepper(pepper)
+	}
+
+	var instances []plugin.PluginInstance
+	defer func() { plugin.CloseInstances(instances) }()
+	ok = step("plugins", func(s *checkStep) {
+		registry := newPluginRegistry(cfg, repo, nil, nil, nil, tokenSigner, urlSigner, newResponseCache(cfg), nil, nil)
+		built, buildErrors, err := registry.Build(ctx, repo)
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		instances = built
+		for _, buildErr := range buildErrors {
+			s.Problems = append(s.Problems, buildErr.Error())
+		}
+		if len(s.Problems) > 0 {
+			s.Status = checkFail
+			s.Message = fmt.Sprintf("%d plugin(s) failed to build", len(s.Problems))
+		}
+		s.Details = map[string]interface{}{"built": len(built)}
+	})
+	if !ok {
+		skip("plugins invalid", "routes", "router")
+		return report
+	}
+
+	var routes []*database.Route
+	var services []*database.Service
+	ok = step("routes", func(s *checkStep) {
+		var err error
+		if routes, err = repo.GetRoutes(ctx, false); err != nil {
+			s.fail(err)
+			return
+		}
+		// Disabled services too, so they aren't reported as missing
+		if services, err = repo.GetServices(ctx, true); err != nil {
+			s.fail(err)
+			return
+		}
+
+		var validationErr *router.ValidationError
+		if err := router.ValidateConfig(routes, services, instances, nil); errors.As(err, &validationErr) {
+			s.Status = checkFail
+			s.Message = fmt.Sprintf("%d problem(s) found", len(validationErr.Problems))
+			s.Problems = validationErr.Problems
+		}
+		s.Details = map[string]interface{}{"routes": len(routes), "services": len(services)}
+	})
+	if !ok {
+		skip("routes invalid", "router")
+		return report
+	}
+
+	step("router", func(s *checkStep) {
+		rt := router.NewRouter(routes, services, instances)
+		s.Details = rt.Stats()
+
+		conflicts, orphans := rt.Conflicts(), rt.Orphans()
+		for _, conflict := range conflicts {
+			s.Problems = append(s.Problems, conflict.String())
+		}
+		for _, orphan := range orphans {
+			s.Problems = append(s.Problems, orphan.String())
+		}
+		if len(s.Problems) > 0 {
+			s.Status = checkWarn
+			s.Message = fmt.Sprintf("%d route conflict(s), %d orphaned route(s)", len(conflicts), len(orphans))
+		}
+	})
+
+	return report
+}
+
+// checkStartupConfig validates the settings startup parses beyond
+// config.Load: encryption keys, trusted proxies, the body buffer spill
+// directory and signing keys. Returns the signers the plugins need.
+func checkStartupConfig(s *checkStep, cfg *config.Config) (*tokenmint.Signer, *signedurl.Signer) {
+	problem := func(err error) {
+		s.Problems = append(s.Problems, err.Error())
+	}
+
+	if _, _, err := loadEncryption(cfg.Encryption); err != nil {
+		problem(err)
+	}
+	if _, err := clientip.NewResolver(cfg.TrustedProxies); err != nil {
+		problem(fmt.Errorf("invalid TRUSTED_PROXIES: %w", err))
+	}
+	if _, err := bodybuffer.NewManager(bodybuffer.Config{SpillDir: cfg.BodyBuffer.SpillDir}); err != nil {
+		problem(fmt.Errorf("invalid BODY_BUFFER_SPILL_DIR: %w", err))
+	}
+
+	var tokenSigner *tokenmint.Signer
+	if len(cfg.TokenMint.KeyFiles) > 0 {
+		signer, err := tokenmint.LoadSigner(cfg.TokenMint.KeyFiles)
+		if err != nil {
+			problem(err)
+		}
+		tokenSigner = signer
+	}
+
+	var urlSigner *signedurl.Signer
+	if len(cfg.SignedURL.Secrets) > 0 {
+		signer, err := signedurl.NewSigner(cfg.SignedURL.Secrets, cfg.SignedURL.MaxTTL)
+		if err != nil {
+			problem(err)
+		}
+		urlSigner = signer
+	}
+
+	if len(s.Problems) > 0 {
+		s.Status = checkFail
+		s.Message = fmt.Sprintf("%d problem(s) found", len(s.Problems))
+	}
+	return tokenSigner, urlSigner
+}
+
+// fail marks the step failed with err.
+func (s *checkStep) fail(err error) {
+	s.Status = checkFail
+	s.Message = err.Error()
+}
@@ -0,0 +1,429 @@
+//go:build integration
+// +build integration
+
+package main
+
+// ============================================================================
+// End-to-end tests
+// ============================================================================
+//
+// These tests assemble the gateway from the same components run() uses
+// (database, plugin registry, router, balancers, proxy, hot reload watcher,
+// HTTP mux) against real Postgres and Redis, and drive it over HTTP.
+//
+// They need disposable instances - the Postgres schema is dropped and
+// recreated from schema.sql:
+//
+//	make test-e2e
+//
+// or, with your own containers:
+//
+//	E2E_POSTGRES_DSN=postgres://... E2E_REDIS_URL=redis://... \
+//	    go test -tags integration -run E2E ./cmd/gateway
+//
+// Without E2E_POSTGRES_DSN the tests are skipped.
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/saidutt46/switchboard-gateway/internal/admission"
+	"github.com/saidutt46/switchboard-gateway/internal/clientip"
+	"github.com/saidutt46/switchboard-gateway/internal/config"
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/gateway"
+	"github.com/saidutt46/switchboard-gateway/internal/health"
+	"github.com/saidutt46/switchboard-gateway/internal/loadbalancer"
+	"github.com/saidutt46/switchboard-gateway/internal/pathnorm"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/proxy"
+	"github.com/saidutt46/switchboard-gateway/internal/recording"
+	"github.com/saidutt46/switchboard-gateway/internal/redirect"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+	"github.com/saidutt46/switchboard-gateway/internal/slo"
+)
+
+// e2eReloadTimeout bounds how long a published config change may take to
+// be applied.
+const e2eReloadTimeout = 10 * time.Second
+
+// e2eHarness is a running gateway with its backing stores.
+type e2eHarness struct {
+	t       *testing.T
+	sql     *sql.DB       // direct access for seeding
+	redis   *redis.Client // publishes config changes
+	server  *httptest.Server
+	backend *httptest.Server
+}
+
+// newE2EHarness resets the database, seeds it with seed and starts a
+// gateway in front of an echo backend.
+//
+// seed runs before the gateway loads its configuration; the backend's
+// host and port are passed for creating services.
+func newE2EHarness(t *testing.T, seed func(h *e2eHarness, host string, port int)) *e2eHarness {
+	t.Helper()
+
+	dsn := os.Getenv("E2E_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("Skipping end-to-end test: E2E_POSTGRES_DSN not set")
+	}
+	redisURL := e2eRedisURL()
+
+	h := &e2eHarness{t: t}
+
+	// Backend echoing the path it received
+	h.backend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend-Path", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(h.backend.Close)
+
+	var err error
+	h.sql, err = sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { h.sql.Close() })
+	h.resetSchema()
+
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		t.Fatalf("Invalid E2E_REDIS_URL: %v", err)
+	}
+	h.redis = redis.NewClient(opt)
+	t.Cleanup(func() { h.redis.Close() })
+	if err := h.redis.FlushDB(context.Background()).Err(); err != nil {
+		t.Fatalf("Failed to reset Redis: %v", err)
+	}
+
+	host, portStr, _ := net.SplitHostPort(h.backend.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	if seed != nil {
+		seed(h, host, port)
+	}
+
+	t.Setenv("POSTGRES_DSN", dsn)
+	t.Setenv("REDIS_URL", redisURL)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	h.start(cfg)
+	return h
+}
+
+// resetSchema recreates the schema and removes its sample data.
+func (h *e2eHarness) resetSchema() {
+	h.t.Helper()
+
+	schema, err := os.ReadFile("../../schema.sql")
+	if err != nil {
+		h.t.Fatalf("Failed to read schema.sql: %v", err)
+	}
+
+	statements := []string{
+		"DROP SCHEMA public CASCADE",
+		"CREATE SCHEMA public",
+		string(schema),
+		"TRUNCATE services, consumers CASCADE",
+	}
+	for _, stmt := range statements {
+		if _, err := h.sql.Exec(stmt); err != nil {
+			h.t.Fatalf("Failed to reset schema: %v", err)
+		}
+	}
+}
+
+// start assembles the gateway the way run() does and serves it.
+func (h *e2eHarness) start(cfg *config.Config) {
+	h.t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	h.t.Cleanup(cancel)
+
+	db, err := database.NewDB(cfg.Database)
+	if err != nil {
+		h.t.Fatalf("Failed to connect to database: %v", err)
+	}
+	h.t.Cleanup(func() { db.Close() })
+	repo := database.NewRepository(db)
+
+	recorder := recording.NewRecorder(repo, 100)
+	h.t.Cleanup(recorder.Close)
+
+	registry, instances, err := initializePlugins(ctx, cfg, repo, repo, recorder, nil, nil, nil, nil, nil, nil, nil)
+	if err != nil {
+		h.t.Fatalf("Failed to initialize plugins: %v", err)
+	}
+
+	routes, err := repo.GetRoutes(ctx, false)
+	if err != nil {
+		h.t.Fatalf("Failed to load routes: %v", err)
+	}
+	services, err := repo.GetServices(ctx, false)
+	if err != nil {
+		h.t.Fatalf("Failed to load services: %v", err)
+	}
+	rt := router.NewRouter(routes, services, instances)
+	rt.SetAutoOptions(cfg.AutoOptions)
+	rt.SetDefaultService(cfg.DefaultService)
+
+	balancers := loadbalancer.NewManager(nil)
+	if err := balancers.Reload(ctx, repo); err != nil {
+		h.t.Fatalf("Failed to initialize load balancers: %v", err)
+	}
+
+	clientResolver, err := clientip.NewResolver(nil)
+	if err != nil {
+		h.t.Fatalf("Failed to create client IP resolver: %v", err)
+	}
+
+	px := proxy.NewProxy(rt, proxy.NewTransport(&proxy.TransportConfig{
+		MaxIdleConns:          10,
+		MaxIdleConnsPerHost:   10,
+		DialTimeout:           5 * time.Second,
+		IdleConnTimeout:       30 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+	}), balancers)
+	px.SetHeaderConfig(proxy.HeaderConfig{TrustedProxies: clientResolver})
+
+	redirects := redirect.NewEngine()
+	if err := redirects.Reload(ctx, repo); err != nil {
+		h.t.Fatalf("Failed to load redirects: %v", err)
+	}
+
+	gw := gateway.New(rt, repo, registry, balancers)
+	gw.SetRedirects(redirects)
+	go watchConfigChanges(ctx, h.redis, db, gw)
+
+	mux := setupRoutes(health.NewHandler(db, repo), rt, px, redirects, nil, admission.NewController(admission.Config{}), nil, slo.NewTracker(), nil, nil, clientResolver, nil, plugin.DecisionLogConfig{}, nil, nil, nil)
+
+	h.server = httptest.NewServer(pathnorm.Handler(pathnorm.DefaultConfig(), mux))
+	h.t.Cleanup(h.server.Close)
+
+	// Give the watcher time to subscribe before tests publish changes
+	time.Sleep(200 * time.Millisecond)
+}
+
+// exec runs a seeding statement and returns the first column of its
+// RETURNING clause, if any.
+func (h *e2eHarness) exec(query string, args ...interface{}) string {
+	h.t.Helper()
+
+	var id sql.NullString
+	err := h.sql.QueryRow(query, args...).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		h.t.Fatalf("Seed query failed: %v\n%s", err, query)
+	}
+	return id.String
+}
+
+// addService creates a service pointing at the backend.
+func (h *e2eHarness) addService(name, host string, port int) string {
+	return h.exec(
+		`INSERT INTO services (name, protocol, host, port) VALUES ($1, 'http', $2, $3) RETURNING id`,
+		name, host, port,
+	)
+}
+
+// addRoute creates a route for a service.
+func (h *e2eHarness) addRoute(serviceID, name string, paths ...string) string {
+	return h.exec(
+		`INSERT INTO routes (service_id, name, paths) VALUES ($1, $2, $3) RETURNING id`,
+		serviceID, name, pqArray(paths),
+	)
+}
+
+// addPlugin creates a plugin. scope is "global" or "route" (routeID set).
+func (h *e2eHarness) addPlugin(name, scope, routeID string, config interface{}) string {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		h.t.Fatalf("Invalid plugin config: %v", err)
+	}
+
+	var route interface{}
+	if routeID != "" {
+		route = routeID
+	}
+	return h.exec(
+		`INSERT INTO plugins (name, scope, route_id, config) VALUES ($1, $2, $3, $4) RETURNING id`,
+		name, scope, route, string(raw),
+	)
+}
+
+// publish announces a config change the way the admin API does.
+func (h *e2eHarness) publish(entityType, entityID, action string) {
+	h.t.Helper()
+
+	payload, _ := json.Marshal(config.ConfigChangeEvent{
+		EventType:  "config_change",
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+	})
+	if err := h.redis.Publish(context.Background(), "gateway:config:changes", payload).Err(); err != nil {
+		h.t.Fatalf("Failed to publish config change: %v", err)
+	}
+}
+
+// get sends a GET request through the gateway.
+func (h *e2eHarness) get(path string, header http.Header) *http.Response {
+	h.t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, h.server.URL+path, nil)
+	if err != nil {
+		h.t.Fatalf("Failed to build request: %v", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		h.t.Fatalf("GET %s failed: %v", path, err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp
+}
+
+// eventually polls check until it passes or e2eReloadTimeout elapses.
+func (h *e2eHarness) eventually(what string, check func() bool) {
+	h.t.Helper()
+
+	deadline := time.Now().Add(e2eReloadTimeout)
+	for time.Now().Before(deadline) {
+		if check() {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	h.t.Fatalf("Timed out waiting for %s", what)
+}
+
+// e2eRedisURL returns the Redis instance for tests (database 15 of the
+// local instance by default, flushed by every test).
+func e2eRedisURL() string {
+	if url := os.Getenv("E2E_REDIS_URL"); url != "" {
+		return url
+	}
+	return "redis://localhost:6379/15"
+}
+
+// pqArray formats a Postgres text array literal.
+func pqArray(values []string) string {
+	out := "{"
+	for i, v := range values {
+		if i > 0 {
+			out += ","
+		}
+		out += strconv.Quote(v)
+	}
+	return out + "}"
+}
+
+// ============================================================================
+// Tests
+// ============================================================================
+
+func TestE2E_Routing(t *testing.T) {
+	h := newE2EHarness(t, func(h *e2eHarness, host string, port int) {
+		svc := h.addService("users", host, port)
+		h.addRoute(svc, "users", "/api/users", "/api/users/:id")
+	})
+
+	resp := h.get("/api/users/42", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-Backend-Path"); got != "/api/users/42" {
+		t.Errorf("backend saw path %q, want /api/users/42", got)
+	}
+
+	if resp := h.get("/api/orders", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unrouted path status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestE2E_PluginExecution(t *testing.T) {
+	h := newE2EHarness(t, func(h *e2eHarness, host string, port int) {
+		svc := h.addService("web", host, port)
+		h.addRoute(svc, "web", "/web")
+		h.addPlugin("cors", "global", "", map[string]interface{}{
+			"allowed_origins": []string{"https://app.example.com"},
+		})
+	})
+
+	resp := h.get("/web", http.Header{"Origin": {"https://app.example.com"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the allowed origin", got)
+	}
+}
+
+func TestE2E_RateLimiting(t *testing.T) {
+	const limit = 3
+
+	h := newE2EHarness(t, func(h *e2eHarness, host string, port int) {
+		svc := h.addService("limited", host, port)
+		route := h.addRoute(svc, "limited", "/limited")
+		h.addPlugin("rate-limit", "route", route, map[string]interface{}{
+			"algorithm":  "sliding-window",
+			"limit":      limit,
+			"window":     "1m",
+			"identifier": "ip",
+			"redis_url":  e2eRedisURL(),
+			"key_prefix": fmt.Sprintf("e2e_rate_limit_%d:", time.Now().UnixNano()),
+		})
+	})
+
+	for i := 0; i < limit; i++ {
+		if resp := h.get("/limited", nil); resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i+1, resp.StatusCode)
+		}
+	}
+	if resp := h.get("/limited", nil); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("request over limit status = %d, want 429", resp.StatusCode)
+	}
+}
+
+func TestE2E_HotReload(t *testing.T) {
+	var serviceID string
+	h := newE2EHarness(t, func(h *e2eHarness, host string, port int) {
+		serviceID = h.addService("reload", host, port)
+		h.addRoute(serviceID, "v1", "/v1")
+	})
+
+	if resp := h.get("/v2", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status before reload = %d, want 404", resp.StatusCode)
+	}
+
+	routeID := h.addRoute(serviceID, "v2", "/v2")
+	h.publish("route", routeID, "created")
+	h.eventually("new route to be served", func() bool {
+		return h.get("/v2", nil).StatusCode == http.StatusOK
+	})
+
+	h.exec(`UPDATE routes SET enabled = false WHERE id = $1 RETURNING id`, routeID)
+	h.publish("route", routeID, "updated")
+	h.eventually("disabled route to stop being served", func() bool {
+		return h.get("/v2", nil).StatusCode == http.StatusNotFound
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/saidutt46/switchboard-gateway/internal/config"
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/fieldcrypt"
+	"github.com/saidutt46/switchboard-gateway/internal/logging"
+)
+
+// loadEncryption builds the field cipher and resolves the API key pepper.
+//
+// Both are optional: a nil cipher disables decryption (encrypted values
+// then fail to load), and an empty pepper keeps plain SHA256 key hashes.
+func loadEncryption(cfg config.EncryptionConfig) (*fieldcrypt.Cipher, []byte, error) {
+	cipher, err := fieldcrypt.LoadCipher(cfg.Key, cfg.KeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+
+	pepper, err := fieldcrypt.DecryptValue(cipher, cfg.APIKeyPepper)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt API_KEY_PEPPER: %w", err)
+	}
+
+	return cipher, []byte(pepper), nil
+}
+
+// runEncryptConfig implements `gateway encrypt-config`.
+//
+// Usage:
+//
+//	gateway encrypt-config -generate-key     print a new random key
+//	gateway encrypt-config -value <secret>   print an encrypted value (e.g. for API_KEY_PEPPER)
+//	gateway encrypt-config                   encrypt designated fields of existing plugin configs
+//
+// The last form migrates a database after enabling CONFIG_ENCRYPTION_KEY.
+// It is idempotent; already-encrypted values are skipped.
+func runEncryptConfig(args []string) error {
+	fs := flag.NewFlagSet("encrypt-config", flag.ContinueOnError)
+	generateKey := fs.Bool("generate-key", false, "Print a new random base64 key and exit")
+	value := fs.String("value", "", "Encrypt a single value and print it")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *generateKey {
+		key := make([]byte, fieldcrypt.KeySize)
+		if _, err := rand.Read(key); err != nil {
+			return fmt.Errorf("failed to generate key: %w", err)
+		}
+		fmt.Println(base64.StdEncoding.EncodeToString(key))
+		return nil
+	}
+
+	_ = godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := logging.Setup("warn", "console"); err != nil {
+		return fmt.Errorf("failed to setup logging: %w", err)
+	}
+
+	cipher, _, err := loadEncryption(cfg.Encryption)
+	if err != nil {
+		return err
+	}
+	if cipher == nil {
+		return fmt.Errorf("set CONFIG_ENCRYPTION_KEY or CONFIG_ENCRYPTION_KEY_FILE first")
+	}
+
+	if *value != "" {
+		encrypted, err := cipher.Encrypt(*value)
+		if err != nil {
+			return err
+		}
+		fmt.Println(encrypted)
+		return nil
+	}
+
+	db, err := database.NewDB(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	updated, err := database.NewRepository(db).EncryptPluginConfigs(ctx, cipher, cfg.Encryption.Fields)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Encrypted fields %v in %d plugin configs\n", cfg.Encryption.Fields, updated)
+	return nil
+}
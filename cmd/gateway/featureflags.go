@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/config"
+	"github.com/saidutt46/switchboard-gateway/internal/featureflag"
+)
+
+// newFlagProvider creates the feature flag provider gating plugins with
+// "enabled_flag". The HTTP provider is polled in the background after a
+// first synchronous fetch.
+func newFlagProvider(cfg config.FeatureFlagsConfig) featureflag.Provider {
+	if cfg.Provider != "http" {
+		return featureflag.NewEnvProvider(cfg.EnvPrefix)
+	}
+
+	var header http.Header
+	if cfg.Token != "" {
+		header = http.Header{"Authorization": {"Bearer " + cfg.Token}}
+	}
+	provider := featureflag.NewHTTPProvider(cfg.URL, header, cfg.Timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	if err := provider.Refresh(ctx); err != nil {
+		log.Warn().
+			Err(err).
+			Str("component", "featureflag").
+			Msg("Initial feature flag fetch failed - flag-gated plugins are off until the flag service answers")
+	}
+	go provider.Run(context.Background(), cfg.RefreshInterval)
+
+	log.Info().
+		Str("component", "featureflag").
+		Str("url", cfg.URL).
+		Dur("refresh_interval", cfg.RefreshInterval).
+		Msg("Feature flags from flag service")
+
+	return provider
+}
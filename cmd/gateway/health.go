@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/health"
+	"github.com/saidutt46/switchboard-gateway/internal/loadbalancer"
+	"github.com/saidutt46/switchboard-gateway/internal/metering"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+)
+
+// registerHealthChecks adds the gateway's subsystems to /health. The
+// database is registered by health.NewHandler; everything here is
+// non-critical, so a failure degrades /health without failing /ready.
+func registerHealthChecks(h *health.Handler, redisClient *redis.Client, pluginRegistry *plugin.Registry, rt *router.Router, balancers *loadbalancer.Manager, meter *metering.Meter) {
+	h.Register("redis", health.CheckerFunc(func(ctx context.Context) (map[string]interface{}, error) {
+		if redisClient == nil {
+			return nil, errors.New("not connected - hot reload uses Postgres LISTEN/NOTIFY")
+		}
+		return nil, redisClient.Ping(ctx).Err()
+	}), health.CheckOptions{Timeout: time.Second})
+
+	h.Register("ratelimit_redis", health.CheckerFunc(func(ctx context.Context) (map[string]interface{}, error) {
+		return pluginBackends(ctx, pluginRegistry, "rate-limit")
+	}), health.CheckOptions{Timeout: time.Second})
+
+	h.Register("router", health.CheckerFunc(func(ctx context.Context) (map[string]interface{}, error) {
+		return rt.Stats(), nil
+	}), health.CheckOptions{})
+
+	h.Register("plugins", health.CheckerFunc(func(ctx context.Context) (map[string]interface{}, error) {
+		if pluginRegistry == nil {
+			return nil, errors.New("plugin system failed to initialize")
+		}
+		return pluginRegistry.Stats(), nil
+	}), health.CheckOptions{})
+
+	h.Register("upstreams", health.CheckerFunc(func(ctx context.Context) (map[string]interface{}, error) {
+		unavailable := balancers.Unavailable()
+		details := map[string]interface{}{
+			"balanced_services": balancers.Stats()["balanced_services"],
+			"unavailable":       unavailable,
+		}
+		if len(unavailable) > 0 {
+			return details, fmt.Errorf("%d service(s) have every target ejected or down", len(unavailable))
+		}
+		return details, nil
+	}), health.CheckOptions{})
+
+	if meter != nil {
+		h.Register("metering", health.CheckerFunc(func(ctx context.Context) (map[string]interface{}, error) {
+			spooled, err := meter.Status()
+			details := map[string]interface{}{
+				"sink":            meter.SinkName(),
+				"spooled_batches": spooled,
+			}
+			if err != nil {
+				return details, fmt.Errorf("sink unavailable: %w", err)
+			}
+			return details, nil
+		}), health.CheckOptions{})
+	}
+
+	log.Info().
+		Str("component", "health").
+		Strs("checks", h.Checks()).
+		Msg("Health checks registered")
+}
+
+// pluginBackends checks the backends of every loaded instance of the named
+// plugin that implements plugin.HealthChecker.
+func pluginBackends(ctx context.Context, registry *plugin.Registry, name string) (map[string]interface{}, error) {
+	if registry == nil {
+		return nil, nil
+	}
+
+	checked := 0
+	failing := []string{}
+	var firstErr error
+	for _, instance := range registry.GetInstances() {
+		checker, ok := instance.Plugin.(plugin.HealthChecker)
+		if !ok || instance.Plugin.Name() != name {
+			continue
+		}
+		checked++
+		if err := checker.CheckHealth(ctx); err != nil {
+			failing = append(failing, instance.Config.ID)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	details := map[string]interface{}{
+		"instances": checked,
+		"failing":   failing,
+	}
+	if firstErr != nil {
+		return details, fmt.Errorf("%d of %d instance(s) unreachable: %w", len(failing), checked, firstErr)
+	}
+	return details, nil
+}
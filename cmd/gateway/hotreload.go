@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/config"
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/gateway"
+)
+
+// watchConfigChanges runs hot reload until ctx is cancelled.
+//
+// Config change events come from the admin API over Redis pub/sub. When
+// Redis is unavailable (redisClient is nil) or the subscription stops, the
+// gateway falls back to Postgres LISTEN/NOTIFY, fed by the
+// notify_config_change trigger.
+func watchConfigChanges(ctx context.Context, redisClient *redis.Client, db *database.DB, gw *gateway.Gateway) {
+	if redisClient != nil {
+		watcher := config.NewWatcher(redisClient, gw)
+		err := watcher.Start(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Error().
+			Err(err).
+			Str("component", "watcher").
+			Msg("Config watcher stopped - falling back to Postgres LISTEN/NOTIFY")
+	}
+
+	err := db.Listen(ctx, database.ConfigChangesChannel, func(payload string) {
+		// An empty payload follows a reconnect: changes may have been
+		// missed, so reload everything (service reloads include balancers)
+		event := config.ConfigChangeEvent{EventType: "config_change", EntityType: "service", Action: "resync"}
+		if payload != "" {
+			if err := json.Unmarshal([]byte(payload), &event); err != nil {
+				log.Warn().
+					Err(err).
+					Str("component", "watcher").
+					Msg("Ignoring malformed config change notification")
+				return
+			}
+		}
+
+		if err := gw.HandleConfigChange(event); err != nil {
+			log.Error().
+				Err(err).
+				Str("component", "watcher").
+				Str("entity_type", event.EntityType).
+				Str("action", event.Action).
+				Msg("Failed to apply config change")
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Error().
+			Err(err).
+			Str("component", "watcher").
+			Msg("Postgres config listener stopped - hot reload disabled")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/importer"
+)
+
+// runImport implements `gateway import`: convert a Kong declarative
+// configuration or an nginx configuration into a topology, printed for
+// review or applied directly.
+//
+// Usage:
+//
+//	gateway import -from kong.yml [-format kong|nginx] [-workspace default] [-o topology.json]
+//	gateway import -from nginx.conf -apply [-prune]
+//
+// The written topology can be edited and applied with
+// `gateway promote -from topology.json`. Whatever could not be converted
+// is listed on stderr.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	from := fs.String("from", "", "Kong declarative config (YAML/JSON) or nginx config to convert (required)")
+	format := fs.String("format", "", "Input format: kong or nginx (default: detected)")
+	workspace := fs.String("workspace", database.DefaultWorkspace, "Workspace of the converted configuration")
+	output := fs.String("o", "", "Write the topology to this file instead of stdout")
+	apply := fs.Bool("apply", false, "Apply the converted topology to the database")
+	prune := fs.Bool("prune", false, "With -apply, delete services, route groups, routes and plugins missing from the import")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" {
+		fs.Usage()
+		return fmt.Errorf("-from is required")
+	}
+
+	data, err := os.ReadFile(*from)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", *from, err)
+	}
+	if *format == "" {
+		if *format, err = importer.DetectFormat(*from, data); err != nil {
+			return err
+		}
+	}
+
+	result, err := importer.Convert(*format, data, *workspace)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *from, err)
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	topology := result.Topology
+	topology.SetDefaults()
+
+	if !*apply {
+		out, err := json.MarshalIndent(topology, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode topology: %w", err)
+		}
+		out = append(out, '\n')
+		if *output == "" {
+			os.Stdout.Write(out)
+		} else if err := os.WriteFile(*output, out, 0o644); err != nil {
+			return fmt.Errorf("failed to write topology: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Converted %d services, %d routes and %d plugins from %s (%d warnings)\n",
+			len(topology.Services), len(topology.Routes), len(topology.Plugins), *from, len(result.Warnings))
+		return nil
+	}
+
+	db, err := openConfigDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	applied, err := database.NewRepository(db).ApplyTopology(ctx, topology, *prune)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %s to workspace %s: %d created, %d updated, %d deleted (%d warnings)\n",
+		*from, topology.Workspace, applied.Created, applied.Updated, applied.Deleted, len(result.Warnings))
+
+	announceResync(topology.Workspace)
+	return nil
+}
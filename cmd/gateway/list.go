@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+)
+
+// tagFlags collects -tag flags; each may hold several comma-separated tags.
+type tagFlags []string
+
+func (t *tagFlags) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *tagFlags) Set(value string) error {
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			*t = append(*t, tag)
+		}
+	}
+	return nil
+}
+
+// runList implements `gateway routes list` and `gateway services list`:
+// print the configured routes or services, optionally filtered by
+// workspace and tags.
+//
+// Usage:
+//
+//	gateway routes list [-tag team-payments] [-workspace <name>] [-json]
+//	gateway services list [-tag team-payments,tier-1] [-workspace <name>] [-json]
+//
+// Entities must have every -tag given. Disabled entities are listed too.
+// Enabled routes the gateway doesn't serve because their service is
+// disabled or missing are listed with a warning on stderr.
+func runList(entity string, args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return fmt.Errorf("usage: gateway %s list [-tag <tag>] [-workspace <name>] [-json]", entity)
+	}
+
+	fs := flag.NewFlagSet(entity+" list", flag.ContinueOnError)
+	var tags tagFlags
+	fs.Var(&tags, "tag", "Only list entities with this tag (repeatable, or comma-separated)")
+	workspace := fs.String("workspace", "", "Only list entities of this workspace (default: all)")
+	asJSON := fs.Bool("json", false, "Print JSON instead of a table")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	db, err := openConfigDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	repo := database.NewRepository(db)
+	services, err := repo.GetServices(ctx, true)
+	if err != nil {
+		return err
+	}
+	routes, err := repo.GetRoutes(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	inWorkspace := func(name string) bool {
+		return *workspace == "" || database.WorkspaceName(name) == database.WorkspaceName(*workspace)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if entity == "services" {
+		var matched []*database.Service
+		for _, svc := range services {
+			if inWorkspace(svc.Workspace) && database.HasTags(svc.Tags, tags) {
+				matched = append(matched, svc)
+			}
+		}
+		defer warnOrphans(routes, services, func(o router.Orphan) bool {
+			for _, svc := range matched {
+				if svc.ID == o.ServiceID {
+					return true
+				}
+			}
+			return false
+		})
+		if *asJSON {
+			return printJSON(matched)
+		}
+
+		fmt.Fprintln(w, "NAME\tWORKSPACE\tUPSTREAM\tTAGS\tENABLED\tID")
+		for _, svc := range matched {
+			upstream := svc.Protocol + "://" + svc.Host + ":" + strconv.Itoa(svc.Port)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\n",
+				svc.Name, database.WorkspaceName(svc.Workspace), upstream, strings.Join(svc.Tags, ","), svc.Enabled, svc.ID)
+		}
+		return w.Flush()
+	}
+
+	serviceNames := make(map[string]string, len(services))
+	for _, svc := range services {
+		serviceNames[svc.ID] = svc.Name
+	}
+
+	var matched []*database.Route
+	for _, route := range routes {
+		if inWorkspace(route.Workspace) && database.HasTags(route.Tags, tags) {
+			matched = append(matched, route)
+		}
+	}
+	defer warnOrphans(matched, services, nil)
+	if *asJSON {
+		return printJSON(matched)
+	}
+
+	fmt.Fprintln(w, "NAME\tWORKSPACE\tSERVICE\tPATHS\tTAGS\tENABLED\tID")
+	for _, route := range matched {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%t\t%s\n",
+			route.Name.String, database.WorkspaceName(route.Workspace), serviceNames[route.ServiceID],
+			strings.Join(route.Paths, ","), strings.Join(route.Tags, ","), route.Enabled, route.ID)
+	}
+	return w.Flush()
+}
+
+// warnOrphans prints a warning on stderr for every enabled route orphaned
+// by a disabled or missing service, optionally filtered by keep.
+func warnOrphans(routes []*database.Route, services []*database.Service, keep func(router.Orphan) bool) {
+	_, orphans := router.FindOrphans(routes, services)
+	for _, orphan := range orphans {
+		if keep == nil || keep(orphan) {
+			fmt.Fprintln(os.Stderr, "warning: "+orphan.String())
+		}
+	}
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	fmt.Println(string(out))
+	return nil
+}
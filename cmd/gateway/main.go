@@ -10,10 +10,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
 	"syscall"
 	"time"
 
@@ -21,15 +23,43 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
 
+	"github.com/saidutt46/switchboard-gateway/internal/admin"
+	"github.com/saidutt46/switchboard-gateway/internal/admission"
+	"github.com/saidutt46/switchboard-gateway/internal/anomaly"
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/clientip"
+	"github.com/saidutt46/switchboard-gateway/internal/cluster"
 	"github.com/saidutt46/switchboard-gateway/internal/config"
 	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/deadline"
+	"github.com/saidutt46/switchboard-gateway/internal/dnscache"
+	"github.com/saidutt46/switchboard-gateway/internal/egress"
+	"github.com/saidutt46/switchboard-gateway/internal/featureflag"
 	"github.com/saidutt46/switchboard-gateway/internal/gateway"
+	"github.com/saidutt46/switchboard-gateway/internal/headerlimit"
 	"github.com/saidutt46/switchboard-gateway/internal/health"
+	"github.com/saidutt46/switchboard-gateway/internal/keycache"
+	"github.com/saidutt46/switchboard-gateway/internal/loadbalancer"
 	"github.com/saidutt46/switchboard-gateway/internal/logging"
+	"github.com/saidutt46/switchboard-gateway/internal/metering"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/notify"
+	"github.com/saidutt46/switchboard-gateway/internal/pathnorm"
 	"github.com/saidutt46/switchboard-gateway/internal/plugin"
 	"github.com/saidutt46/switchboard-gateway/internal/plugin/builtin"
 	"github.com/saidutt46/switchboard-gateway/internal/proxy"
+	"github.com/saidutt46/switchboard-gateway/internal/ratelimit"
+	"github.com/saidutt46/switchboard-gateway/internal/recording"
+	"github.com/saidutt46/switchboard-gateway/internal/recovery"
+	"github.com/saidutt46/switchboard-gateway/internal/redirect"
+	"github.com/saidutt46/switchboard-gateway/internal/respcache"
 	"github.com/saidutt46/switchboard-gateway/internal/router"
+	"github.com/saidutt46/switchboard-gateway/internal/signedurl"
+	"github.com/saidutt46/switchboard-gateway/internal/slo"
+	"github.com/saidutt46/switchboard-gateway/internal/streamproxy"
+	"github.com/saidutt46/switchboard-gateway/internal/tenant"
+	"github.com/saidutt46/switchboard-gateway/internal/tokenmint"
+	"github.com/saidutt46/switchboard-gateway/internal/usage"
 )
 
 // Version information (set during build via ldflags)
@@ -40,6 +70,58 @@ var (
 )
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplay(os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Replay failed")
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "encrypt-config" {
+		if err := runEncryptConfig(os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Encrypt config failed")
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Export failed")
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "promote" {
+		if err := runPromote(os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Promote failed")
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Import failed")
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && (os.Args[1] == "routes" || os.Args[1] == "services") {
+		if err := runList(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("List failed")
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && (os.Args[1] == "--check" || os.Args[1] == "-check") {
+		if err := runCheck(os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Msg("Self-test failed")
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Run the application and exit with appropriate code
 	if err := run(); err != nil {
 		log.Fatal().Err(err).Msg("Application failed to start")
@@ -93,23 +175,126 @@ func run() error {
 	// Create repository
 	repo := database.NewRepository(db)
 
+	// Encryption at rest (plugin config secrets, API key pepper)
+	cipher, pepper, err := loadEncryption(cfg.Encryption)
+	if err != nil {
+		return err
+	}
+	repo.SetCipher(cipher)
+	repo.SetAPIKeyPepper(pepper)
+	if cipher != nil {
+		log.Info().
+			Str("component", "database").
+			Strs("fields", cfg.Encryption.Fields).
+			Msg("Encryption at rest enabled for plugin config fields")
+	}
+
 	log.Info().
 		Str("component", "database").
 		Msg("Database connection established successfully")
 
+	// Client IP resolution (forwarded headers only from trusted proxies)
+	clientResolver, err := clientip.NewResolver(cfg.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	// Memory budget for bodies buffered by plugins
+	bodyBuffers, err := bodybuffer.NewManager(bodybuffer.Config{
+		MaxMemory:      cfg.BodyBuffer.MaxMemoryBytes,
+		SpillThreshold: cfg.BodyBuffer.SpillThresholdBytes,
+		SpillDir:       cfg.BodyBuffer.SpillDir,
+	})
+	if err != nil {
+		return fmt.Errorf("invalid BODY_BUFFER_SPILL_DIR: %w", err)
+	}
+
+	// Webhook notifications for gateway events (nil = disabled)
+	notifier := newNotifier(cfg.Notify)
+
 	// Load initial configuration from database
 	routes, err := repo.GetRoutes(context.Background(), false)
 	if err != nil {
 		return fmt.Errorf("failed to load routes: %w", err)
 	}
 
-	services, err := repo.GetServices(context.Background(), false)
+	// Disabled services too, so their routes are reported as orphaned
+	// rather than as referencing a missing service
+	services, err := repo.GetServices(context.Background(), true)
 	if err != nil {
 		return fmt.Errorf("failed to load services: %w", err)
 	}
 
+	// Background writer for sampled requests (request-recorder plugin)
+	recorder := recording.NewRecorder(repo, 1000)
+	defer recorder.Close()
+
+	// Billing records for metered routes (nil = metering disabled)
+	meter, err := newMeter(cfg.Metering)
+	if err != nil {
+		return fmt.Errorf("failed to initialize metering: %w", err)
+	}
+
+	// API key lookups for auth plugins, optionally cached and pre-warmed
+	// so a fresh instance doesn't send every first request to Postgres
+	var apiKeys builtin.APIKeyLookup = repo
+	if cfg.APIKeyCache.Enabled {
+		keyCache := keycache.New(repo, keycache.Config{
+			Size:        cfg.APIKeyCache.Size,
+			TTL:         cfg.APIKeyCache.TTL,
+			NegativeTTL: cfg.APIKeyCache.NegativeTTL,
+		})
+
+		warmCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := keyCache.Warm(warmCtx); err != nil {
+			log.Warn().
+				Err(err).
+				Str("component", "keycache").
+				Msg("API key cache warm-up failed - keys will be loaded on first use")
+		} else {
+			log.Info().
+				Str("component", "keycache").
+				Int("keys", keyCache.Len()).
+				Msg("API key cache warmed")
+		}
+		cancel()
+
+		if cfg.APIKeyCache.RefreshInterval > 0 {
+			go keyCache.Run(context.Background(), cfg.APIKeyCache.RefreshInterval)
+		}
+		apiKeys = keyCache
+	}
+
+	// Signing keys for gateway-issued upstream tokens (nil = token-exchange disabled)
+	var tokenSigner *tokenmint.Signer
+	if len(cfg.TokenMint.KeyFiles) > 0 {
+		tokenSigner, err = tokenmint.LoadSigner(cfg.TokenMint.KeyFiles)
+		if err != nil {
+			return err
+		}
+		go tokenSigner.Run(context.Background(), cfg.TokenMint.ReloadInterval)
+	}
+
+	// Secrets for signed URLs (nil = signed-url disabled)
+	var urlSigner *signedurl.Signer
+	if len(cfg.SignedURL.Secrets) > 0 {
+		urlSigner, err = signedurl.NewSigner(cfg.SignedURL.Secrets, cfg.SignedURL.MaxTTL)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Responses stored by the cache plugin (nil = cache plugin disabled)
+	responseCache := newResponseCache(cfg)
+
+	// Conflict detection on plugin context metadata (development)
+	plugin.SetMetadataDebug(cfg.PluginMetadataDebug)
+
+	// Workers for async AfterResponse plugins (nil = async plugins disabled)
+	asyncPool := plugin.NewAsyncPool(cfg.PluginAsync.Workers, cfg.PluginAsync.QueueSize)
+
 	// Initialize plugin system
-	pluginRegistry, pluginInstances, err := initializePlugins(context.Background(), repo)
+	pluginRegistry, pluginInstances, err := initializePlugins(context.Background(), cfg, repo, apiKeys, recorder, notifier, meter, tokenSigner, urlSigner, responseCache, newFlagProvider(cfg.FeatureFlags), asyncPool)
 	if err != nil {
 		log.Warn().
 			Err(err).
@@ -120,6 +305,8 @@ func run() error {
 
 	// Create router with radix tree and plugins
 	rt := router.NewRouter(routes, services, pluginInstances)
+	rt.SetAutoOptions(cfg.AutoOptions)
+	rt.SetDefaultService(cfg.DefaultService)
 
 	// Log router statistics
 	stats := rt.Stats()
@@ -132,25 +319,103 @@ func run() error {
 		Msg("Router initialized with radix tree and plugins")
 
 	// Create reverse proxy with HTTP transport configuration
-	transportConfig := &proxy.TransportConfig{
+	transportConfig := proxy.TransportConfig{
 		// Connection pool settings
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 10,
-		MaxConnsPerHost:     100,
+		MaxIdleConns:        cfg.Upstream.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.Upstream.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.Upstream.MaxConnsPerHost,
 
 		// Timeouts
-		DialTimeout:           30 * time.Second,
-		KeepAlive:             30 * time.Second,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ResponseHeaderTimeout: 30 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
+		DialTimeout:           cfg.Upstream.DialTimeout,
+		KeepAlive:             cfg.Upstream.KeepAlive,
+		IdleConnTimeout:       cfg.Upstream.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.Upstream.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.Upstream.ResponseHeaderTimeout,
+		ExpectContinueTimeout: cfg.Upstream.ExpectContinueTimeout,
 
 		// TLS
-		InsecureSkipVerify: false, // Verify TLS certificates in production
+		InsecureSkipVerify: cfg.Upstream.InsecureSkipVerify,
+
+		// Dialing
+		IPFamily:           cfg.Upstream.IPFamily,
+		HappyEyeballsDelay: cfg.Upstream.HappyEyeballsDelay,
+	}
+	if cfg.Egress.Enabled {
+		policy, err := egress.NewPolicy(egress.Config{
+			AllowHosts: cfg.Egress.AllowHosts,
+			AllowCIDRs: cfg.Egress.AllowCIDRs,
+			DenyCIDRs:  cfg.Egress.DenyCIDRs,
+		})
+		if err != nil {
+			return fmt.Errorf("invalid egress policy: %w", err)
+		}
+		transportConfig.Egress = policy
+	}
+	if cfg.DNSCache.Enabled {
+		dnsConfig := dnscache.DefaultConfig()
+		dnsConfig.MinTTL = cfg.DNSCache.MinTTL
+		dnsConfig.MaxTTL = cfg.DNSCache.MaxTTL
+		dnsConfig.NegativeTTL = cfg.DNSCache.NegativeTTL
+		resolver, err := dnscache.New(dnsConfig)
+		if err != nil {
+			return fmt.Errorf("failed to initialize DNS cache: %w", err)
+		}
+		transportConfig.DNSCache = resolver
+	}
+
+	// Build per-service load balancers from service targets, with outlier
+	// detection ejecting misbehaving targets
+	var outlierDetector *loadbalancer.OutlierDetector
+	if cfg.OutlierDetection.Enabled {
+		outlierDetector = loadbalancer.NewOutlierDetector(loadbalancer.OutlierConfig{
+			Enabled:            true,
+			ErrorRateThreshold: cfg.OutlierDetection.ErrorRateThreshold,
+			LatencyFactor:      cfg.OutlierDetection.LatencyFactor,
+			MinLatency:         cfg.OutlierDetection.MinLatency,
+			MinRequests:        cfg.OutlierDetection.MinRequests,
+			Decay:              cfg.OutlierDetection.Decay,
+			BaseEjectionTime:   cfg.OutlierDetection.BaseEjectionTime,
+			MaxEjectionTime:    cfg.OutlierDetection.MaxEjectionTime,
+			MaxEjectionPercent: cfg.OutlierDetection.MaxEjectionPercent,
+		})
+	}
+
+	if outlierDetector != nil {
+		outlierDetector.SetNotifier(notifier)
+	}
+	balancers := loadbalancer.NewManager(outlierDetector)
+	balancers.SetRegion(cfg.Region)
+
+	// Probe the targets of services with a health_check
+	healthChecker := loadbalancer.NewHealthChecker()
+	healthChecker.SetNotifier(notifier)
+	balancers.SetHealthChecker(healthChecker)
+	if err := balancers.Reload(context.Background(), repo); err != nil {
+		return fmt.Errorf("failed to initialize load balancers: %w", err)
+	}
+
+	px := proxy.NewProxy(rt, proxy.NewTransport(&transportConfig), balancers)
+	px.SetHeaderConfig(proxy.HeaderConfig{
+		Via:            cfg.ProxyHeaders.Via,
+		Forwarded:      cfg.ProxyHeaders.Forwarded,
+		TrustedProxies: clientResolver,
+	})
+	px.SetSlowUpstreamConfig(proxy.SlowUpstreamConfig{
+		DialThreshold: cfg.UpstreamSlowDialThreshold,
+		TTFBThreshold: cfg.UpstreamSlowTTFBThreshold,
+	})
+	timingIPs, err := clientip.NewResolver(cfg.Debug.TimingTrustedIPs)
+	if err != nil {
+		return fmt.Errorf("invalid DEBUG_TIMING_TRUSTED_IPS: %w", err)
 	}
+	px.SetTimingConfig(proxy.TimingConfig{TrustedIPs: timingIPs})
+	px.SetRetryBudget(proxy.RetryBudgetConfig{
+		Ratio:        cfg.HedgeBudget.Ratio,
+		MinPerSecond: cfg.HedgeBudget.MinPerSecond,
+	})
 
-	px := proxy.NewProxy(rt, proxy.NewTransport(transportConfig))
+	// Close connections of targets removed on reload once they're idle
+	balancers.SetDrainer(px, cfg.UpstreamDrainTimeout)
 
 	log.Info().
 		Str("component", "proxy").
@@ -174,43 +439,242 @@ func run() error {
 		Int("count", len(plugins)).
 		Msg("Plugins loaded from database")
 
-	// Initialize Redis for hot reload
+	// Create gateway instance for config changes (with plugin registry for hot reload)
+	gw := gateway.New(rt, repo, pluginRegistry, balancers)
+	gw.SetNotifier(notifier)
+
+	// Load redirect rules (reloaded with the rest of the configuration)
+	redirects := redirect.NewEngine()
+	if err := redirects.Reload(context.Background(), repo); err != nil {
+		log.Error().
+			Err(err).
+			Str("component", "redirect").
+			Msg("Failed to load redirect rules - starting without redirects")
+	}
+	gw.SetRedirects(redirects)
+
+	// Load the tenant registry (reloaded with the rest of the configuration)
+	tenants := tenant.NewRegistry(tenant.Config{
+		Source:     cfg.Tenant.Source,
+		Header:     cfg.Tenant.Header,
+		BaseDomain: cfg.Tenant.BaseDomain,
+	})
+	if err := tenants.Reload(context.Background(), repo); err != nil {
+		log.Error().
+			Err(err).
+			Str("component", "tenant").
+			Msg("Failed to load tenant registry - per-tenant services are unavailable")
+	}
+	gw.SetTenants(tenants)
+
+	// Open the stream (TCP/UDP) listeners (reloaded with the rest of the configuration)
+	streams := streamproxy.NewServer(balancers)
+	if err := streams.Reload(context.Background(), repo); err != nil {
+		log.Error().
+			Err(err).
+			Str("component", "streamproxy").
+			Msg("Failed to load stream listeners - starting without stream proxying")
+	}
+	gw.SetStreams(streams)
+
+	// Apply runtime transport settings (rebuilt whenever they change)
+	gw.SetProxy(px, transportConfig)
+	if err := gw.ApplySettings(context.Background()); err != nil {
+		log.Error().
+			Err(err).
+			Str("component", "proxy").
+			Msg("Failed to apply gateway settings - using environment transport settings")
+	}
+
+	// Initialize Redis for hot reload (Postgres LISTEN/NOTIFY is the fallback)
 	redisClient, err := initializeRedis(cfg)
 	if err != nil {
 		log.Warn().
 			Err(err).
-			Msg("Redis setup failed - hot reload disabled")
-	} else {
-		// Create gateway instance for config changes (with plugin registry for hot reload)
-		gw := gateway.New(rt, repo, pluginRegistry)
+			Msg("Redis setup failed - hot reload falls back to Postgres LISTEN/NOTIFY")
+		redisClient = nil
+	}
 
-		// Start config watcher in background
-		watcher := config.NewWatcher(redisClient, gw)
-		go func() {
-			if err := watcher.Start(context.Background()); err != nil {
-				log.Error().
-					Err(err).
-					Str("component", "watcher").
-					Msg("Config watcher stopped")
-			}
-		}()
+	// Purges published by other instances reach the cache through the watcher
+	if responseCache != nil {
+		gw.SetCache(responseCache)
+	}
+
+	// Start config watcher in background
+	go watchConfigChanges(context.Background(), redisClient, db, gw)
+
+	log.Info().
+		Str("component", "hot_reload").
+		Bool("redis", redisClient != nil).
+		Msg("Config watcher started - hot reload enabled 🔥")
+
+	// Setup HTTP server
+	// Priority admission control in front of the proxy (disabled when max is 0)
+	admissionController := admission.NewController(admission.Config{
+		MaxConcurrent: cfg.Admission.MaxConcurrent,
+		MaxQueue:      cfg.Admission.MaxQueue,
+		QueueTimeout:  cfg.Admission.QueueTimeout,
+	})
+
+	adminHandler := admin.NewHandler(admin.Config{
+		Token:   cfg.AdminToken,
+		Version: Version,
+		Debug:   cfg.Debug.Enabled,
+	}, repo, rt)
+	if cfg.Debug.Enabled {
+		runtime.SetBlockProfileRate(cfg.Debug.BlockProfileRate)
+		runtime.SetMutexProfileFraction(cfg.Debug.MutexProfileFraction)
 
 		log.Info().
-			Str("component", "hot_reload").
-			Msg("Config watcher started - hot reload enabled 🔥")
+			Int("block_profile_rate", cfg.Debug.BlockProfileRate).
+			Int("mutex_profile_fraction", cfg.Debug.MutexProfileFraction).
+			Msg("Debug endpoints enabled under /admin/debug/")
 	}
 
-	// Setup HTTP server
-	mux := setupRoutes(db, repo, rt, px)
+	// Recent errors and 429s for the read-only /admin/ui dashboard
+	activity := admin.NewActivity(50)
 
-	server := &http.Server{
-		Addr:         cfg.ServerAddress(),
-		Handler:      mux,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	// Per-route SLO tracking (routes without objectives are not tracked)
+	var sloNotifier slo.Notifier
+	if cfg.SLO.WebhookURL != "" {
+		sloNotifier = slo.NewWebhookNotifier(cfg.SLO.WebhookURL)
+	}
+	var sloEvents slo.Notifier
+	if notifier != nil {
+		sloEvents = sloEventNotifier{dispatcher: notifier}
+	}
+	sloTracker := slo.NewTracker(sloNotifier, sloEvents)
+	if cfg.SLO.EvaluationInterval > 0 {
+		go sloTracker.Run(context.Background(), cfg.SLO.EvaluationInterval)
+	}
+
+	// Per-consumer usage rollups (nil when disabled; Record is a no-op)
+	var usageAggregator *usage.Aggregator
+	if cfg.Usage.FlushInterval > 0 {
+		usageAggregator = usage.NewAggregator(repo)
+		usageAggregator.RetentionDays = cfg.Usage.RetentionDays
+		go usageAggregator.Run(context.Background(), cfg.Usage.FlushInterval)
+
+		log.Info().
+			Dur("flush_interval", cfg.Usage.FlushInterval).
+			Int("retention_days", cfg.Usage.RetentionDays).
+			Msg("Usage aggregation enabled")
 	}
 
+	// Traffic anomaly detection (nil when disabled; Record is a no-op)
+	var anomalies *anomaly.Detector
+	if cfg.Anomaly.Enabled {
+		anomalies = anomaly.NewDetector(anomaly.Config{
+			Decay:         cfg.Anomaly.Decay,
+			SpikeFactor:   cfg.Anomaly.SpikeFactor,
+			MinRequests:   cfg.Anomaly.MinRequests,
+			WarmupWindows: cfg.Anomaly.WarmupWindows,
+			ScanPaths:     cfg.Anomaly.ScanPaths,
+			Cooldown:      cfg.Anomaly.Cooldown,
+			MaxSources:    cfg.Anomaly.MaxSources,
+		})
+		anomalies.SetNotifier(notifier)
+		go anomalies.Run(context.Background(), cfg.Anomaly.Window)
+	}
+
+	// Rate limit keyspace reporting and idle key expiry (nil when disabled)
+	var keyspaceMonitor *ratelimit.KeyspaceMonitor
+	if cfg.RateLimitKeys.Interval > 0 {
+		redisConfig := ratelimit.DefaultRedisConfig()
+		redisConfig.URL = cfg.RateLimitKeys.RedisURL
+		redisConfig.PoolSize = 2
+		redisConfig.MinIdleConns = 0
+		keyStore, err := ratelimit.NewRedisStore(redisConfig)
+		if err != nil {
+			log.Warn().Err(err).Msg("Rate limit keyspace maintenance disabled: Redis unavailable")
+		} else {
+			keyspaceMonitor = ratelimit.NewKeyspaceMonitor(keyStore, ratelimit.KeyspaceConfig{
+				Prefix:        cfg.RateLimitKeys.Prefix,
+				MaxKeys:       cfg.RateLimitKeys.MaxScan,
+				MemorySamples: cfg.RateLimitKeys.MemorySamples,
+				ExpireIdle:    cfg.RateLimitKeys.ExpireIdle,
+				ExpireTypes:   cfg.RateLimitKeys.ExpireTypes,
+			})
+			go keyspaceMonitor.Run(context.Background(), cfg.RateLimitKeys.Interval)
+
+			log.Info().
+				Dur("interval", cfg.RateLimitKeys.Interval).
+				Dur("expire_idle", cfg.RateLimitKeys.ExpireIdle).
+				Strs("expire_types", cfg.RateLimitKeys.ExpireTypes).
+				Msg("Rate limit keyspace maintenance enabled")
+		}
+	}
+
+	adminHandler.SetDashboard(balancers, keyspaceMonitor, activity)
+	adminHandler.SetURLSigner(urlSigner)
+	if responseCache != nil {
+		adminHandler.SetCachePurger(respcache.NewPurger(responseCache, redisClient))
+	}
+
+	// Peer heartbeats for GET /admin/cluster (nil when disabled)
+	var membership *cluster.Membership
+	if cfg.Cluster.Enabled {
+		if redisClient == nil {
+			log.Warn().Msg("Cluster membership disabled: Redis unavailable")
+		} else {
+			membership = cluster.NewMembership(redisClient, cluster.Config{
+				Key:     cfg.Cluster.Key,
+				PeerTTL: cfg.Cluster.PeerTTL,
+			}, Version, cfg.Region, rt.Generation)
+			go membership.Run(context.Background(), cfg.Cluster.HeartbeatInterval)
+			adminHandler.SetCluster(membership)
+
+			log.Info().
+				Str("component", "cluster").
+				Str("member", membership.ID()).
+				Dur("heartbeat_interval", cfg.Cluster.HeartbeatInterval).
+				Msg("Cluster membership enabled")
+		}
+	}
+
+	decisionLog := plugin.DecisionLogConfig{
+		Log:    cfg.DecisionLog.Enabled,
+		Header: cfg.DecisionLog.Header,
+	}
+
+	// Readiness fails once draining starts (pre-stop hook or SIGTERM)
+	healthHandler := health.NewHandler(db, repo)
+	registerHealthChecks(healthHandler, redisClient, pluginRegistry, rt, balancers, meter)
+
+	mux := setupRoutes(healthHandler, rt, px, redirects, tenants, admissionController, activity, sloTracker, usageAggregator, keyspaceMonitor, clientResolver, bodyBuffers, decisionLog, newTagMetrics(cfg.MetricsMaxTags), tokenSigner, anomalies)
+
+	// Canonicalize request paths before anything routes on them
+	handler := pathnorm.Handler(pathnorm.Config{
+		EncodedSlashes: cfg.PathNormalization.EncodedSlashes,
+		DotSegments:    cfg.PathNormalization.DotSegments,
+		MergeSlashes:   cfg.PathNormalization.MergeSlashes,
+	}, mux)
+
+	// Enforce header limits before anything else looks at the request
+	handler = headerlimit.Handler(headerlimit.Config{
+		MaxBytes:      cfg.HeaderLimits.MaxBytes,
+		MaxCount:      cfg.HeaderLimits.MaxCount,
+		MaxValueBytes: cfg.HeaderLimits.MaxValueBytes,
+		Oversized:     cfg.HeaderLimits.Oversized,
+		SingleValued:  cfg.HeaderLimits.SingleValued,
+		Duplicates:    cfg.HeaderLimits.Duplicates,
+	}, handler)
+
+	// Turn panics anywhere in the request path into 500s
+	handler = recovery.Handler(handler)
+
+	// Experimental HTTP/3 over QUIC, advertised on TCP responses
+	h3Server := newHTTP3Server(cfg, handler)
+	server := newServer(cfg, withAltSvc(h3Server, handler))
+
+	// Admin endpoints (specs, diagnostics, drain) on their own listener
+	adminServer := newAdminServer(cfg, recovery.Handler(adminHandler))
+
+	// /admin/drain lets a Kubernetes preStop hook or systemd ExecStop start
+	// the drain before the shutdown signal arrives
+	drainer := newDrainer(healthHandler, server, notifier, cfg.ShutdownDrainDelay)
+	adminHandler.SetDrainer(drainer)
+
 	// Channel to listen for errors from the server
 	serverErrors := make(chan error, 1)
 
@@ -218,11 +682,36 @@ func run() error {
 	go func() {
 		log.Info().
 			Str("address", cfg.ServerAddress()).
+			Bool("tls", cfg.TLSEnabled()).
 			Msg("HTTP server starting")
 
-		serverErrors <- server.ListenAndServe()
+		serverErrors <- listenAndServe(cfg, server)
 	}()
 
+	if h3Server != nil {
+		go func() {
+			log.Info().
+				Str("address", h3Server.Addr).
+				Msg("HTTP/3 server starting (UDP)")
+
+			if err := listenAndServeHTTP3(cfg, h3Server); err != http.ErrServerClosed {
+				serverErrors <- fmt.Errorf("HTTP/3 listener: %w", err)
+			}
+		}()
+	}
+
+	if adminServer != nil {
+		go func() {
+			log.Info().
+				Str("address", adminServer.Addr).
+				Msg("Admin server starting")
+
+			if err := adminServer.ListenAndServe(); err != http.ErrServerClosed {
+				serverErrors <- fmt.Errorf("admin listener: %w", err)
+			}
+		}()
+	}
+
 	// Channel to listen for interrupt signals
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
@@ -237,16 +726,8 @@ func run() error {
 			Str("signal", sig.String()).
 			Msg("Shutdown signal received, starting graceful shutdown...")
 
-		// Create context with timeout for shutdown
-		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
-		defer cancel()
-
-		// Attempt graceful shutdown
-		if err := server.Shutdown(ctx); err != nil {
-			log.Error().Err(err).Msg("Error during graceful shutdown, forcing shutdown")
-			if err := server.Close(); err != nil {
-				return fmt.Errorf("could not stop server gracefully: %w", err)
-			}
+		if err := gracefulShutdown(cfg, server, h3Server, adminServer, streams, drainer, asyncPool, usageAggregator, meter, notifier, membership, redisClient); err != nil {
+			return err
 		}
 
 		log.Info().Msg("Server stopped gracefully")
@@ -257,23 +738,12 @@ func run() error {
 
 // initializePlugins sets up the plugin registry and loads plugins.
 // Returns the registry and loaded plugin instances.
-func initializePlugins(ctx context.Context, repo *database.Repository) (*plugin.Registry, []plugin.PluginInstance, error) {
+func initializePlugins(ctx context.Context, cfg *config.Config, repo *database.Repository, apiKeys builtin.APIKeyLookup, recorder *recording.Recorder, notifier *notify.Dispatcher, meter *metering.Meter, tokenSigner *tokenmint.Signer, urlSigner *signedurl.Signer, cache *respcache.Store, flags featureflag.Provider, asyncPool *plugin.AsyncPool) (*plugin.Registry, []plugin.PluginInstance, error) {
 	log.Info().
 		Str("component", "plugins").
 		Msg("Initializing plugin system")
 
-	// Create plugin registry
-	registry := plugin.NewRegistry()
-
-	// Register built-in plugins
-	registry.Register("request-logger", builtin.NewRequestLogger)
-	registry.Register("cors", builtin.NewCORSPlugin)
-	registry.Register("rate-limit", builtin.NewRateLimitPlugin) // ← ADD THIS LINE
-
-	log.Info().
-		Str("component", "plugins").
-		Interface("registered", registry.GetRegisteredPlugins()).
-		Msg("Built-in plugins registered")
+	registry := newPluginRegistry(cfg, apiKeys, recorder, notifier, meter, tokenSigner, urlSigner, cache, flags, asyncPool)
 
 	// Load plugin configurations from database
 	instances, err := registry.LoadFromDatabase(ctx, repo)
@@ -291,6 +761,68 @@ func initializePlugins(ctx context.Context, repo *database.Repository) (*plugin.
 	return registry, instances, nil
 }
 
+// newPluginRegistry creates a plugin registry with the built-in plugins
+// registered.
+func newPluginRegistry(cfg *config.Config, apiKeys builtin.APIKeyLookup, recorder *recording.Recorder, notifier *notify.Dispatcher, meter *metering.Meter, tokenSigner *tokenmint.Signer, urlSigner *signedurl.Signer, cache *respcache.Store, flags featureflag.Provider, asyncPool *plugin.AsyncPool) *plugin.Registry {
+	registry := plugin.NewRegistry()
+	registry.SetNotifier(notifier)
+	registry.SetFlags(flags)
+	registry.SetAsyncPool(asyncPool)
+
+	// Register built-in plugins
+	registry.Register("request-logger", builtin.NewRequestLogger)
+	registry.Register("cors", builtin.NewCORSPlugin)
+	registry.Register("rate-limit", builtin.NewRateLimitPlugin) // ← ADD THIS LINE
+	registry.Register("idempotency", builtin.NewIdempotencyPlugin)
+	registry.Register("request-recorder", builtin.NewRequestRecorderFactory(recorder))
+	registry.Register("upstream-auth", builtin.NewUpstreamAuthPlugin)
+	registry.Register("paseto-auth", builtin.NewPasetoAuthPlugin)
+	registry.Register("opaque-token-auth", builtin.NewOpaqueTokenAuthFactory(apiKeys))
+	registry.Register("ldap-auth", builtin.NewLDAPAuthPlugin)
+	registry.Register("session-auth", builtin.NewSessionAuthPlugin)
+	registry.Register("auth-summary", builtin.NewAuthSummaryPlugin)
+	registry.Register("token-exchange", builtin.NewTokenExchangeFactory(tokenSigner, cfg.TokenMint.Issuer))
+	registry.Register("signed-url", builtin.NewSignedURLFactory(urlSigner))
+	registry.Register("metering", builtin.NewMeteringFactory(meter))
+	registry.Register("header-limits", builtin.NewHeaderLimitsPlugin)
+	registry.Register("response-validator", builtin.NewResponseValidatorPlugin)
+	registry.Register("response-size-limit", builtin.NewResponseSizeLimitPlugin)
+	registry.Register("grpc-transcode", builtin.NewGRPCTranscodePlugin)
+	registry.Register("xml-transform", builtin.NewXMLTransformPlugin)
+	registry.Register("upstream-timing", builtin.NewUpstreamTimingPlugin)
+	registry.Register("hedging", builtin.NewHedgingPlugin)
+	registry.Register("route-permission", builtin.NewRoutePermissionPlugin)
+	registry.Register("batch-schedule", builtin.NewBatchSchedulePlugin)
+	registry.Register("replay-protection", builtin.NewReplayProtectionPlugin)
+	registry.Register("webhook-verify", builtin.NewWebhookVerifyPlugin)
+	registry.Register("waf", builtin.NewWAFPlugin)
+	registry.Register("json-firewall", builtin.NewJSONFirewallPlugin)
+	registry.Register("static-files", builtin.NewStaticFilesPlugin)
+	registry.Register("request-aggregator", builtin.NewRequestAggregatorPlugin)
+	registry.Register("pagination", builtin.NewPaginationPlugin)
+	registry.Register("etag", builtin.NewETagPlugin)
+	registry.Register("negative-cache", builtin.NewNegativeCachePlugin)
+	registry.Register("cache", builtin.NewCacheFactory(cache))
+	registry.Register("experiments", builtin.NewExperimentsPlugin)
+	registry.Register("fault-injection", builtin.NewFaultInjectionFactory(cfg.FaultInjectionEnabled && !cfg.IsProduction()))
+
+	log.Info().
+		Str("component", "plugins").
+		Interface("registered", registry.GetRegisteredPlugins()).
+		Msg("Built-in plugins registered")
+
+	return registry
+}
+
+// newResponseCache creates the store the cache plugin keeps responses in
+// (nil when CACHE_MAX_BYTES is 0).
+func newResponseCache(cfg *config.Config) *respcache.Store {
+	if cfg.ResponseCache.MaxBytes == 0 {
+		return nil
+	}
+	return respcache.NewStore(cfg.ResponseCache.MaxBytes)
+}
+
 // initializeRedis creates and tests Redis connection for hot reload.
 func initializeRedis(cfg *config.Config) (*redis.Client, error) {
 	log.Debug().
@@ -332,16 +864,26 @@ func initializeRedis(cfg *config.Config) (*redis.Client, error) {
 }
 
 // setupRoutes configures all HTTP routes for the gateway.
-func setupRoutes(db *database.DB, repo *database.Repository, rt *router.Router, px *proxy.Proxy) *http.ServeMux {
+func setupRoutes(healthHandler *health.Handler, rt *router.Router, px *proxy.Proxy, redirects *redirect.Engine, tenants *tenant.Registry, admissionController *admission.Controller, activity *admin.Activity, sloTracker *slo.Tracker, usageAggregator *usage.Aggregator, keyspaceMonitor *ratelimit.KeyspaceMonitor, clientResolver *clientip.Resolver, bodyBuffers *bodybuffer.Manager, decisionLog plugin.DecisionLogConfig, tagCounts *tagMetrics, tokenSigner *tokenmint.Signer, anomalies *anomaly.Detector) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// Health check endpoint
-	healthHandler := health.NewHandler(db, repo)
 	mux.HandleFunc("/health", healthHandler.Health)
 
 	// Ready check endpoint (for Kubernetes)
 	mux.HandleFunc("/ready", healthHandler.Ready)
 
+	// Prometheus metrics endpoint
+	mux.Handle("/metrics", metrics.Handler())
+
+	// Per-route SLO status and route conflicts
+	mux.Handle("/status", statusHandler(sloTracker, rt, keyspaceMonitor))
+
+	// Public keys of gateway-issued upstream tokens
+	if tokenSigner != nil {
+		mux.Handle(tokenmint.JWKSPath, tokenSigner)
+	}
+
 	// Proxy handler - USE THE ROUTER!
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		// Skip health/ready checks
@@ -349,8 +891,22 @@ func setupRoutes(db *database.DB, repo *database.Repository, rt *router.Router,
 			return
 		}
 
-		// Generate request ID
-		requestID := fmt.Sprintf("req_%d", time.Now().UnixNano())
+		start := time.Now()
+
+		// Resolve the client IP once for plugins, balancers and the proxy
+		r = clientResolver.Attach(r)
+		r = bodyBuffers.Attach(r)
+
+		// Request ID assigned by recovery.Handler
+		requestID := logging.RequestIDFromContext(r.Context())
+		if requestID == "" {
+			requestID = logging.NewRequestID()
+		}
+
+		// Redirect rules answer before routing
+		if redirects.Redirect(w, r) {
+			return
+		}
 
 		// Match route using router
 		result, err := rt.Match(r)
@@ -362,24 +918,124 @@ func setupRoutes(db *database.DB, repo *database.Repository, rt *router.Router,
 				Str("method", r.Method).
 				Msg("No route matched")
 
+			var notAllowed *router.MethodNotAllowedError
+			if errors.As(err, &notAllowed) {
+				w.Header().Set("Allow", notAllowed.AllowHeader())
+				if notAllowed.AutoOptions {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+				return
+			}
 			http.Error(w, "Not Found", http.StatusNotFound)
+			anomalies.Record("", clientip.FromRequest(r), r.URL.Path, http.StatusNotFound)
 			return
 		}
 
+		// Logs and metrics are tagged with the route's workspace and the
+		// route's and service's tags
+		workspace := database.WorkspaceName(result.Route.Workspace)
+		tags := database.MergeTags(result.Route.Tags, result.Service.Tags)
+
+		// One deadline for the whole request - admission, plugins and the
+		// upstream call - counted from the match (see internal/deadline)
+		r, cancelDeadline := deadline.Attach(w, r, deadline.Timeout(result.Route, result.Service))
+		defer cancelDeadline()
+
+		// Resolve the tenant (header or subdomain); services templated per
+		// tenant need a registered one
+		var requestTenant *database.Tenant
+		var tenantErr error = tenant.ErrMissing
+		if tenants.Enabled() {
+			requestTenant, tenantErr = tenants.Resolve(r)
+			if requestTenant != nil {
+				r = r.WithContext(tenant.WithTenant(r.Context(), requestTenant))
+			}
+		}
+		if requestTenant == nil && tenant.Templated(result.Service) {
+			status := http.StatusBadRequest
+			body := `{"error":"tenant required","message":"This route requires a tenant"}`
+			if errors.Is(tenantErr, tenant.ErrUnknown) {
+				status = http.StatusNotFound
+				body = `{"error":"unknown tenant","message":"No such tenant"}`
+			}
+
+			log.Info().
+				Err(tenantErr).
+				Str("component", "tenant").
+				Str("request_id", requestID).
+				Str("workspace", workspace).
+				Str("route_id", result.Route.ID).
+				Str("service_id", result.Service.ID).
+				Msg("Request rejected - no tenant for per-tenant service")
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+			recordWorkspaceRequest(workspace, status, time.Since(start))
+			tagCounts.Record(tags, status, time.Since(start))
+			return
+		}
+		tenantID := ""
+		if requestTenant != nil {
+			tenantID = requestTenant.ID
+		}
+
 		// Log successful match
 		log.Info().
 			Str("component", "proxy").
 			Str("request_id", requestID).
 			Str("path", r.URL.Path).
 			Str("method", r.Method).
+			Str("workspace", workspace).
+			Str("tenant", tenantID).
 			Str("route_id", result.Route.ID).
 			Str("route_name", result.Route.Name.String).
 			Str("service_id", result.Service.ID).
 			Str("service_name", result.Service.Name).
+			Strs("tags", tags).
 			Interface("path_params", result.PathParams).
 			Int("plugin_count", result.Chain.Count()).
 			Msg("Route matched successfully")
 
+		// Wait for an admission slot (bulk traffic is queued/shed first)
+		release, err := admissionController.Acquire(r.Context(), result.Route.PriorityClass)
+		if err != nil {
+			status := http.StatusServiceUnavailable
+			if deadline.Exceeded(r.Context()) {
+				status = http.StatusGatewayTimeout
+				log.Warn().
+					Str("component", "admission").
+					Str("request_id", requestID).
+					Str("workspace", workspace).
+					Str("route_id", result.Route.ID).
+					Str("priority_class", result.Route.PriorityClass).
+					Msg("Request deadline exceeded waiting for admission")
+
+				deadline.WriteTimeout(w, result.Route.ID, deadline.StageAdmission)
+			} else {
+				log.Warn().
+					Str("component", "admission").
+					Str("request_id", requestID).
+					Str("workspace", workspace).
+					Str("route_id", result.Route.ID).
+					Str("priority_class", result.Route.PriorityClass).
+					Msg("Request shed by admission control")
+
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(`{"error":"service overloaded","message":"Gateway is at capacity, please retry"}`))
+			}
+			sloTracker.Record(result.Route, time.Since(start), status, r.ContentLength, 0)
+			recordWorkspaceRequest(workspace, status, time.Since(start))
+			tagCounts.Record(tags, status, time.Since(start))
+			activity.Record(r, requestID, result.Route, status, time.Since(start))
+			return
+		}
+		defer release()
+
 		// Create plugin context
 		ctx := plugin.NewContext(
 			r,
@@ -388,14 +1044,32 @@ func setupRoutes(db *database.DB, repo *database.Repository, rt *router.Router,
 			result.Service,
 			plugin.PhaseBeforeRequest,
 		)
+		if tenantID != "" {
+			plugin.KeyTenantID.Set(ctx, tenantID)
+		}
+
+		// Count the request against the route's SLOs and the consumer's
+		// usage once it completes
+		defer func() {
+			sloTracker.Record(result.Route, time.Since(start), ctx.Response.StatusCode(), r.ContentLength, int64(ctx.Response.BodySize()))
+			usageAggregator.Record(plugin.KeyConsumerID.Value(ctx), ctx.Response.StatusCode(), r.ContentLength, int64(ctx.Response.BodySize()), time.Now())
+			anomalies.Record(plugin.KeyConsumerID.Value(ctx), clientip.FromRequest(r), r.URL.Path, ctx.Response.StatusCode())
+			recordWorkspaceRequest(workspace, ctx.Response.StatusCode(), time.Since(start))
+			tagCounts.Record(tags, ctx.Response.StatusCode(), time.Since(start))
+			activity.Record(r, requestID, result.Route, ctx.Response.StatusCode(), time.Since(start))
+			if decisionLog.Log {
+				ctx.LogDecisions(requestID)
+			}
+		}()
 
 		// Execute plugin chain - BEFORE request
 		if err := result.Chain.Execute(ctx); err != nil {
 			log.Error().
 				Err(err).
 				Str("request_id", requestID).
+				Str("workspace", workspace).
 				Msg("Critical plugin failure - aborting request")
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			http.Error(ctx.Response, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
@@ -403,6 +1077,7 @@ func setupRoutes(db *database.DB, repo *database.Repository, rt *router.Router,
 		if ctx.IsAborted() {
 			log.Info().
 				Str("request_id", requestID).
+				Str("workspace", workspace).
 				Int("status_code", ctx.AbortStatusCode()).
 				Str("message", ctx.AbortMessage()).
 				Msg("Request aborted by plugin")
@@ -410,8 +1085,8 @@ func setupRoutes(db *database.DB, repo *database.Repository, rt *router.Router,
 			// Check if response was already written (CORS preflight writes 204)
 			if !ctx.Response.Written() {
 				// Write the error response (e.g., 429 for rate limit)
-				w.WriteHeader(ctx.AbortStatusCode())
-				w.Write([]byte(ctx.AbortMessage()))
+				ctx.Response.WriteHeader(ctx.AbortStatusCode())
+				ctx.Response.Write([]byte(ctx.AbortMessage()))
 			}
 			return
 		}
@@ -419,25 +1094,23 @@ func setupRoutes(db *database.DB, repo *database.Repository, rt *router.Router,
 		// Proxy request to backend service
 		log.Debug().
 			Str("request_id", requestID).
+			Str("workspace", workspace).
 			Str("route", result.Route.Name.String).
 			Str("service", result.Service.Name).
 			Msg("Proxying request to backend")
 
-		// Proxy to backend (use plugin's ResponseWriter to track size)
-		px.ServeHTTP(ctx.Response, r)
-
-		// Execute plugin chain - AFTER response
-		ctx.Phase = plugin.PhaseAfterResponse
-		if err := result.Chain.Execute(ctx); err != nil {
-			log.Warn().
-				Err(err).
-				Str("request_id", requestID).
-				Msg("Plugin error in AfterResponse phase")
-			// Don't fail the request - response already sent
+		if decisionLog.Header {
+			ctx.SetDecisionHeader()
 		}
 
-		// Execute plugin chain - AFTER response (for logging, etc.)
+		// Proxy to backend (use plugin's ResponseWriter to track size, and
+		// ctx.Request so plugin upstream hooks are applied)
+		px.ServeHTTP(ctx.Response, ctx.Request)
+
+		// Execute plugin chain - AFTER response (even if the request ran
+		// out of time)
 		ctx.Phase = plugin.PhaseAfterResponse
+		ctx.DetachDeadline()
 		if err := result.Chain.Execute(ctx); err != nil {
 			log.Warn().
 				Err(err).
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/saidutt46/switchboard-gateway/internal/config"
+	"github.com/saidutt46/switchboard-gateway/internal/metering"
+)
+
+// newMeter creates the billing record meter (nil = metering disabled).
+func newMeter(cfg config.MeteringConfig) (*metering.Meter, error) {
+	var sink metering.Sink
+	switch cfg.Sink {
+	case "webhook":
+		sink = metering.NewWebhookSink(cfg.URL, cfg.Secret)
+	case "kafka-rest":
+		sink = metering.NewKafkaRESTSink(cfg.URL, cfg.Topic)
+	default:
+		return nil, nil
+	}
+
+	return metering.NewMeter(sink, metering.Config{
+		BatchSize:     cfg.BatchSize,
+		FlushInterval: cfg.FlushInterval,
+		MaxRetries:    cfg.MaxRetries,
+		QueueSize:     cfg.QueueSize,
+		SpoolDir:      cfg.SpoolDir,
+		SpoolMaxBytes: cfg.SpoolMaxBytes,
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/saidutt46/switchboard-gateway/internal/config"
+	"github.com/saidutt46/switchboard-gateway/internal/notify"
+	"github.com/saidutt46/switchboard-gateway/internal/slo"
+)
+
+// newNotifier creates the webhook event dispatcher (nil if no webhook
+// URLs are configured).
+func newNotifier(cfg config.NotifyConfig) *notify.Dispatcher {
+	defaults := notify.DefaultConfig()
+
+	return notify.NewDispatcher(notify.Config{
+		URLs:       cfg.WebhookURLs,
+		Format:     cfg.Format,
+		Secret:     cfg.Secret,
+		Events:     cfg.Events,
+		MaxRetries: cfg.MaxRetries,
+		Timeout:    cfg.Timeout,
+		QueueSize:  defaults.QueueSize,
+	})
+}
+
+// sloEventNotifier forwards SLO budget events to the event dispatcher.
+type sloEventNotifier struct {
+	dispatcher *notify.Dispatcher
+}
+
+// Notify implements slo.Notifier.
+func (n sloEventNotifier) Notify(ctx context.Context, event slo.Event) error {
+	severity := notify.SeverityInfo
+	message := fmt.Sprintf("SLO %s budget recovered on route %s", event.Objective, event.RouteID)
+	if event.Type == slo.EventBudgetExhausted {
+		severity = notify.SeverityCritical
+		message = fmt.Sprintf("SLO %s budget exhausted on route %s (burn rate %.2f)", event.Objective, event.RouteID, event.BurnRate)
+	}
+
+	data := map[string]interface{}{
+		"route_id":  event.RouteID,
+		"objective": event.Objective,
+		"burn_rate": event.BurnRate,
+		"window":    event.Window,
+	}
+	if event.RouteName != "" {
+		data["route_name"] = event.RouteName
+	}
+
+	// slo event types match the notify ones (slo.budget_exhausted/recovered)
+	n.dispatcher.Publish(notify.Event{
+		Type:      event.Type,
+		Severity:  severity,
+		Timestamp: event.Timestamp,
+		Message:   message,
+		Data:      data,
+	})
+	return nil
+}
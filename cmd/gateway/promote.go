@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/saidutt46/switchboard-gateway/internal/config"
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/logging"
+	"github.com/saidutt46/switchboard-gateway/internal/promote"
+)
+
+// runExport implements `gateway export`: write a workspace's topology as a
+// template with environment-specific values (hosts, limits) replaced by
+// ${VAR} placeholders, and optionally their current values as an env file.
+//
+// Usage:
+//
+//	gateway export [-workspace default] [-o staging.yaml] [-env-out staging.env] [-raw]
+//
+// The template is JSON (readable as YAML). -raw exports without
+// placeholders.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	workspace := fs.String("workspace", database.DefaultWorkspace, "Workspace to export")
+	output := fs.String("o", "", "Write the template to this file instead of stdout")
+	envOut := fs.String("env-out", "", "Write the placeholders' current values to this env file")
+	raw := fs.Bool("raw", false, "Export values as they are, without placeholders")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openConfigDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	topology, err := database.NewRepository(db).ExportTopology(ctx, *workspace)
+	if err != nil {
+		return err
+	}
+
+	var template []byte
+	var vars map[string]string
+	if *raw {
+		if template, err = json.MarshalIndent(topology, "", "  "); err != nil {
+			return fmt.Errorf("failed to encode topology: %w", err)
+		}
+		template = append(template, '\n')
+	} else if template, vars, err = promote.Parameterize(topology); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		os.Stdout.Write(template)
+	} else if err := os.WriteFile(*output, template, 0o644); err != nil {
+		return fmt.Errorf("failed to write template: %w", err)
+	}
+
+	if *envOut != "" {
+		// Backend addresses may be sensitive
+		if err := os.WriteFile(*envOut, promote.FormatEnv(vars), 0o600); err != nil {
+			return fmt.Errorf("failed to write env file: %w", err)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d services, %d routes and %d plugins of workspace %s (%d variables)\n",
+		len(topology.Services), len(topology.Routes), len(topology.Plugins), topology.Workspace, len(vars))
+	return nil
+}
+
+// runPromote implements `gateway promote`: render a template exported
+// from one environment with another environment's variables and apply it
+// to this environment's database.
+//
+// Usage:
+//
+//	gateway promote -from staging.yaml -env production.env [-workspace <name>] [-prune] [-dry-run]
+//
+// Variables are read from the env file, then from the process environment.
+// Services, route groups and routes are matched by name; -prune deletes
+// those of the workspace missing from the template. -dry-run prints the
+// rendered topology without applying it.
+func runPromote(args []string) error {
+	fs := flag.NewFlagSet("promote", flag.ContinueOnError)
+	from := fs.String("from", "", "Template exported with `gateway export` (required)")
+	envFile := fs.String("env", "", "Env file with the target environment's variables")
+	workspace := fs.String("workspace", "", "Apply to this workspace instead of the template's")
+	prune := fs.Bool("prune", false, "Delete services, route groups, routes and plugins missing from the template")
+	dryRun := fs.Bool("dry-run", false, "Print the rendered topology instead of applying it")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" {
+		fs.Usage()
+		return fmt.Errorf("-from is required")
+	}
+
+	template, err := os.ReadFile(*from)
+	if err != nil {
+		return fmt.Errorf("failed to read template: %w", err)
+	}
+	vars := map[string]string{}
+	if *envFile != "" {
+		if vars, err = godotenv.Read(*envFile); err != nil {
+			return fmt.Errorf("failed to read env file: %w", err)
+		}
+	}
+	lookup := func(name string) (string, bool) {
+		if value, ok := vars[name]; ok {
+			return value, true
+		}
+		return os.LookupEnv(name)
+	}
+
+	topology, err := promote.Load(template, lookup)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *from, err)
+	}
+	if *workspace != "" {
+		topology.Workspace = *workspace
+	}
+
+	if *dryRun {
+		rendered, err := json.MarshalIndent(topology, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode topology: %w", err)
+		}
+		fmt.Println(string(rendered))
+		return nil
+	}
+
+	db, err := openConfigDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	result, err := database.NewRepository(db).ApplyTopology(ctx, topology, *prune)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Promoted %s to workspace %s: %d created, %d updated, %d deleted\n",
+		*from, database.WorkspaceName(topology.Workspace), result.Created, result.Updated, result.Deleted)
+
+	announceResync(topology.Workspace)
+	return nil
+}
+
+// openConfigDB loads the configuration and connects to its database.
+func openConfigDB() (*database.DB, error) {
+	_ = godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := logging.Setup("warn", "console"); err != nil {
+		return nil, fmt.Errorf("failed to setup logging: %w", err)
+	}
+
+	db, err := database.NewDB(cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return db, nil
+}
+
+// announceResync tells running gateways to reload over Redis. Gateways
+// without Redis reload from the database's change notifications instead.
+func announceResync(workspace string) {
+	cfg, err := config.Load()
+	if err != nil {
+		return
+	}
+	client, err := initializeRedis(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Redis unavailable (%v); gateways reload from database notifications\n", err)
+		return
+	}
+	defer client.Close()
+
+	payload, _ := json.Marshal(config.ConfigChangeEvent{
+		EventType:  "config_change",
+		EntityType: "service",
+		EntityID:   "*",
+		Action:     "resync",
+		Metadata:   map[string]interface{}{"source": "promote", "workspace": database.WorkspaceName(workspace)},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Publish(ctx, config.ChangesChannel, payload).Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to announce the change over Redis: %v\n", err)
+	}
+}
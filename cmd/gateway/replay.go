@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/saidutt46/switchboard-gateway/internal/config"
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/logging"
+	"github.com/saidutt46/switchboard-gateway/internal/recording"
+)
+
+// headerFlags collects repeated -header "Name: value" flags.
+type headerFlags []string
+
+func (h *headerFlags) String() string     { return strings.Join(*h, ", ") }
+func (h *headerFlags) Set(v string) error { *h = append(*h, v); return nil }
+
+// runReplay implements `gateway replay`: re-send recorded requests against
+// a target environment and report status codes that differ from the
+// original responses.
+//
+// Usage:
+//
+//	gateway replay -target https://staging.example.com [-route <id>] [-since 1h]
+//	               [-limit 100] [-rate 10] [-header "Authorization: Bearer ..."]
+//
+// Exits non-zero if any request failed or returned a different status.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	target := fs.String("target", "", "Base URL to replay requests against (required)")
+	routeID := fs.String("route", "", "Only replay requests recorded for this route ID")
+	since := fs.Duration("since", 24*time.Hour, "Only replay requests recorded within this window")
+	limit := fs.Int("limit", 100, "Maximum number of requests to replay (0 = all)")
+	rate := fs.Float64("rate", 10, "Requests per second (0 = unthrottled)")
+	timeout := fs.Duration("timeout", 30*time.Second, "Per-request timeout")
+	var headers headerFlags
+	fs.Var(&headers, "header", `Header to set on every request, "Name: value" (repeatable)`)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *target == "" {
+		fs.Usage()
+		return fmt.Errorf("-target is required")
+	}
+
+	replayer, err := recording.NewReplayer(*target, *timeout)
+	if err != nil {
+		return err
+	}
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			return fmt.Errorf("invalid -header %q (expected \"Name: value\")", h)
+		}
+		replayer.Headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	// Database settings come from the same environment as the gateway
+	_ = godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := logging.Setup("warn", "console"); err != nil {
+		return fmt.Errorf("failed to setup logging: %w", err)
+	}
+
+	db, err := database.NewDB(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	requests, err := database.NewRepository(db).GetRecordedRequests(ctx, database.RecordedRequestFilter{
+		RouteID: *routeID,
+		Since:   time.Now().Add(-*since),
+		Limit:   *limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Replaying %d recorded requests against %s\n\n", len(requests), *target)
+
+	var interval time.Duration
+	if *rate > 0 {
+		interval = time.Duration(float64(time.Second) / *rate)
+	}
+
+	var failed, mismatched int
+	for i, rec := range requests {
+		if i > 0 && interval > 0 {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			fmt.Println("\nInterrupted")
+			break
+		}
+
+		res := replayer.Replay(ctx, rec)
+
+		path := rec.Path
+		if rec.Query != "" {
+			path += "?" + rec.Query
+		}
+
+		switch {
+		case res.Err != nil:
+			failed++
+			fmt.Printf("FAIL  %-7s %s  recorded=%d  error=%v\n", rec.Method, path, rec.StatusCode, res.Err)
+		case res.StatusMismatch:
+			mismatched++
+			fmt.Printf("DIFF  %-7s %s  recorded=%d  replayed=%d  %s\n", rec.Method, path, rec.StatusCode, res.StatusCode, res.Duration.Round(time.Millisecond))
+		default:
+			fmt.Printf("OK    %-7s %s  status=%d  %s\n", rec.Method, path, res.StatusCode, res.Duration.Round(time.Millisecond))
+		}
+		if rec.Truncated {
+			fmt.Printf("      (request body was truncated when recorded)\n")
+		}
+	}
+
+	fmt.Printf("\n%d replayed, %d status mismatches, %d failed\n", len(requests), mismatched, failed)
+
+	if failed > 0 || mismatched > 0 {
+		return fmt.Errorf("%d of %d requests did not match", failed+mismatched, len(requests))
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/config"
+	"github.com/saidutt46/switchboard-gateway/internal/connlimit"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+var (
+	protocolRequests = metrics.NewCounterVec(
+		"gateway_http_requests_total",
+		"Client requests received, by HTTP protocol version.",
+		"protocol",
+	)
+	protocolInFlight = metrics.NewGaugeVec(
+		"gateway_http_requests_in_flight",
+		"Client requests currently being served, by HTTP protocol version.",
+		"protocol",
+	)
+)
+
+// newServer creates the client-facing HTTP server.
+//
+// Protocols:
+//   - HTTP/1.1: Always enabled
+//   - HTTP/2 (h2): Negotiated via ALPN when TLS is configured and HTTP2_ENABLED
+//   - HTTP/2 cleartext (h2c): Prior-knowledge HTTP/2 without TLS when H2C_ENABLED
+//     (useful behind a TLS-terminating load balancer)
+//   - HTTP/3: Served separately over QUIC when HTTP3_ENABLED (see
+//     newHTTP3Server) and advertised here via Alt-Svc
+func newServer(cfg *config.Config, handler http.Handler) *http.Server {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(cfg.HTTP2Enabled && cfg.TLSEnabled())
+	protocols.SetUnencryptedHTTP2(cfg.H2CEnabled)
+
+	log.Info().
+		Str("component", "server").
+		Bool("tls", cfg.TLSEnabled()).
+		Bool("http2", protocols.HTTP2()).
+		Bool("h2c", protocols.UnencryptedHTTP2()).
+		Msg("HTTP listener protocols configured")
+
+	return &http.Server{
+		Addr:              cfg.ServerAddress(),
+		Handler:           withProtocolMetrics(handler),
+		Protocols:         protocols,
+		ReadHeaderTimeout: cfg.Listener.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Listener.ReadTimeout,
+		WriteTimeout:      cfg.Listener.WriteTimeout,
+		IdleTimeout:       cfg.Listener.IdleTimeout,
+
+		// Bounds the raw header block while reading; headerlimit.Handler
+		// enforces the exact limits (0 = net/http default of 1MB)
+		MaxHeaderBytes: cfg.HeaderLimits.MaxBytes,
+	}
+}
+
+// listenAndServe starts the server with or without TLS depending on config.
+//
+// Connections beyond MAX_CONNECTIONS / MAX_CONNECTIONS_PER_IP are closed
+// on accept.
+func listenAndServe(cfg *config.Config, server *http.Server) error {
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+
+	limited := connlimit.NewListener(ln, connlimit.Config{
+		MaxConns:          cfg.Listener.MaxConnections,
+		MaxConnsPerIP:     cfg.Listener.MaxConnectionsPerIP,
+		ReadHeaderTimeout: cfg.Listener.ReadHeaderTimeout,
+	})
+	server.ConnState = limited.ConnState
+
+	log.Info().
+		Str("component", "server").
+		Dur("read_header_timeout", cfg.Listener.ReadHeaderTimeout).
+		Dur("idle_timeout", cfg.Listener.IdleTimeout).
+		Int("max_connections", cfg.Listener.MaxConnections).
+		Int("max_connections_per_ip", cfg.Listener.MaxConnectionsPerIP).
+		Msg("Client listener limits configured")
+
+	if cfg.TLSEnabled() {
+		return server.ServeTLS(limited, cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	return server.Serve(limited)
+}
+
+// newHTTP3Server creates the experimental HTTP/3 server, or returns nil
+// unless HTTP3_ENABLED. It listens on the client listener's port over UDP
+// with the same TLS certificate and handler.
+//
+// QUIC connections are not accepted through connlimit, so
+// MAX_CONNECTIONS / MAX_CONNECTIONS_PER_IP do not apply to them.
+func newHTTP3Server(cfg *config.Config, handler http.Handler) *http3.Server {
+	if !cfg.HTTP3Enabled {
+		return nil
+	}
+
+	log.Warn().
+		Str("component", "server").
+		Str("address", cfg.ServerAddress()).
+		Msg("HTTP/3 is experimental - QUIC connections are not subject to MAX_CONNECTIONS limits")
+
+	return &http3.Server{
+		Addr:           cfg.ServerAddress(),
+		Handler:        withProtocolMetrics(handler),
+		IdleTimeout:    cfg.Listener.IdleTimeout,
+		MaxHeaderBytes: cfg.HeaderLimits.MaxBytes,
+	}
+}
+
+// listenAndServeHTTP3 starts the HTTP/3 server on UDP. HTTP/3 always runs
+// over TLS, so config validation requires TLS_CERT_FILE and TLS_KEY_FILE.
+func listenAndServeHTTP3(cfg *config.Config, server *http3.Server) error {
+	return server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+}
+
+// shutdownHTTP3 sends GOAWAY on QUIC connections and waits until ctx is
+// done for their requests, then closes them. A nil server is a no-op.
+func shutdownHTTP3(ctx context.Context, server *http3.Server) error {
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+// withAltSvc advertises server on responses sent over TCP, so clients can
+// switch to HTTP/3 for later requests. Until the QUIC listener is up there
+// is nothing to advertise and responses are sent unchanged.
+func withAltSvc(server *http3.Server, next http.Handler) http.Handler {
+	if server == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.SetQUICHeaders(w.Header())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withProtocolMetrics counts requests per HTTP protocol version.
+func withProtocolMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		protocolRequests.Inc(r.Proto)
+		protocolInFlight.Add(1, r.Proto)
+		defer protocolInFlight.Add(-1, r.Proto)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newAdminServer creates the server for the /admin endpoints on
+// ADMIN_LISTEN_ADDR, or returns nil if they are disabled. It is kept off
+// the client-facing listener so /admin/ paths reach upstreams like any
+// other path, and so the admin port can stay private (127.0.0.1 by
+// default).
+func newAdminServer(cfg *config.Config, handler http.Handler) *http.Server {
+	if cfg.AdminListenAddr == "" {
+		log.Info().
+			Str("component", "admin").
+			Msg("ADMIN_LISTEN_ADDR empty - /admin endpoints disabled")
+		return nil
+	}
+
+	if cfg.AdminToken == "" {
+		log.Warn().
+			Str("component", "admin").
+			Str("address", cfg.AdminListenAddr).
+			Msg("ADMIN_TOKEN not set - /admin endpoints are unauthenticated (development only)")
+	}
+
+	return &http.Server{
+		Addr:              cfg.AdminListenAddr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.Listener.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Listener.ReadTimeout,
+		WriteTimeout:      cfg.Listener.WriteTimeout,
+		IdleTimeout:       cfg.Listener.IdleTimeout,
+	}
+}
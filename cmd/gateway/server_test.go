@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+
+	"github.com/saidutt46/switchboard-gateway/internal/config"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and returns
+// the cert and key file paths.
+func writeTestCert(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gateway.test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+// freeUDPPort returns a UDP port on 127.0.0.1 that was free a moment ago.
+func freeUDPPort(t *testing.T) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestHTTP3Server(t *testing.T) {
+	if newHTTP3Server(&config.Config{}, http.NotFoundHandler()) != nil {
+		t.Fatal("newHTTP3Server() without HTTP3_ENABLED returned a server")
+	}
+
+	certFile, keyFile := writeTestCert(t)
+	port := freeUDPPort(t)
+	cfg := &config.Config{ServerHost: "127.0.0.1", ServerPort: port, TLSCertFile: certFile, TLSKeyFile: keyFile, HTTP3Enabled: true}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	})
+
+	h3Server := newHTTP3Server(cfg, handler)
+	served := make(chan error, 1)
+	go func() {
+		served <- listenAndServeHTTP3(cfg, h3Server)
+	}()
+
+	client := &http.Client{
+		Transport: &http3.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		Timeout:   5 * time.Second,
+	}
+	url := "https://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(port)) + "/orders"
+	var resp *http.Response
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		var err error
+		if resp, err = client.Get(url); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET over HTTP/3 error = %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.ProtoMajor != 3 || string(body) != "HTTP/3.0" {
+		t.Errorf("response %s with body %q, want the handler served over HTTP/3", resp.Proto, body)
+	}
+
+	// TCP responses advertise the QUIC listener
+	w := httptest.NewRecorder()
+	withAltSvc(h3Server, handler).ServeHTTP(w, httptest.NewRequest("GET", "/orders", nil))
+	if got, want := w.Header().Get("Alt-Svc"), `h3=":`+strconv.Itoa(port)+`"; ma=2592000`; got != want {
+		t.Errorf("Alt-Svc = %q, want %q", got, want)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	shutdownHTTP3(ctx, h3Server)
+	if err := <-served; err != http.ErrServerClosed {
+		t.Errorf("listenAndServeHTTP3() = %v, want http.ErrServerClosed after shutdown", err)
+	}
+}
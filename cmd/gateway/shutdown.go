@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/cluster"
+	"github.com/saidutt46/switchboard-gateway/internal/config"
+	"github.com/saidutt46/switchboard-gateway/internal/health"
+	"github.com/saidutt46/switchboard-gateway/internal/metering"
+	"github.com/saidutt46/switchboard-gateway/internal/notify"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/streamproxy"
+	"github.com/saidutt46/switchboard-gateway/internal/usage"
+)
+
+// drainer runs the first shutdown phase: readiness fails and the gateway
+// keeps serving for the drain delay while load balancers take it out of
+// rotation. The pre-stop hook (/admin/drain) and the shutdown signal both
+// call Drain; whichever comes first starts the delay and the other waits
+// for the same deadline, so the delay is not paid twice.
+type drainer struct {
+	health   *health.Handler
+	server   *http.Server
+	notifier *notify.Dispatcher
+	delay    time.Duration
+
+	once sync.Once
+	done chan struct{} // closed once the drain delay has passed
+}
+
+// newDrainer creates a drainer for server.
+func newDrainer(healthHandler *health.Handler, server *http.Server, notifier *notify.Dispatcher, delay time.Duration) *drainer {
+	return &drainer{
+		health:   healthHandler,
+		server:   server,
+		notifier: notifier,
+		delay:    delay,
+		done:     make(chan struct{}),
+	}
+}
+
+// Drain starts draining if needed and blocks until the drain delay has
+// passed or ctx is done. Implements admin.Drainer.
+func (d *drainer) Drain(ctx context.Context) error {
+	d.once.Do(d.start)
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// start fails readiness and starts the drain delay.
+func (d *drainer) start() {
+	d.health.StartDrain()
+
+	// Connection: close on responses, so clients reconnect through the
+	// load balancer instead of reusing connections to this instance
+	d.server.SetKeepAlivesEnabled(false)
+
+	log.Info().
+		Str("component", "shutdown").
+		Dur("drain_delay", d.delay).
+		Msg("Draining: readiness now fails, still serving requests")
+
+	d.notifier.Publish(notify.Event{
+		Type:     notify.EventGatewayDraining,
+		Severity: notify.SeverityInfo,
+		Message:  "Gateway is draining for shutdown",
+		Data: map[string]interface{}{
+			"drain_delay": d.delay.String(),
+		},
+	})
+
+	time.AfterFunc(d.delay, func() { close(d.done) })
+}
+
+// gracefulShutdown stops the gateway in phases, each with its own timeout:
+//  1. Fail readiness (see drainer)
+//  2. Keep serving for SHUTDOWN_DRAIN_DELAY, less any time a pre-stop hook
+//     already waited
+//  3. Stop accepting connections
+//  4. Wait up to SHUTDOWN_TIMEOUT for in-flight requests, then close the
+//     remaining connections
+//  5. Within SHUTDOWN_CLOSE_TIMEOUT, finish queued async plugin jobs, flush
+//     usage, metering and notifications and close Redis
+//
+// The database is closed last, by run's deferred Close.
+func gracefulShutdown(cfg *config.Config, server *http.Server, h3Server *http3.Server, adminServer *http.Server, streams *streamproxy.Server, d *drainer, asyncPool *plugin.AsyncPool, usageAggregator *usage.Aggregator, meter *metering.Meter, notifier *notify.Dispatcher, membership *cluster.Membership, redisClient *redis.Client) error {
+	// Phases 1-2: drain
+	d.Drain(context.Background())
+
+	// Phases 3-4: stop accepting, wait for in-flight requests
+	log.Info().
+		Str("component", "shutdown").
+		Dur("timeout", cfg.ShutdownTimeout).
+		Msg("Stopped accepting connections, waiting for in-flight requests")
+
+	inflightCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// QUIC connections drain alongside TCP ones, within the same timeout
+	h3Done := make(chan error, 1)
+	go func() {
+		h3Done <- shutdownHTTP3(inflightCtx, h3Server)
+	}()
+
+	if err := server.Shutdown(inflightCtx); err != nil {
+		log.Error().
+			Err(err).
+			Str("component", "shutdown").
+			Msg("In-flight requests did not finish in time, forcing shutdown")
+		if err := server.Close(); err != nil {
+			return fmt.Errorf("could not stop server gracefully: %w", err)
+		}
+	}
+
+	if err := <-h3Done; err != nil {
+		log.Error().
+			Err(err).
+			Str("component", "shutdown").
+			Msg("HTTP/3 requests did not finish in time, connections closed")
+	}
+
+	// Stream connections have no request boundary to wait for
+	streams.Close()
+
+	// The admin listener served the drain; nothing else needs it now
+	if adminServer != nil {
+		adminServer.Close()
+	}
+
+	// Phase 5: flush and close backing connections
+	log.Info().
+		Str("component", "shutdown").
+		Dur("timeout", cfg.ShutdownCloseTimeout).
+		Msg("Flushing pending writes and closing connections")
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownCloseTimeout)
+	defer cancel()
+
+	// Run the AfterResponse jobs of async plugins still queued
+	asyncPool.Close(closeCtx)
+
+	// Write usage counted since the last flush
+	usageAggregator.Close(closeCtx)
+
+	// Deliver (or spool) pending metering records
+	meter.Close(closeCtx)
+
+	// Deliver queued notifications
+	notifier.Close(closeCtx)
+
+	// Peers stop listing this instance
+	membership.Leave(closeCtx)
+
+	if redisClient != nil {
+		if err := redisClient.Close(); err != nil {
+			log.Error().
+				Err(err).
+				Str("component", "redis").
+				Msg("Error closing Redis connection")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/ratelimit"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+	"github.com/saidutt46/switchboard-gateway/internal/slo"
+)
+
+// statusReport is the /status document: per-route SLO status plus
+// configuration problems that don't stop the gateway from serving.
+type statusReport struct {
+	slo.Report
+	RouteConflicts []router.Conflict `json:"route_conflicts"`
+	OrphanedRoutes []router.Orphan   `json:"orphaned_routes"`
+
+	// RateLimitKeyspace is the last rate limit keyspace scan (omitted when
+	// keyspace maintenance is off or hasn't run yet)
+	RateLimitKeyspace *ratelimit.KeyspaceReport `json:"ratelimit_keyspace,omitempty"`
+}
+
+// statusHandler serves /status.
+func statusHandler(sloTracker *slo.Tracker, rt *router.Router, keyspaceMonitor *ratelimit.KeyspaceMonitor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+
+		report := statusReport{
+			Report:            sloTracker.Report(),
+			RouteConflicts:    rt.Conflicts(),
+			OrphanedRoutes:    rt.Orphans(),
+			RateLimitKeyspace: keyspaceMonitor.Report(),
+		}
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			log.Error().
+				Err(err).
+				Str("component", "status").
+				Msg("Failed to encode status report")
+		}
+	})
+}
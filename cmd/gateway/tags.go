@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// otherTag labels tags seen after the label limit was reached.
+const otherTag = "other"
+
+var (
+	tagRequests = metrics.NewCounterVec(
+		"gateway_tag_requests_total",
+		"Routed requests by route/service tag and response status class (2xx, 4xx, ...).",
+		"tag", "code",
+	)
+	tagDuration = metrics.NewHistogramVec(
+		"gateway_tag_request_duration_seconds",
+		"Latency of routed requests by route/service tag, including plugins.",
+		nil,
+		"tag",
+	)
+)
+
+// tagMetrics counts requests against their route's and service's tags.
+// Tags are free-form, so the tag label is bounded: the first max distinct
+// tags get their own series and later ones share "other".
+type tagMetrics struct {
+	max int
+
+	mu     sync.Mutex
+	labels map[string]bool
+}
+
+// newTagMetrics returns nil (no tag metrics) when max is 0.
+func newTagMetrics(max int) *tagMetrics {
+	if max <= 0 {
+		return nil
+	}
+	return &tagMetrics{max: max, labels: make(map[string]bool)}
+}
+
+// Record counts a request once per tag.
+func (m *tagMetrics) Record(tags []string, status int, elapsed time.Duration) {
+	if m == nil || len(tags) == 0 {
+		return
+	}
+	code := strconv.Itoa(status/100) + "xx"
+	for _, tag := range tags {
+		label := m.label(tag)
+		tagRequests.Inc(label, code)
+		tagDuration.Observe(elapsed.Seconds(), label)
+	}
+}
+
+// label returns the metric label of tag.
+func (m *tagMetrics) label(tag string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.labels[tag] {
+		return tag
+	}
+	if len(m.labels) >= m.max {
+		return otherTag
+	}
+	m.labels[tag] = true
+	return tag
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+var (
+	workspaceRequests = metrics.NewCounterVec(
+		"gateway_workspace_requests_total",
+		"Routed requests by workspace and response status class (2xx, 4xx, ...).",
+		"workspace", "code",
+	)
+	workspaceDuration = metrics.NewHistogramVec(
+		"gateway_workspace_request_duration_seconds",
+		"Latency of routed requests by workspace, including plugins.",
+		nil,
+		"workspace",
+	)
+)
+
+// recordWorkspaceRequest counts a routed request against its workspace.
+func recordWorkspaceRequest(workspace string, status int, elapsed time.Duration) {
+	workspaceRequests.Inc(workspace, strconv.Itoa(status/100)+"xx")
+	workspaceDuration.Observe(elapsed.Seconds(), workspace)
+}
@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// RecentError is a request that ended in a server error.
+type RecentError struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	RouteID   string    `json:"route_id"`
+	RouteName string    `json:"route_name,omitempty"`
+	Status    int       `json:"status"`
+	Latency   string    `json:"latency"`
+}
+
+// Activity keeps recent request outcomes for the dashboard: the last
+// server errors and how many requests each route rate limited (429).
+//
+// A nil Activity records nothing.
+type Activity struct {
+	mu      sync.Mutex
+	errors  []RecentError // ring buffer, next is the oldest entry once full
+	next    int
+	full    bool
+	limited map[string]int64 // route ID -> 429 responses
+}
+
+// NewActivity creates an activity log keeping the last size errors.
+func NewActivity(size int) *Activity {
+	if size <= 0 {
+		size = 50
+	}
+	return &Activity{
+		errors:  make([]RecentError, size),
+		limited: make(map[string]int64),
+	}
+}
+
+// Record notes the outcome of a routed request.
+func (a *Activity) Record(r *http.Request, requestID string, route *database.Route, status int, latency time.Duration) {
+	if a == nil || route == nil || (status < 500 && status != http.StatusTooManyRequests) {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if status == http.StatusTooManyRequests {
+		a.limited[route.ID]++
+		return
+	}
+
+	a.errors[a.next] = RecentError{
+		Time:      time.Now().UTC(),
+		RequestID: requestID,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		RouteID:   route.ID,
+		RouteName: route.Name.String,
+		Status:    status,
+		Latency:   latency.Round(time.Microsecond).String(),
+	}
+	a.next = (a.next + 1) % len(a.errors)
+	if a.next == 0 {
+		a.full = true
+	}
+}
+
+// RecentErrors returns the recorded server errors, newest first.
+func (a *Activity) RecentErrors() []RecentError {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n := a.next
+	if a.full {
+		n = len(a.errors)
+	}
+	recent := make([]RecentError, 0, n)
+	for i := 1; i <= n; i++ {
+		recent = append(recent, a.errors[(a.next-i+len(a.errors))%len(a.errors)])
+	}
+	return recent
+}
+
+// RateLimited returns the 429 responses per route ID since startup.
+func (a *Activity) RateLimited() map[string]int64 {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	limited := make(map[string]int64, len(a.limited))
+	for id, n := range a.limited {
+		limited[id] = n
+	}
+	return limited
+}
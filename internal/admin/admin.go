@@ -0,0 +1,148 @@
+// Package admin provides the gateway's read-only admin endpoints, served
+// under /admin/ on their own listener (ADMIN_LISTEN_ADDR), never next to
+// proxied traffic.
+//
+// These complement the Python admin API (which owns configuration CRUD)
+// with views that only the gateway can answer, such as what is actually
+// routable right now.
+//
+// If ADMIN_TOKEN is set, every request must carry
+// "Authorization: Bearer <token>"; the gateway refuses to start without
+// one outside development. The exception is the dashboard page
+// under /admin/ui/: it holds no data and asks for the token itself before
+// loading /admin/dashboard.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/cluster"
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/loadbalancer"
+	"github.com/saidutt46/switchboard-gateway/internal/ratelimit"
+	"github.com/saidutt46/switchboard-gateway/internal/respcache"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+	"github.com/saidutt46/switchboard-gateway/internal/signedurl"
+)
+
+// Config holds configuration for the admin endpoints.
+type Config struct {
+	// Token is the bearer token required on admin requests (empty = open)
+	Token string
+
+	// Version is reported in generated documents
+	Version string
+
+	// Debug serves pprof, expvar and goroutine dumps under /admin/debug/
+	// (only with a Token)
+	Debug bool
+}
+
+// Handler serves the /admin/ endpoints.
+type Handler struct {
+	config Config
+	repo   *database.Repository
+	router *router.Router
+	mux    *http.ServeMux
+
+	// drainer serves /admin/drain (nil = unavailable; the route only
+	// exists with a token)
+	drainer Drainer
+
+	// urlSigner serves /admin/signed-urls (nil = unavailable; the route
+	// only exists with a token)
+	urlSigner *signedurl.Signer
+
+	// cachePurger serves /admin/cache (nil = unavailable; the route only
+	// exists with a token)
+	cachePurger *respcache.Purger
+
+	// cluster serves /admin/cluster (nil = unavailable)
+	cluster *cluster.Membership
+
+	// Dashboard sources (nil = section left empty)
+	balancers *loadbalancer.Manager
+	keyspace  *ratelimit.KeyspaceMonitor
+	activity  *Activity
+}
+
+// NewHandler creates the admin handler.
+func NewHandler(config Config, repo *database.Repository, rt *router.Router) *Handler {
+	h := &Handler{
+		config: config,
+		repo:   repo,
+		router: rt,
+		mux:    http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("GET /admin/specs", h.Specs)
+	h.mux.HandleFunc("POST /admin/router/test", h.RouterTest)
+	h.mux.HandleFunc("GET /admin/routes/{id}/plugins", h.RoutePlugins)
+	h.mux.HandleFunc("GET /admin/consumers/{id}/usage", h.ConsumerUsage)
+	h.mux.HandleFunc("GET /admin/cluster", h.Cluster)
+	h.mux.HandleFunc("GET /admin/dashboard", h.Dashboard)
+	h.mux.Handle("GET /admin/ui", http.RedirectHandler("/admin/ui/", http.StatusMovedPermanently))
+	h.mux.Handle("GET /admin/ui/", uiHandler())
+
+	// Draining takes the instance out of every load balancer, signed URLs
+	// grant access and purges send traffic back to the backends, so none
+	// is ever open to anonymous callers
+	if config.Token != "" {
+		h.mux.HandleFunc("POST /admin/drain", h.Drain)
+		h.mux.HandleFunc("POST /admin/signed-urls", h.SignedURL)
+		h.mux.HandleFunc("DELETE /admin/cache", h.PurgeCache)
+	}
+	// Profiles cost CPU and vars expose the command line: never without
+	// a token (config validation refuses that too)
+	if config.Debug && config.Token != "" {
+		h.registerDebug()
+	}
+
+	return h
+}
+
+// ServeHTTP authenticates the request and dispatches it.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.config.Token != "" && !isUIRequest(r) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.config.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid or missing admin token")
+			return
+		}
+	}
+
+	h.mux.ServeHTTP(w, r)
+}
+
+// isUIRequest reports whether r fetches the dashboard page or its assets.
+func isUIRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	p := path.Clean(r.URL.Path)
+	return p == "/admin/ui" || strings.HasPrefix(p, "/admin/ui/")
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().
+			Err(err).
+			Str("component", "admin").
+			Msg("Failed to encode admin response")
+	}
+}
+
+// writeError writes a JSON error response.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
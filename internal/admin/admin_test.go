@@ -0,0 +1,497 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/respcache"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+	"github.com/saidutt46/switchboard-gateway/internal/signedurl"
+)
+
+func newTestHandler(token string) *Handler {
+	service := &database.Service{ID: "svc", Name: "users", Host: "users", Port: 80, Enabled: true}
+	route := &database.Route{
+		ID:        "users-route",
+		ServiceID: service.ID,
+		Paths:     []string{"/api/users/:id"},
+		Methods:   []string{"GET"},
+		Enabled:   true,
+	}
+	rt := router.NewRouter([]*database.Route{route}, []*database.Service{service}, []plugin.PluginInstance{})
+
+	return NewHandler(Config{Token: token}, nil, rt)
+}
+
+func TestHandler_RequiresToken(t *testing.T) {
+	h := newTestHandler("secret")
+
+	body := `{"method":"GET","path":"/api/users/1"}`
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/admin/router/test", strings.NewReader(body)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status without token = %d, want 401", w.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/admin/router/test", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("status with token = %d, want 200", w.Code)
+	}
+}
+
+func TestHandler_RouterTest(t *testing.T) {
+	h := newTestHandler("")
+
+	tests := []struct {
+		name        string
+		body        string
+		wantMatched bool
+		wantReason  string
+	}{
+		{
+			name:        "matches with params",
+			body:        `{"method":"get","path":"/api/users/42?x=1"}`,
+			wantMatched: true,
+		},
+		{
+			name:        "wrong method",
+			body:        `{"method":"DELETE","path":"/api/users/42"}`,
+			wantMatched: false,
+			wantReason:  router.RejectMethod,
+		},
+		{
+			name:        "no path match",
+			body:        `{"path":"/nope"}`,
+			wantMatched: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("POST", "/admin/router/test", strings.NewReader(tt.body)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+			}
+
+			var resp RouterTestResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Matched != tt.wantMatched {
+				t.Errorf("matched = %v, want %v", resp.Matched, tt.wantMatched)
+			}
+			if tt.wantMatched && resp.Params["id"] != "42" {
+				t.Errorf("params = %v, want id=42", resp.Params)
+			}
+			if tt.wantReason != "" && (len(resp.Candidates) != 1 || resp.Candidates[0].Reason != tt.wantReason) {
+				t.Errorf("candidates = %+v, want reason %s", resp.Candidates, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestHandler_RouterTestInvalidPath(t *testing.T) {
+	h := newTestHandler("")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/admin/router/test", strings.NewReader(`{"path":"api"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+// namedPlugin is a no-op plugin for chain tests.
+type namedPlugin struct{ name string }
+
+func (p namedPlugin) Name() string                      { return p.name }
+func (p namedPlugin) Execute(ctx *plugin.Context) error { return nil }
+
+func TestHandler_RoutePlugins(t *testing.T) {
+	service := &database.Service{ID: "svc", Name: "users", Enabled: true}
+	route := &database.Route{ID: "users-route", ServiceID: service.ID, Paths: []string{"/users"}, Enabled: true}
+
+	instance := func(name, scope string, priority int, config map[string]interface{}) plugin.PluginInstance {
+		cfg := &database.Plugin{ID: name + "-id", Name: name, Scope: scope, Priority: priority, Config: config}
+		switch scope {
+		case database.PluginScopeService:
+			cfg.ServiceID = sql.NullString{String: service.ID, Valid: true}
+		case database.PluginScopeRoute:
+			cfg.RouteID = sql.NullString{String: route.ID, Valid: true}
+		}
+		return plugin.PluginInstance{Plugin: namedPlugin{name}, Config: cfg, Scope: scope, Priority: priority}
+	}
+
+	instances := []plugin.PluginInstance{
+		instance("rate-limit", database.PluginScopeRoute, 30, map[string]interface{}{
+			"redis_url": "redis://:hunter2@redis:6379/0",
+		}),
+		instance("cors", database.PluginScopeGlobal, 1, nil),
+		instance("jwt-auth", database.PluginScopeService, 10, map[string]interface{}{
+			"secret":     "s3cr3t",
+			"key_prefix": "jwt",
+		}),
+		instance("other-route", database.PluginScopeRoute, 5, nil),
+		instance("other-workspace", database.PluginScopeGlobal, 2, nil),
+	}
+	instances[3].Config.RouteID.String = "another-route"
+	instances[4].Config.Workspace = "team"
+
+	rt := router.NewRouter([]*database.Route{route}, []*database.Service{service}, instances)
+	h := NewHandler(Config{}, nil, rt)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/routes/users-route/plugins", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var resp RoutePluginsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, p := range resp.Plugins {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "cors,jwt-auth,rate-limit" {
+		t.Fatalf("chain = %v, want cors,jwt-auth,rate-limit", names)
+	}
+
+	if !resp.Plugins[1].Inherited || resp.Plugins[2].Inherited {
+		t.Error("expected service plugin inherited and route plugin not")
+	}
+	if resp.Plugins[1].Config["secret"] != redactedValue || resp.Plugins[1].Config["key_prefix"] != "jwt" {
+		t.Errorf("jwt config = %v, want secret redacted only", resp.Plugins[1].Config)
+	}
+	if got := resp.Plugins[2].Config["redis_url"]; got != "redis://:[REDACTED]@redis:6379/0" {
+		t.Errorf("redis_url = %v, want password redacted", got)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/routes/missing/plugins", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status for unknown route = %d, want 404", w.Code)
+	}
+}
+
+func TestHandler_ConsumerUsageInvalidRange(t *testing.T) {
+	h := newTestHandler("")
+
+	for _, query := range []string{
+		"?from=yesterday",
+		"?to=2026-13-01",
+		"?from=2026-03-02&to=2026-03-01",
+		"?from=2025-01-01&to=2026-03-01",
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/consumers/c1/usage"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, w.Code)
+		}
+	}
+}
+
+type fakeDrainer struct{ calls int }
+
+func (d *fakeDrainer) Drain(ctx context.Context) error {
+	d.calls++
+	return nil
+}
+
+func TestHandler_Drain(t *testing.T) {
+	// Without a token there is no drain endpoint
+	w := httptest.NewRecorder()
+	newTestHandler("").ServeHTTP(w, httptest.NewRequest("POST", "/admin/drain", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status without admin token = %d, want 404", w.Code)
+	}
+
+	h := newTestHandler("secret")
+	drain := func(method, token string) int {
+		req := httptest.NewRequest(method, "/admin/drain", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := drain("POST", "secret"); code != http.StatusServiceUnavailable {
+		t.Errorf("status without drainer = %d, want 503", code)
+	}
+
+	d := &fakeDrainer{}
+	h.SetDrainer(d)
+
+	if code := drain("POST", ""); code != http.StatusUnauthorized {
+		t.Errorf("anonymous POST status = %d, want 401", code)
+	}
+	if code := drain("GET", "secret"); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want 405", code)
+	}
+	if code := drain("POST", "secret"); code != http.StatusOK {
+		t.Errorf("POST status = %d, want 200", code)
+	}
+	if d.calls != 1 {
+		t.Errorf("Drain() calls = %d, want 1", d.calls)
+	}
+}
+
+func TestHandler_Dashboard(t *testing.T) {
+	h := newTestHandler("secret")
+	activity := NewActivity(10)
+	h.SetDashboard(nil, nil, activity)
+
+	route := &database.Route{ID: "users-route"}
+	req := httptest.NewRequest("GET", "/api/users/1", nil)
+	activity.Record(req, "req_1", route, http.StatusBadGateway, 0)
+	activity.Record(req, "req_2", route, http.StatusTooManyRequests, 0)
+
+	// The page loads without a token, its data does not
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/ui/", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "dashboard.js") {
+		t.Fatalf("GET /admin/ui/ = %d, want the dashboard page", w.Code)
+	}
+	for _, target := range []string{"/admin/dashboard", "/admin/ui/../dashboard"} {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		if w.Code == http.StatusOK {
+			t.Errorf("GET %s without token = 200, want it rejected", target)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/admin/dashboard", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	var resp DashboardResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Routes) != 1 || resp.Routes[0].ServiceName != "users" {
+		t.Errorf("routes = %+v, want users-route -> users", resp.Routes)
+	}
+	if len(resp.Services) != 1 || resp.Services[0].Upstream != "http://users:80" {
+		t.Errorf("services = %+v, want users at http://users:80", resp.Services)
+	}
+	if len(resp.RecentErrors) != 1 || resp.RecentErrors[0].RequestID != "req_1" {
+		t.Errorf("recent errors = %+v, want req_1", resp.RecentErrors)
+	}
+	if len(resp.RateLimited) != 1 || resp.RateLimited[0].Count != 1 {
+		t.Errorf("rate limited = %+v, want 1 for users-route", resp.RateLimited)
+	}
+}
+
+func TestActivity_RecentErrors(t *testing.T) {
+	a := NewActivity(3)
+	route := &database.Route{ID: "r"}
+	req := httptest.NewRequest("GET", "/", nil)
+
+	for i, status := range []int{500, 200, 404, 502, 503, 504} {
+		a.Record(req, string(rune('a'+i)), route, status, 0)
+	}
+
+	// Only server errors are kept, the oldest (a) fell out of the ring
+	var got []string
+	for _, e := range a.RecentErrors() {
+		got = append(got, e.RequestID)
+	}
+	if strings.Join(got, ",") != "f,e,d" {
+		t.Errorf("recent errors = %v, want [f e d]", got)
+	}
+
+	var nilActivity *Activity
+	nilActivity.Record(req, "x", route, 500, 0)
+	if nilActivity.RecentErrors() != nil || nilActivity.RateLimited() != nil {
+		t.Error("nil Activity should record nothing")
+	}
+}
+
+func TestHandler_Debug(t *testing.T) {
+	// Off unless enabled
+	w := httptest.NewRecorder()
+	newTestHandler("").ServeHTTP(w, httptest.NewRequest("GET", "/admin/debug/goroutines", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status with debug disabled = %d, want 404", w.Code)
+	}
+
+	// Never without a token
+	w = httptest.NewRecorder()
+	NewHandler(Config{Debug: true}, nil, newTestHandler("").router).ServeHTTP(w, httptest.NewRequest("GET", "/admin/debug/pprof/cmdline", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status with debug enabled but no token = %d, want 404", w.Code)
+	}
+
+	h := NewHandler(Config{Token: "secret", Debug: true}, nil, newTestHandler("").router)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/debug/pprof/", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status without token = %d, want 401", w.Code)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/admin/debug/pprof/", "goroutine"},
+		{"/admin/debug/pprof/goroutine?debug=1", "goroutine profile"},
+		{"/admin/debug/vars", "memstats"},
+		{"/admin/debug/goroutines", "goroutine "},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("GET %s = %d, want 200 containing %q", tt.path, w.Code, tt.want)
+		}
+	}
+}
+
+func TestHandler_SignedURL(t *testing.T) {
+	signer, _ := signedurl.NewSigner([]string{"secret"}, time.Hour)
+	mint := func(h *Handler, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/admin/signed-urls", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without an admin token nobody can mint links
+	open := newTestHandler("")
+	open.SetURLSigner(signer)
+	if w := mint(open, `{"path":"/files/a"}`, ""); w.Code != http.StatusNotFound {
+		t.Errorf("status without admin token = %d, want 404", w.Code)
+	}
+
+	h := newTestHandler("admin")
+	if w := mint(h, `{"path":"/files/a"}`, "admin"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status without signer = %d, want 503", w.Code)
+	}
+
+	h.SetURLSigner(signer)
+
+	if w := mint(h, `{"path":"/files/a","consumer_id":"c1"}`, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", w.Code)
+	}
+
+	w := mint(h, `{"path":"/files/a b.pdf","ttl":"10m","consumer_id":"c1"}`, "admin")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	var resp SignedURLResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	u, err := url.Parse(resp.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer, err := signer.Verify(u.Path, u.Query(), time.Now())
+	if err != nil || consumer != "c1" {
+		t.Errorf("Verify(%s) = %q, %v", resp.URL, consumer, err)
+	}
+
+	for _, body := range []string{`{"path":"files"}`, `{"path":"/a?b=1"}`, `{"path":"/a","ttl":"2h"}`, `{"path":"/a","ttl":"-1s"}`} {
+		if w := mint(h, body, "admin"); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestHandler_ClusterDisabled(t *testing.T) {
+	h := newTestHandler("")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/cluster", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status without membership = %d, want 503", w.Code)
+	}
+}
+
+func TestHandler_PurgeCache(t *testing.T) {
+	purge := func(h *Handler, query, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("DELETE", "/admin/cache?"+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	store := respcache.NewStore(1 << 20)
+	for _, e := range []*respcache.Entry{
+		{Key: "a", RouteID: "products", Path: "/products/1", Tags: []string{"product-1"}},
+		{Key: "b", RouteID: "products", Path: "/products/2", Tags: []string{"product-2"}},
+		{Key: "c", RouteID: "orders", Path: "/orders/1"},
+	} {
+		e.Expires = time.Now().Add(time.Hour)
+		store.Put(e)
+	}
+
+	// Without an admin token nobody can purge
+	open := newTestHandler("")
+	open.SetCachePurger(respcache.NewPurger(store, nil))
+	if w := purge(open, "tag=product-1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("status without admin token = %d, want 404", w.Code)
+	}
+
+	h := newTestHandler("admin")
+	if w := purge(h, "tag=product-1", "admin"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status without a cache = %d, want 503", w.Code)
+	}
+
+	h.SetCachePurger(respcache.NewPurger(store, nil))
+	if w := purge(h, "tag=product-1", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", w.Code)
+	}
+	for _, query := range []string{"", "path=products", "tag=a%20b"} {
+		if w := purge(h, query, "admin"); w.Code != http.StatusBadRequest {
+			t.Errorf("purge ?%s status = %d, want 400", query, w.Code)
+		}
+	}
+
+	w := purge(h, "tag=product-1", "admin")
+	var resp CachePurgeResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("purge by tag = %d %v", w.Code, err)
+	}
+	if resp.Purged != 1 || resp.Propagated {
+		t.Errorf("purge by tag = %+v, want 1 purged, not propagated (no Redis)", resp)
+	}
+
+	w = purge(h, "route=products&path=/products/*", "admin")
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Purged != 1 {
+		t.Errorf("purge by route and path = %d %+v, want 1 purged", w.Code, resp)
+	}
+	if store.Get("c") == nil {
+		t.Error("entry of another route purged")
+	}
+}
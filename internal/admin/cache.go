@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/respcache"
+)
+
+// CachePurgeResponse is the body of DELETE /admin/cache.
+type CachePurgeResponse struct {
+	// Purged is the number of entries dropped on this instance
+	Purged int `json:"purged"`
+
+	// Propagated is false when the purge couldn't be published to the
+	// other instances (no Redis)
+	Propagated bool `json:"propagated"`
+}
+
+// SetCachePurger enables /admin/cache.
+func (h *Handler) SetCachePurger(p *respcache.Purger) {
+	h.cachePurger = p
+}
+
+// PurgeCache handles DELETE /admin/cache?route=...&path=...&tag=... It is
+// only served with ADMIN_TOKEN set.
+//
+// Drops the cached responses of a route (by ID), of a path (or every path
+// under a prefix ending in "*"), or tagged with a surrogate key; given
+// together, entries must match all of them. The purge is published so
+// every instance drops its copies.
+func (h *Handler) PurgeCache(w http.ResponseWriter, r *http.Request) {
+	if h.cachePurger == nil {
+		writeError(w, http.StatusServiceUnavailable, "the response cache is disabled (set CACHE_MAX_BYTES)")
+		return
+	}
+
+	query := r.URL.Query()
+	purge := respcache.Purge{
+		RouteID: query.Get("route"),
+		Path:    query.Get("path"),
+		Tag:     query.Get("tag"),
+	}
+	if err := purge.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	purged, propagated, err := h.cachePurger.Purge(r.Context(), purge)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "purged on this instance only: "+err.Error())
+		return
+	}
+
+	log.Info().
+		Str("component", "admin").
+		Str("route_id", purge.RouteID).
+		Str("path", purge.Path).
+		Str("tag", purge.Tag).
+		Int("purged", purged).
+		Bool("propagated", propagated).
+		Msg("Response cache purged")
+
+	writeJSON(w, http.StatusOK, CachePurgeResponse{Purged: purged, Propagated: propagated})
+}
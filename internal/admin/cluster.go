@@ -0,0 +1,31 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/saidutt46/switchboard-gateway/internal/cluster"
+)
+
+// SetCluster enables /admin/cluster.
+func (h *Handler) SetCluster(m *cluster.Membership) {
+	h.cluster = m
+}
+
+// Cluster handles GET /admin/cluster.
+//
+// Lists the live gateway instances (hostname, version, config generation,
+// uptime) and flags config drift and version skew across them.
+func (h *Handler) Cluster(w http.ResponseWriter, r *http.Request) {
+	if h.cluster == nil {
+		writeError(w, http.StatusServiceUnavailable, "cluster membership is not enabled (set CLUSTER_ENABLED)")
+		return
+	}
+
+	status, err := h.cluster.Status(r.Context())
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,185 @@
+package admin
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/loadbalancer"
+	"github.com/saidutt46/switchboard-gateway/internal/ratelimit"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+)
+
+// uiFiles holds the dashboard page and its assets.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// DashboardResponse is the body of /admin/dashboard.
+type DashboardResponse struct {
+	GeneratedAt       time.Time                 `json:"generated_at"`
+	Version           string                    `json:"version"`
+	Routes            []DashboardRoute          `json:"routes"`
+	Services          []DashboardService        `json:"services"`
+	RouteConflicts    []router.Conflict         `json:"route_conflicts"`
+	OrphanedRoutes    []router.Orphan           `json:"orphaned_routes"`
+	RecentErrors      []RecentError             `json:"recent_errors"`
+	RateLimited       []RateLimitedRoute        `json:"rate_limited"`
+	RateLimitKeyspace *ratelimit.KeyspaceReport `json:"rate_limit_keyspace,omitempty"`
+}
+
+// DashboardRoute is a loaded route.
+type DashboardRoute struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name,omitempty"`
+	Workspace     string   `json:"workspace"`
+	Hosts         []string `json:"hosts,omitempty"`
+	Paths         []string `json:"paths"`
+	Methods       []string `json:"methods,omitempty"`
+	ServiceID     string   `json:"service_id"`
+	ServiceName   string   `json:"service_name,omitempty"`
+	PriorityClass string   `json:"priority_class"`
+}
+
+// DashboardService is a loaded service and the health of its targets.
+type DashboardService struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Workspace string            `json:"workspace"`
+	Enabled   bool              `json:"enabled"`
+	Upstream  string            `json:"upstream"`
+	Balancer  string            `json:"balancer,omitempty"` // empty = no targets, proxied to Upstream
+	Targets   []DashboardTarget `json:"targets,omitempty"`
+}
+
+// DashboardTarget is a load-balanced target of a service.
+type DashboardTarget struct {
+	Address  string `json:"address"`
+	Weight   int    `json:"weight"`
+	InFlight int64  `json:"in_flight"`
+	Healthy  bool   `json:"healthy"` // false while ejected by outlier detection
+}
+
+// RateLimitedRoute counts a route's 429 responses since startup.
+type RateLimitedRoute struct {
+	RouteID   string `json:"route_id"`
+	RouteName string `json:"route_name,omitempty"`
+	Count     int64  `json:"count"`
+}
+
+// SetDashboard enables the target health, recent error and rate limit
+// sections of /admin/dashboard. Any of them may be nil.
+func (h *Handler) SetDashboard(balancers *loadbalancer.Manager, keyspace *ratelimit.KeyspaceMonitor, activity *Activity) {
+	h.balancers = balancers
+	h.keyspace = keyspace
+	h.activity = activity
+}
+
+// Dashboard handles GET /admin/dashboard, the data behind the /admin/ui
+// status page.
+func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
+	resp := DashboardResponse{
+		GeneratedAt:       time.Now().UTC(),
+		Version:           h.config.Version,
+		Routes:            []DashboardRoute{},
+		Services:          []DashboardService{},
+		RouteConflicts:    h.router.Conflicts(),
+		OrphanedRoutes:    h.router.Orphans(),
+		RecentErrors:      h.activity.RecentErrors(),
+		RateLimited:       []RateLimitedRoute{},
+		RateLimitKeyspace: h.keyspace.Report(),
+	}
+	if resp.RecentErrors == nil {
+		resp.RecentErrors = []RecentError{}
+	}
+
+	routeNames := make(map[string]string)
+	serviceIDs := make(map[string]bool)
+	for _, route := range h.router.Routes() {
+		dr := DashboardRoute{
+			ID:            route.ID,
+			Name:          route.Name.String,
+			Workspace:     database.WorkspaceName(route.Workspace),
+			Hosts:         route.Hosts,
+			Paths:         route.Paths,
+			Methods:       route.Methods,
+			ServiceID:     route.ServiceID,
+			PriorityClass: route.PriorityClass,
+		}
+		if service, ok := h.router.Service(route.ServiceID); ok {
+			dr.ServiceName = service.Name
+		}
+		resp.Routes = append(resp.Routes, dr)
+		routeNames[route.ID] = route.Name.String
+		serviceIDs[route.ServiceID] = true
+	}
+
+	for id := range serviceIDs {
+		service, ok := h.router.Service(id)
+		if !ok {
+			continue
+		}
+		resp.Services = append(resp.Services, h.dashboardService(service))
+	}
+	sort.Slice(resp.Services, func(i, j int) bool {
+		return resp.Services[i].Name < resp.Services[j].Name
+	})
+
+	for id, count := range h.activity.RateLimited() {
+		resp.RateLimited = append(resp.RateLimited, RateLimitedRoute{RouteID: id, RouteName: routeNames[id], Count: count})
+	}
+	sort.Slice(resp.RateLimited, func(i, j int) bool {
+		return resp.RateLimited[i].Count > resp.RateLimited[j].Count
+	})
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// dashboardService describes a service and its balancer's targets.
+func (h *Handler) dashboardService(service *database.Service) DashboardService {
+	protocol := service.Protocol
+	if protocol == "" {
+		protocol = "http"
+	}
+
+	ds := DashboardService{
+		ID:        service.ID,
+		Name:      service.Name,
+		Workspace: database.WorkspaceName(service.Workspace),
+		Enabled:   service.Enabled,
+		Upstream:  fmt.Sprintf("%s://%s:%d", protocol, service.Host, service.Port),
+	}
+
+	if h.balancers == nil {
+		return ds
+	}
+	lb, ok := h.balancers.Get(service.ID)
+	if !ok {
+		return ds
+	}
+
+	ds.Balancer = lb.Type()
+	for _, t := range lb.Targets() {
+		ds.Targets = append(ds.Targets, DashboardTarget{
+			Address:  t.Address,
+			Weight:   t.Weight,
+			InFlight: t.InFlight(),
+			Healthy:  t.Available(),
+		})
+	}
+	return ds
+}
+
+// uiHandler serves the embedded dashboard page under /admin/ui/.
+func uiHandler() http.Handler {
+	assets, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err) // the embedded directory is part of the binary
+	}
+	return http.StripPrefix("/admin/ui/", http.FileServer(http.FS(assets)))
+}
@@ -0,0 +1,32 @@
+package admin
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+)
+
+// Importing net/http/pprof and expvar also registers /debug/ handlers on
+// http.DefaultServeMux. The gateway never serves that mux, so they are only
+// reachable through the token-checked routes below.
+
+// registerDebug adds the profiling and runtime debug endpoints.
+func (h *Handler) registerDebug() {
+	// pprof.Index resolves profile names under a fixed /debug/pprof/ prefix
+	h.mux.Handle("/admin/debug/pprof/", http.StripPrefix("/admin", http.HandlerFunc(pprof.Index)))
+	h.mux.HandleFunc("/admin/debug/pprof/cmdline", pprof.Cmdline)
+	h.mux.HandleFunc("/admin/debug/pprof/profile", pprof.Profile)
+	h.mux.HandleFunc("/admin/debug/pprof/symbol", pprof.Symbol)
+	h.mux.HandleFunc("/admin/debug/pprof/trace", pprof.Trace)
+	h.mux.Handle("GET /admin/debug/vars", expvar.Handler())
+	h.mux.HandleFunc("GET /admin/debug/goroutines", h.Goroutines)
+}
+
+// Goroutines handles GET /admin/debug/goroutines: a plain-text stack dump
+// of every goroutine, as printed on an unrecovered panic.
+func (h *Handler) Goroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+}
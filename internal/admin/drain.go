@@ -0,0 +1,56 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Drainer starts the gateway's graceful drain.
+type Drainer interface {
+	// Drain fails readiness and blocks until the drain delay has passed
+	// or ctx is done. Later calls wait for the same delay.
+	Drain(ctx context.Context) error
+}
+
+// DrainResponse is the body of /admin/drain.
+type DrainResponse struct {
+	Status string `json:"status"`
+	Waited string `json:"waited"`
+}
+
+// SetDrainer enables /admin/drain.
+func (h *Handler) SetDrainer(d Drainer) {
+	h.drainer = d
+}
+
+// Drain handles POST /admin/drain, the pre-stop hook for rolling deploys.
+// It is only served with ADMIN_TOKEN set.
+//
+// The gateway stops reporting ready and the response is held until
+// SHUTDOWN_DRAIN_DELAY has passed, so load balancers see the pod leave
+// before it receives SIGTERM.
+func (h *Handler) Drain(w http.ResponseWriter, r *http.Request) {
+	if h.drainer == nil {
+		writeError(w, http.StatusServiceUnavailable, "draining is not available")
+		return
+	}
+
+	log.Info().
+		Str("component", "admin").
+		Str("remote_addr", r.RemoteAddr).
+		Msg("Drain requested by pre-stop hook")
+
+	start := time.Now()
+	if err := h.drainer.Drain(r.Context()); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DrainResponse{
+		Status: "drained",
+		Waited: time.Since(start).Round(time.Millisecond).String(),
+	})
+}
@@ -0,0 +1,178 @@
+package admin
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// redactedValue replaces secret config values in admin responses.
+const redactedValue = "[REDACTED]"
+
+// secretKeyMarkers flag config keys whose values are credentials.
+// Matched case-insensitively against the key name.
+var secretKeyMarkers = []string{"secret", "password", "token", "credential", "private_key", "api_key", "signing_key"}
+
+// RoutePluginsResponse is the body of GET /admin/routes/{id}/plugins.
+type RoutePluginsResponse struct {
+	RouteID        string           `json:"route_id"`
+	RouteName      string           `json:"route_name,omitempty"`
+	ServiceID      string           `json:"service_id"`
+	ServiceName    string           `json:"service_name"`
+	ServiceEnabled bool             `json:"service_enabled"`
+	Plugins        []ResolvedPlugin `json:"plugins"`
+	AfterResponse  []string         `json:"after_response_order"`
+	Counts         map[string]int   `json:"counts"`
+}
+
+// ResolvedPlugin is one plugin in a route's effective chain.
+type ResolvedPlugin struct {
+	Order     int                    `json:"order"`
+	Name      string                 `json:"name"`
+	ID        string                 `json:"id,omitempty"`
+	Scope     string                 `json:"scope"`
+	Inherited bool                   `json:"inherited"` // attached to the service or globally, not the route
+	Priority  int                    `json:"priority"`
+	Critical  bool                   `json:"critical"`
+	Mode      string                 `json:"mode"`
+	Config    map[string]interface{} `json:"config"`
+}
+
+// RoutePlugins handles GET /admin/routes/{id}/plugins.
+//
+// Returns the plugin chain the gateway would run for a route: route,
+// service and global plugins merged and sorted by priority, exactly as
+// the chain builder resolves them. Config values that look like secrets
+// (keys such as "secret" or "api_key", URL passwords) are redacted.
+//
+// Only enabled routes are loaded by the router, so disabled or unknown
+// routes return 404.
+func (h *Handler) RoutePlugins(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	route, service, chain, ok := h.router.ChainForRoute(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "route not found or not enabled: "+id)
+		return
+	}
+
+	resp := RoutePluginsResponse{
+		RouteID:        route.ID,
+		RouteName:      route.Name.String,
+		ServiceID:      service.ID,
+		ServiceName:    service.Name,
+		ServiceEnabled: service.Enabled,
+		Plugins:        make([]ResolvedPlugin, 0, chain.Count()),
+		AfterResponse:  make([]string, 0, chain.Count()),
+		Counts:         make(map[string]int),
+	}
+
+	instances := chain.GetPlugins()
+	for i, instance := range instances {
+		resp.Plugins = append(resp.Plugins, resolvePlugin(i+1, instance))
+		resp.Counts[instance.Scope]++
+	}
+
+	// AfterResponse runs the chain in reverse
+	for i := len(instances) - 1; i >= 0; i-- {
+		resp.AfterResponse = append(resp.AfterResponse, instances[i].Plugin.Name())
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// resolvePlugin converts a chain entry to the API shape.
+func resolvePlugin(order int, instance plugin.PluginInstance) ResolvedPlugin {
+	resolved := ResolvedPlugin{
+		Order:     order,
+		Name:      instance.Plugin.Name(),
+		Scope:     instance.Scope,
+		Inherited: instance.Scope != database.PluginScopeRoute,
+		Priority:  instance.Priority,
+		Critical:  instance.Critical,
+		Mode:      instance.Mode,
+		Config:    map[string]interface{}{},
+	}
+
+	if instance.Config != nil {
+		resolved.ID = instance.Config.ID
+		if instance.Config.Config != nil {
+			resolved.Config = redactConfig(instance.Config.Config)
+		}
+	}
+
+	return resolved
+}
+
+// redactConfig returns a deep copy of a plugin config with secrets removed.
+func redactConfig(config map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(config))
+
+	keys := make([]string, 0, len(config))
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if isSecretKey(k) {
+			out[k] = redactedValue
+			continue
+		}
+		out[k] = redactValue(config[k])
+	}
+
+	return out
+}
+
+// redactValue redacts nested maps, lists and URL credentials.
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return redactConfig(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, child := range val {
+			out[i] = redactValue(child)
+		}
+		return out
+	case string:
+		return redactURLPassword(val)
+	default:
+		return v
+	}
+}
+
+// isSecretKey reports whether a config key holds a credential.
+func isSecretKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, marker := range secretKeyMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	// Bare "key"/"keys" (e.g. HMAC or API key lists), but not "key_prefix"
+	return lower == "key" || lower == "keys"
+}
+
+// redactURLPassword hides the password in URLs like redis://:pw@host.
+func redactURLPassword(s string) string {
+	if !strings.Contains(s, "://") || !strings.Contains(s, "@") {
+		return s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, hasPassword := u.User.Password(); !hasPassword {
+		return s
+	}
+
+	u.User = url.UserPassword(u.User.Username(), redactedValue)
+	return strings.Replace(u.String(), url.QueryEscape(redactedValue), redactedValue, 1)
+}
@@ -0,0 +1,149 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+)
+
+// RouterTestRequest is the body of POST /admin/router/test.
+type RouterTestRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"` // may include a query string
+	Host    string            `json:"host"`
+	Headers map[string]string `json:"headers"`
+}
+
+// RouterTestResponse describes how the router would handle a request.
+type RouterTestResponse struct {
+	Matched    bool                   `json:"matched"`
+	Route      map[string]interface{} `json:"route,omitempty"`
+	Service    map[string]interface{} `json:"service,omitempty"`
+	Params     map[string]string      `json:"params,omitempty"`
+	Plugins    []PluginStep           `json:"plugins,omitempty"`
+	Candidates []CandidateInfo        `json:"candidates"`
+}
+
+// PluginStep is one plugin in the chain, in BeforeRequest order
+// (AfterResponse runs in reverse).
+type PluginStep struct {
+	Name     string `json:"name"`
+	ID       string `json:"id,omitempty"`
+	Scope    string `json:"scope"`
+	Priority int    `json:"priority"`
+	Critical bool   `json:"critical"`
+	Mode     string `json:"mode"`
+}
+
+// CandidateInfo is a route whose path matched, and why it was rejected.
+type CandidateInfo struct {
+	RouteID   string            `json:"route_id"`
+	RouteName string            `json:"route_name,omitempty"`
+	Params    map[string]string `json:"params,omitempty"`
+	Selected  bool              `json:"selected"`
+	Reason    string            `json:"reason,omitempty"`
+}
+
+// RouterTest handles POST /admin/router/test.
+//
+// Runs route matching for a described request without proxying it and
+// returns the selected route/service, extracted params, the plugin chain
+// that would run, and every path-matching route with its rejection reason.
+func (h *Handler) RouterTest(w http.ResponseWriter, r *http.Request) {
+	var body RouterTestRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	if body.Method == "" {
+		body.Method = http.MethodGet
+	}
+	if !strings.HasPrefix(body.Path, "/") {
+		writeError(w, http.StatusBadRequest, "path must start with /")
+		return
+	}
+
+	u, err := url.ParseRequestURI(body.Path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid path: "+err.Error())
+		return
+	}
+
+	req := &http.Request{
+		Method: strings.ToUpper(body.Method),
+		URL:    u,
+		Host:   body.Host,
+		Header: make(http.Header, len(body.Headers)),
+	}
+	for name, value := range body.Headers {
+		req.Header.Set(name, value)
+	}
+	if req.Host == "" {
+		req.Host = req.Header.Get("Host")
+	}
+
+	result, candidates := h.router.Explain(req)
+
+	writeJSON(w, http.StatusOK, buildRouterTestResponse(result, candidates))
+}
+
+// buildRouterTestResponse converts an Explain result to the API shape.
+func buildRouterTestResponse(result *router.MatchResult, candidates []router.Candidate) RouterTestResponse {
+	resp := RouterTestResponse{Candidates: make([]CandidateInfo, 0, len(candidates))}
+
+	for _, c := range candidates {
+		resp.Candidates = append(resp.Candidates, CandidateInfo{
+			RouteID:   c.Route.ID,
+			RouteName: c.Route.Name.String,
+			Params:    c.Params,
+			Selected:  c.Reason == "",
+			Reason:    c.Reason,
+		})
+	}
+
+	if result == nil {
+		return resp
+	}
+
+	resp.Matched = true
+	resp.Params = result.PathParams
+	resp.Route = map[string]interface{}{
+		"id":             result.Route.ID,
+		"name":           result.Route.Name.String,
+		"paths":          result.Route.Paths,
+		"methods":        result.Route.Methods,
+		"hosts":          result.Route.Hosts,
+		"strip_path":     result.Route.StripPath,
+		"preserve_host":  result.Route.PreserveHost,
+		"priority_class": result.Route.PriorityClass,
+	}
+	resp.Service = map[string]interface{}{
+		"id":                 result.Service.ID,
+		"name":               result.Service.Name,
+		"protocol":           result.Service.Protocol,
+		"host":               result.Service.Host,
+		"port":               result.Service.Port,
+		"path":               result.Service.Path.String,
+		"load_balancer_type": result.Service.LoadBalancerType,
+	}
+
+	for _, instance := range result.Chain.GetPlugins() {
+		step := PluginStep{
+			Name:     instance.Plugin.Name(),
+			Scope:    instance.Scope,
+			Priority: instance.Priority,
+			Critical: instance.Critical,
+			Mode:     instance.Mode,
+		}
+		if instance.Config != nil {
+			step.ID = instance.Config.ID
+		}
+		resp.Plugins = append(resp.Plugins, step)
+	}
+
+	return resp
+}
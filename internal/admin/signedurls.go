@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/signedurl"
+)
+
+// SignedURLRequest is the body of POST /admin/signed-urls.
+type SignedURLRequest struct {
+	Path       string `json:"path"`
+	TTL        string `json:"ttl"`                   // default "15m"
+	ConsumerID string `json:"consumer_id,omitempty"` // binds the link to a consumer
+}
+
+// SignedURLResponse is a signed link.
+type SignedURLResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SetURLSigner enables /admin/signed-urls.
+func (h *Handler) SetURLSigner(s *signedurl.Signer) {
+	h.urlSigner = s
+}
+
+// SignedURL handles POST /admin/signed-urls. It is only served with
+// ADMIN_TOKEN set: anyone who can mint links can pass the signed-url
+// plugin.
+//
+// Returns path with the query parameters that make it valid for ttl on
+// routes with the signed-url plugin. Backends holding SIGNED_URL_SECRETS
+// can sign links themselves instead.
+func (h *Handler) SignedURL(w http.ResponseWriter, r *http.Request) {
+	if h.urlSigner == nil {
+		writeError(w, http.StatusServiceUnavailable, "URL signing is not configured (set SIGNED_URL_SECRETS)")
+		return
+	}
+
+	var body SignedURLRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	if !strings.HasPrefix(body.Path, "/") || strings.ContainsAny(body.Path, "?#") {
+		writeError(w, http.StatusBadRequest, "path must start with / and have no query or fragment")
+		return
+	}
+
+	ttl := 15 * time.Minute
+	if body.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(body.TTL); err != nil || ttl <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid ttl")
+			return
+		}
+	}
+	if ttl > h.urlSigner.MaxTTL() {
+		writeError(w, http.StatusBadRequest, "ttl exceeds SIGNED_URL_MAX_TTL ("+h.urlSigner.MaxTTL().String()+")")
+		return
+	}
+
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	query, err := h.urlSigner.Sign(body.Path, expires, body.ConsumerID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	u := url.URL{Path: body.Path, RawQuery: query.Encode()}
+	writeJSON(w, http.StatusOK, SignedURLResponse{URL: u.String(), ExpiresAt: expires.UTC()})
+}
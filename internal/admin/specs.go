@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/openapi"
+)
+
+// Specs handles GET /admin/specs.
+//
+// Returns a single OpenAPI 3 document merged from the specs attached to
+// services, with paths rewritten to gateway paths and limited to
+// operations the router currently accepts. Skipped specs/operations are
+// listed in the x-gateway-warnings extension.
+func (h *Handler) Specs(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	rawSpecs, err := h.repo.GetServiceSpecs(ctx)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("component", "admin").
+			Msg("Failed to load service specs")
+		writeError(w, http.StatusInternalServerError, "failed to load service specs")
+		return
+	}
+
+	routesByService := make(map[string][]*database.Route)
+	for _, route := range h.router.Routes() {
+		routesByService[route.ServiceID] = append(routesByService[route.ServiceID], route)
+	}
+
+	var sources []openapi.Source
+	var warnings []string
+	for serviceID, raw := range rawSpecs {
+		service, ok := h.router.Service(serviceID)
+		if !ok {
+			continue // service not loaded yet (or disabled since the query)
+		}
+
+		var spec openapi.Document
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			warnings = append(warnings, "service "+service.Name+": invalid spec JSON")
+			continue
+		}
+
+		sources = append(sources, openapi.Source{
+			Service: service,
+			Routes:  routesByService[serviceID],
+			Spec:    spec,
+		})
+	}
+
+	doc, mergeWarnings := openapi.Merge(sources, h.router, openapi.Info{
+		Title:   "Switchboard Gateway",
+		Version: h.config.Version,
+	})
+	warnings = append(warnings, mergeWarnings...)
+
+	if len(warnings) > 0 {
+		doc["x-gateway-warnings"] = warnings
+	}
+
+	// Advertise the gateway itself as the server
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.ToLower(proto)
+	}
+	doc["servers"] = []interface{}{
+		map[string]interface{}{"url": scheme + "://" + r.Host},
+	}
+
+	writeJSON(w, http.StatusOK, doc)
+}
@@ -0,0 +1,197 @@
+// Read-only gateway dashboard. Renders /admin/dashboard every few seconds.
+// When ADMIN_TOKEN is set the token is asked for once and kept in
+// sessionStorage for this tab only.
+(function () {
+  "use strict";
+
+  var REFRESH_MS = 5000;
+  var tokenKey = "switchboard-admin-token";
+  var timer = null;
+
+  function $(id) {
+    return document.getElementById(id);
+  }
+
+  // cell builds a table cell holding text (never HTML).
+  function cell(text, className) {
+    var td = document.createElement("td");
+    td.textContent = text === undefined || text === null || text === "" ? "-" : String(text);
+    if (className) {
+      td.className = className;
+    }
+    return td;
+  }
+
+  function fill(tbodyId, rows, emptyText, columns) {
+    var tbody = $(tbodyId);
+    tbody.textContent = "";
+    if (!rows || rows.length === 0) {
+      var tr = document.createElement("tr");
+      var td = cell(emptyText, "muted");
+      td.colSpan = columns;
+      tr.appendChild(td);
+      tbody.appendChild(tr);
+      return;
+    }
+    rows.forEach(function (cells) {
+      var tr = document.createElement("tr");
+      cells.forEach(function (td) {
+        tr.appendChild(td);
+      });
+      tbody.appendChild(tr);
+    });
+  }
+
+  function list(values) {
+    return values && values.length ? values.join(", ") : "";
+  }
+
+  function bytes(n) {
+    if (n >= 1 << 20) {
+      return (n / (1 << 20)).toFixed(1) + " MiB";
+    }
+    if (n >= 1 << 10) {
+      return (n / (1 << 10)).toFixed(1) + " KiB";
+    }
+    return n + " B";
+  }
+
+  function targets(service) {
+    if (!service.targets || service.targets.length === 0) {
+      return cell("", "muted");
+    }
+    var td = document.createElement("td");
+    service.targets.forEach(function (t) {
+      var div = document.createElement("div");
+      div.className = t.healthy ? "ok" : "bad";
+      div.textContent = (t.healthy ? "● " : "○ ejected ") + t.address +
+        " (weight " + t.weight + ", " + t.in_flight + " in flight)";
+      td.appendChild(div);
+    });
+    return td;
+  }
+
+  function render(data) {
+    $("meta").textContent = "version " + data.version + " · updated " +
+      new Date(data.generated_at).toLocaleTimeString();
+
+    $("services-count").textContent = data.services.length;
+    fill("services", data.services.map(function (s) {
+      return [
+        cell(s.name + (s.enabled ? "" : " (disabled)")),
+        cell(s.workspace),
+        cell(s.upstream),
+        cell(s.balancer),
+        targets(s)
+      ];
+    }), "No services", 5);
+
+    $("routes-count").textContent = data.routes.length;
+    fill("routes", data.routes.map(function (r) {
+      return [
+        cell(r.name || r.id),
+        cell(r.workspace),
+        cell(list(r.hosts)),
+        cell(list(r.paths)),
+        cell(list(r.methods)),
+        cell(r.service_name || r.service_id),
+        cell(r.priority_class)
+      ];
+    }), "No routes", 7);
+
+    var conflicts = data.route_conflicts || [];
+    $("conflicts").textContent = conflicts.length ?
+      conflicts.length + " route conflict(s) - see /status for details" : "";
+
+    var orphans = data.orphaned_routes || [];
+    $("orphans").textContent = orphans.length ?
+      orphans.length + " route(s) not served because their service is disabled or missing - see /status for details" : "";
+
+    $("errors-count").textContent = data.recent_errors.length;
+    fill("errors", data.recent_errors.map(function (e) {
+      return [
+        cell(new Date(e.time).toLocaleTimeString()),
+        cell(e.status, "bad"),
+        cell(e.method),
+        cell(e.path),
+        cell(e.route_name || e.route_id),
+        cell(e.latency),
+        cell(e.request_id)
+      ];
+    }), "No server errors since startup", 7);
+
+    fill("rate-limited", data.rate_limited.map(function (r) {
+      return [cell(r.route_name || r.route_id), cell(r.count)];
+    }), "No rate-limited requests since startup", 2);
+
+    var keyspace = data.rate_limit_keyspace;
+    fill("keyspace", keyspace ? keyspace.prefixes.map(function (p) {
+      return [cell(p.prefix), cell(p.keys), cell(bytes(p.memory_bytes))];
+    }) : [], "No keyspace scan yet", 3);
+  }
+
+  function load() {
+    var headers = {};
+    var token = sessionStorage.getItem(tokenKey);
+    if (token) {
+      headers.Authorization = "Bearer " + token;
+    }
+
+    fetch("../dashboard", { headers: headers, cache: "no-store" })
+      .then(function (resp) {
+        if (resp.status === 401) {
+          sessionStorage.removeItem(tokenKey);
+          showLogin(token ? "Invalid token" : "");
+          return null;
+        }
+        if (!resp.ok) {
+          throw new Error("HTTP " + resp.status);
+        }
+        return resp.json();
+      })
+      .then(function (data) {
+        if (!data) {
+          return;
+        }
+        $("login").hidden = true;
+        $("content").hidden = false;
+        render(data);
+        schedule();
+      })
+      .catch(function (err) {
+        $("meta").textContent = "refresh failed: " + err.message;
+        schedule();
+      });
+  }
+
+  function schedule() {
+    clearTimeout(timer);
+    if ($("auto").checked) {
+      timer = setTimeout(load, REFRESH_MS);
+    }
+  }
+
+  function showLogin(message) {
+    clearTimeout(timer);
+    $("content").hidden = true;
+    $("login").hidden = false;
+    $("login-error").textContent = message;
+    $("token").focus();
+  }
+
+  $("login").addEventListener("submit", function (event) {
+    event.preventDefault();
+    sessionStorage.setItem(tokenKey, $("token").value);
+    load();
+  });
+
+  $("auto").addEventListener("change", function () {
+    if ($("auto").checked) {
+      load();
+    } else {
+      clearTimeout(timer);
+    }
+  });
+
+  load();
+})();
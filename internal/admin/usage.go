@@ -0,0 +1,121 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// usageDateLayout is the from/to query format (UTC days).
+const usageDateLayout = "2006-01-02"
+
+// defaultUsageDays is the window returned when from is omitted.
+const defaultUsageDays = 30
+
+// maxUsageDays bounds a single usage query.
+const maxUsageDays = 366
+
+// UsageResponse is the body of GET /admin/consumers/{id}/usage.
+type UsageResponse struct {
+	ConsumerID string     `json:"consumer_id"`
+	From       string     `json:"from"`
+	To         string     `json:"to"`
+	Days       []UsageDay `json:"days"`
+	Totals     UsageTotal `json:"totals"`
+}
+
+// UsageTotal is the usage summed over a period.
+type UsageTotal struct {
+	Requests     int64 `json:"requests"`
+	ClientErrors int64 `json:"client_errors"`
+	ServerErrors int64 `json:"server_errors"`
+	BytesIn      int64 `json:"bytes_in"`
+	BytesOut     int64 `json:"bytes_out"`
+}
+
+// UsageDay is one day of a consumer's usage.
+type UsageDay struct {
+	Day string `json:"day"`
+	UsageTotal
+}
+
+// ConsumerUsage handles GET /admin/consumers/{id}/usage?from=&to=.
+//
+// Returns the consumer's daily rollups between from and to (YYYY-MM-DD,
+// inclusive, UTC). to defaults to today and from to 30 days before to.
+// Days without traffic are omitted. Usage is flushed periodically
+// (USAGE_FLUSH_INTERVAL), so the most recent requests may not be
+// included yet.
+func (h *Handler) ConsumerUsage(w http.ResponseWriter, r *http.Request) {
+	consumerID := r.PathValue("id")
+	query := r.URL.Query()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	to := today
+	if v := query.Get("to"); v != "" {
+		parsed, err := time.Parse(usageDateLayout, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid 'to' date (want YYYY-MM-DD): "+v)
+			return
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -(defaultUsageDays - 1))
+	if v := query.Get("from"); v != "" {
+		parsed, err := time.Parse(usageDateLayout, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid 'from' date (want YYYY-MM-DD): "+v)
+			return
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		writeError(w, http.StatusBadRequest, "'from' must not be after 'to'")
+		return
+	}
+	if to.Sub(from) >= maxUsageDays*24*time.Hour {
+		writeError(w, http.StatusBadRequest, "date range must not exceed 366 days")
+		return
+	}
+
+	rows, err := h.repo.GetConsumerUsage(r.Context(), consumerID, from, to)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("component", "admin").
+			Str("consumer_id", consumerID).
+			Msg("Failed to load consumer usage")
+		writeError(w, http.StatusInternalServerError, "failed to load usage")
+		return
+	}
+
+	resp := UsageResponse{
+		ConsumerID: consumerID,
+		From:       from.Format(usageDateLayout),
+		To:         to.Format(usageDateLayout),
+		Days:       make([]UsageDay, 0, len(rows)),
+	}
+
+	for _, row := range rows {
+		day := UsageTotal{
+			Requests:     row.Requests,
+			ClientErrors: row.ClientErrors,
+			ServerErrors: row.ServerErrors,
+			BytesIn:      row.BytesIn,
+			BytesOut:     row.BytesOut,
+		}
+		resp.Days = append(resp.Days, UsageDay{Day: row.Day.UTC().Format(usageDateLayout), UsageTotal: day})
+
+		resp.Totals.Requests += day.Requests
+		resp.Totals.ClientErrors += day.ClientErrors
+		resp.Totals.ServerErrors += day.ServerErrors
+		resp.Totals.BytesIn += day.BytesIn
+		resp.Totals.BytesOut += day.BytesOut
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
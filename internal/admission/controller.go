@@ -0,0 +1,299 @@
+// Package admission provides priority-aware admission control in front of
+// the proxy.
+//
+// Every route has a priority class (routes.priority_class):
+//   - critical: Business-critical traffic (checkout, auth), shed last
+//   - normal: Default class
+//   - bulk: Batch/export/analytics traffic, queued and shed first
+//
+// The Controller caps the number of requests being proxied concurrently.
+// When the cap is reached, requests wait in a bounded queue and are admitted
+// strictly by priority (critical, then normal, then bulk; FIFO within a
+// class). When the queue is full, a higher-priority arrival evicts the
+// lowest-priority waiter; bulk traffic is therefore shed before anything else.
+package admission
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// Priority classes (matches routes.priority_class).
+const (
+	ClassCritical = "critical"
+	ClassNormal   = "normal"
+	ClassBulk     = "bulk"
+)
+
+// Rejection reasons (used in metrics).
+const (
+	ReasonQueueFull = "queue_full"
+	ReasonTimeout   = "timeout"
+	ReasonEvicted   = "evicted"
+	ReasonCanceled  = "canceled"
+)
+
+var (
+	// ErrRejected is returned when a request is shed.
+	ErrRejected = errors.New("request rejected by admission control")
+)
+
+var (
+	admittedTotal = metrics.NewCounterVec(
+		"gateway_admission_admitted_total",
+		"Requests admitted by admission control, by class and whether they queued.",
+		"class", "queued",
+	)
+	rejectedTotal = metrics.NewCounterVec(
+		"gateway_admission_rejected_total",
+		"Requests shed by admission control, by class and reason.",
+		"class", "reason",
+	)
+	queuedGauge = metrics.NewGaugeVec(
+		"gateway_admission_queued",
+		"Requests currently waiting for admission, by class.",
+		"class",
+	)
+	inFlightGauge = metrics.NewGaugeVec(
+		"gateway_admission_in_flight",
+		"Requests currently admitted and being served.",
+	)
+)
+
+// classes in priority order (highest first).
+var classes = []string{ClassCritical, ClassNormal, ClassBulk}
+
+// Config holds configuration for admission control.
+type Config struct {
+	// MaxConcurrent is the maximum number of requests proxied at once.
+	// 0 disables admission control.
+	MaxConcurrent int
+
+	// MaxQueue is the maximum number of requests waiting for admission.
+	MaxQueue int
+
+	// QueueTimeout is how long a request may wait before being shed.
+	QueueTimeout time.Duration
+}
+
+// waiter is a request waiting for a slot.
+type waiter struct {
+	class string
+	ready chan error // receives nil when admitted, an error when evicted
+	elem  *list.Element
+}
+
+// Controller admits requests by priority under a concurrency limit.
+type Controller struct {
+	config Config
+
+	mu       sync.Mutex
+	inFlight int
+	queued   int
+	queues   map[string]*list.List // class -> FIFO of *waiter
+}
+
+// NewController creates an admission controller.
+//
+// Returns nil if config.MaxConcurrent is 0 (admission control disabled);
+// a nil Controller admits everything.
+func NewController(config Config) *Controller {
+	if config.MaxConcurrent <= 0 {
+		return nil
+	}
+	if config.QueueTimeout <= 0 {
+		config.QueueTimeout = time.Second
+	}
+
+	queues := make(map[string]*list.List, len(classes))
+	for _, class := range classes {
+		queues[class] = list.New()
+	}
+
+	log.Info().
+		Str("component", "admission").
+		Int("max_concurrent", config.MaxConcurrent).
+		Int("max_queue", config.MaxQueue).
+		Dur("queue_timeout", config.QueueTimeout).
+		Msg("Admission controller initialized")
+
+	return &Controller{
+		config: config,
+		queues: queues,
+	}
+}
+
+// NormalizeClass maps unknown or empty classes to ClassNormal.
+func NormalizeClass(class string) string {
+	switch class {
+	case ClassCritical, ClassBulk:
+		return class
+	default:
+		return ClassNormal
+	}
+}
+
+// Acquire waits for a slot for a request of the given class.
+//
+// On success, the returned release function must be called exactly once
+// when the request finishes. On rejection, ErrRejected is returned.
+func (c *Controller) Acquire(ctx context.Context, class string) (func(), error) {
+	if c == nil {
+		return func() {}, nil
+	}
+	class = NormalizeClass(class)
+
+	c.mu.Lock()
+
+	// Fast path: free slot and nobody of equal or higher priority waiting
+	if c.inFlight < c.config.MaxConcurrent && !c.hasWaitersAtOrAbove(class) {
+		c.inFlight++
+		inFlightGauge.Set(float64(c.inFlight))
+		c.mu.Unlock()
+
+		admittedTotal.Inc(class, "false")
+		return c.release, nil
+	}
+
+	// Queue is full: evict a lower-priority waiter, or reject this request
+	if c.queued >= c.config.MaxQueue {
+		victim := c.lowestWaiterBelow(class)
+		if victim == nil {
+			c.mu.Unlock()
+			rejectedTotal.Inc(class, ReasonQueueFull)
+			return nil, ErrRejected
+		}
+		c.remove(victim)
+		victim.ready <- ErrRejected
+		rejectedTotal.Inc(victim.class, ReasonEvicted)
+	}
+
+	w := &waiter{class: class, ready: make(chan error, 1)}
+	w.elem = c.queues[class].PushBack(w)
+	c.queued++
+	queuedGauge.Add(1, class)
+	c.mu.Unlock()
+
+	timer := time.NewTimer(c.config.QueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-w.ready:
+		if err != nil {
+			return nil, err
+		}
+		admittedTotal.Inc(class, "true")
+		return c.release, nil
+
+	case <-timer.C:
+		return c.abandon(w, ReasonTimeout)
+
+	case <-ctx.Done():
+		return c.abandon(w, ReasonCanceled)
+	}
+}
+
+// abandon removes a waiter that gave up. If it was admitted concurrently,
+// the slot is kept and the request proceeds.
+func (c *Controller) abandon(w *waiter, reason string) (func(), error) {
+	c.mu.Lock()
+	if w.elem != nil {
+		c.remove(w)
+		c.mu.Unlock()
+		rejectedTotal.Inc(w.class, reason)
+		return nil, ErrRejected
+	}
+	c.mu.Unlock()
+
+	// Already dispatched (admitted or evicted) - honor that decision
+	if err := <-w.ready; err != nil {
+		return nil, err
+	}
+	admittedTotal.Inc(w.class, "true")
+	return c.release, nil
+}
+
+// release frees a slot and hands it to the highest-priority waiter.
+func (c *Controller) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, class := range classes {
+		q := c.queues[class]
+		if front := q.Front(); front != nil {
+			w := front.Value.(*waiter)
+			c.remove(w)
+			w.ready <- nil // slot is transferred, inFlight unchanged
+			return
+		}
+	}
+
+	c.inFlight--
+	inFlightGauge.Set(float64(c.inFlight))
+}
+
+// remove takes a waiter out of its queue. Caller must hold c.mu.
+func (c *Controller) remove(w *waiter) {
+	c.queues[w.class].Remove(w.elem)
+	w.elem = nil
+	c.queued--
+	queuedGauge.Add(-1, w.class)
+}
+
+// hasWaitersAtOrAbove reports whether any request of equal or higher
+// priority than class is waiting. Caller must hold c.mu.
+func (c *Controller) hasWaitersAtOrAbove(class string) bool {
+	for _, cl := range classes {
+		if c.queues[cl].Len() > 0 {
+			return true
+		}
+		if cl == class {
+			break
+		}
+	}
+	return false
+}
+
+// lowestWaiterBelow returns the most recently queued waiter of the lowest
+// class strictly below class, or nil. Caller must hold c.mu.
+func (c *Controller) lowestWaiterBelow(class string) *waiter {
+	for i := len(classes) - 1; i >= 0; i-- {
+		if classes[i] == class {
+			return nil
+		}
+		if back := c.queues[classes[i]].Back(); back != nil {
+			return back.Value.(*waiter)
+		}
+	}
+	return nil
+}
+
+// Stats returns current admission statistics.
+func (c *Controller) Stats() map[string]interface{} {
+	if c == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	queued := make(map[string]int, len(classes))
+	for _, class := range classes {
+		queued[class] = c.queues[class].Len()
+	}
+
+	return map[string]interface{}{
+		"enabled":        true,
+		"max_concurrent": c.config.MaxConcurrent,
+		"max_queue":      c.config.MaxQueue,
+		"in_flight":      c.inFlight,
+		"queued":         queued,
+	}
+}
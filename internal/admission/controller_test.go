@@ -0,0 +1,175 @@
+package admission
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestController_DisabledAdmitsEverything(t *testing.T) {
+	c := NewController(Config{MaxConcurrent: 0})
+
+	for i := 0; i < 100; i++ {
+		release, err := c.Acquire(context.Background(), ClassBulk)
+		if err != nil {
+			t.Fatalf("Acquire() error = %v, want nil when disabled", err)
+		}
+		release()
+	}
+}
+
+func TestController_AdmitsUpToLimit(t *testing.T) {
+	c := NewController(Config{MaxConcurrent: 2, MaxQueue: 0, QueueTimeout: 50 * time.Millisecond})
+
+	r1, err := c.Acquire(context.Background(), ClassNormal)
+	if err != nil {
+		t.Fatalf("first Acquire() error = %v", err)
+	}
+	r2, err := c.Acquire(context.Background(), ClassNormal)
+	if err != nil {
+		t.Fatalf("second Acquire() error = %v", err)
+	}
+
+	if _, err := c.Acquire(context.Background(), ClassNormal); err != ErrRejected {
+		t.Errorf("third Acquire() error = %v, want ErrRejected", err)
+	}
+
+	r1()
+	r3, err := c.Acquire(context.Background(), ClassNormal)
+	if err != nil {
+		t.Errorf("Acquire() after release error = %v", err)
+	}
+	r2()
+	r3()
+}
+
+func TestController_PriorityOrder(t *testing.T) {
+	c := NewController(Config{MaxConcurrent: 1, MaxQueue: 10, QueueTimeout: time.Second})
+
+	hold, _ := c.Acquire(context.Background(), ClassNormal)
+
+	order := make(chan string, 3)
+	start := func(class string) {
+		go func() {
+			release, err := c.Acquire(context.Background(), class)
+			if err != nil {
+				order <- "rejected:" + class
+				return
+			}
+			order <- class
+			release()
+		}()
+	}
+
+	// Queue bulk first, then normal, then critical
+	start(ClassBulk)
+	waitQueued(t, c, 1)
+	start(ClassNormal)
+	waitQueued(t, c, 2)
+	start(ClassCritical)
+	waitQueued(t, c, 3)
+
+	hold()
+
+	want := []string{ClassCritical, ClassNormal, ClassBulk}
+	for i, class := range want {
+		select {
+		case got := <-order:
+			if got != class {
+				t.Errorf("admission %d = %s, want %s", i, got, class)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for admission %d", i)
+		}
+	}
+}
+
+func TestController_EvictsBulkWhenQueueFull(t *testing.T) {
+	c := NewController(Config{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: time.Second})
+
+	hold, _ := c.Acquire(context.Background(), ClassNormal)
+
+	bulkResult := make(chan error, 1)
+	go func() {
+		release, err := c.Acquire(context.Background(), ClassBulk)
+		if err == nil {
+			release()
+		}
+		bulkResult <- err
+	}()
+	waitQueued(t, c, 1)
+
+	criticalResult := make(chan error, 1)
+	go func() {
+		release, err := c.Acquire(context.Background(), ClassCritical)
+		if err == nil {
+			release()
+		}
+		criticalResult <- err
+	}()
+
+	// Bulk waiter is evicted to make room for critical
+	select {
+	case err := <-bulkResult:
+		if err != ErrRejected {
+			t.Errorf("bulk Acquire() error = %v, want ErrRejected", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("bulk waiter was not evicted")
+	}
+
+	hold()
+
+	if err := <-criticalResult; err != nil {
+		t.Errorf("critical Acquire() error = %v, want nil", err)
+	}
+}
+
+func TestController_QueueTimeout(t *testing.T) {
+	c := NewController(Config{MaxConcurrent: 1, MaxQueue: 5, QueueTimeout: 20 * time.Millisecond})
+
+	hold, _ := c.Acquire(context.Background(), ClassNormal)
+	defer hold()
+
+	if _, err := c.Acquire(context.Background(), ClassBulk); err != ErrRejected {
+		t.Errorf("Acquire() error = %v, want ErrRejected after timeout", err)
+	}
+
+	stats := c.Stats()
+	if queued := stats["queued"].(map[string]int)[ClassBulk]; queued != 0 {
+		t.Errorf("queued bulk = %d, want 0 after timeout", queued)
+	}
+}
+
+func TestNormalizeClass(t *testing.T) {
+	tests := map[string]string{
+		"critical": ClassCritical,
+		"bulk":     ClassBulk,
+		"normal":   ClassNormal,
+		"":         ClassNormal,
+		"unknown":  ClassNormal,
+	}
+
+	for in, want := range tests {
+		if got := NormalizeClass(in); got != want {
+			t.Errorf("NormalizeClass(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// waitQueued waits until n requests are queued.
+func waitQueued(t *testing.T, c *Controller, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		queued := c.queued
+		c.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d queued requests", n)
+}
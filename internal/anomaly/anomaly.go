@@ -0,0 +1,307 @@
+// Package anomaly flags traffic that deviates sharply from a source's own
+// history, as an early signal of abuse (credential stuffing, scraping,
+// path scanning).
+//
+// Every request is recorded against its source: the consumer when an auth
+// plugin identified one, the client IP otherwise. Each window the
+// detector compares a source's request count with an EWMA baseline of its
+// previous windows and reports:
+//   - spike: at least MinRequests requests and more than SpikeFactor times
+//     the baseline (only once WarmupWindows windows have been seen)
+//   - scan: 404s on at least ScanPaths distinct paths in one window
+//
+// Anomalies are logged, counted in gateway_anomalies_total, published as
+// anomaly.traffic_spike / anomaly.path_scan notify events and passed to
+// OnAnomaly hooks (e.g. to feed a ban list). A source reports each kind at
+// most once per Cooldown.
+//
+// State is per gateway instance and in memory: a restart starts every
+// baseline over.
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/notify"
+)
+
+// Anomaly kinds.
+const (
+	KindSpike = "spike"
+	KindScan  = "scan"
+)
+
+var (
+	anomaliesTotal = metrics.NewCounterVec(
+		"gateway_anomalies_total",
+		"Traffic anomalies detected, by kind (spike, scan).",
+		"kind",
+	)
+	sourcesTracked = metrics.NewGaugeVec(
+		"gateway_anomaly_sources_tracked",
+		"Consumers and client IPs with a traffic baseline.",
+	)
+)
+
+// Config holds anomaly detection settings.
+type Config struct {
+	// Decay is the weight of the latest window in the baseline EWMA (0-1)
+	Decay float64
+
+	// SpikeFactor reports windows above this multiple of the baseline
+	SpikeFactor float64
+
+	// MinRequests is the fewest requests in a window reported as a spike
+	MinRequests int64
+
+	// WarmupWindows is how many windows build a baseline before spikes
+	// are reported
+	WarmupWindows int
+
+	// ScanPaths reports sources with 404s on this many distinct paths in
+	// one window (0 = disabled)
+	ScanPaths int
+
+	// Cooldown is the least time between reports of one kind for a source
+	Cooldown time.Duration
+
+	// MaxSources caps the tracked sources; new ones beyond it are ignored
+	// until idle sources are forgotten
+	MaxSources int
+}
+
+// Anomaly is a detected deviation.
+type Anomaly struct {
+	// Kind is KindSpike or KindScan
+	Kind string
+
+	// Source is "consumer:<id>" or "ip:<address>"
+	Source string
+
+	// Requests is the source's request count in the window
+	Requests int64
+
+	// Baseline is the source's EWMA requests per window before it
+	Baseline float64
+
+	// NotFoundPaths is the number of distinct paths that returned 404
+	NotFoundPaths int
+
+	// Window is the evaluation interval
+	Window time.Duration
+
+	// At is when the window closed
+	At time.Time
+}
+
+// source is the state of one consumer or client IP.
+type source struct {
+	requests int64
+	notFound map[string]struct{} // distinct 404 paths, at most ScanPaths
+	baseline float64
+	windows  int
+	reported map[string]time.Time // kind -> last report
+}
+
+// Detector tracks per-source traffic baselines. A nil Detector records
+// nothing.
+type Detector struct {
+	config   Config
+	notifier *notify.Dispatcher
+
+	mu      sync.Mutex
+	sources map[string]*source
+	hooks   []func(Anomaly)
+}
+
+// NewDetector creates a detector.
+func NewDetector(config Config) *Detector {
+	log.Info().
+		Str("component", "anomaly").
+		Float64("spike_factor", config.SpikeFactor).
+		Int64("min_requests", config.MinRequests).
+		Int("scan_paths", config.ScanPaths).
+		Msg("Anomaly detector initialized")
+
+	return &Detector{config: config, sources: make(map[string]*source)}
+}
+
+// SetNotifier sends anomaly events to n. Must be called before Run.
+func (d *Detector) SetNotifier(n *notify.Dispatcher) {
+	if d == nil {
+		return
+	}
+	d.notifier = n
+}
+
+// OnAnomaly registers fn to be called with every reported anomaly. Hooks
+// run on the detector's goroutine and must not block.
+func (d *Detector) OnAnomaly(fn func(Anomaly)) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.hooks = append(d.hooks, fn)
+	d.mu.Unlock()
+}
+
+// Record counts a completed request. consumerID identifies the source
+// when set, clientIP otherwise.
+func (d *Detector) Record(consumerID, clientIP, path string, status int) {
+	if d == nil {
+		return
+	}
+
+	key := ""
+	switch {
+	case consumerID != "":
+		key = "consumer:" + consumerID
+	case clientIP != "":
+		key = "ip:" + clientIP
+	default:
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.sources[key]
+	if !ok {
+		if d.config.MaxSources > 0 && len(d.sources) >= d.config.MaxSources {
+			return
+		}
+		s = &source{}
+		d.sources[key] = s
+	}
+	s.requests++
+
+	if status == 404 && d.config.ScanPaths > 0 && len(s.notFound) < d.config.ScanPaths {
+		if s.notFound == nil {
+			s.notFound = make(map[string]struct{})
+		}
+		s.notFound[path] = struct{}{}
+	}
+}
+
+// Run closes a window every interval until ctx is done.
+func (d *Detector) Run(ctx context.Context, interval time.Duration) {
+	if d == nil || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.evaluate(now, interval)
+		}
+	}
+}
+
+// evaluate closes the current window: it reports anomalies, folds the
+// window into the baselines and forgets idle sources.
+func (d *Detector) evaluate(now time.Time, window time.Duration) []Anomaly {
+	var found []Anomaly
+
+	d.mu.Lock()
+	for key, s := range d.sources {
+		if d.config.ScanPaths > 0 && len(s.notFound) >= d.config.ScanPaths && d.due(s, KindScan, now) {
+			found = append(found, Anomaly{
+				Kind: KindScan, Source: key, Requests: s.requests, Baseline: s.baseline,
+				NotFoundPaths: len(s.notFound), Window: window, At: now,
+			})
+		}
+		if s.windows >= d.config.WarmupWindows && s.requests >= d.config.MinRequests &&
+			float64(s.requests) > d.config.SpikeFactor*max(s.baseline, 1) && d.due(s, KindSpike, now) {
+			found = append(found, Anomaly{
+				Kind: KindSpike, Source: key, Requests: s.requests, Baseline: s.baseline,
+				NotFoundPaths: len(s.notFound), Window: window, At: now,
+			})
+		}
+
+		if s.windows == 0 {
+			s.baseline = float64(s.requests)
+		} else {
+			s.baseline = d.config.Decay*float64(s.requests) + (1-d.config.Decay)*s.baseline
+		}
+		s.windows++
+		s.requests = 0
+		s.notFound = nil
+
+		// Idle sources decay to nothing; forget them
+		if s.baseline < 0.5 && len(s.reported) == 0 {
+			delete(d.sources, key)
+		}
+		for kind, at := range s.reported {
+			if now.Sub(at) >= d.config.Cooldown {
+				delete(s.reported, kind)
+			}
+		}
+	}
+	sourcesTracked.Set(float64(len(d.sources)))
+	hooks := d.hooks
+	d.mu.Unlock()
+
+	for _, a := range found {
+		d.report(a, hooks)
+	}
+	return found
+}
+
+// due reports whether kind may be reported for s, and records it.
+func (d *Detector) due(s *source, kind string, now time.Time) bool {
+	if at, ok := s.reported[kind]; ok && now.Sub(at) < d.config.Cooldown {
+		return false
+	}
+	if s.reported == nil {
+		s.reported = make(map[string]time.Time)
+	}
+	s.reported[kind] = now
+	return true
+}
+
+// report logs and publishes an anomaly and runs the hooks.
+func (d *Detector) report(a Anomaly, hooks []func(Anomaly)) {
+	anomaliesTotal.Inc(a.Kind)
+
+	log.Warn().
+		Str("component", "anomaly").
+		Str("kind", a.Kind).
+		Str("source", a.Source).
+		Int64("requests", a.Requests).
+		Float64("baseline", a.Baseline).
+		Int("not_found_paths", a.NotFoundPaths).
+		Dur("window", a.Window).
+		Msg("Traffic anomaly detected")
+
+	event := notify.Event{
+		Type:     notify.EventAnomalySpike,
+		Severity: notify.SeverityWarning,
+		Message:  fmt.Sprintf("%s sent %d requests in %s (baseline %.1f)", a.Source, a.Requests, a.Window, a.Baseline),
+		Data: map[string]interface{}{
+			"source":   a.Source,
+			"requests": a.Requests,
+			"baseline": a.Baseline,
+			"window":   a.Window.String(),
+		},
+	}
+	if a.Kind == KindScan {
+		event.Type = notify.EventAnomalyScan
+		event.Message = fmt.Sprintf("%s got 404 on %d distinct paths in %s", a.Source, a.NotFoundPaths, a.Window)
+		event.Data["not_found_paths"] = a.NotFoundPaths
+	}
+	d.notifier.Publish(event)
+
+	for _, fn := range hooks {
+		fn(a)
+	}
+}
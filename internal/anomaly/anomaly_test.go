@@ -0,0 +1,120 @@
+package anomaly
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func testConfig() Config {
+	return Config{
+		Decay:         0.5,
+		SpikeFactor:   10,
+		MinRequests:   50,
+		WarmupWindows: 3,
+		ScanPaths:     5,
+		Cooldown:      time.Minute,
+		MaxSources:    100,
+	}
+}
+
+func record(d *Detector, consumerID, ip string, n int) {
+	for i := 0; i < n; i++ {
+		d.Record(consumerID, ip, "/orders", 200)
+	}
+}
+
+func TestDetector_Spike(t *testing.T) {
+	d := NewDetector(testConfig())
+	var hooked []Anomaly
+	d.OnAnomaly(func(a Anomaly) { hooked = append(hooked, a) })
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		record(d, "c1", "10.0.0.1", 10)
+		now = now.Add(10 * time.Second)
+		if found := d.evaluate(now, 10*time.Second); len(found) != 0 {
+			t.Fatalf("window %d: unexpected anomalies %+v", i, found)
+		}
+	}
+
+	// 20x the baseline and above MinRequests
+	record(d, "c1", "", 200)
+	now = now.Add(10 * time.Second)
+	found := d.evaluate(now, 10*time.Second)
+	if len(found) != 1 || found[0].Kind != KindSpike || found[0].Source != "consumer:c1" {
+		t.Fatalf("found = %+v, want one spike for consumer:c1", found)
+	}
+	if found[0].Requests != 200 || found[0].Baseline != 10 {
+		t.Errorf("requests = %d, baseline = %.1f", found[0].Requests, found[0].Baseline)
+	}
+	if len(hooked) != 1 {
+		t.Errorf("hook called %d times, want 1", len(hooked))
+	}
+
+	// Cooldown suppresses a repeat, even though the spike continues
+	record(d, "c1", "", 2000)
+	now = now.Add(10 * time.Second)
+	if found := d.evaluate(now, 10*time.Second); len(found) != 0 {
+		t.Errorf("expected cooldown to suppress %+v", found)
+	}
+}
+
+func TestDetector_NoSpikeDuringWarmup(t *testing.T) {
+	d := NewDetector(testConfig())
+	now := time.Now()
+
+	record(d, "", "10.0.0.2", 1)
+	d.evaluate(now, time.Second)
+	record(d, "", "10.0.0.2", 500)
+	if found := d.evaluate(now.Add(time.Second), time.Second); len(found) != 0 {
+		t.Errorf("expected no spike before the baseline is warm, got %+v", found)
+	}
+}
+
+func TestDetector_Scan(t *testing.T) {
+	d := NewDetector(testConfig())
+	for i := 0; i < 4; i++ {
+		d.Record("", "10.0.0.3", "/.env", 404) // same path repeated
+	}
+	if found := d.evaluate(time.Now(), time.Second); len(found) != 0 {
+		t.Fatalf("repeated 404s on one path reported: %+v", found)
+	}
+
+	for i := 0; i < 5; i++ {
+		d.Record("", "10.0.0.3", fmt.Sprintf("/admin%d", i), 404)
+	}
+	found := d.evaluate(time.Now(), time.Second)
+	if len(found) != 1 || found[0].Kind != KindScan || found[0].Source != "ip:10.0.0.3" || found[0].NotFoundPaths != 5 {
+		t.Fatalf("found = %+v, want one scan for ip:10.0.0.3", found)
+	}
+}
+
+func TestDetector_ForgetsIdleSourcesAndCaps(t *testing.T) {
+	config := testConfig()
+	config.MaxSources = 2
+	d := NewDetector(config)
+
+	d.Record("", "10.0.0.1", "/", 200)
+	d.Record("", "10.0.0.2", "/", 200)
+	d.Record("", "10.0.0.3", "/", 200) // over MaxSources
+	if len(d.sources) != 2 {
+		t.Fatalf("tracking %d sources, want 2", len(d.sources))
+	}
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		now = now.Add(time.Second)
+		d.evaluate(now, time.Second)
+	}
+	if len(d.sources) != 0 {
+		t.Errorf("idle sources not forgotten: %d left", len(d.sources))
+	}
+}
+
+func TestDetector_Nil(t *testing.T) {
+	var d *Detector
+	d.Record("c1", "10.0.0.1", "/", 200)
+	d.OnAnomaly(func(Anomaly) {})
+	d.SetNotifier(nil)
+}
@@ -0,0 +1,358 @@
+// Package bodybuffer bounds the memory plugins spend buffering request and
+// response bodies.
+//
+// Plugins that need a whole body (cache, negative-cache, etag, pagination,
+// xml-transform, response-size-limit, request-aggregator, and the request
+// body checks of webhook-verify, json-firewall, waf, request-recorder and
+// idempotency) buffer it through a Manager, so many concurrent large
+// bodies can't exhaust the gateway's memory:
+//   - MaxMemory caps the bytes buffered in memory across all requests in
+//     flight (BODY_BUFFER_MAX_MEMORY)
+//   - With a spill directory (BODY_BUFFER_SPILL_DIR), plugins that only
+//     stream the body (hashing, measuring) have bodies over SpillThreshold,
+//     or ones that don't fit the remaining budget, written to a temp file
+//   - Otherwise a body that doesn't fit is left incomplete (OverBudget),
+//     and the plugin passes it through untouched or rejects the request
+//
+// Memory and temp files are released when the Body is closed. A nil
+// *Manager buffers in memory without a budget.
+//
+// Plugins inspecting a request body read it with ReadRequest (or
+// ReadRequestSpillable), which puts the body back for the proxy and
+// releases the buffer when the request ends.
+package bodybuffer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// chunkSize is how much memory is reserved per read.
+const chunkSize = 32 << 10
+
+var (
+	buffersTotal = metrics.NewCounterVec(
+		"gateway_body_buffers_total",
+		"Bodies buffered by plugins, by result (memory, spilled, over_budget).",
+		"result",
+	)
+	memoryInUse = metrics.NewGaugeVec(
+		"gateway_body_buffer_memory_bytes",
+		"Bytes of request and response bodies currently buffered in memory by plugins.",
+	)
+)
+
+// Config holds Manager configuration.
+type Config struct {
+	// MaxMemory is the most bytes buffered in memory at once (0 = no limit)
+	MaxMemory int64
+
+	// SpillThreshold is the size above which spillable bodies go to disk
+	// (0 = only when the memory budget is exhausted)
+	SpillThreshold int64
+
+	// SpillDir holds temp files of spilled bodies (empty = never spill)
+	SpillDir string
+}
+
+// Manager hands out the buffering budget.
+type Manager struct {
+	config Config
+	inUse  atomic.Int64
+}
+
+// NewManager creates a Manager. It returns an error if the spill directory
+// can't be created.
+func NewManager(config Config) (*Manager, error) {
+	if config.SpillDir != "" {
+		if err := os.MkdirAll(config.SpillDir, 0o700); err != nil {
+			return nil, err
+		}
+	}
+	return &Manager{config: config}, nil
+}
+
+// InUse returns the bytes currently buffered in memory.
+func (m *Manager) InUse() int64 {
+	if m == nil {
+		return 0
+	}
+	return m.inUse.Load()
+}
+
+// Read buffers r in memory, up to limit bytes. The body is incomplete if
+// r holds more than limit bytes (TooLarge) or the memory budget ran out
+// (OverBudget); ReadCloser then passes it on unchanged.
+func (m *Manager) Read(r io.Reader, limit int64) (*Body, error) {
+	return m.read(r, limit, false)
+}
+
+// ReadSpillable is Read for plugins that only stream the body (Reader,
+// never Bytes): past SpillThreshold, or when the memory budget runs out,
+// the body goes to a temp file instead, if a spill directory is set.
+func (m *Manager) ReadSpillable(r io.Reader, limit int64) (*Body, error) {
+	return m.read(r, limit, m != nil && m.config.SpillDir != "")
+}
+
+// read buffers up to limit+1 bytes of r, one past the limit to tell a
+// body of exactly limit bytes from a larger one.
+func (m *Manager) read(r io.Reader, limit int64, spill bool) (*Body, error) {
+	b := &Body{m: m}
+	var buf bytes.Buffer
+	chunk := make([]byte, chunkSize)
+
+	for b.size <= limit {
+		n := min(int64(chunkSize), limit+1-b.size)
+
+		if b.file == nil {
+			overThreshold := spill && m.config.SpillThreshold > 0 && b.size+n > m.config.SpillThreshold
+			if overThreshold || !m.reserve(n) {
+				if !spill {
+					b.overBudget = true
+					break
+				}
+				if err := b.spill(buf.Bytes()); err != nil {
+					b.Close()
+					return nil, err
+				}
+				buf = bytes.Buffer{}
+			} else {
+				b.reserved += n
+			}
+		}
+
+		read, err := io.ReadFull(r, chunk[:n])
+		if b.file != nil {
+			if _, werr := b.file.Write(chunk[:read]); werr != nil {
+				b.Close()
+				return nil, werr
+			}
+		} else {
+			buf.Write(chunk[:read])
+		}
+		b.size += int64(read)
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			b.Close()
+			return nil, err
+		}
+	}
+	b.data = buf.Bytes()
+	b.tooLarge = b.size > limit
+
+	// Give back what the body didn't use
+	if b.file == nil && b.reserved > b.size {
+		m.release(b.reserved - b.size)
+		b.reserved = b.size
+	}
+
+	switch {
+	case b.overBudget:
+		buffersTotal.Inc("over_budget")
+		log.Debug().
+			Str("component", "body_buffer").
+			Int64("in_use", m.InUse()).
+			Int64("max_memory", m.config.MaxMemory).
+			Msg("Body buffering budget exhausted - body left unbuffered")
+	case b.file != nil:
+		buffersTotal.Inc("spilled")
+	default:
+		buffersTotal.Inc("memory")
+	}
+	return b, nil
+}
+
+// reserve takes n bytes of the memory budget, reporting whether it could.
+func (m *Manager) reserve(n int64) bool {
+	if m == nil {
+		return true
+	}
+	for {
+		current := m.inUse.Load()
+		if m.config.MaxMemory > 0 && current+n > m.config.MaxMemory {
+			return false
+		}
+		if m.inUse.CompareAndSwap(current, current+n) {
+			memoryInUse.Add(float64(n))
+			return true
+		}
+	}
+}
+
+// release returns n bytes to the memory budget.
+func (m *Manager) release(n int64) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.inUse.Add(-n)
+	memoryInUse.Add(-float64(n))
+}
+
+// Body is a buffered body, in memory or in a temp file.
+type Body struct {
+	m          *Manager
+	data       []byte
+	file       *os.File
+	size       int64
+	reserved   int64
+	tooLarge   bool
+	overBudget bool
+	closed     atomic.Bool
+}
+
+// spill moves the body to a temp file, starting with data.
+func (b *Body) spill(data []byte) error {
+	f, err := os.CreateTemp(b.m.config.SpillDir, "body-*")
+	if err != nil {
+		return err
+	}
+	b.file = f
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	b.m.release(b.reserved)
+	b.reserved = 0
+	return nil
+}
+
+// Complete reports whether the whole body was buffered.
+func (b *Body) Complete() bool {
+	return !b.tooLarge && !b.overBudget
+}
+
+// TooLarge reports whether the body is larger than the limit.
+func (b *Body) TooLarge() bool {
+	return b.tooLarge
+}
+
+// OverBudget reports whether buffering stopped because the memory budget
+// ran out.
+func (b *Body) OverBudget() bool {
+	return b.overBudget
+}
+
+// Spilled reports whether the body is in a temp file.
+func (b *Body) Spilled() bool {
+	return b.file != nil
+}
+
+// Size returns the number of bytes buffered.
+func (b *Body) Size() int64 {
+	return b.size
+}
+
+// Bytes returns the buffered bytes, or nil if the body was spilled.
+func (b *Body) Bytes() []byte {
+	return b.data
+}
+
+// Reader returns a reader over the buffered bytes.
+func (b *Body) Reader() io.Reader {
+	if b.file != nil {
+		return io.NewSectionReader(b.file, 0, b.size)
+	}
+	return bytes.NewReader(b.data)
+}
+
+// ReadCloser returns the buffered bytes followed by the rest of the
+// original body. Closing it closes rest and releases b.
+func (b *Body) ReadCloser(rest io.ReadCloser) io.ReadCloser {
+	return &replay{Reader: io.MultiReader(b.Reader(), rest), body: b, rest: rest}
+}
+
+// Close releases the body's memory and removes its temp file.
+// It is safe to call more than once, and concurrently.
+func (b *Body) Close() error {
+	if !b.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	b.m.release(b.reserved)
+	b.reserved = 0
+	b.data = nil
+	if b.file != nil {
+		b.file.Close()
+		return os.Remove(b.file.Name())
+	}
+	return nil
+}
+
+// replay reads a buffered body and the rest of the original.
+type replay struct {
+	io.Reader
+	body *Body
+	rest io.ReadCloser
+}
+
+func (r *replay) Close() error {
+	err := r.rest.Close()
+	r.body.Close()
+	return err
+}
+
+// ============================================================================
+// Request context
+// ============================================================================
+
+type contextKey struct{}
+
+// Attach stores the manager in the request context for plugins.
+func (m *Manager) Attach(req *http.Request) *http.Request {
+	if m == nil {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), contextKey{}, m))
+}
+
+// FromRequest returns the manager attached by Attach, or nil (memory
+// without a budget) if none was.
+func FromRequest(req *http.Request) *Manager {
+	m, _ := req.Context().Value(contextKey{}).(*Manager)
+	return m
+}
+
+// ReadRequest buffers up to limit bytes of req's body with the manager
+// attached to req (see Read) and puts the body back: req.Body then reads
+// the buffered bytes followed by the rest. The buffer is released when
+// req.Body is closed or req's context ends, whichever comes first. A
+// request without a body gets an empty, complete Body.
+func ReadRequest(req *http.Request, limit int64) (*Body, error) {
+	return readRequest(req, limit, false)
+}
+
+// ReadRequestSpillable is ReadRequest for callers that only stream the
+// body (see ReadSpillable).
+func ReadRequestSpillable(req *http.Request, limit int64) (*Body, error) {
+	return readRequest(req, limit, true)
+}
+
+func readRequest(req *http.Request, limit int64, spill bool) (*Body, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return &Body{}, nil
+	}
+
+	m := FromRequest(req)
+	read := m.Read
+	if spill {
+		read = m.ReadSpillable
+	}
+	b, err := read(req.Body, limit)
+	if err != nil {
+		req.Body.Close()
+		return nil, err
+	}
+
+	req.Body = b.ReadCloser(req.Body)
+	context.AfterFunc(req.Context(), func() { b.Close() })
+	return b, nil
+}
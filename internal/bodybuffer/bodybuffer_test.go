@@ -0,0 +1,157 @@
+package bodybuffer
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRead_InMemory(t *testing.T) {
+	m, _ := NewManager(Config{MaxMemory: 1 << 20})
+
+	b, err := m.Read(strings.NewReader("hello"), 10)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !b.Complete() || string(b.Bytes()) != "hello" || b.Size() != 5 {
+		t.Errorf("body = %q (complete %v), want hello", b.Bytes(), b.Complete())
+	}
+	if m.InUse() != 5 {
+		t.Errorf("InUse() = %d, want 5 while the body is held", m.InUse())
+	}
+	b.Close()
+	if m.InUse() != 0 {
+		t.Errorf("InUse() = %d after Close, want 0", m.InUse())
+	}
+}
+
+func TestRead_TooLarge(t *testing.T) {
+	var m *Manager // no budget
+
+	b, err := m.Read(strings.NewReader("0123456789"), 4)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if b.Complete() || !b.TooLarge() {
+		t.Errorf("Complete() = %v, TooLarge() = %v; want an incomplete, too large body", b.Complete(), b.TooLarge())
+	}
+
+	// Exactly at the limit is complete
+	b, _ = m.Read(strings.NewReader("0123"), 4)
+	if !b.Complete() {
+		t.Error("a body of exactly limit bytes should be complete")
+	}
+}
+
+func TestRead_OverBudgetPassesThrough(t *testing.T) {
+	m, _ := NewManager(Config{MaxMemory: chunkSize})
+	held, _ := m.Read(strings.NewReader(strings.Repeat("a", 100)), chunkSize)
+
+	content := strings.Repeat("b", 3*chunkSize)
+	rest := io.NopCloser(strings.NewReader(content))
+	b, err := m.Read(rest, 10*chunkSize)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !b.OverBudget() || b.Complete() {
+		t.Fatalf("OverBudget() = %v, want the body left unbuffered", b.OverBudget())
+	}
+
+	// Nothing read is lost
+	replayed, _ := io.ReadAll(b.ReadCloser(rest))
+	if string(replayed) != content {
+		t.Errorf("replayed %d bytes, want the original %d", len(replayed), len(content))
+	}
+
+	held.Close()
+	if m.InUse() != 0 {
+		t.Errorf("InUse() = %d after release, want 0", m.InUse())
+	}
+}
+
+func TestReadSpillable_SpillsToDisk(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewManager(Config{MaxMemory: 1 << 20, SpillThreshold: chunkSize, SpillDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := strings.Repeat("x", 2*chunkSize+7)
+	b, err := m.ReadSpillable(strings.NewReader(content), 1<<20)
+	if err != nil {
+		t.Fatalf("ReadSpillable() error = %v", err)
+	}
+	if !b.Spilled() || !b.Complete() || b.Bytes() != nil {
+		t.Fatalf("Spilled() = %v, Complete() = %v; want a complete body on disk", b.Spilled(), b.Complete())
+	}
+	if m.InUse() != 0 {
+		t.Errorf("InUse() = %d, want spilled bodies outside the memory budget", m.InUse())
+	}
+
+	data, _ := io.ReadAll(b.Reader())
+	if string(data) != content {
+		t.Errorf("read back %d bytes, want %d", len(data), len(content))
+	}
+
+	b.Close()
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("spill dir has %d files after Close, want 0", len(entries))
+	}
+
+	// Small bodies stay in memory, and Read never spills
+	if b, _ := m.ReadSpillable(strings.NewReader("small"), 1<<20); b.Spilled() {
+		t.Error("a body under the threshold should stay in memory")
+	}
+	if b, _ := m.Read(strings.NewReader(content), 1<<20); b.Spilled() {
+		t.Error("Read should never spill")
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if FromRequest(req) != nil {
+		t.Error("FromRequest() without Attach should be nil")
+	}
+	m, _ := NewManager(Config{})
+	if FromRequest(m.Attach(req)) != m {
+		t.Error("FromRequest() should return the attached manager")
+	}
+}
+
+func TestReadRequest(t *testing.T) {
+	m, _ := NewManager(Config{MaxMemory: 1 << 20})
+	ctx, cancel := context.WithCancel(context.Background())
+	req := m.Attach(httptest.NewRequest("POST", "/", strings.NewReader("0123456789")).WithContext(ctx))
+
+	b, err := ReadRequest(req, 4)
+	if err != nil {
+		t.Fatalf("ReadRequest() error = %v", err)
+	}
+	if !b.TooLarge() || m.InUse() != 5 {
+		t.Errorf("TooLarge() = %v, InUse() = %d; want a too large body holding 5 bytes", b.TooLarge(), m.InUse())
+	}
+
+	// The proxy still gets the whole body
+	if rest, _ := io.ReadAll(req.Body); string(rest) != "0123456789" {
+		t.Errorf("restored body = %q, want the original", rest)
+	}
+
+	// Released once the request ends, even if the body is never closed
+	cancel()
+	for i := 0; i < 100 && m.InUse() != 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if m.InUse() != 0 {
+		t.Errorf("InUse() = %d after the request ended, want 0", m.InUse())
+	}
+	req.Body.Close()
+
+	// No body
+	if b, err := ReadRequest(httptest.NewRequest("GET", "/", nil), 4); err != nil || !b.Complete() || b.Size() != 0 {
+		t.Errorf("ReadRequest() without a body = %+v, %v; want an empty body", b, err)
+	}
+}
@@ -0,0 +1,222 @@
+// Package clientip resolves the real client IP of a request.
+//
+// X-Forwarded-For can be set by anyone, so it is only honored when the
+// immediate peer is a trusted proxy (TRUSTED_PROXIES). The chain is then
+// walked from the right, skipping trusted proxies, and the first untrusted
+// address is the client:
+//
+//	peer 10.0.0.2 (trusted LB), X-Forwarded-For: 1.2.3.4, 203.0.113.9, 10.0.0.7
+//	-> 203.0.113.9 (1.2.3.4 was supplied by the client and is ignored)
+//
+// The gateway resolves the client IP once per request and attaches it to
+// the request context (Attach); the proxy, plugins and load balancers read
+// it with FromRequest so they all agree on who the client is.
+//
+// Addresses are parsed with net/netip (ParseAddr): ports and IPv6 brackets
+// are stripped, zones dropped and IPv4-mapped IPv6 addresses unmapped, so
+// "[::ffff:203.0.113.9]:443" and "203.0.113.9" are the same client.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Aliases accepted in trusted proxy lists.
+var aliases = map[string][]string{
+	"loopback": {"127.0.0.0/8", "::1/128"},
+	"private":  {"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"},
+}
+
+// Resolver determines client IPs given a set of trusted proxies.
+//
+// A nil or empty Resolver trusts no proxies: the client IP is always the
+// immediate peer.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// NewResolver creates a resolver trusting the given proxies. Entries are
+// CIDRs ("10.0.0.0/8"), single IPs, or the aliases "private" and
+// "loopback".
+func NewResolver(proxies []string) (*Resolver, error) {
+	r := &Resolver{}
+
+	for _, entry := range proxies {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		cidrs, ok := aliases[strings.ToLower(entry)]
+		if !ok {
+			cidrs = []string{entry}
+		}
+
+		for _, cidr := range cidrs {
+			network, err := parseNetwork(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			r.trusted = append(r.trusted, network)
+		}
+	}
+
+	return r, nil
+}
+
+// parseNetwork parses a CIDR or a single IP (as a /32 or /128).
+func parseNetwork(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked(), nil
+	}
+
+	ip, ok := ParseAddr(s)
+	if !ok {
+		return netip.Prefix{}, fmt.Errorf("not an IP address or CIDR")
+	}
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+// Trusted reports whether ip belongs to a trusted proxy.
+func (r *Resolver) Trusted(ip string) bool {
+	if r == nil {
+		return false
+	}
+	parsed, ok := ParseAddr(ip)
+	if !ok {
+		return false
+	}
+	for _, network := range r.trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// TrustsPeer reports whether the request came from a trusted proxy, i.e.
+// whether its forwarding headers may be believed.
+func (r *Resolver) TrustsPeer(req *http.Request) bool {
+	return r.Trusted(RemoteIP(req))
+}
+
+// ClientIP resolves the client IP of a request.
+func (r *Resolver) ClientIP(req *http.Request) string {
+	peer := RemoteIP(req)
+	if !r.Trusted(peer) {
+		return peer
+	}
+
+	// Walk X-Forwarded-For right to left, skipping trusted proxies
+	hops := forwardedFor(req.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !r.Trusted(hops[i]) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		// Every hop is a trusted proxy: the leftmost is the origin
+		return hops[0]
+	}
+
+	// Single trusted proxy that only sets X-Real-IP (e.g. nginx)
+	if xri, ok := ParseAddr(req.Header.Get("X-Real-IP")); ok {
+		return xri.String()
+	}
+
+	return peer
+}
+
+// forwardedFor returns the valid IPs listed in X-Forwarded-For headers,
+// in order. Malformed entries end the usable chain at that point, since
+// anything to their left cannot be attributed.
+func forwardedFor(h http.Header) []string {
+	var hops []string
+	for _, value := range h.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			ip, ok := ParseAddr(hop)
+			if !ok {
+				hops = hops[:0]
+				continue
+			}
+			hops = append(hops, ip.String())
+		}
+	}
+	return hops
+}
+
+// RemoteIP returns the IP of the immediate peer.
+func RemoteIP(req *http.Request) string {
+	return HostIP(req.RemoteAddr)
+}
+
+// ParseAddr parses an IP address, with or without a port and IPv6
+// brackets: "203.0.113.9", "203.0.113.9:443", "2001:db8::1",
+// "[2001:db8::1]" and "[2001:db8::1]:443" are all accepted. Zones are
+// dropped and IPv4-mapped IPv6 addresses are returned as IPv4.
+func ParseAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return netip.Addr{}, false
+	}
+
+	// host:port or [host]:port; a bare IPv6 address has no port
+	host := s
+	if h, _, err := net.SplitHostPort(s); err == nil {
+		host = h
+	} else if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		host = s[1 : len(s)-1]
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.WithZone("").Unmap(), true
+}
+
+// HostIP returns the canonical IP of a "host:port" (or bare) address, such
+// as a request's RemoteAddr. Addresses that aren't IPs (e.g. Unix socket
+// paths) are returned as given, trimmed.
+func HostIP(addr string) string {
+	if ip, ok := ParseAddr(addr); ok {
+		return ip.String()
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSpace(addr)
+}
+
+// ============================================================================
+// Request context
+// ============================================================================
+
+type contextKey struct{}
+
+// Attach resolves the client IP and stores it in the request context.
+func (r *Resolver) Attach(req *http.Request) *http.Request {
+	ip := r.ClientIP(req)
+	return req.WithContext(context.WithValue(req.Context(), contextKey{}, ip))
+}
+
+// FromRequest returns the client IP attached by Attach, or the immediate
+// peer if none was attached (forwarded headers are never trusted then).
+func FromRequest(req *http.Request) string {
+	if ip, ok := req.Context().Value(contextKey{}).(string); ok {
+		return ip
+	}
+	return RemoteIP(req)
+}
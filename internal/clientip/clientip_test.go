@@ -0,0 +1,179 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolver_ClientIP(t *testing.T) {
+	resolver, err := NewResolver([]string{"10.0.0.0/8", "192.0.2.1", "loopback"})
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		xri        string
+		expectedIP string
+	}{
+		{
+			name:       "direct connection",
+			remoteAddr: "192.168.1.100:12345",
+			expectedIP: "192.168.1.100",
+		},
+		{
+			name:       "untrusted peer cannot spoof X-Forwarded-For",
+			remoteAddr: "198.51.100.7:12345",
+			xff:        "203.0.113.1",
+			expectedIP: "198.51.100.7",
+		},
+		{
+			name:       "untrusted peer cannot spoof X-Real-IP",
+			remoteAddr: "198.51.100.7:12345",
+			xri:        "203.0.113.1",
+			expectedIP: "198.51.100.7",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.0.0.1:12345",
+			xff:        "203.0.113.1",
+			expectedIP: "203.0.113.1",
+		},
+		{
+			name:       "client-supplied prefix is skipped",
+			remoteAddr: "10.0.0.1:12345",
+			xff:        "1.2.3.4, 203.0.113.1, 10.0.0.7",
+			expectedIP: "203.0.113.1",
+		},
+		{
+			name:       "all hops trusted",
+			remoteAddr: "127.0.0.1:12345",
+			xff:        "10.0.0.9, 192.0.2.1",
+			expectedIP: "10.0.0.9",
+		},
+		{
+			name:       "garbage ends the chain",
+			remoteAddr: "10.0.0.1:12345",
+			xff:        "203.0.113.1, not-an-ip",
+			expectedIP: "10.0.0.1",
+		},
+		{
+			name:       "X-Real-IP from trusted proxy",
+			remoteAddr: "10.0.0.1:12345",
+			xri:        "203.0.113.1",
+			expectedIP: "203.0.113.1",
+		},
+		{
+			name:       "IPv6 peer",
+			remoteAddr: "[2001:db8::1]:443",
+			xff:        "203.0.113.1",
+			expectedIP: "2001:db8::1",
+		},
+		{
+			name:       "IPv4-mapped trusted peer",
+			remoteAddr: "[::ffff:10.0.0.1]:12345",
+			xff:        "203.0.113.1",
+			expectedIP: "203.0.113.1",
+		},
+		{
+			name:       "IPv6 hops with brackets and ports",
+			remoteAddr: "[::1]:12345",
+			xff:        "[2001:db8::7]:5000, 10.0.0.3:80",
+			expectedIP: "2001:db8::7",
+		},
+		{
+			name:       "peer without port",
+			remoteAddr: "[2001:db8::2]",
+			expectedIP: "2001:db8::2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xri != "" {
+				req.Header.Set("X-Real-IP", tt.xri)
+			}
+
+			if ip := resolver.ClientIP(req); ip != tt.expectedIP {
+				t.Errorf("ClientIP() = %v, want %v", ip, tt.expectedIP)
+			}
+		})
+	}
+}
+
+func TestParseAddr(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"203.0.113.9", "203.0.113.9", true},
+		{" 203.0.113.9:443 ", "203.0.113.9", true},
+		{"2001:db8::1", "2001:db8::1", true},
+		{"[2001:db8::1]", "2001:db8::1", true},
+		{"[2001:db8::1]:443", "2001:db8::1", true},
+		{"[fe80::1%eth0]:443", "fe80::1", true},
+		{"::ffff:203.0.113.9", "203.0.113.9", true},
+		{"2001:db8::1:443", "2001:db8::1:443", true},
+		{"", "", false},
+		{"[2001:db8::1", "", false},
+		{"proxy.internal:80", "", false},
+	}
+	for _, tt := range tests {
+		ip, ok := ParseAddr(tt.in)
+		got := ""
+		if ok {
+			got = ip.String()
+		}
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseAddr(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if got := HostIP("/run/gateway.sock"); got != "/run/gateway.sock" {
+		t.Errorf("HostIP() of a non-IP address = %q", got)
+	}
+}
+
+func TestResolver_NilTrustsNoOne(t *testing.T) {
+	var resolver *Resolver
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "10.0.0.1:12345"
+	req.Header.Set("X-Forwarded-For", "203.0.113.1")
+
+	if ip := resolver.ClientIP(req); ip != "10.0.0.1" {
+		t.Errorf("ClientIP() = %v, want peer", ip)
+	}
+}
+
+func TestNewResolver_Invalid(t *testing.T) {
+	if _, err := NewResolver([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+	if _, err := NewResolver([]string{"proxy.internal"}); err == nil {
+		t.Error("expected error for hostname")
+	}
+}
+
+func TestAttachAndFromRequest(t *testing.T) {
+	resolver, _ := NewResolver([]string{"private"})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "10.0.0.1:12345"
+	req.Header.Set("X-Forwarded-For", "203.0.113.1")
+
+	if ip := FromRequest(req); ip != "10.0.0.1" {
+		t.Errorf("FromRequest() without Attach = %v, want peer", ip)
+	}
+	if ip := FromRequest(resolver.Attach(req)); ip != "203.0.113.1" {
+		t.Errorf("FromRequest() = %v, want 203.0.113.1", ip)
+	}
+}
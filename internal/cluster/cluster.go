@@ -0,0 +1,302 @@
+// Package cluster gives active-active gateway instances a view of their
+// peers.
+//
+// Every instance heartbeats a record of itself (hostname, version, config
+// generation, start time) into a Redis hash, one field per instance.
+// Reading the hash lists the live fleet: instances that stopped
+// heartbeating for longer than PeerTTL are dropped (and pruned from the
+// hash), and instances leave it on graceful shutdown.
+//
+// The fleet view flags:
+//   - config drift: instances report different config generations (see
+//     router.Generation), i.e. some gateway missed a reload or failed to
+//     apply it. Expect it briefly while a change propagates
+//   - version skew: instances run different gateway builds, e.g. during a
+//     rolling deploy
+//
+// Membership is advisory: instances never coordinate through it, so a
+// Redis outage only leaves the view stale.
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+var (
+	clusterPeers = metrics.NewGaugeVec(
+		"gateway_cluster_peers",
+		"Live gateway instances at the last heartbeat, including this one.",
+	)
+	clusterConfigDrift = metrics.NewGaugeVec(
+		"gateway_cluster_config_drift",
+		"1 if live instances report different config generations, 0 otherwise.",
+	)
+	clusterVersionSkew = metrics.NewGaugeVec(
+		"gateway_cluster_version_skew",
+		"1 if live instances run different gateway versions, 0 otherwise.",
+	)
+)
+
+// Config holds cluster membership settings.
+type Config struct {
+	// Key is the Redis hash holding the members
+	// Default: "gateway:cluster"
+	Key string
+
+	// PeerTTL drops instances whose last heartbeat is older than this
+	// Default: 30s
+	PeerTTL time.Duration
+}
+
+// Member is the heartbeat record of one gateway instance.
+type Member struct {
+	ID         string    `json:"id"`
+	Hostname   string    `json:"hostname"`
+	Version    string    `json:"version"`
+	Generation string    `json:"config_generation"`
+	Region     string    `json:"region,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// Peer is a live member as seen from this instance.
+type Peer struct {
+	Member
+	Uptime string `json:"uptime"`
+	Self   bool   `json:"self"`
+}
+
+// Status is the fleet view.
+type Status struct {
+	Self        string         `json:"self"`
+	Peers       []Peer         `json:"peers"`
+	ConfigDrift bool           `json:"config_drift"`
+	VersionSkew bool           `json:"version_skew"`
+	Generations map[string]int `json:"generations"` // generation -> instances
+	Versions    map[string]int `json:"versions"`    // version -> instances
+}
+
+// Membership registers this instance and reads the fleet. A nil
+// Membership does nothing.
+type Membership struct {
+	client     *redis.Client
+	config     Config
+	self       Member
+	generation func() string
+}
+
+// NewMembership creates the membership of this instance. generation
+// returns the config generation currently served.
+func NewMembership(client *redis.Client, config Config, version, region string, generation func() string) *Membership {
+	if config.Key == "" {
+		config.Key = "gateway:cluster"
+	}
+	if config.PeerTTL <= 0 {
+		config.PeerTTL = 30 * time.Second
+	}
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "unknown"
+	}
+
+	// Hostnames repeat across restarts; the suffix tells instances apart
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+
+	return &Membership{
+		client: client,
+		config: config,
+		self: Member{
+			ID:        hostname + "-" + hex.EncodeToString(suffix),
+			Hostname:  hostname,
+			Version:   version,
+			Region:    region,
+			StartedAt: time.Now().UTC(),
+		},
+		generation: generation,
+	}
+}
+
+// ID returns this instance's member ID.
+func (m *Membership) ID() string {
+	if m == nil {
+		return ""
+	}
+	return m.self.ID
+}
+
+// Run heartbeats every interval until ctx is done.
+func (m *Membership) Run(ctx context.Context, interval time.Duration) {
+	if m == nil || interval <= 0 {
+		return
+	}
+
+	m.tick(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.tick(ctx)
+		}
+	}
+}
+
+// tick heartbeats and refreshes the fleet metrics.
+func (m *Membership) tick(ctx context.Context) {
+	if err := m.Heartbeat(ctx); err != nil {
+		log.Warn().
+			Err(err).
+			Str("component", "cluster").
+			Msg("Cluster heartbeat failed")
+		return
+	}
+
+	status, err := m.Status(ctx)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("component", "cluster").
+			Msg("Failed to read cluster members")
+		return
+	}
+
+	clusterPeers.Set(float64(len(status.Peers)))
+	clusterConfigDrift.Set(boolValue(status.ConfigDrift))
+	clusterVersionSkew.Set(boolValue(status.VersionSkew))
+}
+
+// Heartbeat writes this instance's record.
+func (m *Membership) Heartbeat(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+
+	self := m.self
+	self.LastSeen = time.Now().UTC()
+	if m.generation != nil {
+		self.Generation = m.generation()
+	}
+
+	data, err := json.Marshal(self)
+	if err != nil {
+		return err
+	}
+	if err := m.client.HSet(ctx, m.config.Key, self.ID, data).Err(); err != nil {
+		return fmt.Errorf("redis HSET failed: %w", err)
+	}
+	return nil
+}
+
+// Leave removes this instance from the fleet (on graceful shutdown).
+func (m *Membership) Leave(ctx context.Context) {
+	if m == nil {
+		return
+	}
+
+	if err := m.client.HDel(ctx, m.config.Key, m.self.ID).Err(); err != nil {
+		log.Warn().
+			Err(err).
+			Str("component", "cluster").
+			Msg("Failed to leave cluster")
+		return
+	}
+
+	log.Info().
+		Str("component", "cluster").
+		Str("member", m.self.ID).
+		Msg("Left cluster")
+}
+
+// Status reads the live fleet, pruning members past PeerTTL.
+func (m *Membership) Status(ctx context.Context) (*Status, error) {
+	if m == nil {
+		return nil, fmt.Errorf("cluster membership is not enabled")
+	}
+
+	fields, err := m.client.HGetAll(ctx, m.config.Key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis HGETALL failed: %w", err)
+	}
+
+	members := make([]Member, 0, len(fields))
+	var invalid []string
+	for id, data := range fields {
+		var member Member
+		if err := json.Unmarshal([]byte(data), &member); err != nil || member.ID != id {
+			invalid = append(invalid, id)
+			continue
+		}
+		members = append(members, member)
+	}
+
+	status, stale := summarize(m.self.ID, members, time.Now(), m.config.PeerTTL)
+
+	if pruned := append(invalid, stale...); len(pruned) > 0 {
+		if err := m.client.HDel(ctx, m.config.Key, pruned...).Err(); err != nil {
+			log.Warn().
+				Err(err).
+				Str("component", "cluster").
+				Msg("Failed to prune stale cluster members")
+		}
+	}
+
+	return status, nil
+}
+
+// summarize builds the fleet view of members at now. It returns the IDs
+// of members whose last heartbeat is older than ttl separately.
+func summarize(self string, members []Member, now time.Time, ttl time.Duration) (*Status, []string) {
+	status := &Status{
+		Self:        self,
+		Peers:       []Peer{},
+		Generations: make(map[string]int),
+		Versions:    make(map[string]int),
+	}
+
+	var stale []string
+	for _, member := range members {
+		if now.Sub(member.LastSeen) > ttl {
+			stale = append(stale, member.ID)
+			continue
+		}
+		status.Peers = append(status.Peers, Peer{
+			Member: member,
+			Uptime: now.Sub(member.StartedAt).Round(time.Second).String(),
+			Self:   member.ID == self,
+		})
+		status.Generations[member.Generation]++
+		status.Versions[member.Version]++
+	}
+
+	sort.Slice(status.Peers, func(i, j int) bool {
+		return status.Peers[i].StartedAt.Before(status.Peers[j].StartedAt)
+	})
+	status.ConfigDrift = len(status.Generations) > 1
+	status.VersionSkew = len(status.Versions) > 1
+
+	return status, stale
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	now := time.Now()
+	members := []Member{
+		{ID: "b", Version: "1.2.0", Generation: "g1", StartedAt: now.Add(-time.Minute), LastSeen: now.Add(-5 * time.Second)},
+		{ID: "a", Version: "1.2.0", Generation: "g1", StartedAt: now.Add(-time.Hour), LastSeen: now},
+		{ID: "gone", Version: "1.1.0", Generation: "g0", StartedAt: now.Add(-2 * time.Hour), LastSeen: now.Add(-time.Minute)},
+	}
+
+	status, stale := summarize("a", members, now, 30*time.Second)
+	if len(stale) != 1 || stale[0] != "gone" {
+		t.Errorf("stale = %v, want [gone]", stale)
+	}
+	if len(status.Peers) != 2 || status.Peers[0].ID != "a" || !status.Peers[0].Self || status.Peers[1].Self {
+		t.Fatalf("peers = %+v, want a (self) then b", status.Peers)
+	}
+	if status.Peers[0].Uptime != "1h0m0s" {
+		t.Errorf("uptime = %q, want 1h0m0s", status.Peers[0].Uptime)
+	}
+	if status.ConfigDrift || status.VersionSkew {
+		t.Error("stale members should not count towards drift or skew")
+	}
+
+	members[1].Generation = "g2"
+	members[1].Version = "1.3.0"
+	status, _ = summarize("a", members, now, 30*time.Second)
+	if !status.ConfigDrift || !status.VersionSkew {
+		t.Errorf("drift = %v, skew = %v; want both", status.ConfigDrift, status.VersionSkew)
+	}
+	if status.Generations["g1"] != 1 || status.Generations["g2"] != 1 {
+		t.Errorf("generations = %v", status.Generations)
+	}
+}
+
+func TestMembership_Nil(t *testing.T) {
+	var m *Membership
+	if err := m.Heartbeat(context.Background()); err != nil {
+		t.Errorf("Heartbeat() error = %v", err)
+	}
+	m.Leave(context.Background())
+	if _, err := m.Status(context.Background()); err == nil {
+		t.Error("Status() on nil membership should fail")
+	}
+	if m.ID() != "" {
+		t.Error("nil membership should have no ID")
+	}
+}
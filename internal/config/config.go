@@ -6,6 +6,7 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
@@ -24,6 +25,20 @@ type Config struct {
 	ServerHost string `envconfig:"GATEWAY_HOST" default:"0.0.0.0"`
 	ServerPort int    `envconfig:"GATEWAY_PORT" default:"8080"`
 
+	// Region (or zone) this gateway runs in; locality-aware services prefer
+	// targets with the same region
+	Region string `envconfig:"GATEWAY_REGION"`
+
+	// TLS and protocols for the client-facing listener
+	TLSCertFile  string `envconfig:"TLS_CERT_FILE"`
+	TLSKeyFile   string `envconfig:"TLS_KEY_FILE"`
+	HTTP2Enabled bool   `envconfig:"HTTP2_ENABLED" default:"true"`  // h2 over TLS
+	H2CEnabled   bool   `envconfig:"H2C_ENABLED" default:"false"`   // cleartext HTTP/2 (prior knowledge)
+	HTTP3Enabled bool   `envconfig:"HTTP3_ENABLED" default:"false"` // experimental HTTP/3 over QUIC (UDP, same port)
+
+	// Client connection timeouts and limits (slowloris protection)
+	Listener ListenerConfig
+
 	// Database
 	Database DatabaseConfig
 
@@ -37,8 +52,559 @@ type Config struct {
 	LogLevel  string `envconfig:"LOG_LEVEL" default:"info"`
 	LogFormat string `envconfig:"LOG_FORMAT" default:"json"` // json or console
 
-	// Shutdown
-	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"30s"`
+	// Shutdown: readiness fails, ShutdownDrainDelay passes, then in-flight
+	// requests get ShutdownTimeout and flushing/closing connections gets
+	// ShutdownCloseTimeout
+	ShutdownTimeout      time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"30s"`
+	ShutdownDrainDelay   time.Duration `envconfig:"SHUTDOWN_DRAIN_DELAY" default:"0s"`
+	ShutdownCloseTimeout time.Duration `envconfig:"SHUTDOWN_CLOSE_TIMEOUT" default:"10s"`
+
+	// Outlier detection (passive health checks for service targets)
+	OutlierDetection OutlierDetectionConfig
+
+	// Retry budget capping hedged requests (see the hedging plugin)
+	HedgeBudget HedgeBudgetConfig
+
+	// Admission control (priority queueing under overload)
+	Admission AdmissionConfig
+
+	// Upstream connection pools and timeouts (overridable at runtime through
+	// the gateway_settings table)
+	Upstream UpstreamConfig
+
+	// Egress policy restricting which addresses upstreams may resolve to
+	Egress EgressConfig
+
+	// DNS cache for upstream host lookups
+	DNSCache DNSCacheConfig
+
+	// UpstreamDrainTimeout is the grace period for in-flight requests to a
+	// removed target before its connections are closed.
+	UpstreamDrainTimeout time.Duration `envconfig:"UPSTREAM_DRAIN_TIMEOUT" default:"30s"`
+
+	// Upstream dials and time-to-first-byte over these thresholds are logged
+	// as slow upstreams (0 = disabled)
+	UpstreamSlowDialThreshold time.Duration `envconfig:"UPSTREAM_SLOW_DIAL_THRESHOLD" default:"1s"`
+	UpstreamSlowTTFBThreshold time.Duration `envconfig:"UPSTREAM_SLOW_TTFB_THRESHOLD" default:"5s"`
+
+	// AdminToken protects the gateway's /admin endpoints (Bearer token).
+	// Required outside development; empty leaves them unauthenticated.
+	AdminToken string `envconfig:"ADMIN_TOKEN"`
+
+	// AdminListenAddr is the listener serving the /admin endpoints, apart
+	// from proxied traffic (empty = admin endpoints disabled)
+	AdminListenAddr string `envconfig:"ADMIN_LISTEN_ADDR" default:"127.0.0.1:8001"`
+
+	// FaultInjectionEnabled allows the fault-injection plugin to load.
+	// Must stay false in production.
+	FaultInjectionEnabled bool `envconfig:"FAULT_INJECTION_ENABLED" default:"false"`
+
+	// Encryption at rest for sensitive config values
+	Encryption EncryptionConfig
+
+	// Signing keys for gateway-issued tokens (token-exchange plugin)
+	TokenMint TokenMintConfig
+
+	// Secrets for time-limited signed URLs (signed-url plugin)
+	SignedURL SignedURLConfig
+
+	// Per-route SLO tracking (objectives are declared on routes)
+	SLO SLOConfig
+
+	// Webhook notifications for gateway events
+	Notify NotifyConfig
+
+	// In-memory API key cache on the auth path
+	APIKeyCache APIKeyCacheConfig
+
+	// Per-consumer usage rollups for billing and reporting
+	Usage UsageConfig
+
+	// Traffic anomaly detection (per-consumer/IP baselines)
+	Anomaly AnomalyConfig
+
+	// Cluster membership: peer heartbeats in Redis (GET /admin/cluster)
+	Cluster ClusterConfig
+
+	// Billing records for routes with the metering plugin
+	Metering MeteringConfig
+
+	// Rate limit keyspace reporting and idle key expiry in Redis
+	RateLimitKeys RateLimitKeysConfig
+
+	// Per-request plugin decision records (which plugins ran, what they
+	// decided, how long they took)
+	DecisionLog DecisionLogConfig
+
+	// MetricsMaxTags bounds the tag label of gateway_tag_* metrics: the
+	// first MetricsMaxTags distinct route/service tags seen get their own
+	// series, later ones are counted as "other" (0 = no tag metrics)
+	MetricsMaxTags int `envconfig:"METRICS_MAX_TAGS" default:"50"`
+
+	// Feature flags gating plugin instances ("enabled_flag")
+	FeatureFlags FeatureFlagsConfig
+
+	// Worker pool for AfterResponse plugins marked "async"
+	PluginAsync PluginAsyncConfig
+
+	// PluginMetadataDebug checks every context metadata write for
+	// conflicts between plugins (wrong type, overwritten by another plugin,
+	// unregistered flat keys) and logs them
+	PluginMetadataDebug bool `envconfig:"PLUGIN_METADATA_DEBUG" default:"false"`
+
+	// Memory budget and disk spill for bodies buffered by plugins
+	BodyBuffer BodyBufferConfig
+
+	// Shared response cache for the cache plugin
+	ResponseCache ResponseCacheConfig
+
+	// Forwarding headers added to proxied requests
+	ProxyHeaders ProxyHeadersConfig
+
+	// Request header size/count limits and problematic header handling
+	HeaderLimits HeaderLimitsConfig
+
+	// Request path canonicalization before routing
+	PathNormalization PathNormalizationConfig
+
+	// AutoOptions answers OPTIONS requests with 204 and an Allow header
+	// when routes serve the path but none accepts OPTIONS (routes that do,
+	// e.g. for the cors plugin's preflights, are proxied as usual).
+	AutoOptions bool `envconfig:"AUTO_OPTIONS" default:"false"`
+
+	// DefaultService (ID, name or workspace/name) receives requests that match no route,
+	// e.g. a legacy backend during a migration. Empty returns 404.
+	DefaultService string `envconfig:"DEFAULT_SERVICE"`
+
+	// Tenant resolution for services templated per tenant
+	Tenant TenantConfig
+
+	// Profiling and runtime debug endpoints under /admin/debug/
+	Debug DebugConfig
+
+	// TrustedProxies lists the proxies (CIDRs, IPs, or "private"/"loopback")
+	// whose X-Forwarded-For headers are honored. Empty trusts no one: the
+	// client IP is the connecting peer.
+	TrustedProxies []string `envconfig:"TRUSTED_PROXIES"`
+}
+
+// UpstreamConfig holds the proxy's HTTP transport settings.
+//
+// Every setting except InsecureSkipVerify can be overridden at runtime by a
+// gateway_settings row keyed by the lower-cased variable name, e.g.
+// upstream_dial_timeout.
+type UpstreamConfig struct {
+	// Connection pools
+	MaxIdleConns        int `envconfig:"UPSTREAM_MAX_IDLE_CONNS" default:"100"`
+	MaxIdleConnsPerHost int `envconfig:"UPSTREAM_MAX_IDLE_CONNS_PER_HOST" default:"10"`
+	MaxConnsPerHost     int `envconfig:"UPSTREAM_MAX_CONNS_PER_HOST" default:"100"` // 0 = unlimited
+
+	// Timeouts
+	DialTimeout           time.Duration `envconfig:"UPSTREAM_DIAL_TIMEOUT" default:"30s"`
+	KeepAlive             time.Duration `envconfig:"UPSTREAM_KEEP_ALIVE" default:"30s"`
+	IdleConnTimeout       time.Duration `envconfig:"UPSTREAM_IDLE_CONN_TIMEOUT" default:"90s"`
+	TLSHandshakeTimeout   time.Duration `envconfig:"UPSTREAM_TLS_HANDSHAKE_TIMEOUT" default:"10s"`
+	ResponseHeaderTimeout time.Duration `envconfig:"UPSTREAM_RESPONSE_HEADER_TIMEOUT" default:"30s"`
+	ExpectContinueTimeout time.Duration `envconfig:"UPSTREAM_EXPECT_CONTINUE_TIMEOUT" default:"1s"`
+
+	// InsecureSkipVerify disables upstream TLS certificate verification
+	InsecureSkipVerify bool `envconfig:"UPSTREAM_INSECURE_SKIP_VERIFY" default:"false"`
+
+	// IPFamily picks the address family dialed first: auto, ipv4, ipv6,
+	// prefer_ipv4 or prefer_ipv6 (services may override it)
+	IPFamily string `envconfig:"UPSTREAM_IP_FAMILY" default:"auto"`
+
+	// HappyEyeballsDelay is how long a dial waits on the first family
+	// before racing the other (0 = one after the other)
+	HappyEyeballsDelay time.Duration `envconfig:"UPSTREAM_HAPPY_EYEBALLS_DELAY" default:"300ms"`
+}
+
+// ListenerConfig holds timeouts and connection limits for the client
+// listener (see package connlimit). Zero limits are not enforced.
+type ListenerConfig struct {
+	// ReadHeaderTimeout closes connections that don't send request headers
+	// in time (slowloris)
+	ReadHeaderTimeout time.Duration `envconfig:"READ_HEADER_TIMEOUT" default:"5s"`
+
+	// ReadTimeout bounds reading a whole request, body included. Requests
+	// matched to a route use their deadline instead (see internal/deadline)
+	ReadTimeout time.Duration `envconfig:"READ_TIMEOUT" default:"15s"`
+
+	// WriteTimeout bounds writing the response. Requests matched to a
+	// route use their deadline instead (see internal/deadline)
+	WriteTimeout time.Duration `envconfig:"WRITE_TIMEOUT" default:"15s"`
+
+	// IdleTimeout closes keep-alive connections idle for this long
+	IdleTimeout time.Duration `envconfig:"IDLE_TIMEOUT" default:"60s"`
+
+	// MaxConnections limits concurrent client connections
+	MaxConnections int `envconfig:"MAX_CONNECTIONS" default:"0"`
+
+	// MaxConnectionsPerIP limits concurrent connections from one peer
+	// address (the load balancer, when behind one)
+	MaxConnectionsPerIP int `envconfig:"MAX_CONNECTIONS_PER_IP" default:"0"`
+}
+
+// ProxyHeadersConfig holds configuration for Via / Forwarded headers.
+type ProxyHeadersConfig struct {
+	// Via is the pseudonym in Via headers (empty = no Via header)
+	Via string `envconfig:"PROXY_VIA" default:"switchboard"`
+
+	// Forwarded adds an RFC 7239 Forwarded header alongside X-Forwarded-*
+	Forwarded bool `envconfig:"PROXY_FORWARDED_HEADER" default:"true"`
+}
+
+// DecisionLogConfig holds configuration for plugin decision records.
+type DecisionLogConfig struct {
+	// Enabled logs one JSON line per request with every plugin decision
+	Enabled bool `envconfig:"DECISION_LOG_ENABLED" default:"false"`
+
+	// Header sends the decisions upstream in X-Gateway-Decisions (debugging
+	// only; not allowed in production)
+	Header bool `envconfig:"DECISION_LOG_HEADER" default:"false"`
+}
+
+// FeatureFlagsConfig holds configuration for the feature flags that gate
+// plugin instances (see package featureflag).
+type FeatureFlagsConfig struct {
+	// Provider is "env" (FEATURE_FLAG_<NAME> variables) or "http" (a flag
+	// service polled every RefreshInterval)
+	Provider  string `envconfig:"FEATURE_FLAGS_PROVIDER" default:"env"`
+	EnvPrefix string `envconfig:"FEATURE_FLAGS_ENV_PREFIX" default:"FEATURE_FLAG_"`
+
+	URL             string        `envconfig:"FEATURE_FLAGS_URL"`
+	Token           string        `envconfig:"FEATURE_FLAGS_TOKEN"` // sent as a bearer token
+	RefreshInterval time.Duration `envconfig:"FEATURE_FLAGS_REFRESH_INTERVAL" default:"30s"`
+	Timeout         time.Duration `envconfig:"FEATURE_FLAGS_TIMEOUT" default:"5s"`
+}
+
+// PluginAsyncConfig holds the worker pool running the AfterResponse phase
+// of plugin instances configured with "async": true.
+type PluginAsyncConfig struct {
+	// Workers is the number of async plugin jobs run at once (0 = async
+	// plugins can't be loaded)
+	Workers int `envconfig:"PLUGIN_ASYNC_WORKERS" default:"4"`
+
+	// QueueSize is the number of jobs queued per plugin instance, unless
+	// the instance sets "async_queue_size"
+	QueueSize int `envconfig:"PLUGIN_ASYNC_QUEUE_SIZE" default:"1000"`
+}
+
+// BodyBufferConfig bounds the request and response bodies plugins buffer
+// (see package bodybuffer).
+type BodyBufferConfig struct {
+	// MaxMemoryBytes is the most body bytes buffered in memory across all
+	// requests in flight (0 = no limit)
+	MaxMemoryBytes int64 `envconfig:"BODY_BUFFER_MAX_MEMORY_BYTES" default:"268435456"`
+
+	// SpillDir holds bodies written to disk (empty = never spill)
+	SpillDir string `envconfig:"BODY_BUFFER_SPILL_DIR"`
+
+	// SpillThresholdBytes is the size above which bodies that can be
+	// streamed from disk are spilled (0 = only when memory runs out)
+	SpillThresholdBytes int64 `envconfig:"BODY_BUFFER_SPILL_THRESHOLD_BYTES" default:"1048576"`
+}
+
+// HeaderLimitsConfig holds gateway-wide request header limits (see package
+// headerlimit). Zero limits are not enforced.
+type HeaderLimitsConfig struct {
+	// MaxBytes limits the total header size (also http.Server.MaxHeaderBytes)
+	MaxBytes int `envconfig:"MAX_HEADER_BYTES" default:"65536"`
+
+	// MaxCount limits the number of header lines
+	MaxCount int `envconfig:"MAX_HEADER_COUNT" default:"100"`
+
+	// MaxValueBytes limits a single header value
+	MaxValueBytes int `envconfig:"MAX_HEADER_VALUE_BYTES" default:"0"`
+
+	// Oversized is "reject" (431) or "strip" for values over MaxValueBytes
+	Oversized string `envconfig:"OVERSIZED_HEADERS" default:"reject"`
+
+	// SingleValued headers may only appear once
+	SingleValued []string `envconfig:"SINGLE_VALUED_HEADERS" default:"Authorization,Content-Length,Content-Type,Transfer-Encoding,X-Forwarded-Host,X-Forwarded-Proto,X-Real-IP"`
+
+	// Duplicates is "reject" (400) or "strip" (keep the first value)
+	Duplicates string `envconfig:"DUPLICATE_HEADERS" default:"reject"`
+}
+
+// PathNormalizationConfig holds configuration for request path
+// normalization (see package pathnorm).
+type PathNormalizationConfig struct {
+	// EncodedSlashes is "reject" (400) or "decode" (%2F splits segments)
+	EncodedSlashes string `envconfig:"PATH_ENCODED_SLASHES" default:"reject"`
+
+	// DotSegments is "reject" (400) or "resolve" ("/a/../b" -> "/b")
+	DotSegments string `envconfig:"PATH_DOT_SEGMENTS" default:"reject"`
+
+	// MergeSlashes collapses duplicate slashes ("/a//b" -> "/a/b")
+	MergeSlashes bool `envconfig:"PATH_MERGE_SLASHES" default:"true"`
+}
+
+// TenantConfig holds configuration for tenant-aware routing (see package
+// tenant).
+type TenantConfig struct {
+	// Source is "header", "subdomain" or empty (disabled)
+	Source string `envconfig:"TENANT_SOURCE"`
+
+	// Header carries the tenant ID when Source is "header"
+	Header string `envconfig:"TENANT_HEADER" default:"X-Tenant-ID"`
+
+	// BaseDomain is the domain tenants are subdomains of when Source is
+	// "subdomain", e.g. example.com for acme.example.com
+	BaseDomain string `envconfig:"TENANT_BASE_DOMAIN"`
+}
+
+// EgressConfig holds the egress policy for upstream connections (SSRF
+// protection for templated and plugin-chosen upstreams).
+type EgressConfig struct {
+	// Enabled refuses connections to cloud metadata and internal addresses
+	// outside AllowCIDRs
+	Enabled bool `envconfig:"EGRESS_POLICY_ENABLED" default:"false"`
+
+	// AllowHosts limits upstream hosts ("api.example.com",
+	// "*.svc.example.com"); empty allows any host
+	AllowHosts []string `envconfig:"EGRESS_ALLOW_HOSTS"`
+
+	// AllowCIDRs are internal ranges upstreams may be in (CIDRs, IPs, or
+	// "private"/"loopback")
+	AllowCIDRs []string `envconfig:"EGRESS_ALLOW_CIDRS"`
+
+	// DenyCIDRs are further ranges to refuse
+	DenyCIDRs []string `envconfig:"EGRESS_DENY_CIDRS"`
+}
+
+// DNSCacheConfig holds configuration for the upstream DNS cache. Answers
+// are kept for their TTL, bounded by MinTTL and MaxTTL.
+type DNSCacheConfig struct {
+	Enabled     bool          `envconfig:"DNS_CACHE_ENABLED" default:"false"`
+	MinTTL      time.Duration `envconfig:"DNS_CACHE_MIN_TTL" default:"5s"`
+	MaxTTL      time.Duration `envconfig:"DNS_CACHE_MAX_TTL" default:"5m"`
+	NegativeTTL time.Duration `envconfig:"DNS_CACHE_NEGATIVE_TTL" default:"5s"`
+}
+
+// DebugConfig holds configuration for the /admin/debug/ endpoints.
+type DebugConfig struct {
+	// Enabled serves pprof, expvar and goroutine dumps under /admin/debug/
+	// on the admin listener (requires ADMIN_TOKEN in every environment)
+	Enabled bool `envconfig:"DEBUG_ENDPOINTS_ENABLED" default:"false"`
+
+	// BlockProfileRate samples one blocking event per this many
+	// nanoseconds blocked (0 = block profiling off)
+	BlockProfileRate int `envconfig:"DEBUG_BLOCK_PROFILE_RATE" default:"0"`
+
+	// MutexProfileFraction samples 1 in this many mutex contention events
+	// (0 = mutex profiling off)
+	MutexProfileFraction int `envconfig:"DEBUG_MUTEX_PROFILE_FRACTION" default:"0"`
+
+	// TimingTrustedIPs may ask for the X-Timing latency breakdown on any
+	// route with X-Debug-Timing: 1 (CIDRs, IPs, or "private"/"loopback")
+	TimingTrustedIPs []string `envconfig:"DEBUG_TIMING_TRUSTED_IPS"`
+}
+
+// NotifyConfig holds configuration for webhook event notifications.
+type NotifyConfig struct {
+	// WebhookURLs receive every event (empty = disabled)
+	WebhookURLs []string `envconfig:"NOTIFY_WEBHOOK_URLS"`
+
+	// Format is "json" or "slack" (Slack incoming-webhook payload)
+	Format string `envconfig:"NOTIFY_FORMAT" default:"json"`
+
+	// Secret signs deliveries (X-Gateway-Signature, HMAC-SHA256)
+	Secret string `envconfig:"NOTIFY_WEBHOOK_SECRET"`
+
+	// Events limits delivery to these event types (empty = all)
+	Events []string `envconfig:"NOTIFY_EVENTS"`
+
+	MaxRetries int           `envconfig:"NOTIFY_MAX_RETRIES" default:"3"`
+	Timeout    time.Duration `envconfig:"NOTIFY_TIMEOUT" default:"5s"`
+}
+
+// SLOConfig holds configuration for SLO evaluation and alerting.
+type SLOConfig struct {
+	// WebhookURL receives budget exhausted/recovered events (empty = log only)
+	WebhookURL string `envconfig:"SLO_WEBHOOK_URL"`
+
+	// EvaluationInterval is how often burn rates are evaluated (0 = never)
+	EvaluationInterval time.Duration `envconfig:"SLO_EVALUATION_INTERVAL" default:"30s"`
+}
+
+// APIKeyCacheConfig holds configuration for the API key lookup cache.
+type APIKeyCacheConfig struct {
+	// Enabled caches API key → consumer lookups and warms the cache at startup
+	Enabled bool `envconfig:"API_KEY_CACHE_ENABLED" default:"false"`
+
+	// Size is the most keys cached (and preloaded)
+	Size int `envconfig:"API_KEY_CACHE_SIZE" default:"10000"`
+
+	// TTL is how long a cached key is trusted without a refresh
+	TTL time.Duration `envconfig:"API_KEY_CACHE_TTL" default:"5m"`
+
+	// NegativeTTL is how long unknown keys are remembered (0 = never)
+	NegativeTTL time.Duration `envconfig:"API_KEY_CACHE_NEGATIVE_TTL" default:"30s"`
+
+	// RefreshInterval reloads active keys (0 = warm once at startup)
+	RefreshInterval time.Duration `envconfig:"API_KEY_CACHE_REFRESH_INTERVAL" default:"1m"`
+}
+
+// UsageConfig holds configuration for per-consumer usage aggregation.
+type UsageConfig struct {
+	// FlushInterval is how often usage is written to Postgres (0 = disabled)
+	FlushInterval time.Duration `envconfig:"USAGE_FLUSH_INTERVAL" default:"1m"`
+
+	// RetentionDays deletes daily rollups older than this (0 = keep forever)
+	RetentionDays int `envconfig:"USAGE_RETENTION_DAYS" default:"0"`
+}
+
+// AnomalyConfig holds configuration for traffic anomaly detection (see
+// anomaly.Detector).
+type AnomalyConfig struct {
+	Enabled       bool          `envconfig:"ANOMALY_DETECTION_ENABLED" default:"false"`
+	Window        time.Duration `envconfig:"ANOMALY_WINDOW" default:"10s"`
+	Decay         float64       `envconfig:"ANOMALY_EWMA_DECAY" default:"0.2"`
+	SpikeFactor   float64       `envconfig:"ANOMALY_SPIKE_FACTOR" default:"10"`
+	MinRequests   int64         `envconfig:"ANOMALY_MIN_REQUESTS" default:"100"`
+	WarmupWindows int           `envconfig:"ANOMALY_WARMUP_WINDOWS" default:"6"`
+	ScanPaths     int           `envconfig:"ANOMALY_SCAN_PATHS" default:"50"` // 0 = no scan detection
+	Cooldown      time.Duration `envconfig:"ANOMALY_COOLDOWN" default:"5m"`
+	MaxSources    int           `envconfig:"ANOMALY_MAX_SOURCES" default:"100000"`
+}
+
+// ClusterConfig holds configuration for cluster membership (see
+// cluster.Membership). Heartbeats go to REDIS_URL.
+type ClusterConfig struct {
+	Enabled           bool          `envconfig:"CLUSTER_ENABLED" default:"false"`
+	Key               string        `envconfig:"CLUSTER_KEY" default:"gateway:cluster"`
+	HeartbeatInterval time.Duration `envconfig:"CLUSTER_HEARTBEAT_INTERVAL" default:"10s"`
+	PeerTTL           time.Duration `envconfig:"CLUSTER_PEER_TTL" default:"30s"` // drop peers silent this long
+}
+
+// RateLimitKeysConfig holds configuration for rate limit keyspace
+// maintenance (see ratelimit.KeyspaceMonitor).
+type RateLimitKeysConfig struct {
+	// Interval between keyspace scans (0 = disabled)
+	Interval time.Duration `envconfig:"RATELIMIT_KEYS_INTERVAL" default:"0"`
+
+	// RedisURL is the Redis the rate limit plugins use (their redis_url)
+	RedisURL string `envconfig:"RATELIMIT_KEYS_REDIS_URL" default:"redis://localhost:6379/0"`
+
+	// Prefix selects the rate limit keys (the plugins' key_prefix)
+	Prefix string `envconfig:"RATELIMIT_KEYS_PREFIX" default:"rate_limit:"`
+
+	// MaxScan caps the keys scanned per run
+	MaxScan int `envconfig:"RATELIMIT_KEYS_MAX_SCAN" default:"1000000"`
+
+	// MemorySamples is how many keys per prefix get MEMORY USAGE each run
+	MemorySamples int `envconfig:"RATELIMIT_KEYS_MEMORY_SAMPLES" default:"100"`
+
+	// ExpireIdle deletes keys idle longer than this (0 = never); keep it
+	// above the longest rate limit window
+	ExpireIdle time.Duration `envconfig:"RATELIMIT_KEYS_EXPIRE_IDLE" default:"0"`
+
+	// ExpireTypes limits expiry to these identifier types (empty = all)
+	ExpireTypes []string `envconfig:"RATELIMIT_KEYS_EXPIRE_TYPES" default:"ip"`
+}
+
+// MeteringConfig holds configuration for billing record delivery.
+type MeteringConfig struct {
+	// Sink is "webhook" or "kafka-rest" (empty = metering disabled)
+	Sink string `envconfig:"METERING_SINK"`
+
+	// URL is the webhook URL or the Kafka REST proxy base URL
+	URL string `envconfig:"METERING_URL"`
+
+	// Topic is the Kafka topic (kafka-rest sink)
+	Topic string `envconfig:"METERING_TOPIC" default:"gateway.metering"`
+
+	// Secret signs webhook deliveries (X-Gateway-Signature)
+	Secret string `envconfig:"METERING_WEBHOOK_SECRET"`
+
+	BatchSize     int           `envconfig:"METERING_BATCH_SIZE" default:"500"`
+	FlushInterval time.Duration `envconfig:"METERING_FLUSH_INTERVAL" default:"5s"`
+	MaxRetries    int           `envconfig:"METERING_MAX_RETRIES" default:"3"`
+
+	// QueueSize bounds records waiting for the sink; the oldest are dropped
+	// when it is full
+	QueueSize int `envconfig:"METERING_QUEUE_SIZE" default:"10000"`
+
+	// SpoolDir holds batches the sink could not accept (empty = drop them)
+	SpoolDir      string `envconfig:"METERING_SPOOL_DIR"`
+	SpoolMaxBytes int64  `envconfig:"METERING_SPOOL_MAX_BYTES" default:"1073741824"`
+}
+
+// EncryptionConfig holds configuration for field-level encryption at rest.
+type EncryptionConfig struct {
+	// Key is the 32-byte AES key, base64 or hex encoded (empty = disabled)
+	Key string `envconfig:"CONFIG_ENCRYPTION_KEY"`
+
+	// KeyFile reads the key from a file instead (e.g. a KMS-decrypted secret mount)
+	KeyFile string `envconfig:"CONFIG_ENCRYPTION_KEY_FILE"`
+
+	// Fields are the plugin config keys encrypted by the admin API and by
+	// `gateway encrypt-config` (matched case-insensitively, at any depth)
+	Fields []string `envconfig:"CONFIG_ENCRYPTED_FIELDS" default:"secret,password,api_key,private_key,client_secret,token,credentials,bind_password"`
+
+	// APIKeyPepper is mixed into API key hashes (HMAC-SHA256). May itself be
+	// an encrypted "enc:v1:" value.
+	APIKeyPepper string `envconfig:"API_KEY_PEPPER"`
+}
+
+// TokenMintConfig holds the keys internal tokens are signed with.
+type TokenMintConfig struct {
+	// KeyFiles are PEM private keys (Ed25519, ECDSA P-256 or RSA). The
+	// first signs; all are published at /.well-known/gateway-jwks.json
+	// (empty = token-exchange disabled)
+	KeyFiles []string `envconfig:"TOKEN_MINT_KEY_FILES"`
+
+	// Issuer is the "iss" claim of minted tokens
+	Issuer string `envconfig:"TOKEN_MINT_ISSUER" default:"switchboard-gateway"`
+
+	// ReloadInterval re-reads the key files for rotation (0 = startup only)
+	ReloadInterval time.Duration `envconfig:"TOKEN_MINT_KEY_RELOAD_INTERVAL" default:"1m"`
+}
+
+// SignedURLConfig holds the secrets signed URLs are signed with.
+type SignedURLConfig struct {
+	// Secrets are HMAC keys; the first signs, all verify (empty =
+	// signed-url disabled)
+	Secrets []string `envconfig:"SIGNED_URL_SECRETS"`
+
+	// MaxTTL is the longest lifetime of a signed URL
+	MaxTTL time.Duration `envconfig:"SIGNED_URL_MAX_TTL" default:"24h"`
+}
+
+// ResponseCacheConfig sizes the response cache the cache plugin stores
+// responses in (see package respcache).
+type ResponseCacheConfig struct {
+	// MaxBytes bounds the responses held in memory (0 = cache plugin
+	// disabled)
+	MaxBytes int64 `envconfig:"CACHE_MAX_BYTES" default:"67108864"`
+}
+
+// AdmissionConfig holds configuration for priority-based admission control.
+type AdmissionConfig struct {
+	MaxConcurrent int           `envconfig:"ADMISSION_MAX_CONCURRENT" default:"0"` // 0 = disabled
+	MaxQueue      int           `envconfig:"ADMISSION_MAX_QUEUE" default:"100"`
+	QueueTimeout  time.Duration `envconfig:"ADMISSION_QUEUE_TIMEOUT" default:"1s"`
+}
+
+// OutlierDetectionConfig holds configuration for per-target outlier detection.
+type OutlierDetectionConfig struct {
+	Enabled            bool          `envconfig:"OUTLIER_DETECTION_ENABLED" default:"true"`
+	ErrorRateThreshold float64       `envconfig:"OUTLIER_ERROR_RATE_THRESHOLD" default:"0.5"`
+	LatencyFactor      float64       `envconfig:"OUTLIER_LATENCY_FACTOR" default:"3.0"`
+	MinLatency         time.Duration `envconfig:"OUTLIER_MIN_LATENCY" default:"100ms"`
+	MinRequests        int64         `envconfig:"OUTLIER_MIN_REQUESTS" default:"20"`
+	Decay              float64       `envconfig:"OUTLIER_EWMA_DECAY" default:"0.1"`
+	BaseEjectionTime   time.Duration `envconfig:"OUTLIER_BASE_EJECTION_TIME" default:"30s"`
+	MaxEjectionTime    time.Duration `envconfig:"OUTLIER_MAX_EJECTION_TIME" default:"5m"`
+	MaxEjectionPercent int           `envconfig:"OUTLIER_MAX_EJECTION_PERCENT" default:"50"`
+}
+
+// HedgeBudgetConfig caps hedged requests across all routes: at most Ratio
+// of hedge-eligible requests, plus MinPerSecond, are hedged.
+type HedgeBudgetConfig struct {
+	Ratio        float64 `envconfig:"HEDGE_BUDGET_RATIO" default:"0.1"`
+	MinPerSecond float64 `envconfig:"HEDGE_BUDGET_MIN_PER_SECOND" default:"1"`
 }
 
 // DatabaseConfig holds database-specific configuration.
@@ -53,6 +619,19 @@ type DatabaseConfig struct {
 
 	// Connection timeout
 	ConnectTimeout time.Duration `envconfig:"DB_CONNECT_TIMEOUT" default:"10s"`
+
+	// StatementCache prepares repository reads once per connection (disable
+	// behind PgBouncer in transaction pooling mode)
+	StatementCache bool `envconfig:"DB_STATEMENT_CACHE" default:"true"`
+
+	// ReplicaDSNs are read replicas for repository reads (empty = primary only)
+	ReplicaDSNs []string `envconfig:"POSTGRES_REPLICA_DSNS"`
+
+	// ReplicaCheckInterval is how often replicas are health-checked
+	ReplicaCheckInterval time.Duration `envconfig:"DB_REPLICA_CHECK_INTERVAL" default:"10s"`
+
+	// ReplicaMaxLag takes replicas further behind out of rotation (0 = no limit)
+	ReplicaMaxLag time.Duration `envconfig:"DB_REPLICA_MAX_LAG" default:"0"`
 }
 
 // Load loads configuration from environment variables.
@@ -104,6 +683,28 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid server port: %d (must be between 1 and 65535)", c.ServerPort)
 	}
 
+	// Validate TLS settings (cert and key go together)
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	if c.HTTP3Enabled && !c.TLSEnabled() {
+		return fmt.Errorf("HTTP/3 requires TLS (set TLS_CERT_FILE and TLS_KEY_FILE)")
+	}
+
+	// Validate listener settings
+	if c.Listener.ReadHeaderTimeout < 0 || c.Listener.ReadTimeout < 0 || c.Listener.WriteTimeout < 0 || c.Listener.IdleTimeout < 0 {
+		return fmt.Errorf("READ_HEADER_TIMEOUT, READ_TIMEOUT, WRITE_TIMEOUT and IDLE_TIMEOUT cannot be negative")
+	}
+	if c.Listener.MaxConnections < 0 || c.Listener.MaxConnectionsPerIP < 0 {
+		return fmt.Errorf("MAX_CONNECTIONS and MAX_CONNECTIONS_PER_IP cannot be negative")
+	}
+
+	// Validate shutdown phases
+	if c.ShutdownTimeout < 0 || c.ShutdownDrainDelay < 0 || c.ShutdownCloseTimeout < 0 {
+		return fmt.Errorf("SHUTDOWN_TIMEOUT, SHUTDOWN_DRAIN_DELAY and SHUTDOWN_CLOSE_TIMEOUT cannot be negative")
+	}
+
 	// Validate log level
 	validLogLevels := map[string]bool{
 		"debug": true,
@@ -140,6 +741,283 @@ func (c *Config) Validate() error {
 			c.Database.MaxIdleConns, c.Database.MaxOpenConns)
 	}
 
+	// Fault injection is for chaos testing only
+	if c.FaultInjectionEnabled && c.IsProduction() {
+		return fmt.Errorf("FAULT_INJECTION_ENABLED cannot be used in production")
+	}
+
+	// Decision headers expose plugin internals to backends
+	if c.DecisionLog.Header && c.IsProduction() {
+		return fmt.Errorf("DECISION_LOG_HEADER cannot be used in production")
+	}
+
+	if c.MetricsMaxTags < 0 {
+		return fmt.Errorf("METRICS_MAX_TAGS cannot be negative")
+	}
+
+	// The admin endpoints fail closed: outside development they need a token
+	if c.AdminListenAddr != "" {
+		if _, _, err := net.SplitHostPort(c.AdminListenAddr); err != nil {
+			return fmt.Errorf("invalid ADMIN_LISTEN_ADDR %q: %w", c.AdminListenAddr, err)
+		}
+		if c.AdminToken == "" && !c.IsDevelopment() {
+			return fmt.Errorf("ADMIN_TOKEN is required outside development (or set ADMIN_LISTEN_ADDR empty to disable the admin endpoints)")
+		}
+	}
+
+	// Profiles and goroutine dumps expose internals and cost CPU
+	if c.Debug.Enabled && (c.AdminToken == "" || c.AdminListenAddr == "") {
+		return fmt.Errorf("DEBUG_ENDPOINTS_ENABLED requires ADMIN_TOKEN and ADMIN_LISTEN_ADDR")
+	}
+	if c.Debug.BlockProfileRate < 0 {
+		return fmt.Errorf("DEBUG_BLOCK_PROFILE_RATE cannot be negative")
+	}
+	if c.Debug.MutexProfileFraction < 0 {
+		return fmt.Errorf("DEBUG_MUTEX_PROFILE_FRACTION cannot be negative")
+	}
+
+	// Validate encryption key source
+	if c.Encryption.Key != "" && c.Encryption.KeyFile != "" {
+		return fmt.Errorf("CONFIG_ENCRYPTION_KEY and CONFIG_ENCRYPTION_KEY_FILE cannot both be set")
+	}
+
+	// Validate token minting
+	if len(c.TokenMint.KeyFiles) > 0 && c.TokenMint.Issuer == "" {
+		return fmt.Errorf("TOKEN_MINT_ISSUER cannot be empty")
+	}
+	if c.TokenMint.ReloadInterval < 0 {
+		return fmt.Errorf("TOKEN_MINT_KEY_RELOAD_INTERVAL cannot be negative")
+	}
+
+	// Validate signed URLs
+	for _, secret := range c.SignedURL.Secrets {
+		if len(secret) < 32 {
+			return fmt.Errorf("SIGNED_URL_SECRETS entries must be at least 32 characters")
+		}
+	}
+	if len(c.SignedURL.Secrets) > 0 && c.SignedURL.MaxTTL <= 0 {
+		return fmt.Errorf("SIGNED_URL_MAX_TTL must be positive")
+	}
+
+	// Validate upstream transport settings
+	if c.Upstream.MaxIdleConns < 0 || c.Upstream.MaxIdleConnsPerHost < 0 || c.Upstream.MaxConnsPerHost < 0 {
+		return fmt.Errorf("UPSTREAM_MAX_* connection limits cannot be negative")
+	}
+	if c.Upstream.DialTimeout < 0 || c.Upstream.KeepAlive < 0 || c.Upstream.IdleConnTimeout < 0 ||
+		c.Upstream.TLSHandshakeTimeout < 0 || c.Upstream.ResponseHeaderTimeout < 0 || c.Upstream.ExpectContinueTimeout < 0 ||
+		c.Upstream.HappyEyeballsDelay < 0 {
+		return fmt.Errorf("UPSTREAM_* timeouts cannot be negative")
+	}
+	switch c.Upstream.IPFamily {
+	case "", "auto", "ipv4", "ipv6", "prefer_ipv4", "prefer_ipv6":
+	default:
+		return fmt.Errorf("UPSTREAM_IP_FAMILY must be auto, ipv4, ipv6, prefer_ipv4 or prefer_ipv6, got %q", c.Upstream.IPFamily)
+	}
+	if c.UpstreamSlowDialThreshold < 0 || c.UpstreamSlowTTFBThreshold < 0 {
+		return fmt.Errorf("UPSTREAM_SLOW_* thresholds cannot be negative")
+	}
+
+	// Validate tenant resolution
+	switch c.Tenant.Source {
+	case "", "header":
+	case "subdomain":
+		if c.Tenant.BaseDomain == "" {
+			return fmt.Errorf("TENANT_BASE_DOMAIN is required when TENANT_SOURCE is subdomain")
+		}
+	default:
+		return fmt.Errorf("invalid TENANT_SOURCE: %s (must be header or subdomain)", c.Tenant.Source)
+	}
+
+	// Validate SLO evaluation interval
+	if c.SLO.EvaluationInterval < 0 {
+		return fmt.Errorf("SLO_EVALUATION_INTERVAL cannot be negative")
+	}
+
+	// Validate API key cache
+	if c.APIKeyCache.Enabled {
+		if c.APIKeyCache.Size <= 0 {
+			return fmt.Errorf("API_KEY_CACHE_SIZE must be positive")
+		}
+		if c.APIKeyCache.TTL <= 0 {
+			return fmt.Errorf("API_KEY_CACHE_TTL must be positive")
+		}
+		if c.APIKeyCache.NegativeTTL < 0 || c.APIKeyCache.RefreshInterval < 0 {
+			return fmt.Errorf("API_KEY_CACHE_NEGATIVE_TTL and API_KEY_CACHE_REFRESH_INTERVAL cannot be negative")
+		}
+	}
+
+	// Validate read replicas
+	if len(c.Database.ReplicaDSNs) > 0 {
+		if c.Database.ReplicaCheckInterval <= 0 {
+			return fmt.Errorf("DB_REPLICA_CHECK_INTERVAL must be positive")
+		}
+		if c.Database.ReplicaMaxLag < 0 {
+			return fmt.Errorf("DB_REPLICA_MAX_LAG cannot be negative")
+		}
+	}
+
+	// Validate usage aggregation
+	if c.Usage.FlushInterval < 0 {
+		return fmt.Errorf("USAGE_FLUSH_INTERVAL cannot be negative")
+	}
+	if c.Usage.RetentionDays < 0 {
+		return fmt.Errorf("USAGE_RETENTION_DAYS cannot be negative")
+	}
+
+	// Validate anomaly detection settings
+	if c.Anomaly.Enabled {
+		a := c.Anomaly
+		if a.Window <= 0 {
+			return fmt.Errorf("ANOMALY_WINDOW must be positive")
+		}
+		if a.Decay <= 0 || a.Decay > 1 {
+			return fmt.Errorf("invalid anomaly EWMA decay: %.2f (must be between 0 and 1)", a.Decay)
+		}
+		if a.SpikeFactor <= 1 {
+			return fmt.Errorf("invalid anomaly spike factor: %.2f (must be greater than 1)", a.SpikeFactor)
+		}
+		if a.MinRequests < 0 || a.WarmupWindows < 0 || a.ScanPaths < 0 || a.Cooldown < 0 || a.MaxSources < 0 {
+			return fmt.Errorf("anomaly detection limits cannot be negative")
+		}
+	}
+
+	// Validate feature flags
+	switch c.FeatureFlags.Provider {
+	case "", "env":
+	case "http":
+		if c.FeatureFlags.URL == "" {
+			return fmt.Errorf("FEATURE_FLAGS_URL is required with FEATURE_FLAGS_PROVIDER=http")
+		}
+		if c.FeatureFlags.RefreshInterval <= 0 || c.FeatureFlags.Timeout <= 0 {
+			return fmt.Errorf("FEATURE_FLAGS_REFRESH_INTERVAL and FEATURE_FLAGS_TIMEOUT must be positive")
+		}
+	default:
+		return fmt.Errorf("invalid FEATURE_FLAGS_PROVIDER: %s (must be env or http)", c.FeatureFlags.Provider)
+	}
+
+	if c.PluginAsync.Workers < 0 || c.PluginAsync.QueueSize < 0 {
+		return fmt.Errorf("PLUGIN_ASYNC_WORKERS and PLUGIN_ASYNC_QUEUE_SIZE cannot be negative")
+	}
+	if c.BodyBuffer.MaxMemoryBytes < 0 || c.BodyBuffer.SpillThresholdBytes < 0 {
+		return fmt.Errorf("BODY_BUFFER_MAX_MEMORY_BYTES and BODY_BUFFER_SPILL_THRESHOLD_BYTES cannot be negative")
+	}
+	if c.ResponseCache.MaxBytes < 0 {
+		return fmt.Errorf("CACHE_MAX_BYTES cannot be negative")
+	}
+
+	// Validate cluster membership
+	if c.Cluster.Enabled {
+		if c.Cluster.HeartbeatInterval <= 0 {
+			return fmt.Errorf("CLUSTER_HEARTBEAT_INTERVAL must be positive")
+		}
+		if c.Cluster.PeerTTL <= c.Cluster.HeartbeatInterval {
+			return fmt.Errorf("CLUSTER_PEER_TTL must be longer than CLUSTER_HEARTBEAT_INTERVAL")
+		}
+	}
+
+	// Validate rate limit keyspace maintenance
+	if c.RateLimitKeys.Interval < 0 {
+		return fmt.Errorf("RATELIMIT_KEYS_INTERVAL cannot be negative")
+	}
+	if c.RateLimitKeys.MaxScan < 0 {
+		return fmt.Errorf("RATELIMIT_KEYS_MAX_SCAN cannot be negative")
+	}
+	if c.RateLimitKeys.MemorySamples < 0 {
+		return fmt.Errorf("RATELIMIT_KEYS_MEMORY_SAMPLES cannot be negative")
+	}
+	if c.RateLimitKeys.ExpireIdle < 0 {
+		return fmt.Errorf("RATELIMIT_KEYS_EXPIRE_IDLE cannot be negative")
+	}
+	for _, t := range c.RateLimitKeys.ExpireTypes {
+		switch t {
+		case "ip", "apikey", "consumer":
+		default:
+			return fmt.Errorf("invalid RATELIMIT_KEYS_EXPIRE_TYPES entry: %s (must be ip, apikey or consumer)", t)
+		}
+	}
+
+	// Validate header limits
+	if c.HeaderLimits.MaxBytes < 0 || c.HeaderLimits.MaxCount < 0 || c.HeaderLimits.MaxValueBytes < 0 {
+		return fmt.Errorf("MAX_HEADER_BYTES, MAX_HEADER_COUNT and MAX_HEADER_VALUE_BYTES cannot be negative")
+	}
+	switch c.HeaderLimits.Oversized {
+	case "", "reject", "strip":
+	default:
+		return fmt.Errorf("invalid OVERSIZED_HEADERS: %s (must be reject or strip)", c.HeaderLimits.Oversized)
+	}
+	switch c.HeaderLimits.Duplicates {
+	case "", "reject", "strip":
+	default:
+		return fmt.Errorf("invalid DUPLICATE_HEADERS: %s (must be reject or strip)", c.HeaderLimits.Duplicates)
+	}
+
+	// Validate metering
+	switch c.Metering.Sink {
+	case "":
+	case "webhook", "kafka-rest":
+		if c.Metering.URL == "" {
+			return fmt.Errorf("METERING_URL is required when METERING_SINK is set")
+		}
+	default:
+		return fmt.Errorf("invalid METERING_SINK: %s (must be webhook or kafka-rest)", c.Metering.Sink)
+	}
+
+	// Validate path normalization
+	switch c.PathNormalization.EncodedSlashes {
+	case "", "reject", "decode":
+	default:
+		return fmt.Errorf("invalid PATH_ENCODED_SLASHES: %s (must be reject or decode)", c.PathNormalization.EncodedSlashes)
+	}
+	switch c.PathNormalization.DotSegments {
+	case "", "reject", "resolve":
+	default:
+		return fmt.Errorf("invalid PATH_DOT_SEGMENTS: %s (must be reject or resolve)", c.PathNormalization.DotSegments)
+	}
+
+	// Validate notification settings
+	if c.Notify.Format != "" && c.Notify.Format != "json" && c.Notify.Format != "slack" {
+		return fmt.Errorf("invalid NOTIFY_FORMAT: %s (must be json or slack)", c.Notify.Format)
+	}
+	if c.Notify.MaxRetries < 0 {
+		return fmt.Errorf("NOTIFY_MAX_RETRIES cannot be negative")
+	}
+
+	// Validate admission control settings
+	if c.Admission.MaxConcurrent < 0 || c.Admission.MaxQueue < 0 {
+		return fmt.Errorf("admission max concurrent and max queue cannot be negative")
+	}
+
+	// Validate outlier detection settings
+	if c.OutlierDetection.Enabled {
+		od := c.OutlierDetection
+		if od.ErrorRateThreshold < 0 || od.ErrorRateThreshold > 1 {
+			return fmt.Errorf("invalid outlier error rate threshold: %.2f (must be between 0 and 1)", od.ErrorRateThreshold)
+		}
+
+		if od.Decay < 0 || od.Decay > 1 {
+			return fmt.Errorf("invalid outlier EWMA decay: %.2f (must be between 0 and 1)", od.Decay)
+		}
+
+		if od.MaxEjectionPercent < 0 || od.MaxEjectionPercent > 100 {
+			return fmt.Errorf("invalid outlier max ejection percent: %d (must be between 0 and 100)", od.MaxEjectionPercent)
+		}
+	}
+
+	// Validate the hedge budget
+	if c.HedgeBudget.Ratio < 0 || c.HedgeBudget.Ratio > 1 {
+		return fmt.Errorf("invalid hedge budget ratio: %.2f (must be between 0 and 1)", c.HedgeBudget.Ratio)
+	}
+	if c.HedgeBudget.MinPerSecond < 0 {
+		return fmt.Errorf("HEDGE_BUDGET_MIN_PER_SECOND cannot be negative")
+	}
+
+	if c.DNSCache.MinTTL < 0 || c.DNSCache.NegativeTTL < 0 {
+		return fmt.Errorf("DNS_CACHE_MIN_TTL and DNS_CACHE_NEGATIVE_TTL cannot be negative")
+	}
+	if c.DNSCache.MaxTTL < c.DNSCache.MinTTL {
+		return fmt.Errorf("DNS_CACHE_MAX_TTL (%s) cannot be less than DNS_CACHE_MIN_TTL (%s)", c.DNSCache.MaxTTL, c.DNSCache.MinTTL)
+	}
+
 	return nil
 }
 
@@ -153,6 +1031,11 @@ func (c *Config) IsProduction() bool {
 	return c.Environment == "production"
 }
 
+// TLSEnabled returns true if the listener should serve TLS.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
 // ServerAddress returns the server address in host:port format.
 func (c *Config) ServerAddress() string {
 	return fmt.Sprintf("%s:%d", c.ServerHost, c.ServerPort)
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestConfig_Validate(t *testing.T) {
@@ -133,6 +134,256 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "tls cert without key",
+			config: Config{
+				Environment: "development",
+				ServerPort:  8080,
+				LogLevel:    "info",
+				LogFormat:   "json",
+				TLSCertFile: "/etc/tls/cert.pem",
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "http3 without tls",
+			config: Config{
+				Environment:  "development",
+				ServerPort:   8080,
+				LogLevel:     "info",
+				LogFormat:    "json",
+				HTTP3Enabled: true,
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "fault injection in production",
+			config: Config{
+				Environment:           "production",
+				ServerPort:            8080,
+				LogLevel:              "info",
+				LogFormat:             "json",
+				FaultInjectionEnabled: true,
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "decision header in production",
+			config: Config{
+				Environment: "production",
+				ServerPort:  8080,
+				LogLevel:    "info",
+				LogFormat:   "json",
+				DecisionLog: DecisionLogConfig{Header: true},
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "debug endpoints in production without admin token",
+			config: Config{
+				Environment: "production",
+				ServerPort:  8080,
+				LogLevel:    "info",
+				LogFormat:   "json",
+				Debug:       DebugConfig{Enabled: true},
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "admin listener without admin token outside development",
+			config: Config{
+				Environment:     "staging",
+				ServerPort:      8080,
+				LogLevel:        "info",
+				LogFormat:       "json",
+				AdminListenAddr: "127.0.0.1:8001",
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "admin listener without admin token in development",
+			config: Config{
+				Environment:     "development",
+				ServerPort:      8080,
+				LogLevel:        "info",
+				LogFormat:       "json",
+				AdminListenAddr: "127.0.0.1:8001",
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid admin listen address",
+			config: Config{
+				Environment:     "development",
+				ServerPort:      8080,
+				LogLevel:        "info",
+				LogFormat:       "json",
+				AdminListenAddr: "8001",
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "debug endpoints in development without admin token",
+			config: Config{
+				Environment:     "development",
+				ServerPort:      8080,
+				LogLevel:        "info",
+				LogFormat:       "json",
+				AdminListenAddr: "127.0.0.1:8001",
+				Debug:           DebugConfig{Enabled: true},
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "debug endpoints without admin listener",
+			config: Config{
+				Environment: "development",
+				ServerPort:  8080,
+				LogLevel:    "info",
+				LogFormat:   "json",
+				AdminToken:  "secret",
+				Debug:       DebugConfig{Enabled: true},
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative drain delay",
+			config: Config{
+				Environment:        "development",
+				ServerPort:         8080,
+				LogLevel:           "info",
+				LogFormat:          "json",
+				ShutdownDrainDelay: -time.Second,
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative response cache size",
+			config: Config{
+				Environment:   "development",
+				ServerPort:    8080,
+				LogLevel:      "info",
+				LogFormat:     "json",
+				ResponseCache: ResponseCacheConfig{MaxBytes: -1},
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "subdomain tenants without base domain",
+			config: Config{
+				Environment: "development",
+				ServerPort:  8080,
+				LogLevel:    "info",
+				LogFormat:   "json",
+				Tenant:      TenantConfig{Source: "subdomain"},
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "encryption key and key file both set",
+			config: Config{
+				Environment: "development",
+				ServerPort:  8080,
+				LogLevel:    "info",
+				LogFormat:   "json",
+				Encryption: EncryptionConfig{
+					Key:     "a2V5",
+					KeyFile: "/run/secrets/key",
+				},
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid outlier error rate threshold",
+			config: Config{
+				Environment: "development",
+				ServerPort:  8080,
+				LogLevel:    "info",
+				LogFormat:   "json",
+				Database: DatabaseConfig{
+					DSN:          "postgres://localhost:5432/test",
+					MaxOpenConns: 25,
+					MaxIdleConns: 5,
+				},
+				OutlierDetection: OutlierDetectionConfig{
+					Enabled:            true,
+					ErrorRateThreshold: 1.5,
+					Decay:              0.1,
+					MaxEjectionPercent: 50,
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -181,6 +432,18 @@ func TestConfig_ServerAddress(t *testing.T) {
 	}
 }
 
+func TestConfig_TLSEnabled(t *testing.T) {
+	cfg := Config{TLSCertFile: "cert.pem"}
+	if cfg.TLSEnabled() {
+		t.Error("expected TLSEnabled to return false without a key file")
+	}
+
+	cfg.TLSKeyFile = "key.pem"
+	if !cfg.TLSEnabled() {
+		t.Error("expected TLSEnabled to return true with cert and key")
+	}
+}
+
 func TestConfig_Load(t *testing.T) {
 	// Set required environment variable
 	os.Setenv("POSTGRES_DSN", "postgres://localhost:5432/test")
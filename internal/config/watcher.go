@@ -10,6 +10,10 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// ChangesChannel is the Redis pub/sub channel carrying config change
+// events to every gateway instance.
+const ChangesChannel = "gateway:config:changes"
+
 // ConfigChangeEvent represents a configuration change from Admin API.
 type ConfigChangeEvent struct {
 	EventType  string                 `json:"event_type"`
@@ -43,7 +47,7 @@ func (w *Watcher) Start(ctx context.Context) error {
 	log.Println("Starting configuration watcher...")
 
 	// Subscribe to config changes channel
-	pubsub := w.redis.Subscribe(ctx, "gateway:config:changes")
+	pubsub := w.redis.Subscribe(ctx, ChangesChannel)
 	defer pubsub.Close()
 
 	// Wait for subscription to be confirmed
@@ -52,7 +56,7 @@ func (w *Watcher) Start(ctx context.Context) error {
 		return err
 	}
 
-	log.Println("Subscribed to gateway:config:changes channel")
+	log.Printf("Subscribed to %s channel", ChangesChannel)
 
 	// Listen for messages
 	ch := pubsub.Channel()
@@ -0,0 +1,202 @@
+// Package connlimit protects the client listener from connection floods and
+// slow clients (slowloris).
+//
+// Listener wraps a net.Listener and closes new connections beyond
+// MaxConns in total or MaxConnsPerIP from one peer address, before any
+// bytes are read. Peers are the connecting addresses, so behind a load
+// balancer the per-IP limit applies to the balancer; leave it at 0 there.
+//
+// Slow clients are cut off by the http.Server timeouts (ReadHeaderTimeout,
+// ReadTimeout, IdleTimeout); Listener.ConnState classifies why connections
+// closed so slowloris attempts show up in metrics:
+//
+//	gateway_connections_rejected_total{reason="max_connections|max_per_ip"}
+//	gateway_connections_closed_total{reason="slow_header|no_request|idle|active"}
+//	gateway_connections_open
+package connlimit
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/clientip"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+var (
+	connectionsRejected = metrics.NewCounterVec(
+		"gateway_connections_rejected_total",
+		"Client connections closed on accept because a connection limit was reached, by reason.",
+		"reason",
+	)
+	connectionsClosed = metrics.NewCounterVec(
+		"gateway_connections_closed_total",
+		"Client connections closed, by the state they were in (slow_header = no request headers within READ_HEADER_TIMEOUT).",
+		"reason",
+	)
+	connectionsOpen = metrics.NewGaugeVec(
+		"gateway_connections_open",
+		"Client connections currently open.",
+	)
+)
+
+// Config holds connection limits. Zero limits are not enforced.
+type Config struct {
+	// MaxConns limits concurrent connections
+	MaxConns int
+
+	// MaxConnsPerIP limits concurrent connections from one peer address
+	MaxConnsPerIP int
+
+	// ReadHeaderTimeout is the server's header timeout; connections closed
+	// without a request after this long count as slow_header
+	ReadHeaderTimeout time.Duration
+}
+
+// Listener enforces connection limits on an inner listener.
+type Listener struct {
+	net.Listener
+	config Config
+
+	mu    sync.Mutex
+	total int
+	perIP map[string]int
+
+	// states tracks connections between ConnState callbacks
+	statesMu sync.Mutex
+	states   map[net.Conn]connState
+}
+
+type connState struct {
+	state  http.ConnState
+	opened time.Time
+}
+
+// NewListener wraps inner with config's limits.
+func NewListener(inner net.Listener, config Config) *Listener {
+	return &Listener{
+		Listener: inner,
+		config:   config,
+		perIP:    make(map[string]int),
+		states:   make(map[net.Conn]connState),
+	}
+}
+
+// Accept returns the next connection within the limits, closing any over
+// them.
+func (l *Listener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		ip := peerIP(conn.RemoteAddr())
+		if reason := l.acquire(ip); reason != "" {
+			connectionsRejected.Inc(reason)
+			log.Debug().
+				Str("component", "connlimit").
+				Str("peer", ip).
+				Str("reason", reason).
+				Msg("Rejected client connection")
+			conn.Close()
+			continue
+		}
+
+		connectionsOpen.Add(1)
+		return &limitedConn{Conn: conn, release: func() { l.release(ip) }}, nil
+	}
+}
+
+// ConnState is an http.Server ConnState hook recording why connections
+// close.
+func (l *Listener) ConnState(conn net.Conn, state http.ConnState) {
+	l.statesMu.Lock()
+	defer l.statesMu.Unlock()
+
+	switch state {
+	case http.StateNew:
+		l.states[conn] = connState{state: state, opened: time.Now()}
+	case http.StateActive, http.StateIdle:
+		s := l.states[conn]
+		s.state = state
+		l.states[conn] = s
+	case http.StateHijacked:
+		delete(l.states, conn)
+	case http.StateClosed:
+		s, ok := l.states[conn]
+		delete(l.states, conn)
+		if ok {
+			connectionsClosed.Inc(closeReason(s, l.config.ReadHeaderTimeout))
+		}
+	}
+}
+
+// acquire reserves a connection slot for ip, returning the reason if a
+// limit is reached.
+func (l *Listener) acquire(ip string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.config.MaxConns > 0 && l.total >= l.config.MaxConns {
+		return "max_connections"
+	}
+	if l.config.MaxConnsPerIP > 0 && l.perIP[ip] >= l.config.MaxConnsPerIP {
+		return "max_per_ip"
+	}
+	l.total++
+	l.perIP[ip]++
+	return ""
+}
+
+// release frees the slot of a closed connection.
+func (l *Listener) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total--
+	if l.perIP[ip]--; l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+}
+
+// closeReason labels how a connection ended.
+func closeReason(s connState, readHeaderTimeout time.Duration) string {
+	switch s.state {
+	case http.StateNew:
+		if readHeaderTimeout > 0 && time.Since(s.opened) >= readHeaderTimeout {
+			return "slow_header"
+		}
+		return "no_request"
+	case http.StateIdle:
+		return "idle"
+	default:
+		return "active"
+	}
+}
+
+// limitedConn releases its slot once closed.
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		connectionsOpen.Add(-1)
+		c.release()
+	})
+	return err
+}
+
+// peerIP returns the IP of a remote address (the address itself if it
+// isn't an IP).
+func peerIP(addr net.Addr) string {
+	return clientip.HostIP(addr.String())
+}
@@ -0,0 +1,85 @@
+package connlimit
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestListener_MaxConnsPerIP(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	ln := NewListener(inner, Config{MaxConnsPerIP: 1})
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	first := dial(t, inner.Addr())
+	defer first.Close()
+	server := <-accepted
+
+	// The second connection from the same IP is closed without being accepted
+	second := dial(t, inner.Addr())
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("second connection Read() error = %v, want EOF", err)
+	}
+
+	// Closing the first frees the slot
+	server.Close()
+	third := dial(t, inner.Addr())
+	defer third.Close()
+	select {
+	case conn := <-accepted:
+		conn.Close()
+	case <-time.After(2 * time.Second):
+		t.Error("connection after release was not accepted")
+	}
+}
+
+func TestCloseReason(t *testing.T) {
+	timeout := time.Second
+	long := time.Now().Add(-2 * time.Second)
+
+	tests := []struct {
+		name  string
+		state connState
+		want  string
+	}{
+		{"no headers before timeout", connState{http.StateNew, long}, "slow_header"},
+		{"closed early", connState{http.StateNew, time.Now()}, "no_request"},
+		{"idle", connState{http.StateIdle, long}, "idle"},
+		{"mid request", connState{http.StateActive, long}, "active"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := closeReason(tt.state, timeout); got != tt.want {
+				t.Errorf("closeReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func dial(t *testing.T, addr net.Addr) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	return conn
+}
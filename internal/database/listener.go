@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// ConfigChangesChannel is the Postgres NOTIFY channel on which the
+// notify_config_change trigger (schema.sql) announces changes to routes,
+// services, service targets, plugins and redirects.
+const ConfigChangesChannel = "gateway_config_changes"
+
+// listenerPingInterval checks an idle LISTEN connection is still alive.
+const listenerPingInterval = 90 * time.Second
+
+// Listen subscribes to a Postgres NOTIFY channel on the primary and calls
+// handle with each notification's payload until ctx is cancelled.
+//
+// The listener uses its own connection (outside the pool) and reconnects
+// automatically. Notifications sent while it was disconnected are lost,
+// so after every reconnect handle is called with an empty payload: the
+// caller should resynchronize (e.g. reload all configuration).
+func (db *DB) Listen(ctx context.Context, channel string, handle func(payload string)) error {
+	listener := pq.NewListener(db.dsn, time.Second, 30*time.Second, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			log.Warn().
+				Err(err).
+				Str("component", "database").
+				Str("channel", channel).
+				Msg("LISTEN connection lost - reconnecting")
+		case pq.ListenerEventReconnected:
+			log.Info().
+				Str("component", "database").
+				Str("channel", channel).
+				Msg("LISTEN connection re-established")
+		case pq.ListenerEventConnectionAttemptFailed:
+			log.Debug().
+				Err(err).
+				Str("component", "database").
+				Str("channel", channel).
+				Msg("LISTEN reconnect attempt failed")
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(channel); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", channel, err)
+	}
+
+	log.Info().
+		Str("component", "database").
+		Str("channel", channel).
+		Msg("Listening for Postgres notifications")
+
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case n := <-listener.Notify:
+			// A nil notification follows a reconnect
+			if n == nil {
+				handle("")
+				continue
+			}
+			handle(n.Extra)
+
+		case <-ticker.C:
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryStore is an in-memory ConfigStore.
+//
+// It returns the same shapes and orderings as Repository (enabled
+// filtering, plugins by priority, targets grouped by service), so
+// components can be exercised without Postgres. Setters replace a whole
+// collection; callers must not modify the objects they pass in or get back.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	routes    []*Route
+	services  []*Service
+	plugins   []*Plugin
+	targets   []*ServiceTarget
+	redirects []*Redirect
+	streams   []*StreamListener
+	tenants   []*Tenant
+	settings  map[string]string
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// SetRoutes replaces the stored routes.
+func (s *MemoryStore) SetRoutes(routes []*Route) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = append([]*Route(nil), routes...)
+}
+
+// SetServices replaces the stored services.
+func (s *MemoryStore) SetServices(services []*Service) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.services = append([]*Service(nil), services...)
+}
+
+// SetPlugins replaces the stored plugins.
+func (s *MemoryStore) SetPlugins(plugins []*Plugin) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.plugins = append([]*Plugin(nil), plugins...)
+}
+
+// SetServiceTargets replaces the stored service targets.
+func (s *MemoryStore) SetServiceTargets(targets []*ServiceTarget) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets = append([]*ServiceTarget(nil), targets...)
+}
+
+// SetRedirects replaces the stored redirect rules.
+func (s *MemoryStore) SetRedirects(redirects []*Redirect) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.redirects = append([]*Redirect(nil), redirects...)
+}
+
+// SetStreamListeners replaces the stored stream listeners.
+func (s *MemoryStore) SetStreamListeners(listeners []*StreamListener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams = append([]*StreamListener(nil), listeners...)
+}
+
+// SetTenants replaces the stored tenant registry.
+func (s *MemoryStore) SetTenants(tenants []*Tenant) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenants = append([]*Tenant(nil), tenants...)
+}
+
+// SetGatewaySettings replaces the stored runtime settings.
+func (s *MemoryStore) SetGatewaySettings(settings map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings = make(map[string]string, len(settings))
+	for key, value := range settings {
+		s.settings[key] = value
+	}
+}
+
+// GetRoutes returns enabled routes, or all routes if includeDisabled.
+func (s *MemoryStore) GetRoutes(ctx context.Context, includeDisabled bool) ([]*Route, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var routes []*Route
+	for _, route := range s.routes {
+		if route.Enabled || includeDisabled {
+			routes = append(routes, route)
+		}
+	}
+	return routes, nil
+}
+
+// GetServices returns enabled services, or all services if includeDisabled.
+func (s *MemoryStore) GetServices(ctx context.Context, includeDisabled bool) ([]*Service, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var services []*Service
+	for _, service := range s.services {
+		if service.Enabled || includeDisabled {
+			services = append(services, service)
+		}
+	}
+	return services, nil
+}
+
+// GetPlugins returns plugins ordered by priority (then creation time);
+// only enabled ones if enabledOnly.
+func (s *MemoryStore) GetPlugins(ctx context.Context, enabledOnly bool) ([]*Plugin, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var plugins []*Plugin
+	for _, p := range s.plugins {
+		if p.Enabled || !enabledOnly {
+			plugins = append(plugins, p)
+		}
+	}
+
+	sort.SliceStable(plugins, func(i, j int) bool {
+		if plugins[i].Priority != plugins[j].Priority {
+			return plugins[i].Priority < plugins[j].Priority
+		}
+		return plugins[i].CreatedAt.Before(plugins[j].CreatedAt)
+	})
+	return plugins, nil
+}
+
+// GetAllServiceTargets returns enabled targets grouped by service, oldest
+// first within a service.
+func (s *MemoryStore) GetAllServiceTargets(ctx context.Context) ([]*ServiceTarget, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var targets []*ServiceTarget
+	for _, t := range s.targets {
+		if t.Enabled {
+			targets = append(targets, t)
+		}
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool {
+		if targets[i].ServiceID != targets[j].ServiceID {
+			return targets[i].ServiceID < targets[j].ServiceID
+		}
+		return targets[i].CreatedAt.Before(targets[j].CreatedAt)
+	})
+	return targets, nil
+}
+
+// GetRedirects returns enabled redirect rules, oldest first.
+func (s *MemoryStore) GetRedirects(ctx context.Context) ([]*Redirect, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var redirects []*Redirect
+	for _, r := range s.redirects {
+		if r.Enabled {
+			redirects = append(redirects, r)
+		}
+	}
+
+	sort.SliceStable(redirects, func(i, j int) bool {
+		return redirects[i].CreatedAt.Before(redirects[j].CreatedAt)
+	})
+	return redirects, nil
+}
+
+// GetStreamListeners returns enabled stream listeners of enabled services,
+// oldest first.
+func (s *MemoryStore) GetStreamListeners(ctx context.Context) ([]*StreamListener, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	enabledServices := make(map[string]bool, len(s.services))
+	for _, service := range s.services {
+		if service.Enabled {
+			enabledServices[service.ID] = true
+		}
+	}
+
+	var listeners []*StreamListener
+	for _, l := range s.streams {
+		if l.Enabled && enabledServices[l.ServiceID] {
+			listeners = append(listeners, l)
+		}
+	}
+
+	sort.SliceStable(listeners, func(i, j int) bool {
+		return listeners[i].CreatedAt.Before(listeners[j].CreatedAt)
+	})
+	return listeners, nil
+}
+
+// GetTenants returns enabled tenants, ordered by ID.
+func (s *MemoryStore) GetTenants(ctx context.Context) ([]*Tenant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tenants []*Tenant
+	for _, t := range s.tenants {
+		if t.Enabled {
+			tenants = append(tenants, t)
+		}
+	}
+
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].ID < tenants[j].ID
+	})
+	return tenants, nil
+}
+
+// GetGatewaySettings returns a copy of the runtime settings.
+func (s *MemoryStore) GetGatewaySettings(ctx context.Context) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settings := make(map[string]string, len(s.settings))
+	for key, value := range s.settings {
+		settings[key] = value
+	}
+	return settings, nil
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_FiltersAndOrders(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewMemoryStore()
+
+	store.SetServices([]*Service{
+		{ID: "on", Enabled: true},
+		{ID: "off", Enabled: false},
+	})
+	store.SetPlugins([]*Plugin{
+		{ID: "late", Priority: 10, Enabled: true, CreatedAt: now.Add(time.Second)},
+		{ID: "disabled", Priority: 1, Enabled: false, CreatedAt: now},
+		{ID: "early", Priority: 10, Enabled: true, CreatedAt: now},
+		{ID: "first", Priority: 5, Enabled: true, CreatedAt: now.Add(time.Hour)},
+	})
+	store.SetServiceTargets([]*ServiceTarget{
+		{ID: "b1", ServiceID: "b", Enabled: true, CreatedAt: now},
+		{ID: "a2", ServiceID: "a", Enabled: true, CreatedAt: now.Add(time.Second)},
+		{ID: "a0", ServiceID: "a", Enabled: false, CreatedAt: now},
+		{ID: "a1", ServiceID: "a", Enabled: true, CreatedAt: now},
+	})
+	store.SetStreamListeners([]*StreamListener{
+		{ID: "mqtt-late", ServiceID: "on", Enabled: true, CreatedAt: now.Add(time.Second)},
+		{ID: "mqtt", ServiceID: "on", Enabled: true, CreatedAt: now},
+		{ID: "disabled", ServiceID: "on", Enabled: false, CreatedAt: now},
+		{ID: "service-off", ServiceID: "off", Enabled: true, CreatedAt: now},
+	})
+
+	services, _ := store.GetServices(ctx, false)
+	if len(services) != 1 || services[0].ID != "on" {
+		t.Errorf("GetServices(false) = %v, want only enabled", serviceIDs(services))
+	}
+	if services, _ := store.GetServices(ctx, true); len(services) != 2 {
+		t.Errorf("GetServices(true) returned %d services, want 2", len(services))
+	}
+
+	plugins, _ := store.GetPlugins(ctx, true)
+	got := make([]string, 0, len(plugins))
+	for _, p := range plugins {
+		got = append(got, p.ID)
+	}
+	if want := []string{"first", "early", "late"}; !equalStrings(got, want) {
+		t.Errorf("GetPlugins(true) = %v, want %v", got, want)
+	}
+	if plugins, _ := store.GetPlugins(ctx, false); len(plugins) != 4 || plugins[0].ID != "disabled" {
+		t.Errorf("GetPlugins(false) should include disabled plugins in priority order")
+	}
+
+	targets, _ := store.GetAllServiceTargets(ctx)
+	got = got[:0]
+	for _, target := range targets {
+		got = append(got, target.ID)
+	}
+	if want := []string{"a1", "a2", "b1"}; !equalStrings(got, want) {
+		t.Errorf("GetAllServiceTargets() = %v, want %v", got, want)
+	}
+
+	listeners, _ := store.GetStreamListeners(ctx)
+	got = got[:0]
+	for _, l := range listeners {
+		got = append(got, l.ID)
+	}
+	if want := []string{"mqtt", "mqtt-late"}; !equalStrings(got, want) {
+		t.Errorf("GetStreamListeners() = %v, want %v", got, want)
+	}
+}
+
+func serviceIDs(services []*Service) []string {
+	out := make([]string, 0, len(services))
+	for _, s := range services {
+		out = append(out, s.ID)
+	}
+	return out
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
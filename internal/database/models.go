@@ -6,17 +6,31 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/lib/pq"
 )
 
+// DefaultWorkspace is the workspace of configuration created without one.
+const DefaultWorkspace = "default"
+
+// WorkspaceName returns ws, or DefaultWorkspace if it is empty.
+func WorkspaceName(ws string) string {
+	if ws == "" {
+		return DefaultWorkspace
+	}
+	return ws
+}
+
 // Service represents a backend microservice that the gateway proxies to.
 //
 // Maps to the 'services' table in PostgreSQL.
 type Service struct {
-	ID   string `json:"id" db:"id"`
-	Name string `json:"name" db:"name"`
+	ID        string `json:"id" db:"id"`
+	Workspace string `json:"workspace" db:"workspace"`
+	Name      string `json:"name" db:"name"` // unique per workspace
 
 	// Connection details
 	Protocol string         `json:"protocol" db:"protocol"` // http, https, grpc
@@ -31,7 +45,20 @@ type Service struct {
 	Retries          int `json:"retries" db:"retries"`
 
 	// Load balancing
-	LoadBalancerType string `json:"load_balancer_type" db:"load_balancer_type"` // round-robin, least-connections, weighted, ip-hash
+	LoadBalancerType  string         `json:"load_balancer_type" db:"load_balancer_type"`   // round-robin, least-connections, weighted, ip-hash, consistent-hash, locality-aware
+	HashOn            string         `json:"hash_on" db:"hash_on"`                         // consistent-hash key source: ip, header, cookie, path-param
+	HashOnKey         sql.NullString `json:"hash_on_key,omitempty" db:"hash_on_key"`       // header/cookie/path-param name
+	HashBalanceFactor float64        `json:"hash_balance_factor" db:"hash_balance_factor"` // bounded-load factor (>= 1)
+
+	// Dialing
+	IPFamily string `json:"ip_family" db:"ip_family"` // default, auto, ipv4, ipv6, prefer_ipv4, prefer_ipv6
+
+	// Active health checks of the service's targets (see
+	// loadbalancer.HealthChecker)
+	HealthCheck ServiceHealthCheck `json:"health_check" db:"health_check"`
+
+	// Labels for filtering and per-team metrics (see MergeTags)
+	Tags pq.StringArray `json:"tags,omitempty" db:"tags"` // e.g., ["team-payments", "tier-1"]
 
 	Enabled   bool      `json:"enabled" db:"enabled"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
@@ -49,6 +76,7 @@ type ServiceTarget struct {
 	Target          string `json:"target" db:"target"`                       // Format: "host:port"
 	Weight          int    `json:"weight" db:"weight"`                       // For weighted load balancing
 	HealthCheckPath string `json:"health_check_path" db:"health_check_path"` // e.g., "/health"
+	Region          string `json:"region" db:"region"`                       // region or zone, "" = unknown
 
 	Enabled   bool      `json:"enabled" db:"enabled"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
@@ -59,6 +87,7 @@ type ServiceTarget struct {
 // Maps to the 'routes' table in PostgreSQL.
 type Route struct {
 	ID        string         `json:"id" db:"id"`
+	Workspace string         `json:"workspace" db:"workspace"` // always the service's workspace
 	ServiceID string         `json:"service_id" db:"service_id"`
 	Name      sql.NullString `json:"name,omitempty" db:"name"`
 
@@ -71,20 +100,236 @@ type Route struct {
 	StripPath    bool `json:"strip_path" db:"strip_path"`       // Remove matched path before proxying
 	PreserveHost bool `json:"preserve_host" db:"preserve_host"` // Keep original Host header
 
+	// Admission control
+	PriorityClass string `json:"priority_class" db:"priority_class"` // critical, normal, bulk
+
+	// Total time allowed per request, from routing to the end of the
+	// upstream response (see internal/deadline); 0 = the service's
+	// ReadTimeoutMs
+	TimeoutMs int `json:"timeout_ms" db:"timeout_ms"`
+
+	// Time-based scheduling (see router.Schedule)
+	ScheduleStart    sql.NullTime   `json:"schedule_start,omitempty" db:"schedule_start"`
+	ScheduleEnd      sql.NullTime   `json:"schedule_end,omitempty" db:"schedule_end"`
+	ScheduleCron     sql.NullString `json:"schedule_cron,omitempty" db:"schedule_cron"` // e.g., "* 9-17 * * 1-5"
+	ScheduleMode     string         `json:"schedule_mode" db:"schedule_mode"`           // active, inactive
+	ScheduleTimezone string         `json:"schedule_timezone" db:"schedule_timezone"`   // IANA name, e.g., "America/New_York"
+
+	// Service level objectives (see internal/slo)
+	SLO RouteSLO `json:"slo" db:"slo"`
+
+	// Route group the route belongs to. Repository reads return the
+	// effective route: paths prefixed with the group's base path, and
+	// disabled while the group is.
+	GroupID sql.NullString `json:"group_id,omitempty" db:"group_id"`
+
+	// Labels for filtering and per-team metrics; a request carries its
+	// route's and service's tags
+	Tags pq.StringArray `json:"tags,omitempty" db:"tags"`
+
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Redirect answers matching requests with a redirect instead of proxying
+// them (see internal/redirect).
+//
+// Maps to the 'redirects' table in PostgreSQL. Hosts and Paths use the same
+// patterns as routes; Location is a template that may reference {host},
+// {path}, path parameters ({id} for ":id") and the wildcard remainder ({*}).
+type Redirect struct {
+	ID   string         `json:"id" db:"id"`
+	Name sql.NullString `json:"name,omitempty" db:"name"`
+
+	// Matching criteria
+	Hosts pq.StringArray `json:"hosts,omitempty" db:"hosts"` // e.g., ["old.example.com", "*.example.com"]
+	Paths pq.StringArray `json:"paths" db:"paths"`           // e.g., ["/docs/*", "/users/:id"]
+
+	// Response
+	Location      string `json:"location" db:"location"`             // e.g., "https://docs.example.com/{*}"
+	StatusCode    int    `json:"status_code" db:"status_code"`       // 301, 302, 303, 307 or 308
+	PreserveQuery bool   `json:"preserve_query" db:"preserve_query"` // Append the request's query string
+
 	Enabled   bool      `json:"enabled" db:"enabled"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// StreamListener is a TCP or UDP listener whose connections are proxied to
+// a service's targets (see internal/streamproxy).
+//
+// Maps to the 'stream_listeners' table in PostgreSQL. Connections are
+// balanced across the service's targets with its load balancer (or sent to
+// the service's host/port without targets).
+type StreamListener struct {
+	ID        string         `json:"id" db:"id"`
+	Workspace string         `json:"workspace" db:"workspace"` // always the service's workspace
+	ServiceID string         `json:"service_id" db:"service_id"`
+	Name      sql.NullString `json:"name,omitempty" db:"name"`
+
+	Protocol      string `json:"protocol" db:"protocol"`             // tcp, udp
+	ListenAddress string `json:"listen_address" db:"listen_address"` // e.g., ":1883"
+
+	// Limits
+	MaxConnections int `json:"max_connections" db:"max_connections"` // concurrent connections (UDP: sessions), 0 = unlimited
+	IdleTimeoutMs  int `json:"idle_timeout_ms" db:"idle_timeout_ms"` // 0 = no idle timeout
+
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Tenant is an entry in the tenant registry (see internal/tenant).
+//
+// Maps to the 'tenants' table in PostgreSQL. Requests are resolved to a
+// tenant by header or subdomain, and services whose host or path contain
+// {tenant} are proxied to that tenant's own backend. ID is a DNS label, so
+// it is safe to substitute into host names.
+type Tenant struct {
+	ID   string         `json:"id" db:"id"`               // e.g., "acme"
+	Name sql.NullString `json:"name,omitempty" db:"name"` // Display name
+
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// GatewaySetting overrides a gateway setting at runtime.
+//
+// Maps to the 'gateway_settings' table in PostgreSQL. Key is the lower-cased
+// environment variable name (e.g. "upstream_dial_timeout").
+type GatewaySetting struct {
+	Key       string    `json:"key" db:"key"`
+	Value     string    `json:"value" db:"value"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// RouteSLO holds a route's service level objectives (routes.slo JSONB).
+//
+// Every field is optional; a zero RouteSLO means the route is not tracked.
+//
+//	{"latency_ms": 300, "error_budget": 0.001, "window": "1h"}
+//
+// = 99% of requests complete within 300ms and at most 0.1% return 5xx,
+// measured over a rolling hour.
+type RouteSLO struct {
+	// LatencyMs is the latency threshold met by Percentile of requests
+	LatencyMs int `json:"latency_ms,omitempty"`
+
+	// ErrorBudget is the tolerated fraction of 5xx responses (e.g. 0.001)
+	ErrorBudget float64 `json:"error_budget,omitempty"`
+
+	// RequestSizeBytes / ResponseSizeBytes are size thresholds met by
+	// Percentile of requests
+	RequestSizeBytes  int64 `json:"request_size_bytes,omitempty"`
+	ResponseSizeBytes int64 `json:"response_size_bytes,omitempty"`
+
+	// Percentile for latency and size objectives (default 0.99)
+	Percentile float64 `json:"percentile,omitempty"`
+
+	// Window is the rolling evaluation window (default "1h", max "168h")
+	Window string `json:"window,omitempty"`
+}
+
+// Configured returns true if the route declares any objective.
+func (s RouteSLO) Configured() bool {
+	return s.LatencyMs > 0 || s.ErrorBudget > 0 || s.RequestSizeBytes > 0 || s.ResponseSizeBytes > 0
+}
+
+// Scan implements sql.Scanner for the JSONB column (NULL = no SLO).
+func (s *RouteSLO) Scan(src interface{}) error {
+	*s = RouteSLO{}
+
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type %T for route slo", src)
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, s)
+}
+
+// ServiceHealthCheck configures active health checks of a service's
+// targets (services.health_check JSONB).
+//
+// Every field but Interval is optional; a zero ServiceHealthCheck means the
+// targets are only checked passively (outlier detection).
+//
+//	{"interval": "10s", "grpc_service": "payments.v1.Payments", "tls": true}
+//
+// = probe every target every 10s; a grpc service is asked for the health
+// of payments.v1.Payments, over TLS.
+type ServiceHealthCheck struct {
+	// Interval between probes of each target
+	Interval string `json:"interval,omitempty"`
+
+	// Timeout of a probe (default "2s")
+	Timeout string `json:"timeout,omitempty"`
+
+	// UnhealthyThreshold failed probes in a row mark a target down
+	// (default 3); HealthyThreshold passed probes bring it back (default 2)
+	UnhealthyThreshold int `json:"unhealthy_threshold,omitempty"`
+	HealthyThreshold   int `json:"healthy_threshold,omitempty"`
+
+	// GRPCService is the service name sent in grpc.health.v1 Check
+	// requests ("" = the server as a whole); grpc services only
+	GRPCService string `json:"grpc_service,omitempty"`
+
+	// TLS probes over TLS, verifying the certificate against TLSServerName
+	// (default: the target host) unless TLSSkipVerify. https services are
+	// always probed over TLS.
+	TLS           bool   `json:"tls,omitempty"`
+	TLSServerName string `json:"tls_server_name,omitempty"`
+	TLSSkipVerify bool   `json:"tls_skip_verify,omitempty"`
+}
+
+// Configured returns true if the service's targets are actively checked.
+func (h ServiceHealthCheck) Configured() bool {
+	return h.Interval != ""
+}
+
+// Scan implements sql.Scanner for the JSONB column (NULL = no checks).
+func (h *ServiceHealthCheck) Scan(src interface{}) error {
+	*h = ServiceHealthCheck{}
+
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type %T for service health check", src)
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, h)
+}
+
 // Consumer represents an API client (application or service) that calls the gateway.
 //
 // Maps to the 'consumers' table in PostgreSQL.
 // Note: Consumer ≠ end user. Consumer = application/service making API requests.
 type Consumer struct {
-	ID       string         `json:"id" db:"id"`
-	Username string         `json:"username" db:"username"`
-	Email    sql.NullString `json:"email,omitempty" db:"email"`
-	CustomID sql.NullString `json:"custom_id,omitempty" db:"custom_id"`
+	ID        string         `json:"id" db:"id"`
+	Workspace string         `json:"workspace" db:"workspace"`
+	Username  string         `json:"username" db:"username"` // unique per workspace
+	Email     sql.NullString `json:"email,omitempty" db:"email"`
+	CustomID  sql.NullString `json:"custom_id,omitempty" db:"custom_id"`
 
 	// Metadata stores arbitrary JSON data about the consumer
 	Metadata map[string]interface{} `json:"metadata,omitempty" db:"metadata"`
@@ -111,28 +356,41 @@ type APIKey struct {
 	ExpiresAt  sql.NullTime `json:"expires_at,omitempty" db:"expires_at"`
 }
 
+// APIKeyConsumer pairs an API key hash with the consumer it authenticates.
+// Used to preload the API key cache.
+type APIKeyConsumer struct {
+	KeyHash  string
+	Consumer *Consumer
+}
+
 // Plugin represents modular functionality (auth, rate limiting, caching, etc.).
 //
 // Maps to the 'plugins' table in PostgreSQL.
 //
 // Plugins can be scoped to:
-//   - global: applies to all routes
+//   - global: applies to all routes in the plugin's workspace
 //   - service: applies to all routes of a service
 //   - route: applies to a specific route
+//   - group: applies to all routes of a route group
 //   - consumer: applies to a specific consumer
 type Plugin struct {
-	ID    string `json:"id" db:"id"`
-	Name  string `json:"name" db:"name"`   // e.g., "rate-limit", "api-key-auth", "cache"
-	Scope string `json:"scope" db:"scope"` // global, service, route, consumer
+	ID        string `json:"id" db:"id"`
+	Workspace string `json:"workspace" db:"workspace"`
+	Name      string `json:"name" db:"name"`   // e.g., "rate-limit", "api-key-auth", "cache"
+	Scope     string `json:"scope" db:"scope"` // global, service, route, group, consumer
 
 	// Foreign keys (only one should be set based on scope)
 	ServiceID  sql.NullString `json:"service_id,omitempty" db:"service_id"`
 	RouteID    sql.NullString `json:"route_id,omitempty" db:"route_id"`
+	GroupID    sql.NullString `json:"group_id,omitempty" db:"group_id"`
 	ConsumerID sql.NullString `json:"consumer_id,omitempty" db:"consumer_id"`
 
 	// Config stores plugin-specific configuration as JSON
 	Config map[string]interface{} `json:"config" db:"config"`
 
+	// Labels for filtering
+	Tags pq.StringArray `json:"tags,omitempty" db:"tags"`
+
 	Enabled   bool      `json:"enabled" db:"enabled"`
 	Priority  int       `json:"priority" db:"priority"` // Lower = executes first
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
@@ -144,6 +402,7 @@ const (
 	PluginScopeGlobal   = "global"
 	PluginScopeService  = "service"
 	PluginScopeRoute    = "route"
+	PluginScopeGroup    = "group"
 	PluginScopeConsumer = "consumer"
 )
 
@@ -152,5 +411,48 @@ var ValidPluginScopes = []string{
 	PluginScopeGlobal,
 	PluginScopeService,
 	PluginScopeRoute,
+	PluginScopeGroup,
 	PluginScopeConsumer,
 }
+
+// RecordedRequest is a sampled request captured by the request-recorder
+// plugin, used by `gateway replay` to re-send traffic against another
+// environment.
+//
+// Maps to the 'recorded_requests' table in PostgreSQL.
+type RecordedRequest struct {
+	ID         int64               `json:"id" db:"id"`
+	RouteID    string              `json:"route_id" db:"route_id"`
+	Method     string              `json:"method" db:"method"`
+	Path       string              `json:"path" db:"path"`
+	Query      string              `json:"query,omitempty" db:"query"`
+	Host       string              `json:"host,omitempty" db:"host"`
+	Headers    map[string][]string `json:"headers" db:"headers"` // Sensitive headers are redacted
+	Body       []byte              `json:"body,omitempty" db:"body"`
+	Truncated  bool                `json:"body_truncated" db:"body_truncated"`
+	StatusCode int                 `json:"status_code" db:"status_code"` // Original upstream response
+	DurationMs int64               `json:"duration_ms" db:"duration_ms"`
+	RecordedAt time.Time           `json:"recorded_at" db:"recorded_at"`
+}
+
+// RecordedRequestFilter selects recorded requests for replay.
+type RecordedRequestFilter struct {
+	RouteID string    // empty = all routes
+	Since   time.Time // zero = no lower bound
+	Limit   int       // 0 = no limit
+}
+
+// ConsumerUsage is one consumer's traffic for one UTC day.
+//
+// Maps to the 'consumer_usage_daily' table in PostgreSQL. Rows are
+// written additively by the gateway's usage aggregator, so several
+// gateway instances can report into the same row.
+type ConsumerUsage struct {
+	ConsumerID   string    `json:"consumer_id" db:"consumer_id"`
+	Day          time.Time `json:"day" db:"day"`
+	Requests     int64     `json:"requests" db:"requests"`
+	ClientErrors int64     `json:"client_errors" db:"client_errors"` // 4xx
+	ServerErrors int64     `json:"server_errors" db:"server_errors"` // 5xx
+	BytesIn      int64     `json:"bytes_in" db:"bytes_in"`
+	BytesOut     int64     `json:"bytes_out" db:"bytes_out"`
+}
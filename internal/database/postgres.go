@@ -3,6 +3,7 @@
 //
 // This package handles:
 //   - Database connection pool management
+//   - Read replica routing with failover to the primary
 //   - Health checks and connection verification
 //   - Graceful shutdown
 //   - Connection retry logic
@@ -12,6 +13,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/lib/pq" // PostgreSQL driver
@@ -24,6 +26,14 @@ import (
 type DB struct {
 	pool *sql.DB
 	dsn  string
+
+	// stmts caches prepared read statements (nil = disabled)
+	stmts *stmtCache
+
+	// Read replicas (see replicas.go); empty = all queries on the primary
+	replicas []*replica
+	next     atomic.Uint64 // round-robin cursor
+	stop     chan struct{}
 }
 
 // NewDB creates a new database connection pool with the provided configuration.
@@ -50,6 +60,7 @@ func NewDB(cfg config.DatabaseConfig) (*DB, error) {
 	db := &DB{
 		pool: pool,
 		dsn:  cfg.DSN,
+		stop: make(chan struct{}),
 	}
 
 	// Verify connection with timeout
@@ -60,6 +71,48 @@ func NewDB(cfg config.DatabaseConfig) (*DB, error) {
 		pool.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
+	poolHealthy.Set(1, "primary")
+
+	if cfg.StatementCache {
+		db.stmts = newStmtCache(pool)
+	}
+
+	// Read replicas: unreachable ones start out of rotation
+	if len(cfg.ReplicaDSNs) > 0 {
+		replicas, err := openReplicas(cfg)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		db.replicas = replicas
+
+		db.checkReplicasOnce(cfg.ReplicaMaxLag)
+		healthy := 0
+		for _, r := range replicas {
+			if r.healthy.Load() {
+				healthy++
+			}
+		}
+
+		for _, r := range replicas {
+			if !r.healthy.Load() {
+				log.Warn().
+					Str("component", "database").
+					Str("pool", r.name).
+					Str("error", r.lastErr).
+					Msg("Read replica unavailable at startup - out of rotation until it passes a health check")
+			}
+		}
+
+		go db.checkReplicas(cfg.ReplicaCheckInterval, cfg.ReplicaMaxLag)
+
+		log.Info().
+			Str("component", "database").
+			Int("replicas", len(replicas)).
+			Int("healthy", healthy).
+			Dur("max_lag", cfg.ReplicaMaxLag).
+			Msg("Read replicas configured - reads are routed to replicas")
+	}
 
 	log.Info().
 		Str("component", "database").
@@ -114,6 +167,10 @@ func (db *DB) Health(ctx context.Context) map[string]interface{} {
 	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
+	if len(db.replicas) > 0 {
+		health["replicas"] = db.replicaHealth()
+	}
+
 	if err := db.Ping(ctx); err != nil {
 		health["status"] = "unhealthy"
 		health["error"] = err.Error()
@@ -157,6 +214,15 @@ func (db *DB) Close() error {
 		Str("component", "database").
 		Msg("Closing database connection pool...")
 
+	if db.stop != nil {
+		close(db.stop)
+	}
+	db.stmts.close()
+	for _, r := range db.replicas {
+		r.stmts.close()
+		r.pool.Close()
+	}
+
 	if err := db.pool.Close(); err != nil {
 		log.Error().
 			Err(err).
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
@@ -44,3 +46,51 @@ func TestModels_ServiceStruct(t *testing.T) {
 		t.Errorf("expected port 8080, got %d", svc.Port)
 	}
 }
+
+func TestDB_ReadPoolRouting(t *testing.T) {
+	cfg := config.DatabaseConfig{
+		ReplicaDSNs:  []string{"postgres://a/db", "postgres://b/db"},
+		MaxOpenConns: 1,
+	}
+	replicas, err := openReplicas(cfg)
+	if err != nil {
+		t.Fatalf("openReplicas: %v", err)
+	}
+
+	primary, _ := sql.Open("postgres", "postgres://primary/db")
+	db := &DB{pool: primary, replicas: replicas}
+	defer db.Close()
+
+	// No healthy replica: reads go to the primary
+	if pool, r := db.readPool(); pool != primary || r != nil {
+		t.Fatal("expected the primary while no replica is healthy")
+	}
+
+	db.setReplicaHealth(replicas[0], nil, 0)
+	db.setReplicaHealth(replicas[1], nil, 0)
+
+	seen := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		_, r := db.readPool()
+		if r == nil {
+			t.Fatal("expected a replica")
+		}
+		seen[r.name]++
+	}
+	if seen["replica-0"] != 2 || seen["replica-1"] != 2 {
+		t.Errorf("reads not spread round-robin: %v", seen)
+	}
+
+	// An unhealthy replica is skipped
+	db.setReplicaHealth(replicas[1], errors.New("connection refused"), 0)
+	for i := 0; i < 3; i++ {
+		if _, r := db.readPool(); r != replicas[0] {
+			t.Fatal("expected only replica-0 while replica-1 is unhealthy")
+		}
+	}
+
+	report := db.replicaHealth()
+	if len(report) != 2 || report[1]["status"] != "unhealthy" || report[1]["error"] != "connection refused" {
+		t.Errorf("unexpected replica health report: %v", report)
+	}
+}
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/config"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// ============================================================================
+// Read replicas
+// ============================================================================
+//
+// With POSTGRES_REPLICA_DSNS set, repository reads (every Get* method) are
+// spread round-robin over healthy replicas and writes stay on the primary.
+// This keeps config reloads across a large fleet off the primary.
+//
+// Failover:
+//   - A read that fails on a replica is retried on the primary, and the
+//     replica is taken out of rotation
+//   - A background check pings every replica (and, with
+//     DB_REPLICA_MAX_LAG, measures replay lag) and puts it back once it
+//     passes
+//   - With no healthy replica, reads go to the primary
+//
+// Replicas lag the primary, so a read right after a write may not see it.
+// The gateway only reads configuration written by the admin API, which a
+// later reload picks up.
+
+var poolHealthy = metrics.NewGaugeVec(
+	"gateway_db_pool_healthy",
+	"Whether a database connection pool is serving queries (1) or not (0).",
+	"pool",
+)
+
+// replicaPingTimeout bounds one replica health check.
+const replicaPingTimeout = 2 * time.Second
+
+// replica is a read-only connection pool.
+type replica struct {
+	name  string // "replica-0", ... (DSNs carry credentials, so never logged)
+	pool  *sql.DB
+	stmts *stmtCache // nil = statement cache disabled
+
+	healthy atomic.Bool
+
+	mu      sync.Mutex
+	lastErr string
+	lag     time.Duration
+}
+
+// openReplicas opens a pool per replica DSN. Replicas that cannot be
+// reached yet start out of rotation instead of failing startup.
+func openReplicas(cfg config.DatabaseConfig) ([]*replica, error) {
+	replicas := make([]*replica, 0, len(cfg.ReplicaDSNs))
+
+	for i, dsn := range cfg.ReplicaDSNs {
+		pool, err := sql.Open("postgres", dsn)
+		if err != nil {
+			for _, r := range replicas {
+				r.pool.Close()
+			}
+			return nil, fmt.Errorf("failed to open replica %d: %w", i, err)
+		}
+
+		pool.SetMaxOpenConns(cfg.MaxOpenConns)
+		pool.SetMaxIdleConns(cfg.MaxIdleConns)
+		pool.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+		pool.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+		r := &replica{name: fmt.Sprintf("replica-%d", i), pool: pool}
+		if cfg.StatementCache {
+			r.stmts = newStmtCache(pool)
+		}
+		poolHealthy.Set(0, r.name)
+		replicas = append(replicas, r)
+	}
+
+	return replicas, nil
+}
+
+// readPool picks the pool for a read: the next healthy replica, or the
+// primary. The replica is nil when the primary was picked.
+func (db *DB) readPool() (*sql.DB, *replica) {
+	n := len(db.replicas)
+	if n == 0 {
+		return db.pool, nil
+	}
+
+	start := int(db.next.Add(1) % uint64(n))
+	for i := 0; i < n; i++ {
+		r := db.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r.pool, r
+		}
+	}
+
+	return db.pool, nil
+}
+
+// queryRead runs a read query on a replica, falling back to the primary.
+func (db *DB) queryRead(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	pool, r := db.readPool()
+	if r == nil {
+		return db.stmts.query(ctx, db.pool, query, args...)
+	}
+
+	rows, err := r.stmts.query(ctx, pool, query, args...)
+	if err == nil || ctx.Err() != nil {
+		return rows, err
+	}
+
+	db.setReplicaHealth(r, err, 0)
+	return db.stmts.query(ctx, db.pool, query, args...)
+}
+
+// readRow is the result of queryRowRead. It behaves like *sql.Row.
+type readRow struct {
+	rows *sql.Rows
+	err  error
+}
+
+// queryRowRead runs a single-row read query on a replica, falling back to
+// the primary.
+func (db *DB) queryRowRead(ctx context.Context, query string, args ...interface{}) *readRow {
+	rows, err := db.queryRead(ctx, query, args...)
+	return &readRow{rows: rows, err: err}
+}
+
+// Scan copies the first row into dest, returning sql.ErrNoRows if there
+// is none.
+func (r *readRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	return r.rows.Close()
+}
+
+// checkReplicas health-checks every replica until stop is closed.
+func (db *DB) checkReplicas(interval, maxLag time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.stop:
+			return
+		case <-ticker.C:
+			db.checkReplicasOnce(maxLag)
+		}
+	}
+}
+
+// checkReplicasOnce pings every replica and measures its lag.
+func (db *DB) checkReplicasOnce(maxLag time.Duration) {
+	for _, r := range db.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), replicaPingTimeout)
+		lag, err := replicaLag(ctx, r.pool)
+		cancel()
+
+		if err == nil && maxLag > 0 && lag > maxLag {
+			err = fmt.Errorf("replication lag %s exceeds %s", lag.Round(time.Millisecond), maxLag)
+		}
+		db.setReplicaHealth(r, err, lag)
+	}
+}
+
+// replicaLag returns how far a replica's replay is behind (0 if the
+// server is not replaying, e.g. a promoted replica).
+func replicaLag(ctx context.Context, pool *sql.DB) (time.Duration, error) {
+	var seconds sql.NullFloat64
+	err := pool.QueryRowContext(ctx,
+		`SELECT EXTRACT(EPOCH FROM (NOW() - pg_last_xact_replay_timestamp()))`,
+	).Scan(&seconds)
+	if err != nil {
+		return 0, fmt.Errorf("replica check failed: %w", err)
+	}
+	if !seconds.Valid || seconds.Float64 < 0 {
+		return 0, nil
+	}
+	return time.Duration(seconds.Float64 * float64(time.Second)), nil
+}
+
+// setReplicaHealth records a check result, logging state changes.
+func (db *DB) setReplicaHealth(r *replica, err error, lag time.Duration) {
+	r.mu.Lock()
+	r.lag = lag
+	r.lastErr = ""
+	if err != nil {
+		r.lastErr = err.Error()
+	}
+	r.mu.Unlock()
+
+	healthy := err == nil
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+
+	if healthy {
+		poolHealthy.Set(1, r.name)
+		log.Info().
+			Str("component", "database").
+			Str("pool", r.name).
+			Msg("Read replica back in rotation")
+		return
+	}
+
+	poolHealthy.Set(0, r.name)
+	log.Warn().
+		Err(err).
+		Str("component", "database").
+		Str("pool", r.name).
+		Msg("Read replica out of rotation - reads fall back to the primary")
+}
+
+// replicaHealth reports each replica's state for Health.
+func (db *DB) replicaHealth() []map[string]interface{} {
+	report := make([]map[string]interface{}, 0, len(db.replicas))
+
+	for _, r := range db.replicas {
+		stats := r.pool.Stats()
+
+		r.mu.Lock()
+		entry := map[string]interface{}{
+			"name":             r.name,
+			"status":           "healthy",
+			"lag_ms":           r.lag.Milliseconds(),
+			"open_connections": stats.OpenConnections,
+			"in_use":           stats.InUse,
+			"idle":             stats.Idle,
+		}
+		if !r.healthy.Load() {
+			entry["status"] = "unhealthy"
+			entry["error"] = r.lastErr
+		}
+		r.mu.Unlock()
+
+		report = append(report, entry)
+	}
+
+	return report
+}
@@ -7,11 +7,19 @@ package database
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/fieldcrypt"
 )
 
 // Repository provides data access methods for all gateway entities.
@@ -20,6 +28,12 @@ import (
 // for the rest of the application.
 type Repository struct {
 	db *DB
+
+	// cipher decrypts encrypted plugin config values (nil = disabled)
+	cipher *fieldcrypt.Cipher
+
+	// apiKeyPepper is the HMAC key for API key hashes (nil = plain SHA256)
+	apiKeyPepper []byte
 }
 
 // NewRepository creates a new repository instance.
@@ -27,6 +41,31 @@ func NewRepository(db *DB) *Repository {
 	return &Repository{db: db}
 }
 
+// SetCipher enables transparent decryption of encrypted plugin config
+// values ("enc:v1:..."). Must be called before the repository is used.
+func (r *Repository) SetCipher(cipher *fieldcrypt.Cipher) {
+	r.cipher = cipher
+}
+
+// SetAPIKeyPepper sets the secret mixed into API key hashes. Must match
+// the admin API's API_KEY_PEPPER, or no key will authenticate.
+func (r *Repository) SetAPIKeyPepper(pepper []byte) {
+	r.apiKeyPepper = pepper
+}
+
+// HashAPIKey returns the stored hash of a plaintext API key: hex
+// HMAC-SHA256 keyed by the pepper, or plain SHA256 without one.
+func (r *Repository) HashAPIKey(apiKey string) string {
+	if len(r.apiKeyPepper) == 0 {
+		sum := sha256.Sum256([]byte(apiKey))
+		return hex.EncodeToString(sum[:])
+	}
+
+	mac := hmac.New(sha256.New, r.apiKeyPepper)
+	mac.Write([]byte(apiKey))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 // ============================================================================
 // Services
 // ============================================================================
@@ -36,15 +75,16 @@ func NewRepository(db *DB) *Repository {
 // Only returns enabled services unless includeDisabled is true.
 func (r *Repository) GetServices(ctx context.Context, includeDisabled bool) ([]*Service, error) {
 	query := `
-		SELECT id, name, protocol, host, port, path,
+		SELECT id, workspace, name, protocol, host, port, path,
 		       connect_timeout_ms, read_timeout_ms, write_timeout_ms, retries,
-		       load_balancer_type, enabled, created_at, updated_at
+		       load_balancer_type, hash_on, hash_on_key, hash_balance_factor,
+		       ip_family, health_check, tags, enabled, created_at, updated_at
 		FROM services
 		WHERE enabled = true OR $1 = true
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.pool.QueryContext(ctx, query, includeDisabled)
+	rows, err := r.db.queryRead(ctx, query, includeDisabled)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query services: %w", err)
 	}
@@ -54,9 +94,10 @@ func (r *Repository) GetServices(ctx context.Context, includeDisabled bool) ([]*
 	for rows.Next() {
 		var svc Service
 		err := rows.Scan(
-			&svc.ID, &svc.Name, &svc.Protocol, &svc.Host, &svc.Port, &svc.Path,
+			&svc.ID, &svc.Workspace, &svc.Name, &svc.Protocol, &svc.Host, &svc.Port, &svc.Path,
 			&svc.ConnectTimeoutMs, &svc.ReadTimeoutMs, &svc.WriteTimeoutMs, &svc.Retries,
-			&svc.LoadBalancerType, &svc.Enabled, &svc.CreatedAt, &svc.UpdatedAt,
+			&svc.LoadBalancerType, &svc.HashOn, &svc.HashOnKey, &svc.HashBalanceFactor,
+			&svc.IPFamily, &svc.HealthCheck, &svc.Tags, &svc.Enabled, &svc.CreatedAt, &svc.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan service: %w", err)
@@ -82,18 +123,20 @@ func (r *Repository) GetServices(ctx context.Context, includeDisabled bool) ([]*
 // Returns sql.ErrNoRows if the service doesn't exist.
 func (r *Repository) GetServiceByID(ctx context.Context, id string) (*Service, error) {
 	query := `
-		SELECT id, name, protocol, host, port, path,
+		SELECT id, workspace, name, protocol, host, port, path,
 		       connect_timeout_ms, read_timeout_ms, write_timeout_ms, retries,
-		       load_balancer_type, enabled, created_at, updated_at
+		       load_balancer_type, hash_on, hash_on_key, hash_balance_factor,
+		       ip_family, health_check, tags, enabled, created_at, updated_at
 		FROM services
 		WHERE id = $1
 	`
 
 	var svc Service
-	err := r.db.pool.QueryRowContext(ctx, query, id).Scan(
-		&svc.ID, &svc.Name, &svc.Protocol, &svc.Host, &svc.Port, &svc.Path,
+	err := r.db.queryRowRead(ctx, query, id).Scan(
+		&svc.ID, &svc.Workspace, &svc.Name, &svc.Protocol, &svc.Host, &svc.Port, &svc.Path,
 		&svc.ConnectTimeoutMs, &svc.ReadTimeoutMs, &svc.WriteTimeoutMs, &svc.Retries,
-		&svc.LoadBalancerType, &svc.Enabled, &svc.CreatedAt, &svc.UpdatedAt,
+		&svc.LoadBalancerType, &svc.HashOn, &svc.HashOnKey, &svc.HashBalanceFactor,
+		&svc.IPFamily, &svc.HealthCheck, &svc.Tags, &svc.Enabled, &svc.CreatedAt, &svc.UpdatedAt,
 	)
 
 	if err != nil {
@@ -106,23 +149,25 @@ func (r *Repository) GetServiceByID(ctx context.Context, id string) (*Service, e
 	return &svc, nil
 }
 
-// GetServiceByName retrieves a service by its name.
+// GetServiceByName retrieves a service by its name within a workspace.
 //
 // Returns sql.ErrNoRows if the service doesn't exist.
-func (r *Repository) GetServiceByName(ctx context.Context, name string) (*Service, error) {
+func (r *Repository) GetServiceByName(ctx context.Context, workspace, name string) (*Service, error) {
 	query := `
-		SELECT id, name, protocol, host, port, path,
+		SELECT id, workspace, name, protocol, host, port, path,
 		       connect_timeout_ms, read_timeout_ms, write_timeout_ms, retries,
-		       load_balancer_type, enabled, created_at, updated_at
+		       load_balancer_type, hash_on, hash_on_key, hash_balance_factor,
+		       ip_family, health_check, tags, enabled, created_at, updated_at
 		FROM services
-		WHERE name = $1
+		WHERE workspace = $1 AND name = $2
 	`
 
 	var svc Service
-	err := r.db.pool.QueryRowContext(ctx, query, name).Scan(
-		&svc.ID, &svc.Name, &svc.Protocol, &svc.Host, &svc.Port, &svc.Path,
+	err := r.db.queryRowRead(ctx, query, WorkspaceName(workspace), name).Scan(
+		&svc.ID, &svc.Workspace, &svc.Name, &svc.Protocol, &svc.Host, &svc.Port, &svc.Path,
 		&svc.ConnectTimeoutMs, &svc.ReadTimeoutMs, &svc.WriteTimeoutMs, &svc.Retries,
-		&svc.LoadBalancerType, &svc.Enabled, &svc.CreatedAt, &svc.UpdatedAt,
+		&svc.LoadBalancerType, &svc.HashOn, &svc.HashOnKey, &svc.HashBalanceFactor,
+		&svc.IPFamily, &svc.HealthCheck, &svc.Tags, &svc.Enabled, &svc.CreatedAt, &svc.UpdatedAt,
 	)
 
 	if err != nil {
@@ -135,6 +180,40 @@ func (r *Repository) GetServiceByName(ctx context.Context, name string) (*Servic
 	return &svc, nil
 }
 
+// GetServiceSpecs retrieves the OpenAPI documents attached to enabled services.
+//
+// Returns a map of service ID to raw spec JSON. Specs are loaded separately
+// from GetServices because they can be large and are only needed for docs.
+func (r *Repository) GetServiceSpecs(ctx context.Context) (map[string]json.RawMessage, error) {
+	query := `
+		SELECT id, openapi_spec
+		FROM services
+		WHERE enabled = true AND openapi_spec IS NOT NULL
+	`
+
+	rows, err := r.db.queryRead(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query service specs: %w", err)
+	}
+	defer rows.Close()
+
+	specs := make(map[string]json.RawMessage)
+	for rows.Next() {
+		var id string
+		var spec []byte
+		if err := rows.Scan(&id, &spec); err != nil {
+			return nil, fmt.Errorf("failed to scan service spec: %w", err)
+		}
+		specs[id] = json.RawMessage(spec)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating service specs: %w", err)
+	}
+
+	return specs, nil
+}
+
 // ============================================================================
 // Routes
 // ============================================================================
@@ -144,14 +223,18 @@ func (r *Repository) GetServiceByName(ctx context.Context, name string) (*Servic
 // Only returns enabled routes unless includeDisabled is true.
 func (r *Repository) GetRoutes(ctx context.Context, includeDisabled bool) ([]*Route, error) {
 	query := `
-		SELECT id, service_id, name, hosts, paths, methods,
-		       strip_path, preserve_host, enabled, created_at, updated_at
-		FROM routes
-		WHERE enabled = true OR $1 = true
-		ORDER BY created_at DESC
+		SELECT r.id, r.workspace, r.service_id, r.name, r.hosts, r.paths, r.methods,
+		       r.strip_path, r.preserve_host, r.priority_class, r.timeout_ms,
+		       r.schedule_start, r.schedule_end, r.schedule_cron, r.schedule_mode, r.schedule_timezone,
+		       r.slo, r.tags, r.enabled, r.created_at, r.updated_at,
+		       r.group_id, g.base_path, g.enabled
+		FROM routes r
+		LEFT JOIN route_groups g ON g.id = r.group_id
+		WHERE (r.enabled = true AND COALESCE(g.enabled, true) = true) OR $1 = true
+		ORDER BY r.created_at DESC
 	`
 
-	rows, err := r.db.pool.QueryContext(ctx, query, includeDisabled)
+	rows, err := r.db.queryRead(ctx, query, includeDisabled)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query routes: %w", err)
 	}
@@ -160,13 +243,18 @@ func (r *Repository) GetRoutes(ctx context.Context, includeDisabled bool) ([]*Ro
 	var routes []*Route
 	for rows.Next() {
 		var route Route
+		var group routeGroupColumns
 		err := rows.Scan(
-			&route.ID, &route.ServiceID, &route.Name, &route.Hosts, &route.Paths, &route.Methods,
-			&route.StripPath, &route.PreserveHost, &route.Enabled, &route.CreatedAt, &route.UpdatedAt,
+			&route.ID, &route.Workspace, &route.ServiceID, &route.Name, &route.Hosts, &route.Paths, &route.Methods,
+			&route.StripPath, &route.PreserveHost, &route.PriorityClass, &route.TimeoutMs,
+			&route.ScheduleStart, &route.ScheduleEnd, &route.ScheduleCron, &route.ScheduleMode, &route.ScheduleTimezone,
+			&route.SLO, &route.Tags, &route.Enabled, &route.CreatedAt, &route.UpdatedAt,
+			&route.GroupID, &group.basePath, &group.enabled,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan route: %w", err)
 		}
+		group.apply(&route)
 		routes = append(routes, &route)
 	}
 
@@ -188,16 +276,24 @@ func (r *Repository) GetRoutes(ctx context.Context, includeDisabled bool) ([]*Ro
 // Returns sql.ErrNoRows if the route doesn't exist.
 func (r *Repository) GetRouteByID(ctx context.Context, id string) (*Route, error) {
 	query := `
-		SELECT id, service_id, name, hosts, paths, methods,
-		       strip_path, preserve_host, enabled, created_at, updated_at
-		FROM routes
-		WHERE id = $1
+		SELECT r.id, r.workspace, r.service_id, r.name, r.hosts, r.paths, r.methods,
+		       r.strip_path, r.preserve_host, r.priority_class, r.timeout_ms,
+		       r.schedule_start, r.schedule_end, r.schedule_cron, r.schedule_mode, r.schedule_timezone,
+		       r.slo, r.tags, r.enabled, r.created_at, r.updated_at,
+		       r.group_id, g.base_path, g.enabled
+		FROM routes r
+		LEFT JOIN route_groups g ON g.id = r.group_id
+		WHERE r.id = $1
 	`
 
 	var route Route
-	err := r.db.pool.QueryRowContext(ctx, query, id).Scan(
-		&route.ID, &route.ServiceID, &route.Name, &route.Hosts, &route.Paths, &route.Methods,
-		&route.StripPath, &route.PreserveHost, &route.Enabled, &route.CreatedAt, &route.UpdatedAt,
+	var group routeGroupColumns
+	err := r.db.queryRowRead(ctx, query, id).Scan(
+		&route.ID, &route.Workspace, &route.ServiceID, &route.Name, &route.Hosts, &route.Paths, &route.Methods,
+		&route.StripPath, &route.PreserveHost, &route.PriorityClass, &route.TimeoutMs,
+		&route.ScheduleStart, &route.ScheduleEnd, &route.ScheduleCron, &route.ScheduleMode, &route.ScheduleTimezone,
+		&route.SLO, &route.Tags, &route.Enabled, &route.CreatedAt, &route.UpdatedAt,
+		&route.GroupID, &group.basePath, &group.enabled,
 	)
 
 	if err != nil {
@@ -206,6 +302,7 @@ func (r *Repository) GetRouteByID(ctx context.Context, id string) (*Route, error
 		}
 		return nil, fmt.Errorf("failed to get route: %w", err)
 	}
+	group.apply(&route)
 
 	return &route, nil
 }
@@ -213,14 +310,18 @@ func (r *Repository) GetRouteByID(ctx context.Context, id string) (*Route, error
 // GetRoutesByServiceID retrieves all routes for a specific service.
 func (r *Repository) GetRoutesByServiceID(ctx context.Context, serviceID string) ([]*Route, error) {
 	query := `
-		SELECT id, service_id, name, hosts, paths, methods,
-		       strip_path, preserve_host, enabled, created_at, updated_at
-		FROM routes
-		WHERE service_id = $1 AND enabled = true
-		ORDER BY created_at DESC
+		SELECT r.id, r.workspace, r.service_id, r.name, r.hosts, r.paths, r.methods,
+		       r.strip_path, r.preserve_host, r.priority_class, r.timeout_ms,
+		       r.schedule_start, r.schedule_end, r.schedule_cron, r.schedule_mode, r.schedule_timezone,
+		       r.slo, r.tags, r.enabled, r.created_at, r.updated_at,
+		       r.group_id, g.base_path, g.enabled
+		FROM routes r
+		LEFT JOIN route_groups g ON g.id = r.group_id
+		WHERE r.service_id = $1 AND r.enabled = true AND COALESCE(g.enabled, true) = true
+		ORDER BY r.created_at DESC
 	`
 
-	rows, err := r.db.pool.QueryContext(ctx, query, serviceID)
+	rows, err := r.db.queryRead(ctx, query, serviceID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query routes by service: %w", err)
 	}
@@ -229,19 +330,228 @@ func (r *Repository) GetRoutesByServiceID(ctx context.Context, serviceID string)
 	var routes []*Route
 	for rows.Next() {
 		var route Route
+		var group routeGroupColumns
 		err := rows.Scan(
-			&route.ID, &route.ServiceID, &route.Name, &route.Hosts, &route.Paths, &route.Methods,
-			&route.StripPath, &route.PreserveHost, &route.Enabled, &route.CreatedAt, &route.UpdatedAt,
+			&route.ID, &route.Workspace, &route.ServiceID, &route.Name, &route.Hosts, &route.Paths, &route.Methods,
+			&route.StripPath, &route.PreserveHost, &route.PriorityClass, &route.TimeoutMs,
+			&route.ScheduleStart, &route.ScheduleEnd, &route.ScheduleCron, &route.ScheduleMode, &route.ScheduleTimezone,
+			&route.SLO, &route.Tags, &route.Enabled, &route.CreatedAt, &route.UpdatedAt,
+			&route.GroupID, &group.basePath, &group.enabled,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan route: %w", err)
 		}
+		group.apply(&route)
 		routes = append(routes, &route)
 	}
 
 	return routes, nil
 }
 
+// routeGroupColumns are the route_groups columns joined into route reads.
+type routeGroupColumns struct {
+	basePath sql.NullString
+	enabled  sql.NullBool
+}
+
+// apply turns a stored route into the effective route of its group: paths
+// under the group's base path, and disabled while the group is. Routes
+// without a group are left alone.
+func (g routeGroupColumns) apply(route *Route) {
+	if !route.GroupID.Valid {
+		return
+	}
+	if g.enabled.Valid && !g.enabled.Bool {
+		route.Enabled = false
+	}
+	base := strings.TrimSuffix(g.basePath.String, "/")
+	if base == "" {
+		return
+	}
+	paths := make(pq.StringArray, len(route.Paths))
+	for i, path := range route.Paths {
+		if path == "/" {
+			paths[i] = base
+			continue
+		}
+		paths[i] = base + path
+	}
+	route.Paths = paths
+}
+
+// ============================================================================
+// Redirects
+// ============================================================================
+
+// GetRedirects retrieves enabled redirect rules, oldest first (the order
+// they are evaluated in).
+func (r *Repository) GetRedirects(ctx context.Context) ([]*Redirect, error) {
+	query := `
+		SELECT id, name, hosts, paths, location, status_code, preserve_query,
+		       enabled, created_at, updated_at
+		FROM redirects
+		WHERE enabled = true
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.db.queryRead(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query redirects: %w", err)
+	}
+	defer rows.Close()
+
+	var redirects []*Redirect
+	for rows.Next() {
+		var redirect Redirect
+		err := rows.Scan(
+			&redirect.ID, &redirect.Name, &redirect.Hosts, &redirect.Paths,
+			&redirect.Location, &redirect.StatusCode, &redirect.PreserveQuery,
+			&redirect.Enabled, &redirect.CreatedAt, &redirect.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan redirect: %w", err)
+		}
+		redirects = append(redirects, &redirect)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating redirects: %w", err)
+	}
+
+	log.Debug().
+		Str("component", "repository").
+		Int("count", len(redirects)).
+		Msg("Retrieved redirects")
+
+	return redirects, nil
+}
+
+// ============================================================================
+// Stream Listeners
+// ============================================================================
+
+// GetStreamListeners retrieves the enabled stream listeners of enabled
+// services.
+func (r *Repository) GetStreamListeners(ctx context.Context) ([]*StreamListener, error) {
+	query := `
+		SELECT l.id, l.workspace, l.service_id, l.name, l.protocol, l.listen_address,
+		       l.max_connections, l.idle_timeout_ms, l.enabled, l.created_at, l.updated_at
+		FROM stream_listeners l
+		JOIN services s ON s.id = l.service_id
+		WHERE l.enabled = true AND s.enabled = true
+		ORDER BY l.created_at ASC
+	`
+
+	rows, err := r.db.queryRead(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stream listeners: %w", err)
+	}
+	defer rows.Close()
+
+	var listeners []*StreamListener
+	for rows.Next() {
+		var l StreamListener
+		err := rows.Scan(
+			&l.ID, &l.Workspace, &l.ServiceID, &l.Name, &l.Protocol, &l.ListenAddress,
+			&l.MaxConnections, &l.IdleTimeoutMs, &l.Enabled, &l.CreatedAt, &l.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stream listener: %w", err)
+		}
+		listeners = append(listeners, &l)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stream listeners: %w", err)
+	}
+
+	log.Debug().
+		Str("component", "repository").
+		Int("count", len(listeners)).
+		Msg("Retrieved stream listeners")
+
+	return listeners, nil
+}
+
+// ============================================================================
+// Tenants
+// ============================================================================
+
+// GetTenants retrieves the enabled tenants of the tenant registry.
+func (r *Repository) GetTenants(ctx context.Context) ([]*Tenant, error) {
+	query := `
+		SELECT id, name, enabled, created_at, updated_at
+		FROM tenants
+		WHERE enabled = true
+		ORDER BY id ASC
+	`
+
+	rows, err := r.db.queryRead(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tenants: %w", err)
+	}
+	defer rows.Close()
+
+	var tenants []*Tenant
+	for rows.Next() {
+		var tenant Tenant
+		err := rows.Scan(
+			&tenant.ID, &tenant.Name,
+			&tenant.Enabled, &tenant.CreatedAt, &tenant.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tenant: %w", err)
+		}
+		tenants = append(tenants, &tenant)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tenants: %w", err)
+	}
+
+	log.Debug().
+		Str("component", "repository").
+		Int("count", len(tenants)).
+		Msg("Retrieved tenants")
+
+	return tenants, nil
+}
+
+// ============================================================================
+// Gateway Settings
+// ============================================================================
+
+// GetGatewaySettings retrieves the runtime setting overrides, keyed by name.
+func (r *Repository) GetGatewaySettings(ctx context.Context) (map[string]string, error) {
+	query := `SELECT key, value FROM gateway_settings`
+
+	rows, err := r.db.queryRead(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query gateway settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := make(map[string]string)
+	for rows.Next() {
+		var setting GatewaySetting
+		if err := rows.Scan(&setting.Key, &setting.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan gateway setting: %w", err)
+		}
+		settings[setting.Key] = setting.Value
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating gateway settings: %w", err)
+	}
+
+	log.Debug().
+		Str("component", "repository").
+		Int("count", len(settings)).
+		Msg("Retrieved gateway settings")
+
+	return settings, nil
+}
+
 // ============================================================================
 // Consumers
 // ============================================================================
@@ -249,7 +559,7 @@ func (r *Repository) GetRoutesByServiceID(ctx context.Context, serviceID string)
 // GetConsumerByID retrieves a consumer by its ID.
 func (r *Repository) GetConsumerByID(ctx context.Context, id string) (*Consumer, error) {
 	query := `
-		SELECT id, username, email, custom_id, metadata, created_at, updated_at
+		SELECT id, workspace, username, email, custom_id, metadata, created_at, updated_at
 		FROM consumers
 		WHERE id = $1
 	`
@@ -257,8 +567,8 @@ func (r *Repository) GetConsumerByID(ctx context.Context, id string) (*Consumer,
 	var consumer Consumer
 	var metadataJSON []byte
 
-	err := r.db.pool.QueryRowContext(ctx, query, id).Scan(
-		&consumer.ID, &consumer.Username, &consumer.Email, &consumer.CustomID,
+	err := r.db.queryRowRead(ctx, query, id).Scan(
+		&consumer.ID, &consumer.Workspace, &consumer.Username, &consumer.Email, &consumer.CustomID,
 		&metadataJSON, &consumer.CreatedAt, &consumer.UpdatedAt,
 	)
 
@@ -279,19 +589,19 @@ func (r *Repository) GetConsumerByID(ctx context.Context, id string) (*Consumer,
 	return &consumer, nil
 }
 
-// GetConsumerByUsername retrieves a consumer by username.
-func (r *Repository) GetConsumerByUsername(ctx context.Context, username string) (*Consumer, error) {
+// GetConsumerByUsername retrieves a consumer by username within a workspace.
+func (r *Repository) GetConsumerByUsername(ctx context.Context, workspace, username string) (*Consumer, error) {
 	query := `
-		SELECT id, username, email, custom_id, metadata, created_at, updated_at
+		SELECT id, workspace, username, email, custom_id, metadata, created_at, updated_at
 		FROM consumers
-		WHERE username = $1
+		WHERE workspace = $1 AND username = $2
 	`
 
 	var consumer Consumer
 	var metadataJSON []byte
 
-	err := r.db.pool.QueryRowContext(ctx, query, username).Scan(
-		&consumer.ID, &consumer.Username, &consumer.Email, &consumer.CustomID,
+	err := r.db.queryRowRead(ctx, query, WorkspaceName(workspace), username).Scan(
+		&consumer.ID, &consumer.Workspace, &consumer.Username, &consumer.Email, &consumer.CustomID,
 		&metadataJSON, &consumer.CreatedAt, &consumer.UpdatedAt,
 	)
 
@@ -315,26 +625,29 @@ func (r *Repository) GetConsumerByUsername(ctx context.Context, username string)
 // GetConsumerByAPIKeyHash retrieves a consumer by API key hash.
 //
 // This is the critical path for API key authentication.
-// Returns the consumer associated with the given key hash.
+// Returns the consumer associated with the given key hash. Disabled and
+// expired keys are ignored; if no key matches, the error wraps
+// sql.ErrNoRows.
 func (r *Repository) GetConsumerByAPIKeyHash(ctx context.Context, keyHash string) (*Consumer, error) {
 	query := `
-		SELECT c.id, c.username, c.email, c.custom_id, c.metadata, c.created_at, c.updated_at
+		SELECT c.id, c.workspace, c.username, c.email, c.custom_id, c.metadata, c.created_at, c.updated_at
 		FROM consumers c
 		INNER JOIN api_keys k ON c.id = k.consumer_id
 		WHERE k.key_hash = $1 AND k.enabled = true
+		  AND (k.expires_at IS NULL OR k.expires_at > NOW())
 	`
 
 	var consumer Consumer
 	var metadataJSON []byte
 
-	err := r.db.pool.QueryRowContext(ctx, query, keyHash).Scan(
-		&consumer.ID, &consumer.Username, &consumer.Email, &consumer.CustomID,
+	err := r.db.queryRowRead(ctx, query, keyHash).Scan(
+		&consumer.ID, &consumer.Workspace, &consumer.Username, &consumer.Email, &consumer.CustomID,
 		&metadataJSON, &consumer.CreatedAt, &consumer.UpdatedAt,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("no consumer found for API key")
+			return nil, fmt.Errorf("no consumer found for API key: %w", err)
 		}
 		return nil, fmt.Errorf("failed to get consumer by API key: %w", err)
 	}
@@ -355,6 +668,64 @@ func (r *Repository) GetConsumerByAPIKeyHash(ctx context.Context, keyHash string
 	return &consumer, nil
 }
 
+// GetConsumerByAPIKey retrieves a consumer by plaintext API key, hashing
+// it with the configured pepper.
+func (r *Repository) GetConsumerByAPIKey(ctx context.Context, apiKey string) (*Consumer, error) {
+	return r.GetConsumerByAPIKeyHash(ctx, r.HashAPIKey(apiKey))
+}
+
+// GetActiveAPIKeyConsumers retrieves up to limit enabled, unexpired API
+// keys with their consumers, most recently used first.
+//
+// Used to warm the API key cache at startup so the first requests after
+// a deploy don't each query Postgres.
+func (r *Repository) GetActiveAPIKeyConsumers(ctx context.Context, limit int) ([]*APIKeyConsumer, error) {
+	query := `
+		SELECT k.key_hash, c.id, c.workspace, c.username, c.email, c.custom_id, c.metadata, c.created_at, c.updated_at
+		FROM api_keys k
+		INNER JOIN consumers c ON c.id = k.consumer_id
+		WHERE k.enabled = true
+		  AND (k.expires_at IS NULL OR k.expires_at > NOW())
+		ORDER BY k.last_used_at DESC NULLS LAST, k.created_at DESC
+		LIMIT $1
+	`
+
+	rows, err := r.db.queryRead(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKeyConsumer
+	for rows.Next() {
+		var key APIKeyConsumer
+		var consumer Consumer
+		var metadataJSON []byte
+
+		if err := rows.Scan(
+			&key.KeyHash, &consumer.ID, &consumer.Workspace, &consumer.Username, &consumer.Email, &consumer.CustomID,
+			&metadataJSON, &consumer.CreatedAt, &consumer.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+
+		if len(metadataJSON) > 0 {
+			if err := json.Unmarshal(metadataJSON, &consumer.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal consumer metadata: %w", err)
+			}
+		}
+
+		key.Consumer = &consumer
+		keys = append(keys, &key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
 // ============================================================================
 // Plugins
 // ============================================================================
@@ -364,14 +735,14 @@ func (r *Repository) GetConsumerByAPIKeyHash(ctx context.Context, keyHash string
 // Returns plugins ordered by priority (lower = executes first).
 func (r *Repository) GetPlugins(ctx context.Context, enabledOnly bool) ([]*Plugin, error) {
 	query := `
-		SELECT id, name, scope, service_id, route_id, consumer_id,
-		       config, enabled, priority, created_at, updated_at
+		SELECT id, workspace, name, scope, service_id, route_id, group_id, consumer_id,
+		       config, tags, enabled, priority, created_at, updated_at
 		FROM plugins
 		WHERE enabled = true OR $1 = false
 		ORDER BY priority ASC, created_at ASC
 	`
 
-	rows, err := r.db.pool.QueryContext(ctx, query, enabledOnly)
+	rows, err := r.db.queryRead(ctx, query, enabledOnly)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query plugins: %w", err)
 	}
@@ -383,8 +754,8 @@ func (r *Repository) GetPlugins(ctx context.Context, enabledOnly bool) ([]*Plugi
 		var configJSON []byte
 
 		err := rows.Scan(
-			&plugin.ID, &plugin.Name, &plugin.Scope, &plugin.ServiceID, &plugin.RouteID, &plugin.ConsumerID,
-			&configJSON, &plugin.Enabled, &plugin.Priority, &plugin.CreatedAt, &plugin.UpdatedAt,
+			&plugin.ID, &plugin.Workspace, &plugin.Name, &plugin.Scope, &plugin.ServiceID, &plugin.RouteID, &plugin.GroupID, &plugin.ConsumerID,
+			&configJSON, &plugin.Tags, &plugin.Enabled, &plugin.Priority, &plugin.CreatedAt, &plugin.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan plugin: %w", err)
@@ -397,6 +768,10 @@ func (r *Repository) GetPlugins(ctx context.Context, enabledOnly bool) ([]*Plugi
 			}
 		}
 
+		if err := fieldcrypt.DecryptFields(r.cipher, plugin.Config); err != nil {
+			return nil, fmt.Errorf("failed to decrypt config of plugin %s (%s): %w", plugin.Name, plugin.ID, err)
+		}
+
 		plugins = append(plugins, &plugin)
 	}
 
@@ -416,32 +791,34 @@ func (r *Repository) GetPlugins(ctx context.Context, enabledOnly bool) ([]*Plugi
 // GetPluginsByRouteID retrieves all plugins for a specific route.
 //
 // This includes:
-//   - Global plugins (scope = 'global')
+//   - Global plugins (scope = 'global') of the route's workspace
 //   - Service-level plugins (for the route's service)
+//   - Group-level plugins (for the route's group)
 //   - Route-specific plugins
 //
 // Returns plugins ordered by priority.
 func (r *Repository) GetPluginsByRouteID(ctx context.Context, routeID string) ([]*Plugin, error) {
-	// First, get the route to find its service_id
+	// First, get the route to find its service_id and workspace
 	route, err := r.GetRouteByID(ctx, routeID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get route: %w", err)
 	}
 
 	query := `
-		SELECT id, name, scope, service_id, route_id, consumer_id,
-		       config, enabled, priority, created_at, updated_at
+		SELECT id, workspace, name, scope, service_id, route_id, group_id, consumer_id,
+		       config, tags, enabled, priority, created_at, updated_at
 		FROM plugins
 		WHERE enabled = true
 		  AND (
-		      scope = 'global'
+		      (scope = 'global' AND workspace = $3)
 		      OR (scope = 'service' AND service_id = $1)
 		      OR (scope = 'route' AND route_id = $2)
+		      OR (scope = 'group' AND group_id = $4)
 		  )
 		ORDER BY priority ASC, created_at ASC
 	`
 
-	rows, err := r.db.pool.QueryContext(ctx, query, route.ServiceID, routeID)
+	rows, err := r.db.queryRead(ctx, query, route.ServiceID, routeID, WorkspaceName(route.Workspace), route.GroupID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query plugins for route: %w", err)
 	}
@@ -453,8 +830,8 @@ func (r *Repository) GetPluginsByRouteID(ctx context.Context, routeID string) ([
 		var configJSON []byte
 
 		err := rows.Scan(
-			&plugin.ID, &plugin.Name, &plugin.Scope, &plugin.ServiceID, &plugin.RouteID, &plugin.ConsumerID,
-			&configJSON, &plugin.Enabled, &plugin.Priority, &plugin.CreatedAt, &plugin.UpdatedAt,
+			&plugin.ID, &plugin.Workspace, &plugin.Name, &plugin.Scope, &plugin.ServiceID, &plugin.RouteID, &plugin.GroupID, &plugin.ConsumerID,
+			&configJSON, &plugin.Tags, &plugin.Enabled, &plugin.Priority, &plugin.CreatedAt, &plugin.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan plugin: %w", err)
@@ -467,22 +844,97 @@ func (r *Repository) GetPluginsByRouteID(ctx context.Context, routeID string) ([
 			}
 		}
 
+		if err := fieldcrypt.DecryptFields(r.cipher, plugin.Config); err != nil {
+			return nil, fmt.Errorf("failed to decrypt config of plugin %s (%s): %w", plugin.Name, plugin.ID, err)
+		}
+
 		plugins = append(plugins, &plugin)
 	}
 
 	return plugins, nil
 }
 
+// EncryptPluginConfigs encrypts the plaintext values of designated config
+// keys in every plugin row, in one transaction.
+//
+// Used to migrate existing configs after enabling encryption at rest.
+// Already-encrypted values are left alone, so it is safe to re-run.
+// Returns the number of plugins updated.
+func (r *Repository) EncryptPluginConfigs(ctx context.Context, cipher *fieldcrypt.Cipher, keys []string) (int, error) {
+	tx, err := r.db.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, config FROM plugins FOR UPDATE`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query plugins: %w", err)
+	}
+
+	updates := make(map[string][]byte)
+	for rows.Next() {
+		var id string
+		var configJSON []byte
+		if err := rows.Scan(&id, &configJSON); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan plugin: %w", err)
+		}
+
+		var config map[string]interface{}
+		if len(configJSON) == 0 || json.Unmarshal(configJSON, &config) != nil || config == nil {
+			continue
+		}
+
+		changed, err := cipher.EncryptFields(config, keys)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to encrypt config of plugin %s: %w", id, err)
+		}
+		if changed == 0 {
+			continue
+		}
+
+		encoded, err := json.Marshal(config)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to marshal config of plugin %s: %w", id, err)
+		}
+		updates[id] = encoded
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating plugins: %w", err)
+	}
+
+	for id, config := range updates {
+		if _, err := tx.ExecContext(ctx, `UPDATE plugins SET config = $1 WHERE id = $2`, config, id); err != nil {
+			return 0, fmt.Errorf("failed to update plugin %s: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit encrypted configs: %w", err)
+	}
+
+	log.Info().
+		Str("component", "repository").
+		Int("updated", len(updates)).
+		Msg("Encrypted plugin config fields")
+
+	return len(updates), nil
+}
+
 // GetServiceTargets retrieves all targets for a specific service.
 func (r *Repository) GetServiceTargets(ctx context.Context, serviceID string) ([]*ServiceTarget, error) {
 	query := `
-		SELECT id, service_id, target, weight, health_check_path, enabled, created_at
+		SELECT id, service_id, target, weight, health_check_path, region, enabled, created_at
 		FROM service_targets
 		WHERE service_id = $1 AND enabled = true
 		ORDER BY created_at ASC
 	`
 
-	rows, err := r.db.pool.QueryContext(ctx, query, serviceID)
+	rows, err := r.db.queryRead(ctx, query, serviceID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query service targets: %w", err)
 	}
@@ -493,7 +945,7 @@ func (r *Repository) GetServiceTargets(ctx context.Context, serviceID string) ([
 		var target ServiceTarget
 		err := rows.Scan(
 			&target.ID, &target.ServiceID, &target.Target, &target.Weight,
-			&target.HealthCheckPath, &target.Enabled, &target.CreatedAt,
+			&target.HealthCheckPath, &target.Region, &target.Enabled, &target.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan service target: %w", err)
@@ -503,3 +955,240 @@ func (r *Repository) GetServiceTargets(ctx context.Context, serviceID string) ([
 
 	return targets, nil
 }
+
+// GetAllServiceTargets retrieves all enabled targets for all services.
+//
+// Used by the load balancer manager to build per-service balancers in one query.
+func (r *Repository) GetAllServiceTargets(ctx context.Context) ([]*ServiceTarget, error) {
+	query := `
+		SELECT id, service_id, target, weight, health_check_path, region, enabled, created_at
+		FROM service_targets
+		WHERE enabled = true
+		ORDER BY service_id, created_at ASC
+	`
+
+	rows, err := r.db.queryRead(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query service targets: %w", err)
+	}
+	defer rows.Close()
+
+	var targets []*ServiceTarget
+	for rows.Next() {
+		var target ServiceTarget
+		err := rows.Scan(
+			&target.ID, &target.ServiceID, &target.Target, &target.Weight,
+			&target.HealthCheckPath, &target.Region, &target.Enabled, &target.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan service target: %w", err)
+		}
+		targets = append(targets, &target)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating service targets: %w", err)
+	}
+
+	log.Debug().
+		Str("component", "repository").
+		Int("count", len(targets)).
+		Msg("Retrieved service targets")
+
+	return targets, nil
+}
+
+// ============================================================================
+// Recorded Requests
+// ============================================================================
+
+// InsertRecordedRequest stores a sampled request.
+func (r *Repository) InsertRecordedRequest(ctx context.Context, req *RecordedRequest) error {
+	headersJSON, err := json.Marshal(req.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recorded headers: %w", err)
+	}
+
+	query := `
+		INSERT INTO recorded_requests
+			(route_id, method, path, query, host, headers, body, body_truncated, status_code, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, recorded_at
+	`
+
+	err = r.db.pool.QueryRowContext(ctx, query,
+		req.RouteID, req.Method, req.Path, req.Query, req.Host,
+		headersJSON, req.Body, req.Truncated, req.StatusCode, req.DurationMs,
+	).Scan(&req.ID, &req.RecordedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert recorded request: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecordedRequests retrieves recorded requests in recording order.
+func (r *Repository) GetRecordedRequests(ctx context.Context, filter RecordedRequestFilter) ([]*RecordedRequest, error) {
+	query := `
+		SELECT id, route_id, method, path, query, host, headers, body,
+		       body_truncated, status_code, duration_ms, recorded_at
+		FROM recorded_requests
+		WHERE ($1 = '' OR route_id::text = $1)
+		  AND recorded_at >= $2
+		ORDER BY recorded_at ASC, id ASC
+	`
+	args := []interface{}{filter.RouteID, filter.Since}
+	if filter.Limit > 0 {
+		query += " LIMIT $3"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := r.db.queryRead(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recorded requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*RecordedRequest
+	for rows.Next() {
+		var req RecordedRequest
+		var rawQuery, host sql.NullString
+		var headersJSON []byte
+
+		err := rows.Scan(
+			&req.ID, &req.RouteID, &req.Method, &req.Path, &rawQuery, &host, &headersJSON, &req.Body,
+			&req.Truncated, &req.StatusCode, &req.DurationMs, &req.RecordedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recorded request: %w", err)
+		}
+		req.Query = rawQuery.String
+		req.Host = host.String
+
+		if len(headersJSON) > 0 {
+			if err := json.Unmarshal(headersJSON, &req.Headers); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal recorded headers: %w", err)
+			}
+		}
+
+		requests = append(requests, &req)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating recorded requests: %w", err)
+	}
+
+	log.Debug().
+		Str("component", "repository").
+		Int("count", len(requests)).
+		Str("route_id", filter.RouteID).
+		Msg("Retrieved recorded requests")
+
+	return requests, nil
+}
+
+// ============================================================================
+// Consumer Usage
+// ============================================================================
+
+// UpsertConsumerUsage adds usage deltas to the daily rollup rows, creating
+// them as needed. Counters are added, not replaced, so every gateway
+// instance can flush its own deltas.
+func (r *Repository) UpsertConsumerUsage(ctx context.Context, usage []*ConsumerUsage) error {
+	if len(usage) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO consumer_usage_daily
+			(consumer_id, day, requests, client_errors, server_errors, bytes_in, bytes_out)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (consumer_id, day) DO UPDATE SET
+			requests = consumer_usage_daily.requests + EXCLUDED.requests,
+			client_errors = consumer_usage_daily.client_errors + EXCLUDED.client_errors,
+			server_errors = consumer_usage_daily.server_errors + EXCLUDED.server_errors,
+			bytes_in = consumer_usage_daily.bytes_in + EXCLUDED.bytes_in,
+			bytes_out = consumer_usage_daily.bytes_out + EXCLUDED.bytes_out,
+			updated_at = NOW()
+	`
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare usage upsert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, u := range usage {
+		_, err := stmt.ExecContext(ctx,
+			u.ConsumerID, u.Day.Format("2006-01-02"),
+			u.Requests, u.ClientErrors, u.ServerErrors, u.BytesIn, u.BytesOut,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to upsert usage for consumer %s: %w", u.ConsumerID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit usage: %w", err)
+	}
+
+	log.Debug().
+		Str("component", "repository").
+		Int("rows", len(usage)).
+		Msg("Upserted consumer usage")
+
+	return nil
+}
+
+// GetConsumerUsage retrieves a consumer's daily usage between from and to
+// (inclusive UTC days), oldest first. Days without traffic have no row.
+func (r *Repository) GetConsumerUsage(ctx context.Context, consumerID string, from, to time.Time) ([]*ConsumerUsage, error) {
+	query := `
+		SELECT consumer_id, day, requests, client_errors, server_errors, bytes_in, bytes_out
+		FROM consumer_usage_daily
+		WHERE consumer_id = $1 AND day >= $2 AND day <= $3
+		ORDER BY day ASC
+	`
+
+	rows, err := r.db.queryRead(ctx, query, consumerID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query consumer usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []*ConsumerUsage
+	for rows.Next() {
+		var u ConsumerUsage
+		err := rows.Scan(
+			&u.ConsumerID, &u.Day, &u.Requests, &u.ClientErrors, &u.ServerErrors, &u.BytesIn, &u.BytesOut,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan consumer usage: %w", err)
+		}
+		u.Day = u.Day.UTC()
+		usage = append(usage, &u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating consumer usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// DeleteConsumerUsageBefore removes rollup rows older than day (exclusive).
+// Returns the number of rows deleted.
+func (r *Repository) DeleteConsumerUsageBefore(ctx context.Context, day time.Time) (int64, error) {
+	result, err := r.db.pool.ExecContext(ctx,
+		`DELETE FROM consumer_usage_daily WHERE day < $1`, day.Format("2006-01-02"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete consumer usage: %w", err)
+	}
+	return result.RowsAffected()
+}
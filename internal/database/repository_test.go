@@ -135,6 +135,7 @@ func TestModels_PluginScopeValidation(t *testing.T) {
 		PluginScopeGlobal:   true,
 		PluginScopeService:  true,
 		PluginScopeRoute:    true,
+		PluginScopeGroup:    true,
 		PluginScopeConsumer: true,
 	}
 
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// maxCachedStatements bounds a statement cache. Repository queries are
+// static strings, so this is only reached if a caller builds queries
+// dynamically; further queries then run unprepared.
+const maxCachedStatements = 256
+
+// stmtCache keeps one prepared statement per query text for a pool.
+//
+// database/sql prepares a cached *sql.Stmt lazily on each connection it
+// runs on and re-prepares after reconnects, so every connection parses and
+// plans a query once instead of on every call. With PgBouncer in
+// transaction mode prepared statements don't survive across transactions;
+// disable the cache there (DB_STATEMENT_CACHE=false).
+//
+// A nil *stmtCache runs queries unprepared.
+type stmtCache struct {
+	pool *sql.DB
+
+	mu    sync.RWMutex
+	stmts map[string]*sql.Stmt
+}
+
+// newStmtCache creates a statement cache for pool.
+func newStmtCache(pool *sql.DB) *stmtCache {
+	return &stmtCache{pool: pool, stmts: make(map[string]*sql.Stmt)}
+}
+
+// query runs a query through a cached prepared statement.
+func (c *stmtCache) query(ctx context.Context, pool *sql.DB, query string, args ...interface{}) (*sql.Rows, error) {
+	if c == nil {
+		return pool.QueryContext(ctx, query, args...)
+	}
+
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if stmt == nil {
+		return pool.QueryContext(ctx, query, args...)
+	}
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if isStalePlan(err) {
+		// The schema changed under the statement (e.g. a migration added a
+		// column); drop it and run this query unprepared
+		c.evict(query)
+		return pool.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+// prepare returns the cached statement for query, preparing it on first
+// use. Returns nil (and no error) when the cache is full.
+func (c *stmtCache) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	c.mu.RLock()
+	stmt, ok := c.stmts[query]
+	c.mu.RUnlock()
+	if ok {
+		return stmt, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt, nil
+	}
+	if len(c.stmts) >= maxCachedStatements {
+		return nil, nil
+	}
+
+	stmt, err := c.pool.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+// evict drops and closes a cached statement.
+func (c *stmtCache) evict(query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if stmt, ok := c.stmts[query]; ok {
+		stmt.Close()
+		delete(c.stmts, query)
+	}
+}
+
+// close closes every cached statement.
+func (c *stmtCache) close() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for query, stmt := range c.stmts {
+		stmt.Close()
+		delete(c.stmts, query)
+	}
+}
+
+// len returns the number of cached statements.
+func (c *stmtCache) len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.stmts)
+}
+
+// isStalePlan reports whether err is Postgres rejecting a prepared
+// statement whose result columns changed since it was prepared.
+func isStalePlan(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "0A000"
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+)
+
+// countingDriver is a database/sql driver that counts prepares and
+// answers every query with the rows configured on it.
+type countingDriver struct {
+	prepares atomic.Int64
+	rows     [][]driver.Value
+}
+
+func (d *countingDriver) Open(name string) (driver.Conn, error) { return &countingConn{d: d}, nil }
+
+type countingConn struct{ d *countingDriver }
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.prepares.Add(1)
+	return &countingStmt{d: c.d}, nil
+}
+func (c *countingConn) Close() error              { return nil }
+func (c *countingConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type countingStmt struct{ d *countingDriver }
+
+func (s *countingStmt) Close() error  { return nil }
+func (s *countingStmt) NumInput() int { return -1 }
+func (s *countingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s *countingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &countingRows{rows: s.d.rows}, nil
+}
+
+type countingRows struct {
+	rows [][]driver.Value
+	pos  int
+}
+
+func (r *countingRows) Columns() []string { return []string{"value"} }
+func (r *countingRows) Close() error      { return nil }
+func (r *countingRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
+
+var testDriver = &countingDriver{}
+
+func init() {
+	sql.Register("counting", testDriver)
+}
+
+func newCountingDB(t *testing.T, rows [][]driver.Value, cache bool) *DB {
+	t.Helper()
+	testDriver.prepares.Store(0)
+	testDriver.rows = rows
+
+	pool, err := sql.Open("counting", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetMaxOpenConns(1)
+	t.Cleanup(func() { pool.Close() })
+
+	db := &DB{pool: pool}
+	if cache {
+		db.stmts = newStmtCache(pool)
+	}
+	return db
+}
+
+func TestStmtCache_PreparesOnce(t *testing.T) {
+	db := newCountingDB(t, [][]driver.Value{{"a"}}, true)
+
+	for i := 0; i < 5; i++ {
+		var value string
+		if err := db.queryRowRead(context.Background(), "SELECT value FROM t WHERE id = $1", i).Scan(&value); err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		if value != "a" {
+			t.Fatalf("value = %q, want a", value)
+		}
+	}
+
+	if n := testDriver.prepares.Load(); n != 1 {
+		t.Errorf("prepares = %d, want 1", n)
+	}
+	if db.stmts.len() != 1 {
+		t.Errorf("cached statements = %d, want 1", db.stmts.len())
+	}
+}
+
+func TestStmtCache_Disabled(t *testing.T) {
+	db := newCountingDB(t, [][]driver.Value{{"a"}}, false)
+
+	for i := 0; i < 3; i++ {
+		rows, err := db.queryRead(context.Background(), "SELECT value FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+	}
+
+	if n := testDriver.prepares.Load(); n != 3 {
+		t.Errorf("prepares = %d, want 3 (one per unprepared query)", n)
+	}
+}
+
+func TestReadRow_NoRows(t *testing.T) {
+	db := newCountingDB(t, nil, true)
+
+	var value string
+	err := db.queryRowRead(context.Background(), "SELECT value FROM t WHERE id = $1", 1).Scan(&value)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Scan = %v, want sql.ErrNoRows", err)
+	}
+}
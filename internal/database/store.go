@@ -0,0 +1,43 @@
+package database
+
+import "context"
+
+// ConfigStore is the read side of gateway configuration: everything the
+// router, plugin registry, load balancers, redirect rules, stream
+// listeners, tenant registry and runtime settings load at startup and on
+// hot reload.
+//
+// Repository implements it on top of Postgres; MemoryStore keeps the
+// configuration in memory, for tests and for running without a database.
+type ConfigStore interface {
+	// GetRoutes returns enabled routes, or all routes if includeDisabled
+	GetRoutes(ctx context.Context, includeDisabled bool) ([]*Route, error)
+
+	// GetServices returns enabled services, or all services if includeDisabled
+	GetServices(ctx context.Context, includeDisabled bool) ([]*Service, error)
+
+	// GetPlugins returns plugins ordered by priority; only enabled ones if
+	// enabledOnly. Config secrets are already decrypted.
+	GetPlugins(ctx context.Context, enabledOnly bool) ([]*Plugin, error)
+
+	// GetAllServiceTargets returns every enabled target, grouped by service
+	GetAllServiceTargets(ctx context.Context) ([]*ServiceTarget, error)
+
+	// GetRedirects returns enabled redirect rules, oldest first
+	GetRedirects(ctx context.Context) ([]*Redirect, error)
+
+	// GetStreamListeners returns the enabled stream listeners of enabled
+	// services, oldest first
+	GetStreamListeners(ctx context.Context) ([]*StreamListener, error)
+
+	// GetTenants returns the enabled tenants of the tenant registry
+	GetTenants(ctx context.Context) ([]*Tenant, error)
+
+	// GetGatewaySettings returns runtime setting overrides, keyed by name
+	GetGatewaySettings(ctx context.Context) (map[string]string, error)
+}
+
+var (
+	_ ConfigStore = (*Repository)(nil)
+	_ ConfigStore = (*MemoryStore)(nil)
+)
@@ -0,0 +1,91 @@
+// Package database - Tags on services, routes and plugins
+//
+// Tags are free-form labels ("team-payments", "tier-1", "pci") used to
+// filter configuration (`gateway routes list -tag team-payments`,
+// ?tags= on the admin API listings) and to break down metrics and
+// decision logs by team. A request carries the tags of its route and its
+// service.
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+// MaxTags is the number of tags an entity may have.
+const MaxTags = 20
+
+// tagPattern is the format of a tag (at most 64 characters).
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._:-]{0,63}$`)
+
+// ValidateTags checks the tags of one entity: lowercase letters, digits,
+// '.', '_', ':' and '-', at most 64 characters each, no duplicates.
+func ValidateTags(tags []string) error {
+	if len(tags) > MaxTags {
+		return fmt.Errorf("at most %d tags are allowed, got %d", MaxTags, len(tags))
+	}
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if !tagPattern.MatchString(tag) {
+			return fmt.Errorf("invalid tag %q: use lowercase letters, digits, '.', '_', ':' and '-' (at most 64 characters)", tag)
+		}
+		if seen[tag] {
+			return fmt.Errorf("tag %q is listed more than once", tag)
+		}
+		seen[tag] = true
+	}
+	return nil
+}
+
+// MergeTags returns the distinct tags of the given lists, sorted, e.g. a
+// route's and its service's tags.
+func MergeTags(lists ...[]string) []string {
+	total := 0
+	for _, tags := range lists {
+		total += len(tags)
+	}
+	if total == 0 {
+		return nil
+	}
+
+	merged := make([]string, 0, total)
+	seen := make(map[string]bool)
+	for _, tags := range lists {
+		for _, tag := range tags {
+			if !seen[tag] {
+				seen[tag] = true
+				merged = append(merged, tag)
+			}
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// HasTags reports whether tags includes every tag of want.
+func HasTags(tags, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, tag := range tags {
+			if tag == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// tagArray converts tags for a NOT NULL array column (nil would be NULL).
+func tagArray(tags []string) pq.StringArray {
+	if tags == nil {
+		return pq.StringArray{}
+	}
+	return pq.StringArray(tags)
+}
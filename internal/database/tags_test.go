@@ -0,0 +1,44 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateTags(t *testing.T) {
+	if err := ValidateTags([]string{"team-payments", "tier.1", "pci:scope", "a_b"}); err != nil {
+		t.Errorf("ValidateTags() error = %v", err)
+	}
+
+	tests := []struct {
+		tags []string
+		want string
+	}{
+		{[]string{"Team"}, "invalid tag"},
+		{[]string{"-leading"}, "invalid tag"},
+		{[]string{strings.Repeat("a", 65)}, "invalid tag"},
+		{[]string{"a", "a"}, "more than once"},
+		{make([]string, MaxTags+1), "at most"},
+	}
+	for _, tt := range tests {
+		err := ValidateTags(tt.tags)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ValidateTags(%q) error = %v, want %q", tt.tags, err, tt.want)
+		}
+	}
+}
+
+func TestMergeAndHasTags(t *testing.T) {
+	merged := MergeTags([]string{"tier-1", "team-payments"}, nil, []string{"team-payments", "pci"})
+	if want := []string{"pci", "team-payments", "tier-1"}; !reflect.DeepEqual(merged, want) {
+		t.Errorf("MergeTags() = %v, want %v", merged, want)
+	}
+
+	if !HasTags(merged, []string{"pci", "tier-1"}) || !HasTags(merged, nil) {
+		t.Error("HasTags() should match a subset")
+	}
+	if HasTags(merged, []string{"pci", "team-search"}) {
+		t.Error("HasTags() should require every tag")
+	}
+}
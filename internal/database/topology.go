@@ -0,0 +1,884 @@
+// Package database - Topology export and apply
+//
+// A Topology is a workspace's routing configuration (services and their
+// targets, route groups, routes and plugins) with entities referring to
+// each other by name instead of ID, so it can be exported from one
+// environment and applied to another (see `gateway export` and
+// `gateway promote`).
+//
+// Consumers, their API keys and consumer-scoped plugins are environment
+// data and are not part of a topology; neither are route schedules.
+// Plugin configs are exported as stored, so fields encrypted with
+// CONFIG_ENCRYPTION_KEY only apply where the same key is configured.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// TopologyVersion is the format version written by ExportTopology.
+const TopologyVersion = 1
+
+// Topology is the portable routing configuration of a workspace.
+type Topology struct {
+	Version     int                  `json:"version"`
+	Workspace   string               `json:"workspace"`
+	Services    []TopologyService    `json:"services"`
+	RouteGroups []TopologyRouteGroup `json:"route_groups,omitempty"`
+	Routes      []TopologyRoute      `json:"routes"`
+	Plugins     []TopologyPlugin     `json:"plugins,omitempty"`
+}
+
+// TopologyService is a service and its targets.
+type TopologyService struct {
+	Name              string              `json:"name"`
+	Protocol          string              `json:"protocol"`
+	Host              string              `json:"host"`
+	Port              int                 `json:"port"`
+	Path              string              `json:"path,omitempty"`
+	ConnectTimeoutMs  int                 `json:"connect_timeout_ms"`
+	ReadTimeoutMs     int                 `json:"read_timeout_ms"`
+	WriteTimeoutMs    int                 `json:"write_timeout_ms"`
+	Retries           int                 `json:"retries"`
+	LoadBalancerType  string              `json:"load_balancer_type"`
+	HashOn            string              `json:"hash_on"`
+	HashOnKey         string              `json:"hash_on_key,omitempty"`
+	HashBalanceFactor float64             `json:"hash_balance_factor"`
+	IPFamily          string              `json:"ip_family"`
+	HealthCheck       *ServiceHealthCheck `json:"health_check,omitempty"`
+	Tags              []string            `json:"tags,omitempty"`
+	Enabled           bool                `json:"enabled"`
+	Targets           []TopologyTarget    `json:"targets,omitempty"`
+}
+
+// TopologyTarget is a backend instance of a service.
+type TopologyTarget struct {
+	Target          string `json:"target"` // host:port
+	Weight          int    `json:"weight"`
+	HealthCheckPath string `json:"health_check_path"`
+	Region          string `json:"region,omitempty"`
+	Enabled         bool   `json:"enabled"`
+}
+
+// TopologyRouteGroup is a route group of a service.
+type TopologyRouteGroup struct {
+	Name        string `json:"name"`
+	Service     string `json:"service"`
+	Description string `json:"description,omitempty"`
+	BasePath    string `json:"base_path,omitempty"`
+	Enabled     bool   `json:"enabled"`
+}
+
+// TopologyRoute is a route. Routes are matched by name, so every route of
+// an exported workspace must have one.
+type TopologyRoute struct {
+	Name          string    `json:"name"`
+	Service       string    `json:"service"`
+	Group         string    `json:"group,omitempty"`
+	Hosts         []string  `json:"hosts,omitempty"`
+	Paths         []string  `json:"paths"` // relative to the group's base path
+	Methods       []string  `json:"methods"`
+	StripPath     bool      `json:"strip_path"`
+	PreserveHost  bool      `json:"preserve_host"`
+	PriorityClass string    `json:"priority_class"`
+	TimeoutMs     int       `json:"timeout_ms,omitempty"`
+	SLO           *RouteSLO `json:"slo,omitempty"`
+	Tags          []string  `json:"tags,omitempty"`
+	Enabled       bool      `json:"enabled"`
+}
+
+// TopologyPlugin is a global, service, group or route plugin. Its scope
+// target is named by Service, Group or Route.
+type TopologyPlugin struct {
+	Name     string                 `json:"name"`
+	Scope    string                 `json:"scope"`
+	Service  string                 `json:"service,omitempty"`
+	Group    string                 `json:"group,omitempty"`
+	Route    string                 `json:"route,omitempty"`
+	Config   map[string]interface{} `json:"config"`
+	Tags     []string               `json:"tags,omitempty"`
+	Enabled  bool                   `json:"enabled"`
+	Priority int                    `json:"priority"`
+}
+
+// key identifies a plugin instance across environments.
+func (p TopologyPlugin) key() string {
+	return p.Name + "\x00" + p.Scope + "\x00" + p.Service + "\x00" + p.Group + "\x00" + p.Route
+}
+
+// TopologyApplyResult counts the changes made by ApplyTopology.
+type TopologyApplyResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+}
+
+// ============================================================================
+// Export
+// ============================================================================
+
+// ExportTopology reads the routing configuration of a workspace.
+//
+// Returns an error if a route has no name or two routes share one, since
+// routes are matched by name when the topology is applied.
+func (r *Repository) ExportTopology(ctx context.Context, workspace string) (*Topology, error) {
+	workspace = WorkspaceName(workspace)
+	topology := &Topology{Version: TopologyVersion, Workspace: workspace}
+
+	tx, err := r.db.pool.BeginTx(ctx, &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	serviceNames, err := exportServices(ctx, tx, topology)
+	if err != nil {
+		return nil, err
+	}
+	groupNames, err := exportRouteGroups(ctx, tx, topology, serviceNames)
+	if err != nil {
+		return nil, err
+	}
+	routeNames, err := exportRoutes(ctx, tx, topology, serviceNames, groupNames)
+	if err != nil {
+		return nil, err
+	}
+	if err := exportPlugins(ctx, tx, topology, serviceNames, groupNames, routeNames); err != nil {
+		return nil, err
+	}
+
+	log.Debug().
+		Str("component", "repository").
+		Str("workspace", workspace).
+		Int("services", len(topology.Services)).
+		Int("routes", len(topology.Routes)).
+		Int("plugins", len(topology.Plugins)).
+		Msg("Exported topology")
+
+	return topology, nil
+}
+
+// exportServices adds the workspace's services and targets, returning
+// service names by ID.
+func exportServices(ctx context.Context, tx *sql.Tx, topology *Topology) (map[string]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, name, protocol, host, port, path, connect_timeout_ms, read_timeout_ms, write_timeout_ms,
+		       retries, load_balancer_type, hash_on, hash_on_key, hash_balance_factor, ip_family, health_check, tags, enabled
+		FROM services
+		WHERE workspace = $1
+		ORDER BY name
+	`, topology.Workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query services: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[string]string)
+	index := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var s TopologyService
+		var path, hashOnKey sql.NullString
+		var healthCheck ServiceHealthCheck
+		var tags pq.StringArray
+		if err := rows.Scan(
+			&id, &s.Name, &s.Protocol, &s.Host, &s.Port, &path, &s.ConnectTimeoutMs, &s.ReadTimeoutMs, &s.WriteTimeoutMs,
+			&s.Retries, &s.LoadBalancerType, &s.HashOn, &hashOnKey, &s.HashBalanceFactor, &s.IPFamily, &healthCheck, &tags, &s.Enabled,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan service: %w", err)
+		}
+		s.Path = path.String
+		s.HashOnKey = hashOnKey.String
+		if healthCheck.Configured() {
+			s.HealthCheck = &healthCheck
+		}
+		s.Tags = tags
+		names[id] = s.Name
+		index[id] = len(topology.Services)
+		topology.Services = append(topology.Services, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating services: %w", err)
+	}
+	rows.Close()
+
+	targets, err := tx.QueryContext(ctx, `
+		SELECT t.service_id, t.target, t.weight, t.health_check_path, t.region, t.enabled
+		FROM service_targets t
+		JOIN services s ON s.id = t.service_id
+		WHERE s.workspace = $1
+		ORDER BY t.target
+	`, topology.Workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query service targets: %w", err)
+	}
+	defer targets.Close()
+
+	for targets.Next() {
+		var serviceID string
+		var t TopologyTarget
+		var healthCheckPath sql.NullString
+		if err := targets.Scan(&serviceID, &t.Target, &t.Weight, &healthCheckPath, &t.Region, &t.Enabled); err != nil {
+			return nil, fmt.Errorf("failed to scan service target: %w", err)
+		}
+		t.HealthCheckPath = healthCheckPath.String
+		s := &topology.Services[index[serviceID]]
+		s.Targets = append(s.Targets, t)
+	}
+	if err := targets.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating service targets: %w", err)
+	}
+
+	return names, nil
+}
+
+// exportRouteGroups adds the workspace's route groups, returning group
+// names by ID.
+func exportRouteGroups(ctx context.Context, tx *sql.Tx, topology *Topology, serviceNames map[string]string) (map[string]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, service_id, name, description, base_path, enabled
+		FROM route_groups
+		WHERE workspace = $1
+		ORDER BY name
+	`, topology.Workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query route groups: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[string]string)
+	for rows.Next() {
+		var id, serviceID string
+		var g TopologyRouteGroup
+		var description sql.NullString
+		if err := rows.Scan(&id, &serviceID, &g.Name, &description, &g.BasePath, &g.Enabled); err != nil {
+			return nil, fmt.Errorf("failed to scan route group: %w", err)
+		}
+		g.Service = serviceNames[serviceID]
+		g.Description = description.String
+		names[id] = g.Name
+		topology.RouteGroups = append(topology.RouteGroups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating route groups: %w", err)
+	}
+
+	return names, nil
+}
+
+// exportRoutes adds the workspace's routes, returning route names by ID.
+func exportRoutes(ctx context.Context, tx *sql.Tx, topology *Topology, serviceNames, groupNames map[string]string) (map[string]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, service_id, group_id, name, hosts, paths, methods,
+		       strip_path, preserve_host, priority_class, timeout_ms, slo, tags, enabled
+		FROM routes
+		WHERE workspace = $1
+		ORDER BY name, created_at
+	`, topology.Workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query routes: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[string]string)
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var id, serviceID string
+		var groupID, name sql.NullString
+		var hosts, paths, methods, tags pq.StringArray
+		var slo RouteSLO
+		var rt TopologyRoute
+		if err := rows.Scan(
+			&id, &serviceID, &groupID, &name, &hosts, &paths, &methods,
+			&rt.StripPath, &rt.PreserveHost, &rt.PriorityClass, &rt.TimeoutMs, &slo, &tags, &rt.Enabled,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan route: %w", err)
+		}
+		if !name.Valid || name.String == "" {
+			return nil, fmt.Errorf("route %s has no name; routes are matched by name, so name it before exporting", id)
+		}
+		if seen[name.String] {
+			return nil, fmt.Errorf("route name %q is used more than once in workspace %s", name.String, topology.Workspace)
+		}
+		seen[name.String] = true
+
+		rt.Name = name.String
+		rt.Service = serviceNames[serviceID]
+		if groupID.Valid {
+			rt.Group = groupNames[groupID.String]
+		}
+		rt.Hosts, rt.Paths, rt.Methods, rt.Tags = hosts, paths, methods, tags
+		if slo.Configured() {
+			rt.SLO = &slo
+		}
+		names[id] = rt.Name
+		topology.Routes = append(topology.Routes, rt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating routes: %w", err)
+	}
+
+	return names, nil
+}
+
+// exportPlugins adds the workspace's plugins, except consumer-scoped ones.
+func exportPlugins(ctx context.Context, tx *sql.Tx, topology *Topology, serviceNames, groupNames, routeNames map[string]string) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT name, scope, service_id, group_id, route_id, config, tags, enabled, priority
+		FROM plugins
+		WHERE workspace = $1 AND scope <> 'consumer'
+		ORDER BY priority, name, created_at
+	`, topology.Workspace)
+	if err != nil {
+		return fmt.Errorf("failed to query plugins: %w", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var p TopologyPlugin
+		var serviceID, groupID, routeID sql.NullString
+		var configJSON []byte
+		var tags pq.StringArray
+		if err := rows.Scan(&p.Name, &p.Scope, &serviceID, &groupID, &routeID, &configJSON, &tags, &p.Enabled, &p.Priority); err != nil {
+			return fmt.Errorf("failed to scan plugin: %w", err)
+		}
+		p.Tags = tags
+		if len(configJSON) > 0 {
+			if err := json.Unmarshal(configJSON, &p.Config); err != nil {
+				return fmt.Errorf("failed to unmarshal config of plugin %s: %w", p.Name, err)
+			}
+		}
+		p.Service = serviceNames[serviceID.String]
+		p.Group = groupNames[groupID.String]
+		p.Route = routeNames[routeID.String]
+
+		if seen[p.key()] {
+			return fmt.Errorf("plugin %s is configured more than once on the same %s scope", p.Name, p.Scope)
+		}
+		seen[p.key()] = true
+		topology.Plugins = append(topology.Plugins, p)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating plugins: %w", err)
+	}
+
+	return nil
+}
+
+// ============================================================================
+// Apply
+// ============================================================================
+
+// SetDefaults fills fields left out of a hand-written topology with the
+// schema defaults. Exported topologies carry every field.
+func (t *Topology) SetDefaults() {
+	for i := range t.Services {
+		s := &t.Services[i]
+		if s.Protocol == "" {
+			s.Protocol = "http"
+		}
+		if s.Port == 0 {
+			s.Port = 80
+		}
+		if s.ConnectTimeoutMs == 0 {
+			s.ConnectTimeoutMs = 5000
+		}
+		if s.ReadTimeoutMs == 0 {
+			s.ReadTimeoutMs = 60000
+		}
+		if s.WriteTimeoutMs == 0 {
+			s.WriteTimeoutMs = 60000
+		}
+		if s.LoadBalancerType == "" {
+			s.LoadBalancerType = "round-robin"
+		}
+		if s.HashOn == "" {
+			s.HashOn = "ip"
+		}
+		if s.HashBalanceFactor == 0 {
+			s.HashBalanceFactor = 1.25
+		}
+		if s.IPFamily == "" {
+			s.IPFamily = "default"
+		}
+		for j := range s.Targets {
+			if s.Targets[j].Weight == 0 {
+				s.Targets[j].Weight = 100
+			}
+			if s.Targets[j].HealthCheckPath == "" {
+				s.Targets[j].HealthCheckPath = "/health"
+			}
+		}
+	}
+	for i := range t.Routes {
+		rt := &t.Routes[i]
+		if len(rt.Methods) == 0 {
+			rt.Methods = []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS", "HEAD"}
+		}
+		if rt.PriorityClass == "" {
+			rt.PriorityClass = "normal"
+		}
+	}
+}
+
+// Validate checks that a topology is self-consistent: names are unique
+// and every reference names an entity of the topology.
+func (t *Topology) Validate() error {
+	if t.Version != TopologyVersion {
+		return fmt.Errorf("unsupported topology version %d (expected %d)", t.Version, TopologyVersion)
+	}
+
+	services := make(map[string]bool, len(t.Services))
+	for _, s := range t.Services {
+		if s.Name == "" {
+			return fmt.Errorf("service without a name")
+		}
+		if services[s.Name] {
+			return fmt.Errorf("service %q is defined more than once", s.Name)
+		}
+		if err := ValidateTags(s.Tags); err != nil {
+			return fmt.Errorf("service %q: %w", s.Name, err)
+		}
+		services[s.Name] = true
+	}
+
+	groups := make(map[string]string, len(t.RouteGroups))
+	for _, g := range t.RouteGroups {
+		if g.Name == "" {
+			return fmt.Errorf("route group without a name")
+		}
+		if _, ok := groups[g.Name]; ok {
+			return fmt.Errorf("route group %q is defined more than once", g.Name)
+		}
+		if !services[g.Service] {
+			return fmt.Errorf("route group %q: unknown service %q", g.Name, g.Service)
+		}
+		groups[g.Name] = g.Service
+	}
+
+	routes := make(map[string]bool, len(t.Routes))
+	for _, rt := range t.Routes {
+		if rt.Name == "" {
+			return fmt.Errorf("route without a name")
+		}
+		if routes[rt.Name] {
+			return fmt.Errorf("route %q is defined more than once", rt.Name)
+		}
+		if !services[rt.Service] {
+			return fmt.Errorf("route %q: unknown service %q", rt.Name, rt.Service)
+		}
+		if rt.Group != "" {
+			service, ok := groups[rt.Group]
+			if !ok {
+				return fmt.Errorf("route %q: unknown route group %q", rt.Name, rt.Group)
+			}
+			if service != rt.Service {
+				return fmt.Errorf("route %q: route group %q belongs to service %q", rt.Name, rt.Group, service)
+			}
+		}
+		if len(rt.Paths) == 0 {
+			return fmt.Errorf("route %q: at least one path is required", rt.Name)
+		}
+		if err := ValidateTags(rt.Tags); err != nil {
+			return fmt.Errorf("route %q: %w", rt.Name, err)
+		}
+		routes[rt.Name] = true
+	}
+
+	plugins := make(map[string]bool, len(t.Plugins))
+	for _, p := range t.Plugins {
+		var ok bool
+		switch p.Scope {
+		case PluginScopeGlobal:
+			ok = p.Service == "" && p.Group == "" && p.Route == ""
+		case PluginScopeService:
+			ok = services[p.Service] && p.Group == "" && p.Route == ""
+		case PluginScopeGroup:
+			_, known := groups[p.Group]
+			ok = known && p.Service == "" && p.Route == ""
+		case PluginScopeRoute:
+			ok = routes[p.Route] && p.Service == "" && p.Group == ""
+		default:
+			return fmt.Errorf("plugin %s: scope %q can't be part of a topology", p.Name, p.Scope)
+		}
+		if !ok {
+			return fmt.Errorf("plugin %s: %s scope must name exactly one known %s", p.Name, p.Scope, p.Scope)
+		}
+		if plugins[p.key()] {
+			return fmt.Errorf("plugin %s is configured more than once on the same %s scope", p.Name, p.Scope)
+		}
+		if err := ValidateTags(p.Tags); err != nil {
+			return fmt.Errorf("plugin %s: %w", p.Name, err)
+		}
+		plugins[p.key()] = true
+	}
+
+	return nil
+}
+
+// ApplyTopology creates or updates the entities of a topology, matching
+// existing ones by name, in a single transaction. With prune, services,
+// route groups, routes and plugins of the workspace that aren't in the
+// topology are deleted (consumer-scoped plugins are left alone).
+//
+// Database triggers notify gateways of the changes as they commit.
+func (r *Repository) ApplyTopology(ctx context.Context, topology *Topology, prune bool) (*TopologyApplyResult, error) {
+	topology.SetDefaults()
+	if err := topology.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid topology: %w", err)
+	}
+	workspace := WorkspaceName(topology.Workspace)
+
+	tx, err := r.db.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO workspaces (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, workspace,
+	); err != nil {
+		return nil, fmt.Errorf("failed to create workspace %s: %w", workspace, err)
+	}
+
+	a := &topologyApplier{tx: tx, workspace: workspace, result: &TopologyApplyResult{}}
+	if err := a.applyServices(ctx, topology.Services); err != nil {
+		return nil, err
+	}
+	if err := a.applyRouteGroups(ctx, topology.RouteGroups); err != nil {
+		return nil, err
+	}
+	if err := a.applyRoutes(ctx, topology.Routes); err != nil {
+		return nil, err
+	}
+	if err := a.applyPlugins(ctx, topology.Plugins); err != nil {
+		return nil, err
+	}
+	if prune {
+		if err := a.prune(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit topology: %w", err)
+	}
+
+	log.Info().
+		Str("component", "repository").
+		Str("workspace", workspace).
+		Int("created", a.result.Created).
+		Int("updated", a.result.Updated).
+		Int("deleted", a.result.Deleted).
+		Msg("Applied topology")
+
+	return a.result, nil
+}
+
+// topologyApplier holds the state of one ApplyTopology transaction: the
+// IDs of applied entities by name, used to resolve references and to
+// find what to prune.
+type topologyApplier struct {
+	tx        *sql.Tx
+	workspace string
+	result    *TopologyApplyResult
+
+	serviceIDs map[string]string
+	groupIDs   map[string]string
+	routeIDs   map[string]string
+	pluginIDs  []string
+}
+
+// upserted counts an insert or update reported by a RETURNING (xmax = 0)
+// clause.
+func (a *topologyApplier) upserted(inserted bool) {
+	if inserted {
+		a.result.Created++
+	} else {
+		a.result.Updated++
+	}
+}
+
+func (a *topologyApplier) applyServices(ctx context.Context, services []TopologyService) error {
+	a.serviceIDs = make(map[string]string, len(services))
+	for _, s := range services {
+		var healthCheck []byte
+		if s.HealthCheck != nil && s.HealthCheck.Configured() {
+			var err error
+			if healthCheck, err = json.Marshal(s.HealthCheck); err != nil {
+				return fmt.Errorf("failed to marshal health check of service %s: %w", s.Name, err)
+			}
+		}
+
+		var id string
+		var inserted bool
+		err := a.tx.QueryRowContext(ctx, `
+			INSERT INTO services (workspace, name, protocol, host, port, path, connect_timeout_ms, read_timeout_ms,
+			                      write_timeout_ms, retries, load_balancer_type, hash_on, hash_on_key,
+			                      hash_balance_factor, ip_family, tags, enabled, health_check)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11, $12, NULLIF($13, ''), $14, $15, $16, $17, $18)
+			ON CONFLICT (workspace, name) DO UPDATE SET
+				protocol = EXCLUDED.protocol, host = EXCLUDED.host, port = EXCLUDED.port, path = EXCLUDED.path,
+				connect_timeout_ms = EXCLUDED.connect_timeout_ms, read_timeout_ms = EXCLUDED.read_timeout_ms,
+				write_timeout_ms = EXCLUDED.write_timeout_ms, retries = EXCLUDED.retries,
+				load_balancer_type = EXCLUDED.load_balancer_type, hash_on = EXCLUDED.hash_on,
+				hash_on_key = EXCLUDED.hash_on_key, hash_balance_factor = EXCLUDED.hash_balance_factor,
+				ip_family = EXCLUDED.ip_family, tags = EXCLUDED.tags, enabled = EXCLUDED.enabled,
+				health_check = EXCLUDED.health_check
+			RETURNING id, (xmax = 0)
+		`, a.workspace, s.Name, s.Protocol, s.Host, s.Port, s.Path, s.ConnectTimeoutMs, s.ReadTimeoutMs,
+			s.WriteTimeoutMs, s.Retries, s.LoadBalancerType, s.HashOn, s.HashOnKey,
+			s.HashBalanceFactor, s.IPFamily, tagArray(s.Tags), s.Enabled, healthCheck,
+		).Scan(&id, &inserted)
+		if err != nil {
+			return fmt.Errorf("failed to apply service %s: %w", s.Name, err)
+		}
+		a.upserted(inserted)
+		a.serviceIDs[s.Name] = id
+
+		if err := a.applyTargets(ctx, id, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyTargets replaces a service's targets with the topology's.
+func (a *topologyApplier) applyTargets(ctx context.Context, serviceID string, s TopologyService) error {
+	targets := make(pq.StringArray, 0, len(s.Targets))
+	for _, t := range s.Targets {
+		targets = append(targets, t.Target)
+	}
+	res, err := a.tx.ExecContext(ctx,
+		`DELETE FROM service_targets WHERE service_id = $1 AND NOT (target = ANY($2))`, serviceID, targets,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove targets of service %s: %w", s.Name, err)
+	}
+	deleted, _ := res.RowsAffected()
+	a.result.Deleted += int(deleted)
+
+	for _, t := range s.Targets {
+		var inserted bool
+		err := a.tx.QueryRowContext(ctx, `
+			INSERT INTO service_targets (service_id, target, weight, health_check_path, region, enabled)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (service_id, target) DO UPDATE SET
+				weight = EXCLUDED.weight, health_check_path = EXCLUDED.health_check_path,
+				region = EXCLUDED.region, enabled = EXCLUDED.enabled
+			RETURNING (xmax = 0)
+		`, serviceID, t.Target, t.Weight, t.HealthCheckPath, t.Region, t.Enabled).Scan(&inserted)
+		if err != nil {
+			return fmt.Errorf("failed to apply target %s of service %s: %w", t.Target, s.Name, err)
+		}
+		a.upserted(inserted)
+	}
+	return nil
+}
+
+func (a *topologyApplier) applyRouteGroups(ctx context.Context, groups []TopologyRouteGroup) error {
+	a.groupIDs = make(map[string]string, len(groups))
+	for _, g := range groups {
+		var id string
+		var inserted bool
+		err := a.tx.QueryRowContext(ctx, `
+			INSERT INTO route_groups (workspace, service_id, name, description, base_path, enabled)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+			ON CONFLICT (workspace, name) DO UPDATE SET
+				service_id = EXCLUDED.service_id, description = EXCLUDED.description,
+				base_path = EXCLUDED.base_path, enabled = EXCLUDED.enabled
+			RETURNING id, (xmax = 0)
+		`, a.workspace, a.serviceIDs[g.Service], g.Name, g.Description, g.BasePath, g.Enabled).Scan(&id, &inserted)
+		if err != nil {
+			return fmt.Errorf("failed to apply route group %s: %w", g.Name, err)
+		}
+		a.upserted(inserted)
+		a.groupIDs[g.Name] = id
+	}
+	return nil
+}
+
+func (a *topologyApplier) applyRoutes(ctx context.Context, routes []TopologyRoute) error {
+	// Routes have no unique name constraint, so match them up front
+	existing := make(map[string]string)
+	rows, err := a.tx.QueryContext(ctx,
+		`SELECT id, name FROM routes WHERE workspace = $1 AND name IS NOT NULL FOR UPDATE`, a.workspace,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query routes: %w", err)
+	}
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan route: %w", err)
+		}
+		if _, ok := existing[name]; ok {
+			rows.Close()
+			return fmt.Errorf("route name %q is used more than once in workspace %s", name, a.workspace)
+		}
+		existing[name] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating routes: %w", err)
+	}
+
+	a.routeIDs = make(map[string]string, len(routes))
+	for _, rt := range routes {
+		var groupID sql.NullString
+		if rt.Group != "" {
+			groupID = sql.NullString{String: a.groupIDs[rt.Group], Valid: true}
+		}
+		var slo []byte
+		if rt.SLO != nil && rt.SLO.Configured() {
+			if slo, err = json.Marshal(rt.SLO); err != nil {
+				return fmt.Errorf("failed to marshal slo of route %s: %w", rt.Name, err)
+			}
+		}
+		args := []interface{}{
+			a.serviceIDs[rt.Service], groupID, rt.Name, pq.StringArray(rt.Hosts), pq.StringArray(rt.Paths),
+			pq.StringArray(rt.Methods), rt.StripPath, rt.PreserveHost, rt.PriorityClass, slo, tagArray(rt.Tags), rt.Enabled,
+			rt.TimeoutMs,
+		}
+
+		id, ok := existing[rt.Name]
+		if ok {
+			_, err = a.tx.ExecContext(ctx, `
+				UPDATE routes SET
+					service_id = $1, group_id = $2, name = $3, hosts = $4, paths = $5, methods = $6,
+					strip_path = $7, preserve_host = $8, priority_class = $9, slo = $10, tags = $11, enabled = $12,
+					timeout_ms = $13
+				WHERE id = $14
+			`, append(args, id)...)
+			a.result.Updated++
+		} else {
+			err = a.tx.QueryRowContext(ctx, `
+				INSERT INTO routes (service_id, group_id, name, hosts, paths, methods,
+				                    strip_path, preserve_host, priority_class, slo, tags, enabled, timeout_ms, workspace)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+				RETURNING id
+			`, append(args, a.workspace)...).Scan(&id)
+			a.result.Created++
+		}
+		if err != nil {
+			return fmt.Errorf("failed to apply route %s: %w", rt.Name, err)
+		}
+		a.routeIDs[rt.Name] = id
+	}
+	return nil
+}
+
+func (a *topologyApplier) applyPlugins(ctx context.Context, plugins []TopologyPlugin) error {
+	// Plugin instances are identified by name and scope target
+	existing := make(map[string]string)
+	rows, err := a.tx.QueryContext(ctx, `
+		SELECT id, name, scope, service_id, group_id, route_id
+		FROM plugins
+		WHERE workspace = $1 AND scope <> 'consumer'
+		ORDER BY created_at
+		FOR UPDATE
+	`, a.workspace)
+	if err != nil {
+		return fmt.Errorf("failed to query plugins: %w", err)
+	}
+	for rows.Next() {
+		var id string
+		var key TopologyPlugin
+		var serviceID, groupID, routeID sql.NullString
+		if err := rows.Scan(&id, &key.Name, &key.Scope, &serviceID, &groupID, &routeID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan plugin: %w", err)
+		}
+		key.Service, key.Group, key.Route = serviceID.String, groupID.String, routeID.String
+		if _, ok := existing[key.key()]; !ok {
+			existing[key.key()] = id
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating plugins: %w", err)
+	}
+
+	for _, p := range plugins {
+		// Resolve names to IDs of this environment
+		ref := TopologyPlugin{
+			Name:    p.Name,
+			Scope:   p.Scope,
+			Service: a.serviceIDs[p.Service],
+			Group:   a.groupIDs[p.Group],
+			Route:   a.routeIDs[p.Route],
+		}
+		config := p.Config
+		if config == nil {
+			config = map[string]interface{}{}
+		}
+		configJSON, err := json.Marshal(config)
+		if err != nil {
+			return fmt.Errorf("failed to marshal config of plugin %s: %w", p.Name, err)
+		}
+
+		id, ok := existing[ref.key()]
+		if ok {
+			_, err = a.tx.ExecContext(ctx,
+				`UPDATE plugins SET config = $1, tags = $2, enabled = $3, priority = $4 WHERE id = $5`,
+				configJSON, tagArray(p.Tags), p.Enabled, p.Priority, id,
+			)
+			a.result.Updated++
+		} else {
+			err = a.tx.QueryRowContext(ctx, `
+				INSERT INTO plugins (workspace, name, scope, service_id, group_id, route_id, config, tags, enabled, priority)
+				VALUES ($1, $2, $3, NULLIF($4, '')::uuid, NULLIF($5, '')::uuid, NULLIF($6, '')::uuid, $7, $8, $9, $10)
+				RETURNING id
+			`, a.workspace, p.Name, p.Scope, ref.Service, ref.Group, ref.Route, configJSON, tagArray(p.Tags), p.Enabled, p.Priority).Scan(&id)
+			a.result.Created++
+		}
+		if err != nil {
+			return fmt.Errorf("failed to apply %s plugin %s: %w", p.Scope, p.Name, err)
+		}
+		a.pluginIDs = append(a.pluginIDs, id)
+	}
+	return nil
+}
+
+// prune deletes the workspace's entities that weren't applied. Children
+// go first so cascades don't hide them from the deleted count.
+func (a *topologyApplier) prune(ctx context.Context) error {
+	steps := []struct {
+		entity string
+		query  string
+		keep   []string
+	}{
+		{"plugins", `DELETE FROM plugins WHERE workspace = $1 AND scope <> 'consumer' AND NOT (id = ANY($2::uuid[]))`, a.pluginIDs},
+		{"routes", `DELETE FROM routes WHERE workspace = $1 AND NOT (id = ANY($2::uuid[]))`, sortedValues(a.routeIDs)},
+		{"route groups", `DELETE FROM route_groups WHERE workspace = $1 AND NOT (id = ANY($2::uuid[]))`, sortedValues(a.groupIDs)},
+		{"services", `DELETE FROM services WHERE workspace = $1 AND NOT (id = ANY($2::uuid[]))`, sortedValues(a.serviceIDs)},
+	}
+	for _, step := range steps {
+		res, err := a.tx.ExecContext(ctx, step.query, a.workspace, pq.StringArray(step.keep))
+		if err != nil {
+			return fmt.Errorf("failed to prune %s: %w", step.entity, err)
+		}
+		deleted, _ := res.RowsAffected()
+		a.result.Deleted += int(deleted)
+	}
+	return nil
+}
+
+// sortedValues returns the values of a map, sorted.
+func sortedValues(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
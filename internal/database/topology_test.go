@@ -0,0 +1,76 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTopology_Validate(t *testing.T) {
+	valid := func() *Topology {
+		return &Topology{
+			Version:     TopologyVersion,
+			Services:    []TopologyService{{Name: "orders"}, {Name: "users"}},
+			RouteGroups: []TopologyRouteGroup{{Name: "orders-v2", Service: "orders", BasePath: "/v2"}},
+			Routes: []TopologyRoute{
+				{Name: "list", Service: "orders", Group: "orders-v2", Paths: []string{"/orders"}},
+				{Name: "me", Service: "users", Paths: []string{"/me"}},
+			},
+			Plugins: []TopologyPlugin{
+				{Name: "cors", Scope: PluginScopeGlobal},
+				{Name: "rate-limit", Scope: PluginScopeGroup, Group: "orders-v2"},
+				{Name: "rate-limit", Scope: PluginScopeRoute, Route: "me"},
+			},
+		}
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("expected a valid topology, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*Topology)
+		want   string
+	}{
+		{"version", func(tp *Topology) { tp.Version = 2 }, "unsupported topology version"},
+		{"duplicate service", func(tp *Topology) { tp.Services[1].Name = "orders" }, `service "orders" is defined more than once`},
+		{"unknown route service", func(tp *Topology) { tp.Routes[1].Service = "billing" }, `unknown service "billing"`},
+		{"group of another service", func(tp *Topology) { tp.Routes[1].Group = "orders-v2" }, `belongs to service "orders"`},
+		{"unnamed route", func(tp *Topology) { tp.Routes[0].Name = "" }, "route without a name"},
+		{"duplicate route", func(tp *Topology) { tp.Routes[1].Name = "list" }, `route "list" is defined more than once`},
+		{"no paths", func(tp *Topology) { tp.Routes[1].Paths = nil }, "at least one path"},
+		{"consumer plugin", func(tp *Topology) { tp.Plugins[0].Scope = PluginScopeConsumer }, "can't be part of a topology"},
+		{"unknown plugin route", func(tp *Topology) { tp.Plugins[2].Route = "missing" }, "route scope must name"},
+		{"global plugin with target", func(tp *Topology) { tp.Plugins[0].Service = "orders" }, "global scope must name"},
+		{"duplicate plugin", func(tp *Topology) { tp.Plugins[2] = tp.Plugins[1] }, "more than once"},
+		{"invalid route tag", func(tp *Topology) { tp.Routes[0].Tags = []string{"Team Payments"} }, `route "list": invalid tag`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topology := valid()
+			tt.mutate(topology)
+			err := topology.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopology_SetDefaults(t *testing.T) {
+	topology := &Topology{
+		Services: []TopologyService{{Name: "orders", Host: "orders", Targets: []TopologyTarget{{Target: "10.0.0.1:80"}}}},
+		Routes:   []TopologyRoute{{Name: "list", Service: "orders", Paths: []string{"/orders"}}},
+	}
+	topology.SetDefaults()
+
+	s := topology.Services[0]
+	if s.Protocol != "http" || s.Port != 80 || s.ConnectTimeoutMs != 5000 || s.LoadBalancerType != "round-robin" || s.HashBalanceFactor != 1.25 {
+		t.Errorf("unexpected service defaults: %+v", s)
+	}
+	if s.Targets[0].Weight != 100 || s.Targets[0].HealthCheckPath != "/health" {
+		t.Errorf("unexpected target defaults: %+v", s.Targets[0])
+	}
+	if rt := topology.Routes[0]; len(rt.Methods) != 7 || rt.PriorityClass != "normal" {
+		t.Errorf("unexpected route defaults: %+v", rt)
+	}
+}
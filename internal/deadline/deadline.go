@@ -0,0 +1,119 @@
+// Package deadline gives every proxied request a single deadline.
+//
+// The deadline is set when the request is matched to a route: the route's
+// timeout_ms or, if it has none, its service's read_timeout_ms, counted
+// from the match. It is carried by the request context (Attach), so the
+// admission queue, the plugin chain (ctx.Context()) and the upstream call
+// all run against the same clock instead of timeouts of their own.
+//
+// Whichever stage runs out of time, the client gets the same response
+// (WriteTimeout):
+//
+//	504 {"error":"gateway timeout","message":"Request exceeded its deadline"}
+//
+// and the request is counted in gateway_request_deadline_exceeded_total
+// by route and stage (admission, plugins, upstream).
+//
+// The listener's READ_TIMEOUT and WRITE_TIMEOUT still bound requests that
+// don't match a route; for matched requests Attach moves the connection's
+// deadlines to the request deadline plus Grace, so they neither cut a
+// longer route short nor drop the connection before the 504 is written.
+package deadline
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// Stages a request can run out of time in.
+const (
+	StageAdmission = "admission"
+	StagePlugins   = "plugins"
+	StageUpstream  = "upstream"
+)
+
+// Grace is how long past the request deadline the connection stays
+// writable, to send the 504.
+const Grace = time.Second
+
+// Body is the body of the 504 response.
+const Body = `{"error":"gateway timeout","message":"Request exceeded its deadline"}`
+
+// ErrExceeded is the cause of a request context whose deadline passed.
+var ErrExceeded = errors.New("request deadline exceeded")
+
+var exceededTotal = metrics.NewCounterVec(
+	"gateway_request_deadline_exceeded_total",
+	"Requests answered 504 because their deadline passed, by route and stage (admission, plugins, upstream).",
+	"route", "stage",
+)
+
+// Timeout returns the total time allowed for a request to route: its own
+// TimeoutMs, else the service's ReadTimeoutMs. 0 means no deadline.
+func Timeout(route *database.Route, service *database.Service) time.Duration {
+	if route.TimeoutMs > 0 {
+		return time.Duration(route.TimeoutMs) * time.Millisecond
+	}
+	if service.ReadTimeoutMs > 0 {
+		return time.Duration(service.ReadTimeoutMs) * time.Millisecond
+	}
+	return 0
+}
+
+// Attach returns r with its context bounded by timeout, and extends the
+// connection's read and write deadlines to match (see the package
+// documentation). The cancel function must be called once the request is
+// done. A timeout <= 0 leaves the request as it is.
+func Attach(w http.ResponseWriter, r *http.Request, timeout time.Duration) (*http.Request, context.CancelFunc) {
+	if timeout <= 0 {
+		return r, func() {}
+	}
+
+	ctx, cancel := context.WithTimeoutCause(r.Context(), timeout, ErrExceeded)
+	connDeadline := time.Now().Add(timeout + Grace)
+
+	// Not every ResponseWriter supports this (e.g. in tests); the
+	// listener's timeouts then stay in effect
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(connDeadline)
+	_ = rc.SetWriteDeadline(connDeadline)
+
+	return r.WithContext(ctx), cancel
+}
+
+// Exceeded reports whether ctx ended because its request deadline passed.
+func Exceeded(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrExceeded)
+}
+
+// IsTimeout reports whether err is a timeout: a context deadline or a
+// network timeout (e.g. connecting to the upstream).
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// WriteTimeout answers a request that ran out of time in stage with a
+// 504, and counts it.
+func WriteTimeout(w http.ResponseWriter, routeID, stage string) {
+	Record(routeID, stage)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGatewayTimeout)
+	w.Write([]byte(Body))
+}
+
+// Record counts a request of routeID that ran out of time in stage, for
+// callers that write the 504 themselves.
+func Record(routeID, stage string) {
+	exceededTotal.Inc(routeID, stage)
+}
@@ -0,0 +1,81 @@
+package deadline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+func TestTimeout(t *testing.T) {
+	service := &database.Service{ReadTimeoutMs: 60000}
+
+	tests := []struct {
+		name    string
+		route   *database.Route
+		service *database.Service
+		want    time.Duration
+	}{
+		{name: "service read timeout", route: &database.Route{}, service: service, want: time.Minute},
+		{name: "route override", route: &database.Route{TimeoutMs: 1500}, service: service, want: 1500 * time.Millisecond},
+		{name: "none", route: &database.Route{}, service: &database.Service{}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Timeout(tt.route, tt.service); got != tt.want {
+				t.Errorf("Timeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttach(t *testing.T) {
+	r, cancel := Attach(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), 10*time.Millisecond)
+	defer cancel()
+
+	<-r.Context().Done()
+	if !Exceeded(r.Context()) {
+		t.Error("Exceeded() = false after the deadline passed")
+	}
+
+	// Canceled requests (client gone) aren't timeouts
+	ctx, stop := context.WithCancel(context.Background())
+	stop()
+	if Exceeded(ctx) {
+		t.Error("Exceeded() = true for a canceled context")
+	}
+
+	// No timeout, no deadline
+	r, cancel = Attach(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), 0)
+	defer cancel()
+	if _, ok := r.Context().Deadline(); ok {
+		t.Error("Attach() with no timeout set a deadline")
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	if !IsTimeout(fmt.Errorf("upstream request failed: %w", context.DeadlineExceeded)) {
+		t.Error("IsTimeout(context.DeadlineExceeded) = false")
+	}
+	if IsTimeout(errors.New("connection refused")) {
+		t.Error("IsTimeout(connection refused) = true")
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteTimeout(w, "r-write", StageUpstream)
+
+	if w.Code != http.StatusGatewayTimeout || w.Body.String() != Body {
+		t.Errorf("response = %d %q, want 504 %q", w.Code, w.Body.String(), Body)
+	}
+	if got := exceededTotal.Value("r-write", StageUpstream); got != 1 {
+		t.Errorf("exceeded count = %v, want 1", got)
+	}
+}
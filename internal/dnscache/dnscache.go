@@ -0,0 +1,345 @@
+// Package dnscache caches upstream host name lookups for the proxy's
+// dialer, so high-QPS upstreams don't pay for a DNS round trip on every
+// new connection.
+//
+// Lookups go to the nameservers in resolv.conf (honoring search domains
+// and ndots), so the records' TTLs are known; entries are kept for the
+// TTL, bounded by MinTTL and MaxTTL. Names in the hosts file are answered
+// from it. If the nameservers can't be reached the system resolver is
+// used and its answer kept for MinTTL.
+//
+//	hit           cached addresses, no lookup
+//	miss          looked up; concurrent misses for a host share one lookup
+//	negative_hit  cached "no such host", kept for NegativeTTL
+//	stale         the refresh failed, the expired addresses are used for
+//	              another MinTTL
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+var (
+	cacheRequests = metrics.NewCounterVec(
+		"gateway_dns_cache_requests_total",
+		"Upstream host lookups through the DNS cache, by result (hit, miss, negative_hit, stale).",
+		"result",
+	)
+	lookupDuration = metrics.NewHistogramVec(
+		"gateway_dns_lookup_seconds",
+		"DNS lookups made on cache misses, by source (dns, system).",
+		nil,
+		"source",
+	)
+	lookupErrors = metrics.NewCounterVec(
+		"gateway_dns_lookup_errors_total",
+		"Failed DNS lookups on cache misses, by kind (not_found, error).",
+		"kind",
+	)
+)
+
+// maxEntries bounds the cache; expired entries are dropped past it.
+const maxEntries = 10000
+
+// Config configures a caching resolver.
+type Config struct {
+	// MinTTL and MaxTTL bound how long an answer is cached, whatever its
+	// records' TTL
+	MinTTL time.Duration
+	MaxTTL time.Duration
+
+	// NegativeTTL is how long "no such host" is cached
+	NegativeTTL time.Duration
+
+	// Timeout bounds one lookup
+	Timeout time.Duration
+
+	// ResolvConf and HostsFile are read once, when the resolver is created
+	ResolvConf string
+	HostsFile  string
+}
+
+// DefaultConfig returns the configuration used unless overridden.
+func DefaultConfig() Config {
+	return Config{
+		MinTTL:      5 * time.Second,
+		MaxTTL:      5 * time.Minute,
+		NegativeTTL: 5 * time.Second,
+		Timeout:     5 * time.Second,
+		ResolvConf:  "/etc/resolv.conf",
+		HostsFile:   "/etc/hosts",
+	}
+}
+
+// Resolver is a caching resolver for upstream dialing.
+type Resolver struct {
+	cfg    Config
+	conf   resolvConf
+	hosts  map[string][]net.IP
+	system *net.Resolver
+	now    func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]*entry
+	inflight map[string]*lookup
+}
+
+// entry is a cached answer: addresses, or the error for a missing host.
+type entry struct {
+	ips     []net.IP
+	err     error
+	expires time.Time
+}
+
+// lookup is a lookup in progress; ips and err are set before done closes.
+type lookup struct {
+	done chan struct{}
+	ips  []net.IP
+	err  error
+}
+
+// New creates a caching resolver.
+func New(cfg Config) (*Resolver, error) {
+	conf, err := readResolvConf(cfg.ResolvConf)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := readHosts(cfg.HostsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("component", "dnscache").
+		Strs("nameservers", conf.nameservers).
+		Strs("search", conf.search).
+		Dur("min_ttl", cfg.MinTTL).
+		Dur("max_ttl", cfg.MaxTTL).
+		Msg("DNS cache initialized")
+
+	return &Resolver{
+		cfg:      cfg,
+		conf:     conf,
+		hosts:    hosts,
+		system:   net.DefaultResolver,
+		now:      time.Now,
+		entries:  make(map[string]*entry),
+		inflight: make(map[string]*lookup),
+	}, nil
+}
+
+// LookupIP returns host's addresses, from the cache if they haven't
+// expired.
+func (r *Resolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	r.mu.Lock()
+	if e, ok := r.entries[host]; ok && r.now().Before(e.expires) {
+		r.mu.Unlock()
+		if e.err != nil {
+			cacheRequests.Inc("negative_hit")
+			return nil, e.err
+		}
+		cacheRequests.Inc("hit")
+		return e.ips, nil
+	}
+	l, running := r.inflight[host]
+	if !running {
+		l = &lookup{done: make(chan struct{})}
+		r.inflight[host] = l
+		go r.refresh(host, l, r.entries[host])
+	}
+	r.mu.Unlock()
+	cacheRequests.Inc("miss")
+
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	select {
+	case <-l.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if trace != nil && trace.DNSDone != nil {
+		addrs := make([]net.IPAddr, len(l.ips))
+		for i, ip := range l.ips {
+			addrs[i] = net.IPAddr{IP: ip}
+		}
+		trace.DNSDone(httptrace.DNSDoneInfo{Addrs: addrs, Err: l.err})
+	}
+	return l.ips, l.err
+}
+
+// refresh looks host up and caches the answer. It runs detached from the
+// callers, so one caller giving up doesn't fail the others.
+func (r *Resolver) refresh(host string, l *lookup, stale *entry) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.cfg.Timeout)
+	defer cancel()
+
+	ips, ttl, err := r.resolve(ctx, host)
+	now := r.now()
+
+	var e *entry
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		e = &entry{ips: ips, expires: now.Add(min(max(ttl, r.cfg.MinTTL), r.cfg.MaxTTL))}
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		lookupErrors.Inc("not_found")
+		e = &entry{err: err, expires: now.Add(r.cfg.NegativeTTL)}
+	default:
+		lookupErrors.Inc("error")
+		if stale != nil && stale.err == nil {
+			cacheRequests.Inc("stale")
+			log.Warn().
+				Err(err).
+				Str("component", "dnscache").
+				Str("host", host).
+				Msg("DNS lookup failed; using expired addresses")
+			ips, err = stale.ips, nil
+			e = &entry{ips: ips, expires: now.Add(r.cfg.MinTTL)}
+		}
+	}
+
+	r.mu.Lock()
+	if e != nil {
+		r.entries[host] = e
+		if len(r.entries) > maxEntries {
+			r.prune(now)
+		}
+	}
+	delete(r.inflight, host)
+	r.mu.Unlock()
+
+	l.ips, l.err = ips, err
+	close(l.done)
+}
+
+// prune drops expired entries, then arbitrary ones if still over
+// maxEntries. Called with mu held.
+func (r *Resolver) prune(now time.Time) {
+	for host, e := range r.entries {
+		if !now.Before(e.expires) {
+			delete(r.entries, host)
+		}
+	}
+	for host := range r.entries {
+		if len(r.entries) <= maxEntries {
+			break
+		}
+		delete(r.entries, host)
+	}
+}
+
+// resolve looks host up in the hosts file, then the nameservers, then the
+// system resolver, returning the addresses and how long they may be
+// cached.
+func (r *Resolver) resolve(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	if ips, ok := r.hosts[host]; ok {
+		return ips, r.cfg.MaxTTL, nil
+	}
+
+	if len(r.conf.nameservers) > 0 {
+		start := time.Now()
+		ips, ttl, err := r.resolveDNS(ctx, host)
+		if err == nil || errors.Is(err, errNXDomain) {
+			lookupDuration.Observe(time.Since(start).Seconds(), "dns")
+			if err != nil {
+				return nil, 0, &net.DNSError{Err: err.Error(), Name: host, IsNotFound: true}
+			}
+			return ips, ttl, nil
+		}
+		log.Debug().
+			Err(err).
+			Str("component", "dnscache").
+			Str("host", host).
+			Msg("Nameserver lookup failed; using the system resolver")
+	}
+
+	start := time.Now()
+	addrs, err := r.system.LookupIPAddr(ctx, host)
+	lookupDuration.Observe(time.Since(start).Seconds(), "system")
+	if err != nil {
+		return nil, 0, err
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+	return ips, r.cfg.MinTTL, nil
+}
+
+// resolveDNS queries the nameservers for each search candidate of host,
+// returning errNXDomain if none exists, or the first transport error if a
+// candidate couldn't be answered.
+func (r *Resolver) resolveDNS(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	var failed error
+	for _, name := range r.conf.candidates(host) {
+		ips, ttl, err := r.query(ctx, name)
+		if err == nil && len(ips) > 0 {
+			return ips, ttl, nil
+		}
+		if err != nil && !errors.Is(err, errNXDomain) && failed == nil {
+			failed = err
+		}
+	}
+	if failed != nil {
+		return nil, 0, failed
+	}
+	return nil, 0, errNXDomain
+}
+
+// query asks for name's A and AAAA records in parallel, trying each
+// nameserver in turn. IPv4 addresses come first.
+func (r *Resolver) query(ctx context.Context, name string) ([]net.IP, time.Duration, error) {
+	type answer struct {
+		ips []net.IP
+		ttl time.Duration
+		err error
+	}
+	ask := func(qtype uint16, out chan<- answer) {
+		var a answer
+		for _, server := range r.conf.nameservers {
+			a.ips, a.ttl, a.err = exchange(ctx, server, name, qtype)
+			if a.err == nil || errors.Is(a.err, errNXDomain) {
+				break
+			}
+		}
+		out <- a
+	}
+
+	v4, v6 := make(chan answer, 1), make(chan answer, 1)
+	go ask(typeA, v4)
+	go ask(typeAAAA, v6)
+	a, aaaa := <-v4, <-v6
+
+	if a.err != nil && aaaa.err != nil {
+		return nil, 0, a.err
+	}
+	var ips []net.IP
+	var ttl time.Duration
+	for _, ans := range []answer{a, aaaa} {
+		if ans.err != nil || len(ans.ips) == 0 {
+			continue
+		}
+		if len(ips) == 0 || ans.ttl < ttl {
+			ttl = ans.ttl
+		}
+		ips = append(ips, ans.ips...)
+	}
+	return ips, ttl, nil
+}
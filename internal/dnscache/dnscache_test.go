@@ -0,0 +1,200 @@
+package dnscache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeDNS answers A queries from records and NXDOMAIN for anything else.
+type fakeDNS struct {
+	conn    net.PacketConn
+	records map[string]net.IP // fully qualified name -> IPv4
+	ttl     uint32
+	queries atomic.Int32
+	mu      sync.Mutex
+	fail    bool // drop queries
+}
+
+func newFakeDNS(t *testing.T, records map[string]net.IP, ttl uint32) *fakeDNS {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeDNS{conn: conn, records: records, ttl: ttl}
+	t.Cleanup(func() { conn.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeDNS) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := f.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		f.queries.Add(1)
+		f.mu.Lock()
+		fail := f.fail
+		f.mu.Unlock()
+		if fail {
+			continue
+		}
+		f.conn.WriteTo(f.answer(buf[:n]), addr)
+	}
+}
+
+func (f *fakeDNS) setFail(fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fail = fail
+}
+
+func (f *fakeDNS) answer(query []byte) []byte {
+	// Decode the question name
+	var labels []string
+	off := 12
+	for query[off] != 0 {
+		l := int(query[off])
+		labels = append(labels, string(query[off+1:off+1+l]))
+		off += l + 1
+	}
+	question := query[12 : off+5]
+	qtype := binary.BigEndian.Uint16(query[off+1:])
+	name := strings.Join(labels, ".") + "."
+
+	resp := append([]byte(nil), query[:12]...)
+	binary.BigEndian.PutUint16(resp[6:], 0) // answers
+	ip, ok := f.records[name]
+	switch {
+	case !ok:
+		binary.BigEndian.PutUint16(resp[2:], 0x8183) // NXDOMAIN
+		return append(resp, question...)
+	case qtype != typeA:
+		binary.BigEndian.PutUint16(resp[2:], 0x8180) // no data
+		return append(resp, question...)
+	}
+	binary.BigEndian.PutUint16(resp[2:], 0x8180)
+	binary.BigEndian.PutUint16(resp[6:], 1)
+	resp = append(resp, question...)
+	resp = append(resp, 0xc0, 12) // pointer to the question name
+	resp = binary.BigEndian.AppendUint16(resp, typeA)
+	resp = binary.BigEndian.AppendUint16(resp, 1)
+	resp = binary.BigEndian.AppendUint32(resp, f.ttl)
+	resp = binary.BigEndian.AppendUint16(resp, 4)
+	return append(resp, ip.To4()...)
+}
+
+func newTestResolver(t *testing.T, dns *fakeDNS, cfg Config) *Resolver {
+	dir := t.TempDir()
+	cfg.ResolvConf = filepath.Join(dir, "resolv.conf")
+	cfg.HostsFile = filepath.Join(dir, "hosts")
+	os.WriteFile(cfg.ResolvConf, []byte("search svc.test\noptions ndots:2\n"), 0o644)
+	os.WriteFile(cfg.HostsFile, []byte("192.0.2.10 pinned.test # comment\n"), 0o644)
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second
+	}
+
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	r.conf.nameservers = []string{dns.conn.LocalAddr().String()}
+	return r
+}
+
+func TestResolver_CachesForTTL(t *testing.T) {
+	dns := newFakeDNS(t, map[string]net.IP{"api.example.test.": net.ParseIP("192.0.2.1")}, 30)
+	r := newTestResolver(t, dns, Config{MinTTL: time.Second, MaxTTL: time.Minute, NegativeTTL: time.Second})
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ips, err := r.LookupIP(context.Background(), "api.example.test")
+		if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+			t.Fatalf("LookupIP() = %v, %v", ips, err)
+		}
+	}
+	if got := dns.queries.Load(); got != 2 { // one A, one AAAA
+		t.Errorf("queries = %d, want 2 (answers cached)", got)
+	}
+
+	// Expired after the record's 30s TTL
+	now = now.Add(31 * time.Second)
+	r.LookupIP(context.Background(), "api.example.test")
+	if got := dns.queries.Load(); got != 4 {
+		t.Errorf("queries after TTL = %d, want 4", got)
+	}
+}
+
+func TestResolver_TTLBounds(t *testing.T) {
+	dns := newFakeDNS(t, map[string]net.IP{"api.example.test.": net.ParseIP("192.0.2.1")}, 3600)
+	r := newTestResolver(t, dns, Config{MinTTL: time.Second, MaxTTL: 10 * time.Second, NegativeTTL: time.Second})
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	r.LookupIP(context.Background(), "api.example.test")
+	now = now.Add(11 * time.Second)
+	r.LookupIP(context.Background(), "api.example.test")
+	if got := dns.queries.Load(); got != 4 {
+		t.Errorf("queries = %d, want 4 (TTL capped at MaxTTL)", got)
+	}
+}
+
+func TestResolver_NegativeCaching(t *testing.T) {
+	dns := newFakeDNS(t, nil, 30)
+	r := newTestResolver(t, dns, Config{MinTTL: time.Second, MaxTTL: time.Minute, NegativeTTL: 5 * time.Second})
+
+	for i := 0; i < 2; i++ {
+		_, err := r.LookupIP(context.Background(), "missing.example")
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			t.Fatalf("LookupIP() error = %v, want not found", err)
+		}
+	}
+	// Both candidates (search domain, then as given), A and AAAA, once
+	if got := dns.queries.Load(); got != 4 {
+		t.Errorf("queries = %d, want 4 (not found cached)", got)
+	}
+}
+
+func TestResolver_SearchDomainsAndHosts(t *testing.T) {
+	dns := newFakeDNS(t, map[string]net.IP{"orders.svc.test.": net.ParseIP("192.0.2.2")}, 30)
+	r := newTestResolver(t, dns, Config{MinTTL: time.Second, MaxTTL: time.Minute, NegativeTTL: time.Second})
+
+	ips, err := r.LookupIP(context.Background(), "orders")
+	if err != nil || !ips[0].Equal(net.ParseIP("192.0.2.2")) {
+		t.Errorf("LookupIP(orders) = %v, %v, want the search domain's address", ips, err)
+	}
+	ips, err = r.LookupIP(context.Background(), "pinned.test")
+	if err != nil || !ips[0].Equal(net.ParseIP("192.0.2.10")) {
+		t.Errorf("LookupIP(pinned.test) = %v, %v, want the hosts file address", ips, err)
+	}
+}
+
+func TestResolver_ServesStaleOnFailure(t *testing.T) {
+	dns := newFakeDNS(t, map[string]net.IP{"api.example.test.": net.ParseIP("192.0.2.1")}, 1)
+	r := newTestResolver(t, dns, Config{MinTTL: time.Second, MaxTTL: time.Minute, NegativeTTL: time.Second, Timeout: 100 * time.Millisecond})
+	r.system = &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return nil, errors.New("no system resolver in tests")
+	}}
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	r.LookupIP(context.Background(), "api.example.test")
+	dns.setFail(true)
+	now = now.Add(2 * time.Second)
+	ips, err := r.LookupIP(context.Background(), "api.example.test")
+	if err != nil || len(ips) != 1 {
+		t.Errorf("LookupIP() with nameserver down = %v, %v, want the expired addresses", ips, err)
+	}
+}
@@ -0,0 +1,279 @@
+package dnscache
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DNS record types and response codes used here.
+const (
+	typeA     = 1
+	typeCNAME = 5
+	typeAAAA  = 28
+
+	rcodeSuccess  = 0
+	rcodeNXDomain = 3
+)
+
+// errNXDomain means the name doesn't exist.
+var errNXDomain = errors.New("no such host")
+
+// resolvConf is the part of resolv.conf the cache uses.
+type resolvConf struct {
+	nameservers []string // host:port
+	search      []string // without trailing dots
+	ndots       int
+}
+
+// readResolvConf parses a resolv.conf file. A missing file gives an empty
+// configuration, so lookups fall back to the system resolver.
+func readResolvConf(path string) (resolvConf, error) {
+	conf := resolvConf{ndots: 1}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return conf, nil
+	}
+	if err != nil {
+		return conf, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			if net.ParseIP(fields[1]) != nil {
+				conf.nameservers = append(conf.nameservers, net.JoinHostPort(fields[1], "53"))
+			}
+		case "search", "domain":
+			conf.search = conf.search[:0]
+			for _, domain := range fields[1:] {
+				conf.search = append(conf.search, strings.TrimSuffix(domain, "."))
+			}
+		case "options":
+			for _, option := range fields[1:] {
+				if value, ok := strings.CutPrefix(option, "ndots:"); ok {
+					if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+						conf.ndots = min(n, 15)
+					}
+				}
+			}
+		}
+	}
+	return conf, scanner.Err()
+}
+
+// candidates returns the names to query for host, in order, applying the
+// search domains like the system resolver: names with at least ndots dots
+// are tried as given first, others last.
+func (c resolvConf) candidates(host string) []string {
+	if strings.HasSuffix(host, ".") {
+		return []string{host}
+	}
+	searched := make([]string, 0, len(c.search))
+	for _, domain := range c.search {
+		searched = append(searched, host+"."+domain+".")
+	}
+	if strings.Count(host, ".") >= c.ndots {
+		return append([]string{host + "."}, searched...)
+	}
+	return append(searched, host+".")
+}
+
+// readHosts parses a hosts file into name -> addresses. A missing file is
+// empty.
+func readHosts(path string) (map[string][]net.IP, error) {
+	hosts := make(map[string][]net.IP)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return hosts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			continue
+		}
+		for _, name := range fields[1:] {
+			name = strings.ToLower(strings.TrimSuffix(name, "."))
+			hosts[name] = append(hosts[name], ip)
+		}
+	}
+	return hosts, scanner.Err()
+}
+
+// exchange sends one question for name (fully qualified) to server over
+// UDP and returns the A or AAAA addresses in the answer and the smallest
+// TTL of the records leading to them.
+func exchange(ctx context.Context, server, name string, qtype uint16) ([]net.IP, time.Duration, error) {
+	query, id, err := buildQuery(name, qtype)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, 0, err
+	}
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, 0, err
+		}
+		ips, ttl, err := parseResponse(buf[:n], id)
+		if errors.Is(err, errMismatchedID) {
+			continue // a late answer to an earlier query
+		}
+		return ips, ttl, err
+	}
+}
+
+// buildQuery encodes a recursive query for name.
+func buildQuery(name string, qtype uint16) ([]byte, uint16, error) {
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return nil, 0, err
+	}
+	id := binary.BigEndian.Uint16(idBytes[:])
+
+	msg := make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], 0x0100) // recursion desired
+	binary.BigEndian.PutUint16(msg[4:], 1)      // one question
+
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return nil, 0, fmt.Errorf("invalid host name %q", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, 1) // class IN
+	return msg, id, nil
+}
+
+var (
+	errMismatchedID = errors.New("dns response for another query")
+	errMalformed    = errors.New("malformed dns response")
+	errTruncated    = errors.New("truncated dns response")
+)
+
+// parseResponse decodes a response to the query with the given ID.
+func parseResponse(msg []byte, id uint16) ([]net.IP, time.Duration, error) {
+	if len(msg) < 12 {
+		return nil, 0, errMalformed
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != id {
+		return nil, 0, errMismatchedID
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if flags&0x8000 == 0 {
+		return nil, 0, errMalformed
+	}
+	if flags&0x0200 != 0 {
+		return nil, 0, errTruncated
+	}
+	switch rcode := flags & 0x000f; rcode {
+	case rcodeSuccess:
+	case rcodeNXDomain:
+		return nil, 0, errNXDomain
+	default:
+		return nil, 0, fmt.Errorf("dns server returned rcode %d", rcode)
+	}
+
+	questions := binary.BigEndian.Uint16(msg[4:])
+	answers := binary.BigEndian.Uint16(msg[6:])
+	off := 12
+	for i := 0; i < int(questions); i++ {
+		var ok bool
+		if off, ok = skipName(msg, off); !ok || off+4 > len(msg) {
+			return nil, 0, errMalformed
+		}
+		off += 4
+	}
+
+	var ips []net.IP
+	var ttl uint32
+	seen := false
+	for i := 0; i < int(answers); i++ {
+		var ok bool
+		if off, ok = skipName(msg, off); !ok || off+10 > len(msg) {
+			return nil, 0, errMalformed
+		}
+		rtype := binary.BigEndian.Uint16(msg[off:])
+		rttl := binary.BigEndian.Uint32(msg[off+4:])
+		length := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+length > len(msg) {
+			return nil, 0, errMalformed
+		}
+		data := msg[off : off+length]
+		off += length
+
+		switch {
+		case rtype == typeA && length == net.IPv4len,
+			rtype == typeAAAA && length == net.IPv6len:
+			ips = append(ips, net.IP(append([]byte(nil), data...)))
+		case rtype == typeCNAME:
+		default:
+			continue
+		}
+		if !seen || rttl < ttl {
+			ttl, seen = rttl, true
+		}
+	}
+	return ips, time.Duration(ttl) * time.Second, nil
+}
+
+// skipName returns the offset after the (possibly compressed) name at off.
+func skipName(msg []byte, off int) (int, bool) {
+	for {
+		if off >= len(msg) {
+			return 0, false
+		}
+		length := int(msg[off])
+		switch {
+		case length == 0:
+			return off + 1, true
+		case length&0xc0 == 0xc0:
+			return off + 2, off+2 <= len(msg)
+		default:
+			off += length + 1
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Package egress restricts where the gateway may open upstream
+// connections, so a templated or plugin-chosen upstream can't be turned
+// into a server-side request forgery:
+//
+//	cloud metadata (169.254.169.254, fd00:ec2::254, ...)  always denied
+//	DenyCIDRs                                              denied
+//	AllowCIDRs                                             allowed
+//	loopback, private, link-local, CGNAT, multicast, ...   denied
+//	everything else (public addresses)                     allowed
+//
+// With AllowHosts set, the upstream host must also match one of them
+// ("api.example.com", or "*.example.com" for any subdomain); IP literal
+// hosts must be listed or fall in AllowCIDRs.
+//
+// Addresses are checked in the transport's dialer after DNS resolution,
+// for every address actually dialed, so a host name that resolves (or is
+// rebound) to an internal address is still refused. Blocked connections
+// are logged and counted in gateway_egress_blocked_total{reason}.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// ErrBlocked matches every error returned for a refused connection.
+var ErrBlocked = errors.New("upstream destination not allowed by egress policy")
+
+var blockedTotal = metrics.NewCounterVec(
+	"gateway_egress_blocked_total",
+	"Upstream connections refused by the egress policy, by reason (metadata, denied, internal, host).",
+	"reason",
+)
+
+// Ranges that are never dialed: cloud instance metadata services.
+var metadataRanges = mustParse(
+	"169.254.169.254/32", // AWS, GCP, Azure, OpenStack
+	"fd00:ec2::254/128",  // AWS over IPv6
+	"100.100.100.200/32", // Alibaba Cloud
+)
+
+// Ranges that are not publicly routable and are denied unless allowed.
+var internalRanges = mustParse(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10", // carrier-grade NAT
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15", // benchmarking
+	"224.0.0.0/4",   // multicast
+	"240.0.0.0/4",   // reserved, broadcast
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+// Aliases accepted in AllowCIDRs and DenyCIDRs.
+var aliases = map[string][]string{
+	"loopback": {"127.0.0.0/8", "::1/128"},
+	"private":  {"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"},
+}
+
+// Config describes an egress policy.
+type Config struct {
+	// AllowHosts limits upstream hosts to these names ("*.example.com"
+	// for subdomains). Empty allows any host.
+	AllowHosts []string
+
+	// AllowCIDRs are internal ranges upstreams may be in (CIDRs, IPs, or
+	// "private"/"loopback")
+	AllowCIDRs []string
+
+	// DenyCIDRs are further ranges to refuse, e.g. a public range the
+	// gateway must not reach
+	DenyCIDRs []string
+}
+
+// Policy decides whether an upstream connection may be opened.
+type Policy struct {
+	hosts    map[string]bool // exact host names and IP literals
+	suffixes []string        // ".example.com" for "*.example.com"
+	allow    []*net.IPNet
+	deny     []*net.IPNet
+}
+
+// BlockedError describes a refused connection.
+type BlockedError struct {
+	Host   string
+	IP     string // empty when the host itself was refused
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	if e.IP == "" {
+		return fmt.Sprintf("egress to %s denied: %s", e.Host, e.Reason)
+	}
+	return fmt.Sprintf("egress to %s (%s) denied: %s", e.Host, e.IP, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrBlocked) true for every BlockedError.
+func (e *BlockedError) Is(target error) bool {
+	return target == ErrBlocked
+}
+
+// NewPolicy builds a policy from cfg.
+func NewPolicy(cfg Config) (*Policy, error) {
+	p := &Policy{hosts: make(map[string]bool)}
+
+	for _, host := range cfg.AllowHosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		switch {
+		case host == "":
+		case strings.HasPrefix(host, "*."):
+			p.suffixes = append(p.suffixes, host[1:])
+		case strings.Contains(host, "*"):
+			return nil, fmt.Errorf("invalid allowed host %q: only a leading *. is supported", host)
+		default:
+			p.hosts[host] = true
+		}
+	}
+
+	var err error
+	if p.allow, err = parseNetworks(cfg.AllowCIDRs); err != nil {
+		return nil, fmt.Errorf("invalid allowed range: %w", err)
+	}
+	if p.deny, err = parseNetworks(cfg.DenyCIDRs); err != nil {
+		return nil, fmt.Errorf("invalid denied range: %w", err)
+	}
+	return p, nil
+}
+
+// DialFunc dials address with d, e.g. resolving it through a cache first.
+type DialFunc func(ctx context.Context, d *net.Dialer, network, address string) (net.Conn, error)
+
+// DialContext wraps dial (nil = d.DialContext) so every connection is
+// checked against the policy: the host before resolution, each resolved
+// address before it is dialed.
+func (p *Policy) DialContext(d *net.Dialer, dial DialFunc) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.CheckHost(host); err != nil {
+			return nil, err
+		}
+
+		dialer := *d
+		dialer.Control = func(_, resolved string, _ syscall.RawConn) error {
+			ip, _, err := net.SplitHostPort(resolved)
+			if err != nil {
+				return err
+			}
+			return p.CheckIP(host, net.ParseIP(ip))
+		}
+		if dial != nil {
+			return dial(ctx, &dialer, network, address)
+		}
+		return dialer.DialContext(ctx, network, address)
+	}
+}
+
+// CheckHost returns a *BlockedError if host isn't an allowed upstream host.
+func (p *Policy) CheckHost(host string) error {
+	if len(p.hosts) == 0 && len(p.suffixes) == 0 {
+		return nil
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if p.hosts[host] {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if contains(p.allow, ip) {
+			return nil
+		}
+		return p.block(host, "", "host", "IP not in allowed hosts or ranges")
+	}
+	for _, suffix := range p.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return nil
+		}
+	}
+	return p.block(host, "", "host", "host not in allowed hosts")
+}
+
+// CheckIP returns a *BlockedError if ip, resolved from host, may not be
+// dialed.
+func (p *Policy) CheckIP(host string, ip net.IP) error {
+	if ip == nil {
+		return p.block(host, "", "denied", "unparseable address")
+	}
+	switch {
+	case contains(metadataRanges, ip):
+		return p.block(host, ip.String(), "metadata", "cloud metadata address")
+	case contains(p.deny, ip):
+		return p.block(host, ip.String(), "denied", "address in denied range")
+	case contains(p.allow, ip):
+		return nil
+	case contains(internalRanges, ip):
+		return p.block(host, ip.String(), "internal", "internal address")
+	}
+	return nil
+}
+
+// block logs and counts a refused connection and returns its error.
+func (p *Policy) block(host, ip, reason, detail string) error {
+	blockedTotal.Inc(reason)
+	log.Warn().
+		Str("component", "egress").
+		Str("host", host).
+		Str("ip", ip).
+		Str("reason", reason).
+		Msg("Blocked upstream connection")
+	return &BlockedError{Host: host, IP: ip, Reason: detail}
+}
+
+// parseNetworks parses CIDRs, single IPs and aliases.
+func parseNetworks(entries []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		cidrs, ok := aliases[strings.ToLower(entry)]
+		if !ok {
+			cidrs = []string{entry}
+		}
+		for _, cidr := range cidrs {
+			network, err := parseNetwork(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", entry, err)
+			}
+			networks = append(networks, network)
+		}
+	}
+	return networks, nil
+}
+
+// parseNetwork parses a CIDR or a single IP (as a /32 or /128).
+func parseNetwork(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		return network, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("not an IP address or CIDR")
+	}
+	bits := 128
+	if ip.To4() != nil {
+		ip, bits = ip.To4(), 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func mustParse(cidrs ...string) []*net.IPNet {
+	networks, err := parseNetworks(cidrs)
+	if err != nil {
+		panic(err)
+	}
+	return networks
+}
+
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
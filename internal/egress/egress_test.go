@@ -0,0 +1,121 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPolicy_CheckIP(t *testing.T) {
+	policy, err := NewPolicy(Config{
+		AllowCIDRs: []string{"10.20.0.0/16", "169.254.169.254"},
+		DenyCIDRs:  []string{"203.0.113.0/24"},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::1", true},
+		{"10.20.3.4", true},
+		{"10.21.3.4", false},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"192.168.1.1", false},
+		{"172.16.0.1", false},
+		{"100.64.0.1", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+		{"169.254.169.254", false}, // metadata can't be allowed
+		{"::ffff:169.254.169.254", false},
+		{"fd00:ec2::254", false},
+		{"203.0.113.9", false},
+	}
+	for _, tt := range tests {
+		err := policy.CheckIP("upstream", net.ParseIP(tt.ip))
+		if (err == nil) != tt.allowed {
+			t.Errorf("CheckIP(%s) error = %v, want allowed %v", tt.ip, err, tt.allowed)
+		}
+		if err != nil && !errors.Is(err, ErrBlocked) {
+			t.Errorf("CheckIP(%s) error %v is not ErrBlocked", tt.ip, err)
+		}
+	}
+}
+
+func TestPolicy_CheckHost(t *testing.T) {
+	policy, err := NewPolicy(Config{
+		AllowHosts: []string{"api.example.com", "*.svc.example.com", "198.51.100.7"},
+		AllowCIDRs: []string{"private"},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"api.example.com", true},
+		{"API.Example.com.", true},
+		{"orders.svc.example.com", true},
+		{"svc.example.com", false},
+		{"evil.com", false},
+		{"api.example.com.evil.com", false},
+		{"198.51.100.7", true},
+		{"10.1.2.3", true},
+		{"198.51.100.8", false},
+	}
+	for _, tt := range tests {
+		if err := policy.CheckHost(tt.host); (err == nil) != tt.allowed {
+			t.Errorf("CheckHost(%s) error = %v, want allowed %v", tt.host, err, tt.allowed)
+		}
+	}
+
+	open, _ := NewPolicy(Config{})
+	if err := open.CheckHost("anything.example"); err != nil {
+		t.Errorf("CheckHost() without allowed hosts error = %v", err)
+	}
+}
+
+func TestNewPolicy_Invalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{AllowCIDRs: []string{"10.0.0.0/33"}},
+		{DenyCIDRs: []string{"not-an-ip"}},
+		{AllowHosts: []string{"api.*.example.com"}},
+	} {
+		if _, err := NewPolicy(cfg); err == nil {
+			t.Errorf("NewPolicy(%+v) expected error", cfg)
+		}
+	}
+}
+
+func TestPolicy_DialContext(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	dialer := &net.Dialer{Timeout: time.Second}
+
+	// Host names are checked after resolution: localhost is still loopback
+	policy, _ := NewPolicy(Config{})
+	_, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	_, err := policy.DialContext(dialer, nil)(context.Background(), "tcp", net.JoinHostPort("localhost", port))
+	if !errors.Is(err, ErrBlocked) {
+		t.Fatalf("dial localhost error = %v, want ErrBlocked", err)
+	}
+
+	allowed, _ := NewPolicy(Config{AllowCIDRs: []string{"loopback"}})
+	conn, err := allowed.DialContext(dialer, nil)(context.Background(), "tcp", backend.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial allowed loopback error = %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,183 @@
+// Package eventqueue provides the bounded, non-blocking queue between the
+// request path and background sinks (metering, request recording).
+//
+// A Queue is a fixed-size ring buffer:
+//   - Push never blocks and never allocates beyond the ring, so a slow or
+//     unavailable sink can't stall requests or grow memory
+//   - When the ring is full the oldest event is overwritten; the newest
+//     events are usually the most useful, and a sink that catches up
+//     resumes from the present rather than from a backlog
+//   - Drops are counted per queue in gateway_event_queue_dropped_total,
+//     and the fill level is exported as gateway_event_queue_depth
+//
+// A single consumer drains the queue, either one event at a time with Next
+// or in batches by waiting on Ready and calling Pop.
+package eventqueue
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// DefaultCapacity is used when a queue is created with no capacity.
+const DefaultCapacity = 1000
+
+var (
+	droppedTotal = metrics.NewCounterVec(
+		"gateway_event_queue_dropped_total",
+		"Events dropped by background event queues because the queue was full or closed, by queue.",
+		"queue",
+	)
+	queueDepth = metrics.NewGaugeVec(
+		"gateway_event_queue_depth",
+		"Events waiting in background event queues, by queue.",
+		"queue",
+	)
+)
+
+// Queue is a bounded ring buffer of events that drops the oldest event
+// when full. It is safe for concurrent producers and one consumer.
+type Queue[T any] struct {
+	name string
+
+	mu     sync.Mutex
+	ring   []T
+	head   int // index of the oldest event
+	size   int
+	closed bool
+	ready  chan struct{} // signaled on Push, closed by Close
+
+	dropped atomic.Uint64
+}
+
+// New creates a queue holding up to capacity events. name labels the
+// queue's metrics.
+func New[T any](name string, capacity int) *Queue[T] {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &Queue[T]{
+		name:  name,
+		ring:  make([]T, capacity),
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// Push appends an event. Never blocks; returns false if an event was
+// dropped to make room (the oldest one) or because the queue is closed
+// (the pushed one).
+func (q *Queue[T]) Push(event T) bool {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		q.drop()
+		return false
+	}
+
+	ok := true
+	if q.size == len(q.ring) {
+		var zero T
+		q.ring[q.head] = zero
+		q.head = (q.head + 1) % len(q.ring)
+		q.size--
+		ok = false
+	}
+	q.ring[(q.head+q.size)%len(q.ring)] = event
+	q.size++
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	q.mu.Unlock()
+
+	if ok {
+		queueDepth.Add(1, q.name)
+	} else {
+		q.drop()
+	}
+	return ok
+}
+
+// Pop removes and returns the oldest event, or false if the queue is
+// empty. Never blocks.
+func (q *Queue[T]) Pop() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var zero T
+	if q.size == 0 {
+		return zero, false
+	}
+	event := q.ring[q.head]
+	q.ring[q.head] = zero
+	q.head = (q.head + 1) % len(q.ring)
+	q.size--
+	queueDepth.Add(-1, q.name)
+	return event, true
+}
+
+// Next blocks until an event is available and returns it, or returns false
+// once the queue is closed and empty.
+func (q *Queue[T]) Next() (T, bool) {
+	for {
+		closed := q.Closed()
+		if event, ok := q.Pop(); ok {
+			return event, true
+		}
+		if closed {
+			var zero T
+			return zero, false
+		}
+		<-q.ready
+	}
+}
+
+// Ready receives after events are pushed and is closed by Close. A
+// consumer selecting on it should check Closed before draining with Pop,
+// so no event pushed before Close is missed.
+func (q *Queue[T]) Ready() <-chan struct{} {
+	return q.ready
+}
+
+// Close stops accepting events. Queued events can still be popped.
+func (q *Queue[T]) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.ready)
+	}
+}
+
+// Closed reports whether Close was called.
+func (q *Queue[T]) Closed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+// Len returns the number of queued events.
+func (q *Queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// Cap returns the queue's capacity.
+func (q *Queue[T]) Cap() int {
+	return len(q.ring)
+}
+
+// Dropped returns the number of events dropped since the queue was created.
+func (q *Queue[T]) Dropped() uint64 {
+	return q.dropped.Load()
+}
+
+// drop counts a dropped event.
+func (q *Queue[T]) drop() {
+	q.dropped.Add(1)
+	droppedTotal.Inc(q.name)
+}
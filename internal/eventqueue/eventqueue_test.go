@@ -0,0 +1,107 @@
+package eventqueue
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueue_FIFO(t *testing.T) {
+	q := New[int]("test-fifo", 4)
+	for i := 1; i <= 3; i++ {
+		if !q.Push(i) {
+			t.Fatalf("Push(%d) dropped with room in the queue", i)
+		}
+	}
+	for want := 1; want <= 3; want++ {
+		if got, ok := q.Pop(); !ok || got != want {
+			t.Fatalf("Pop() = %d, %v; want %d", got, ok, want)
+		}
+	}
+	if _, ok := q.Pop(); ok {
+		t.Error("Pop() on an empty queue should return false")
+	}
+}
+
+func TestQueue_DropsOldestWhenFull(t *testing.T) {
+	q := New[int]("test-drop", 3)
+	for i := 1; i <= 5; i++ {
+		q.Push(i)
+	}
+
+	if q.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", q.Len())
+	}
+	if q.Dropped() != 2 {
+		t.Errorf("Dropped() = %d, want 2", q.Dropped())
+	}
+	if v := droppedTotal.Value("test-drop"); v != 2 {
+		t.Errorf("gateway_event_queue_dropped_total = %v, want 2", v)
+	}
+	if v := queueDepth.Value("test-drop"); v != 3 {
+		t.Errorf("gateway_event_queue_depth = %v, want 3", v)
+	}
+
+	// The newest events survive
+	for want := 3; want <= 5; want++ {
+		if got, _ := q.Pop(); got != want {
+			t.Errorf("Pop() = %d, want %d", got, want)
+		}
+	}
+}
+
+func TestQueue_CloseDrains(t *testing.T) {
+	q := New[string]("test-close", 0)
+	if q.Cap() != DefaultCapacity {
+		t.Errorf("Cap() = %d, want %d", q.Cap(), DefaultCapacity)
+	}
+
+	q.Push("a")
+	q.Push("b")
+	q.Close()
+
+	if q.Push("c") {
+		t.Error("Push() after Close should drop the event")
+	}
+
+	var got []string
+	for {
+		event, ok := q.Next()
+		if !ok {
+			break
+		}
+		got = append(got, event)
+	}
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("drained %q, want [a b]", got)
+	}
+}
+
+func TestQueue_NextWaitsForPush(t *testing.T) {
+	q := New[int]("test-next", 10)
+
+	var wg sync.WaitGroup
+	var received []int
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			event, ok := q.Next()
+			if !ok {
+				return
+			}
+			received = append(received, event)
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		q.Push(i)
+		time.Sleep(time.Millisecond)
+	}
+	q.Close()
+	wg.Wait()
+
+	if len(received) != 5 {
+		t.Errorf("received %d events, want 5", len(received))
+	}
+}
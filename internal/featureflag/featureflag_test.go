@@ -0,0 +1,105 @@
+package featureflag
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Value
+		ok   bool
+	}{
+		{"true", On, true},
+		{" ON ", On, true},
+		{"0", Off, true},
+		{"", Off, true},
+		{"25%", 25, true},
+		{"12.5 %", 12.5, true},
+		{"150%", Off, false},
+		{"maybe", Off, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseValue(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseValue(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestValue_Rollout(t *testing.T) {
+	v := Value(25)
+	on := 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("consumer-%d", i)
+		enabled := v.Enabled("new-limiter", key)
+		if enabled != v.Enabled("new-limiter", key) {
+			t.Fatal("rollout should be stable for a key")
+		}
+		if enabled {
+			on++
+		}
+	}
+	if on < 2300 || on > 2700 {
+		t.Errorf("25%% rollout enabled %d of 10000 keys", on)
+	}
+	if Off.Enabled("f", "k") || !On.Enabled("f", "k") {
+		t.Error("Off/On should ignore the key")
+	}
+}
+
+func TestEnvProvider(t *testing.T) {
+	p := NewEnvProvider("")
+	env := map[string]string{"FEATURE_FLAG_NEW_RATE_LIMITER": "on", "FEATURE_FLAG_BROKEN": "sometimes"}
+	p.lookup = func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	if got := p.Variable("new-rate-limiter"); got != "FEATURE_FLAG_NEW_RATE_LIMITER" {
+		t.Errorf("Variable() = %q", got)
+	}
+	if !p.Enabled("new-rate-limiter", "c1") {
+		t.Error("flag set to on should be enabled")
+	}
+	if p.Enabled("broken", "c1") || p.Enabled("unknown", "c1") {
+		t.Error("invalid and unknown flags should be off")
+	}
+}
+
+func TestHTTPProvider(t *testing.T) {
+	body := `{"a": true, "b": "off", "c": 100, "d": [1]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	p := NewHTTPProvider(srv.URL, http.Header{"Authorization": {"Bearer t"}}, time.Second)
+	if p.Enabled("a", "k") {
+		t.Error("flags should be off before the first refresh")
+	}
+	if err := p.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if !p.Enabled("a", "k") || p.Enabled("b", "k") || !p.Enabled("c", "k") || p.Enabled("d", "k") {
+		t.Error("unexpected flag values after refresh")
+	}
+
+	// A failed poll keeps the previous values
+	body = `not json`
+	if err := p.Refresh(context.Background()); err == nil {
+		t.Error("Refresh() should fail on an invalid response")
+	}
+	if !p.Enabled("a", "k") {
+		t.Error("previous values should be kept after a failed refresh")
+	}
+}
@@ -0,0 +1,155 @@
+// Package featureflag - HTTP flag service provider
+package featureflag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// maxFlagsBytes caps the flag service response.
+const maxFlagsBytes = 1 << 20
+
+var refreshesTotal = metrics.NewCounterVec(
+	"gateway_feature_flag_refreshes_total",
+	"Feature flag service polls, by result (success, failed).",
+	"result",
+)
+
+// HTTPProvider polls a flag service and evaluates flags from the last good
+// response, so a request never waits on the service.
+//
+// The service answers GET requests with a JSON object of flag values:
+//
+//	{"new-rate-limiter": "25%", "strict-waf": true, "beta-cache": 10}
+//
+// Values are booleans, numbers (percent) or strings (see ParseValue).
+// When a poll fails the previous values are kept.
+type HTTPProvider struct {
+	url    string
+	header http.Header
+	client *http.Client
+
+	mu    sync.RWMutex
+	flags map[string]Value
+}
+
+// NewHTTPProvider creates a provider polling url. header is sent with
+// every poll (e.g. Authorization), and may be nil.
+func NewHTTPProvider(url string, header http.Header, timeout time.Duration) *HTTPProvider {
+	return &HTTPProvider{
+		url:    url,
+		header: header,
+		client: &http.Client{Timeout: timeout},
+		flags:  make(map[string]Value),
+	}
+}
+
+// Enabled implements Provider.
+func (p *HTTPProvider) Enabled(flag, key string) bool {
+	p.mu.RLock()
+	v, ok := p.flags[flag]
+	p.mu.RUnlock()
+
+	return ok && v.Enabled(flag, key)
+}
+
+// Run polls the flag service every interval until ctx is done.
+func (p *HTTPProvider) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Refresh(ctx); err != nil {
+				log.Warn().
+					Err(err).
+					Str("component", "featureflag").
+					Str("url", p.url).
+					Msg("Feature flag refresh failed - keeping previous values")
+			}
+		}
+	}
+}
+
+// Refresh fetches the flag values once.
+func (p *HTTPProvider) Refresh(ctx context.Context) error {
+	flags, err := p.fetch(ctx)
+	if err != nil {
+		refreshesTotal.Inc("failed")
+		return err
+	}
+
+	p.mu.Lock()
+	p.flags = flags
+	p.mu.Unlock()
+
+	refreshesTotal.Inc("success")
+	return nil
+}
+
+// fetch polls the flag service and parses its response.
+func (p *HTTPProvider) fetch(ctx context.Context) (map[string]Value, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range p.header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("flag service returned %d", resp.StatusCode)
+	}
+
+	var raw map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxFlagsBytes)).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid flag service response: %w", err)
+	}
+
+	flags := make(map[string]Value, len(raw))
+	for name, value := range raw {
+		var v Value
+		ok := true
+		switch value := value.(type) {
+		case bool:
+			if value {
+				v = On
+			}
+		case float64:
+			v, ok = ParseValue(strconv.FormatFloat(value, 'f', -1, 64) + "%")
+		case string:
+			v, ok = ParseValue(value)
+		default:
+			ok = false
+		}
+		if !ok {
+			log.Warn().
+				Str("component", "featureflag").
+				Str("flag", name).
+				Interface("value", value).
+				Msg("Invalid feature flag value - flag is off")
+		}
+		flags[name] = v
+	}
+	return flags, nil
+}
@@ -0,0 +1,285 @@
+// Package fieldcrypt implements field-level encryption at rest for
+// sensitive configuration values (plugin secrets, the API key pepper).
+//
+// Values are encrypted with AES-256-GCM and stored as strings:
+//
+//	enc:v1:<base64(nonce || ciphertext || tag)>
+//
+// so they fit anywhere a plain string did (JSONB plugin configs, env vars)
+// and are recognizable without a schema change. The admin API encrypts
+// designated keys on write; the gateway Repository decrypts them when
+// loading, so a database dump contains no usable credentials.
+//
+// The key is 32 bytes, supplied base64- or hex-encoded via
+// CONFIG_ENCRYPTION_KEY or a file (CONFIG_ENCRYPTION_KEY_FILE). For KMS,
+// have the platform decrypt the data key into that file (e.g. a Vault
+// agent or CSI secrets driver); the gateway does not call KMS APIs itself.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Prefix marks an encrypted value.
+const Prefix = "enc:v1:"
+
+// KeySize is the required key length (AES-256).
+const KeySize = 32
+
+// ErrNoKey is returned when an encrypted value is found but no key is configured.
+var ErrNoKey = errors.New("encrypted value found but no encryption key is configured (set CONFIG_ENCRYPTION_KEY)")
+
+// Cipher encrypts and decrypts field values.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher from a 32-byte key.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// ParseKey decodes a base64 (standard or URL) or hex encoded key.
+func ParseKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+
+	if len(encoded) == hex.EncodedLen(KeySize) {
+		if key, err := hex.DecodeString(encoded); err == nil {
+			return key, nil
+		}
+	}
+
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if key, err := enc.DecodeString(encoded); err == nil && len(key) == KeySize {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("encryption key must be %d bytes encoded as base64 or hex", KeySize)
+}
+
+// LoadCipher builds a cipher from an encoded key or a key file.
+//
+// Returns nil (and no error) if neither is set, meaning encryption is
+// disabled.
+func LoadCipher(key, keyFile string) (*Cipher, error) {
+	if key == "" && keyFile == "" {
+		return nil, nil
+	}
+
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+		}
+		key = string(data)
+	}
+
+	raw, err := ParseKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCipher(raw)
+}
+
+// IsEncrypted reports whether s is an encrypted value.
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, Prefix)
+}
+
+// Encrypt encrypts a plaintext value. Already-encrypted values are
+// returned unchanged.
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if IsEncrypted(plaintext) {
+		return plaintext, nil
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return Prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts an encrypted value. Plaintext values are returned
+// unchanged, so configs can be migrated gradually.
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, Prefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value encoding: %w", err)
+	}
+
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize+c.aead.Overhead() {
+		return "", fmt.Errorf("encrypted value too short")
+	}
+
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value (wrong key?): %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// DecryptValue decrypts value with c, which may be nil when encryption is
+// disabled (only plaintext values are accepted then).
+func DecryptValue(c *Cipher, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if c == nil {
+		return "", ErrNoKey
+	}
+	return c.Decrypt(value)
+}
+
+// DecryptFields decrypts every encrypted string in a decoded JSON object,
+// in place and recursively. c may be nil when encryption is disabled.
+func DecryptFields(c *Cipher, fields map[string]interface{}) error {
+	for k, v := range fields {
+		decrypted, err := decryptAny(c, v)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", k, err)
+		}
+		fields[k] = decrypted
+	}
+	return nil
+}
+
+// decryptAny decrypts strings inside maps and lists.
+func decryptAny(c *Cipher, v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		return DecryptValue(c, val)
+	case map[string]interface{}:
+		return val, DecryptFields(c, val)
+	case []interface{}:
+		for i, child := range val {
+			decrypted, err := decryptAny(c, child)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = decrypted
+		}
+		return val, nil
+	default:
+		return v, nil
+	}
+}
+
+// EncryptFields encrypts the string values of designated keys (matched
+// case-insensitively, at any depth) in place. Lists of strings under a
+// designated key are encrypted element-wise.
+//
+// Returns the number of values that were newly encrypted.
+func (c *Cipher) EncryptFields(fields map[string]interface{}, keys []string) (int, error) {
+	designated := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		designated[strings.ToLower(strings.TrimSpace(k))] = true
+	}
+	return c.encryptMap(fields, designated)
+}
+
+// encryptMap walks a decoded JSON object encrypting designated values.
+func (c *Cipher) encryptMap(fields map[string]interface{}, designated map[string]bool) (int, error) {
+	count := 0
+
+	for k, v := range fields {
+		if designated[strings.ToLower(k)] {
+			encrypted, n, err := c.encryptAll(v)
+			if err != nil {
+				return count, fmt.Errorf("field %q: %w", k, err)
+			}
+			fields[k] = encrypted
+			count += n
+			continue
+		}
+
+		switch val := v.(type) {
+		case map[string]interface{}:
+			n, err := c.encryptMap(val, designated)
+			count += n
+			if err != nil {
+				return count, err
+			}
+		case []interface{}:
+			for _, child := range val {
+				if m, ok := child.(map[string]interface{}); ok {
+					n, err := c.encryptMap(m, designated)
+					count += n
+					if err != nil {
+						return count, err
+					}
+				}
+			}
+		}
+	}
+
+	return count, nil
+}
+
+// encryptAll encrypts every string under a designated key.
+func (c *Cipher) encryptAll(v interface{}) (interface{}, int, error) {
+	switch val := v.(type) {
+	case string:
+		if IsEncrypted(val) || val == "" {
+			return val, 0, nil
+		}
+		encrypted, err := c.Encrypt(val)
+		return encrypted, 1, err
+	case []interface{}:
+		count := 0
+		for i, child := range val {
+			encrypted, n, err := c.encryptAll(child)
+			if err != nil {
+				return nil, count, err
+			}
+			val[i] = encrypted
+			count += n
+		}
+		return val, count, nil
+	case map[string]interface{}:
+		count := 0
+		for k, child := range val {
+			encrypted, n, err := c.encryptAll(child)
+			if err != nil {
+				return nil, count, err
+			}
+			val[k] = encrypted
+			count += n
+		}
+		return val, count, nil
+	default:
+		return v, 0, nil
+	}
+}
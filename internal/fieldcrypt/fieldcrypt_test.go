@@ -0,0 +1,116 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func testCipher(t *testing.T) *Cipher {
+	t.Helper()
+	c, err := NewCipher(bytes.Repeat([]byte{7}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCipher_RoundTrip(t *testing.T) {
+	c := testCipher(t)
+
+	encrypted, err := c.Encrypt("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(encrypted) {
+		t.Fatalf("expected %q to carry the %s prefix", encrypted, Prefix)
+	}
+
+	again, _ := c.Encrypt("hunter2")
+	if again == encrypted {
+		t.Error("expected a fresh nonce per encryption")
+	}
+
+	decrypted, err := c.Decrypt(encrypted)
+	if err != nil || decrypted != "hunter2" {
+		t.Errorf("Decrypt() = %q, %v", decrypted, err)
+	}
+
+	if plain, _ := c.Decrypt("plain"); plain != "plain" {
+		t.Errorf("plaintext should pass through, got %q", plain)
+	}
+}
+
+func TestCipher_WrongKey(t *testing.T) {
+	encrypted, _ := testCipher(t).Encrypt("secret")
+
+	other, _ := NewCipher(bytes.Repeat([]byte{8}, KeySize))
+	if _, err := other.Decrypt(encrypted); err == nil {
+		t.Error("expected error decrypting with the wrong key")
+	}
+
+	if _, err := DecryptValue(nil, encrypted); !errors.Is(err, ErrNoKey) {
+		t.Errorf("DecryptValue(nil) error = %v, want ErrNoKey", err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	raw := bytes.Repeat([]byte{1}, KeySize)
+
+	for _, encoded := range []string{
+		base64.StdEncoding.EncodeToString(raw),
+		base64.RawURLEncoding.EncodeToString(raw),
+		hex.EncodeToString(raw),
+	} {
+		key, err := ParseKey(encoded + "\n")
+		if err != nil || !bytes.Equal(key, raw) {
+			t.Errorf("ParseKey(%q) = %v, %v", encoded, key, err)
+		}
+	}
+
+	if _, err := ParseKey(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Error("expected error for short key")
+	}
+}
+
+func TestFields_EncryptDecrypt(t *testing.T) {
+	c := testCipher(t)
+
+	config := map[string]interface{}{
+		"secret":     "jwt-secret",
+		"key_prefix": "idem",
+		"upstream": map[string]interface{}{
+			"Password": "pw",
+		},
+		"keys":    []interface{}{"a", "b"},
+		"enabled": true,
+	}
+
+	n, err := c.EncryptFields(config, []string{"secret", "password", "keys"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("encrypted %d values, want 4", n)
+	}
+	if config["key_prefix"] != "idem" {
+		t.Error("non-designated key should stay plaintext")
+	}
+	if !IsEncrypted(config["upstream"].(map[string]interface{})["Password"].(string)) {
+		t.Error("nested designated key should be encrypted")
+	}
+
+	// Encrypting again is a no-op
+	if n, _ := c.EncryptFields(config, []string{"secret", "password", "keys"}); n != 0 {
+		t.Errorf("re-encryption changed %d values, want 0", n)
+	}
+
+	if err := DecryptFields(c, config); err != nil {
+		t.Fatal(err)
+	}
+	if config["secret"] != "jwt-secret" || config["keys"].([]interface{})[1] != "b" {
+		t.Errorf("decrypted config = %v", config)
+	}
+}
@@ -3,30 +3,143 @@ package gateway
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/saidutt46/switchboard-gateway/internal/config"
 	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/loadbalancer"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/notify"
 	"github.com/saidutt46/switchboard-gateway/internal/plugin" // ADD THIS
+	"github.com/saidutt46/switchboard-gateway/internal/proxy"
+	"github.com/saidutt46/switchboard-gateway/internal/redirect"
+	"github.com/saidutt46/switchboard-gateway/internal/respcache"
 	"github.com/saidutt46/switchboard-gateway/internal/router"
+	"github.com/saidutt46/switchboard-gateway/internal/streamproxy"
+	"github.com/saidutt46/switchboard-gateway/internal/tenant"
+)
+
+var (
+	reloadsTotal = metrics.NewCounterVec(
+		"gateway_config_reloads_total",
+		"Hot reloads by result (success, rejected = failed validation, failed = load error)",
+		"result",
+	)
+	lastReloadSuccessful = metrics.NewGaugeVec(
+		"gateway_config_last_reload_successful",
+		"1 if the last config reload was applied, 0 if the gateway is serving a stale snapshot",
+	)
+	lastReloadSuccessTime = metrics.NewGaugeVec(
+		"gateway_config_last_reload_success_timestamp_seconds",
+		"Unix time of the last successfully applied config reload",
+	)
 )
 
 // Gateway handles HTTP proxying and config changes.
 type Gateway struct {
-	router   *router.Router
-	repo     *database.Repository
-	registry *plugin.Registry
+	router    *router.Router
+	repo      database.ConfigStore
+	registry  *plugin.Registry
+	balancers *loadbalancer.Manager
+
+	// notifier receives reload events (nil = disabled)
+	notifier *notify.Dispatcher
+
+	// redirects holds the live redirect rules (nil = not reloaded)
+	redirects *redirect.Engine
+
+	// tenants holds the live tenant registry (nil = not reloaded)
+	tenants *tenant.Registry
+
+	// streams holds the live stream listeners (nil = not reloaded)
+	streams *streamproxy.Server
+
+	// cache receives purges published by other instances (nil = disabled)
+	cache *respcache.Store
+
+	// proxy gets a new transport when transport settings change; the
+	// settings are applied on top of transportBase (nil = not reloaded)
+	proxy         *proxy.Proxy
+	transportBase proxy.TransportConfig
 }
 
 // New creates a new Gateway instance.
-func New(router *router.Router, repo *database.Repository, registry *plugin.Registry) *Gateway {
+func New(router *router.Router, repo database.ConfigStore, registry *plugin.Registry, balancers *loadbalancer.Manager) *Gateway {
+	// The startup config is the first good snapshot
+	lastReloadSuccessful.Set(1)
+	lastReloadSuccessTime.Set(float64(time.Now().Unix()))
+
 	return &Gateway{
-		router:   router,
-		repo:     repo,
-		registry: registry,
+		router:    router,
+		repo:      repo,
+		registry:  registry,
+		balancers: balancers,
 	}
 }
 
+// SetNotifier sends config.reloaded / config.reload_failed events to d.
+func (g *Gateway) SetNotifier(d *notify.Dispatcher) {
+	g.notifier = d
+}
+
+// SetRedirects reloads the redirect rules in e along with the rest of the
+// configuration.
+func (g *Gateway) SetRedirects(e *redirect.Engine) {
+	g.redirects = e
+}
+
+// SetTenants reloads the tenant registry in reg along with the rest of the
+// configuration.
+func (g *Gateway) SetTenants(reg *tenant.Registry) {
+	g.tenants = reg
+}
+
+// SetStreams reloads the stream listeners in s along with the rest of the
+// configuration.
+func (g *Gateway) SetStreams(s *streamproxy.Server) {
+	g.streams = s
+}
+
+// SetCache applies cache purges published on the config change channel
+// to s.
+func (g *Gateway) SetCache(s *respcache.Store) {
+	g.cache = s
+}
+
+// SetProxy rebuilds px's upstream transport from base plus the
+// gateway_settings overrides whenever settings change.
+func (g *Gateway) SetProxy(px *proxy.Proxy, base proxy.TransportConfig) {
+	g.proxy = px
+	g.transportBase = base
+}
+
+// ApplySettings loads the runtime settings and gives the proxy a transport
+// built from them. Invalid settings are rejected and the current transport
+// keeps serving.
+func (g *Gateway) ApplySettings(ctx context.Context) error {
+	if g.proxy == nil {
+		return nil
+	}
+
+	settings, err := g.repo.GetGatewaySettings(ctx)
+	if err != nil {
+		return g.reloadFailed(err)
+	}
+	cfg, err := g.transportBase.WithSettings(settings)
+	if err != nil {
+		return g.reloadFailed(err)
+	}
+
+	g.proxy.SetTransport(proxy.NewTransport(cfg))
+	reloadsTotal.Inc("success")
+	lastReloadSuccessful.Set(1)
+	lastReloadSuccessTime.Set(float64(time.Now().Unix()))
+
+	return nil
+}
+
 // HandleConfigChange handles configuration change events from Admin API.
 // This implements the config.ConfigChangeHandler interface.
 func (g *Gateway) HandleConfigChange(event config.ConfigChangeEvent) error {
@@ -37,12 +150,22 @@ func (g *Gateway) HandleConfigChange(event config.ConfigChangeEvent) error {
 		Msg("Handling config change")
 
 	switch event.EntityType {
-	case "route":
+	case "route", "route_group":
 		return g.handleRouteChange(event)
 	case "service":
 		return g.handleServiceChange(event)
 	case "plugin":
 		return g.handlePluginChange(event)
+	case "redirect":
+		return g.handleRedirectChange(event)
+	case "tenant":
+		return g.handleTenantChange(event)
+	case "stream_listener":
+		return g.handleStreamListenerChange(event)
+	case "setting":
+		return g.handleSettingChange(event)
+	case respcache.EntityType:
+		return g.handleCachePurge(event)
 	default:
 		log.Warn().
 			Str("entity_type", event.EntityType).
@@ -57,32 +180,31 @@ func (g *Gateway) handleRouteChange(event config.ConfigChangeEvent) error {
 		Str("route_id", event.EntityID).
 		Msg("Route change detected - reloading configuration")
 
-	ctx := context.Background()
-
-	// Reload plugins first
-	var pluginInstances []plugin.PluginInstance
-	if g.registry != nil {
-		if err := g.registry.Reload(ctx, g.repo); err != nil {
-			log.Error().
-				Err(err).
-				Msg("Failed to reload plugins - continuing with empty plugins")
-			pluginInstances = []plugin.PluginInstance{}
-		} else {
-			pluginInstances = g.registry.GetInstances()
-		}
-	} else {
-		pluginInstances = []plugin.PluginInstance{}
+	if err := g.reload(context.Background(), false); err != nil {
+		return err
 	}
 
-	// Reload router with new plugins
-	if err := g.router.Reload(ctx, g.repo, pluginInstances); err != nil {
-		log.Error().
-			Err(err).
-			Msg("Failed to reload routes")
+	log.Info().Msg("Route configuration reloaded successfully")
+
+	return nil
+}
+
+func (g *Gateway) handleCachePurge(event config.ConfigChangeEvent) error {
+	if g.cache == nil {
+		return nil
+	}
+	purge, err := respcache.PurgeFromEvent(event)
+	if err != nil {
 		return err
 	}
 
-	log.Info().Msg("Route configuration reloaded successfully")
+	purged := g.cache.Purge(purge)
+	log.Info().
+		Str("route_id", purge.RouteID).
+		Str("path", purge.Path).
+		Str("tag", purge.Tag).
+		Int("purged", purged).
+		Msg("Response cache purged")
 
 	return nil
 }
@@ -93,28 +215,8 @@ func (g *Gateway) handleServiceChange(event config.ConfigChangeEvent) error {
 		Str("service_id", event.EntityID).
 		Msg("Service change detected - reloading configuration")
 
-	ctx := context.Background()
-
-	// Reload plugins first
-	var pluginInstances []plugin.PluginInstance
-	if g.registry != nil {
-		if err := g.registry.Reload(ctx, g.repo); err != nil {
-			log.Error().
-				Err(err).
-				Msg("Failed to reload plugins - continuing with empty plugins")
-			pluginInstances = []plugin.PluginInstance{}
-		} else {
-			pluginInstances = g.registry.GetInstances()
-		}
-	} else {
-		pluginInstances = []plugin.PluginInstance{}
-	}
-
-	// Reload router with new plugins
-	if err := g.router.Reload(ctx, g.repo, pluginInstances); err != nil {
-		log.Error().
-			Err(err).
-			Msg("Failed to reload services")
+	// Rebuild load balancers too (targets or balancing settings may have changed)
+	if err := g.reload(context.Background(), true); err != nil {
 		return err
 	}
 
@@ -129,36 +231,232 @@ func (g *Gateway) handlePluginChange(event config.ConfigChangeEvent) error {
 		Str("plugin_id", event.EntityID).
 		Msg("Plugin change detected - reloading configuration")
 
-	ctx := context.Background()
+	if err := g.reload(context.Background(), false); err != nil {
+		return err
+	}
+
+	log.Info().Msg("Plugin configuration reloaded successfully")
+
+	return nil
+}
+
+func (g *Gateway) handleRedirectChange(event config.ConfigChangeEvent) error {
+	log.Info().
+		Str("action", event.Action).
+		Str("redirect_id", event.EntityID).
+		Msg("Redirect change detected - reloading configuration")
 
-	// Reload plugins
-	var pluginInstances []plugin.PluginInstance
+	if err := g.reload(context.Background(), false); err != nil {
+		return err
+	}
+
+	log.Info().Msg("Redirect configuration reloaded successfully")
+
+	return nil
+}
+
+func (g *Gateway) handleTenantChange(event config.ConfigChangeEvent) error {
+	log.Info().
+		Str("action", event.Action).
+		Str("tenant_id", event.EntityID).
+		Msg("Tenant change detected - reloading tenant registry")
+
+	if g.tenants == nil {
+		return nil
+	}
+	if err := g.tenants.Reload(context.Background(), g.repo); err != nil {
+		return g.reloadFailed(err)
+	}
+
+	reloadsTotal.Inc("success")
+	lastReloadSuccessful.Set(1)
+	lastReloadSuccessTime.Set(float64(time.Now().Unix()))
+
+	log.Info().Msg("Tenant registry reloaded successfully")
+
+	return nil
+}
+
+func (g *Gateway) handleStreamListenerChange(event config.ConfigChangeEvent) error {
+	log.Info().
+		Str("action", event.Action).
+		Str("stream_listener_id", event.EntityID).
+		Msg("Stream listener change detected - reloading stream listeners")
+
+	if g.streams == nil {
+		return nil
+	}
+	if err := g.streams.Reload(context.Background(), g.repo); err != nil {
+		return g.reloadFailed(err)
+	}
+
+	reloadsTotal.Inc("success")
+	lastReloadSuccessful.Set(1)
+	lastReloadSuccessTime.Set(float64(time.Now().Unix()))
+
+	log.Info().Msg("Stream listeners reloaded successfully")
+
+	return nil
+}
+
+func (g *Gateway) handleSettingChange(event config.ConfigChangeEvent) error {
+	log.Info().
+		Str("action", event.Action).
+		Str("setting", event.EntityID).
+		Msg("Setting change detected - rebuilding upstream transport")
+
+	if err := g.ApplySettings(context.Background()); err != nil {
+		return err
+	}
+
+	log.Info().Msg("Gateway settings applied successfully")
+
+	return nil
+}
+
+// reload loads and validates the full config set (plugins, routes,
+// services, redirects, tenants, stream listeners) and swaps it in only if it is valid.
+//
+// On any failure the previous plugins and routes keep serving; the error
+// is logged and counted in gateway_config_reloads_total so it can be
+// alerted on.
+func (g *Gateway) reload(ctx context.Context, reloadBalancers bool) error {
+	// Build plugin instances without replacing the live ones
+	pluginInstances := []plugin.PluginInstance{}
+	var pluginErrors []error
 	if g.registry != nil {
-		if err := g.registry.Reload(ctx, g.repo); err != nil {
-			log.Error().
-				Err(err).
-				Msg("Failed to reload plugins")
-			return err
+		instances, buildErrors, err := g.registry.Build(ctx, g.repo)
+		if err != nil {
+			return g.reloadFailed(err)
 		}
-		pluginInstances = g.registry.GetInstances()
-
-		log.Info().
-			Int("plugin_count", len(pluginInstances)).
-			Msg("Plugins reloaded successfully")
+		pluginInstances, pluginErrors = instances, buildErrors
 	} else {
 		log.Warn().Msg("Plugin registry not available")
-		pluginInstances = []plugin.PluginInstance{}
 	}
 
-	// Reload router with new plugins
-	if err := g.router.Reload(ctx, g.repo, pluginInstances); err != nil {
-		log.Error().
-			Err(err).
-			Msg("Failed to reload configuration after plugin change")
-		return err
+	// Instances that aren't installed are closed (see plugin.CloseInstances)
+	installed := false
+	defer func() {
+		if !installed {
+			plugin.CloseInstances(pluginInstances)
+		}
+	}()
+
+	// Compile redirect rules before anything is swapped
+	var redirects *redirect.Rules
+	if g.redirects != nil {
+		rules, err := redirect.Load(ctx, g.repo)
+		if err != nil {
+			return g.reloadFailed(err)
+		}
+		redirects = rules
 	}
 
-	log.Info().Msg("Plugin configuration reloaded successfully")
+	// Load the tenant registry before anything is swapped
+	var tenants []*database.Tenant
+	if g.tenants != nil {
+		loaded, err := tenant.Load(ctx, g.repo)
+		if err != nil {
+			return g.reloadFailed(err)
+		}
+		tenants = loaded
+	}
+
+	// Stream listeners follow their services (address, enabled)
+	var streams *streamproxy.Config
+	if g.streams != nil {
+		loaded, err := streamproxy.Load(ctx, g.repo)
+		if err != nil {
+			return g.reloadFailed(err)
+		}
+		streams = loaded
+	}
+
+	// Validate and swap routes (keeps the last good snapshot on failure)
+	if err := g.router.Reload(ctx, g.repo, pluginInstances, pluginErrors); err != nil {
+		return g.reloadFailed(err)
+	}
+
+	if g.registry != nil {
+		g.registry.SetInstances(pluginInstances)
+	}
+	installed = true
+	g.redirects.Set(redirects)
+	g.tenants.Set(tenants)
+	g.streams.Set(streams)
+
+	if reloadBalancers && g.balancers != nil {
+		if err := g.balancers.Reload(ctx, g.repo); err != nil {
+			log.Error().
+				Err(err).
+				Msg("Failed to reload load balancers")
+			reloadsTotal.Inc("failed")
+			g.publishReloadFailed("failed", err, nil)
+			return err
+		}
+	}
+
+	reloadsTotal.Inc("success")
+	lastReloadSuccessful.Set(1)
+	lastReloadSuccessTime.Set(float64(time.Now().Unix()))
+
+	g.notifier.Publish(notify.Event{
+		Type:     notify.EventConfigReloaded,
+		Severity: notify.SeverityInfo,
+		Message:  "Configuration reloaded",
+		Data: map[string]interface{}{
+			"plugins":          len(pluginInstances),
+			"reload_balancers": reloadBalancers,
+		},
+	})
 
 	return nil
 }
+
+// reloadFailed records a rejected or failed reload.
+func (g *Gateway) reloadFailed(err error) error {
+	result := "failed"
+	var problems []string
+	var validationErr *router.ValidationError
+	if errors.As(err, &validationErr) {
+		result = "rejected"
+		problems = validationErr.Problems
+		for _, problem := range validationErr.Problems {
+			log.Error().
+				Str("component", "gateway").
+				Str("problem", problem).
+				Msg("Invalid configuration")
+		}
+	}
+
+	reloadsTotal.Inc(result)
+	lastReloadSuccessful.Set(0)
+
+	log.Error().
+		Err(err).
+		Str("component", "gateway").
+		Str("result", result).
+		Msg("Config reload failed - keeping last good configuration")
+
+	g.publishReloadFailed(result, err, problems)
+
+	return err
+}
+
+// publishReloadFailed sends a config.reload_failed event.
+func (g *Gateway) publishReloadFailed(result string, err error, problems []string) {
+	data := map[string]interface{}{
+		"result": result,
+		"error":  err.Error(),
+	}
+	if len(problems) > 0 {
+		data["problems"] = problems
+	}
+
+	g.notifier.Publish(notify.Event{
+		Type:     notify.EventConfigReloadFailed,
+		Severity: notify.SeverityCritical,
+		Message:  "Config reload failed - gateway is serving the last good configuration",
+		Data:     data,
+	})
+}
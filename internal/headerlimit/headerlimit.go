@@ -0,0 +1,222 @@
+// Package headerlimit enforces limits on request headers before they reach
+// backends.
+//
+// http.Server.MaxHeaderBytes only bounds the raw header block while it is
+// read. Check applies exact limits on the parsed headers and deals with
+// headers that confuse backends when repeated or oversized:
+//   - Total header bytes (name + ": " + value + CRLF per line) over
+//     MaxBytes, or more than MaxCount header lines, get 431
+//   - A header value over MaxValueBytes is rejected (431) or stripped
+//   - A repeated single-valued header (Authorization, Content-Type, ...) is
+//     rejected (400) or reduced to its first value
+//
+// Handler applies the gateway-wide limits to every request; the
+// header-limits plugin applies tighter ones per service or route.
+package headerlimit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+var headersTotal = metrics.NewCounterVec(
+	"gateway_header_limit_total",
+	"Headers stripped and requests rejected by header limits, by result and reason.",
+	"result", "reason",
+)
+
+// Policies for oversized and duplicate headers.
+const (
+	Reject = "reject"
+	Strip  = "strip"
+)
+
+// Errors returned by Check.
+var (
+	ErrTooLarge      = errors.New("request headers too large")
+	ErrTooMany       = errors.New("too many request headers")
+	ErrValueTooLarge = errors.New("request header value too large")
+	ErrDuplicate     = errors.New("duplicate single-valued request header")
+)
+
+// DefaultSingleValued are headers that must not repeat: backends disagree on
+// whether the first or last value wins.
+var DefaultSingleValued = []string{
+	"Authorization",
+	"Content-Length",
+	"Content-Type",
+	"Transfer-Encoding",
+	"X-Forwarded-Host",
+	"X-Forwarded-Proto",
+	"X-Real-IP",
+}
+
+// Config holds header limits. Zero limits are not enforced.
+type Config struct {
+	// MaxBytes limits the total size of all headers
+	MaxBytes int
+
+	// MaxCount limits the number of header lines
+	MaxCount int
+
+	// MaxValueBytes limits a single header value
+	MaxValueBytes int
+
+	// Oversized is Reject or Strip for values over MaxValueBytes
+	Oversized string
+
+	// SingleValued headers may appear once
+	SingleValued []string
+
+	// Duplicates is Reject or Strip (keep the first value)
+	Duplicates string
+}
+
+// DefaultConfig returns the gateway-wide defaults.
+func DefaultConfig() Config {
+	return Config{
+		MaxBytes:     64 << 10,
+		MaxCount:     100,
+		Oversized:    Reject,
+		SingleValued: DefaultSingleValued,
+		Duplicates:   Reject,
+	}
+}
+
+// Validate checks the policies are known and limits are not negative.
+func (c Config) Validate() error {
+	if c.MaxBytes < 0 || c.MaxCount < 0 || c.MaxValueBytes < 0 {
+		return fmt.Errorf("header limits cannot be negative")
+	}
+	if c.Oversized != Reject && c.Oversized != Strip {
+		return fmt.Errorf("invalid oversized header policy: %q (must be reject or strip)", c.Oversized)
+	}
+	if c.Duplicates != Reject && c.Duplicates != Strip {
+		return fmt.Errorf("invalid duplicate header policy: %q (must be reject or strip)", c.Duplicates)
+	}
+	return nil
+}
+
+// Check enforces config on h, stripping headers in place where the policy
+// allows it. Returns the names of stripped headers, or an error if the
+// request must be rejected (see Status). Outcomes are counted in
+// gateway_header_limit_total.
+func Check(h http.Header, config Config) (stripped []string, err error) {
+	defer func() {
+		if err != nil {
+			headersTotal.Inc("rejected", reason(err))
+		}
+	}()
+
+	// Strip first, so the totals count what the backend would receive
+	if config.MaxValueBytes > 0 {
+		for _, name := range sortedNames(h) {
+			for _, value := range h[name] {
+				if len(value) <= config.MaxValueBytes {
+					continue
+				}
+				if config.Oversized != Strip {
+					return nil, fmt.Errorf("%w: %s", ErrValueTooLarge, name)
+				}
+				h.Del(name)
+				headersTotal.Inc("stripped", "value_too_large")
+				stripped = append(stripped, name)
+				break
+			}
+		}
+	}
+
+	for _, name := range config.SingleValued {
+		name = http.CanonicalHeaderKey(name)
+		if len(h[name]) <= 1 {
+			continue
+		}
+		if config.Duplicates != Strip {
+			return stripped, fmt.Errorf("%w: %s", ErrDuplicate, name)
+		}
+		h[name] = h[name][:1]
+		headersTotal.Inc("stripped", "duplicate")
+		stripped = append(stripped, name)
+	}
+
+	size, count := 0, 0
+	for name, values := range h {
+		for _, value := range values {
+			size += len(name) + len(value) + 4 // ": " and CRLF
+			count++
+		}
+	}
+	if config.MaxCount > 0 && count > config.MaxCount {
+		return stripped, ErrTooMany
+	}
+	if config.MaxBytes > 0 && size > config.MaxBytes {
+		return stripped, ErrTooLarge
+	}
+	return stripped, nil
+}
+
+// Status returns the HTTP status for a Check error.
+func Status(err error) int {
+	if errors.Is(err, ErrDuplicate) {
+		return http.StatusBadRequest
+	}
+	return http.StatusRequestHeaderFieldsTooLarge
+}
+
+// Handler enforces config on every request before passing it to next.
+func Handler(config Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stripped, err := Check(r.Header, config)
+		if len(stripped) > 0 {
+			log.Debug().
+				Str("component", "headerlimit").
+				Str("path", r.URL.Path).
+				Strs("headers", stripped).
+				Msg("Stripped request headers")
+		}
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Str("component", "headerlimit").
+				Str("path", r.URL.Path).
+				Msg("Rejected request headers")
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(Status(err))
+			fmt.Fprintf(w, `{"error":"bad request","message":%q}`, err.Error())
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reason labels a Check error for metrics.
+func reason(err error) string {
+	switch {
+	case errors.Is(err, ErrTooMany):
+		return "too_many"
+	case errors.Is(err, ErrValueTooLarge):
+		return "value_too_large"
+	case errors.Is(err, ErrDuplicate):
+		return "duplicate"
+	default:
+		return "too_large"
+	}
+}
+
+// sortedNames returns h's header names in a stable order.
+func sortedNames(h http.Header) []string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
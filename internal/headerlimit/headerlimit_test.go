@@ -0,0 +1,114 @@
+package headerlimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	strict := DefaultConfig()
+	strict.MaxValueBytes = 16
+	lenient := strict
+	lenient.Oversized = Strip
+	lenient.Duplicates = Strip
+
+	tests := []struct {
+		name         string
+		header       http.Header
+		config       Config
+		wantErr      error
+		wantStripped []string
+	}{
+		{"within limits", http.Header{"Accept": {"*/*"}}, strict, nil, nil},
+		{"too many", manyHeaders(101), strict, ErrTooMany, nil},
+		{"too large", http.Header{"X-Big": {strings.Repeat("a", 64<<10)}}, DefaultConfig(), ErrTooLarge, nil},
+		{"value too large", http.Header{"Cookie": {strings.Repeat("a", 17)}}, strict, ErrValueTooLarge, nil},
+		{"strips large value", http.Header{"Cookie": {strings.Repeat("a", 17)}}, lenient, nil, []string{"Cookie"}},
+		{"duplicate", http.Header{"Authorization": {"a", "b"}}, strict, ErrDuplicate, nil},
+		{"strips duplicate", http.Header{"Authorization": {"a", "b"}}, lenient, nil, []string{"Authorization"}},
+		{"repeatable header", http.Header{"Accept": {"a", "b"}}, strict, nil, nil},
+		{"no limits", manyHeaders(500), Config{Oversized: Reject, Duplicates: Reject}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, err := Check(tt.header, tt.config)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Check() error = %v, want %v", err, tt.wantErr)
+			}
+			if strings.Join(stripped, ",") != strings.Join(tt.wantStripped, ",") {
+				t.Errorf("Check() stripped = %v, want %v", stripped, tt.wantStripped)
+			}
+		})
+	}
+}
+
+func TestCheck_StripsInPlace(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxValueBytes = 4
+	config.Oversized = Strip
+	config.Duplicates = Strip
+
+	h := http.Header{"Authorization": {"a", "b"}, "X-Long": {"12345"}}
+	if _, err := Check(h, config); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if got := h.Values("Authorization"); len(got) != 1 || got[0] != "a" {
+		t.Errorf("Authorization = %v, want [a]", got)
+	}
+	if h.Get("X-Long") != "" {
+		t.Error("X-Long should have been stripped")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	handler := Handler(DefaultConfig(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"passes", http.Header{"Accept": {"*/*"}}, http.StatusOK},
+		{"too many", manyHeaders(101), http.StatusRequestHeaderFieldsTooLarge},
+		{"duplicate", http.Header{"Content-Type": {"a", "b"}}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header = tt.header
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("DefaultConfig().Validate() = %v", err)
+	}
+
+	bad := DefaultConfig()
+	bad.Duplicates = "ignore"
+	if bad.Validate() == nil {
+		t.Error("Validate() should reject unknown policies")
+	}
+}
+
+func manyHeaders(n int) http.Header {
+	h := make(http.Header, n)
+	for i := 0; i < n; i++ {
+		h.Set("X-Header-"+strconv.Itoa(i), "v")
+	}
+	return h
+}
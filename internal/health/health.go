@@ -4,13 +4,22 @@
 //   - Load balancer health checks
 //   - Kubernetes liveness/readiness probes
 //   - Monitoring and alerting
+//
+// Subsystems register a Checker with the Handler, each with its own
+// timeout and criticality, and appear in /health from then on:
+//   - A failing critical check makes /health return 503 and /ready fail
+//   - A failing non-critical check marks /health "degraded" (still 200)
 package health
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -18,33 +27,130 @@ import (
 	"github.com/saidutt46/switchboard-gateway/internal/database"
 )
 
+// DefaultCheckTimeout bounds a check registered without a timeout.
+const DefaultCheckTimeout = 2 * time.Second
+
+// Checker checks one subsystem. It returns details shown under the check
+// in /health, and an error if the subsystem is unhealthy.
+//
+// Check must honor ctx; a check still running when its timeout passes is
+// reported as failed.
+type Checker interface {
+	Check(ctx context.Context) (map[string]interface{}, error)
+}
+
+// CheckerFunc adapts a function to the Checker interface.
+type CheckerFunc func(ctx context.Context) (map[string]interface{}, error)
+
+// Check calls f(ctx).
+func (f CheckerFunc) Check(ctx context.Context) (map[string]interface{}, error) {
+	return f(ctx)
+}
+
+// CheckOptions configures a registered check.
+type CheckOptions struct {
+	// Timeout bounds the check (0 = DefaultCheckTimeout)
+	Timeout time.Duration
+
+	// Critical checks fail /health and /ready; non-critical ones only
+	// degrade /health
+	Critical bool
+}
+
+// check is a registered Checker.
+type check struct {
+	name    string
+	checker Checker
+	options CheckOptions
+}
+
 // Handler provides HTTP handlers for health checks.
 type Handler struct {
 	db   *database.DB
 	repo *database.Repository
+
+	mu     sync.RWMutex
+	checks []check
+
+	// draining fails readiness once shutdown has begun
+	draining atomic.Bool
 }
 
-// NewHandler creates a new health check handler.
+// NewHandler creates a new health check handler. The database is
+// registered as a critical check when db is not nil.
 func NewHandler(db *database.DB, repo *database.Repository) *Handler {
-	return &Handler{
+	h := &Handler{
 		db:   db,
 		repo: repo,
 	}
+	if db != nil {
+		h.Register("database", CheckerFunc(databaseCheck(db)), CheckOptions{Critical: true})
+	}
+	return h
+}
+
+// Register adds a check reported under name, replacing any check already
+// registered under it. Checks run in registration order.
+func (h *Handler) Register(name string, checker Checker, options CheckOptions) {
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultCheckTimeout
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := check{name: name, checker: checker, options: options}
+	for i := range h.checks {
+		if h.checks[i].name == name {
+			h.checks[i] = c
+			return
+		}
+	}
+	h.checks = append(h.checks, c)
+}
+
+// Checks returns the names of the registered checks.
+func (h *Handler) Checks() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	names := make([]string, len(h.checks))
+	for i, c := range h.checks {
+		names[i] = c.name
+	}
+	return names
+}
+
+// StartDrain makes /ready fail so load balancers stop sending new traffic.
+// Returns false if draining had already started.
+func (h *Handler) StartDrain() bool {
+	return h.draining.CompareAndSwap(false, true)
+}
+
+// Draining reports whether StartDrain has been called.
+func (h *Handler) Draining() bool {
+	return h.draining.Load()
 }
 
 // HealthResponse represents the health check response.
 type HealthResponse struct {
-	Status   string                 `json:"status"` // "healthy" or "unhealthy"
-	Version  string                 `json:"version,omitempty"`
-	Uptime   string                 `json:"uptime,omitempty"`
-	Database map[string]interface{} `json:"database"`
-	Checks   map[string]CheckResult `json:"checks,omitempty"`
+	Status  string `json:"status"` // "healthy", "degraded" or "unhealthy"
+	Version string `json:"version,omitempty"`
+	Uptime  string `json:"uptime,omitempty"`
+
+	// Database repeats the database check's details for existing consumers
+	Database map[string]interface{} `json:"database,omitempty"`
+
+	Checks map[string]CheckResult `json:"checks,omitempty"`
 }
 
 // CheckResult represents the result of an individual health check.
 type CheckResult struct {
-	Status  string `json:"status"` // "pass" or "fail"
-	Message string `json:"message,omitempty"`
+	Status     string                 `json:"status"` // "pass" or "fail"
+	Message    string                 `json:"message,omitempty"`
+	Critical   bool                   `json:"critical"`
+	DurationMs int64                  `json:"duration_ms"`
+	Details    map[string]interface{} `json:"details,omitempty"`
 }
 
 var startTime = time.Now()
@@ -53,24 +159,27 @@ var startTime = time.Now()
 //
 // Returns detailed health information including:
 //   - Overall status
-//   - Database health
+//   - The result of every registered check
 //   - Uptime
 //
-// Returns 200 if healthy, 503 if unhealthy.
+// Returns 200 if healthy or degraded, 503 if a critical check failed.
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-
-	// Check database health
-	dbHealth := h.db.Health(ctx)
+	results := h.run(r.Context(), false)
 
 	// Determine overall status
 	overallStatus := "healthy"
 	statusCode := http.StatusOK
 
-	if dbHealth["status"] != "healthy" {
-		overallStatus = "unhealthy"
-		statusCode = http.StatusServiceUnavailable
+	for _, result := range results {
+		if result.Status == "pass" {
+			continue
+		}
+		if result.Critical {
+			overallStatus = "unhealthy"
+			statusCode = http.StatusServiceUnavailable
+			break
+		}
+		overallStatus = "degraded"
 	}
 
 	// Calculate uptime
@@ -78,15 +187,12 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 
 	// Build response
 	response := HealthResponse{
-		Status:   overallStatus,
-		Uptime:   formatDuration(uptime),
-		Database: dbHealth,
-		Checks: map[string]CheckResult{
-			"database": {
-				Status:  getCheckStatus(dbHealth["status"]),
-				Message: getCheckMessage(dbHealth),
-			},
-		},
+		Status: overallStatus,
+		Uptime: formatDuration(uptime),
+		Checks: results,
+	}
+	if db, ok := results["database"]; ok {
+		response.Database = db.Details
 	}
 
 	// Log health check
@@ -110,32 +216,46 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 // This is specifically for Kubernetes readiness probes.
 // Returns 200 if the gateway is ready to accept traffic, 503 otherwise.
 //
-// Currently checks:
-//   - Database connectivity
-//
-// In future phases will check:
-//   - Configuration loaded
-//   - Routes initialized
-//   - Plugins loaded
+// Checks:
+//   - Not draining (see StartDrain)
+//   - Every critical check (database connectivity and any other
+//     subsystem registered as critical)
 func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	// Fail readiness while shutting down so traffic moves elsewhere
+	if h.Draining() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":"not ready","reason":"draining"}`))
+		return
+	}
 
-	// Check database connectivity
-	if err := h.db.Ping(ctx); err != nil {
+	results := h.run(r.Context(), true)
+
+	failed := make([]string, 0)
+	for name, result := range results {
+		if result.Status != "pass" {
+			failed = append(failed, name)
+		}
+	}
+	sort.Strings(failed)
+
+	if len(failed) > 0 {
 		log.Warn().
-			Err(err).
+			Strs("checks", failed).
 			Str("component", "health").
-			Msg("Readiness check failed: database not reachable")
+			Str("error", results[failed[0]].Message).
+			Msg("Readiness check failed")
 
+		body, _ := json.Marshal(map[string]string{
+			"status": "not ready",
+			"reason": failed[0] + " unavailable",
+		})
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusServiceUnavailable)
-		w.Write([]byte(`{"status":"not ready","reason":"database unavailable"}`))
+		w.Write(body)
 		return
 	}
 
-	// TODO: Phase 3 - Check if routes are loaded
-	// TODO: Phase 7 - Check if plugins are initialized
-
 	log.Debug().
 		Str("component", "health").
 		Str("remote_addr", r.RemoteAddr).
@@ -146,20 +266,97 @@ func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status":"ready"}`))
 }
 
-// getCheckStatus converts a health status to a check status.
-func getCheckStatus(status interface{}) string {
-	if s, ok := status.(string); ok && s == "healthy" {
-		return "pass"
+// run runs the registered checks concurrently (only the critical ones if
+// criticalOnly is set) and returns their results by name.
+func (h *Handler) run(ctx context.Context, criticalOnly bool) map[string]CheckResult {
+	h.mu.RLock()
+	checks := make([]check, 0, len(h.checks))
+	for _, c := range h.checks {
+		if !criticalOnly || c.options.Critical {
+			checks = append(checks, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	byName := make(map[string]CheckResult, len(checks))
+	for i, c := range checks {
+		byName[c.name] = results[i]
+	}
+	return byName
+}
+
+// run runs the check within its timeout. A checker that doesn't return in
+// time is left running in the background and reported as failed.
+func (c check) run(parent context.Context) CheckResult {
+	ctx, cancel := context.WithTimeout(parent, c.options.Timeout)
+	defer cancel()
+
+	type outcome struct {
+		details map[string]interface{}
+		err     error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+
+	go func() {
+		details, err := c.checker.Check(ctx)
+		done <- outcome{details, err}
+	}()
+
+	var out outcome
+	select {
+	case out = <-done:
+	case <-ctx.Done():
+		out.err = ctx.Err()
+	}
+	if errors.Is(out.err, context.DeadlineExceeded) {
+		out.err = fmt.Errorf("timed out after %s", c.options.Timeout)
+	}
+
+	result := CheckResult{
+		Status:     "pass",
+		Message:    "operational",
+		Critical:   c.options.Critical,
+		DurationMs: time.Since(start).Milliseconds(),
+		Details:    out.details,
+	}
+	if out.err != nil {
+		result.Status = "fail"
+		result.Message = out.err.Error()
+
+		log.Warn().
+			Err(out.err).
+			Str("component", "health").
+			Str("check", c.name).
+			Bool("critical", c.options.Critical).
+			Msg("Health check failed")
 	}
-	return "fail"
+	return result
 }
 
-// getCheckMessage extracts a message from health check results.
-func getCheckMessage(health map[string]interface{}) string {
-	if err, ok := health["error"].(string); ok {
-		return err
+// databaseCheck checks database connectivity and reports pool statistics.
+func databaseCheck(db *database.DB) func(ctx context.Context) (map[string]interface{}, error) {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		details := db.Health(ctx)
+		if details["status"] != "healthy" {
+			if msg, ok := details["error"].(string); ok {
+				return details, errors.New(msg)
+			}
+			return details, errors.New("database unhealthy")
+		}
+		return details, nil
 	}
-	return "operational"
 }
 
 // formatDuration formats a duration in a human-readable way.
@@ -0,0 +1,137 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func passing(details map[string]interface{}) Checker {
+	return CheckerFunc(func(ctx context.Context) (map[string]interface{}, error) {
+		return details, nil
+	})
+}
+
+func failing(msg string) Checker {
+	return CheckerFunc(func(ctx context.Context) (map[string]interface{}, error) {
+		return nil, errors.New(msg)
+	})
+}
+
+func getHealth(t *testing.T, h *Handler) (int, HealthResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.Health(rec, httptest.NewRequest("GET", "/health", nil))
+
+	var resp HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode /health: %v", err)
+	}
+	return rec.Code, resp
+}
+
+func TestHealth_ReportsRegisteredChecks(t *testing.T) {
+	h := NewHandler(nil, nil)
+	h.Register("router", passing(map[string]interface{}{"routes": 3}), CheckOptions{})
+	h.Register("redis", passing(nil), CheckOptions{Critical: true})
+
+	code, resp := getHealth(t, h)
+	if code != http.StatusOK || resp.Status != "healthy" {
+		t.Fatalf("/health = %d %q, want 200 healthy", code, resp.Status)
+	}
+	router, ok := resp.Checks["router"]
+	if !ok || router.Status != "pass" || router.Details["routes"] != float64(3) {
+		t.Errorf("router check = %+v, want pass with details", router)
+	}
+	if !resp.Checks["redis"].Critical {
+		t.Error("redis check should be reported as critical")
+	}
+}
+
+func TestHealth_NonCriticalFailureDegrades(t *testing.T) {
+	h := NewHandler(nil, nil)
+	h.Register("metering", failing("sink unavailable"), CheckOptions{})
+
+	code, resp := getHealth(t, h)
+	if code != http.StatusOK || resp.Status != "degraded" {
+		t.Errorf("/health = %d %q, want 200 degraded", code, resp.Status)
+	}
+	if got := resp.Checks["metering"]; got.Status != "fail" || got.Message != "sink unavailable" {
+		t.Errorf("metering check = %+v, want fail with the error", got)
+	}
+
+	// Non-critical checks don't affect readiness
+	rec := httptest.NewRecorder()
+	h.Ready(rec, httptest.NewRequest("GET", "/ready", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("/ready = %d, want 200", rec.Code)
+	}
+}
+
+func TestHealth_CriticalFailure(t *testing.T) {
+	h := NewHandler(nil, nil)
+	h.Register("metering", failing("sink unavailable"), CheckOptions{})
+	h.Register("store", failing("connection refused"), CheckOptions{Critical: true})
+
+	code, resp := getHealth(t, h)
+	if code != http.StatusServiceUnavailable || resp.Status != "unhealthy" {
+		t.Errorf("/health = %d %q, want 503 unhealthy", code, resp.Status)
+	}
+
+	rec := httptest.NewRecorder()
+	h.Ready(rec, httptest.NewRequest("GET", "/ready", nil))
+	var ready map[string]string
+	json.NewDecoder(rec.Body).Decode(&ready)
+	if rec.Code != http.StatusServiceUnavailable || ready["reason"] != "store unavailable" {
+		t.Errorf("/ready = %d %v, want 503 with reason store unavailable", rec.Code, ready)
+	}
+}
+
+func TestHealth_CheckTimeout(t *testing.T) {
+	h := NewHandler(nil, nil)
+	release := make(chan struct{})
+	defer close(release)
+	h.Register("stuck", CheckerFunc(func(ctx context.Context) (map[string]interface{}, error) {
+		<-release // ignores ctx
+		return nil, nil
+	}), CheckOptions{Timeout: 20 * time.Millisecond})
+
+	start := time.Now()
+	_, resp := getHealth(t, h)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("/health took %s, want the check cut off at its timeout", elapsed)
+	}
+	if got := resp.Checks["stuck"]; got.Status != "fail" || got.Message != "timed out after 20ms" {
+		t.Errorf("stuck check = %+v, want a timeout failure", got)
+	}
+}
+
+func TestRegister_ReplacesByName(t *testing.T) {
+	h := NewHandler(nil, nil)
+	h.Register("redis", failing("down"), CheckOptions{})
+	h.Register("redis", passing(nil), CheckOptions{})
+
+	if names := h.Checks(); len(names) != 1 || names[0] != "redis" {
+		t.Errorf("Checks() = %v, want [redis]", names)
+	}
+	if _, resp := getHealth(t, h); resp.Status != "healthy" {
+		t.Errorf("status = %q, want the replacement check to run", resp.Status)
+	}
+}
+
+func TestReady_Draining(t *testing.T) {
+	h := NewHandler(nil, nil)
+	if !h.StartDrain() || h.StartDrain() {
+		t.Fatal("StartDrain() should report only the first call")
+	}
+
+	rec := httptest.NewRecorder()
+	h.Ready(rec, httptest.NewRequest("GET", "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/ready while draining = %d, want 503", rec.Code)
+	}
+}
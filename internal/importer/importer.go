@@ -0,0 +1,176 @@
+// Package importer converts the configuration of other gateways into a
+// Switchboard topology, easing migration (see `gateway import`).
+//
+// Two formats are read:
+//
+//   - Kong declarative configuration (kong.yml, as written by `deck dump`
+//     or `kong config db_export`): services, routes, upstreams and the
+//     plugins with a Switchboard equivalent.
+//   - A subset of nginx configuration: upstream blocks and server blocks
+//     whose locations proxy_pass to them.
+//
+// Anything without an equivalent (regex paths, consumers, unmapped
+// plugins, nginx variables...) is left out and reported as a warning, so
+// the converted topology should be reviewed before it is applied.
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// Supported input formats.
+const (
+	FormatKong  = "kong"
+	FormatNginx = "nginx"
+)
+
+// Result is a converted configuration.
+type Result struct {
+	Topology *database.Topology
+	// Warnings lists what could not be converted, or not exactly.
+	Warnings []string
+}
+
+// warnf records a warning.
+func (r *Result) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// DetectFormat guesses the format of a file from its name and content.
+func DetectFormat(filename string, data []byte) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yml", ".yaml", ".json":
+		return FormatKong, nil
+	case ".conf":
+		return FormatNginx, nil
+	}
+	if bytes.Contains(data, []byte("_format_version")) {
+		return FormatKong, nil
+	}
+	if bytes.Contains(data, []byte("proxy_pass")) || bytes.Contains(data, []byte("upstream ")) {
+		return FormatNginx, nil
+	}
+	return "", fmt.Errorf("cannot detect the format of %s: use -format %s or -format %s", filename, FormatKong, FormatNginx)
+}
+
+// Convert converts a configuration of the given format into a topology of
+// workspace. The topology is validated, but defaults are left to
+// database.Topology.SetDefaults.
+func Convert(format string, data []byte, workspace string) (*Result, error) {
+	result := &Result{Topology: &database.Topology{
+		Version:   database.TopologyVersion,
+		Workspace: database.WorkspaceName(workspace),
+		Services:  []database.TopologyService{},
+		Routes:    []database.TopologyRoute{},
+	}}
+
+	var err error
+	switch format {
+	case FormatKong:
+		err = convertKong(data, result)
+	case FormatNginx:
+		err = convertNginx(data, result)
+	default:
+		return nil, fmt.Errorf("unknown format %q (expected %s or %s)", format, FormatKong, FormatNginx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Topology.Routes) == 0 {
+		result.warnf("no routes were converted")
+	}
+	if err := result.Topology.Validate(); err != nil {
+		return nil, fmt.Errorf("converted topology is invalid: %w", err)
+	}
+	return result, nil
+}
+
+// prefixPaths converts a prefix match into router paths: the prefix itself
+// and everything below it.
+func prefixPaths(prefix string) []string {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix == "" {
+		return []string{"/", "/*"}
+	}
+	return []string{prefix, prefix + "/*"}
+}
+
+// appendPaths adds paths not already listed.
+func appendPaths(paths []string, add ...string) []string {
+	for _, p := range add {
+		found := false
+		for _, existing := range paths {
+			if existing == p {
+				found = true
+				break
+			}
+		}
+		if !found {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slug turns s into a lowercase, dash-separated name.
+func slug(s string) string {
+	return strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// names hands out unique names.
+type names map[string]bool
+
+// unique returns base, or base-2, base-3... if it is taken.
+func (n names) unique(base string) string {
+	if base == "" {
+		base = "unnamed"
+	}
+	name := base
+	for i := 2; n[name]; i++ {
+		name = base + "-" + strconv.Itoa(i)
+	}
+	n[name] = true
+	return name
+}
+
+// cleanTags keeps the tags Switchboard accepts, warning about the others.
+func cleanTags(result *Result, entity string, tags []string) []string {
+	var kept []string
+	for _, tag := range tags {
+		normalized := strings.ToLower(tag)
+		if database.ValidateTags([]string{normalized}) != nil {
+			result.warnf("%s: tag %q is not a valid tag and was dropped", entity, tag)
+			continue
+		}
+		if len(kept) == database.MaxTags {
+			result.warnf("%s: only the first %d tags were kept", entity, database.MaxTags)
+			break
+		}
+		kept = database.MergeTags(kept, []string{normalized})
+	}
+	return kept
+}
+
+// splitHostPort splits a host:port target.
+func splitHostPort(target string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port in %q", target)
+	}
+	return host, port, nil
+}
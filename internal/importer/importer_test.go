@@ -0,0 +1,300 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+const kongConfigYAML = `_format_version: "3.0"
+services:
+- name: orders
+  host: orders-upstream
+  port: 8080
+  protocol: http
+  connect_timeout: 2000
+  retries: 2
+  tags: [team-payments]
+  routes:
+  - name: orders-api
+    paths: [/orders, "~/orders/\\d+$"]
+    methods: [get, post]
+    hosts: [api.example.com]
+    tags: [Tier-1, "bad tag"]
+  plugins:
+  - name: rate-limiting
+    config:
+      minute: 100
+      hour: 1000
+      limit_by: ip
+- name: legacy
+  url: https://legacy.internal/api/
+routes:
+- name: legacy-root
+  service: {name: legacy}
+  strip_path: false
+  preserve_host: true
+upstreams:
+- name: orders-upstream
+  algorithm: consistent-hashing
+  hash_on: header
+  hash_on_header: X-Tenant
+  healthchecks:
+    active:
+      http_path: /ready
+  targets:
+  - target: 10.0.0.1:8080
+    weight: 50
+  - target: 10.0.0.2
+plugins:
+- name: cors
+  config:
+    origins: ["https://app.example.com"]
+    credentials: true
+- name: key-auth
+- name: cors
+  consumer: alice
+consumers:
+- username: alice
+`
+
+func TestConvertKong(t *testing.T) {
+	result, err := Convert(FormatKong, []byte(kongConfigYAML), "")
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	topology := result.Topology
+
+	wantServices := []database.TopologyService{
+		{
+			Name: "orders", Protocol: "http", Host: "10.0.0.1", Port: 8080,
+			ConnectTimeoutMs: 2000, Retries: 2,
+			LoadBalancerType: "consistent-hash", HashOn: "header", HashOnKey: "X-Tenant",
+			Tags: []string{"team-payments"}, Enabled: true,
+			Targets: []database.TopologyTarget{
+				{Target: "10.0.0.1:8080", Weight: 50, HealthCheckPath: "/ready", Enabled: true},
+				{Target: "10.0.0.2:8000", Weight: 100, HealthCheckPath: "/ready", Enabled: true},
+			},
+		},
+		{Name: "legacy", Protocol: "https", Host: "legacy.internal", Port: 443, Path: "/api", Enabled: true},
+	}
+	if !reflect.DeepEqual(topology.Services, wantServices) {
+		t.Errorf("Services =\n%+v\nwant\n%+v", topology.Services, wantServices)
+	}
+
+	wantRoutes := []database.TopologyRoute{
+		{
+			Name: "orders-api", Service: "orders", Hosts: []string{"api.example.com"},
+			Paths: []string{"/orders", "/orders/*"}, Methods: []string{"GET", "POST"},
+			StripPath: true, Tags: []string{"tier-1"}, Enabled: true,
+		},
+		{
+			Name: "legacy-root", Service: "legacy", Paths: []string{"/", "/*"},
+			PreserveHost: true, Enabled: true,
+		},
+	}
+	if !reflect.DeepEqual(topology.Routes, wantRoutes) {
+		t.Errorf("Routes =\n%+v\nwant\n%+v", topology.Routes, wantRoutes)
+	}
+
+	wantPlugins := []database.TopologyPlugin{
+		{
+			Name: "rate-limit", Scope: database.PluginScopeService, Service: "orders", Enabled: true,
+			Config: map[string]interface{}{"algorithm": "sliding-window", "limit": 100, "window": "1m", "identifier": "ip"},
+		},
+		{
+			Name: "cors", Scope: database.PluginScopeGlobal, Enabled: true,
+			Config: map[string]interface{}{"allowed_origins": []interface{}{"https://app.example.com"}, "allow_credentials": true},
+		},
+	}
+	if !reflect.DeepEqual(topology.Plugins, wantPlugins) {
+		t.Errorf("Plugins =\n%+v\nwant\n%+v", topology.Plugins, wantPlugins)
+	}
+
+	for _, want := range []string{"regex path", `"bad tag"`, "only the 1m limit", `"key-auth" has no Switchboard equivalent`, "consumer-scoped", "1 consumers"} {
+		if !containsWarning(result.Warnings, want) {
+			t.Errorf("missing warning containing %q in %q", want, result.Warnings)
+		}
+	}
+}
+
+func TestConvertKong_JSON(t *testing.T) {
+	config := `{"_format_version": "3.0", "services": [{"name": "a", "url": "http://a:81", "routes": [{"paths": ["/a"]}, {"paths": ["/b"]}]}]}`
+	result, err := Convert(FormatKong, []byte(config), "staging")
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if result.Topology.Workspace != "staging" {
+		t.Errorf("Workspace = %q", result.Topology.Workspace)
+	}
+	// Unnamed routes get unique names
+	if len(result.Topology.Routes) != 2 || result.Topology.Routes[0].Name != "a" || result.Topology.Routes[1].Name != "a-2" {
+		t.Errorf("Routes = %+v", result.Topology.Routes)
+	}
+}
+
+const nginxConfig = `
+http {
+    limit_req_zone $binary_remote_addr zone=api:10m rate=20r/s;
+    proxy_read_timeout 30s;
+
+    upstream orders_backend {
+        least_conn;
+        server 10.0.0.1:8080 weight=2;
+        server 10.0.0.2:8080;
+        server 10.0.0.3:8080 backup;
+    }
+
+    server {
+        listen 80;
+        server_name api.example.com .example.org;
+        proxy_set_header Host $host;
+
+        location /orders/ {
+            proxy_pass http://orders_backend/v1/;
+            limit_req zone=api burst=10;
+            limit_except GET { deny all; }
+        }
+
+        location = /health {
+            proxy_pass http://orders_backend;
+            proxy_connect_timeout 1500ms;
+        }
+
+        location ~ ^/users/\d+ {
+            proxy_pass http://users:9000;
+        }
+
+        location /static/ {
+            root /var/www;
+        }
+
+        location /users {
+            proxy_pass http://users.internal:9000;
+            proxy_read_timeout 1m30s;
+            proxy_next_upstream_tries 3;
+        }
+    }
+}
+`
+
+func TestConvertNginx(t *testing.T) {
+	result, err := Convert(FormatNginx, []byte(nginxConfig), "")
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	topology := result.Topology
+
+	targets := []database.TopologyTarget{
+		{Target: "10.0.0.1:8080", Weight: 200, Enabled: true},
+		{Target: "10.0.0.2:8080", Weight: 100, Enabled: true},
+		{Target: "10.0.0.3:8080", Weight: 100, Enabled: false},
+	}
+	wantServices := []database.TopologyService{
+		{
+			Name: "orders_backend", Protocol: "http", Host: "10.0.0.1", Port: 8080, Path: "/v1",
+			ReadTimeoutMs: 30000, LoadBalancerType: "least-connections", Enabled: true, Targets: targets,
+		},
+		{
+			Name: "orders_backend-2", Protocol: "http", Host: "10.0.0.1", Port: 8080,
+			ConnectTimeoutMs: 1500, ReadTimeoutMs: 30000, LoadBalancerType: "least-connections", Enabled: true, Targets: targets,
+		},
+		{
+			Name: "users-internal", Protocol: "http", Host: "users.internal", Port: 9000,
+			ReadTimeoutMs: 90000, Retries: 2, Enabled: true,
+		},
+	}
+	if !reflect.DeepEqual(topology.Services, wantServices) {
+		t.Errorf("Services =\n%+v\nwant\n%+v", topology.Services, wantServices)
+	}
+
+	hosts := []string{"api.example.com", "example.org", "*.example.org"}
+	wantRoutes := []database.TopologyRoute{
+		{
+			Name: "api-example-com-orders", Service: "orders_backend", Hosts: hosts,
+			Paths: []string{"/orders", "/orders/*"}, Methods: []string{"GET", "HEAD"},
+			StripPath: true, PreserveHost: true, Enabled: true,
+		},
+		{
+			Name: "api-example-com-health", Service: "orders_backend-2", Hosts: hosts,
+			Paths: []string{"/health"}, PreserveHost: true, Enabled: true,
+		},
+		{
+			Name: "api-example-com-users", Service: "users-internal", Hosts: hosts,
+			Paths: []string{"/users", "/users/*"}, PreserveHost: true, Enabled: true,
+		},
+	}
+	if !reflect.DeepEqual(topology.Routes, wantRoutes) {
+		t.Errorf("Routes =\n%+v\nwant\n%+v", topology.Routes, wantRoutes)
+	}
+
+	wantPlugins := []database.TopologyPlugin{{
+		Name: "rate-limit", Scope: database.PluginScopeRoute, Route: "api-example-com-orders", Enabled: true,
+		Config: map[string]interface{}{"algorithm": "sliding-window", "limit": 20, "window": "1s", "identifier": "ip"},
+	}}
+	if !reflect.DeepEqual(topology.Plugins, wantPlugins) {
+		t.Errorf("Plugins =\n%+v\nwant\n%+v", topology.Plugins, wantPlugins)
+	}
+
+	for _, want := range []string{"backup server", "burst=10", "regex location", "/static/ has no proxy_pass", "root is not converted"} {
+		if !containsWarning(result.Warnings, want) {
+			t.Errorf("missing warning containing %q in %q", want, result.Warnings)
+		}
+	}
+}
+
+func TestParseNginx_Errors(t *testing.T) {
+	for _, config := range []string{
+		"server { listen 80;",
+		"server { listen 80 }",
+		"}",
+		`server_name "unterminated;`,
+	} {
+		if _, err := parseNginx(config); err == nil {
+			t.Errorf("parseNginx(%q) should fail", config)
+		}
+	}
+}
+
+func TestParseNginxTime(t *testing.T) {
+	tests := map[string]string{"60": "1m0s", "30s": "30s", "500ms": "500ms", "1m30s": "1m30s", "1h": "1h0m0s"}
+	for in, want := range tests {
+		got, err := parseNginxTime(in)
+		if err != nil || got.String() != want {
+			t.Errorf("parseNginxTime(%q) = %v, %v; want %s", in, got, err, want)
+		}
+	}
+	if _, err := parseNginxTime("soon"); err == nil {
+		t.Error("parseNginxTime should reject invalid times")
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name, data, want string
+	}{
+		{"kong.yml", "", FormatKong},
+		{"conf.d/api.conf", "", FormatNginx},
+		{"export", `_format_version: "3.0"`, FormatKong},
+		{"sites-enabled/api", "location / { proxy_pass http://a; }", FormatNginx},
+	}
+	for _, tt := range tests {
+		if got, err := DetectFormat(tt.name, []byte(tt.data)); err != nil || got != tt.want {
+			t.Errorf("DetectFormat(%q) = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := DetectFormat("notes.txt", []byte("hello")); err == nil {
+		t.Error("DetectFormat should fail on unknown content")
+	}
+}
+
+func containsWarning(warnings []string, substr string) bool {
+	for _, w := range warnings {
+		if strings.Contains(w, substr) {
+			return true
+		}
+	}
+	return false
+}
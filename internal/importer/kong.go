@@ -0,0 +1,536 @@
+// Package importer - Kong declarative configuration
+//
+// Mapping:
+//
+//   - services become services; url or protocol/host/port/path, timeouts,
+//     retries, tags and enabled carry over
+//   - an upstream named by a service's host becomes that service's targets
+//     and load balancer (round-robin, least-connections, consistent-hashing
+//     on ip, header or cookie)
+//   - routes (nested or top-level) become routes; Kong paths are prefixes,
+//     so /orders becomes /orders and /orders/*; strip_path defaults to true
+//     as in Kong
+//   - rate-limiting and cors plugins become rate-limit and cors
+//
+// Consumers, regex paths and other plugins are reported as warnings.
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// kongConfig is a Kong declarative configuration (_format_version 1.1-3.0).
+type kongConfig struct {
+	FormatVersion interface{}       `json:"_format_version"`
+	Services      []kongService     `json:"services"`
+	Routes        []kongRoute       `json:"routes"`
+	Upstreams     []kongUpstream    `json:"upstreams"`
+	Plugins       []kongPlugin      `json:"plugins"`
+	Consumers     []json.RawMessage `json:"consumers"`
+}
+
+type kongService struct {
+	ID             string       `json:"id"`
+	Name           string       `json:"name"`
+	URL            string       `json:"url"`
+	Protocol       string       `json:"protocol"`
+	Host           string       `json:"host"`
+	Port           int          `json:"port"`
+	Path           string       `json:"path"`
+	ConnectTimeout int          `json:"connect_timeout"`
+	ReadTimeout    int          `json:"read_timeout"`
+	WriteTimeout   int          `json:"write_timeout"`
+	Retries        *int         `json:"retries"`
+	Enabled        *bool        `json:"enabled"`
+	Tags           []string     `json:"tags"`
+	Routes         []kongRoute  `json:"routes"`
+	Plugins        []kongPlugin `json:"plugins"`
+}
+
+type kongRoute struct {
+	ID           string              `json:"id"`
+	Name         string              `json:"name"`
+	Service      kongRef             `json:"service"`
+	Paths        []string            `json:"paths"`
+	Methods      []string            `json:"methods"`
+	Hosts        []string            `json:"hosts"`
+	Headers      map[string][]string `json:"headers"`
+	Protocols    []string            `json:"protocols"`
+	StripPath    *bool               `json:"strip_path"`
+	PreserveHost bool                `json:"preserve_host"`
+	Tags         []string            `json:"tags"`
+	Plugins      []kongPlugin        `json:"plugins"`
+}
+
+type kongUpstream struct {
+	Name         string       `json:"name"`
+	Algorithm    string       `json:"algorithm"`
+	HashOn       string       `json:"hash_on"`
+	HashOnHeader string       `json:"hash_on_header"`
+	HashOnCookie string       `json:"hash_on_cookie"`
+	Targets      []kongTarget `json:"targets"`
+	Healthchecks struct {
+		Active struct {
+			HTTPPath string `json:"http_path"`
+		} `json:"active"`
+	} `json:"healthchecks"`
+}
+
+type kongTarget struct {
+	Target string `json:"target"`
+	Weight *int   `json:"weight"`
+}
+
+type kongPlugin struct {
+	Name     string                 `json:"name"`
+	Config   map[string]interface{} `json:"config"`
+	Enabled  *bool                  `json:"enabled"`
+	Service  kongRef                `json:"service"`
+	Route    kongRef                `json:"route"`
+	Consumer kongRef                `json:"consumer"`
+	Tags     []string               `json:"tags"`
+}
+
+// kongRef references an entity by name or ID, either as a string or as
+// {"name": ...} / {"id": ...}.
+type kongRef string
+
+func (r *kongRef) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*r = kongRef(s)
+		return nil
+	}
+	var obj struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("invalid reference %s", data)
+	}
+	if obj.Name != "" {
+		*r = kongRef(obj.Name)
+	} else {
+		*r = kongRef(obj.ID)
+	}
+	return nil
+}
+
+// kongDefaultPorts are the ports implied by a Kong service protocol.
+var kongDefaultPorts = map[string]int{"http": 80, "https": 443, "grpc": 80, "grpcs": 443}
+
+// convertKong converts a Kong declarative configuration (YAML or JSON).
+func convertKong(data []byte, result *Result) error {
+	var doc interface{}
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("invalid Kong configuration: %w", err)
+		}
+	} else {
+		var err error
+		if doc, err = parseYAML(data); err != nil {
+			return fmt.Errorf("invalid Kong configuration: %w", err)
+		}
+	}
+
+	// Decode the generic document into the typed configuration
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("invalid Kong configuration: %w", err)
+	}
+	var cfg kongConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return fmt.Errorf("invalid Kong configuration: %w", err)
+	}
+	if cfg.FormatVersion == nil {
+		result.warnf("no _format_version: is this a Kong declarative configuration?")
+	}
+
+	k := &kongConverter{
+		result:       result,
+		upstreams:    make(map[string]kongUpstream),
+		services:     make(map[string]string),
+		routes:       make(map[string]string),
+		serviceNames: names{},
+		routeNames:   names{},
+	}
+	for _, u := range cfg.Upstreams {
+		k.upstreams[u.Name] = u
+	}
+
+	for _, svc := range cfg.Services {
+		name, ok := k.service(svc)
+		if !ok {
+			continue
+		}
+		for _, rt := range svc.Routes {
+			k.route(rt, name)
+		}
+		for _, p := range svc.Plugins {
+			k.plugin(p, database.PluginScopeService, name, "")
+		}
+	}
+
+	for _, rt := range cfg.Routes {
+		service, ok := k.services[string(rt.Service)]
+		if !ok {
+			result.warnf("route %q: service %q was not converted, route skipped", rt.Name, rt.Service)
+			continue
+		}
+		k.route(rt, service)
+	}
+
+	for _, p := range cfg.Plugins {
+		switch {
+		case p.Route != "":
+			route, ok := k.routes[string(p.Route)]
+			if !ok {
+				result.warnf("plugin %q: route %q was not converted, plugin skipped", p.Name, p.Route)
+				continue
+			}
+			k.plugin(p, database.PluginScopeRoute, "", route)
+		case p.Service != "":
+			service, ok := k.services[string(p.Service)]
+			if !ok {
+				result.warnf("plugin %q: service %q was not converted, plugin skipped", p.Name, p.Service)
+				continue
+			}
+			k.plugin(p, database.PluginScopeService, service, "")
+		default:
+			k.plugin(p, database.PluginScopeGlobal, "", "")
+		}
+	}
+
+	if len(cfg.Consumers) > 0 {
+		result.warnf("%d consumers were not converted: create them with the admin API", len(cfg.Consumers))
+	}
+	return nil
+}
+
+// kongConverter holds the state of a Kong conversion.
+type kongConverter struct {
+	result    *Result
+	upstreams map[string]kongUpstream
+	// services and routes map Kong names and IDs to topology names
+	services     map[string]string
+	routes       map[string]string
+	serviceNames names
+	routeNames   names
+}
+
+// service converts a Kong service, returning its topology name.
+func (k *kongConverter) service(svc kongService) (string, bool) {
+	label := svc.Name
+	if label == "" {
+		label = svc.Host
+	}
+
+	protocol, host, port, path := svc.Protocol, svc.Host, svc.Port, svc.Path
+	if svc.URL != "" {
+		u, err := url.Parse(svc.URL)
+		if err != nil || u.Host == "" {
+			k.result.warnf("service %q: invalid url %q, service skipped", label, svc.URL)
+			return "", false
+		}
+		protocol, host, path = u.Scheme, u.Hostname(), u.Path
+		port, _ = strconv.Atoi(u.Port())
+		if label == "" {
+			label = host
+		}
+	}
+	if protocol == "" {
+		protocol = "http"
+	}
+
+	switch protocol {
+	case "http", "https", "grpc":
+	case "grpcs":
+		k.result.warnf("service %q: grpcs upstreams are proxied as grpc", label)
+		protocol = "grpc"
+	default:
+		k.result.warnf("service %q: protocol %q is not supported, service and its routes skipped", label, protocol)
+		return "", false
+	}
+	if port == 0 {
+		port = kongDefaultPorts[protocol]
+	}
+	if host == "" {
+		k.result.warnf("service %q: no host, service skipped", label)
+		return "", false
+	}
+
+	s := database.TopologyService{
+		Name:             k.serviceNames.unique(nameOr(svc.Name, host)),
+		Protocol:         protocol,
+		Host:             host,
+		Port:             port,
+		Path:             strings.TrimRight(path, "/"),
+		ConnectTimeoutMs: svc.ConnectTimeout,
+		ReadTimeoutMs:    svc.ReadTimeout,
+		WriteTimeoutMs:   svc.WriteTimeout,
+		Tags:             cleanTags(k.result, "service "+label, svc.Tags),
+		Enabled:          svc.Enabled == nil || *svc.Enabled,
+	}
+	if svc.Retries != nil {
+		s.Retries = *svc.Retries
+	}
+	if u, ok := k.upstreams[host]; ok {
+		k.upstream(&s, u)
+	}
+
+	k.result.Topology.Services = append(k.result.Topology.Services, s)
+	for _, ref := range []string{svc.Name, svc.ID} {
+		if ref != "" {
+			k.services[ref] = s.Name
+		}
+	}
+	return s.Name, true
+}
+
+// upstream applies a Kong upstream to the service balancing over it.
+func (k *kongConverter) upstream(s *database.TopologyService, u kongUpstream) {
+	weighted := false
+	for _, t := range u.Targets {
+		target := t.Target
+		if _, _, err := splitHostPort(target); err != nil {
+			target += ":8000" // Kong's default target port
+		}
+		weight := 100
+		if t.Weight != nil {
+			weight = *t.Weight
+		}
+		if weight != 100 {
+			weighted = true
+		}
+		s.Targets = append(s.Targets, database.TopologyTarget{
+			Target:          target,
+			Weight:          weight,
+			HealthCheckPath: u.Healthchecks.Active.HTTPPath,
+			Enabled:         weight > 0,
+		})
+	}
+	if len(s.Targets) > 0 {
+		// The upstream name isn't resolvable; point the service at a target
+		if host, port, err := splitHostPort(s.Targets[0].Target); err == nil {
+			s.Host, s.Port = host, port
+		}
+	} else {
+		k.result.warnf("service %q: upstream %q has no targets", s.Name, u.Name)
+	}
+
+	switch u.Algorithm {
+	case "", "round-robin":
+		if weighted {
+			s.LoadBalancerType = "weighted"
+		}
+	case "least-connections":
+		s.LoadBalancerType = "least-connections"
+	case "consistent-hashing":
+		s.LoadBalancerType = "consistent-hash"
+		switch u.HashOn {
+		case "", "none", "ip":
+			s.HashOn = "ip"
+		case "header":
+			s.HashOn, s.HashOnKey = "header", u.HashOnHeader
+		case "cookie":
+			s.HashOn, s.HashOnKey = "cookie", u.HashOnCookie
+		default:
+			k.result.warnf("service %q: hash_on %q is not supported, hashing on the client IP", s.Name, u.HashOn)
+			s.HashOn = "ip"
+		}
+	default:
+		k.result.warnf("service %q: algorithm %q is not supported, using round-robin", s.Name, u.Algorithm)
+	}
+}
+
+// route converts a Kong route of the named service.
+func (k *kongConverter) route(rt kongRoute, service string) {
+	label := rt.Name
+	if label == "" {
+		label = service + " route"
+	}
+
+	var paths []string
+	for _, p := range rt.Paths {
+		if strings.HasPrefix(p, "~") {
+			k.result.warnf("route %q: regex path %q is not supported and was skipped", label, p)
+			continue
+		}
+		paths = appendPaths(paths, prefixPaths(p)...)
+	}
+	if len(rt.Paths) == 0 {
+		paths = prefixPaths("/")
+	} else if len(paths) == 0 {
+		k.result.warnf("route %q: no supported paths, route skipped", label)
+		return
+	}
+	if len(rt.Headers) > 0 {
+		k.result.warnf("route %q: header matching is not supported and was ignored", label)
+	}
+	for _, protocol := range rt.Protocols {
+		if protocol != "http" && protocol != "https" && protocol != "grpc" && protocol != "grpcs" {
+			k.result.warnf("route %q: protocol %q is not supported and was ignored", label, protocol)
+		}
+	}
+
+	var methods []string
+	for _, m := range rt.Methods {
+		methods = append(methods, strings.ToUpper(m))
+	}
+
+	r := database.TopologyRoute{
+		Name:         k.routeNames.unique(nameOr(rt.Name, service)),
+		Service:      service,
+		Hosts:        rt.Hosts,
+		Paths:        paths,
+		Methods:      methods,
+		StripPath:    rt.StripPath == nil || *rt.StripPath,
+		PreserveHost: rt.PreserveHost,
+		Tags:         cleanTags(k.result, "route "+label, rt.Tags),
+		Enabled:      true,
+	}
+	k.result.Topology.Routes = append(k.result.Topology.Routes, r)
+	for _, ref := range []string{rt.Name, rt.ID} {
+		if ref != "" {
+			k.routes[ref] = r.Name
+		}
+	}
+
+	for _, p := range rt.Plugins {
+		k.plugin(p, database.PluginScopeRoute, "", r.Name)
+	}
+}
+
+// plugin converts a Kong plugin attached to the given scope.
+func (k *kongConverter) plugin(p kongPlugin, scope, service, route string) {
+	if p.Consumer != "" {
+		k.result.warnf("plugin %q: consumer-scoped plugins are not converted", p.Name)
+		return
+	}
+
+	var name string
+	var config map[string]interface{}
+	switch p.Name {
+	case "rate-limiting":
+		name, config = "rate-limit", k.rateLimitConfig(p.Config)
+	case "cors":
+		name, config = "cors", kongCORSConfig(p.Config)
+	default:
+		k.result.warnf("plugin %q has no Switchboard equivalent and was skipped", p.Name)
+		return
+	}
+	if config == nil {
+		return
+	}
+
+	for _, existing := range k.result.Topology.Plugins {
+		if existing.Name == name && existing.Scope == scope && existing.Service == service && existing.Route == route {
+			k.result.warnf("plugin %q: only one %s plugin per scope is supported, duplicate skipped", p.Name, name)
+			return
+		}
+	}
+
+	k.result.Topology.Plugins = append(k.result.Topology.Plugins, database.TopologyPlugin{
+		Name:    name,
+		Scope:   scope,
+		Service: service,
+		Route:   route,
+		Config:  config,
+		Tags:    cleanTags(k.result, "plugin "+p.Name, p.Tags),
+		Enabled: p.Enabled == nil || *p.Enabled,
+	})
+}
+
+// kongWindows are the rate-limiting windows, shortest first.
+var kongWindows = []struct{ field, window string }{
+	{"second", "1s"}, {"minute", "1m"}, {"hour", "1h"}, {"day", "24h"}, {"month", "720h"}, {"year", "8760h"},
+}
+
+// rateLimitConfig converts a rate-limiting config. Switchboard enforces
+// one window per plugin, so the shortest is kept.
+func (k *kongConverter) rateLimitConfig(cfg map[string]interface{}) map[string]interface{} {
+	config := map[string]interface{}{"algorithm": "sliding-window"}
+
+	var dropped []string
+	for _, w := range kongWindows {
+		limit, ok := cfg[w.field].(float64)
+		if !ok || limit <= 0 {
+			continue
+		}
+		if _, set := config["limit"]; set {
+			dropped = append(dropped, w.field)
+			continue
+		}
+		config["limit"] = int(limit)
+		config["window"] = w.window
+	}
+	if _, set := config["limit"]; !set {
+		k.result.warnf("plugin \"rate-limiting\": no limit configured, plugin skipped")
+		return nil
+	}
+	if len(dropped) > 0 {
+		k.result.warnf("plugin \"rate-limiting\": only the %s limit was kept (dropped: %s)",
+			config["window"], strings.Join(dropped, ", "))
+	}
+
+	switch limitBy, _ := cfg["limit_by"].(string); limitBy {
+	case "":
+	case "consumer":
+		config["identifier"] = "consumer_id"
+	case "credential":
+		config["identifier"] = "api_key"
+	case "ip":
+		config["identifier"] = "ip"
+	default:
+		k.result.warnf("plugin \"rate-limiting\": limit_by %q is not supported, limiting by consumer, API key or IP", limitBy)
+	}
+
+	if policy, _ := cfg["policy"].(string); policy == "redis" {
+		if host, _ := cfg["redis_host"].(string); host != "" {
+			port := 6379
+			if p, ok := cfg["redis_port"].(float64); ok {
+				port = int(p)
+			}
+			db := 0
+			if d, ok := cfg["redis_database"].(float64); ok {
+				db = int(d)
+			}
+			config["redis_url"] = fmt.Sprintf("redis://%s:%d/%d", host, port, db)
+		}
+	}
+	return config
+}
+
+// kongCORSConfig converts a cors config.
+func kongCORSConfig(cfg map[string]interface{}) map[string]interface{} {
+	config := map[string]interface{}{}
+	fields := map[string]string{
+		"origins":         "allowed_origins",
+		"methods":         "allowed_methods",
+		"headers":         "allowed_headers",
+		"exposed_headers": "exposed_headers",
+		"credentials":     "allow_credentials",
+		"max_age":         "max_age",
+	}
+	for kongField, field := range fields {
+		if v, ok := cfg[kongField]; ok && v != nil {
+			config[field] = v
+		}
+	}
+	return config
+}
+
+// nameOr returns name, or a name derived from fallback if it is empty.
+func nameOr(name, fallback string) string {
+	if name != "" {
+		return name
+	}
+	return slug(fallback)
+}
@@ -0,0 +1,700 @@
+// Package importer - nginx configuration subset
+//
+// Supported:
+//
+//   - upstream blocks: server (weight=, down, backup), least_conn, ip_hash
+//     and hash $remote_addr / $http_<name> / $cookie_<name>
+//   - server blocks: server_name becomes the routes' hosts
+//   - location blocks with proxy_pass (or grpc_pass) to an upstream or a
+//     URL: prefix and ^~ locations match the prefix, = locations the exact
+//     path; a URI on proxy_pass strips the location prefix and becomes the
+//     service path, as in nginx
+//   - proxy_connect/read/send_timeout, proxy_next_upstream_tries,
+//     proxy_set_header Host $host, limit_except and limit_req (with its
+//     limit_req_zone), inherited from the http and server levels
+//
+// Regex locations, variables in proxy_pass, include and other directives
+// are reported as warnings.
+package importer
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// directive is a parsed nginx directive and its block, if any.
+type directive struct {
+	name  string
+	args  []string
+	block []*directive
+	line  int
+}
+
+// nginxInherited are the directives a location inherits from the server
+// and http levels. As in nginx, a level defining one of them replaces the
+// inherited values of that directive.
+var nginxInherited = map[string]bool{
+	"proxy_connect_timeout":     true,
+	"proxy_read_timeout":        true,
+	"proxy_send_timeout":        true,
+	"proxy_next_upstream_tries": true,
+	"proxy_set_header":          true,
+	"limit_req":                 true,
+}
+
+// nginxScope holds the inherited directives of a level.
+type nginxScope map[string][]*directive
+
+// child returns the scope of a block nested in s.
+func (s nginxScope) child(block []*directive) nginxScope {
+	c := make(nginxScope, len(s))
+	for name, ds := range s {
+		c[name] = ds
+	}
+	seen := make(map[string]bool)
+	for _, d := range block {
+		if !nginxInherited[d.name] {
+			continue
+		}
+		if !seen[d.name] {
+			seen[d.name] = true
+			c[d.name] = nil
+		}
+		c[d.name] = append(c[d.name], d)
+	}
+	return c
+}
+
+// last returns the innermost value of a single-valued directive.
+func (s nginxScope) last(name string) *directive {
+	if ds := s[name]; len(ds) > 0 {
+		return ds[len(ds)-1]
+	}
+	return nil
+}
+
+// nginxUpstream is a converted upstream block.
+type nginxUpstream struct {
+	targets   []database.TopologyTarget
+	lbType    string
+	hashOn    string
+	hashOnKey string
+}
+
+// nginxZone is a limit_req_zone.
+type nginxZone struct {
+	limit      int
+	window     string
+	identifier string
+}
+
+// nginxConverter holds the state of an nginx conversion.
+type nginxConverter struct {
+	result    *Result
+	upstreams map[string]*nginxUpstream
+	zones     map[string]nginxZone
+	// services maps a proxy_pass destination to its service's index
+	services     map[string]int
+	serviceNames names
+	routeNames   names
+}
+
+// convertNginx converts the server and upstream blocks of an nginx
+// configuration (a full nginx.conf or a conf.d snippet).
+func convertNginx(data []byte, result *Result) error {
+	directives, err := parseNginx(string(data))
+	if err != nil {
+		return err
+	}
+
+	n := &nginxConverter{
+		result:       result,
+		upstreams:    make(map[string]*nginxUpstream),
+		zones:        make(map[string]nginxZone),
+		services:     make(map[string]int),
+		serviceNames: names{},
+		routeNames:   names{},
+	}
+
+	// Upstreams and zones first: servers may precede them
+	var servers []*directive
+	var scopes []nginxScope
+	var collect func(block []*directive, scope nginxScope)
+	collect = func(block []*directive, scope nginxScope) {
+		for _, d := range block {
+			switch d.name {
+			case "http":
+				collect(d.block, scope.child(d.block))
+			case "upstream":
+				n.upstream(d)
+			case "limit_req_zone":
+				n.zone(d)
+			case "server":
+				if d.block != nil {
+					servers = append(servers, d)
+					scopes = append(scopes, scope)
+				}
+			case "include":
+				result.warnf("line %d: include %s was not followed; convert the included files separately", d.line, strings.Join(d.args, " "))
+			case "stream", "mail":
+				result.warnf("line %d: %s blocks are not supported and were skipped", d.line, d.name)
+			}
+		}
+	}
+	collect(directives, nginxScope{})
+
+	for i, server := range servers {
+		n.server(server, scopes[i].child(server.block))
+	}
+	return nil
+}
+
+// upstream converts an upstream block.
+func (n *nginxConverter) upstream(d *directive) {
+	if len(d.args) != 1 {
+		n.result.warnf("line %d: upstream without a name was skipped", d.line)
+		return
+	}
+	u := &nginxUpstream{}
+	weighted := false
+
+	for _, s := range d.block {
+		switch s.name {
+		case "server":
+			if len(s.args) == 0 {
+				continue
+			}
+			addr := s.args[0]
+			if strings.HasPrefix(addr, "unix:") {
+				n.result.warnf("line %d: upstream %s: unix socket %s is not supported", s.line, d.args[0], addr)
+				continue
+			}
+			if _, _, err := splitHostPort(addr); err != nil {
+				addr += ":80"
+			}
+			t := database.TopologyTarget{Target: addr, Weight: 100, Enabled: true}
+			for _, param := range s.args[1:] {
+				switch {
+				case strings.HasPrefix(param, "weight="):
+					w, err := strconv.Atoi(strings.TrimPrefix(param, "weight="))
+					if err != nil || w <= 0 {
+						n.result.warnf("line %d: invalid %s", s.line, param)
+						continue
+					}
+					// nginx weights default to 1, Switchboard's to 100
+					t.Weight = w * 100
+					weighted = weighted || w != 1
+				case param == "down":
+					t.Enabled = false
+				case param == "backup":
+					n.result.warnf("line %d: upstream %s: backup server %s was added disabled", s.line, d.args[0], addr)
+					t.Enabled = false
+				}
+			}
+			u.targets = append(u.targets, t)
+		case "least_conn":
+			u.lbType = "least-connections"
+		case "ip_hash":
+			u.lbType, u.hashOn = "ip-hash", "ip"
+		case "hash":
+			u.lbType = "consistent-hash"
+			key := ""
+			if len(s.args) > 0 {
+				key = s.args[0]
+			}
+			switch {
+			case key == "$remote_addr" || key == "$binary_remote_addr":
+				u.hashOn = "ip"
+			case strings.HasPrefix(key, "$http_"):
+				u.hashOn, u.hashOnKey = "header", strings.ReplaceAll(strings.TrimPrefix(key, "$http_"), "_", "-")
+			case strings.HasPrefix(key, "$cookie_"):
+				u.hashOn, u.hashOnKey = "cookie", strings.TrimPrefix(key, "$cookie_")
+			default:
+				n.result.warnf("line %d: upstream %s: hash key %q is not supported, hashing on the client IP", s.line, d.args[0], key)
+				u.hashOn = "ip"
+			}
+		case "keepalive", "keepalive_timeout", "keepalive_requests", "keepalive_time", "zone":
+		default:
+			n.result.warnf("line %d: upstream %s: %s is not supported and was ignored", s.line, d.args[0], s.name)
+		}
+	}
+	if u.lbType == "" && weighted {
+		u.lbType = "weighted"
+	}
+	if len(u.targets) == 0 {
+		n.result.warnf("line %d: upstream %s has no servers", d.line, d.args[0])
+	}
+	n.upstreams[d.args[0]] = u
+}
+
+// zone records a limit_req_zone (key zone=name:size rate=Nr/s).
+func (n *nginxConverter) zone(d *directive) {
+	if len(d.args) == 0 {
+		n.result.warnf("line %d: invalid limit_req_zone was skipped", d.line)
+		return
+	}
+	var name, rate string
+	for _, arg := range d.args[1:] {
+		switch {
+		case strings.HasPrefix(arg, "zone="):
+			name, _, _ = strings.Cut(strings.TrimPrefix(arg, "zone="), ":")
+		case strings.HasPrefix(arg, "rate="):
+			rate = strings.TrimPrefix(arg, "rate=")
+		}
+	}
+
+	z := nginxZone{}
+	count, unit, ok := strings.Cut(rate, "r/")
+	limit, err := strconv.Atoi(count)
+	if name == "" || !ok || err != nil || limit <= 0 || (unit != "s" && unit != "m") {
+		n.result.warnf("line %d: invalid limit_req_zone was skipped", d.line)
+		return
+	}
+	z.limit, z.window = limit, "1"+unit
+
+	if key := d.args[0]; key == "$binary_remote_addr" || key == "$remote_addr" {
+		z.identifier = "ip"
+	} else {
+		n.result.warnf("line %d: limit_req_zone %s: key %s is not supported, limiting by consumer, API key or IP", d.line, name, d.args[0])
+	}
+	n.zones[name] = z
+}
+
+// server converts the locations of a server block.
+func (n *nginxConverter) server(d *directive, scope nginxScope) {
+	var hosts []string
+	for _, s := range d.block {
+		if s.name != "server_name" {
+			continue
+		}
+		for _, name := range s.args {
+			switch {
+			case name == "_" || name == "":
+			case strings.HasPrefix(name, "~"):
+				n.result.warnf("line %d: regex server_name %s is not supported", s.line, name)
+			case strings.HasPrefix(name, "."):
+				hosts = append(hosts, name[1:], "*"+name)
+			case strings.HasSuffix(name, ".*"):
+				n.result.warnf("line %d: server_name %s: trailing wildcards are not supported", s.line, name)
+			default:
+				hosts = append(hosts, name)
+			}
+		}
+	}
+
+	for _, loc := range d.block {
+		if loc.name == "location" {
+			n.location(loc, scope.child(loc.block), hosts)
+		}
+	}
+}
+
+// location converts a location block, and its nested locations, into a
+// route.
+func (n *nginxConverter) location(d *directive, scope nginxScope, hosts []string) {
+	for _, nested := range d.block {
+		if nested.name == "location" {
+			n.location(nested, scope.child(nested.block), hosts)
+		}
+	}
+
+	var modifier, path string
+	switch len(d.args) {
+	case 1:
+		path = d.args[0]
+	case 2:
+		modifier, path = d.args[0], d.args[1]
+	default:
+		n.result.warnf("line %d: invalid location was skipped", d.line)
+		return
+	}
+	label := strings.TrimSpace(modifier + " " + path)
+	if strings.HasPrefix(path, "@") {
+		n.result.warnf("line %d: named location %s was skipped", d.line, path)
+		return
+	}
+
+	var pass *directive
+	protocol := ""
+	var methods []string
+	for _, s := range d.block {
+		switch s.name {
+		case "proxy_pass":
+			pass = s
+		case "grpc_pass":
+			pass, protocol = s, "grpc"
+		case "limit_except":
+			for _, m := range s.args {
+				methods = appendPaths(methods, strings.ToUpper(m))
+				if strings.EqualFold(m, "GET") {
+					methods = appendPaths(methods, "HEAD")
+				}
+			}
+		case "location", "limit_req":
+		default:
+			if !strings.HasPrefix(s.name, "proxy_") && !strings.HasPrefix(s.name, "grpc_") {
+				n.result.warnf("line %d: location %s: %s is not converted", s.line, label, s.name)
+			}
+		}
+	}
+	if pass == nil {
+		n.result.warnf("line %d: location %s has no proxy_pass and was skipped", d.line, label)
+		return
+	}
+
+	var paths []string
+	switch modifier {
+	case "", "^~":
+		paths = prefixPaths(path)
+	case "=":
+		paths = []string{path}
+	default:
+		n.result.warnf("line %d: regex location %s is not supported and was skipped", d.line, label)
+		return
+	}
+
+	service, stripPath, ok := n.service(pass, protocol, scope)
+	if !ok {
+		return
+	}
+
+	preserveHost := false
+	for _, h := range scope["proxy_set_header"] {
+		if len(h.args) != 2 {
+			continue
+		}
+		switch strings.ToLower(h.args[0]) {
+		case "host":
+			preserveHost = h.args[1] == "$host" || h.args[1] == "$http_host"
+		case "x-real-ip", "x-forwarded-for", "x-forwarded-proto", "x-forwarded-host", "connection", "upgrade":
+			// The gateway sets the forwarding headers itself
+		default:
+			n.result.warnf("line %d: location %s: proxy_set_header %s is not converted", h.line, label, h.args[0])
+		}
+	}
+
+	base := path
+	if len(hosts) > 0 {
+		base = hosts[0] + base
+	}
+	name := slug(base)
+	if name == "" {
+		name = "root"
+	}
+	route := database.TopologyRoute{
+		Name:         n.routeNames.unique(name),
+		Service:      n.result.Topology.Services[service].Name,
+		Hosts:        hosts,
+		Paths:        paths,
+		Methods:      methods,
+		StripPath:    stripPath,
+		PreserveHost: preserveHost,
+		Enabled:      true,
+	}
+	n.result.Topology.Routes = append(n.result.Topology.Routes, route)
+
+	if limit := scope.last("limit_req"); limit != nil {
+		n.rateLimit(limit, route.Name)
+	}
+}
+
+// service returns the index of the service a proxy_pass forwards to,
+// creating it on first use, and whether the location prefix is stripped.
+func (n *nginxConverter) service(pass *directive, protocol string, scope nginxScope) (int, bool, bool) {
+	if len(pass.args) != 1 || strings.Contains(pass.args[0], "$") {
+		n.result.warnf("line %d: %s %s: variables are not supported, location skipped", pass.line, pass.name, strings.Join(pass.args, " "))
+		return 0, false, false
+	}
+	dest := pass.args[0]
+	if !strings.Contains(dest, "://") {
+		dest = "http://" + dest // grpc_pass may omit the scheme
+	}
+	u, err := url.Parse(dest)
+	if err != nil || u.Host == "" {
+		n.result.warnf("line %d: invalid %s %s, location skipped", pass.line, pass.name, pass.args[0])
+		return 0, false, false
+	}
+	if protocol == "" {
+		protocol = u.Scheme
+	}
+	switch protocol {
+	case "http", "https", "grpc":
+	case "grpcs":
+		n.result.warnf("line %d: grpcs upstreams are proxied as grpc", pass.line)
+		protocol = "grpc"
+	default:
+		n.result.warnf("line %d: %s scheme %q is not supported, location skipped", pass.line, pass.name, u.Scheme)
+		return 0, false, false
+	}
+
+	// A URI on proxy_pass replaces the matched location prefix
+	stripPath := u.Path != ""
+	servicePath := strings.TrimRight(u.Path, "/")
+
+	key := protocol + "://" + u.Host + servicePath
+	index, exists := n.services[key]
+	if !exists {
+		s := database.TopologyService{Protocol: protocol, Path: servicePath, Enabled: true}
+		if up, ok := n.upstreams[u.Host]; ok {
+			s.Name = n.serviceNames.unique(u.Host)
+			s.Targets = up.targets
+			s.LoadBalancerType, s.HashOn, s.HashOnKey = up.lbType, up.hashOn, up.hashOnKey
+			if len(up.targets) > 0 {
+				s.Host, s.Port, _ = splitHostPort(up.targets[0].Target)
+			} else {
+				s.Host = u.Host
+			}
+		} else {
+			s.Name = n.serviceNames.unique(slug(u.Hostname()))
+			s.Host = u.Hostname()
+			s.Port, _ = strconv.Atoi(u.Port())
+			if s.Port == 0 && protocol == "https" {
+				s.Port = 443
+			}
+		}
+		index = len(n.result.Topology.Services)
+		n.services[key] = index
+		n.result.Topology.Services = append(n.result.Topology.Services, s)
+	}
+
+	// Timeouts and retries are per service; the first location sets them
+	s := &n.result.Topology.Services[index]
+	settings := []struct {
+		name  string
+		field *int
+		ms    bool
+	}{
+		{"proxy_connect_timeout", &s.ConnectTimeoutMs, true},
+		{"proxy_read_timeout", &s.ReadTimeoutMs, true},
+		{"proxy_send_timeout", &s.WriteTimeoutMs, true},
+		{"proxy_next_upstream_tries", &s.Retries, false},
+	}
+	for _, setting := range settings {
+		d := scope.last(setting.name)
+		if d == nil || len(d.args) != 1 {
+			continue
+		}
+		var value int
+		if setting.ms {
+			dur, err := parseNginxTime(d.args[0])
+			if err != nil {
+				n.result.warnf("line %d: %s: %v", d.line, setting.name, err)
+				continue
+			}
+			value = int(dur / time.Millisecond)
+		} else {
+			tries, err := strconv.Atoi(d.args[0])
+			if err != nil || tries < 0 {
+				n.result.warnf("line %d: invalid %s %s", d.line, setting.name, d.args[0])
+				continue
+			}
+			// Tries include the first attempt
+			if tries > 0 {
+				value = tries - 1
+			}
+		}
+		switch {
+		case !exists:
+			*setting.field = value
+		case *setting.field != value:
+			n.result.warnf("line %d: %s differs between locations of service %s; keeping %d", d.line, setting.name, s.Name, *setting.field)
+		}
+	}
+	return index, stripPath, true
+}
+
+// rateLimit adds a rate-limit plugin for a limit_req directive.
+func (n *nginxConverter) rateLimit(d *directive, route string) {
+	var zoneName string
+	for _, arg := range d.args {
+		switch {
+		case strings.HasPrefix(arg, "zone="):
+			zoneName = strings.TrimPrefix(arg, "zone=")
+		case strings.HasPrefix(arg, "burst="):
+			n.result.warnf("line %d: limit_req %s is not converted", d.line, arg)
+		}
+	}
+	z, ok := n.zones[zoneName]
+	if !ok {
+		n.result.warnf("line %d: limit_req: unknown zone %q, route %s is not rate limited", d.line, zoneName, route)
+		return
+	}
+
+	config := map[string]interface{}{
+		"algorithm": "sliding-window",
+		"limit":     z.limit,
+		"window":    z.window,
+	}
+	if z.identifier != "" {
+		config["identifier"] = z.identifier
+	}
+	n.result.Topology.Plugins = append(n.result.Topology.Plugins, database.TopologyPlugin{
+		Name:    "rate-limit",
+		Scope:   database.PluginScopeRoute,
+		Route:   route,
+		Config:  config,
+		Enabled: true,
+	})
+}
+
+// parseNginxTime parses an nginx time ("30s", "1m30s", "500ms", "60").
+func parseNginxTime(s string) (time.Duration, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	units := map[string]time.Duration{"ms": time.Millisecond, "s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour}
+	var total time.Duration
+	rest := s
+	for rest != "" {
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		j := i
+		for j < len(rest) && (rest[j] < '0' || rest[j] > '9') {
+			j++
+		}
+		n, err := strconv.Atoi(rest[:i])
+		unit, ok := units[rest[i:j]]
+		if err != nil || !ok {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		total += time.Duration(n) * unit
+		rest = rest[j:]
+	}
+	if total <= 0 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return total, nil
+}
+
+// parseNginx parses nginx configuration syntax into directives.
+func parseNginx(text string) ([]*directive, error) {
+	tokens, err := tokenizeNginx(text)
+	if err != nil {
+		return nil, err
+	}
+	pos := 0
+	directives, err := parseNginxBlock(tokens, &pos, false)
+	if err != nil {
+		return nil, err
+	}
+	return directives, nil
+}
+
+// nginxToken is a word or one of "{", "}" and ";".
+type nginxToken struct {
+	text   string
+	quoted bool
+	line   int
+}
+
+func (t nginxToken) is(s string) bool {
+	return !t.quoted && t.text == s
+}
+
+// parseNginxBlock parses directives up to the closing brace (or the end
+// of input at the top level).
+func parseNginxBlock(tokens []nginxToken, pos *int, nested bool) ([]*directive, error) {
+	var directives []*directive
+	for *pos < len(tokens) {
+		t := tokens[*pos]
+		if t.is("}") {
+			if !nested {
+				return nil, fmt.Errorf("nginx line %d: unexpected '}'", t.line)
+			}
+			*pos++
+			return directives, nil
+		}
+		if t.is("{") || t.is(";") {
+			return nil, fmt.Errorf("nginx line %d: unexpected '%s'", t.line, t.text)
+		}
+
+		d := &directive{name: t.text, line: t.line}
+		*pos++
+		for {
+			if *pos >= len(tokens) {
+				return nil, fmt.Errorf("nginx line %d: directive %s is not terminated", d.line, d.name)
+			}
+			t := tokens[*pos]
+			*pos++
+			if t.is(";") {
+				break
+			}
+			if t.is("{") {
+				block, err := parseNginxBlock(tokens, pos, true)
+				if err != nil {
+					return nil, err
+				}
+				d.block = block
+				if d.block == nil {
+					d.block = []*directive{}
+				}
+				break
+			}
+			if t.is("}") {
+				return nil, fmt.Errorf("nginx line %d: unexpected '}'", t.line)
+			}
+			d.args = append(d.args, t.text)
+		}
+		directives = append(directives, d)
+	}
+	if nested {
+		return nil, fmt.Errorf("nginx: unexpected end of file, expecting '}'")
+	}
+	return directives, nil
+}
+
+// tokenizeNginx splits nginx configuration into tokens, dropping comments.
+func tokenizeNginx(text string) ([]nginxToken, error) {
+	var tokens []nginxToken
+	line := 1
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case c == '{' || c == '}' || c == ';':
+			tokens = append(tokens, nginxToken{text: string(c), line: line})
+			i++
+		case c == '"' || c == '\'':
+			start := line
+			var b strings.Builder
+			i++
+			for i < len(text) && text[i] != c {
+				if text[i] == '\\' && i+1 < len(text) {
+					i++
+				}
+				if text[i] == '\n' {
+					line++
+				}
+				b.WriteByte(text[i])
+				i++
+			}
+			if i >= len(text) {
+				return nil, fmt.Errorf("nginx line %d: unterminated quoted string", start)
+			}
+			i++
+			tokens = append(tokens, nginxToken{text: b.String(), quoted: true, line: start})
+		default:
+			start := i
+			for i < len(text) && !strings.ContainsRune(" \t\r\n;{}\"'", rune(text[i])) {
+				i++
+			}
+			tokens = append(tokens, nginxToken{text: text[start:i], line: line})
+		}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,565 @@
+// Package importer - YAML subset reader
+//
+// Kong declarative files are YAML, and the gateway has no YAML
+// dependency, so this reads the subset such files use: block mappings and
+// sequences, flow collections ([a, b], {k: v}), plain/quoted scalars,
+// literal (|) and folded (>) block scalars and comments. Anchors, aliases,
+// tags and multi-document streams are rejected.
+//
+// Values decode like encoding/json's interface{} values (map[string]
+// interface{}, []interface{}, string, float64, bool, nil), so a document
+// can be re-encoded as JSON and decoded into typed structs.
+package importer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlParser reads a document line by line.
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+// parseYAML decodes a YAML document.
+func parseYAML(data []byte) (interface{}, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	p := &yamlParser{lines: strings.Split(text, "\n")}
+
+	// Optional document start marker
+	if i := p.next(); i >= 0 && strings.HasPrefix(p.content(i), "---") {
+		if rest := strings.TrimSpace(strings.TrimPrefix(p.content(i), "---")); rest != "" {
+			return nil, p.errorf(i, "content after the document start marker is not supported")
+		}
+		p.pos = i + 1
+	}
+
+	i := p.next()
+	if i < 0 {
+		return nil, nil
+	}
+	value, err := p.parseNode(indentOf(p.lines[i]))
+	if err != nil {
+		return nil, err
+	}
+
+	if i := p.next(); i >= 0 {
+		if c := p.content(i); c == "---" || c == "..." {
+			if j := p.nextAfter(i + 1); j >= 0 {
+				return nil, p.errorf(j, "multiple documents are not supported")
+			}
+			return value, nil
+		}
+		return nil, p.errorf(i, "unexpected indentation")
+	}
+	return value, nil
+}
+
+// next returns the index of the next line with content, or -1.
+func (p *yamlParser) next() int {
+	return p.nextAfter(p.pos)
+}
+
+func (p *yamlParser) nextAfter(from int) int {
+	for i := from; i < len(p.lines); i++ {
+		if p.content(i) != "" {
+			return i
+		}
+	}
+	return -1
+}
+
+// content returns line i without indentation, comment and trailing space.
+func (p *yamlParser) content(i int) string {
+	return strings.TrimSpace(stripComment(p.lines[i]))
+}
+
+func (p *yamlParser) errorf(i int, format string, args ...interface{}) error {
+	return fmt.Errorf("yaml line %d: %s", i+1, fmt.Sprintf(format, args...))
+}
+
+// parseNode parses the block node starting at the next line, which is
+// indented by indent.
+func (p *yamlParser) parseNode(indent int) (interface{}, error) {
+	i := p.next()
+	if i < 0 {
+		return nil, nil
+	}
+	if strings.HasPrefix(p.lines[i], "\t") || strings.Contains(p.lines[i][:indentOf(p.lines[i])], "\t") {
+		return nil, p.errorf(i, "tabs are not allowed for indentation")
+	}
+
+	c := p.content(i)
+	switch {
+	case isSequenceItem(c):
+		return p.parseSequence(indent)
+	case isMappingEntry(c):
+		return p.parseMapping(indent)
+	}
+
+	p.pos = i + 1
+	return p.parseInline(i, c)
+}
+
+// parseSequence parses "- item" lines indented by indent.
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for {
+		i := p.next()
+		if i < 0 || indentOf(p.lines[i]) != indent || !isSequenceItem(p.content(i)) {
+			return items, nil
+		}
+
+		line := p.lines[i]
+		rest := strings.TrimLeft(line[indent+1:], " ")
+		offset := len(line) - len(rest)
+
+		switch c := strings.TrimSpace(stripComment(rest)); {
+		case c == "":
+			// Item on the following lines
+			p.pos = i + 1
+			j := p.next()
+			if j < 0 || indentOf(p.lines[j]) <= indent {
+				items = append(items, nil)
+				continue
+			}
+			item, err := p.parseNode(indentOf(p.lines[j]))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+
+		case isMappingEntry(c) || isSequenceItem(c):
+			// "- key: value" starts a mapping (or "- - x" a sequence)
+			// indented at the item's content
+			p.lines[i] = strings.Repeat(" ", offset) + rest
+			item, err := p.parseNode(offset)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+
+		default:
+			p.pos = i + 1
+			item, err := p.parseScalarValue(i, c, indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+	}
+}
+
+// parseMapping parses "key: value" lines indented by indent.
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for {
+		i := p.next()
+		if i < 0 || indentOf(p.lines[i]) != indent {
+			return m, nil
+		}
+		c := p.content(i)
+		if isSequenceItem(c) {
+			return m, nil
+		}
+		if !isMappingEntry(c) {
+			return nil, p.errorf(i, "expected a key: value entry")
+		}
+
+		key, value, err := splitEntry(c)
+		if err != nil {
+			return nil, p.errorf(i, "%v", err)
+		}
+		if _, ok := m[key]; ok {
+			return nil, p.errorf(i, "duplicate key %q", key)
+		}
+		p.pos = i + 1
+
+		if value != "" {
+			if m[key], err = p.parseScalarValue(i, value, indent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Value on the following lines: more indented, or a sequence at
+		// the key's indentation
+		j := p.next()
+		switch {
+		case j < 0:
+			m[key] = nil
+		case indentOf(p.lines[j]) > indent:
+			if m[key], err = p.parseNode(indentOf(p.lines[j])); err != nil {
+				return nil, err
+			}
+		case indentOf(p.lines[j]) == indent && isSequenceItem(p.content(j)):
+			if m[key], err = p.parseSequence(indent); err != nil {
+				return nil, err
+			}
+		default:
+			m[key] = nil
+		}
+	}
+}
+
+// parseScalarValue parses an inline value, or the block scalar it
+// introduces (| or >), of a node indented by indent.
+func (p *yamlParser) parseScalarValue(i int, value string, indent int) (interface{}, error) {
+	if value[0] == '|' || value[0] == '>' {
+		return p.parseBlockScalar(i, value, indent)
+	}
+	return p.parseInline(i, value)
+}
+
+// parseBlockScalar reads the lines of a literal or folded block scalar.
+func (p *yamlParser) parseBlockScalar(i int, header string, indent int) (interface{}, error) {
+	folded := header[0] == '>'
+	chomp := strings.TrimSpace(header[1:])
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return nil, p.errorf(i, "unsupported block scalar header %q", header)
+	}
+
+	var lines []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		n := indentOf(line)
+		if n <= indent || (blockIndent >= 0 && n < blockIndent) {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = n
+		}
+		lines = append(lines, line[blockIndent:])
+		p.pos++
+	}
+
+	// Trailing blank lines belong to the chomping, not the content
+	end := len(lines)
+	for end > 0 && lines[end-1] == "" {
+		end--
+	}
+	if end < len(lines) {
+		p.pos -= len(lines) - end
+	}
+	lines = lines[:end]
+
+	var text string
+	if folded {
+		var b strings.Builder
+		for k, line := range lines {
+			switch {
+			case k == 0 || lines[k-1] == "":
+			case line == "":
+				b.WriteString("\n")
+			default:
+				b.WriteString(" ")
+			}
+			b.WriteString(line)
+		}
+		text = b.String()
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+	if chomp != "-" && text != "" {
+		text += "\n"
+	}
+	return text, nil
+}
+
+// parseInline parses a scalar or flow collection on line i.
+func (p *yamlParser) parseInline(i int, s string) (interface{}, error) {
+	f := &flowParser{s: s}
+	value, err := f.value()
+	if err == nil {
+		f.skipSpace()
+		if f.pos < len(f.s) {
+			err = fmt.Errorf("unexpected %q", f.s[f.pos:])
+		}
+	}
+	if err != nil {
+		return nil, p.errorf(i, "%v", err)
+	}
+	return value, nil
+}
+
+// flowParser parses inline values: scalars, [sequences] and {mappings}.
+type flowParser struct {
+	s     string
+	pos   int
+	depth int
+}
+
+func (f *flowParser) skipSpace() {
+	for f.pos < len(f.s) && f.s[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *flowParser) value() (interface{}, error) {
+	f.skipSpace()
+	if f.pos >= len(f.s) {
+		return nil, nil
+	}
+	switch f.s[f.pos] {
+	case '[':
+		return f.sequence()
+	case '{':
+		return f.mapping()
+	case '"', '\'':
+		return f.quoted()
+	case '&', '*', '!':
+		return nil, fmt.Errorf("anchors, aliases and tags are not supported")
+	}
+
+	// Plain scalar, up to a flow delimiter when nested
+	start := f.pos
+	for f.pos < len(f.s) {
+		c := f.s[f.pos]
+		if f.nested() && (c == ',' || c == ']' || c == '}') {
+			break
+		}
+		if c == ':' && f.nested() && (f.pos+1 == len(f.s) || f.s[f.pos+1] == ' ') {
+			break
+		}
+		f.pos++
+	}
+	return resolveScalar(strings.TrimSpace(f.s[start:f.pos])), nil
+}
+
+// nested reports whether the parser is inside a flow collection.
+func (f *flowParser) nested() bool {
+	return f.depth > 0
+}
+
+func (f *flowParser) sequence() (interface{}, error) {
+	f.pos++ // [
+	f.depth++
+	defer func() { f.depth-- }()
+	items := []interface{}{}
+	for {
+		f.skipSpace()
+		if f.pos >= len(f.s) {
+			return nil, fmt.Errorf("unterminated flow sequence")
+		}
+		if f.s[f.pos] == ']' {
+			f.pos++
+			return items, nil
+		}
+		item, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		f.skipSpace()
+		if f.pos < len(f.s) && f.s[f.pos] == ',' {
+			f.pos++
+		}
+	}
+}
+
+func (f *flowParser) mapping() (interface{}, error) {
+	f.pos++ // {
+	f.depth++
+	defer func() { f.depth-- }()
+	m := map[string]interface{}{}
+	for {
+		f.skipSpace()
+		if f.pos >= len(f.s) {
+			return nil, fmt.Errorf("unterminated flow mapping")
+		}
+		if f.s[f.pos] == '}' {
+			f.pos++
+			return m, nil
+		}
+		key, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpace()
+		if f.pos >= len(f.s) || f.s[f.pos] != ':' {
+			return nil, fmt.Errorf("expected ':' in flow mapping")
+		}
+		f.pos++
+		value, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(key)] = value
+		f.skipSpace()
+		if f.pos < len(f.s) && f.s[f.pos] == ',' {
+			f.pos++
+		}
+	}
+}
+
+// quoted parses a single- or double-quoted string.
+func (f *flowParser) quoted() (interface{}, error) {
+	quote := f.s[f.pos]
+	f.pos++
+	var b strings.Builder
+	for f.pos < len(f.s) {
+		c := f.s[f.pos]
+		switch {
+		case quote == '\'' && c == '\'':
+			if f.pos+1 < len(f.s) && f.s[f.pos+1] == '\'' {
+				b.WriteByte('\'')
+				f.pos += 2
+				continue
+			}
+			f.pos++
+			return b.String(), nil
+		case quote == '"' && c == '"':
+			f.pos++
+			return b.String(), nil
+		case quote == '"' && c == '\\' && f.pos+1 < len(f.s):
+			s, n, err := unescape(f.s[f.pos:])
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(s)
+			f.pos += n
+			continue
+		}
+		b.WriteByte(c)
+		f.pos++
+	}
+	return nil, fmt.Errorf("unterminated quoted string")
+}
+
+// unescape decodes the escape sequence at the start of s, returning the
+// text and the number of bytes consumed.
+func unescape(s string) (string, int, error) {
+	simple := map[byte]string{
+		'n': "\n", 't': "\t", 'r': "\r", '0': "\x00", '"': `"`, '\\': `\`, '/': "/", ' ': " ", 'e': "\x1b",
+	}
+	if v, ok := simple[s[1]]; ok {
+		return v, 2, nil
+	}
+	size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[1]]
+	if size == 0 || len(s) < 2+size {
+		return "", 0, fmt.Errorf("invalid escape sequence %q", s[:2])
+	}
+	code, err := strconv.ParseUint(s[2:2+size], 16, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid escape sequence %q", s[:2+size])
+	}
+	return string(rune(code)), 2 + size, nil
+}
+
+// resolveScalar types a plain scalar (null, booleans and numbers).
+func resolveScalar(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return float64(n)
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "xXpP_") {
+		return f
+	}
+	return s
+}
+
+// splitEntry splits "key: value" (the value may be empty).
+func splitEntry(c string) (string, string, error) {
+	var key string
+	rest := c
+	if c[0] == '"' || c[0] == '\'' {
+		f := &flowParser{s: c}
+		k, err := f.quoted()
+		if err != nil {
+			return "", "", err
+		}
+		key, rest = k.(string), c[f.pos:]
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", fmt.Errorf("expected ':' after key")
+		}
+		return key, strings.TrimSpace(rest[1:]), nil
+	}
+
+	k := mappingColon(c)
+	if k < 0 {
+		return "", "", fmt.Errorf("expected a key: value entry")
+	}
+	key = strings.TrimSpace(c[:k])
+	if strings.HasPrefix(key, "? ") || key == "?" || strings.HasPrefix(key, "<<") {
+		return "", "", fmt.Errorf("complex keys and merge keys are not supported")
+	}
+	return key, strings.TrimSpace(c[k+1:]), nil
+}
+
+// mappingColon returns the index of the ':' separating a plain key from
+// its value, or -1.
+func mappingColon(c string) int {
+	if c == "" || c[0] == '[' || c[0] == '{' {
+		return -1
+	}
+	for k := 0; k < len(c); k++ {
+		if c[k] == ':' && (k+1 == len(c) || c[k+1] == ' ') {
+			return k
+		}
+	}
+	return -1
+}
+
+// isMappingEntry reports whether c is a "key: value" line.
+func isMappingEntry(c string) bool {
+	if c == "" {
+		return false
+	}
+	if c[0] == '"' || c[0] == '\'' {
+		_, _, err := splitEntry(c)
+		return err == nil
+	}
+	return mappingColon(c) >= 0 && !isSequenceItem(c)
+}
+
+// isSequenceItem reports whether c is a "- item" line.
+func isSequenceItem(c string) bool {
+	return c == "-" || strings.HasPrefix(c, "- ")
+}
+
+// indentOf counts the leading spaces of line.
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// stripComment removes a # comment (outside quotes) from line.
+func stripComment(line string) string {
+	inQuote := byte(0)
+	for k := 0; k < len(line); k++ {
+		c := line[k]
+		switch {
+		case inQuote != 0:
+			switch {
+			case inQuote == '"' && c == '\\':
+				k++ // escaped character
+			case inQuote == '\'' && c == '\'' && k+1 < len(line) && line[k+1] == '\'':
+				k++ // '' is a quote
+			case c == inQuote:
+				inQuote = 0
+			}
+		case c == '"' || c == '\'':
+			// Quotes only open at the start of a token
+			if k == 0 || strings.ContainsRune(" \t:[{,-", rune(line[k-1])) {
+				inQuote = c
+			}
+		case c == '#' && (k == 0 || line[k-1] == ' ' || line[k-1] == '\t'):
+			return line[:k]
+		}
+	}
+	return line
+}
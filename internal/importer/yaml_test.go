@@ -0,0 +1,114 @@
+package importer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	doc := `---
+# Kong declarative config
+_format_version: "3.0"
+services:
+- name: orders   # trailing comment
+  url: http://orders.internal:8080/v1
+  retries: 3
+  tags: [team-payments, "tier-1"]
+  routes:
+    - name: orders-api
+      paths:
+      - /orders
+      strip_path: false
+      methods: []
+plugins:
+  - name: cors
+    config: {origins: ["https://app.example.com"], credentials: true, max_age: 3600}
+empty:
+quoted: 'it''s # not a comment'
+escaped: "tab\tand \u00e9"
+script: |
+  line one
+    indented
+
+  line three
+folded: >-
+  one
+  two
+
+  three
+`
+	got, err := parseYAML([]byte(doc))
+	if err != nil {
+		t.Fatalf("parseYAML() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"_format_version": "3.0",
+		"services": []interface{}{
+			map[string]interface{}{
+				"name":    "orders",
+				"url":     "http://orders.internal:8080/v1",
+				"retries": float64(3),
+				"tags":    []interface{}{"team-payments", "tier-1"},
+				"routes": []interface{}{
+					map[string]interface{}{
+						"name":       "orders-api",
+						"paths":      []interface{}{"/orders"},
+						"strip_path": false,
+						"methods":    []interface{}{},
+					},
+				},
+			},
+		},
+		"plugins": []interface{}{
+			map[string]interface{}{
+				"name": "cors",
+				"config": map[string]interface{}{
+					"origins":     []interface{}{"https://app.example.com"},
+					"credentials": true,
+					"max_age":     float64(3600),
+				},
+			},
+		},
+		"empty":   nil,
+		"quoted":  "it's # not a comment",
+		"escaped": "tab\tand é",
+		"script":  "line one\n  indented\n\nline three\n",
+		"folded":  "one two\nthree",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYAML() =\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestParseYAML_NestedSequences(t *testing.T) {
+	got, err := parseYAML([]byte("matrix:\n- - 1\n  - 2\n-\n  - a\n"))
+	if err != nil {
+		t.Fatalf("parseYAML() error = %v", err)
+	}
+	want := map[string]interface{}{
+		"matrix": []interface{}{
+			[]interface{}{float64(1), float64(2)},
+			[]interface{}{"a"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYAML() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseYAML_Errors(t *testing.T) {
+	tests := map[string]string{
+		"duplicate key":      "a: 1\na: 2\n",
+		"anchor":             "a: &x 1\n",
+		"bad indentation":    "a:\n    b: 1\n  c: 2\n",
+		"multiple documents": "a: 1\n---\nb: 2\n",
+		"unterminated flow":  "a: [1, 2\n",
+		"tab indentation":    "a:\n\tb: 1\n",
+	}
+	for name, doc := range tests {
+		if _, err := parseYAML([]byte(doc)); err == nil {
+			t.Errorf("%s: parseYAML() should fail", name)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Package keycache caches API key → consumer lookups for the auth path.
+//
+// Right after a deploy every gateway instance starts with an empty cache,
+// so each API key's first request costs a Postgres query. Cache avoids
+// that stampede:
+//   - Warm preloads the most recently used active keys before the
+//     gateway starts serving
+//   - Run refreshes the preload periodically, which also evicts keys that
+//     were revoked, disabled or expired in the meantime
+//   - Unknown keys are cached negatively (briefly), so a client retrying
+//     a bad key doesn't hit the database on every attempt
+//   - Concurrent misses for the same key share one query
+//
+// Entries expire after TTL, bounding how long a revoked key keeps working
+// on an instance when refreshing is disabled.
+package keycache
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+var lookupsTotal = metrics.NewCounterVec(
+	"gateway_api_key_cache_lookups_total",
+	"API key cache lookups by result (hit, negative_hit, miss).",
+	"result",
+)
+
+// Store loads API keys (implemented by database.Repository).
+type Store interface {
+	HashAPIKey(apiKey string) string
+	GetConsumerByAPIKeyHash(ctx context.Context, keyHash string) (*database.Consumer, error)
+	GetActiveAPIKeyConsumers(ctx context.Context, limit int) ([]*database.APIKeyConsumer, error)
+}
+
+// Config holds cache configuration.
+type Config struct {
+	// Size is the most keys cached (LRU eviction beyond it)
+	Size int
+
+	// TTL is how long a cached consumer is trusted
+	TTL time.Duration
+
+	// NegativeTTL is how long an unknown key is remembered (0 = never)
+	NegativeTTL time.Duration
+}
+
+// DefaultConfig returns sensible defaults.
+func DefaultConfig() Config {
+	return Config{
+		Size:        10000,
+		TTL:         5 * time.Minute,
+		NegativeTTL: 30 * time.Second,
+	}
+}
+
+// entry is a cached lookup. A nil consumer caches "no such key".
+type entry struct {
+	keyHash  string
+	consumer *database.Consumer
+	expires  time.Time
+}
+
+// call is an in-flight database lookup shared by concurrent misses.
+type call struct {
+	done     chan struct{}
+	consumer *database.Consumer
+	err      error
+}
+
+// Cache is an LRU of API key hash → consumer in front of a Store.
+type Cache struct {
+	store  Store
+	config Config
+
+	mu       sync.Mutex
+	lru      *list.List // front = most recently used; values are *entry
+	items    map[string]*list.Element
+	inflight map[string]*call
+}
+
+// New creates an empty cache. Zero config values take their defaults.
+func New(store Store, config Config) *Cache {
+	defaults := DefaultConfig()
+	if config.Size <= 0 {
+		config.Size = defaults.Size
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaults.TTL
+	}
+	if config.NegativeTTL < 0 {
+		config.NegativeTTL = 0
+	}
+
+	return &Cache{
+		store:    store,
+		config:   config,
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+		inflight: make(map[string]*call),
+	}
+}
+
+// GetConsumerByAPIKey resolves a plaintext API key, hashing it the same
+// way the store does. Unknown keys return an error wrapping sql.ErrNoRows.
+func (c *Cache) GetConsumerByAPIKey(ctx context.Context, apiKey string) (*database.Consumer, error) {
+	return c.GetConsumerByAPIKeyHash(ctx, c.store.HashAPIKey(apiKey))
+}
+
+// GetConsumerByAPIKeyHash resolves a key hash through the cache.
+func (c *Cache) GetConsumerByAPIKeyHash(ctx context.Context, keyHash string) (*database.Consumer, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	if elem, ok := c.items[keyHash]; ok {
+		e := elem.Value.(*entry)
+		if now.Before(e.expires) {
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+
+			if e.consumer == nil {
+				lookupsTotal.Inc("negative_hit")
+				return nil, fmt.Errorf("no consumer found for API key: %w", sql.ErrNoRows)
+			}
+			lookupsTotal.Inc("hit")
+			return e.consumer, nil
+		}
+		c.removeElement(elem)
+	}
+
+	// Join an in-flight lookup for the same key, or start one
+	if inflight, ok := c.inflight[keyHash]; ok {
+		c.mu.Unlock()
+		select {
+		case <-inflight.done:
+			return inflight.consumer, inflight.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	inflight := &call{done: make(chan struct{})}
+	c.inflight[keyHash] = inflight
+	c.mu.Unlock()
+
+	lookupsTotal.Inc("miss")
+	inflight.consumer, inflight.err = c.store.GetConsumerByAPIKeyHash(ctx, keyHash)
+
+	c.mu.Lock()
+	delete(c.inflight, keyHash)
+	switch {
+	case inflight.err == nil:
+		c.put(keyHash, inflight.consumer, now.Add(c.config.TTL))
+	case errors.Is(inflight.err, sql.ErrNoRows) && c.config.NegativeTTL > 0:
+		c.put(keyHash, nil, now.Add(c.config.NegativeTTL))
+	}
+	c.mu.Unlock()
+	close(inflight.done)
+
+	return inflight.consumer, inflight.err
+}
+
+// Warm preloads the most recently used active keys, up to Size.
+//
+// When every active key fits, cached keys that are no longer active
+// (revoked, disabled, expired) are evicted, so Warm doubles as a refresh.
+func (c *Cache) Warm(ctx context.Context) error {
+	start := time.Now()
+
+	keys, err := c.store.GetActiveAPIKeyConsumers(ctx, c.config.Size)
+	if err != nil {
+		return fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	now := time.Now()
+	active := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		active[key.KeyHash] = true
+	}
+
+	c.mu.Lock()
+	evicted := 0
+	if len(keys) < c.config.Size {
+		for keyHash, elem := range c.items {
+			if elem.Value.(*entry).consumer != nil && !active[keyHash] {
+				c.removeElement(elem)
+				evicted++
+			}
+		}
+	}
+
+	// Insert least recently used first so the hottest keys end up at the
+	// front of the LRU
+	for i := len(keys) - 1; i >= 0; i-- {
+		c.put(keys[i].KeyHash, keys[i].Consumer, now.Add(c.config.TTL))
+	}
+	size := c.lru.Len()
+	c.mu.Unlock()
+
+	log.Debug().
+		Str("component", "keycache").
+		Int("loaded", len(keys)).
+		Int("evicted", evicted).
+		Int("cached", size).
+		Dur("duration", time.Since(start)).
+		Msg("API key cache warmed")
+
+	return nil
+}
+
+// Run re-warms the cache every interval until ctx is cancelled.
+func (c *Cache) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Warm(ctx); err != nil {
+				log.Warn().
+					Err(err).
+					Str("component", "keycache").
+					Msg("API key cache refresh failed - serving cached entries until they expire")
+			}
+		}
+	}
+}
+
+// Len returns the number of cached entries (including negative ones).
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// put inserts or replaces an entry, evicting the least recently used
+// entry when full. Caller holds mu.
+func (c *Cache) put(keyHash string, consumer *database.Consumer, expires time.Time) {
+	if elem, ok := c.items[keyHash]; ok {
+		e := elem.Value.(*entry)
+		e.consumer = consumer
+		e.expires = expires
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.items[keyHash] = c.lru.PushFront(&entry{keyHash: keyHash, consumer: consumer, expires: expires})
+
+	for c.lru.Len() > c.config.Size {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// removeElement drops an entry. Caller holds mu.
+func (c *Cache) removeElement(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.items, elem.Value.(*entry).keyHash)
+}
@@ -0,0 +1,207 @@
+package keycache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// fakeStore serves keys from a map and counts queries.
+type fakeStore struct {
+	mu      sync.Mutex
+	keys    map[string]*database.Consumer // hash -> consumer
+	order   []string                      // most recently used first
+	queries atomic.Int64
+	delay   time.Duration
+	fail    bool
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{keys: make(map[string]*database.Consumer)}
+}
+
+func (s *fakeStore) add(hash, consumerID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[hash] = &database.Consumer{ID: consumerID}
+	s.order = append(s.order, hash)
+}
+
+func (s *fakeStore) revoke(hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, hash)
+	for i, h := range s.order {
+		if h == hash {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (s *fakeStore) HashAPIKey(apiKey string) string { return "h:" + apiKey }
+
+func (s *fakeStore) GetConsumerByAPIKeyHash(ctx context.Context, keyHash string) (*database.Consumer, error) {
+	s.queries.Add(1)
+	time.Sleep(s.delay)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return nil, errors.New("connection refused")
+	}
+	consumer, ok := s.keys[keyHash]
+	if !ok {
+		return nil, fmt.Errorf("no consumer found for API key: %w", sql.ErrNoRows)
+	}
+	return consumer, nil
+}
+
+func (s *fakeStore) GetActiveAPIKeyConsumers(ctx context.Context, limit int) ([]*database.APIKeyConsumer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return nil, errors.New("connection refused")
+	}
+	var keys []*database.APIKeyConsumer
+	for _, hash := range s.order {
+		if len(keys) == limit {
+			break
+		}
+		keys = append(keys, &database.APIKeyConsumer{KeyHash: hash, Consumer: s.keys[hash]})
+	}
+	return keys, nil
+}
+
+func TestCache_WarmServesWithoutQueries(t *testing.T) {
+	store := newFakeStore()
+	store.add("h:key1", "c1")
+	store.add("h:key2", "c2")
+
+	c := New(store, DefaultConfig())
+	if err := c.Warm(context.Background()); err != nil {
+		t.Fatalf("Warm: %v", err)
+	}
+
+	consumer, err := c.GetConsumerByAPIKey(context.Background(), "key2")
+	if err != nil || consumer.ID != "c2" {
+		t.Fatalf("lookup = %v, %v; want c2", consumer, err)
+	}
+	if n := store.queries.Load(); n != 0 {
+		t.Errorf("queries after warm-up = %d, want 0", n)
+	}
+}
+
+func TestCache_NegativeCaching(t *testing.T) {
+	store := newFakeStore()
+	c := New(store, DefaultConfig())
+
+	for i := 0; i < 3; i++ {
+		_, err := c.GetConsumerByAPIKey(context.Background(), "unknown")
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("lookup error = %v, want sql.ErrNoRows", err)
+		}
+	}
+	if n := store.queries.Load(); n != 1 {
+		t.Errorf("queries = %d, want 1", n)
+	}
+}
+
+func TestCache_ErrorsNotCached(t *testing.T) {
+	store := newFakeStore()
+	store.add("h:key1", "c1")
+	store.fail = true
+	c := New(store, DefaultConfig())
+
+	if _, err := c.GetConsumerByAPIKey(context.Background(), "key1"); err == nil || errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("lookup error = %v, want a store error", err)
+	}
+
+	store.fail = false
+	if consumer, err := c.GetConsumerByAPIKey(context.Background(), "key1"); err != nil || consumer.ID != "c1" {
+		t.Errorf("lookup after recovery = %v, %v; want c1", consumer, err)
+	}
+}
+
+func TestCache_RefreshEvictsRevokedKeys(t *testing.T) {
+	store := newFakeStore()
+	store.add("h:key1", "c1")
+	store.add("h:key2", "c2")
+
+	c := New(store, DefaultConfig())
+	c.Warm(context.Background())
+
+	store.revoke("h:key1")
+	c.Warm(context.Background())
+
+	if _, err := c.GetConsumerByAPIKey(context.Background(), "key1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("revoked key lookup = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestCache_LRUEviction(t *testing.T) {
+	store := newFakeStore()
+	store.add("h:key1", "c1")
+	store.add("h:key2", "c2")
+	store.add("h:key3", "c3")
+
+	c := New(store, Config{Size: 2, TTL: time.Minute})
+	c.GetConsumerByAPIKey(context.Background(), "key1")
+	c.GetConsumerByAPIKey(context.Background(), "key2")
+	c.GetConsumerByAPIKey(context.Background(), "key1") // key2 is now least recent
+	c.GetConsumerByAPIKey(context.Background(), "key3")
+
+	if c.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", c.Len())
+	}
+	before := store.queries.Load()
+	c.GetConsumerByAPIKey(context.Background(), "key1")
+	if store.queries.Load() != before {
+		t.Error("key1 was evicted instead of key2")
+	}
+}
+
+func TestCache_ExpiredEntriesReloaded(t *testing.T) {
+	store := newFakeStore()
+	store.add("h:key1", "c1")
+
+	c := New(store, Config{TTL: time.Millisecond})
+	c.GetConsumerByAPIKey(context.Background(), "key1")
+	time.Sleep(5 * time.Millisecond)
+	c.GetConsumerByAPIKey(context.Background(), "key1")
+
+	if n := store.queries.Load(); n != 2 {
+		t.Errorf("queries = %d, want 2", n)
+	}
+}
+
+func TestCache_ConcurrentMissesShareQuery(t *testing.T) {
+	store := newFakeStore()
+	store.add("h:key1", "c1")
+	store.delay = 20 * time.Millisecond
+
+	c := New(store, DefaultConfig())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if consumer, err := c.GetConsumerByAPIKey(context.Background(), "key1"); err != nil || consumer.ID != "c1" {
+				t.Errorf("lookup = %v, %v", consumer, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := store.queries.Load(); n != 1 {
+		t.Errorf("queries = %d, want 1", n)
+	}
+}
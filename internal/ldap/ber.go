@@ -0,0 +1,235 @@
+package ldap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// ============================================================================
+// BER encoding
+// ============================================================================
+//
+// LDAP messages are ASN.1 encoded with the Basic Encoding Rules. Only the
+// subset LDAP clients need is implemented: definite lengths, single-byte
+// tags, and the universal types below.
+
+// BER classes and the constructed bit, combined with a tag number.
+const (
+	classUniversal   = 0x00
+	classApplication = 0x40
+	classContext     = 0x80
+	constructed      = 0x20
+)
+
+// Universal tags.
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x10 | constructed
+	tagSet         = 0x11 | constructed
+)
+
+// maxPacketSize bounds a single LDAP message read from the server.
+const maxPacketSize = 16 << 20
+
+// packet is a decoded BER element. Constructed elements have Children;
+// primitive elements have Value.
+type packet struct {
+	Tag      byte
+	Value    []byte
+	Children []*packet
+}
+
+// constructed reports whether the element contains other elements.
+func (p *packet) constructed() bool {
+	return p.Tag&constructed != 0
+}
+
+// newPacket creates a primitive element.
+func newPacket(tag byte, value []byte) *packet {
+	return &packet{Tag: tag, Value: value}
+}
+
+// newConstructed creates a constructed element.
+func newConstructed(tag byte, children ...*packet) *packet {
+	return &packet{Tag: tag | constructed, Children: children}
+}
+
+// newString creates an OCTET STRING.
+func newString(s string) *packet {
+	return newPacket(tagOctetString, []byte(s))
+}
+
+// newInteger creates an INTEGER (or ENUMERATED with tag tagEnumerated).
+func newInteger(tag byte, n int64) *packet {
+	return newPacket(tag, encodeInt(n))
+}
+
+// newBool creates a BOOLEAN.
+func newBool(b bool) *packet {
+	if b {
+		return newPacket(tagBoolean, []byte{0xff})
+	}
+	return newPacket(tagBoolean, []byte{0x00})
+}
+
+// add appends children to a constructed element.
+func (p *packet) add(children ...*packet) *packet {
+	p.Children = append(p.Children, children...)
+	return p
+}
+
+// bytes encodes the element.
+func (p *packet) bytes() []byte {
+	content := p.Value
+	if p.constructed() {
+		content = nil
+		for _, child := range p.Children {
+			content = append(content, child.bytes()...)
+		}
+	}
+
+	out := []byte{p.Tag}
+	out = append(out, encodeLength(len(content))...)
+	return append(out, content...)
+}
+
+// encodeLength encodes a definite length.
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var digits []byte
+	for v := n; v > 0; v >>= 8 {
+		digits = append([]byte{byte(v)}, digits...)
+	}
+	return append([]byte{0x80 | byte(len(digits))}, digits...)
+}
+
+// encodeInt encodes a two's complement integer in the fewest bytes.
+func encodeInt(n int64) []byte {
+	out := []byte{byte(n)}
+	for {
+		next := n >> 8
+		// Stop once the remaining bytes are pure sign extension
+		if (next == 0 && out[0]&0x80 == 0) || (next == -1 && out[0]&0x80 != 0) {
+			return out
+		}
+		n = next
+		out = append([]byte{byte(n)}, out...)
+	}
+}
+
+// decodeInt decodes a two's complement integer.
+func decodeInt(b []byte) (int64, error) {
+	if len(b) == 0 || len(b) > 8 {
+		return 0, fmt.Errorf("invalid integer length %d", len(b))
+	}
+	n := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		n = n<<8 | int64(c)
+	}
+	return n, nil
+}
+
+// ============================================================================
+// BER decoding
+// ============================================================================
+
+// readPacket reads one complete element from r.
+func readPacket(r *bufio.Reader) (*packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxPacketSize {
+		return nil, fmt.Errorf("message too large (%d bytes)", length)
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return parsePacket(tag, content)
+}
+
+// readLength reads a definite length.
+func readLength(r *bufio.Reader) (int, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if first < 0x80 {
+		return int(first), nil
+	}
+
+	count := int(first & 0x7f)
+	if count == 0 || count > 4 {
+		return 0, fmt.Errorf("unsupported length encoding 0x%02x", first)
+	}
+	length := 0
+	for i := 0; i < count; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	return length, nil
+}
+
+// parsePacket decodes an element's content, recursing into constructed
+// elements.
+func parsePacket(tag byte, content []byte) (*packet, error) {
+	p := &packet{Tag: tag}
+	if !p.constructed() {
+		p.Value = content
+		return p, nil
+	}
+
+	for len(content) > 0 {
+		if len(content) < 2 {
+			return nil, fmt.Errorf("truncated element")
+		}
+		childTag := content[0]
+		length, header, err := parseLength(content[1:])
+		if err != nil {
+			return nil, err
+		}
+		start := 1 + header
+		if length > len(content)-start {
+			return nil, fmt.Errorf("truncated element")
+		}
+		child, err := parsePacket(childTag, content[start:start+length])
+		if err != nil {
+			return nil, err
+		}
+		p.Children = append(p.Children, child)
+		content = content[start+length:]
+	}
+	return p, nil
+}
+
+// parseLength decodes a definite length from b, returning the length and
+// the number of bytes it occupied.
+func parseLength(b []byte) (int, int, error) {
+	if b[0] < 0x80 {
+		return int(b[0]), 1, nil
+	}
+	count := int(b[0] & 0x7f)
+	if count == 0 || count > 4 || len(b) < 1+count {
+		return 0, 0, fmt.Errorf("unsupported length encoding")
+	}
+	length := 0
+	for _, c := range b[1 : 1+count] {
+		length = length<<8 | int(c)
+	}
+	return length, 1 + count, nil
+}
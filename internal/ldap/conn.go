@@ -0,0 +1,523 @@
+// Package ldap is a minimal LDAPv3 client (RFC 4511) for authenticating
+// users against LDAP / Active Directory.
+//
+// It supports exactly what the ldap-auth plugin needs:
+//   - ldap:// and ldaps:// connections, and StartTLS
+//   - Simple bind
+//   - Search with string filters (RFC 4515, no extensible matches)
+//   - A connection pool (Pool)
+//
+// Referrals, SASL, paging and other controls are not supported.
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Protocol operation tags.
+const (
+	opBindRequest      = classApplication | constructed | 0
+	opBindResponse     = classApplication | constructed | 1
+	opUnbindRequest    = classApplication | 2
+	opSearchRequest    = classApplication | constructed | 3
+	opSearchEntry      = classApplication | constructed | 4
+	opSearchDone       = classApplication | constructed | 5
+	opSearchReference  = classApplication | constructed | 19
+	opExtendedRequest  = classApplication | constructed | 23
+	opExtendedResponse = classApplication | constructed | 24
+	tagSimpleAuth      = classContext | 0
+	tagRequestName     = classContext | 0
+)
+
+// startTLSOID is the StartTLS extended operation.
+const startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+// Result codes used by callers.
+const (
+	ResultSuccess            = 0
+	ResultNoSuchObject       = 32
+	ResultInvalidCredentials = 49
+)
+
+// Search scopes.
+const (
+	ScopeBaseObject   = 0
+	ScopeSingleLevel  = 1
+	ScopeWholeSubtree = 2
+)
+
+// Error is a non-success LDAPResult returned by the server.
+type Error struct {
+	ResultCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.ResultCode)
+	}
+	return fmt.Sprintf("ldap: result code %d: %s", e.ResultCode, e.Message)
+}
+
+// IsResultCode reports whether err is an LDAP Error with the given code.
+func IsResultCode(err error, code int) bool {
+	var ldapErr *Error
+	return errors.As(err, &ldapErr) && ldapErr.ResultCode == code
+}
+
+// DialConfig configures a connection.
+type DialConfig struct {
+	// URL is ldap://host[:389] or ldaps://host[:636]
+	URL string
+
+	// StartTLS upgrades an ldap:// connection before any other operation
+	StartTLS bool
+
+	// TLSConfig is used for ldaps:// and StartTLS (nil = defaults with
+	// ServerName taken from the URL)
+	TLSConfig *tls.Config
+
+	// Timeout bounds dialing and each operation
+	Timeout time.Duration
+}
+
+// Conn is a single LDAP connection. It is safe for sequential use only;
+// Pool hands each connection to one caller at a time.
+type Conn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	nextID  int64
+
+	// broken is set after an I/O or protocol error; the connection must
+	// not be reused
+	broken bool
+}
+
+// Dial connects to the server in cfg.URL.
+func Dial(ctx context.Context, cfg DialConfig) (*Conn, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP URL: %w", err)
+	}
+
+	host := u.Host
+	useTLS := false
+	switch strings.ToLower(u.Scheme) {
+	case "ldap":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+	case "ldaps":
+		useTLS = true
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "636")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported LDAP URL scheme '%s' (must be ldap or ldaps)", u.Scheme)
+	}
+	if useTLS && cfg.StartTLS {
+		return nil, fmt.Errorf("StartTLS cannot be used with ldaps://")
+	}
+
+	tlsConfig := cfg.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	var netConn net.Conn
+	if useTLS {
+		netConn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", host)
+	} else {
+		netConn, err = dialer.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", host, err)
+	}
+
+	c := NewConn(netConn, cfg.Timeout)
+	if cfg.StartTLS {
+		if err := c.startTLS(tlsConfig); err != nil {
+			c.conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// NewConn wraps an established connection.
+func NewConn(conn net.Conn, timeout time.Duration) *Conn {
+	return &Conn{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: timeout,
+	}
+}
+
+// Broken reports whether the connection failed and must be discarded.
+func (c *Conn) Broken() bool {
+	return c.broken
+}
+
+// Close sends an unbind request and closes the connection.
+func (c *Conn) Close() error {
+	if !c.broken {
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		c.send(newPacket(opUnbindRequest, nil))
+	}
+	c.broken = true
+	return c.conn.Close()
+}
+
+// ============================================================================
+// Operations
+// ============================================================================
+
+// Bind authenticates the connection with a simple bind.
+//
+// An empty password would be an unauthenticated bind (RFC 4513 section
+// 5.1.2), which many servers accept for any DN, so it is rejected here.
+func (c *Conn) Bind(dn, password string) error {
+	if password == "" {
+		return &Error{ResultCode: ResultInvalidCredentials, Message: "empty password"}
+	}
+
+	req := newConstructed(opBindRequest,
+		newInteger(tagInteger, 3),
+		newString(dn),
+		newPacket(tagSimpleAuth, []byte(password)),
+	)
+
+	resp, err := c.roundTrip(req, opBindResponse)
+	if err != nil {
+		return err
+	}
+	return resultError(resp)
+}
+
+// SearchRequest describes a search.
+type SearchRequest struct {
+	BaseDN     string
+	Scope      int
+	Filter     string
+	Attributes []string
+	SizeLimit  int
+}
+
+// Entry is a search result entry.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string // keyed by lowercased attribute name
+}
+
+// Values returns an attribute's values (attribute names are
+// case-insensitive).
+func (e *Entry) Values(attribute string) []string {
+	return e.Attributes[strings.ToLower(attribute)]
+}
+
+// Search runs a search and returns its entries. Referrals are ignored.
+func (c *Conn) Search(req SearchRequest) ([]*Entry, error) {
+	filter, err := compileFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := newConstructed(tagSequence)
+	for _, attr := range req.Attributes {
+		attributes.add(newString(attr))
+	}
+
+	timeLimit := int64(c.timeout / time.Second)
+	op := newConstructed(opSearchRequest,
+		newString(req.BaseDN),
+		newInteger(tagEnumerated, int64(req.Scope)),
+		newInteger(tagEnumerated, 0), // neverDerefAliases
+		newInteger(tagInteger, int64(req.SizeLimit)),
+		newInteger(tagInteger, timeLimit),
+		newBool(false),
+		filter,
+		attributes,
+	)
+
+	id, err := c.write(op)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for {
+		resp, err := c.read(id)
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.Tag {
+		case opSearchEntry:
+			entry, err := parseEntry(resp)
+			if err != nil {
+				c.broken = true
+				return nil, err
+			}
+			entries = append(entries, entry)
+
+		case opSearchReference:
+			// Referrals are not followed
+
+		case opSearchDone:
+			if err := resultError(resp); err != nil {
+				return entries, err
+			}
+			return entries, nil
+
+		default:
+			c.broken = true
+			return nil, fmt.Errorf("unexpected search response tag 0x%02x", resp.Tag)
+		}
+	}
+}
+
+// startTLS upgrades the connection to TLS.
+func (c *Conn) startTLS(config *tls.Config) error {
+	req := newConstructed(opExtendedRequest, newPacket(tagRequestName, []byte(startTLSOID)))
+	resp, err := c.roundTrip(req, opExtendedResponse)
+	if err != nil {
+		return fmt.Errorf("StartTLS failed: %w", err)
+	}
+	if err := resultError(resp); err != nil {
+		return fmt.Errorf("StartTLS refused: %w", err)
+	}
+
+	tlsConn := tls.Client(c.conn, config)
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		c.broken = true
+		return fmt.Errorf("StartTLS handshake failed: %w", err)
+	}
+	c.conn.SetDeadline(time.Time{})
+
+	c.conn = tlsConn
+	c.reader = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// ============================================================================
+// Message framing
+// ============================================================================
+
+// roundTrip sends an operation and reads a single response of the given tag.
+func (c *Conn) roundTrip(op *packet, responseTag byte) (*packet, error) {
+	id, err := c.write(op)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.read(id)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Tag != responseTag {
+		c.broken = true
+		return nil, fmt.Errorf("unexpected response tag 0x%02x", resp.Tag)
+	}
+	return resp, nil
+}
+
+// write sends op in a new LDAPMessage, returning its message ID.
+func (c *Conn) write(op *packet) (int64, error) {
+	if c.broken {
+		return 0, fmt.Errorf("connection is closed")
+	}
+	c.nextID++
+	id := c.nextID
+
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	msg := newConstructed(tagSequence, newInteger(tagInteger, id), op)
+	if err := c.send(msg); err != nil {
+		c.broken = true
+		return 0, err
+	}
+	return id, nil
+}
+
+// send writes a packet to the connection.
+func (c *Conn) send(p *packet) error {
+	_, err := c.conn.Write(p.bytes())
+	return err
+}
+
+// read returns the protocol operation of the next message, which must
+// carry message ID id.
+func (c *Conn) read(id int64) (*packet, error) {
+	msg, err := readPacket(c.reader)
+	if err != nil {
+		c.broken = true
+		return nil, err
+	}
+	if msg.Tag != tagSequence || len(msg.Children) < 2 {
+		c.broken = true
+		return nil, fmt.Errorf("malformed LDAP message")
+	}
+
+	msgID, err := decodeInt(msg.Children[0].Value)
+	if err != nil || msgID != id {
+		// A message ID of 0 is an unsolicited notification, typically
+		// the server disconnecting
+		c.broken = true
+		return nil, fmt.Errorf("unexpected message ID %d", msgID)
+	}
+	return msg.Children[1], nil
+}
+
+// resultError converts an LDAPResult to an error (nil on success).
+func resultError(resp *packet) error {
+	if len(resp.Children) < 3 {
+		return fmt.Errorf("malformed LDAP result")
+	}
+	code, err := decodeInt(resp.Children[0].Value)
+	if err != nil {
+		return fmt.Errorf("malformed LDAP result: %w", err)
+	}
+	if code == ResultSuccess {
+		return nil
+	}
+	return &Error{ResultCode: int(code), Message: string(resp.Children[2].Value)}
+}
+
+// parseEntry decodes a SearchResultEntry.
+func parseEntry(resp *packet) (*Entry, error) {
+	if len(resp.Children) < 2 {
+		return nil, fmt.Errorf("malformed search entry")
+	}
+
+	entry := &Entry{
+		DN:         string(resp.Children[0].Value),
+		Attributes: make(map[string][]string),
+	}
+	for _, attr := range resp.Children[1].Children {
+		if len(attr.Children) < 2 {
+			return nil, fmt.Errorf("malformed search entry attribute")
+		}
+		name := strings.ToLower(string(attr.Children[0].Value))
+		for _, value := range attr.Children[1].Children {
+			entry.Attributes[name] = append(entry.Attributes[name], string(value.Value))
+		}
+	}
+	return entry, nil
+}
+
+// ============================================================================
+// Pool
+// ============================================================================
+
+// Pool reuses connections to one server and bounds how many are open.
+//
+// Connections are handed out as-is: callers must Bind before anything
+// identity-sensitive, since the previous user's bind persists.
+type Pool struct {
+	config      DialConfig
+	idleTimeout time.Duration
+	slots       chan struct{} // one token per open connection
+
+	mu     sync.Mutex
+	idle   []idleConn
+	closed bool
+}
+
+// idleConn is a pooled connection and when it was returned.
+type idleConn struct {
+	conn  *Conn
+	since time.Time
+}
+
+// NewPool creates a pool of at most size connections. Idle connections
+// older than idleTimeout are closed instead of reused (servers drop idle
+// clients).
+func NewPool(config DialConfig, size int, idleTimeout time.Duration) *Pool {
+	if size <= 0 {
+		size = 1
+	}
+	return &Pool{
+		config:      config,
+		idleTimeout: idleTimeout,
+		slots:       make(chan struct{}, size),
+	}
+}
+
+// Get returns an idle connection or dials a new one, waiting for a free
+// slot if the pool is at capacity.
+func (p *Pool) Get(ctx context.Context) (*Conn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.slots
+		return nil, fmt.Errorf("ldap pool is closed")
+	}
+	for len(p.idle) > 0 {
+		last := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if p.idleTimeout > 0 && time.Since(last.since) > p.idleTimeout {
+			last.conn.Close()
+			continue
+		}
+		p.mu.Unlock()
+		return last.conn, nil
+	}
+	p.mu.Unlock()
+
+	conn, err := Dial(ctx, p.config)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Put returns a connection obtained from Get. Broken connections are
+// closed.
+func (p *Pool) Put(conn *Conn) {
+	defer func() { <-p.slots }()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if conn.Broken() || p.closed {
+		conn.Close()
+		return
+	}
+	p.idle = append(p.idle, idleConn{conn: conn, since: time.Now()})
+}
+
+// Close closes idle connections; connections in use are closed when
+// returned.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	for _, idle := range p.idle {
+		idle.conn.Close()
+	}
+	p.idle = nil
+}
@@ -0,0 +1,231 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ============================================================================
+// Search filters (RFC 4515)
+// ============================================================================
+
+// Filter CHOICE tags.
+const (
+	filterAnd            = classContext | constructed | 0
+	filterOr             = classContext | constructed | 1
+	filterNot            = classContext | constructed | 2
+	filterEquality       = classContext | constructed | 3
+	filterSubstrings     = classContext | constructed | 4
+	filterGreaterOrEqual = classContext | constructed | 5
+	filterLessOrEqual    = classContext | constructed | 6
+	filterPresent        = classContext | 7
+	filterApprox         = classContext | constructed | 8
+)
+
+// Substring choice tags.
+const (
+	substringInitial = classContext | 0
+	substringAny     = classContext | 1
+	substringFinal   = classContext | 2
+)
+
+// compileFilter parses a string filter such as
+// "(&(objectClass=person)(uid=jdoe))". Extensible matches are not
+// supported.
+func compileFilter(filter string) (*packet, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return nil, fmt.Errorf("empty filter")
+	}
+	if filter[0] != '(' {
+		// Tolerate the common unparenthesized form "uid=jdoe"
+		filter = "(" + filter + ")"
+	}
+
+	p, rest, err := parseFilter(filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", filter, err)
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("invalid filter %q: trailing data", filter)
+	}
+	return p, nil
+}
+
+// ValidateFilter reports whether filter is a valid (supported) search
+// filter.
+func ValidateFilter(filter string) error {
+	_, err := compileFilter(filter)
+	return err
+}
+
+// parseFilter parses one parenthesized filter, returning the remainder.
+func parseFilter(s string) (*packet, string, error) {
+	if len(s) < 2 || s[0] != '(' {
+		return nil, "", fmt.Errorf("expected '('")
+	}
+	s = s[1:]
+
+	var p *packet
+	var err error
+	switch s[0] {
+	case '&', '|':
+		tag := byte(filterAnd)
+		if s[0] == '|' {
+			tag = filterOr
+		}
+		p = newConstructed(tag)
+		s = s[1:]
+		for len(s) > 0 && s[0] == '(' {
+			var child *packet
+			if child, s, err = parseFilter(s); err != nil {
+				return nil, "", err
+			}
+			p.add(child)
+		}
+		if len(p.Children) == 0 {
+			return nil, "", fmt.Errorf("empty filter list")
+		}
+
+	case '!':
+		var child *packet
+		if child, s, err = parseFilter(s[1:]); err != nil {
+			return nil, "", err
+		}
+		p = newConstructed(filterNot, child)
+
+	default:
+		end := strings.IndexByte(s, ')')
+		if end < 0 {
+			return nil, "", fmt.Errorf("missing ')'")
+		}
+		if p, err = parseItem(s[:end]); err != nil {
+			return nil, "", err
+		}
+		s = s[end:]
+	}
+
+	if len(s) == 0 || s[0] != ')' {
+		return nil, "", fmt.Errorf("missing ')'")
+	}
+	return p, s[1:], nil
+}
+
+// parseItem parses "attr=value", "attr>=value", "attr=*", "attr=a*b*c" etc.
+func parseItem(item string) (*packet, error) {
+	eq := strings.IndexByte(item, '=')
+	if eq <= 0 {
+		return nil, fmt.Errorf("invalid item %q", item)
+	}
+
+	attr, value := item[:eq], item[eq+1:]
+	tag := byte(filterEquality)
+	switch attr[len(attr)-1] {
+	case '>':
+		tag, attr = filterGreaterOrEqual, attr[:len(attr)-1]
+	case '<':
+		tag, attr = filterLessOrEqual, attr[:len(attr)-1]
+	case '~':
+		tag, attr = filterApprox, attr[:len(attr)-1]
+	case ':':
+		return nil, fmt.Errorf("extensible match is not supported")
+	}
+	if attr == "" {
+		return nil, fmt.Errorf("invalid item %q", item)
+	}
+
+	if tag == filterEquality && value == "*" {
+		return newPacket(filterPresent, []byte(attr)), nil
+	}
+
+	if tag == filterEquality && strings.Contains(value, "*") {
+		parts := strings.Split(value, "*")
+		subs := newConstructed(tagSequence)
+		for i, part := range parts {
+			if part == "" {
+				continue
+			}
+			decoded, err := unescapeValue(part)
+			if err != nil {
+				return nil, err
+			}
+			subTag := byte(substringAny)
+			switch i {
+			case 0:
+				subTag = substringInitial
+			case len(parts) - 1:
+				subTag = substringFinal
+			}
+			subs.add(newPacket(subTag, decoded))
+		}
+		return newConstructed(filterSubstrings, newString(attr), subs), nil
+	}
+
+	decoded, err := unescapeValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return newConstructed(tag, newString(attr), newPacket(tagOctetString, decoded)), nil
+}
+
+// unescapeValue decodes \XX hex escapes in a filter value.
+func unescapeValue(value string) ([]byte, error) {
+	out := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			out = append(out, value[i])
+			continue
+		}
+		if i+2 >= len(value) {
+			return nil, fmt.Errorf("truncated escape in %q", value)
+		}
+		b, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid escape in %q", value)
+		}
+		out = append(out, b[0])
+		i += 2
+	}
+	return out, nil
+}
+
+// EscapeFilter escapes a value for use in a search filter, so user input
+// cannot change the filter's structure (RFC 4515 section 3).
+func EscapeFilter(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// EscapeDN escapes a value for use as an attribute value in a DN
+// (RFC 4514 section 2.4).
+func EscapeDN(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == 0:
+			b.WriteString(`\00`)
+		case strings.IndexByte(`"+,;<>\=`, c) >= 0:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case i == 0 && (c == ' ' || c == '#'):
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case i == len(value)-1 && c == ' ':
+			b.WriteString(`\ `)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,270 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEncodeInt(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{3, []byte{0x03}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x00, 0x80}},
+		{256, []byte{0x01, 0x00}},
+		{-1, []byte{0xff}},
+		{-129, []byte{0xff, 0x7f}},
+	}
+
+	for _, tt := range tests {
+		got := encodeInt(tt.n)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("encodeInt(%d) = %x, want %x", tt.n, got, tt.want)
+		}
+		back, err := decodeInt(got)
+		if err != nil || back != tt.n {
+			t.Errorf("decodeInt(%x) = %d, %v; want %d", got, back, err, tt.n)
+		}
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	long := bytes.Repeat([]byte("x"), 300) // forces a long-form length
+	msg := newConstructed(tagSequence,
+		newInteger(tagInteger, 7),
+		newConstructed(opBindRequest, newString("cn=a"), newPacket(tagSimpleAuth, long)),
+	)
+
+	decoded, err := readPacket(bufio.NewReader(bytes.NewReader(msg.bytes())))
+	if err != nil {
+		t.Fatalf("readPacket: %v", err)
+	}
+	if len(decoded.Children) != 2 {
+		t.Fatalf("children = %d, want 2", len(decoded.Children))
+	}
+	bind := decoded.Children[1]
+	if bind.Tag != opBindRequest || string(bind.Children[0].Value) != "cn=a" {
+		t.Errorf("unexpected bind request: %+v", bind)
+	}
+	if !bytes.Equal(bind.Children[1].Value, long) {
+		t.Errorf("long value not preserved")
+	}
+}
+
+func TestCompileFilter(t *testing.T) {
+	valid := []string{
+		"(uid=jdoe)",
+		"uid=jdoe",
+		"(&(objectClass=person)(|(uid=jdoe)(mail=jdoe@example.com)))",
+		"(!(disabled=TRUE))",
+		"(cn=*)",
+		"(cn=ad*in*s)",
+		"(uidNumber>=1000)",
+		`(cn=a\2ab)`,
+	}
+	for _, f := range valid {
+		if err := ValidateFilter(f); err != nil {
+			t.Errorf("ValidateFilter(%q) = %v, want nil", f, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"(uid=jdoe",
+		"(&)",
+		"(=jdoe)",
+		"(uid=jdoe))",
+		`(cn=a\2)`,
+		"(cn:dn:=x)",
+	}
+	for _, f := range invalid {
+		if err := ValidateFilter(f); err == nil {
+			t.Errorf("ValidateFilter(%q) = nil, want error", f)
+		}
+	}
+}
+
+func TestCompileFilter_Encoding(t *testing.T) {
+	p, err := compileFilter("(cn=ad*in*s)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Tag != filterSubstrings {
+		t.Fatalf("tag = 0x%02x, want substrings", p.Tag)
+	}
+	subs := p.Children[1].Children
+	if len(subs) != 3 || subs[0].Tag != substringInitial || subs[1].Tag != substringAny || subs[2].Tag != substringFinal {
+		t.Errorf("unexpected substrings: %+v", subs)
+	}
+
+	p, err = compileFilter("(cn=*)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Tag != filterPresent || string(p.Value) != "cn" {
+		t.Errorf("unexpected present filter: %+v", p)
+	}
+}
+
+func TestEscapeFilter(t *testing.T) {
+	got := EscapeFilter("*)(uid=*")
+	want := `\2a\29\28uid=\2a`
+	if got != want {
+		t.Errorf("EscapeFilter = %q, want %q", got, want)
+	}
+
+	// An escaped value must stay a single equality item
+	p, err := compileFilter("(uid=" + EscapeFilter("a*)(|(x=y") + ")")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Tag != filterEquality || string(p.Children[1].Value) != "a*)(|(x=y" {
+		t.Errorf("escaped filter changed structure: %+v", p)
+	}
+}
+
+func TestEscapeDN(t *testing.T) {
+	tests := map[string]string{
+		"jdoe":         "jdoe",
+		"doe, john":    `doe\, john`,
+		"a+b=c":        `a\+b\=c`,
+		"#admin":       `\#admin`,
+		" padded ":     `\ padded\ `,
+		`quote"back\\`: `quote\"back\\\\`,
+	}
+	for in, want := range tests {
+		if got := EscapeDN(in); got != want {
+			t.Errorf("EscapeDN(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// fakeServer answers bind and search requests on one side of a pipe.
+//
+// Binds succeed only for users[dn] == password. Searches return entries.
+func fakeServer(t *testing.T, conn net.Conn, users map[string]string, entries []*Entry) {
+	t.Helper()
+	reader := bufio.NewReader(conn)
+
+	go func() {
+		defer conn.Close()
+		for {
+			msg, err := readPacket(reader)
+			if err != nil {
+				return
+			}
+			id := msg.Children[0].Value
+			op := msg.Children[1]
+
+			reply := func(p *packet) {
+				conn.Write(newConstructed(tagSequence, newPacket(tagInteger, id), p).bytes())
+			}
+			result := func(tag byte, code int64) *packet {
+				return newConstructed(tag, newInteger(tagEnumerated, code), newString(""), newString(""))
+			}
+
+			switch op.Tag {
+			case opBindRequest:
+				dn := string(op.Children[1].Value)
+				password := string(op.Children[2].Value)
+				code := int64(ResultInvalidCredentials)
+				if want, ok := users[dn]; ok && want == password {
+					code = ResultSuccess
+				}
+				reply(result(opBindResponse, code))
+
+			case opSearchRequest:
+				for _, e := range entries {
+					attrs := newConstructed(tagSequence)
+					for name, values := range e.Attributes {
+						vals := newConstructed(tagSet)
+						for _, v := range values {
+							vals.add(newString(v))
+						}
+						attrs.add(newConstructed(tagSequence, newString(name), vals))
+					}
+					reply(newConstructed(opSearchEntry, newString(e.DN), attrs))
+				}
+				reply(result(opSearchDone, ResultSuccess))
+
+			case opUnbindRequest:
+				return
+			}
+		}
+	}()
+}
+
+func TestConn_BindAndSearch(t *testing.T) {
+	client, server := net.Pipe()
+	fakeServer(t, server,
+		map[string]string{"uid=jdoe,ou=people,dc=example,dc=com": "secret"},
+		[]*Entry{{
+			DN: "uid=jdoe,ou=people,dc=example,dc=com",
+			Attributes: map[string][]string{
+				"memberOf": {"cn=admins,ou=groups,dc=example,dc=com", "cn=dev,ou=groups,dc=example,dc=com"},
+			},
+		}},
+	)
+
+	conn := NewConn(client, 2*time.Second)
+	defer conn.Close()
+
+	err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "wrong")
+	if !IsResultCode(err, ResultInvalidCredentials) {
+		t.Fatalf("Bind with wrong password = %v, want invalid credentials", err)
+	}
+	if conn.Broken() {
+		t.Fatal("connection marked broken after a failed bind")
+	}
+
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "secret"); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+
+	entries, err := conn.Search(SearchRequest{
+		BaseDN:     "dc=example,dc=com",
+		Scope:      ScopeWholeSubtree,
+		Filter:     "(uid=jdoe)",
+		Attributes: []string{"memberOf"},
+	})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("entries = %d, want 1", len(entries))
+	}
+	if groups := entries[0].Values("MEMBEROF"); len(groups) != 2 {
+		t.Errorf("memberOf = %v, want 2 values", groups)
+	}
+}
+
+func TestConn_EmptyPasswordRejected(t *testing.T) {
+	client, server := net.Pipe()
+	fakeServer(t, server, nil, nil)
+
+	conn := NewConn(client, time.Second)
+	defer conn.Close()
+
+	if err := conn.Bind("uid=jdoe,dc=example,dc=com", ""); !IsResultCode(err, ResultInvalidCredentials) {
+		t.Errorf("Bind with empty password = %v, want invalid credentials", err)
+	}
+}
+
+func TestConn_BrokenAfterServerClose(t *testing.T) {
+	client, server := net.Pipe()
+	server.Close()
+
+	conn := NewConn(client, time.Second)
+	if err := conn.Bind("cn=a", "b"); err == nil {
+		t.Fatal("Bind on a closed connection succeeded")
+	}
+	if !conn.Broken() {
+		t.Error("connection not marked broken")
+	}
+}
@@ -0,0 +1,159 @@
+// Package loadbalancer provides target selection for services with multiple
+// backend instances.
+//
+// A service can have several targets (rows in the service_targets table).
+// For every proxied request the balancer picks one of them according to the
+// service's load_balancer_type:
+//   - round-robin: Rotate through targets in order (default)
+//   - consistent-hash: Stable key → target mapping using a ketama ring
+//     with bounded load (see ConsistentHash)
+//   - locality-aware: Prefer targets in the gateway's own region, spilling
+//     over to other regions when none is available (see LocalityAware)
+//
+// Targets that misbehave (high 5xx rate or latency far above their peers)
+// are temporarily ejected by the OutlierDetector and skipped by all
+// balancers until the ejection expires. Services with a health_check are
+// also probed by the HealthChecker, which takes failing targets out of
+// rotation until they pass again.
+//
+// Balancers are owned by a Manager, which rebuilds them on hot reload while
+// preserving state (ring positions, in-flight counters) for unchanged targets.
+package loadbalancer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoTargets is returned when a service has no targets to choose from.
+var ErrNoTargets = errors.New("no targets available")
+
+// Load balancer types (matches services.load_balancer_type).
+const (
+	TypeRoundRobin       = "round-robin"
+	TypeLeastConnections = "least-connections"
+	TypeWeighted         = "weighted"
+	TypeIPHash           = "ip-hash"
+	TypeConsistentHash   = "consistent-hash"
+	TypeLocalityAware    = "locality-aware"
+)
+
+// Target is a single backend instance a request can be sent to.
+type Target struct {
+	// ID is the service_targets row ID.
+	ID string
+
+	// Address is the "host:port" of the backend instance.
+	Address string
+
+	// Weight is the relative weight of this target (default 100).
+	Weight int
+
+	// Region is the region or zone the target runs in ("" = unknown).
+	Region string
+
+	// HealthCheckPath is the path probed by HTTP health checks.
+	HealthCheckPath string
+
+	// inFlight counts requests currently being served by this target.
+	inFlight int64
+
+	// ejectedUntil is the Unix nano time until which the target is ejected
+	// by outlier detection (0 = not ejected).
+	ejectedUntil int64
+
+	// outlier holds the passive health statistics for outlier detection.
+	outlier outlierStats
+
+	// down is 1 while active health checks fail.
+	down int32
+
+	// health holds the active health check streaks.
+	health healthStats
+}
+
+// NewTarget creates a new target.
+func NewTarget(id, address string, weight int) *Target {
+	if weight <= 0 {
+		weight = 100
+	}
+	return &Target{
+		ID:      id,
+		Address: address,
+		Weight:  weight,
+	}
+}
+
+// InFlight returns the number of requests currently being served by the target.
+func (t *Target) InFlight() int64 {
+	return atomic.LoadInt64(&t.inFlight)
+}
+
+// Ejected reports whether the target is currently ejected by outlier detection.
+func (t *Target) Ejected() bool {
+	until := atomic.LoadInt64(&t.ejectedUntil)
+	return until != 0 && time.Now().UnixNano() < until
+}
+
+// Down reports whether the target is failing active health checks.
+func (t *Target) Down() bool {
+	return atomic.LoadInt32(&t.down) == 1
+}
+
+// Available reports whether balancers should pick the target: it is
+// neither ejected nor down.
+func (t *Target) Available() bool {
+	return !t.Ejected() && !t.Down()
+}
+
+// eject marks the target as ejected until the given time.
+func (t *Target) eject(until time.Time) {
+	atomic.StoreInt64(&t.ejectedUntil, until.UnixNano())
+}
+
+// String implements fmt.Stringer.
+func (t *Target) String() string {
+	if t.Region != "" {
+		return fmt.Sprintf("%s (weight=%d, region=%s)", t.Address, t.Weight, t.Region)
+	}
+	return fmt.Sprintf("%s (weight=%d)", t.Address, t.Weight)
+}
+
+// Balancer selects a target for each request.
+//
+// Implementations must be safe for concurrent use. Every successful call to
+// Next must be paired with a call to Done once the upstream request finishes,
+// so load-aware balancers can track in-flight requests.
+type Balancer interface {
+	// Type returns the load balancer type (e.g., "round-robin").
+	Type() string
+
+	// Next picks a target for the request.
+	// pathParams are the parameters extracted by the router (may be nil).
+	Next(r *http.Request, pathParams map[string]string) (*Target, error)
+
+	// Done marks a request to the target as finished.
+	Done(t *Target)
+
+	// Update replaces the set of targets (used on hot reload).
+	Update(targets []*Target)
+
+	// Targets returns the current set of targets.
+	Targets() []*Target
+}
+
+// acquire increments the in-flight counter of a target.
+func acquire(t *Target) {
+	atomic.AddInt64(&t.inFlight, 1)
+}
+
+// release decrements the in-flight counter of a target.
+func release(t *Target) {
+	if t == nil {
+		return
+	}
+	atomic.AddInt64(&t.inFlight, -1)
+}
@@ -0,0 +1,268 @@
+// Package loadbalancer - Consistent hashing with bounded load
+//
+// Consistent Hashing (ketama):
+//   - Each target is placed on a hash ring many times ("virtual nodes")
+//   - A request key (IP, header, cookie, path param) is hashed onto the ring
+//   - The first target clockwise from the key's position serves the request
+//   - Adding/removing a target only remaps ~1/n of the keys
+//
+// Bounded Load (Mirrokni et al., "Consistent Hashing with Bounded Loads"):
+//   - No target may have more than ceil(c * average load) in-flight requests
+//   - If the preferred target is full, walk the ring to the next one
+//   - Prevents hot keys from overloading a single target
+//
+// Use Cases:
+//   - Cache-friendly backends (same key → same target → warm local cache)
+//   - Sticky sessions without cookies managed by the gateway
+package loadbalancer
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/saidutt46/switchboard-gateway/internal/clientip"
+)
+
+// Hash key sources (matches services.hash_on).
+const (
+	HashOnIP        = "ip"
+	HashOnHeader    = "header"
+	HashOnCookie    = "cookie"
+	HashOnPathParam = "path-param"
+)
+
+// ConsistentHashConfig holds configuration for the consistent hash balancer.
+type ConsistentHashConfig struct {
+	// HashOn selects where the hash key comes from.
+	// Options: "ip", "header", "cookie", "path-param"
+	// Default: "ip"
+	HashOn string
+
+	// HashOnKey is the header name, cookie name, or path parameter name.
+	// Ignored when HashOn is "ip".
+	HashOnKey string
+
+	// BalanceFactor is the load bound multiplier (c).
+	// A target may serve at most ceil(c * average load) in-flight requests.
+	// Must be >= 1. Lower = more even load, higher = more stable mapping.
+	// Default: 1.25
+	BalanceFactor float64
+
+	// Replicas is the number of virtual nodes per target at weight 100.
+	// Default: 160 (same as libketama)
+	Replicas int
+}
+
+// DefaultConsistentHashConfig returns sensible defaults.
+func DefaultConsistentHashConfig() ConsistentHashConfig {
+	return ConsistentHashConfig{
+		HashOn:        HashOnIP,
+		BalanceFactor: 1.25,
+		Replicas:      160,
+	}
+}
+
+// ringPoint is a virtual node on the hash ring.
+type ringPoint struct {
+	hash   uint32
+	target *Target
+}
+
+// ConsistentHash implements a ketama-style consistent hash ring with bounded load.
+type ConsistentHash struct {
+	config ConsistentHashConfig
+
+	mu      sync.RWMutex
+	targets []*Target
+	ring    []ringPoint
+	weights int
+}
+
+// NewConsistentHash creates a new consistent hash balancer.
+func NewConsistentHash(config ConsistentHashConfig, targets []*Target) *ConsistentHash {
+	defaults := DefaultConsistentHashConfig()
+	if config.HashOn == "" {
+		config.HashOn = defaults.HashOn
+	}
+	if config.BalanceFactor < 1 {
+		config.BalanceFactor = defaults.BalanceFactor
+	}
+	if config.Replicas <= 0 {
+		config.Replicas = defaults.Replicas
+	}
+
+	ch := &ConsistentHash{config: config}
+	ch.Update(targets)
+	return ch
+}
+
+// Type returns the load balancer type.
+func (ch *ConsistentHash) Type() string {
+	return TypeConsistentHash
+}
+
+// Next picks the target owning the request's hash key.
+//
+// If that target is at its load bound, ejected or down, the ring is walked
+// clockwise until a healthy target with spare capacity is found.
+func (ch *ConsistentHash) Next(r *http.Request, pathParams map[string]string) (*Target, error) {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+
+	if len(ch.ring) == 0 {
+		return nil, ErrNoTargets
+	}
+
+	key := ch.hashKey(r, pathParams)
+	hash := hashKey(key)
+
+	// Find first point clockwise from hash
+	idx := sort.Search(len(ch.ring), func(i int) bool {
+		return ch.ring[i].hash >= hash
+	})
+	if idx == len(ch.ring) {
+		idx = 0
+	}
+
+	// Total in-flight load across all targets (+1 for this request)
+	var total int64 = 1
+	for _, t := range ch.targets {
+		total += t.InFlight()
+	}
+
+	// Skip unavailable targets unless every target is unavailable
+	skipUnavailable := false
+	for _, t := range ch.targets {
+		if t.Available() {
+			skipUnavailable = true
+			break
+		}
+	}
+
+	// Walk the ring until we find a target under its bound
+	var fallback *Target
+	checked := make(map[*Target]bool, len(ch.targets))
+	for i := 0; i < len(ch.ring) && len(checked) < len(ch.targets); i++ {
+		point := ch.ring[(idx+i)%len(ch.ring)]
+		if checked[point.target] {
+			continue
+		}
+		checked[point.target] = true
+
+		if skipUnavailable && !point.target.Available() {
+			continue
+		}
+		if fallback == nil {
+			fallback = point.target
+		}
+		if point.target.InFlight() < ch.capacity(point.target, total) {
+			acquire(point.target)
+			return point.target, nil
+		}
+	}
+
+	// Every eligible target is at its bound (can only happen with concurrent
+	// updates or ejections). Fall back to the preferred eligible target
+	// rather than failing the request.
+	if fallback == nil {
+		fallback = ch.ring[idx].target
+	}
+	acquire(fallback)
+	return fallback, nil
+}
+
+// capacity returns the maximum in-flight requests allowed for a target.
+//
+// The bound is proportional to the target's share of the total weight:
+//
+//	capacity = ceil(c * total * weight / sum(weights))
+func (ch *ConsistentHash) capacity(t *Target, total int64) int64 {
+	share := float64(t.Weight) / float64(ch.weights)
+	return int64(math.Ceil(ch.config.BalanceFactor * float64(total) * share))
+}
+
+// hashKey extracts the hash key from the request.
+//
+// Falls back to the client IP when the configured source is missing,
+// so requests without the header/cookie are still spread across targets.
+func (ch *ConsistentHash) hashKey(r *http.Request, pathParams map[string]string) string {
+	switch ch.config.HashOn {
+	case HashOnHeader:
+		if v := r.Header.Get(ch.config.HashOnKey); v != "" {
+			return v
+		}
+	case HashOnCookie:
+		if c, err := r.Cookie(ch.config.HashOnKey); err == nil && c.Value != "" {
+			return c.Value
+		}
+	case HashOnPathParam:
+		if v := pathParams[ch.config.HashOnKey]; v != "" {
+			return v
+		}
+	}
+
+	return clientip.FromRequest(r)
+}
+
+// Done marks a request to the target as finished.
+func (ch *ConsistentHash) Done(t *Target) {
+	release(t)
+}
+
+// Update rebuilds the hash ring for a new set of targets.
+//
+// Ring positions depend only on target addresses, so unchanged targets keep
+// their keys across reloads.
+func (ch *ConsistentHash) Update(targets []*Target) {
+	ring := make([]ringPoint, 0, len(targets)*ch.config.Replicas)
+	weights := 0
+
+	for _, t := range targets {
+		weights += t.Weight
+
+		// Virtual nodes scale with weight (weight 100 = Replicas points).
+		// Each MD5 digest yields 4 ring points, as in libketama.
+		points := ch.config.Replicas * t.Weight / 100
+		if points < 4 {
+			points = 4
+		}
+		for i := 0; i < points/4; i++ {
+			digest := md5.Sum([]byte(fmt.Sprintf("%s-%d", t.Address, i)))
+			for j := 0; j < 4; j++ {
+				ring = append(ring, ringPoint{
+					hash:   binary.LittleEndian.Uint32(digest[j*4 : j*4+4]),
+					target: t,
+				})
+			}
+		}
+	}
+
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+
+	ch.mu.Lock()
+	ch.targets = targets
+	ch.ring = ring
+	ch.weights = weights
+	ch.mu.Unlock()
+}
+
+// Targets returns the current set of targets.
+func (ch *ConsistentHash) Targets() []*Target {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+
+	return ch.targets
+}
+
+// hashKey hashes a key onto the ring (first 4 bytes of MD5, as in libketama).
+func hashKey(key string) uint32 {
+	digest := md5.Sum([]byte(key))
+	return binary.LittleEndian.Uint32(digest[0:4])
+}
@@ -0,0 +1,216 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestTargets(n int) []*Target {
+	targets := make([]*Target, 0, n)
+	for i := 0; i < n; i++ {
+		targets = append(targets, NewTarget(fmt.Sprintf("t%d", i), fmt.Sprintf("10.0.0.%d:8080", i+1), 100))
+	}
+	return targets
+}
+
+func requestWithHeader(key string) *http.Request {
+	r := httptest.NewRequest("GET", "/api/users", nil)
+	r.Header.Set("X-User-ID", key)
+	return r
+}
+
+func TestConsistentHash_StableMapping(t *testing.T) {
+	config := DefaultConsistentHashConfig()
+	config.HashOn = HashOnHeader
+	config.HashOnKey = "X-User-ID"
+	ch := NewConsistentHash(config, newTestTargets(3))
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("user-%d", i)
+
+		first, err := ch.Next(requestWithHeader(key), nil)
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		ch.Done(first)
+
+		second, _ := ch.Next(requestWithHeader(key), nil)
+		ch.Done(second)
+
+		if first != second {
+			t.Errorf("key %s mapped to %s then %s", key, first.Address, second.Address)
+		}
+	}
+}
+
+func TestConsistentHash_MinimalRemapOnRemoval(t *testing.T) {
+	config := DefaultConsistentHashConfig()
+	config.HashOn = HashOnHeader
+	config.HashOnKey = "X-User-ID"
+	targets := newTestTargets(4)
+	ch := NewConsistentHash(config, targets)
+
+	const keys = 1000
+	before := make(map[string]*Target, keys)
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		target, _ := ch.Next(requestWithHeader(key), nil)
+		ch.Done(target)
+		before[key] = target
+	}
+
+	// Remove the last target
+	removed := targets[3]
+	ch.Update(targets[:3])
+
+	for key, old := range before {
+		target, _ := ch.Next(requestWithHeader(key), nil)
+		ch.Done(target)
+
+		if old != removed && target != old {
+			t.Errorf("key %s moved from %s to %s although its target was not removed", key, old.Address, target.Address)
+		}
+		if target == removed {
+			t.Errorf("key %s still mapped to removed target", key)
+		}
+	}
+}
+
+func TestConsistentHash_BoundedLoad(t *testing.T) {
+	config := DefaultConsistentHashConfig()
+	config.HashOn = HashOnHeader
+	config.HashOnKey = "X-User-ID"
+	targets := newTestTargets(4)
+	ch := NewConsistentHash(config, targets)
+
+	// Hold every request open; all share one hot key
+	const requests = 100
+	for i := 0; i < requests; i++ {
+		if _, err := ch.Next(requestWithHeader("hot-key"), nil); err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+	}
+
+	// No target may exceed ceil(c * total / n)
+	limit := int64(requests*config.BalanceFactor/float64(len(targets))) + 1
+	for _, target := range targets {
+		if target.InFlight() > limit {
+			t.Errorf("target %s has %d in-flight, want <= %d", target.Address, target.InFlight(), limit)
+		}
+	}
+
+	for _, target := range targets {
+		for target.InFlight() > 0 {
+			ch.Done(target)
+		}
+	}
+}
+
+func TestConsistentHash_KeySources(t *testing.T) {
+	tests := []struct {
+		name       string
+		hashOn     string
+		hashOnKey  string
+		request    func(key string) *http.Request
+		pathParams func(key string) map[string]string
+	}{
+		{
+			name:      "header",
+			hashOn:    HashOnHeader,
+			hashOnKey: "X-User-ID",
+			request:   requestWithHeader,
+		},
+		{
+			name:      "cookie",
+			hashOn:    HashOnCookie,
+			hashOnKey: "session",
+			request: func(key string) *http.Request {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.AddCookie(&http.Cookie{Name: "session", Value: key})
+				return r
+			},
+		},
+		{
+			name:      "path param",
+			hashOn:    HashOnPathParam,
+			hashOnKey: "id",
+			request: func(key string) *http.Request {
+				return httptest.NewRequest("GET", "/api/users/"+key, nil)
+			},
+			pathParams: func(key string) map[string]string {
+				return map[string]string{"id": key}
+			},
+		},
+		{
+			name:   "ip",
+			hashOn: HashOnIP,
+			request: func(key string) *http.Request {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.RemoteAddr = key + ":1234"
+				return r
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConsistentHashConfig()
+			config.HashOn = tt.hashOn
+			config.HashOnKey = tt.hashOnKey
+			ch := NewConsistentHash(config, newTestTargets(3))
+
+			// Different keys should spread across more than one target
+			seen := make(map[*Target]bool)
+			for i := 0; i < 50; i++ {
+				key := fmt.Sprintf("192.168.1.%d", i)
+
+				var params map[string]string
+				if tt.pathParams != nil {
+					params = tt.pathParams(key)
+				}
+
+				target, err := ch.Next(tt.request(key), params)
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				ch.Done(target)
+				seen[target] = true
+			}
+
+			if len(seen) < 2 {
+				t.Errorf("all keys mapped to %d target(s), want spread across targets", len(seen))
+			}
+		})
+	}
+}
+
+func TestConsistentHash_NoTargets(t *testing.T) {
+	ch := NewConsistentHash(DefaultConsistentHashConfig(), nil)
+
+	if _, err := ch.Next(httptest.NewRequest("GET", "/", nil), nil); err != ErrNoTargets {
+		t.Errorf("Next() error = %v, want ErrNoTargets", err)
+	}
+}
+
+func TestRoundRobin_Rotation(t *testing.T) {
+	targets := newTestTargets(3)
+	rr := NewRoundRobin(targets)
+
+	counts := make(map[*Target]int)
+	for i := 0; i < 30; i++ {
+		target, err := rr.Next(httptest.NewRequest("GET", "/", nil), nil)
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		rr.Done(target)
+		counts[target]++
+	}
+
+	for _, target := range targets {
+		if counts[target] != 10 {
+			t.Errorf("target %s got %d requests, want 10", target.Address, counts[target])
+		}
+	}
+}
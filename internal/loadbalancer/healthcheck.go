@@ -0,0 +1,535 @@
+// Package loadbalancer - Active health checks
+//
+// Active health checking:
+//   - Every target of a service with a health_check is probed every interval
+//   - http and https services: GET the target's health_check_path; any 2xx
+//     or 3xx is a pass
+//   - grpc services: the standard grpc.health.v1.Health/Check RPC, asking
+//     for grpc_service ("" = the server as a whole); SERVING is a pass
+//   - Probes go over TLS for https services and with "tls": true, checking
+//     the certificate against tls_server_name (default: the target host)
+//     unless tls_skip_verify. Plain-text gRPC probes use HTTP/2 without TLS
+//     (h2c), like the proxy
+//   - unhealthy_threshold failed probes in a row mark a target down and
+//     healthy_threshold passes in a row bring it back; balancers skip down
+//     targets like ejected ones
+//
+// The gRPC messages are encoded by hand: HealthCheckRequest has a single
+// string field (service = 1) and HealthCheckResponse a single enum
+// (status = 1), each sent in a 5-byte length-prefixed gRPC frame.
+package loadbalancer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/notify"
+)
+
+// Health check defaults.
+const (
+	DefaultHealthCheckTimeout = 2 * time.Second
+	DefaultUnhealthyThreshold = 3
+	DefaultHealthyThreshold   = 2
+	DefaultHealthCheckPath    = "/health"
+)
+
+// grpcHealthCheckMethod is the request path of grpc.health.v1 Check.
+const grpcHealthCheckMethod = "/grpc.health.v1.Health/Check"
+
+// maxHealthResponseBytes caps the probe response bodies read.
+const maxHealthResponseBytes = 64 << 10
+
+// grpcServingStatus names HealthCheckResponse.ServingStatus values.
+var grpcServingStatus = map[uint64]string{
+	0: "UNKNOWN",
+	1: "SERVING",
+	2: "NOT_SERVING",
+	3: "SERVICE_UNKNOWN",
+}
+
+const grpcServing = 1
+
+var (
+	healthChecks = metrics.NewCounterVec(
+		"gateway_health_checks_total",
+		"Active health check probes of targets, by result (pass, fail).",
+		"service_id", "target", "result",
+	)
+	healthCheckDown = metrics.NewGaugeVec(
+		"gateway_health_check_down",
+		"Whether a target is down by active health checks (1) or not (0).",
+		"service_id", "target",
+	)
+)
+
+// healthStats holds the active health check streaks of a target.
+type healthStats struct {
+	mu       sync.Mutex
+	failures int // failed probes in a row
+	passes   int // passed probes in a row
+}
+
+// healthSettings are a service's parsed health check settings.
+type healthSettings struct {
+	interval  time.Duration
+	timeout   time.Duration
+	unhealthy int
+	healthy   int
+
+	grpc    bool
+	service string      // grpc.health.v1 service name
+	tls     *tls.Config // nil = plain text
+}
+
+// parseHealthCheck reads the health check settings of a service.
+func parseHealthCheck(svc *database.Service) (healthSettings, error) {
+	hc := svc.HealthCheck
+	s := healthSettings{
+		timeout:   DefaultHealthCheckTimeout,
+		unhealthy: DefaultUnhealthyThreshold,
+		healthy:   DefaultHealthyThreshold,
+		grpc:      svc.Protocol == "grpc",
+		service:   hc.GRPCService,
+	}
+
+	var err error
+	if s.interval, err = time.ParseDuration(hc.Interval); err != nil || s.interval <= 0 {
+		return s, fmt.Errorf("interval must be a positive duration")
+	}
+	if hc.Timeout != "" {
+		if s.timeout, err = time.ParseDuration(hc.Timeout); err != nil || s.timeout <= 0 {
+			return s, fmt.Errorf("timeout must be a positive duration")
+		}
+	}
+	if hc.UnhealthyThreshold < 0 || hc.HealthyThreshold < 0 {
+		return s, fmt.Errorf("thresholds must be positive")
+	}
+	if hc.UnhealthyThreshold > 0 {
+		s.unhealthy = hc.UnhealthyThreshold
+	}
+	if hc.HealthyThreshold > 0 {
+		s.healthy = hc.HealthyThreshold
+	}
+	if hc.GRPCService != "" && !s.grpc {
+		return s, fmt.Errorf("grpc_service is only used by grpc services")
+	}
+
+	if hc.TLS || svc.Protocol == "https" {
+		s.tls = &tls.Config{
+			ServerName:         hc.TLSServerName,
+			InsecureSkipVerify: hc.TLSSkipVerify, // opted into per service
+			MinVersion:         tls.VersionTLS12,
+		}
+	}
+	return s, nil
+}
+
+// ValidateHealthCheck checks the health check settings of a service.
+func ValidateHealthCheck(svc *database.Service) error {
+	if !svc.HealthCheck.Configured() {
+		return nil
+	}
+	_, err := parseHealthCheck(svc)
+	return err
+}
+
+// HealthChecker probes the targets of services with a health_check and
+// marks failing ones down.
+type HealthChecker struct {
+	// targets returns the current targets of a service (set by the Manager)
+	targets func(serviceID string) []*Target
+
+	// notifier receives target.unhealthy / target.healthy events (nil = disabled)
+	notifier *notify.Dispatcher
+
+	mu    sync.Mutex
+	loops map[string]*healthLoop // service_id -> probe loop
+}
+
+// healthLoop is the probe loop of a service.
+type healthLoop struct {
+	protocol string
+	config   database.ServiceHealthCheck
+	stop     context.CancelFunc
+}
+
+// NewHealthChecker creates a health checker. It probes nothing until set
+// on a Manager (see Manager.SetHealthChecker).
+func NewHealthChecker() *HealthChecker {
+	return &HealthChecker{loops: make(map[string]*healthLoop)}
+}
+
+// SetNotifier sends down and recovery events to n. Must be called before
+// the checker is set on a Manager.
+func (hc *HealthChecker) SetNotifier(n *notify.Dispatcher) {
+	hc.notifier = n
+}
+
+// update starts probing services with a health check and stops probing the
+// others, whose targets are brought back up. Loops of services whose
+// settings are unchanged keep running. balancers are the services' new
+// balancers.
+func (hc *HealthChecker) update(services []*database.Service, balancers map[string]Balancer) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	wanted := make(map[string]bool, len(services))
+	for _, svc := range services {
+		if !svc.HealthCheck.Configured() {
+			continue
+		}
+		settings, err := parseHealthCheck(svc)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("component", "health_checker").
+				Str("service_id", svc.ID).
+				Msg("Invalid health check - targets not probed")
+			continue
+		}
+		wanted[svc.ID] = true
+
+		if loop, ok := hc.loops[svc.ID]; ok {
+			if loop.protocol == svc.Protocol && loop.config == svc.HealthCheck {
+				continue
+			}
+			loop.stop()
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		hc.loops[svc.ID] = &healthLoop{protocol: svc.Protocol, config: svc.HealthCheck, stop: cancel}
+		go hc.run(ctx, svc.ID, settings)
+
+		log.Info().
+			Str("component", "health_checker").
+			Str("service_id", svc.ID).
+			Bool("grpc", settings.grpc).
+			Bool("tls", settings.tls != nil).
+			Dur("interval", settings.interval).
+			Msg("Active health checks started")
+	}
+
+	for serviceID, loop := range hc.loops {
+		if wanted[serviceID] {
+			continue
+		}
+		loop.stop()
+		delete(hc.loops, serviceID)
+		if b, ok := balancers[serviceID]; ok {
+			for _, t := range b.Targets() {
+				hc.reset(serviceID, t)
+			}
+		}
+	}
+}
+
+// run probes a service's targets every interval until ctx is cancelled.
+func (hc *HealthChecker) run(ctx context.Context, serviceID string, s healthSettings) {
+	client := s.client()
+	defer client.CloseIdleConnections()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		hc.probeAll(ctx, client, serviceID, s)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeAll probes every target of a service once, concurrently.
+func (hc *HealthChecker) probeAll(ctx context.Context, client *http.Client, serviceID string, s healthSettings) {
+	var wg sync.WaitGroup
+	for _, t := range hc.targets(serviceID) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := s.probe(ctx, client, t)
+			if ctx.Err() != nil {
+				return // stopped or reconfigured meanwhile
+			}
+			hc.record(serviceID, t, s, err)
+		}()
+	}
+	wg.Wait()
+}
+
+// record updates a target's streaks with a probe result, marking it down
+// or back up when a threshold is reached.
+func (hc *HealthChecker) record(serviceID string, t *Target, s healthSettings, err error) {
+	stats := &t.health
+	stats.mu.Lock()
+	if err != nil {
+		stats.failures++
+		stats.passes = 0
+	} else {
+		stats.passes++
+		stats.failures = 0
+	}
+	failures, passes := stats.failures, stats.passes
+	stats.mu.Unlock()
+
+	if err != nil {
+		healthChecks.Inc(serviceID, t.Address, "fail")
+		log.Debug().
+			Err(err).
+			Str("component", "health_checker").
+			Str("service_id", serviceID).
+			Str("target", t.Address).
+			Int("failures", failures).
+			Msg("Health check failed")
+	} else {
+		healthChecks.Inc(serviceID, t.Address, "pass")
+	}
+
+	switch {
+	case err != nil && failures >= s.unhealthy && atomic.CompareAndSwapInt32(&t.down, 0, 1):
+		healthCheckDown.Set(1, serviceID, t.Address)
+		log.Warn().
+			Err(err).
+			Str("component", "health_checker").
+			Str("service_id", serviceID).
+			Str("target", t.Address).
+			Int("failures", failures).
+			Msg("Target down - failing active health checks")
+
+		hc.notifier.Publish(notify.Event{
+			Type:     notify.EventTargetUnhealthy,
+			Severity: notify.SeverityWarning,
+			Message:  fmt.Sprintf("Target %s down (health_check)", t.Address),
+			Data: map[string]interface{}{
+				"service_id": serviceID,
+				"target":     t.Address,
+				"reason":     "health_check",
+				"error":      err.Error(),
+			},
+		})
+
+	case err == nil && passes >= s.healthy && atomic.CompareAndSwapInt32(&t.down, 1, 0):
+		healthCheckDown.Set(0, serviceID, t.Address)
+		log.Info().
+			Str("component", "health_checker").
+			Str("service_id", serviceID).
+			Str("target", t.Address).
+			Msg("Target passing health checks - back in rotation")
+
+		hc.notifier.Publish(notify.Event{
+			Type:     notify.EventTargetHealthy,
+			Severity: notify.SeverityInfo,
+			Message:  fmt.Sprintf("Target %s back in rotation", t.Address),
+			Data: map[string]interface{}{
+				"service_id": serviceID,
+				"target":     t.Address,
+			},
+		})
+	}
+}
+
+// reset forgets a target's health check state once its service is no
+// longer checked.
+func (hc *HealthChecker) reset(serviceID string, t *Target) {
+	t.health.mu.Lock()
+	t.health.failures, t.health.passes = 0, 0
+	t.health.mu.Unlock()
+
+	if atomic.CompareAndSwapInt32(&t.down, 1, 0) {
+		healthCheckDown.Set(0, serviceID, t.Address)
+	}
+}
+
+// client returns the HTTP client probes of a service are sent with.
+func (s healthSettings) client() *http.Client {
+	transport := &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: s.timeout}).DialContext,
+		TLSClientConfig:     s.tls,
+		TLSHandshakeTimeout: s.timeout,
+		MaxIdleConnsPerHost: 1,
+		IdleConnTimeout:     2 * s.interval,
+	}
+	if s.grpc {
+		// gRPC needs HTTP/2: negotiated over TLS, h2c in plain text
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP2(true)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	return &http.Client{
+		Transport: transport,
+		// A redirect is an answer; following it would probe another host
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// probe checks a target once.
+func (s healthSettings) probe(ctx context.Context, client *http.Client, t *Target) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	scheme := "http"
+	if s.tls != nil {
+		scheme = "https"
+	}
+	if s.grpc {
+		return s.probeGRPC(ctx, client, scheme+"://"+t.Address+grpcHealthCheckMethod)
+	}
+
+	path := t.HealthCheckPath
+	if path == "" {
+		path = DefaultHealthCheckPath
+	}
+	return probeHTTP(ctx, client, scheme+"://"+t.Address+path)
+}
+
+// probeHTTP passes when GET url answers 2xx or 3xx.
+func probeHTTP(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "switchboard-health-check")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxHealthResponseBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// probeGRPC passes when grpc.health.v1 Check at url answers SERVING.
+func (s healthSettings) probeGRPC(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(grpcHealthRequest(s.service)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("User-Agent", "switchboard-health-check")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthResponseBytes))
+	if err != nil {
+		return err
+	}
+
+	// Trailers-only responses (errors) carry the status in the headers
+	code, message := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if code == "" {
+		code, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	switch code {
+	case "0":
+	case "":
+		return errors.New("response has no grpc-status")
+	default:
+		return fmt.Errorf("grpc-status %s: %s", code, message)
+	}
+
+	status, err := parseGRPCHealthResponse(body)
+	if err != nil {
+		return err
+	}
+	if status != grpcServing {
+		name, ok := grpcServingStatus[status]
+		if !ok {
+			name = fmt.Sprintf("%d", status)
+		}
+		return fmt.Errorf("serving status %s", name)
+	}
+	return nil
+}
+
+// grpcHealthRequest returns a framed HealthCheckRequest for service.
+func grpcHealthRequest(service string) []byte {
+	var msg []byte
+	if service != "" {
+		msg = append(msg, 1<<3|2) // field 1, length-delimited
+		msg = binary.AppendUvarint(msg, uint64(len(service)))
+		msg = append(msg, service...)
+	}
+
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+// parseGRPCHealthResponse returns the status of a framed
+// HealthCheckResponse. A missing status is UNKNOWN (0).
+func parseGRPCHealthResponse(body []byte) (uint64, error) {
+	if len(body) < 5 {
+		return 0, errors.New("truncated gRPC response")
+	}
+	if body[0] != 0 {
+		return 0, errors.New("compressed gRPC response")
+	}
+	length := binary.BigEndian.Uint32(body[1:5])
+	if uint64(length) > uint64(len(body)-5) {
+		return 0, errors.New("truncated gRPC response")
+	}
+	msg := body[5 : 5+int(length)]
+
+	var status uint64
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return 0, errors.New("invalid HealthCheckResponse")
+		}
+		msg = msg[n:]
+
+		switch tag & 7 {
+		case 0: // varint
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return 0, errors.New("invalid HealthCheckResponse")
+			}
+			if tag>>3 == 1 {
+				status = v
+			}
+			msg = msg[n:]
+		case 2: // length-delimited (unknown fields)
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || size > uint64(len(msg)-n) {
+				return 0, errors.New("invalid HealthCheckResponse")
+			}
+			msg = msg[n+int(size):]
+		default:
+			return 0, fmt.Errorf("unsupported wire type %d in HealthCheckResponse", tag&7)
+		}
+	}
+	return status, nil
+}
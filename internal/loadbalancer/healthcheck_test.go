@@ -0,0 +1,216 @@
+package loadbalancer
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// fakeHealth is a grpc.health.v1 server over h2c (or TLS with useTLS)
+// answering Check with statuses[service]; unknown services get NOT_FOUND.
+func fakeHealth(t *testing.T, useTLS bool, statuses map[string]uint64) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.URL.Path != grpcHealthCheckMethod || r.Header.Get("Content-Type") != "application/grpc" {
+			http.Error(w, "not a health check", http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		if len(data) < 5 || int(binary.BigEndian.Uint32(data[1:5])) != len(data)-5 {
+			http.Error(w, "bad frame", http.StatusBadRequest)
+			return
+		}
+		service := ""
+		if msg := data[5:]; len(msg) > 2 {
+			service = string(msg[2:]) // tag, length (short names), name
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		status, ok := statuses[service]
+		if !ok {
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "unknown service")
+			return
+		}
+		w.Write([]byte{0, 0, 0, 0, 2, 1 << 3, byte(status)})
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0) // failed handshakes are expected
+	if useTLS {
+		srv.EnableHTTP2 = true
+		srv.StartTLS()
+	} else {
+		srv.Config.Protocols = new(http.Protocols)
+		srv.Config.Protocols.SetUnencryptedHTTP2(true)
+		srv.Start()
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHealthCheck_GRPCProbe(t *testing.T) {
+	plain := fakeHealth(t, false, map[string]uint64{"": 1, "payments.v1.Payments": 1, "orders.v1.Orders": 2})
+	secure := fakeHealth(t, true, map[string]uint64{"": 1})
+
+	tests := []struct {
+		name    string
+		srv     *httptest.Server
+		config  database.ServiceHealthCheck
+		wantErr string
+	}{
+		{name: "server serving", srv: plain},
+		{name: "service serving", srv: plain, config: database.ServiceHealthCheck{GRPCService: "payments.v1.Payments"}},
+		{name: "service not serving", srv: plain, config: database.ServiceHealthCheck{GRPCService: "orders.v1.Orders"}, wantErr: "NOT_SERVING"},
+		{name: "unknown service", srv: plain, config: database.ServiceHealthCheck{GRPCService: "carts.v1.Carts"}, wantErr: "grpc-status 5"},
+		{name: "tls", srv: secure, config: database.ServiceHealthCheck{TLS: true, TLSSkipVerify: true}},
+		{name: "tls untrusted certificate", srv: secure, config: database.ServiceHealthCheck{TLS: true}, wantErr: "certificate"},
+		{name: "plain text to a tls server", srv: secure, wantErr: "Post"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Interval = "1s"
+			svc := &database.Service{ID: "svc", Protocol: "grpc", HealthCheck: tt.config}
+			s, err := parseHealthCheck(svc)
+			if err != nil {
+				t.Fatalf("parseHealthCheck() error = %v", err)
+			}
+			client := s.client()
+			defer client.CloseIdleConnections()
+
+			target := NewTarget("t1", strings.TrimPrefix(strings.TrimPrefix(tt.srv.URL, "https://"), "http://"), 100)
+			err = s.probe(context.Background(), client, target)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("probe() error = %v, want pass", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("probe() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHealthCheck_ParseGRPCResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    []byte
+		want    uint64
+		wantErr bool
+	}{
+		{name: "serving", body: []byte{0, 0, 0, 0, 2, 0x08, 1}, want: 1},
+		{name: "empty message is unknown", body: []byte{0, 0, 0, 0, 0}, want: 0},
+		{name: "unknown field skipped", body: []byte{0, 0, 0, 0, 5, 0x12, 1, 'x', 0x08, 2}, want: 2},
+		{name: "compressed", body: []byte{1, 0, 0, 0, 2, 0x08, 1}, wantErr: true},
+		{name: "truncated frame", body: []byte{0, 0, 0, 0, 9, 0x08, 1}, wantErr: true},
+		{name: "truncated header", body: []byte{0, 0}, wantErr: true},
+		{name: "truncated varint", body: []byte{0, 0, 0, 0, 2, 0x08, 0x80}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGRPCHealthResponse(tt.body)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseGRPCHealthResponse() = %d, %v; want %d (error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	want := []byte{0, 0, 0, 0, 6, 0x0a, 4, 'a', '.', 'v', '1'}
+	if got := grpcHealthRequest("a.v1"); string(got) != string(want) {
+		t.Errorf("grpcHealthRequest() = %v, want %v", got, want)
+	}
+}
+
+func TestHealthChecker_MarksTargetsDown(t *testing.T) {
+	var healthy atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ready" || !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer upstream.Close()
+	address := strings.TrimPrefix(upstream.URL, "http://")
+
+	services := []*database.Service{{
+		ID:       "svc",
+		Protocol: "http",
+		HealthCheck: database.ServiceHealthCheck{
+			Interval:           "10ms",
+			UnhealthyThreshold: 2,
+			HealthyThreshold:   1,
+		},
+	}}
+	targets := testServiceTargets("svc", address)
+	targets[0].HealthCheckPath = "/ready"
+
+	m := NewManager(nil)
+	m.SetHealthChecker(NewHealthChecker())
+	m.Update(services, targets)
+	b, _ := m.Get("svc")
+	target := b.Targets()[0]
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitFor("the failing target to go down", target.Down)
+	if target.Available() || len(m.Unavailable()) != 1 {
+		t.Error("down target still available")
+	}
+
+	healthy.Store(true)
+	waitFor("the target to recover", func() bool { return !target.Down() })
+
+	// Without a health check the target is no longer probed or held down
+	healthy.Store(false)
+	waitFor("the target to go down again", target.Down)
+	services[0].HealthCheck = database.ServiceHealthCheck{}
+	m.Update(services, targets)
+	if target.Down() {
+		t.Error("target still down after its service's health check was removed")
+	}
+}
+
+func TestValidateHealthCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		config   database.ServiceHealthCheck
+		wantErr  bool
+	}{
+		{name: "not configured", protocol: "http"},
+		{name: "interval only", protocol: "http", config: database.ServiceHealthCheck{Interval: "10s"}},
+		{name: "grpc service", protocol: "grpc", config: database.ServiceHealthCheck{Interval: "10s", GRPCService: "a.v1.A", TLS: true}},
+		{name: "bad interval", protocol: "http", config: database.ServiceHealthCheck{Interval: "often"}, wantErr: true},
+		{name: "zero interval", protocol: "http", config: database.ServiceHealthCheck{Interval: "0s"}, wantErr: true},
+		{name: "bad timeout", protocol: "http", config: database.ServiceHealthCheck{Interval: "10s", Timeout: "-1s"}, wantErr: true},
+		{name: "negative threshold", protocol: "http", config: database.ServiceHealthCheck{Interval: "10s", HealthyThreshold: -1}, wantErr: true},
+		{name: "grpc service on http", protocol: "http", config: database.ServiceHealthCheck{Interval: "10s", GRPCService: "a.v1.A"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateHealthCheck(&database.Service{Protocol: tt.protocol, HealthCheck: tt.config})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateHealthCheck() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
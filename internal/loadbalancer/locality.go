@@ -0,0 +1,122 @@
+// Package loadbalancer - Locality-aware balancer
+package loadbalancer
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// localitySpillovers counts requests sent out of the gateway's region.
+var localitySpillovers = metrics.NewCounterVec(
+	"gateway_locality_spillovers_total",
+	"Requests a locality-aware balancer sent to another region because no local target was available.",
+	"from_region", "to_region",
+)
+
+// LocalityAware prefers targets in the gateway's own region.
+//
+// Targets are split into a local tier (Region equal to the gateway's
+// region) and a remote tier (everything else, including targets without a
+// region), each rotated round-robin. Requests go to the local tier while
+// it has an available target; once every local target is ejected by
+// outlier detection or down (or there are none), requests spill over to
+// the remote tier, and return automatically when a local target recovers.
+//
+// With an empty gateway region every target is remote, so the balancer
+// behaves like round-robin.
+type LocalityAware struct {
+	mu      sync.RWMutex
+	region  string
+	targets []*Target
+	local   *RoundRobin
+	remote  *RoundRobin
+}
+
+// NewLocalityAware creates a locality-aware balancer for a gateway in region.
+func NewLocalityAware(region string, targets []*Target) *LocalityAware {
+	la := &LocalityAware{
+		region: region,
+		local:  NewRoundRobin(nil),
+		remote: NewRoundRobin(nil),
+	}
+	la.Update(targets)
+	return la
+}
+
+// Type returns the load balancer type.
+func (la *LocalityAware) Type() string {
+	return TypeLocalityAware
+}
+
+// Next picks a local target, or a remote one when no local target is
+// available.
+//
+// If no target in either tier is available, local targets are still
+// preferred rather than failing the request.
+func (la *LocalityAware) Next(r *http.Request, pathParams map[string]string) (*Target, error) {
+	la.mu.RLock()
+	defer la.mu.RUnlock()
+
+	if len(la.targets) == 0 {
+		return nil, ErrNoTargets
+	}
+
+	local := la.local.Targets()
+	if available(local) || (len(local) > 0 && !available(la.remote.Targets())) {
+		return la.local.Next(r, pathParams)
+	}
+
+	target, err := la.remote.Next(r, pathParams)
+	if err == nil && len(local) > 0 {
+		to := target.Region
+		if to == "" {
+			to = "none"
+		}
+		localitySpillovers.Inc(la.region, to)
+	}
+	return target, err
+}
+
+// Done marks a request to the target as finished.
+func (la *LocalityAware) Done(t *Target) {
+	release(t)
+}
+
+// Update replaces the set of targets and re-splits the tiers.
+func (la *LocalityAware) Update(targets []*Target) {
+	var local, remote []*Target
+	for _, t := range targets {
+		if la.region != "" && t.Region == la.region {
+			local = append(local, t)
+		} else {
+			remote = append(remote, t)
+		}
+	}
+
+	la.mu.Lock()
+	defer la.mu.Unlock()
+
+	la.targets = targets
+	la.local.Update(local)
+	la.remote.Update(remote)
+}
+
+// Targets returns the current set of targets.
+func (la *LocalityAware) Targets() []*Target {
+	la.mu.RLock()
+	defer la.mu.RUnlock()
+
+	return la.targets
+}
+
+// available reports whether any of targets is available.
+func available(targets []*Target) bool {
+	for _, t := range targets {
+		if t.Available() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package loadbalancer
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+func newRegionTargets(regions ...string) []*Target {
+	targets := newTestTargets(len(regions))
+	for i, region := range regions {
+		targets[i].Region = region
+	}
+	return targets
+}
+
+func TestLocalityAware_PrefersLocalTargets(t *testing.T) {
+	targets := newRegionTargets("us-east", "eu-west", "us-east", "eu-west")
+	b := NewLocalityAware("us-east", targets)
+
+	seen := make(map[*Target]int)
+	for i := 0; i < 20; i++ {
+		target, err := b.Next(httptest.NewRequest("GET", "/", nil), nil)
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		b.Done(target)
+		if target.Region != "us-east" {
+			t.Fatalf("picked %s in %s, want a local target", target.Address, target.Region)
+		}
+		seen[target]++
+	}
+	if seen[targets[0]] != 10 || seen[targets[2]] != 10 {
+		t.Errorf("local targets picked %d and %d times, want 10 each", seen[targets[0]], seen[targets[2]])
+	}
+}
+
+func TestLocalityAware_SpillsOverWhenLocalEjected(t *testing.T) {
+	targets := newRegionTargets("us-east", "eu-west", "ap-south")
+	b := NewLocalityAware("us-east", targets)
+
+	targets[0].eject(time.Now().Add(time.Minute))
+	for i := 0; i < 10; i++ {
+		target, _ := b.Next(httptest.NewRequest("GET", "/", nil), nil)
+		b.Done(target)
+		if target == targets[0] {
+			t.Fatal("picked ejected local target while remote targets are available")
+		}
+	}
+
+	// Back to the local region once it recovers
+	targets[0].eject(time.Time{})
+	target, _ := b.Next(httptest.NewRequest("GET", "/", nil), nil)
+	b.Done(target)
+	if target != targets[0] {
+		t.Errorf("picked %s, want local target after recovery", target.Address)
+	}
+}
+
+func TestLocalityAware_AllEjectedPrefersLocal(t *testing.T) {
+	targets := newRegionTargets("us-east", "eu-west")
+	for _, target := range targets {
+		target.eject(time.Now().Add(time.Minute))
+	}
+
+	b := NewLocalityAware("us-east", targets)
+	target, err := b.Next(httptest.NewRequest("GET", "/", nil), nil)
+	if err != nil || target != targets[0] {
+		t.Errorf("Next() = %v, %v; want the local target when all are ejected", target, err)
+	}
+}
+
+func TestLocalityAware_NoRegionActsAsRoundRobin(t *testing.T) {
+	targets := newRegionTargets("us-east", "eu-west")
+	b := NewLocalityAware("", targets)
+
+	seen := make(map[*Target]bool)
+	for i := 0; i < 4; i++ {
+		target, _ := b.Next(httptest.NewRequest("GET", "/", nil), nil)
+		b.Done(target)
+		seen[target] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("picked %d distinct targets, want 2", len(seen))
+	}
+
+	if _, err := NewLocalityAware("us-east", nil).Next(httptest.NewRequest("GET", "/", nil), nil); err != ErrNoTargets {
+		t.Errorf("Next() error = %v, want ErrNoTargets", err)
+	}
+}
+
+func TestManager_LocalityAwareRegions(t *testing.T) {
+	services := []*database.Service{{ID: "svc", LoadBalancerType: TypeLocalityAware}}
+	rows := testServiceTargets("svc", "a:80", "b:80")
+	rows[0].Region = "eu-west"
+	rows[1].Region = "us-east"
+
+	m := NewManager(nil)
+	m.SetRegion("us-east")
+	m.Update(services, rows)
+
+	b, ok := m.Get("svc")
+	if !ok || b.Type() != TypeLocalityAware {
+		t.Fatalf("Get() = %v, %v; want a locality-aware balancer", b, ok)
+	}
+	target, _ := b.Next(httptest.NewRequest("GET", "/", nil), nil)
+	b.Done(target)
+	if target.Address != "b:80" {
+		t.Errorf("picked %s, want the us-east target", target.Address)
+	}
+
+	// A target moving region is re-tiered on reload
+	rows[0].Region, rows[1].Region = "us-east", "eu-west"
+	m.Update(services, rows)
+	b, _ = m.Get("svc")
+	target, _ = b.Next(httptest.NewRequest("GET", "/", nil), nil)
+	b.Done(target)
+	if target.Address != "a:80" {
+		t.Errorf("picked %s after region change, want a:80", target.Address)
+	}
+}
@@ -0,0 +1,355 @@
+// Package loadbalancer - Per-service balancer management
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// drainPollInterval is how often a draining target's in-flight count is checked.
+const drainPollInterval = 100 * time.Millisecond
+
+// Manager owns one balancer per service and rebuilds them on hot reload.
+//
+// Services without targets have no balancer; the proxy falls back to the
+// service's own host/port in that case.
+type Manager struct {
+	mu        sync.RWMutex
+	balancers map[string]Balancer // service_id -> Balancer
+	configs   map[string]string   // service_id -> balancer config fingerprint
+
+	// outlier ejects misbehaving targets (nil = disabled)
+	outlier *OutlierDetector
+
+	// checker probes the targets of services with a health check (nil =
+	// disabled)
+	checker *HealthChecker
+
+	// drainer releases connections of removed targets (nil = disabled)
+	drainer      Drainer
+	drainTimeout time.Duration
+
+	// region is the gateway's own region for locality-aware balancing
+	region string
+}
+
+// Drainer releases upstream resources (e.g., keep-alive connections) held
+// for a target that was removed from its service.
+type Drainer interface {
+	Drain(t *Target)
+}
+
+// NewManager creates an empty balancer manager.
+//
+// outlier may be nil to disable outlier detection.
+func NewManager(outlier *OutlierDetector) *Manager {
+	return &Manager{
+		balancers: make(map[string]Balancer),
+		configs:   make(map[string]string),
+		outlier:   outlier,
+	}
+}
+
+// Get returns the balancer for a service.
+func (m *Manager) Get(serviceID string) (Balancer, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	b, ok := m.balancers[serviceID]
+	return b, ok
+}
+
+// SetDrainer configures connection draining for removed targets.
+//
+// When a target disappears on reload, the manager waits until its in-flight
+// requests finish (at most timeout) and then calls d.Drain.
+func (m *Manager) SetDrainer(d Drainer, timeout time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.drainer = d
+	m.drainTimeout = timeout
+}
+
+// SetHealthChecker enables active health checks of services with a
+// health_check. It takes effect on the next Update.
+func (m *Manager) SetHealthChecker(hc *HealthChecker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hc.targets = func(serviceID string) []*Target {
+		if b, ok := m.Get(serviceID); ok {
+			return b.Targets()
+		}
+		return nil
+	}
+	m.checker = hc
+}
+
+// SetRegion sets the gateway's own region, which locality-aware balancers
+// prefer targets in. It takes effect on the next Update.
+func (m *Manager) SetRegion(region string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.region = region
+}
+
+// Report records the outcome of a request to a target for outlier detection.
+//
+// err is the transport error (if any); statusCode is ignored when err is set.
+// Requests are considered failed on transport errors and 5xx responses.
+func (m *Manager) Report(serviceID string, target *Target, statusCode int, err error, latency time.Duration) {
+	if m.outlier == nil || target == nil {
+		return
+	}
+
+	b, ok := m.Get(serviceID)
+	if !ok {
+		return
+	}
+
+	failed := err != nil || statusCode >= 500
+	m.outlier.Observe(serviceID, b.Targets(), target, failed, latency)
+}
+
+// Reload loads services and targets from the database and updates balancers.
+func (m *Manager) Reload(ctx context.Context, repo database.ConfigStore) error {
+	services, err := repo.GetServices(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to load services: %w", err)
+	}
+
+	targets, err := repo.GetAllServiceTargets(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load service targets: %w", err)
+	}
+
+	m.Update(services, targets)
+	return nil
+}
+
+// Update rebuilds balancers from services and their targets.
+//
+// Existing Target objects are reused when a target's address is unchanged,
+// preserving in-flight counters across reloads. Existing balancers are
+// updated in place when their type and settings are unchanged, so hash ring
+// positions stay stable.
+func (m *Manager) Update(services []*database.Service, targets []*database.ServiceTarget) {
+	// Group enabled targets by service
+	byService := make(map[string][]*database.ServiceTarget)
+	for _, t := range targets {
+		if !t.Enabled {
+			continue
+		}
+		byService[t.ServiceID] = append(byService[t.ServiceID], t)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Snapshot current targets (balancers may be updated in place below)
+	previous := make(map[string][]*Target, len(m.balancers))
+	for serviceID, b := range m.balancers {
+		previous[serviceID] = b.Targets()
+	}
+
+	balancers := make(map[string]Balancer, len(services))
+	configs := make(map[string]string, len(services))
+
+	for _, svc := range services {
+		rows := byService[svc.ID]
+		if len(rows) == 0 {
+			continue
+		}
+
+		// Reuse existing targets where possible
+		existing := make(map[string]*Target)
+		if old, ok := m.balancers[svc.ID]; ok {
+			for _, t := range old.Targets() {
+				existing[t.Address] = t
+			}
+		}
+
+		lbTargets := make([]*Target, 0, len(rows))
+		for _, row := range rows {
+			if t, ok := existing[row.Target]; ok && t.Weight == row.Weight && t.Region == row.Region &&
+				t.HealthCheckPath == row.HealthCheckPath {
+				lbTargets = append(lbTargets, t)
+				continue
+			}
+			t := NewTarget(row.ID, row.Target, row.Weight)
+			t.Region = row.Region
+			t.HealthCheckPath = row.HealthCheckPath
+			lbTargets = append(lbTargets, t)
+		}
+
+		fingerprint := configFingerprint(svc, m.region)
+		if old, ok := m.balancers[svc.ID]; ok && m.configs[svc.ID] == fingerprint {
+			old.Update(lbTargets)
+			balancers[svc.ID] = old
+		} else {
+			balancers[svc.ID] = newBalancer(svc, lbTargets, m.region)
+		}
+		configs[svc.ID] = fingerprint
+	}
+
+	// Drain targets whose address is no longer part of their service
+	if m.drainer != nil {
+		for serviceID, oldTargets := range previous {
+			current := make(map[string]bool)
+			if b, ok := balancers[serviceID]; ok {
+				for _, t := range b.Targets() {
+					current[t.Address] = true
+				}
+			}
+			for _, t := range oldTargets {
+				if !current[t.Address] {
+					go m.drain(serviceID, t, m.drainer, m.drainTimeout)
+				}
+			}
+		}
+	}
+
+	m.balancers = balancers
+	m.configs = configs
+
+	if m.checker != nil {
+		m.checker.update(services, balancers)
+	}
+
+	log.Info().
+		Str("component", "loadbalancer").
+		Int("services", len(services)).
+		Int("balanced_services", len(balancers)).
+		Int("targets", len(targets)).
+		Msg("Load balancers updated")
+}
+
+// drain waits for a removed target's in-flight requests to finish, up to
+// timeout, then releases its connections.
+func (m *Manager) drain(serviceID string, t *Target, d Drainer, timeout time.Duration) {
+	log.Info().
+		Str("component", "loadbalancer").
+		Str("service_id", serviceID).
+		Str("target", t.Address).
+		Int64("in_flight", t.InFlight()).
+		Dur("grace_period", timeout).
+		Msg("Target removed - draining connections")
+
+	deadline := time.Now().Add(timeout)
+	for t.InFlight() > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+
+	if inFlight := t.InFlight(); inFlight > 0 {
+		log.Warn().
+			Str("component", "loadbalancer").
+			Str("service_id", serviceID).
+			Str("target", t.Address).
+			Int64("in_flight", inFlight).
+			Msg("Drain grace period expired with requests still in flight")
+	}
+
+	d.Drain(t)
+}
+
+// Stats returns statistics about the managed balancers.
+func (m *Manager) Stats() map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	services := make(map[string]interface{}, len(m.balancers))
+	for serviceID, b := range m.balancers {
+		targets := make(map[string]int64)
+		regions := make(map[string]string)
+		ejected := []string{}
+		down := []string{}
+		for _, t := range b.Targets() {
+			targets[t.Address] = t.InFlight()
+			if t.Region != "" {
+				regions[t.Address] = t.Region
+			}
+			if t.Ejected() {
+				ejected = append(ejected, t.Address)
+			}
+			if t.Down() {
+				down = append(down, t.Address)
+			}
+		}
+		services[serviceID] = map[string]interface{}{
+			"type":      b.Type(),
+			"in_flight": targets,
+			"regions":   regions,
+			"ejected":   ejected,
+			"down":      down,
+		}
+	}
+
+	return map[string]interface{}{
+		"region":            m.region,
+		"balanced_services": len(m.balancers),
+		"services":          services,
+	}
+}
+
+// Unavailable returns the IDs of services whose targets are all ejected or
+// down, sorted. Requests to them still reach one of those targets.
+func (m *Manager) Unavailable() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var unavailable []string
+	for serviceID, b := range m.balancers {
+		targets := b.Targets()
+		if len(targets) > 0 && !available(targets) {
+			unavailable = append(unavailable, serviceID)
+		}
+	}
+	sort.Strings(unavailable)
+	return unavailable
+}
+
+// newBalancer creates a balancer for a service based on its load_balancer_type.
+// region is the gateway's own region (used by locality-aware balancers).
+func newBalancer(svc *database.Service, targets []*Target, region string) Balancer {
+	switch svc.LoadBalancerType {
+	case TypeConsistentHash, TypeIPHash:
+		config := DefaultConsistentHashConfig()
+		if svc.LoadBalancerType == TypeConsistentHash && svc.HashOn != "" {
+			config.HashOn = svc.HashOn
+			config.HashOnKey = svc.HashOnKey.String
+		}
+		if svc.HashBalanceFactor > 0 {
+			config.BalanceFactor = svc.HashBalanceFactor
+		}
+		return NewConsistentHash(config, targets)
+
+	case TypeLocalityAware:
+		return NewLocalityAware(region, targets)
+
+	case TypeRoundRobin, "":
+		return NewRoundRobin(targets)
+
+	default:
+		log.Warn().
+			Str("component", "loadbalancer").
+			Str("service_id", svc.ID).
+			Str("type", svc.LoadBalancerType).
+			Msg("Load balancer type not supported yet - using round-robin")
+		return NewRoundRobin(targets)
+	}
+}
+
+// configFingerprint returns a string identifying the balancer settings of a service.
+func configFingerprint(svc *database.Service, region string) string {
+	return fmt.Sprintf("%s|%s|%s|%.2f|%s",
+		svc.LoadBalancerType, svc.HashOn, svc.HashOnKey.String, svc.HashBalanceFactor, region)
+}
@@ -0,0 +1,167 @@
+package loadbalancer
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// recordingDrainer records drained target addresses.
+type recordingDrainer struct {
+	mu      sync.Mutex
+	drained []string
+	done    chan struct{}
+}
+
+func newRecordingDrainer() *recordingDrainer {
+	return &recordingDrainer{done: make(chan struct{}, 10)}
+}
+
+func (d *recordingDrainer) Drain(t *Target) {
+	d.mu.Lock()
+	d.drained = append(d.drained, t.Address)
+	d.mu.Unlock()
+	d.done <- struct{}{}
+}
+
+func testServiceTargets(serviceID string, addresses ...string) []*database.ServiceTarget {
+	targets := make([]*database.ServiceTarget, 0, len(addresses))
+	for _, addr := range addresses {
+		targets = append(targets, &database.ServiceTarget{
+			ID:        addr,
+			ServiceID: serviceID,
+			Target:    addr,
+			Weight:    100,
+			Enabled:   true,
+		})
+	}
+	return targets
+}
+
+func TestManager_UpdateReusesTargets(t *testing.T) {
+	services := []*database.Service{{ID: "svc", LoadBalancerType: TypeRoundRobin}}
+	m := NewManager(nil)
+
+	m.Update(services, testServiceTargets("svc", "a:80", "b:80"))
+	b, ok := m.Get("svc")
+	if !ok {
+		t.Fatal("expected balancer for service with targets")
+	}
+	before := b.Targets()[0]
+
+	m.Update(services, testServiceTargets("svc", "a:80", "b:80", "c:80"))
+	b, _ = m.Get("svc")
+	if b.Targets()[0] != before {
+		t.Error("unchanged target should be reused across updates")
+	}
+	if len(b.Targets()) != 3 {
+		t.Errorf("targets = %d, want 3", len(b.Targets()))
+	}
+}
+
+func TestManager_Unavailable(t *testing.T) {
+	services := []*database.Service{
+		{ID: "down", LoadBalancerType: TypeRoundRobin},
+		{ID: "partial", LoadBalancerType: TypeRoundRobin},
+	}
+	targets := append(testServiceTargets("down", "a:80", "b:80"), testServiceTargets("partial", "c:80", "d:80")...)
+	m := NewManager(nil)
+	m.Update(services, targets)
+
+	if got := m.Unavailable(); len(got) != 0 {
+		t.Fatalf("Unavailable() = %v, want none", got)
+	}
+
+	down, _ := m.Get("down")
+	for _, target := range down.Targets() {
+		target.eject(time.Now().Add(time.Minute))
+	}
+	partial, _ := m.Get("partial")
+	partial.Targets()[0].eject(time.Now().Add(time.Minute))
+
+	if got := m.Unavailable(); len(got) != 1 || got[0] != "down" {
+		t.Errorf("Unavailable() = %v, want [down]", got)
+	}
+}
+
+func TestManager_DrainsRemovedTargets(t *testing.T) {
+	services := []*database.Service{{ID: "svc", LoadBalancerType: TypeRoundRobin}}
+	m := NewManager(nil)
+	d := newRecordingDrainer()
+	m.SetDrainer(d, time.Second)
+
+	m.Update(services, testServiceTargets("svc", "a:80", "b:80"))
+	m.Update(services, testServiceTargets("svc", "a:80"))
+
+	select {
+	case <-d.done:
+	case <-time.After(time.Second):
+		t.Fatal("removed target was not drained")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.drained) != 1 || d.drained[0] != "b:80" {
+		t.Errorf("drained = %v, want [b:80]", d.drained)
+	}
+}
+
+func TestManager_DrainWaitsForInFlight(t *testing.T) {
+	services := []*database.Service{{ID: "svc", LoadBalancerType: TypeRoundRobin}}
+	m := NewManager(nil)
+	d := newRecordingDrainer()
+	m.SetDrainer(d, 5*time.Second)
+
+	m.Update(services, testServiceTargets("svc", "a:80"))
+	b, _ := m.Get("svc")
+	target, _ := b.Next(httptest.NewRequest("GET", "/", nil), nil)
+
+	// Remove the target while a request is in flight
+	m.Update(services, nil)
+
+	select {
+	case <-d.done:
+		t.Fatal("target drained while a request was still in flight")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	b.Done(target)
+
+	select {
+	case <-d.done:
+	case <-time.After(time.Second):
+		t.Fatal("target not drained after in-flight request finished")
+	}
+}
+
+func TestManager_ReloadFromStore(t *testing.T) {
+	store := database.NewMemoryStore()
+	store.SetServices([]*database.Service{
+		{ID: "svc", LoadBalancerType: TypeRoundRobin, Enabled: true},
+		{ID: "off", LoadBalancerType: TypeRoundRobin, Enabled: false},
+	})
+	store.SetServiceTargets(append(
+		testServiceTargets("svc", "a:80", "b:80"),
+		testServiceTargets("off", "c:80")...,
+	))
+
+	m := NewManager(nil)
+	if err := m.Reload(context.Background(), store); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	b, ok := m.Get("svc")
+	if !ok {
+		t.Fatal("expected balancer for enabled service")
+	}
+	if len(b.Targets()) != 2 {
+		t.Errorf("targets = %d, want 2", len(b.Targets()))
+	}
+	if _, ok := m.Get("off"); ok {
+		t.Error("disabled service should have no balancer")
+	}
+}
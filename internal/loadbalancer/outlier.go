@@ -0,0 +1,353 @@
+// Package loadbalancer - Outlier detection
+//
+// Outlier Detection (passive health checking):
+//   - Every proxied response is reported back to the detector
+//   - Per target, an EWMA of the 5xx/error rate and of the latency is kept
+//   - A target whose error rate exceeds the threshold, or whose latency is
+//     far above the median of its peers, is ejected for a while
+//   - Ejection time grows with repeated ejections (base * count, capped)
+//   - At most MaxEjectionPercent of a service's targets can be ejected
+//
+// Ejected targets are skipped by all balancers. Ejection expires on its own;
+// the target then starts with fresh statistics.
+//
+// This complements active health checks: it reacts to real traffic within
+// a handful of requests and needs no extra probes.
+package loadbalancer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/notify"
+)
+
+// Ejection reasons (used in logs and metrics).
+const (
+	EjectReasonErrorRate = "error_rate"
+	EjectReasonLatency   = "latency"
+)
+
+var (
+	outlierEjections = metrics.NewCounterVec(
+		"gateway_outlier_ejections_total",
+		"Number of times a target was ejected by outlier detection.",
+		"service_id", "target", "reason",
+	)
+	outlierEjected = metrics.NewGaugeVec(
+		"gateway_outlier_ejected",
+		"Whether a target is currently ejected (1) or not (0).",
+		"service_id", "target",
+	)
+)
+
+// OutlierConfig holds configuration for outlier detection.
+type OutlierConfig struct {
+	// Enabled turns outlier detection on or off.
+	Enabled bool
+
+	// ErrorRateThreshold is the EWMA error rate (0-1) above which a target
+	// is ejected. Example: 0.5 means half of recent requests failed.
+	ErrorRateThreshold float64
+
+	// LatencyFactor ejects a target whose latency EWMA exceeds this multiple
+	// of the median latency EWMA of its peers. 0 disables latency ejection.
+	LatencyFactor float64
+
+	// MinLatency is the latency EWMA below which a target is never ejected
+	// for being slow (avoids ejecting a 3ms target because peers take 1ms).
+	MinLatency time.Duration
+
+	// MinRequests is the number of requests a target must have served since
+	// its last ejection before it can be ejected.
+	MinRequests int64
+
+	// Decay is the EWMA smoothing factor (0-1). Higher reacts faster.
+	Decay float64
+
+	// BaseEjectionTime is the ejection duration for the first ejection.
+	// Subsequent ejections last BaseEjectionTime * ejection count.
+	BaseEjectionTime time.Duration
+
+	// MaxEjectionTime caps the ejection duration.
+	MaxEjectionTime time.Duration
+
+	// MaxEjectionPercent is the maximum percentage of a service's targets
+	// that can be ejected at the same time.
+	MaxEjectionPercent int
+}
+
+// DefaultOutlierConfig returns sensible defaults for outlier detection.
+func DefaultOutlierConfig() OutlierConfig {
+	return OutlierConfig{
+		Enabled:            true,
+		ErrorRateThreshold: 0.5,
+		LatencyFactor:      3.0,
+		MinLatency:         100 * time.Millisecond,
+		MinRequests:        20,
+		Decay:              0.1,
+		BaseEjectionTime:   30 * time.Second,
+		MaxEjectionTime:    5 * time.Minute,
+		MaxEjectionPercent: 50,
+	}
+}
+
+// outlierStats holds the passive health statistics of a target.
+type outlierStats struct {
+	mu sync.Mutex
+
+	// errorRate is the EWMA of failed requests (1 = failed, 0 = ok).
+	errorRate float64
+
+	// latency is the EWMA of upstream latency in nanoseconds.
+	latency float64
+
+	// requests counts requests observed since the last ejection.
+	requests int64
+
+	// ejections counts consecutive ejections (drives ejection time).
+	ejections int
+
+	// lastEjection is when the target was last ejected.
+	lastEjection time.Time
+}
+
+// OutlierDetector ejects targets whose error rate or latency deviates from
+// the norm.
+type OutlierDetector struct {
+	config OutlierConfig
+
+	// notifier receives target.unhealthy / target.healthy events (nil = disabled)
+	notifier *notify.Dispatcher
+}
+
+// NewOutlierDetector creates a new outlier detector.
+func NewOutlierDetector(config OutlierConfig) *OutlierDetector {
+	defaults := DefaultOutlierConfig()
+	if config.Decay <= 0 || config.Decay > 1 {
+		config.Decay = defaults.Decay
+	}
+	if config.BaseEjectionTime <= 0 {
+		config.BaseEjectionTime = defaults.BaseEjectionTime
+	}
+	if config.MaxEjectionTime < config.BaseEjectionTime {
+		config.MaxEjectionTime = config.BaseEjectionTime
+	}
+
+	log.Info().
+		Str("component", "outlier_detector").
+		Bool("enabled", config.Enabled).
+		Float64("error_rate_threshold", config.ErrorRateThreshold).
+		Float64("latency_factor", config.LatencyFactor).
+		Int64("min_requests", config.MinRequests).
+		Dur("base_ejection_time", config.BaseEjectionTime).
+		Int("max_ejection_percent", config.MaxEjectionPercent).
+		Msg("Outlier detector initialized")
+
+	return &OutlierDetector{config: config}
+}
+
+// SetNotifier sends ejection and readmission events to n. Must be called
+// before the detector observes traffic.
+func (d *OutlierDetector) SetNotifier(n *notify.Dispatcher) {
+	d.notifier = n
+}
+
+// Observe records the outcome of a request to target and ejects it if it
+// has become an outlier.
+//
+// Parameters:
+//   - serviceID: Service the target belongs to (for logs/metrics)
+//   - peers: All targets of the service (including target)
+//   - target: Target that served the request
+//   - failed: Whether the request failed (transport error or 5xx)
+//   - latency: Upstream latency of the request
+func (d *OutlierDetector) Observe(serviceID string, peers []*Target, target *Target, failed bool, latency time.Duration) {
+	if d == nil || !d.config.Enabled || target == nil {
+		return
+	}
+
+	// Requests that were already in flight when the target got ejected
+	// shouldn't count towards its next period.
+	if target.Ejected() {
+		return
+	}
+
+	stats := &target.outlier
+	stats.mu.Lock()
+
+	sample := 0.0
+	if failed {
+		sample = 1.0
+	}
+	if stats.requests == 0 {
+		stats.errorRate = sample
+		stats.latency = float64(latency)
+	} else {
+		stats.errorRate = d.config.Decay*sample + (1-d.config.Decay)*stats.errorRate
+		stats.latency = d.config.Decay*float64(latency) + (1-d.config.Decay)*stats.latency
+	}
+	stats.requests++
+
+	requests := stats.requests
+	errorRate := stats.errorRate
+	targetLatency := stats.latency
+	stats.mu.Unlock()
+
+	if requests < d.config.MinRequests {
+		return
+	}
+
+	reason := ""
+	if d.config.ErrorRateThreshold > 0 && errorRate > d.config.ErrorRateThreshold {
+		reason = EjectReasonErrorRate
+	} else if d.isSlow(peers, target, targetLatency) {
+		reason = EjectReasonLatency
+	}
+
+	if reason != "" {
+		d.eject(serviceID, peers, target, reason, errorRate, time.Duration(targetLatency))
+	}
+}
+
+// isSlow reports whether a target's latency EWMA is far above its peers'.
+func (d *OutlierDetector) isSlow(peers []*Target, target *Target, targetLatency float64) bool {
+	if d.config.LatencyFactor <= 0 || targetLatency < float64(d.config.MinLatency) {
+		return false
+	}
+
+	// Collect latency of healthy peers with enough data
+	latencies := make([]float64, 0, len(peers))
+	for _, peer := range peers {
+		if peer == target || peer.Ejected() {
+			continue
+		}
+		peer.outlier.mu.Lock()
+		if peer.outlier.requests >= d.config.MinRequests {
+			latencies = append(latencies, peer.outlier.latency)
+		}
+		peer.outlier.mu.Unlock()
+	}
+
+	// Need at least two peers for a meaningful median
+	if len(latencies) < 2 {
+		return false
+	}
+
+	sort.Float64s(latencies)
+	median := latencies[len(latencies)/2]
+	if len(latencies)%2 == 0 {
+		median = (latencies[len(latencies)/2-1] + latencies[len(latencies)/2]) / 2
+	}
+
+	return targetLatency > d.config.LatencyFactor*median
+}
+
+// eject ejects a target unless the service is at its ejection limit.
+func (d *OutlierDetector) eject(serviceID string, peers []*Target, target *Target, reason string, errorRate float64, latency time.Duration) {
+	// Respect max ejection percent
+	ejected := 0
+	for _, peer := range peers {
+		if peer.Ejected() {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > d.config.MaxEjectionPercent*len(peers) {
+		log.Debug().
+			Str("component", "outlier_detector").
+			Str("service_id", serviceID).
+			Str("target", target.Address).
+			Str("reason", reason).
+			Int("ejected", ejected).
+			Int("targets", len(peers)).
+			Msg("Outlier not ejected - max ejection percent reached")
+		return
+	}
+
+	stats := &target.outlier
+	stats.mu.Lock()
+
+	// Targets that stayed healthy for a full max ejection period start over
+	now := time.Now()
+	if now.Sub(stats.lastEjection) > d.config.MaxEjectionTime+d.ejectionTime(stats.ejections) {
+		stats.ejections = 0
+	}
+	stats.ejections++
+	stats.lastEjection = now
+
+	duration := d.ejectionTime(stats.ejections)
+	ejections := stats.ejections
+
+	// Start fresh once the ejection expires
+	stats.requests = 0
+	stats.errorRate = 0
+	stats.latency = 0
+	stats.mu.Unlock()
+
+	target.eject(now.Add(duration))
+
+	outlierEjections.Inc(serviceID, target.Address, reason)
+	outlierEjected.Set(1, serviceID, target.Address)
+	time.AfterFunc(duration, func() {
+		if !target.Ejected() {
+			outlierEjected.Set(0, serviceID, target.Address)
+			log.Info().
+				Str("component", "outlier_detector").
+				Str("service_id", serviceID).
+				Str("target", target.Address).
+				Msg("Target ejection expired - target back in rotation")
+
+			d.notifier.Publish(notify.Event{
+				Type:     notify.EventTargetHealthy,
+				Severity: notify.SeverityInfo,
+				Message:  fmt.Sprintf("Target %s back in rotation", target.Address),
+				Data: map[string]interface{}{
+					"service_id": serviceID,
+					"target":     target.Address,
+				},
+			})
+		}
+	})
+
+	log.Warn().
+		Str("component", "outlier_detector").
+		Str("service_id", serviceID).
+		Str("target", target.Address).
+		Str("reason", reason).
+		Float64("error_rate", errorRate).
+		Dur("latency_ewma", latency).
+		Int("ejection_count", ejections).
+		Dur("ejection_time", duration).
+		Msg("Target ejected by outlier detection")
+
+	d.notifier.Publish(notify.Event{
+		Type:     notify.EventTargetUnhealthy,
+		Severity: notify.SeverityWarning,
+		Message:  fmt.Sprintf("Target %s ejected (%s)", target.Address, reason),
+		Data: map[string]interface{}{
+			"service_id":     serviceID,
+			"target":         target.Address,
+			"reason":         reason,
+			"error_rate":     errorRate,
+			"ejection_count": ejections,
+			"ejection_time":  duration.String(),
+		},
+	})
+}
+
+// ejectionTime returns the ejection duration for the nth ejection.
+func (d *OutlierDetector) ejectionTime(n int) time.Duration {
+	if n < 1 {
+		n = 1
+	}
+	duration := d.config.BaseEjectionTime * time.Duration(n)
+	if duration > d.config.MaxEjectionTime {
+		duration = d.config.MaxEjectionTime
+	}
+	return duration
+}
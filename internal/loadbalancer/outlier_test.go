@@ -0,0 +1,147 @@
+package loadbalancer
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestDetector() *OutlierDetector {
+	config := DefaultOutlierConfig()
+	config.MinRequests = 5
+	config.Decay = 0.5
+	config.MinLatency = 10 * time.Millisecond
+	return NewOutlierDetector(config)
+}
+
+func TestOutlierDetector_EjectsOnErrorRate(t *testing.T) {
+	d := newTestDetector()
+	targets := newTestTargets(4)
+	bad := targets[0]
+
+	for i := 0; i < 10; i++ {
+		d.Observe("svc", targets, bad, true, 5*time.Millisecond)
+	}
+
+	if !bad.Ejected() {
+		t.Fatal("target with 100% errors should be ejected")
+	}
+
+	for _, target := range targets[1:] {
+		if target.Ejected() {
+			t.Errorf("healthy target %s should not be ejected", target.Address)
+		}
+	}
+}
+
+func TestOutlierDetector_NoEjectionBelowMinRequests(t *testing.T) {
+	d := newTestDetector()
+	targets := newTestTargets(4)
+
+	for i := 0; i < 4; i++ {
+		d.Observe("svc", targets, targets[0], true, 5*time.Millisecond)
+	}
+
+	if targets[0].Ejected() {
+		t.Error("target should not be ejected before MinRequests")
+	}
+}
+
+func TestOutlierDetector_EjectsOnLatency(t *testing.T) {
+	d := newTestDetector()
+	targets := newTestTargets(4)
+	slow := targets[3]
+
+	for i := 0; i < 10; i++ {
+		for _, target := range targets[:3] {
+			d.Observe("svc", targets, target, false, 20*time.Millisecond)
+		}
+		d.Observe("svc", targets, slow, false, 500*time.Millisecond)
+	}
+
+	if !slow.Ejected() {
+		t.Fatal("target far slower than peers should be ejected")
+	}
+	for _, target := range targets[:3] {
+		if target.Ejected() {
+			t.Errorf("target %s should not be ejected", target.Address)
+		}
+	}
+}
+
+func TestOutlierDetector_MaxEjectionPercent(t *testing.T) {
+	d := newTestDetector()
+	targets := newTestTargets(4)
+
+	// Every target fails; only 50% may be ejected
+	for i := 0; i < 10; i++ {
+		for _, target := range targets {
+			d.Observe("svc", targets, target, true, 5*time.Millisecond)
+		}
+	}
+
+	ejected := 0
+	for _, target := range targets {
+		if target.Ejected() {
+			ejected++
+		}
+	}
+	if ejected != 2 {
+		t.Errorf("ejected = %d, want 2 (50%% of 4 targets)", ejected)
+	}
+}
+
+func TestOutlierDetector_EjectionTimeGrows(t *testing.T) {
+	d := newTestDetector()
+
+	if got := d.ejectionTime(1); got != 30*time.Second {
+		t.Errorf("ejectionTime(1) = %v, want 30s", got)
+	}
+	if got := d.ejectionTime(3); got != 90*time.Second {
+		t.Errorf("ejectionTime(3) = %v, want 90s", got)
+	}
+	if got := d.ejectionTime(100); got != 5*time.Minute {
+		t.Errorf("ejectionTime(100) = %v, want capped at 5m", got)
+	}
+}
+
+func TestBalancers_SkipEjectedTargets(t *testing.T) {
+	targets := newTestTargets(3)
+	targets[1].eject(time.Now().Add(time.Minute))
+
+	balancers := []Balancer{
+		NewRoundRobin(targets),
+		NewConsistentHash(DefaultConsistentHashConfig(), targets),
+	}
+
+	for _, b := range balancers {
+		for i := 0; i < 50; i++ {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "10.1.1." + string(rune('0'+i%10)) + ":1234"
+
+			target, err := b.Next(r, nil)
+			if err != nil {
+				t.Fatalf("%s: Next() error = %v", b.Type(), err)
+			}
+			b.Done(target)
+
+			if target == targets[1] {
+				t.Fatalf("%s: picked ejected target", b.Type())
+			}
+		}
+	}
+}
+
+func TestBalancers_AllEjectedStillServe(t *testing.T) {
+	targets := newTestTargets(2)
+	for _, target := range targets {
+		target.eject(time.Now().Add(time.Minute))
+	}
+
+	for _, b := range []Balancer{NewRoundRobin(targets), NewConsistentHash(DefaultConsistentHashConfig(), targets)} {
+		target, err := b.Next(httptest.NewRequest("GET", "/", nil), nil)
+		if err != nil || target == nil {
+			t.Errorf("%s: Next() = %v, %v; want a target when all are ejected", b.Type(), target, err)
+		}
+	}
+}
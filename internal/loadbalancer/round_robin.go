@@ -0,0 +1,78 @@
+// Package loadbalancer - Round-robin balancer
+package loadbalancer
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// RoundRobin rotates through targets in order.
+//
+// This is the default balancer for services without a specific
+// load_balancer_type. It's cheap, lock-free on the hot path, and spreads
+// load evenly when targets have similar capacity.
+type RoundRobin struct {
+	mu      sync.RWMutex
+	targets []*Target
+	next    uint64
+}
+
+// NewRoundRobin creates a new round-robin balancer.
+func NewRoundRobin(targets []*Target) *RoundRobin {
+	rr := &RoundRobin{}
+	rr.Update(targets)
+	return rr
+}
+
+// Type returns the load balancer type.
+func (rr *RoundRobin) Type() string {
+	return TypeRoundRobin
+}
+
+// Next picks the next target in rotation.
+//
+// Ejected and down targets are skipped. If no target is available, the
+// rotation continues over all of them rather than failing the request.
+func (rr *RoundRobin) Next(r *http.Request, pathParams map[string]string) (*Target, error) {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	if len(rr.targets) == 0 {
+		return nil, ErrNoTargets
+	}
+
+	n := atomic.AddUint64(&rr.next, 1) - 1
+	count := uint64(len(rr.targets))
+
+	target := rr.targets[n%count]
+	for i := uint64(1); !target.Available() && i < count; i++ {
+		if candidate := rr.targets[(n+i)%count]; candidate.Available() {
+			target = candidate
+		}
+	}
+	acquire(target)
+
+	return target, nil
+}
+
+// Done marks a request to the target as finished.
+func (rr *RoundRobin) Done(t *Target) {
+	release(t)
+}
+
+// Update replaces the set of targets.
+func (rr *RoundRobin) Update(targets []*Target) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	rr.targets = targets
+}
+
+// Targets returns the current set of targets.
+func (rr *RoundRobin) Targets() []*Target {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	return rr.targets
+}
@@ -5,8 +5,11 @@
 package logging
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"os"
+	"runtime/debug"
 	"strings"
 	"time"
 
@@ -83,6 +86,26 @@ func WithRequestID(requestID string) zerolog.Logger {
 	return log.With().Str("request_id", requestID).Logger()
 }
 
+type requestIDKey struct{}
+
+// NewRequestID generates an ID for an incoming request.
+func NewRequestID() string {
+	return fmt.Sprintf("req_%d", time.Now().UnixNano())
+}
+
+// ContextWithRequestID returns ctx carrying the request's ID, so handlers
+// further down log the same ID.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the ID stored by ContextWithRequestID, or
+// "" if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
 // WithComponent adds a component name to the logger context.
 //
 // Useful for identifying which part of the application is logging.
@@ -119,8 +142,24 @@ func LogRequest(method, path string, statusCode int, latencyMs int64) {
 // Includes the error message and any additional context fields.
 func LogError(err error, msg string, fields map[string]interface{}) {
 	event := log.Error().Err(err)
+	addFields(event, fields)
+	event.Msg(msg)
+}
 
-	// Add additional fields
+// LogPanic logs a panic with the stack trace of the panicking goroutine
+// and any additional context fields.
+//
+// Should be used in defer recover() blocks.
+func LogPanic(recovered interface{}, fields map[string]interface{}) {
+	event := log.Error().
+		Interface("panic", recovered).
+		Str("stack", string(debug.Stack()))
+	addFields(event, fields)
+	event.Msg("Panic recovered")
+}
+
+// addFields adds context fields to a log event.
+func addFields(event *zerolog.Event, fields map[string]interface{}) {
 	for key, value := range fields {
 		switch v := value.(type) {
 		case string:
@@ -137,16 +176,4 @@ func LogError(err error, msg string, fields map[string]interface{}) {
 			event.Interface(key, v)
 		}
 	}
-
-	event.Msg(msg)
-}
-
-// LogPanic logs a panic with stack trace.
-//
-// Should be used in defer recover() blocks.
-func LogPanic(recovered interface{}) {
-	log.Error().
-		Interface("panic", recovered).
-		Stack().
-		Msg("Panic recovered")
 }
@@ -0,0 +1,402 @@
+// Package metering emits billing records for metered routes.
+//
+// The metering plugin hands one Record per billable request to a Meter,
+// which batches records in the background and delivers them to a Sink
+// (a webhook or a Kafka REST proxy) in a stable, versioned schema:
+//
+//	{
+//	  "batch_id": "b_6f1c...",
+//	  "schema_version": 1,
+//	  "records": [{
+//	    "id": "m_9a2e...",
+//	    "consumer_id": "...",
+//	    "product": "search-api",
+//	    "route_id": "...",
+//	    "units": 1,
+//	    "unit": "requests",
+//	    "status_code": 200,
+//	    "timestamp": "2026-01-01T12:00:00Z"
+//	  }]
+//	}
+//
+// Delivery is at-least-once with stable identifiers ("exactly-once-ish"):
+//   - Every record and batch carries an ID that never changes, so
+//     downstream billing deduplicates on record ID
+//   - A batch that cannot be delivered after retries is spilled to disk
+//     (METERING_SPOOL_DIR) and replayed, unchanged, once the sink recovers
+//   - Spooled batches are deleted only after the sink acknowledges them
+//
+// Emitting never blocks the request path. Records are dropped (and
+// counted in gateway_metering_records_total) only when the in-memory queue
+// is full, which drops the oldest queued record, or when a batch fails and
+// no spool is configured or the spool is full.
+package metering
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/eventqueue"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// SchemaVersion is the version of the Record/Batch wire format. It only
+// changes on incompatible changes; new optional fields keep the version.
+const SchemaVersion = 1
+
+var recordsTotal = metrics.NewCounterVec(
+	"gateway_metering_records_total",
+	"Metering records by result (delivered, spooled, replayed, dropped).",
+	"result",
+)
+
+// Record is one billable unit of usage.
+type Record struct {
+	// ID is unique per record; downstream systems deduplicate on it
+	ID string `json:"id"`
+
+	ConsumerID string `json:"consumer_id"`
+
+	// Product is the billing product (defaults to the route name or ID)
+	Product string `json:"product"`
+	RouteID string `json:"route_id"`
+
+	// Units is the billed quantity, measured in Unit (e.g. "requests")
+	Units int64  `json:"units"`
+	Unit  string `json:"unit"`
+
+	StatusCode int       `json:"status_code"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Batch is the unit of delivery.
+type Batch struct {
+	ID            string    `json:"batch_id"`
+	SchemaVersion int       `json:"schema_version"`
+	Records       []*Record `json:"records"`
+}
+
+// Config holds Meter configuration.
+type Config struct {
+	// BatchSize is the most records delivered in one batch
+	BatchSize int
+
+	// FlushInterval delivers a partial batch after this long
+	FlushInterval time.Duration
+
+	// QueueSize is the number of records buffered before dropping
+	QueueSize int
+
+	// MaxRetries is the number of retries before a batch is spooled
+	MaxRetries int
+
+	// SpoolDir holds undeliverable batches (empty = drop them)
+	SpoolDir string
+
+	// SpoolMaxBytes caps the spool's size on disk
+	SpoolMaxBytes int64
+}
+
+// DefaultConfig returns sensible defaults.
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:     500,
+		FlushInterval: 5 * time.Second,
+		QueueSize:     10000,
+		MaxRetries:    3,
+		SpoolMaxBytes: 1 << 30,
+	}
+}
+
+// Meter batches records and delivers them to a Sink.
+//
+// A nil *Meter is valid and discards records, so the metering plugin can
+// be configured even when no sink is.
+type Meter struct {
+	config  Config
+	sink    Sink
+	spool   *Spool
+	queue   *eventqueue.Queue[*Record]
+	backoff time.Duration // first retry delay (doubles per attempt)
+
+	// sendErr is the error of the last delivery attempt (nil on success)
+	sendErr atomic.Pointer[error]
+
+	done chan struct{}
+}
+
+// NewMeter creates a meter delivering to sink and starts its worker.
+// Zero config values take their defaults.
+func NewMeter(sink Sink, config Config) (*Meter, error) {
+	defaults := DefaultConfig()
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.SpoolMaxBytes <= 0 {
+		config.SpoolMaxBytes = defaults.SpoolMaxBytes
+	}
+
+	m := &Meter{
+		config:  config,
+		sink:    sink,
+		queue:   eventqueue.New[*Record]("metering", config.QueueSize),
+		backoff: time.Second,
+		done:    make(chan struct{}),
+	}
+
+	if config.SpoolDir != "" {
+		spool, err := OpenSpool(config.SpoolDir, config.SpoolMaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		m.spool = spool
+	}
+
+	go m.run()
+
+	log.Info().
+		Str("component", "metering").
+		Str("sink", sink.Name()).
+		Int("batch_size", config.BatchSize).
+		Str("spool_dir", config.SpoolDir).
+		Msg("Metering enabled")
+
+	return m, nil
+}
+
+// Emit queues a record for delivery. ID and Timestamp are filled in if
+// empty. Never blocks; when the queue is full the oldest queued record is
+// dropped.
+func (m *Meter) Emit(record *Record) {
+	if m == nil {
+		return
+	}
+
+	if record.ID == "" {
+		record.ID = newID("m_")
+	}
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now().UTC()
+	}
+
+	if !m.queue.Push(record) {
+		recordsTotal.Inc("dropped")
+		log.Warn().
+			Str("component", "metering").
+			Uint64("dropped", m.queue.Dropped()).
+			Msg("Metering queue full - oldest record dropped")
+	}
+}
+
+// Close stops accepting records and waits (until ctx is done) for queued
+// records to be delivered or spooled.
+func (m *Meter) Close(ctx context.Context) {
+	if m == nil {
+		return
+	}
+	m.queue.Close()
+
+	select {
+	case <-m.done:
+	case <-ctx.Done():
+	}
+}
+
+// SinkName returns the name of the sink records are delivered to.
+func (m *Meter) SinkName() string {
+	return m.sink.Name()
+}
+
+// Status reports the sink's health: the error of the last delivery
+// attempt (nil once a batch was delivered) and the number of batches
+// spooled waiting for the sink to recover.
+func (m *Meter) Status() (spooled int, err error) {
+	if m.spool != nil {
+		spooled = m.spool.Len()
+	}
+	if p := m.sendErr.Load(); p != nil {
+		err = *p
+	}
+	return spooled, err
+}
+
+// run batches queued records until the queue is closed.
+func (m *Meter) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.config.FlushInterval)
+	defer ticker.Stop()
+
+	pending := make([]*Record, 0, m.config.BatchSize)
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		m.deliver(&Batch{ID: newID("b_"), SchemaVersion: SchemaVersion, Records: pending})
+		pending = make([]*Record, 0, m.config.BatchSize)
+	}
+
+	for {
+		select {
+		case <-m.queue.Ready():
+			closed := m.queue.Closed()
+			for {
+				record, ok := m.queue.Pop()
+				if !ok {
+					break
+				}
+				pending = append(pending, record)
+				if len(pending) >= m.config.BatchSize {
+					flush()
+				}
+			}
+			if closed {
+				flush()
+				return
+			}
+
+		case <-ticker.C:
+			flush()
+			m.replay()
+		}
+	}
+}
+
+// deliver sends a batch, spooling it if the sink stays unavailable.
+func (m *Meter) deliver(batch *Batch) {
+	// While older batches are spooled the sink is probably still down;
+	// spool behind them rather than waiting out another round of retries
+	if m.spool != nil && m.spool.Len() > 0 {
+		m.spill(batch, nil)
+		return
+	}
+
+	if err := m.send(batch); err != nil {
+		m.spill(batch, err)
+		return
+	}
+	recordsTotal.Add(float64(len(batch.Records)), "delivered")
+}
+
+// send delivers a batch, retrying with exponential backoff.
+func (m *Meter) send(batch *Batch) error {
+	delay := m.backoff
+	var err error
+
+	for attempt := 0; attempt <= m.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err = m.sink.Send(ctx, batch)
+		cancel()
+		if err == nil {
+			m.sendErr.Store(nil)
+			return nil
+		}
+	}
+
+	m.sendErr.Store(&err)
+	return err
+}
+
+// spill writes an undeliverable batch to the spool, or drops it.
+func (m *Meter) spill(batch *Batch, cause error) {
+	if m.spool == nil {
+		recordsTotal.Add(float64(len(batch.Records)), "dropped")
+		log.Error().
+			Err(cause).
+			Str("component", "metering").
+			Str("batch_id", batch.ID).
+			Int("records", len(batch.Records)).
+			Msg("Failed to deliver metering batch - no spool configured, records dropped")
+		return
+	}
+
+	if err := m.spool.Write(batch); err != nil {
+		recordsTotal.Add(float64(len(batch.Records)), "dropped")
+		log.Error().
+			Err(err).
+			Str("component", "metering").
+			Str("batch_id", batch.ID).
+			Int("records", len(batch.Records)).
+			Msg("Failed to spool metering batch - records dropped")
+		return
+	}
+
+	recordsTotal.Add(float64(len(batch.Records)), "spooled")
+	if cause != nil {
+		log.Warn().
+			Err(cause).
+			Str("component", "metering").
+			Str("batch_id", batch.ID).
+			Int("records", len(batch.Records)).
+			Msg("Metering sink unavailable - batch spooled to disk")
+	}
+}
+
+// replay re-sends spooled batches oldest first, stopping at the first
+// failure so batches are never reordered behind a newer one.
+func (m *Meter) replay() {
+	if m.spool == nil || m.spool.Len() == 0 {
+		return
+	}
+
+	for _, name := range m.spool.List() {
+		batch, err := m.spool.Read(name)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("component", "metering").
+				Str("file", name).
+				Msg("Unreadable metering spool file - discarding")
+			m.spool.Remove(name)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err = m.sink.Send(ctx, batch)
+		cancel()
+		if err != nil {
+			m.sendErr.Store(&err)
+			log.Debug().
+				Err(err).
+				Str("component", "metering").
+				Int("spooled_batches", m.spool.Len()).
+				Msg("Metering sink still unavailable")
+			return
+		}
+
+		m.sendErr.Store(nil)
+		m.spool.Remove(name)
+		recordsTotal.Add(float64(len(batch.Records)), "replayed")
+		log.Info().
+			Str("component", "metering").
+			Str("batch_id", batch.ID).
+			Int("records", len(batch.Records)).
+			Msg("Replayed spooled metering batch")
+	}
+}
+
+// newID returns prefix + 16 random bytes in hex.
+func newID(prefix string) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
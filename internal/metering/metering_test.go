@@ -0,0 +1,223 @@
+package metering
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/notify"
+)
+
+// fakeSink collects batches and can be switched to fail.
+type fakeSink struct {
+	mu      sync.Mutex
+	batches []*Batch
+	fail    bool
+}
+
+func (s *fakeSink) Name() string { return "fake" }
+
+func (s *fakeSink) Send(ctx context.Context, batch *Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errors.New("broker unavailable")
+	}
+	s.batches = append(s.batches, batch)
+	return nil
+}
+
+func (s *fakeSink) setFail(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = fail
+}
+
+func (s *fakeSink) records() []*Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var records []*Record
+	for _, b := range s.batches {
+		records = append(records, b.Records...)
+	}
+	return records
+}
+
+func newTestMeter(t *testing.T, sink Sink, config Config) *Meter {
+	t.Helper()
+	m, err := NewMeter(sink, config)
+	if err != nil {
+		t.Fatalf("NewMeter: %v", err)
+	}
+	m.backoff = time.Millisecond
+	return m
+}
+
+func TestMeter_BatchesRecords(t *testing.T) {
+	sink := &fakeSink{}
+	m := newTestMeter(t, sink, Config{BatchSize: 2, FlushInterval: time.Hour})
+
+	for i := 0; i < 5; i++ {
+		m.Emit(&Record{ConsumerID: "c1", Product: "search", Units: 1, Unit: "requests"})
+	}
+	m.Close(context.Background())
+
+	if len(sink.batches) != 3 {
+		t.Fatalf("batches = %d, want 3 (2+2+1)", len(sink.batches))
+	}
+	for _, b := range sink.batches {
+		if b.ID == "" || b.SchemaVersion != SchemaVersion {
+			t.Errorf("batch missing id or schema version: %+v", b)
+		}
+	}
+	for _, r := range sink.records() {
+		if r.ID == "" || r.Timestamp.IsZero() {
+			t.Errorf("record missing id or timestamp: %+v", r)
+		}
+	}
+}
+
+func TestMeter_SpoolsAndReplays(t *testing.T) {
+	dir := t.TempDir()
+	sink := &fakeSink{fail: true}
+	m := newTestMeter(t, sink, Config{BatchSize: 1, FlushInterval: time.Hour, MaxRetries: 1, SpoolDir: dir})
+
+	m.Emit(&Record{ConsumerID: "c1", Units: 1})
+	m.Emit(&Record{ConsumerID: "c2", Units: 1})
+	m.Close(context.Background())
+
+	if m.spool.Len() != 2 {
+		t.Fatalf("spooled batches = %d, want 2", m.spool.Len())
+	}
+	if spooled, err := m.Status(); spooled != 2 || err == nil {
+		t.Errorf("Status() = %d, %v; want 2 spooled and the sink error", spooled, err)
+	}
+
+	// A new meter picks up the spool and replays it once the sink is back
+	sink.setFail(false)
+	m2 := newTestMeter(t, sink, Config{BatchSize: 1, FlushInterval: time.Hour, SpoolDir: dir})
+	defer m2.Close(context.Background())
+	m2.replay()
+
+	records := sink.records()
+	if len(records) != 2 || records[0].ConsumerID != "c1" || records[1].ConsumerID != "c2" {
+		t.Fatalf("replayed records = %+v, want c1 then c2", records)
+	}
+	if m2.spool.Len() != 0 {
+		t.Errorf("spool not emptied after replay: %d", m2.spool.Len())
+	}
+	if spooled, err := m2.Status(); spooled != 0 || err != nil {
+		t.Errorf("Status() = %d, %v after replay; want 0, nil", spooled, err)
+	}
+}
+
+func TestMeter_ReplayKeepsIDs(t *testing.T) {
+	dir := t.TempDir()
+	sink := &fakeSink{fail: true}
+	m := newTestMeter(t, sink, Config{BatchSize: 1, FlushInterval: time.Hour, SpoolDir: dir})
+
+	m.Emit(&Record{ID: "m_fixed", ConsumerID: "c1", Units: 1})
+	m.Close(context.Background())
+
+	sink.setFail(false)
+	m.replay()
+
+	if len(sink.batches) != 1 || sink.batches[0].Records[0].ID != "m_fixed" {
+		t.Errorf("replayed batch = %+v, want record m_fixed", sink.batches)
+	}
+}
+
+func TestSpool_MaxBytes(t *testing.T) {
+	spool, err := OpenSpool(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spool.Write(&Batch{ID: "b1", Records: []*Record{{ConsumerID: "c1"}}}); err == nil {
+		t.Error("Write beyond max bytes succeeded")
+	}
+}
+
+func TestMeter_Nil(t *testing.T) {
+	var m *Meter
+	m.Emit(&Record{ConsumerID: "c1"})
+	m.Close(context.Background())
+}
+
+func TestWebhookSink_Signed(t *testing.T) {
+	var gotBatch Batch
+	var gotHeaders http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders = r.Header
+		body, _ = io.ReadAll(r.Body)
+		json.Unmarshal(body, &gotBatch)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, "secret")
+	batch := &Batch{ID: "b1", SchemaVersion: SchemaVersion, Records: []*Record{{ID: "m1", ConsumerID: "c1", Units: 3}}}
+	if err := sink.Send(context.Background(), batch); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if gotBatch.ID != "b1" || len(gotBatch.Records) != 1 || gotBatch.Records[0].Units != 3 {
+		t.Errorf("received batch = %+v", gotBatch)
+	}
+	if gotHeaders.Get(BatchIDHeader) != "b1" {
+		t.Errorf("batch id header = %q", gotHeaders.Get(BatchIDHeader))
+	}
+	want := notify.Sign("secret", gotHeaders.Get(notify.TimestampHeader), body)
+	if gotHeaders.Get(notify.SignatureHeader) != want {
+		t.Errorf("signature = %q, want %q", gotHeaders.Get(notify.SignatureHeader), want)
+	}
+}
+
+func TestKafkaRESTSink(t *testing.T) {
+	var payload struct {
+		Records []struct {
+			Key   string                 `json:"key"`
+			Value map[string]interface{} `json:"value"`
+		} `json:"records"`
+	}
+	var path, contentType string
+	rejected := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		contentType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&payload)
+		if rejected {
+			w.Write([]byte(`{"offsets":[{"partition":0,"offset":1},{"error_code":50002,"error":"not leader"}]}`))
+			return
+		}
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":1}]}`))
+	}))
+	defer server.Close()
+
+	sink := NewKafkaRESTSink(server.URL+"/", "billing.usage")
+	batch := &Batch{ID: "b1", SchemaVersion: SchemaVersion, Records: []*Record{{ID: "m1", ConsumerID: "c1", Product: "search", Units: 1}}}
+	if err := sink.Send(context.Background(), batch); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if path != "/topics/billing.usage" || contentType != "application/vnd.kafka.json.v2+json" {
+		t.Errorf("request = %s (%s)", path, contentType)
+	}
+	if len(payload.Records) != 1 || payload.Records[0].Key != "c1" {
+		t.Fatalf("payload = %+v", payload)
+	}
+	value := payload.Records[0].Value
+	if value["id"] != "m1" || value["batch_id"] != "b1" || value["product"] != "search" {
+		t.Errorf("message value = %v", value)
+	}
+
+	rejected = true
+	if err := sink.Send(context.Background(), batch); err == nil {
+		t.Error("Send succeeded although the proxy rejected a record")
+	}
+}
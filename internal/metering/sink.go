@@ -0,0 +1,197 @@
+package metering
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/notify"
+)
+
+// sendTimeout bounds a single delivery attempt.
+const sendTimeout = 10 * time.Second
+
+// Batch headers on webhook deliveries.
+const (
+	BatchIDHeader       = "X-Metering-Batch-Id"
+	SchemaVersionHeader = "X-Metering-Schema-Version"
+)
+
+// Sink delivers batches to a billing pipeline. Send must only return nil
+// once the batch is durably accepted.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, batch *Batch) error
+}
+
+// ============================================================================
+// Webhook
+// ============================================================================
+
+// WebhookSink POSTs each batch as JSON to a URL.
+//
+// With a secret, deliveries are signed exactly like gateway event
+// notifications (X-Gateway-Timestamp / X-Gateway-Signature). Any 2xx
+// response acknowledges the batch; receivers should deduplicate on
+// X-Metering-Batch-Id or record IDs, since a batch may be redelivered.
+type WebhookSink struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookSink creates a webhook sink.
+func NewWebhookSink(url, secret string) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: sendTimeout},
+	}
+}
+
+// Name returns the sink type.
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+// Send POSTs a batch.
+func (s *WebhookSink) Send(ctx context.Context, batch *Batch) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(BatchIDHeader, batch.ID)
+	req.Header.Set(SchemaVersionHeader, strconv.Itoa(batch.SchemaVersion))
+
+	if s.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(notify.TimestampHeader, timestamp)
+		req.Header.Set(notify.SignatureHeader, notify.Sign(s.secret, timestamp, body))
+	}
+
+	return do(s.client, req)
+}
+
+// ============================================================================
+// Kafka (REST proxy)
+// ============================================================================
+
+// KafkaRESTSink produces records to a Kafka topic through a Kafka REST
+// proxy (Confluent REST Proxy v2 API).
+//
+// Each record becomes one Kafka message keyed by consumer ID, so a
+// consumer's records stay ordered within a partition. The message value
+// is the record plus batch_id and schema_version. The gateway has no
+// native Kafka client; point this at a REST proxy in front of the
+// brokers.
+type KafkaRESTSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewKafkaRESTSink creates a sink producing to topic via the REST proxy
+// at proxyURL.
+func NewKafkaRESTSink(proxyURL, topic string) *KafkaRESTSink {
+	return &KafkaRESTSink{
+		endpoint: strings.TrimSuffix(proxyURL, "/") + "/topics/" + url.PathEscape(topic),
+		client:   &http.Client{Timeout: sendTimeout},
+	}
+}
+
+// Name returns the sink type.
+func (s *KafkaRESTSink) Name() string {
+	return "kafka-rest"
+}
+
+// kafkaMessage is a record as produced to Kafka.
+type kafkaMessage struct {
+	*Record
+	BatchID       string `json:"batch_id"`
+	SchemaVersion int    `json:"schema_version"`
+}
+
+// Send produces a batch's records in one request.
+func (s *KafkaRESTSink) Send(ctx context.Context, batch *Batch) error {
+	type produceRecord struct {
+		Key   string       `json:"key"`
+		Value kafkaMessage `json:"value"`
+	}
+	payload := struct {
+		Records []produceRecord `json:"records"`
+	}{Records: make([]produceRecord, 0, len(batch.Records))}
+
+	for _, record := range batch.Records {
+		payload.Records = append(payload.Records, produceRecord{
+			Key:   record.ConsumerID,
+			Value: kafkaMessage{Record: record, BatchID: batch.ID, SchemaVersion: batch.SchemaVersion},
+		})
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("kafka rest proxy returned status %d", resp.StatusCode)
+	}
+
+	// The proxy answers 200 even when individual records fail; each offset
+	// entry then carries an error
+	var result struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid kafka rest proxy response: %w", err)
+	}
+	for _, offset := range result.Offsets {
+		if offset.Error != "" {
+			return fmt.Errorf("kafka rest proxy rejected record: %s", offset.Error)
+		}
+	}
+
+	return nil
+}
+
+// do sends a request and treats any non-2xx response as a failure.
+func do(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sink returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package metering
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spoolExt marks complete spool files; partial writes use a temp name.
+const spoolExt = ".batch.json"
+
+// Spool stores undeliverable batches on local disk, one file per batch.
+//
+// Files are named by spill time so List returns them oldest first, and are
+// written via a temp file and rename so a crash never leaves a truncated
+// batch behind. Batches already on disk when the gateway starts are picked
+// up and replayed.
+type Spool struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	files map[string]int64 // name -> size
+	size  int64
+}
+
+// OpenSpool opens (creating if needed) a spool directory.
+func OpenSpool(dir string, maxBytes int64) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	s := &Spool{dir: dir, maxBytes: maxBytes, files: make(map[string]int64)}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), spoolExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		s.files[entry.Name()] = info.Size()
+		s.size += info.Size()
+	}
+
+	return s, nil
+}
+
+// Write persists a batch.
+func (s *Spool) Write(batch *Batch) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size+int64(len(data)) > s.maxBytes {
+		return fmt.Errorf("spool full (%d bytes)", s.size)
+	}
+
+	name := fmt.Sprintf("%020d-%s%s", time.Now().UnixNano(), batch.ID, spoolExt)
+	tmp := filepath.Join(s.dir, name+".tmp")
+
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to commit spool file: %w", err)
+	}
+
+	s.files[name] = int64(len(data))
+	s.size += int64(len(data))
+	return nil
+}
+
+// List returns spooled batch names, oldest first.
+func (s *Spool) List() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Read loads a spooled batch.
+func (s *Spool) Read(name string) (*Batch, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool file: %w", err)
+	}
+
+	var batch Batch
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, fmt.Errorf("failed to decode spool file: %w", err)
+	}
+	return &batch, nil
+}
+
+// Remove deletes a spooled batch (after it was delivered).
+func (s *Spool) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	os.Remove(filepath.Join(s.dir, name))
+	s.size -= s.files[name]
+	delete(s.files, name)
+}
+
+// Len returns the number of spooled batches.
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.files)
+}
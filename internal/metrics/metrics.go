@@ -0,0 +1,534 @@
+// Package metrics provides lightweight Prometheus-compatible metrics.
+//
+// Metrics are registered on a Registry (usually the package-level Default)
+// and exposed in the Prometheus text exposition format by Handler, which the
+// gateway mounts at /metrics.
+//
+// Supported metric types:
+//   - CounterVec: Monotonically increasing values with labels
+//   - GaugeVec: Values that go up and down, with labels
+//   - GaugeFunc: Gauge computed at scrape time
+//   - HistogramVec: Observations counted into cumulative buckets, with labels
+//
+// All types are safe for concurrent use. The implementation intentionally
+// avoids external dependencies; the output is readable by any Prometheus
+// scraper.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default is the registry used by the package-level constructors.
+var Default = NewRegistry()
+
+// collector is implemented by every metric type.
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds a set of metrics and renders them for scraping.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]collector),
+	}
+}
+
+// register adds a collector, returning the existing one if the name is taken.
+//
+// Returning the existing collector makes constructors idempotent, so
+// packages can declare metrics in plugin factories that run on every reload.
+func (reg *Registry) register(c collector) collector {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if existing, ok := reg.collectors[c.name()]; ok {
+		return existing
+	}
+	reg.collectors[c.name()] = c
+	return c
+}
+
+// Write writes all metrics in the Prometheus text format, sorted by name.
+func (reg *Registry) Write(w io.Writer) {
+	reg.mu.RLock()
+	names := make([]string, 0, len(reg.collectors))
+	for name := range reg.collectors {
+		names = append(names, name)
+	}
+	collectors := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, reg.collectors[name])
+	}
+	reg.mu.RUnlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler returns an http.Handler serving the registry's metrics.
+func (reg *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		reg.Write(w)
+	})
+}
+
+// Handler returns an http.Handler serving the Default registry.
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// ============================================================================
+// Counter
+// ============================================================================
+
+// CounterVec is a counter partitioned by label values.
+type CounterVec struct {
+	metricName string
+	help       string
+	labels     []string
+
+	mu     sync.RWMutex
+	values map[string]*series
+}
+
+// series is one labelled time series.
+type series struct {
+	labelValues []string
+
+	mu    sync.Mutex
+	value float64
+}
+
+// NewCounterVec creates and registers a counter on the Default registry.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+// NewCounterVec creates and registers a counter on this registry.
+//
+// If a counter with the same name already exists it is returned instead.
+func (reg *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		metricName: name,
+		help:       help,
+		labels:     labels,
+		values:     make(map[string]*series),
+	}
+	if existing, ok := reg.register(c).(*CounterVec); ok {
+		return existing
+	}
+	return c
+}
+
+// Inc increments the counter for the given label values by 1.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the given label values by delta.
+//
+// Negative deltas are ignored (counters never go down).
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	s := getSeries(&c.mu, c.values, labelValues)
+	s.mu.Lock()
+	s.value += delta
+	s.mu.Unlock()
+}
+
+// Value returns the current value for the given label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	return seriesValue(&c.mu, c.values, labelValues)
+}
+
+func (c *CounterVec) name() string { return c.metricName }
+
+func (c *CounterVec) write(w io.Writer) {
+	writeSeries(w, c.metricName, c.help, "counter", c.labels, &c.mu, c.values)
+}
+
+// ============================================================================
+// Gauge
+// ============================================================================
+
+// GaugeVec is a gauge partitioned by label values.
+type GaugeVec struct {
+	metricName string
+	help       string
+	labels     []string
+
+	mu     sync.RWMutex
+	values map[string]*series
+}
+
+// NewGaugeVec creates and registers a gauge on the Default registry.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return Default.NewGaugeVec(name, help, labels...)
+}
+
+// NewGaugeVec creates and registers a gauge on this registry.
+//
+// If a gauge with the same name already exists it is returned instead.
+func (reg *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{
+		metricName: name,
+		help:       help,
+		labels:     labels,
+		values:     make(map[string]*series),
+	}
+	if existing, ok := reg.register(g).(*GaugeVec); ok {
+		return existing
+	}
+	return g
+}
+
+// Set sets the gauge for the given label values.
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	s := getSeries(&g.mu, g.values, labelValues)
+	s.mu.Lock()
+	s.value = value
+	s.mu.Unlock()
+}
+
+// Add adds delta (which may be negative) to the gauge.
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	s := getSeries(&g.mu, g.values, labelValues)
+	s.mu.Lock()
+	s.value += delta
+	s.mu.Unlock()
+}
+
+// Delete removes the series for the given label values.
+func (g *GaugeVec) Delete(labelValues ...string) {
+	g.mu.Lock()
+	delete(g.values, seriesKey(labelValues))
+	g.mu.Unlock()
+}
+
+// Value returns the current value for the given label values.
+func (g *GaugeVec) Value(labelValues ...string) float64 {
+	return seriesValue(&g.mu, g.values, labelValues)
+}
+
+func (g *GaugeVec) name() string { return g.metricName }
+
+func (g *GaugeVec) write(w io.Writer) {
+	writeSeries(w, g.metricName, g.help, "gauge", g.labels, &g.mu, g.values)
+}
+
+// GaugeFunc is a gauge whose value is computed at scrape time.
+type GaugeFunc struct {
+	metricName string
+	help       string
+	fn         func() float64
+}
+
+// NewGaugeFunc creates and registers a computed gauge on the Default registry.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return Default.NewGaugeFunc(name, help, fn)
+}
+
+// NewGaugeFunc creates and registers a computed gauge on this registry.
+func (reg *Registry) NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, fn: fn}
+	if existing, ok := reg.register(g).(*GaugeFunc); ok {
+		return existing
+	}
+	return g
+}
+
+func (g *GaugeFunc) name() string { return g.metricName }
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.metricName, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatValue(g.fn()))
+}
+
+// ============================================================================
+// Histogram
+// ============================================================================
+
+// DefaultBuckets are latency buckets in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// HistogramVec is a histogram partitioned by label values.
+type HistogramVec struct {
+	metricName string
+	help       string
+	labels     []string
+	buckets    []float64 // sorted upper bounds, without +Inf
+
+	mu     sync.RWMutex
+	values map[string]*histogramSeries
+}
+
+// histogramSeries is one labelled histogram.
+type histogramSeries struct {
+	labelValues []string
+
+	mu     sync.Mutex
+	counts []uint64 // per bucket, not cumulative; last is +Inf
+	sum    float64
+}
+
+// NewHistogramVec creates and registers a histogram on the Default registry.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labels...)
+}
+
+// NewHistogramVec creates and registers a histogram on this registry.
+// Nil buckets means DefaultBuckets.
+//
+// If a histogram with the same name already exists it is returned instead.
+func (reg *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	h := &HistogramVec{
+		metricName: name,
+		help:       help,
+		labels:     labels,
+		buckets:    sorted,
+		values:     make(map[string]*histogramSeries),
+	}
+	if existing, ok := reg.register(h).(*HistogramVec); ok {
+		return existing
+	}
+	return h
+}
+
+// Observe records a value for the given label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	s := h.series(labelValues)
+	i := sort.SearchFloat64s(h.buckets, value) // first bucket with bound >= value
+
+	s.mu.Lock()
+	s.counts[i]++
+	s.sum += value
+	s.mu.Unlock()
+}
+
+// Count returns the number of observations for the given label values.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.RLock()
+	s, ok := h.values[seriesKey(labelValues)]
+	h.mu.RUnlock()
+	if !ok {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var total uint64
+	for _, c := range s.counts {
+		total += c
+	}
+	return total
+}
+
+// series returns the histogram for labelValues, creating it if needed.
+func (h *HistogramVec) series(labelValues []string) *histogramSeries {
+	key := seriesKey(labelValues)
+
+	h.mu.RLock()
+	s, ok := h.values[key]
+	h.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.values[key]; ok {
+		return s
+	}
+	s = &histogramSeries{
+		labelValues: append([]string(nil), labelValues...),
+		counts:      make([]uint64, len(h.buckets)+1),
+	}
+	h.values[key] = s
+	return s
+}
+
+func (h *HistogramVec) name() string { return h.metricName }
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.RLock()
+	keys := make([]string, 0, len(h.values))
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	all := make([]*histogramSeries, 0, len(keys))
+	for _, key := range keys {
+		all = append(all, h.values[key])
+	}
+	h.mu.RUnlock()
+
+	writeHeader(w, h.metricName, h.help, "histogram")
+	bucketLabels := append(append([]string(nil), h.labels...), "le")
+	for _, s := range all {
+		s.mu.Lock()
+		counts := append([]uint64(nil), s.counts...)
+		sum := s.sum
+		s.mu.Unlock()
+
+		values := make([]string, len(h.labels), len(h.labels)+1)
+		copy(values, s.labelValues)
+
+		var cumulative uint64
+		for i, count := range counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = formatValue(h.buckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(bucketLabels, append(values, le)), cumulative)
+		}
+		labels := formatLabels(h.labels, s.labelValues)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, labels, formatValue(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, labels, cumulative)
+	}
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+// seriesKey joins label values into a map key.
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+// getSeries returns the series for labelValues, creating it if needed.
+func getSeries(mu *sync.RWMutex, values map[string]*series, labelValues []string) *series {
+	key := seriesKey(labelValues)
+
+	mu.RLock()
+	s, ok := values[key]
+	mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if s, ok := values[key]; ok {
+		return s
+	}
+	s = &series{labelValues: append([]string(nil), labelValues...)}
+	values[key] = s
+	return s
+}
+
+// seriesValue returns the value of a series, or 0 if it doesn't exist.
+func seriesValue(mu *sync.RWMutex, values map[string]*series, labelValues []string) float64 {
+	mu.RLock()
+	s, ok := values[seriesKey(labelValues)]
+	mu.RUnlock()
+	if !ok {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value
+}
+
+// writeHeader writes the HELP and TYPE lines for a metric.
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.ReplaceAll(help, "\n", " "))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+// writeSeries writes all series of a labelled metric, sorted by label values.
+func writeSeries(w io.Writer, name, help, typ string, labels []string, mu *sync.RWMutex, values map[string]*series) {
+	mu.RLock()
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	all := make([]*series, 0, len(keys))
+	for _, key := range keys {
+		all = append(all, values[key])
+	}
+	mu.RUnlock()
+
+	writeHeader(w, name, help, typ)
+	for _, s := range all {
+		s.mu.Lock()
+		value := s.value
+		s.mu.Unlock()
+
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(labels, s.labelValues), formatValue(value))
+	}
+}
+
+// formatLabels renders {name="value",...} for a series.
+func formatLabels(labels, values []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		b.WriteString(label)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// escapeLabelValue escapes backslashes, quotes and newlines.
+func escapeLabelValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `"`, `\"`)
+	return strings.ReplaceAll(v, "\n", `\n`)
+}
+
+// formatValue renders a sample value the way Prometheus expects.
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_TextFormat(t *testing.T) {
+	reg := NewRegistry()
+
+	requests := reg.NewCounterVec("test_requests_total", "Total requests", "service", "status")
+	requests.Inc("users", "200")
+	requests.Inc("users", "200")
+	requests.Add(3, "orders", "503")
+
+	inFlight := reg.NewGaugeVec("test_in_flight", "In-flight requests", "service")
+	inFlight.Set(4, "users")
+	inFlight.Add(-1, "users")
+
+	reg.NewGaugeFunc("test_up", "Always one", func() float64 { return 1 })
+
+	var buf bytes.Buffer
+	reg.Write(&buf)
+	out := buf.String()
+
+	want := []string{
+		"# TYPE test_requests_total counter",
+		`test_requests_total{service="users",status="200"} 2`,
+		`test_requests_total{service="orders",status="503"} 3`,
+		"# TYPE test_in_flight gauge",
+		`test_in_flight{service="users"} 3`,
+		"test_up 1",
+	}
+	for _, line := range want {
+		if !strings.Contains(out, line) {
+			t.Errorf("output missing %q\n%s", line, out)
+		}
+	}
+}
+
+func TestRegistry_IdempotentRegistration(t *testing.T) {
+	reg := NewRegistry()
+
+	first := reg.NewCounterVec("test_total", "Test", "label")
+	first.Inc("a")
+
+	second := reg.NewCounterVec("test_total", "Test", "label")
+	second.Inc("a")
+
+	if got := first.Value("a"); got != 2 {
+		t.Errorf("Value() = %v, want 2 (re-registration should share series)", got)
+	}
+}
+
+func TestCounterVec_IgnoresNegative(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.NewCounterVec("test_total", "Test")
+
+	c.Add(5)
+	c.Add(-2)
+
+	if got := c.Value(); got != 5 {
+		t.Errorf("Value() = %v, want 5", got)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	got := escapeLabelValue("a\"b\\c\nd")
+	want := `a\"b\\c\nd`
+	if got != want {
+		t.Errorf("escapeLabelValue() = %q, want %q", got, want)
+	}
+}
+
+func TestHistogramVec_TextFormat(t *testing.T) {
+	reg := NewRegistry()
+	h := reg.NewHistogramVec("test_duration_seconds", "Durations", []float64{1, 0.1}, "route")
+	h.Observe(0.05, "users")
+	h.Observe(0.1, "users") // bounds are inclusive
+	h.Observe(3, "users")
+
+	if got := h.Count("users"); got != 3 {
+		t.Errorf("Count() = %d, want 3", got)
+	}
+
+	var buf bytes.Buffer
+	reg.Write(&buf)
+	out := buf.String()
+
+	want := []string{
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{route="users",le="0.1"} 2`,
+		`test_duration_seconds_bucket{route="users",le="1"} 2`,
+		`test_duration_seconds_bucket{route="users",le="+Inf"} 3`,
+		`test_duration_seconds_sum{route="users"} 3.15`,
+		`test_duration_seconds_count{route="users"} 3`,
+	}
+	for _, line := range want {
+		if !strings.Contains(out, line) {
+			t.Errorf("output missing %q\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+// Package notify delivers gateway events to webhooks.
+//
+// Components publish events (config reloaded, target ejected, SLO budget
+// exhausted, plugin failed to load) to a Dispatcher, which POSTs them as
+// JSON to every configured webhook URL from a background worker:
+//
+//	{
+//	  "id": "evt_1718000000000000000",
+//	  "type": "target.unhealthy",
+//	  "severity": "warning",
+//	  "timestamp": "2026-01-01T12:00:00Z",
+//	  "message": "Target 10.0.0.5:8080 ejected (error_rate)",
+//	  "data": {"service_id": "...", "target": "10.0.0.5:8080"}
+//	}
+//
+// With FormatSlack the body is a Slack incoming-webhook message instead.
+//
+// When a secret is configured each delivery is signed:
+//
+//	X-Gateway-Timestamp: 1718000000
+//	X-Gateway-Signature: sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))
+//
+// Deliveries are retried with exponential backoff on network errors, 429
+// and 5xx responses. Publishing never blocks: if the queue is full the
+// event is dropped and counted in gateway_notifications_total.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// Event types.
+const (
+	EventConfigReloaded     = "config.reloaded"
+	EventConfigReloadFailed = "config.reload_failed"
+	EventTargetUnhealthy    = "target.unhealthy"
+	EventTargetHealthy      = "target.healthy"
+	EventSLOBudgetExhausted = "slo.budget_exhausted"
+	EventSLOBudgetRecovered = "slo.budget_recovered"
+	EventPluginLoadFailed   = "plugin.load_failed"
+	EventGatewayDraining    = "gateway.draining"
+	EventAnomalySpike       = "anomaly.traffic_spike"
+	EventAnomalyScan        = "anomaly.path_scan"
+)
+
+// Severities.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Payload formats.
+const (
+	FormatJSON  = "json"
+	FormatSlack = "slack"
+)
+
+// Signature headers.
+const (
+	SignatureHeader = "X-Gateway-Signature"
+	TimestampHeader = "X-Gateway-Timestamp"
+	EventHeader     = "X-Gateway-Event"
+)
+
+var notificationsTotal = metrics.NewCounterVec(
+	"gateway_notifications_total",
+	"Webhook notifications by event type and result (delivered, failed, dropped)",
+	"type", "result",
+)
+
+// Event is a gateway event sent to webhooks.
+type Event struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Severity  string                 `json:"severity"`
+	Timestamp time.Time              `json:"timestamp"`
+	Message   string                 `json:"message"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// Config holds dispatcher configuration.
+type Config struct {
+	// URLs receive every event (empty = dispatcher disabled)
+	URLs []string
+
+	// Format is FormatJSON (default) or FormatSlack
+	Format string
+
+	// Secret signs deliveries with HMAC-SHA256 (empty = unsigned)
+	Secret string
+
+	// Events limits delivery to these event types (empty = all)
+	Events []string
+
+	// MaxRetries is the number of retries after a failed delivery
+	MaxRetries int
+
+	// Timeout is the per-attempt HTTP timeout
+	Timeout time.Duration
+
+	// QueueSize is the number of events buffered for delivery
+	QueueSize int
+}
+
+// DefaultConfig returns sensible defaults.
+func DefaultConfig() Config {
+	return Config{
+		Format:     FormatJSON,
+		MaxRetries: 3,
+		Timeout:    5 * time.Second,
+		QueueSize:  1000,
+	}
+}
+
+// Dispatcher queues events and delivers them to webhooks.
+//
+// A nil *Dispatcher is valid and discards events, so components can hold
+// one unconditionally.
+type Dispatcher struct {
+	config  Config
+	events  map[string]bool // allowed event types (nil = all)
+	client  *http.Client
+	queue   chan Event
+	backoff time.Duration // first retry delay (doubles per attempt)
+
+	mu     sync.RWMutex // guards closed against concurrent Publish
+	closed bool
+	done   chan struct{}
+}
+
+// NewDispatcher creates a dispatcher and starts its delivery worker.
+//
+// Returns nil if no URLs are configured.
+func NewDispatcher(config Config) *Dispatcher {
+	if len(config.URLs) == 0 {
+		return nil
+	}
+
+	defaults := DefaultConfig()
+	if config.Format == "" {
+		config.Format = defaults.Format
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+
+	d := &Dispatcher{
+		config:  config,
+		client:  &http.Client{Timeout: config.Timeout},
+		queue:   make(chan Event, config.QueueSize),
+		backoff: time.Second,
+		done:    make(chan struct{}),
+	}
+	if len(config.Events) > 0 {
+		d.events = make(map[string]bool, len(config.Events))
+		for _, eventType := range config.Events {
+			d.events[eventType] = true
+		}
+	}
+
+	go d.run()
+
+	log.Info().
+		Str("component", "notify").
+		Int("webhooks", len(config.URLs)).
+		Str("format", config.Format).
+		Bool("signed", config.Secret != "").
+		Msg("Webhook notifications enabled")
+
+	return d
+}
+
+// Publish queues an event for delivery. ID and Timestamp are filled in if
+// empty. Never blocks; events are dropped when the queue is full.
+func (d *Dispatcher) Publish(event Event) {
+	if d == nil {
+		return
+	}
+	if d.events != nil && !d.events[event.Type] {
+		return
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	if event.ID == "" {
+		event.ID = fmt.Sprintf("evt_%d", event.Timestamp.UnixNano())
+	}
+	if event.Severity == "" {
+		event.Severity = SeverityInfo
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return
+	}
+
+	select {
+	case d.queue <- event:
+	default:
+		notificationsTotal.Inc(event.Type, "dropped")
+		log.Warn().
+			Str("component", "notify").
+			Str("event", event.Type).
+			Msg("Notification queue full - event dropped")
+	}
+}
+
+// Close stops accepting events and waits (until ctx is done) for queued
+// events to be delivered.
+func (d *Dispatcher) Close(ctx context.Context) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+	case <-ctx.Done():
+	}
+}
+
+// run delivers queued events until the queue is closed.
+func (d *Dispatcher) run() {
+	defer close(d.done)
+
+	for event := range d.queue {
+		body, err := d.encode(event)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("component", "notify").
+				Str("event", event.Type).
+				Msg("Failed to encode notification")
+			continue
+		}
+
+		for _, url := range d.config.URLs {
+			if err := d.deliver(url, event.Type, body); err != nil {
+				notificationsTotal.Inc(event.Type, "failed")
+				log.Error().
+					Err(err).
+					Str("component", "notify").
+					Str("event", event.Type).
+					Str("url", url).
+					Msg("Failed to deliver notification")
+				continue
+			}
+			notificationsTotal.Inc(event.Type, "delivered")
+		}
+	}
+}
+
+// deliver POSTs body to url, retrying retryable failures.
+func (d *Dispatcher) deliver(url, eventType string, body []byte) error {
+	delay := d.backoff
+	var err error
+
+	for attempt := 0; attempt <= d.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		var retry bool
+		retry, err = d.post(url, eventType, body)
+		if err == nil || !retry {
+			return err
+		}
+	}
+
+	return fmt.Errorf("giving up after %d attempts: %w", d.config.MaxRetries+1, err)
+}
+
+// post makes one delivery attempt. Returns whether a failure is retryable.
+func (d *Dispatcher) post(url, eventType string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+
+	if d.config.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(d.config.Secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("request failed: %w", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+}
+
+// Sign returns the signature header value for a delivery:
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)).
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ============================================================================
+// Payload encoding
+// ============================================================================
+
+// encode renders an event in the configured format.
+func (d *Dispatcher) encode(event Event) ([]byte, error) {
+	if d.config.Format == FormatSlack {
+		return json.Marshal(slackMessage(event))
+	}
+	return json.Marshal(event)
+}
+
+// slackSeverityIcons prefixes Slack messages by severity.
+var slackSeverityIcons = map[string]string{
+	SeverityInfo:     ":information_source:",
+	SeverityWarning:  ":warning:",
+	SeverityCritical: ":rotating_light:",
+}
+
+// slackMessage builds a Slack incoming-webhook payload for an event.
+func slackMessage(event Event) map[string]interface{} {
+	text := fmt.Sprintf("%s *%s*: %s", slackSeverityIcons[event.Severity], event.Type, event.Message)
+
+	keys := make([]string, 0, len(event.Data))
+	for key := range event.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		fields = append(fields, map[string]interface{}{
+			"title": key,
+			"value": fmt.Sprint(event.Data[key]),
+			"short": true,
+		})
+	}
+
+	return map[string]interface{}{
+		"text": text,
+		"attachments": []map[string]interface{}{{
+			"fields": fields,
+			"ts":     event.Timestamp.Unix(),
+		}},
+	}
+}
@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookServer records deliveries and fails the first `failures` requests.
+type webhookServer struct {
+	mu       sync.Mutex
+	failures int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	s.bodies = append(s.bodies, body)
+	s.headers = append(s.headers, r.Header.Clone())
+}
+
+func newTestDispatcher(t *testing.T, config Config) *Dispatcher {
+	t.Helper()
+	d := NewDispatcher(config)
+	d.backoff = time.Millisecond
+	return d
+}
+
+func closeDispatcher(d *Dispatcher) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d.Close(ctx)
+}
+
+func TestDispatcher_DeliversSignedEvents(t *testing.T) {
+	hook := &webhookServer{failures: 2}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	d := newTestDispatcher(t, Config{URLs: []string{server.URL}, Secret: "s3cret", MaxRetries: 3})
+	d.Publish(Event{Type: EventConfigReloaded, Message: "reloaded"})
+	closeDispatcher(d)
+
+	if len(hook.bodies) != 1 {
+		t.Fatalf("expected 1 delivery after retries, got %d", len(hook.bodies))
+	}
+
+	var event Event
+	if err := json.Unmarshal(hook.bodies[0], &event); err != nil {
+		t.Fatalf("invalid JSON body: %v", err)
+	}
+	if event.Type != EventConfigReloaded || event.ID == "" || event.Severity != SeverityInfo {
+		t.Errorf("unexpected event: %+v", event)
+	}
+
+	headers := hook.headers[0]
+	want := Sign("s3cret", headers.Get(TimestampHeader), hook.bodies[0])
+	if got := headers.Get(SignatureHeader); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if got := headers.Get(EventHeader); got != EventConfigReloaded {
+		t.Errorf("event header = %q", got)
+	}
+}
+
+func TestDispatcher_GivesUpAfterMaxRetries(t *testing.T) {
+	hook := &webhookServer{failures: 10}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	d := newTestDispatcher(t, Config{URLs: []string{server.URL}, MaxRetries: 1})
+	d.Publish(Event{Type: EventTargetUnhealthy})
+	closeDispatcher(d)
+
+	if len(hook.bodies) != 0 || hook.failures != 8 {
+		t.Errorf("expected 2 failed attempts, got %d deliveries and %d failures left", len(hook.bodies), hook.failures)
+	}
+}
+
+func TestDispatcher_SlackFormatAndFilter(t *testing.T) {
+	hook := &webhookServer{}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	d := newTestDispatcher(t, Config{
+		URLs:   []string{server.URL},
+		Format: FormatSlack,
+		Events: []string{EventPluginLoadFailed},
+	})
+	d.Publish(Event{Type: EventConfigReloaded})
+	d.Publish(Event{
+		Type:     EventPluginLoadFailed,
+		Severity: SeverityCritical,
+		Message:  "Plugin cors failed to load",
+		Data:     map[string]interface{}{"plugin": "cors"},
+	})
+	closeDispatcher(d)
+
+	if len(hook.bodies) != 1 {
+		t.Fatalf("expected only the filtered event, got %d deliveries", len(hook.bodies))
+	}
+
+	var message struct {
+		Text        string `json:"text"`
+		Attachments []struct {
+			Fields []struct {
+				Title string `json:"title"`
+				Value string `json:"value"`
+			} `json:"fields"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal(hook.bodies[0], &message); err != nil {
+		t.Fatalf("invalid Slack payload: %v", err)
+	}
+	if message.Text != ":rotating_light: *plugin.load_failed*: Plugin cors failed to load" {
+		t.Errorf("text = %q", message.Text)
+	}
+	if len(message.Attachments) != 1 || len(message.Attachments[0].Fields) != 1 || message.Attachments[0].Fields[0].Value != "cors" {
+		t.Errorf("unexpected attachments: %+v", message.Attachments)
+	}
+}
+
+func TestDispatcher_NilIsNoop(t *testing.T) {
+	var d *Dispatcher
+	d.Publish(Event{Type: EventConfigReloaded})
+	d.Close(context.Background())
+
+	if NewDispatcher(Config{}) != nil {
+		t.Error("expected nil dispatcher without URLs")
+	}
+
+	// Publishing after Close must not panic
+	hook := &webhookServer{}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+	d = newTestDispatcher(t, Config{URLs: []string{server.URL}})
+	closeDispatcher(d)
+	d.Publish(Event{Type: EventConfigReloaded})
+}
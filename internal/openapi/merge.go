@@ -0,0 +1,337 @@
+// Package openapi merges the OpenAPI documents attached to services into a
+// single document describing what is actually routable through the gateway.
+//
+// For every service with a spec (services.openapi_spec):
+//   - Spec paths are rewritten to gateway paths using the service's routes
+//     (service path prefix removed, route prefix added for strip_path routes)
+//   - Each operation is kept only if the router would send that method and
+//     path to that route right now (method, host and schedule included)
+//   - Components are prefixed with the service name ("users_User") and all
+//     $refs and security requirements are rewritten to match, so specs with
+//     overlapping schema names merge cleanly
+//
+// Only OpenAPI 3.x documents are supported; others are skipped with a warning.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+)
+
+// Document is a decoded OpenAPI document.
+type Document = map[string]interface{}
+
+// Source is one service's spec and the routes pointing at it.
+type Source struct {
+	Service *database.Service
+	Routes  []*database.Route
+	Spec    Document
+}
+
+// Matcher resolves requests to routes (implemented by *router.Router).
+type Matcher interface {
+	Match(req *http.Request) (*router.MatchResult, error)
+}
+
+// Info describes the merged document.
+type Info struct {
+	Title   string
+	Version string
+}
+
+// operationMethods are the OpenAPI path item keys that are operations.
+var operationMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// componentSections are the component maps whose names are prefixed.
+var componentSections = []string{
+	"schemas", "responses", "parameters", "examples", "requestBodies",
+	"headers", "securitySchemes", "links", "callbacks",
+}
+
+// templateParam matches OpenAPI path templates ("{id}").
+var templateParam = regexp.MustCompile(`\{[^/{}]+\}`)
+
+// nonIdentifier matches characters not allowed in component names.
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Merge builds a single OpenAPI 3 document from the sources.
+//
+// Returns the merged document and human-readable warnings about specs or
+// operations that were skipped.
+func Merge(sources []Source, matcher Matcher, info Info) (Document, []string) {
+	var warnings []string
+
+	paths := make(map[string]interface{})
+	components := make(map[string]interface{})
+	var tags []interface{}
+
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Service.Name < sources[j].Service.Name
+	})
+
+	for _, src := range sources {
+		version, _ := src.Spec["openapi"].(string)
+		if !strings.HasPrefix(version, "3.") {
+			warnings = append(warnings, fmt.Sprintf("service %s: unsupported spec version %q (only OpenAPI 3.x)", src.Service.Name, version))
+			continue
+		}
+
+		prefix := nonIdentifier.ReplaceAllString(src.Service.Name, "_") + "_"
+		spec := rewriteRefs(src.Spec, prefix).(Document)
+
+		mergeComponents(components, spec, prefix)
+
+		specPaths, _ := spec["paths"].(map[string]interface{})
+		added := 0
+		for _, specPath := range sortedKeys(specPaths) {
+			item, ok := specPaths[specPath].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			added += addPathItem(paths, src, specPath, item, spec["security"], prefix, matcher)
+		}
+
+		if added == 0 {
+			warnings = append(warnings, fmt.Sprintf("service %s: no spec operations are routable through the gateway", src.Service.Name))
+			continue
+		}
+
+		tag := map[string]interface{}{"name": src.Service.Name}
+		if specInfo, ok := spec["info"].(map[string]interface{}); ok {
+			if desc, ok := specInfo["description"].(string); ok && desc != "" {
+				tag["description"] = desc
+			} else if title, ok := specInfo["title"].(string); ok {
+				tag["description"] = title
+			}
+		}
+		tags = append(tags, tag)
+	}
+
+	doc := Document{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   info.Title,
+			"version": info.Version,
+		},
+		"paths": paths,
+	}
+	if len(components) > 0 {
+		doc["components"] = components
+	}
+	if len(tags) > 0 {
+		doc["tags"] = tags
+	}
+
+	return doc, warnings
+}
+
+// addPathItem adds the routable operations of one spec path. Returns the
+// number of operations added.
+func addPathItem(paths map[string]interface{}, src Source, specPath string, item map[string]interface{}, topSecurity interface{}, prefix string, matcher Matcher) int {
+	added := 0
+
+	for _, route := range src.Routes {
+		for _, gatewayPath := range gatewayPaths(src.Service, route, specPath) {
+			for _, method := range operationMethods {
+				op, ok := item[method].(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				target, _ := paths[gatewayPath].(map[string]interface{})
+				if target != nil && target[method] != nil {
+					continue // first route wins
+				}
+
+				if !routable(matcher, route, strings.ToUpper(method), gatewayPath) {
+					continue
+				}
+
+				if target == nil {
+					target = make(map[string]interface{})
+					if params, ok := item["parameters"]; ok {
+						target["parameters"] = params
+					}
+					paths[gatewayPath] = target
+				}
+
+				target[method] = annotateOperation(op, src.Service.Name, prefix, topSecurity)
+				added++
+			}
+		}
+	}
+
+	return added
+}
+
+// gatewayPaths returns the client-facing paths for a spec path served
+// through route.
+func gatewayPaths(service *database.Service, route *database.Route, specPath string) []string {
+	// Spec paths are relative to the service root; the proxy prepends
+	// service.path to the forwarded path, so remove it here.
+	rest := specPath
+	if service.Path.Valid && service.Path.String != "" && service.Path.String != "/" {
+		base := strings.TrimSuffix(service.Path.String, "/")
+		if rest != base && !strings.HasPrefix(rest, base+"/") {
+			return nil
+		}
+		rest = strings.TrimPrefix(rest, base)
+		if rest == "" {
+			rest = "/"
+		}
+	}
+
+	if !route.StripPath {
+		return []string{rest}
+	}
+
+	// strip_path removes the literal route prefix, so the gateway path is
+	// route prefix + forwarded path
+	var out []string
+	for _, routePath := range route.Paths {
+		if strings.ContainsAny(routePath, ":*") {
+			continue
+		}
+		p := strings.TrimSuffix(routePath, "/") + rest
+		if rest == "/" {
+			p = routePath
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// routable asks the router whether method + path reaches route.
+func routable(matcher Matcher, route *database.Route, method, path string) bool {
+	sample := templateParam.ReplaceAllString(path, "1")
+
+	req := &http.Request{
+		Method: method,
+		URL:    &url.URL{Path: sample},
+		Header: make(http.Header),
+	}
+	if len(route.Hosts) > 0 {
+		req.Host = strings.ReplaceAll(route.Hosts[0], "*", "x")
+	}
+
+	match, err := matcher.Match(req)
+	return err == nil && match.Route.ID == route.ID
+}
+
+// annotateOperation tags an operation with its service and makes its
+// operationId and security requirements unique across services.
+func annotateOperation(op map[string]interface{}, serviceName, prefix string, topSecurity interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(op)+1)
+	for k, v := range op {
+		out[k] = v
+	}
+
+	out["tags"] = []interface{}{serviceName}
+	if id, ok := out["operationId"].(string); ok && id != "" {
+		out["operationId"] = prefix + id
+	}
+
+	security, ok := out["security"]
+	if !ok {
+		security = topSecurity
+	}
+	if reqs, ok := security.([]interface{}); ok {
+		out["security"] = prefixSecurity(reqs, prefix)
+	}
+
+	return out
+}
+
+// prefixSecurity renames the scheme keys of security requirements.
+func prefixSecurity(reqs []interface{}, prefix string) []interface{} {
+	out := make([]interface{}, 0, len(reqs))
+	for _, r := range reqs {
+		req, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		renamed := make(map[string]interface{}, len(req))
+		for name, scopes := range req {
+			renamed[prefix+name] = scopes
+		}
+		out = append(out, renamed)
+	}
+	return out
+}
+
+// mergeComponents copies a spec's components under prefixed names.
+func mergeComponents(dst map[string]interface{}, spec Document, prefix string) {
+	comps, ok := spec["components"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for _, section := range componentSections {
+		entries, ok := comps[section].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		target, _ := dst[section].(map[string]interface{})
+		if target == nil {
+			target = make(map[string]interface{})
+			dst[section] = target
+		}
+		for name, value := range entries {
+			target[prefix+name] = value
+		}
+	}
+}
+
+// rewriteRefs returns a deep copy of v with local component $refs prefixed.
+func rewriteRefs(v interface{}, prefix string) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			if ref, ok := child.(string); ok && k == "$ref" {
+				out[k] = prefixRef(ref, prefix)
+				continue
+			}
+			out[k] = rewriteRefs(child, prefix)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, child := range val {
+			out[i] = rewriteRefs(child, prefix)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// prefixRef rewrites "#/components/<section>/<name>" to use a prefixed name.
+func prefixRef(ref, prefix string) string {
+	const root = "#/components/"
+	if !strings.HasPrefix(ref, root) {
+		return ref
+	}
+	parts := strings.SplitN(strings.TrimPrefix(ref, root), "/", 2)
+	if len(parts) != 2 {
+		return ref
+	}
+	return root + parts[0] + "/" + prefix + parts[1]
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,145 @@
+package openapi
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+)
+
+func usersSpec() Document {
+	return Document{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": "Users", "version": "1.0"},
+		"paths": map[string]interface{}{
+			"/users": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "listUsers",
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{
+							"content": map[string]interface{}{
+								"application/json": map[string]interface{}{
+									"schema": map[string]interface{}{"$ref": "#/components/schemas/User"},
+								},
+							},
+						},
+					},
+				},
+				"delete": map[string]interface{}{"operationId": "deleteAll"},
+			},
+			"/internal/metrics": map[string]interface{}{
+				"get": map[string]interface{}{"operationId": "metrics"},
+			},
+		},
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"User": map[string]interface{}{"type": "object"},
+			},
+		},
+	}
+}
+
+func TestMerge_RewritesPathsAndFiltersRoutable(t *testing.T) {
+	service := &database.Service{ID: "svc-users", Name: "users", Enabled: true}
+	route := &database.Route{
+		ID:        "route-users",
+		ServiceID: service.ID,
+		Paths:     []string{"/api/v1", "/api/v1/*"},
+		Methods:   []string{"GET"},
+		StripPath: true,
+		Enabled:   true,
+	}
+	rt := router.NewRouter([]*database.Route{route}, []*database.Service{service}, []plugin.PluginInstance{})
+
+	doc, warnings := Merge([]Source{{
+		Service: service,
+		Routes:  []*database.Route{route},
+		Spec:    usersSpec(),
+	}}, rt, Info{Title: "Gateway", Version: "test"})
+
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+
+	paths := doc["paths"].(map[string]interface{})
+	item, ok := paths["/api/v1/users"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected /api/v1/users in merged paths, got %v", paths)
+	}
+
+	get, ok := item["get"].(map[string]interface{})
+	if !ok {
+		t.Fatal("expected GET operation to be routable")
+	}
+	if _, ok := item["delete"]; ok {
+		t.Error("DELETE is not allowed by the route and should be omitted")
+	}
+	if get["operationId"] != "users_listUsers" {
+		t.Errorf("operationId = %v, want users_listUsers", get["operationId"])
+	}
+
+	ref := get["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})["$ref"]
+	if ref != "#/components/schemas/users_User" {
+		t.Errorf("$ref = %v, want prefixed component", ref)
+	}
+
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	if _, ok := schemas["users_User"]; !ok {
+		t.Error("expected prefixed component users_User")
+	}
+}
+
+func TestMerge_ServicePathPrefix(t *testing.T) {
+	service := &database.Service{
+		ID:      "svc-orders",
+		Name:    "orders",
+		Path:    sql.NullString{String: "/v2", Valid: true},
+		Enabled: true,
+	}
+	route := &database.Route{
+		ID:        "route-orders",
+		ServiceID: service.ID,
+		Paths:     []string{"/orders", "/orders/:id"},
+		Methods:   []string{"GET"},
+		Enabled:   true,
+	}
+	rt := router.NewRouter([]*database.Route{route}, []*database.Service{service}, []plugin.PluginInstance{})
+
+	spec := Document{
+		"openapi": "3.1.0",
+		"paths": map[string]interface{}{
+			"/v2/orders/{id}": map[string]interface{}{
+				"get": map[string]interface{}{"operationId": "getOrder"},
+			},
+			"/v1/legacy": map[string]interface{}{
+				"get": map[string]interface{}{"operationId": "legacy"},
+			},
+		},
+	}
+
+	doc, _ := Merge([]Source{{Service: service, Routes: []*database.Route{route}, Spec: spec}}, rt, Info{})
+
+	paths := doc["paths"].(map[string]interface{})
+	if _, ok := paths["/orders/{id}"]; !ok {
+		t.Errorf("expected /orders/{id}, got %v", paths)
+	}
+	if len(paths) != 1 {
+		t.Errorf("expected only routable paths, got %v", paths)
+	}
+}
+
+func TestMerge_SkipsUnsupportedVersion(t *testing.T) {
+	service := &database.Service{ID: "svc", Name: "legacy", Enabled: true}
+	rt := router.NewRouter(nil, []*database.Service{service}, []plugin.PluginInstance{})
+
+	_, warnings := Merge([]Source{{
+		Service: service,
+		Spec:    Document{"swagger": "2.0", "paths": map[string]interface{}{}},
+	}}, rt, Info{})
+
+	if len(warnings) != 1 {
+		t.Errorf("expected one warning for swagger 2.0 spec, got %v", warnings)
+	}
+}
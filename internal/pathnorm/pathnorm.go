@@ -0,0 +1,177 @@
+// Package pathnorm normalizes request paths before routing.
+//
+// Routes are matched against the request path, and the upstream URL is
+// built from it, so ambiguous paths can slip past route intent:
+//
+//	/public/%2e%2e/admin      dot segments after decoding
+//	/public/..%2Fadmin        encoded slash hiding a segment boundary
+//	/public//admin            empty segments
+//
+// Handler rewrites every request to a single canonical path (or rejects
+// it with 400) before the router and proxy see it:
+//   - Each segment is percent-decoded once; invalid escapes and NUL bytes
+//     are rejected
+//   - Encoded slashes (%2F) are rejected, or decoded into real segment
+//     boundaries
+//   - Dot segments ("." and "..", encoded or not) are rejected, or
+//     resolved; ".." above the root is always rejected
+//   - Runs of slashes are collapsed
+//
+// The canonical path is stored in URL.Path with URL.RawPath cleared, so it
+// is re-escaped consistently wherever it is used.
+package pathnorm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+var requestsTotal = metrics.NewCounterVec(
+	"gateway_path_normalization_total",
+	"Requests whose path was rewritten or rejected by normalization, by result and reason.",
+	"result", "reason",
+)
+
+// Policies for encoded slashes and dot segments.
+const (
+	Reject  = "reject"
+	Decode  = "decode"  // encoded slashes
+	Resolve = "resolve" // dot segments
+)
+
+// Errors returned by Normalize.
+var (
+	ErrInvalidEncoding = errors.New("invalid percent-encoding in path")
+	ErrEncodedSlash    = errors.New("encoded slash in path")
+	ErrDotSegment      = errors.New("dot segment in path")
+	ErrTraversal       = errors.New("path escapes the root")
+)
+
+// Config holds path normalization settings.
+type Config struct {
+	// EncodedSlashes is Reject or Decode
+	EncodedSlashes string
+
+	// DotSegments is Reject or Resolve
+	DotSegments string
+
+	// MergeSlashes collapses "//" into "/"
+	MergeSlashes bool
+}
+
+// DefaultConfig returns the strictest settings.
+func DefaultConfig() Config {
+	return Config{
+		EncodedSlashes: Reject,
+		DotSegments:    Reject,
+		MergeSlashes:   true,
+	}
+}
+
+// Normalize returns the canonical form of an escaped request path
+// (URL.EscapedPath()), decoded.
+func Normalize(escapedPath string, config Config) (string, error) {
+	trailingSlash := len(escapedPath) > 1 && strings.HasSuffix(escapedPath, "/")
+
+	var segments []string
+	for _, raw := range strings.Split(strings.TrimPrefix(escapedPath, "/"), "/") {
+		segment, err := url.PathUnescape(raw)
+		if err != nil || strings.IndexByte(segment, 0) >= 0 {
+			return "", ErrInvalidEncoding
+		}
+
+		parts := []string{segment}
+		if strings.Contains(segment, "/") {
+			if config.EncodedSlashes != Decode {
+				return "", ErrEncodedSlash
+			}
+			parts = strings.Split(segment, "/")
+		}
+
+		for _, part := range parts {
+			switch part {
+			case ".", "..":
+				if config.DotSegments != Resolve {
+					return "", ErrDotSegment
+				}
+				if part == ".." {
+					if len(segments) == 0 {
+						return "", ErrTraversal
+					}
+					segments = segments[:len(segments)-1]
+				}
+			case "":
+				if !config.MergeSlashes {
+					segments = append(segments, part)
+				}
+			default:
+				segments = append(segments, part)
+			}
+		}
+	}
+
+	path := "/" + strings.Join(segments, "/")
+	if trailingSlash && len(segments) > 0 && segments[len(segments)-1] != "" {
+		path += "/"
+	}
+	return path, nil
+}
+
+// Handler normalizes request paths before passing requests to next.
+// Requests that can't be normalized get 400.
+func Handler(config Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		escaped := r.URL.EscapedPath()
+		if !strings.HasPrefix(escaped, "/") {
+			// "OPTIONS *" and similar; nothing to route
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		path, err := Normalize(escaped, config)
+		if err != nil {
+			requestsTotal.Inc("rejected", reason(err))
+			log.Debug().
+				Err(err).
+				Str("component", "pathnorm").
+				Str("path", escaped).
+				Msg("Rejected request path")
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error":"bad request","message":%q}`, err.Error())
+			return
+		}
+
+		if path != r.URL.Path || r.URL.RawPath != "" {
+			if path != r.URL.Path {
+				requestsTotal.Inc("normalized", "rewritten")
+			}
+			r.URL.Path = path
+			r.URL.RawPath = ""
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reason labels a normalization error for metrics.
+func reason(err error) string {
+	switch err {
+	case ErrEncodedSlash:
+		return "encoded_slash"
+	case ErrDotSegment:
+		return "dot_segment"
+	case ErrTraversal:
+		return "traversal"
+	default:
+		return "invalid_encoding"
+	}
+}
@@ -0,0 +1,80 @@
+package pathnorm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	strict := DefaultConfig()
+	lenient := Config{EncodedSlashes: Decode, DotSegments: Resolve, MergeSlashes: true}
+	keepSlashes := Config{EncodedSlashes: Reject, DotSegments: Reject, MergeSlashes: false}
+
+	tests := []struct {
+		name    string
+		path    string
+		config  Config
+		want    string
+		wantErr error
+	}{
+		{"root", "/", strict, "/", nil},
+		{"plain", "/api/users/1", strict, "/api/users/1", nil},
+		{"trailing slash kept", "/api/users/", strict, "/api/users/", nil},
+		{"decodes escapes", "/files/a%20b", strict, "/files/a b", nil},
+		{"merges slashes", "/api//users///1", strict, "/api/users/1", nil},
+		{"keeps slashes when disabled", "/api//users", keepSlashes, "/api//users", nil},
+
+		{"rejects encoded slash", "/public/..%2Fadmin", strict, "", ErrEncodedSlash},
+		{"decodes encoded slash", "/a%2Fb", lenient, "/a/b", nil},
+		{"encoded slash then dot segment", "/public/x%2F..%2Fadmin", lenient, "/public/admin", nil},
+
+		{"rejects dot dot", "/public/../admin", strict, "", ErrDotSegment},
+		{"rejects encoded dot dot", "/public/%2e%2e/admin", strict, "", ErrDotSegment},
+		{"rejects single dot", "/a/./b", strict, "", ErrDotSegment},
+		{"resolves dot segments", "/a/./b/../c", lenient, "/a/c", nil},
+		{"resolves encoded dot segments", "/a/%2E%2E/c", lenient, "/c", nil},
+		{"rejects traversal above root", "/a/../../etc/passwd", lenient, "", ErrTraversal},
+		{"rejects encoded traversal above root", "/%2e%2e%2fetc", lenient, "", ErrTraversal},
+
+		{"rejects invalid escape", "/a%zz", strict, "", ErrInvalidEncoding},
+		{"rejects NUL", "/a%00b", strict, "", ErrInvalidEncoding},
+		{"decodes once only", "/a%252e%252e", strict, "/a%2e%2e", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.path, tt.config)
+			if err != tt.wantErr {
+				t.Fatalf("Normalize(%q) error = %v, want %v", tt.path, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var gotPath, gotEscaped string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotEscaped = r.URL.Path, r.URL.EscapedPath()
+	})
+	h := Handler(Config{EncodedSlashes: Decode, DotSegments: Resolve, MergeSlashes: true}, next)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api//x%2F..%2Fusers/a%3Fb", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if gotPath != "/api/users/a?b" || gotEscaped != "/api/users/a%3Fb" {
+		t.Errorf("path = %q (escaped %q), want /api/users/a?b (escaped /api/users/a%%3Fb)", gotPath, gotEscaped)
+	}
+
+	strict := Handler(DefaultConfig(), next)
+	rec = httptest.NewRecorder()
+	strict.ServeHTTP(rec, httptest.NewRequest("GET", "/public/%2e%2e/admin", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 for dot segment", rec.Code)
+	}
+}
@@ -0,0 +1,304 @@
+// Package plugin - Asynchronous AfterResponse plugins
+//
+// AfterResponse plugins that only observe the exchange (loggers,
+// analytics, metrics exporters) can run off the request path:
+//
+//	{
+//	  "async": true,
+//	  "async_queue_size": 500,
+//	  "async_drop_policy": "drop-oldest"
+//	}
+//
+// Their BeforeRequest phase runs as usual. In the AfterResponse phase the
+// chain flushes the response, hands the plugin a snapshot of the request
+// context and moves on; a bounded worker pool (PLUGIN_ASYNC_WORKERS) runs
+// it later. Every instance has its own queue, so a slow exporter can't
+// starve the others, and workers take from the queues in turn. When a
+// queue is full the new job is dropped ("drop-newest", the default) or
+// the oldest queued one is ("drop-oldest").
+//
+// An async plugin sees the request, the final status, size and captured
+// body, and the metadata set so far; anything it writes to the response
+// is discarded and it can't abort or fail the request, so "critical" and
+// shadow mode don't apply. Elapsed() is frozen when the job is queued.
+// Jobs are counted in gateway_plugin_async_jobs_total and the decision
+// log records them as "queued" or "dropped".
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// Async queue drop policies (config key "async_drop_policy").
+const (
+	DropNewest = "drop-newest"
+	DropOldest = "drop-oldest"
+)
+
+var (
+	asyncJobs = metrics.NewCounterVec(
+		"gateway_plugin_async_jobs_total",
+		"Jobs of async AfterResponse plugins, by plugin and result (queued, dropped, completed, failed).",
+		"plugin", "result",
+	)
+	asyncQueueDepth = metrics.NewGaugeVec(
+		"gateway_plugin_async_queue_depth",
+		"Jobs waiting in the queues of async AfterResponse plugins, by plugin.",
+		"plugin",
+	)
+)
+
+// AsyncPool runs async AfterResponse plugins on a fixed number of workers.
+type AsyncPool struct {
+	queueSize int // default per-instance queue size
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queues  map[string]*asyncQueue
+	order   []*asyncQueue // round-robin order of queues
+	next    int
+	pending int
+	closed  bool
+	done    chan struct{}
+}
+
+// asyncQueue holds the jobs of one plugin instance.
+type asyncQueue struct {
+	plugin string
+	jobs   []asyncJob
+}
+
+// asyncJob is one AfterResponse execution of an async plugin.
+type asyncJob struct {
+	instance PluginInstance
+	ctx      *Context
+}
+
+// NewAsyncPool starts workers running async plugins, with queues of
+// queueSize jobs per plugin instance unless the instance sets
+// "async_queue_size".
+//
+// Returns nil if workers is not positive; async plugins then can't be
+// loaded.
+func NewAsyncPool(workers, queueSize int) *AsyncPool {
+	if workers <= 0 {
+		return nil
+	}
+	if queueSize <= 0 {
+		queueSize = 1000
+	}
+
+	p := &AsyncPool{
+		queueSize: queueSize,
+		queues:    make(map[string]*asyncQueue),
+		done:      make(chan struct{}),
+	}
+	p.cond = sync.NewCond(&p.mu)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			p.work()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(p.done)
+	}()
+
+	log.Info().
+		Str("component", "plugin_async").
+		Int("workers", workers).
+		Int("queue_size", queueSize).
+		Msg("Async plugin workers started")
+
+	return p
+}
+
+// Enqueue queues an AfterResponse execution of instance with a snapshot
+// of ctx. Never blocks; returns false if the job was dropped (with
+// drop-oldest, the oldest queued job is dropped instead).
+func (p *AsyncPool) Enqueue(instance PluginInstance, ctx *Context) bool {
+	name := instance.Plugin.Name()
+	key := name
+	if instance.Config != nil && instance.Config.ID != "" {
+		key = instance.Config.ID
+	}
+	size := instance.AsyncQueueSize
+	if size <= 0 {
+		size = p.queueSize
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		asyncJobs.Inc(name, "dropped")
+		return false
+	}
+
+	q, ok := p.queues[key]
+	if !ok {
+		q = &asyncQueue{plugin: name}
+		p.queues[key] = q
+		p.order = append(p.order, q)
+	}
+
+	if len(q.jobs) >= size {
+		asyncJobs.Inc(name, "dropped")
+		if instance.AsyncDropPolicy != DropOldest {
+			return false
+		}
+		q.jobs[0] = asyncJob{}
+		q.jobs = q.jobs[1:]
+		p.pending--
+		asyncQueueDepth.Add(-1, name)
+	}
+
+	q.jobs = append(q.jobs, asyncJob{instance: instance, ctx: ctx.detach()})
+	p.pending++
+	asyncJobs.Inc(name, "queued")
+	asyncQueueDepth.Add(1, name)
+	p.cond.Signal()
+	return true
+}
+
+// Close stops accepting jobs and waits (until ctx is done) for queued jobs
+// to finish.
+func (p *AsyncPool) Close(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		log.Warn().
+			Str("component", "plugin_async").
+			Int("pending", p.Pending()).
+			Msg("Async plugin jobs did not finish before shutdown")
+	}
+}
+
+// Pending returns the number of queued jobs.
+func (p *AsyncPool) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pending
+}
+
+// work runs queued jobs until the pool is closed and drained.
+func (p *AsyncPool) work() {
+	for {
+		p.mu.Lock()
+		for p.pending == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if p.pending == 0 {
+			p.mu.Unlock()
+			return
+		}
+		job := p.take()
+		p.mu.Unlock()
+
+		p.run(job)
+	}
+}
+
+// take removes the next job, visiting the queues in turn. Requires p.mu
+// and a pending job.
+func (p *AsyncPool) take() asyncJob {
+	for {
+		q := p.order[p.next%len(p.order)]
+		p.next = (p.next + 1) % len(p.order)
+		if len(q.jobs) == 0 {
+			continue
+		}
+		job := q.jobs[0]
+		q.jobs[0] = asyncJob{}
+		q.jobs = q.jobs[1:]
+		p.pending--
+		asyncQueueDepth.Add(-1, q.plugin)
+		return job
+	}
+}
+
+// run executes a job, containing panics so a plugin can't take down a
+// worker.
+func (p *AsyncPool) run(job asyncJob) {
+	name := job.instance.Plugin.Name()
+	ctx := job.ctx
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		ctx.plugin = name
+		return job.instance.Plugin.Execute(ctx)
+	}()
+
+	if err != nil {
+		asyncJobs.Inc(name, "failed")
+		ctx.LogError(name, err, "Async plugin failed")
+		return
+	}
+	asyncJobs.Inc(name, "completed")
+}
+
+// dispatchAsync queues an async plugin's AfterResponse execution, flushing
+// the response first so the client isn't kept waiting on buffered bytes.
+func (c *Chain) dispatchAsync(instance PluginInstance, ctx *Context) {
+	if !ctx.flushed && ctx.Response.Written() {
+		_ = http.NewResponseController(ctx.Response.ResponseWriter).Flush()
+		ctx.flushed = true
+	}
+
+	outcome := DecisionQueued
+	if !instance.async.Enqueue(instance, ctx) {
+		outcome = DecisionDropped
+		log.Debug().
+			Str("component", "plugin_async").
+			Str("plugin", instance.Plugin.Name()).
+			Str("policy", instance.AsyncDropPolicy).
+			Msg("Async plugin queue full - job dropped")
+	}
+	ctx.decisions = append(ctx.decisions, Decision{Plugin: instance.Plugin.Name(), Phase: ctx.Phase, Outcome: outcome})
+}
+
+// detach returns a snapshot of c for an async plugin: its own request,
+// metadata and a response that discards writes, on a context that isn't
+// canceled when the request ends.
+func (c *Context) detach() *Context {
+	base := context.WithoutCancel(c.Request.Context())
+	metadata := make(map[string]interface{}, len(c.Metadata))
+	for k, v := range c.Metadata {
+		metadata[k] = v
+	}
+	return &Context{
+		Request:   c.Request.Clone(base),
+		Response:  c.Response.shadowCopy(),
+		Route:     c.Route,
+		Service:   c.Service,
+		Phase:     c.Phase,
+		StartTime: c.StartTime,
+		Metadata:  metadata,
+		finished:  time.Now(),
+		ctx:       base,
+	}
+}
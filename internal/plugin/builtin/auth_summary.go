@@ -0,0 +1,218 @@
+// Package builtin - Auth summary plugin
+//
+// The auth-summary plugin tells backends how a request was authenticated,
+// so they can run defense-in-depth checks (e.g. "writes need a token with
+// orders:write") without parsing credentials themselves:
+//
+//	X-Auth-Mechanism: paseto
+//	X-Auth-Scopes: orders:read orders:write
+//	X-Auth-Claims: {"iss":"https://auth.internal","tenant":"acme"}
+//
+// The mechanism is set by the auth plugin that authenticated the request:
+//   - paseto-auth: "paseto"
+//   - opaque-token-auth: "apikey" (database backend) or "token" (redis)
+//   - ldap-auth: "ldap"
+//   - session-auth: "session"
+//   - signed-url: "signed_url" (links bound to a consumer)
+//
+// Requests without a consumer are "anonymous"; consumers set by plugins
+// that don't record a mechanism are "unknown". Scopes come from the
+// token's "scope" (space-separated) or "scp" claim, and the claims header
+// holds the configured claims present in the token as compact JSON. Both
+// are omitted when empty.
+//
+// Configuration Example:
+//
+//	{
+//	  "claims": ["iss", "tenant"],
+//	  "mechanism_header": "X-Auth-Mechanism",
+//	  "scopes_header": "X-Auth-Scopes",
+//	  "claims_header": "X-Auth-Claims"
+//	}
+//
+// The plugin must run after the route's auth plugins. Client-supplied
+// values of the headers are always removed, so backends can trust them.
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// Authentication mechanisms reported by auth-summary.
+const (
+	authMechanismAnonymous = "anonymous"
+	authMechanismUnknown   = "unknown"
+	authMechanismPaseto    = "paseto"
+	authMechanismAPIKey    = "apikey"
+	authMechanismToken     = "token"
+	authMechanismLDAP      = "ldap"
+	authMechanismSession   = "session"
+	authMechanismSignedURL = "signed_url"
+)
+
+// AuthSummaryPlugin adds authentication summary headers to upstream requests.
+type AuthSummaryPlugin struct {
+	config AuthSummaryConfig
+}
+
+// AuthSummaryConfig holds configuration for the auth summary plugin.
+type AuthSummaryConfig struct {
+	// Claims are the token claims copied into the claims header
+	// Default: none (no claims header)
+	Claims []string `json:"claims"`
+
+	// MechanismHeader carries the authentication mechanism
+	// Default: "X-Auth-Mechanism"
+	MechanismHeader string `json:"mechanism_header"`
+
+	// ScopesHeader carries the token's scopes, space-separated
+	// Default: "X-Auth-Scopes"
+	ScopesHeader string `json:"scopes_header"`
+
+	// ClaimsHeader carries the selected claims as a JSON object
+	// Default: "X-Auth-Claims"
+	ClaimsHeader string `json:"claims_header"`
+}
+
+// NewAuthSummaryPlugin creates a new auth summary plugin.
+func NewAuthSummaryPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := AuthSummaryConfig{
+		MechanismHeader: "X-Auth-Mechanism",
+		ScopesHeader:    "X-Auth-Scopes",
+		ClaimsHeader:    "X-Auth-Claims",
+	}
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid auth-summary config: %w", err)
+		}
+	}
+
+	headers := []struct{ field, name string }{
+		{"mechanism_header", config.MechanismHeader},
+		{"scopes_header", config.ScopesHeader},
+		{"claims_header", config.ClaimsHeader},
+	}
+	seen := make(map[string]string, len(headers))
+	for _, h := range headers {
+		field, name := h.field, h.name
+		if name == "" {
+			return nil, fmt.Errorf("invalid auth-summary config: %s is required", field)
+		}
+		canonical := http.CanonicalHeaderKey(name)
+		if other, ok := seen[canonical]; ok {
+			return nil, fmt.Errorf("invalid auth-summary config: %s and %s are both %q", other, field, name)
+		}
+		seen[canonical] = field
+	}
+	for _, claim := range config.Claims {
+		if claim == "" {
+			return nil, fmt.Errorf("invalid auth-summary config: empty claim name")
+		}
+	}
+
+	return &AuthSummaryPlugin{config: config}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *AuthSummaryPlugin) Name() string {
+	return "auth-summary"
+}
+
+// Execute replaces the summary headers with the request's authentication.
+func (p *AuthSummaryPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	header := ctx.Request.Header
+	header.Del(p.config.MechanismHeader)
+	header.Del(p.config.ScopesHeader)
+	header.Del(p.config.ClaimsHeader)
+
+	header.Set(p.config.MechanismHeader, authMechanism(ctx))
+
+	tokenClaims := plugin.KeyTokenClaims.Value(ctx)
+	if len(tokenClaims) == 0 {
+		return nil
+	}
+
+	if scopes := tokenScopes(tokenClaims); len(scopes) > 0 {
+		header.Set(p.config.ScopesHeader, strings.Join(scopes, " "))
+	}
+
+	if len(p.config.Claims) > 0 {
+		selected := make(map[string]interface{}, len(p.config.Claims))
+		for _, claim := range p.config.Claims {
+			if value, ok := tokenClaims[claim]; ok {
+				selected[claim] = value
+			}
+		}
+		if len(selected) > 0 {
+			encoded, err := json.Marshal(selected)
+			if err != nil {
+				return fmt.Errorf("failed to encode claims: %w", err)
+			}
+			header.Set(p.config.ClaimsHeader, string(encoded))
+		}
+	}
+
+	return nil
+}
+
+// authMechanism returns how the request was authenticated.
+func authMechanism(ctx *plugin.Context) string {
+	if mechanism := plugin.KeyAuthMechanism.Value(ctx); mechanism != "" {
+		return mechanism
+	}
+	if plugin.KeyConsumerID.Value(ctx) != "" {
+		return authMechanismUnknown
+	}
+	return authMechanismAnonymous
+}
+
+// tokenScopes returns the scopes of a token: the space-separated "scope"
+// claim (RFC 8693), or "scp" as a list or string.
+func tokenScopes(claims map[string]interface{}) []string {
+	if scopes := claimStrings(claims, "scope"); len(scopes) > 0 {
+		return scopes
+	}
+	return claimStrings(claims, "scp")
+}
+
+// claimStrings returns a claim holding a list of strings or a
+// space-separated string, without duplicates.
+func claimStrings(claims map[string]interface{}, name string) []string {
+	var raw []string
+	switch v := claims[name].(type) {
+	case string:
+		raw = strings.Fields(v)
+	case []interface{}:
+		raw = stringValues(v)
+	}
+
+	seen := make(map[string]bool, len(raw))
+	values := raw[:0]
+	for _, s := range raw {
+		if s != "" && !seen[s] {
+			seen[s] = true
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// stringValues returns the string elements of a decoded JSON array.
+func stringValues(values []interface{}) []string {
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			strs = append(strs, strings.TrimSpace(s))
+		}
+	}
+	return strs
+}
@@ -0,0 +1,134 @@
+package builtin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+func TestAuthSummary_Headers(t *testing.T) {
+	p, err := NewAuthSummaryPlugin(json.RawMessage(`{"claims": ["iss", "tenant", "missing"]}`))
+	if err != nil {
+		t.Fatalf("NewAuthSummaryPlugin() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		consumer  string
+		mechanism string
+		claims    map[string]interface{}
+		want      http.Header // the summary headers sent upstream
+	}{
+		{
+			name: "anonymous",
+			want: http.Header{"X-Auth-Mechanism": {"anonymous"}},
+		},
+		{
+			name:     "consumer without a mechanism",
+			consumer: "c-1",
+			want:     http.Header{"X-Auth-Mechanism": {"unknown"}},
+		},
+		{
+			name:      "api key",
+			consumer:  "c-1",
+			mechanism: authMechanismAPIKey,
+			want:      http.Header{"X-Auth-Mechanism": {"apikey"}},
+		},
+		{
+			name:      "token with scope and claims",
+			consumer:  "c-1",
+			mechanism: authMechanismPaseto,
+			claims: map[string]interface{}{
+				"sub":    "c-1",
+				"iss":    "https://auth.internal",
+				"tenant": "acme",
+				"scope":  "orders:read  orders:write orders:read",
+			},
+			want: http.Header{
+				"X-Auth-Mechanism": {"paseto"},
+				"X-Auth-Scopes":    {"orders:read orders:write"},
+				"X-Auth-Claims":    {`{"iss":"https://auth.internal","tenant":"acme"}`},
+			},
+		},
+		{
+			name:      "scp list",
+			consumer:  "c-1",
+			mechanism: authMechanismToken,
+			claims:    map[string]interface{}{"scp": []interface{}{"billing", 7, " reports "}},
+			want: http.Header{
+				"X-Auth-Mechanism": {"token"},
+				"X-Auth-Scopes":    {"billing reports"},
+			},
+		},
+		{
+			name:      "claims without scopes or selected claims",
+			consumer:  "c-1",
+			mechanism: authMechanismSession,
+			claims:    map[string]interface{}{"sub": "c-1", "scope": ""},
+			want:      http.Header{"X-Auth-Mechanism": {"session"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/orders", nil)
+			// Client-supplied values never reach the backend
+			r.Header.Set("X-Auth-Mechanism", "mtls")
+			r.Header.Set("X-Auth-Scopes", "admin")
+			r.Header.Set("X-Auth-Claims", `{"tenant":"other"}`)
+			ctx := newTestContext(r, "r-orders")
+			if tt.consumer != "" {
+				plugin.KeyConsumerID.Set(ctx, tt.consumer)
+			}
+			if tt.mechanism != "" {
+				plugin.KeyAuthMechanism.Set(ctx, tt.mechanism)
+			}
+			if tt.claims != nil {
+				plugin.KeyTokenClaims.Set(ctx, tt.claims)
+			}
+			if err := p.Execute(ctx); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			for _, name := range []string{"X-Auth-Mechanism", "X-Auth-Scopes", "X-Auth-Claims"} {
+				got, want := ctx.Request.Header.Values(name), tt.want.Values(name)
+				if len(got) != len(want) || (len(want) == 1 && got[0] != want[0]) {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthSummary_HeaderNames(t *testing.T) {
+	p, err := NewAuthSummaryPlugin(json.RawMessage(`{"mechanism_header": "X-Gw-Auth", "scopes_header": "X-Gw-Scopes"}`))
+	if err != nil {
+		t.Fatalf("NewAuthSummaryPlugin() error = %v", err)
+	}
+	r := httptest.NewRequest("GET", "/orders", nil)
+	r.Header.Set("X-Gw-Scopes", "admin")
+	ctx := newTestContext(r, "r-orders")
+	plugin.KeyConsumerID.Set(ctx, "c-1")
+	plugin.KeyAuthMechanism.Set(ctx, authMechanismLDAP)
+	p.Execute(ctx)
+
+	h := ctx.Request.Header
+	if h.Get("X-Gw-Auth") != "ldap" || h.Get("X-Gw-Scopes") != "" || h.Get("X-Auth-Mechanism") != "" {
+		t.Errorf("headers = %v, want only X-Gw-Auth: ldap", h)
+	}
+}
+
+func TestAuthSummary_Config(t *testing.T) {
+	for _, config := range []string{
+		`{"mechanism_header": ""}`,
+		`{"scopes_header": "x-auth-mechanism"}`,
+		`{"claims": ["iss", ""]}`,
+	} {
+		if _, err := NewAuthSummaryPlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewAuthSummaryPlugin(%s) succeeded, want error", config)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Package builtin - Batch schedule plugin
+//
+// The batch-schedule plugin keeps designated batch consumers (exports,
+// ETL jobs, bulk syncs) off a route during peak hours, so interactive
+// traffic keeps the capacity. During the peak window a batch consumer's
+// request is:
+//   - let through while the consumer is within peak_quota requests in the
+//     current minute
+//   - otherwise held until the window ends, if that is at most
+//     max_defer_ms away and fewer than max_deferred requests are held
+//   - otherwise rejected with 429 and a Retry-After pointing at the start
+//     of the off-peak window
+//
+// Outside the window, and for all other consumers, the plugin does
+// nothing. The peak window is a 5-field cron expression as in route
+// schedules, matched per minute in timezone (default UTC).
+//
+// Configuration Example:
+//
+//	{
+//	  "consumers": ["nightly-export", "warehouse-sync"],
+//	  "groups": ["batch-jobs"],
+//	  "groups_key": "ldap-auth.groups",
+//	  "peak": "* 8-17 * * 1-5",
+//	  "timezone": "America/New_York",
+//	  "peak_quota": 10,
+//	  "max_defer_ms": 30000,
+//	  "max_deferred": 100,
+//	  "message": "Batch requests are not accepted during peak hours"
+//	}
+//
+// The plugin must run after an auth plugin. Consumers are matched by
+// consumer_id or consumer_username, groups as in route-permission. The
+// quota is counted per gateway instance.
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+)
+
+// batchOffPeakHorizon is how far ahead the end of a peak window is looked
+// for; windows that don't end within it get no Retry-After.
+const batchOffPeakHorizon = 31 * 24 * time.Hour
+
+// BatchSchedulePlugin defers batch consumers out of peak hours.
+type BatchSchedulePlugin struct {
+	config    BatchScheduleConfig
+	consumers map[string]bool
+	groups    map[string]bool
+	peak      *router.CronExpr
+	location  *time.Location
+	decisions *metrics.CounterVec
+	now       func() time.Time
+
+	deferred atomic.Int64 // requests currently held
+
+	mu      sync.Mutex
+	minute  int64          // Unix minute the counts and offPeak are for
+	counts  map[string]int // peak requests per consumer this minute
+	offPeak time.Time      // start of the next off-peak minute
+	hasEnd  bool           // offPeak was found within the horizon
+}
+
+// BatchScheduleConfig holds configuration for the batch schedule plugin.
+type BatchScheduleConfig struct {
+	// Consumers are the batch consumers' IDs or usernames
+	Consumers []string `json:"consumers"`
+
+	// Groups whose members are batch consumers
+	Groups []string `json:"groups"`
+
+	// GroupsKey is the context metadata key holding the consumer's groups
+	// Default: "ldap-auth.groups"
+	GroupsKey string `json:"groups_key"`
+
+	// Peak is a cron expression matching the minutes of the peak window
+	// Required
+	Peak string `json:"peak"`
+
+	// Timezone the peak window is in
+	// Default: "UTC"
+	Timezone string `json:"timezone"`
+
+	// PeakQuota is how many requests per minute each batch consumer may
+	// still make during the peak window
+	// Default: 0
+	PeakQuota int `json:"peak_quota"`
+
+	// MaxDeferMs holds requests until the window ends if it ends within
+	// this many milliseconds
+	// Default: 0 (never hold)
+	MaxDeferMs int `json:"max_defer_ms"`
+
+	// MaxDeferred caps how many requests are held at once
+	// Default: 100
+	MaxDeferred int `json:"max_deferred"`
+
+	// Message is returned with the 429
+	Message string `json:"message"`
+}
+
+// NewBatchSchedulePlugin creates a new batch schedule plugin.
+func NewBatchSchedulePlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := BatchScheduleConfig{
+		GroupsKey:   ldapGroupsKey.Name(),
+		Timezone:    "UTC",
+		MaxDeferred: 100,
+		Message:     "Batch requests are not accepted during peak hours",
+	}
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid batch-schedule config: %w", err)
+		}
+	}
+
+	if len(config.Consumers) == 0 && len(config.Groups) == 0 {
+		return nil, fmt.Errorf("invalid batch-schedule config: no consumers or groups")
+	}
+	if config.Peak == "" {
+		return nil, fmt.Errorf("invalid batch-schedule config: peak is required")
+	}
+	peak, err := router.ParseCron(config.Peak)
+	if err != nil {
+		return nil, fmt.Errorf("invalid batch-schedule config: peak: %w", err)
+	}
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid batch-schedule config: timezone: %w", err)
+	}
+	if config.PeakQuota < 0 || config.MaxDeferMs < 0 || config.MaxDeferred < 0 {
+		return nil, fmt.Errorf("invalid batch-schedule config: peak_quota, max_defer_ms and max_deferred must not be negative")
+	}
+
+	p := &BatchSchedulePlugin{
+		config:    config,
+		consumers: make(map[string]bool, len(config.Consumers)),
+		groups:    make(map[string]bool, len(config.Groups)),
+		peak:      peak,
+		location:  location,
+		counts:    make(map[string]int),
+		now:       time.Now,
+		decisions: plugin.NewMetrics("batch-schedule").Counter(
+			"decisions_total",
+			"Batch consumer requests during peak hours, by route and result (quota, deferred, rejected).",
+			"route", "result",
+		),
+	}
+	for _, c := range config.Consumers {
+		p.consumers[c] = true
+	}
+	for _, g := range config.Groups {
+		p.groups[g] = true
+	}
+	return p, nil
+}
+
+// Name returns the plugin identifier.
+func (p *BatchSchedulePlugin) Name() string {
+	return "batch-schedule"
+}
+
+// Execute holds or rejects batch consumers' requests during peak hours.
+func (p *BatchSchedulePlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	consumer, ok := p.batchConsumer(ctx)
+	if !ok {
+		return nil
+	}
+
+	now := p.now()
+	if !p.peak.Matches(now.In(p.location)) {
+		return nil
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+
+	offPeak, hasEnd, withinQuota := p.admit(consumer, now)
+	if withinQuota {
+		p.decisions.Inc(routeID, "quota")
+		return nil
+	}
+
+	wait := offPeak.Sub(now)
+	if hasEnd && wait <= time.Duration(p.config.MaxDeferMs)*time.Millisecond {
+		if p.deferred.Add(1) <= int64(p.config.MaxDeferred) {
+			defer p.deferred.Add(-1)
+			p.decisions.Inc(routeID, "deferred")
+
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+				return nil
+			case <-ctx.Request.Context().Done():
+				return ctx.Request.Context().Err()
+			}
+		}
+		p.deferred.Add(-1)
+	}
+
+	p.decisions.Inc(routeID, "rejected")
+	log.Info().
+		Str("component", "plugin").
+		Str("plugin", "batch-schedule").
+		Str("route_id", routeID).
+		Str("consumer_id", consumer).
+		Time("off_peak_at", offPeak).
+		Msg("Batch request rejected during peak hours")
+
+	if hasEnd {
+		seconds := int64(math.Ceil(wait.Seconds()))
+		ctx.Response.Header().Set("Retry-After", strconv.FormatInt(max(seconds, 1), 10))
+	}
+	ctx.Decide(fmt.Sprintf("batch consumer %s during peak hours", consumer))
+	ctx.Abort(http.StatusTooManyRequests, p.config.Message)
+	return nil
+}
+
+// batchConsumer returns the request's consumer if it's a batch consumer.
+func (p *BatchSchedulePlugin) batchConsumer(ctx *plugin.Context) (string, bool) {
+	consumerID := plugin.KeyConsumerID.Value(ctx)
+	if consumerID == "" {
+		return "", false
+	}
+	if p.consumers[consumerID] || p.consumers[plugin.KeyConsumerUsername.Value(ctx)] {
+		return consumerID, true
+	}
+	for _, group := range contextGroups(ctx, p.config.GroupsKey) {
+		if p.groups[group] {
+			return consumerID, true
+		}
+	}
+	return "", false
+}
+
+// admit counts a peak request for consumer, reporting whether it is within
+// the quota, and returns when the peak window ends.
+func (p *BatchSchedulePlugin) admit(consumer string, now time.Time) (offPeak time.Time, hasEnd, withinQuota bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	minute := now.Unix() / 60
+	if minute != p.minute {
+		p.minute = minute
+		clear(p.counts)
+		p.offPeak, p.hasEnd = p.nextOffPeak(now)
+	}
+
+	if p.counts[consumer] < p.config.PeakQuota {
+		p.counts[consumer]++
+		return p.offPeak, p.hasEnd, true
+	}
+	return p.offPeak, p.hasEnd, false
+}
+
+// nextOffPeak returns the start of the first minute after now outside the
+// peak window, or false if there is none within batchOffPeakHorizon.
+func (p *BatchSchedulePlugin) nextOffPeak(now time.Time) (time.Time, bool) {
+	t := now.In(p.location).Truncate(time.Minute)
+	for end := t.Add(batchOffPeakHorizon); t.Before(end); {
+		t = t.Add(time.Minute)
+		if !p.peak.Matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,175 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// newTestBatchSchedule builds the plugin with its clock at now.
+func newTestBatchSchedule(t *testing.T, config string, now time.Time) *BatchSchedulePlugin {
+	t.Helper()
+	p, err := NewBatchSchedulePlugin(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewBatchSchedulePlugin() error = %v", err)
+	}
+	bs := p.(*BatchSchedulePlugin)
+	bs.now = func() time.Time { return now }
+	return bs
+}
+
+// batchRequest runs p on a request from consumerID with the given groups.
+func batchRequest(t *testing.T, p *BatchSchedulePlugin, r *http.Request, consumerID string, groups ...string) (*plugin.Context, error) {
+	t.Helper()
+	ctx := newTestContext(r, "r-export")
+	if consumerID != "" {
+		plugin.KeyConsumerID.Set(ctx, consumerID)
+		plugin.KeyConsumerUsername.Set(ctx, consumerID+"-user")
+	}
+	if groups != nil {
+		ldapGroupsKey.Set(ctx, groups)
+	}
+	return ctx, p.Execute(ctx)
+}
+
+func TestBatchSchedule_Window(t *testing.T) {
+	// 2026-10-12 is a Monday; peak is 08:00-17:59 New York time (UTC-4)
+	const config = `{"consumers": ["c-export", "sync-user"], "groups": ["batch"], "peak": "* 8-17 * * 1-5", "timezone": "America/New_York"}`
+	newYork, _ := time.LoadLocation("America/New_York")
+	at := func(day, hour, minute, second int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, second, 0, newYork)
+	}
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		consumer   string
+		groups     []string
+		retryAfter string // "" = allowed
+	}{
+		{name: "peak", now: at(12, 9, 30, 0), consumer: "c-export", retryAfter: "30600"},
+		{name: "last peak minute", now: at(12, 17, 59, 30), consumer: "c-export", retryAfter: "30"},
+		{name: "by username", now: at(12, 12, 0, 0), consumer: "sync", retryAfter: "21600"},
+		{name: "by group", now: at(12, 12, 0, 0), consumer: "c-etl", groups: []string{"staff", "batch"}, retryAfter: "21600"},
+		{name: "before peak", now: at(12, 7, 59, 59), consumer: "c-export"},
+		{name: "after peak", now: at(12, 18, 0, 0), consumer: "c-export"},
+		{name: "peak hour in UTC only", now: time.Date(2026, 10, 12, 9, 30, 0, 0, time.UTC), consumer: "c-export"},
+		{name: "weekend", now: at(17, 10, 0, 0), consumer: "c-export"},
+		{name: "interactive consumer", now: at(12, 9, 30, 0), consumer: "c-web", groups: []string{"staff"}},
+		{name: "anonymous", now: at(12, 9, 30, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestBatchSchedule(t, config, tt.now)
+			ctx, err := batchRequest(t, p, httptest.NewRequest("GET", "/export", nil), tt.consumer, tt.groups...)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if tt.retryAfter == "" {
+				if ctx.IsAborted() {
+					t.Errorf("aborted with %d, want the request allowed", ctx.AbortStatusCode())
+				}
+				return
+			}
+			if ctx.AbortStatusCode() != http.StatusTooManyRequests {
+				t.Fatalf("status = %d, want 429", ctx.AbortStatusCode())
+			}
+			if got := ctx.Response.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %s, want %s (the start of off-peak)", got, tt.retryAfter)
+			}
+		})
+	}
+
+	// A window that never ends has no off-peak to point at
+	p := newTestBatchSchedule(t, `{"consumers": ["c-export"], "peak": "* * * * *"}`, at(12, 9, 30, 0))
+	ctx, _ := batchRequest(t, p, httptest.NewRequest("GET", "/export", nil), "c-export")
+	if ctx.AbortStatusCode() != http.StatusTooManyRequests || ctx.Response.Header().Get("Retry-After") != "" {
+		t.Errorf("status %d with Retry-After %q, want 429 without one", ctx.AbortStatusCode(), ctx.Response.Header().Get("Retry-After"))
+	}
+}
+
+func TestBatchSchedule_Quota(t *testing.T) {
+	now := time.Date(2026, 10, 12, 10, 0, 0, 0, time.UTC)
+	p := newTestBatchSchedule(t, `{"consumers": ["c-export", "c-sync"], "peak": "* 8-17 * * *", "peak_quota": 2}`, now)
+
+	allowed := func(consumer string) bool {
+		ctx, _ := batchRequest(t, p, httptest.NewRequest("GET", "/export", nil), consumer)
+		return !ctx.IsAborted()
+	}
+
+	if !allowed("c-export") || !allowed("c-export") {
+		t.Fatal("requests within peak_quota rejected")
+	}
+	if allowed("c-export") {
+		t.Error("third request in the minute allowed, want it over the quota")
+	}
+	if !allowed("c-sync") {
+		t.Error("quota shared between consumers, want one per consumer")
+	}
+
+	// The quota is per minute
+	p.now = func() time.Time { return now.Add(time.Minute) }
+	if !allowed("c-export") {
+		t.Error("request in the next minute rejected")
+	}
+}
+
+func TestBatchSchedule_Defer(t *testing.T) {
+	// 50ms before the window ends
+	now := time.Date(2026, 10, 12, 17, 59, 59, 950e6, time.UTC)
+	const peak = `"consumers": ["c-export"], "peak": "* 8-17 * * *"`
+
+	p := newTestBatchSchedule(t, `{`+peak+`, "max_defer_ms": 1000}`, now)
+	start := time.Now()
+	ctx, err := batchRequest(t, p, httptest.NewRequest("GET", "/export", nil), "c-export")
+	if err != nil || ctx.IsAborted() {
+		t.Fatalf("aborted = %v, error %v; want the request held, then allowed", ctx.IsAborted(), err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond || elapsed > time.Second {
+		t.Errorf("held for %v, want until the window ends (50ms)", elapsed)
+	}
+
+	// The window ends too late to hold the request
+	p = newTestBatchSchedule(t, `{`+peak+`, "max_defer_ms": 10}`, now)
+	if ctx, _ := batchRequest(t, p, httptest.NewRequest("GET", "/export", nil), "c-export"); ctx.AbortStatusCode() != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429 past max_defer_ms", ctx.AbortStatusCode())
+	}
+
+	// No room to hold it
+	p = newTestBatchSchedule(t, `{`+peak+`, "max_defer_ms": 1000, "max_deferred": 0}`, now)
+	if ctx, _ := batchRequest(t, p, httptest.NewRequest("GET", "/export", nil), "c-export"); ctx.AbortStatusCode() != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429 with max_deferred reached", ctx.AbortStatusCode())
+	}
+
+	// A held client that goes away
+	p = newTestBatchSchedule(t, `{`+peak+`, "max_defer_ms": 1000}`, now)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := batchRequest(t, p, httptest.NewRequest("GET", "/export", nil).WithContext(cancelled), "c-export"); err != context.Canceled {
+		t.Errorf("Execute() error = %v, want context.Canceled", err)
+	}
+	if p.deferred.Load() != 0 {
+		t.Errorf("%d requests still counted as held", p.deferred.Load())
+	}
+}
+
+func TestBatchSchedule_Config(t *testing.T) {
+	for _, config := range []string{
+		`{"peak": "* 8-17 * * *"}`,
+		`{"consumers": ["c-export"]}`,
+		`{"consumers": ["c-export"], "peak": "every morning"}`,
+		`{"consumers": ["c-export"], "peak": "* 8-17 * * *", "timezone": "Mars/Olympus"}`,
+		`{"consumers": ["c-export"], "peak": "* 8-17 * * *", "peak_quota": -1}`,
+		`{"consumers": ["c-export"], "peak": "* 8-17 * * *", "max_defer_ms": -1}`,
+	} {
+		if _, err := NewBatchSchedulePlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewBatchSchedulePlugin(%s) succeeded, want error", config)
+		}
+	}
+}
@@ -0,0 +1,453 @@
+// Package builtin - Response cache plugin
+//
+// The cache plugin answers repeated requests for a route from stored
+// upstream responses, so the backend serves each resource once per TTL
+// instead of once per request:
+//   - Responses to the configured methods with the configured statuses
+//     are stored for s-maxage, else max-age, from their Cache-Control,
+//     else the plugin's ttl
+//   - Entries are keyed by route, method, host, path, query, the request
+//     headers in vary_headers and the request's credentials
+//     (credential_headers, credential_cookies and the authenticated
+//     consumer), so one consumer never sees a response cached for
+//     another, whichever order the cache and auth plugins run in
+//   - Requests sending Cache-Control: no-cache skip the lookup; responses
+//     marked no-store, private or no-cache, setting cookies, or varying on
+//     a header outside vary_headers aren't stored
+//   - Hits for If-None-Match or If-Modified-Since requests matching the
+//     entry's ETag or Last-Modified are answered 304 Not Modified
+//   - Range requests hitting a 200 entry get the range from it (206, or
+//     416 when unsatisfiable), honoring If-Range. On a miss Range is
+//     forwarded and the upstream's 206 isn't stored
+//   - The upstream's Surrogate-Key header (space-separated tags) is kept
+//     with the entry for purging and removed from responses to clients
+//   - With stale_if_error, expired entries are kept for that long and
+//     served when the upstream answers 5xx, times out or can't be
+//     reached, marked Warning: 110 and X-Served-Stale: true. A
+//     stale-if-error directive in the response's Cache-Control overrides
+//     the window
+//
+// Configuration Example:
+//
+//	{
+//	  "ttl": "1m",
+//	  "methods": ["GET", "HEAD"],
+//	  "statuses": [200, 203, 301, 404],
+//	  "vary_headers": ["Accept", "Accept-Encoding"],
+//	  "credential_headers": ["Authorization", "X-API-Key"],
+//	  "credential_cookies": ["sb_session"],
+//	  "max_body_bytes": 1048576,
+//	  "stale_if_error": "10m"
+//	}
+//
+// Entries live in the gateway's shared response cache (see respcache),
+// held in memory per instance and bounded by CACHE_MAX_BYTES; without it
+// the plugin can't be configured. Responses carry X-Cache: HIT (with an
+// Age header), STALE or MISS. Entries are purged by route, path or
+// surrogate key with DELETE /admin/cache, on every instance.
+package builtin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/respcache"
+)
+
+// CacheHeader reports whether a response came from the cache.
+const CacheHeader = "X-Cache"
+
+// SurrogateKeyHeader lists the tags an upstream response is purged by.
+const SurrogateKeyHeader = "Surrogate-Key"
+
+// ServedStaleHeader marks expired copies served because the upstream
+// failed.
+const ServedStaleHeader = "X-Served-Stale"
+
+// CachePlugin serves responses from the response cache.
+type CachePlugin struct {
+	config   CacheConfig
+	store    *respcache.Store
+	ttl      time.Duration
+	stale    time.Duration // stale-if-error window (0 = off)
+	methods  map[string]bool
+	statuses map[int]bool
+	vary     map[string]bool // canonical header names
+	now      func() time.Time
+
+	lookups     *metrics.CounterVec
+	stored      *metrics.CounterVec
+	staleServed *metrics.CounterVec
+}
+
+// CacheConfig holds configuration for the cache plugin.
+type CacheConfig struct {
+	// TTL is how long responses are served when Cache-Control doesn't say
+	// Default: "1m"
+	TTL string `json:"ttl"`
+
+	// Methods are the HTTP methods whose responses are cached
+	// Default: ["GET", "HEAD"]
+	Methods []string `json:"methods"`
+
+	// Statuses are the status codes cached
+	// Default: [200]
+	Statuses []int `json:"statuses"`
+
+	// VaryHeaders are request headers that separate cache entries;
+	// responses varying on other headers aren't cached
+	// Default: ["Accept", "Accept-Encoding"]
+	VaryHeaders []string `json:"vary_headers"`
+
+	// CredentialHeaders are request headers that separate cache entries
+	// Default: ["Authorization", "X-API-Key"]
+	CredentialHeaders []string `json:"credential_headers"`
+
+	// CredentialCookies are request cookies that separate cache entries,
+	// such as session-auth's cookie_name
+	// Default: ["sb_session"]
+	CredentialCookies []string `json:"credential_cookies"`
+
+	// MaxBodyBytes is the largest response body cached
+	// Default: 1048576 (1 MiB)
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+
+	// StaleIfError is how long after expiry an entry may be served when
+	// the upstream fails (empty = never)
+	// Default: ""
+	StaleIfError string `json:"stale_if_error"`
+}
+
+// NewCacheFactory returns a factory for cache plugins storing responses
+// in store. Without a store the plugin can't be configured.
+func NewCacheFactory(store *respcache.Store) plugin.PluginFactory {
+	return func(configJSON json.RawMessage) (plugin.Plugin, error) {
+		if store == nil {
+			return nil, fmt.Errorf("cache requires the response cache (set CACHE_MAX_BYTES)")
+		}
+
+		config := CacheConfig{
+			TTL:               "1m",
+			Methods:           []string{http.MethodGet, http.MethodHead},
+			Statuses:          []int{http.StatusOK},
+			VaryHeaders:       []string{"Accept", "Accept-Encoding"},
+			CredentialHeaders: []string{"Authorization", "X-API-Key"},
+			CredentialCookies: []string{"sb_session"},
+			MaxBodyBytes:      1 << 20,
+		}
+		if len(configJSON) > 0 {
+			if err := json.Unmarshal(configJSON, &config); err != nil {
+				return nil, fmt.Errorf("invalid cache config: %w", err)
+			}
+		}
+
+		ttl, err := time.ParseDuration(config.TTL)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid cache config: ttl must be a positive duration")
+		}
+		if len(config.Methods) == 0 {
+			return nil, fmt.Errorf("invalid cache config: at least one method is required")
+		}
+		if len(config.Statuses) == 0 {
+			return nil, fmt.Errorf("invalid cache config: at least one status is required")
+		}
+		if config.MaxBodyBytes <= 0 {
+			return nil, fmt.Errorf("invalid cache config: max_body_bytes must be positive")
+		}
+		var stale time.Duration
+		if config.StaleIfError != "" {
+			if stale, err = time.ParseDuration(config.StaleIfError); err != nil || stale <= 0 {
+				return nil, fmt.Errorf("invalid cache config: stale_if_error must be a positive duration")
+			}
+		}
+
+		methods := make(map[string]bool, len(config.Methods))
+		for _, m := range config.Methods {
+			m = strings.ToUpper(m)
+			if m != http.MethodGet && m != http.MethodHead {
+				return nil, fmt.Errorf("invalid cache config: only GET and HEAD responses can be cached (got %s)", m)
+			}
+			methods[m] = true
+		}
+		statuses := make(map[int]bool, len(config.Statuses))
+		for _, status := range config.Statuses {
+			// Partial content answers the request's range, not the resource
+			if status < 200 || status > 599 || status == http.StatusPartialContent {
+				return nil, fmt.Errorf("invalid cache config: status %d can't be cached", status)
+			}
+			statuses[status] = true
+		}
+		vary := make(map[string]bool, len(config.VaryHeaders))
+		for _, name := range config.VaryHeaders {
+			vary[http.CanonicalHeaderKey(name)] = true
+		}
+
+		m := plugin.NewMetrics("cache")
+		return &CachePlugin{
+			config:   config,
+			store:    store,
+			ttl:      ttl,
+			stale:    stale,
+			methods:  methods,
+			statuses: statuses,
+			vary:     vary,
+			now:      time.Now,
+			lookups: m.Counter(
+				"lookups_total",
+				"Response cache lookups, by route and result (hit, miss, bypass).",
+				"route", "result",
+			),
+			stored: m.Counter(
+				"stored_total",
+				"Responses stored in the response cache, by route and status code.",
+				"route", "status",
+			),
+			staleServed: m.Counter(
+				"stale_served_total",
+				"Expired responses served because the upstream failed, by route and reason (status, error).",
+				"route", "reason",
+			),
+		}, nil
+	}
+}
+
+// Name returns the plugin identifier.
+func (p *CachePlugin) Name() string {
+	return "cache"
+}
+
+// Execute serves a cached response or registers the hook that caches the
+// upstream's.
+func (p *CachePlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+	r := ctx.Request
+	if !p.methods[r.Method] {
+		return nil
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+	key := p.cacheKey(ctx, routeID)
+
+	result := "miss"
+	if strings.Contains(strings.ToLower(r.Header.Get("Cache-Control")), "no-cache") {
+		result = "bypass"
+	} else if entry := p.store.Get(key); entry != nil {
+		p.lookups.Inc(routeID, "hit")
+		p.serve(ctx, entry)
+		return nil
+	}
+	p.lookups.Inc(routeID, result)
+
+	ctx.AddErrorHook(func(error) *http.Response {
+		entry := p.store.GetStale(key)
+		if entry == nil {
+			return nil
+		}
+		p.staleServed.Inc(routeID, "error")
+		return p.staleResponse(entry)
+	})
+
+	ctx.AddResponseHook(func(resp *http.Response) error {
+		if resp.StatusCode >= 500 {
+			if entry := p.store.GetStale(key); entry != nil {
+				resp.Body.Close()
+				stale := p.staleResponse(entry)
+				resp.StatusCode, resp.Status = stale.StatusCode, stale.Status
+				resp.Header, resp.Body, resp.ContentLength = stale.Header, stale.Body, stale.ContentLength
+				p.staleServed.Inc(routeID, "status")
+				return nil
+			}
+		}
+
+		tags := strings.Fields(strings.Join(resp.Header.Values(SurrogateKeyHeader), " "))
+		resp.Header.Del(SurrogateKeyHeader)
+		resp.Header.Set(CacheHeader, "MISS")
+
+		ttl, stale, ok := p.storable(resp)
+		if !ok {
+			return nil
+		}
+
+		body, err := bodybuffer.FromRequest(r).Read(resp.Body, p.config.MaxBodyBytes)
+		if err != nil {
+			return fmt.Errorf("failed to read upstream response: %w", err)
+		}
+		resp.Body = body.ReadCloser(resp.Body)
+		if !body.Complete() {
+			return nil
+		}
+
+		now := p.now()
+		var staleUntil time.Time
+		if stale > 0 {
+			staleUntil = now.Add(ttl + stale)
+		}
+		p.store.Put(&respcache.Entry{
+			Key:        key,
+			RouteID:    routeID,
+			Path:       r.URL.Path,
+			Tags:       tags,
+			StatusCode: resp.StatusCode,
+			Header:     storableHeaders(resp.Header),
+			Body:       append([]byte(nil), body.Bytes()...),
+			Stored:     now,
+			Expires:    now.Add(ttl),
+			StaleUntil: staleUntil,
+		})
+		p.stored.Inc(routeID, strconv.Itoa(resp.StatusCode))
+		return nil
+	})
+	return nil
+}
+
+// storable reports whether resp may be cached, for how long, and how long
+// after that it may stand in for a failed upstream.
+func (p *CachePlugin) storable(resp *http.Response) (ttl, stale time.Duration, ok bool) {
+	if !p.statuses[resp.StatusCode] || resp.ContentLength > p.config.MaxBodyBytes {
+		return 0, 0, false
+	}
+	if len(resp.Header.Values("Set-Cookie")) > 0 {
+		return 0, 0, false
+	}
+	for _, value := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" && !p.vary[http.CanonicalHeaderKey(name)] {
+				return 0, 0, false
+			}
+		}
+	}
+
+	ttl, stale = p.ttl, p.stale
+	maxAge, sMaxAge, staleIfError := -1, -1, -1
+	for _, directive := range strings.Split(strings.ToLower(strings.Join(resp.Header.Values("Cache-Control"), ",")), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch name {
+		case "no-store", "private", "no-cache":
+			return 0, 0, false
+		case "max-age":
+			maxAge, _ = strconv.Atoi(strings.Trim(value, `"`))
+		case "s-maxage":
+			sMaxAge, _ = strconv.Atoi(strings.Trim(value, `"`))
+		case "stale-if-error":
+			staleIfError, _ = strconv.Atoi(strings.Trim(value, `"`))
+		}
+	}
+	if sMaxAge >= 0 {
+		ttl = time.Duration(sMaxAge) * time.Second
+	} else if maxAge >= 0 {
+		ttl = time.Duration(maxAge) * time.Second
+	}
+	if staleIfError >= 0 {
+		stale = time.Duration(staleIfError) * time.Second
+	}
+	return ttl, stale, ttl > 0
+}
+
+// cacheKey identifies a request: route, method, host, path, query, vary
+// headers and credentials.
+func (p *CachePlugin) cacheKey(ctx *plugin.Context, routeID string) string {
+	r := ctx.Request
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n", r.Method, r.Host, r.URL.Path, r.URL.RawQuery)
+	for _, name := range p.config.VaryHeaders {
+		fmt.Fprintf(h, "%s\n", strings.Join(r.Header.Values(name), ","))
+	}
+	for _, name := range p.config.CredentialHeaders {
+		fmt.Fprintf(h, "%s\n", strings.Join(r.Header.Values(name), ","))
+	}
+	writeCookieValues(h, r, p.config.CredentialCookies)
+	fmt.Fprintf(h, "%s\n", plugin.KeyConsumerID.Value(ctx))
+	return routeID + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// writeCookieValues writes the values of the named request cookies to w,
+// one per line (empty when the cookie isn't sent).
+func writeCookieValues(w io.Writer, r *http.Request, names []string) {
+	for _, name := range names {
+		value := ""
+		if cookie, err := r.Cookie(name); err == nil {
+			value = cookie.Value
+		}
+		fmt.Fprintf(w, "%s\n", value)
+	}
+}
+
+// staleResponse returns an expired entry as a response marked stale.
+func (p *CachePlugin) staleResponse(entry *respcache.Entry) *http.Response {
+	header := entry.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(p.now().Sub(entry.Stored).Seconds())))
+	header.Set("Warning", `110 - "Response is Stale"`)
+	header.Set(ServedStaleHeader, "true")
+	header.Set(CacheHeader, "STALE")
+
+	return &http.Response{
+		StatusCode:    entry.StatusCode,
+		Status:        fmt.Sprintf("%d %s", entry.StatusCode, http.StatusText(entry.StatusCode)),
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+	}
+}
+
+// serve writes a cached response to the client and aborts the chain.
+// Conditional requests matching the entry's ETag or Last-Modified get a
+// 304 instead, and Range requests for a 200 entry the requested bytes.
+func (p *CachePlugin) serve(ctx *plugin.Context, entry *respcache.Entry) {
+	r := ctx.Request
+	header := ctx.Response.Header()
+	age := strconv.Itoa(int(p.now().Sub(entry.Stored).Seconds()))
+
+	if entry.StatusCode == http.StatusOK && notModified(entry.Header, r.Header.Get("If-None-Match"), r.Header.Get("If-Modified-Since")) {
+		for _, name := range notModifiedHeaders {
+			if values := entry.Header.Values(name); len(values) > 0 {
+				header[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+		header.Set("Age", age)
+		header.Set(CacheHeader, "HIT")
+		ctx.Response.WriteHeader(http.StatusNotModified)
+		ctx.Abort(http.StatusNotModified, "")
+		return
+	}
+
+	for name, values := range entry.Header {
+		header[name] = values
+	}
+	header.Set("Age", age)
+	header.Set(CacheHeader, "HIT")
+
+	// http.ServeContent answers the range (206, or 416 when unsatisfiable)
+	// and If-Range. A nil Content-Type stops it sniffing one the upstream
+	// didn't send.
+	if entry.StatusCode == http.StatusOK && r.Header.Get("Range") != "" {
+		if _, ok := header["Content-Type"]; !ok {
+			header["Content-Type"] = nil
+		}
+		modTime, _ := http.ParseTime(entry.Header.Get("Last-Modified"))
+		http.ServeContent(ctx.Response, r, "", modTime, bytes.NewReader(entry.Body))
+		ctx.Abort(ctx.Response.StatusCode(), "")
+		return
+	}
+
+	ctx.Response.WriteHeader(entry.StatusCode)
+	if ctx.Request.Method != http.MethodHead {
+		ctx.Response.Write(entry.Body)
+	}
+	ctx.Abort(entry.StatusCode, "")
+}
@@ -0,0 +1,443 @@
+package builtin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/respcache"
+)
+
+func newTestCachePlugin(t *testing.T, config string) (*CachePlugin, *respcache.Store) {
+	t.Helper()
+	store := respcache.NewStore(1 << 20)
+	p, err := NewCacheFactory(store)(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewCacheFactory() error = %v", err)
+	}
+	return p.(*CachePlugin), store
+}
+
+func upstreamResponse(status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		StatusCode:    status,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+// cacheRoundTrip runs the plugin on r and, unless it answered from the
+// cache, its response hooks on resp. It returns the plugin context and
+// the body the client would get.
+func cacheRoundTrip(t *testing.T, p *CachePlugin, r *http.Request, resp *http.Response) (*plugin.Context, string) {
+	t.Helper()
+	ctx := newTestContext(r, "r-products")
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if ctx.IsAborted() {
+		return ctx, ctx.Response.ResponseWriter.(*httptest.ResponseRecorder).Body.String()
+	}
+	for _, hook := range plugin.ResponseHooks(ctx.Request) {
+		if err := hook(resp); err != nil {
+			t.Fatalf("response hook error = %v", err)
+		}
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return ctx, string(body)
+}
+
+func TestCache_HitAndMiss(t *testing.T) {
+	p, _ := newTestCachePlugin(t, "")
+
+	header := http.Header{"Content-Type": {"application/json"}, "Surrogate-Key": {"products product-1"}}
+	resp := upstreamResponse(http.StatusOK, header, `{"id": 1}`)
+	ctx, body := cacheRoundTrip(t, p, httptest.NewRequest("GET", "/products/1", nil), resp)
+	if ctx.IsAborted() || body != `{"id": 1}` {
+		t.Fatalf("first request: aborted %v, body %q; want proxied", ctx.IsAborted(), body)
+	}
+	if resp.Header.Get(CacheHeader) != "MISS" || resp.Header.Get(SurrogateKeyHeader) != "" {
+		t.Errorf("miss headers = %v, want X-Cache: MISS and no Surrogate-Key", resp.Header)
+	}
+
+	ctx, body = cacheRoundTrip(t, p, httptest.NewRequest("GET", "/products/1", nil), nil)
+	if !ctx.IsAborted() || body != `{"id": 1}` {
+		t.Fatalf("second request: aborted %v, body %q; want served from the cache", ctx.IsAborted(), body)
+	}
+	got := ctx.Response.Header()
+	if got.Get(CacheHeader) != "HIT" || got.Get("Age") == "" || got.Get("Content-Type") != "application/json" {
+		t.Errorf("hit headers = %v", got)
+	}
+	if got.Get(SurrogateKeyHeader) != "" {
+		t.Error("Surrogate-Key sent to the client")
+	}
+
+	// Other paths, queries and credentials are separate entries
+	for _, r := range []*http.Request{
+		httptest.NewRequest("GET", "/products/2", nil),
+		httptest.NewRequest("GET", "/products/1?fields=name", nil),
+		func() *http.Request {
+			r := httptest.NewRequest("GET", "/products/1", nil)
+			r.Header.Set("Authorization", "Bearer other")
+			return r
+		}(),
+		func() *http.Request {
+			r := httptest.NewRequest("GET", "/products/1", nil)
+			r.AddCookie(&http.Cookie{Name: "sb_session", Value: "s-1"})
+			return r
+		}(),
+	} {
+		if ctx, _ := cacheRoundTrip(t, p, r, upstreamResponse(http.StatusOK, nil, "other")); ctx.IsAborted() {
+			t.Errorf("%s %v served from another request's entry", r.URL, r.Header)
+		}
+	}
+
+	// Cookies other than credential_cookies share the entry
+	r := httptest.NewRequest("GET", "/products/1", nil)
+	r.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	if ctx, _ := cacheRoundTrip(t, p, r, nil); !ctx.IsAborted() {
+		t.Error("request with an unrelated cookie missed the cache")
+	}
+
+	// Clients can ask for a fresh copy
+	r = httptest.NewRequest("GET", "/products/1", nil)
+	r.Header.Set("Cache-Control", "no-cache")
+	if ctx, _ := cacheRoundTrip(t, p, r, upstreamResponse(http.StatusOK, nil, "fresh")); ctx.IsAborted() {
+		t.Error("Cache-Control: no-cache request served from the cache")
+	}
+}
+
+func TestCache_Storable(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		status int
+		header http.Header
+		body   string
+		want   bool
+	}{
+		{name: "ok", status: http.StatusOK, want: true},
+		{name: "status not cached", status: http.StatusNotFound},
+		{name: "configured status", config: `{"statuses": [200, 404]}`, status: http.StatusNotFound, want: true},
+		{name: "server error", status: http.StatusBadGateway},
+		{name: "no-store", status: http.StatusOK, header: http.Header{"Cache-Control": {"no-store"}}},
+		{name: "private", status: http.StatusOK, header: http.Header{"Cache-Control": {"private, max-age=60"}}},
+		{name: "max-age 0", status: http.StatusOK, header: http.Header{"Cache-Control": {"max-age=0"}}},
+		{name: "s-maxage wins", status: http.StatusOK, header: http.Header{"Cache-Control": {"max-age=0, s-maxage=60"}}, want: true},
+		{name: "set-cookie", status: http.StatusOK, header: http.Header{"Set-Cookie": {"session=abc"}}},
+		{name: "vary on a keyed header", status: http.StatusOK, header: http.Header{"Vary": {"Accept-Encoding"}}, want: true},
+		{name: "vary on another header", status: http.StatusOK, header: http.Header{"Vary": {"Accept, User-Agent"}}},
+		{name: "too large", config: `{"max_body_bytes": 4}`, status: http.StatusOK, body: "12345"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, store := newTestCachePlugin(t, tt.config)
+			body := tt.body
+			if body == "" {
+				body = "cached"
+			}
+			_, sent := cacheRoundTrip(t, p, httptest.NewRequest("GET", "/products/1", nil), upstreamResponse(tt.status, tt.header, body))
+			if sent != body {
+				t.Errorf("proxied body = %q, want %q", sent, body)
+			}
+			if stored := store.Len() == 1; stored != tt.want {
+				t.Errorf("stored = %v, want %v", stored, tt.want)
+			}
+		})
+	}
+}
+
+func TestCache_ConditionalHit(t *testing.T) {
+	p, _ := newTestCachePlugin(t, "")
+
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+	header := http.Header{"Content-Type": {"application/json"}, "Last-Modified": {modified}, "Cache-Control": {"max-age=60"}}
+	header.Set("ETag", `"v1"`)
+	cacheRoundTrip(t, p, httptest.NewRequest("GET", "/products/1", nil), upstreamResponse(http.StatusOK, header, `{"id": 1}`))
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		ifModified  string
+		want        int
+	}{
+		{name: "etag match", ifNoneMatch: `"v1"`, want: http.StatusNotModified},
+		{name: "weak etag match", ifNoneMatch: `W/"v1"`, want: http.StatusNotModified},
+		{name: "one of several", ifNoneMatch: `"v0", "v1"`, want: http.StatusNotModified},
+		{name: "any", ifNoneMatch: "*", want: http.StatusNotModified},
+		{name: "etag changed", ifNoneMatch: `"v2"`, want: http.StatusOK},
+		{name: "not modified since", ifModified: modified, want: http.StatusNotModified},
+		{name: "modified since", ifModified: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat), want: http.StatusOK},
+		{name: "etag wins over date", ifNoneMatch: `"v2"`, ifModified: modified, want: http.StatusOK},
+		{name: "unconditional", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/products/1", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			if tt.ifModified != "" {
+				r.Header.Set("If-Modified-Since", tt.ifModified)
+			}
+			ctx, body := cacheRoundTrip(t, p, r, nil)
+			if !ctx.IsAborted() || ctx.AbortStatusCode() != tt.want {
+				t.Fatalf("status = %d (aborted %v), want %d from the cache", ctx.AbortStatusCode(), ctx.IsAborted(), tt.want)
+			}
+
+			got := ctx.Response.Header()
+			if got.Get(CacheHeader) != "HIT" || got.Get("ETag") != `"v1"` || got.Get("Cache-Control") != "max-age=60" {
+				t.Errorf("headers = %v, want X-Cache: HIT with the entry's validators", got)
+			}
+			if tt.want == http.StatusNotModified {
+				if body != "" || got.Get("Content-Type") != "" {
+					t.Errorf("304 carries body %q and Content-Type %q, want neither", body, got.Get("Content-Type"))
+				}
+			} else if body != `{"id": 1}` {
+				t.Errorf("body = %q, want the cached body", body)
+			}
+		})
+	}
+}
+
+// With etag running first, its ETags are stored and 304s come from the
+// cache.
+func TestCache_ETagPlugin(t *testing.T) {
+	p, _ := newTestCachePlugin(t, "")
+	etag, err := NewETagPlugin(nil)
+	if err != nil {
+		t.Fatalf("NewETagPlugin() error = %v", err)
+	}
+
+	roundTrip := func(r *http.Request, resp *http.Response) *plugin.Context {
+		ctx := newTestContext(r, "r-products")
+		etag.Execute(ctx)
+		p.Execute(ctx)
+		if !ctx.IsAborted() {
+			for _, hook := range plugin.ResponseHooks(ctx.Request) {
+				if err := hook(resp); err != nil {
+					t.Fatalf("response hook error = %v", err)
+				}
+			}
+		}
+		return ctx
+	}
+
+	resp := upstreamResponse(http.StatusOK, nil, `{"id": 1}`)
+	roundTrip(httptest.NewRequest("GET", "/products/1", nil), resp)
+	tag := resp.Header.Get("ETag")
+	if tag == "" {
+		t.Fatal("etag plugin didn't tag the response")
+	}
+
+	r := httptest.NewRequest("GET", "/products/1", nil)
+	r.Header.Set("If-None-Match", tag)
+	if ctx := roundTrip(r, nil); ctx.AbortStatusCode() != http.StatusNotModified {
+		t.Errorf("conditional hit answered %d, want 304 from the cache", ctx.AbortStatusCode())
+	}
+}
+
+func TestCache_RangeHit(t *testing.T) {
+	p, store := newTestCachePlugin(t, "")
+
+	// A ranged miss reaches the upstream, whose 206 isn't stored
+	r := httptest.NewRequest("GET", "/files/1", nil)
+	r.Header.Set("Range", "bytes=0-3")
+	partial := upstreamResponse(http.StatusPartialContent, http.Header{"Content-Range": {"bytes 0-3/16"}}, "0123")
+	if ctx, body := cacheRoundTrip(t, p, r, partial); ctx.IsAborted() || body != "0123" {
+		t.Fatalf("ranged miss: aborted %v, body %q; want the upstream's 206", ctx.IsAborted(), body)
+	}
+	if store.Len() != 0 {
+		t.Fatalf("store holds %d entries after a 206, want 0", store.Len())
+	}
+
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+	header := http.Header{"Content-Type": {"text/plain"}, "Last-Modified": {modified}}
+	header.Set("ETag", `"v1"`)
+	cacheRoundTrip(t, p, httptest.NewRequest("GET", "/files/1", nil), upstreamResponse(http.StatusOK, header, "0123456789abcdef"))
+
+	tests := []struct {
+		name         string
+		rangeHeader  string
+		ifRange      string
+		want         int
+		wantBody     string
+		contentRange string
+	}{
+		{name: "first bytes", rangeHeader: "bytes=0-3", want: 206, wantBody: "0123", contentRange: "bytes 0-3/16"},
+		{name: "open-ended", rangeHeader: "bytes=10-", want: 206, wantBody: "abcdef", contentRange: "bytes 10-15/16"},
+		{name: "suffix", rangeHeader: "bytes=-2", want: 206, wantBody: "ef", contentRange: "bytes 14-15/16"},
+		{name: "past the end", rangeHeader: "bytes=4-100", want: 206, wantBody: "456789abcdef", contentRange: "bytes 4-15/16"},
+		{name: "unsatisfiable", rangeHeader: "bytes=16-", want: 416, contentRange: "bytes */16"},
+		{name: "if-range etag", rangeHeader: "bytes=0-3", ifRange: `"v1"`, want: 206, wantBody: "0123", contentRange: "bytes 0-3/16"},
+		{name: "if-range date", rangeHeader: "bytes=0-3", ifRange: modified, want: 206, wantBody: "0123", contentRange: "bytes 0-3/16"},
+		{name: "if-range changed", rangeHeader: "bytes=0-3", ifRange: `"v2"`, want: 200, wantBody: "0123456789abcdef"},
+		{name: "malformed", rangeHeader: "pages=1", want: 416},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/files/1", nil)
+			r.Header.Set("Range", tt.rangeHeader)
+			if tt.ifRange != "" {
+				r.Header.Set("If-Range", tt.ifRange)
+			}
+			ctx, body := cacheRoundTrip(t, p, r, nil)
+			if !ctx.IsAborted() || ctx.AbortStatusCode() != tt.want {
+				t.Fatalf("status = %d (aborted %v), want %d from the cache", ctx.AbortStatusCode(), ctx.IsAborted(), tt.want)
+			}
+
+			got := ctx.Response.Header()
+			if got.Get(CacheHeader) != "HIT" {
+				t.Errorf("%s = %q, want HIT", CacheHeader, got.Get(CacheHeader))
+			}
+			if got.Get("Content-Range") != tt.contentRange {
+				t.Errorf("Content-Range = %q, want %q", got.Get("Content-Range"), tt.contentRange)
+			}
+			if tt.want != 416 && body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+
+	// Without a Content-Type the range isn't given a sniffed one
+	cacheRoundTrip(t, p, httptest.NewRequest("GET", "/files/2", nil), upstreamResponse(http.StatusOK, nil, "<html></html>"))
+	r = httptest.NewRequest("GET", "/files/2", nil)
+	r.Header.Set("Range", "bytes=0-5")
+	if ctx, _ := cacheRoundTrip(t, p, r, nil); ctx.Response.Header().Get("Content-Type") != "" {
+		t.Errorf("Content-Type = %q, want none", ctx.Response.Header().Get("Content-Type"))
+	}
+}
+
+func TestCache_Expiry(t *testing.T) {
+	p, _ := newTestCachePlugin(t, `{"ttl": "30s"}`)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	header := http.Header{"Cache-Control": {"max-age=5"}}
+	cacheRoundTrip(t, p, httptest.NewRequest("GET", "/products/1", nil), upstreamResponse(http.StatusOK, header, "v1"))
+	if ctx, _ := cacheRoundTrip(t, p, httptest.NewRequest("GET", "/products/1", nil), nil); !ctx.IsAborted() {
+		t.Fatal("fresh entry not served")
+	}
+
+	// max-age from the upstream wins over ttl
+	entry := p.store.Get(p.cacheKey(newTestContext(httptest.NewRequest("GET", "/products/1", nil), "r-products"), "r-products"))
+	if entry == nil || entry.Expires != now.Add(5*time.Second) {
+		t.Fatalf("entry = %+v, want it to expire after max-age", entry)
+	}
+}
+
+func TestCache_StaleIfError(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		header  http.Header
+		elapsed time.Duration
+		want    bool
+	}{
+		{name: "within the window", config: `{"stale_if_error": "10m"}`, elapsed: 5 * time.Minute, want: true},
+		{name: "past the window", config: `{"stale_if_error": "10m"}`, elapsed: 15 * time.Minute},
+		{name: "disabled", elapsed: 5 * time.Minute},
+		{name: "upstream directive", header: http.Header{"Cache-Control": {"max-age=60, stale-if-error=600"}}, elapsed: 5 * time.Minute, want: true},
+		{name: "directive overrides config", config: `{"stale_if_error": "10m"}`, header: http.Header{"Cache-Control": {"stale-if-error=0"}}, elapsed: 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The store keeps real time: cache the response elapsed ago
+			p, _ := newTestCachePlugin(t, tt.config)
+			p.now = func() time.Time { return time.Now().Add(-tt.elapsed) }
+			cacheRoundTrip(t, p, httptest.NewRequest("GET", "/products/1", nil), upstreamResponse(http.StatusOK, tt.header, "v1"))
+			p.now = time.Now
+
+			// The upstream answers 503
+			resp := upstreamResponse(http.StatusServiceUnavailable, nil, "down")
+			_, body := cacheRoundTrip(t, p, httptest.NewRequest("GET", "/products/1", nil), resp)
+			if served := body == "v1"; served != tt.want {
+				t.Fatalf("5xx: body = %q, stale served = %v, want %v", body, served, tt.want)
+			}
+			if tt.want {
+				if resp.StatusCode != http.StatusOK || resp.Header.Get(ServedStaleHeader) != "true" ||
+					resp.Header.Get("Warning") == "" || resp.Header.Get(CacheHeader) != "STALE" {
+					t.Errorf("stale response = %d %v", resp.StatusCode, resp.Header)
+				}
+			}
+
+			// The upstream can't be reached
+			ctx := newTestContext(httptest.NewRequest("GET", "/products/1", nil), "r-products")
+			if err := p.Execute(ctx); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			var substitute *http.Response
+			for _, hook := range plugin.ErrorHooks(ctx.Request) {
+				if substitute = hook(errors.New("connection refused")); substitute != nil {
+					break
+				}
+			}
+			if served := substitute != nil; served != tt.want {
+				t.Fatalf("connection error: stale served = %v, want %v", served, tt.want)
+			}
+			if substitute != nil {
+				if body, _ := io.ReadAll(substitute.Body); string(body) != "v1" || substitute.Header.Get(ServedStaleHeader) != "true" {
+					t.Errorf("substitute = %q %v", body, substitute.Header)
+				}
+			}
+		})
+	}
+}
+
+func TestCache_PurgeByTag(t *testing.T) {
+	p, store := newTestCachePlugin(t, "")
+	for _, path := range []string{"/products/1", "/products/2"} {
+		header := http.Header{"Surrogate-Key": {"products " + strings.TrimPrefix(path, "/products/")}}
+		cacheRoundTrip(t, p, httptest.NewRequest("GET", path, nil), upstreamResponse(http.StatusOK, header, path))
+	}
+
+	if got := store.Purge(respcache.Purge{Tag: "1"}); got != 1 {
+		t.Errorf("Purge(tag 1) = %d, want 1", got)
+	}
+	if ctx, _ := cacheRoundTrip(t, p, httptest.NewRequest("GET", "/products/1", nil), upstreamResponse(http.StatusOK, nil, "v2")); ctx.IsAborted() {
+		t.Error("purged entry served")
+	}
+	if ctx, _ := cacheRoundTrip(t, p, httptest.NewRequest("GET", "/products/2", nil), nil); !ctx.IsAborted() {
+		t.Error("entry with another tag purged")
+	}
+	if got := store.Purge(respcache.Purge{RouteID: "r-products", Tag: "products"}); got != 1 {
+		t.Errorf("Purge(route, tag products) = %d, want 1 (the re-fetched /products/1 has no tags)", got)
+	}
+}
+
+func TestCache_Config(t *testing.T) {
+	if _, err := NewCacheFactory(nil)(nil); err == nil {
+		t.Error("factory without a store succeeded, want error")
+	}
+	store := respcache.NewStore(1 << 20)
+	for _, config := range []string{
+		`{"ttl": "0s"}`,
+		`{"ttl": "soon"}`,
+		`{"methods": []}`,
+		`{"methods": ["POST"]}`,
+		`{"statuses": []}`,
+		`{"statuses": [206]}`,
+		`{"statuses": [99]}`,
+		`{"max_body_bytes": 0}`,
+		`{"stale_if_error": "0s"}`,
+		`{"stale_if_error": "forever"}`,
+	} {
+		if _, err := NewCacheFactory(store)(json.RawMessage(config)); err == nil {
+			t.Errorf("NewCacheFactory()(%s) succeeded, want error", config)
+		}
+	}
+}
@@ -14,6 +14,9 @@ import (
 	"github.com/saidutt46/switchboard-gateway/internal/plugin"
 )
 
+// corsOriginKey holds the request's origin once it is allowed.
+var corsOriginKey = plugin.RegisterKeyWithAlias[string]("cors.origin", "cors_origin")
+
 // CORSPlugin handles Cross-Origin Resource Sharing (CORS) for the gateway.
 //
 // CORS is a security feature that allows you to control which domains
@@ -189,8 +192,7 @@ func (p *CORSPlugin) handleBeforeRequest(ctx *plugin.Context) error {
 	}
 
 	// Store that this is a valid CORS request
-	ctx.Set("cors_origin_allowed", true)
-	ctx.Set("cors_origin", origin)
+	corsOriginKey.Set(ctx, origin)
 
 	// Handle preflight OPTIONS request
 	if ctx.Request.Method == "OPTIONS" {
@@ -206,12 +208,11 @@ func (p *CORSPlugin) handleBeforeRequest(ctx *plugin.Context) error {
 // This is where we add CORS headers to the actual response.
 func (p *CORSPlugin) handleAfterResponse(ctx *plugin.Context) error {
 	// Check if this was a valid CORS request
-	if !ctx.GetBool("cors_origin_allowed") {
+	origin, ok := corsOriginKey.Get(ctx)
+	if !ok {
 		return nil
 	}
 
-	origin := ctx.GetString("cors_origin")
-
 	// Add CORS headers to response
 	p.addCORSHeaders(ctx.Response, origin)
 
@@ -0,0 +1,225 @@
+// Package builtin - ETag plugin
+//
+// The etag plugin adds validators to a route's cacheable responses and
+// answers conditional requests at the gateway, so polling clients get a
+// bodiless 304 when nothing changed instead of the full response again:
+//   - 200 responses to GET requests without an ETag (or every one, with
+//     "override": true) get one computed from a hash of the body
+//   - If-None-Match is compared with the ETag (weak comparison, "*"
+//     matches anything); without it, If-Modified-Since is compared with
+//     Last-Modified
+//   - A match replaces the response with 304 Not Modified, keeping the
+//     validator and caching headers
+//
+// Configuration Example:
+//
+//	{
+//	  "weak": false,
+//	  "override": false,
+//	  "max_body_bytes": 1048576
+//	}
+//
+// The backend still serves every request (the cache plugin answers from
+// the gateway): the saving is the bandwidth to the client.
+// Responses larger than max_body_bytes, marked Cache-Control: no-store,
+// or content-encoded are passed through untagged (an ETag the backend
+// sent still counts for If-None-Match).
+package builtin
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// notModifiedHeaders are kept on a 304 (RFC 9110 section 15.4.5).
+var notModifiedHeaders = []string{
+	"Cache-Control", "Content-Location", "Date", "ETag", "Expires", "Last-Modified", "Vary",
+}
+
+// ETagPlugin computes ETags and answers conditional requests.
+type ETagPlugin struct {
+	config    ETagConfig
+	responses *metrics.CounterVec
+}
+
+// ETagConfig holds configuration for the etag plugin.
+type ETagConfig struct {
+	// Weak marks computed ETags weak (W/"..."), for backends whose bodies
+	// vary in insignificant ways
+	// Default: false
+	Weak bool `json:"weak"`
+
+	// Override replaces ETags sent by the backend
+	// Default: false
+	Override bool `json:"override"`
+
+	// MaxBodyBytes is the largest response hashed
+	// Default: 1048576 (1 MiB)
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+}
+
+// NewETagPlugin creates a new etag plugin.
+func NewETagPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := ETagConfig{MaxBodyBytes: 1 << 20}
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid etag config: %w", err)
+		}
+	}
+	if config.MaxBodyBytes <= 0 {
+		return nil, fmt.Errorf("invalid etag config: max_body_bytes must be positive")
+	}
+
+	return &ETagPlugin{
+		config: config,
+		responses: plugin.NewMetrics("etag").Counter(
+			"responses_total",
+			"Responses seen by the etag plugin, by route and result (not_modified, tagged, passed, skipped).",
+			"route", "result",
+		),
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *ETagPlugin) Name() string {
+	return "etag"
+}
+
+// Execute registers the response hook for GET and HEAD requests.
+func (p *ETagPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+	r := ctx.Request
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return nil
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+
+	ctx.AddResponseHook(func(resp *http.Response) error {
+		if resp.StatusCode != http.StatusOK {
+			p.responses.Inc(routeID, "skipped")
+			return nil
+		}
+
+		result := "passed"
+		if resp.Header.Get("ETag") == "" || p.config.Override {
+			tagged, err := p.tag(resp, r)
+			if err != nil {
+				return err
+			}
+			if tagged {
+				result = "tagged"
+			}
+		}
+
+		if notModified(resp.Header, ifNoneMatch, ifModifiedSince) {
+			p.replaceNotModified(resp)
+			result = "not_modified"
+		}
+		p.responses.Inc(routeID, result)
+		return nil
+	})
+	return nil
+}
+
+// tag sets an ETag hashed from the response body, reporting whether it
+// did. Large bodies may be spilled to disk while they are hashed.
+func (p *ETagPlugin) tag(resp *http.Response, r *http.Request) (bool, error) {
+	if r.Method == http.MethodHead || resp.ContentLength > p.config.MaxBodyBytes {
+		return false, nil
+	}
+	if strings.Contains(strings.ToLower(resp.Header.Get("Cache-Control")), "no-store") {
+		return false, nil
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return false, nil
+	}
+
+	body, err := bodybuffer.FromRequest(r).ReadSpillable(resp.Body, p.config.MaxBodyBytes)
+	if err != nil {
+		return false, fmt.Errorf("failed to read upstream response: %w", err)
+	}
+	resp.Body = body.ReadCloser(resp.Body)
+	if !body.Complete() {
+		return false, nil
+	}
+	resp.ContentLength = body.Size()
+	resp.Header.Set("Content-Length", strconv.FormatInt(body.Size(), 10))
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, body.Reader()); err != nil {
+		return false, fmt.Errorf("failed to hash upstream response: %w", err)
+	}
+	sum := hash.Sum(nil)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if p.config.Weak {
+		etag = "W/" + etag
+	}
+	resp.Header.Set("ETag", etag)
+	return true, nil
+}
+
+// replaceNotModified replaces the response with a 304.
+func (p *ETagPlugin) replaceNotModified(resp *http.Response) {
+	resp.Body.Close()
+	kept := make(http.Header, len(notModifiedHeaders))
+	for _, name := range notModifiedHeaders {
+		if values := resp.Header.Values(name); len(values) > 0 {
+			kept[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	resp.StatusCode = http.StatusNotModified
+	resp.Status = "304 Not Modified"
+	resp.Header = kept
+	resp.Body = http.NoBody
+	resp.ContentLength = 0
+}
+
+// notModified evaluates a request's If-None-Match, or else its
+// If-Modified-Since, against response headers.
+func notModified(header http.Header, ifNoneMatch, ifModifiedSince string) bool {
+	if ifNoneMatch != "" {
+		etag := header.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ifModifiedSince == "" {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
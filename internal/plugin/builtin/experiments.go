@@ -0,0 +1,337 @@
+// Package builtin - A/B experiment assignment plugin
+//
+// The experiments plugin buckets each client into a variant of every
+// configured experiment and tells the backend which one, so backends
+// don't each re-implement bucketing:
+//
+//	X-Experiment-Checkout-Redesign: new-flow
+//
+// Assignment is deterministic: a client is hashed (SHA-256 of experiment
+// name and client key) into one of 10000 buckets, and variants take
+// consecutive ranges of them by percent. The same client therefore lands
+// in the same variant on every gateway instance, and experiments are
+// bucketed independently of each other. Percentages may add up to less
+// than 100: clients in the remainder aren't enrolled and get no header.
+//
+// The client key is the consumer ID set by an auth plugin, or the client
+// IP ("key": "consumer_or_ip", the default). With "key": "consumer",
+// anonymous requests aren't enrolled. With a sticky cookie, assignments
+// are remembered in the browser, so they survive IP changes and
+// percentage changes.
+//
+// Configuration Example:
+//
+//	{
+//	  "experiments": [
+//	    {"name": "checkout-redesign", "variants": [
+//	      {"name": "control", "percent": 50},
+//	      {"name": "new-flow", "percent": 50}
+//	    ]}
+//	  ],
+//	  "key": "consumer_or_ip",
+//	  "header_prefix": "X-Experiment-",
+//	  "sticky_cookie": "sb_experiments",
+//	  "cookie_max_age": "720h"
+//	}
+//
+// Client-supplied headers with header_prefix are removed. Assignments are
+// stored in the context metadata under "experiments" (name -> variant)
+// and counted in gateway_plugin_experiments_assignments_total.
+package builtin
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/clientip"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// Experiment client keys.
+const (
+	experimentKeyConsumer     = "consumer"
+	experimentKeyIP           = "ip"
+	experimentKeyConsumerOrIP = "consumer_or_ip"
+)
+
+// experimentBuckets is the bucketing resolution (0.01%).
+const experimentBuckets = 10000
+
+// experimentsKey is the context metadata key of assignments.
+var experimentsKey = plugin.RegisterKeyWithAlias[map[string]string]("experiments.assigned", "experiments")
+
+// experimentNamePattern restricts experiment and variant names to
+// characters valid in header names and cookie values.
+var experimentNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ExperimentsPlugin assigns clients to experiment variants.
+type ExperimentsPlugin struct {
+	config      ExperimentsConfig
+	cookieAge   time.Duration
+	assignments *metrics.CounterVec
+}
+
+// ExperimentsConfig holds configuration for the experiments plugin.
+type ExperimentsConfig struct {
+	// Experiments are the running experiments
+	Experiments []Experiment `json:"experiments"`
+
+	// Key is what clients are bucketed by
+	// Options: "consumer", "ip", "consumer_or_ip"
+	// Default: "consumer_or_ip"
+	Key string `json:"key"`
+
+	// HeaderPrefix is prepended to experiment names to form header names
+	// Default: "X-Experiment-"
+	HeaderPrefix string `json:"header_prefix"`
+
+	// StickyCookie remembers assignments in this cookie (empty = no cookie)
+	// Default: ""
+	StickyCookie string `json:"sticky_cookie"`
+
+	// CookieMaxAge is the sticky cookie's lifetime
+	// Default: "720h" (30 days)
+	CookieMaxAge string `json:"cookie_max_age"`
+}
+
+// Experiment is a named experiment and its variants.
+type Experiment struct {
+	Name     string              `json:"name"`
+	Variants []ExperimentVariant `json:"variants"`
+}
+
+// ExperimentVariant is a variant and the share of clients it gets.
+type ExperimentVariant struct {
+	Name    string  `json:"name"`
+	Percent float64 `json:"percent"`
+
+	// upper is the exclusive upper bucket of the variant's range
+	upper int
+}
+
+// NewExperimentsPlugin creates a new experiments plugin.
+func NewExperimentsPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := ExperimentsConfig{
+		Key:          experimentKeyConsumerOrIP,
+		HeaderPrefix: "X-Experiment-",
+		CookieMaxAge: "720h",
+	}
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid experiments config: %w", err)
+		}
+	}
+
+	switch config.Key {
+	case experimentKeyConsumer, experimentKeyIP, experimentKeyConsumerOrIP:
+	default:
+		return nil, fmt.Errorf("invalid experiments config: key must be consumer, ip or consumer_or_ip")
+	}
+	if config.HeaderPrefix == "" {
+		return nil, fmt.Errorf("invalid experiments config: header_prefix is required")
+	}
+	cookieAge, err := time.ParseDuration(config.CookieMaxAge)
+	if err != nil || cookieAge <= 0 {
+		return nil, fmt.Errorf("invalid experiments config: invalid cookie_max_age '%s'", config.CookieMaxAge)
+	}
+	if len(config.Experiments) == 0 {
+		return nil, fmt.Errorf("invalid experiments config: experiments is required")
+	}
+
+	seen := make(map[string]bool, len(config.Experiments))
+	for i := range config.Experiments {
+		exp := &config.Experiments[i]
+		if !experimentNamePattern.MatchString(exp.Name) {
+			return nil, fmt.Errorf("invalid experiments config: experiment name '%s' must be letters, digits, - and _", exp.Name)
+		}
+		canonical := http.CanonicalHeaderKey(exp.Name)
+		if seen[canonical] {
+			return nil, fmt.Errorf("invalid experiments config: experiment '%s' is listed twice", exp.Name)
+		}
+		seen[canonical] = true
+
+		if len(exp.Variants) == 0 {
+			return nil, fmt.Errorf("invalid experiments config: experiment '%s' has no variants", exp.Name)
+		}
+		total := 0.0
+		variants := make(map[string]bool, len(exp.Variants))
+		for j := range exp.Variants {
+			v := &exp.Variants[j]
+			if !experimentNamePattern.MatchString(v.Name) || variants[v.Name] {
+				return nil, fmt.Errorf("invalid experiments config: experiment '%s' has an invalid or duplicate variant '%s'", exp.Name, v.Name)
+			}
+			variants[v.Name] = true
+			if v.Percent < 0 {
+				return nil, fmt.Errorf("invalid experiments config: variant '%s' has a negative percent", v.Name)
+			}
+			total += v.Percent
+			v.upper = int(total*experimentBuckets/100 + 0.5)
+		}
+		if total > 100.0001 {
+			return nil, fmt.Errorf("invalid experiments config: variants of '%s' add up to %.2f%% (more than 100)", exp.Name, total)
+		}
+	}
+
+	return &ExperimentsPlugin{
+		config:    config,
+		cookieAge: cookieAge,
+		assignments: plugin.NewMetrics("experiments").Counter(
+			"assignments_total",
+			"Experiment assignments, by route, experiment and variant (\"none\" = not enrolled).",
+			"route", "experiment", "variant",
+		),
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *ExperimentsPlugin) Name() string {
+	return "experiments"
+}
+
+// Execute assigns the client to a variant of each experiment.
+func (p *ExperimentsPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+
+	// Only the gateway assigns variants
+	header := ctx.Request.Header
+	for name := range header {
+		if len(name) >= len(p.config.HeaderPrefix) && strings.EqualFold(name[:len(p.config.HeaderPrefix)], p.config.HeaderPrefix) {
+			header.Del(name)
+		}
+	}
+
+	sticky := p.stickyAssignments(ctx.Request)
+	key := p.clientKey(ctx)
+
+	assigned := make(map[string]string, len(p.config.Experiments))
+	for _, exp := range p.config.Experiments {
+		variant, ok := sticky[exp.Name]
+		if !ok || !exp.hasVariant(variant) {
+			variant = ""
+			if key != "" {
+				variant = exp.assign(key)
+			}
+		}
+
+		if variant == "" {
+			p.assignments.Inc(routeID, exp.Name, "none")
+			continue
+		}
+		p.assignments.Inc(routeID, exp.Name, variant)
+		assigned[exp.Name] = variant
+		header.Set(p.config.HeaderPrefix+exp.Name, variant)
+	}
+
+	if len(assigned) > 0 {
+		experimentsKey.Set(ctx, assigned)
+	}
+	if p.config.StickyCookie != "" && len(assigned) > 0 && !sameAssignments(sticky, assigned) {
+		http.SetCookie(ctx.Response, &http.Cookie{
+			Name:     p.config.StickyCookie,
+			Value:    encodeAssignments(p.config.Experiments, assigned),
+			Path:     "/",
+			MaxAge:   int(p.cookieAge.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	return nil
+}
+
+// clientKey returns what the client is bucketed by ("" = not enrolled).
+func (p *ExperimentsPlugin) clientKey(ctx *plugin.Context) string {
+	consumerID := plugin.KeyConsumerID.Value(ctx)
+	switch p.config.Key {
+	case experimentKeyConsumer:
+		if consumerID != "" {
+			return "consumer:" + consumerID
+		}
+		return ""
+	case experimentKeyIP:
+		return "ip:" + clientip.FromRequest(ctx.Request)
+	default:
+		if consumerID != "" {
+			return "consumer:" + consumerID
+		}
+		return "ip:" + clientip.FromRequest(ctx.Request)
+	}
+}
+
+// assign returns the variant key falls into ("" = not enrolled).
+func (exp *Experiment) assign(key string) string {
+	sum := sha256.Sum256([]byte(exp.Name + "\n" + key))
+	bucket := int(binary.BigEndian.Uint64(sum[:8]) % experimentBuckets)
+	for _, v := range exp.Variants {
+		if bucket < v.upper {
+			return v.Name
+		}
+	}
+	return ""
+}
+
+// hasVariant reports whether the experiment has an enrolled variant name.
+func (exp *Experiment) hasVariant(name string) bool {
+	for _, v := range exp.Variants {
+		if v.Name == name && v.Percent > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// stickyAssignments reads the sticky cookie: "exp:variant.exp:variant".
+func (p *ExperimentsPlugin) stickyAssignments(r *http.Request) map[string]string {
+	if p.config.StickyCookie == "" {
+		return nil
+	}
+	cookie, err := r.Cookie(p.config.StickyCookie)
+	if err != nil {
+		return nil
+	}
+	assigned := make(map[string]string)
+	for _, pair := range strings.Split(cookie.Value, ".") {
+		if name, variant, ok := strings.Cut(pair, ":"); ok {
+			assigned[name] = variant
+		}
+	}
+	return assigned
+}
+
+// encodeAssignments writes assignments in experiment order.
+func encodeAssignments(experiments []Experiment, assigned map[string]string) string {
+	pairs := make([]string, 0, len(assigned))
+	for _, exp := range experiments {
+		if variant, ok := assigned[exp.Name]; ok {
+			pairs = append(pairs, exp.Name+":"+variant)
+		}
+	}
+	return strings.Join(pairs, ".")
+}
+
+// sameAssignments reports whether the cookie already holds assigned.
+func sameAssignments(sticky, assigned map[string]string) bool {
+	if len(sticky) != len(assigned) {
+		return false
+	}
+	for name, variant := range assigned {
+		if sticky[name] != variant {
+			return false
+		}
+	}
+	return true
+}
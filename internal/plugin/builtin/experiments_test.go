@@ -0,0 +1,182 @@
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+const checkoutExperiment = `{"name": "checkout", "variants": [
+	{"name": "control", "percent": 20},
+	{"name": "one-page", "percent": 30},
+	{"name": "express", "percent": 50}
+]}`
+
+func newTestExperiments(t *testing.T, config string) *ExperimentsPlugin {
+	t.Helper()
+	p, err := NewExperimentsPlugin(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewExperimentsPlugin() error = %v", err)
+	}
+	return p.(*ExperimentsPlugin)
+}
+
+// assignExperiments runs p on a request from consumerID (none if empty)
+// with the given cookie header.
+func assignExperiments(t *testing.T, p *ExperimentsPlugin, consumerID, cookie string) *plugin.Context {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/checkout", nil)
+	if cookie != "" {
+		r.Header.Set("Cookie", cookie)
+	}
+	ctx := newTestContext(r, "r-checkout")
+	if consumerID != "" {
+		plugin.KeyConsumerID.Set(ctx, consumerID)
+	}
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	return ctx
+}
+
+func TestExperiments_Sticky(t *testing.T) {
+	config := `{"experiments": [` + checkoutExperiment + `]}`
+	a, b := newTestExperiments(t, config), newTestExperiments(t, config)
+
+	// The same client gets the same variant from every instance
+	for i := 0; i < 50; i++ {
+		consumer := fmt.Sprintf("c-%d", i)
+		first := assignExperiments(t, a, consumer, "").Request.Header.Get("X-Experiment-Checkout")
+		if first == "" {
+			t.Fatalf("%s not enrolled, want a variant at 100%%", consumer)
+		}
+		for _, p := range []*ExperimentsPlugin{a, b} {
+			if got := assignExperiments(t, p, consumer, "").Request.Header.Get("X-Experiment-Checkout"); got != first {
+				t.Errorf("%s assigned %q, then %q", consumer, first, got)
+			}
+		}
+	}
+
+	// A cookie overrides the hash, until its variant is no longer enrolled
+	p := newTestExperiments(t, `{"experiments": [`+checkoutExperiment+`], "sticky_cookie": "sb_exp"}`)
+	ctx := assignExperiments(t, p, "c-1", "")
+	cookie := ctx.Response.Header().Get("Set-Cookie")
+	want := "sb_exp=checkout:" + ctx.Request.Header.Get("X-Experiment-Checkout")
+	if !strings.HasPrefix(cookie, want) {
+		t.Errorf("Set-Cookie = %q, want %s...", cookie, want)
+	}
+
+	for _, variant := range []string{"control", "one-page", "express"} {
+		ctx = assignExperiments(t, p, "c-1", "sb_exp=checkout:"+variant)
+		if got := ctx.Request.Header.Get("X-Experiment-Checkout"); got != variant {
+			t.Errorf("cookie %s: assigned %q", variant, got)
+		}
+		if ctx.Response.Header().Get("Set-Cookie") != "" {
+			t.Errorf("cookie %s: cookie set again", variant)
+		}
+	}
+
+	ctx = assignExperiments(t, p, "c-1", "sb_exp=checkout:retired")
+	if got := ctx.Request.Header.Get("X-Experiment-Checkout"); got == "retired" || got == "" {
+		t.Errorf("retired variant: assigned %q, want a new variant", got)
+	}
+	if ctx.Response.Header().Get("Set-Cookie") == "" {
+		t.Error("retired variant: cookie not replaced")
+	}
+}
+
+func TestExperiments_Distribution(t *testing.T) {
+	const clients = 20000
+
+	p := newTestExperiments(t, `{"experiments": [`+checkoutExperiment+`, {"name": "banner", "variants": [
+		{"name": "on", "percent": 10},
+		{"name": "off", "percent": 10}
+	]}]}`)
+
+	want := map[string]map[string]float64{
+		"checkout": {"control": 20, "one-page": 30, "express": 50},
+		"banner":   {"on": 10, "off": 10, "none": 80},
+	}
+	before := make(map[string]map[string]float64)
+	for exp, variants := range want {
+		before[exp] = make(map[string]float64)
+		for variant := range variants {
+			before[exp][variant] = p.assignments.Value("r-checkout", exp, variant)
+		}
+	}
+
+	counts := map[string]map[string]int{"checkout": {}, "banner": {}}
+	for i := 0; i < clients; i++ {
+		ctx := assignExperiments(t, p, fmt.Sprintf("c-%d", i), "")
+		for exp := range counts {
+			variant := ctx.Request.Header.Get("X-Experiment-" + exp)
+			if variant == "" {
+				variant = "none"
+			}
+			counts[exp][variant]++
+		}
+	}
+
+	for exp, variants := range want {
+		for variant, percent := range variants {
+			// Within a point of the configured share
+			if got := float64(counts[exp][variant]) * 100 / clients; math.Abs(got-percent) > 1 {
+				t.Errorf("%s/%s got %.1f%% of clients, want %.0f%%", exp, variant, got, percent)
+			}
+			if got := p.assignments.Value("r-checkout", exp, variant) - before[exp][variant]; int(got) != counts[exp][variant] {
+				t.Errorf("%s/%s counted %v assignments, want %d", exp, variant, got, counts[exp][variant])
+			}
+		}
+	}
+}
+
+func TestExperiments_Headers(t *testing.T) {
+	p := newTestExperiments(t, `{"experiments": [`+checkoutExperiment+`], "key": "consumer", "header_prefix": "X-Variant-"}`)
+
+	// Client-supplied variant headers never reach the backend
+	r := httptest.NewRequest("GET", "/checkout", nil)
+	r.Header.Set("X-Variant-Checkout", "forged")
+	r.Header.Set("x-variant-admin", "yes")
+	ctx := newTestContext(r, "r-checkout")
+	plugin.KeyConsumerID.Set(ctx, "c-7")
+	p.Execute(ctx)
+
+	variant := ctx.Request.Header.Get("X-Variant-Checkout")
+	if variant == "forged" || variant == "" || ctx.Request.Header.Get("X-Variant-Admin") != "" {
+		t.Errorf("headers = %v, want only the assigned variant", ctx.Request.Header)
+	}
+	assigned := experimentsKey.Value(ctx)
+	if len(assigned) != 1 || assigned["checkout"] != variant {
+		t.Errorf("metadata = %v, want checkout:%s", assigned, variant)
+	}
+
+	// With key consumer, anonymous requests aren't enrolled
+	ctx = assignExperiments(t, p, "", "")
+	if got := ctx.Request.Header.Get("X-Variant-Checkout"); got != "" || experimentsKey.Value(ctx) != nil {
+		t.Errorf("anonymous request assigned %q, want no variant", got)
+	}
+}
+
+func TestExperiments_Config(t *testing.T) {
+	for _, config := range []string{
+		`{}`,
+		`{"experiments": [` + checkoutExperiment + `], "key": "session"}`,
+		`{"experiments": [` + checkoutExperiment + `], "header_prefix": ""}`,
+		`{"experiments": [` + checkoutExperiment + `], "cookie_max_age": "0s"}`,
+		`{"experiments": [` + checkoutExperiment + `, ` + checkoutExperiment + `]}`,
+		`{"experiments": [{"name": "new checkout", "variants": [{"name": "a", "percent": 100}]}]}`,
+		`{"experiments": [{"name": "checkout", "variants": []}]}`,
+		`{"experiments": [{"name": "checkout", "variants": [{"name": "a", "percent": 50}, {"name": "a", "percent": 50}]}]}`,
+		`{"experiments": [{"name": "checkout", "variants": [{"name": "a", "percent": -1}]}]}`,
+		`{"experiments": [{"name": "checkout", "variants": [{"name": "a", "percent": 60}, {"name": "b", "percent": 60}]}]}`,
+	} {
+		if _, err := NewExperimentsPlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewExperimentsPlugin(%s) succeeded, want error", config)
+		}
+	}
+}
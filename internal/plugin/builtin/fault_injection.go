@@ -0,0 +1,239 @@
+// Package builtin - Fault Injection plugin for chaos testing
+//
+// This plugin injects latency and error responses so teams can test how
+// their clients behave when an upstream is slow or failing, without
+// touching the upstream itself.
+//
+// Features:
+//   - Fixed delay plus random jitter, applied to a percentage of requests
+//   - Error responses (503 or custom status) for a percentage of requests
+//   - Optional targeting of specific consumers
+//   - Optional override header forcing a fault on a single request
+//   - Disabled unless FAULT_INJECTION_ENABLED=true outside production
+//
+// Configuration Example:
+//
+//	{
+//	  "delay": "200ms",
+//	  "delay_jitter": "100ms",
+//	  "delay_percentage": 50,
+//	  "abort_percentage": 10,
+//	  "abort_status": 503,
+//	  "abort_message": "Fault injected",
+//	  "consumers": ["consumer-uuid"],
+//	  "override_header": "X-Fault"
+//	}
+//
+// Delay and abort are rolled independently: a request may be delayed and
+// then aborted. A request sending the override header with "delay",
+// "abort" or "delay, abort" gets those faults whatever the percentages
+// (consumer targeting still applies); the header isn't forwarded.
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// FaultHeader is set on responses produced by an injected abort.
+const FaultHeader = "X-Fault-Injected"
+
+// FaultInjectionPlugin injects latency and errors into requests.
+type FaultInjectionPlugin struct {
+	config    FaultInjectionConfig
+	delay     time.Duration
+	jitter    time.Duration
+	consumers map[string]bool
+	roll      func() float64 // a percentage in [0, 100)
+}
+
+// FaultInjectionConfig holds configuration for the fault injection plugin.
+type FaultInjectionConfig struct {
+	// Delay is the fixed latency added to delayed requests
+	// Format: "100ms", "2s"
+	Delay string `json:"delay"`
+
+	// DelayJitter adds a random 0..jitter on top of Delay
+	DelayJitter string `json:"delay_jitter"`
+
+	// DelayPercentage is the percentage of requests (0-100) to delay
+	// Default: 100 (if a delay is configured)
+	DelayPercentage float64 `json:"delay_percentage"`
+
+	// AbortPercentage is the percentage of requests (0-100) to fail
+	// Default: 0
+	AbortPercentage float64 `json:"abort_percentage"`
+
+	// AbortStatus is the HTTP status returned for aborted requests
+	// Default: 503
+	AbortStatus int `json:"abort_status"`
+
+	// AbortMessage is the response body for aborted requests
+	// Default: "Fault injected"
+	AbortMessage string `json:"abort_message"`
+
+	// Consumers restricts faults to these consumer IDs (empty = everyone)
+	Consumers []string `json:"consumers"`
+
+	// OverrideHeader is a request header forcing faults regardless of the
+	// percentages: "delay", "abort" or both, comma-separated (empty = off)
+	OverrideHeader string `json:"override_header"`
+}
+
+// DefaultFaultInjectionConfig returns sensible defaults (no faults).
+func DefaultFaultInjectionConfig() FaultInjectionConfig {
+	return FaultInjectionConfig{
+		DelayPercentage: 100,
+		AbortPercentage: 0,
+		AbortStatus:     503,
+		AbortMessage:    "Fault injected",
+	}
+}
+
+// NewFaultInjectionFactory returns the fault-injection plugin factory.
+//
+// If enabled is false (the default, and always in production), the
+// factory refuses to create instances so a stray plugin row can never
+// inject faults into real traffic.
+func NewFaultInjectionFactory(enabled bool) plugin.PluginFactory {
+	return func(configJSON json.RawMessage) (plugin.Plugin, error) {
+		if !enabled {
+			return nil, fmt.Errorf("%w: fault injection is disabled (set FAULT_INJECTION_ENABLED=true in a non-production environment)", plugin.ErrPluginUnavailable)
+		}
+		return NewFaultInjectionPlugin(configJSON)
+	}
+}
+
+// NewFaultInjectionPlugin creates a new fault injection plugin.
+func NewFaultInjectionPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := DefaultFaultInjectionConfig()
+
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid fault-injection config: %w", err)
+		}
+	}
+
+	var delay, jitter time.Duration
+	var err error
+	if config.Delay != "" {
+		if delay, err = time.ParseDuration(config.Delay); err != nil || delay < 0 {
+			return nil, fmt.Errorf("invalid delay '%s'", config.Delay)
+		}
+	}
+	if config.DelayJitter != "" {
+		if jitter, err = time.ParseDuration(config.DelayJitter); err != nil || jitter < 0 {
+			return nil, fmt.Errorf("invalid delay_jitter '%s'", config.DelayJitter)
+		}
+	}
+
+	if config.DelayPercentage < 0 || config.DelayPercentage > 100 {
+		return nil, fmt.Errorf("delay_percentage must be between 0 and 100")
+	}
+	if config.AbortPercentage < 0 || config.AbortPercentage > 100 {
+		return nil, fmt.Errorf("abort_percentage must be between 0 and 100")
+	}
+	if config.AbortStatus < 400 || config.AbortStatus >= 600 {
+		return nil, fmt.Errorf("abort_status must be 4xx or 5xx")
+	}
+
+	consumers := make(map[string]bool, len(config.Consumers))
+	for _, id := range config.Consumers {
+		consumers[id] = true
+	}
+
+	log.Warn().
+		Str("component", "plugin").
+		Str("plugin", "fault-injection").
+		Dur("delay", delay).
+		Dur("jitter", jitter).
+		Float64("delay_percentage", config.DelayPercentage).
+		Float64("abort_percentage", config.AbortPercentage).
+		Int("abort_status", config.AbortStatus).
+		Int("consumers", len(consumers)).
+		Msg("Fault injection plugin initialized - requests will be delayed/failed")
+
+	return &FaultInjectionPlugin{
+		config:    config,
+		delay:     delay,
+		jitter:    jitter,
+		consumers: consumers,
+		roll:      roll,
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *FaultInjectionPlugin) Name() string {
+	return "fault-injection"
+}
+
+// Execute runs the fault injection plugin.
+func (p *FaultInjectionPlugin) Execute(ctx *plugin.Context) error {
+	// Only run in BeforeRequest phase
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	if len(p.consumers) > 0 && !p.consumers[plugin.KeyConsumerID.Value(ctx)] {
+		return nil
+	}
+
+	forceDelay, forceAbort := p.overrides(ctx)
+
+	if (p.delay > 0 || p.jitter > 0) && (forceDelay || p.roll() < p.config.DelayPercentage) {
+		delay := p.delay
+		if p.jitter > 0 {
+			delay += rand.N(p.jitter)
+		}
+
+		ctx.LogDebug("fault-injection", fmt.Sprintf("Injecting %s delay", delay))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Context().Done():
+			timer.Stop()
+			ctx.Abort(499, "Client closed request")
+			return nil
+		}
+	}
+
+	if forceAbort || (p.config.AbortPercentage > 0 && p.roll() < p.config.AbortPercentage) {
+		ctx.LogInfo("fault-injection", "Injecting error response")
+
+		ctx.Response.Header().Set(FaultHeader, "true")
+		ctx.Abort(p.config.AbortStatus, p.config.AbortMessage)
+	}
+
+	return nil
+}
+
+// overrides reads and removes the override header, reporting which
+// faults it forces.
+func (p *FaultInjectionPlugin) overrides(ctx *plugin.Context) (delay, abort bool) {
+	if p.config.OverrideHeader == "" {
+		return false, false
+	}
+	values := ctx.Request.Header.Values(p.config.OverrideHeader)
+	ctx.Request.Header.Del(p.config.OverrideHeader)
+	for _, fault := range strings.Split(strings.Join(values, ","), ",") {
+		switch strings.ToLower(strings.TrimSpace(fault)) {
+		case "delay":
+			delay = true
+		case "abort":
+			abort = true
+		}
+	}
+	return delay, abort
+}
+
+// roll returns a random percentage in [0, 100).
+func roll() float64 {
+	return rand.Float64() * 100
+}
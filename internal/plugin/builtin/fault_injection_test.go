@@ -0,0 +1,174 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// newTestFaultInjection builds the plugin with a seeded roll.
+func newTestFaultInjection(t *testing.T, config string) *FaultInjectionPlugin {
+	t.Helper()
+	p, err := NewFaultInjectionPlugin(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewFaultInjectionPlugin() error = %v", err)
+	}
+	fi := p.(*FaultInjectionPlugin)
+	rng := rand.New(rand.NewPCG(1, 2))
+	fi.roll = func() float64 { return rng.Float64() * 100 }
+	return fi
+}
+
+// faultRequest runs p on a request. With cancelled set the client has
+// already gone, so a delayed request ends at once with 499.
+func faultRequest(t *testing.T, p *FaultInjectionPlugin, header http.Header, cancelled bool) *plugin.Context {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/orders", nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	if cancelled {
+		ctx, cancel := context.WithCancel(r.Context())
+		cancel()
+		r = r.WithContext(ctx)
+	}
+	ctx := newTestContext(r, "r-orders")
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	return ctx
+}
+
+func TestFaultInjection_Percentages(t *testing.T) {
+	const requests = 2000
+
+	tests := []struct {
+		name   string
+		config string
+		status int // the status counted
+		want   float64
+	}{
+		{name: "delay 25%", config: `{"delay": "1h", "delay_percentage": 25}`, status: 499, want: 25},
+		{name: "delay 100%", config: `{"delay": "1h"}`, status: 499, want: 100},
+		{name: "delay 0%", config: `{"delay": "1h", "delay_percentage": 0}`, status: 499, want: 0},
+		{name: "abort 10%", config: `{"abort_percentage": 10}`, status: 503, want: 10},
+		{name: "abort 50% custom status", config: `{"abort_percentage": 50, "abort_status": 429}`, status: 429, want: 50},
+		{name: "abort 0%", config: `{}`, status: 503, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestFaultInjection(t, tt.config)
+			count := 0
+			for i := 0; i < requests; i++ {
+				if ctx := faultRequest(t, p, nil, true); ctx.IsAborted() {
+					if ctx.AbortStatusCode() != tt.status {
+						t.Fatalf("aborted with %d, want %d", ctx.AbortStatusCode(), tt.status)
+					}
+					count++
+				}
+			}
+			// Within 3 points of the configured percentage
+			if got := float64(count) * 100 / requests; math.Abs(got-tt.want) > 3 {
+				t.Errorf("faulted %.1f%% of requests, want %.0f%%", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFaultInjection_Abort(t *testing.T) {
+	p := newTestFaultInjection(t, `{"abort_percentage": 100, "abort_status": 500, "abort_message": "chaos"}`)
+	ctx := faultRequest(t, p, nil, false)
+	if !ctx.IsAborted() || ctx.AbortStatusCode() != 500 || ctx.AbortMessage() != "chaos" {
+		t.Errorf("aborted = %v with %d %q, want 500 chaos", ctx.IsAborted(), ctx.AbortStatusCode(), ctx.AbortMessage())
+	}
+	if ctx.Response.Header().Get(FaultHeader) != "true" {
+		t.Errorf("%s missing on an injected error", FaultHeader)
+	}
+}
+
+func TestFaultInjection_OverrideHeader(t *testing.T) {
+	p := newTestFaultInjection(t, `{"delay": "1h", "delay_percentage": 0, "abort_percentage": 0, "override_header": "X-Fault"}`)
+
+	tests := []struct {
+		name  string
+		value string
+		want  int // 0 = no fault
+	}{
+		{name: "abort", value: "abort", want: 503},
+		{name: "delay", value: "delay", want: 499},
+		{name: "both", value: "Delay, abort", want: 499},
+		{name: "unknown fault", value: "explode"},
+		{name: "no header"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.value != "" {
+				header.Set("X-Fault", tt.value)
+			}
+			ctx := faultRequest(t, p, header, true)
+			if got := ctx.AbortStatusCode(); ctx.IsAborted() != (tt.want != 0) || got != tt.want {
+				t.Errorf("aborted = %v with %d, want %d", ctx.IsAborted(), got, tt.want)
+			}
+			if ctx.Request.Header.Get("X-Fault") != "" {
+				t.Error("override header forwarded upstream")
+			}
+		})
+	}
+
+	// Without override_header the header is an ordinary one
+	p = newTestFaultInjection(t, `{"abort_percentage": 0}`)
+	ctx := faultRequest(t, p, http.Header{"X-Fault": {"abort"}}, false)
+	if ctx.IsAborted() || ctx.Request.Header.Get("X-Fault") != "abort" {
+		t.Errorf("aborted = %v, X-Fault = %q; want the header ignored and forwarded", ctx.IsAborted(), ctx.Request.Header.Get("X-Fault"))
+	}
+}
+
+func TestFaultInjection_Consumers(t *testing.T) {
+	p := newTestFaultInjection(t, `{"abort_percentage": 100, "consumers": ["c-chaos"], "override_header": "X-Fault"}`)
+	request := func(consumerID string) *plugin.Context {
+		ctx := newTestContext(httptest.NewRequest("GET", "/orders", nil), "r-orders")
+		ctx.Request.Header.Set("X-Fault", "abort")
+		if consumerID != "" {
+			plugin.KeyConsumerID.Set(ctx, consumerID)
+		}
+		p.Execute(ctx)
+		return ctx
+	}
+
+	if ctx := request("c-chaos"); ctx.AbortStatusCode() != 503 {
+		t.Errorf("targeted consumer: status %d, want 503", ctx.AbortStatusCode())
+	}
+	for _, id := range []string{"c-other", ""} {
+		if ctx := request(id); ctx.IsAborted() {
+			t.Errorf("consumer %q: aborted with %d, want untouched even with the override header", id, ctx.AbortStatusCode())
+		}
+	}
+}
+
+func TestFaultInjection_Config(t *testing.T) {
+	for _, config := range []string{
+		`{"delay": "soon"}`,
+		`{"delay": "-1s"}`,
+		`{"delay_jitter": "-1s"}`,
+		`{"delay_percentage": 101}`,
+		`{"abort_percentage": -1}`,
+		`{"abort_status": 200}`,
+	} {
+		if _, err := NewFaultInjectionPlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewFaultInjectionPlugin(%s) succeeded, want error", config)
+		}
+	}
+
+	if _, err := NewFaultInjectionFactory(false)(nil); err == nil {
+		t.Error("factory succeeded with fault injection disabled")
+	}
+}
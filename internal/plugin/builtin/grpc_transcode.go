@@ -0,0 +1,177 @@
+// Package builtin - gRPC transcoding plugin
+//
+// The grpc-transcode plugin exposes a gRPC service as a REST/JSON API.
+// Requests are matched against the google.api.http annotations of a
+// compiled descriptor set, sent to the backend as unary gRPC calls, and
+// the responses (or gRPC errors) are returned as JSON:
+//
+//	GET /v1/orders/42  ->  /shop.v1.Orders/GetOrder {order_id: 42}
+//
+// Every unary method is also reachable as POST /<package>.<Service>/<Method>
+// with the request message as the JSON body.
+//
+// Configuration Example:
+//
+//	{
+//	  "descriptor_file": "/etc/gateway/orders.pb",
+//	  "services": ["shop.v1.Orders"],
+//	  "path_prefix": "/api",
+//	  "use_proto_names": false,
+//	  "emit_unpopulated": false
+//	}
+//
+// The descriptor set is produced with
+// `protoc --include_imports --descriptor_set_out=orders.pb orders.proto`
+// and given either as a file or inline as base64 ("descriptor_set"). The
+// route's service should use protocol "grpc" (HTTP/2 without TLS) or
+// "https" (HTTP/2 negotiated over TLS).
+//
+// Requests that match no method get a 404 with a google.rpc.Status body;
+// they are not passed through. Streaming methods are not transcoded.
+package builtin
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/transcode"
+)
+
+// GRPCTranscodePlugin translates REST/JSON requests to gRPC calls.
+type GRPCTranscodePlugin struct {
+	transcoder *transcode.Transcoder
+	requests   *metrics.CounterVec
+}
+
+// GRPCTranscodeConfig holds configuration for the gRPC transcoding plugin.
+type GRPCTranscodeConfig struct {
+	// DescriptorSet is a base64-encoded FileDescriptorSet
+	DescriptorSet string `json:"descriptor_set"`
+
+	// DescriptorFile is the path of a FileDescriptorSet
+	// One of DescriptorSet or DescriptorFile is required
+	DescriptorFile string `json:"descriptor_file"`
+
+	// Services limits transcoding to these fully qualified services
+	// Default: every service in the descriptor set
+	Services []string `json:"services"`
+
+	// PathPrefix is stripped from request paths before matching, e.g. the
+	// route's path; the service's own path is not used
+	PathPrefix string `json:"path_prefix"`
+
+	// UseProtoNames writes proto field names (order_id) instead of JSON
+	// names (orderId) in responses
+	UseProtoNames bool `json:"use_proto_names"`
+
+	// EmitUnpopulated writes fields left at their default value
+	EmitUnpopulated bool `json:"emit_unpopulated"`
+
+	// MaxBodyBytes limits request and response bodies
+	// Default: 4 MB
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+}
+
+// NewGRPCTranscodePlugin creates a new gRPC transcoding plugin.
+func NewGRPCTranscodePlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	var config GRPCTranscodeConfig
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid grpc-transcode config: %w", err)
+		}
+	}
+
+	var descriptorSet []byte
+	switch {
+	case config.DescriptorSet != "" && config.DescriptorFile != "":
+		return nil, errors.New("invalid grpc-transcode config: set only one of descriptor_set and descriptor_file")
+	case config.DescriptorSet != "":
+		data, err := base64.StdEncoding.DecodeString(config.DescriptorSet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grpc-transcode config: descriptor_set: %w", err)
+		}
+		descriptorSet = data
+	case config.DescriptorFile != "":
+		data, err := os.ReadFile(config.DescriptorFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grpc-transcode config: %w", err)
+		}
+		descriptorSet = data
+	default:
+		return nil, errors.New("invalid grpc-transcode config: descriptor_set or descriptor_file is required")
+	}
+
+	transcoder, err := transcode.New(descriptorSet, transcode.Config{
+		Services:        config.Services,
+		PathPrefix:      config.PathPrefix,
+		UseProtoNames:   config.UseProtoNames,
+		EmitUnpopulated: config.EmitUnpopulated,
+		MaxBodyBytes:    config.MaxBodyBytes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid grpc-transcode config: %w", err)
+	}
+
+	log.Info().
+		Str("component", "plugin").
+		Str("plugin", "grpc-transcode").
+		Strs("services", config.Services).
+		Str("path_prefix", config.PathPrefix).
+		Msg("gRPC transcoding plugin initialized")
+
+	return &GRPCTranscodePlugin{
+		transcoder: transcoder,
+		requests: plugin.NewMetrics("grpc-transcode").Counter(
+			"requests_total",
+			"Transcoded requests by gRPC method and HTTP status.",
+			"method", "code",
+		),
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *GRPCTranscodePlugin) Name() string {
+	return "grpc-transcode"
+}
+
+// Execute maps the request to a gRPC method and registers the hooks that
+// rewrite the upstream request and translate its response.
+func (p *GRPCTranscodePlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	call, err := p.transcoder.Prepare(ctx.Request)
+	if err != nil {
+		var rpcErr *transcode.Error
+		if !errors.As(err, &rpcErr) {
+			return err
+		}
+		p.requests.Inc("", strconv.Itoa(rpcErr.HTTPStatus))
+		ctx.Response.Header().Set("Content-Type", "application/json")
+		ctx.Abort(rpcErr.HTTPStatus, string(rpcErr.Body()))
+		return nil
+	}
+
+	method := call.Method.FullMethod()
+	ctx.Decide("transcoded to " + method)
+
+	ctx.AddUpstreamHook(call.Rewrite)
+	ctx.AddResponseHook(func(resp *http.Response) error {
+		if err := call.Translate(resp); err != nil {
+			return err
+		}
+		p.requests.Inc(method, strconv.Itoa(resp.StatusCode))
+		return nil
+	})
+	return nil
+}
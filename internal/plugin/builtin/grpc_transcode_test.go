@@ -0,0 +1,238 @@
+package builtin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// protoBytes and protoVarint encode a length-delimited and a varint field.
+func protoBytes(number int, parts ...[]byte) []byte {
+	data := bytes.Join(parts, nil)
+	b := binary.AppendUvarint(nil, uint64(number)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func protoVarint(number int, v uint64) []byte {
+	return binary.AppendUvarint(binary.AppendUvarint(nil, uint64(number)<<3), v)
+}
+
+// ordersDescriptorSet returns the base64 descriptor set of:
+//
+//	package shop.v1;
+//
+//	message GetOrderRequest { int64 order_id = 1; }
+//	message Order { int64 order_id = 1; string status = 2; }
+//
+//	service Orders {
+//	  rpc GetOrder(GetOrderRequest) returns (Order) {
+//	    option (google.api.http) = { get: "/v1/orders/{order_id}" };
+//	  }
+//	}
+func ordersDescriptorSet() string {
+	field := func(name string, number, typ int) []byte {
+		return protoBytes(2, protoBytes(1, []byte(name)), protoVarint(3, uint64(number)), protoVarint(4, 1), protoVarint(5, uint64(typ)))
+	}
+	const typeInt64, typeString = 3, 9
+
+	file := protoBytes(1,
+		protoBytes(1, []byte("shop/v1/orders.proto")),
+		protoBytes(2, []byte("shop.v1")),
+		protoBytes(4, protoBytes(1, []byte("GetOrderRequest")), field("order_id", 1, typeInt64)),
+		protoBytes(4, protoBytes(1, []byte("Order")), field("order_id", 1, typeInt64), field("status", 2, typeString)),
+		protoBytes(6,
+			protoBytes(1, []byte("Orders")),
+			protoBytes(2,
+				protoBytes(1, []byte("GetOrder")),
+				protoBytes(2, []byte(".shop.v1.GetOrderRequest")),
+				protoBytes(3, []byte(".shop.v1.Order")),
+				protoBytes(4, protoBytes(72295728, protoBytes(2, []byte("/v1/orders/{order_id}")))),
+			),
+		),
+	)
+	return base64.StdEncoding.EncodeToString(file)
+}
+
+// grpcFrame wraps a message in the gRPC length-prefixed framing.
+func grpcFrame(message []byte) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+// transcodeRequest runs a grpc-transcode plugin on GET path and, if the
+// request is transcoded, applies its upstream hooks.
+func transcodeRequest(t *testing.T, path string) *plugin.Context {
+	t.Helper()
+	p, err := NewGRPCTranscodePlugin(json.RawMessage(`{"descriptor_set": "` + ordersDescriptorSet() + `", "path_prefix": "/api"}`))
+	if err != nil {
+		t.Fatalf("NewGRPCTranscodePlugin() error = %v", err)
+	}
+	ctx := newTestContext(httptest.NewRequest("GET", path, nil), "r-orders")
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	for _, hook := range plugin.UpstreamHooks(ctx.Request) {
+		if err := hook(ctx.Request); err != nil {
+			t.Fatalf("upstream hook error = %v", err)
+		}
+	}
+	return ctx
+}
+
+// grpcResponse is a gRPC response carrying body, with status in trailers.
+func grpcResponse(body []byte, trailer http.Header) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/grpc"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Trailer:    trailer,
+	}
+}
+
+// translate runs the response hooks on resp and returns the client body.
+func translate(t *testing.T, ctx *plugin.Context, resp *http.Response) string {
+	t.Helper()
+	for _, hook := range plugin.ResponseHooks(ctx.Request) {
+		if err := hook(resp); err != nil {
+			t.Fatalf("response hook error = %v", err)
+		}
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestGRPCTranscode_RoundTrip(t *testing.T) {
+	ctx := transcodeRequest(t, "/api/v1/orders/42")
+	if ctx.IsAborted() {
+		t.Fatalf("aborted with %d %s, want the request transcoded", ctx.AbortStatusCode(), ctx.AbortMessage())
+	}
+
+	// The upstream request is a framed GetOrderRequest{order_id: 42}
+	r := ctx.Request
+	if r.Method != "POST" || r.URL.Path != "/shop.v1.Orders/GetOrder" || r.Header.Get("Content-Type") != "application/grpc" || r.Header.Get("Te") != "trailers" {
+		t.Errorf("upstream request = %s %s %v, want a gRPC call to GetOrder", r.Method, r.URL.Path, r.Header)
+	}
+	body, _ := io.ReadAll(r.Body)
+	if want := grpcFrame(protoVarint(1, 42)); !bytes.Equal(body, want) {
+		t.Errorf("upstream body = %x, want %x", body, want)
+	}
+
+	// The Order reply comes back as JSON, int64 as a string
+	order := append(protoVarint(1, 42), protoBytes(2, []byte("SHIPPED"))...)
+	resp := grpcResponse(grpcFrame(order), http.Header{"Grpc-Status": {"0"}})
+	resp.Header.Set("Grpc-Encoding", "identity")
+	got := translate(t, ctx, resp)
+
+	if want := `{"orderId":"42","status":"SHIPPED"}`; got != want || resp.StatusCode != http.StatusOK {
+		t.Errorf("response = %d %s, want 200 %s", resp.StatusCode, got, want)
+	}
+	if resp.Header.Get("Content-Type") != "application/json" || resp.Header.Get("Grpc-Encoding") != "" {
+		t.Errorf("response headers = %v, want JSON without the grpc- headers", resp.Header)
+	}
+}
+
+func TestGRPCTranscode_Status(t *testing.T) {
+	tests := []struct {
+		name   string
+		resp   *http.Response
+		status int
+		want   string
+	}{
+		{
+			name:   "not found",
+			resp:   grpcResponse(nil, http.Header{"Grpc-Status": {"5"}, "Grpc-Message": {"order%2042%20not%20found"}}),
+			status: http.StatusNotFound,
+			want:   `{"code":5,"message":"order 42 not found","details":[]}`,
+		},
+		{name: "invalid argument", resp: grpcResponse(nil, http.Header{"Grpc-Status": {"3"}}), status: http.StatusBadRequest},
+		{name: "deadline exceeded", resp: grpcResponse(nil, http.Header{"Grpc-Status": {"4"}}), status: http.StatusGatewayTimeout},
+		{name: "already exists", resp: grpcResponse(nil, http.Header{"Grpc-Status": {"6"}}), status: http.StatusConflict},
+		{name: "permission denied", resp: grpcResponse(nil, http.Header{"Grpc-Status": {"7"}}), status: http.StatusForbidden},
+		{name: "resource exhausted", resp: grpcResponse(nil, http.Header{"Grpc-Status": {"8"}}), status: http.StatusTooManyRequests},
+		{name: "unimplemented", resp: grpcResponse(nil, http.Header{"Grpc-Status": {"12"}}), status: http.StatusNotImplemented},
+		{name: "internal", resp: grpcResponse(nil, http.Header{"Grpc-Status": {"13"}}), status: http.StatusInternalServerError},
+		{name: "unavailable", resp: grpcResponse(nil, http.Header{"Grpc-Status": {"14"}}), status: http.StatusServiceUnavailable},
+		{name: "unauthenticated", resp: grpcResponse(nil, http.Header{"Grpc-Status": {"16"}}), status: http.StatusUnauthorized},
+		{
+			name: "trailers only",
+			resp: &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/grpc"}, "Grpc-Status": {"7"}, "Grpc-Message": {"no"}},
+				Body:       http.NoBody,
+			},
+			status: http.StatusForbidden,
+			want:   `{"code":7,"message":"no","details":[]}`,
+		},
+		{
+			name:   "missing status",
+			resp:   grpcResponse(grpcFrame(nil), nil),
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:   "not a gRPC server",
+			resp:   upstreamResponse(http.StatusBadGateway, http.Header{"Content-Type": {"text/html"}}, "<h1>bad gateway</h1>"),
+			status: http.StatusServiceUnavailable,
+			want:   `{"code":14,"message":"upstream returned HTTP 502","details":[]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := transcodeRequest(t, "/api/v1/orders/42")
+			got := translate(t, ctx, tt.resp)
+			if tt.resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", tt.resp.StatusCode, tt.status)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+			if tt.resp.Header.Get("Content-Type") != "application/json" || tt.resp.Header.Get("Grpc-Status") != "" {
+				t.Errorf("headers = %v, want JSON without the grpc- headers", tt.resp.Header)
+			}
+		})
+	}
+}
+
+func TestGRPCTranscode_NoMethod(t *testing.T) {
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{path: "/api/v1/customers/42", status: http.StatusNotFound},
+		{path: "/api/v1/orders/forty-two", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		ctx := transcodeRequest(t, tt.path)
+		if !ctx.IsAborted() || ctx.AbortStatusCode() != tt.status {
+			t.Errorf("%s: aborted = %v with %d, want %d", tt.path, ctx.IsAborted(), ctx.AbortStatusCode(), tt.status)
+		}
+		if !strings.HasPrefix(ctx.AbortMessage(), `{"code":`) || ctx.Response.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: body = %s, want a google.rpc.Status", tt.path, ctx.AbortMessage())
+		}
+	}
+}
+
+func TestGRPCTranscode_Config(t *testing.T) {
+	for _, config := range []string{
+		`{}`,
+		`{"descriptor_set": "not base64!"}`,
+		`{"descriptor_set": "` + ordersDescriptorSet() + `", "descriptor_file": "/etc/orders.pb"}`,
+		`{"descriptor_file": "/nonexistent/orders.pb"}`,
+		`{"descriptor_set": "` + ordersDescriptorSet() + `", "services": ["shop.v1.Missing"]}`,
+	} {
+		if _, err := NewGRPCTranscodePlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewGRPCTranscodePlugin(%s) succeeded, want error", config)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Package builtin - Header Limits plugin for per-route header limits
+//
+// The gateway enforces MAX_HEADER_BYTES / MAX_HEADER_COUNT and the
+// duplicate header policy on every request. This plugin applies tighter
+// limits to a service or route, e.g. an internal API that never needs
+// large cookies.
+//
+// Configuration Example:
+//
+//	{
+//	  "max_bytes": 8192,
+//	  "max_count": 30,
+//	  "max_value_bytes": 4096,
+//	  "oversized": "strip",
+//	  "single_valued": ["Authorization", "X-Tenant-ID"],
+//	  "duplicates": "reject"
+//	}
+//
+// Rejected requests get 431 (too large / too many) or 400 (duplicate).
+// Stripped headers never reach the backend.
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/saidutt46/switchboard-gateway/internal/headerlimit"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// HeaderLimitsPlugin enforces request header limits for a route.
+type HeaderLimitsPlugin struct {
+	limits headerlimit.Config
+}
+
+// HeaderLimitsConfig holds configuration for the header limits plugin.
+type HeaderLimitsConfig struct {
+	// MaxBytes limits the total header size (0 = not enforced)
+	MaxBytes int `json:"max_bytes"`
+
+	// MaxCount limits the number of header lines (0 = not enforced)
+	MaxCount int `json:"max_count"`
+
+	// MaxValueBytes limits a single header value (0 = not enforced)
+	MaxValueBytes int `json:"max_value_bytes"`
+
+	// Oversized is "reject" or "strip" for values over MaxValueBytes
+	// Default: "reject"
+	Oversized string `json:"oversized"`
+
+	// SingleValued headers may appear once
+	// Default: Authorization, Content-Type, X-Forwarded-Host, ...
+	SingleValued []string `json:"single_valued"`
+
+	// Duplicates is "reject" or "strip" (keep the first value)
+	// Default: "reject"
+	Duplicates string `json:"duplicates"`
+}
+
+// NewHeaderLimitsPlugin creates a new header limits plugin.
+func NewHeaderLimitsPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := HeaderLimitsConfig{
+		Oversized:    headerlimit.Reject,
+		SingleValued: headerlimit.DefaultSingleValued,
+		Duplicates:   headerlimit.Reject,
+	}
+
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid header-limits config: %w", err)
+		}
+	}
+
+	limits := headerlimit.Config{
+		MaxBytes:      config.MaxBytes,
+		MaxCount:      config.MaxCount,
+		MaxValueBytes: config.MaxValueBytes,
+		Oversized:     config.Oversized,
+		SingleValued:  config.SingleValued,
+		Duplicates:    config.Duplicates,
+	}
+	if err := limits.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid header-limits config: %w", err)
+	}
+
+	return &HeaderLimitsPlugin{limits: limits}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *HeaderLimitsPlugin) Name() string {
+	return "header-limits"
+}
+
+// Execute checks the request headers before they are proxied.
+func (p *HeaderLimitsPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	stripped, err := headerlimit.Check(ctx.Request.Header, p.limits)
+	if err != nil {
+		ctx.Abort(headerlimit.Status(err), err.Error())
+		return nil
+	}
+	if len(stripped) > 0 {
+		ctx.Decide(fmt.Sprintf("stripped %v", stripped))
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package builtin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHeaderLimits_Execute(t *testing.T) {
+	// Each header below is counted as len(name) + len(value) + 4
+	const config = `{"max_bytes": 100, "max_count": 4, "max_value_bytes": 40}`
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int // 0 = allowed
+	}{
+		{name: "within limits", header: http.Header{"Accept": {"application/json"}, "X-Request-Id": {"r-1"}}},
+		{name: "at the count", header: http.Header{"A": {"1"}, "B": {"2"}, "C": {"3", "4"}}},
+		{name: "too many", header: http.Header{"A": {"1"}, "B": {"2"}, "C": {"3", "4"}, "D": {"5"}}, want: 431},
+		{name: "value at the limit", header: http.Header{"X-Trace": {strings.Repeat("t", 40)}}},
+		{name: "value too large", header: http.Header{"X-Trace": {strings.Repeat("t", 41)}}, want: 431},
+		{name: "total at the limit", header: http.Header{"X-A": {strings.Repeat("a", 39)}, "X-B": {strings.Repeat("b", 39)}, "X-C": {"c"}}},
+		{name: "total too large", header: http.Header{"X-A": {strings.Repeat("a", 39)}, "X-B": {strings.Repeat("b", 39)}, "X-C": {"cc"}}, want: 431},
+		{name: "duplicate", header: http.Header{"Authorization": {"Bearer a", "Bearer b"}}, want: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewHeaderLimitsPlugin(json.RawMessage(config))
+			if err != nil {
+				t.Fatalf("NewHeaderLimitsPlugin() error = %v", err)
+			}
+			r := httptest.NewRequest("GET", "/internal", nil)
+			r.Header = tt.header
+			ctx := newTestContext(r, "r-internal")
+			if err := p.Execute(ctx); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got := ctx.AbortStatusCode(); ctx.IsAborted() != (tt.want != 0) || got != tt.want {
+				t.Errorf("aborted = %v with %d (%s), want %d", ctx.IsAborted(), got, ctx.AbortMessage(), tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderLimits_Strip(t *testing.T) {
+	p, err := NewHeaderLimitsPlugin(json.RawMessage(`{"max_value_bytes": 8, "oversized": "strip", "duplicates": "strip"}`))
+	if err != nil {
+		t.Fatalf("NewHeaderLimitsPlugin() error = %v", err)
+	}
+	r := httptest.NewRequest("GET", "/internal", nil)
+	r.Header.Set("Cookie", "session=0123456789")
+	r.Header.Set("Accept", "*/*")
+	r.Header["Authorization"] = []string{"Bearer a", "Bearer b"}
+	ctx := newTestContext(r, "r-internal")
+	p.Execute(ctx)
+
+	if ctx.IsAborted() {
+		t.Fatalf("aborted with %d, want the headers stripped", ctx.AbortStatusCode())
+	}
+	h := ctx.Request.Header
+	if h.Get("Cookie") != "" || h.Get("Accept") != "*/*" {
+		t.Errorf("headers = %v, want only the oversized Cookie removed", h)
+	}
+	if got := h.Values("Authorization"); len(got) != 1 || got[0] != "Bearer a" {
+		t.Errorf("Authorization = %v, want the first value kept", got)
+	}
+}
+
+func TestHeaderLimits_Config(t *testing.T) {
+	for _, config := range []string{
+		`{"max_bytes": -1}`,
+		`{"oversized": "truncate"}`,
+		`{"duplicates": "merge"}`,
+	} {
+		if _, err := NewHeaderLimitsPlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewHeaderLimitsPlugin(%s) succeeded, want error", config)
+		}
+	}
+}
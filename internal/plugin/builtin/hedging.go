@@ -0,0 +1,98 @@
+// Package builtin - Hedged requests plugin
+//
+// The hedging plugin cuts tail latency on latency-sensitive routes: if the
+// upstream hasn't responded to an idempotent request (GET or HEAD without
+// a body) within a percentile of the route's recent latencies, the proxy
+// sends the same request to a second target and uses whichever responds
+// first, cancelling the other.
+//
+// Configuration Example:
+//
+//	{
+//	  "percentile": 0.95,
+//	  "min_delay_ms": 10,
+//	  "max_delay_ms": 1000
+//	}
+//
+// Hedging needs a service with several targets and a balancer that spreads
+// requests across them (not consistent-hash, which keeps sending a request
+// to the same target). The route only hedges once it has seen enough
+// requests to estimate the percentile. Hedges across all routes are capped
+// by the gateway's retry budget (HEDGE_BUDGET_RATIO,
+// HEDGE_BUDGET_MIN_PER_SECOND) so a struggling backend doesn't get twice
+// the traffic; see gateway_upstream_hedges_total.
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// HedgingPlugin marks a route's requests for hedging.
+type HedgingPlugin struct {
+	policy plugin.HedgePolicy
+}
+
+// HedgingConfig holds configuration for hedged requests.
+type HedgingConfig struct {
+	// Percentile of recent upstream latencies to wait before hedging
+	// Default: 0.95
+	Percentile float64 `json:"percentile"`
+
+	// MinDelayMs is the shortest wait before hedging
+	// Default: 10
+	MinDelayMs int `json:"min_delay_ms"`
+
+	// MaxDelayMs is the longest wait before hedging (0 = no limit)
+	// Default: 1000
+	MaxDelayMs int `json:"max_delay_ms"`
+}
+
+// NewHedgingPlugin creates a new hedging plugin.
+func NewHedgingPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := HedgingConfig{
+		Percentile: 0.95,
+		MinDelayMs: 10,
+		MaxDelayMs: 1000,
+	}
+
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid hedging config: %w", err)
+		}
+	}
+
+	if config.Percentile <= 0 || config.Percentile >= 1 {
+		return nil, fmt.Errorf("invalid hedging config: percentile must be between 0 and 1")
+	}
+	if config.MinDelayMs < 0 || config.MaxDelayMs < 0 {
+		return nil, fmt.Errorf("invalid hedging config: delays cannot be negative")
+	}
+	if config.MaxDelayMs > 0 && config.MinDelayMs > config.MaxDelayMs {
+		return nil, fmt.Errorf("invalid hedging config: min_delay_ms exceeds max_delay_ms")
+	}
+
+	return &HedgingPlugin{
+		policy: plugin.HedgePolicy{
+			Percentile: config.Percentile,
+			MinDelay:   time.Duration(config.MinDelayMs) * time.Millisecond,
+			MaxDelay:   time.Duration(config.MaxDelayMs) * time.Millisecond,
+		},
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *HedgingPlugin) Name() string {
+	return "hedging"
+}
+
+// Execute asks the proxy to hedge the request.
+func (p *HedgingPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase == plugin.PhaseBeforeRequest {
+		ctx.Hedge(p.policy)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package builtin
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+func TestHedging_Execute(t *testing.T) {
+	p, err := NewHedgingPlugin(json.RawMessage(`{"percentile": 0.99, "min_delay_ms": 5, "max_delay_ms": 200}`))
+	if err != nil {
+		t.Fatalf("NewHedgingPlugin() error = %v", err)
+	}
+
+	ctx := newTestContext(httptest.NewRequest("GET", "/search", nil), "r-search")
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	policy, ok := plugin.HedgePolicyFor(ctx.Request)
+	want := plugin.HedgePolicy{Percentile: 0.99, MinDelay: 5 * time.Millisecond, MaxDelay: 200 * time.Millisecond}
+	if !ok || policy != want {
+		t.Errorf("HedgePolicyFor() = %+v, %v; want %+v", policy, ok, want)
+	}
+
+	// Later phases don't ask again
+	ctx = newTestContext(httptest.NewRequest("GET", "/search", nil), "r-search")
+	ctx.Phase = plugin.PhaseAfterResponse
+	p.Execute(ctx)
+	if _, ok := plugin.HedgePolicyFor(ctx.Request); ok {
+		t.Error("hedge requested after the response")
+	}
+}
+
+func TestHedging_Config(t *testing.T) {
+	for _, config := range []string{
+		`{"percentile": 0}`,
+		`{"percentile": 1}`,
+		`{"min_delay_ms": -1}`,
+		`{"min_delay_ms": 500, "max_delay_ms": 100}`,
+	} {
+		if _, err := NewHedgingPlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewHedgingPlugin(%s) succeeded, want error", config)
+		}
+	}
+}
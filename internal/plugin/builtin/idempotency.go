@@ -0,0 +1,466 @@
+// Package builtin - Idempotency plugin for request deduplication
+//
+// This plugin makes unsafe requests (e.g., payment POSTs) safe to retry.
+// When a client sends an Idempotency-Key header, the first response for
+// that key is stored in Redis and replayed for any duplicate request.
+//
+// Features:
+//   - Replays stored status, headers and body for duplicate keys
+//   - 409 Conflict when a duplicate arrives while the original is in flight
+//   - 422 Unprocessable Entity when a key is reused with a different body
+//   - Keys scoped per route and per authenticated consumer (never by
+//     headers or client IP, which a caller can choose)
+//   - Request bodies hashed as a stream, up to max_request_body_bytes
+//   - 5xx responses are not stored, so failed requests can be retried
+//   - Distributed state using Redis
+//
+// Configuration Example:
+//
+//	{
+//	  "critical": false,
+//	  "header_name": "Idempotency-Key",
+//	  "methods": ["POST", "PATCH"],
+//	  "required": false,
+//	  "ttl": "24h",
+//	  "lock_ttl": "30s",
+//	  "max_body_bytes": 1048576,
+//	  "max_request_body_bytes": 10485760,
+//	  "scope": "consumer",
+//	  "redis_url": "redis://localhost:6379/0",
+//	  "key_prefix": "idempotency:"
+//	}
+//
+// The plugin should run after authentication (higher priority number) so
+// keys are scoped to the authenticated consumer. If a later plugin aborts
+// the request, the in-flight lock expires after lock_ttl.
+package builtin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/ratelimit"
+)
+
+// Idempotency record states.
+const (
+	idempotencyInFlight  = "in_flight"
+	idempotencyCompleted = "completed"
+)
+
+// Context metadata key holding the Redis key locked by this request.
+var idempotencyLockKey = plugin.RegisterKeyWithAlias[string]("idempotency.key", "idempotency_key")
+
+// ReplayedHeader is set on responses replayed from the idempotency store.
+const ReplayedHeader = "Idempotent-Replayed"
+
+// IdempotencyPlugin deduplicates requests carrying an idempotency key.
+type IdempotencyPlugin struct {
+	config  IdempotencyConfig
+	store   *ratelimit.RedisStore
+	ttl     time.Duration
+	lockTTL time.Duration
+	methods map[string]bool
+}
+
+// IdempotencyConfig holds configuration for the idempotency plugin.
+type IdempotencyConfig struct {
+	// Critical indicates if a Redis failure should stop the request
+	// Default: false (requests pass through without deduplication)
+	Critical bool `json:"critical"`
+
+	// HeaderName is the request header carrying the idempotency key
+	// Default: "Idempotency-Key"
+	HeaderName string `json:"header_name"`
+
+	// Methods are the HTTP methods the plugin applies to
+	// Default: ["POST", "PATCH"]
+	Methods []string `json:"methods"`
+
+	// Required rejects requests without a key with 400 Bad Request
+	// Default: false
+	Required bool `json:"required"`
+
+	// TTL is how long a completed response is replayed
+	// Default: "24h"
+	TTL string `json:"ttl"`
+
+	// LockTTL bounds how long a request is considered in flight
+	// Should exceed the route's upstream timeout
+	// Default: "30s"
+	LockTTL string `json:"lock_ttl"`
+
+	// MaxBodyBytes is the largest response body that will be stored
+	// Larger responses are passed through and not stored
+	// Default: 1048576 (1 MiB)
+	MaxBodyBytes int `json:"max_body_bytes"`
+
+	// MaxRequestBodyBytes is the largest request body fingerprinted
+	// Larger requests get 413 Payload Too Large
+	// Default: 10485760 (10 MiB)
+	MaxRequestBodyBytes int64 `json:"max_request_body_bytes"`
+
+	// Scope determines who shares a key namespace
+	// Options: "consumer" (per authenticated consumer; requests without
+	// one share the route's namespace; "auto" is accepted as an alias),
+	// "global"
+	// Default: "consumer"
+	Scope string `json:"scope"`
+
+	// RedisURL is the Redis connection string
+	// Default: "redis://localhost:6379/0"
+	RedisURL string `json:"redis_url"`
+
+	// KeyPrefix is prepended to all Redis keys
+	// Default: "idempotency:"
+	KeyPrefix string `json:"key_prefix"`
+}
+
+// DefaultIdempotencyConfig returns sensible defaults.
+func DefaultIdempotencyConfig() IdempotencyConfig {
+	return IdempotencyConfig{
+		Critical:            false,
+		HeaderName:          "Idempotency-Key",
+		Methods:             []string{"POST", "PATCH"},
+		Required:            false,
+		TTL:                 "24h",
+		LockTTL:             "30s",
+		MaxBodyBytes:        1 << 20,
+		MaxRequestBodyBytes: 10 << 20,
+		Scope:               "consumer",
+		RedisURL:            "redis://localhost:6379/0",
+		KeyPrefix:           "idempotency:",
+	}
+}
+
+// idempotencyRecord is the value stored in Redis for each key.
+type idempotencyRecord struct {
+	State       string      `json:"state"`
+	Fingerprint string      `json:"fingerprint"` // SHA-256 of method, path and request body
+	StatusCode  int         `json:"status_code,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+	BodyHash    string      `json:"body_hash,omitempty"` // SHA-256 of Body, checked on replay
+}
+
+// NewIdempotencyPlugin creates a new idempotency plugin.
+//
+// This is the factory function registered with the plugin registry.
+func NewIdempotencyPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := DefaultIdempotencyConfig()
+
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid idempotency config: %w", err)
+		}
+	}
+
+	ttl, err := parseWindowDuration(config.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ttl: %w", err)
+	}
+	lockTTL, err := parseWindowDuration(config.LockTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lock_ttl: %w", err)
+	}
+	if config.HeaderName == "" {
+		return nil, fmt.Errorf("header_name is required")
+	}
+	if len(config.Methods) == 0 {
+		return nil, fmt.Errorf("at least one method is required")
+	}
+	if config.MaxBodyBytes <= 0 {
+		return nil, fmt.Errorf("max_body_bytes must be positive")
+	}
+	if config.MaxRequestBodyBytes <= 0 {
+		return nil, fmt.Errorf("max_request_body_bytes must be positive")
+	}
+	switch config.Scope {
+	case "consumer", "global":
+	case "auto":
+		config.Scope = "consumer"
+	default:
+		return nil, fmt.Errorf("invalid scope '%s' (must be consumer or global)", config.Scope)
+	}
+
+	methods := make(map[string]bool, len(config.Methods))
+	for _, m := range config.Methods {
+		methods[strings.ToUpper(m)] = true
+	}
+
+	log.Info().
+		Str("component", "plugin").
+		Str("plugin", "idempotency").
+		Str("header", config.HeaderName).
+		Strs("methods", config.Methods).
+		Dur("ttl", ttl).
+		Str("scope", config.Scope).
+		Msg("Initializing idempotency plugin")
+
+	redisConfig := ratelimit.DefaultRedisConfig()
+	redisConfig.URL = config.RedisURL
+	store, err := ratelimit.NewRedisStore(redisConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create redis store: %w", err)
+	}
+
+	return &IdempotencyPlugin{
+		config:  config,
+		store:   store,
+		ttl:     ttl,
+		lockTTL: lockTTL,
+		methods: methods,
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *IdempotencyPlugin) Name() string {
+	return "idempotency"
+}
+
+// Execute runs the idempotency plugin.
+//
+// BeforeRequest: acquire the key or replay/reject duplicates.
+// AfterResponse: store the response (or release the key on 5xx).
+func (p *IdempotencyPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase == plugin.PhaseAfterResponse {
+		return p.storeResponse(ctx)
+	}
+
+	if !p.methods[ctx.Request.Method] {
+		return nil
+	}
+
+	idempotencyKey := ctx.Request.Header.Get(p.config.HeaderName)
+	if idempotencyKey == "" {
+		if p.config.Required {
+			ctx.Abort(http.StatusBadRequest, fmt.Sprintf("%s header is required", p.config.HeaderName))
+		}
+		return nil
+	}
+
+	body, err := bodybuffer.ReadRequestSpillable(ctx.Request, p.config.MaxRequestBodyBytes)
+	if err != nil {
+		ctx.Abort(http.StatusBadRequest, "Failed to read request body")
+		return nil
+	}
+	if body.OverBudget() {
+		abortBodyOverBudget(ctx)
+		return nil
+	}
+	if body.TooLarge() {
+		ctx.Abort(http.StatusRequestEntityTooLarge, "Request body too large")
+		return nil
+	}
+
+	fingerprint, err := requestFingerprint(ctx.Request, body)
+	if err != nil {
+		return p.handleError(ctx, err)
+	}
+
+	key := p.redisKey(ctx, idempotencyKey)
+
+	lock, err := json.Marshal(idempotencyRecord{State: idempotencyInFlight, Fingerprint: fingerprint})
+	if err != nil {
+		return p.handleError(ctx, err)
+	}
+
+	acquired, err := p.store.SetNX(ctx.Context(), key, lock, p.lockTTL)
+	if err != nil {
+		return p.handleError(ctx, err)
+	}
+
+	if acquired {
+		// First request for this key - proxy it and capture the response
+		idempotencyLockKey.Set(ctx, key)
+		ctx.Response.EnableCapture(p.config.MaxBodyBytes)
+		return nil
+	}
+
+	raw, err := p.store.Get(ctx.Context(), key)
+	if err != nil {
+		return p.handleError(ctx, err)
+	}
+	if raw == "" {
+		// Record expired between SETNX and GET - treat as in flight
+		ctx.Abort(http.StatusConflict, "A request with this idempotency key is already in progress")
+		return nil
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal([]byte(raw), &record); err != nil {
+		return p.handleError(ctx, fmt.Errorf("corrupt idempotency record: %w", err))
+	}
+
+	if record.Fingerprint != fingerprint {
+		ctx.Abort(http.StatusUnprocessableEntity, "Idempotency key was already used with a different request")
+		return nil
+	}
+
+	if record.State == idempotencyInFlight {
+		log.Debug().
+			Str("component", "plugin").
+			Str("plugin", "idempotency").
+			Str("route_id", ctx.Route.ID).
+			Msg("Duplicate request while original is in flight")
+
+		ctx.Response.Header().Set("Retry-After", "1")
+		ctx.Abort(http.StatusConflict, "A request with this idempotency key is already in progress")
+		return nil
+	}
+
+	return p.replay(ctx, &record)
+}
+
+// replay writes a stored response to the client and aborts the chain.
+func (p *IdempotencyPlugin) replay(ctx *plugin.Context, record *idempotencyRecord) error {
+	sum := sha256.Sum256(record.Body)
+	if hex.EncodeToString(sum[:]) != record.BodyHash {
+		return p.handleError(ctx, fmt.Errorf("idempotency record body hash mismatch"))
+	}
+
+	header := ctx.Response.Header()
+	for name, values := range record.Header {
+		header[name] = values
+	}
+	header.Set(ReplayedHeader, "true")
+
+	ctx.Response.WriteHeader(record.StatusCode)
+	ctx.Response.Write(record.Body)
+
+	log.Info().
+		Str("component", "plugin").
+		Str("plugin", "idempotency").
+		Str("route_id", ctx.Route.ID).
+		Int("status_code", record.StatusCode).
+		Msg("Replayed stored response for idempotency key")
+
+	ctx.Abort(record.StatusCode, "")
+	return nil
+}
+
+// storeResponse saves the response for the key acquired in BeforeRequest.
+func (p *IdempotencyPlugin) storeResponse(ctx *plugin.Context) error {
+	key := idempotencyLockKey.Value(ctx)
+	if key == "" {
+		return nil
+	}
+
+	status := ctx.Response.StatusCode()
+	body, complete := ctx.Response.CapturedBody()
+
+	// Let clients retry failures and oversized responses
+	if status >= 500 || !complete {
+		if err := p.store.Del(ctx.Context(), key); err != nil {
+			return fmt.Errorf("failed to release idempotency key: %w", err)
+		}
+		return nil
+	}
+
+	raw, err := p.store.Get(ctx.Context(), key)
+	if err != nil {
+		return fmt.Errorf("failed to read idempotency lock: %w", err)
+	}
+	var lock idempotencyRecord
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &lock); err != nil {
+			return fmt.Errorf("corrupt idempotency lock: %w", err)
+		}
+	}
+
+	sum := sha256.Sum256(body)
+	record := idempotencyRecord{
+		State:       idempotencyCompleted,
+		Fingerprint: lock.Fingerprint,
+		StatusCode:  status,
+		Header:      storableHeaders(ctx.Response.Header()),
+		Body:        body,
+		BodyHash:    hex.EncodeToString(sum[:]),
+	}
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotency record: %w", err)
+	}
+	if err := p.store.Set(ctx.Context(), key, value, p.ttl); err != nil {
+		return fmt.Errorf("failed to store idempotency record: %w", err)
+	}
+
+	log.Debug().
+		Str("component", "plugin").
+		Str("plugin", "idempotency").
+		Str("route_id", ctx.Route.ID).
+		Int("status_code", status).
+		Int("body_bytes", len(body)).
+		Msg("Stored response for idempotency key")
+
+	return nil
+}
+
+// requestFingerprint hashes the request method, path and buffered body so a key
+// reused for a different request can be detected.
+func requestFingerprint(r *http.Request, body *bodybuffer.Body) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	if _, err := io.Copy(h, body.Reader()); err != nil {
+		return "", fmt.Errorf("failed to hash request body: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// redisKey builds the Redis key: prefix + route + scope + hashed key.
+// Only the authenticated consumer narrows the scope.
+func (p *IdempotencyPlugin) redisKey(ctx *plugin.Context, idempotencyKey string) string {
+	client := "global"
+	if consumerID := plugin.KeyConsumerID.Value(ctx); p.config.Scope == "consumer" && consumerID != "" {
+		client = "consumer:" + consumerID
+	}
+
+	sum := sha256.Sum256([]byte(idempotencyKey))
+	return p.config.KeyPrefix + ctx.Route.ID + ":" + client + ":" + hex.EncodeToString(sum[:16])
+}
+
+// storableHeaders copies response headers worth replaying.
+//
+// Hop-by-hop and per-response headers are dropped.
+func storableHeaders(header http.Header) http.Header {
+	stored := make(http.Header, len(header))
+	for name, values := range header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Connection", "Keep-Alive", "Transfer-Encoding", "Upgrade",
+			"Date", "Content-Length", "Retry-After", ReplayedHeader:
+			continue
+		}
+		stored[name] = append([]string(nil), values...)
+	}
+	return stored
+}
+
+// handleError handles Redis/store errors.
+//
+// If critical=false (default), the request proceeds without deduplication.
+// If critical=true, the request is rejected with 503.
+func (p *IdempotencyPlugin) handleError(ctx *plugin.Context, err error) error {
+	log.Error().
+		Err(err).
+		Str("component", "plugin").
+		Str("plugin", "idempotency").
+		Bool("critical", p.config.Critical).
+		Msg("Idempotency check failed")
+
+	if p.config.Critical {
+		ctx.Abort(http.StatusServiceUnavailable, "Idempotency service unavailable")
+		return fmt.Errorf("idempotency check failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package builtin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// newTestIdempotencyPlugin builds the plugin without connecting to Redis,
+// for the checks that run before the key lookup.
+func newTestIdempotencyPlugin(scope string) *IdempotencyPlugin {
+	config := DefaultIdempotencyConfig()
+	config.Scope = scope
+	config.MaxRequestBodyBytes = 16
+	return &IdempotencyPlugin{config: config, methods: map[string]bool{"POST": true}}
+}
+
+func TestIdempotency_RequestBodyTooLarge(t *testing.T) {
+	r := httptest.NewRequest("POST", "/payments", strings.NewReader(strings.Repeat("x", 17)))
+	r.Header.Set("Idempotency-Key", "k1")
+	ctx := newTestContext(r, "r-pay")
+
+	if err := newTestIdempotencyPlugin("consumer").Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if ctx.AbortStatusCode() != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413", ctx.AbortStatusCode())
+	}
+}
+
+func TestIdempotency_Fingerprint(t *testing.T) {
+	fingerprint := func(method, path, body string) string {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		buffered, err := bodybuffer.ReadRequestSpillable(r, 1<<10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := requestFingerprint(r, buffered)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	original := fingerprint("POST", "/payments", `{"amount":10}`)
+	if fingerprint("POST", "/payments", `{"amount":10}`) != original {
+		t.Error("identical requests got different fingerprints")
+	}
+	for _, changed := range []string{
+		fingerprint("POST", "/payments", `{"amount":99}`),
+		fingerprint("PATCH", "/payments", `{"amount":10}`),
+		fingerprint("POST", "/refunds", `{"amount":10}`),
+	} {
+		if changed == original {
+			t.Error("a different request got the same fingerprint")
+		}
+	}
+}
+
+func TestIdempotency_RedisKey(t *testing.T) {
+	key := func(p *IdempotencyPlugin, apiKey, remoteAddr, consumerID string) string {
+		r := httptest.NewRequest("POST", "/payments", nil)
+		r.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			r.Header.Set("X-API-Key", apiKey)
+		}
+		ctx := newTestContext(r, "r-pay")
+		if consumerID != "" {
+			plugin.KeyConsumerID.Set(ctx, consumerID)
+		}
+		return p.redisKey(ctx, "k1")
+	}
+
+	p := newTestIdempotencyPlugin("consumer")
+	if key(p, "key-a", "192.0.2.1:1234", "") != key(p, "key-b", "198.51.100.7:4321", "") {
+		t.Error("X-API-Key or client IP changed the key namespace")
+	}
+	if key(p, "", "192.0.2.1:1234", "c1") == key(p, "", "192.0.2.1:1234", "c2") {
+		t.Error("consumers share a key namespace")
+	}
+
+	global := newTestIdempotencyPlugin("global")
+	if key(global, "", "192.0.2.1:1234", "c1") != key(global, "", "192.0.2.1:1234", "c2") {
+		t.Error("scope global: consumers got separate namespaces")
+	}
+}
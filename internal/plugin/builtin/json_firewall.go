@@ -0,0 +1,276 @@
+// Package builtin - JSON body firewall plugin
+//
+// The json-firewall plugin enforces structural limits on JSON request
+// bodies, so malformed or abusive payloads (deeply nested documents,
+// huge arrays, prototype pollution keys) are rejected before they reach
+// backends, without an OpenAPI schema:
+//   - max_depth: nesting of objects and arrays
+//   - max_array_length: elements in any one array
+//   - max_object_keys: members in any one object
+//   - max_string_length: bytes in any string, keys included
+//   - disallowed_keys: object keys rejected at any depth
+//
+// The body is checked with a streaming tokenizer, so limits are enforced
+// without building the document in memory. Bodies that aren't
+// application/json (or +json) are proxied unchecked, unless
+// require_json is set.
+//
+// Configuration Example:
+//
+//	{
+//	  "max_body_bytes": 1048576,
+//	  "max_depth": 20,
+//	  "max_array_length": 1000,
+//	  "max_object_keys": 1000,
+//	  "max_string_length": 65536,
+//	  "disallowed_keys": ["__proto__", "constructor", "prototype"],
+//	  "require_json": false
+//	}
+//
+// Violations get 400 with the reason (413 for bodies over max_body_bytes,
+// 415 for non-JSON bodies with require_json), and are counted in
+// gateway_plugin_json_firewall_checks_total{route,result}.
+package builtin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// jsonViolation is a payload that breaks a limit. result labels the
+// metric; the error message is sent to the client.
+type jsonViolation struct {
+	result  string
+	message string
+}
+
+func (v *jsonViolation) Error() string {
+	return v.message
+}
+
+// JSONFirewallPlugin enforces structural limits on JSON bodies.
+type JSONFirewallPlugin struct {
+	config     JSONFirewallConfig
+	disallowed map[string]bool
+	checks     *metrics.CounterVec
+}
+
+// JSONFirewallConfig holds configuration for the JSON firewall plugin.
+// Zero limits are not enforced.
+type JSONFirewallConfig struct {
+	// MaxBodyBytes is the largest body accepted
+	// Default: 1048576 (1 MiB)
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+
+	// MaxDepth limits nesting of objects and arrays
+	// Default: 20
+	MaxDepth int `json:"max_depth"`
+
+	// MaxArrayLength limits the elements of any array
+	// Default: 1000
+	MaxArrayLength int `json:"max_array_length"`
+
+	// MaxObjectKeys limits the members of any object
+	// Default: 1000
+	MaxObjectKeys int `json:"max_object_keys"`
+
+	// MaxStringLength limits the bytes of any string or key
+	// Default: 65536
+	MaxStringLength int `json:"max_string_length"`
+
+	// DisallowedKeys are object keys rejected at any depth
+	// Default: ["__proto__", "constructor", "prototype"]
+	DisallowedKeys []string `json:"disallowed_keys"`
+
+	// RequireJSON rejects bodies that aren't JSON with 415
+	// Default: false (proxied unchecked)
+	RequireJSON bool `json:"require_json"`
+}
+
+// NewJSONFirewallPlugin creates a new JSON firewall plugin.
+func NewJSONFirewallPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := JSONFirewallConfig{
+		MaxBodyBytes:    1 << 20,
+		MaxDepth:        20,
+		MaxArrayLength:  1000,
+		MaxObjectKeys:   1000,
+		MaxStringLength: 64 << 10,
+		DisallowedKeys:  []string{"__proto__", "constructor", "prototype"},
+	}
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid json-firewall config: %w", err)
+		}
+	}
+
+	if config.MaxBodyBytes <= 0 {
+		return nil, fmt.Errorf("invalid json-firewall config: max_body_bytes must be positive")
+	}
+	if config.MaxDepth < 0 || config.MaxArrayLength < 0 || config.MaxObjectKeys < 0 || config.MaxStringLength < 0 {
+		return nil, fmt.Errorf("invalid json-firewall config: limits cannot be negative")
+	}
+
+	disallowed := make(map[string]bool, len(config.DisallowedKeys))
+	for _, key := range config.DisallowedKeys {
+		if key == "" {
+			return nil, fmt.Errorf("invalid json-firewall config: empty disallowed key")
+		}
+		disallowed[key] = true
+	}
+
+	return &JSONFirewallPlugin{
+		config:     config,
+		disallowed: disallowed,
+		checks: plugin.NewMetrics("json-firewall").Counter(
+			"checks_total",
+			"JSON body checks, by route and result (allowed, skipped, too_large, over_budget, not_json, malformed, depth, array_length, object_keys, string_length, disallowed_key).",
+			"route", "result",
+		),
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *JSONFirewallPlugin) Name() string {
+	return "json-firewall"
+}
+
+// Execute checks the request body before it is proxied.
+func (p *JSONFirewallPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+
+	r := ctx.Request
+	if r.Body == nil || r.Body == http.NoBody {
+		p.checks.Inc(routeID, "skipped")
+		return nil
+	}
+	if !isJSONContent(r.Header) {
+		if p.config.RequireJSON {
+			p.checks.Inc(routeID, "not_json")
+			ctx.Abort(http.StatusUnsupportedMediaType, "Request body must be JSON")
+			return nil
+		}
+		p.checks.Inc(routeID, "skipped")
+		return nil
+	}
+
+	body, err := bodybuffer.ReadRequest(r, p.config.MaxBodyBytes)
+	if err != nil {
+		ctx.Abort(http.StatusBadRequest, "Failed to read request body")
+		return nil
+	}
+	if body.OverBudget() {
+		p.checks.Inc(routeID, "over_budget")
+		abortBodyOverBudget(ctx)
+		return nil
+	}
+	if body.TooLarge() {
+		p.checks.Inc(routeID, "too_large")
+		ctx.Abort(http.StatusRequestEntityTooLarge, "Request body too large")
+		return nil
+	}
+
+	if err := p.check(body.Bytes()); err != nil {
+		var violation *jsonViolation
+		if !errors.As(err, &violation) {
+			violation = &jsonViolation{result: "malformed", message: "Malformed JSON body"}
+		}
+		p.checks.Inc(routeID, violation.result)
+		ctx.Decide("json body rejected: " + violation.result)
+		ctx.Abort(http.StatusBadRequest, violation.message)
+		return nil
+	}
+
+	p.checks.Inc(routeID, "allowed")
+	return nil
+}
+
+// jsonFrame is an open object or array.
+type jsonFrame struct {
+	object bool
+	count  int  // members or elements so far
+	key    bool // object: the next string token is a key
+}
+
+// check walks the document's tokens and enforces the limits.
+func (p *JSONFirewallPlugin) check(body []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var stack []jsonFrame
+	values := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// A key, or a value in the enclosing container
+		isKey := false
+		if n := len(stack); n > 0 {
+			top := &stack[n-1]
+			if delim, ok := tok.(json.Delim); !ok || (delim != '}' && delim != ']') {
+				switch {
+				case top.object && top.key:
+					isKey = true
+					top.key = false
+					top.count++
+					if p.config.MaxObjectKeys > 0 && top.count > p.config.MaxObjectKeys {
+						return &jsonViolation{"object_keys", fmt.Sprintf("Object has more than %d keys", p.config.MaxObjectKeys)}
+					}
+				case top.object:
+					top.key = true // value done after this token (or its container)
+				default:
+					top.count++
+					if p.config.MaxArrayLength > 0 && top.count > p.config.MaxArrayLength {
+						return &jsonViolation{"array_length", fmt.Sprintf("Array has more than %d elements", p.config.MaxArrayLength)}
+					}
+				}
+			}
+		} else {
+			values++
+		}
+
+		switch v := tok.(type) {
+		case json.Delim:
+			switch v {
+			case '{', '[':
+				stack = append(stack, jsonFrame{object: v == '{', key: v == '{'})
+				if p.config.MaxDepth > 0 && len(stack) > p.config.MaxDepth {
+					return &jsonViolation{"depth", fmt.Sprintf("JSON nested deeper than %d levels", p.config.MaxDepth)}
+				}
+			default:
+				stack = stack[:len(stack)-1]
+			}
+		case string:
+			if p.config.MaxStringLength > 0 && len(v) > p.config.MaxStringLength {
+				return &jsonViolation{"string_length", fmt.Sprintf("String longer than %d bytes", p.config.MaxStringLength)}
+			}
+			if isKey && p.disallowed[v] {
+				return &jsonViolation{"disallowed_key", fmt.Sprintf("Key %q is not allowed", v)}
+			}
+		}
+	}
+
+	if values != 1 || len(stack) > 0 {
+		return errors.New("expected exactly one complete JSON value")
+	}
+	return nil
+}
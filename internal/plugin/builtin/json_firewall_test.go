@@ -0,0 +1,78 @@
+package builtin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSONFirewall_Limits(t *testing.T) {
+	config := `{"max_body_bytes": 256, "max_depth": 3, "max_array_length": 3, "max_object_keys": 3, "max_string_length": 9, "require_json": true}`
+	p, err := NewJSONFirewallPlugin(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewJSONFirewallPlugin() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int // 0 = allowed
+	}{
+		{name: "within limits", body: `{"a": [1, 2, 3], "b": {"c": "short"}}`},
+		{name: "json suffix", contentType: "application/vnd.api+json", body: `{"a": 1}`},
+		{name: "at max depth", body: `{"a": {"b": [1]}}`},
+		{name: "too deep", body: `{"a": {"b": {"c": [1]}}}`, want: http.StatusBadRequest},
+		{name: "array too long", body: `[1, 2, 3, 4]`, want: http.StatusBadRequest},
+		{name: "too many keys", body: `{"a": 1, "b": 2, "c": 3, "d": 4}`, want: http.StatusBadRequest},
+		{name: "string too long", body: `{"a": "1234567890"}`, want: http.StatusBadRequest},
+		{name: "key too long", body: `{"1234567890": 1}`, want: http.StatusBadRequest},
+		{name: "prototype pollution", body: `{"a": {"__proto__": {"admin": true}}}`, want: http.StatusBadRequest},
+		{name: "disallowed name as a value", body: `{"a": "__proto__"}`},
+		{name: "malformed", body: `{"a": }`, want: http.StatusBadRequest},
+		{name: "trailing value", body: `{"a": 1} {"b": 2}`, want: http.StatusBadRequest},
+		{name: "too large", body: `"` + strings.Repeat("x", 300) + `"`, want: http.StatusRequestEntityTooLarge},
+		{name: "not json", contentType: "text/plain", body: "hello", want: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/orders", strings.NewReader(tt.body))
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			r.Header.Set("Content-Type", contentType)
+			ctx := newTestContext(r, "r-orders")
+
+			if err := p.Execute(ctx); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got := ctx.AbortStatusCode(); ctx.IsAborted() != (tt.want != 0) || got != tt.want {
+				t.Fatalf("aborted = %v with %d %q, want %d", ctx.IsAborted(), got, ctx.AbortMessage(), tt.want)
+			}
+			if tt.want == 0 {
+				if sent, _ := io.ReadAll(ctx.Request.Body); string(sent) != tt.body {
+					t.Errorf("proxied body = %q, want the original", sent)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONFirewall_NonJSONPassesWithoutRequireJSON(t *testing.T) {
+	p, err := NewJSONFirewallPlugin(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("POST", "/upload", strings.NewReader("{not json"))
+	r.Header.Set("Content-Type", "text/plain")
+	ctx := newTestContext(r, "r-upload")
+
+	if err := p.Execute(ctx); err != nil || ctx.IsAborted() {
+		t.Errorf("Execute() = %v, aborted %v; want non-JSON bodies proxied unchecked", err, ctx.IsAborted())
+	}
+}
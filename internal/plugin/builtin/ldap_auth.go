@@ -0,0 +1,565 @@
+// Package builtin - LDAP / Active Directory authentication plugin
+//
+// This plugin authenticates HTTP Basic credentials by binding to an LDAP
+// server as the user. It is intended for internal, admin-facing routes.
+//
+// Features:
+//   - Direct bind (bind_dn_template) or search-then-bind with a service
+//     account (bind_dn + user_filter), which Active Directory usually needs
+//   - ldaps://, StartTLS and custom CA certificates
+//   - Pooled connections
+//   - Group membership (memberOf) exposed as context metadata, with an
+//     optional allow-list of groups
+//   - Successful binds cached in memory for cache_ttl
+//
+// Configuration Example (OpenLDAP, direct bind):
+//
+//	{
+//	  "url": "ldaps://ldap.internal:636",
+//	  "bind_dn_template": "uid={username},ou=people,dc=example,dc=com",
+//	  "allowed_groups": ["gateway-admins"],
+//	  "cache_ttl": "5m"
+//	}
+//
+// Configuration Example (Active Directory, search then bind):
+//
+//	{
+//	  "url": "ldap://dc1.corp.example.com",
+//	  "start_tls": true,
+//	  "bind_dn": "CN=svc-gateway,OU=Service Accounts,DC=corp,DC=example,DC=com",
+//	  "bind_password": "...",
+//	  "base_dn": "DC=corp,DC=example,DC=com",
+//	  "user_filter": "(sAMAccountName={username})"
+//	}
+//
+// On success the plugin sets plugin.KeyConsumerID and
+// plugin.KeyConsumerUsername (the username), "ldap-auth.dn", and
+// "ldap-auth.groups" (group CNs) in the context metadata. A revoked user or changed password keeps working for up to
+// cache_ttl.
+package builtin
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/saidutt46/switchboard-gateway/internal/ldap"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// Context metadata keys set by the ldap-auth plugin.
+var (
+	ldapDNKey     = plugin.RegisterKeyWithAlias[string]("ldap-auth.dn", "ldap_dn")
+	ldapGroupsKey = plugin.RegisterKeyWithAlias[[]string]("ldap-auth.groups", "ldap_groups")
+)
+
+// maxLDAPCacheEntries bounds the bind cache; it is cleared when full.
+const maxLDAPCacheEntries = 10000
+
+// LDAPAuthPlugin authenticates Basic credentials against LDAP.
+type LDAPAuthPlugin struct {
+	config    LDAPAuthConfig
+	pool      *ldap.Pool
+	timeout   time.Duration
+	cacheTTL  time.Duration
+	allowed   map[string]bool
+	cacheSalt []byte // keys the cache so it never holds plain password hashes
+	failures  *metrics.CounterVec
+
+	mu    sync.Mutex
+	cache map[string]ldapIdentity
+}
+
+// LDAPAuthConfig holds configuration for the LDAP auth plugin.
+type LDAPAuthConfig struct {
+	// URL is the server, ldap://host[:389] or ldaps://host[:636]
+	// Required
+	URL string `json:"url"`
+
+	// StartTLS upgrades ldap:// connections to TLS
+	// Default: false
+	StartTLS bool `json:"start_tls"`
+
+	// CACert is a PEM CA bundle for verifying the server (default: system roots)
+	CACert string `json:"ca_cert"`
+
+	// InsecureSkipVerify disables server certificate verification (testing only)
+	// Default: false
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+
+	// BindDNTemplate is the user's DN (or AD UPN) with {username} replaced
+	// Used for direct bind when BindDN is empty
+	BindDNTemplate string `json:"bind_dn_template"`
+
+	// BindDN and BindPassword are a service account used to search for
+	// the user's DN before binding as the user
+	BindDN       string `json:"bind_dn"`
+	BindPassword string `json:"bind_password"`
+
+	// BaseDN is where users are searched for
+	BaseDN string `json:"base_dn"`
+
+	// UserFilter finds the user, with {username} replaced (escaped)
+	// Default: "(uid={username})"
+	UserFilter string `json:"user_filter"`
+
+	// GroupAttribute lists the user's groups ("" disables group lookup)
+	// Default: "memberOf"
+	GroupAttribute string `json:"group_attribute"`
+
+	// AllowedGroups, when set, requires membership of one of these groups
+	// (matched case-insensitively against group CNs); others get 403
+	// Default: [] (any authenticated user)
+	AllowedGroups []string `json:"allowed_groups"`
+
+	// CacheTTL is how long a successful bind is cached ("0s" disables)
+	// Default: "5m"
+	CacheTTL string `json:"cache_ttl"`
+
+	// Timeout bounds connecting and each LDAP operation
+	// Default: "5s"
+	Timeout string `json:"timeout"`
+
+	// PoolSize is the maximum number of open connections
+	// Default: 10
+	PoolSize int `json:"pool_size"`
+
+	// IdleTimeout closes pooled connections unused for this long
+	// Default: "60s"
+	IdleTimeout string `json:"idle_timeout"`
+
+	// HeaderName is the request header carrying "Basic <credentials>"
+	// Default: "Authorization"
+	HeaderName string `json:"header_name"`
+
+	// HideCredentials removes the credentials header before proxying
+	// Default: true
+	HideCredentials bool `json:"hide_credentials"`
+
+	// Realm is sent in the WWW-Authenticate challenge
+	// Default: "switchboard"
+	Realm string `json:"realm"`
+}
+
+// DefaultLDAPAuthConfig returns sensible defaults.
+func DefaultLDAPAuthConfig() LDAPAuthConfig {
+	return LDAPAuthConfig{
+		UserFilter:      "(uid={username})",
+		GroupAttribute:  "memberOf",
+		CacheTTL:        "5m",
+		Timeout:         "5s",
+		PoolSize:        10,
+		IdleTimeout:     "60s",
+		HeaderName:      "Authorization",
+		HideCredentials: true,
+		Realm:           "switchboard",
+	}
+}
+
+// ldapIdentity is an authenticated user, as cached.
+type ldapIdentity struct {
+	dn      string
+	groups  []string
+	expires time.Time
+}
+
+// NewLDAPAuthPlugin creates a new LDAP auth plugin.
+//
+// This is the factory function registered with the plugin registry.
+func NewLDAPAuthPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := DefaultLDAPAuthConfig()
+
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid ldap-auth config: %w", err)
+		}
+	}
+
+	if config.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	if config.BindDN == "" && config.BindDNTemplate == "" {
+		return nil, fmt.Errorf("either bind_dn_template or bind_dn is required")
+	}
+	if config.BindDNTemplate != "" && !strings.Contains(config.BindDNTemplate, "{username}") {
+		return nil, fmt.Errorf("bind_dn_template must contain {username}")
+	}
+	if config.BindDN != "" && (config.BaseDN == "" || config.UserFilter == "") {
+		return nil, fmt.Errorf("base_dn and user_filter are required with bind_dn")
+	}
+	if config.UserFilter != "" && !strings.Contains(config.UserFilter, "{username}") {
+		return nil, fmt.Errorf("user_filter must contain {username}")
+	}
+	if len(config.AllowedGroups) > 0 && config.GroupAttribute == "" {
+		return nil, fmt.Errorf("allowed_groups requires group_attribute")
+	}
+	if config.PoolSize <= 0 {
+		return nil, fmt.Errorf("pool_size must be positive")
+	}
+	if config.HeaderName == "" {
+		return nil, fmt.Errorf("header_name is required")
+	}
+
+	timeout, err := parseWindowDuration(config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout: %w", err)
+	}
+	idleTimeout, err := parseWindowDuration(config.IdleTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid idle_timeout: %w", err)
+	}
+	cacheTTL, err := time.ParseDuration(config.CacheTTL)
+	if err != nil || cacheTTL < 0 {
+		return nil, fmt.Errorf("invalid cache_ttl '%s'", config.CacheTTL)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CACert != "" {
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM([]byte(config.CACert)) {
+			return nil, fmt.Errorf("ca_cert contains no PEM certificates")
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	// Catch filter typos at load time rather than on the first request
+	if config.UserFilter != "" {
+		filter, _ := ldapFilter(config.UserFilter, "probe")
+		if err := ldap.ValidateFilter(filter); err != nil {
+			return nil, fmt.Errorf("invalid user_filter: %w", err)
+		}
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate cache key: %w", err)
+	}
+
+	allowed := make(map[string]bool, len(config.AllowedGroups))
+	for _, group := range config.AllowedGroups {
+		allowed[strings.ToLower(group)] = true
+	}
+
+	dialConfig := ldap.DialConfig{
+		URL:       config.URL,
+		StartTLS:  config.StartTLS,
+		TLSConfig: tlsConfig,
+		Timeout:   timeout,
+	}
+
+	log.Info().
+		Str("component", "plugin").
+		Str("plugin", "ldap-auth").
+		Str("url", config.URL).
+		Bool("search_bind", config.BindDN != "").
+		Int("pool_size", config.PoolSize).
+		Dur("cache_ttl", cacheTTL).
+		Msg("Initializing LDAP auth plugin")
+
+	return &LDAPAuthPlugin{
+		config:    config,
+		pool:      ldap.NewPool(dialConfig, config.PoolSize, idleTimeout),
+		timeout:   timeout,
+		cacheTTL:  cacheTTL,
+		allowed:   allowed,
+		cacheSalt: salt,
+		cache:     make(map[string]ldapIdentity),
+		failures: plugin.NewMetrics("ldap-auth").Counter(
+			"failures_total",
+			"Rejected LDAP authentications by reason",
+			"route", "reason",
+		),
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *LDAPAuthPlugin) Name() string {
+	return "ldap-auth"
+}
+
+// Close closes the connection pool. The registry calls it once a reload
+// has replaced the instance; connections still bound are closed when
+// their request returns them.
+func (p *LDAPAuthPlugin) Close() error {
+	p.pool.Close()
+	return nil
+}
+
+// Execute runs the LDAP auth plugin.
+//
+// BeforeRequest: authenticate the Basic credentials.
+// AfterResponse: nothing.
+func (p *LDAPAuthPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase == plugin.PhaseAfterResponse {
+		return nil
+	}
+
+	username, password, ok := basicCredentials(ctx.Request, p.config.HeaderName)
+	if !ok || username == "" || password == "" {
+		p.failures.Inc(ctx.Route.ID, "missing_credentials")
+		p.challenge(ctx, "Missing credentials")
+		return nil
+	}
+
+	identity, err := p.authenticate(ctx.Context(), username, password)
+	if ldap.IsResultCode(err, ldap.ResultInvalidCredentials) || errors.Is(err, errLDAPUserNotFound) {
+		log.Info().
+			Str("component", "plugin").
+			Str("plugin", "ldap-auth").
+			Str("username", username).
+			Msg("LDAP authentication failed")
+		p.failures.Inc(ctx.Route.ID, "invalid_credentials")
+		p.challenge(ctx, "Invalid credentials")
+		return nil
+	}
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("component", "plugin").
+			Str("plugin", "ldap-auth").
+			Str("url", p.config.URL).
+			Msg("LDAP authentication error")
+		p.failures.Inc(ctx.Route.ID, "unavailable")
+		ctx.Abort(http.StatusServiceUnavailable, "Authentication service unavailable")
+		return fmt.Errorf("ldap authentication failed: %w", err)
+	}
+
+	if len(p.allowed) > 0 && !p.memberOfAllowed(identity.groups) {
+		p.failures.Inc(ctx.Route.ID, "forbidden_group")
+		ctx.Abort(http.StatusForbidden, "Forbidden")
+		return nil
+	}
+
+	plugin.KeyConsumerID.Set(ctx, username)
+	plugin.KeyConsumerUsername.Set(ctx, username)
+	ldapDNKey.Set(ctx, identity.dn)
+	ldapGroupsKey.Set(ctx, identity.groups)
+	plugin.KeyAuthMechanism.Set(ctx, authMechanismLDAP)
+
+	if p.config.HideCredentials {
+		ctx.Request.Header.Del(p.config.HeaderName)
+	}
+
+	return nil
+}
+
+// challenge aborts with 401 and a Basic challenge.
+func (p *LDAPAuthPlugin) challenge(ctx *plugin.Context, message string) {
+	ctx.Response.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s", charset="UTF-8"`, p.config.Realm))
+	ctx.Abort(http.StatusUnauthorized, message)
+}
+
+// memberOfAllowed reports whether any group is in allowed_groups.
+func (p *LDAPAuthPlugin) memberOfAllowed(groups []string) bool {
+	for _, group := range groups {
+		if p.allowed[strings.ToLower(group)] {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================================================
+// Authentication
+// ============================================================================
+
+// errLDAPUserNotFound means the user search matched no single entry.
+var errLDAPUserNotFound = errors.New("user not found")
+
+// authenticate returns the user's identity from the cache or by binding.
+func (p *LDAPAuthPlugin) authenticate(ctx context.Context, username, password string) (ldapIdentity, error) {
+	key := p.cacheKey(username, password)
+	now := time.Now()
+
+	if p.cacheTTL > 0 {
+		p.mu.Lock()
+		identity, ok := p.cache[key]
+		p.mu.Unlock()
+		if ok && now.Before(identity.expires) {
+			return identity, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	// A pooled connection may have been dropped by the server; retry once
+	// on a fresh one
+	var identity ldapIdentity
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var conn *ldap.Conn
+		conn, err = p.pool.Get(ctx)
+		if err != nil {
+			return ldapIdentity{}, err
+		}
+		identity, err = p.bind(conn, username, password)
+		broken := conn.Broken()
+		p.pool.Put(conn)
+		if !broken {
+			break
+		}
+	}
+	if err != nil {
+		return ldapIdentity{}, err
+	}
+
+	if p.cacheTTL > 0 {
+		identity.expires = now.Add(p.cacheTTL)
+		p.mu.Lock()
+		if len(p.cache) >= maxLDAPCacheEntries {
+			p.cache = make(map[string]ldapIdentity)
+		}
+		p.cache[key] = identity
+		p.mu.Unlock()
+	}
+
+	return identity, nil
+}
+
+// bind resolves the user's DN, binds as the user and reads their groups.
+func (p *LDAPAuthPlugin) bind(conn *ldap.Conn, username, password string) (ldapIdentity, error) {
+	var identity ldapIdentity
+
+	if p.config.BindDN != "" {
+		// Search then bind
+		if err := conn.Bind(p.config.BindDN, p.config.BindPassword); err != nil {
+			return identity, fmt.Errorf("service account bind failed: %w", err)
+		}
+		entry, err := p.findUser(conn, username)
+		if err != nil {
+			return identity, err
+		}
+		identity.dn = entry.DN
+		identity.groups = groupNames(entry.Values(p.config.GroupAttribute))
+
+		if err := conn.Bind(identity.dn, password); err != nil {
+			return identity, err
+		}
+		return identity, nil
+	}
+
+	// Direct bind
+	identity.dn = strings.ReplaceAll(p.config.BindDNTemplate, "{username}", ldap.EscapeDN(username))
+	if err := conn.Bind(identity.dn, password); err != nil {
+		return identity, err
+	}
+
+	if p.config.GroupAttribute == "" {
+		return identity, nil
+	}
+
+	// Read groups as the user. With an AD UPN template the bind name is
+	// not a DN, so search under base_dn instead.
+	if p.config.BaseDN != "" {
+		entry, err := p.findUser(conn, username)
+		if err != nil {
+			return identity, err
+		}
+		identity.dn = entry.DN
+		identity.groups = groupNames(entry.Values(p.config.GroupAttribute))
+		return identity, nil
+	}
+
+	entries, err := conn.Search(ldap.SearchRequest{
+		BaseDN:     identity.dn,
+		Scope:      ldap.ScopeBaseObject,
+		Filter:     "(objectClass=*)",
+		Attributes: []string{p.config.GroupAttribute},
+	})
+	if err != nil {
+		return identity, fmt.Errorf("group lookup failed: %w", err)
+	}
+	if len(entries) > 0 {
+		identity.groups = groupNames(entries[0].Values(p.config.GroupAttribute))
+	}
+	return identity, nil
+}
+
+// findUser searches base_dn for exactly one entry matching user_filter.
+func (p *LDAPAuthPlugin) findUser(conn *ldap.Conn, username string) (*ldap.Entry, error) {
+	filter, err := ldapFilter(p.config.UserFilter, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var attributes []string
+	if p.config.GroupAttribute != "" {
+		attributes = []string{p.config.GroupAttribute}
+	}
+
+	entries, err := conn.Search(ldap.SearchRequest{
+		BaseDN:     p.config.BaseDN,
+		Scope:      ldap.ScopeWholeSubtree,
+		Filter:     filter,
+		Attributes: attributes,
+		SizeLimit:  2,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("user search failed: %w", err)
+	}
+	if len(entries) != 1 {
+		return nil, errLDAPUserNotFound
+	}
+	return entries[0], nil
+}
+
+// ldapFilter substitutes the escaped username into a filter template.
+func ldapFilter(template, username string) (string, error) {
+	if template == "" {
+		return "", fmt.Errorf("user_filter is empty")
+	}
+	return strings.ReplaceAll(template, "{username}", ldap.EscapeFilter(username)), nil
+}
+
+// groupNames reduces group DNs to their CN ("cn=admins,ou=groups,..." ->
+// "admins"). Values that are not DNs are kept as-is.
+func groupNames(values []string) []string {
+	names := make([]string, 0, len(values))
+	for _, value := range values {
+		first, _, _ := strings.Cut(value, ",")
+		if attr, name, ok := strings.Cut(first, "="); ok && strings.EqualFold(strings.TrimSpace(attr), "cn") {
+			names = append(names, strings.TrimSpace(name))
+			continue
+		}
+		names = append(names, value)
+	}
+	return names
+}
+
+// cacheKey derives the bind cache key from the credentials.
+func (p *LDAPAuthPlugin) cacheKey(username, password string) string {
+	mac := hmac.New(sha256.New, p.cacheSalt)
+	mac.Write([]byte(username))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// basicCredentials parses a "Basic <base64(user:pass)>" header.
+func basicCredentials(r *http.Request, headerName string) (string, string, bool) {
+	value := strings.TrimSpace(r.Header.Get(headerName))
+	scheme, encoded, ok := strings.Cut(value, " ")
+	if !ok || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
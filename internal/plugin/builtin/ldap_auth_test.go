@@ -0,0 +1,57 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLDAPAuth_Close(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	config, _ := json.Marshal(map[string]interface{}{
+		"url":              "ldap://" + ln.Addr().String(),
+		"bind_dn_template": "uid={username},ou=people,dc=example,dc=com",
+	})
+	p, err := NewLDAPAuthPlugin(config)
+	if err != nil {
+		t.Fatalf("NewLDAPAuthPlugin() error = %v", err)
+	}
+	ldapAuth := p.(*LDAPAuthPlugin)
+
+	// Leave one connection idle in the pool
+	conn, err := ldapAuth.pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("pool.Get() error = %v", err)
+	}
+	ldapAuth.pool.Put(conn)
+	server := <-accepted
+	defer server.Close()
+
+	if err := ldapAuth.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// The server sees the idle connection unbind and close
+	server.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadAll(server); err != nil {
+		t.Errorf("idle connection not closed: %v", err)
+	}
+
+	if _, err := ldapAuth.authenticate(context.Background(), "ada", "secret"); err == nil {
+		t.Error("authenticate() succeeded on a closed plugin")
+	}
+}
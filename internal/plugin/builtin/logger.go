@@ -14,6 +14,9 @@ import (
 	"github.com/saidutt46/switchboard-gateway/internal/plugin"
 )
 
+// requestStartKey holds when the request logger saw the request.
+var requestStartKey = plugin.RegisterKeyWithAlias[time.Time]("request-logger.start_time", "request_start_time")
+
 // RequestLoggerPlugin logs detailed information about each request.
 //
 // This plugin logs in two phases:
@@ -122,8 +125,8 @@ func (p *RequestLoggerPlugin) logRequest(ctx *plugin.Context) error {
 	requestID := fmt.Sprintf("req_%d", time.Now().UnixNano())
 
 	// Store request ID in context for later phases and plugins
-	ctx.Set("request_id", requestID)
-	ctx.Set("request_start_time", time.Now())
+	plugin.KeyRequestID.Set(ctx, requestID)
+	requestStartKey.Set(ctx, time.Now())
 
 	// Build log event
 	event := log.Info().
@@ -167,14 +170,12 @@ func (p *RequestLoggerPlugin) logRequest(ctx *plugin.Context) error {
 // logResponse logs response details (AfterResponse phase).
 func (p *RequestLoggerPlugin) logResponse(ctx *plugin.Context) error {
 	// Retrieve request ID from context
-	requestID := ctx.GetString("request_id")
+	requestID := plugin.KeyRequestID.Value(ctx)
 
 	// Calculate request duration
 	var duration time.Duration
-	if startTime, exists := ctx.Get("request_start_time"); exists {
-		if t, ok := startTime.(time.Time); ok {
-			duration = time.Since(t)
-		}
+	if startTime, exists := requestStartKey.Get(ctx); exists {
+		duration = time.Since(startTime)
 	}
 
 	// Get response details
@@ -0,0 +1,204 @@
+// Package builtin - Metering plugin for billing records
+//
+// This plugin marks a route (or service) as billable: every completed
+// request by an identified consumer emits a metering record (consumer,
+// product, units, timestamp) to the gateway's metering sink, from which
+// downstream billing systems rate and invoice usage.
+//
+// Features:
+//   - Bill per request, per byte, or by units reported by the backend
+//   - Only successful responses are billed by default
+//   - Records are batched and delivered asynchronously (see package
+//     metering); the request path never waits on the sink
+//
+// Configuration Example:
+//
+//	{
+//	  "product": "search-api",
+//	  "measure": "response_header",
+//	  "units_header": "X-Billing-Units",
+//	  "unit": "credits"
+//	}
+//
+// Requires METERING_SINK; without it the plugin loads but records are
+// discarded.
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metering"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// Metering measures.
+const (
+	measureRequests       = "requests"
+	measureRequestBytes   = "request_bytes"
+	measureResponseBytes  = "response_bytes"
+	measureResponseHeader = "response_header"
+)
+
+// MeteringPlugin emits billing records for completed requests.
+type MeteringPlugin struct {
+	config MeteringConfig
+	meter  *metering.Meter
+}
+
+// MeteringConfig holds configuration for the metering plugin.
+type MeteringConfig struct {
+	// Product is the billing product name
+	// Default: the route name, or the route ID if unnamed
+	Product string `json:"product"`
+
+	// Measure is what is counted: "requests", "request_bytes",
+	// "response_bytes", or "response_header" (units set by the backend)
+	// Default: "requests"
+	Measure string `json:"measure"`
+
+	// Unit names the billed quantity in records
+	// Default: the measure ("requests", "request_bytes", ...), or "units"
+	// for response_header
+	Unit string `json:"unit"`
+
+	// UnitsHeader is the response header carrying the units to bill
+	// (measure "response_header"). Requests without it are not billed.
+	// Default: "X-Billing-Units"
+	UnitsHeader string `json:"units_header"`
+
+	// BillErrors also bills 4xx/5xx responses
+	// Default: false (only 1xx-3xx are billed)
+	BillErrors bool `json:"bill_errors"`
+
+	// ConsumerKey is the context metadata key holding the consumer
+	// Default: "auth.consumer_id". Anonymous requests are never billed.
+	ConsumerKey string `json:"consumer_key"`
+}
+
+// DefaultMeteringConfig returns sensible defaults.
+func DefaultMeteringConfig() MeteringConfig {
+	return MeteringConfig{
+		Measure:     measureRequests,
+		UnitsHeader: "X-Billing-Units",
+		BillErrors:  false,
+		ConsumerKey: plugin.KeyConsumerID.Name(),
+	}
+}
+
+// NewMeteringFactory returns the metering plugin factory.
+//
+// All instances share one Meter (and its sink, batching and spool).
+func NewMeteringFactory(meter *metering.Meter) plugin.PluginFactory {
+	return func(configJSON json.RawMessage) (plugin.Plugin, error) {
+		config := DefaultMeteringConfig()
+
+		if len(configJSON) > 0 {
+			if err := json.Unmarshal(configJSON, &config); err != nil {
+				return nil, fmt.Errorf("invalid metering config: %w", err)
+			}
+		}
+
+		switch config.Measure {
+		case measureRequests, measureRequestBytes, measureResponseBytes:
+		case measureResponseHeader:
+			if config.UnitsHeader == "" {
+				return nil, fmt.Errorf("units_header is required for measure %q", measureResponseHeader)
+			}
+		default:
+			return nil, fmt.Errorf("invalid measure %q (must be requests, request_bytes, response_bytes or response_header)", config.Measure)
+		}
+
+		if config.Unit == "" {
+			config.Unit = config.Measure
+			if config.Measure == measureResponseHeader {
+				config.Unit = "units"
+			}
+		}
+		if config.ConsumerKey == "" {
+			config.ConsumerKey = plugin.KeyConsumerID.Name()
+		}
+
+		return &MeteringPlugin{
+			config: config,
+			meter:  meter,
+		}, nil
+	}
+}
+
+// Name returns the plugin identifier.
+func (p *MeteringPlugin) Name() string {
+	return "metering"
+}
+
+// Execute runs the metering plugin.
+//
+// BeforeRequest: nothing (usage is only known once the response is done).
+// AfterResponse: measure the request and emit a record.
+func (p *MeteringPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseAfterResponse {
+		return nil
+	}
+
+	value, _ := ctx.Lookup(p.config.ConsumerKey)
+	consumerID, _ := value.(string)
+	if consumerID == "" {
+		return nil
+	}
+
+	status := ctx.Response.StatusCode()
+	if status >= 400 && !p.config.BillErrors {
+		return nil
+	}
+
+	units, ok := p.units(ctx)
+	if !ok || units <= 0 {
+		return nil
+	}
+
+	product := p.config.Product
+	if product == "" {
+		product = ctx.Route.Name.String
+	}
+	if product == "" {
+		product = ctx.Route.ID
+	}
+
+	p.meter.Emit(&metering.Record{
+		ConsumerID: consumerID,
+		Product:    product,
+		RouteID:    ctx.Route.ID,
+		Units:      units,
+		Unit:       p.config.Unit,
+		StatusCode: status,
+		Timestamp:  time.Now().UTC(),
+	})
+
+	return nil
+}
+
+// units measures the request. Returns false if it cannot be measured.
+func (p *MeteringPlugin) units(ctx *plugin.Context) (int64, bool) {
+	switch p.config.Measure {
+	case measureRequestBytes:
+		return ctx.Request.ContentLength, ctx.Request.ContentLength >= 0
+	case measureResponseBytes:
+		return int64(ctx.Response.BodySize()), true
+	case measureResponseHeader:
+		value := strings.TrimSpace(ctx.Response.Header().Get(p.config.UnitsHeader))
+		if value == "" {
+			return 0, false
+		}
+		units, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			ctx.LogError(p.Name(), err, "Invalid units header from backend - request not billed")
+			return 0, false
+		}
+		return units, true
+	default:
+		return 1, true
+	}
+}
@@ -0,0 +1,150 @@
+package builtin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/metering"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// recordingSink collects the records delivered to it.
+type recordingSink struct {
+	mu      sync.Mutex
+	records []*metering.Record
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Send(ctx context.Context, batch *metering.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, batch.Records...)
+	return nil
+}
+
+// meterCall is a completed request seen by the metering plugin.
+type meterCall struct {
+	consumer string
+	status   int
+	body     string // request and response body
+	units    string // X-Billing-Units from the backend
+}
+
+// meterRequests runs a metering plugin built from config after each call
+// and returns the records delivered.
+func meterRequests(t *testing.T, config string, calls []meterCall) []*metering.Record {
+	t.Helper()
+	sink := &recordingSink{}
+	meter, err := metering.NewMeter(sink, metering.Config{FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewMeter() error = %v", err)
+	}
+	p, err := NewMeteringFactory(meter)(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewMeteringFactory() error = %v", err)
+	}
+
+	route := &database.Route{ID: "r-search", Name: sql.NullString{String: "search", Valid: true}}
+	for _, call := range calls {
+		r := httptest.NewRequest("POST", "/search", strings.NewReader(call.body))
+		ctx := plugin.NewContext(r, httptest.NewRecorder(), route, &database.Service{}, plugin.PhaseAfterResponse)
+		if call.consumer != "" {
+			plugin.KeyConsumerID.Set(ctx, call.consumer)
+		}
+		if call.units != "" {
+			ctx.Response.Header().Set("X-Billing-Units", call.units)
+		}
+		ctx.Response.WriteHeader(call.status)
+		ctx.Response.Write([]byte(call.body))
+		if err := p.Execute(ctx); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+	}
+
+	meter.Close(context.Background())
+	return sink.records
+}
+
+// unitsByConsumer totals the records' units per consumer.
+func unitsByConsumer(records []*metering.Record) map[string]int64 {
+	units := make(map[string]int64)
+	for _, r := range records {
+		units[r.ConsumerID] += r.Units
+	}
+	return units
+}
+
+func TestMetering_PerConsumer(t *testing.T) {
+	calls := []meterCall{
+		{consumer: "c-1", status: 200, body: "abc", units: "5"},
+		{consumer: "c-1", status: 201, body: "abcdef", units: "2"},
+		{consumer: "c-2", status: 200, body: "a", units: "7"},
+		{consumer: "c-2", status: 404, body: "not found", units: "1"},
+		{consumer: "c-3", status: 200, body: "ab", units: "many"},
+		{consumer: "c-3", status: 200, body: "ab"},
+		{status: 200, body: "anonymous", units: "9"},
+	}
+
+	tests := []struct {
+		name   string
+		config string
+		want   map[string]int64
+		unit   string
+	}{
+		{name: "requests", config: `{}`, want: map[string]int64{"c-1": 2, "c-2": 1, "c-3": 2}, unit: "requests"},
+		{name: "with errors", config: `{"bill_errors": true}`, want: map[string]int64{"c-1": 2, "c-2": 2, "c-3": 2}, unit: "requests"},
+		{name: "request bytes", config: `{"measure": "request_bytes"}`, want: map[string]int64{"c-1": 9, "c-2": 1, "c-3": 4}, unit: "request_bytes"},
+		{name: "response bytes", config: `{"measure": "response_bytes", "unit": "bytes"}`, want: map[string]int64{"c-1": 9, "c-2": 1, "c-3": 4}, unit: "bytes"},
+		{name: "backend units", config: `{"measure": "response_header"}`, want: map[string]int64{"c-1": 7, "c-2": 7}, unit: "units"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := meterRequests(t, tt.config, calls)
+			got := unitsByConsumer(records)
+			if len(got) != len(tt.want) {
+				t.Errorf("billed %v, want %v", got, tt.want)
+			}
+			for consumer, units := range tt.want {
+				if got[consumer] != units {
+					t.Errorf("%s billed %d, want %d", consumer, got[consumer], units)
+				}
+			}
+			for _, r := range records {
+				if r.Product != "search" || r.RouteID != "r-search" || r.Unit != tt.unit || r.ID == "" || r.Timestamp.IsZero() {
+					t.Errorf("record = %+v, want product search, unit %s, an ID and a timestamp", r, tt.unit)
+				}
+			}
+		})
+	}
+}
+
+func TestMetering_Config(t *testing.T) {
+	for _, config := range []string{
+		`{"measure": "tokens"}`,
+		`{"measure": "response_header", "units_header": ""}`,
+	} {
+		if _, err := NewMeteringFactory(nil)(json.RawMessage(config)); err == nil {
+			t.Errorf("NewMeteringFactory()(%s) succeeded, want error", config)
+		}
+	}
+
+	// Without a sink records are discarded
+	p, err := NewMeteringFactory(nil)(json.RawMessage(`{"product": "search-api"}`))
+	if err != nil {
+		t.Fatalf("NewMeteringFactory() error = %v", err)
+	}
+	ctx := plugin.NewContext(httptest.NewRequest("GET", "/search", nil), httptest.NewRecorder(), &database.Route{ID: "r-search"}, &database.Service{}, plugin.PhaseAfterResponse)
+	plugin.KeyConsumerID.Set(ctx, "c-1")
+	if err := p.Execute(ctx); err != nil {
+		t.Errorf("Execute() without a meter error = %v", err)
+	}
+}
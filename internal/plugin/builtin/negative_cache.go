@@ -0,0 +1,367 @@
+// Package builtin - Negative cache plugin
+//
+// The negative-cache plugin remembers a route's error responses for a
+// short time, so clients polling for a resource that doesn't exist, or
+// probing with bad credentials, are answered by the gateway instead of
+// reaching the backend on every attempt:
+//   - ttls maps a status code ("404") or class ("4xx") to how long the
+//     response is replayed; an exact code wins over its class
+//   - Entries are keyed by route, method, host, path and query, and by
+//     the request's credentials (credential_headers, credential_cookies
+//     and the authenticated consumer), so a retry with different
+//     credentials bypasses a cached 401 or 403 and reaches the backend
+//   - Requests sending Cache-Control: no-cache skip the lookup, and
+//     responses marked Cache-Control: no-store (or private) aren't cached
+//   - Range requests are answered from the cache by default: an error
+//     response is never partial, so the full cached response is the
+//     answer to any range of the resource. With "range_requests": "bypass"
+//     they always reach the backend and their responses aren't stored.
+//     416 Range Not Satisfiable describes the request's range, not the
+//     resource, and is never cached
+//
+// Configuration Example:
+//
+//	{
+//	  "ttls": {"404": "30s", "410": "5m", "401": "5s", "403": "5s"},
+//	  "methods": ["GET", "HEAD"],
+//	  "credential_headers": ["Authorization", "X-API-Key"],
+//	  "credential_cookies": ["sb_session"],
+//	  "max_entries": 10000,
+//	  "max_body_bytes": 65536,
+//	  "range_requests": "serve"
+//	}
+//
+// Entries are held in memory per gateway instance (LRU eviction beyond
+// max_entries). Replayed responses carry an Age header and
+// X-Negative-Cache: HIT.
+package builtin
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// NegativeCacheHeader is set on responses replayed from the negative cache.
+const NegativeCacheHeader = "X-Negative-Cache"
+
+// Negative cache handling of Range requests (config key "range_requests").
+const (
+	RangeRequestsServe  = "serve"
+	RangeRequestsBypass = "bypass"
+)
+
+// NegativeCachePlugin replays recent error responses.
+type NegativeCachePlugin struct {
+	config  NegativeCacheConfig
+	ttls    map[string]time.Duration // status code or class ("4xx")
+	methods map[string]bool
+	now     func() time.Time
+
+	mu    sync.Mutex
+	lru   *list.List // front = most recently used; values are *negativeEntry
+	items map[string]*list.Element
+
+	lookups *metrics.CounterVec
+	stored  *metrics.CounterVec
+}
+
+// NegativeCacheConfig holds configuration for the negative cache plugin.
+type NegativeCacheConfig struct {
+	// TTLs maps status codes ("404") or classes ("4xx") to cache durations
+	// Default: {"404": "30s", "410": "30s", "401": "5s", "403": "5s"}
+	TTLs map[string]string `json:"ttls"`
+
+	// Methods are the HTTP methods whose responses are cached
+	// Default: ["GET", "HEAD"]
+	Methods []string `json:"methods"`
+
+	// CredentialHeaders are request headers that separate cache entries
+	// Default: ["Authorization", "X-API-Key"]
+	CredentialHeaders []string `json:"credential_headers"`
+
+	// CredentialCookies are request cookies that separate cache entries,
+	// such as session-auth's cookie_name
+	// Default: ["sb_session"]
+	CredentialCookies []string `json:"credential_cookies"`
+
+	// MaxEntries is the most responses held (LRU eviction beyond it)
+	// Default: 10000
+	MaxEntries int `json:"max_entries"`
+
+	// MaxBodyBytes is the largest response body cached
+	// Default: 65536 (64 KiB)
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+
+	// RangeRequests is "serve" (answer Range requests from cached
+	// responses) or "bypass" (send them to the backend, uncached)
+	// Default: "serve"
+	RangeRequests string `json:"range_requests"`
+}
+
+// negativeEntry is a cached error response.
+type negativeEntry struct {
+	key        string
+	statusCode int
+	header     http.Header
+	body       []byte
+	stored     time.Time
+	expires    time.Time
+}
+
+// NewNegativeCachePlugin creates a new negative cache plugin.
+func NewNegativeCachePlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := NegativeCacheConfig{
+		Methods:           []string{http.MethodGet, http.MethodHead},
+		CredentialHeaders: []string{"Authorization", "X-API-Key"},
+		CredentialCookies: []string{"sb_session"},
+		MaxEntries:        10000,
+		MaxBodyBytes:      64 << 10,
+		RangeRequests:     RangeRequestsServe,
+	}
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid negative-cache config: %w", err)
+		}
+	}
+	// Set after unmarshaling: decoding into a default map would merge
+	// the configured codes into it instead of replacing it
+	if config.TTLs == nil {
+		config.TTLs = map[string]string{"404": "30s", "410": "30s", "401": "5s", "403": "5s"}
+	}
+	if len(config.TTLs) == 0 {
+		return nil, fmt.Errorf("invalid negative-cache config: ttls is required")
+	}
+	if len(config.Methods) == 0 {
+		return nil, fmt.Errorf("invalid negative-cache config: at least one method is required")
+	}
+	if config.MaxEntries <= 0 {
+		return nil, fmt.Errorf("invalid negative-cache config: max_entries must be positive")
+	}
+	if config.MaxBodyBytes <= 0 {
+		return nil, fmt.Errorf("invalid negative-cache config: max_body_bytes must be positive")
+	}
+	if config.RangeRequests != RangeRequestsServe && config.RangeRequests != RangeRequestsBypass {
+		return nil, fmt.Errorf("invalid negative-cache config: range_requests must be %q or %q", RangeRequestsServe, RangeRequestsBypass)
+	}
+
+	ttls := make(map[string]time.Duration, len(config.TTLs))
+	for status, value := range config.TTLs {
+		status = strings.ToLower(status)
+		if !validNegativeStatus(status) {
+			return nil, fmt.Errorf("invalid negative-cache config: ttls: %q is not a 4xx status code or class", status)
+		}
+		if status == "416" {
+			return nil, fmt.Errorf("invalid negative-cache config: ttls: 416 depends on the request's range and can't be cached")
+		}
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid negative-cache config: ttls: %s: %q is not a positive duration", status, value)
+		}
+		ttls[status] = ttl
+	}
+
+	methods := make(map[string]bool, len(config.Methods))
+	for _, m := range config.Methods {
+		methods[strings.ToUpper(m)] = true
+	}
+
+	m := plugin.NewMetrics("negative-cache")
+	return &NegativeCachePlugin{
+		config:  config,
+		ttls:    ttls,
+		methods: methods,
+		now:     time.Now,
+		lru:     list.New(),
+		items:   make(map[string]*list.Element),
+		lookups: m.Counter(
+			"lookups_total",
+			"Negative cache lookups, by route and result (hit, miss, bypass).",
+			"route", "result",
+		),
+		stored: m.Counter(
+			"stored_total",
+			"Responses stored in the negative cache, by route and status code.",
+			"route", "status",
+		),
+	}, nil
+}
+
+// validNegativeStatus accepts a 4xx status code or the "4xx" class.
+func validNegativeStatus(status string) bool {
+	if status == "4xx" {
+		return true
+	}
+	code, err := strconv.Atoi(status)
+	return err == nil && code >= 400 && code <= 499
+}
+
+// Name returns the plugin identifier.
+func (p *NegativeCachePlugin) Name() string {
+	return "negative-cache"
+}
+
+// Execute replays a cached response or registers the hook that caches
+// the upstream's.
+func (p *NegativeCachePlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+	r := ctx.Request
+	if !p.methods[r.Method] {
+		return nil
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+	key := p.cacheKey(ctx, routeID)
+
+	if r.Header.Get("Range") != "" && p.config.RangeRequests == RangeRequestsBypass {
+		p.lookups.Inc(routeID, "bypass")
+		return nil
+	}
+
+	if strings.Contains(strings.ToLower(r.Header.Get("Cache-Control")), "no-cache") {
+		p.lookups.Inc(routeID, "bypass")
+	} else if entry := p.get(key); entry != nil {
+		p.lookups.Inc(routeID, "hit")
+		p.replay(ctx, entry)
+		return nil
+	} else {
+		p.lookups.Inc(routeID, "miss")
+	}
+
+	ctx.AddResponseHook(func(resp *http.Response) error {
+		ttl, ok := p.ttlFor(resp.StatusCode)
+		if !ok {
+			return nil
+		}
+		cacheControl := strings.ToLower(resp.Header.Get("Cache-Control"))
+		if strings.Contains(cacheControl, "no-store") || strings.Contains(cacheControl, "private") {
+			return nil
+		}
+		if resp.ContentLength > p.config.MaxBodyBytes {
+			return nil
+		}
+
+		body, err := bodybuffer.FromRequest(r).Read(resp.Body, p.config.MaxBodyBytes)
+		if err != nil {
+			return fmt.Errorf("failed to read upstream response: %w", err)
+		}
+		resp.Body = body.ReadCloser(resp.Body)
+		if !body.Complete() {
+			return nil
+		}
+		data := body.Bytes()
+
+		now := p.now()
+		p.put(&negativeEntry{
+			key:        key,
+			statusCode: resp.StatusCode,
+			header:     storableHeaders(resp.Header),
+			body:       data,
+			stored:     now,
+			expires:    now.Add(ttl),
+		})
+		p.stored.Inc(routeID, strconv.Itoa(resp.StatusCode))
+		return nil
+	})
+	return nil
+}
+
+// ttlFor returns the TTL for a status code, preferring the exact code
+// over its class.
+func (p *NegativeCachePlugin) ttlFor(status int) (time.Duration, bool) {
+	if status == http.StatusRequestedRangeNotSatisfiable {
+		return 0, false
+	}
+	if ttl, ok := p.ttls[strconv.Itoa(status)]; ok {
+		return ttl, true
+	}
+	if status >= 400 && status <= 499 {
+		ttl, ok := p.ttls["4xx"]
+		return ttl, ok
+	}
+	return 0, false
+}
+
+// cacheKey identifies a request: route, method, host, path, query and
+// credentials.
+func (p *NegativeCachePlugin) cacheKey(ctx *plugin.Context, routeID string) string {
+	r := ctx.Request
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n", r.Method, r.Host, r.URL.Path, r.URL.RawQuery)
+	for _, name := range p.config.CredentialHeaders {
+		fmt.Fprintf(h, "%s\n", strings.Join(r.Header.Values(name), ","))
+	}
+	writeCookieValues(h, r, p.config.CredentialCookies)
+	fmt.Fprintf(h, "%s\n", plugin.KeyConsumerID.Value(ctx))
+	return routeID + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// replay writes a cached response to the client and aborts the chain.
+func (p *NegativeCachePlugin) replay(ctx *plugin.Context, entry *negativeEntry) {
+	header := ctx.Response.Header()
+	for name, values := range entry.header {
+		header[name] = values
+	}
+	header.Set("Age", strconv.Itoa(int(p.now().Sub(entry.stored).Seconds())))
+	header.Set(NegativeCacheHeader, "HIT")
+
+	ctx.Response.WriteHeader(entry.statusCode)
+	if ctx.Request.Method != http.MethodHead {
+		ctx.Response.Write(entry.body)
+	}
+	ctx.Abort(entry.statusCode, "")
+}
+
+// get returns the unexpired entry for a key, or nil.
+func (p *NegativeCachePlugin) get(key string) *negativeEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elem, ok := p.items[key]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*negativeEntry)
+	if !p.now().Before(entry.expires) {
+		p.lru.Remove(elem)
+		delete(p.items, key)
+		return nil
+	}
+	p.lru.MoveToFront(elem)
+	return entry
+}
+
+// put stores an entry, evicting the least recently used beyond
+// max_entries.
+func (p *NegativeCachePlugin) put(entry *negativeEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if elem, ok := p.items[entry.key]; ok {
+		elem.Value = entry
+		p.lru.MoveToFront(elem)
+		return
+	}
+	p.items[entry.key] = p.lru.PushFront(entry)
+	for p.lru.Len() > p.config.MaxEntries {
+		oldest := p.lru.Back()
+		p.lru.Remove(oldest)
+		delete(p.items, oldest.Value.(*negativeEntry).key)
+	}
+}
@@ -0,0 +1,245 @@
+package builtin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+func newTestNegativeCache(t *testing.T, config string) (*NegativeCachePlugin, *time.Time) {
+	t.Helper()
+	p, err := NewNegativeCachePlugin(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewNegativeCachePlugin() error = %v", err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	nc := p.(*NegativeCachePlugin)
+	nc.now = func() time.Time { return now }
+	return nc, &now
+}
+
+// negativeRoundTrip runs the plugin on r and, unless it replayed a cached
+// response, its response hooks on resp. It reports whether the response
+// came from the cache and the body the client would get.
+func negativeRoundTrip(t *testing.T, p *NegativeCachePlugin, r *http.Request, resp *http.Response) (bool, string) {
+	t.Helper()
+	ctx := newTestContext(r, "r-users")
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if ctx.IsAborted() {
+		rec := ctx.Response.ResponseWriter.(*httptest.ResponseRecorder)
+		if rec.Header().Get(NegativeCacheHeader) != "HIT" {
+			t.Errorf("replayed response without %s: HIT", NegativeCacheHeader)
+		}
+		return true, rec.Body.String()
+	}
+	if resp == nil {
+		t.Fatal("request reached the upstream, want it answered from the cache")
+	}
+	for _, hook := range plugin.ResponseHooks(ctx.Request) {
+		if err := hook(resp); err != nil {
+			t.Fatalf("response hook error = %v", err)
+		}
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return false, string(body)
+}
+
+func TestNegativeCache_TTLs(t *testing.T) {
+	p, now := newTestNegativeCache(t, `{"ttls": {"404": "30s", "410": "5m", "4xx": "1s"}}`)
+
+	tests := []struct {
+		path   string
+		status int
+		ttl    time.Duration
+	}{
+		{path: "/users/404", status: 404, ttl: 30 * time.Second},
+		{path: "/users/410", status: 410, ttl: 5 * time.Minute},
+		{path: "/users/429", status: 429, ttl: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			stored := *now
+			resp := upstreamResponse(tt.status, http.Header{"Content-Type": {"application/json"}}, `{"error": "gone"}`)
+			if hit, body := negativeRoundTrip(t, p, httptest.NewRequest("GET", tt.path, nil), resp); hit || body != `{"error": "gone"}` {
+				t.Fatalf("first request: hit %v, body %q; want the upstream's response", hit, body)
+			}
+
+			// Replayed until the TTL runs out
+			*now = stored.Add(tt.ttl - time.Millisecond)
+			r := httptest.NewRequest("GET", tt.path, nil)
+			ctx := newTestContext(r, "r-users")
+			p.Execute(ctx)
+			if ctx.AbortStatusCode() != tt.status {
+				t.Fatalf("within ttl: status %d, want %d from the cache", ctx.AbortStatusCode(), tt.status)
+			}
+			rec := ctx.Response.ResponseWriter.(*httptest.ResponseRecorder)
+			if rec.Body.String() != `{"error": "gone"}` || rec.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("replayed %q with %v, want the stored response", rec.Body.String(), rec.Header())
+			}
+			if got, want := rec.Header().Get("Age"), strconv.Itoa(int((tt.ttl - time.Millisecond).Seconds())); got != want {
+				t.Errorf("Age = %s, want %s", got, want)
+			}
+
+			*now = stored.Add(tt.ttl)
+			if hit, _ := negativeRoundTrip(t, p, httptest.NewRequest("GET", tt.path, nil), upstreamResponse(200, nil, "found")); hit {
+				t.Error("expired entry replayed")
+			}
+			*now = stored
+		})
+	}
+}
+
+func TestNegativeCache_Bypass(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		header http.Header // request headers
+		status int
+		resp   http.Header
+		body   string
+	}{
+		{name: "server error", status: 500},
+		{name: "bad gateway", status: 502},
+		{name: "success", status: 200},
+		{name: "status without a ttl", status: 400},
+		{name: "default replaced by ttls", status: 401},
+		{name: "range not satisfiable", status: 416},
+		{name: "no-store", status: 404, resp: http.Header{"Cache-Control": {"no-store"}}},
+		{name: "private", status: 404, resp: http.Header{"Cache-Control": {"private, max-age=60"}}},
+		{name: "oversized", status: 404, body: string(make([]byte, 100))},
+		{name: "method not cached", method: "POST", status: 404},
+		{name: "client no-cache", header: http.Header{"Cache-Control": {"no-cache"}}, status: 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := newTestNegativeCache(t, `{"ttls": {"404": "30s"}, "max_body_bytes": 64}`)
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			for i := 0; i < 2; i++ {
+				r := httptest.NewRequest(method, "/users/1", nil)
+				for name, values := range tt.header {
+					r.Header[name] = values
+				}
+				if hit, _ := negativeRoundTrip(t, p, r, upstreamResponse(tt.status, tt.resp.Clone(), tt.body)); hit {
+					t.Fatalf("request %d answered from the cache, want it sent upstream", i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestNegativeCache_Credentials(t *testing.T) {
+	p, _ := newTestNegativeCache(t, "")
+	request := func(authorization, session string) *http.Request {
+		r := httptest.NewRequest("GET", "/users/me", nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		if session != "" {
+			r.AddCookie(&http.Cookie{Name: "sb_session", Value: session})
+		}
+		return r
+	}
+	negativeRoundTrip(t, p, request("Bearer bad", ""), upstreamResponse(401, nil, "unauthorized"))
+
+	if hit, _ := negativeRoundTrip(t, p, request("Bearer bad", ""), nil); !hit {
+		t.Error("same credentials: cached 401 not replayed")
+	}
+	if hit, _ := negativeRoundTrip(t, p, request("Bearer good", ""), upstreamResponse(200, nil, "ok")); hit {
+		t.Error("new token: cached 401 replayed, want the retry sent upstream")
+	}
+	if hit, _ := negativeRoundTrip(t, p, request("Bearer bad", "s-1"), upstreamResponse(200, nil, "ok")); hit {
+		t.Error("session cookie: cached 401 replayed, want the retry sent upstream")
+	}
+
+	// Consumers authenticated by an earlier plugin don't share entries
+	ctx := newTestContext(request("", ""), "r-users")
+	plugin.KeyConsumerID.Set(ctx, "c-1")
+	p.Execute(ctx)
+	for _, hook := range plugin.ResponseHooks(ctx.Request) {
+		hook(upstreamResponse(403, nil, "forbidden"))
+	}
+	ctx = newTestContext(request("", ""), "r-users")
+	plugin.KeyConsumerID.Set(ctx, "c-2")
+	if p.Execute(ctx); ctx.IsAborted() {
+		t.Error("another consumer got c-1's cached 403")
+	}
+}
+
+func TestNegativeCache_Range(t *testing.T) {
+	ranged := func() *http.Request {
+		r := httptest.NewRequest("GET", "/files/missing", nil)
+		r.Header.Set("Range", "bytes=0-99")
+		return r
+	}
+
+	// serve: a cached error answers any range in full
+	p, _ := newTestNegativeCache(t, `{"range_requests": "serve"}`)
+	negativeRoundTrip(t, p, httptest.NewRequest("GET", "/files/missing", nil), upstreamResponse(404, nil, "not found"))
+	if hit, body := negativeRoundTrip(t, p, ranged(), nil); !hit || body != "not found" {
+		t.Errorf("serve: hit %v, body %q; want the whole cached 404", hit, body)
+	}
+
+	// A ranged miss stores its error for later requests, ranged or not
+	p, _ = newTestNegativeCache(t, `{"range_requests": "serve"}`)
+	negativeRoundTrip(t, p, ranged(), upstreamResponse(404, nil, "not found"))
+	if hit, _ := negativeRoundTrip(t, p, httptest.NewRequest("GET", "/files/missing", nil), nil); !hit {
+		t.Error("serve: 404 for a ranged request not stored")
+	}
+
+	// bypass: ranged requests always reach the backend and aren't stored
+	p, _ = newTestNegativeCache(t, `{"range_requests": "bypass"}`)
+	negativeRoundTrip(t, p, httptest.NewRequest("GET", "/files/missing", nil), upstreamResponse(404, nil, "not found"))
+	if hit, _ := negativeRoundTrip(t, p, ranged(), upstreamResponse(206, nil, "partial")); hit {
+		t.Error("bypass: ranged request answered from the cache")
+	}
+	p, _ = newTestNegativeCache(t, `{"range_requests": "bypass"}`)
+	negativeRoundTrip(t, p, ranged(), upstreamResponse(404, nil, "not found"))
+	if hit, _ := negativeRoundTrip(t, p, httptest.NewRequest("GET", "/files/missing", nil), upstreamResponse(200, nil, "found")); hit {
+		t.Error("bypass: response to a ranged request stored")
+	}
+}
+
+func TestNegativeCache_Eviction(t *testing.T) {
+	p, _ := newTestNegativeCache(t, `{"max_entries": 2}`)
+	for _, path := range []string{"/a", "/b", "/c"} {
+		negativeRoundTrip(t, p, httptest.NewRequest("GET", path, nil), upstreamResponse(404, nil, "not found"))
+	}
+	if hit, _ := negativeRoundTrip(t, p, httptest.NewRequest("GET", "/a", nil), upstreamResponse(404, nil, "not found")); hit {
+		t.Error("least recently used entry not evicted")
+	}
+	if hit, _ := negativeRoundTrip(t, p, httptest.NewRequest("GET", "/c", nil), nil); !hit {
+		t.Error("newest entry evicted")
+	}
+}
+
+func TestNegativeCache_Config(t *testing.T) {
+	for _, config := range []string{
+		`{"ttls": {}}`,
+		`{"ttls": {"500": "30s"}}`,
+		`{"ttls": {"5xx": "30s"}}`,
+		`{"ttls": {"416": "30s"}}`,
+		`{"ttls": {"404": "0s"}}`,
+		`{"ttls": {"404": "soon"}}`,
+		`{"methods": []}`,
+		`{"max_entries": 0}`,
+		`{"max_body_bytes": 0}`,
+		`{"range_requests": "partial"}`,
+	} {
+		if _, err := NewNegativeCachePlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewNegativeCachePlugin(%s) succeeded, want error", config)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+// Package builtin - Opaque token authentication plugin
+//
+// This plugin authenticates requests carrying an opaque bearer token (a
+// random string with no meaning of its own) by looking it up in a token
+// store. Tokens are never stored or logged in plaintext.
+//
+// Backends:
+//   - redis: GET <key_prefix><sha256 hex of token>. The value is either a
+//     consumer ID or a JSON object:
+//     {"consumer_id": "...", "username": "...", "workspace": "...",
+//     "expires_at": "<RFC 3339>"}
+//     Expire tokens with a Redis TTL or expires_at; revoke with DEL.
+//   - database: the token is an API key from the api_keys table, hashed
+//     the same way as X-API-Key credentials. Disabled and expired keys
+//     are rejected.
+//
+// Tokens of a consumer in another workspace than the route's are rejected
+// as invalid (Redis records without a workspace are accepted anywhere).
+//
+// Lookups are cached in memory for cache_ttl, so a revoked token can keep
+// working for up to cache_ttl on each gateway instance. Set cache_ttl to
+// "0s" to disable caching.
+//
+// Configuration Example:
+//
+//	{
+//	  "backend": "redis",
+//	  "redis_url": "redis://localhost:6379/0",
+//	  "key_prefix": "opaque-token:",
+//	  "cache_ttl": "10s",
+//	  "header_name": "Authorization",
+//	  "hide_credentials": true
+//	}
+//
+// On success the plugin sets plugin.KeyConsumerID (and
+// plugin.KeyConsumerUsername when known) in the context metadata. Store failures return 503: an auth
+// plugin never fails open.
+package builtin
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/ratelimit"
+)
+
+// Opaque token backends.
+const (
+	opaqueBackendRedis    = "redis"
+	opaqueBackendDatabase = "database"
+)
+
+// maxOpaqueCacheEntries bounds the lookup cache; it is cleared when full.
+const maxOpaqueCacheEntries = 10000
+
+// errTokenNotFound means the store does not know the token (or it expired).
+var errTokenNotFound = errors.New("token not found")
+
+// OpaqueTokenAuthPlugin authenticates opaque bearer tokens.
+type OpaqueTokenAuthPlugin struct {
+	config   OpaqueTokenAuthConfig
+	store    *ratelimit.RedisStore
+	keys     APIKeyLookup
+	cacheTTL time.Duration
+	lookups  *metrics.CounterVec
+
+	mu    sync.Mutex
+	cache map[string]cachedToken // keyed by token hash
+}
+
+// OpaqueTokenAuthConfig holds configuration for the opaque token auth plugin.
+type OpaqueTokenAuthConfig struct {
+	// Backend is where tokens are looked up
+	// Options: "redis", "database"
+	// Default: "redis"
+	Backend string `json:"backend"`
+
+	// RedisURL is the Redis connection string (redis backend)
+	// Default: "redis://localhost:6379/0"
+	RedisURL string `json:"redis_url"`
+
+	// KeyPrefix is prepended to token hashes to form Redis keys
+	// Default: "opaque-token:"
+	KeyPrefix string `json:"key_prefix"`
+
+	// CacheTTL is how long lookups are cached in memory ("0s" disables)
+	// Default: "10s"
+	CacheTTL string `json:"cache_ttl"`
+
+	// HeaderName is the request header carrying "Bearer <token>"
+	// Default: "Authorization"
+	HeaderName string `json:"header_name"`
+
+	// HideCredentials removes the token header before proxying
+	// Default: true
+	HideCredentials bool `json:"hide_credentials"`
+}
+
+// DefaultOpaqueTokenAuthConfig returns sensible defaults.
+func DefaultOpaqueTokenAuthConfig() OpaqueTokenAuthConfig {
+	return OpaqueTokenAuthConfig{
+		Backend:         opaqueBackendRedis,
+		RedisURL:        "redis://localhost:6379/0",
+		KeyPrefix:       "opaque-token:",
+		CacheTTL:        "10s",
+		HeaderName:      "Authorization",
+		HideCredentials: true,
+	}
+}
+
+// opaqueToken is the identity a token resolves to.
+type opaqueToken struct {
+	ConsumerID string     `json:"consumer_id"`
+	Username   string     `json:"username,omitempty"`
+	Workspace  string     `json:"workspace,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// cachedToken is a lookup cache entry.
+type cachedToken struct {
+	token   opaqueToken
+	expires time.Time
+}
+
+// APIKeyLookup resolves API keys to consumers (database.Repository, or a
+// keycache.Cache in front of it). Unknown keys return an error wrapping
+// sql.ErrNoRows.
+type APIKeyLookup interface {
+	GetConsumerByAPIKey(ctx context.Context, apiKey string) (*database.Consumer, error)
+}
+
+// NewOpaqueTokenAuthFactory returns the opaque-token-auth plugin factory.
+//
+// keys backs the database backend; it may be nil if only Redis is used.
+func NewOpaqueTokenAuthFactory(keys APIKeyLookup) plugin.PluginFactory {
+	return func(configJSON json.RawMessage) (plugin.Plugin, error) {
+		config := DefaultOpaqueTokenAuthConfig()
+
+		if len(configJSON) > 0 {
+			if err := json.Unmarshal(configJSON, &config); err != nil {
+				return nil, fmt.Errorf("invalid opaque-token-auth config: %w", err)
+			}
+		}
+
+		cacheTTL, err := time.ParseDuration(config.CacheTTL)
+		if err != nil || cacheTTL < 0 {
+			return nil, fmt.Errorf("invalid cache_ttl '%s'", config.CacheTTL)
+		}
+		if config.HeaderName == "" {
+			return nil, fmt.Errorf("header_name is required")
+		}
+
+		p := &OpaqueTokenAuthPlugin{
+			config:   config,
+			cacheTTL: cacheTTL,
+			cache:    make(map[string]cachedToken),
+			lookups: plugin.NewMetrics("opaque-auth").Counter(
+				"lookups_total",
+				"Token lookups by result (cache_hit, hit, not_found, error)",
+				"result",
+			),
+		}
+
+		switch config.Backend {
+		case opaqueBackendRedis:
+			redisConfig := ratelimit.DefaultRedisConfig()
+			redisConfig.URL = config.RedisURL
+			store, err := ratelimit.NewRedisStore(redisConfig)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create redis store: %w", err)
+			}
+			p.store = store
+
+		case opaqueBackendDatabase:
+			if keys == nil {
+				return nil, fmt.Errorf("database backend is not available")
+			}
+			p.keys = keys
+
+		default:
+			return nil, fmt.Errorf("invalid backend '%s' (must be redis or database)", config.Backend)
+		}
+
+		log.Info().
+			Str("component", "plugin").
+			Str("plugin", "opaque-token-auth").
+			Str("backend", config.Backend).
+			Dur("cache_ttl", cacheTTL).
+			Msg("Initializing opaque token auth plugin")
+
+		return p, nil
+	}
+}
+
+// Name returns the plugin identifier.
+func (p *OpaqueTokenAuthPlugin) Name() string {
+	return "opaque-token-auth"
+}
+
+// Execute runs the opaque token auth plugin.
+//
+// BeforeRequest: resolve the token to a consumer.
+// AfterResponse: nothing.
+func (p *OpaqueTokenAuthPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase == plugin.PhaseAfterResponse {
+		return nil
+	}
+
+	raw := bearerToken(ctx.Request, p.config.HeaderName)
+	if raw == "" {
+		rejectToken(ctx, "", "Missing bearer token")
+		return nil
+	}
+
+	token, err := p.lookup(ctx.Context(), raw)
+	if errors.Is(err, errTokenNotFound) {
+		rejectToken(ctx, "invalid_token", "Invalid token")
+		return nil
+	}
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("component", "plugin").
+			Str("plugin", "opaque-token-auth").
+			Str("backend", p.config.Backend).
+			Msg("Token lookup failed")
+		ctx.Abort(http.StatusServiceUnavailable, "Authentication service unavailable")
+		return fmt.Errorf("token lookup failed: %w", err)
+	}
+
+	// Credentials only authenticate in their own workspace
+	if token.Workspace != "" && ctx.Route != nil && token.Workspace != database.WorkspaceName(ctx.Route.Workspace) {
+		rejectToken(ctx, "invalid_token", "Invalid token")
+		return nil
+	}
+
+	plugin.KeyConsumerID.Set(ctx, token.ConsumerID)
+	if token.Username != "" {
+		plugin.KeyConsumerUsername.Set(ctx, token.Username)
+	}
+	if p.config.Backend == "database" {
+		plugin.KeyAuthMechanism.Set(ctx, authMechanismAPIKey)
+	} else {
+		plugin.KeyAuthMechanism.Set(ctx, authMechanismToken)
+	}
+
+	if p.config.HideCredentials {
+		ctx.Request.Header.Del(p.config.HeaderName)
+	}
+
+	log.Debug().
+		Str("component", "plugin").
+		Str("plugin", "opaque-token-auth").
+		Str("consumer_id", token.ConsumerID).
+		Msg("Opaque token verified")
+
+	return nil
+}
+
+// ============================================================================
+// Lookup
+// ============================================================================
+
+// lookup resolves a token through the cache and the configured backend.
+func (p *OpaqueTokenAuthPlugin) lookup(ctx context.Context, raw string) (opaqueToken, error) {
+	sum := sha256.Sum256([]byte(raw))
+	hash := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	if p.cacheTTL > 0 {
+		p.mu.Lock()
+		entry, ok := p.cache[hash]
+		p.mu.Unlock()
+		if ok && now.Before(entry.expires) && !tokenExpired(entry.token, now) {
+			p.lookups.Inc("cache_hit")
+			return entry.token, nil
+		}
+	}
+
+	var token opaqueToken
+	var err error
+	if p.keys != nil {
+		token, err = p.lookupDatabase(ctx, raw)
+	} else {
+		token, err = p.lookupRedis(ctx, hash)
+	}
+	switch {
+	case errors.Is(err, errTokenNotFound):
+		p.lookups.Inc("not_found")
+		return opaqueToken{}, err
+	case err != nil:
+		p.lookups.Inc("error")
+		return opaqueToken{}, err
+	case token.ConsumerID == "" || tokenExpired(token, now):
+		p.lookups.Inc("not_found")
+		return opaqueToken{}, errTokenNotFound
+	}
+	p.lookups.Inc("hit")
+
+	if p.cacheTTL > 0 {
+		p.mu.Lock()
+		if len(p.cache) >= maxOpaqueCacheEntries {
+			p.cache = make(map[string]cachedToken)
+		}
+		p.cache[hash] = cachedToken{token: token, expires: now.Add(p.cacheTTL)}
+		p.mu.Unlock()
+	}
+
+	return token, nil
+}
+
+// lookupRedis reads the token record stored under the token hash.
+func (p *OpaqueTokenAuthPlugin) lookupRedis(ctx context.Context, hash string) (opaqueToken, error) {
+	value, err := p.store.Get(ctx, p.config.KeyPrefix+hash)
+	if err != nil {
+		return opaqueToken{}, err
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return opaqueToken{}, errTokenNotFound
+	}
+	if !strings.HasPrefix(value, "{") {
+		// Plain consumer ID
+		return opaqueToken{ConsumerID: value}, nil
+	}
+
+	var token opaqueToken
+	if err := json.Unmarshal([]byte(value), &token); err != nil {
+		return opaqueToken{}, fmt.Errorf("invalid token record: %w", err)
+	}
+	return token, nil
+}
+
+// lookupDatabase resolves the token as an API key.
+func (p *OpaqueTokenAuthPlugin) lookupDatabase(ctx context.Context, raw string) (opaqueToken, error) {
+	consumer, err := p.keys.GetConsumerByAPIKey(ctx, raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return opaqueToken{}, errTokenNotFound
+	}
+	if err != nil {
+		return opaqueToken{}, err
+	}
+	return opaqueToken{
+		ConsumerID: consumer.ID,
+		Username:   consumer.Username,
+		Workspace:  database.WorkspaceName(consumer.Workspace),
+	}, nil
+}
+
+// tokenExpired reports whether a token record's expires_at has passed.
+func tokenExpired(token opaqueToken, now time.Time) bool {
+	return token.ExpiresAt != nil && !now.Before(*token.ExpiresAt)
+}
@@ -0,0 +1,408 @@
+// Package builtin - Pagination plugin
+//
+// The pagination plugin rewrites a backend's pagination scheme into the
+// gateway's standard one, so API consumers page through every route the
+// same way however the backends do it. It reads the page's items and its
+// next/prev links or cursors from the upstream response (JSON fields, or
+// the backend's Link header) and returns them as:
+//   - envelope: {"data": [...], "pagination": {"next": "...", "prev": "...",
+//     "next_cursor": "...", "prev_cursor": "...", "total": 120}}
+//   - link: the items array as the body, with an RFC 8288 Link header
+//     (rel="next", rel="prev") and X-Total-Count
+//   - both: the envelope and the headers
+//
+// Configuration Example (a backend answering
+// {"results": [...], "meta": {"next_token": "abc", "count": 120}}):
+//
+//	{
+//	  "items": "results",
+//	  "next": "meta.next_token",
+//	  "prev": "meta.prev_token",
+//	  "total": "meta.count",
+//	  "cursor_param": "cursor",
+//	  "format": "envelope"
+//	}
+//
+// Field paths are dot-separated object keys ("" for items means the body
+// is the array). Backends that page with a Link header are read with
+// "link_header": true instead of next/prev.
+//
+// A next/prev value that is a URL (absolute or starting with "/" or "?")
+// has its query string carried over to the client's URL; any other value
+// (a cursor, a page number) is set as cursor_param on the client's URL.
+// Links are relative references to the gateway path the client called, so
+// backend hosts never leak.
+//
+// Only 2xx JSON responses up to max_body_bytes are rewritten; anything
+// else, including 206 partial content, or a body without the items array,
+// is passed through unchanged.
+package builtin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// Pagination output formats.
+const (
+	PaginationFormatEnvelope = "envelope"
+	PaginationFormatLink     = "link"
+	PaginationFormatBoth     = "both"
+)
+
+// PaginationPlugin normalizes upstream pagination.
+type PaginationPlugin struct {
+	config    PaginationConfig
+	responses *metrics.CounterVec
+}
+
+// PaginationConfig holds configuration for the pagination plugin.
+type PaginationConfig struct {
+	// Items is the path of the items array ("" = the body)
+	Items string `json:"items"`
+
+	// Next, Prev and Total are the paths of the next and previous page
+	// (URL or cursor) and the total item count; empty ones are skipped
+	Next  string `json:"next"`
+	Prev  string `json:"prev"`
+	Total string `json:"total"`
+
+	// LinkHeader reads next and prev from the backend's Link header
+	// Default: false
+	LinkHeader bool `json:"link_header"`
+
+	// CursorParam is the query parameter cursors are sent back in
+	// Default: "cursor"
+	CursorParam string `json:"cursor_param"`
+
+	// Format is envelope, link or both
+	// Default: "envelope"
+	Format string `json:"format"`
+
+	// DataKey and PaginationKey name the envelope's fields
+	// Default: "data", "pagination"
+	DataKey       string `json:"data_key"`
+	PaginationKey string `json:"pagination_key"`
+
+	// MaxBodyBytes is the largest response rewritten
+	// Default: 10485760 (10 MiB)
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+}
+
+// DefaultPaginationConfig returns defaults for the pagination plugin.
+func DefaultPaginationConfig() PaginationConfig {
+	return PaginationConfig{
+		CursorParam:   "cursor",
+		Format:        PaginationFormatEnvelope,
+		DataKey:       "data",
+		PaginationKey: "pagination",
+		MaxBodyBytes:  10 << 20,
+	}
+}
+
+// NewPaginationPlugin creates a new pagination plugin.
+func NewPaginationPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := DefaultPaginationConfig()
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid pagination config: %w", err)
+		}
+	}
+
+	switch config.Format {
+	case PaginationFormatEnvelope, PaginationFormatLink, PaginationFormatBoth:
+	default:
+		return nil, fmt.Errorf("invalid pagination config: format must be %s, %s or %s", PaginationFormatEnvelope, PaginationFormatLink, PaginationFormatBoth)
+	}
+	if config.CursorParam == "" {
+		return nil, fmt.Errorf("invalid pagination config: cursor_param cannot be empty")
+	}
+	if config.Format != PaginationFormatLink && (config.DataKey == "" || config.PaginationKey == "" || config.DataKey == config.PaginationKey) {
+		return nil, fmt.Errorf("invalid pagination config: data_key and pagination_key must be set and differ")
+	}
+	if config.MaxBodyBytes <= 0 {
+		return nil, fmt.Errorf("invalid pagination config: max_body_bytes must be positive")
+	}
+	if config.LinkHeader && (config.Next != "" || config.Prev != "") {
+		return nil, fmt.Errorf("invalid pagination config: link_header replaces next and prev")
+	}
+
+	return &PaginationPlugin{
+		config: config,
+		responses: plugin.NewMetrics("pagination").Counter(
+			"responses_total",
+			"Upstream responses seen by the pagination plugin, by route and result (rewritten, skipped, too_large, over_budget, invalid).",
+			"route", "result",
+		),
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *PaginationPlugin) Name() string {
+	return "pagination"
+}
+
+// Execute registers the response rewrite.
+func (p *PaginationPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+
+	// The client's URL, before any path stripping or upstream hooks
+	clientURL := *ctx.Request.URL
+	buffers := bodybuffer.FromRequest(ctx.Request)
+	ctx.AddResponseHook(func(resp *http.Response) error {
+		return p.rewrite(resp, routeID, &clientURL, buffers)
+	})
+	return nil
+}
+
+// page is the pagination read from an upstream response.
+type page struct {
+	items      json.RawMessage
+	next, prev string // client URLs
+	nextCursor string
+	prevCursor string
+	total      json.RawMessage
+}
+
+// rewrite replaces the upstream response with the standard format.
+func (p *PaginationPlugin) rewrite(resp *http.Response, routeID string, clientURL *url.URL, buffers *bodybuffer.Manager) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || isPartialContent(resp) || !hasBody(resp) || !isJSONContent(resp.Header) {
+		p.responses.Inc(routeID, "skipped")
+		return nil
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		p.responses.Inc(routeID, "skipped")
+		return nil
+	}
+
+	buffered, err := buffers.Read(resp.Body, p.config.MaxBodyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to read upstream response: %w", err)
+	}
+	resp.Body = buffered.ReadCloser(resp.Body)
+	if buffered.OverBudget() {
+		p.responses.Inc(routeID, "over_budget")
+		return nil
+	}
+	if buffered.TooLarge() {
+		p.responses.Inc(routeID, "too_large")
+		return nil
+	}
+
+	pg, ok := p.read(buffered.Bytes(), resp.Header, clientURL)
+	if !ok {
+		p.responses.Inc(routeID, "invalid")
+		log.Debug().
+			Str("component", "plugin").
+			Str("plugin", "pagination").
+			Str("route_id", routeID).
+			Str("items", p.config.Items).
+			Msg("Upstream response has no items array; passing it through")
+		return nil
+	}
+
+	body := []byte(pg.items)
+	if p.config.Format != PaginationFormatLink {
+		meta := map[string]any{}
+		for key, value := range map[string]string{
+			"next": pg.next, "next_cursor": pg.nextCursor,
+			"prev": pg.prev, "prev_cursor": pg.prevCursor,
+		} {
+			if value != "" {
+				meta[key] = value
+			}
+		}
+		if pg.total != nil {
+			meta["total"] = pg.total
+		}
+
+		// Links keep their "&"s rather than \u0026
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(map[string]any{p.config.DataKey: pg.items, p.config.PaginationKey: meta}); err != nil {
+			return fmt.Errorf("failed to encode pagination envelope: %w", err)
+		}
+		body = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	}
+	if p.config.Format != PaginationFormatEnvelope {
+		var links []string
+		if pg.next != "" {
+			links = append(links, "<"+pg.next+`>; rel="next"`)
+		}
+		if pg.prev != "" {
+			links = append(links, "<"+pg.prev+`>; rel="prev"`)
+		}
+		resp.Header.Del("Link")
+		if len(links) > 0 {
+			resp.Header.Set("Link", strings.Join(links, ", "))
+		}
+		if pg.total != nil {
+			resp.Header.Set("X-Total-Count", strings.Trim(string(pg.total), `"`))
+		}
+	} else {
+		// The envelope replaces the backend's links
+		resp.Header.Del("Link")
+	}
+
+	p.responses.Inc(routeID, "rewritten")
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// read extracts the page from an upstream body and headers.
+func (p *PaginationPlugin) read(data []byte, header http.Header, clientURL *url.URL) (page, bool) {
+	// Numbers stay json.Numbers so large IDs aren't rounded
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return page{}, false
+	}
+
+	var pg page
+	items, ok := jsonField(doc, p.config.Items)
+	if _, isArray := items.([]any); !ok || !isArray {
+		return page{}, false
+	}
+	if p.config.Items == "" {
+		pg.items = bytes.TrimSpace(data)
+	} else {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(items); err != nil {
+			return page{}, false
+		}
+		pg.items = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	}
+
+	if p.config.LinkHeader {
+		links := parseLinkHeader(header.Values("Link"))
+		pg.next, pg.nextCursor = p.clientLink(links["next"], clientURL)
+		pg.prev, pg.prevCursor = p.clientLink(links["prev"], clientURL)
+	} else {
+		pg.next, pg.nextCursor = p.clientLink(jsonString(doc, p.config.Next), clientURL)
+		pg.prev, pg.prevCursor = p.clientLink(jsonString(doc, p.config.Prev), clientURL)
+	}
+	if p.config.Total != "" {
+		if total, ok := jsonField(doc, p.config.Total); ok && total != nil {
+			pg.total, _ = json.Marshal(total)
+		}
+	}
+	return pg, true
+}
+
+// clientLink turns a backend next/prev value into a link to the client's
+// URL and the cursor it carries.
+func (p *PaginationPlugin) clientLink(value string, clientURL *url.URL) (link, cursor string) {
+	if value == "" {
+		return "", ""
+	}
+	query := clientURL.Query()
+	if strings.Contains(value, "://") || strings.HasPrefix(value, "/") || strings.HasPrefix(value, "?") {
+		u, err := url.Parse(value)
+		if err != nil {
+			return "", ""
+		}
+		for key, values := range u.Query() {
+			query[key] = values
+		}
+		cursor = u.Query().Get(p.config.CursorParam)
+	} else {
+		query.Set(p.config.CursorParam, value)
+		cursor = value
+	}
+	u := url.URL{Path: clientURL.Path, RawPath: clientURL.RawPath, RawQuery: query.Encode()}
+	return u.String(), cursor
+}
+
+// jsonField returns the value at a dot-separated path of object keys.
+func jsonField(doc any, path string) (any, bool) {
+	if path == "" {
+		return doc, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := doc.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if doc, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// jsonString returns the string or number at path, or "".
+func jsonString(doc any, path string) string {
+	if path == "" {
+		return ""
+	}
+	value, _ := jsonField(doc, path)
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+// parseLinkHeader returns the targets of RFC 8288 Link header values by
+// rel.
+func parseLinkHeader(values []string) map[string]string {
+	links := make(map[string]string)
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			target = target[1 : len(target)-1]
+			for _, param := range parts[1:] {
+				name, rel, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(name, "rel") {
+					continue
+				}
+				for _, r := range strings.Fields(strings.Trim(rel, `"`)) {
+					if _, seen := links[strings.ToLower(r)]; !seen {
+						links[strings.ToLower(r)] = target
+					}
+				}
+			}
+		}
+	}
+	return links
+}
+
+// isJSONContent reports whether a Content-Type is JSON (application/json
+// or a +json type).
+func isJSONContent(h http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
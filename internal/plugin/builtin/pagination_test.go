@@ -0,0 +1,184 @@
+package builtin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// paginate runs a pagination plugin built from config on a request for
+// target and returns the rewritten response body.
+func paginate(t *testing.T, config, target string, resp *http.Response) string {
+	t.Helper()
+	p, err := NewPaginationPlugin(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewPaginationPlugin() error = %v", err)
+	}
+	ctx := newTestContext(httptest.NewRequest("GET", target, nil), "r-items")
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	for _, hook := range plugin.ResponseHooks(ctx.Request) {
+		if err := hook(resp); err != nil {
+			t.Fatalf("response hook error = %v", err)
+		}
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func jsonResponse(status int, body string) *http.Response {
+	return upstreamResponse(status, http.Header{"Content-Type": {"application/json"}}, body)
+}
+
+func TestPagination_Envelope(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		target string
+		body   string
+		want   string
+	}{
+		{
+			name:   "cursor",
+			config: `{"items": "results", "next": "meta.next_token", "prev": "meta.prev_token", "total": "meta.count"}`,
+			target: "/items?limit=2",
+			body:   `{"results": [{"id": 1}, {"id": 2}], "meta": {"next_token": "abc", "prev_token": "xyz", "count": 120}}`,
+			want:   `{"data":[{"id":1},{"id":2}],"pagination":{"next":"/items?cursor=abc&limit=2","next_cursor":"abc","prev":"/items?cursor=xyz&limit=2","prev_cursor":"xyz","total":120}}`,
+		},
+		{
+			name:   "offset url",
+			config: `{"items": "data.items", "next": "data.next", "cursor_param": "after"}`,
+			target: "/items?limit=10&offset=10",
+			body:   `{"data": {"items": [3, 4], "next": "http://backend.internal:8080/v1/items?offset=20&limit=10"}}`,
+			want:   `{"data":[3,4],"pagination":{"next":"/items?limit=10&offset=20"}}`,
+		},
+		{
+			name:   "relative url carrying the cursor",
+			config: `{"items": "results", "next": "next"}`,
+			target: "/items",
+			body:   `{"results": [], "next": "/v1/items?cursor=c2"}`,
+			want:   `{"data":[],"pagination":{"next":"/items?cursor=c2","next_cursor":"c2"}}`,
+		},
+		{
+			name:   "page number",
+			config: `{"items": "results", "next": "next_page", "cursor_param": "page"}`,
+			target: "/items?page=1",
+			body:   `{"results": [1], "next_page": 2}`,
+			want:   `{"data":[1],"pagination":{"next":"/items?page=2","next_cursor":"2"}}`,
+		},
+		{
+			name:   "last page",
+			config: `{"items": "results", "next": "next", "total": "total"}`,
+			target: "/items",
+			body:   `{"results": [9007199254740993], "next": null, "total": 1}`,
+			want:   `{"data":[9007199254740993],"pagination":{"total":1}}`,
+		},
+		{
+			name:   "body is the array",
+			config: `{}`,
+			target: "/items",
+			body:   `[1, 2]`,
+			want:   `{"data":[1,2],"pagination":{}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := jsonResponse(http.StatusOK, tt.body)
+			resp.Header.Set("Link", `<http://backend.internal/v1/items?page=9>; rel="next"`)
+			if got := paginate(t, tt.config, tt.target, resp); got != tt.want {
+				t.Errorf("body = %s\nwant   %s", got, tt.want)
+			}
+			if resp.Header.Get("Link") != "" {
+				t.Errorf("Link = %q, want the backend's removed", resp.Header.Get("Link"))
+			}
+			if resp.Header.Get("Content-Length") == "" {
+				t.Error("Content-Length not updated")
+			}
+		})
+	}
+}
+
+func TestPagination_LinkHeader(t *testing.T) {
+	backendLinks := `<https://backend.internal/v1/items?page=3&per_page=2>; rel="next", <https://backend.internal/v1/items?page=1&per_page=2>; rel="prev first"`
+
+	// A backend paging with Link headers, rewritten to client links
+	resp := jsonResponse(http.StatusOK, `[{"id": 5}, {"id": 6}]`)
+	resp.Header.Set("Link", backendLinks)
+	resp.Header.Set("X-Total", "7")
+	body := paginate(t, `{"link_header": true, "format": "link"}`, "/items?page=2&per_page=2", resp)
+
+	if body != `[{"id": 5}, {"id": 6}]` {
+		t.Errorf("body = %s, want the items array", body)
+	}
+	want := `</items?page=3&per_page=2>; rel="next", </items?page=1&per_page=2>; rel="prev"`
+	if got := resp.Header.Get("Link"); got != want {
+		t.Errorf("Link = %s\nwant   %s", got, want)
+	}
+
+	// both: the envelope and the headers, with X-Total-Count
+	resp = jsonResponse(http.StatusOK, `{"items": [5, 6], "total": "7"}`)
+	resp.Header.Set("Link", backendLinks)
+	body = paginate(t, `{"items": "items", "total": "total", "link_header": true, "format": "both"}`, "/items?page=2&per_page=2", resp)
+	if got := resp.Header.Get("X-Total-Count"); got != "7" {
+		t.Errorf("X-Total-Count = %q, want 7", got)
+	}
+	if resp.Header.Get("Link") != want {
+		t.Errorf("Link = %s, want the client links", resp.Header.Get("Link"))
+	}
+	wantBody := `{"data":[5,6],"pagination":{"next":"/items?page=3&per_page=2","prev":"/items?page=1&per_page=2","total":"7"}}`
+	if body != wantBody {
+		t.Errorf("body = %s\nwant   %s", body, wantBody)
+	}
+}
+
+func TestPagination_PassThrough(t *testing.T) {
+	const config = `{"items": "results", "next": "next"}`
+	const body = `{"results": [1], "next": "abc"}`
+
+	tests := []struct {
+		name string
+		resp *http.Response
+		want string
+	}{
+		{name: "error status", resp: jsonResponse(http.StatusInternalServerError, body), want: body},
+		{name: "partial content", resp: jsonResponse(http.StatusPartialContent, body), want: body},
+		{name: "not json", resp: upstreamResponse(http.StatusOK, http.Header{"Content-Type": {"text/csv"}}, "id\n1\n"), want: "id\n1\n"},
+		{name: "no items", resp: jsonResponse(http.StatusOK, `{"error": "none"}`), want: `{"error": "none"}`},
+		{name: "items not an array", resp: jsonResponse(http.StatusOK, `{"results": {"id": 1}}`), want: `{"results": {"id": 1}}`},
+		{name: "malformed", resp: jsonResponse(http.StatusOK, `{"results": [1`), want: `{"results": [1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := paginate(t, config, "/items", tt.resp); got != tt.want {
+				t.Errorf("body = %q, want it unchanged", got)
+			}
+		})
+	}
+
+	// Bodies over max_body_bytes are left alone
+	resp := jsonResponse(http.StatusOK, body)
+	if got := paginate(t, `{"items": "results", "max_body_bytes": 8}`, "/items", resp); got != body {
+		t.Errorf("oversized body = %q, want it unchanged", got)
+	}
+}
+
+func TestPagination_Config(t *testing.T) {
+	for _, config := range []string{
+		`{"format": "xml"}`,
+		`{"cursor_param": ""}`,
+		`{"data_key": "items", "pagination_key": "items"}`,
+		`{"max_body_bytes": 0}`,
+		`{"link_header": true, "next": "next"}`,
+	} {
+		if _, err := NewPaginationPlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewPaginationPlugin(%s) succeeded, want error", config)
+		}
+	}
+}
@@ -0,0 +1,591 @@
+// Package builtin - PASETO authentication plugin
+//
+// This plugin authenticates requests carrying a PASETO (Platform-Agnostic
+// Security Token) bearer token, for teams that use PASETO instead of JWT.
+//
+// Features:
+//   - v2.public and v4.public tokens (Ed25519 signatures)
+//   - Multiple public keys for rotation (any key may verify)
+//   - exp / nbf validation with clock leeway, plus issuer and audience checks
+//   - v4 implicit assertions
+//   - consumer_id taken from a claim (default "sub") for downstream plugins
+//   - Required scopes and roles (403 insufficient_scope when missing)
+//   - Claims copied into upstream request headers
+//
+// Local (symmetric, encrypted) tokens are not supported: v2.local and
+// v4.local need XChaCha20, which is not in the Go standard library. They
+// are rejected with 401 and cannot be enabled in the config.
+//
+// Configuration Example:
+//
+//	{
+//	  "versions": ["v4"],
+//	  "public_keys": ["<hex or base64 Ed25519 public key, or PEM>"],
+//	  "issuer": "https://auth.internal",
+//	  "audiences": ["orders-api"],
+//	  "consumer_claim": "sub",
+//	  "leeway": "30s",
+//	  "header_name": "Authorization",
+//	  "hide_credentials": false,
+//	  "required_scopes": ["orders:read"],
+//	  "required_roles": ["support", "admin"],
+//	  "claims_to_headers": {"tenant": "X-Tenant-ID", "email": "X-User-Email"}
+//	}
+//
+// Scopes are read from the "scope" claim (space-separated) or "scp", roles
+// from roles_claim (a list or space-separated string). Every required
+// scope must be present, and at least one required role. Tokens that
+// don't qualify get 403 with an RFC 6750 insufficient_scope challenge.
+//
+// claims_to_headers sets each header to its claim's value (strings as-is,
+// lists comma-separated, objects as JSON) and removes it when the claim is
+// missing, so clients can't supply the headers themselves.
+//
+// On success the plugin sets plugin.KeyConsumerID and
+// plugin.KeyTokenClaims (the decoded claims map) in the context metadata.
+package builtin
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// PasetoAuthPlugin verifies PASETO public tokens.
+type PasetoAuthPlugin struct {
+	config    PasetoAuthConfig
+	keys      []ed25519.PublicKey
+	versions  map[string]bool
+	audiences map[string]bool
+	leeway    time.Duration
+}
+
+// PasetoAuthConfig holds configuration for the PASETO auth plugin.
+type PasetoAuthConfig struct {
+	// Versions are the accepted protocol versions ("v2", "v4")
+	// Default: ["v4"]
+	Versions []string `json:"versions"`
+
+	// PublicKeys are Ed25519 public keys, as hex, base64 or PEM
+	// A token is accepted if any key verifies it (key rotation)
+	// Required
+	PublicKeys []string `json:"public_keys"`
+
+	// ImplicitAssertion is bound into v4 signatures but not sent in the token
+	// Default: "" (none)
+	ImplicitAssertion string `json:"implicit_assertion"`
+
+	// Issuer, when set, must equal the "iss" claim
+	// Default: "" (not checked)
+	Issuer string `json:"issuer"`
+
+	// Audiences, when set, must contain the "aud" claim
+	// Default: [] (not checked)
+	Audiences []string `json:"audiences"`
+
+	// RequireExpiration rejects tokens without an "exp" claim
+	// Default: true
+	RequireExpiration bool `json:"require_expiration"`
+
+	// Leeway tolerates clock skew when checking exp and nbf
+	// Default: "30s"
+	Leeway string `json:"leeway"`
+
+	// ConsumerClaim is the claim copied to consumer_id
+	// Default: "sub"
+	ConsumerClaim string `json:"consumer_claim"`
+
+	// HeaderName is the request header carrying "Bearer <token>"
+	// Default: "Authorization"
+	HeaderName string `json:"header_name"`
+
+	// HideCredentials removes the token header before proxying
+	// Default: false
+	HideCredentials bool `json:"hide_credentials"`
+
+	// RequiredScopes must all be in the token's scopes
+	// Default: [] (not checked)
+	RequiredScopes []string `json:"required_scopes"`
+
+	// RequiredRoles, when set, must include one of the token's roles
+	// Default: [] (not checked)
+	RequiredRoles []string `json:"required_roles"`
+
+	// RolesClaim is the claim holding the token's roles
+	// Default: "roles"
+	RolesClaim string `json:"roles_claim"`
+
+	// ClaimsToHeaders maps claims to the upstream request headers they are
+	// copied into
+	// Default: {} (none)
+	ClaimsToHeaders map[string]string `json:"claims_to_headers"`
+}
+
+// DefaultPasetoAuthConfig returns sensible defaults.
+func DefaultPasetoAuthConfig() PasetoAuthConfig {
+	return PasetoAuthConfig{
+		Versions:          []string{"v4"},
+		RequireExpiration: true,
+		Leeway:            "30s",
+		ConsumerClaim:     "sub",
+		HeaderName:        "Authorization",
+		RolesClaim:        "roles",
+	}
+}
+
+// NewPasetoAuthPlugin creates a new PASETO auth plugin.
+//
+// This is the factory function registered with the plugin registry.
+func NewPasetoAuthPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := DefaultPasetoAuthConfig()
+
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid paseto-auth config: %w", err)
+		}
+	}
+
+	if len(config.Versions) == 0 {
+		return nil, fmt.Errorf("at least one version is required")
+	}
+	versions := make(map[string]bool, len(config.Versions))
+	for _, v := range config.Versions {
+		v = strings.ToLower(v)
+		if v != "v2" && v != "v4" {
+			return nil, fmt.Errorf("unsupported version '%s' (must be v2 or v4)", v)
+		}
+		versions[v] = true
+	}
+
+	if len(config.PublicKeys) == 0 {
+		return nil, fmt.Errorf("at least one public key is required")
+	}
+	keys := make([]ed25519.PublicKey, 0, len(config.PublicKeys))
+	for i, encoded := range config.PublicKeys {
+		key, err := parseEd25519PublicKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public_keys[%d]: %w", i, err)
+		}
+		keys = append(keys, key)
+	}
+
+	leeway, err := time.ParseDuration(config.Leeway)
+	if err != nil || leeway < 0 {
+		return nil, fmt.Errorf("invalid leeway '%s'", config.Leeway)
+	}
+	if config.HeaderName == "" {
+		return nil, fmt.Errorf("header_name is required")
+	}
+	if config.ConsumerClaim == "" {
+		return nil, fmt.Errorf("consumer_claim is required")
+	}
+	if len(config.RequiredRoles) > 0 && config.RolesClaim == "" {
+		return nil, fmt.Errorf("roles_claim is required with required_roles")
+	}
+	for _, scope := range config.RequiredScopes {
+		if scope == "" || strings.ContainsAny(scope, " \"") {
+			return nil, fmt.Errorf("invalid required scope '%s'", scope)
+		}
+	}
+	for claim, header := range config.ClaimsToHeaders {
+		if claim == "" || header == "" {
+			return nil, fmt.Errorf("claims_to_headers entries need a claim and a header")
+		}
+		if strings.EqualFold(header, config.HeaderName) {
+			return nil, fmt.Errorf("claims_to_headers can't overwrite %s", config.HeaderName)
+		}
+	}
+
+	audiences := make(map[string]bool, len(config.Audiences))
+	for _, aud := range config.Audiences {
+		audiences[aud] = true
+	}
+
+	log.Info().
+		Str("component", "plugin").
+		Str("plugin", "paseto-auth").
+		Strs("versions", config.Versions).
+		Int("keys", len(keys)).
+		Str("issuer", config.Issuer).
+		Msg("Initializing PASETO auth plugin")
+
+	return &PasetoAuthPlugin{
+		config:    config,
+		keys:      keys,
+		versions:  versions,
+		audiences: audiences,
+		leeway:    leeway,
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *PasetoAuthPlugin) Name() string {
+	return "paseto-auth"
+}
+
+// Execute runs the PASETO auth plugin.
+//
+// BeforeRequest: verify the token and expose its claims.
+// AfterResponse: nothing.
+func (p *PasetoAuthPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase == plugin.PhaseAfterResponse {
+		return nil
+	}
+
+	token := bearerToken(ctx.Request, p.config.HeaderName)
+	if token == "" {
+		rejectToken(ctx, "", "Missing bearer token")
+		return nil
+	}
+
+	claims, err := p.verify(token, time.Now())
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Str("component", "plugin").
+			Str("plugin", "paseto-auth").
+			Msg("PASETO verification failed")
+		rejectToken(ctx, "invalid_token", "Invalid token")
+		return nil
+	}
+
+	consumerID, _ := claims[p.config.ConsumerClaim].(string)
+	if consumerID == "" {
+		rejectToken(ctx, "invalid_token", fmt.Sprintf("Token has no %s claim", p.config.ConsumerClaim))
+		return nil
+	}
+
+	if missing := missingScopes(claims, p.config.RequiredScopes); len(missing) > 0 {
+		ctx.Decide("token lacks scopes " + strings.Join(missing, " "))
+		forbidToken(ctx, p.config.RequiredScopes)
+		return nil
+	}
+	if len(p.config.RequiredRoles) > 0 && !hasAnyString(claimStrings(claims, p.config.RolesClaim), p.config.RequiredRoles) {
+		ctx.Decide("token has none of the roles " + strings.Join(p.config.RequiredRoles, " "))
+		forbidToken(ctx, p.config.RequiredScopes)
+		return nil
+	}
+
+	plugin.KeyConsumerID.Set(ctx, consumerID)
+	plugin.KeyTokenClaims.Set(ctx, claims)
+	plugin.KeyAuthMechanism.Set(ctx, authMechanismPaseto)
+
+	for claim, header := range p.config.ClaimsToHeaders {
+		ctx.Request.Header.Del(header)
+		if value, ok := claimHeaderValue(claims[claim]); ok {
+			ctx.Request.Header.Set(header, value)
+		}
+	}
+
+	if p.config.HideCredentials {
+		ctx.Request.Header.Del(p.config.HeaderName)
+	}
+
+	log.Debug().
+		Str("component", "plugin").
+		Str("plugin", "paseto-auth").
+		Str("consumer_id", consumerID).
+		Msg("PASETO token verified")
+
+	return nil
+}
+
+// ============================================================================
+// Verification
+// ============================================================================
+
+// Errors returned by token verification. They are logged, never sent to
+// the client.
+var (
+	errPasetoMalformed = errors.New("malformed token")
+	errPasetoLocal     = errors.New("local (encrypted) tokens are not supported")
+	errPasetoSignature = errors.New("signature verification failed")
+)
+
+// verify checks the token's signature and registered claims, returning
+// the decoded claims.
+func (p *PasetoAuthPlugin) verify(token string, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 && len(parts) != 4 {
+		return nil, errPasetoMalformed
+	}
+
+	version, purpose := parts[0], parts[1]
+	if purpose == "local" {
+		return nil, errPasetoLocal
+	}
+	if purpose != "public" {
+		return nil, errPasetoMalformed
+	}
+	if !p.versions[version] {
+		return nil, fmt.Errorf("version %s not accepted", version)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(payload) < ed25519.SignatureSize {
+		return nil, errPasetoMalformed
+	}
+	var footer []byte
+	if len(parts) == 4 {
+		if footer, err = base64.RawURLEncoding.DecodeString(parts[3]); err != nil {
+			return nil, errPasetoMalformed
+		}
+	}
+
+	message := payload[:len(payload)-ed25519.SignatureSize]
+	signature := payload[len(payload)-ed25519.SignatureSize:]
+
+	header := []byte(version + ".public.")
+	var signed []byte
+	if version == "v4" {
+		signed = pae(header, message, footer, []byte(p.config.ImplicitAssertion))
+	} else {
+		signed = pae(header, message, footer)
+	}
+
+	verified := false
+	for _, key := range p.keys {
+		if ed25519.Verify(key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errPasetoSignature
+	}
+
+	var claims map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(message))
+	decoder.UseNumber()
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("invalid claims: %w", err)
+	}
+
+	if err := p.validateClaims(claims, now); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// validateClaims checks exp, nbf, iss and aud. PASETO times are RFC 3339
+// strings.
+func (p *PasetoAuthPlugin) validateClaims(claims map[string]interface{}, now time.Time) error {
+	exp, err := claimTime(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if exp.IsZero() && p.config.RequireExpiration {
+		return fmt.Errorf("token has no exp claim")
+	}
+	if !exp.IsZero() && now.After(exp.Add(p.leeway)) {
+		return fmt.Errorf("token expired at %s", exp.Format(time.RFC3339))
+	}
+
+	nbf, err := claimTime(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	if !nbf.IsZero() && now.Add(p.leeway).Before(nbf) {
+		return fmt.Errorf("token not valid before %s", nbf.Format(time.RFC3339))
+	}
+
+	if p.config.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != p.config.Issuer {
+			return fmt.Errorf("unexpected issuer '%s'", iss)
+		}
+	}
+
+	if len(p.audiences) > 0 {
+		if aud, _ := claims["aud"].(string); !p.audiences[aud] {
+			return fmt.Errorf("unexpected audience '%s'", aud)
+		}
+	}
+
+	return nil
+}
+
+// claimTime parses an RFC 3339 time claim. A missing claim returns the
+// zero time.
+func claimTime(claims map[string]interface{}, name string) (time.Time, error) {
+	raw, ok := claims[name]
+	if !ok {
+		return time.Time{}, nil
+	}
+	s, ok := raw.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%s claim must be an RFC 3339 string", name)
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s claim: %w", name, err)
+	}
+	return t, nil
+}
+
+// pae is PASETO's Pre-Authentication Encoding: the piece count followed by
+// each piece prefixed with its length, all as little-endian uint64.
+func pae(pieces ...[]byte) []byte {
+	size := 8
+	for _, piece := range pieces {
+		size += 8 + len(piece)
+	}
+
+	out := make([]byte, 0, size)
+	out = binary.LittleEndian.AppendUint64(out, uint64(len(pieces))&(1<<63-1))
+	for _, piece := range pieces {
+		out = binary.LittleEndian.AppendUint64(out, uint64(len(piece))&(1<<63-1))
+		out = append(out, piece...)
+	}
+	return out
+}
+
+// parseEd25519PublicKey accepts a PEM "PUBLIC KEY" block or a raw 32-byte
+// key encoded as hex or (URL-safe or standard) base64.
+func parseEd25519PublicKey(encoded string) (ed25519.PublicKey, error) {
+	encoded = strings.TrimSpace(encoded)
+
+	if block, _ := pem.Decode([]byte(encoded)); block != nil {
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PEM key: %w", err)
+		}
+		key, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("PEM key is not Ed25519")
+		}
+		return key, nil
+	}
+
+	var raw []byte
+	if decoded, err := hex.DecodeString(encoded); err == nil {
+		raw = decoded
+	} else if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+		raw = decoded
+	} else if decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "=")); err == nil {
+		raw = decoded
+	} else {
+		return nil, fmt.Errorf("key is not hex, base64 or PEM")
+	}
+
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", ed25519.PublicKeySize, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// ============================================================================
+// Helpers shared by token auth plugins
+// ============================================================================
+
+// bearerToken extracts the token from a "Bearer <token>" header.
+func bearerToken(r *http.Request, headerName string) string {
+	value := strings.TrimSpace(r.Header.Get(headerName))
+	scheme, token, ok := strings.Cut(value, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// rejectToken aborts with 401 and an RFC 6750 WWW-Authenticate challenge.
+// errorCode is empty when no credentials were sent.
+func rejectToken(ctx *plugin.Context, errorCode, message string) {
+	challenge := `Bearer realm="switchboard"`
+	if errorCode != "" {
+		challenge += `, error="` + errorCode + `"`
+	}
+	ctx.Response.Header().Set("WWW-Authenticate", challenge)
+	ctx.Abort(http.StatusUnauthorized, message)
+}
+
+// forbidToken aborts with 403 and an RFC 6750 insufficient_scope
+// challenge listing the scopes the route requires.
+func forbidToken(ctx *plugin.Context, scopes []string) {
+	challenge := `Bearer realm="switchboard", error="insufficient_scope"`
+	if len(scopes) > 0 {
+		challenge += `, scope="` + strings.Join(scopes, " ") + `"`
+	}
+	ctx.Response.Header().Set("WWW-Authenticate", challenge)
+	ctx.Abort(http.StatusForbidden, "Insufficient scope")
+}
+
+// missingScopes returns the required scopes the token doesn't have.
+func missingScopes(claims map[string]interface{}, required []string) []string {
+	if len(required) == 0 {
+		return nil
+	}
+	granted := make(map[string]bool)
+	for _, scope := range tokenScopes(claims) {
+		granted[scope] = true
+	}
+	var missing []string
+	for _, scope := range required {
+		if !granted[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// hasAnyString reports whether values and wanted share an element.
+func hasAnyString(values, wanted []string) bool {
+	for _, v := range values {
+		for _, w := range wanted {
+			if v == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimHeaderValue formats a claim as a header value: strings as-is,
+// numbers and booleans in JSON notation, lists of strings comma-separated
+// and anything else as JSON. It reports false for missing claims and for
+// strings that can't be sent in a header.
+func claimHeaderValue(value interface{}) (string, bool) {
+	var formatted string
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		formatted = v
+	case []interface{}:
+		strs := stringValues(v)
+		if len(strs) != len(v) {
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return "", false
+			}
+			formatted = string(encoded)
+		} else {
+			formatted = strings.Join(strs, ", ")
+		}
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		formatted = string(encoded)
+	}
+
+	for i := 0; i < len(formatted); i++ {
+		if c := formatted[i]; (c < ' ' && c != '\t') || c == 0x7f {
+			return "", false
+		}
+	}
+	return formatted, formatted != ""
+}
@@ -0,0 +1,230 @@
+package builtin
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// The PASETO test-vector key pair (v2 and v4 public vectors).
+const (
+	pasetoVectorSecretKey = "b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a37741eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2"
+	pasetoVectorPublicKey = "1eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2"
+)
+
+// signPaseto builds a public token over claims with key.
+func signPaseto(t *testing.T, key ed25519.PrivateKey, version string, claims map[string]interface{}, footer, implicit string) string {
+	t.Helper()
+	message, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	header := []byte(version + ".public.")
+	var signed []byte
+	if version == "v4" {
+		signed = pae(header, message, []byte(footer), []byte(implicit))
+	} else {
+		signed = pae(header, message, []byte(footer))
+	}
+	token := string(header) + base64.RawURLEncoding.EncodeToString(append(message, ed25519.Sign(key, signed)...))
+	if footer != "" {
+		token += "." + base64.RawURLEncoding.EncodeToString([]byte(footer))
+	}
+	return token
+}
+
+func newTestPasetoPlugin(t *testing.T, config string) *PasetoAuthPlugin {
+	t.Helper()
+	p, err := NewPasetoAuthPlugin(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewPasetoAuthPlugin() error = %v", err)
+	}
+	return p.(*PasetoAuthPlugin)
+}
+
+func TestPasetoAuth_Vectors(t *testing.T) {
+	const footer = `eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZUaW9lQTlDT2NTeTlIU096am1pNXJjIn0`
+
+	tests := []struct {
+		name     string
+		token    string
+		implicit string
+		wantErr  bool
+	}{
+		{
+			name:  "2-S-1",
+			token: "v2.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAxOS0wMS0wMVQwMDowMDowMCswMDowMCJ9HQr8URrGntTu7Dz9J2IF23d1M7-9lH9xiqdGyJNvzp4angPW5Esc7C5huy_M8I8_DjJK2ZXC2SUYuOFM-Q_5Cw",
+		},
+		{
+			name:  "2-S-2 footer",
+			token: "v2.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAxOS0wMS0wMVQwMDowMDowMCswMDowMCJ9uYgevIO5sS_EbOWVmcLIKo7hEeASeQ1Xe3BwTo76nui-sNsodbtXcXEnWvtZK0KwBhrzb4FmJoS1G7fbnsW7AA." + footer,
+		},
+		{
+			name:  "4-S-1",
+			token: "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA",
+		},
+		{
+			name:  "4-S-2 footer",
+			token: "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bBys2x0WqDAvrD_ulH0uFlOubNiPXyIkGFRacPFWT-r1jNZDy11s1MBKshZegNCPisty8I6eg-DfwivzQW7yCA." + footer,
+		},
+		{
+			name:     "4-S-3 footer and implicit assertion",
+			token:    "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9L2t9yboWVHmRrT3I3niChnSLMBpYI9KKv5A3HvLVq1JBqipLvqSJzV38A9AyxBsf-uxA7LJNqiefvCdXBUUnCg." + footer,
+			implicit: `{"test-vector":"4-S-3"}`,
+		},
+		{
+			name:    "4-S-3 without the implicit assertion",
+			token:   "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9L2t9yboWVHmRrT3I3niChnSLMBpYI9KKv5A3HvLVq1JBqipLvqSJzV38A9AyxBsf-uxA7LJNqiefvCdXBUUnCg." + footer,
+			wantErr: true,
+		},
+		{
+			name:     "4-S-2 with an unexpected implicit assertion",
+			token:    "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bBys2x0WqDAvrD_ulH0uFlOubNiPXyIkGFRacPFWT-r1jNZDy11s1MBKshZegNCPisty8I6eg-DfwivzQW7yCA." + footer,
+			implicit: `{"test-vector":"4-S-3"}`,
+			wantErr:  true,
+		},
+		{
+			name:    "4-S-2 without its footer",
+			token:   "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bBys2x0WqDAvrD_ulH0uFlOubNiPXyIkGFRacPFWT-r1jNZDy11s1MBKshZegNCPisty8I6eg-DfwivzQW7yCA",
+			wantErr: true,
+		},
+	}
+
+	// The vectors expire in 2019 and 2022
+	now := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, _ := json.Marshal(map[string]interface{}{
+				"versions":           []string{"v2", "v4"},
+				"public_keys":        []string{pasetoVectorPublicKey},
+				"implicit_assertion": tt.implicit,
+			})
+			p := newTestPasetoPlugin(t, string(config))
+
+			claims, err := p.verify(tt.token, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("verify() = %v, want an error", claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verify() error = %v", err)
+			}
+			if got := claims["data"]; got != "this is a signed message" {
+				t.Errorf("data claim = %v, want the signed message", got)
+			}
+		})
+	}
+}
+
+func TestPasetoAuth_Execute(t *testing.T) {
+	seed, _ := hex.DecodeString(pasetoVectorSecretKey[:64])
+	key := ed25519.NewKeyFromSeed(seed)
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+
+	now := time.Now().UTC()
+	at := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"sub":   "c-42",
+			"iss":   "https://auth.internal",
+			"aud":   "orders-api",
+			"exp":   at(time.Hour),
+			"scope": "orders:read orders:write",
+		}
+	}
+	with := func(name string, value interface{}) map[string]interface{} {
+		claims := valid()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	p := newTestPasetoPlugin(t, `{
+		"versions": ["v4"],
+		"public_keys": ["`+pasetoVectorPublicKey+`"],
+		"issuer": "https://auth.internal",
+		"audiences": ["orders-api"],
+		"leeway": "30s",
+		"required_scopes": ["orders:read"],
+		"claims_to_headers": {"aud": "X-Audience"}
+	}`)
+
+	tampered := signPaseto(t, key, "v4", valid(), "", "")
+	payload, _ := base64.RawURLEncoding.DecodeString(strings.Split(tampered, ".")[2])
+	payload = []byte(strings.Replace(string(payload), "c-42", "c-99", 1))
+	tampered = "v4.public." + base64.RawURLEncoding.EncodeToString(payload)
+
+	tests := []struct {
+		name   string
+		header string
+		want   int // 0 = allowed
+	}{
+		{name: "valid", header: "Bearer " + signPaseto(t, key, "v4", valid(), "", "")},
+		{name: "valid with footer", header: "Bearer " + signPaseto(t, key, "v4", valid(), `{"kid":"k1"}`, "")},
+		{name: "expired within leeway", header: "Bearer " + signPaseto(t, key, "v4", with("exp", at(-10*time.Second)), "", "")},
+		{name: "missing header", want: http.StatusUnauthorized},
+		{name: "not a bearer token", header: "Basic dXNlcjpwYXNz", want: http.StatusUnauthorized},
+		{name: "expired", header: "Bearer " + signPaseto(t, key, "v4", with("exp", at(-time.Minute)), "", ""), want: http.StatusUnauthorized},
+		{name: "no exp", header: "Bearer " + signPaseto(t, key, "v4", with("exp", nil), "", ""), want: http.StatusUnauthorized},
+		{name: "unix exp", header: "Bearer " + signPaseto(t, key, "v4", with("exp", now.Add(time.Hour).Unix()), "", ""), want: http.StatusUnauthorized},
+		{name: "future nbf", header: "Bearer " + signPaseto(t, key, "v4", with("nbf", at(time.Minute)), "", ""), want: http.StatusUnauthorized},
+		{name: "wrong audience", header: "Bearer " + signPaseto(t, key, "v4", with("aud", "billing-api"), "", ""), want: http.StatusUnauthorized},
+		{name: "wrong issuer", header: "Bearer " + signPaseto(t, key, "v4", with("iss", "https://evil"), "", ""), want: http.StatusUnauthorized},
+		{name: "no subject", header: "Bearer " + signPaseto(t, key, "v4", with("sub", nil), "", ""), want: http.StatusUnauthorized},
+		{name: "missing scope", header: "Bearer " + signPaseto(t, key, "v4", with("scope", "orders:write"), "", ""), want: http.StatusForbidden},
+		{name: "wrong key", header: "Bearer " + signPaseto(t, otherKey, "v4", valid(), "", ""), want: http.StatusUnauthorized},
+		{name: "version not accepted", header: "Bearer " + signPaseto(t, key, "v2", valid(), "", ""), want: http.StatusUnauthorized},
+		{name: "unknown version prefix", header: "Bearer v3" + strings.TrimPrefix(signPaseto(t, key, "v4", valid(), "", ""), "v4"), want: http.StatusUnauthorized},
+		{name: "local token", header: "Bearer v4.local." + base64.RawURLEncoding.EncodeToString(make([]byte, 96)), want: http.StatusUnauthorized},
+		{name: "tampered payload", header: "Bearer " + tampered, want: http.StatusUnauthorized},
+		{name: "malformed", header: "Bearer v4.public", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/orders", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			r.Header.Set("X-Audience", "spoofed")
+			ctx := newTestContext(r, "r1")
+
+			if err := p.Execute(ctx); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got := ctx.AbortStatusCode(); ctx.IsAborted() != (tt.want != 0) || got != tt.want {
+				t.Fatalf("aborted = %v with %d (%s), want %d", ctx.IsAborted(), got, ctx.AbortMessage(), tt.want)
+			}
+
+			if tt.want != 0 {
+				if ctx.Response.Header().Get("WWW-Authenticate") == "" {
+					t.Error("rejection has no WWW-Authenticate challenge")
+				}
+				if _, ok := plugin.KeyConsumerID.Get(ctx); ok {
+					t.Error("consumer_id set for a rejected token")
+				}
+				return
+			}
+			if got := plugin.KeyConsumerID.Value(ctx); got != "c-42" {
+				t.Errorf("consumer_id = %q, want c-42", got)
+			}
+			if got := r.Header.Get("X-Audience"); got != "orders-api" {
+				t.Errorf("X-Audience = %q, want the aud claim", got)
+			}
+		})
+	}
+}
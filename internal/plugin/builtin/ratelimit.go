@@ -28,15 +28,14 @@
 package builtin
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
-	"net"
-	"net/http"
-	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/saidutt46/switchboard-gateway/internal/clientip"
 	"github.com/saidutt46/switchboard-gateway/internal/plugin"
 	"github.com/saidutt46/switchboard-gateway/internal/ratelimit"
 )
@@ -339,12 +338,17 @@ func (p *RateLimitPlugin) Execute(ctx *plugin.Context) error {
 	return nil
 }
 
+// CheckHealth pings the plugin's Redis store (see plugin.HealthChecker).
+func (p *RateLimitPlugin) CheckHealth(ctx context.Context) error {
+	return p.store.Ping(ctx)
+}
+
 // getIdentifier extracts the identifier for rate limiting.
 //
 // Hierarchy (configurable via config.Identifier):
 //  1. consumer_id (from authentication plugin)
 //  2. api_key (from X-API-Key header, hashed)
-//  3. ip (from X-Forwarded-For or RemoteAddr)
+//  3. ip (client IP resolved via trusted proxies)
 func (p *RateLimitPlugin) getIdentifier(ctx *plugin.Context) string {
 	// If specific identifier is requested, try that first
 	if p.config.Identifier != "auto" {
@@ -355,7 +359,7 @@ func (p *RateLimitPlugin) getIdentifier(ctx *plugin.Context) string {
 
 	// Auto mode: try in priority order
 	// Priority 1: Consumer ID (from auth plugin)
-	if consumerID := ctx.GetString("consumer_id"); consumerID != "" {
+	if consumerID := plugin.KeyConsumerID.Value(ctx); consumerID != "" {
 		return "consumer:" + consumerID
 	}
 
@@ -366,7 +370,7 @@ func (p *RateLimitPlugin) getIdentifier(ctx *plugin.Context) string {
 	}
 
 	// Priority 3: IP Address (fallback)
-	ip := getClientIP(ctx.Request)
+	ip := clientip.FromRequest(ctx.Request)
 	return "ip:" + ip
 }
 
@@ -374,7 +378,7 @@ func (p *RateLimitPlugin) getIdentifier(ctx *plugin.Context) string {
 func (p *RateLimitPlugin) tryGetIdentifier(ctx *plugin.Context, identifierType string) string {
 	switch identifierType {
 	case "consumer_id":
-		if consumerID := ctx.GetString("consumer_id"); consumerID != "" {
+		if consumerID := plugin.KeyConsumerID.Value(ctx); consumerID != "" {
 			return "consumer:" + consumerID
 		}
 
@@ -385,7 +389,7 @@ func (p *RateLimitPlugin) tryGetIdentifier(ctx *plugin.Context, identifierType s
 		}
 
 	case "ip":
-		ip := getClientIP(ctx.Request)
+		ip := clientip.FromRequest(ctx.Request)
 		return "ip:" + ip
 	}
 
@@ -400,36 +404,6 @@ func hashAPIKey(apiKey string) string {
 	return fmt.Sprintf("%x", hash[:8]) // Use first 8 bytes (16 hex chars)
 }
 
-// getClientIP extracts the client IP address from the request.
-//
-// Checks in order:
-//  1. X-Forwarded-For header (proxy/load balancer)
-//  2. X-Real-IP header (nginx)
-//  3. RemoteAddr (direct connection)
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For (most common with proxies)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For can be a list: "client, proxy1, proxy2"
-		// Take the first IP (original client)
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-
-	// Check X-Real-IP (nginx)
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-
-	// Fallback to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr // Return as-is if can't parse
-	}
-	return ip
-}
-
 // addRateLimitHeaders adds standard rate limit headers to the response.
 //
 // Headers:
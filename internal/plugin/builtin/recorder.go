@@ -0,0 +1,168 @@
+// Package builtin - Request Recorder plugin for traffic capture
+//
+// This plugin samples requests on a route and stores them (method, path,
+// headers, optional body and the original response status) so they can
+// be re-sent against another environment with `gateway replay`.
+//
+// Features:
+//   - Percentage-based sampling
+//   - Optional request body capture (size-limited)
+//   - Sensitive headers redacted before storage
+//   - Asynchronous writes - recording never slows down the request
+//
+// Configuration Example:
+//
+//	{
+//	  "sample_rate": 5,
+//	  "record_body": true,
+//	  "max_body_bytes": 65536,
+//	  "redact_headers": ["Authorization", "Cookie", "X-API-Key"]
+//	}
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/recording"
+)
+
+// Context metadata key holding the in-progress recording.
+var recordingKey = plugin.RegisterKeyWithAlias[*database.RecordedRequest]("request-recorder.recording", "recording")
+
+// RequestRecorderPlugin samples requests for later replay.
+type RequestRecorderPlugin struct {
+	config   RequestRecorderConfig
+	recorder *recording.Recorder
+	redact   map[string]bool
+}
+
+// RequestRecorderConfig holds configuration for the request recorder plugin.
+type RequestRecorderConfig struct {
+	// SampleRate is the percentage of requests (0-100) to record
+	// Default: 1
+	SampleRate float64 `json:"sample_rate"`
+
+	// RecordBody captures request bodies
+	// Default: false
+	RecordBody bool `json:"record_body"`
+
+	// MaxBodyBytes is the largest body stored; larger bodies are truncated
+	// Default: 65536 (64 KiB)
+	MaxBodyBytes int `json:"max_body_bytes"`
+
+	// RedactHeaders are stored as "[REDACTED]"
+	// Default: Authorization, Cookie, X-API-Key, Proxy-Authorization
+	RedactHeaders []string `json:"redact_headers"`
+}
+
+// DefaultRequestRecorderConfig returns sensible defaults.
+func DefaultRequestRecorderConfig() RequestRecorderConfig {
+	return RequestRecorderConfig{
+		SampleRate:    1,
+		RecordBody:    false,
+		MaxBodyBytes:  64 << 10,
+		RedactHeaders: []string{"Authorization", "Cookie", "X-API-Key", "Proxy-Authorization"},
+	}
+}
+
+// NewRequestRecorderFactory returns the request-recorder plugin factory.
+//
+// All instances share one Recorder (and its Postgres writer).
+func NewRequestRecorderFactory(recorder *recording.Recorder) plugin.PluginFactory {
+	return func(configJSON json.RawMessage) (plugin.Plugin, error) {
+		config := DefaultRequestRecorderConfig()
+
+		if len(configJSON) > 0 {
+			if err := json.Unmarshal(configJSON, &config); err != nil {
+				return nil, fmt.Errorf("invalid request-recorder config: %w", err)
+			}
+		}
+
+		if config.SampleRate < 0 || config.SampleRate > 100 {
+			return nil, fmt.Errorf("sample_rate must be between 0 and 100")
+		}
+		if config.MaxBodyBytes <= 0 {
+			return nil, fmt.Errorf("max_body_bytes must be positive")
+		}
+
+		redact := make(map[string]bool, len(config.RedactHeaders))
+		for _, h := range config.RedactHeaders {
+			redact[http.CanonicalHeaderKey(h)] = true
+		}
+
+		return &RequestRecorderPlugin{
+			config:   config,
+			recorder: recorder,
+			redact:   redact,
+		}, nil
+	}
+}
+
+// Name returns the plugin identifier.
+func (p *RequestRecorderPlugin) Name() string {
+	return "request-recorder"
+}
+
+// Execute runs the request recorder plugin.
+//
+// BeforeRequest: sample and snapshot the request.
+// AfterResponse: add the response status and queue the recording.
+func (p *RequestRecorderPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase == plugin.PhaseAfterResponse {
+		rec, ok := recordingKey.Get(ctx)
+		if !ok {
+			return nil
+		}
+		rec.StatusCode = ctx.Response.StatusCode()
+		rec.DurationMs = ctx.Elapsed().Milliseconds()
+		p.recorder.Record(rec)
+		return nil
+	}
+
+	if rand.Float64()*100 >= p.config.SampleRate {
+		return nil
+	}
+
+	r := ctx.Request
+	rec := &database.RecordedRequest{
+		RouteID: ctx.Route.ID,
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   r.URL.RawQuery,
+		Host:    r.Host,
+		Headers: make(map[string][]string, len(r.Header)),
+	}
+
+	for name, values := range r.Header {
+		if p.redact[http.CanonicalHeaderKey(name)] {
+			rec.Headers[name] = []string{recording.RedactedValue}
+			continue
+		}
+		rec.Headers[name] = append([]string(nil), values...)
+	}
+
+	if p.config.RecordBody {
+		buffered, err := bodybuffer.ReadRequest(r, int64(p.config.MaxBodyBytes))
+		if err != nil {
+			return fmt.Errorf("failed to read request body: %w", err)
+		}
+
+		body := buffered.Bytes()
+		if !buffered.Complete() {
+			body = body[:min(len(body), p.config.MaxBodyBytes)]
+			rec.Truncated = true
+		}
+		if len(body) > 0 {
+			rec.Body = body
+		}
+	}
+
+	recordingKey.Set(ctx, rec)
+	return nil
+}
@@ -0,0 +1,152 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/recording"
+)
+
+// memoryRecordingStore keeps recorded requests in memory.
+type memoryRecordingStore struct {
+	mu       sync.Mutex
+	requests []*database.RecordedRequest
+}
+
+func (s *memoryRecordingStore) InsertRecordedRequest(ctx context.Context, req *database.RecordedRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	return nil
+}
+
+// recordRequest runs a request-recorder built from config through both
+// phases of a POST of body answered with status, and returns what was
+// stored and the body passed upstream.
+func recordRequest(t *testing.T, config string, body string, status int) ([]*database.RecordedRequest, string) {
+	t.Helper()
+	store := &memoryRecordingStore{}
+	recorder := recording.NewRecorder(store, 10)
+	p, err := NewRequestRecorderFactory(recorder)(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewRequestRecorderFactory() error = %v", err)
+	}
+
+	r := httptest.NewRequest("POST", "/orders?dry_run=1", strings.NewReader(body))
+	r.Host = "api.example.com"
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer secret-token")
+	r.Header.Set("Cookie", "session=s-1")
+	r.Header.Set("X-Api-Key", "k-1")
+	r.Header.Set("X-Tenant-Secret", "t-1")
+	r.Header.Set("X-Request-Id", "req-1")
+	ctx := newTestContext(r, "r-orders")
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	upstream, _ := io.ReadAll(ctx.Request.Body)
+
+	ctx.Response.WriteHeader(status)
+	ctx.Phase = plugin.PhaseAfterResponse
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	recorder.Close()
+	return store.requests, string(upstream)
+}
+
+func TestRequestRecorder_Capture(t *testing.T) {
+	const body = `{"sku": "a-1", "quantity": 2}`
+	stored, upstream := recordRequest(t, `{"sample_rate": 100, "record_body": true}`, body, 201)
+
+	if upstream != body {
+		t.Errorf("upstream body = %q, want it intact after recording", upstream)
+	}
+	if len(stored) != 1 {
+		t.Fatalf("stored %d recordings, want 1", len(stored))
+	}
+	rec := stored[0]
+	if rec.RouteID != "r-orders" || rec.Method != "POST" || rec.Path != "/orders" || rec.Query != "dry_run=1" || rec.Host != "api.example.com" {
+		t.Errorf("recording = %s %s?%s on %s (route %s)", rec.Method, rec.Path, rec.Query, rec.Host, rec.RouteID)
+	}
+	if string(rec.Body) != body || rec.Truncated {
+		t.Errorf("body = %q (truncated %v), want the whole body", rec.Body, rec.Truncated)
+	}
+	if rec.StatusCode != 201 {
+		t.Errorf("status = %d, want the response's 201", rec.StatusCode)
+	}
+
+	// The default sensitive headers are redacted, the rest kept
+	want := map[string]string{
+		"Authorization":   recording.RedactedValue,
+		"Cookie":          recording.RedactedValue,
+		"X-Api-Key":       recording.RedactedValue,
+		"X-Tenant-Secret": "t-1",
+		"X-Request-Id":    "req-1",
+		"Content-Type":    "application/json",
+	}
+	for name, value := range want {
+		if got := rec.Headers[name]; len(got) != 1 || got[0] != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestRequestRecorder_Redaction(t *testing.T) {
+	stored, _ := recordRequest(t, `{"sample_rate": 100, "redact_headers": ["x-tenant-secret", "cookie"]}`, "{}", 200)
+	if len(stored) != 1 {
+		t.Fatalf("stored %d recordings, want 1", len(stored))
+	}
+	rec := stored[0]
+
+	// Configured headers replace the defaults
+	for name, value := range map[string]string{
+		"X-Tenant-Secret": recording.RedactedValue,
+		"Cookie":          recording.RedactedValue,
+		"Authorization":   "Bearer secret-token",
+	} {
+		if got := rec.Headers[name]; len(got) != 1 || got[0] != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if rec.Body != nil {
+		t.Errorf("body = %q, want none without record_body", rec.Body)
+	}
+}
+
+func TestRequestRecorder_Truncated(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	stored, upstream := recordRequest(t, `{"sample_rate": 100, "record_body": true, "max_body_bytes": 10}`, body, 200)
+	if upstream != body {
+		t.Errorf("upstream body has %d bytes, want all 100", len(upstream))
+	}
+	if len(stored) != 1 || string(stored[0].Body) != body[:10] || !stored[0].Truncated {
+		t.Errorf("recordings = %+v, want the first 10 bytes marked truncated", stored)
+	}
+}
+
+func TestRequestRecorder_Sampling(t *testing.T) {
+	if stored, _ := recordRequest(t, `{"sample_rate": 0}`, "{}", 200); len(stored) != 0 {
+		t.Errorf("stored %d recordings at sample_rate 0, want none", len(stored))
+	}
+}
+
+func TestRequestRecorder_Config(t *testing.T) {
+	for _, config := range []string{
+		`{"sample_rate": -1}`,
+		`{"sample_rate": 101}`,
+		`{"max_body_bytes": 0}`,
+	} {
+		if _, err := NewRequestRecorderFactory(nil)(json.RawMessage(config)); err == nil {
+			t.Errorf("NewRequestRecorderFactory()(%s) succeeded, want error", config)
+		}
+	}
+}
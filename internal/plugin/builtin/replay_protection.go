@@ -0,0 +1,310 @@
+// Package builtin - Replay protection plugin
+//
+// The replay-protection plugin rejects requests that were captured and
+// sent again. Each request carries a nonce and a timestamp:
+//   - The timestamp must be within max_skew of the gateway's clock, so old
+//     requests are refused outright
+//   - The nonce must not have been seen within the freshness window. Seen
+//     nonces are stored in Redis with SETNX for twice max_skew, long enough
+//     to outlive any timestamp that would still be accepted
+//
+// Nonces are unique per route. With scope "consumer" they are unique per
+// authenticated consumer instead (requests without one share the route's
+// namespace); raw headers and client IPs are never used, since whoever
+// replays a request can choose them.
+//
+// The plugin only checks freshness and uniqueness. Pair it with a plugin
+// that signs over the nonce and timestamp (HMAC auth, webhook signatures)
+// so they can't be changed by whoever replays the request.
+//
+// Configuration Example:
+//
+//	{
+//	  "nonce_header": "X-Nonce",
+//	  "timestamp_header": "X-Timestamp",
+//	  "timestamp_format": "unix",
+//	  "max_skew": "5m",
+//	  "min_nonce_length": 16,
+//	  "scope": "global",
+//	  "critical": true,
+//	  "redis_url": "redis://localhost:6379/0",
+//	  "key_prefix": "replay:"
+//	}
+//
+// Missing or malformed headers get 400; stale timestamps and replayed
+// nonces get 401. Checks are counted in
+// gateway_plugin_replay_protection_checks_total{route,result}.
+package builtin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/clientip"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/ratelimit"
+)
+
+// maxNonceLength bounds nonces so they can't be used to bloat Redis keys
+// or logs.
+const maxNonceLength = 256
+
+// ReplayProtectionPlugin rejects stale and replayed requests.
+type ReplayProtectionPlugin struct {
+	config  ReplayProtectionConfig
+	store   *ratelimit.RedisStore
+	maxSkew time.Duration
+	checks  *metrics.CounterVec
+}
+
+// ReplayProtectionConfig holds configuration for the replay protection
+// plugin.
+type ReplayProtectionConfig struct {
+	// NonceHeader carries a unique value per request
+	// Default: "X-Nonce"
+	NonceHeader string `json:"nonce_header"`
+
+	// TimestampHeader carries the time the request was made
+	// Default: "X-Timestamp"
+	TimestampHeader string `json:"timestamp_header"`
+
+	// TimestampFormat of TimestampHeader
+	// Options: "unix" (seconds), "unix_ms", "rfc3339"
+	// Default: "unix"
+	TimestampFormat string `json:"timestamp_format"`
+
+	// MaxSkew is how far the timestamp may be from the gateway's clock
+	// Default: "5m"
+	MaxSkew string `json:"max_skew"`
+
+	// MinNonceLength rejects nonces too short to be unique
+	// Default: 16
+	MinNonceLength int `json:"min_nonce_length"`
+
+	// Scope determines who shares a nonce namespace
+	// Options: "global" (everyone on the route), "consumer" (per
+	// authenticated consumer; "auto" is accepted as an alias)
+	// Default: "global"
+	Scope string `json:"scope"`
+
+	// Critical indicates if a Redis failure should stop the request
+	// Default: true (a replay check that can't run fails closed)
+	Critical bool `json:"critical"`
+
+	// RedisURL is the Redis connection string
+	// Default: "redis://localhost:6379/0"
+	RedisURL string `json:"redis_url"`
+
+	// KeyPrefix is prepended to all Redis keys
+	// Default: "replay:"
+	KeyPrefix string `json:"key_prefix"`
+}
+
+// DefaultReplayProtectionConfig returns sensible defaults.
+func DefaultReplayProtectionConfig() ReplayProtectionConfig {
+	return ReplayProtectionConfig{
+		NonceHeader:     "X-Nonce",
+		TimestampHeader: "X-Timestamp",
+		TimestampFormat: "unix",
+		MaxSkew:         "5m",
+		MinNonceLength:  16,
+		Scope:           "global",
+		Critical:        true,
+		RedisURL:        "redis://localhost:6379/0",
+		KeyPrefix:       "replay:",
+	}
+}
+
+// NewReplayProtectionPlugin creates a new replay protection plugin.
+func NewReplayProtectionPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := DefaultReplayProtectionConfig()
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid replay-protection config: %w", err)
+		}
+	}
+
+	maxSkew, err := parseWindowDuration(config.MaxSkew)
+	if err != nil {
+		return nil, fmt.Errorf("invalid max_skew: %w", err)
+	}
+	if config.NonceHeader == "" || config.TimestampHeader == "" {
+		return nil, fmt.Errorf("nonce_header and timestamp_header are required")
+	}
+	switch config.TimestampFormat {
+	case "unix", "unix_ms", "rfc3339":
+	default:
+		return nil, fmt.Errorf("invalid timestamp_format '%s' (must be unix, unix_ms or rfc3339)", config.TimestampFormat)
+	}
+	if config.MinNonceLength < 1 || config.MinNonceLength > maxNonceLength {
+		return nil, fmt.Errorf("min_nonce_length must be between 1 and %d", maxNonceLength)
+	}
+	switch config.Scope {
+	case "global", "consumer":
+	case "auto":
+		config.Scope = "consumer"
+	default:
+		return nil, fmt.Errorf("invalid scope '%s' (must be global or consumer)", config.Scope)
+	}
+
+	log.Info().
+		Str("component", "plugin").
+		Str("plugin", "replay-protection").
+		Str("nonce_header", config.NonceHeader).
+		Str("timestamp_header", config.TimestampHeader).
+		Dur("max_skew", maxSkew).
+		Str("scope", config.Scope).
+		Msg("Initializing replay protection plugin")
+
+	redisConfig := ratelimit.DefaultRedisConfig()
+	redisConfig.URL = config.RedisURL
+	store, err := ratelimit.NewRedisStore(redisConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create redis store: %w", err)
+	}
+
+	return &ReplayProtectionPlugin{
+		config:  config,
+		store:   store,
+		maxSkew: maxSkew,
+		checks: plugin.NewMetrics("replay-protection").Counter(
+			"checks_total",
+			"Replay checks, by route and result (accepted, missing, invalid, stale, replayed, error).",
+			"route", "result",
+		),
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *ReplayProtectionPlugin) Name() string {
+	return "replay-protection"
+}
+
+// Execute checks the request's timestamp and nonce.
+func (p *ReplayProtectionPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+
+	nonce := ctx.Request.Header.Get(p.config.NonceHeader)
+	rawTimestamp := ctx.Request.Header.Get(p.config.TimestampHeader)
+	if nonce == "" || rawTimestamp == "" {
+		p.checks.Inc(routeID, "missing")
+		ctx.Abort(http.StatusBadRequest, fmt.Sprintf("%s and %s headers are required", p.config.NonceHeader, p.config.TimestampHeader))
+		return nil
+	}
+	if len(nonce) < p.config.MinNonceLength || len(nonce) > maxNonceLength {
+		p.checks.Inc(routeID, "invalid")
+		ctx.Abort(http.StatusBadRequest, fmt.Sprintf("%s must be %d to %d characters", p.config.NonceHeader, p.config.MinNonceLength, maxNonceLength))
+		return nil
+	}
+
+	timestamp, err := p.parseTimestamp(rawTimestamp)
+	if err != nil {
+		p.checks.Inc(routeID, "invalid")
+		ctx.Abort(http.StatusBadRequest, fmt.Sprintf("Invalid %s header", p.config.TimestampHeader))
+		return nil
+	}
+
+	skew := time.Since(timestamp)
+	if skew > p.maxSkew || skew < -p.maxSkew {
+		p.checks.Inc(routeID, "stale")
+		p.reject(ctx, routeID, "stale", skew)
+		ctx.Abort(http.StatusUnauthorized, "Request timestamp is outside the allowed window")
+		return nil
+	}
+
+	fresh, err := p.store.SetNX(ctx.Context(), p.redisKey(ctx, routeID, nonce), 1, 2*p.maxSkew)
+	if err != nil {
+		p.checks.Inc(routeID, "error")
+		return p.handleError(ctx, err)
+	}
+	if !fresh {
+		p.checks.Inc(routeID, "replayed")
+		p.reject(ctx, routeID, "replayed", skew)
+		ctx.Abort(http.StatusUnauthorized, "Request was already processed")
+		return nil
+	}
+
+	p.checks.Inc(routeID, "accepted")
+	return nil
+}
+
+// parseTimestamp parses a timestamp header in the configured format.
+func (p *ReplayProtectionPlugin) parseTimestamp(value string) (time.Time, error) {
+	switch p.config.TimestampFormat {
+	case "unix_ms":
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.UnixMilli(ms), nil
+	case "rfc3339":
+		return time.Parse(time.RFC3339, value)
+	default:
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(seconds, 0), nil
+	}
+}
+
+// reject logs a stale or replayed request.
+func (p *ReplayProtectionPlugin) reject(ctx *plugin.Context, routeID, reason string, skew time.Duration) {
+	log.Warn().
+		Str("component", "plugin").
+		Str("plugin", "replay-protection").
+		Str("route_id", routeID).
+		Str("reason", reason).
+		Str("client_ip", clientip.FromRequest(ctx.Request)).
+		Str("consumer_id", plugin.KeyConsumerID.Value(ctx)).
+		Dur("skew", skew).
+		Msg("Rejected possible replay")
+	ctx.Decide("replay protection: " + reason)
+}
+
+// redisKey builds the Redis key: prefix + route + scope + hashed nonce.
+// Only the authenticated consumer narrows the scope.
+func (p *ReplayProtectionPlugin) redisKey(ctx *plugin.Context, routeID, nonce string) string {
+	client := "global"
+	if consumerID := plugin.KeyConsumerID.Value(ctx); p.config.Scope == "consumer" && consumerID != "" {
+		client = "consumer:" + consumerID
+	}
+
+	sum := sha256.Sum256([]byte(nonce))
+	return p.config.KeyPrefix + routeID + ":" + client + ":" + hex.EncodeToString(sum[:16])
+}
+
+// handleError handles Redis errors.
+//
+// If critical=true (default), the request is rejected with 503.
+// If critical=false, the request proceeds without the nonce check.
+func (p *ReplayProtectionPlugin) handleError(ctx *plugin.Context, err error) error {
+	log.Error().
+		Err(err).
+		Str("component", "plugin").
+		Str("plugin", "replay-protection").
+		Bool("critical", p.config.Critical).
+		Msg("Replay check failed")
+
+	if p.config.Critical {
+		ctx.Abort(http.StatusServiceUnavailable, "Replay protection unavailable")
+		return fmt.Errorf("replay check failed: %w", err)
+	}
+
+	return nil
+}
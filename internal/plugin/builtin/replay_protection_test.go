@@ -0,0 +1,170 @@
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// newTestContext returns a before-request plugin context for r on a route
+// with the given ID.
+func newTestContext(r *http.Request, routeID string) *plugin.Context {
+	return plugin.NewContext(r, httptest.NewRecorder(), &database.Route{ID: routeID}, &database.Service{}, plugin.PhaseBeforeRequest)
+}
+
+// newTestReplayPlugin builds the plugin without connecting to Redis, for
+// the checks that run before the nonce lookup.
+func newTestReplayPlugin(scope string) *ReplayProtectionPlugin {
+	config := DefaultReplayProtectionConfig()
+	config.Scope = scope
+	return &ReplayProtectionPlugin{
+		config:  config,
+		maxSkew: 5 * time.Minute,
+		checks:  plugin.NewMetrics("replay-protection").Counter("checks_total", "", "route", "result"),
+	}
+}
+
+func replayRequest(nonce string, timestamp time.Time, apiKey, remoteAddr string) *http.Request {
+	r := httptest.NewRequest("POST", "/hooks", nil)
+	if nonce != "" {
+		r.Header.Set("X-Nonce", nonce)
+	}
+	if !timestamp.IsZero() {
+		r.Header.Set("X-Timestamp", strconv.FormatInt(timestamp.Unix(), 10))
+	}
+	if apiKey != "" {
+		r.Header.Set("X-API-Key", apiKey)
+	}
+	if remoteAddr != "" {
+		r.RemoteAddr = remoteAddr
+	}
+	return r
+}
+
+func TestReplayProtection_RejectsBeforeLookup(t *testing.T) {
+	const nonce = "0123456789abcdef"
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{name: "missing nonce", req: replayRequest("", now, "", ""), want: http.StatusBadRequest},
+		{name: "missing timestamp", req: replayRequest(nonce, time.Time{}, "", ""), want: http.StatusBadRequest},
+		{name: "short nonce", req: replayRequest("abc", now, "", ""), want: http.StatusBadRequest},
+		{name: "malformed timestamp", req: func() *http.Request {
+			r := replayRequest(nonce, now, "", "")
+			r.Header.Set("X-Timestamp", "yesterday")
+			return r
+		}(), want: http.StatusBadRequest},
+		{name: "past the window", req: replayRequest(nonce, now.Add(-5*time.Minute-10*time.Second), "", ""), want: http.StatusUnauthorized},
+		{name: "ahead of the window", req: replayRequest(nonce, now.Add(5*time.Minute+10*time.Second), "", ""), want: http.StatusUnauthorized},
+	}
+
+	p := newTestReplayPlugin("global")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(tt.req, "r-replay")
+			if err := p.Execute(ctx); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !ctx.IsAborted() || ctx.AbortStatusCode() != tt.want {
+				t.Errorf("aborted = %v with %d, want %d", ctx.IsAborted(), ctx.AbortStatusCode(), tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayProtection_RedisKey(t *testing.T) {
+	const nonce = "0123456789abcdef"
+	now := time.Now()
+
+	key := func(p *ReplayProtectionPlugin, apiKey, remoteAddr, consumerID string) string {
+		ctx := newTestContext(replayRequest(nonce, now, apiKey, remoteAddr), "r-replay")
+		if consumerID != "" {
+			plugin.KeyConsumerID.Set(ctx, consumerID)
+		}
+		return p.redisKey(ctx, "r-replay", nonce)
+	}
+
+	// Client-chosen headers and addresses never open a fresh namespace
+	for _, scope := range []string{"global", "consumer"} {
+		p := newTestReplayPlugin(scope)
+		original := key(p, "key-a", "192.0.2.1:1234", "")
+		if replayed := key(p, "key-b", "198.51.100.7:4321", ""); replayed != original {
+			t.Errorf("scope %s: key with another X-API-Key and IP = %q, want %q", scope, replayed, original)
+		}
+	}
+
+	global := newTestReplayPlugin("global")
+	if key(global, "", "", "c1") != key(global, "", "", "c2") {
+		t.Error("scope global: consumers got separate namespaces")
+	}
+
+	consumer := newTestReplayPlugin("consumer")
+	if key(consumer, "", "", "c1") == key(consumer, "", "", "c2") {
+		t.Error("scope consumer: consumers share a namespace")
+	}
+}
+
+func TestReplayProtection_Config(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{name: "unknown scope", config: `{"scope": "ip"}`},
+		{name: "bad timestamp format", config: `{"timestamp_format": "iso"}`},
+		{name: "nonce length", config: `{"min_nonce_length": 0}`},
+		{name: "skew", config: `{"max_skew": "soon"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewReplayProtectionPlugin(json.RawMessage(tt.config)); err == nil {
+				t.Errorf("NewReplayProtectionPlugin(%s) succeeded, want error", tt.config)
+			}
+		})
+	}
+}
+
+func TestReplayProtection_NonceReuse(t *testing.T) {
+	config := fmt.Sprintf(`{"redis_url": "redis://localhost:6379/15", "key_prefix": "test:replay:%d:"}`, time.Now().UnixNano())
+	p, err := NewReplayProtectionPlugin(json.RawMessage(config))
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int // 0 = accepted
+	}{
+		{name: "first use", req: replayRequest("nonce-0000000001", now, "key-a", "192.0.2.1:1234")},
+		{name: "same nonce", req: replayRequest("nonce-0000000001", now, "key-a", "192.0.2.1:1234"), want: http.StatusUnauthorized},
+		{name: "same nonce from another key and IP", req: replayRequest("nonce-0000000001", now, "key-b", "198.51.100.7:4321"), want: http.StatusUnauthorized},
+		{name: "new nonce", req: replayRequest("nonce-0000000002", now, "key-a", "192.0.2.1:1234")},
+		{name: "inside the window, past", req: replayRequest("nonce-0000000003", now.Add(-5*time.Minute+10*time.Second), "", "")},
+		{name: "inside the window, ahead", req: replayRequest("nonce-0000000004", now.Add(5*time.Minute-10*time.Second), "", "")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(tt.req, "r-replay")
+			if err := p.Execute(ctx); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got := ctx.AbortStatusCode(); ctx.IsAborted() != (tt.want != 0) || got != tt.want {
+				t.Errorf("aborted = %v with %d, want %d", ctx.IsAborted(), got, tt.want)
+			}
+		})
+	}
+}
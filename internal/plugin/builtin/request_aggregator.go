@@ -0,0 +1,425 @@
+// Package builtin - Request aggregator plugin
+//
+// The request-aggregator plugin composes one response from several
+// backend calls (the backend-for-frontend pattern): the route's request
+// fans out to every configured branch in parallel, and the branches' JSON
+// responses are merged under their names:
+//
+//	GET /profile/42  ->  GET http://users.svc/users/42
+//	                 ->  GET http://orders.svc/orders?user=42
+//	                 <-  {"user": {...}, "orders": [...]}
+//
+// Configuration Example:
+//
+//	{
+//	  "branches": [
+//	    {"name": "user", "url": "http://users.svc/users/{id}", "timeout": "500ms", "required": true},
+//	    {"name": "orders", "url": "http://orders.svc/orders?user={id}", "timeout": "1s"}
+//	  ],
+//	  "timeout": "2s",
+//	  "forward_headers": ["Authorization", "Accept-Language"],
+//	  "errors_key": "_errors"
+//	}
+//
+// Branch URLs may use the route's path parameters ({id} for ":id") and
+// the wildcard remainder ({*}); values are path-escaped. Each branch has
+// its own timeout, bounded by the overall timeout.
+//
+// A branch fails on a transport error, a timeout, a non-2xx status or a
+// body that isn't JSON. A failed required branch fails the request (504 on
+// a timeout, 502 otherwise); a failed optional branch is null in the
+// response and its error is listed under errors_key. The route's service
+// is not called.
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/logging"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// RequestAggregatorPlugin merges parallel backend calls into one response.
+type RequestAggregatorPlugin struct {
+	config   RequestAggregatorConfig
+	branches []aggregatorBranch
+	timeout  time.Duration
+	client   *http.Client
+	results  *metrics.CounterVec
+	duration *metrics.HistogramVec
+}
+
+// RequestAggregatorConfig holds configuration for the request aggregator.
+type RequestAggregatorConfig struct {
+	// Branches are the backend calls, merged under their names
+	// Required
+	Branches []AggregatorBranchConfig `json:"branches"`
+
+	// Timeout bounds the whole fan-out
+	// Default: "5s"
+	Timeout string `json:"timeout"`
+
+	// ForwardHeaders are copied from the client request to every branch
+	// Default: ["Authorization", "Accept-Language"]
+	ForwardHeaders []string `json:"forward_headers"`
+
+	// ErrorsKey holds the errors of failed optional branches ("" = omit)
+	// Default: "_errors"
+	ErrorsKey string `json:"errors_key"`
+
+	// MaxBodyBytes limits each branch's response body
+	// Default: 1048576 (1 MiB)
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+}
+
+// AggregatorBranchConfig is one backend call.
+type AggregatorBranchConfig struct {
+	// Name is the branch's key in the merged response
+	Name string `json:"name"`
+
+	// URL is the request URL template
+	URL string `json:"url"`
+
+	// Method is the HTTP method
+	// Default: "GET"
+	Method string `json:"method"`
+
+	// Headers are added to the branch request
+	Headers map[string]string `json:"headers"`
+
+	// Timeout bounds the call
+	// Default: the overall timeout
+	Timeout string `json:"timeout"`
+
+	// Required fails the whole request if the branch fails
+	// Default: false
+	Required bool `json:"required"`
+}
+
+// aggregatorBranch is a validated AggregatorBranchConfig.
+type aggregatorBranch struct {
+	AggregatorBranchConfig
+	timeout time.Duration
+}
+
+// branchResult is the outcome of one branch call.
+type branchResult struct {
+	body    json.RawMessage
+	err     error
+	timeout bool
+
+	// buffer holds body until the merged response is written
+	buffer *bodybuffer.Body
+}
+
+// public describes the failure for the client, without the branch URL.
+func (r branchResult) public() string {
+	var urlErr *url.Error
+	switch {
+	case r.timeout:
+		return "timeout"
+	case errors.As(r.err, &urlErr):
+		return "request failed"
+	}
+	return r.err.Error()
+}
+
+// DefaultRequestAggregatorConfig returns defaults for the request aggregator.
+func DefaultRequestAggregatorConfig() RequestAggregatorConfig {
+	return RequestAggregatorConfig{
+		Timeout:        "5s",
+		ForwardHeaders: []string{"Authorization", "Accept-Language"},
+		ErrorsKey:      "_errors",
+		MaxBodyBytes:   1 << 20,
+	}
+}
+
+// NewRequestAggregatorPlugin creates a new request aggregator plugin.
+func NewRequestAggregatorPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := DefaultRequestAggregatorConfig()
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid request-aggregator config: %w", err)
+		}
+	}
+
+	timeout, err := time.ParseDuration(config.Timeout)
+	if err != nil || timeout <= 0 {
+		return nil, fmt.Errorf("invalid request-aggregator config: timeout must be a positive duration, got %q", config.Timeout)
+	}
+	if config.MaxBodyBytes <= 0 {
+		return nil, fmt.Errorf("invalid request-aggregator config: max_body_bytes must be positive")
+	}
+	if len(config.Branches) == 0 {
+		return nil, fmt.Errorf("invalid request-aggregator config: at least one branch is required")
+	}
+
+	names := make(map[string]bool, len(config.Branches))
+	branches := make([]aggregatorBranch, 0, len(config.Branches))
+	for i, b := range config.Branches {
+		if b.Name == "" {
+			return nil, fmt.Errorf("invalid request-aggregator config: branch %d has no name", i)
+		}
+		if names[b.Name] {
+			return nil, fmt.Errorf("invalid request-aggregator config: duplicate branch name %q", b.Name)
+		}
+		if b.Name == config.ErrorsKey {
+			return nil, fmt.Errorf("invalid request-aggregator config: branch name %q is the errors_key", b.Name)
+		}
+		names[b.Name] = true
+
+		u, err := url.Parse(b.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid request-aggregator config: branch %q: url must be an http(s) URL, got %q", b.Name, b.URL)
+		}
+		if b.Method == "" {
+			b.Method = http.MethodGet
+		}
+		b.Method = strings.ToUpper(b.Method)
+
+		branch := aggregatorBranch{AggregatorBranchConfig: b, timeout: timeout}
+		if b.Timeout != "" {
+			d, err := time.ParseDuration(b.Timeout)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid request-aggregator config: branch %q: timeout must be a positive duration, got %q", b.Name, b.Timeout)
+			}
+			branch.timeout = min(d, timeout)
+		}
+		branches = append(branches, branch)
+	}
+
+	m := plugin.NewMetrics("request-aggregator")
+	return &RequestAggregatorPlugin{
+		config:   config,
+		branches: branches,
+		timeout:  timeout,
+		client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		results: m.Counter(
+			"branches_total",
+			"Aggregated branch calls, by route, branch and result (ok, error, timeout).",
+			"route", "branch", "result",
+		),
+		duration: m.Histogram(
+			"branch_duration_seconds",
+			"Aggregated branch call latency, by route and branch.",
+			nil,
+			"route", "branch",
+		),
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *RequestAggregatorPlugin) Name() string {
+	return "request-aggregator"
+}
+
+// Execute fans the request out to the branches and writes the merged
+// response, aborting the chain.
+func (p *RequestAggregatorPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+	params := routePathParams(ctx.Route, ctx.Request.URL.Path)
+
+	fanout, cancel := context.WithTimeout(ctx.Context(), p.timeout)
+	defer cancel()
+
+	results := make([]branchResult, len(p.branches))
+	var wg sync.WaitGroup
+	for i := range p.branches {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			branch := &p.branches[i]
+			start := time.Now()
+			results[i] = p.call(fanout, ctx.Request, branch, params)
+			p.duration.Observe(time.Since(start).Seconds(), routeID, branch.Name)
+		}(i)
+	}
+	wg.Wait()
+	defer func() {
+		for _, res := range results {
+			if res.buffer != nil {
+				res.buffer.Close()
+			}
+		}
+	}()
+
+	merged := make(map[string]json.RawMessage, len(p.branches)+1)
+	failures := make(map[string]string)
+	for i, res := range results {
+		branch := &p.branches[i]
+		switch {
+		case res.err == nil:
+			p.results.Inc(routeID, branch.Name, "ok")
+			merged[branch.Name] = res.body
+			continue
+		case res.timeout:
+			p.results.Inc(routeID, branch.Name, "timeout")
+		default:
+			p.results.Inc(routeID, branch.Name, "error")
+		}
+
+		log.Warn().
+			Err(res.err).
+			Str("component", "plugin").
+			Str("plugin", "request-aggregator").
+			Str("route_id", routeID).
+			Str("branch", branch.Name).
+			Bool("required", branch.Required).
+			Msg("Aggregated branch failed")
+
+		if branch.Required {
+			status, reason := http.StatusBadGateway, "bad gateway"
+			if res.timeout {
+				status, reason = http.StatusGatewayTimeout, "gateway timeout"
+			}
+			body, _ := json.Marshal(map[string]string{
+				"error":   reason,
+				"message": fmt.Sprintf("Branch %s failed", branch.Name),
+			})
+			ctx.Response.Header().Set("Content-Type", "application/json")
+			ctx.Decide(fmt.Sprintf("required branch %s failed: %v", branch.Name, res.err))
+			ctx.Abort(status, string(body))
+			return nil
+		}
+		merged[branch.Name] = json.RawMessage("null")
+		failures[branch.Name] = res.public()
+	}
+	if len(failures) > 0 && p.config.ErrorsKey != "" {
+		errs, _ := json.Marshal(failures)
+		merged[p.config.ErrorsKey] = errs
+	}
+
+	body, err := json.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("failed to encode aggregated response: %w", err)
+	}
+	ctx.Response.Header().Set("Content-Type", "application/json")
+	ctx.Response.WriteHeader(http.StatusOK)
+	ctx.Response.Write(body)
+
+	ctx.Decide(fmt.Sprintf("aggregated %d branches (%d failed)", len(p.branches), len(failures)))
+	ctx.Abort(http.StatusOK, "")
+	return nil
+}
+
+// call sends one branch request and reads its JSON body.
+func (p *RequestAggregatorPlugin) call(ctx context.Context, r *http.Request, branch *aggregatorBranch, params map[string]string) branchResult {
+	ctx, cancel := context.WithTimeout(ctx, branch.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, branch.Method, expandBranchURL(branch.URL, params), nil)
+	if err != nil {
+		return branchResult{err: err}
+	}
+	for _, name := range p.config.ForwardHeaders {
+		if values := r.Header.Values(name); len(values) > 0 {
+			req.Header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	for name, value := range branch.Headers {
+		req.Header.Set(name, value)
+	}
+	if requestID := logging.RequestIDFromContext(r.Context()); requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return branchResult{err: err, timeout: errors.Is(ctx.Err(), context.DeadlineExceeded)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return branchResult{err: fmt.Errorf("status %d", resp.StatusCode)}
+	}
+	buffered, err := bodybuffer.FromRequest(r).Read(resp.Body, p.config.MaxBodyBytes)
+	if err != nil {
+		return branchResult{err: err, timeout: errors.Is(ctx.Err(), context.DeadlineExceeded)}
+	}
+	data := buffered.Bytes()
+	switch {
+	case buffered.OverBudget():
+		buffered.Close()
+		return branchResult{err: errors.New("gateway body buffering budget exhausted")}
+	case buffered.TooLarge():
+		buffered.Close()
+		return branchResult{err: fmt.Errorf("response over %d bytes", p.config.MaxBodyBytes)}
+	case resp.StatusCode == http.StatusNoContent || len(data) == 0:
+		buffered.Close()
+		return branchResult{body: json.RawMessage("null")}
+	case !json.Valid(data):
+		buffered.Close()
+		return branchResult{err: errors.New("response is not JSON")}
+	}
+	return branchResult{body: data, buffer: buffered}
+}
+
+// expandBranchURL substitutes path parameters ({name}) and the wildcard
+// remainder ({*}) into a branch URL. Unknown placeholders are left as is.
+func expandBranchURL(template string, params map[string]string) string {
+	if len(params) == 0 || !strings.Contains(template, "{") {
+		return template
+	}
+	pairs := make([]string, 0, 2*len(params))
+	for name, value := range params {
+		escaped := url.PathEscape(value)
+		if name == "*" {
+			// Keep the remainder's slashes
+			escaped = strings.ReplaceAll(escaped, "%2F", "/")
+		}
+		pairs = append(pairs, "{"+name+"}", escaped)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// routePathParams matches path against the route's path patterns and
+// returns the parameters of the first match: ":name" segments by name and
+// the trailing "*" remainder as "*".
+func routePathParams(route *database.Route, path string) map[string]string {
+	if route == nil {
+		return nil
+	}
+	segments := splitPathSegments(path)
+	for _, p := range route.Paths {
+		pattern := splitPathSegments(p)
+		if !matchPathSegments(pattern, segments) {
+			continue
+		}
+		params := make(map[string]string)
+		for i, s := range pattern {
+			switch {
+			case s == "*":
+				params["*"] = strings.Join(segments[i:], "/")
+			case strings.HasPrefix(s, ":"):
+				params[s[1:]] = segments[i]
+			}
+		}
+		return params
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package builtin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// newAggregatorBackends starts a backend per handler and returns their
+// base URLs.
+func newAggregatorBackends(t *testing.T, handlers map[string]http.HandlerFunc) map[string]string {
+	t.Helper()
+	urls := make(map[string]string, len(handlers))
+	for name, handler := range handlers {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		urls[name] = server.URL
+	}
+	return urls
+}
+
+// aggregate runs a request-aggregator built from config on GET path,
+// for a route matching /profile/:id.
+func aggregate(t *testing.T, config map[string]interface{}, path string) (*plugin.Context, map[string]json.RawMessage) {
+	t.Helper()
+	configJSON, _ := json.Marshal(config)
+	p, err := NewRequestAggregatorPlugin(configJSON)
+	if err != nil {
+		t.Fatalf("NewRequestAggregatorPlugin() error = %v", err)
+	}
+
+	r := httptest.NewRequest("GET", path, nil)
+	r.Header.Set("Authorization", "Bearer t-1")
+	r.Header.Set("Cookie", "session=s-1")
+	route := &database.Route{ID: "r-profile", Paths: []string{"/profile/:id"}}
+	ctx := plugin.NewContext(r, httptest.NewRecorder(), route, &database.Service{}, plugin.PhaseBeforeRequest)
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !ctx.IsAborted() {
+		t.Fatal("request passed on to the route's service, want it answered by the aggregator")
+	}
+
+	rec := ctx.Response.ResponseWriter.(*httptest.ResponseRecorder)
+	var merged map[string]json.RawMessage
+	if ctx.AbortStatusCode() == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &merged); err != nil {
+			t.Fatalf("merged response %q isn't JSON: %v", rec.Body.String(), err)
+		}
+	}
+	return ctx, merged
+}
+
+func TestRequestAggregator_FanOut(t *testing.T) {
+	urls := newAggregatorBackends(t, map[string]http.HandlerFunc{
+		"users": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/users/42" || r.Header.Get("Authorization") != "Bearer t-1" || r.Header.Get("Cookie") != "" {
+				t.Errorf("users got %s with %v, want /users/42 with only the forwarded headers", r.URL.Path, r.Header)
+			}
+			w.Write([]byte(`{"id": "42", "name": "Ada"}`))
+		},
+		"orders": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("user") != "42" || r.Header.Get("X-Source") != "gateway" {
+				t.Errorf("orders got %s with %v, want ?user=42 and the branch's header", r.URL, r.Header)
+			}
+			w.Write([]byte(`[{"id": "o-1"}]`))
+		},
+		"empty": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})
+
+	ctx, merged := aggregate(t, map[string]interface{}{
+		"branches": []map[string]interface{}{
+			{"name": "user", "url": urls["users"] + "/users/{id}", "required": true},
+			{"name": "orders", "url": urls["orders"] + "/orders?user={id}", "headers": map[string]string{"X-Source": "gateway"}},
+			{"name": "flags", "url": urls["empty"] + "/flags"},
+		},
+	}, "/profile/42")
+
+	if ctx.AbortStatusCode() != http.StatusOK {
+		t.Fatalf("status = %d (%s), want 200", ctx.AbortStatusCode(), ctx.AbortMessage())
+	}
+	want := map[string]string{
+		"user":   `{"id":"42","name":"Ada"}`,
+		"orders": `[{"id":"o-1"}]`,
+		"flags":  `null`,
+	}
+	for name, body := range want {
+		if string(merged[name]) != body {
+			t.Errorf("%s = %s, want %s", name, merged[name], body)
+		}
+	}
+	if _, ok := merged["_errors"]; ok || len(merged) != len(want) {
+		t.Errorf("merged = %v, want exactly the branches", merged)
+	}
+	if got := ctx.Response.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
+
+func TestRequestAggregator_PartialFailure(t *testing.T) {
+	urls := newAggregatorBackends(t, map[string]http.HandlerFunc{
+		"ok": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"id": "42"}`))
+		},
+		"down": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "boom", http.StatusInternalServerError)
+		},
+		"html": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<h1>maintenance</h1>"))
+		},
+	})
+
+	// Failed optional branches are null and listed under errors_key
+	ctx, merged := aggregate(t, map[string]interface{}{
+		"branches": []map[string]interface{}{
+			{"name": "user", "url": urls["ok"] + "/users/{id}", "required": true},
+			{"name": "orders", "url": urls["down"] + "/orders"},
+			{"name": "tips", "url": urls["html"] + "/tips"},
+		},
+	}, "/profile/42")
+	if ctx.AbortStatusCode() != http.StatusOK {
+		t.Fatalf("status = %d, want 200 with the optional branches failed", ctx.AbortStatusCode())
+	}
+	if string(merged["user"]) != `{"id":"42"}` || string(merged["orders"]) != "null" || string(merged["tips"]) != "null" {
+		t.Errorf("merged = %s", merged)
+	}
+	var errs map[string]string
+	json.Unmarshal(merged["_errors"], &errs)
+	if errs["orders"] != "status 500" || errs["tips"] != "response is not JSON" || len(errs) != 2 {
+		t.Errorf("_errors = %v, want orders and tips", errs)
+	}
+
+	// A failed required branch fails the request
+	ctx, _ = aggregate(t, map[string]interface{}{
+		"branches": []map[string]interface{}{
+			{"name": "user", "url": urls["down"] + "/users/{id}", "required": true},
+			{"name": "orders", "url": urls["ok"] + "/orders"},
+		},
+	}, "/profile/42")
+	if ctx.AbortStatusCode() != http.StatusBadGateway {
+		t.Errorf("required branch failed: status %d, want 502", ctx.AbortStatusCode())
+	}
+}
+
+func TestRequestAggregator_Timeout(t *testing.T) {
+	urls := newAggregatorBackends(t, map[string]http.HandlerFunc{
+		"ok": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"id": "42"}`))
+		},
+		"slow": func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(2 * time.Second):
+				w.Write([]byte(`{}`))
+			case <-r.Context().Done():
+			}
+		},
+	})
+
+	// A slow optional branch times out on its own timeout
+	start := time.Now()
+	ctx, merged := aggregate(t, map[string]interface{}{
+		"branches": []map[string]interface{}{
+			{"name": "user", "url": urls["ok"] + "/users/{id}"},
+			{"name": "recs", "url": urls["slow"] + "/recs", "timeout": "50ms"},
+		},
+	}, "/profile/42")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %v, want the branch cut off at its timeout", elapsed)
+	}
+	var errs map[string]string
+	json.Unmarshal(merged["_errors"], &errs)
+	if ctx.AbortStatusCode() != http.StatusOK || string(merged["recs"]) != "null" || errs["recs"] != "timeout" {
+		t.Errorf("status %d, merged %s; want 200 with recs timed out", ctx.AbortStatusCode(), merged)
+	}
+
+	// The overall timeout bounds every branch; a required one gets 504
+	start = time.Now()
+	ctx, _ = aggregate(t, map[string]interface{}{
+		"timeout": "50ms",
+		"branches": []map[string]interface{}{
+			{"name": "user", "url": urls["slow"] + "/users/{id}", "timeout": "10s", "required": true},
+		},
+	}, "/profile/42")
+	if ctx.AbortStatusCode() != http.StatusGatewayTimeout || time.Since(start) > time.Second {
+		t.Errorf("status %d after %v, want 504 at the overall timeout", ctx.AbortStatusCode(), time.Since(start))
+	}
+}
+
+func TestRequestAggregator_Config(t *testing.T) {
+	for _, config := range []string{
+		`{}`,
+		`{"branches": [{"url": "http://a"}]}`,
+		`{"branches": [{"name": "a", "url": "http://a"}, {"name": "a", "url": "http://b"}]}`,
+		`{"branches": [{"name": "_errors", "url": "http://a"}]}`,
+		`{"branches": [{"name": "a", "url": "ftp://a"}]}`,
+		`{"branches": [{"name": "a", "url": "/relative"}]}`,
+		`{"branches": [{"name": "a", "url": "http://a", "timeout": "0s"}]}`,
+		`{"branches": [{"name": "a", "url": "http://a"}], "timeout": "soon"}`,
+		`{"branches": [{"name": "a", "url": "http://a"}], "max_body_bytes": 0}`,
+	} {
+		if _, err := NewRequestAggregatorPlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewRequestAggregatorPlugin(%s) succeeded, want error", config)
+		}
+	}
+}
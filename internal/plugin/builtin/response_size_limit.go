@@ -0,0 +1,214 @@
+// Package builtin - Response size limit plugin
+//
+// The response-size-limit plugin caps how much of an upstream response a
+// route passes on, protecting the gateway and its clients from runaway
+// responses (an unbounded export, a backend stuck in a loop):
+//   - A declared Content-Length over max_bytes is replaced by the configured
+//     error (502 by default, 507 is a common alternative) before anything
+//     is sent
+//   - A body without a declared length is read up to max_bytes before
+//     anything is sent, so it also gets the configured error if it runs
+//     over, at the cost of holding up to max_bytes per request (in the
+//     shared body buffer budget, spilled to disk if configured)
+//   - With "buffer": false, or when the buffer budget is exhausted, such a
+//     body is streamed and cut off once it passes max_bytes. The status
+//     has already been sent by then, so the gateway aborts the connection
+//     and the client sees a truncated response rather than one that looks
+//     complete
+//
+// Configuration Example:
+//
+//	{
+//	  "max_bytes": 10485760,
+//	  "status": 507,
+//	  "message": "Upstream response too large",
+//	  "buffer": true
+//	}
+//
+// Oversized responses are logged and counted in
+// gateway_plugin_response_size_limit_exceeded_total{route,mode}, where mode
+// is "declared", "buffered" or "streamed". Bytes sent per route are in
+// gateway_upstream_response_bytes_total.
+package builtin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// errResponseTooLarge ends a streamed response that passed its limit.
+var errResponseTooLarge = errors.New("upstream response exceeds the route's size limit")
+
+// ResponseSizeLimitPlugin caps upstream response bodies.
+type ResponseSizeLimitPlugin struct {
+	config   ResponseSizeLimitConfig
+	body     string
+	exceeded *metrics.CounterVec
+}
+
+// ResponseSizeLimitConfig holds configuration for the response size limit.
+type ResponseSizeLimitConfig struct {
+	// MaxBytes is the largest response body passed on
+	// Required
+	MaxBytes int64 `json:"max_bytes"`
+
+	// Status is sent instead of an oversized response (5xx)
+	// Default: 502
+	Status int `json:"status"`
+
+	// Message is the error message sent with Status
+	Message string `json:"message"`
+
+	// Buffer reads the body up to MaxBytes before sending anything, so
+	// oversized responses without a Content-Length also get Status; off,
+	// they are streamed and truncated
+	// Default: true
+	Buffer bool `json:"buffer"`
+}
+
+// NewResponseSizeLimitPlugin creates a new response size limit plugin.
+func NewResponseSizeLimitPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := ResponseSizeLimitConfig{
+		Status:  http.StatusBadGateway,
+		Message: "Upstream response too large",
+		Buffer:  true,
+	}
+
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid response-size-limit config: %w", err)
+		}
+	}
+
+	if config.MaxBytes <= 0 {
+		return nil, fmt.Errorf("invalid response-size-limit config: max_bytes must be positive")
+	}
+	if config.Status < 500 || config.Status > 599 {
+		return nil, fmt.Errorf("invalid response-size-limit config: status must be 5xx, got %d", config.Status)
+	}
+
+	body, err := json.Marshal(map[string]string{"error": "response too large", "message": config.Message})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponseSizeLimitPlugin{
+		config: config,
+		body:   string(body),
+		exceeded: plugin.NewMetrics("response-size-limit").Counter(
+			"exceeded_total",
+			"Upstream responses over the route's size limit, by route and how they were caught.",
+			"route", "mode",
+		),
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *ResponseSizeLimitPlugin) Name() string {
+	return "response-size-limit"
+}
+
+// Execute registers the size check before the request is proxied.
+func (p *ResponseSizeLimitPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+
+	buffers := bodybuffer.FromRequest(ctx.Request)
+	ctx.AddResponseHook(func(resp *http.Response) error {
+		if resp.ContentLength > p.config.MaxBytes {
+			return p.reject(routeID, "declared", resp.ContentLength)
+		}
+
+		if p.config.Buffer && resp.ContentLength < 0 {
+			body, err := buffers.ReadSpillable(resp.Body, p.config.MaxBytes)
+			if err != nil {
+				return fmt.Errorf("failed to read upstream response: %w", err)
+			}
+			resp.Body = body.ReadCloser(resp.Body)
+			if body.TooLarge() {
+				return p.reject(routeID, "buffered", -1)
+			}
+			if body.Complete() {
+				resp.ContentLength = body.Size()
+				resp.Header.Set("Content-Length", strconv.FormatInt(body.Size(), 10))
+				return nil
+			}
+			// No room to buffer it: enforce the limit while streaming
+		}
+
+		resp.Body = &limitedBody{
+			ReadCloser: resp.Body,
+			remaining:  p.config.MaxBytes,
+			exceeded: func() {
+				p.exceeded.Inc(routeID, "streamed")
+				log.Warn().
+					Str("component", "plugin").
+					Str("plugin", "response-size-limit").
+					Str("route_id", routeID).
+					Int64("max_bytes", p.config.MaxBytes).
+					Msg("Upstream response exceeded the size limit mid-stream; truncating")
+			},
+		}
+		return nil
+	})
+	return nil
+}
+
+// reject replaces an oversized response before it is sent.
+func (p *ResponseSizeLimitPlugin) reject(routeID, mode string, size int64) error {
+	p.exceeded.Inc(routeID, mode)
+	log.Warn().
+		Str("component", "plugin").
+		Str("plugin", "response-size-limit").
+		Str("route_id", routeID).
+		Str("mode", mode).
+		Int64("content_length", size).
+		Int64("max_bytes", p.config.MaxBytes).
+		Msg("Upstream response exceeded the size limit")
+
+	return &plugin.ResponseError{
+		StatusCode: p.config.Status,
+		Body:       p.body,
+		Reason:     fmt.Sprintf("response over %d bytes", p.config.MaxBytes),
+	}
+}
+
+// limitedBody fails once more than remaining bytes have been read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  func()
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errResponseTooLarge
+	}
+	// Read one byte past the limit to tell "exactly at" from "over"
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		b.exceeded()
+		return n + int(b.remaining), errResponseTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,153 @@
+package builtin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// limitResponse runs a response-size-limit plugin's hook on resp, with
+// manager (if any) as the request's body buffer, and returns the hook's
+// error.
+func limitResponse(t *testing.T, config string, manager *bodybuffer.Manager, resp *http.Response) error {
+	t.Helper()
+	p, err := NewResponseSizeLimitPlugin(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewResponseSizeLimitPlugin() error = %v", err)
+	}
+	r := httptest.NewRequest("GET", "/export", nil)
+	if manager != nil {
+		r = manager.Attach(r)
+	}
+	ctx := newTestContext(r, "r-export")
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	for _, hook := range plugin.ResponseHooks(ctx.Request) {
+		if err := hook(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamedResponse is a 200 without a Content-Length whose body arrives a
+// byte per read, so any limit is crossed mid-copy.
+func streamedResponse(body string) *http.Response {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{},
+		Body:          io.NopCloser(iotest.OneByteReader(strings.NewReader(body))),
+		ContentLength: -1,
+	}
+}
+
+func TestResponseSizeLimit_Declared(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int64
+		reject bool
+	}{
+		{name: "under", length: 9},
+		{name: "at the limit", length: 10},
+		{name: "over", length: 11, reject: true},
+		{name: "far over", length: 1 << 40, reject: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The declared length decides; the body isn't read
+			resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: untouchedBody{t}, ContentLength: tt.length}
+			err := limitResponse(t, `{"max_bytes": 10, "status": 507, "message": "export too big"}`, nil, resp)
+
+			var rejected *plugin.ResponseError
+			if !tt.reject {
+				if err != nil {
+					t.Errorf("hook error = %v, want the response passed on", err)
+				}
+				return
+			}
+			if !errors.As(err, &rejected) || rejected.StatusCode != 507 || !strings.Contains(rejected.Body, "export too big") {
+				t.Errorf("hook error = %v, want a 507 with the configured message", err)
+			}
+		})
+	}
+}
+
+func TestResponseSizeLimit_Streamed(t *testing.T) {
+	// By default a body without a length is measured before the status
+	// is sent, so crossing the limit mid-copy still gets a 502
+	err := limitResponse(t, `{"max_bytes": 10}`, nil, streamedResponse("0123456789abcdef"))
+	var rejected *plugin.ResponseError
+	if !errors.As(err, &rejected) || rejected.StatusCode != http.StatusBadGateway {
+		t.Fatalf("hook error = %v, want a 502 ResponseError", err)
+	}
+
+	// One that fits is passed on whole, with its length
+	resp := streamedResponse("0123456789")
+	if err := limitResponse(t, `{"max_bytes": 10}`, nil, resp); err != nil {
+		t.Fatalf("hook error = %v, want the response passed on", err)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "0123456789" || resp.ContentLength != 10 || resp.Header.Get("Content-Length") != "10" {
+		t.Errorf("body %q with length %d (%q), want the whole body and its length", body, resp.ContentLength, resp.Header.Get("Content-Length"))
+	}
+}
+
+func TestResponseSizeLimit_Truncated(t *testing.T) {
+	// Without buffering, or without room in the buffer budget, the body is
+	// streamed and fails once it passes the limit
+	full, err := bodybuffer.NewManager(bodybuffer.Config{MaxMemory: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		config  string
+		manager *bodybuffer.Manager
+	}{
+		{name: "buffer off", config: `{"max_bytes": 10, "buffer": false}`},
+		{name: "budget exhausted", config: `{"max_bytes": 10}`, manager: full},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := streamedResponse("0123456789abcdef")
+			if err := limitResponse(t, tt.config, tt.manager, resp); err != nil {
+				t.Fatalf("hook error = %v, want the response streamed", err)
+			}
+			var sent strings.Builder
+			n, err := io.Copy(&sent, resp.Body)
+			if !errors.Is(err, errResponseTooLarge) || n != 10 || sent.String() != "0123456789" {
+				t.Errorf("copied %d bytes (%q), error %v; want the first 10 then errResponseTooLarge", n, sent.String(), err)
+			}
+		})
+	}
+
+	// Exactly at the limit isn't over it
+	resp := streamedResponse("0123456789")
+	limitResponse(t, `{"max_bytes": 10, "buffer": false}`, nil, resp)
+	if body, err := io.ReadAll(resp.Body); err != nil || string(body) != "0123456789" {
+		t.Errorf("ReadAll() = %q, %v; want the whole body", body, err)
+	}
+}
+
+func TestResponseSizeLimit_Config(t *testing.T) {
+	for _, config := range []string{
+		`{}`,
+		`{"max_bytes": -1}`,
+		`{"max_bytes": 10, "status": 413}`,
+		`{"max_bytes": 10, "status": 600}`,
+	} {
+		if _, err := NewResponseSizeLimitPlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewResponseSizeLimitPlugin(%s) succeeded, want error", config)
+		}
+	}
+}
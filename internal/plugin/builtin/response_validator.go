@@ -0,0 +1,263 @@
+// Package builtin - Response validator plugin for upstream contract checks
+//
+// The response-validator plugin checks that a backend's responses match
+// what the route promises its clients, e.g. a JSON API that suddenly
+// returns an HTML error page from a misconfigured load balancer:
+//   - content_types: allowed media types ("application/json",
+//     "application/*", "*/*")
+//   - status_codes: allowed statuses ("2xx", "404", "500-503")
+//
+// The check runs as a response hook, before anything is sent to the
+// client. A violating response is replaced by a gateway error (action
+// "reject", the default) or passed through and only reported (action
+// "log"). Either way it is logged and counted in
+// gateway_plugin_response_validator_violations_total{route,reason}.
+//
+// Configuration Example:
+//
+//	{
+//	  "content_types": ["application/json", "application/problem+json"],
+//	  "status_codes": ["2xx", "4xx"],
+//	  "action": "reject",
+//	  "status": 502,
+//	  "message": "Upstream returned an unexpected response"
+//	}
+//
+// Responses without a body (204, 304, Content-Length: 0) skip the content
+// type check.
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// Response validator actions.
+const (
+	ResponseValidatorReject = "reject"
+	ResponseValidatorLog    = "log"
+)
+
+// ResponseValidatorPlugin enforces a route's upstream response contract.
+type ResponseValidatorPlugin struct {
+	contentTypes []string
+	statuses     []statusRange
+	action       string
+	status       int
+	body         string
+	violations   *metrics.CounterVec
+}
+
+// ResponseValidatorConfig holds configuration for the response validator.
+type ResponseValidatorConfig struct {
+	// ContentTypes lists the allowed media types (empty = any)
+	ContentTypes []string `json:"content_types"`
+
+	// StatusCodes lists the allowed statuses: "2xx", "404" or "500-503"
+	// (empty = any)
+	StatusCodes []string `json:"status_codes"`
+
+	// Action is "reject" (replace the response) or "log"
+	// Default: "reject"
+	Action string `json:"action"`
+
+	// Status is sent instead of a rejected response
+	// Default: 502
+	Status int `json:"status"`
+
+	// Message is the error message sent instead of a rejected response
+	Message string `json:"message"`
+}
+
+// statusRange is an inclusive range of status codes.
+type statusRange struct {
+	min, max int
+}
+
+// NewResponseValidatorPlugin creates a new response validator plugin.
+func NewResponseValidatorPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := ResponseValidatorConfig{
+		Action:  ResponseValidatorReject,
+		Status:  http.StatusBadGateway,
+		Message: "Upstream returned an unexpected response",
+	}
+
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid response-validator config: %w", err)
+		}
+	}
+
+	if len(config.ContentTypes) == 0 && len(config.StatusCodes) == 0 {
+		return nil, fmt.Errorf("invalid response-validator config: content_types or status_codes is required")
+	}
+	if config.Action != ResponseValidatorReject && config.Action != ResponseValidatorLog {
+		return nil, fmt.Errorf("invalid response-validator config: action must be %q or %q", ResponseValidatorReject, ResponseValidatorLog)
+	}
+	if config.Status < 400 || config.Status > 599 {
+		return nil, fmt.Errorf("invalid response-validator config: status must be 4xx or 5xx, got %d", config.Status)
+	}
+
+	p := &ResponseValidatorPlugin{
+		action: config.Action,
+		status: config.Status,
+		violations: plugin.NewMetrics("response-validator").Counter(
+			"violations_total",
+			"Upstream responses that broke the route's contract, by route and reason.",
+			"route", "reason",
+		),
+	}
+
+	for _, ct := range config.ContentTypes {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response-validator config: content type %q: %w", ct, err)
+		}
+		p.contentTypes = append(p.contentTypes, mediaType)
+	}
+
+	for _, code := range config.StatusCodes {
+		r, err := parseStatusRange(code)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response-validator config: %w", err)
+		}
+		p.statuses = append(p.statuses, r)
+	}
+
+	body, err := json.Marshal(map[string]string{"error": "bad upstream response", "message": config.Message})
+	if err != nil {
+		return nil, err
+	}
+	p.body = string(body)
+
+	return p, nil
+}
+
+// parseStatusRange parses "2xx", "404" or "500-503".
+func parseStatusRange(s string) (statusRange, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	if len(s) == 3 && strings.HasSuffix(s, "xx") && s[0] >= '1' && s[0] <= '5' {
+		class := int(s[0]-'0') * 100
+		return statusRange{min: class, max: class + 99}, nil
+	}
+
+	first, last, isRange := strings.Cut(s, "-")
+	if !isRange {
+		last = first
+	}
+	lo, err1 := strconv.Atoi(first)
+	hi, err2 := strconv.Atoi(last)
+	if err1 != nil || err2 != nil || lo < 100 || hi > 599 || lo > hi {
+		return statusRange{}, fmt.Errorf("invalid status code %q (use \"2xx\", \"404\" or \"500-503\")", s)
+	}
+	return statusRange{min: lo, max: hi}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *ResponseValidatorPlugin) Name() string {
+	return "response-validator"
+}
+
+// Execute registers the response check before the request is proxied.
+func (p *ResponseValidatorPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+
+	ctx.AddResponseHook(func(resp *http.Response) error {
+		reason, detail := p.check(resp)
+		if reason == "" {
+			return nil
+		}
+
+		p.violations.Inc(routeID, reason)
+		log.Warn().
+			Str("component", "plugin").
+			Str("plugin", "response-validator").
+			Str("route_id", routeID).
+			Str("reason", reason).
+			Int("upstream_status", resp.StatusCode).
+			Str("content_type", resp.Header.Get("Content-Type")).
+			Str("action", p.action).
+			Msg("Upstream response broke the route's contract")
+
+		if p.action == ResponseValidatorLog {
+			return nil
+		}
+		return &plugin.ResponseError{StatusCode: p.status, Body: p.body, Reason: detail}
+	})
+	return nil
+}
+
+// check returns the reason ("status" or "content_type") and a description
+// if resp violates the contract, or "" if it is acceptable.
+func (p *ResponseValidatorPlugin) check(resp *http.Response) (string, string) {
+	if len(p.statuses) > 0 && !p.allowsStatus(resp.StatusCode) {
+		return "status", fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+
+	if len(p.contentTypes) == 0 || !hasBody(resp) {
+		return "", ""
+	}
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	// A multi-range answer wraps the parts, each with its own type
+	if err == nil && isPartialContent(resp) && mediaType == "multipart/byteranges" {
+		return "", ""
+	}
+	if err != nil || !p.allowsMediaType(mediaType) {
+		return "content_type", fmt.Sprintf("unexpected content type %q", contentType)
+	}
+	return "", ""
+}
+
+func (p *ResponseValidatorPlugin) allowsStatus(code int) bool {
+	for _, r := range p.statuses {
+		if code >= r.min && code <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsMediaType matches exact types and "type/*" or "*/*" wildcards.
+func (p *ResponseValidatorPlugin) allowsMediaType(mediaType string) bool {
+	for _, allowed := range p.contentTypes {
+		if allowed == mediaType || allowed == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasBody reports whether resp can carry a body.
+func hasBody(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return false
+	}
+	return resp.ContentLength != 0
+}
+
+// isPartialContent reports whether resp carries only part of the
+// representation (206), which body rewrites must leave alone.
+func isPartialContent(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusPartialContent
+}
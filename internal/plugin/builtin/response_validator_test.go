@@ -0,0 +1,160 @@
+package builtin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+func newTestResponseValidator(t *testing.T, config string) *ResponseValidatorPlugin {
+	t.Helper()
+	p, err := NewResponseValidatorPlugin(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewResponseValidatorPlugin() error = %v", err)
+	}
+	return p.(*ResponseValidatorPlugin)
+}
+
+// validateResponse runs p's response hook on resp.
+func validateResponse(t *testing.T, p *ResponseValidatorPlugin, resp *http.Response) error {
+	t.Helper()
+	ctx := newTestContext(httptest.NewRequest("GET", "/orders", nil), "r-orders")
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	hooks := plugin.ResponseHooks(ctx.Request)
+	if len(hooks) != 1 {
+		t.Fatalf("registered %d response hooks, want 1", len(hooks))
+	}
+	return hooks[0](resp)
+}
+
+// untouchedBody fails the test if the validator reads the body.
+type untouchedBody struct {
+	t *testing.T
+}
+
+func (b untouchedBody) Read([]byte) (int, error) {
+	b.t.Error("validator read the response body")
+	return 0, io.EOF
+}
+
+func (b untouchedBody) Close() error { return nil }
+
+func TestResponseValidator_Contract(t *testing.T) {
+	const config = `{"content_types": ["application/json", "application/problem+json", "text/*"], "status_codes": ["2xx", "404", "500-503"]}`
+
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		length      int64
+		wantReason  string // "" = passed through
+	}{
+		{name: "json", status: 200, contentType: "application/json", length: 2},
+		{name: "json with charset", status: 200, contentType: "application/json; charset=utf-8", length: 2},
+		{name: "wildcard subtype", status: 200, contentType: "text/csv", length: 2},
+		{name: "problem json 404", status: 404, contentType: "application/problem+json", length: 2},
+		{name: "status in range", status: 502, contentType: "application/json", length: 2},
+		{name: "non-json", status: 200, contentType: "application/xml", length: 2, wantReason: "content_type"},
+		{name: "no content type", status: 200, length: 2, wantReason: "content_type"},
+		{name: "malformed content type", status: 200, contentType: "application/", length: 2, wantReason: "content_type"},
+		{name: "status outside", status: 403, contentType: "application/json", length: 2, wantReason: "status"},
+		{name: "status past range", status: 504, contentType: "application/json", length: 2, wantReason: "status"},
+		{name: "no content", status: 204},
+		{name: "empty body", status: 200, contentType: "application/octet-stream"},
+		{name: "multi-range", status: 206, contentType: "multipart/byteranges; boundary=x", length: 2},
+		{name: "large body", status: 200, contentType: "application/json", length: 1 << 30},
+		{name: "large non-json body", status: 200, contentType: "application/zip", length: 1 << 30, wantReason: "content_type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestResponseValidator(t, config)
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}, Body: untouchedBody{t}, ContentLength: tt.length}
+			if tt.contentType != "" {
+				resp.Header.Set("Content-Type", tt.contentType)
+			}
+
+			// Instances share the process-wide counter
+			before := p.violations.Value("r-orders", tt.wantReason)
+			err := validateResponse(t, p, resp)
+			if got := p.violations.Value("r-orders", tt.wantReason) - before; tt.wantReason != "" && got != 1 {
+				t.Errorf("violations{reason=%s} rose by %v, want 1", tt.wantReason, got)
+			}
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Errorf("hook error = %v, want the response passed through", err)
+				}
+				return
+			}
+			var rejected *plugin.ResponseError
+			if !errors.As(err, &rejected) || rejected.StatusCode != http.StatusBadGateway {
+				t.Fatalf("hook error = %v, want a 502 ResponseError", err)
+			}
+			if !strings.Contains(rejected.Body, "Upstream returned an unexpected response") {
+				t.Errorf("body = %s, want the configured message", rejected.Body)
+			}
+		})
+	}
+}
+
+func TestResponseValidator_Actions(t *testing.T) {
+	html := func() *http.Response {
+		return upstreamResponse(http.StatusOK, http.Header{"Content-Type": {"text/html"}}, "<h1>502 Bad Gateway</h1>")
+	}
+
+	// log passes the violating response on, but still counts it
+	p := newTestResponseValidator(t, `{"content_types": ["application/json"], "action": "log"}`)
+	resp := html()
+	before := p.violations.Value("r-orders", "content_type")
+	if err := validateResponse(t, p, resp); err != nil {
+		t.Errorf("log: hook error = %v, want nil", err)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "<h1>502 Bad Gateway</h1>" {
+		t.Errorf("log: body = %q, want the upstream's", body)
+	}
+	if got := p.violations.Value("r-orders", "content_type") - before; got != 1 {
+		t.Errorf("log: violations rose by %v, want 1", got)
+	}
+
+	// reject answers with the configured status and message
+	p = newTestResponseValidator(t, `{"content_types": ["application/json"], "status": 503, "message": "orders backend misbehaving"}`)
+	var rejected *plugin.ResponseError
+	if err := validateResponse(t, p, html()); !errors.As(err, &rejected) {
+		t.Fatalf("reject: hook error = %v, want a ResponseError", err)
+	}
+	var body map[string]string
+	if err := json.Unmarshal([]byte(rejected.Body), &body); err != nil {
+		t.Fatalf("reject: body %q isn't JSON: %v", rejected.Body, err)
+	}
+	if rejected.StatusCode != 503 || body["message"] != "orders backend misbehaving" {
+		t.Errorf("reject: %d %v, want 503 with the configured message", rejected.StatusCode, body)
+	}
+	if rejected.Reason != `unexpected content type "text/html"` {
+		t.Errorf("reject: reason = %q", rejected.Reason)
+	}
+}
+
+func TestResponseValidator_Config(t *testing.T) {
+	for _, config := range []string{
+		`{}`,
+		`{"content_types": ["application/json"], "action": "drop"}`,
+		`{"content_types": ["application/json"], "status": 200}`,
+		`{"content_types": ["application/json"], "status": 600}`,
+		`{"content_types": ["bad type"]}`,
+		`{"status_codes": ["6xx"]}`,
+		`{"status_codes": ["503-500"]}`,
+		`{"status_codes": ["ok"]}`,
+	} {
+		if _, err := NewResponseValidatorPlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewResponseValidatorPlugin(%s) succeeded, want error", config)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// Package builtin - Route permission plugin
+//
+// The route-permission plugin restricts authenticated consumers to the
+// routes and methods they are allowed to call. It is finer-grained than
+// group checks in the auth plugins: a consumer may read orders but not
+// delete them, or reach one admin route but not the rest.
+//
+// Each consumer (by consumer_id or consumer_username) and each group lists
+// rules; a request is allowed if any rule of the consumer or of one of its
+// groups matches it:
+//   - routes: route names or IDs
+//   - paths: path patterns as in routes: static segments, ":name" for any
+//     one segment, and a trailing "*" for one or more segments
+//   - methods: HTTP methods
+//
+// Empty routes and paths match any route; empty methods match any method.
+//
+// Configuration Example:
+//
+//	{
+//	  "consumers": {
+//	    "reporting-service": [{"paths": ["/orders/*"], "methods": ["GET"]}]
+//	  },
+//	  "groups": {
+//	    "gateway-admins": [{"routes": ["admin-api"]}],
+//	    "support": [{"routes": ["orders-read", "customers-read"]}]
+//	  },
+//	  "groups_key": "ldap-auth.groups",
+//	  "allow_unlisted": false
+//	}
+//
+// The plugin must run after an auth plugin. Requests without a consumer
+// get 401, consumers without a matching rule 403. Groups are read from the
+// context metadata key groups_key (ldap-auth sets "ldap-auth.groups"); any
+// plugin that sets a list of strings there works. Consumers with no rules
+// of their own or through a group are denied unless allow_unlisted is set.
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// RoutePermissionPlugin checks consumers against route allow-lists.
+type RoutePermissionPlugin struct {
+	config    RoutePermissionConfig
+	consumers map[string][]routeRule
+	groups    map[string][]routeRule
+	decisions *metrics.CounterVec
+}
+
+// RoutePermissionConfig holds configuration for the route permission plugin.
+type RoutePermissionConfig struct {
+	// Consumers maps a consumer ID or username to its rules
+	Consumers map[string][]RoutePermissionRule `json:"consumers"`
+
+	// Groups maps a group name to the rules of its members
+	Groups map[string][]RoutePermissionRule `json:"groups"`
+
+	// GroupsKey is the context metadata key holding the consumer's groups
+	// Default: "ldap-auth.groups"
+	GroupsKey string `json:"groups_key"`
+
+	// AllowUnlisted lets consumers without any rules through
+	// Default: false
+	AllowUnlisted bool `json:"allow_unlisted"`
+}
+
+// RoutePermissionRule allows a set of routes and methods.
+type RoutePermissionRule struct {
+	Routes  []string `json:"routes"`
+	Paths   []string `json:"paths"`
+	Methods []string `json:"methods"`
+}
+
+// routeRule is a compiled RoutePermissionRule.
+type routeRule struct {
+	routes  map[string]bool
+	paths   [][]string // split patterns
+	methods map[string]bool
+}
+
+// NewRoutePermissionPlugin creates a new route permission plugin.
+func NewRoutePermissionPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := RoutePermissionConfig{GroupsKey: ldapGroupsKey.Name()}
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid route-permission config: %w", err)
+		}
+	}
+	if len(config.Consumers) == 0 && len(config.Groups) == 0 && !config.AllowUnlisted {
+		return nil, fmt.Errorf("invalid route-permission config: no consumers or groups (every request would be denied)")
+	}
+
+	consumers, err := compileRouteRules(config.Consumers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid route-permission config: consumer %w", err)
+	}
+	groups, err := compileRouteRules(config.Groups)
+	if err != nil {
+		return nil, fmt.Errorf("invalid route-permission config: group %w", err)
+	}
+
+	return &RoutePermissionPlugin{
+		config:    config,
+		consumers: consumers,
+		groups:    groups,
+		decisions: plugin.NewMetrics("route-permission").Counter(
+			"decisions_total",
+			"Route permission checks, by route and result (allowed, denied, unauthenticated).",
+			"route", "result",
+		),
+	}, nil
+}
+
+func compileRouteRules(config map[string][]RoutePermissionRule) (map[string][]routeRule, error) {
+	compiled := make(map[string][]routeRule, len(config))
+	for name, rules := range config {
+		for _, r := range rules {
+			rule := routeRule{routes: make(map[string]bool), methods: make(map[string]bool)}
+			for _, route := range r.Routes {
+				rule.routes[route] = true
+			}
+			for _, pattern := range r.Paths {
+				if !strings.HasPrefix(pattern, "/") {
+					return nil, fmt.Errorf("%q: path %q must start with /", name, pattern)
+				}
+				segments := splitPathSegments(pattern)
+				for i, s := range segments {
+					if s == "*" && i != len(segments)-1 {
+						return nil, fmt.Errorf("%q: path %q: * must be the last segment", name, pattern)
+					}
+				}
+				rule.paths = append(rule.paths, segments)
+			}
+			for _, method := range r.Methods {
+				rule.methods[strings.ToUpper(method)] = true
+			}
+			compiled[name] = append(compiled[name], rule)
+		}
+	}
+	return compiled, nil
+}
+
+// Name returns the plugin identifier.
+func (p *RoutePermissionPlugin) Name() string {
+	return "route-permission"
+}
+
+// Execute checks the authenticated consumer's permissions.
+func (p *RoutePermissionPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	routeID, routeName := "", ""
+	if ctx.Route != nil {
+		routeID, routeName = ctx.Route.ID, ctx.Route.ID
+		if ctx.Route.Name.Valid && ctx.Route.Name.String != "" {
+			routeName = ctx.Route.Name.String
+		}
+	}
+
+	consumerID := plugin.KeyConsumerID.Value(ctx)
+	if consumerID == "" {
+		p.decisions.Inc(routeID, "unauthenticated")
+		ctx.Abort(http.StatusUnauthorized, "Authentication required")
+		return nil
+	}
+
+	var rules []routeRule
+	rules = append(rules, p.consumers[consumerID]...)
+	if username := plugin.KeyConsumerUsername.Value(ctx); username != "" && username != consumerID {
+		rules = append(rules, p.consumers[username]...)
+	}
+	for _, group := range contextGroups(ctx, p.config.GroupsKey) {
+		rules = append(rules, p.groups[group]...)
+	}
+
+	if len(rules) == 0 && p.config.AllowUnlisted {
+		p.decisions.Inc(routeID, "allowed")
+		return nil
+	}
+
+	segments := splitPathSegments(ctx.Request.URL.Path)
+	for _, rule := range rules {
+		if rule.allows(routeID, routeName, ctx.Request.Method, segments) {
+			p.decisions.Inc(routeID, "allowed")
+			return nil
+		}
+	}
+
+	p.decisions.Inc(routeID, "denied")
+	log.Info().
+		Str("component", "plugin").
+		Str("plugin", "route-permission").
+		Str("route_id", routeID).
+		Str("consumer_id", consumerID).
+		Str("method", ctx.Request.Method).
+		Str("path", ctx.Request.URL.Path).
+		Msg("Consumer not permitted on route")
+	ctx.Decide(fmt.Sprintf("consumer %s not permitted to %s %s", consumerID, ctx.Request.Method, routeName))
+	ctx.Abort(http.StatusForbidden, "Forbidden")
+	return nil
+}
+
+// contextGroups returns the consumer's groups from the context metadata
+// key, which may hold a list of strings or a single string.
+func contextGroups(ctx *plugin.Context, key string) []string {
+	value, ok := ctx.Lookup(key)
+	if !ok {
+		return nil
+	}
+	switch groups := value.(type) {
+	case []string:
+		return groups
+	case []interface{}:
+		names := make([]string, 0, len(groups))
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				names = append(names, s)
+			}
+		}
+		return names
+	case string:
+		return []string{groups}
+	}
+	return nil
+}
+
+// allows reports whether the rule matches a request.
+func (r routeRule) allows(routeID, routeName, method string, segments []string) bool {
+	if len(r.methods) > 0 && !r.methods[method] {
+		return false
+	}
+	if len(r.routes) == 0 && len(r.paths) == 0 {
+		return true
+	}
+	if r.routes[routeName] || r.routes[routeID] {
+		return true
+	}
+	for _, pattern := range r.paths {
+		if matchPathSegments(pattern, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPathSegments matches path segments against a pattern like the
+// router does: static segments exactly, ":name" any one segment, and a
+// trailing "*" one or more segments.
+func matchPathSegments(pattern, segments []string) bool {
+	for i, p := range pattern {
+		if i >= len(segments) {
+			return false
+		}
+		if p == "*" {
+			return true
+		}
+		if !strings.HasPrefix(p, ":") && p != segments[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(segments)
+}
+
+// splitPathSegments splits a path into non-empty segments.
+func splitPathSegments(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
@@ -0,0 +1,119 @@
+package builtin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+func TestRoutePermission_Rules(t *testing.T) {
+	config := `{
+		"consumers": {
+			"c-reporting": [{"paths": ["/orders/*"], "methods": ["get"]}],
+			"ops-bot": [{"paths": ["/orders/:id/refund"], "methods": ["POST"]}]
+		},
+		"groups": {
+			"admins": [{"routes": ["admin-api"]}],
+			"support": [{"routes": ["r-customers"], "methods": ["GET"]}]
+		}
+	}`
+	p, err := NewRoutePermissionPlugin(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewRoutePermissionPlugin() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		route    string // route name; the route ID is "r-" + name
+		consumer string
+		username string
+		groups   interface{}
+		want     int // 0 = allowed
+	}{
+		{name: "unauthenticated", path: "/orders/1", route: "orders", want: http.StatusUnauthorized},
+		{name: "path and method", path: "/orders/1", route: "orders", consumer: "c-reporting"},
+		{name: "nested path", path: "/orders/1/items", route: "orders", consumer: "c-reporting"},
+		{name: "wildcard needs a segment", path: "/orders", route: "orders", consumer: "c-reporting", want: http.StatusForbidden},
+		{name: "wrong method", method: "DELETE", path: "/orders/1", route: "orders", consumer: "c-reporting", want: http.StatusForbidden},
+		{name: "other path", path: "/customers/1", route: "customers", consumer: "c-reporting", want: http.StatusForbidden},
+		{name: "param segment", method: "POST", path: "/orders/42/refund", route: "orders", consumer: "ops-bot"},
+		{name: "param segment, extra segment", method: "POST", path: "/orders/42/refund/now", route: "orders", consumer: "ops-bot", want: http.StatusForbidden},
+		{name: "by username", path: "/orders/1", route: "orders", consumer: "c-9", username: "c-reporting"},
+		{name: "group by route name", method: "DELETE", path: "/admin/routes", route: "admin-api", consumer: "c-9", groups: []string{"admins"}},
+		{name: "group by route ID", path: "/customers/1", route: "customers", consumer: "c-9", groups: []interface{}{"support"}},
+		{name: "group, single string", path: "/customers/1", route: "customers", consumer: "c-9", groups: "support"},
+		{name: "group, wrong method", method: "PUT", path: "/customers/1", route: "customers", consumer: "c-9", groups: []string{"support"}, want: http.StatusForbidden},
+		{name: "unknown group", path: "/admin/routes", route: "admin-api", consumer: "c-9", groups: []string{"guests"}, want: http.StatusForbidden},
+		{name: "unlisted", path: "/orders/1", route: "orders", consumer: "c-9", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			route := &database.Route{ID: "r-" + tt.route, Name: sql.NullString{String: tt.route, Valid: true}}
+			ctx := plugin.NewContext(httptest.NewRequest(method, tt.path, nil), httptest.NewRecorder(), route, &database.Service{}, plugin.PhaseBeforeRequest)
+			if tt.consumer != "" {
+				plugin.KeyConsumerID.Set(ctx, tt.consumer)
+			}
+			if tt.username != "" {
+				plugin.KeyConsumerUsername.Set(ctx, tt.username)
+			}
+			if tt.groups != nil {
+				ctx.Set(ldapGroupsKey.Name(), tt.groups)
+			}
+
+			if err := p.Execute(ctx); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got := ctx.AbortStatusCode(); ctx.IsAborted() != (tt.want != 0) || got != tt.want {
+				t.Errorf("aborted = %v with %d, want %d", ctx.IsAborted(), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoutePermission_AllowUnlisted(t *testing.T) {
+	p, err := NewRoutePermissionPlugin(json.RawMessage(`{"allow_unlisted": true, "consumers": {"c-limited": [{"methods": ["GET"]}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		consumer string
+		allowed  bool
+	}{
+		{consumer: "c-other", allowed: true},
+		{consumer: "c-limited", allowed: false}, // listed consumers keep their rules
+	} {
+		ctx := newTestContext(httptest.NewRequest("POST", "/orders", nil), "r-orders")
+		plugin.KeyConsumerID.Set(ctx, tt.consumer)
+		if err := p.Execute(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if ctx.IsAborted() == tt.allowed {
+			t.Errorf("%s: aborted = %v, want allowed %v", tt.consumer, ctx.IsAborted(), tt.allowed)
+		}
+	}
+}
+
+func TestRoutePermission_Config(t *testing.T) {
+	for _, config := range []string{
+		`{}`,
+		`{"consumers": {"c1": [{"paths": ["orders/*"]}]}}`,
+		`{"groups": {"g": [{"paths": ["/orders/*/items"]}]}}`,
+	} {
+		if _, err := NewRoutePermissionPlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewRoutePermissionPlugin(%s) succeeded, want error", config)
+		}
+	}
+}
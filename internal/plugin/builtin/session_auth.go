@@ -0,0 +1,370 @@
+// Package builtin - Session cookie authentication plugin
+//
+// This plugin lets browser clients (SPAs) authenticate with an opaque
+// session cookie instead of keeping tokens in JavaScript, while backends
+// keep receiving bearer tokens. The login service stores the session in
+// Redis; the gateway validates the cookie against it and forwards the
+// session's access token upstream:
+//
+//	Cookie: sb_session=8c1f...   ->   Authorization: Bearer <access_token>
+//
+// Redis layout: GET <key_prefix><sha256 hex of the cookie value> (or of
+// the raw value when hash_session_id is false). The value is a JSON
+// object:
+//
+//	{"consumer_id": "...", "username": "...", "workspace": "...",
+//	 "access_token": "...", "claims": {"scope": "orders:read"},
+//	 "expires_at": "<RFC 3339>"}
+//
+// End a session with DEL (or a Redis TTL / expires_at). The claims are
+// exposed as the request's token claims, so auth-summary and
+// token-exchange work as for token-authenticated requests.
+//
+// Lookups are cached in memory for cache_ttl, so a deleted session can
+// keep working for up to cache_ttl on each gateway instance. Set
+// cache_ttl to "0s" to disable caching.
+//
+// With idle_timeout, every lookup that reaches Redis resets the session
+// key's TTL to idle_timeout (sliding expiry), so sessions end after that
+// long without requests. Lookups served from the cache don't extend it,
+// which is why idle_timeout must be longer than cache_ttl. expires_at
+// still ends a session at a fixed time.
+//
+// Configuration Example:
+//
+//	{
+//	  "cookie_name": "sb_session",
+//	  "redis_url": "redis://localhost:6379/0",
+//	  "key_prefix": "session:",
+//	  "hash_session_id": true,
+//	  "cache_ttl": "10s",
+//	  "idle_timeout": "30m",
+//	  "header_name": "Authorization",
+//	  "hide_cookie": true
+//	}
+//
+// Client-supplied values of header_name are always removed, so backends
+// only see tokens the gateway attached. Missing, unknown and expired
+// sessions get 401; store failures return 503 (never fail open).
+package builtin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/ratelimit"
+)
+
+// maxSessionCacheEntries bounds the lookup cache; it is cleared when full.
+const maxSessionCacheEntries = 10000
+
+// errSessionNotFound means the store does not know the session (or it
+// expired).
+var errSessionNotFound = errors.New("session not found")
+
+// sessionStore holds session records (a ratelimit.RedisStore).
+type sessionStore interface {
+	Get(ctx context.Context, key string) (string, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// SessionAuthPlugin authenticates browser session cookies.
+type SessionAuthPlugin struct {
+	config      SessionAuthConfig
+	store       sessionStore
+	cacheTTL    time.Duration
+	idleTimeout time.Duration
+	lookups     *metrics.CounterVec
+
+	mu    sync.Mutex
+	cache map[string]cachedSession // keyed by Redis key
+}
+
+// SessionAuthConfig holds configuration for the session auth plugin.
+type SessionAuthConfig struct {
+	// CookieName is the cookie carrying the session ID
+	// Default: "sb_session"
+	CookieName string `json:"cookie_name"`
+
+	// RedisURL is the Redis connection string
+	// Default: "redis://localhost:6379/0"
+	RedisURL string `json:"redis_url"`
+
+	// KeyPrefix is prepended to session IDs to form Redis keys
+	// Default: "session:"
+	KeyPrefix string `json:"key_prefix"`
+
+	// HashSessionID looks sessions up by the SHA-256 hex of the cookie
+	// value instead of the value itself
+	// Default: true
+	HashSessionID bool `json:"hash_session_id"`
+
+	// CacheTTL is how long lookups are cached in memory ("0s" disables)
+	// Default: "10s"
+	CacheTTL string `json:"cache_ttl"`
+
+	// IdleTimeout, when set, resets the session key's TTL on every store
+	// lookup (sliding expiry); must be longer than cache_ttl
+	// Default: "" (the TTL is left alone)
+	IdleTimeout string `json:"idle_timeout"`
+
+	// HeaderName is the upstream request header carrying "Bearer <token>"
+	// Default: "Authorization"
+	HeaderName string `json:"header_name"`
+
+	// HideCookie removes the session cookie before proxying
+	// Default: true
+	HideCookie bool `json:"hide_cookie"`
+}
+
+// DefaultSessionAuthConfig returns sensible defaults.
+func DefaultSessionAuthConfig() SessionAuthConfig {
+	return SessionAuthConfig{
+		CookieName:    "sb_session",
+		RedisURL:      "redis://localhost:6379/0",
+		KeyPrefix:     "session:",
+		HashSessionID: true,
+		CacheTTL:      "10s",
+		HeaderName:    "Authorization",
+		HideCookie:    true,
+	}
+}
+
+// browserSession is the record a session cookie resolves to.
+type browserSession struct {
+	ConsumerID  string                 `json:"consumer_id"`
+	Username    string                 `json:"username,omitempty"`
+	Workspace   string                 `json:"workspace,omitempty"`
+	AccessToken string                 `json:"access_token,omitempty"`
+	Claims      map[string]interface{} `json:"claims,omitempty"`
+	ExpiresAt   *time.Time             `json:"expires_at,omitempty"`
+}
+
+// cachedSession is a lookup cache entry.
+type cachedSession struct {
+	session browserSession
+	expires time.Time
+}
+
+// NewSessionAuthPlugin creates a new session auth plugin.
+func NewSessionAuthPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := DefaultSessionAuthConfig()
+
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid session-auth config: %w", err)
+		}
+	}
+
+	cacheTTL, err := time.ParseDuration(config.CacheTTL)
+	if err != nil || cacheTTL < 0 {
+		return nil, fmt.Errorf("invalid cache_ttl '%s'", config.CacheTTL)
+	}
+	var idleTimeout time.Duration
+	if config.IdleTimeout != "" {
+		idleTimeout, err = time.ParseDuration(config.IdleTimeout)
+		if err != nil || idleTimeout <= 0 {
+			return nil, fmt.Errorf("invalid idle_timeout '%s'", config.IdleTimeout)
+		}
+		if idleTimeout <= cacheTTL {
+			return nil, fmt.Errorf("idle_timeout must be longer than cache_ttl")
+		}
+	}
+	if config.CookieName == "" {
+		return nil, fmt.Errorf("cookie_name is required")
+	}
+	if config.HeaderName == "" {
+		return nil, fmt.Errorf("header_name is required")
+	}
+
+	redisConfig := ratelimit.DefaultRedisConfig()
+	redisConfig.URL = config.RedisURL
+	store, err := ratelimit.NewRedisStore(redisConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create redis store: %w", err)
+	}
+
+	log.Info().
+		Str("component", "plugin").
+		Str("plugin", "session-auth").
+		Str("cookie", config.CookieName).
+		Dur("cache_ttl", cacheTTL).
+		Dur("idle_timeout", idleTimeout).
+		Msg("Initializing session auth plugin")
+
+	return &SessionAuthPlugin{
+		config:      config,
+		store:       store,
+		cacheTTL:    cacheTTL,
+		idleTimeout: idleTimeout,
+		cache:       make(map[string]cachedSession),
+		lookups: plugin.NewMetrics("session-auth").Counter(
+			"lookups_total",
+			"Session lookups by result (cache_hit, hit, not_found, error)",
+			"result",
+		),
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *SessionAuthPlugin) Name() string {
+	return "session-auth"
+}
+
+// Execute runs the session auth plugin.
+//
+// BeforeRequest: resolve the session cookie and attach its token.
+// AfterResponse: nothing.
+func (p *SessionAuthPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase == plugin.PhaseAfterResponse {
+		return nil
+	}
+
+	// Only tokens from the session store reach the backend
+	ctx.Request.Header.Del(p.config.HeaderName)
+
+	cookie, err := ctx.Request.Cookie(p.config.CookieName)
+	if err != nil || cookie.Value == "" {
+		ctx.Abort(http.StatusUnauthorized, "Missing session")
+		return nil
+	}
+
+	session, err := p.lookup(ctx.Context(), cookie.Value)
+	if errors.Is(err, errSessionNotFound) {
+		ctx.Abort(http.StatusUnauthorized, "Invalid session")
+		return nil
+	}
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("component", "plugin").
+			Str("plugin", "session-auth").
+			Msg("Session lookup failed")
+		ctx.Abort(http.StatusServiceUnavailable, "Authentication service unavailable")
+		return fmt.Errorf("session lookup failed: %w", err)
+	}
+
+	// Sessions only authenticate in their own workspace
+	if session.Workspace != "" && ctx.Route != nil && session.Workspace != database.WorkspaceName(ctx.Route.Workspace) {
+		ctx.Abort(http.StatusUnauthorized, "Invalid session")
+		return nil
+	}
+
+	plugin.KeyConsumerID.Set(ctx, session.ConsumerID)
+	if session.Username != "" {
+		plugin.KeyConsumerUsername.Set(ctx, session.Username)
+	}
+	if len(session.Claims) > 0 {
+		plugin.KeyTokenClaims.Set(ctx, session.Claims)
+	}
+	plugin.KeyAuthMechanism.Set(ctx, authMechanismSession)
+
+	if session.AccessToken != "" {
+		ctx.Request.Header.Set(p.config.HeaderName, "Bearer "+session.AccessToken)
+	}
+	if p.config.HideCookie {
+		removeCookie(ctx.Request, p.config.CookieName)
+	}
+
+	log.Debug().
+		Str("component", "plugin").
+		Str("plugin", "session-auth").
+		Str("consumer_id", session.ConsumerID).
+		Msg("Session verified")
+
+	return nil
+}
+
+// lookup resolves a session ID through the cache and Redis.
+func (p *SessionAuthPlugin) lookup(ctx context.Context, id string) (browserSession, error) {
+	key := id
+	if p.config.HashSessionID {
+		sum := sha256.Sum256([]byte(id))
+		key = hex.EncodeToString(sum[:])
+	}
+	key = p.config.KeyPrefix + key
+	now := time.Now()
+
+	if p.cacheTTL > 0 {
+		p.mu.Lock()
+		entry, ok := p.cache[key]
+		p.mu.Unlock()
+		if ok && now.Before(entry.expires) && !sessionExpired(entry.session, now) {
+			p.lookups.Inc("cache_hit")
+			return entry.session, nil
+		}
+	}
+
+	value, err := p.store.Get(ctx, key)
+	if err != nil {
+		p.lookups.Inc("error")
+		return browserSession{}, err
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		p.lookups.Inc("not_found")
+		return browserSession{}, errSessionNotFound
+	}
+
+	var session browserSession
+	if err := json.Unmarshal([]byte(value), &session); err != nil {
+		p.lookups.Inc("error")
+		return browserSession{}, fmt.Errorf("invalid session record: %w", err)
+	}
+	if session.ConsumerID == "" || sessionExpired(session, now) {
+		p.lookups.Inc("not_found")
+		return browserSession{}, errSessionNotFound
+	}
+	p.lookups.Inc("hit")
+
+	// A failed refresh only shortens the session; the request goes on
+	if p.idleTimeout > 0 {
+		if err := p.store.Expire(ctx, key, p.idleTimeout); err != nil {
+			log.Warn().
+				Err(err).
+				Str("component", "plugin").
+				Str("plugin", "session-auth").
+				Msg("Failed to extend session")
+		}
+	}
+
+	if p.cacheTTL > 0 {
+		p.mu.Lock()
+		if len(p.cache) >= maxSessionCacheEntries {
+			p.cache = make(map[string]cachedSession)
+		}
+		p.cache[key] = cachedSession{session: session, expires: now.Add(p.cacheTTL)}
+		p.mu.Unlock()
+	}
+
+	return session, nil
+}
+
+// sessionExpired reports whether a session record's expires_at has passed.
+func sessionExpired(session browserSession, now time.Time) bool {
+	return session.ExpiresAt != nil && !now.Before(*session.ExpiresAt)
+}
+
+// removeCookie drops the named cookie from the request's Cookie headers,
+// keeping the others.
+func removeCookie(r *http.Request, name string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name != name {
+			r.AddCookie(c)
+		}
+	}
+}
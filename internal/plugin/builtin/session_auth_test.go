@@ -0,0 +1,239 @@
+package builtin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// fakeSessionStore is an in-memory sessionStore.
+type fakeSessionStore struct {
+	mu      sync.Mutex
+	records map[string]string
+	ttls    map[string]time.Duration
+	gets    int
+	err     error
+}
+
+func newFakeSessionStore() *fakeSessionStore {
+	return &fakeSessionStore{records: make(map[string]string), ttls: make(map[string]time.Duration)}
+}
+
+func (s *fakeSessionStore) Get(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gets++
+	if s.err != nil {
+		return "", s.err
+	}
+	return s.records[key], nil
+}
+
+func (s *fakeSessionStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[key]; ok {
+		s.ttls[key] = ttl
+	}
+	return nil
+}
+
+// put stores a session record under the hashed cookie value.
+func (s *fakeSessionStore) put(id, record string) string {
+	sum := sha256.Sum256([]byte(id))
+	key := "session:" + hex.EncodeToString(sum[:])
+	s.mu.Lock()
+	s.records[key] = record
+	s.mu.Unlock()
+	return key
+}
+
+// newTestSessionPlugin builds the plugin on store instead of Redis.
+func newTestSessionPlugin(store sessionStore, cacheTTL, idleTimeout time.Duration) *SessionAuthPlugin {
+	return &SessionAuthPlugin{
+		config:      DefaultSessionAuthConfig(),
+		store:       store,
+		cacheTTL:    cacheTTL,
+		idleTimeout: idleTimeout,
+		cache:       make(map[string]cachedSession),
+		lookups:     plugin.NewMetrics("session-auth").Counter("lookups_total", "", "result"),
+	}
+}
+
+// sessionRequest runs p on a request carrying the session cookie (if any).
+func sessionRequest(t *testing.T, p *SessionAuthPlugin, cookie string) *plugin.Context {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/orders", nil)
+	r.Header.Set("Authorization", "Bearer client-supplied")
+	r.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	if cookie != "" {
+		r.AddCookie(&http.Cookie{Name: "sb_session", Value: cookie})
+	}
+	ctx := plugin.NewContext(r, httptest.NewRecorder(), &database.Route{ID: "r1", Workspace: "default"}, &database.Service{}, plugin.PhaseBeforeRequest)
+	if err := p.Execute(ctx); err != nil && !ctx.IsAborted() {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	return ctx
+}
+
+func TestSessionAuth_Sessions(t *testing.T) {
+	store := newFakeSessionStore()
+	store.put("s-valid", `{"consumer_id": "c-1", "username": "ada", "access_token": "at-1", "claims": {"scope": "orders:read"}}`)
+	store.put("s-expired", `{"consumer_id": "c-1", "access_token": "at-1", "expires_at": "2020-01-01T00:00:00Z"}`)
+	store.put("s-other-workspace", `{"consumer_id": "c-1", "workspace": "payments"}`)
+	store.put("s-no-consumer", `{"access_token": "at-1"}`)
+	store.put("s-corrupt", `{"consumer_id":`)
+	p := newTestSessionPlugin(store, 0, 0)
+
+	tests := []struct {
+		name   string
+		cookie string
+		want   int // 0 = allowed
+	}{
+		{name: "valid", cookie: "s-valid"},
+		{name: "missing cookie", want: http.StatusUnauthorized},
+		{name: "unknown session", cookie: "s-unknown", want: http.StatusUnauthorized},
+		{name: "tampered cookie", cookie: "s-valiD", want: http.StatusUnauthorized},
+		{name: "expired", cookie: "s-expired", want: http.StatusUnauthorized},
+		{name: "other workspace", cookie: "s-other-workspace", want: http.StatusUnauthorized},
+		{name: "no consumer", cookie: "s-no-consumer", want: http.StatusUnauthorized},
+		{name: "corrupt record", cookie: "s-corrupt", want: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := sessionRequest(t, p, tt.cookie)
+			if got := ctx.AbortStatusCode(); ctx.IsAborted() != (tt.want != 0) || got != tt.want {
+				t.Fatalf("aborted = %v with %d (%s), want %d", ctx.IsAborted(), got, ctx.AbortMessage(), tt.want)
+			}
+
+			r := ctx.Request
+			if tt.want != 0 {
+				if got := r.Header.Get("Authorization"); got != "" {
+					t.Errorf("Authorization = %q on a rejected request, want it removed", got)
+				}
+				return
+			}
+			if got := plugin.KeyConsumerID.Value(ctx); got != "c-1" {
+				t.Errorf("consumer_id = %q, want c-1", got)
+			}
+			if got := plugin.KeyConsumerUsername.Value(ctx); got != "ada" {
+				t.Errorf("username = %q, want ada", got)
+			}
+			if got := plugin.KeyTokenClaims.Value(ctx)["scope"]; got != "orders:read" {
+				t.Errorf("scope claim = %v, want orders:read", got)
+			}
+			if got := r.Header.Get("Authorization"); got != "Bearer at-1" {
+				t.Errorf("Authorization = %q, want the session's token", got)
+			}
+			if _, err := r.Cookie("sb_session"); err == nil {
+				t.Error("session cookie sent upstream")
+			}
+			if _, err := r.Cookie("theme"); err != nil {
+				t.Error("other cookies removed along with the session cookie")
+			}
+		})
+	}
+}
+
+func TestSessionAuth_Revoked(t *testing.T) {
+	store := newFakeSessionStore()
+	key := store.put("s-1", `{"consumer_id": "c-1"}`)
+
+	// Without the cache a deleted session stops working at once
+	p := newTestSessionPlugin(store, 0, 0)
+	if ctx := sessionRequest(t, p, "s-1"); ctx.IsAborted() {
+		t.Fatalf("valid session rejected: %s", ctx.AbortMessage())
+	}
+	delete(store.records, key)
+	if ctx := sessionRequest(t, p, "s-1"); ctx.AbortStatusCode() != http.StatusUnauthorized {
+		t.Errorf("revoked session: status %d, want 401", ctx.AbortStatusCode())
+	}
+
+	// With it, for up to cache_ttl
+	store.put("s-1", `{"consumer_id": "c-1"}`)
+	p = newTestSessionPlugin(store, time.Minute, 0)
+	sessionRequest(t, p, "s-1")
+	delete(store.records, key)
+	if ctx := sessionRequest(t, p, "s-1"); ctx.IsAborted() {
+		t.Errorf("cached session rejected: %s", ctx.AbortMessage())
+	}
+	if store.gets != 3 {
+		t.Errorf("store lookups = %d, want 3 (the last served from the cache)", store.gets)
+	}
+}
+
+func TestSessionAuth_SlidingExpiry(t *testing.T) {
+	store := newFakeSessionStore()
+	key := store.put("s-1", `{"consumer_id": "c-1"}`)
+
+	p := newTestSessionPlugin(store, 10*time.Second, 30*time.Minute)
+	if ctx := sessionRequest(t, p, "s-1"); ctx.IsAborted() {
+		t.Fatalf("valid session rejected: %s", ctx.AbortMessage())
+	}
+	if got := store.ttls[key]; got != 30*time.Minute {
+		t.Errorf("session TTL = %v after a lookup, want idle_timeout", got)
+	}
+
+	// Cache hits don't reach the store, so they don't extend the session
+	store.ttls[key] = time.Minute
+	sessionRequest(t, p, "s-1")
+	if got := store.ttls[key]; got != time.Minute {
+		t.Errorf("session TTL = %v after a cache hit, want it unchanged", got)
+	}
+
+	// Unknown sessions aren't created by the refresh
+	sessionRequest(t, p, "s-unknown")
+	if len(store.ttls) != 1 {
+		t.Errorf("TTLs set for %d keys, want 1", len(store.ttls))
+	}
+
+	// Without idle_timeout the TTL is left alone
+	delete(store.ttls, key)
+	sessionRequest(t, newTestSessionPlugin(store, 0, 0), "s-1")
+	if _, ok := store.ttls[key]; ok {
+		t.Error("session TTL changed without idle_timeout")
+	}
+}
+
+func TestSessionAuth_StoreDown(t *testing.T) {
+	store := newFakeSessionStore()
+	store.put("s-1", `{"consumer_id": "c-1"}`)
+	store.err = errors.New("connection refused")
+
+	ctx := plugin.NewContext(httptest.NewRequest("GET", "/orders", nil), httptest.NewRecorder(), &database.Route{ID: "r1"}, &database.Service{}, plugin.PhaseBeforeRequest)
+	ctx.Request.AddCookie(&http.Cookie{Name: "sb_session", Value: "s-1"})
+	err := newTestSessionPlugin(store, 0, 0).Execute(ctx)
+	if err == nil || ctx.AbortStatusCode() != http.StatusServiceUnavailable {
+		t.Errorf("Execute() = %v with status %d, want an error and 503", err, ctx.AbortStatusCode())
+	}
+}
+
+func TestSessionAuth_Config(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{name: "bad cache_ttl", config: `{"cache_ttl": "soon"}`},
+		{name: "bad idle_timeout", config: `{"idle_timeout": "-1m"}`},
+		{name: "idle_timeout within cache_ttl", config: `{"cache_ttl": "1m", "idle_timeout": "30s"}`},
+		{name: "no cookie name", config: `{"cookie_name": ""}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSessionAuthPlugin([]byte(tt.config)); err == nil {
+				t.Errorf("NewSessionAuthPlugin(%s) succeeded, want an error", tt.config)
+			}
+		})
+	}
+}
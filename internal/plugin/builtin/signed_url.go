@@ -0,0 +1,150 @@
+// Package builtin - Signed URL plugin
+//
+// The signed-url plugin admits requests carrying a valid gateway signature
+// in their query string, so backends can hand out temporary public links
+// to resources that otherwise need authentication:
+//
+//	GET /files/report.pdf?sb_expires=1767225600&sb_consumer=c1&sb_signature=...
+//
+// Links are signed with SIGNED_URL_SECRETS, by POST /admin/signed-urls or
+// by a backend holding the secret (see signedurl for the scheme). A link
+// bound to a consumer authenticates the request as that consumer
+// (plugin.KeyConsumerID is set, auth mechanism "signed_url"), so rate limits and
+// usage are attributed to it.
+//
+// Configuration Example:
+//
+//	{
+//	  "methods": ["GET", "HEAD"],
+//	  "strip_params": true
+//	}
+//
+// Requests with a missing, invalid or expired signature, or another
+// method, get 403. The plugin replaces the auth plugin on a route: put
+// shareable paths on their own route with signed-url.
+package builtin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/signedurl"
+)
+
+// SignedURLPlugin verifies signed URLs.
+type SignedURLPlugin struct {
+	config        SignedURLConfig
+	signer        *signedurl.Signer
+	methods       map[string]bool
+	verifications *metrics.CounterVec
+}
+
+// SignedURLConfig holds configuration for the signed URL plugin.
+type SignedURLConfig struct {
+	// Methods are the request methods signed links may be used with
+	// Default: ["GET", "HEAD"]
+	Methods []string `json:"methods"`
+
+	// StripParams removes the signature parameters before proxying
+	// Default: true
+	StripParams bool `json:"strip_params"`
+}
+
+// NewSignedURLFactory returns a factory for signed-url plugins verifying
+// with signer. Without a signer the plugin can't be configured.
+func NewSignedURLFactory(signer *signedurl.Signer) plugin.PluginFactory {
+	return func(configJSON json.RawMessage) (plugin.Plugin, error) {
+		if signer == nil {
+			return nil, fmt.Errorf("signed-url requires signing secrets (set SIGNED_URL_SECRETS)")
+		}
+
+		config := SignedURLConfig{
+			Methods:     []string{http.MethodGet, http.MethodHead},
+			StripParams: true,
+		}
+		if len(configJSON) > 0 {
+			if err := json.Unmarshal(configJSON, &config); err != nil {
+				return nil, fmt.Errorf("invalid signed-url config: %w", err)
+			}
+		}
+		if len(config.Methods) == 0 {
+			return nil, fmt.Errorf("invalid signed-url config: methods is required")
+		}
+
+		methods := make(map[string]bool, len(config.Methods))
+		for _, method := range config.Methods {
+			methods[strings.ToUpper(method)] = true
+		}
+
+		return &SignedURLPlugin{
+			config:  config,
+			signer:  signer,
+			methods: methods,
+			verifications: plugin.NewMetrics("signed-url").Counter(
+				"verifications_total",
+				"Signed URL checks, by route and result (verified, missing, invalid, expired, method).",
+				"route", "result",
+			),
+		}, nil
+	}
+}
+
+// Name returns the plugin identifier.
+func (p *SignedURLPlugin) Name() string {
+	return "signed-url"
+}
+
+// Execute verifies the request's signature.
+func (p *SignedURLPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+
+	r := ctx.Request
+	if !p.methods[r.Method] {
+		p.verifications.Inc(routeID, "method")
+		ctx.Abort(http.StatusForbidden, "Signed URLs are not valid for "+r.Method)
+		return nil
+	}
+
+	query := r.URL.Query()
+	consumerID, err := p.signer.Verify(r.URL.Path, query, time.Now())
+	if err != nil {
+		result := "invalid"
+		switch {
+		case errors.Is(err, signedurl.ErrMissing):
+			result = "missing"
+		case errors.Is(err, signedurl.ErrExpired):
+			result = "expired"
+		}
+		p.verifications.Inc(routeID, result)
+		ctx.Decide("signed url " + result)
+		ctx.Abort(http.StatusForbidden, "Invalid or expired signed URL")
+		return nil
+	}
+
+	p.verifications.Inc(routeID, "verified")
+	if consumerID != "" {
+		plugin.KeyConsumerID.Set(ctx, consumerID)
+		plugin.KeyAuthMechanism.Set(ctx, authMechanismSignedURL)
+	}
+
+	if p.config.StripParams {
+		query.Del(signedurl.ParamExpires)
+		query.Del(signedurl.ParamConsumer)
+		query.Del(signedurl.ParamSignature)
+		r.URL.RawQuery = query.Encode()
+	}
+	return nil
+}
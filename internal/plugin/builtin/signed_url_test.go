@@ -0,0 +1,136 @@
+package builtin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/signedurl"
+)
+
+func TestSignedURL_Verify(t *testing.T) {
+	signer, err := signedurl.NewSigner([]string{"current", "previous"}, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, _ := signedurl.NewSigner([]string{"previous"}, 24*time.Hour)
+	other, _ := signedurl.NewSigner([]string{"other"}, 24*time.Hour)
+	long, _ := signedurl.NewSigner([]string{"current"}, 48*time.Hour)
+
+	now := time.Now()
+	link := func(s *signedurl.Signer, path string, expires time.Time, consumerID string) url.Values {
+		query, err := s.Sign(path, expires, consumerID)
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		return query
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		query    url.Values
+		want     int // 0 = admitted
+		consumer string
+	}{
+		{name: "valid", path: "/files/a.pdf", query: link(signer, "/files/a.pdf", now.Add(time.Hour), "")},
+		{name: "bound to a consumer", path: "/files/a.pdf", query: link(signer, "/files/a.pdf", now.Add(time.Hour), "c1"), consumer: "c1"},
+		{name: "rotated secret", path: "/files/a.pdf", query: link(previous, "/files/a.pdf", now.Add(time.Hour), "")},
+		{name: "head", method: "HEAD", path: "/files/a.pdf", query: link(signer, "/files/a.pdf", now.Add(time.Hour), "")},
+		{name: "missing", path: "/files/a.pdf", query: url.Values{}, want: http.StatusForbidden},
+		{name: "expired", path: "/files/a.pdf", query: link(signer, "/files/a.pdf", now.Add(-time.Second), ""), want: http.StatusForbidden},
+		{name: "past the max TTL", path: "/files/a.pdf", query: link(long, "/files/a.pdf", now.Add(36*time.Hour), ""), want: http.StatusForbidden},
+		{name: "unknown secret", path: "/files/a.pdf", query: link(other, "/files/a.pdf", now.Add(time.Hour), ""), want: http.StatusForbidden},
+		{name: "other path", path: "/files/b.pdf", query: link(signer, "/files/a.pdf", now.Add(time.Hour), ""), want: http.StatusForbidden},
+		{name: "tampered expiry", path: "/files/a.pdf", query: func() url.Values {
+			q := link(signer, "/files/a.pdf", now.Add(time.Hour), "")
+			q.Set(signedurl.ParamExpires, "9999999999")
+			return q
+		}(), want: http.StatusForbidden},
+		{name: "tampered consumer", path: "/files/a.pdf", query: func() url.Values {
+			q := link(signer, "/files/a.pdf", now.Add(time.Hour), "c1")
+			q.Set(signedurl.ParamConsumer, "c2")
+			return q
+		}(), want: http.StatusForbidden},
+		{name: "consumer added", path: "/files/a.pdf", query: func() url.Values {
+			q := link(signer, "/files/a.pdf", now.Add(time.Hour), "")
+			q.Set(signedurl.ParamConsumer, "admin")
+			return q
+		}(), want: http.StatusForbidden},
+		{name: "tampered signature", path: "/files/a.pdf", query: func() url.Values {
+			q := link(signer, "/files/a.pdf", now.Add(time.Hour), "")
+			q.Set(signedurl.ParamSignature, "AAAA"+q.Get(signedurl.ParamSignature)[4:])
+			return q
+		}(), want: http.StatusForbidden},
+		{name: "method not allowed", method: "POST", path: "/files/a.pdf", query: link(signer, "/files/a.pdf", now.Add(time.Hour), ""), want: http.StatusForbidden},
+	}
+
+	p, err := NewSignedURLFactory(signer)(nil)
+	if err != nil {
+		t.Fatalf("NewSignedURLFactory() error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			query := tt.query
+			query.Set("page", "2")
+			r := httptest.NewRequest(method, tt.path+"?"+query.Encode(), nil)
+			ctx := newTestContext(r, "r-files")
+
+			if err := p.Execute(ctx); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got := ctx.AbortStatusCode(); ctx.IsAborted() != (tt.want != 0) || got != tt.want {
+				t.Fatalf("aborted = %v with %d %q, want %d", ctx.IsAborted(), got, ctx.AbortMessage(), tt.want)
+			}
+			if tt.want != 0 {
+				return
+			}
+
+			if got := plugin.KeyConsumerID.Value(ctx); got != tt.consumer {
+				t.Errorf("consumer = %q, want %q", got, tt.consumer)
+			}
+			// Signature parameters are stripped, others proxied
+			if got := r.URL.RawQuery; got != "page=2" {
+				t.Errorf("proxied query = %q, want page=2", got)
+			}
+		})
+	}
+}
+
+func TestSignedURL_KeepParams(t *testing.T) {
+	signer, _ := signedurl.NewSigner([]string{"current"}, time.Hour)
+	p, err := NewSignedURLFactory(signer)(json.RawMessage(`{"strip_params": false}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, _ := signer.Sign("/files/a.pdf", time.Now().Add(time.Minute), "")
+	r := httptest.NewRequest("GET", "/files/a.pdf?"+query.Encode(), nil)
+	ctx := newTestContext(r, "r-files")
+
+	if err := p.Execute(ctx); err != nil || ctx.IsAborted() {
+		t.Fatalf("Execute() = %v, aborted %v", err, ctx.IsAborted())
+	}
+	if r.URL.Query().Get(signedurl.ParamSignature) == "" {
+		t.Error("signature stripped with strip_params false")
+	}
+}
+
+func TestSignedURL_Config(t *testing.T) {
+	if _, err := NewSignedURLFactory(nil)(nil); err == nil {
+		t.Error("factory without a signer succeeded, want error")
+	}
+	signer, _ := signedurl.NewSigner([]string{"current"}, time.Hour)
+	if _, err := NewSignedURLFactory(signer)(json.RawMessage(`{"methods": []}`)); err == nil {
+		t.Error("empty methods succeeded, want error")
+	}
+}
@@ -0,0 +1,444 @@
+// Package builtin - Static files plugin
+//
+// The static-files plugin answers a route's requests from a local
+// directory or an S3-compatible bucket instead of proxying them, so simple
+// assets (a SPA bundle, downloads, images) don't need a backend of their
+// own:
+//   - directory: files under root, opened so that ".." and symlinks can't
+//     leave it
+//   - s3: objects in bucket, fetched with a SigV4-signed GET (or
+//     anonymously, for public buckets). endpoint selects S3-compatible
+//     stores such as MinIO or R2
+//
+// Both sources support HEAD, range requests (206), ETag and Last-Modified
+// validators (304 for If-None-Match / If-Modified-Since), and add
+// cache_control to successful responses. Directories serve index.
+//
+// Configuration Examples:
+//
+//	{
+//	  "source": "directory",
+//	  "root": "/var/www/assets",
+//	  "strip_prefix": "/assets",
+//	  "cache_control": "public, max-age=86400"
+//	}
+//
+//	{
+//	  "source": "s3",
+//	  "bucket": "acme-assets",
+//	  "region": "us-east-1",
+//	  "prefix": "web/",
+//	  "credentials": {"access_key_id": "AKIA...", "secret_access_key": "..."}
+//	}
+//
+// The route still needs a service, which is only contacted for requests
+// that fall through (fallthrough: true and no such file). Other methods
+// than GET and HEAD get 405. Credentials live under "credentials", which
+// is encrypted at rest like upstream-auth's.
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// Static file sources.
+const (
+	StaticSourceDirectory = "directory"
+	StaticSourceS3        = "s3"
+)
+
+// staticForwardHeaders are the request headers passed on to S3, which
+// evaluates ranges and conditions itself.
+var staticForwardHeaders = []string{
+	"Range", "If-Range", "If-None-Match", "If-Match", "If-Modified-Since", "If-Unmodified-Since",
+}
+
+// staticResponseHeaders are the S3 response headers passed on to clients.
+var staticResponseHeaders = []string{
+	"Content-Type", "Content-Length", "Content-Range", "Content-Encoding", "Content-Disposition",
+	"Content-Language", "Accept-Ranges", "ETag", "Last-Modified",
+}
+
+// StaticFilesPlugin serves a route from a directory or bucket.
+type StaticFilesPlugin struct {
+	config   StaticFilesConfig
+	root     *os.Root
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+	requests *metrics.CounterVec
+}
+
+// StaticFilesConfig holds configuration for the static files plugin.
+type StaticFilesConfig struct {
+	// Source is "directory" or "s3"
+	Source string `json:"source"`
+
+	// Root is the directory files are served from (directory source)
+	Root string `json:"root"`
+
+	// Bucket, Region and Prefix locate objects (s3 source); the object
+	// key is Prefix + the request path
+	Bucket string `json:"bucket"`
+	Region string `json:"region"`
+	Prefix string `json:"prefix"`
+
+	// Endpoint is the S3 API URL
+	// Default: "https://s3.<region>.amazonaws.com"
+	Endpoint string `json:"endpoint"`
+
+	// PathStyle addresses the bucket in the path (endpoint/bucket/key)
+	// instead of the host (bucket.endpoint/key), as most S3-compatible
+	// stores expect
+	// Default: false
+	PathStyle bool `json:"path_style"`
+
+	// Credentials sign S3 requests; empty for public buckets
+	Credentials UpstreamCredentials `json:"credentials"`
+
+	// Timeout bounds fetching an object's headers from S3
+	// Default: "30s"
+	Timeout string `json:"timeout"`
+
+	// StripPrefix is removed from request paths before the lookup, e.g.
+	// the route's path
+	StripPrefix string `json:"strip_prefix"`
+
+	// Index is served for directories
+	// Default: "index.html"
+	Index string `json:"index"`
+
+	// CacheControl is set on successful responses ("" = none)
+	// Default: "public, max-age=300"
+	CacheControl string `json:"cache_control"`
+
+	// Fallthrough proxies requests for missing files to the route's
+	// service instead of answering 404
+	// Default: false
+	Fallthrough bool `json:"fallthrough"`
+}
+
+// DefaultStaticFilesConfig returns defaults for the static files plugin.
+func DefaultStaticFilesConfig() StaticFilesConfig {
+	return StaticFilesConfig{
+		Source:       StaticSourceDirectory,
+		Timeout:      "30s",
+		Index:        "index.html",
+		CacheControl: "public, max-age=300",
+	}
+}
+
+// NewStaticFilesPlugin creates a new static files plugin.
+func NewStaticFilesPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := DefaultStaticFilesConfig()
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid static-files config: %w", err)
+		}
+	}
+	if strings.Contains(config.Index, "/") {
+		return nil, fmt.Errorf("invalid static-files config: index must be a file name, got %q", config.Index)
+	}
+
+	p := &StaticFilesPlugin{
+		config: config,
+		now:    time.Now,
+		requests: plugin.NewMetrics("static-files").Counter(
+			"requests_total",
+			"Requests served from static files, by route, source and status.",
+			"route", "source", "status",
+		),
+	}
+
+	switch config.Source {
+	case StaticSourceDirectory:
+		if config.Root == "" {
+			return nil, fmt.Errorf("invalid static-files config: directory source requires root")
+		}
+		root, err := os.OpenRoot(config.Root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid static-files config: %w", err)
+		}
+		p.root = root
+
+	case StaticSourceS3:
+		if config.Bucket == "" || config.Region == "" {
+			return nil, fmt.Errorf("invalid static-files config: s3 source requires bucket and region")
+		}
+		if (config.Credentials.AccessKeyID == "") != (config.Credentials.SecretAccessKey == "") {
+			return nil, fmt.Errorf("invalid static-files config: credentials need both access_key_id and secret_access_key")
+		}
+		endpoint := config.Endpoint
+		if endpoint == "" {
+			endpoint = "https://s3." + config.Region + ".amazonaws.com"
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid static-files config: endpoint must be an http(s) URL, got %q", endpoint)
+		}
+		timeout, err := time.ParseDuration(config.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid static-files config: timeout must be a positive duration, got %q", config.Timeout)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ResponseHeaderTimeout = timeout
+		p.endpoint = u
+		p.client = &http.Client{
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+
+	default:
+		return nil, fmt.Errorf("invalid static-files config: source must be %s or %s", StaticSourceDirectory, StaticSourceS3)
+	}
+
+	// Never log credentials
+	log.Debug().
+		Str("component", "plugin").
+		Str("plugin", "static-files").
+		Str("source", config.Source).
+		Str("root", config.Root).
+		Str("bucket", config.Bucket).
+		Msg("Static files plugin initialized")
+
+	return p, nil
+}
+
+// Name returns the plugin identifier.
+func (p *StaticFilesPlugin) Name() string {
+	return "static-files"
+}
+
+// Close releases the root directory handle and idle S3 connections. The
+// registry calls it once a reload has replaced the instance.
+func (p *StaticFilesPlugin) Close() error {
+	if p.client != nil {
+		p.client.CloseIdleConnections()
+	}
+	if p.root != nil {
+		return p.root.Close()
+	}
+	return nil
+}
+
+// Execute serves the request from the source and aborts the chain.
+func (p *StaticFilesPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+
+	r := ctx.Request
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		p.requests.Inc(routeID, p.config.Source, "405")
+		ctx.Response.Header().Set("Allow", "GET, HEAD")
+		ctx.Abort(http.StatusMethodNotAllowed, "Method not allowed")
+		return nil
+	}
+
+	name, ok := p.fileName(r.URL.Path)
+	if !ok {
+		return p.notFound(ctx, routeID)
+	}
+
+	var status int
+	var err error
+	if p.root != nil {
+		status, err = p.serveFile(ctx, name)
+	} else {
+		status, err = p.serveObject(ctx, name)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return p.notFound(ctx, routeID)
+	}
+	if err != nil {
+		p.requests.Inc(routeID, p.config.Source, "502")
+		log.Error().
+			Err(err).
+			Str("component", "plugin").
+			Str("plugin", "static-files").
+			Str("route_id", routeID).
+			Str("file", name).
+			Msg("Failed to serve static file")
+		ctx.Abort(http.StatusBadGateway, "Failed to fetch file")
+		return nil
+	}
+
+	p.requests.Inc(routeID, p.config.Source, strconv.Itoa(status))
+	ctx.Decide("served from " + p.config.Source)
+	ctx.Abort(status, "")
+	return nil
+}
+
+// notFound answers 404, or lets the request through to the service.
+func (p *StaticFilesPlugin) notFound(ctx *plugin.Context, routeID string) error {
+	if p.config.Fallthrough {
+		p.requests.Inc(routeID, p.config.Source, "fallthrough")
+		return nil
+	}
+	p.requests.Inc(routeID, p.config.Source, "404")
+	ctx.Abort(http.StatusNotFound, "Not found")
+	return nil
+}
+
+// fileName maps a request path to a file or object name relative to the
+// source: StripPrefix removed, cleaned, and Index appended to directory
+// paths. Hidden files (any segment starting with ".") are never served.
+func (p *StaticFilesPlugin) fileName(requestPath string) (string, bool) {
+	if p.config.StripPrefix != "" {
+		rest, ok := strings.CutPrefix(requestPath, strings.TrimSuffix(p.config.StripPrefix, "/"))
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			return "", false
+		}
+		requestPath = rest
+	}
+
+	dir := requestPath == "" || strings.HasSuffix(requestPath, "/")
+	name := strings.TrimPrefix(path.Clean("/"+requestPath), "/")
+	if dir {
+		if p.config.Index == "" {
+			return "", false
+		}
+		name = path.Join(name, p.config.Index)
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// serveFile serves name from the root directory. http.ServeContent handles
+// HEAD, ranges and conditional requests.
+func (p *StaticFilesPlugin) serveFile(ctx *plugin.Context, name string) (int, error) {
+	f, err := p.root.Open(name)
+	if err != nil {
+		// Missing, unreadable, or a symlink out of root
+		return 0, fs.ErrNotExist
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir() {
+		if p.config.Index == "" {
+			return 0, fs.ErrNotExist
+		}
+		return p.serveFile(ctx, path.Join(name, p.config.Index))
+	}
+
+	header := ctx.Response.Header()
+	header.Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		header.Set("Content-Type", ctype)
+	}
+	if p.config.CacheControl != "" {
+		header.Set("Cache-Control", p.config.CacheControl)
+	}
+
+	http.ServeContent(ctx.Response, ctx.Request, name, info.ModTime(), f)
+	return ctx.Response.StatusCode(), nil
+}
+
+// serveObject fetches name from the bucket and copies the response.
+func (p *StaticFilesPlugin) serveObject(ctx *plugin.Context, name string) (int, error) {
+	req, err := p.objectRequest(ctx.Context(), ctx.Request, name)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("s3 request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusNotModified,
+		http.StatusPreconditionFailed, http.StatusRequestedRangeNotSatisfiable:
+	case http.StatusNotFound, http.StatusForbidden:
+		// Without s3:ListBucket, S3 answers 403 for missing keys
+		return 0, fs.ErrNotExist
+	default:
+		return 0, fmt.Errorf("s3 returned %d", resp.StatusCode)
+	}
+
+	header := ctx.Response.Header()
+	for _, name := range staticResponseHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+	if p.config.CacheControl != "" && (resp.StatusCode < 300 || resp.StatusCode == http.StatusNotModified) {
+		header.Set("Cache-Control", p.config.CacheControl)
+	}
+
+	ctx.Response.WriteHeader(resp.StatusCode)
+	if ctx.Request.Method != http.MethodHead {
+		if _, err := io.Copy(ctx.Response, resp.Body); err != nil {
+			// Headers are sent; the client sees a truncated body
+			log.Warn().
+				Err(err).
+				Str("component", "plugin").
+				Str("plugin", "static-files").
+				Str("object", name).
+				Msg("Static file copy interrupted")
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// objectRequest builds the signed S3 request for object name.
+func (p *StaticFilesPlugin) objectRequest(ctx context.Context, r *http.Request, name string) (*http.Request, error) {
+	key := p.config.Prefix + name
+	u := *p.endpoint
+	objectPath := "/" + key
+	if p.config.PathStyle {
+		objectPath = "/" + p.config.Bucket + objectPath
+	} else {
+		u.Host = p.config.Bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + objectPath
+	u.RawPath = awsCanonicalURI(u.Path, false)
+	u.RawQuery = ""
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range staticForwardHeaders {
+		if value := r.Header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	if p.config.Credentials.AccessKeyID != "" {
+		signAWSRequest(req, p.config.Credentials, p.config.Region, "s3", hexSHA256(nil), false, p.now())
+	}
+	return req, nil
+}
@@ -0,0 +1,200 @@
+package builtin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// newTestStaticDir lays out a served directory next to a file outside it:
+//
+//	secret.txt                  (outside the root)
+//	www/app.js, www/index.html, www/.env
+//	www/docs/index.html, www/docs/.git/config
+//	www/escape -> ../secret.txt, www/alias.js -> app.js
+func newTestStaticDir(t *testing.T) string {
+	t.Helper()
+	base := t.TempDir()
+	root := filepath.Join(base, "www")
+	files := map[string]string{
+		"secret.txt":           "secret",
+		"www/app.js":           "console.log('app')",
+		"www/index.html":       "<h1>home</h1>",
+		"www/.env":             "TOKEN=1",
+		"www/docs/index.html":  "<h1>docs</h1>",
+		"www/docs/.git/config": "[core]",
+	}
+	for name, body := range files {
+		full := filepath.Join(base, name)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("../secret.txt", filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("app.js", filepath.Join(root, "alias.js")); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func newTestStaticPlugin(t *testing.T, config map[string]interface{}) *StaticFilesPlugin {
+	t.Helper()
+	configJSON, _ := json.Marshal(config)
+	p, err := NewStaticFilesPlugin(configJSON)
+	if err != nil {
+		t.Fatalf("NewStaticFilesPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.(*StaticFilesPlugin).Close() })
+	return p.(*StaticFilesPlugin)
+}
+
+// serveStatic runs the plugin on r and returns the context and the
+// response the client got.
+func serveStatic(t *testing.T, p *StaticFilesPlugin, r *http.Request) (*plugin.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	ctx := newTestContext(r, "r-assets")
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	return ctx, ctx.Response.ResponseWriter.(*httptest.ResponseRecorder)
+}
+
+func TestStaticFiles_Directory(t *testing.T) {
+	p := newTestStaticPlugin(t, map[string]interface{}{
+		"root":         newTestStaticDir(t),
+		"strip_prefix": "/assets",
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		wantStatus  int
+		wantBody    string
+		contentType string
+	}{
+		{name: "file", target: "/assets/app.js", wantStatus: 200, wantBody: "console.log('app')", contentType: "text/javascript; charset=utf-8"},
+		{name: "index", target: "/assets/", wantStatus: 200, wantBody: "<h1>home</h1>", contentType: "text/html; charset=utf-8"},
+		{name: "prefix only", target: "/assets", wantStatus: 200, wantBody: "<h1>home</h1>"},
+		{name: "subdirectory index", target: "/assets/docs/", wantStatus: 200, wantBody: "<h1>docs</h1>"},
+		{name: "directory without slash", target: "/assets/docs", wantStatus: 200, wantBody: "<h1>docs</h1>"},
+		{name: "symlink inside the root", target: "/assets/alias.js", wantStatus: 200, wantBody: "console.log('app')"},
+		{name: "head", method: "HEAD", target: "/assets/app.js", wantStatus: 200},
+		{name: "missing", target: "/assets/missing.js", wantStatus: 404},
+		{name: "dot-dot", target: "/assets/../secret.txt", wantStatus: 404},
+		{name: "dot-dot past the root", target: "/assets/../../secret.txt", wantStatus: 404},
+		{name: "encoded dot-dot", target: "/assets/%2e%2e/%2e%2e/secret.txt", wantStatus: 404},
+		{name: "encoded slash", target: "/assets/..%2f..%2fsecret.txt", wantStatus: 404},
+		{name: "symlink out of the root", target: "/assets/escape", wantStatus: 404},
+		{name: "hidden file", target: "/assets/.env", wantStatus: 404},
+		{name: "hidden directory", target: "/assets/docs/.git/config", wantStatus: 404},
+		{name: "other prefix", target: "/assetsx/app.js", wantStatus: 404},
+		{name: "post", method: "POST", target: "/assets/app.js", wantStatus: 405},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			ctx, rec := serveStatic(t, p, httptest.NewRequest(method, tt.target, nil))
+			if got := ctx.AbortStatusCode(); !ctx.IsAborted() || got != tt.wantStatus {
+				t.Fatalf("aborted = %v with %d, want %d", ctx.IsAborted(), got, tt.wantStatus)
+			}
+			if tt.wantStatus != 200 {
+				return
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+			if tt.contentType != "" && rec.Header().Get("Content-Type") != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", rec.Header().Get("Content-Type"), tt.contentType)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "public, max-age=300" {
+				t.Errorf("Cache-Control = %q, want the default", got)
+			}
+		})
+	}
+}
+
+func TestStaticFiles_Conditional(t *testing.T) {
+	root := newTestStaticDir(t)
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(root, "app.js"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	p := newTestStaticPlugin(t, map[string]interface{}{"root": root})
+
+	_, rec := serveStatic(t, p, httptest.NewRequest("GET", "/app.js", nil))
+	etag := rec.Header().Get("ETag")
+	if etag == "" || rec.Header().Get("Last-Modified") != modTime.Format(http.TimeFormat) {
+		t.Fatalf("validators = %q, %q; want an ETag and the file's modification time", etag, rec.Header().Get("Last-Modified"))
+	}
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "range", header: "Range", value: "bytes=0-6", wantStatus: 206, wantBody: "console"},
+		{name: "suffix range", header: "Range", value: "bytes=-5", wantStatus: 206, wantBody: "app')"},
+		{name: "unsatisfiable range", header: "Range", value: "bytes=100-", wantStatus: 416},
+		{name: "if-none-match", header: "If-None-Match", value: etag, wantStatus: 304},
+		{name: "if-none-match, changed", header: "If-None-Match", value: `"other"`, wantStatus: 200, wantBody: "console.log('app')"},
+		{name: "if-modified-since", header: "If-Modified-Since", value: modTime.Format(http.TimeFormat), wantStatus: 304},
+		{name: "if-modified-since, older", header: "If-Modified-Since", value: modTime.Add(-time.Hour).Format(http.TimeFormat), wantStatus: 200, wantBody: "console.log('app')"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/app.js", nil)
+			r.Header.Set(tt.header, tt.value)
+			ctx, rec := serveStatic(t, p, r)
+			if got := ctx.AbortStatusCode(); got != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", got, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if tt.wantStatus == 206 && rec.Header().Get("Content-Range") == "" {
+				t.Error("206 without Content-Range")
+			}
+		})
+	}
+}
+
+func TestStaticFiles_Fallthrough(t *testing.T) {
+	p := newTestStaticPlugin(t, map[string]interface{}{"root": newTestStaticDir(t), "fallthrough": true})
+
+	if ctx, _ := serveStatic(t, p, httptest.NewRequest("GET", "/api/orders", nil)); ctx.IsAborted() {
+		t.Errorf("missing file answered %d, want it passed to the service", ctx.AbortStatusCode())
+	}
+	if ctx, _ := serveStatic(t, p, httptest.NewRequest("GET", "/app.js", nil)); ctx.AbortStatusCode() != 200 {
+		t.Errorf("existing file answered %d, want 200", ctx.AbortStatusCode())
+	}
+}
+
+func TestStaticFiles_Close(t *testing.T) {
+	p := newTestStaticPlugin(t, map[string]interface{}{"root": newTestStaticDir(t)})
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := p.root.Open("app.js"); err == nil {
+		t.Error("root still open after Close()")
+	}
+}
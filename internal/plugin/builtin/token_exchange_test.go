@@ -0,0 +1,211 @@
+package builtin
+
+import (
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/tokenmint"
+)
+
+const testMintIssuer = "https://gateway.internal"
+
+func newTestTokenExchange(t *testing.T, config string) (*TokenExchangePlugin, ed25519.PublicKey) {
+	t.Helper()
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	key, err := tokenmint.NewKey(priv)
+	if err != nil {
+		t.Fatalf("NewKey() error = %v", err)
+	}
+	signer, err := tokenmint.NewSigner(key)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	p, err := NewTokenExchangeFactory(signer, testMintIssuer)(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewTokenExchangeFactory() error = %v", err)
+	}
+	return p.(*TokenExchangePlugin), pub
+}
+
+// mintedClaims verifies a minted "Bearer <jwt>" header value against pub
+// and returns the token's claims.
+func mintedClaims(t *testing.T, header string, pub ed25519.PublicKey) map[string]interface{} {
+	t.Helper()
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	parts := strings.Split(token, ".")
+	if !ok || len(parts) != 3 {
+		t.Fatalf("header = %q, want Bearer <jwt>", header)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	if !ed25519.Verify(pub, []byte(parts[0]+"."+parts[1]), sig) {
+		t.Fatal("minted token signature doesn't verify")
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("minted token claims: %v", err)
+	}
+	return claims
+}
+
+// exchangeContext returns a request context for the orders route, sent
+// with the client's own token.
+func exchangeContext(consumerID string) *plugin.Context {
+	r := httptest.NewRequest("GET", "/orders", nil)
+	r.Header.Set("Authorization", "Bearer client-token")
+	route := &database.Route{ID: "r-orders", Name: sql.NullString{String: "orders", Valid: true}}
+	ctx := plugin.NewContext(r, httptest.NewRecorder(), route, &database.Service{Name: "orders-svc"}, plugin.PhaseBeforeRequest)
+	if consumerID != "" {
+		plugin.KeyConsumerID.Set(ctx, consumerID)
+	}
+	return ctx
+}
+
+func TestTokenExchange_Mint(t *testing.T) {
+	p, pub := newTestTokenExchange(t, `{"ttl": "90s", "claims": ["tenant"]}`)
+
+	ctx := exchangeContext("c-42")
+	plugin.KeyConsumerUsername.Set(ctx, "ada")
+	plugin.KeyAuthMechanism.Set(ctx, authMechanismPaseto)
+	plugin.KeyTokenClaims.Set(ctx, map[string]interface{}{
+		"scope":  "orders:read orders:write",
+		"tenant": "acme",
+		"email":  "ada@example.com",
+		"iss":    "https://client-idp",
+	})
+
+	before := time.Now().Unix()
+	if err := p.Execute(ctx); err != nil || ctx.IsAborted() {
+		t.Fatalf("Execute() = %v, aborted %v", err, ctx.IsAborted())
+	}
+
+	claims := mintedClaims(t, ctx.Request.Header.Get("Authorization"), pub)
+	want := map[string]interface{}{
+		"iss":                testMintIssuer,
+		"sub":                "c-42",
+		"aud":                "orders-svc",
+		"route":              "orders",
+		"scope":              "orders:read orders:write",
+		"tenant":             "acme",
+		"preferred_username": "ada",
+		"auth_mechanism":     "paseto",
+	}
+	for name, value := range want {
+		if claims[name] != value {
+			t.Errorf("claim %s = %v, want %v", name, claims[name], value)
+		}
+	}
+	if _, ok := claims["email"]; ok {
+		t.Error("unlisted client claim copied into the minted token")
+	}
+	if jti, _ := claims["jti"].(string); len(jti) != 32 {
+		t.Errorf("jti = %v, want 32 hex characters", claims["jti"])
+	}
+
+	// Minted tokens live for ttl from issue
+	iat, _ := claims["iat"].(float64)
+	exp, _ := claims["exp"].(float64)
+	if int64(iat) < before || exp-iat != 90 {
+		t.Errorf("iat = %v, exp = %v; want exp = iat + 90s", iat, exp)
+	}
+
+	// Each request gets its own token
+	next := exchangeContext("c-42")
+	p.Execute(next)
+	if mintedClaims(t, next.Request.Header.Get("Authorization"), pub)["jti"] == claims["jti"] {
+		t.Error("two requests minted the same jti")
+	}
+}
+
+func TestTokenExchange_HeaderReplacement(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     string
+		consumer   string
+		header     string
+		wantStatus int  // 0 = proxied
+		wantToken  bool // a minted token in header
+	}{
+		{name: "replaces the client token", config: `{}`, consumer: "c-1", header: "Authorization", wantToken: true},
+		{name: "custom header", config: `{"header_name": "X-Internal-Token"}`, consumer: "c-1", header: "X-Internal-Token", wantToken: true},
+		{name: "unauthenticated", config: `{}`, header: "Authorization", wantStatus: http.StatusUnauthorized},
+		{name: "anonymous allowed", config: `{"allow_anonymous": true}`, header: "Authorization"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, pub := newTestTokenExchange(t, tt.config)
+			ctx := exchangeContext(tt.consumer)
+			ctx.Request.Header.Set("X-Internal-Token", "client-supplied")
+
+			if err := p.Execute(ctx); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got := ctx.AbortStatusCode(); ctx.IsAborted() != (tt.wantStatus != 0) || got != tt.wantStatus {
+				t.Fatalf("aborted = %v with %d, want %d", ctx.IsAborted(), got, tt.wantStatus)
+			}
+
+			got := ctx.Request.Header.Get(tt.header)
+			if !tt.wantToken {
+				if got != "" {
+					t.Errorf("%s = %q, want the client's credential removed", tt.header, got)
+				}
+				return
+			}
+			if sub := mintedClaims(t, got, pub)["sub"]; sub != tt.consumer {
+				t.Errorf("sub = %v, want %s", sub, tt.consumer)
+			}
+		})
+	}
+}
+
+func TestTokenExchange_SigningFailure(t *testing.T) {
+	p, _ := newTestTokenExchange(t, `{}`)
+	p.signer = nil // Sign fails without keys
+
+	ctx := exchangeContext("c-1")
+	err := p.Execute(ctx)
+	if err == nil || ctx.AbortStatusCode() != http.StatusInternalServerError {
+		t.Errorf("Execute() = %v with status %d, want an error and 500", err, ctx.AbortStatusCode())
+	}
+	if got := ctx.Request.Header.Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q after a failed exchange, want it removed", got)
+	}
+}
+
+func TestTokenExchange_Config(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{name: "ttl over an hour", config: `{"ttl": "2h"}`},
+		{name: "zero ttl", config: `{"ttl": "0s"}`},
+		{name: "bad ttl", config: `{"ttl": "soon"}`},
+		{name: "no header", config: `{"header_name": ""}`},
+		{name: "reserved claim", config: `{"claims": ["sub"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, priv, _ := ed25519.GenerateKey(nil)
+			key, _ := tokenmint.NewKey(priv)
+			signer, _ := tokenmint.NewSigner(key)
+			if _, err := NewTokenExchangeFactory(signer, testMintIssuer)(json.RawMessage(tt.config)); err == nil {
+				t.Errorf("factory accepted %s", tt.config)
+			}
+		})
+	}
+
+	if _, err := NewTokenExchangeFactory(nil, testMintIssuer)(nil); err == nil {
+		t.Error("factory without signing keys succeeded")
+	}
+}
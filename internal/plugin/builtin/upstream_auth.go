@@ -0,0 +1,510 @@
+// Package builtin - Upstream auth plugin for signing requests to backends
+//
+// The upstream-auth plugin authenticates the gateway to the backend, so
+// clients can call an open (or differently authenticated) route while the
+// backend only accepts requests from the gateway:
+//   - aws_sigv4: AWS Signature Version 4 (API Gateway, S3, Lambda URLs)
+//   - bearer: static token injected into a header
+//   - hmac: HMAC-SHA256/512 signature over method, path, timestamp and body
+//
+// Signing runs as an upstream hook, after the proxy has chosen the target
+// and set the final URL and Host, so signatures cover what is actually sent.
+//
+// Credentials live under "credentials", which is one of the default
+// CONFIG_ENCRYPTED_FIELDS: with encryption at rest enabled the admin API
+// stores them encrypted and the gateway decrypts them when loading plugins.
+package builtin
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// Upstream auth types.
+const (
+	UpstreamAuthAWSSigV4 = "aws_sigv4"
+	UpstreamAuthBearer   = "bearer"
+	UpstreamAuthHMAC     = "hmac"
+)
+
+// UpstreamAuthPlugin signs or authenticates requests sent to the backend.
+//
+// Configuration examples:
+//
+//	{
+//	  "type": "aws_sigv4",
+//	  "region": "us-east-1",
+//	  "service": "execute-api",
+//	  "credentials": {
+//	    "access_key_id": "AKIA...",
+//	    "secret_access_key": "...",
+//	    "session_token": ""
+//	  }
+//	}
+//
+//	{
+//	  "type": "bearer",
+//	  "header": "Authorization",
+//	  "credentials": {"token": "..."}
+//	}
+//
+//	{
+//	  "type": "hmac",
+//	  "algorithm": "sha256",
+//	  "key_id": "gateway-1",
+//	  "credentials": {"secret": "..."}
+//	}
+//
+// HMAC signatures are computed over:
+//
+//	METHOD + "\n" + PATH?QUERY + "\n" + TIMESTAMP + "\n" + hex(sha256(body))
+//
+// and sent as hex in X-Signature, with the Unix timestamp in
+// X-Signature-Timestamp and key_id (if set) in X-Signature-Key-Id.
+type UpstreamAuthPlugin struct {
+	config UpstreamAuthConfig
+	now    func() time.Time
+}
+
+// UpstreamAuthConfig holds configuration for upstream authentication.
+type UpstreamAuthConfig struct {
+	// Type is aws_sigv4, bearer or hmac.
+	Type string `json:"type"`
+
+	// Credentials holds the secrets for the chosen type.
+	Credentials UpstreamCredentials `json:"credentials"`
+
+	// Region and Service scope AWS signatures (e.g. "us-east-1", "execute-api",
+	// "s3", "lambda").
+	Region  string `json:"region"`
+	Service string `json:"service"`
+
+	// UnsignedPayload skips hashing the body for AWS signatures
+	// (X-Amz-Content-Sha256: UNSIGNED-PAYLOAD, supported by S3). Otherwise
+	// the request body is buffered to hash it.
+	UnsignedPayload bool `json:"unsigned_payload"`
+
+	// MaxBodyBytes is the largest request body buffered to sign (AWS and
+	// HMAC). Larger requests get 413. Default: 10485760 (10 MiB).
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+
+	// Header receives the bearer token. Default: "Authorization".
+	Header string `json:"header"`
+
+	// Scheme prefixes the bearer token. Default: "Bearer" (only when Header
+	// is Authorization).
+	Scheme string `json:"scheme"`
+
+	// Algorithm is the HMAC hash: sha256 (default) or sha512.
+	Algorithm string `json:"algorithm"`
+
+	// KeyID identifies the HMAC secret to the backend (optional).
+	KeyID string `json:"key_id"`
+
+	// SignatureHeader, TimestampHeader and KeyIDHeader name the HMAC headers.
+	SignatureHeader string `json:"signature_header"`
+	TimestampHeader string `json:"timestamp_header"`
+	KeyIDHeader     string `json:"key_id_header"`
+}
+
+// UpstreamCredentials holds upstream secrets.
+type UpstreamCredentials struct {
+	// AWS
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token"`
+
+	// Bearer
+	Token string `json:"token"`
+
+	// HMAC
+	Secret string `json:"secret"`
+}
+
+// DefaultUpstreamAuthConfig returns defaults for upstream authentication.
+func DefaultUpstreamAuthConfig() UpstreamAuthConfig {
+	return UpstreamAuthConfig{
+		Header:          "Authorization",
+		Algorithm:       "sha256",
+		SignatureHeader: "X-Signature",
+		TimestampHeader: "X-Signature-Timestamp",
+		KeyIDHeader:     "X-Signature-Key-Id",
+		MaxBodyBytes:    10 << 20,
+	}
+}
+
+// NewUpstreamAuthPlugin creates a new upstream auth plugin.
+//
+// This is the factory function registered with the plugin registry.
+func NewUpstreamAuthPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := DefaultUpstreamAuthConfig()
+
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid upstream-auth config: %w", err)
+		}
+	}
+
+	if config.Scheme == "" && strings.EqualFold(config.Header, "Authorization") {
+		config.Scheme = "Bearer"
+	}
+
+	if err := validateUpstreamAuthConfig(config); err != nil {
+		return nil, fmt.Errorf("invalid upstream-auth configuration: %w", err)
+	}
+
+	// Never log credentials
+	log.Debug().
+		Str("component", "plugin").
+		Str("plugin", "upstream-auth").
+		Str("type", config.Type).
+		Str("region", config.Region).
+		Str("service", config.Service).
+		Msg("Upstream auth plugin initialized")
+
+	return &UpstreamAuthPlugin{
+		config: config,
+		now:    time.Now,
+	}, nil
+}
+
+// validateUpstreamAuthConfig validates upstream auth configuration.
+func validateUpstreamAuthConfig(config UpstreamAuthConfig) error {
+	creds := config.Credentials
+
+	switch config.Type {
+	case UpstreamAuthAWSSigV4:
+		if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+			return fmt.Errorf("aws_sigv4 requires credentials.access_key_id and credentials.secret_access_key")
+		}
+		if config.Region == "" || config.Service == "" {
+			return fmt.Errorf("aws_sigv4 requires region and service")
+		}
+	case UpstreamAuthBearer:
+		if creds.Token == "" {
+			return fmt.Errorf("bearer requires credentials.token")
+		}
+		if config.Header == "" {
+			return fmt.Errorf("header cannot be empty")
+		}
+	case UpstreamAuthHMAC:
+		if creds.Secret == "" {
+			return fmt.Errorf("hmac requires credentials.secret")
+		}
+		if config.Algorithm != "sha256" && config.Algorithm != "sha512" {
+			return fmt.Errorf("algorithm must be sha256 or sha512")
+		}
+		if config.SignatureHeader == "" || config.TimestampHeader == "" {
+			return fmt.Errorf("signature_header and timestamp_header cannot be empty")
+		}
+	default:
+		return fmt.Errorf("type must be one of %s, %s, %s", UpstreamAuthAWSSigV4, UpstreamAuthBearer, UpstreamAuthHMAC)
+	}
+
+	if config.MaxBodyBytes <= 0 {
+		return fmt.Errorf("max_body_bytes must be positive")
+	}
+
+	return nil
+}
+
+// Name returns the plugin name.
+func (p *UpstreamAuthPlugin) Name() string {
+	return "upstream-auth"
+}
+
+// Execute registers the signing hook for the upstream request.
+func (p *UpstreamAuthPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	switch p.config.Type {
+	case UpstreamAuthAWSSigV4:
+		ctx.AddUpstreamHook(p.signAWS)
+	case UpstreamAuthBearer:
+		ctx.AddUpstreamHook(p.setBearer)
+	case UpstreamAuthHMAC:
+		ctx.AddUpstreamHook(p.signHMAC)
+	}
+
+	ctx.LogDebug(p.Name(), "Upstream request will be signed ("+p.config.Type+")")
+	return nil
+}
+
+// ============================================================================
+// Bearer
+// ============================================================================
+
+// setBearer injects the static token.
+func (p *UpstreamAuthPlugin) setBearer(req *http.Request) error {
+	value := p.config.Credentials.Token
+	if p.config.Scheme != "" {
+		value = p.config.Scheme + " " + value
+	}
+	req.Header.Set(p.config.Header, value)
+	return nil
+}
+
+// ============================================================================
+// HMAC
+// ============================================================================
+
+// signHMAC adds an HMAC signature over method, path, timestamp and body.
+func (p *UpstreamAuthPlugin) signHMAC(req *http.Request) error {
+	bodyHash, err := hashRequestBody(req, p.config.MaxBodyBytes)
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(p.now().Unix(), 10)
+	stringToSign := strings.Join([]string{req.Method, req.URL.RequestURI(), timestamp, bodyHash}, "\n")
+
+	newHash := sha256.New
+	if p.config.Algorithm == "sha512" {
+		newHash = sha512.New
+	}
+	mac := hmac.New(newHash, []byte(p.config.Credentials.Secret))
+	mac.Write([]byte(stringToSign))
+
+	req.Header.Set(p.config.TimestampHeader, timestamp)
+	req.Header.Set(p.config.SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	if p.config.KeyID != "" && p.config.KeyIDHeader != "" {
+		req.Header.Set(p.config.KeyIDHeader, p.config.KeyID)
+	}
+	return nil
+}
+
+// ============================================================================
+// AWS Signature Version 4
+// ============================================================================
+
+const (
+	awsAlgorithm       = "AWS4-HMAC-SHA256"
+	awsTimeFormat      = "20060102T150405Z"
+	awsDateFormat      = "20060102"
+	awsUnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// signAWS signs the request with AWS Signature Version 4.
+func (p *UpstreamAuthPlugin) signAWS(req *http.Request) error {
+	payloadHash := awsUnsignedPayload
+	if !p.config.UnsignedPayload {
+		var err error
+		if payloadHash, err = hashRequestBody(req, p.config.MaxBodyBytes); err != nil {
+			return err
+		}
+	}
+	signAWSRequest(req, p.config.Credentials, p.config.Region, p.config.Service, payloadHash, p.config.UnsignedPayload, p.now())
+	return nil
+}
+
+// signAWSRequest sets the SigV4 headers on req for a payload hashed as
+// payloadHash (or UNSIGNED-PAYLOAD). X-Amz-Content-Sha256 is sent for S3,
+// which requires it, or when sendPayloadHash is set.
+//
+// See https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func signAWSRequest(req *http.Request, creds UpstreamCredentials, region, service, payloadHash string, sendPayloadHash bool, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(awsTimeFormat)
+	date := now.Format(awsDateFormat)
+
+	// Replace any client-supplied AWS auth
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	} else {
+		req.Header.Del("X-Amz-Security-Token")
+	}
+	if service == "s3" || sendPayloadHash {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	canonicalHeaders, signedHeaders := awsCanonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalURI(req.URL.Path, service != "s3"),
+		awsCanonicalQuery(req),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		awsAlgorithm,
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsAlgorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// awsCanonicalURI encodes the path; all services except S3 expect each
+// segment to be encoded twice.
+func awsCanonicalURI(path string, doubleEncode bool) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segment = awsEscape(segment)
+		if doubleEncode {
+			segment = awsEscape(segment)
+		}
+		segments[i] = segment
+	}
+	return strings.Join(segments, "/")
+}
+
+// awsCanonicalQuery encodes the query parameters and sorts them by encoded
+// key, then encoded value. Sorting the joined "key=value" strings instead
+// would put "a-b=1" before "a=2", since "-" sorts before "=".
+func awsCanonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	pairs := make([][2]string, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, [2]string{awsEscape(key), awsEscape(value)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+
+	encoded := make([]string, len(pairs))
+	for i, pair := range pairs {
+		encoded[i] = pair[0] + "=" + pair[1]
+	}
+	return strings.Join(encoded, "&")
+}
+
+// awsCanonicalHeaders returns the canonical header block and the signed
+// header list. Signs host, content-type and all x-amz-* headers.
+func awsCanonicalHeaders(req *http.Request) (string, string) {
+	headers := map[string]string{"host": req.Host}
+	if req.Host == "" {
+		headers["host"] = req.URL.Host
+	}
+	for key, values := range req.Header {
+		name := strings.ToLower(key)
+		if name != "content-type" && !strings.HasPrefix(name, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return canonical.String(), strings.Join(names, ";")
+}
+
+// awsEscape percent-encodes everything except unreserved characters.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+// hashRequestBody returns hex(sha256(body)), buffering up to limit bytes
+// of the body so it can still be sent. Larger bodies are refused with 413,
+// and bodies that don't fit the gateway's buffering budget with 503.
+func hashRequestBody(req *http.Request, limit int64) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return hexSHA256(nil), nil
+	}
+
+	buffered, err := bodybuffer.ReadRequest(req, limit)
+	if err != nil {
+		return "", fmt.Errorf("failed to read request body for signing: %w", err)
+	}
+	switch {
+	case buffered.OverBudget():
+		return "", &plugin.ResponseError{
+			StatusCode: http.StatusServiceUnavailable,
+			Body:       `{"error":"service unavailable","message":"Gateway is busy buffering other requests, please retry"}`,
+			Reason:     "request body over the buffering budget",
+		}
+	case buffered.TooLarge():
+		return "", &plugin.ResponseError{
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Body:       `{"error":"payload too large","message":"Request body too large to sign"}`,
+			Reason:     fmt.Sprintf("request body over max_body_bytes (%d)", limit),
+		}
+	}
+
+	sum := sha256.New()
+	if _, err := io.Copy(sum, buffered.Reader()); err != nil {
+		return "", fmt.Errorf("failed to hash request body for signing: %w", err)
+	}
+
+	body := buffered.Bytes()
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = buffered.Size()
+
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,225 @@
+package builtin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+func TestHashRequestBody(t *testing.T) {
+	req := httptest.NewRequest("POST", "/upload", strings.NewReader("hello"))
+
+	got, err := hashRequestBody(req, 16)
+	if err != nil {
+		t.Fatalf("hashRequestBody() error = %v", err)
+	}
+	if want := hexSHA256([]byte("hello")); got != want {
+		t.Errorf("hash = %s, want %s", got, want)
+	}
+	if req.ContentLength != 5 {
+		t.Errorf("ContentLength = %d, want 5", req.ContentLength)
+	}
+
+	// The body is still sent, and can be sent again
+	if body, _ := io.ReadAll(req.Body); string(body) != "hello" {
+		t.Errorf("body = %q after hashing, want hello", body)
+	}
+	again, _ := req.GetBody()
+	if body, _ := io.ReadAll(again); string(body) != "hello" {
+		t.Errorf("GetBody() = %q, want hello", body)
+	}
+
+	// Empty bodies hash like nothing
+	if got, _ := hashRequestBody(httptest.NewRequest("GET", "/", nil), 16); got != hexSHA256(nil) {
+		t.Errorf("hash of no body = %s, want %s", got, hexSHA256(nil))
+	}
+}
+
+func TestHashRequestBody_TooLarge(t *testing.T) {
+	req := httptest.NewRequest("POST", "/upload", strings.NewReader(strings.Repeat("x", 17)))
+
+	_, err := hashRequestBody(req, 16)
+	var refused *plugin.ResponseError
+	if !errors.As(err, &refused) || refused.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("hashRequestBody() error = %v, want a 413 ResponseError", err)
+	}
+}
+
+// Vectors from the AWS Signature Version 4 test suite, signed as AKIDEXAMPLE
+// for us-east-1/service at 20150830T123600Z.
+func TestSignAWSRequest_TestSuite(t *testing.T) {
+	const unreserved = "-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	tests := []struct {
+		name          string
+		method        string
+		target        string
+		contentType   string
+		body          string
+		signedHeaders string
+		signature     string
+	}{
+		{
+			name:          "get-vanilla",
+			method:        "GET",
+			target:        "/",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:          "get-vanilla-query-order-key-case",
+			method:        "GET",
+			target:        "/?Param2=value2&Param1=value1",
+			signedHeaders: "host;x-amz-date",
+			signature:     "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:          "get-vanilla-query-unreserved",
+			method:        "GET",
+			target:        "/?" + unreserved + "=" + unreserved,
+			signedHeaders: "host;x-amz-date",
+			signature:     "9c3e54bfcdf0b19771a7f523ee5669cdf59bc7cc0884027167c21bb143a40197",
+		},
+		{
+			name:          "post-x-www-form-urlencoded",
+			method:        "POST",
+			target:        "/",
+			contentType:   "application/x-www-form-urlencoded",
+			body:          "Param1=value1",
+			signedHeaders: "content-type;host;x-amz-date",
+			signature:     "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+	}
+
+	creds := UpstreamCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://example.amazonaws.com"+tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			signAWSRequest(req, creds, "us-east-1", "service", hexSHA256([]byte(tt.body)), false, now)
+
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=" + tt.signedHeaders + ", Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization = %q, want %q", got, want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %q, want 20150830T123600Z", got)
+			}
+		})
+	}
+}
+
+func TestAWSCanonicalQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "", want: ""},
+		{query: "b=2&a=1", want: "a=1&b=2"},
+		{query: "a-b=1&a=2", want: "a=2&a-b=1"},
+		{query: "a1=2&a=1", want: "a=1&a1=2"},
+		{query: "a=z&a=b&a=", want: "a=&a=b&a=z"},
+		{query: "k=a%20b&k=a+b&k=a%2Fb", want: "k=a%20b&k=a%20b&k=a%2Fb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/?"+tt.query, nil)
+			if got := awsCanonicalQuery(req); got != tt.want {
+				t.Errorf("awsCanonicalQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpstreamAuth_HMAC(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		newHash   func() hash.Hash
+		keyHeader string
+		keyID     string
+	}{
+		{
+			name:      "sha256 with key id",
+			config:    `{"type":"hmac","key_id":"gateway-1","credentials":{"secret":"s3cret"}}`,
+			newHash:   sha256.New,
+			keyHeader: "X-Signature-Key-Id",
+			keyID:     "gateway-1",
+		},
+		{
+			name:      "sha512 without key id",
+			config:    `{"type":"hmac","algorithm":"sha512","credentials":{"secret":"s3cret"}}`,
+			newHash:   sha512.New,
+			keyHeader: "X-Signature-Key-Id",
+		},
+		{
+			name:      "custom header names",
+			config:    `{"type":"hmac","key_id":"k2","key_id_header":"X-Key","signature_header":"X-Sig","timestamp_header":"X-Ts","credentials":{"secret":"s3cret"}}`,
+			newHash:   sha256.New,
+			keyHeader: "X-Key",
+			keyID:     "k2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewUpstreamAuthPlugin(json.RawMessage(tt.config))
+			if err != nil {
+				t.Fatalf("NewUpstreamAuthPlugin() error = %v", err)
+			}
+			auth := p.(*UpstreamAuthPlugin)
+			auth.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+			ctx := newTestContext(httptest.NewRequest("POST", "/orders?id=7", strings.NewReader(`{"qty":1}`)), "r1")
+			if err := auth.Execute(ctx); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			hooks := plugin.UpstreamHooks(ctx.Request)
+			if len(hooks) != 1 {
+				t.Fatalf("registered %d upstream hooks, want 1", len(hooks))
+			}
+
+			upstream := httptest.NewRequest("POST", "http://backend/orders?id=7", strings.NewReader(`{"qty":1}`))
+			if err := hooks[0](upstream); err != nil {
+				t.Fatalf("hook error = %v", err)
+			}
+
+			sigHeader, tsHeader := auth.config.SignatureHeader, auth.config.TimestampHeader
+			mac := hmac.New(tt.newHash, []byte("s3cret"))
+			mac.Write([]byte("POST\n/orders?id=7\n1700000000\n" + hexSHA256([]byte(`{"qty":1}`))))
+			if got, want := upstream.Header.Get(sigHeader), hex.EncodeToString(mac.Sum(nil)); got != want {
+				t.Errorf("%s = %q, want %q", sigHeader, got, want)
+			}
+			if got := upstream.Header.Get(tsHeader); got != "1700000000" {
+				t.Errorf("%s = %q, want 1700000000", tsHeader, got)
+			}
+			if got := upstream.Header.Get(tt.keyHeader); got != tt.keyID {
+				t.Errorf("%s = %q, want %q", tt.keyHeader, got, tt.keyID)
+			}
+
+			// The body still reaches the backend
+			if body, _ := io.ReadAll(upstream.Body); string(body) != `{"qty":1}` {
+				t.Errorf("body = %q after signing, want it unchanged", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+// Package builtin - Upstream timing plugin
+//
+// The upstream-timing plugin adds an X-Timing header to a route's
+// responses with the upstream request's latency breakdown, in
+// milliseconds:
+//
+//	X-Timing: dns=2;connect=5;tls=12;ttfb=80;total=95
+//
+// dns, connect and tls only appear when the request opened a new
+// connection. ttfb is the time from writing the request to the upstream's
+// first response byte (time spent in the backend) and total the time to
+// the upstream's response headers. The same phases are added to the
+// Server-Timing header, which browser developer tools display:
+//
+//	Server-Timing: upstream-dns;dur=2.1, upstream-connect;dur=5.3, ...
+//
+// The plugin takes no configuration. Outside of routes with the plugin,
+// clients from DEBUG_TIMING_TRUSTED_IPS can ask for the headers per request
+// by sending X-Debug-Timing: 1.
+package builtin
+
+import (
+	"encoding/json"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// UpstreamTimingPlugin requests the timing headers.
+type UpstreamTimingPlugin struct{}
+
+// NewUpstreamTimingPlugin creates a new upstream timing plugin.
+func NewUpstreamTimingPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	return &UpstreamTimingPlugin{}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *UpstreamTimingPlugin) Name() string {
+	return "upstream-timing"
+}
+
+// Execute asks the proxy for the timing headers.
+func (p *UpstreamTimingPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase == plugin.PhaseBeforeRequest {
+		ctx.RequestTiming()
+	}
+	return nil
+}
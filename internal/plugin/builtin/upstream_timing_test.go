@@ -0,0 +1,60 @@
+package builtin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/proxy"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+)
+
+func TestUpstreamTiming_Headers(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server-Timing", "db;dur=53")
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	u, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(u.Port())
+	service := &database.Service{ID: "svc", Name: "orders", Protocol: "http", Host: u.Hostname(), Port: port, Enabled: true}
+	route := &database.Route{ID: "r-orders", ServiceID: "svc", Paths: []string{"/orders"}, Enabled: true}
+	px := proxy.NewProxy(router.NewRouter([]*database.Route{route}, []*database.Service{service}, nil), nil, nil)
+
+	p, err := NewUpstreamTimingPlugin(nil)
+	if err != nil {
+		t.Fatalf("NewUpstreamTimingPlugin() error = %v", err)
+	}
+	serve := func(phase plugin.Phase) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx := plugin.NewContext(httptest.NewRequest("GET", "/orders", nil), w, route, service, phase)
+		if err := p.Execute(ctx); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		px.ServeHTTP(w, ctx.Request)
+		return w
+	}
+
+	// The first request dials the backend
+	w := serve(plugin.PhaseBeforeRequest)
+	if got := w.Header().Get("X-Timing"); !regexp.MustCompile(`^(dns=\d+;)?connect=\d+;ttfb=\d+;total=\d+$`).MatchString(got) {
+		t.Errorf("X-Timing = %q, want connect, ttfb and total", got)
+	}
+	serverTiming := w.Header().Values("Server-Timing")
+	phases := regexp.MustCompile(`^(upstream-dns;dur=\d+\.\d, )?upstream-connect;dur=\d+\.\d, upstream-ttfb;dur=\d+\.\d, upstream-total;dur=\d+\.\d$`)
+	if len(serverTiming) != 2 || serverTiming[0] != "db;dur=53" || !phases.MatchString(serverTiming[1]) {
+		t.Errorf("Server-Timing = %q, want the backend's metrics, then the upstream phases", serverTiming)
+	}
+
+	// Run in a later phase, the plugin adds nothing
+	w = serve(plugin.PhaseAfterResponse)
+	if w.Header().Get("X-Timing") != "" || len(w.Header().Values("Server-Timing")) != 1 {
+		t.Errorf("headers = %v, want no timing added", w.Header())
+	}
+}
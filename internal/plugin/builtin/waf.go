@@ -0,0 +1,445 @@
+// Package builtin - Signature-based WAF plugin
+//
+// The waf plugin matches requests against regex signatures of common
+// attacks before they reach the backend:
+//   - sqli: UNION SELECT, quote tautologies and comments, stacked
+//     statements, time-based functions
+//   - xss: script tags, inline event handlers, javascript: URIs
+//   - path_traversal: ../ segments, sensitive system files, NUL bytes
+//
+// Signatures are checked against the URL path, query parameter names and
+// values (percent-decoded, twice to catch double encoding), request
+// header values and, when "body" is among the targets, the first
+// max_body_bytes of text-like bodies (form, JSON, XML, text/*).
+//
+// Each built-in rule has a sensitivity level; a route runs the rules at
+// or below its sensitivity:
+//   - low: only high-confidence signatures (few false positives)
+//   - medium: adds broader signatures (default)
+//   - high: adds aggressive signatures that can match legitimate input
+//
+// Configuration Example:
+//
+//	{
+//	  "action": "block",
+//	  "sensitivity": "medium",
+//	  "rule_sets": ["sqli", "xss", "path_traversal"],
+//	  "targets": ["path", "query", "headers"],
+//	  "disabled_rules": ["xss-js-uri"],
+//	  "rules": [{"id": "no-wp", "pattern": "(?i)/wp-(admin|login)", "targets": ["path"]}],
+//	  "max_body_bytes": 65536
+//	}
+//
+// With action "block" the first matching rule rejects the request with
+// 403. With "detect" every matching rule is logged and counted, and the
+// request is proxied: use it to tune a route before blocking. Rule hits
+// are counted in gateway_plugin_waf_rule_hits_total{route,rule,action}.
+package builtin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/clientip"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// WAF actions.
+const (
+	wafActionBlock  = "block"
+	wafActionDetect = "detect"
+)
+
+// WAF rule sets.
+const (
+	wafSetSQLi          = "sqli"
+	wafSetXSS           = "xss"
+	wafSetPathTraversal = "path_traversal"
+)
+
+// WAF inspection targets.
+const (
+	wafTargetPath    = "path"
+	wafTargetQuery   = "query"
+	wafTargetHeaders = "headers"
+	wafTargetBody    = "body"
+)
+
+// errWAFBodyOverBudget means the body couldn't be buffered for inspection.
+var errWAFBodyOverBudget = errors.New("body buffering budget exhausted")
+
+// wafSensitivities maps sensitivity names to rule levels.
+var wafSensitivities = map[string]int{"low": 1, "medium": 2, "high": 3}
+
+// wafRule is a compiled signature.
+type wafRule struct {
+	id      string
+	set     string
+	level   int
+	pattern *regexp.Regexp
+	targets map[string]bool // nil = every target
+}
+
+// wafBuiltinRules are the built-in signatures. Patterns run against
+// decoded input.
+var wafBuiltinRules = []wafRule{
+	// SQL injection
+	{id: "sqli-union-select", set: wafSetSQLi, level: 1,
+		pattern: regexp.MustCompile(`(?i)\bunion\b(\s|/\*.*?\*/)+(all\s+|distinct\s+)?select\b`)},
+	{id: "sqli-schema-probe", set: wafSetSQLi, level: 1,
+		pattern: regexp.MustCompile(`(?i)\b(information_schema|pg_catalog|sysobjects|sqlite_master)\b`)},
+	{id: "sqli-tautology", set: wafSetSQLi, level: 2,
+		pattern: regexp.MustCompile(`(?i)['"]\s*(or|and)\s+['"]?[\w-]+['"]?\s*(=|<|>|like\b)\s*['"]?[\w-]+`)},
+	{id: "sqli-comment", set: wafSetSQLi, level: 2,
+		pattern: regexp.MustCompile(`['"]\s*(--|#|/\*)`)},
+	{id: "sqli-stacked-query", set: wafSetSQLi, level: 2,
+		pattern: regexp.MustCompile(`(?i);\s*(drop|delete|insert|update|alter|create|truncate|exec|shutdown)\s+\w`)},
+	{id: "sqli-time-based", set: wafSetSQLi, level: 2,
+		pattern: regexp.MustCompile(`(?i)\b(sleep|pg_sleep|benchmark)\s*\(|\bwaitfor\s+delay\b`)},
+	{id: "sqli-quote", set: wafSetSQLi, level: 3,
+		pattern: regexp.MustCompile(`'\s*(\)|;|\|\||or\b|and\b)`)},
+
+	// Cross-site scripting
+	{id: "xss-script-tag", set: wafSetXSS, level: 1,
+		pattern: regexp.MustCompile(`(?i)<\s*/?\s*script\b`)},
+	{id: "xss-event-handler", set: wafSetXSS, level: 2,
+		pattern: regexp.MustCompile(`(?i)<[^>]*\bon[a-z]{3,}\s*=`)},
+	{id: "xss-js-uri", set: wafSetXSS, level: 2,
+		pattern: regexp.MustCompile(`(?i)\b(javascript|vbscript)\s*:`)},
+	{id: "xss-embed-tag", set: wafSetXSS, level: 3,
+		pattern: regexp.MustCompile(`(?i)<\s*(iframe|object|embed|svg|img|body|meta|link|base)\b`)},
+
+	// Path traversal
+	{id: "traversal-dot-dot", set: wafSetPathTraversal, level: 1,
+		pattern: regexp.MustCompile(`(^|[/\\])\.\.([/\\]|$)`)},
+	{id: "traversal-system-file", set: wafSetPathTraversal, level: 2,
+		pattern: regexp.MustCompile(`(?i)(/etc/(passwd|shadow|hosts)\b|/proc/self/|\bwin\.ini\b|\bboot\.ini\b)`)},
+	{id: "traversal-null-byte", set: wafSetPathTraversal, level: 2,
+		pattern: regexp.MustCompile(`\x00`),
+		targets: map[string]bool{wafTargetPath: true, wafTargetQuery: true}},
+}
+
+// WAFPlugin blocks or reports requests matching attack signatures.
+type WAFPlugin struct {
+	config  WAFConfig
+	rules   []wafRule
+	targets map[string]bool
+	hits    *metrics.CounterVec
+}
+
+// WAFConfig holds configuration for the WAF plugin.
+type WAFConfig struct {
+	// Action is what a match does
+	// Options: "block" (403), "detect" (log and count only)
+	// Default: "block"
+	Action string `json:"action"`
+
+	// Sensitivity selects the built-in rules that run
+	// Options: "low", "medium", "high"
+	// Default: "medium"
+	Sensitivity string `json:"sensitivity"`
+
+	// RuleSets are the built-in rule sets that run
+	// Default: ["sqli", "xss", "path_traversal"]
+	RuleSets []string `json:"rule_sets"`
+
+	// Targets are the parts of the request inspected
+	// Options: "path", "query", "headers", "body"
+	// Default: ["path", "query", "headers"]
+	Targets []string `json:"targets"`
+
+	// DisabledRules are built-in rule IDs that don't run (false positives)
+	// Default: []
+	DisabledRules []string `json:"disabled_rules"`
+
+	// Rules are custom signatures; they run at every sensitivity
+	// Default: []
+	Rules []WAFCustomRule `json:"rules"`
+
+	// MaxBodyBytes is how much of a body is inspected
+	// Default: 65536
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+}
+
+// WAFCustomRule is a user-defined signature.
+type WAFCustomRule struct {
+	// ID names the rule in logs and metrics
+	ID string `json:"id"`
+
+	// Pattern is a Go regular expression
+	Pattern string `json:"pattern"`
+
+	// Targets limits the rule to some targets (empty = all inspected)
+	Targets []string `json:"targets"`
+}
+
+// NewWAFPlugin creates a new WAF plugin.
+func NewWAFPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := WAFConfig{
+		Action:       wafActionBlock,
+		Sensitivity:  "medium",
+		RuleSets:     []string{wafSetSQLi, wafSetXSS, wafSetPathTraversal},
+		Targets:      []string{wafTargetPath, wafTargetQuery, wafTargetHeaders},
+		MaxBodyBytes: 64 << 10,
+	}
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid waf config: %w", err)
+		}
+	}
+
+	if config.Action != wafActionBlock && config.Action != wafActionDetect {
+		return nil, fmt.Errorf("invalid waf config: action must be %s or %s", wafActionBlock, wafActionDetect)
+	}
+	level, ok := wafSensitivities[config.Sensitivity]
+	if !ok {
+		return nil, fmt.Errorf("invalid waf config: sensitivity must be low, medium or high")
+	}
+	if config.MaxBodyBytes <= 0 {
+		return nil, fmt.Errorf("invalid waf config: max_body_bytes must be positive")
+	}
+
+	targets, err := wafTargets(config.Targets)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("invalid waf config: targets is empty")
+	}
+
+	sets := make(map[string]bool, len(config.RuleSets))
+	for _, set := range config.RuleSets {
+		switch set {
+		case wafSetSQLi, wafSetXSS, wafSetPathTraversal:
+			sets[set] = true
+		default:
+			return nil, fmt.Errorf("invalid waf config: unknown rule set '%s'", set)
+		}
+	}
+
+	known := make(map[string]bool, len(wafBuiltinRules))
+	for _, rule := range wafBuiltinRules {
+		known[rule.id] = true
+	}
+	disabled := make(map[string]bool, len(config.DisabledRules))
+	for _, id := range config.DisabledRules {
+		if !known[id] {
+			return nil, fmt.Errorf("invalid waf config: unknown rule '%s' in disabled_rules", id)
+		}
+		disabled[id] = true
+	}
+
+	var rules []wafRule
+	for _, rule := range wafBuiltinRules {
+		if sets[rule.set] && rule.level <= level && !disabled[rule.id] {
+			rules = append(rules, rule)
+		}
+	}
+	for _, custom := range config.Rules {
+		if custom.ID == "" || known[custom.ID] {
+			return nil, fmt.Errorf("invalid waf config: custom rules need a unique id (got '%s')", custom.ID)
+		}
+		known[custom.ID] = true
+		pattern, err := regexp.Compile(custom.Pattern)
+		if err != nil || custom.Pattern == "" {
+			return nil, fmt.Errorf("invalid waf config: rule '%s' has an invalid pattern", custom.ID)
+		}
+		ruleTargets, err := wafTargets(custom.Targets)
+		if err != nil {
+			return nil, err
+		}
+		if len(ruleTargets) == 0 {
+			ruleTargets = nil
+		}
+		rules = append(rules, wafRule{id: custom.ID, set: "custom", pattern: pattern, targets: ruleTargets})
+	}
+
+	return &WAFPlugin{
+		config:  config,
+		rules:   rules,
+		targets: targets,
+		hits: plugin.NewMetrics("waf").Counter(
+			"rule_hits_total",
+			"WAF rule matches, by route, rule and action (blocked, detected).",
+			"route", "rule", "action",
+		),
+	}, nil
+}
+
+// wafTargets validates a list of inspection targets.
+func wafTargets(names []string) (map[string]bool, error) {
+	targets := make(map[string]bool, len(names))
+	for _, name := range names {
+		switch name {
+		case wafTargetPath, wafTargetQuery, wafTargetHeaders, wafTargetBody:
+			targets[name] = true
+		default:
+			return nil, fmt.Errorf("invalid waf config: unknown target '%s'", name)
+		}
+	}
+	return targets, nil
+}
+
+// Name returns the plugin identifier.
+func (p *WAFPlugin) Name() string {
+	return "waf"
+}
+
+// wafInput is one inspected value.
+type wafInput struct {
+	target string
+	name   string // parameter or header name ("" for path and body)
+	value  string
+}
+
+// Execute inspects the request.
+func (p *WAFPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+
+	inputs, err := p.inputs(ctx.Request)
+	if errors.Is(err, errWAFBodyOverBudget) {
+		abortBodyOverBudget(ctx)
+		return nil
+	}
+	if err != nil {
+		ctx.Abort(http.StatusBadRequest, "Failed to read request body")
+		return nil
+	}
+
+	for _, rule := range p.rules {
+		for _, in := range inputs {
+			if !p.targets[in.target] || (rule.targets != nil && !rule.targets[in.target]) {
+				continue
+			}
+			if !rule.pattern.MatchString(in.value) {
+				continue
+			}
+
+			log.Warn().
+				Str("component", "plugin").
+				Str("plugin", "waf").
+				Str("route_id", routeID).
+				Str("rule", rule.id).
+				Str("target", in.target).
+				Str("name", in.name).
+				Str("action", p.config.Action).
+				Str("client_ip", clientip.FromRequest(ctx.Request)).
+				Msg("WAF rule matched")
+
+			if p.config.Action == wafActionDetect {
+				p.hits.Inc(routeID, rule.id, "detected")
+				break // next rule
+			}
+
+			p.hits.Inc(routeID, rule.id, "blocked")
+			ctx.Decide(fmt.Sprintf("waf rule %s matched %s", rule.id, in.target))
+			ctx.Abort(http.StatusForbidden, "Forbidden")
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// inputs collects the decoded values the rules run against.
+func (p *WAFPlugin) inputs(r *http.Request) ([]wafInput, error) {
+	var inputs []wafInput
+
+	if p.targets[wafTargetPath] {
+		inputs = append(inputs, wafInput{target: wafTargetPath, value: wafDecode(r.URL.EscapedPath(), url.PathUnescape)})
+	}
+
+	if p.targets[wafTargetQuery] && r.URL.RawQuery != "" {
+		for _, pair := range strings.Split(r.URL.RawQuery, "&") {
+			name, value, _ := strings.Cut(pair, "=")
+			name = wafDecode(name, url.QueryUnescape)
+			inputs = append(inputs,
+				wafInput{target: wafTargetQuery, name: name, value: name},
+				wafInput{target: wafTargetQuery, name: name, value: wafDecode(value, url.QueryUnescape)},
+			)
+		}
+	}
+
+	if p.targets[wafTargetHeaders] {
+		for name, values := range r.Header {
+			for _, value := range values {
+				inputs = append(inputs, wafInput{target: wafTargetHeaders, name: name, value: value})
+			}
+		}
+	}
+
+	if p.targets[wafTargetBody] && wafInspectableBody(r) {
+		buffered, err := bodybuffer.ReadRequest(r, p.config.MaxBodyBytes)
+		if err != nil {
+			return nil, err
+		}
+		// A body that can't be inspected isn't let through unchecked
+		if buffered.OverBudget() {
+			return nil, errWAFBodyOverBudget
+		}
+		body := buffered.Bytes()
+		if int64(len(body)) > p.config.MaxBodyBytes {
+			body = body[:p.config.MaxBodyBytes]
+		}
+		if len(body) > 0 {
+			value := string(body)
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if mediaType == "application/x-www-form-urlencoded" {
+				value = wafDecode(value, url.QueryUnescape)
+			}
+			inputs = append(inputs, wafInput{target: wafTargetBody, value: value})
+		}
+	}
+
+	return inputs, nil
+}
+
+// wafDecode percent-decodes s up to twice, so double-encoded payloads are
+// seen decoded. Invalid escapes are left as they are.
+func wafDecode(s string, unescape func(string) (string, error)) string {
+	for i := 0; i < 2 && strings.Contains(s, "%"); i++ {
+		decoded, err := unescape(s)
+		if err != nil {
+			break
+		}
+		s = decoded
+	}
+	return s
+}
+
+// wafInspectableBody reports whether the request has a text-like body.
+// Binary and multipart uploads aren't inspected.
+func wafInspectableBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "application/x-www-form-urlencoded",
+		mediaType == "application/json", strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/xml", strings.HasSuffix(mediaType, "+xml"),
+		strings.HasPrefix(mediaType, "text/"):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package builtin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWAF_Rules(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		target  string
+		headers map[string]string
+		body    string
+		want    int // 0 = proxied
+	}{
+		{name: "clean request", target: "/search?q=blue+shoes&page=2"},
+		{name: "union select", target: "/search?q=1%20UNION%20SELECT%20password", want: http.StatusForbidden},
+		{name: "double encoded", target: "/search?q=%253Cscript%253Ealert(1)", want: http.StatusForbidden},
+		{name: "malicious parameter name", target: "/search?%3Cscript%3E=1", want: http.StatusForbidden},
+		{name: "tautology", target: "/login?user=%27%20OR%20%271%27%3D%271", want: http.StatusForbidden},
+		{name: "dot-dot segment", target: "/files/../../etc/passwd", want: http.StatusForbidden},
+		{name: "dots inside a name", target: "/files/report..v2.pdf"},
+		{name: "header", target: "/", headers: map[string]string{"Referer": "<script>alert(1)</script>"}, want: http.StatusForbidden},
+
+		// Sensitivity
+		{name: "low skips medium rules", config: `{"sensitivity": "low"}`, target: "/search?q=sleep(5)"},
+		{name: "medium runs medium rules", target: "/search?q=sleep(5)", want: http.StatusForbidden},
+		{name: "medium skips high rules", target: "/search?q=%3Cimg%20src%3Dx%3E"},
+		{name: "high runs high rules", config: `{"sensitivity": "high"}`, target: "/search?q=%3Cimg%20src%3Dx%3E", want: http.StatusForbidden},
+
+		// Rule selection
+		{name: "rule set off", config: `{"rule_sets": ["sqli"]}`, target: "/search?q=%3Cscript%3E"},
+		{name: "disabled rule", config: `{"disabled_rules": ["xss-js-uri"]}`, target: "/go?to=javascript:alert(1)"},
+		{name: "target off", config: `{"targets": ["path"]}`, target: "/search?q=1%20UNION%20SELECT%20password"},
+		{name: "custom rule", config: `{"rules": [{"id": "no-wp", "pattern": "(?i)/wp-admin", "targets": ["path"]}]}`, target: "/wp-admin/setup.php", want: http.StatusForbidden},
+		{name: "custom rule limited to path", config: `{"rules": [{"id": "no-wp", "pattern": "(?i)/wp-admin", "targets": ["path"]}]}`, target: "/?next=/wp-admin"},
+
+		// Body
+		{name: "body not inspected by default", target: "/comments", body: `{"text": "<script>alert(1)</script>"}`},
+		{name: "json body", config: `{"targets": ["body"]}`, target: "/comments", body: `{"text": "<script>alert(1)</script>"}`, want: http.StatusForbidden},
+		{name: "clean json body", config: `{"targets": ["body"]}`, target: "/comments", body: `{"text": "great post"}`},
+		{name: "binary body", config: `{"targets": ["body"]}`, target: "/upload", headers: map[string]string{"Content-Type": "application/octet-stream"}, body: "<script>"},
+		{name: "past max_body_bytes", config: `{"targets": ["body"], "max_body_bytes": 16}`, target: "/comments", body: `{"text": "padding padding <script>"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewWAFPlugin(json.RawMessage(tt.config))
+			if err != nil {
+				t.Fatalf("NewWAFPlugin() error = %v", err)
+			}
+
+			method, body := "GET", io.Reader(nil)
+			if tt.body != "" {
+				method, body = "POST", strings.NewReader(tt.body)
+			}
+			r := httptest.NewRequest(method, tt.target, body)
+			if tt.body != "" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			ctx := newTestContext(r, "r-waf")
+
+			if err := p.Execute(ctx); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got := ctx.AbortStatusCode(); ctx.IsAborted() != (tt.want != 0) || got != tt.want {
+				t.Fatalf("aborted = %v with %d, want %d", ctx.IsAborted(), got, tt.want)
+			}
+			if tt.want == 0 && tt.body != "" {
+				if sent, _ := io.ReadAll(ctx.Request.Body); string(sent) != tt.body {
+					t.Errorf("proxied body = %q, want the original", sent)
+				}
+			}
+		})
+	}
+}
+
+func TestWAF_DetectMode(t *testing.T) {
+	p, err := NewWAFPlugin(json.RawMessage(`{"action": "detect"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waf := p.(*WAFPlugin)
+
+	r := httptest.NewRequest("GET", "/search?q=1%20UNION%20SELECT%20password&next=javascript:alert(1)", nil)
+	ctx := newTestContext(r, "r-waf-detect")
+	if err := p.Execute(ctx); err != nil || ctx.IsAborted() {
+		t.Fatalf("Execute() = %v, aborted %v; want detect mode to proxy", err, ctx.IsAborted())
+	}
+
+	// Every matching rule is counted, not just the first
+	for _, rule := range []string{"sqli-union-select", "xss-js-uri"} {
+		if got := waf.hits.Value("r-waf-detect", rule, "detected"); got != 1 {
+			t.Errorf("%s detected count = %v, want 1", rule, got)
+		}
+	}
+	if got := waf.hits.Value("r-waf-detect", "sqli-union-select", "blocked"); got != 0 {
+		t.Errorf("blocked count = %v, want 0 in detect mode", got)
+	}
+}
+
+func TestWAF_Config(t *testing.T) {
+	for _, config := range []string{
+		`{"action": "drop"}`,
+		`{"sensitivity": "paranoid"}`,
+		`{"rule_sets": ["rce"]}`,
+		`{"targets": ["cookies"]}`,
+		`{"targets": []}`,
+		`{"disabled_rules": ["sqli-nope"]}`,
+		`{"max_body_bytes": 0}`,
+		`{"rules": [{"pattern": "x"}]}`,
+		`{"rules": [{"id": "sqli-comment", "pattern": "x"}]}`,
+		`{"rules": [{"id": "bad", "pattern": "("}]}`,
+		`{"rules": [{"id": "empty", "pattern": ""}]}`,
+		`{"rules": [{"id": "a", "pattern": "x"}, {"id": "a", "pattern": "y"}]}`,
+	} {
+		if _, err := NewWAFPlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewWAFPlugin(%s) succeeded, want error", config)
+		}
+	}
+}
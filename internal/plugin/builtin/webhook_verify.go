@@ -0,0 +1,329 @@
+// Package builtin - Webhook verification plugin for inbound webhooks
+//
+// The webhook-verify plugin checks the signature third-party providers put
+// on the webhooks they send, before the request reaches the backend:
+//   - stripe: Stripe-Signature "t=<unix>,v1=<hex>", HMAC-SHA256 over
+//     "<t>.<body>"
+//   - github: X-Hub-Signature-256 "sha256=<hex>", HMAC-SHA256 over the body
+//   - slack: X-Slack-Signature "v0=<hex>" with X-Slack-Request-Timestamp,
+//     HMAC-SHA256 over "v0:<timestamp>:<body>"
+//
+// Timestamped schemes (stripe, slack) are rejected outside tolerance of
+// the gateway's clock. Verified requests are sent on with
+// X-Webhook-Verified: true; the header is always removed from client
+// requests, so backends can trust it.
+//
+// Credentials live under "credentials", which is one of the default
+// CONFIG_ENCRYPTED_FIELDS. Several secrets may be listed to rotate them: a
+// signature from any of them is accepted.
+//
+// Configuration Example:
+//
+//	{
+//	  "provider": "stripe",
+//	  "credentials": {"secrets": ["whsec_..."]},
+//	  "tolerance": "5m",
+//	  "max_body_bytes": 1048576
+//	}
+package builtin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// WebhookVerifiedHeader is set on webhooks whose signature was verified.
+const WebhookVerifiedHeader = "X-Webhook-Verified"
+
+// Webhook providers.
+const (
+	WebhookProviderStripe = "stripe"
+	WebhookProviderGitHub = "github"
+	WebhookProviderSlack  = "slack"
+)
+
+// Webhook verification failures, also used as metric results.
+var (
+	errWebhookMissing = errors.New("missing")
+	errWebhookInvalid = errors.New("invalid")
+	errWebhookExpired = errors.New("expired")
+)
+
+// WebhookVerifyPlugin verifies inbound webhook signatures.
+type WebhookVerifyPlugin struct {
+	config        WebhookVerifyConfig
+	tolerance     time.Duration
+	now           func() time.Time
+	verifications *metrics.CounterVec
+}
+
+// WebhookVerifyConfig holds configuration for webhook verification.
+type WebhookVerifyConfig struct {
+	// Provider is stripe, github or slack
+	Provider string `json:"provider"`
+
+	// Credentials holds the signing secrets
+	Credentials WebhookCredentials `json:"credentials"`
+
+	// Tolerance is how far a signed timestamp may be from the gateway's
+	// clock (stripe, slack)
+	// Default: "5m"
+	Tolerance string `json:"tolerance"`
+
+	// MaxBodyBytes is the largest webhook body accepted
+	// Default: 1048576 (1 MiB)
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+}
+
+// WebhookCredentials holds webhook signing secrets.
+type WebhookCredentials struct {
+	// Secrets are the provider's signing secrets, current first
+	Secrets []string `json:"secrets"`
+}
+
+// NewWebhookVerifyPlugin creates a new webhook verification plugin.
+func NewWebhookVerifyPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := WebhookVerifyConfig{
+		Tolerance:    "5m",
+		MaxBodyBytes: 1 << 20,
+	}
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid webhook-verify config: %w", err)
+		}
+	}
+
+	switch config.Provider {
+	case WebhookProviderStripe, WebhookProviderGitHub, WebhookProviderSlack:
+	default:
+		return nil, fmt.Errorf("invalid webhook-verify config: provider must be one of %s, %s, %s",
+			WebhookProviderStripe, WebhookProviderGitHub, WebhookProviderSlack)
+	}
+	if len(config.Credentials.Secrets) == 0 {
+		return nil, fmt.Errorf("invalid webhook-verify config: credentials.secrets is required")
+	}
+	for _, secret := range config.Credentials.Secrets {
+		if secret == "" {
+			return nil, fmt.Errorf("invalid webhook-verify config: empty secret")
+		}
+	}
+	tolerance, err := parseWindowDuration(config.Tolerance)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook-verify config: tolerance: %w", err)
+	}
+	if config.MaxBodyBytes <= 0 {
+		return nil, fmt.Errorf("invalid webhook-verify config: max_body_bytes must be positive")
+	}
+
+	// Never log secrets
+	log.Debug().
+		Str("component", "plugin").
+		Str("plugin", "webhook-verify").
+		Str("provider", config.Provider).
+		Int("secrets", len(config.Credentials.Secrets)).
+		Msg("Webhook verify plugin initialized")
+
+	return &WebhookVerifyPlugin{
+		config:    config,
+		tolerance: tolerance,
+		now:       time.Now,
+		verifications: plugin.NewMetrics("webhook-verify").Counter(
+			"verifications_total",
+			"Webhook signature checks, by route, provider and result (verified, missing, invalid, expired, too_large, over_budget).",
+			"route", "provider", "result",
+		),
+	}, nil
+}
+
+// Name returns the plugin identifier.
+func (p *WebhookVerifyPlugin) Name() string {
+	return "webhook-verify"
+}
+
+// Execute verifies the webhook's signature before it is proxied.
+func (p *WebhookVerifyPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	// Only the gateway may say a webhook was verified
+	ctx.Request.Header.Del(WebhookVerifiedHeader)
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+
+	buffered, err := bodybuffer.ReadRequest(ctx.Request, p.config.MaxBodyBytes)
+	if err != nil {
+		ctx.Abort(http.StatusBadRequest, "Failed to read webhook body")
+		return nil
+	}
+	if buffered.OverBudget() {
+		p.verifications.Inc(routeID, p.config.Provider, "over_budget")
+		abortBodyOverBudget(ctx)
+		return nil
+	}
+	if buffered.TooLarge() {
+		p.verifications.Inc(routeID, p.config.Provider, "too_large")
+		ctx.Abort(http.StatusRequestEntityTooLarge, "Webhook body too large")
+		return nil
+	}
+	body := buffered.Bytes()
+
+	switch p.config.Provider {
+	case WebhookProviderStripe:
+		err = p.verifyStripe(ctx.Request.Header, body)
+	case WebhookProviderGitHub:
+		err = p.verifyGitHub(ctx.Request.Header, body)
+	case WebhookProviderSlack:
+		err = p.verifySlack(ctx.Request.Header, body)
+	}
+	if err != nil {
+		p.verifications.Inc(routeID, p.config.Provider, err.Error())
+		log.Warn().
+			Str("component", "plugin").
+			Str("plugin", "webhook-verify").
+			Str("route_id", routeID).
+			Str("provider", p.config.Provider).
+			Str("result", err.Error()).
+			Msg("Webhook signature rejected")
+		ctx.Decide(fmt.Sprintf("%s webhook signature %s", p.config.Provider, err))
+		ctx.Abort(http.StatusUnauthorized, "Invalid webhook signature")
+		return nil
+	}
+
+	p.verifications.Inc(routeID, p.config.Provider, "verified")
+	ctx.Request.Header.Set(WebhookVerifiedHeader, "true")
+	return nil
+}
+
+// verifyStripe checks a Stripe-Signature header: "t=<unix>,v1=<hex>",
+// where v1 may repeat while Stripe rolls secrets.
+func (p *WebhookVerifyPlugin) verifyStripe(header http.Header, body []byte) error {
+	value := header.Get("Stripe-Signature")
+	if value == "" {
+		return errWebhookMissing
+	}
+
+	var timestamp string
+	var signatures [][]byte
+	for _, part := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = val
+		case "v1":
+			if sig, err := hex.DecodeString(val); err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return errWebhookInvalid
+	}
+	if err := p.checkTimestamp(timestamp); err != nil {
+		return err
+	}
+
+	payload := append([]byte(timestamp+"."), body...)
+	for _, sig := range signatures {
+		if p.matches(payload, sig) {
+			return nil
+		}
+	}
+	return errWebhookInvalid
+}
+
+// verifyGitHub checks an X-Hub-Signature-256 header: "sha256=<hex>".
+func (p *WebhookVerifyPlugin) verifyGitHub(header http.Header, body []byte) error {
+	value := header.Get("X-Hub-Signature-256")
+	if value == "" {
+		return errWebhookMissing
+	}
+	sig, err := decodePrefixedSignature(value, "sha256=")
+	if err != nil {
+		return err
+	}
+	if !p.matches(body, sig) {
+		return errWebhookInvalid
+	}
+	return nil
+}
+
+// verifySlack checks X-Slack-Signature ("v0=<hex>") against
+// X-Slack-Request-Timestamp and the body.
+func (p *WebhookVerifyPlugin) verifySlack(header http.Header, body []byte) error {
+	value := header.Get("X-Slack-Signature")
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	if value == "" || timestamp == "" {
+		return errWebhookMissing
+	}
+	sig, err := decodePrefixedSignature(value, "v0=")
+	if err != nil {
+		return err
+	}
+	if err := p.checkTimestamp(timestamp); err != nil {
+		return err
+	}
+	if !p.matches(append([]byte("v0:"+timestamp+":"), body...), sig) {
+		return errWebhookInvalid
+	}
+	return nil
+}
+
+// checkTimestamp rejects Unix timestamps outside the tolerance.
+func (p *WebhookVerifyPlugin) checkTimestamp(value string) error {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return errWebhookInvalid
+	}
+	skew := p.now().Sub(time.Unix(seconds, 0))
+	if skew > p.tolerance || skew < -p.tolerance {
+		return errWebhookExpired
+	}
+	return nil
+}
+
+// matches reports whether sig is the HMAC-SHA256 of payload under any of
+// the secrets.
+func (p *WebhookVerifyPlugin) matches(payload, sig []byte) bool {
+	for _, secret := range p.config.Credentials.Secrets {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(payload)
+		if hmac.Equal(mac.Sum(nil), sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodePrefixedSignature decodes a "<prefix><hex>" signature.
+func decodePrefixedSignature(value, prefix string) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return nil, errWebhookInvalid
+	}
+	sig, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, errWebhookInvalid
+	}
+	return sig, nil
+}
@@ -0,0 +1,197 @@
+package builtin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const webhookBody = `{"type":"payment_intent.succeeded"}`
+
+func webhookHMAC(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newTestWebhookPlugin(t *testing.T, provider string, now time.Time) *WebhookVerifyPlugin {
+	t.Helper()
+	config := `{"provider": "` + provider + `", "credentials": {"secrets": ["current", "previous"]}, "tolerance": "5m", "max_body_bytes": 1024}`
+	p, err := NewWebhookVerifyPlugin(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewWebhookVerifyPlugin() error = %v", err)
+	}
+	wp := p.(*WebhookVerifyPlugin)
+	wp.now = func() time.Time { return now }
+	return wp
+}
+
+func TestWebhookVerify_Signatures(t *testing.T) {
+	now := time.Unix(1767225600, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	tsAt := func(offset time.Duration) string { return strconv.FormatInt(now.Add(offset).Unix(), 10) }
+
+	tests := []struct {
+		name     string
+		provider string
+		headers  map[string]string
+		body     string
+		want     int // 0 = verified
+	}{
+		{
+			name:     "stripe valid",
+			provider: WebhookProviderStripe,
+			headers:  map[string]string{"Stripe-Signature": "t=" + ts + ",v1=" + webhookHMAC("current", ts+"."+webhookBody)},
+		},
+		{
+			name:     "stripe rotated secret",
+			provider: WebhookProviderStripe,
+			headers:  map[string]string{"Stripe-Signature": "t=" + ts + ",v1=deadbeef,v1=" + webhookHMAC("previous", ts+"."+webhookBody)},
+		},
+		{
+			name:     "stripe wrong secret",
+			provider: WebhookProviderStripe,
+			headers:  map[string]string{"Stripe-Signature": "t=" + ts + ",v1=" + webhookHMAC("other", ts+"."+webhookBody)},
+			want:     http.StatusUnauthorized,
+		},
+		{
+			name:     "stripe tampered body",
+			provider: WebhookProviderStripe,
+			headers:  map[string]string{"Stripe-Signature": "t=" + ts + ",v1=" + webhookHMAC("current", ts+"."+webhookBody)},
+			body:     `{"type":"payment_intent.canceled"}`,
+			want:     http.StatusUnauthorized,
+		},
+		{
+			name:     "stripe missing",
+			provider: WebhookProviderStripe,
+			want:     http.StatusUnauthorized,
+		},
+		{
+			name:     "stripe inside the tolerance",
+			provider: WebhookProviderStripe,
+			headers:  map[string]string{"Stripe-Signature": "t=" + tsAt(-4*time.Minute) + ",v1=" + webhookHMAC("current", tsAt(-4*time.Minute)+"."+webhookBody)},
+		},
+		{
+			name:     "stripe past the tolerance",
+			provider: WebhookProviderStripe,
+			headers:  map[string]string{"Stripe-Signature": "t=" + tsAt(-6*time.Minute) + ",v1=" + webhookHMAC("current", tsAt(-6*time.Minute)+"."+webhookBody)},
+			want:     http.StatusUnauthorized,
+		},
+		{
+			name:     "stripe ahead of the tolerance",
+			provider: WebhookProviderStripe,
+			headers:  map[string]string{"Stripe-Signature": "t=" + tsAt(6*time.Minute) + ",v1=" + webhookHMAC("current", tsAt(6*time.Minute)+"."+webhookBody)},
+			want:     http.StatusUnauthorized,
+		},
+		{
+			name:     "github valid",
+			provider: WebhookProviderGitHub,
+			headers:  map[string]string{"X-Hub-Signature-256": "sha256=" + webhookHMAC("current", webhookBody)},
+		},
+		{
+			name:     "github wrong prefix",
+			provider: WebhookProviderGitHub,
+			headers:  map[string]string{"X-Hub-Signature-256": "sha1=" + webhookHMAC("current", webhookBody)},
+			want:     http.StatusUnauthorized,
+		},
+		{
+			name:     "github not hex",
+			provider: WebhookProviderGitHub,
+			headers:  map[string]string{"X-Hub-Signature-256": "sha256=zz"},
+			want:     http.StatusUnauthorized,
+		},
+		{
+			name:     "slack valid",
+			provider: WebhookProviderSlack,
+			headers: map[string]string{
+				"X-Slack-Request-Timestamp": ts,
+				"X-Slack-Signature":         "v0=" + webhookHMAC("current", "v0:"+ts+":"+webhookBody),
+			},
+		},
+		{
+			name:     "slack timestamp changed",
+			provider: WebhookProviderSlack,
+			headers: map[string]string{
+				"X-Slack-Request-Timestamp": tsAt(time.Minute),
+				"X-Slack-Signature":         "v0=" + webhookHMAC("current", "v0:"+ts+":"+webhookBody),
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name:     "slack past the tolerance",
+			provider: WebhookProviderSlack,
+			headers: map[string]string{
+				"X-Slack-Request-Timestamp": tsAt(-10 * time.Minute),
+				"X-Slack-Signature":         "v0=" + webhookHMAC("current", "v0:"+tsAt(-10*time.Minute)+":"+webhookBody),
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name:     "too large",
+			provider: WebhookProviderGitHub,
+			headers:  map[string]string{"X-Hub-Signature-256": "sha256=" + webhookHMAC("current", strings.Repeat("x", 2048))},
+			body:     strings.Repeat("x", 2048),
+			want:     http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := tt.body
+			if body == "" {
+				body = webhookBody
+			}
+			r := httptest.NewRequest("POST", "/hooks", strings.NewReader(body))
+			r.Header.Set(WebhookVerifiedHeader, "true") // forged by the client
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			ctx := newTestContext(r, "r-hooks")
+
+			if err := newTestWebhookPlugin(t, tt.provider, now).Execute(ctx); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			if tt.want != 0 {
+				if ctx.AbortStatusCode() != tt.want {
+					t.Errorf("status = %d, want %d", ctx.AbortStatusCode(), tt.want)
+				}
+				if r.Header.Get(WebhookVerifiedHeader) != "" {
+					t.Error("rejected webhook kept X-Webhook-Verified")
+				}
+				return
+			}
+
+			if ctx.IsAborted() {
+				t.Fatalf("aborted with %d %q, want verified", ctx.AbortStatusCode(), ctx.AbortMessage())
+			}
+			if r.Header.Get(WebhookVerifiedHeader) != "true" {
+				t.Error("verified webhook lacks X-Webhook-Verified")
+			}
+			if sent, _ := io.ReadAll(ctx.Request.Body); string(sent) != body {
+				t.Errorf("proxied body = %q, want the original", sent)
+			}
+		})
+	}
+}
+
+func TestWebhookVerify_Config(t *testing.T) {
+	for _, config := range []string{
+		`{"provider": "paypal", "credentials": {"secrets": ["s"]}}`,
+		`{"provider": "github"}`,
+		`{"provider": "github", "credentials": {"secrets": [""]}}`,
+		`{"provider": "github", "credentials": {"secrets": ["s"]}, "max_body_bytes": 0}`,
+	} {
+		if _, err := NewWebhookVerifyPlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewWebhookVerifyPlugin(%s) succeeded, want error", config)
+		}
+	}
+}
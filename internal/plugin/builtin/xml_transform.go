@@ -0,0 +1,397 @@
+// Package builtin - XML body transformation plugin
+//
+// The xml-transform plugin edits XML request and response bodies, e.g.
+// SOAP envelopes for a legacy backend, and can convert between JSON and
+// XML for clients that don't speak SOAP:
+//   - request / response: "remove" deletes the elements or attributes a
+//     path selects; "set" sets their text or value, creating a missing last
+//     element or attribute under each element the rest of the path selects
+//   - to_json: XML responses are sent as JSON (see xmlbody.ToJSON);
+//     unwrap_soap converts only the content of the SOAP Body
+//   - from_json: JSON requests are sent as XML (see xmlbody.FromJSON),
+//     with xml_content_type, before the request edits
+//
+// Only bodies whose Content-Type is XML (application/xml, text/xml, any
+// "+xml" type) are touched, besides JSON requests with from_json, and 206
+// partial responses are passed through. Malformed XML requests (or JSON
+// ones that can't be converted) are rejected with 400, which makes the
+// plugin an XML well-formedness check on its own.
+//
+// Configuration Example:
+//
+//	{
+//	  "namespaces": {
+//	    "soap": "http://schemas.xmlsoap.org/soap/envelope/",
+//	    "ord": "urn:example:orders"
+//	  },
+//	  "request": {
+//	    "remove": ["//soap:Header/ord:Debug"],
+//	    "set": [{"path": "/soap:Envelope/soap:Header/ord:Consumer", "value": "{consumer_id}"}]
+//	  },
+//	  "response": {
+//	    "remove": ["//ord:InternalId"]
+//	  },
+//	  "to_json": true,
+//	  "unwrap_soap": true,
+//	  "from_json": false,
+//	  "xml_content_type": "application/xml",
+//	  "max_body_bytes": 1048576
+//	}
+//
+// Paths are the XPath subset described in internal/xmlbody. Values may
+// reference context metadata as {key}, e.g. {consumer_id} set by an auth
+// plugin. Edits apply in order: removals first, then sets.
+package builtin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/bodybuffer"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/xmlbody"
+)
+
+// XMLTransformPlugin edits and converts XML bodies.
+type XMLTransformPlugin struct {
+	config   XMLTransformConfig
+	request  xmlEdits
+	response xmlEdits
+	bodies   *metrics.CounterVec
+}
+
+// XMLTransformConfig holds configuration for the XML transform plugin.
+type XMLTransformConfig struct {
+	// Namespaces binds the prefixes used in paths to namespace URIs
+	Namespaces map[string]string `json:"namespaces"`
+
+	// Request edits XML request bodies
+	Request XMLEditsConfig `json:"request"`
+
+	// Response edits XML response bodies
+	Response XMLEditsConfig `json:"response"`
+
+	// ToJSON converts XML responses to JSON
+	ToJSON bool `json:"to_json"`
+
+	// UnwrapSOAP converts only the element inside a SOAP Body
+	// (the operation response or Fault) when ToJSON is set
+	UnwrapSOAP bool `json:"unwrap_soap"`
+
+	// FromJSON converts JSON requests to XML
+	FromJSON bool `json:"from_json"`
+
+	// XMLContentType is the Content-Type of requests converted by FromJSON,
+	// e.g. "text/xml" for SOAP 1.1
+	// Default: "application/xml"
+	XMLContentType string `json:"xml_content_type"`
+
+	// MaxBodyBytes limits the bodies that are parsed. Larger requests are
+	// rejected with 413; larger responses pass through unchanged
+	// Default: 1 MB
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+}
+
+// XMLEditsConfig lists the edits for one direction.
+type XMLEditsConfig struct {
+	// Remove lists paths to delete
+	Remove []string `json:"remove"`
+
+	// Set lists paths to set, in order
+	Set []XMLSetConfig `json:"set"`
+}
+
+// XMLSetConfig sets the text or value a path selects.
+type XMLSetConfig struct {
+	Path  string `json:"path"`
+	Value string `json:"value"`
+}
+
+// xmlEdits are compiled edits.
+type xmlEdits struct {
+	remove []*xmlbody.Path
+	set    []xmlSet
+}
+
+type xmlSet struct {
+	path  *xmlbody.Path
+	value string
+}
+
+func (e xmlEdits) empty() bool {
+	return len(e.remove) == 0 && len(e.set) == 0
+}
+
+// metadataPlaceholder matches {key} references in set values.
+var metadataPlaceholder = regexp.MustCompile(`\{([A-Za-z0-9_.-]+)\}`)
+
+// NewXMLTransformPlugin creates a new XML transform plugin.
+func NewXMLTransformPlugin(configJSON json.RawMessage) (plugin.Plugin, error) {
+	config := XMLTransformConfig{XMLContentType: "application/xml", MaxBodyBytes: 1 << 20}
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("invalid xml-transform config: %w", err)
+		}
+	}
+	if config.MaxBodyBytes <= 0 {
+		return nil, fmt.Errorf("invalid xml-transform config: max_body_bytes must be positive")
+	}
+	if config.UnwrapSOAP && !config.ToJSON {
+		return nil, fmt.Errorf("invalid xml-transform config: unwrap_soap requires to_json")
+	}
+	if !isXMLContent(http.Header{"Content-Type": {config.XMLContentType}}) {
+		return nil, fmt.Errorf("invalid xml-transform config: xml_content_type '%s' is not an XML type", config.XMLContentType)
+	}
+
+	request, err := compileXMLEdits(config.Request, config.Namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xml-transform config: request: %w", err)
+	}
+	response, err := compileXMLEdits(config.Response, config.Namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xml-transform config: response: %w", err)
+	}
+
+	log.Info().
+		Str("component", "plugin").
+		Str("plugin", "xml-transform").
+		Int("request_edits", len(request.remove)+len(request.set)).
+		Int("response_edits", len(response.remove)+len(response.set)).
+		Bool("to_json", config.ToJSON).
+		Bool("from_json", config.FromJSON).
+		Msg("XML transform plugin initialized")
+
+	return &XMLTransformPlugin{
+		config:   config,
+		request:  request,
+		response: response,
+		bodies: plugin.NewMetrics("xml-transform").Counter(
+			"bodies_total",
+			"XML bodies seen by the plugin, by direction and result.",
+			"direction", "result",
+		),
+	}, nil
+}
+
+func compileXMLEdits(config XMLEditsConfig, namespaces map[string]string) (xmlEdits, error) {
+	var edits xmlEdits
+	for _, expr := range config.Remove {
+		p, err := xmlbody.Compile(expr, namespaces)
+		if err != nil {
+			return edits, err
+		}
+		edits.remove = append(edits.remove, p)
+	}
+	for _, s := range config.Set {
+		p, err := xmlbody.Compile(s.Path, namespaces)
+		if err != nil {
+			return edits, err
+		}
+		edits.set = append(edits.set, xmlSet{path: p, value: s.Value})
+	}
+	return edits, nil
+}
+
+// Name returns the plugin identifier.
+func (p *XMLTransformPlugin) Name() string {
+	return "xml-transform"
+}
+
+// Execute rewrites the request body and registers the response rewrite.
+func (p *XMLTransformPlugin) Execute(ctx *plugin.Context) error {
+	if ctx.Phase != plugin.PhaseBeforeRequest {
+		return nil
+	}
+
+	if err := p.transformRequest(ctx); err != nil || ctx.IsAborted() {
+		return err
+	}
+
+	if p.response.empty() && !p.config.ToJSON {
+		return nil
+	}
+	// Compressed responses can't be parsed
+	ctx.AddUpstreamHook(func(req *http.Request) error {
+		req.Header.Set("Accept-Encoding", "identity")
+		return nil
+	})
+	buffers := bodybuffer.FromRequest(ctx.Request)
+	ctx.AddResponseHook(func(resp *http.Response) error {
+		return p.transformResponse(resp, buffers)
+	})
+	return nil
+}
+
+// abortBodyOverBudget rejects a request whose body couldn't be buffered
+// because the gateway's body buffering budget is exhausted.
+func abortBodyOverBudget(ctx *plugin.Context) {
+	ctx.Response.Header().Set("Retry-After", "1")
+	ctx.Abort(http.StatusServiceUnavailable, "Gateway is busy buffering other requests, please retry")
+}
+
+// transformRequest validates and edits an XML request body, converting a
+// JSON one first with from_json.
+func (p *XMLTransformPlugin) transformRequest(ctx *plugin.Context) error {
+	r := ctx.Request
+	fromJSON := p.config.FromJSON && isJSONContent(r.Header)
+	if r.Body == nil || r.Body == http.NoBody || !(fromJSON || isXMLContent(r.Header)) {
+		return nil
+	}
+
+	// The buffer is only held while the body is parsed
+	buffered, err := bodybuffer.FromRequest(r).Read(r.Body, p.config.MaxBodyBytes)
+	r.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	defer buffered.Close()
+	if buffered.OverBudget() {
+		p.bodies.Inc("request", "over_budget")
+		abortBodyOverBudget(ctx)
+		return nil
+	}
+	if buffered.TooLarge() {
+		p.bodies.Inc("request", "too_large")
+		ctx.Abort(http.StatusRequestEntityTooLarge, "Request body too large")
+		return nil
+	}
+	data := buffered.Bytes()
+
+	var doc *xmlbody.Document
+	if fromJSON {
+		doc, err = xmlbody.FromJSON(data)
+		if err != nil {
+			p.bodies.Inc("request", "invalid")
+			ctx.Abort(http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+			return nil
+		}
+		r.Header.Set("Content-Type", p.config.XMLContentType)
+		ctx.Decide("converted JSON request body to XML")
+	} else if doc, err = xmlbody.Parse(data); err != nil {
+		p.bodies.Inc("request", "invalid")
+		ctx.Abort(http.StatusBadRequest, "Invalid XML body: "+err.Error())
+		return nil
+	}
+
+	if !p.request.empty() {
+		p.apply(doc, p.request, func(value string) string { return expandMetadata(ctx, value) })
+		ctx.Decide("edited XML request body")
+	}
+	if fromJSON || !p.request.empty() {
+		data = doc.Bytes()
+	}
+	if fromJSON {
+		p.bodies.Inc("request", "converted")
+	} else {
+		p.bodies.Inc("request", "ok")
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.ContentLength = int64(len(data))
+	r.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	return nil
+}
+
+// transformResponse edits an XML response body and converts it to JSON.
+func (p *XMLTransformPlugin) transformResponse(resp *http.Response, buffers *bodybuffer.Manager) error {
+	if !isXMLContent(resp.Header) || !hasBody(resp) {
+		return nil
+	}
+	if isPartialContent(resp) {
+		p.bodies.Inc("response", "partial")
+		return nil
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		p.bodies.Inc("response", "encoded")
+		return nil
+	}
+
+	buffered, err := buffers.Read(resp.Body, p.config.MaxBodyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to read upstream response: %w", err)
+	}
+	resp.Body = buffered.ReadCloser(resp.Body)
+	if !buffered.Complete() {
+		// Too large to parse, or no room to buffer it; send it on as it is
+		if buffered.OverBudget() {
+			p.bodies.Inc("response", "over_budget")
+		} else {
+			p.bodies.Inc("response", "too_large")
+		}
+		return nil
+	}
+	data := buffered.Bytes()
+
+	doc, err := xmlbody.Parse(data)
+	if err != nil {
+		p.bodies.Inc("response", "invalid")
+		log.Warn().
+			Err(err).
+			Str("component", "plugin").
+			Str("plugin", "xml-transform").
+			Int("upstream_status", resp.StatusCode).
+			Msg("Upstream returned malformed XML; passing it through")
+		return nil
+	}
+	resp.Body.Close()
+
+	p.apply(doc, p.response, func(value string) string { return value })
+
+	switch {
+	case p.config.ToJSON:
+		root := doc.Root
+		if p.config.UnwrapSOAP {
+			if body := xmlbody.SOAPBody(doc); body != nil {
+				root = body
+			}
+		}
+		data = xmlbody.ToJSON(root)
+		resp.Header.Set("Content-Type", "application/json")
+		p.bodies.Inc("response", "converted")
+	case !p.response.empty():
+		data = doc.Bytes()
+		p.bodies.Inc("response", "ok")
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	return nil
+}
+
+// apply runs removals, then sets.
+func (p *XMLTransformPlugin) apply(doc *xmlbody.Document, edits xmlEdits, expand func(string) string) {
+	for _, path := range edits.remove {
+		path.Remove(doc)
+	}
+	for _, s := range edits.set {
+		s.path.Set(doc, expand(s.value))
+	}
+}
+
+// expandMetadata replaces {key} with the context metadata value of key.
+// Unknown keys are left as written.
+func expandMetadata(ctx *plugin.Context, value string) string {
+	return metadataPlaceholder.ReplaceAllStringFunc(value, func(m string) string {
+		key := m[1 : len(m)-1]
+		if v, ok := ctx.Lookup(key); ok {
+			return fmt.Sprint(v)
+		}
+		return m
+	})
+}
+
+// isXMLContent reports whether the headers declare an XML body.
+func isXMLContent(h http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && xmlbody.IsXML(mediaType)
+}
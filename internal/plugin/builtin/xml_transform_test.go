@@ -0,0 +1,242 @@
+package builtin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+const soapOrderResponse = `<?xml version="1.0"?>` +
+	`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ord="urn:orders">` +
+	`<soap:Body><ord:GetOrderResponse><ord:Order id="7"><ord:Item>a &amp; b</ord:Item><ord:Item>c</ord:Item>` +
+	`<ord:InternalId>x1</ord:InternalId></ord:Order></ord:GetOrderResponse></soap:Body></soap:Envelope>`
+
+func newTestXMLTransform(t *testing.T, config string) plugin.Plugin {
+	t.Helper()
+	p, err := NewXMLTransformPlugin(json.RawMessage(config))
+	if err != nil {
+		t.Fatalf("NewXMLTransformPlugin() error = %v", err)
+	}
+	return p
+}
+
+// xmlRequest runs p on a POST of body with the given Content-Type, as
+// consumer c-1.
+func xmlRequest(t *testing.T, p plugin.Plugin, contentType, body string) *plugin.Context {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/soap/orders", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	ctx := newTestContext(r, "r-soap")
+	plugin.KeyConsumerID.Set(ctx, "c-1")
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	return ctx
+}
+
+func TestXMLTransform_Request(t *testing.T) {
+	p := newTestXMLTransform(t, `{
+		"namespaces": {"soap": "http://schemas.xmlsoap.org/soap/envelope/", "ord": "urn:orders"},
+		"request": {
+			"remove": ["//ord:Debug"],
+			"set": [{"path": "/soap:Envelope/soap:Header/ord:Consumer", "value": "{consumer_id}"}]
+		},
+		"max_body_bytes": 512
+	}`)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int // 0 = passed on
+		want        string
+	}{
+		{
+			name:        "edited",
+			contentType: "text/xml; charset=utf-8",
+			body:        `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ord="urn:orders"><soap:Header><ord:Debug>on</ord:Debug></soap:Header><soap:Body/></soap:Envelope>`,
+			want:        `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ord="urn:orders"><soap:Header><ord:Consumer>c-1</ord:Consumer></soap:Header><soap:Body/></soap:Envelope>`,
+		},
+		{
+			name:        "malformed",
+			contentType: "application/xml",
+			body:        `<soap:Envelope><soap:Body>`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "too large",
+			contentType: "application/soap+xml",
+			body:        "<a>" + strings.Repeat("x", 512) + "</a>",
+			status:      http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "not XML",
+			contentType: "application/json",
+			body:        `{"order": 7}`,
+			want:        `{"order": 7}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := xmlRequest(t, p, tt.contentType, tt.body)
+			if got := ctx.AbortStatusCode(); ctx.IsAborted() != (tt.status != 0) || got != tt.status {
+				t.Fatalf("aborted = %v with %d (%s), want %d", ctx.IsAborted(), got, ctx.AbortMessage(), tt.status)
+			}
+			if tt.status != 0 {
+				return
+			}
+			body, _ := io.ReadAll(ctx.Request.Body)
+			if string(body) != tt.want {
+				t.Errorf("upstream body =\n%s\nwant\n%s", body, tt.want)
+			}
+			if ctx.Request.ContentLength != int64(len(body)) {
+				t.Errorf("ContentLength = %d, want %d", ctx.Request.ContentLength, len(body))
+			}
+		})
+	}
+}
+
+func TestXMLTransform_FromJSON(t *testing.T) {
+	p := newTestXMLTransform(t, `{
+		"namespaces": {"soap": "http://schemas.xmlsoap.org/soap/envelope/"},
+		"request": {"set": [{"path": "//GetOrder/Consumer", "value": "{consumer_id}"}]},
+		"from_json": true,
+		"xml_content_type": "text/xml; charset=utf-8"
+	}`)
+
+	// JSON is converted, then edited, and sent as XML
+	ctx := xmlRequest(t, p, "application/json", `{"soap:Envelope": {
+		"@xmlns:soap": "http://schemas.xmlsoap.org/soap/envelope/",
+		"soap:Body": {"GetOrder": {"@id": 7, "Item": ["a & b", "c"]}}
+	}}`)
+	if ctx.IsAborted() {
+		t.Fatalf("aborted with %d (%s), want the request converted", ctx.AbortStatusCode(), ctx.AbortMessage())
+	}
+	body, _ := io.ReadAll(ctx.Request.Body)
+	want := `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>` +
+		`<GetOrder id="7"><Item>a &amp; b</Item><Item>c</Item><Consumer>c-1</Consumer></GetOrder></soap:Body></soap:Envelope>`
+	if string(body) != want {
+		t.Errorf("upstream body =\n%s\nwant\n%s", body, want)
+	}
+	if got := ctx.Request.Header.Get("Content-Type"); got != "text/xml; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want the configured XML type", got)
+	}
+
+	// JSON that isn't well formed, or has no XML form, is rejected
+	for _, bad := range []string{`{"GetOrder": `, `{"a": 1, "b": 2}`, `{"GetOrder": [[1]]}`, `["GetOrder"]`} {
+		if ctx := xmlRequest(t, p, "application/json", bad); ctx.AbortStatusCode() != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", bad, ctx.AbortStatusCode())
+		}
+	}
+
+	// XML requests are still only validated and edited
+	ctx = xmlRequest(t, p, "application/xml", `<GetOrder/>`)
+	body, _ = io.ReadAll(ctx.Request.Body)
+	if string(body) != `<GetOrder><Consumer>c-1</Consumer></GetOrder>` || ctx.Request.Header.Get("Content-Type") != "application/xml" {
+		t.Errorf("XML request = %s (%s)", body, ctx.Request.Header.Get("Content-Type"))
+	}
+}
+
+func TestXMLTransform_Response(t *testing.T) {
+	const namespaces = `"namespaces": {"ord": "urn:orders"}`
+
+	tests := []struct {
+		name        string
+		config      string
+		resp        *http.Response
+		want        string
+		contentType string
+	}{
+		{
+			name:        "to json",
+			config:      `{` + namespaces + `, "response": {"remove": ["//ord:InternalId"]}, "to_json": true, "unwrap_soap": true}`,
+			resp:        upstreamResponse(http.StatusOK, http.Header{"Content-Type": {"text/xml"}}, soapOrderResponse),
+			want:        `{"GetOrderResponse":{"Order":{"@id":"7","Item":["a & b","c"]}}}`,
+			contentType: "application/json",
+		},
+		{
+			name:        "to json with the envelope",
+			config:      `{"to_json": true}`,
+			resp:        upstreamResponse(http.StatusOK, http.Header{"Content-Type": {"application/xml"}}, `<Fault><Reason>boom</Reason></Fault>`),
+			want:        `{"Fault":{"Reason":"boom"}}`,
+			contentType: "application/json",
+		},
+		{
+			name:        "edited",
+			config:      `{` + namespaces + `, "response": {"remove": ["//ord:InternalId", "//ord:Order/@id"]}}`,
+			resp:        upstreamResponse(http.StatusOK, http.Header{"Content-Type": {"text/xml"}}, soapOrderResponse),
+			want:        strings.NewReplacer(`<ord:InternalId>x1</ord:InternalId>`, "", ` id="7"`, "").Replace(soapOrderResponse),
+			contentType: "text/xml",
+		},
+		{
+			name:        "malformed",
+			config:      `{"to_json": true}`,
+			resp:        upstreamResponse(http.StatusOK, http.Header{"Content-Type": {"text/xml"}}, `<Order><Item>`),
+			want:        `<Order><Item>`,
+			contentType: "text/xml",
+		},
+		{
+			name:        "partial content",
+			config:      `{"to_json": true}`,
+			resp:        upstreamResponse(http.StatusPartialContent, http.Header{"Content-Type": {"text/xml"}}, `<Order>`),
+			want:        `<Order>`,
+			contentType: "text/xml",
+		},
+		{
+			name:        "not XML",
+			config:      `{"to_json": true}`,
+			resp:        upstreamResponse(http.StatusOK, http.Header{"Content-Type": {"text/html"}}, `<p>hi</p>`),
+			want:        `<p>hi</p>`,
+			contentType: "text/html",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestXMLTransform(t, tt.config)
+			ctx := newTestContext(httptest.NewRequest("GET", "/soap/orders", nil), "r-soap")
+			if err := p.Execute(ctx); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			for _, hook := range plugin.UpstreamHooks(ctx.Request) {
+				hook(ctx.Request)
+			}
+			if got := ctx.Request.Header.Get("Accept-Encoding"); got != "identity" {
+				t.Errorf("upstream Accept-Encoding = %q, want identity", got)
+			}
+			for _, hook := range plugin.ResponseHooks(ctx.Request) {
+				if err := hook(tt.resp); err != nil {
+					t.Fatalf("response hook error = %v", err)
+				}
+			}
+
+			body, _ := io.ReadAll(tt.resp.Body)
+			if string(body) != tt.want {
+				t.Errorf("body =\n%s\nwant\n%s", body, tt.want)
+			}
+			if got := tt.resp.Header.Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+		})
+	}
+}
+
+func TestXMLTransform_Config(t *testing.T) {
+	for _, config := range []string{
+		`{"max_body_bytes": 0}`,
+		`{"unwrap_soap": true}`,
+		`{"from_json": true, "xml_content_type": "application/json"}`,
+		`{"request": {"remove": ["//"]}}`,
+		`{"response": {"set": [{"path": "//ord:Order", "value": "x"}]}}`,
+	} {
+		if _, err := NewXMLTransformPlugin(json.RawMessage(config)); err == nil {
+			t.Errorf("NewXMLTransformPlugin(%s) succeeded, want error", config)
+		}
+	}
+}
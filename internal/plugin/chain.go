@@ -6,6 +6,8 @@
 //   - Executing plugins in the correct phase
 //   - Handling plugin errors gracefully
 //   - Short-circuiting when needed (abort or critical error)
+//   - Aborting with a 504 once the request deadline has passed (see
+//     internal/deadline)
 //
 // Chain Execution Order:
 //
@@ -21,10 +23,14 @@
 package plugin
 
 import (
+	"net/http"
 	"sort"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/deadline"
+	"github.com/saidutt46/switchboard-gateway/internal/featureflag"
 )
 
 // Chain represents a collection of plugins to execute.
@@ -49,6 +55,29 @@ type PluginInstance struct {
 	// Critical indicates if plugin failure should stop the chain
 	// Read from plugin config JSON: {"critical": true}
 	Critical bool
+
+	// Mode is ModeEnforce (default) or ModeShadow (decisions are recorded
+	// but never enforced). Read from plugin config JSON: {"mode": "shadow"}
+	Mode string
+
+	// EnabledFlag gates the plugin on a feature flag evaluated per request
+	// (empty = always runs). Read from plugin config JSON:
+	// {"enabled_flag": "new-rate-limiter"}
+	EnabledFlag string
+
+	// Async runs the AfterResponse phase on the registry's worker pool
+	// after the response is flushed, with a queue of AsyncQueueSize jobs
+	// (0 = PLUGIN_ASYNC_QUEUE_SIZE) and AsyncDropPolicy when it is full.
+	// Read from plugin config JSON: {"async": true}
+	Async           bool
+	AsyncQueueSize  int
+	AsyncDropPolicy string
+
+	// flags evaluates EnabledFlag (set by the registry)
+	flags featureflag.Provider
+
+	// async runs Async instances (set by the registry)
+	async *AsyncPool
 }
 
 // NewChain creates a new empty plugin chain.
@@ -90,6 +119,7 @@ func (c *Chain) Sort() {
 //   - BeforeRequest: Execute in ascending priority order (1, 2, 3...)
 //   - AfterResponse: Execute in descending priority order (3, 2, 1...)
 //   - Stop on ctx.Abort() (e.g., auth failure)
+//   - Stop with a 504 once the request deadline has passed (BeforeRequest)
 //   - Stop on critical plugin error
 //   - Continue on non-critical plugin error (just log it)
 //
@@ -126,8 +156,36 @@ func (c *Chain) Execute(ctx *Context) error {
 			return nil
 		}
 
+		// Out of time: don't start another plugin
+		if c.abortOnDeadline(ctx, instance.Plugin.Name()) {
+			return nil
+		}
+
+		// Plugins behind a feature flag that is off for this request are skipped
+		if !instance.flagEnabled(ctx) {
+			continue
+		}
+
+		// Async plugins observe the response off the request path
+		if instance.Async && ctx.Phase == PhaseAfterResponse {
+			c.dispatchAsync(instance, ctx)
+			continue
+		}
+
+		// Shadow plugins never abort or fail the request
+		if instance.Mode == ModeShadow {
+			c.executeShadow(instance, ctx)
+			continue
+		}
+
 		// Execute plugin
 		if err := c.executePlugin(instance, ctx); err != nil {
+			// A plugin failing because the request ran out of time (e.g. a
+			// canceled Redis call) is a timeout, not a plugin failure
+			if c.abortOnDeadline(ctx, instance.Plugin.Name()) {
+				return nil
+			}
+
 			// Check if this is a critical error
 			if instance.Critical {
 				log.Error().
@@ -188,15 +246,12 @@ func (c *Chain) getExecutionOrder(phase Phase) []PluginInstance {
 func (c *Chain) executePlugin(instance PluginInstance, ctx *Context) error {
 	pluginName := instance.Plugin.Name()
 
-	log.Debug().
-		Str("component", "plugin_chain").
-		Str("plugin", pluginName).
-		Str("phase", string(ctx.Phase)).
-		Int("priority", instance.Priority).
-		Msg("Executing plugin")
-
 	// Execute the plugin
+	started := time.Now()
+	ctx.plugin = pluginName
 	err := instance.Plugin.Execute(ctx)
+	ctx.plugin = ""
+	ctx.recordDecision(pluginName, started, ctx.aborted, ctx.abortStatusCode, ctx.abortMessage, err, false)
 
 	if err != nil {
 		ctx.LogError(pluginName, err, "Plugin execution failed")
@@ -211,16 +266,31 @@ func (c *Chain) executePlugin(instance PluginInstance, ctx *Context) error {
 			Int("status_code", ctx.AbortStatusCode()).
 			Str("message", ctx.AbortMessage()).
 			Msg("Plugin aborted the request")
-	} else {
-		log.Debug().
-			Str("component", "plugin_chain").
-			Str("plugin", pluginName).
-			Msg("Plugin executed successfully")
 	}
 
 	return nil
 }
 
+// abortOnDeadline aborts the request with a 504 if its deadline has
+// passed during the BeforeRequest phase. AfterResponse plugins always run,
+// since the response is already out.
+func (c *Chain) abortOnDeadline(ctx *Context, pluginName string) bool {
+	if ctx.Phase != PhaseBeforeRequest || !deadline.Exceeded(ctx.Context()) {
+		return false
+	}
+
+	deadline.Record(ctx.Route.ID, deadline.StagePlugins)
+	ctx.Response.Header().Set("Content-Type", "application/json")
+	ctx.Abort(http.StatusGatewayTimeout, deadline.Body)
+
+	log.Warn().
+		Str("component", "plugin_chain").
+		Str("plugin", pluginName).
+		Str("route_id", ctx.Route.ID).
+		Msg("Request deadline exceeded - stopping chain")
+	return true
+}
+
 // Count returns the number of plugins in the chain.
 func (c *Chain) Count() int {
 	return len(c.plugins)
@@ -256,6 +326,7 @@ func NewChainBuilder(plugins []PluginInstance) *ChainBuilder {
 // Includes plugins with scope:
 //   - global (apply to all requests)
 //   - service (match route's service)
+//   - group (match route's route group)
 //   - route (match this specific route)
 func (cb *ChainBuilder) BuildForRoute(route *database.Route, service *database.Service) *Chain {
 	chain := NewChain()
@@ -288,8 +359,8 @@ func (cb *ChainBuilder) shouldInclude(
 ) bool {
 	switch instance.Scope {
 	case database.PluginScopeGlobal:
-		// Global plugins apply to all requests
-		return true
+		// Global plugins apply to all requests in their workspace
+		return database.WorkspaceName(instance.Config.Workspace) == database.WorkspaceName(route.Workspace)
 
 	case database.PluginScopeService:
 		// Service plugins apply to requests for that service
@@ -305,6 +376,13 @@ func (cb *ChainBuilder) shouldInclude(
 		}
 		return false
 
+	case database.PluginScopeGroup:
+		// Group plugins apply to every route of the group
+		if instance.Config.GroupID.Valid && route.GroupID.Valid {
+			return instance.Config.GroupID.String == route.GroupID.String
+		}
+		return false
+
 	case database.PluginScopeConsumer:
 		// Consumer plugins - will implement in future phase
 		// For now, skip consumer-scoped plugins
@@ -325,6 +403,7 @@ func (cb *ChainBuilder) Stats() map[string]interface{} {
 	globalCount := 0
 	serviceCount := 0
 	routeCount := 0
+	groupCount := 0
 	consumerCount := 0
 
 	for _, instance := range cb.allPlugins {
@@ -335,6 +414,8 @@ func (cb *ChainBuilder) Stats() map[string]interface{} {
 			serviceCount++
 		case database.PluginScopeRoute:
 			routeCount++
+		case database.PluginScopeGroup:
+			groupCount++
 		case database.PluginScopeConsumer:
 			consumerCount++
 		}
@@ -345,6 +426,7 @@ func (cb *ChainBuilder) Stats() map[string]interface{} {
 		"global_plugins":   globalCount,
 		"service_plugins":  serviceCount,
 		"route_plugins":    routeCount,
+		"group_plugins":    groupCount,
 		"consumer_plugins": consumerCount,
 	}
 }
@@ -0,0 +1,146 @@
+// Package plugin - Decision records for troubleshooting plugin chains
+//
+// Every plugin execution is recorded on the Context: which plugin ran, in
+// which phase, what it decided and how long it took. Plugins can add a
+// short reason for their decision:
+//
+//	ctx.Decide("api key valid")
+//
+// (aborts use the abort message as the reason). The gateway can emit the
+// record as a single JSON log line per request and, in debug setups, send
+// it upstream in the X-Gateway-Decisions header:
+//
+//	X-Gateway-Decisions: key-auth=continue;0.21ms, rate-limit=continue;1.3ms
+//
+// so one line answers "why was this request rejected/slow?" instead of
+// correlating each plugin's debug logs.
+package plugin
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// DecisionHeader carries the BeforeRequest decisions to the backend.
+const DecisionHeader = "X-Gateway-Decisions"
+
+// Decision outcomes.
+const (
+	DecisionContinue = "continue"
+	DecisionAbort    = "abort"
+	DecisionError    = "error"
+
+	// Async plugins (see async): the AfterResponse job was queued or dropped
+	DecisionQueued  = "queued"
+	DecisionDropped = "dropped"
+)
+
+// Decision records one plugin execution.
+type Decision struct {
+	Plugin     string  `json:"plugin"`
+	Phase      Phase   `json:"phase"`
+	Outcome    string  `json:"outcome"`
+	Status     int     `json:"status,omitempty"`
+	Reason     string  `json:"reason,omitempty"`
+	Shadow     bool    `json:"shadow,omitempty"` // outcome was not enforced
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// DecisionLogConfig controls how decision records leave the gateway.
+type DecisionLogConfig struct {
+	// Log writes one JSON log line per request with every decision
+	Log bool
+
+	// Header sends BeforeRequest decisions upstream in DecisionHeader
+	Header bool
+}
+
+// Decide sets the reason recorded for the current plugin's decision.
+func (c *Context) Decide(reason string) {
+	c.reason = reason
+}
+
+// Decisions returns the plugin decisions recorded so far, in execution order.
+func (c *Context) Decisions() []Decision {
+	return c.decisions
+}
+
+// recordDecision appends the decision of a plugin that just executed.
+func (c *Context) recordDecision(pluginName string, started time.Time, aborted bool, status int, message string, err error, shadow bool) {
+	d := Decision{
+		Plugin:     pluginName,
+		Phase:      c.Phase,
+		Outcome:    DecisionContinue,
+		Reason:     c.reason,
+		Shadow:     shadow,
+		DurationMS: float64(time.Since(started).Microseconds()) / 1000,
+	}
+	switch {
+	case err != nil:
+		d.Outcome = DecisionError
+		d.Reason = err.Error()
+	case aborted:
+		d.Outcome = DecisionAbort
+		d.Status = status
+		if d.Reason == "" {
+			d.Reason = message
+		}
+	}
+	c.reason = ""
+	c.decisions = append(c.decisions, d)
+}
+
+// SetDecisionHeader writes the BeforeRequest decisions to DecisionHeader on
+// the upstream request, replacing any value sent by the client.
+func (c *Context) SetDecisionHeader() {
+	parts := make([]string, 0, len(c.decisions))
+	for _, d := range c.decisions {
+		if d.Phase != PhaseBeforeRequest {
+			continue
+		}
+		outcome := d.Outcome
+		if d.Shadow {
+			outcome = "shadow-" + outcome
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s;%gms", d.Plugin, outcome, d.DurationMS))
+	}
+
+	c.Request.Header.Del(DecisionHeader)
+	if len(parts) > 0 {
+		c.Request.Header.Set(DecisionHeader, strings.Join(parts, ", "))
+	}
+}
+
+// LogDecisions writes the request's decision record as one log line.
+func (c *Context) LogDecisions(requestID string) {
+	routeID, serviceID, workspace := "", "", ""
+	var routeTags, serviceTags []string
+	if c.Route != nil {
+		routeID = c.Route.ID
+		workspace = database.WorkspaceName(c.Route.Workspace)
+		routeTags = c.Route.Tags
+	}
+	if c.Service != nil {
+		serviceID = c.Service.ID
+		serviceTags = c.Service.Tags
+	}
+
+	log.Info().
+		Str("component", "decision_log").
+		Str("request_id", requestID).
+		Str("method", c.Request.Method).
+		Str("path", c.Request.URL.Path).
+		Str("workspace", workspace).
+		Str("route_id", routeID).
+		Str("service_id", serviceID).
+		Strs("tags", database.MergeTags(routeTags, serviceTags)).
+		Int("status_code", c.Response.StatusCode()).
+		Dur("elapsed_ms", c.Elapsed()).
+		Interface("decisions", c.decisions).
+		Msg("Plugin decisions")
+}
@@ -0,0 +1,240 @@
+// Package plugin - Typed context metadata keys
+//
+// Plugins pass data down the chain in the context metadata. Free-form
+// string keys let one plugin silently clobber another's value (two plugins
+// both using "user"), so keys are registered once, namespaced and typed:
+//
+//	var sessionKey = plugin.RegisterKey[*Session]("my-plugin.session")
+//	...
+//	sessionKey.Set(ctx, session)
+//	session, ok := sessionKey.Get(ctx)
+//
+// Names are "<namespace>.<name>" in lowercase, the namespace being the
+// plugin name (or one of the shared namespaces of the standard keys below).
+// Registering a name again with the same type returns the same key;
+// registering it with another type panics at startup instead of failing
+// at request time.
+//
+// Values written by other plugins are read through the standard keys
+// (KeyConsumerID, ...). Keys that predate namespacing keep their old flat
+// name as an alias: a value written under either name is visible under
+// both, so ctx.Get("consumer_id") and config options naming the old key
+// keep working.
+//
+// With SetMetadataDebug (PLUGIN_METADATA_DEBUG=true), every write is
+// checked and conflicts are logged and counted in
+// gateway_plugin_metadata_conflicts_total: a value of the wrong type for a
+// registered key, a key overwritten by a different plugin within the same
+// request, and writes to unregistered keys without a namespace.
+package plugin
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// Standard keys shared by plugins and the gateway.
+var (
+	// KeyConsumerID is the authenticated consumer, set by auth plugins and
+	// read by rate limiting, metering, usage and per-consumer policies.
+	KeyConsumerID = RegisterKeyWithAlias[string]("auth.consumer_id", "consumer_id")
+
+	// KeyConsumerUsername is the consumer's username, when known.
+	KeyConsumerUsername = RegisterKeyWithAlias[string]("auth.consumer_username", "consumer_username")
+
+	// KeyAuthMechanism is how the consumer authenticated (e.g. "api_key").
+	KeyAuthMechanism = RegisterKeyWithAlias[string]("auth.mechanism", "auth_mechanism")
+
+	// KeyTokenClaims holds the verified claims of the consumer's token.
+	KeyTokenClaims = RegisterKeyWithAlias[map[string]interface{}]("auth.token_claims", "token_claims")
+
+	// KeyRequestID is the request ID the request logger assigns.
+	KeyRequestID = RegisterKeyWithAlias[string]("request.id", "request_id")
+
+	// KeyTenantID is the resolved tenant of templated services.
+	KeyTenantID = RegisterKeyWithAlias[string]("tenant.id", "tenant_id")
+)
+
+// keyNamePattern is "<namespace>.<name>[.<name>...]".
+var keyNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*(\.[a-z][a-z0-9_-]*)+$`)
+
+// Key is a registered context metadata key holding values of type T.
+type Key[T any] struct {
+	name string
+}
+
+// keyInfo is the registration of a key.
+type keyInfo struct {
+	typ   reflect.Type
+	alias string
+}
+
+var (
+	keysMu     sync.RWMutex
+	keys       = make(map[string]keyInfo) // name -> registration
+	keyAliases = make(map[string]string)  // alias -> name
+)
+
+// RegisterKey registers a context metadata key. Register keys once, in
+// package variables, so conflicts surface at startup.
+//
+// Panics if name isn't namespaced ("<namespace>.<name>") or is already
+// registered with another type.
+func RegisterKey[T any](name string) Key[T] {
+	return RegisterKeyWithAlias[T](name, "")
+}
+
+// RegisterKeyWithAlias registers a key that replaces the flat key alias
+// used before namespacing. Values written under either name are visible
+// under both.
+//
+// Panics like RegisterKey, or if alias belongs to another key.
+func RegisterKeyWithAlias[T any](name, alias string) Key[T] {
+	if !keyNamePattern.MatchString(name) {
+		panic(fmt.Sprintf("plugin: invalid context key %q: want <namespace>.<name>", name))
+	}
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	if existing, ok := keys[name]; ok {
+		if existing.typ != typ || existing.alias != alias {
+			panic(fmt.Sprintf("plugin: context key %q already registered as %s", name, existing.typ))
+		}
+		return Key[T]{name: name}
+	}
+	if alias != "" {
+		if owner, ok := keyAliases[alias]; ok {
+			panic(fmt.Sprintf("plugin: context key alias %q already belongs to %q", alias, owner))
+		}
+		if _, ok := keys[alias]; ok {
+			panic(fmt.Sprintf("plugin: context key alias %q is a registered key", alias))
+		}
+		keyAliases[alias] = name
+	}
+	keys[name] = keyInfo{typ: typ, alias: alias}
+	return Key[T]{name: name}
+}
+
+// Name returns the key's metadata name.
+func (k Key[T]) Name() string {
+	return k.name
+}
+
+// Set stores value under the key.
+func (k Key[T]) Set(ctx *Context, value T) {
+	ctx.set(k.name, value)
+}
+
+// Get returns the key's value, and whether it is set with type T.
+func (k Key[T]) Get(ctx *Context) (T, bool) {
+	value, ok := ctx.Metadata[k.name].(T)
+	return value, ok
+}
+
+// Value returns the key's value, or the zero value of T if it isn't set.
+func (k Key[T]) Value(ctx *Context) T {
+	value, _ := k.Get(ctx)
+	return value
+}
+
+// Lookup returns the metadata value stored under name. It is meant for
+// key names that come from plugin configuration (e.g. a groups_key
+// option); plugins reading a key they know use its Key.
+func (c *Context) Lookup(name string) (interface{}, bool) {
+	value, ok := c.Metadata[name]
+	return value, ok
+}
+
+// metadataDebug enables conflict detection on metadata writes.
+var metadataDebug atomic.Bool
+
+var metadataConflicts = metrics.NewCounterVec(
+	"gateway_plugin_metadata_conflicts_total",
+	"Context metadata conflicts found with PLUGIN_METADATA_DEBUG, by key and kind (type, overwrite, unregistered).",
+	"key", "kind",
+)
+
+// SetMetadataDebug enables checking every context metadata write for
+// conflicts (see the package documentation). Off by default: it costs a
+// lookup and a map write per Set.
+func SetMetadataDebug(enabled bool) {
+	metadataDebug.Store(enabled)
+}
+
+// unregisteredWarned remembers the unregistered flat keys already logged.
+var unregisteredWarned sync.Map
+
+// set stores a metadata value, under the key's alias too if it has one.
+func (c *Context) set(name string, value interface{}) {
+	keysMu.RLock()
+	if canonical, ok := keyAliases[name]; ok {
+		name = canonical
+	}
+	info, registered := keys[name]
+	keysMu.RUnlock()
+
+	if metadataDebug.Load() {
+		c.checkMetadata(name, info, registered, value)
+	}
+
+	c.Metadata[name] = value
+	if info.alias != "" {
+		c.Metadata[info.alias] = value
+	}
+
+	log.Debug().
+		Str("component", "plugin_context").
+		Str("key", name).
+		Interface("value", value).
+		Msg("Context value set")
+}
+
+// checkMetadata logs and counts conflicts of a metadata write.
+func (c *Context) checkMetadata(name string, info keyInfo, registered bool, value interface{}) {
+	writer := c.plugin
+	if writer == "" {
+		writer = "gateway"
+	}
+
+	conflict := func(kind string) *zerolog.Event {
+		metadataConflicts.Inc(name, kind)
+		return log.Warn().
+			Str("component", "plugin_context").
+			Str("key", name).
+			Str("plugin", writer).
+			Str("kind", kind)
+	}
+
+	if registered && value != nil && !reflect.TypeOf(value).AssignableTo(info.typ) {
+		conflict("type").
+			Str("want", info.typ.String()).
+			Str("got", reflect.TypeOf(value).String()).
+			Msg("Context metadata value has the wrong type for its key")
+	}
+	if !registered && !keyNamePattern.MatchString(name) {
+		if _, warned := unregisteredWarned.LoadOrStore(name, true); !warned {
+			conflict("unregistered").
+				Msg("Context metadata key is not registered or namespaced - use plugin.RegisterKey")
+		}
+	}
+
+	if c.writers == nil {
+		c.writers = make(map[string]string)
+	}
+	if previous, ok := c.writers[name]; ok && previous != writer {
+		conflict("overwrite").
+			Str("previous_plugin", previous).
+			Msg("Context metadata key overwritten by another plugin")
+	}
+	c.writers[name] = writer
+}
@@ -0,0 +1,99 @@
+// Package plugin - Metrics for plugin authors
+//
+// Plugins record their own metrics (cache hit ratio, auth failures by
+// reason, ...) instead of only logging. Metrics are namespaced by plugin so
+// plugins can't collide with each other or with gateway metrics:
+//
+//	"cache_lookups_total" from "opaque-auth"
+//	    → gateway_plugin_opaque_auth_cache_lookups_total
+//
+// Create metrics once in the factory and keep them on the plugin:
+//
+//	m := plugin.NewMetrics("my-plugin")
+//	p.lookups = m.Counter("cache_lookups_total", "Cache lookups by result", "result")
+//	...
+//	p.lookups.Inc("hit")
+//
+// or look them up from the context while executing (registration is
+// idempotent, so this is safe on every request, just slower):
+//
+//	ctx.Metrics().Counter("denied_total", "Requests denied", "reason").Inc("expired")
+//
+// Everything is exposed on /metrics with the gateway's own metrics.
+package plugin
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// metricsNamespace prefixes every plugin metric name.
+const metricsNamespace = "gateway_plugin_"
+
+// Metrics creates metrics in one plugin's namespace.
+type Metrics struct {
+	prefix string
+}
+
+var (
+	pluginMetricsMu sync.Mutex
+	pluginMetrics   = make(map[string]*Metrics)
+)
+
+// NewMetrics returns the metrics namespace for a plugin.
+func NewMetrics(pluginName string) *Metrics {
+	pluginMetricsMu.Lock()
+	defer pluginMetricsMu.Unlock()
+
+	if m, ok := pluginMetrics[pluginName]; ok {
+		return m
+	}
+	m := &Metrics{prefix: metricsNamespace + sanitizeMetricName(pluginName) + "_"}
+	pluginMetrics[pluginName] = m
+	return m
+}
+
+// Name returns the full exposed name of a plugin metric.
+func (m *Metrics) Name(name string) string {
+	return m.prefix + sanitizeMetricName(name)
+}
+
+// Counter creates (or returns the existing) counter for this plugin.
+func (m *Metrics) Counter(name, help string, labels ...string) *metrics.CounterVec {
+	return metrics.NewCounterVec(m.Name(name), help, labels...)
+}
+
+// Gauge creates (or returns the existing) gauge for this plugin.
+func (m *Metrics) Gauge(name, help string, labels ...string) *metrics.GaugeVec {
+	return metrics.NewGaugeVec(m.Name(name), help, labels...)
+}
+
+// Histogram creates (or returns the existing) histogram for this plugin.
+// Nil buckets means metrics.DefaultBuckets (latencies in seconds).
+func (m *Metrics) Histogram(name, help string, buckets []float64, labels ...string) *metrics.HistogramVec {
+	return metrics.NewHistogramVec(m.Name(name), help, buckets, labels...)
+}
+
+// Metrics returns the metrics namespace of the plugin currently executing.
+func (c *Context) Metrics() *Metrics {
+	name := c.plugin
+	if name == "" {
+		name = "unknown"
+	}
+	return NewMetrics(name)
+}
+
+// sanitizeMetricName maps a name onto the Prometheus metric name charset,
+// e.g. "rate-limit" → "rate_limit".
+func sanitizeMetricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -51,6 +51,7 @@
 package plugin
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net/http"
@@ -101,7 +102,7 @@ const (
 //	        ctx.Abort(401, "Unauthorized")
 //	        return nil
 //	    }
-//	    ctx.Set("user_id", "123") // Pass data to next plugins
+//	    plugin.KeyConsumerID.Set(ctx, "123") // Pass data to next plugins
 //	    return nil
 //	}
 type Plugin interface {
@@ -115,11 +116,19 @@ type Plugin interface {
 	// The plugin can:
 	//   - Read/modify ctx.Request (in BeforeRequest phase)
 	//   - Read/modify ctx.Response (in AfterResponse phase)
-	//   - Store data in ctx.Metadata for other plugins
+	//   - Store data in the context metadata for other plugins (see Key)
 	//   - Call ctx.Abort() to stop the chain
 	Execute(ctx *Context) error
 }
 
+// HealthChecker is implemented by plugins that depend on an external
+// backend (e.g., Redis). The gateway's /health endpoint reports whether
+// the backend is reachable.
+type HealthChecker interface {
+	// CheckHealth returns an error if the backend is unreachable.
+	CheckHealth(ctx context.Context) error
+}
+
 // Context holds all data available to plugins during execution.
 //
 // This is the primary way plugins interact with the gateway and each other.
@@ -144,12 +153,17 @@ type Context struct {
 	// StartTime is when the request started processing.
 	StartTime time.Time
 
-	// Metadata is a map for plugins to communicate with each other.
+	// Metadata is a map for plugins to communicate with each other,
+	// written and read through registered keys (see Key).
 	// Example:
-	//   ctx.Set("user_id", "123")
-	//   userID := ctx.Get("user_id").(string)
+	//   plugin.KeyConsumerID.Set(ctx, "123")
+	//   consumerID := plugin.KeyConsumerID.Value(ctx)
 	Metadata map[string]interface{}
 
+	// writers records which plugin last wrote each metadata key (only
+	// with SetMetadataDebug)
+	writers map[string]string
+
 	// aborted indicates if the chain should stop.
 	aborted bool
 
@@ -159,6 +173,19 @@ type Context struct {
 	// abortMessage is the error message if aborted.
 	abortMessage string
 
+	// plugin is the name of the plugin currently executing (see Metrics)
+	plugin string
+
+	// decisions records each plugin execution; reason is the pending
+	// reason for the current one (see Decide)
+	decisions []Decision
+	reason    string
+
+	// flushed is set once the response is flushed for async plugins;
+	// finished freezes Elapsed for an async plugin's snapshot (see async)
+	flushed  bool
+	finished time.Time
+
 	// Context for cancellation and timeouts
 	ctx context.Context
 }
@@ -175,6 +202,12 @@ type ResponseWriter struct {
 	written     bool
 	bodySize    int
 	headersSent bool
+
+	// Body capture (see EnableCapture)
+	capture         bool
+	captureLimit    int
+	captured        bytes.Buffer
+	captureOverflow bool
 }
 
 // NewResponseWriter creates a new ResponseWriter wrapper.
@@ -189,7 +222,16 @@ func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
 }
 
 // WriteHeader captures the status code and writes it.
+//
+// Informational (1xx) responses other than 101 Switching Protocols are
+// passed through without being recorded; the final status follows them.
 func (w *ResponseWriter) WriteHeader(statusCode int) {
+	if statusCode >= 100 && statusCode < 200 && statusCode != http.StatusSwitchingProtocols {
+		if !w.written {
+			w.ResponseWriter.WriteHeader(statusCode)
+		}
+		return
+	}
 	if w.written {
 		log.Warn().
 			Str("component", "response_writer").
@@ -211,9 +253,42 @@ func (w *ResponseWriter) Write(b []byte) (int, error) {
 
 	n, err := w.ResponseWriter.Write(b)
 	w.bodySize += n
+
+	if w.capture && !w.captureOverflow {
+		if w.captured.Len()+n > w.captureLimit {
+			w.captureOverflow = true
+			w.captured.Reset()
+		} else {
+			w.captured.Write(b[:n])
+		}
+	}
+
 	return n, err
 }
 
+// EnableCapture records a copy of the response body as it is written,
+// up to limit bytes. Plugins that need the body in the AfterResponse
+// phase (e.g., idempotency, caching) call this in BeforeRequest.
+//
+// The body is still streamed to the client; capture only keeps a copy.
+func (w *ResponseWriter) EnableCapture(limit int) {
+	w.capture = true
+	if limit > w.captureLimit {
+		w.captureLimit = limit
+	}
+}
+
+// CapturedBody returns the captured response body.
+//
+// complete is false if capture was not enabled or the body exceeded
+// the capture limit (in which case body is nil).
+func (w *ResponseWriter) CapturedBody() (body []byte, complete bool) {
+	if !w.capture || w.captureOverflow {
+		return nil, false
+	}
+	return w.captured.Bytes(), true
+}
+
 // StatusCode returns the HTTP status code that was written.
 func (w *ResponseWriter) StatusCode() int {
 	return w.statusCode
@@ -257,14 +332,11 @@ func NewContext(
 //
 //	ctx.Set("user_id", "123")
 //	ctx.Set("rate_limit_remaining", 99)
+//
+// Deprecated: free-form keys can clobber other plugins' values. Use a key
+// registered with RegisterKey and its Set method.
 func (c *Context) Set(key string, value interface{}) {
-	c.Metadata[key] = value
-
-	log.Debug().
-		Str("component", "plugin_context").
-		Str("key", key).
-		Interface("value", value).
-		Msg("Context value set")
+	c.set(key, value)
 }
 
 // Get retrieves a value from the context metadata.
@@ -275,14 +347,18 @@ func (c *Context) Set(key string, value interface{}) {
 //	if exists {
 //	    id := userID.(string)
 //	}
+//
+// Deprecated: use a registered Key's Get method, or Lookup for key names
+// from plugin configuration.
 func (c *Context) Get(key string) (interface{}, bool) {
-	value, exists := c.Metadata[key]
-	return value, exists
+	return c.Lookup(key)
 }
 
 // GetString is a type-safe helper to get a string value.
 //
 // Returns empty string if key doesn't exist or value is not a string.
+//
+// Deprecated: use a Key[string] and its Value method.
 func (c *Context) GetString(key string) string {
 	if value, exists := c.Metadata[key]; exists {
 		if str, ok := value.(string); ok {
@@ -295,6 +371,8 @@ func (c *Context) GetString(key string) string {
 // GetInt is a type-safe helper to get an int value.
 //
 // Returns 0 if key doesn't exist or value is not an int.
+//
+// Deprecated: use a Key[int] and its Value method.
 func (c *Context) GetInt(key string) int {
 	if value, exists := c.Metadata[key]; exists {
 		if num, ok := value.(int); ok {
@@ -307,6 +385,8 @@ func (c *Context) GetInt(key string) int {
 // GetBool is a type-safe helper to get a bool value.
 //
 // Returns false if key doesn't exist or value is not a bool.
+//
+// Deprecated: use a Key[bool] and its Value method.
 func (c *Context) GetBool(key string) bool {
 	if value, exists := c.Metadata[key]; exists {
 		if b, ok := value.(bool); ok {
@@ -361,8 +441,21 @@ func (c *Context) Context() context.Context {
 	return c.ctx
 }
 
-// Elapsed returns the time elapsed since request started.
+// DetachDeadline drops the request deadline (and cancellation) from the
+// contexts plugins see, keeping their values. The gateway calls it once
+// the response is sent, so AfterResponse plugins (logging, metering) can
+// still do their I/O after a request timed out.
+func (c *Context) DetachDeadline() {
+	c.ctx = context.WithoutCancel(c.ctx)
+	c.Request = c.Request.WithContext(context.WithoutCancel(c.Request.Context()))
+}
+
+// Elapsed returns the time elapsed since request started (for async
+// plugins, until their job was queued).
 func (c *Context) Elapsed() time.Duration {
+	if !c.finished.IsZero() {
+		return c.finished.Sub(c.StartTime)
+	}
 	return time.Since(c.StartTime)
 }
 
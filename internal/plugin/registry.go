@@ -17,15 +17,25 @@
 //
 //	instances, err := registry.LoadFromDatabase(ctx, repo)
 //	// Returns configured plugin instances ready to use
+//
+// Plugins holding resources (open directories, connection pools) implement
+// io.Closer. Instances replaced by a reload are closed after retireDelay,
+// once requests still running on them have finished; instances that are
+// built but never installed are closed right away (see CloseInstances).
 package plugin
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/featureflag"
+	"github.com/saidutt46/switchboard-gateway/internal/notify"
 )
 
 // PluginFactory is a function that creates a new plugin instance.
@@ -44,6 +54,15 @@ import (
 //	}
 type PluginFactory func(config json.RawMessage) (Plugin, error)
 
+// retireDelay is how long replaced plugin instances stay open, so requests
+// still running on the previous config can finish.
+var retireDelay = time.Minute
+
+// ErrPluginUnavailable is wrapped by factories that refuse to build a plugin
+// because of the gateway's environment (e.g. fault injection in production)
+// rather than a bad config. Such plugins are skipped, not treated as invalid.
+var ErrPluginUnavailable = errors.New("plugin unavailable in this environment")
+
 // Registry manages plugin registration and instantiation.
 type Registry struct {
 	// factories maps plugin names to their factory functions
@@ -51,6 +70,15 @@ type Registry struct {
 
 	// instances holds all loaded plugin instances
 	instances []PluginInstance
+
+	// notifier receives plugin.load_failed events (nil = disabled)
+	notifier *notify.Dispatcher
+
+	// flags evaluates "enabled_flag" (nil = plugins can't be gated)
+	flags featureflag.Provider
+
+	// async runs "async" plugins (nil = async plugins can't be loaded)
+	async *AsyncPool
 }
 
 // NewRegistry creates a new plugin registry.
@@ -61,6 +89,23 @@ func NewRegistry() *Registry {
 	}
 }
 
+// SetNotifier sends a plugin.load_failed event to d for every plugin row
+// that fails to build.
+func (r *Registry) SetNotifier(d *notify.Dispatcher) {
+	r.notifier = d
+}
+
+// SetFlags evaluates the "enabled_flag" of plugin instances with p.
+func (r *Registry) SetFlags(p featureflag.Provider) {
+	r.flags = p
+}
+
+// SetAsyncPool runs the AfterResponse phase of "async" plugin instances on
+// pool.
+func (r *Registry) SetAsyncPool(pool *AsyncPool) {
+	r.async = pool
+}
+
 // Register registers a plugin factory function.
 //
 // The name must match the plugin name in the database.
@@ -111,7 +156,7 @@ func (r *Registry) GetRegisteredPlugins() []string {
 //  4. Returns plugin instances ready for chain execution
 //
 // Plugins without registered factories are skipped with a warning.
-func (r *Registry) LoadFromDatabase(ctx context.Context, repo *database.Repository) ([]PluginInstance, error) {
+func (r *Registry) LoadFromDatabase(ctx context.Context, repo database.ConfigStore) ([]PluginInstance, error) {
 	log.Info().
 		Str("component", "plugin_registry").
 		Msg("Loading plugins from database")
@@ -147,6 +192,7 @@ func (r *Registry) LoadFromDatabase(ctx context.Context, repo *database.Reposito
 				Str("plugin", config.Name).
 				Str("plugin_id", config.ID).
 				Msg("Failed to create plugin instance - skipping")
+			r.publishLoadFailed(config, err)
 			continue
 		}
 
@@ -162,7 +208,7 @@ func (r *Registry) LoadFromDatabase(ctx context.Context, repo *database.Reposito
 	}
 
 	// Store instances
-	r.instances = instances
+	r.SetInstances(instances)
 
 	log.Info().
 		Str("component", "plugin_registry").
@@ -215,50 +261,108 @@ func (r *Registry) createInstance(config *database.Plugin) (PluginInstance, erro
 			Msg("Plugin name mismatch")
 	}
 
-	// Parse critical flag from config JSON
-	critical := r.parseCriticalFlag(configJSON)
+	// Parse critical flag, mode, feature flag and async settings from config JSON
+	flags, err := r.parseInstanceFlags(configJSON)
+	if err != nil {
+		return PluginInstance{}, err
+	}
 
 	// Create plugin instance
 	instance := PluginInstance{
-		Plugin:   plugin,
-		Config:   config,
-		Scope:    config.Scope,
-		Priority: config.Priority,
-		Critical: critical,
+		Plugin:          plugin,
+		Config:          config,
+		Scope:           config.Scope,
+		Priority:        config.Priority,
+		Critical:        flags.Critical,
+		Mode:            flags.Mode,
+		EnabledFlag:     flags.EnabledFlag,
+		Async:           flags.Async,
+		AsyncQueueSize:  flags.AsyncQueueSize,
+		AsyncDropPolicy: flags.AsyncDropPolicy,
+		flags:           r.flags,
+		async:           r.async,
 	}
 
 	// Validate instance
 	if err := r.validateInstance(instance); err != nil {
+		closePlugin(plugin)
 		return PluginInstance{}, fmt.Errorf("plugin validation failed: %w", err)
 	}
 
 	return instance, nil
 }
 
-// parseCriticalFlag extracts the "critical" flag from plugin config JSON.
+// instanceFlags are the gateway-level settings in a plugin's config JSON.
+type instanceFlags struct {
+	Critical        bool   `json:"critical"`
+	Mode            string `json:"mode"`
+	EnabledFlag     string `json:"enabled_flag"`
+	Async           bool   `json:"async"`
+	AsyncQueueSize  int    `json:"async_queue_size"`
+	AsyncDropPolicy string `json:"async_drop_policy"`
+}
+
+// parseInstanceFlags extracts the gateway-level flags from plugin config JSON.
 //
 // Config example:
 //
 //	{
 //	  "critical": true,
+//	  "mode": "shadow",
+//	  "enabled_flag": "new-rate-limiter",
+//	  "async": false,
 //	  "api_key": "secret"
 //	}
 //
 // If "critical" is not specified, defaults to false (non-critical).
-func (r *Registry) parseCriticalFlag(configJSON json.RawMessage) bool {
-	var config struct {
-		Critical bool `json:"critical"`
-	}
+// If "mode" is not specified, defaults to ModeEnforce.
+// If "enabled_flag" is not specified, the plugin always runs.
+// If "async" is not specified, the AfterResponse phase runs in the request.
+func (r *Registry) parseInstanceFlags(configJSON json.RawMessage) (instanceFlags, error) {
+	var config instanceFlags
 
 	if err := json.Unmarshal(configJSON, &config); err != nil {
 		log.Debug().
 			Err(err).
 			Str("component", "plugin_registry").
-			Msg("Failed to parse critical flag - defaulting to false")
-		return false
+			Msg("Failed to parse instance flags - using defaults")
+		return instanceFlags{Mode: ModeEnforce}, nil
+	}
+
+	switch config.Mode {
+	case "":
+		config.Mode = ModeEnforce
+	case ModeEnforce, ModeShadow:
+	default:
+		return instanceFlags{}, fmt.Errorf("invalid mode '%s' (must be %s or %s)", config.Mode, ModeEnforce, ModeShadow)
 	}
 
-	return config.Critical
+	if config.EnabledFlag != "" && r.flags == nil {
+		return instanceFlags{}, fmt.Errorf("enabled_flag '%s' requires a feature flag provider (see FEATURE_FLAGS_PROVIDER)", config.EnabledFlag)
+	}
+
+	switch config.AsyncDropPolicy {
+	case "":
+		config.AsyncDropPolicy = DropNewest
+	case DropNewest, DropOldest:
+	default:
+		return instanceFlags{}, fmt.Errorf("invalid async_drop_policy '%s' (must be %s or %s)", config.AsyncDropPolicy, DropNewest, DropOldest)
+	}
+	if config.AsyncQueueSize < 0 {
+		return instanceFlags{}, fmt.Errorf("async_queue_size cannot be negative")
+	}
+	if config.Async {
+		switch {
+		case r.async == nil:
+			return instanceFlags{}, fmt.Errorf("async plugins require PLUGIN_ASYNC_WORKERS > 0")
+		case config.Critical:
+			return instanceFlags{}, fmt.Errorf("async plugins cannot be critical: they run after the response is sent")
+		case config.Mode == ModeShadow:
+			return instanceFlags{}, fmt.Errorf("async plugins cannot run in shadow mode")
+		}
+	}
+
+	return config, nil
 }
 
 // validateInstance validates a plugin instance configuration.
@@ -273,6 +377,7 @@ func (r *Registry) validateInstance(instance PluginInstance) error {
 		database.PluginScopeGlobal,
 		database.PluginScopeService,
 		database.PluginScopeRoute,
+		database.PluginScopeGroup,
 		database.PluginScopeConsumer,
 	}
 
@@ -309,6 +414,11 @@ func (r *Registry) validateInstance(instance PluginInstance) error {
 			return fmt.Errorf("route-scoped plugin must have a route_id")
 		}
 
+	case database.PluginScopeGroup:
+		if !instance.Config.GroupID.Valid {
+			return fmt.Errorf("group-scoped plugin must have a group_id")
+		}
+
 	case database.PluginScopeConsumer:
 		if !instance.Config.ConsumerID.Valid {
 			return fmt.Errorf("consumer-scoped plugin must have a consumer_id")
@@ -346,6 +456,7 @@ func (r *Registry) Stats() map[string]interface{} {
 	globalCount := 0
 	serviceCount := 0
 	routeCount := 0
+	groupCount := 0
 	consumerCount := 0
 	criticalCount := 0
 
@@ -357,6 +468,8 @@ func (r *Registry) Stats() map[string]interface{} {
 			serviceCount++
 		case database.PluginScopeRoute:
 			routeCount++
+		case database.PluginScopeGroup:
+			groupCount++
 		case database.PluginScopeConsumer:
 			consumerCount++
 		}
@@ -372,6 +485,7 @@ func (r *Registry) Stats() map[string]interface{} {
 		"global_plugins":       globalCount,
 		"service_plugins":      serviceCount,
 		"route_plugins":        routeCount,
+		"group_plugins":        groupCount,
 		"consumer_plugins":     consumerCount,
 		"critical_plugins":     criticalCount,
 	}
@@ -379,23 +493,27 @@ func (r *Registry) Stats() map[string]interface{} {
 
 // Reload reloads all plugins from the database.
 //
-// This clears existing instances and loads fresh configurations.
-// Used during hot reload when plugin configurations change.
-func (r *Registry) Reload(ctx context.Context, repo *database.Repository) error {
+// Fresh instances are built first; if the load fails the current
+// instances are kept. Plugins that fail to build are skipped (see Build
+// for the strict variant used by validated hot reloads).
+func (r *Registry) Reload(ctx context.Context, repo database.ConfigStore) error {
 	log.Info().
 		Str("component", "plugin_registry").
 		Msg("Reloading plugins from database")
 
-	// Clear existing instances
-	r.instances = make([]PluginInstance, 0)
-
-	// Load fresh instances
-	instances, err := r.LoadFromDatabase(ctx, repo)
+	instances, buildErrors, err := r.Build(ctx, repo)
 	if err != nil {
 		return fmt.Errorf("failed to reload plugins: %w", err)
 	}
 
-	r.instances = instances
+	for _, buildErr := range buildErrors {
+		log.Error().
+			Err(buildErr).
+			Str("component", "plugin_registry").
+			Msg("Failed to create plugin instance - skipping")
+	}
+
+	r.SetInstances(instances)
 
 	log.Info().
 		Str("component", "plugin_registry").
@@ -405,8 +523,77 @@ func (r *Registry) Reload(ctx context.Context, repo *database.Repository) error
 	return nil
 }
 
+// Build creates instances for all enabled plugins without replacing the
+// registry's current instances.
+//
+// Returns the instances that built successfully and one error per plugin
+// row that did not (unknown name, invalid config). Plugins whose factory
+// reports ErrPluginUnavailable are skipped without an error. The caller
+// decides whether build errors reject the whole set, then installs it
+// with SetInstances.
+func (r *Registry) Build(ctx context.Context, repo database.ConfigStore) ([]PluginInstance, []error, error) {
+	pluginConfigs, err := repo.GetPlugins(ctx, true) // true = enabled only
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query plugins: %w", err)
+	}
+
+	instances := make([]PluginInstance, 0, len(pluginConfigs))
+	var buildErrors []error
+
+	for _, config := range pluginConfigs {
+		instance, err := r.createInstance(config)
+		if err != nil {
+			if errors.Is(err, ErrPluginUnavailable) {
+				log.Warn().
+					Err(err).
+					Str("component", "plugin_registry").
+					Str("plugin", config.Name).
+					Str("plugin_id", config.ID).
+					Msg("Plugin unavailable in this environment - skipping")
+				continue
+			}
+			buildErrors = append(buildErrors, fmt.Errorf("plugin %s (%s): %w", config.Name, config.ID, err))
+			r.publishLoadFailed(config, err)
+			continue
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, buildErrors, nil
+}
+
+// publishLoadFailed reports a plugin that failed to build. Plugins that
+// are merely unavailable in this environment are not reported.
+func (r *Registry) publishLoadFailed(config *database.Plugin, err error) {
+	if errors.Is(err, ErrPluginUnavailable) {
+		return
+	}
+
+	r.notifier.Publish(notify.Event{
+		Type:     notify.EventPluginLoadFailed,
+		Severity: notify.SeverityCritical,
+		Message:  fmt.Sprintf("Plugin %s failed to load", config.Name),
+		Data: map[string]interface{}{
+			"plugin":    config.Name,
+			"plugin_id": config.ID,
+			"scope":     config.Scope,
+			"error":     err.Error(),
+		},
+	})
+}
+
+// SetInstances replaces the loaded plugin instances.
+//
+// The instances it replaces are closed after retireDelay.
+func (r *Registry) SetInstances(instances []PluginInstance) {
+	retired := r.instances
+	r.instances = instances
+	r.retire(retired)
+}
+
 // Clear removes all plugin instances (keeps factories registered).
 func (r *Registry) Clear() {
+	r.retire(r.instances)
 	r.instances = make([]PluginInstance, 0)
 
 	log.Debug().
@@ -429,10 +616,15 @@ func (r *Registry) ValidatePluginConfig(pluginName string, configJSON json.RawMe
 	}
 
 	// Try to create instance with the config
-	_, err := factory(configJSON)
+	plugin, err := factory(configJSON)
 	if err != nil {
 		return fmt.Errorf("invalid plugin configuration: %w", err)
 	}
+	closePlugin(plugin)
+
+	if _, err := r.parseInstanceFlags(configJSON); err != nil {
+		return fmt.Errorf("invalid plugin configuration: %w", err)
+	}
 
 	log.Debug().
 		Str("component", "plugin_registry").
@@ -441,3 +633,37 @@ func (r *Registry) ValidatePluginConfig(pluginName string, configJSON json.RawMe
 
 	return nil
 }
+
+// retire closes instances after retireDelay, when requests that started
+// on them have finished.
+func (r *Registry) retire(instances []PluginInstance) {
+	if len(instances) == 0 {
+		return
+	}
+	time.AfterFunc(retireDelay, func() { CloseInstances(instances) })
+}
+
+// CloseInstances closes the plugins of instances that implement io.Closer.
+//
+// Use it for instances that were built but never served (a rejected
+// reload, a config check); the registry closes the ones it replaces.
+func CloseInstances(instances []PluginInstance) {
+	for _, instance := range instances {
+		closePlugin(instance.Plugin)
+	}
+}
+
+// closePlugin releases p's resources if it holds any. Errors are logged.
+func closePlugin(p Plugin) {
+	closer, ok := p.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		log.Warn().
+			Err(err).
+			Str("component", "plugin_registry").
+			Str("plugin", p.Name()).
+			Msg("Failed to close plugin instance")
+	}
+}
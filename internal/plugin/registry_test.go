@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// closingPlugin counts Close calls.
+type closingPlugin struct {
+	closed atomic.Int32
+}
+
+func (p *closingPlugin) Name() string               { return "closing" }
+func (p *closingPlugin) Execute(ctx *Context) error { return nil }
+func (p *closingPlugin) Close() error               { p.closed.Add(1); return nil }
+
+func TestRegistry_ClosesReplacedInstances(t *testing.T) {
+	defer func(d time.Duration) { retireDelay = d }(retireDelay)
+	retireDelay = 10 * time.Millisecond
+
+	r := NewRegistry()
+	first, second := &closingPlugin{}, &closingPlugin{}
+	r.SetInstances([]PluginInstance{{Plugin: first}})
+	r.SetInstances([]PluginInstance{{Plugin: second}})
+
+	// Requests may still be running on the replaced instance
+	if first.closed.Load() != 0 {
+		t.Fatal("replaced instance closed before retireDelay")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for first.closed.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("replaced instance never closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if second.closed.Load() != 0 {
+		t.Error("installed instance closed")
+	}
+
+	// Built but never installed: closed at once
+	unused := &closingPlugin{}
+	CloseInstances([]PluginInstance{{Plugin: unused}, {Plugin: nil}})
+	if unused.closed.Load() != 1 {
+		t.Errorf("CloseInstances() closed the plugin %d times, want 1", unused.closed.Load())
+	}
+}
+
+func TestRegistry_ValidateClosesPlugin(t *testing.T) {
+	r := NewRegistry()
+	built := &closingPlugin{}
+	r.Register("closing", func(json.RawMessage) (Plugin, error) { return built, nil })
+
+	if err := r.ValidatePluginConfig("closing", json.RawMessage(`{}`)); err != nil {
+		t.Fatalf("ValidatePluginConfig() error = %v", err)
+	}
+	if built.closed.Load() != 1 {
+		t.Errorf("validation instance closed %d times, want 1", built.closed.Load())
+	}
+}
@@ -0,0 +1,146 @@
+// Package plugin - Shadow (dry-run) mode for plugin instances
+//
+// Any plugin instance can be configured with "mode": "shadow":
+//
+//	{
+//	  "mode": "shadow",
+//	  "requests_per_minute": 10
+//	}
+//
+// A shadow plugin runs on live traffic and makes its decision as usual,
+// but the decision is only recorded, never enforced:
+//   - ctx.Abort() is undone; the request continues
+//   - Errors are recorded and never fail the request, even if critical
+//   - Anything the plugin writes to the response (headers, body) is
+//     discarded, and changes it makes to the request are rolled back
+//
+// Decisions are counted in gateway_plugin_shadow_decisions_total and, for
+// would-be aborts, reported to the client in the X-Gateway-Shadow header
+// ("rate-limit=429"), so new policies can be trialed on production
+// traffic before they are enforced. Metadata set via ctx.Set is kept.
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// Plugin instance modes (config key "mode").
+const (
+	ModeEnforce = "enforce"
+	ModeShadow  = "shadow"
+)
+
+// ShadowHeader reports the decisions shadow plugins would have enforced.
+const ShadowHeader = "X-Gateway-Shadow"
+
+// Shadow decisions.
+const (
+	shadowAllow = "allow"
+	shadowAbort = "would_abort"
+	shadowError = "error"
+)
+
+var shadowDecisions = metrics.NewCounterVec(
+	"gateway_plugin_shadow_decisions_total",
+	"Decisions made by plugins running in shadow mode",
+	"plugin", "route", "decision",
+)
+
+// executeShadow runs a shadow-mode plugin against a sandboxed response
+// and request, then records (but does not apply) its decision.
+func (c *Chain) executeShadow(instance PluginInstance, ctx *Context) {
+	pluginName := instance.Plugin.Name()
+
+	realResponse := ctx.Response
+	realRequest := ctx.Request
+
+	ctx.Response = realResponse.shadowCopy()
+	ctx.Request = realRequest.Clone(realRequest.Context())
+
+	shadowResponse := ctx.Response
+	started := time.Now()
+	ctx.plugin = pluginName
+	err := instance.Plugin.Execute(ctx)
+	ctx.plugin = ""
+
+	// Body capture only keeps a copy, so let it through for AfterResponse
+	if shadowResponse.capture && !realResponse.capture {
+		realResponse.EnableCapture(shadowResponse.captureLimit)
+	}
+
+	aborted, status, message := ctx.aborted, ctx.abortStatusCode, ctx.abortMessage
+	ctx.aborted, ctx.abortStatusCode, ctx.abortMessage = false, 0, ""
+	ctx.recordDecision(pluginName, started, aborted, status, message, err, true)
+	// Keep the body if the plugin consumed and replaced it (e.g. to hash it)
+	if ctx.Request.Body != realRequest.Body {
+		realRequest.Body = ctx.Request.Body
+		realRequest.GetBody = ctx.Request.GetBody
+	}
+	ctx.Response = realResponse
+	ctx.Request = realRequest
+
+	decision := shadowAllow
+	switch {
+	case err != nil:
+		decision = shadowError
+	case aborted:
+		decision = shadowAbort
+	}
+
+	routeID := ""
+	if ctx.Route != nil {
+		routeID = ctx.Route.ID
+	}
+	shadowDecisions.Inc(pluginName, routeID, decision)
+
+	if decision == shadowAllow {
+		return
+	}
+
+	if aborted && !realResponse.Written() {
+		realResponse.Header().Add(ShadowHeader, fmt.Sprintf("%s=%d", pluginName, status))
+	}
+
+	log.Info().
+		Err(err).
+		Str("component", "plugin_chain").
+		Str("plugin", pluginName).
+		Str("phase", string(ctx.Phase)).
+		Str("route_id", routeID).
+		Str("decision", decision).
+		Int("status_code", status).
+		Str("message", message).
+		Msg("Shadow plugin decision not enforced")
+}
+
+// shadowCopy returns a ResponseWriter that reports the same state as w
+// (status, size, captured body) but discards anything written to it.
+func (w *ResponseWriter) shadowCopy() *ResponseWriter {
+	shadow := &ResponseWriter{
+		ResponseWriter:  discardWriter{header: w.Header().Clone()},
+		statusCode:      w.statusCode,
+		written:         w.written,
+		bodySize:        w.bodySize,
+		headersSent:     w.headersSent,
+		capture:         w.capture,
+		captureLimit:    w.captureLimit,
+		captureOverflow: w.captureOverflow,
+	}
+	shadow.captured.Write(w.captured.Bytes())
+	return shadow
+}
+
+// discardWriter is an http.ResponseWriter that drops everything.
+type discardWriter struct {
+	header http.Header
+}
+
+func (d discardWriter) Header() http.Header         { return d.header }
+func (d discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d discardWriter) WriteHeader(int)             {}
@@ -0,0 +1,148 @@
+// Package plugin - Upstream request and response hooks
+//
+// Plugins run before the proxy picks a target, so they cannot see the
+// final upstream URL or Host. Plugins that need them (e.g. request
+// signing) register an UpstreamHook instead; the proxy calls it on the
+// outgoing request after setting the URL, Host and forwarding headers,
+// right before sending it.
+//
+// Likewise the AfterResponse phase runs once the response has been sent.
+// Plugins that must check the upstream response before the client sees it
+// (e.g. contract validation) register a ResponseHook, which the proxy calls
+// before copying any headers or body.
+//
+// When the upstream request fails without a response (timeout, connection
+// error), the proxy asks each ErrorHook for a substitute (e.g. a stale
+// cached copy) before answering 504 or 502.
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// UpstreamHook modifies the outgoing upstream request. Returning an error
+// fails the request: a *ResponseError chooses the status and body sent
+// instead, any other error fails it with 502 Bad Gateway.
+type UpstreamHook func(req *http.Request) error
+
+// upstreamHooksKey is the request context key for registered hooks.
+type upstreamHooksKey struct{}
+
+// AddUpstreamHook registers hook to run on the upstream request.
+//
+// Hooks run in registration order (i.e. plugin execution order). The hook
+// is attached to ctx.Request's context, so the request passed on to the
+// proxy must be ctx.Request.
+func (c *Context) AddUpstreamHook(hook UpstreamHook) {
+	hooks := append(UpstreamHooks(c.Request), hook)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), upstreamHooksKey{}, hooks))
+}
+
+// UpstreamHooks returns the hooks registered for a request.
+func UpstreamHooks(r *http.Request) []UpstreamHook {
+	hooks, _ := r.Context().Value(upstreamHooksKey{}).([]UpstreamHook)
+	// Copy so appends never share a backing array between requests
+	return append([]UpstreamHook(nil), hooks...)
+}
+
+// ResponseHook inspects the upstream response before it is sent to the
+// client. Returning an error discards the response: a *ResponseError
+// chooses the status and body sent instead, any other error fails the
+// request with 502 Bad Gateway.
+type ResponseHook func(resp *http.Response) error
+
+// ResponseError replaces a rejected upstream response, or the response to
+// a request an UpstreamHook refused to send.
+type ResponseError struct {
+	// StatusCode and Body are sent to the client instead
+	StatusCode int
+	Body       string
+
+	// Reason says why the response was rejected (logged, not sent)
+	Reason string
+}
+
+// Error implements error.
+func (e *ResponseError) Error() string {
+	return e.Reason
+}
+
+// responseHooksKey is the request context key for registered hooks.
+type responseHooksKey struct{}
+
+// AddResponseHook registers hook to run on the upstream response.
+//
+// Like AddUpstreamHook, the hook is attached to ctx.Request's context.
+func (c *Context) AddResponseHook(hook ResponseHook) {
+	hooks := append(ResponseHooks(c.Request), hook)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), responseHooksKey{}, hooks))
+}
+
+// ResponseHooks returns the response hooks registered for a request.
+func ResponseHooks(r *http.Request) []ResponseHook {
+	hooks, _ := r.Context().Value(responseHooksKey{}).([]ResponseHook)
+	return append([]ResponseHook(nil), hooks...)
+}
+
+// ErrorHook returns a response to send instead of failing a request whose
+// upstream request got no response, or nil to let it fail. err is the
+// upstream error.
+type ErrorHook func(err error) *http.Response
+
+// errorHooksKey is the request context key for registered hooks.
+type errorHooksKey struct{}
+
+// AddErrorHook registers hook to run if the upstream request fails.
+//
+// Like AddUpstreamHook, the hook is attached to ctx.Request's context.
+func (c *Context) AddErrorHook(hook ErrorHook) {
+	hooks := append(ErrorHooks(c.Request), hook)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), errorHooksKey{}, hooks))
+}
+
+// ErrorHooks returns the error hooks registered for a request.
+func ErrorHooks(r *http.Request) []ErrorHook {
+	hooks, _ := r.Context().Value(errorHooksKey{}).([]ErrorHook)
+	return append([]ErrorHook(nil), hooks...)
+}
+
+// timingKey is the request context key for timing requests.
+type timingKey struct{}
+
+// RequestTiming asks the proxy to add an X-Timing header with the upstream
+// request's latency breakdown to the response.
+func (c *Context) RequestTiming() {
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), timingKey{}, true))
+}
+
+// TimingRequested reports whether a plugin called RequestTiming.
+func TimingRequested(r *http.Request) bool {
+	requested, _ := r.Context().Value(timingKey{}).(bool)
+	return requested
+}
+
+// HedgePolicy configures hedged requests for a route: if the upstream
+// hasn't responded within Percentile of the route's recent latencies
+// (clamped to MinDelay..MaxDelay), the proxy sends the request to a second
+// target and uses whichever responds first.
+type HedgePolicy struct {
+	Percentile float64
+	MinDelay   time.Duration
+	MaxDelay   time.Duration
+}
+
+// hedgePolicyKey is the request context key for the hedge policy.
+type hedgePolicyKey struct{}
+
+// Hedge asks the proxy to hedge the upstream request according to policy.
+func (c *Context) Hedge(policy HedgePolicy) {
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), hedgePolicyKey{}, policy))
+}
+
+// HedgePolicyFor returns the hedge policy a plugin set for r, if any.
+func HedgePolicyFor(r *http.Request) (HedgePolicy, bool) {
+	policy, ok := r.Context().Value(hedgePolicyKey{}).(HedgePolicy)
+	return policy, ok
+}
@@ -0,0 +1,379 @@
+// Package promote turns exported topologies into environment templates and
+// renders them back, so the same route topology can be promoted between
+// environments (staging → production) that differ only in backends and
+// limits.
+//
+// A template is an exported database.Topology in which environment
+// specific values are ${VAR} placeholders:
+//
+//	{
+//	  "name": "orders",
+//	  "host": "${SERVICE_ORDERS_HOST}",
+//	  "port": ${SERVICE_ORDERS_PORT},
+//	  ...
+//	}
+//
+// Placeholders may appear anywhere in the document. Inside a JSON string
+// the value is escaped as string content; elsewhere it is inserted as is
+// (so numbers and booleans stay numbers and booleans). ${VAR:-default}
+// falls back to default when VAR is unset, and $${ is a literal ${.
+//
+// Templates are JSON, which YAML parsers read as well, so they can be kept
+// next to YAML configuration (e.g. staging.yaml) and edited as such as
+// long as they stay JSON.
+package promote
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// variableName matches valid placeholder names.
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Render substitutes the ${VAR} placeholders of a template with values
+// from lookup.
+//
+// Returns an error naming every variable that is unset and has no default.
+func Render(template []byte, lookup func(name string) (string, bool)) ([]byte, error) {
+	var out bytes.Buffer
+	out.Grow(len(template))
+
+	inString, escaped := false, false
+	missing := make(map[string]bool)
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+
+		if c == '$' && i+2 < len(template) && template[i+1] == '$' && template[i+2] == '{' {
+			out.WriteString("${")
+			i += 2
+			continue
+		}
+		if c == '$' && i+1 < len(template) && template[i+1] == '{' {
+			end := bytes.IndexByte(template[i+2:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated placeholder at offset %d", i)
+			}
+			expr := string(template[i+2 : i+2+end])
+			i += 2 + end
+
+			name, def, hasDefault := strings.Cut(expr, ":-")
+			if !variableName.MatchString(name) {
+				return nil, fmt.Errorf("invalid placeholder ${%s}", expr)
+			}
+			value, ok := lookup(name)
+			if !ok {
+				if !hasDefault {
+					missing[name] = true
+					continue
+				}
+				value = def
+			}
+			if inString {
+				encoded, _ := json.Marshal(value)
+				value = string(encoded[1 : len(encoded)-1])
+			}
+			out.WriteString(value)
+			continue
+		}
+
+		// Track whether we're inside a JSON string
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		}
+		out.WriteByte(c)
+	}
+
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("undefined variables: %s", strings.Join(names, ", "))
+	}
+	return out.Bytes(), nil
+}
+
+// Load renders a template and decodes the topology it describes.
+func Load(template []byte, lookup func(name string) (string, bool)) (*database.Topology, error) {
+	rendered, err := Render(template, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(rendered))
+	decoder.DisallowUnknownFields()
+	var topology database.Topology
+	if err := decoder.Decode(&topology); err != nil {
+		return nil, fmt.Errorf("invalid topology (templates are JSON): %w", err)
+	}
+	topology.SetDefaults()
+	if err := topology.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid topology: %w", err)
+	}
+	return &topology, nil
+}
+
+// Parameterize returns a topology as a template whose environment specific
+// values are placeholders, and the current values of those variables (an
+// env file for the source environment):
+//   - service hosts, ports and targets
+//   - route hosts
+//   - top-level plugin config fields holding limits ("limit", "max_*",
+//     "*_limit") or addresses ("host", "url", "*_host", "*_url")
+func Parameterize(topology *database.Topology) ([]byte, map[string]string, error) {
+	encoded, err := json.Marshal(topology)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode topology: %w", err)
+	}
+	// Exported values containing ${ must not render as placeholders
+	encoded = bytes.ReplaceAll(encoded, []byte("${"), []byte("$${"))
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	value, err := decodeOrdered(decoder)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode topology: %w", err)
+	}
+	doc := value.(*object)
+
+	p := &parameterizer{vars: make(map[string]string), numeric: make(map[string]bool)}
+	for _, s := range objects(doc.get("services")) {
+		prefix := "SERVICE_" + identifier(s.get("name"))
+		p.replace(s, "host", prefix+"_HOST")
+		p.replace(s, "port", prefix+"_PORT")
+		for i, t := range objects(s.get("targets")) {
+			p.replace(t, "target", fmt.Sprintf("%s_TARGET_%d", prefix, i+1))
+		}
+	}
+	for _, rt := range objects(doc.get("routes")) {
+		hosts, _ := rt.get("hosts").([]interface{})
+		for i := range hosts {
+			hosts[i] = p.placeholder(fmt.Sprintf("ROUTE_%s_HOST_%d", identifier(rt.get("name")), i+1), hosts[i])
+		}
+	}
+	for _, pl := range objects(doc.get("plugins")) {
+		prefix := "PLUGIN_" + identifier(pl.get("name"))
+		for _, scope := range []string{"service", "group", "route"} {
+			if target, ok := pl.get(scope).(string); ok && target != "" {
+				prefix += "_" + identifier(scope) + "_" + identifier(target)
+			}
+		}
+		if config, ok := pl.get("config").(*object); ok {
+			for _, key := range config.keys {
+				if environmentSpecific(key, config.get(key)) {
+					p.replace(config, key, prefix+"_"+identifier(key))
+				}
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode template: %w", err)
+	}
+
+	// Numbers are inserted unquoted, so they render back as numbers
+	template := out.Bytes()
+	for name := range p.numeric {
+		template = bytes.ReplaceAll(template, []byte(`"${`+name+`}"`), []byte(`${`+name+`}`))
+	}
+	return template, p.vars, nil
+}
+
+// FormatEnv formats variables as an env file, sorted by name.
+func FormatEnv(vars map[string]string) []byte {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&out, "%s=%s\n", name, quoteEnv(vars[name]))
+	}
+	return out.Bytes()
+}
+
+// quoteEnv double-quotes values godotenv wouldn't read back verbatim.
+func quoteEnv(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\"'#$\\`=\n") {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "$", `\$`)
+	return `"` + r.Replace(value) + `"`
+}
+
+// parameterizer collects the variables of a template.
+type parameterizer struct {
+	vars    map[string]string
+	numeric map[string]bool
+}
+
+// replace swaps obj[key] for a placeholder, if it is a string or number.
+func (p *parameterizer) replace(obj *object, key, name string) {
+	if value, ok := obj.values[key]; ok {
+		obj.values[key] = p.placeholder(name, value)
+	}
+}
+
+// placeholder registers a variable holding value and returns its
+// placeholder. Values other than strings and numbers are kept.
+func (p *parameterizer) placeholder(name string, value interface{}) interface{} {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = strings.ReplaceAll(v, "$${", "${")
+	case json.Number:
+		text = v.String()
+	default:
+		return value
+	}
+
+	// Names derived from different entities may collide
+	unique := name
+	for n := 2; ; n++ {
+		if _, taken := p.vars[unique]; !taken {
+			break
+		}
+		unique = fmt.Sprintf("%s_%d", name, n)
+	}
+
+	p.vars[unique] = text
+	if _, ok := value.(json.Number); ok {
+		p.numeric[unique] = true
+	}
+	return "${" + unique + "}"
+}
+
+// environmentSpecific reports whether a plugin config field is a limit or
+// an address.
+func environmentSpecific(key string, value interface{}) bool {
+	key = strings.ToLower(key)
+	switch value.(type) {
+	case json.Number:
+		return key == "limit" || strings.HasPrefix(key, "max_") || strings.HasSuffix(key, "_limit")
+	case string:
+		return key == "host" || key == "url" || strings.HasSuffix(key, "_host") || strings.HasSuffix(key, "_url")
+	}
+	return false
+}
+
+// object is a JSON object that keeps its key order, so templates read
+// like the topology they were exported from.
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// get returns the value of a key, or nil.
+func (o *object) get(key string) interface{} {
+	return o.values[key]
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o *object) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+
+	out.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		if err := encoder.Encode(key); err != nil {
+			return nil, err
+		}
+		out.WriteByte(':')
+		if err := encoder.Encode(o.values[key]); err != nil {
+			return nil, err
+		}
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// decodeOrdered decodes the next JSON value, with objects as *object.
+func decodeOrdered(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		obj := &object{values: make(map[string]interface{})}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			obj.keys = append(obj.keys, key.(string))
+			obj.values[key.(string)] = value
+		}
+		_, err := decoder.Token() // }
+		return obj, err
+	case json.Delim('['):
+		items := []interface{}{}
+		for decoder.More() {
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		_, err := decoder.Token() // ]
+		return items, err
+	}
+	return token, nil
+}
+
+// objects returns the JSON objects of an array value.
+func objects(value interface{}) []*object {
+	items, _ := value.([]interface{})
+	out := make([]*object, 0, len(items))
+	for _, item := range items {
+		if obj, ok := item.(*object); ok {
+			out = append(out, obj)
+		}
+	}
+	return out
+}
+
+// identifier upper-cases a name and replaces characters not allowed in
+// variable names with underscores.
+func identifier(value interface{}) string {
+	name, _ := value.(string)
+	var b strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package promote
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joho/godotenv"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+func lookupMap(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func testTopology() *database.Topology {
+	return &database.Topology{
+		Version:   database.TopologyVersion,
+		Workspace: "default",
+		Services: []database.TopologyService{{
+			Name: "orders", Protocol: "http", Host: "orders.staging.internal", Port: 8080,
+			ConnectTimeoutMs: 5000, ReadTimeoutMs: 30000, WriteTimeoutMs: 30000, Retries: 1,
+			LoadBalancerType: "round-robin", HashOn: "ip", HashBalanceFactor: 1.25, IPFamily: "default", Enabled: true,
+			Targets: []database.TopologyTarget{{Target: "10.0.0.1:8080", Weight: 100, HealthCheckPath: "/health", Enabled: true}},
+		}},
+		Routes: []database.TopologyRoute{{
+			Name: "orders-api", Service: "orders", Hosts: []string{"api.staging.example.com"},
+			Paths: []string{"/orders"}, Methods: []string{"GET"}, PriorityClass: "normal", Enabled: true,
+		}},
+		Plugins: []database.TopologyPlugin{{
+			Name: "rate-limit", Scope: "route", Route: "orders-api", Enabled: true, Priority: 10,
+			Config: map[string]interface{}{"limit": 100, "window": "1m", "redis_url": "redis://staging:6379", "note": "a ${literal}"},
+		}},
+	}
+}
+
+func TestRender_Placeholders(t *testing.T) {
+	template := []byte(`{"host": "${HOST}", "port": ${PORT}, "path": "${PATH_PREFIX:-/v1}", "raw": "$${KEEP}"}`)
+	rendered, err := Render(template, lookupMap(map[string]string{"HOST": `a"b`, "PORT": "8080"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"host": "a\"b", "port": 8080, "path": "/v1", "raw": "${KEEP}"}`
+	if string(rendered) != want {
+		t.Errorf("Render() = %s, want %s", rendered, want)
+	}
+}
+
+func TestRender_MissingVariables(t *testing.T) {
+	_, err := Render([]byte(`{"a": "${B}", "c": ${A}, "d": "${B}"}`), lookupMap(nil))
+	if err == nil || !strings.Contains(err.Error(), "undefined variables: A, B") {
+		t.Errorf("Render() error = %v, want both variables named", err)
+	}
+
+	if _, err := Render([]byte(`"${bad-name}"`), lookupMap(nil)); err == nil {
+		t.Error("expected an invalid placeholder name to be rejected")
+	}
+	if _, err := Render([]byte(`"${OPEN`), lookupMap(nil)); err == nil {
+		t.Error("expected an unterminated placeholder to be rejected")
+	}
+}
+
+func TestParameterize_RoundTrip(t *testing.T) {
+	topology := testTopology()
+	template, vars, err := Parameterize(topology)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, placeholder := range []string{
+		`"${SERVICE_ORDERS_HOST}"`,
+		`"port": ${SERVICE_ORDERS_PORT}`,
+		`"${SERVICE_ORDERS_TARGET_1}"`,
+		`"${ROUTE_ORDERS_API_HOST_1}"`,
+		`"limit": ${PLUGIN_RATE_LIMIT_ROUTE_ORDERS_API_LIMIT}`,
+		`"${PLUGIN_RATE_LIMIT_ROUTE_ORDERS_API_REDIS_URL}"`,
+		`"a $${literal}"`,
+	} {
+		if !strings.Contains(string(template), placeholder) {
+			t.Errorf("template lacks %s:\n%s", placeholder, template)
+		}
+	}
+	if vars["SERVICE_ORDERS_PORT"] != "8080" || vars["ROUTE_ORDERS_API_HOST_1"] != "api.staging.example.com" {
+		t.Errorf("unexpected variables: %v", vars)
+	}
+
+	// The env file written for the source environment renders it back
+	env, err := godotenv.Unmarshal(string(FormatEnv(vars)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(template, lookupMap(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.Plugins[0].Config["limit"] = 100 // decoded as float64
+	if !reflect.DeepEqual(loaded, topology) {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", loaded, topology)
+	}
+
+	// Another environment swaps backends and limits
+	env["SERVICE_ORDERS_HOST"] = "orders.prod.internal"
+	env["PLUGIN_RATE_LIMIT_ROUTE_ORDERS_API_LIMIT"] = "1000"
+	prod, err := Load(template, lookupMap(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prod.Services[0].Host != "orders.prod.internal" || prod.Plugins[0].Config["limit"] != float64(1000) {
+		t.Errorf("unexpected production topology: %+v", prod)
+	}
+}
+
+func TestLoad_RejectsInvalidTopology(t *testing.T) {
+	template := []byte(`{"version": 1, "workspace": "default", "services": [], "routes": [
+		{"name": "r", "service": "missing", "paths": ["/"], "methods": ["GET"]}]}`)
+	if _, err := Load(template, lookupMap(nil)); err == nil || !strings.Contains(err.Error(), "unknown service") {
+		t.Errorf("Load() error = %v, want unknown service", err)
+	}
+
+	if _, err := Load([]byte(`{"version": 1, "servics": []}`), lookupMap(nil)); err == nil {
+		t.Error("expected unknown fields to be rejected")
+	}
+}
+
+func TestFormatEnv_Quoting(t *testing.T) {
+	vars := map[string]string{"A": "plain", "B": `has "quotes" and $dollar`, "C": "", "D": "x=y # z"}
+	env, err := godotenv.Unmarshal(string(FormatEnv(vars)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(env, vars) {
+		t.Errorf("godotenv read back %v, want %v", env, vars)
+	}
+}
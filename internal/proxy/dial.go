@@ -0,0 +1,275 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/dnscache"
+)
+
+// IP families for upstream connections (UPSTREAM_IP_FAMILY and the
+// services' ip_family column).
+const (
+	// IPFamilyDefault on a service uses the gateway's UPSTREAM_IP_FAMILY
+	IPFamilyDefault = "default"
+
+	// IPFamilyAuto dials addresses in the resolver's order, racing the
+	// other family after the Happy Eyeballs delay
+	IPFamilyAuto = "auto"
+
+	// IPFamilyIPv4 and IPFamilyIPv6 only dial that family
+	IPFamilyIPv4 = "ipv4"
+	IPFamilyIPv6 = "ipv6"
+
+	// IPFamilyPreferIPv4 and IPFamilyPreferIPv6 dial that family first,
+	// falling back to the other
+	IPFamilyPreferIPv4 = "prefer_ipv4"
+	IPFamilyPreferIPv6 = "prefer_ipv6"
+)
+
+// ValidIPFamily reports whether family is a known gateway IP family.
+func ValidIPFamily(family string) bool {
+	switch family {
+	case IPFamilyAuto, IPFamilyIPv4, IPFamilyIPv6, IPFamilyPreferIPv4, IPFamilyPreferIPv6:
+		return true
+	}
+	return false
+}
+
+// ipFamilyKey carries a service's IP family override to the dialer.
+type ipFamilyKey struct{}
+
+// withIPFamily returns ctx asking the dialer for family, unless it's the
+// gateway default.
+func withIPFamily(ctx context.Context, family string) context.Context {
+	if family == "" || family == IPFamilyDefault {
+		return ctx
+	}
+	return context.WithValue(ctx, ipFamilyKey{}, family)
+}
+
+// upstreamDialer opens upstream connections: it resolves the host
+// (through the DNS cache when configured), orders the addresses by IP
+// family, and races the second family after a delay (RFC 6555 Happy
+// Eyeballs), so a backend with broken AAAA records costs the delay, not a
+// dial timeout.
+type upstreamDialer struct {
+	family string
+	delay  time.Duration // 0 = try families one after the other
+	cache  *dnscache.Resolver
+	next   atomic.Uint32 // rotates the first address dialed
+}
+
+// dial connects to address with d. Its signature matches
+// egress.DialFunc, so the egress policy can wrap it.
+func (u *upstreamDialer) dial(ctx context.Context, d *net.Dialer, network, address string) (net.Conn, error) {
+	family := u.family
+	if override, ok := ctx.Value(ipFamilyKey{}).(string); ok {
+		family = override
+	}
+	switch network {
+	case "tcp4", "udp4":
+		family = IPFamilyIPv4
+	case "tcp6", "udp6":
+		family = IPFamilyIPv6
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a cache or a preference the standard dialer does the same
+	if u.cache == nil && (family == IPFamilyAuto || family == IPFamilyIPv4 || family == IPFamilyIPv6) {
+		dialer := *d
+		dialer.FallbackDelay = u.fallbackDelay()
+		return dialer.DialContext(ctx, familyNetwork(network, family), address)
+	}
+
+	ips, err := u.lookup(ctx, host)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+	primaries, fallbacks := u.order(ips, family)
+	if len(primaries) == 0 {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: fmt.Errorf("no %s address for %s", family, host)}
+	}
+	return u.race(ctx, d, network, port, primaries, fallbacks)
+}
+
+// fallbackDelay is the net.Dialer setting for the Happy Eyeballs delay.
+func (u *upstreamDialer) fallbackDelay() time.Duration {
+	if u.delay <= 0 {
+		return -1 // no racing
+	}
+	return u.delay
+}
+
+// familyNetwork restricts network to family's addresses.
+func familyNetwork(network, family string) string {
+	if network != "tcp" && network != "udp" {
+		return network
+	}
+	switch family {
+	case IPFamilyIPv4:
+		return network + "4"
+	case IPFamilyIPv6:
+		return network + "6"
+	}
+	return network
+}
+
+// lookup resolves host through the cache, or the system resolver.
+func (u *upstreamDialer) lookup(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	if u.cache != nil {
+		return u.cache.LookupIP(ctx, host)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+	return ips, nil
+}
+
+// order splits ips into the family dialed first and the fallback family,
+// each rotated so connections spread over all of a host's addresses.
+func (u *upstreamDialer) order(ips []net.IP, family string) (primaries, fallbacks []net.IP) {
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	n := int(u.next.Add(1))
+	v4, v6 = rotate(v4, n), rotate(v6, n)
+
+	switch family {
+	case IPFamilyIPv4:
+		return v4, nil
+	case IPFamilyIPv6:
+		return v6, nil
+	case IPFamilyPreferIPv4:
+		if len(v4) == 0 {
+			return v6, nil
+		}
+		return v4, v6
+	case IPFamilyPreferIPv6:
+		if len(v6) == 0 {
+			return v4, nil
+		}
+		return v6, v4
+	}
+	// auto: the resolver's first address picks the family
+	if len(ips) > 0 && ips[0].To4() == nil {
+		return v6, v4
+	}
+	if len(v4) == 0 {
+		return v6, nil
+	}
+	return v4, v6
+}
+
+// race dials the primaries one after another and, if none has connected
+// after the Happy Eyeballs delay (or they all failed), the fallbacks
+// alongside. The first connection wins; the other attempt is cancelled.
+func (u *upstreamDialer) race(ctx context.Context, d *net.Dialer, network, port string, primaries, fallbacks []net.IP) (net.Conn, error) {
+	if len(fallbacks) == 0 || u.delay <= 0 {
+		return dialSerial(ctx, d, network, port, append(primaries, fallbacks...))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, 2)
+	start := func(ips []net.IP) {
+		go func() {
+			conn, err := dialSerial(ctx, d, network, port, ips)
+			results <- result{conn, err}
+		}()
+	}
+
+	start(primaries)
+	pending, fellBack := 1, false
+	timer := time.NewTimer(u.delay)
+	defer timer.Stop()
+
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if !fellBack {
+				start(fallbacks)
+				pending, fellBack = pending+1, true
+			}
+
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				// Close the loser if it connects before it's cancelled
+				go func(pending int) {
+					for i := 0; i < pending; i++ {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}
+				}(pending)
+				return res.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			if !fellBack {
+				start(fallbacks)
+				pending, fellBack = pending+1, true
+				continue
+			}
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// dialSerial dials ips in order, returning the first connection or the
+// first error.
+func dialSerial(ctx context.Context, d *net.Dialer, network, port string, ips []net.IP) (net.Conn, error) {
+	var firstErr error
+	for _, ip := range ips {
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// rotate returns ips starting at position n.
+func rotate(ips []net.IP, n int) []net.IP {
+	if len(ips) < 2 {
+		return ips
+	}
+	n %= len(ips)
+	return append(append([]net.IP(nil), ips[n:]...), ips[:n]...)
+}
@@ -0,0 +1,134 @@
+// Package proxy - Forwarding header hygiene
+//
+// On top of X-Forwarded-*, the proxy:
+//   - Appends "Via: <version> switchboard" to upstream requests and client
+//     responses (RFC 9110 section 7.6.3)
+//   - Generates the standard Forwarded header (RFC 7239) alongside
+//     X-Forwarded-For/-Proto/-Host
+//   - Drops hop-by-hop headers in both directions, including any header
+//     named in the message's Connection header
+//   - Extends the incoming X-Forwarded-For / Forwarded chain only when the
+//     peer is a trusted proxy, and replaces it with the peer otherwise, so
+//     clients cannot inject forwarding information
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/saidutt46/switchboard-gateway/internal/clientip"
+)
+
+// HeaderConfig controls the forwarding headers added by the proxy.
+type HeaderConfig struct {
+	// Via is the pseudonym in Via headers ("1.1 switchboard").
+	// Empty disables Via.
+	Via string
+
+	// Forwarded adds an RFC 7239 Forwarded header to upstream requests.
+	Forwarded bool
+
+	// TrustedProxies decides whose X-Forwarded-For and Forwarded chains
+	// are kept (and used for the client IP). nil trusts no one.
+	TrustedProxies *clientip.Resolver
+}
+
+// DefaultHeaderConfig returns the default forwarding header settings.
+func DefaultHeaderConfig() HeaderConfig {
+	return HeaderConfig{
+		Via:       "switchboard",
+		Forwarded: true,
+	}
+}
+
+// SetHeaderConfig configures forwarding headers. Must be called before
+// the proxy serves traffic.
+func (p *Proxy) SetHeaderConfig(cfg HeaderConfig) {
+	p.headers = cfg
+}
+
+// clientIP returns the client IP, honoring forwarded headers only from
+// trusted proxies.
+func (p *Proxy) clientIP(r *http.Request) string {
+	return p.headers.TrustedProxies.ClientIP(r)
+}
+
+// connectionHeaders returns the header names listed in h's Connection
+// header(s), canonicalized. These are hop-by-hop for this message only.
+func connectionHeaders(h http.Header) map[string]bool {
+	var listed map[string]bool
+	for _, value := range h.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if listed == nil {
+				listed = make(map[string]bool)
+			}
+			listed[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	return listed
+}
+
+// appendVia adds this proxy to the Via header of h for a message received
+// with protocol major.minor.
+func (p *Proxy) appendVia(h http.Header, major, minor int) {
+	if p.headers.Via == "" {
+		return
+	}
+	h.Add("Via", viaProtocol(major, minor)+" "+p.headers.Via)
+}
+
+// viaProtocol formats the received protocol version for Via ("1.1", "2").
+func viaProtocol(major, minor int) string {
+	if major == 0 {
+		return "1.1"
+	}
+	if major >= 2 && minor == 0 {
+		return fmt.Sprintf("%d", major)
+	}
+	return fmt.Sprintf("%d.%d", major, minor)
+}
+
+// forwardedElement builds one RFC 7239 forwarded-element.
+func forwardedElement(clientIP, host, proto string) string {
+	params := make([]string, 0, 3)
+	if clientIP != "" {
+		params = append(params, "for="+forwardedNode(clientIP))
+	}
+	if host != "" {
+		params = append(params, "host="+forwardedValue(host))
+	}
+	params = append(params, "proto="+proto)
+	return strings.Join(params, ";")
+}
+
+// forwardedNode formats an IP as a Forwarded node: IPv6 addresses are
+// bracketed and quoted.
+func forwardedNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return `"[` + ip + `]"`
+	}
+	return ip
+}
+
+// forwardedValue quotes a value unless it is a plain token.
+func forwardedValue(v string) string {
+	for _, c := range v {
+		if !isTokenChar(c) {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+		}
+	}
+	return v
+}
+
+// isTokenChar reports whether c may appear in an RFC 7230 token.
+func isTokenChar(c rune) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", c)
+}
@@ -0,0 +1,377 @@
+package proxy
+
+// Hedged requests
+//
+// On routes with the hedging plugin, idempotent requests (GET and HEAD
+// without a body) whose upstream hasn't responded within a percentile of
+// the route's recent latencies are sent again to a second target. The
+// first successful response is used and the other attempt is cancelled:
+//
+//	t=0      GET -> target A
+//	t=p95    no response yet: GET -> target B (if the budget allows)
+//	t=p95+x  B responds first: B's response is sent, A is cancelled
+//
+// Hedges are capped by a retry budget shared by all routes, so a slow
+// backend can't turn hedging into a retry storm that doubles its load.
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+	"github.com/saidutt46/switchboard-gateway/internal/tenant"
+)
+
+// hedgesTotal counts hedge decisions by route and result: "won" and
+// "lost" (a hedge was sent and did or didn't answer first), "budget" (the
+// budget was exhausted) and "no_target" (no second target to send to).
+var hedgesTotal = metrics.NewCounterVec(
+	"gateway_upstream_hedges_total",
+	"Hedged upstream requests, by route and result (won, lost, budget, no_target).",
+	"route", "result",
+)
+
+const (
+	// hedgeWindowSize is how many recent latencies each route keeps
+	hedgeWindowSize = 256
+
+	// hedgeMinSamples is how many latencies a route needs before it hedges
+	hedgeMinSamples = 20
+
+	// hedgeResort re-sorts a route's latencies every this many samples
+	hedgeResort = 16
+)
+
+// RetryBudgetConfig bounds hedged requests across the gateway.
+type RetryBudgetConfig struct {
+	// Ratio of hedge-eligible requests that may be hedged (0.1 = 10%)
+	Ratio float64
+
+	// MinPerSecond hedges are always allowed, so low-traffic routes can
+	// hedge at all
+	MinPerSecond float64
+}
+
+// DefaultRetryBudgetConfig returns the budget used unless configured.
+func DefaultRetryBudgetConfig() RetryBudgetConfig {
+	return RetryBudgetConfig{Ratio: 0.1, MinPerSecond: 1}
+}
+
+// SetRetryBudget replaces the hedge budget. Must be called before serving.
+func (p *Proxy) SetRetryBudget(cfg RetryBudgetConfig) {
+	p.budget = newRetryBudget(cfg)
+}
+
+// retryBudget is a token bucket: every eligible request deposits Ratio
+// tokens, MinPerSecond tokens accrue per second, and every hedge
+// withdraws one. It starts with one second's worth of tokens.
+type retryBudget struct {
+	cfg      RetryBudgetConfig
+	capacity float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRetryBudget(cfg RetryBudgetConfig) *retryBudget {
+	return &retryBudget{
+		cfg:      cfg,
+		capacity: math.Max(10, cfg.MinPerSecond),
+		tokens:   cfg.MinPerSecond,
+		last:     time.Now(),
+	}
+}
+
+// deposit credits one eligible request.
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.tokens = math.Min(b.capacity, b.tokens+b.cfg.Ratio)
+}
+
+// withdraw takes a token for a hedge, reporting whether one was available.
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *retryBudget) refill() {
+	now := time.Now()
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.cfg.MinPerSecond)
+	b.last = now
+}
+
+// latencyWindow holds a route's recent upstream latencies.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples [hedgeWindowSize]time.Duration
+	count   int // samples recorded, capped at hedgeWindowSize
+	next    int // ring position of the next sample
+	sorted  []time.Duration
+	stale   int // samples recorded since sorted was built
+}
+
+func (w *latencyWindow) record(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % hedgeWindowSize
+	if w.count < hedgeWindowSize {
+		w.count++
+	}
+	w.stale++
+}
+
+// percentile returns the p-th percentile of the window, or false while
+// there are too few samples.
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.count < hedgeMinSamples {
+		return 0, false
+	}
+	if w.sorted == nil || w.stale >= hedgeResort {
+		w.sorted = append(w.sorted[:0], w.samples[:w.count]...)
+		sort.Slice(w.sorted, func(i, j int) bool { return w.sorted[i] < w.sorted[j] })
+		w.stale = 0
+	}
+	i := int(math.Ceil(p*float64(len(w.sorted)))) - 1
+	return w.sorted[max(i, 0)], true
+}
+
+// latencyWindows holds a latencyWindow per route.
+type latencyWindows struct {
+	mu     sync.Mutex
+	routes map[string]*latencyWindow
+}
+
+func (l *latencyWindows) get(routeID string) *latencyWindow {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.routes == nil {
+		l.routes = make(map[string]*latencyWindow)
+	}
+	w, ok := l.routes[routeID]
+	if !ok {
+		w = &latencyWindow{}
+		l.routes[routeID] = w
+	}
+	return w
+}
+
+// hedgePolicy returns r's hedge policy if r may be hedged: a plugin asked
+// for it and the request is idempotent and has no body to send twice.
+func hedgePolicy(r *http.Request) (plugin.HedgePolicy, bool) {
+	policy, ok := plugin.HedgePolicyFor(r)
+	if !ok || (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.ContentLength != 0 {
+		return policy, false
+	}
+	return policy, true
+}
+
+// hedgeResult is the outcome of one attempt of a hedged request.
+type hedgeResult struct {
+	attempt *upstreamAttempt
+	resp    *http.Response
+	err     error
+}
+
+// roundTripHedged sends primary and, if it's slow, a hedge to a second
+// target. It returns the first successful response and the attempt that
+// produced it, or the first error if every attempt failed.
+//
+// A primary that fails before the hedge delay isn't hedged: hedging cuts
+// tail latency, it doesn't retry errors.
+func (p *Proxy) roundTripHedged(r *http.Request, primary *upstreamAttempt, match *router.MatchResult, requestID string, policy plugin.HedgePolicy) (*http.Response, *upstreamAttempt, error) {
+	start := time.Now()
+	window := p.hedgeLatency.get(match.Route.ID)
+	p.budget.deposit()
+
+	results := make(chan hedgeResult, 2)
+	cancels := make(map[*upstreamAttempt]context.CancelFunc, 2)
+	launch := func(a *upstreamAttempt, finish func()) {
+		ctx, cancel := context.WithCancel(r.Context())
+		cancels[a] = cancel
+		done := func() {
+			cancel()
+			if finish != nil {
+				finish()
+			}
+		}
+		go func() {
+			resp, err := p.roundTrip(ctx, r, a, match, requestID)
+			if err != nil {
+				done()
+			} else {
+				resp.Body = &attemptBody{ReadCloser: resp.Body, done: done}
+			}
+			results <- hedgeResult{attempt: a, resp: resp, err: err}
+		}()
+	}
+	launch(primary, nil)
+	pending := 1
+
+	var timer <-chan time.Time
+	if delay, ok := hedgeDelay(window, policy); ok {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	var hedge *upstreamAttempt
+	var failed *hedgeResult
+	for pending > 0 {
+		select {
+		case <-timer:
+			timer = nil
+			var release func()
+			hedge, release = p.hedgeAttempt(r, primary, match)
+			if hedge == nil {
+				break
+			}
+			launch(hedge, release)
+			pending++
+
+			log.Debug().
+				Str("component", "proxy").
+				Str("request_id", requestID).
+				Str("route_id", match.Route.ID).
+				Str("hedge_url", hedge.url).
+				Dur("after_ms", time.Since(start)).
+				Msg("Sent hedged request")
+
+		case res := <-results:
+			pending--
+			if res.err != nil {
+				if failed == nil {
+					failed = &res
+				}
+				timer = nil
+				continue
+			}
+
+			// First response wins; cancel the other attempt and close
+			// its response if it still arrives
+			for a, cancel := range cancels {
+				if a != res.attempt {
+					cancel()
+				}
+			}
+			go abandon(results, pending)
+
+			window.record(time.Since(start))
+			if hedge != nil {
+				result := "lost"
+				if res.attempt == hedge {
+					result = "won"
+				}
+				hedgesTotal.Inc(match.Route.ID, result)
+			}
+			return res.resp, res.attempt, nil
+		}
+	}
+	return nil, failed.attempt, failed.err
+}
+
+// abandon closes the responses of pending attempts that lost, releasing
+// their targets.
+func abandon(results <-chan hedgeResult, pending int) {
+	for i := 0; i < pending; i++ {
+		if res := <-results; res.resp != nil {
+			res.resp.Body.Close()
+		}
+	}
+}
+
+// hedgeDelay returns how long to wait before hedging, or false if the
+// route has too few latencies to tell.
+func hedgeDelay(window *latencyWindow, policy plugin.HedgePolicy) (time.Duration, bool) {
+	delay, ok := window.percentile(policy.Percentile)
+	if !ok {
+		return 0, false
+	}
+	if delay < policy.MinDelay {
+		delay = policy.MinDelay
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	return delay, true
+}
+
+// hedgeAttempt picks a second target for a hedge, if the budget allows
+// and the service's balancer offers one other than primary's. The returned
+// func releases the target.
+func (p *Proxy) hedgeAttempt(r *http.Request, primary *upstreamAttempt, match *router.MatchResult) (*upstreamAttempt, func()) {
+	routeID := match.Route.ID
+	if primary.target == nil || p.balancers == nil {
+		hedgesTotal.Inc(routeID, "no_target")
+		return nil, nil
+	}
+	lb, ok := p.balancers.Get(match.Service.ID)
+	if !ok {
+		hedgesTotal.Inc(routeID, "no_target")
+		return nil, nil
+	}
+
+	target, err := lb.Next(r, match.PathParams)
+	if err != nil {
+		hedgesTotal.Inc(routeID, "no_target")
+		return nil, nil
+	}
+	release := func() { lb.Done(target) }
+	if target == primary.target {
+		release()
+		hedgesTotal.Inc(routeID, "no_target")
+		return nil, nil
+	}
+
+	if !p.budget.withdraw() {
+		release()
+		hedgesTotal.Inc(routeID, "budget")
+		return nil, nil
+	}
+
+	targetURL, err := tenant.Expand(buildTargetURL(match.Service, target.Address), tenant.FromContext(r.Context()))
+	if err != nil {
+		release()
+		hedgesTotal.Inc(routeID, "no_target")
+		return nil, nil
+	}
+
+	return &upstreamAttempt{
+		target:    target,
+		url:       p.buildUpstreamURL(targetURL, r, match),
+		transport: p.transportFor(match.Service, target),
+	}, release
+}
+
+// attemptBody runs done once the response body of an attempt is closed.
+type attemptBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *attemptBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
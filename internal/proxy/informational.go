@@ -0,0 +1,116 @@
+// Package proxy - Informational responses and Expect: 100-continue
+//
+// Expect: 100-continue is honored end to end. The upstream request carries
+// the Expect header only when the client sent it with a body, so the
+// transport holds the body back until the upstream answers 100 Continue
+// (or UPSTREAM_EXPECT_CONTINUE_TIMEOUT passes). The client's own
+// 100 Continue is sent when the body is first read, i.e. once the upstream
+// (or a gateway plugin that inspects the body) asked for it. An upstream
+// that rejects the request outright (401, 413, 417) answers a client that
+// never had to upload the body.
+//
+// Other informational responses from the upstream - 103 Early Hints,
+// 102 Processing - are relayed to the client as they arrive, ahead of the
+// final response, with hop-by-hop headers removed. They are not relayed to
+// HTTP/1.0 clients, which can't receive them, or for hedged requests,
+// where two upstreams may answer.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// maxInformational is the most informational responses relayed for one
+// request; an upstream sending more fails the request.
+const maxInformational = 5
+
+var informationalTotal = metrics.NewCounterVec(
+	"gateway_upstream_informational_responses_total",
+	"Informational (1xx) responses relayed from upstreams to clients, by service and status code.",
+	"service", "code",
+)
+
+var errTooManyInformational = errors.New("too many informational responses from upstream")
+
+// expectsContinue reports whether r asks for 100 Continue before sending
+// a body.
+func expectsContinue(r *http.Request) bool {
+	return r.ProtoAtLeast(1, 1) &&
+		strings.EqualFold(strings.TrimSpace(r.Header.Get("Expect")), "100-continue") &&
+		r.ContentLength != 0 && r.Body != nil && r.Body != http.NoBody
+}
+
+// setExpectHeader forwards Expect: 100-continue to the upstream when the
+// client is still waiting to send the body; any other Expect is dropped.
+func setExpectHeader(upstreamReq, r *http.Request) {
+	upstreamReq.Header.Del("Expect")
+	if expectsContinue(r) {
+		upstreamReq.Header.Set("Expect", "100-continue")
+	}
+}
+
+// withInformational returns ctx with a client trace relaying the
+// upstream's informational responses to w. stop must be called once the
+// round trip returns; no response is relayed after that.
+func (p *Proxy) withInformational(ctx context.Context, w http.ResponseWriter, r *http.Request, service string) (_ context.Context, stop func()) {
+	if !r.ProtoAtLeast(1, 1) {
+		return ctx, func() {}
+	}
+
+	var mu sync.Mutex
+	var relayed int
+	done := false
+
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			// The server sends its own 100 Continue when the body is read
+			if code == http.StatusContinue {
+				return nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if done {
+				return nil
+			}
+			if relayed++; relayed > maxInformational {
+				return errTooManyInformational
+			}
+
+			p.writeInformational(w, code, http.Header(header))
+			informationalTotal.Inc(service, strconv.Itoa(code))
+			return nil
+		},
+	}
+
+	return httptrace.WithClientTrace(ctx, trace), func() {
+		mu.Lock()
+		done = true
+		mu.Unlock()
+	}
+}
+
+// writeInformational sends an informational response with the upstream's
+// headers only. The headers already set for the final response are kept
+// aside, since the server writes the whole header map with a 1xx.
+func (p *Proxy) writeInformational(w http.ResponseWriter, code int, header http.Header) {
+	h := w.Header()
+	pending := h.Clone()
+	clear(h)
+
+	p.copyHeaders(h, header)
+	w.WriteHeader(code)
+
+	clear(h)
+	maps.Copy(h, pending)
+}
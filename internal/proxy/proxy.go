@@ -2,6 +2,8 @@
 package proxy
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,25 +13,96 @@ import (
 
 	"github.com/rs/zerolog/log"
 
+	"github.com/saidutt46/switchboard-gateway/internal/clientip"
 	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/deadline"
+	"github.com/saidutt46/switchboard-gateway/internal/egress"
+	"github.com/saidutt46/switchboard-gateway/internal/loadbalancer"
+	"github.com/saidutt46/switchboard-gateway/internal/logging"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
 	"github.com/saidutt46/switchboard-gateway/internal/router"
+	"github.com/saidutt46/switchboard-gateway/internal/tenant"
+)
+
+// responseBytes counts response body bytes sent on to clients.
+var responseBytes = metrics.NewCounterVec(
+	"gateway_upstream_response_bytes_total",
+	"Upstream response body bytes copied to clients, by route.",
+	"route",
 )
 
 // Proxy handles reverse proxying requests to backend services.
 type Proxy struct {
 	router    *router.Router
-	transport *http.Transport
+	balancers *loadbalancer.Manager
+
+	// targets holds the base transport and one transport per load-balanced
+	// target so removed targets can have their connections drained
+	targets *targetTransports
+
+	// headers controls Via / Forwarded / X-Forwarded-For handling
+	headers HeaderConfig
+
+	// slow logs slow upstream dials and responses (nil = disabled)
+	slow *slowUpstream
+
+	// timing controls who may request the X-Timing header
+	timing TimingConfig
+
+	// budget caps hedged requests; hedgeLatency holds the recent
+	// latencies hedge delays are derived from
+	budget       *retryBudget
+	hedgeLatency latencyWindows
 }
 
-// NewProxy creates a new reverse proxy with the given router and transport.
-func NewProxy(r *router.Router, transport *http.Transport) *Proxy {
+// NewProxy creates a new reverse proxy with the given router, transport and
+// load balancer manager.
+//
+// balancers may be nil, in which case every request goes to the service's
+// own host/port.
+func NewProxy(r *router.Router, transport *http.Transport, balancers *loadbalancer.Manager) *Proxy {
 	if transport == nil {
 		transport = NewTransport(nil)
 	}
 
 	return &Proxy{
 		router:    r,
-		transport: transport,
+		balancers: balancers,
+		targets:   newTargetTransports(transport),
+		headers:   DefaultHeaderConfig(),
+		budget:    newRetryBudget(DefaultRetryBudgetConfig()),
+	}
+}
+
+// SetTransport replaces the upstream transport, e.g. after transport
+// settings change on hot reload.
+//
+// New requests use the new transport's pools and timeouts. Requests in
+// flight finish on the old transports, whose idle connections are closed.
+func (p *Proxy) SetTransport(transport *http.Transport) {
+	old := p.targets.reset(transport)
+	for _, t := range old {
+		t.CloseIdleConnections()
+	}
+
+	log.Info().
+		Str("component", "proxy").
+		Int("replaced_transports", len(old)).
+		Msg("Upstream transport replaced")
+}
+
+// Drain closes the upstream connections of a removed target.
+//
+// Implements loadbalancer.Drainer. The balancer manager calls this once the
+// target has no requests in flight (or its grace period has expired).
+func (p *Proxy) Drain(target *loadbalancer.Target) {
+	if p.targets.close(target.Address) {
+		log.Info().
+			Str("component", "proxy").
+			Str("target", target.Address).
+			Int("remaining_transports", p.targets.count()).
+			Msg("Closed idle upstream connections for removed target")
 	}
 }
 
@@ -39,8 +112,12 @@ func NewProxy(r *router.Router, transport *http.Transport) *Proxy {
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 
-	// Generate request ID
-	requestID := generateRequestID()
+	// Use the request ID the recovery middleware assigned, so panics,
+	// logs, the client and the upstream all see the same one
+	requestID := logging.RequestIDFromContext(r.Context())
+	if requestID == "" {
+		requestID = generateRequestID()
+	}
 
 	// Add request ID to response header
 	w.Header().Set("X-Request-ID", requestID)
@@ -56,6 +133,16 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			Str("method", r.Method).
 			Msg("No route matched")
 
+		var notAllowed *router.MethodNotAllowedError
+		if errors.As(err, &notAllowed) {
+			w.Header().Set("Allow", notAllowed.AllowHeader())
+			if notAllowed.AutoOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			http.Error(w, `{"error":"method not allowed","message":"Method not allowed for this path"}`, http.StatusMethodNotAllowed)
+			return
+		}
 		http.Error(w, `{"error":"not found","message":"No route configured for this path"}`, http.StatusNotFound)
 		return
 	}
@@ -67,7 +154,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Str("method", r.Method).
 		Str("path", r.URL.Path).
 		Str("query", r.URL.RawQuery).
-		Str("client_ip", getClientIP(r)).
+		Str("client_ip", p.clientIP(r)).
 		Int64("request_size", r.ContentLength).
 		Str("user_agent", r.UserAgent()).
 		Str("route_id", match.Route.ID).
@@ -75,9 +162,23 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Str("service_name", match.Service.Name).
 		Msg("Request matched to route")
 
-	// Get the first target from the service
-	// TODO: Phase 11 - Use load balancer to select target
-	targetURL, err := p.getTargetURL(match.Service)
+	// The gateway bounds requests at match time; bound any that come in
+	// without a deadline the same way
+	if _, ok := r.Context().Deadline(); !ok {
+		var cancel context.CancelFunc
+		r, cancel = deadline.Attach(w, r, deadline.Timeout(match.Route, match.Service))
+		defer cancel()
+	}
+
+	// Select an upstream target (load balanced when the service has targets)
+	targetURL, target, err := p.selectTarget(r, match)
+	if target != nil {
+		defer p.release(match.Service.ID, target)
+	}
+	if err == nil {
+		// Services templated per tenant go to the request's tenant
+		targetURL, err = tenant.Expand(targetURL, tenant.FromContext(r.Context()))
+	}
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -100,7 +201,95 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Msg("Proxying request to upstream")
 
 	// Proxy the request
-	if err := p.proxyRequest(w, r, upstreamURL, match, requestID); err != nil {
+	upstreamStart := time.Now()
+	primary := &upstreamAttempt{
+		target:    target,
+		url:       upstreamURL,
+		transport: p.transportFor(match.Service, target),
+	}
+	statusCode, served, err := p.proxyRequest(w, r, primary, match, requestID)
+
+	// Feed the outcome to outlier detection. Errors after the upstream
+	// responded (e.g. client went away mid-body) or before anything was sent
+	// (plugin upstream hooks, egress policy) don't count against the target.
+	if served.target != nil && !errors.Is(err, errUpstreamHook) && !errors.Is(err, egress.ErrBlocked) {
+		var upstreamErr error
+		if statusCode == 0 {
+			upstreamErr = err
+		}
+		p.balancers.Report(match.Service.ID, served.target, statusCode, upstreamErr, time.Since(upstreamStart))
+	}
+
+	// A plugin rejected the upstream request or response; send its
+	// replacement
+	var rejected *plugin.ResponseError
+	if errors.As(err, &rejected) {
+		log.Warn().
+			Str("component", "proxy").
+			Str("request_id", requestID).
+			Str("upstream_url", upstreamURL).
+			Int("upstream_status", statusCode).
+			Str("reason", rejected.Reason).
+			Msg("Upstream response rejected")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(rejected.StatusCode)
+		w.Write([]byte(rejected.Body))
+		return
+	}
+
+	// The status and part of the body are already out: abort the
+	// connection so the client sees a truncated response, not a short one
+	if errors.Is(err, errResponseCopy) {
+		log.Warn().
+			Err(err).
+			Str("component", "proxy").
+			Str("request_id", requestID).
+			Str("upstream_url", upstreamURL).
+			Int("upstream_status", statusCode).
+			Msg("Response body copy failed; aborting connection")
+		panic(http.ErrAbortHandler)
+	}
+
+	// The egress policy refused the upstream address (already logged)
+	if errors.Is(err, egress.ErrBlocked) {
+		http.Error(w, `{"error":"forbidden","message":"Upstream destination not allowed"}`, http.StatusForbidden)
+		return
+	}
+
+	// No response from the upstream (timeout, connection error): a plugin
+	// may have one to send instead, e.g. a stale cached copy
+	if err != nil && statusCode == 0 && !errors.Is(err, errUpstreamHook) {
+		if resp := substituteResponse(r, err); resp != nil {
+			log.Warn().
+				Err(err).
+				Str("component", "proxy").
+				Str("request_id", requestID).
+				Str("upstream_url", upstreamURL).
+				Int("status_code", resp.StatusCode).
+				Msg("Upstream request failed; sending plugin substitute")
+
+			p.writeSubstitute(w, resp, match.Route.ID)
+			return
+		}
+	}
+
+	// Out of time: the request deadline passed, or connecting to the
+	// upstream timed out
+	if err != nil && (deadline.Exceeded(r.Context()) || deadline.IsTimeout(err)) {
+		log.Warn().
+			Err(err).
+			Str("component", "proxy").
+			Str("request_id", requestID).
+			Str("upstream_url", upstreamURL).
+			Dur("elapsed", time.Since(start)).
+			Msg("Upstream request timed out")
+
+		deadline.WriteTimeout(w, match.Route.ID, deadline.StageUpstream)
+		return
+	}
+
+	if err != nil {
 		log.Error().
 			Err(err).
 			Str("component", "proxy").
@@ -125,16 +314,113 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Msg("Request proxied successfully")
 }
 
+// substituteResponse asks the request's error hooks for a response to send
+// instead of failing with err. The first non-nil one wins.
+func substituteResponse(r *http.Request, err error) *http.Response {
+	for _, hook := range plugin.ErrorHooks(r) {
+		if resp := hook(err); resp != nil {
+			return resp
+		}
+	}
+	return nil
+}
+
+// writeSubstitute sends a response supplied by an error hook.
+func (p *Proxy) writeSubstitute(w http.ResponseWriter, resp *http.Response, routeID string) {
+	defer resp.Body.Close()
+
+	p.copyHeaders(w.Header(), resp.Header)
+	w.WriteHeader(resp.StatusCode)
+	n, _ := io.Copy(w, resp.Body)
+	responseBytes.Add(float64(n), routeID)
+}
+
+// errUpstreamHook marks failures of plugin upstream hooks.
+var errUpstreamHook = errors.New("upstream hook failed")
+
+// errResponseCopy marks failures after the response has started.
+var errResponseCopy = errors.New("failed to copy response body")
+
+// selectTarget picks the upstream base URL for a matched request.
+//
+// If the service has targets configured, its load balancer chooses one and
+// the returned Target must be released via p.release once the request is done.
+// Otherwise the service's own host/port is used and the Target is nil.
+func (p *Proxy) selectTarget(r *http.Request, match *router.MatchResult) (string, *loadbalancer.Target, error) {
+	if p.balancers != nil {
+		if lb, ok := p.balancers.Get(match.Service.ID); ok {
+			target, err := lb.Next(r, match.PathParams)
+			if err != nil {
+				return "", nil, fmt.Errorf("load balancer failed: %w", err)
+			}
+
+			log.Debug().
+				Str("component", "proxy").
+				Str("service_id", match.Service.ID).
+				Str("balancer", lb.Type()).
+				Str("target", target.Address).
+				Msg("Selected upstream target")
+
+			return buildTargetURL(match.Service, target.Address), target, nil
+		}
+	}
+
+	targetURL, err := p.getTargetURL(match.Service)
+	return targetURL, nil, err
+}
+
+// transportFor returns the connection pool for a request. gRPC services
+// speak HTTP/2 without TLS (h2c), which needs transports of its own.
+func (p *Proxy) transportFor(service *database.Service, target *loadbalancer.Target) *http.Transport {
+	address := ""
+	if target != nil {
+		address = target.Address
+	}
+	if service.Protocol == "grpc" {
+		return p.targets.getH2C(address)
+	}
+	if target == nil {
+		return p.targets.baseTransport()
+	}
+	return p.targets.get(address)
+}
+
+// release tells the service's balancer that a request to target has finished.
+func (p *Proxy) release(serviceID string, target *loadbalancer.Target) {
+	if lb, ok := p.balancers.Get(serviceID); ok {
+		lb.Done(target)
+	}
+}
+
+// buildTargetURL builds a target URL from a "host:port" address and the
+// service's protocol and path.
+func buildTargetURL(service *database.Service, address string) string {
+	scheme := upstreamScheme(service)
+
+	targetURL := fmt.Sprintf("%s://%s", scheme, address)
+	if service.Path.Valid && service.Path.String != "" {
+		targetURL += service.Path.String
+	}
+
+	return targetURL
+}
+
+// upstreamScheme returns the URL scheme for a service's protocol. gRPC
+// runs over plain-text HTTP/2 (see transportFor).
+func upstreamScheme(service *database.Service) string {
+	if service.Protocol == "" || service.Protocol == "grpc" {
+		return "http"
+	}
+	return service.Protocol
+}
+
 // getTargetURL gets the target URL for a service.
 //
-// For now, we construct it from the service host/port.
-// In Phase 11, we'll use service_targets table for load balancing.
+// Used when the service has no targets configured; the URL is constructed
+// from the service host/port.
 func (p *Proxy) getTargetURL(service *database.Service) (string, error) {
 	// Build target URL from service
-	scheme := service.Protocol
-	if scheme == "" {
-		scheme = "http"
-	}
+	scheme := upstreamScheme(service)
 
 	host := service.Host
 	port := service.Port
@@ -179,8 +465,9 @@ func (p *Proxy) buildUpstreamURL(targetURL string, r *http.Request, match *route
 		path = "/" + path
 	}
 
-	// Build full URL
-	upstreamURL := targetURL + path
+	// Build full URL (re-escaping the decoded path, so characters like
+	// "?" or "#" decoded from %3F/%23 stay part of the path)
+	upstreamURL := targetURL + (&url.URL{Path: path}).EscapedPath()
 
 	// Add query string if present
 	if r.URL.RawQuery != "" {
@@ -190,43 +477,39 @@ func (p *Proxy) buildUpstreamURL(targetURL string, r *http.Request, match *route
 	return upstreamURL
 }
 
-// proxyRequest performs the actual HTTP request to the upstream service.
-func (p *Proxy) proxyRequest(w http.ResponseWriter, r *http.Request, upstreamURL string, match *router.MatchResult, requestID string) error {
-	// Parse upstream URL
-	targetURL, err := url.Parse(upstreamURL)
-	if err != nil {
-		return fmt.Errorf("invalid upstream URL: %w", err)
-	}
+// upstreamAttempt is one request to an upstream target. Hedged requests
+// make a second attempt to another target.
+type upstreamAttempt struct {
+	target    *loadbalancer.Target // nil without load balancing
+	url       string
+	transport http.RoundTripper
 
-	// Create upstream request
-	upstreamReq, err := http.NewRequestWithContext(r.Context(), r.Method, targetURL.String(), r.Body)
-	if err != nil {
-		return fmt.Errorf("failed to create upstream request: %w", err)
-	}
-
-	// Copy headers from original request
-	p.copyHeaders(upstreamReq.Header, r.Header)
-
-	// Add/modify proxy headers
-	p.setProxyHeaders(upstreamReq, r, match, requestID)
-
-	// Create HTTP client with our transport
-	client := &http.Client{
-		Transport: p.transport,
-		Timeout:   time.Duration(match.Service.ReadTimeoutMs) * time.Millisecond,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			// Don't follow redirects - return them to client
-			return http.ErrUseLastResponse
-		},
-	}
+	// trace is set once the attempt is sent
+	trace *upstreamTrace
+}
 
-	// Perform the request
+// proxyRequest performs the actual HTTP request to the upstream service.
+//
+// Returns the upstream status code, or 0 if no response was received, and
+// the attempt whose response was used.
+func (p *Proxy) proxyRequest(w http.ResponseWriter, r *http.Request, primary *upstreamAttempt, match *router.MatchResult, requestID string) (int, *upstreamAttempt, error) {
+	// Perform the request, hedged if a plugin asked for it
 	upstreamStart := time.Now()
-	resp, err := client.Do(upstreamReq)
+	served := primary
+	var resp *http.Response
+	var err error
+	if policy, ok := hedgePolicy(r); ok {
+		resp, served, err = p.roundTripHedged(r, primary, match, requestID, policy)
+	} else {
+		ctx, stop := p.withInformational(r.Context(), w, r, match.Service.Name)
+		resp, err = p.roundTrip(ctx, r, primary, match, requestID)
+		stop()
+	}
 	if err != nil {
-		return fmt.Errorf("upstream request failed: %w", err)
+		return 0, served, err
 	}
-	defer resp.Body.Close()
+	// Response hooks may replace the body; close the one finally sent
+	defer func() { resp.Body.Close() }()
 
 	upstreamLatency := time.Since(upstreamStart)
 
@@ -237,29 +520,95 @@ func (p *Proxy) proxyRequest(w http.ResponseWriter, r *http.Request, upstreamURL
 		Dur("upstream_latency_ms", upstreamLatency).
 		Msg("Received response from upstream")
 
+	// Let plugins vet the response before the client sees any of it
+	for _, hook := range plugin.ResponseHooks(r) {
+		if err := hook(resp); err != nil {
+			return resp.StatusCode, served, err
+		}
+	}
+
 	// Copy response headers
 	p.copyHeaders(w.Header(), resp.Header)
+	p.appendVia(w.Header(), resp.ProtoMajor, resp.ProtoMinor)
 
 	// Add custom headers
 	w.Header().Set("X-Upstream-Latency", fmt.Sprintf("%dms", upstreamLatency.Milliseconds()))
+	if p.wantsTiming(r) {
+		w.Header().Set(TimingHeader, served.trace.header(upstreamLatency))
+		// Kept alongside the backend's own Server-Timing metrics
+		w.Header().Add(ServerTimingHeader, served.trace.serverTiming(upstreamLatency))
+	}
 
 	// Write status code
 	w.WriteHeader(resp.StatusCode)
 
 	// Copy response body
-	_, err = io.Copy(w, resp.Body)
+	n, err := io.Copy(w, resp.Body)
+	responseBytes.Add(float64(n), match.Route.ID)
 	if err != nil {
-		return fmt.Errorf("failed to copy response body: %w", err)
+		return resp.StatusCode, served, fmt.Errorf("%w: %w", errResponseCopy, err)
 	}
 
-	return nil
+	return resp.StatusCode, served, nil
 }
 
-// copyHeaders copies HTTP headers from src to dst.
+// roundTrip sends an attempt upstream and returns the response, whose body
+// the caller must close.
+func (p *Proxy) roundTrip(ctx context.Context, r *http.Request, a *upstreamAttempt, match *router.MatchResult, requestID string) (*http.Response, error) {
+	// Parse upstream URL
+	targetURL, err := url.Parse(a.url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream URL: %w", err)
+	}
+
+	// Create upstream request, traced for connection and latency metrics
+	ctx, a.trace = p.withUpstreamTrace(ctx, match.Service.Name)
+	ctx = withIPFamily(ctx, match.Service.IPFamily)
+	upstreamReq, err := http.NewRequestWithContext(ctx, r.Method, targetURL.String(), r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upstream request: %w", err)
+	}
+
+	// Copy headers from original request
+	p.copyHeaders(upstreamReq.Header, r.Header)
+	upstreamReq.Header.Del(TimingRequestHeader)
+	setExpectHeader(upstreamReq, r)
+
+	// Add/modify proxy headers
+	p.setProxyHeaders(upstreamReq, r, match, requestID)
+
+	// Let plugins finalize the request (e.g. sign it for the upstream)
+	for _, hook := range plugin.UpstreamHooks(r) {
+		if err := hook(upstreamReq); err != nil {
+			return nil, fmt.Errorf("%w: %w", errUpstreamHook, err)
+		}
+	}
+
+	// Create HTTP client with our transport. The request's deadline (see
+	// internal/deadline) bounds the exchange, so the client has no timeout
+	// of its own.
+	client := &http.Client{
+		Transport: a.transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// Don't follow redirects - return them to client
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Do(upstreamReq)
+	if err != nil {
+		return nil, fmt.Errorf("upstream request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// copyHeaders copies HTTP headers from src to dst, leaving out hop-by-hop
+// headers and any header src's Connection header lists.
 func (p *Proxy) copyHeaders(dst, src http.Header) {
+	listed := connectionHeaders(src)
 	for key, values := range src {
 		// Skip hop-by-hop headers
-		if isHopByHopHeader(key) {
+		if isHopByHopHeader(key) || listed[http.CanonicalHeaderKey(key)] {
 			continue
 		}
 
@@ -271,12 +620,20 @@ func (p *Proxy) copyHeaders(dst, src http.Header) {
 
 // setProxyHeaders sets/modifies headers for the upstream request.
 func (p *Proxy) setProxyHeaders(upstreamReq *http.Request, originalReq *http.Request, match *router.MatchResult, requestID string) {
-	// X-Forwarded-For
-	if clientIP := getClientIP(originalReq); clientIP != "" {
+	clientIP := p.clientIP(originalReq)
+
+	// Untrusted forwarding information from the client is discarded
+	if !p.headers.TrustedProxies.TrustsPeer(originalReq) {
+		upstreamReq.Header.Del("X-Forwarded-For")
+		upstreamReq.Header.Del("Forwarded")
+	}
+
+	// X-Forwarded-For (extends the chain with the immediate peer)
+	if peer := clientip.RemoteIP(originalReq); peer != "" {
 		if prior := upstreamReq.Header.Get("X-Forwarded-For"); prior != "" {
-			upstreamReq.Header.Set("X-Forwarded-For", prior+", "+clientIP)
+			upstreamReq.Header.Set("X-Forwarded-For", prior+", "+peer)
 		} else {
-			upstreamReq.Header.Set("X-Forwarded-For", clientIP)
+			upstreamReq.Header.Set("X-Forwarded-For", peer)
 		}
 	}
 
@@ -287,11 +644,23 @@ func (p *Proxy) setProxyHeaders(upstreamReq *http.Request, originalReq *http.Req
 	}
 	upstreamReq.Header.Set("X-Forwarded-Proto", proto)
 
+	// Forwarded (RFC 7239)
+	if p.headers.Forwarded {
+		element := forwardedElement(clientip.RemoteIP(originalReq), originalReq.Host, proto)
+		if prior := upstreamReq.Header.Get("Forwarded"); prior != "" {
+			element = prior + ", " + element
+		}
+		upstreamReq.Header.Set("Forwarded", element)
+	}
+
+	// Via
+	p.appendVia(upstreamReq.Header, originalReq.ProtoMajor, originalReq.ProtoMinor)
+
 	// X-Forwarded-Host
 	upstreamReq.Header.Set("X-Forwarded-Host", originalReq.Host)
 
 	// X-Real-IP
-	if clientIP := getClientIP(originalReq); clientIP != "" {
+	if clientIP != "" {
 		upstreamReq.Header.Set("X-Real-IP", clientIP)
 	}
 
@@ -326,30 +695,6 @@ func isHopByHopHeader(header string) bool {
 	return hopByHopHeaders[http.CanonicalHeaderKey(header)]
 }
 
-// getClientIP extracts the client IP from the request.
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// Take the first IP
-		if idx := strings.Index(xff, ","); idx > 0 {
-			return strings.TrimSpace(xff[:idx])
-		}
-		return strings.TrimSpace(xff)
-	}
-
-	// Check X-Real-IP
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return strings.TrimSpace(xri)
-	}
-
-	// Fall back to RemoteAddr
-	if idx := strings.LastIndex(r.RemoteAddr, ":"); idx > 0 {
-		return r.RemoteAddr[:idx]
-	}
-
-	return r.RemoteAddr
-}
-
 // generateRequestID generates a unique request ID.
 //
 // Format: req_<timestamp>_<random>
@@ -1,61 +1,33 @@
 package proxy
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"testing/iotest"
 	"time"
 
+	"github.com/saidutt46/switchboard-gateway/internal/clientip"
 	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/deadline"
+	"github.com/saidutt46/switchboard-gateway/internal/egress"
+	"github.com/saidutt46/switchboard-gateway/internal/loadbalancer"
+	"github.com/saidutt46/switchboard-gateway/internal/logging"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
 	"github.com/saidutt46/switchboard-gateway/internal/router"
 )
 
-func TestProxy_GetClientIP(t *testing.T) {
-	tests := []struct {
-		name       string
-		remoteAddr string
-		xff        string
-		xri        string
-		expectedIP string
-	}{
-		{
-			name:       "from RemoteAddr",
-			remoteAddr: "192.168.1.100:12345",
-			expectedIP: "192.168.1.100",
-		},
-		{
-			name:       "from X-Forwarded-For",
-			remoteAddr: "10.0.0.1:12345",
-			xff:        "203.0.113.1, 198.51.100.1",
-			expectedIP: "203.0.113.1",
-		},
-		{
-			name:       "from X-Real-IP",
-			remoteAddr: "10.0.0.1:12345",
-			xri:        "203.0.113.1",
-			expectedIP: "203.0.113.1",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest("GET", "/test", nil)
-			req.RemoteAddr = tt.remoteAddr
-
-			if tt.xff != "" {
-				req.Header.Set("X-Forwarded-For", tt.xff)
-			}
-			if tt.xri != "" {
-				req.Header.Set("X-Real-IP", tt.xri)
-			}
-
-			ip := getClientIP(req)
-			if ip != tt.expectedIP {
-				t.Errorf("getClientIP() = %v, want %v", ip, tt.expectedIP)
-			}
-		})
-	}
-}
-
 func TestProxy_IsHopByHopHeader(t *testing.T) {
 	tests := []struct {
 		header string
@@ -129,6 +101,15 @@ func TestProxy_GetTargetURL(t *testing.T) {
 			},
 			want: "http://localhost:8081",
 		},
+		{
+			name: "grpc over cleartext HTTP/2",
+			service: &database.Service{
+				Protocol: "grpc",
+				Host:     "orders",
+				Port:     50051,
+			},
+			want: "http://orders:50051",
+		},
 	}
 
 	for _, tt := range tests {
@@ -177,6 +158,13 @@ func TestProxy_BuildUpstreamURL(t *testing.T) {
 			routePath: "/api",
 			want:      "http://backend/users/123",
 		},
+		{
+			name:      "decoded reserved characters stay in the path",
+			targetURL: "http://backend",
+			path:      "/files/a%3Fb%23c%20d",
+			query:     "x=1",
+			want:      "http://backend/files/a%3Fb%23c%20d?x=1",
+		},
 	}
 
 	for _, tt := range tests {
@@ -200,3 +188,1069 @@ func TestProxy_BuildUpstreamURL(t *testing.T) {
 		})
 	}
 }
+
+func TestProxy_TargetTransports(t *testing.T) {
+	tt := newTargetTransports(NewTransport(nil))
+
+	a := tt.get("10.0.0.1:8080")
+	if tt.get("10.0.0.1:8080") != a {
+		t.Error("get() should return the same transport for the same address")
+	}
+	if tt.get("10.0.0.2:8080") == a {
+		t.Error("get() should return separate transports per address")
+	}
+
+	if !tt.close("10.0.0.1:8080") {
+		t.Error("close() should report a tracked transport")
+	}
+	if tt.close("10.0.0.1:8080") {
+		t.Error("close() should report false for an unknown address")
+	}
+	if got := tt.count(); got != 1 {
+		t.Errorf("count() = %d, want 1", got)
+	}
+}
+
+func TestProxy_GRPCTransportUsesH2C(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	backend.Config.Protocols = new(http.Protocols)
+	backend.Config.Protocols.SetUnencryptedHTTP2(true)
+	backend.Start()
+	defer backend.Close()
+
+	p := NewProxy(nil, nil, nil)
+	service := &database.Service{Protocol: "grpc"}
+	transport := p.transportFor(service, nil)
+	if transport == p.targets.baseTransport() {
+		t.Fatal("grpc services should not use the base transport")
+	}
+	if p.transportFor(service, nil) != transport {
+		t.Error("transportFor() should reuse the h2c transport")
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Get(backend.URL)
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("proto = %s, want HTTP/2.0", resp.Proto)
+	}
+
+	if got := len(p.targets.reset(NewTransport(nil))); got != 2 {
+		t.Errorf("reset() returned %d transports, want 2 (base and h2c)", got)
+	}
+}
+
+func TestProxy_TargetTransportsReset(t *testing.T) {
+	tt := newTargetTransports(NewTransport(nil))
+	a := tt.get("10.0.0.1:8080")
+
+	base := NewTransport(nil)
+	if old := tt.reset(base); len(old) != 2 {
+		t.Errorf("reset() returned %d transports, want 2 (base and target)", len(old))
+	}
+	if tt.baseTransport() != base {
+		t.Error("baseTransport() should return the new base")
+	}
+	if tt.get("10.0.0.1:8080") == a {
+		t.Error("get() after reset() should clone the new base")
+	}
+}
+
+func TestTransportConfig_WithSettings(t *testing.T) {
+	base := DefaultTransportConfig()
+
+	cfg, err := base.WithSettings(map[string]string{
+		"upstream_max_idle_conns_per_host": "50",
+		"upstream_response_header_timeout": "2s",
+		"upstream_something_new":           "1",
+	})
+	if err != nil {
+		t.Fatalf("WithSettings() error = %v", err)
+	}
+	if cfg.MaxIdleConnsPerHost != 50 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 50", cfg.MaxIdleConnsPerHost)
+	}
+	if cfg.ResponseHeaderTimeout != 2*time.Second {
+		t.Errorf("ResponseHeaderTimeout = %v, want 2s", cfg.ResponseHeaderTimeout)
+	}
+	if cfg.MaxIdleConns != base.MaxIdleConns {
+		t.Errorf("MaxIdleConns = %d, want unchanged %d", cfg.MaxIdleConns, base.MaxIdleConns)
+	}
+	if base.MaxIdleConnsPerHost != 10 {
+		t.Error("WithSettings() should not modify the receiver")
+	}
+
+	for _, settings := range []map[string]string{
+		{"upstream_max_idle_conns": "lots"},
+		{"upstream_max_idle_conns": "-1"},
+		{"upstream_dial_timeout": "5"},
+	} {
+		if _, err := base.WithSettings(settings); err == nil {
+			t.Errorf("WithSettings(%v) should fail", settings)
+		}
+	}
+}
+
+func TestProxy_CopyHeadersDropsConnectionListed(t *testing.T) {
+	p := &Proxy{}
+
+	src := http.Header{}
+	src.Set("Connection", "keep-alive, X-Hop")
+	src.Set("X-Hop", "1")
+	src.Set("Keep-Alive", "timeout=5")
+	src.Set("X-End-To-End", "1")
+
+	dst := http.Header{}
+	p.copyHeaders(dst, src)
+
+	if dst.Get("X-Hop") != "" || dst.Get("Connection") != "" || dst.Get("Keep-Alive") != "" {
+		t.Errorf("hop-by-hop headers forwarded: %v", dst)
+	}
+	if dst.Get("X-End-To-End") != "1" {
+		t.Errorf("end-to-end header dropped: %v", dst)
+	}
+}
+
+func TestProxy_SetProxyHeaders(t *testing.T) {
+	tests := []struct {
+		name          string
+		trust         bool
+		wantXFF       string
+		wantForwarded string
+		wantRealIP    string
+	}{
+		{
+			name:          "chain from trusted proxy is extended",
+			trust:         true,
+			wantXFF:       "203.0.113.1, 10.0.0.1",
+			wantForwarded: "for=203.0.113.1, for=10.0.0.1;host=api.example.com;proto=http",
+			wantRealIP:    "203.0.113.1",
+		},
+		{
+			name:          "chain from untrusted peer is replaced",
+			trust:         false,
+			wantXFF:       "10.0.0.1",
+			wantForwarded: "for=10.0.0.1;host=api.example.com;proto=http",
+			wantRealIP:    "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trusted []string
+			if tt.trust {
+				trusted = []string{"10.0.0.0/8"}
+			}
+			resolver, err := clientip.NewResolver(trusted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &Proxy{headers: HeaderConfig{Via: "switchboard", Forwarded: true, TrustedProxies: resolver}}
+
+			original := httptest.NewRequest("GET", "http://api.example.com/users", nil)
+			original.RemoteAddr = "10.0.0.1:4000"
+			original.Header.Set("X-Forwarded-For", "203.0.113.1")
+			original.Header.Set("Forwarded", "for=203.0.113.1")
+
+			upstream := httptest.NewRequest("GET", "http://backend/users", nil)
+			p.copyHeaders(upstream.Header, original.Header)
+			p.setProxyHeaders(upstream, original, &router.MatchResult{Route: &database.Route{}}, "req_1")
+
+			if got := upstream.Header.Get("X-Forwarded-For"); got != tt.wantXFF {
+				t.Errorf("X-Forwarded-For = %q, want %q", got, tt.wantXFF)
+			}
+			if got := upstream.Header.Get("Forwarded"); got != tt.wantForwarded {
+				t.Errorf("Forwarded = %q, want %q", got, tt.wantForwarded)
+			}
+			if got := upstream.Header.Get("X-Real-IP"); got != tt.wantRealIP {
+				t.Errorf("X-Real-IP = %q, want %q", got, tt.wantRealIP)
+			}
+			if got := upstream.Header.Get("Via"); got != "1.1 switchboard" {
+				t.Errorf("Via = %q, want %q", got, "1.1 switchboard")
+			}
+		})
+	}
+}
+
+func TestForwardedElement(t *testing.T) {
+	got := forwardedElement("2001:db8::1", "api.example.com:8443", "https")
+	want := `for="[2001:db8::1]";host="api.example.com:8443";proto=https`
+	if got != want {
+		t.Errorf("forwardedElement() = %q, want %q", got, want)
+	}
+
+	if got := viaProtocol(2, 0); got != "2" {
+		t.Errorf("viaProtocol(2, 0) = %q, want %q", got, "2")
+	}
+}
+
+func TestProxy_RequestIDFromContext(t *testing.T) {
+	var upstreamID string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamID = r.Header.Get("X-Request-ID")
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+	service := &database.Service{ID: "svc", Name: "api", Protocol: "http", Host: u.Hostname(), Port: port, Enabled: true}
+	route := &database.Route{ID: "r1", ServiceID: "svc", Paths: []string{"/api"}, Enabled: true}
+	px := NewProxy(router.NewRouter([]*database.Route{route}, []*database.Service{service}, nil), nil, nil)
+
+	r := httptest.NewRequest("GET", "/api", nil)
+	w := httptest.NewRecorder()
+	px.ServeHTTP(w, r.WithContext(logging.ContextWithRequestID(r.Context(), "req_assigned")))
+
+	if got := w.Header().Get("X-Request-ID"); got != "req_assigned" {
+		t.Errorf("response X-Request-ID = %q, want req_assigned", got)
+	}
+	if upstreamID != "req_assigned" {
+		t.Errorf("upstream X-Request-ID = %q, want req_assigned", upstreamID)
+	}
+
+	// Without one in the context, the proxy makes its own
+	w = httptest.NewRecorder()
+	px.ServeHTTP(w, httptest.NewRequest("GET", "/api", nil))
+	if got := w.Header().Get("X-Request-ID"); got == "" || got != upstreamID {
+		t.Errorf("fallback X-Request-ID = %q (upstream %q), want the same generated ID", got, upstreamID)
+	}
+}
+
+func TestProxy_ResponseHooks(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("<html>oops</html>"))
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+	service := &database.Service{ID: "svc", Name: "api", Protocol: "http", Host: u.Hostname(), Port: port, Enabled: true}
+	route := &database.Route{ID: "r1", ServiceID: "svc", Paths: []string{"/api"}, Enabled: true}
+	px := NewProxy(router.NewRouter([]*database.Route{route}, []*database.Service{service}, nil), nil, nil)
+
+	tests := []struct {
+		name       string
+		hook       plugin.ResponseHook
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "no hooks",
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "<html>oops</html>",
+		},
+		{
+			name: "replaced",
+			hook: func(resp *http.Response) error {
+				return &plugin.ResponseError{StatusCode: http.StatusServiceUnavailable, Body: `{"error":"contract"}`, Reason: "html"}
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"error":"contract"}`,
+		},
+		{
+			name: "failed",
+			hook: func(resp *http.Response) error {
+				return errors.New("boom")
+			},
+			wantStatus: http.StatusBadGateway,
+			wantBody:   "bad gateway",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx := plugin.NewContext(httptest.NewRequest("GET", "/api", nil), w, route, service, plugin.PhaseBeforeRequest)
+			if tt.hook != nil {
+				ctx.AddResponseHook(tt.hook)
+			}
+
+			px.ServeHTTP(w, ctx.Request)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestProxy_UpstreamHookRefusal(t *testing.T) {
+	var called bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+	service := &database.Service{ID: "svc", Name: "api", Protocol: "http", Host: u.Hostname(), Port: port, Enabled: true}
+	route := &database.Route{ID: "r1", ServiceID: "svc", Paths: []string{"/api"}, Enabled: true}
+	px := NewProxy(router.NewRouter([]*database.Route{route}, []*database.Service{service}, nil), nil, nil)
+
+	w := httptest.NewRecorder()
+	ctx := plugin.NewContext(httptest.NewRequest("POST", "/api", strings.NewReader("big")), w, route, service, plugin.PhaseBeforeRequest)
+	ctx.AddUpstreamHook(func(req *http.Request) error {
+		return &plugin.ResponseError{StatusCode: http.StatusRequestEntityTooLarge, Body: `{"error":"payload too large"}`, Reason: "too large to sign"}
+	})
+
+	px.ServeHTTP(w, ctx.Request)
+
+	if w.Code != http.StatusRequestEntityTooLarge || w.Body.String() != `{"error":"payload too large"}` {
+		t.Errorf("response = %d %q, want the hook's 413", w.Code, w.Body.String())
+	}
+	if called {
+		t.Error("the refused request reached the upstream")
+	}
+}
+
+func TestProxy_ErrorHookSubstitute(t *testing.T) {
+	// A closed server: connections are refused
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+	service := &database.Service{ID: "svc", Name: "api", Protocol: "http", Host: u.Hostname(), Port: port, Enabled: true}
+	route := &database.Route{ID: "r1", ServiceID: "svc", Paths: []string{"/api"}, Enabled: true}
+	px := NewProxy(router.NewRouter([]*database.Route{route}, []*database.Service{service}, nil), nil, nil)
+
+	tests := []struct {
+		name       string
+		hook       plugin.ErrorHook
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "no hooks",
+			wantStatus: http.StatusBadGateway,
+			wantBody:   "bad gateway",
+		},
+		{
+			name:       "declined",
+			hook:       func(error) *http.Response { return nil },
+			wantStatus: http.StatusBadGateway,
+			wantBody:   "bad gateway",
+		},
+		{
+			name: "substituted",
+			hook: func(error) *http.Response {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"X-Served-Stale": {"true"}},
+					Body:       io.NopCloser(strings.NewReader("stale copy")),
+				}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "stale copy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx := plugin.NewContext(httptest.NewRequest("GET", "/api", nil), w, route, service, plugin.PhaseBeforeRequest)
+			if tt.hook != nil {
+				ctx.AddErrorHook(tt.hook)
+			}
+
+			px.ServeHTTP(w, ctx.Request)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestProxy_RelaysInformationalResponses(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</app.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Del("Link")
+		w.Header().Set("X-Expect", r.Header.Get("Expect"))
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+	service := &database.Service{ID: "svc", Name: "hints", Protocol: "http", Host: u.Hostname(), Port: port, Enabled: true}
+	route := &database.Route{ID: "r-hints", ServiceID: "svc", Paths: []string{"/api"}, Enabled: true}
+	px := NewProxy(router.NewRouter([]*database.Route{route}, []*database.Service{service}, nil), nil, nil)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Gateway", "1")
+		px.ServeHTTP(plugin.NewResponseWriter(w), r)
+	}))
+	defer gateway.Close()
+
+	var hints []http.Header
+	got100 := false
+	trace := &httptrace.ClientTrace{
+		Got100Continue: func() { got100 = true },
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = append(hints, http.Header(header))
+			}
+			return nil
+		},
+	}
+	client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: 5 * time.Second}}
+
+	req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), "POST", gateway.URL+"/api", strings.NewReader("body"))
+	req.Header.Set("Expect", "100-continue")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if len(hints) != 1 || hints[0].Get("Link") == "" {
+		t.Fatalf("early hints = %v, want one with the upstream's Link header", hints)
+	}
+	if hints[0].Get("X-Gateway") != "" {
+		t.Error("early hints should not carry headers meant for the final response")
+	}
+	if resp.Header.Get("X-Gateway") != "1" || resp.Header.Get("Link") != "" {
+		t.Errorf("final headers = %v, want the gateway's headers without the hints", resp.Header)
+	}
+	if !got100 {
+		t.Error("client should get 100 Continue")
+	}
+	if got := resp.Header.Get("X-Expect"); got != "100-continue" {
+		t.Errorf("upstream Expect = %q, want 100-continue", got)
+	}
+	if got := informationalTotal.Value("hints", "103"); got != 1 {
+		t.Errorf("relayed informational responses = %v, want 1", got)
+	}
+
+	// Without a body there is nothing to wait for
+	req, _ = http.NewRequest("GET", gateway.URL+"/api", nil)
+	req.Header.Set("Expect", "100-continue")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Expect"); got != "" {
+		t.Errorf("upstream Expect without a body = %q, want none", got)
+	}
+}
+
+func TestProxy_RangeRequests(t *testing.T) {
+	content := "0123456789abcdefghij"
+	modified := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "data.txt", modified, strings.NewReader(content))
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+	service := &database.Service{ID: "svc", Name: "ranges", Protocol: "http", Host: u.Hostname(), Port: port, Enabled: true}
+	route := &database.Route{ID: "r-range", ServiceID: "svc", Paths: []string{"/files", "/files/*"}, StripPath: true, Enabled: true}
+	px := NewProxy(router.NewRouter([]*database.Route{route}, []*database.Service{service}, nil), nil, nil)
+
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		wantStatus   int
+		wantBody     string
+		contentRange string
+	}{
+		{"single range", map[string]string{"Range": "bytes=2-5"}, http.StatusPartialContent, "2345", "bytes 2-5/20"},
+		{"suffix range", map[string]string{"Range": "bytes=-3"}, http.StatusPartialContent, "hij", "bytes 17-19/20"},
+		{"if-range match", map[string]string{"Range": "bytes=0-1", "If-Range": `"v1"`}, http.StatusPartialContent, "01", "bytes 0-1/20"},
+		{"if-range stale", map[string]string{"Range": "bytes=0-1", "If-Range": `"v0"`}, http.StatusOK, content, ""},
+		{"unsatisfiable", map[string]string{"Range": "bytes=50-60"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */20"},
+		{"multiple ranges", map[string]string{"Range": "bytes=0-1,4-5"}, http.StatusPartialContent, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/files/data.txt", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			px.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if got := w.Header().Get("Content-Range"); got != tt.contentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.contentRange)
+			}
+			if w.Code == http.StatusPartialContent && w.Header().Get("Content-Range") == "" {
+				if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "multipart/byteranges") {
+					t.Errorf("Content-Type = %q, want multipart/byteranges", ct)
+				}
+				if !strings.Contains(w.Body.String(), "Content-Range: bytes 4-5/20") {
+					t.Errorf("multipart body is missing the second part: %q", w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestProxy_AbortsTruncatedResponses(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+	service := &database.Service{ID: "svc", Name: "api", Protocol: "http", Host: u.Hostname(), Port: port, Enabled: true}
+	route := &database.Route{ID: "r-truncated", ServiceID: "svc", Paths: []string{"/api"}, Enabled: true}
+	px := NewProxy(router.NewRouter([]*database.Route{route}, []*database.Service{service}, nil), nil, nil)
+
+	w := httptest.NewRecorder()
+	ctx := plugin.NewContext(httptest.NewRequest("GET", "/api", nil), w, route, service, plugin.PhaseBeforeRequest)
+	ctx.AddResponseHook(func(resp *http.Response) error {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(io.LimitReader(resp.Body, 4), iotest.ErrReader(errors.New("cut"))), resp.Body}
+		return nil
+	})
+
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", recovered)
+		}
+		if w.Body.String() != "0123" {
+			t.Errorf("body = %q, want the 4 bytes copied before the failure", w.Body.String())
+		}
+		if got := responseBytes.Value("r-truncated"); got != 4 {
+			t.Errorf("response bytes = %v, want 4", got)
+		}
+	}()
+	px.ServeHTTP(w, ctx.Request)
+}
+
+func TestProxy_TracesUpstreamConnections(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+	service := &database.Service{ID: "svc", Name: "traced", Protocol: "http", Host: u.Hostname(), Port: port, Enabled: true}
+	route := &database.Route{ID: "r-traced", ServiceID: "svc", Paths: []string{"/api"}, Enabled: true}
+	px := NewProxy(router.NewRouter([]*database.Route{route}, []*database.Service{service}, nil), nil, nil)
+	px.SetSlowUpstreamConfig(SlowUpstreamConfig{TTFBThreshold: 10 * time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		px.ServeHTTP(w, httptest.NewRequest("GET", "/api", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d", i, w.Code)
+		}
+	}
+
+	if got := upstreamConnections.Value("traced", "false"); got != 1 {
+		t.Errorf("new connections = %v, want 1", got)
+	}
+	if got := upstreamConnections.Value("traced", "true"); got != 1 {
+		t.Errorf("reused connections = %v, want 1", got)
+	}
+	if got := upstreamDial.Count("traced"); got != 1 {
+		t.Errorf("dial observations = %d, want 1", got)
+	}
+	if got := upstreamTTFB.Count("traced"); got != 2 {
+		t.Errorf("ttfb observations = %d, want 2", got)
+	}
+	if got := upstreamSlow.Value("traced", "ttfb"); got != 2 {
+		t.Errorf("slow responses = %v, want 2", got)
+	}
+	if got := upstreamSlow.Value("traced", "dial"); got != 0 {
+		t.Errorf("slow dials = %v, want 0 (threshold disabled)", got)
+	}
+}
+
+func TestProxy_TimingHeader(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(TimingRequestHeader) != "" {
+			t.Error("X-Debug-Timing should not be forwarded")
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+	service := &database.Service{ID: "svc", Name: "timed", Protocol: "http", Host: u.Hostname(), Port: port, Enabled: true}
+	route := &database.Route{ID: "r-timed", ServiceID: "svc", Paths: []string{"/api"}, Enabled: true}
+	px := NewProxy(router.NewRouter([]*database.Route{route}, []*database.Service{service}, nil), nil, nil)
+	trusted, _ := clientip.NewResolver([]string{"10.0.0.0/8"})
+	px.SetTimingConfig(TimingConfig{TrustedIPs: trusted})
+
+	serve := func(remoteAddr string, debug, viaPlugin bool) string {
+		r := httptest.NewRequest("GET", "/api", nil)
+		r.RemoteAddr = remoteAddr
+		if debug {
+			r.Header.Set(TimingRequestHeader, "1")
+		}
+		w := httptest.NewRecorder()
+		if viaPlugin {
+			ctx := plugin.NewContext(r, w, route, service, plugin.PhaseBeforeRequest)
+			ctx.RequestTiming()
+			r = ctx.Request
+		}
+		px.ServeHTTP(w, r)
+		return w.Header().Get(TimingHeader)
+	}
+
+	// First request dials, so connect is reported; reused connections aren't
+	if got := serve("10.1.2.3:5000", true, false); !strings.Contains(got, "connect=") || !strings.Contains(got, ";total=") {
+		t.Errorf("trusted client: X-Timing = %q, want connect and total", got)
+	}
+	if got := serve("10.1.2.3:5000", true, false); strings.Contains(got, "connect=") || !strings.HasPrefix(got, "ttfb=") {
+		t.Errorf("reused connection: X-Timing = %q, want ttfb and total only", got)
+	}
+	if got := serve("203.0.113.9:5000", true, false); got != "" {
+		t.Errorf("untrusted client: X-Timing = %q, want none", got)
+	}
+	if got := serve("203.0.113.9:5000", false, true); got == "" {
+		t.Error("route plugin: X-Timing missing")
+	}
+}
+
+func TestUpstreamTrace_TimingHeaders(t *testing.T) {
+	trace := &upstreamTrace{dns: 2100 * time.Microsecond, connect: 5 * time.Millisecond, ttfb: 80449 * time.Microsecond}
+	total := 95 * time.Millisecond
+
+	if got, want := trace.header(total), "dns=2;connect=5;ttfb=80;total=95"; got != want {
+		t.Errorf("header() = %q, want %q", got, want)
+	}
+	want := "upstream-dns;dur=2.1, upstream-connect;dur=5.0, upstream-ttfb;dur=80.4, upstream-total;dur=95.0"
+	if got := trace.serverTiming(total); got != want {
+		t.Errorf("serverTiming() = %q, want %q", got, want)
+	}
+	if got := (&upstreamTrace{}).serverTiming(time.Millisecond); got != "upstream-total;dur=1.0" {
+		t.Errorf("serverTiming() without phases = %q", got)
+	}
+}
+
+func TestProxy_HedgesSlowRequests(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(500 * time.Millisecond):
+			w.Write([]byte("slow"))
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast"))
+	}))
+	defer fast.Close()
+
+	service := &database.Service{ID: "svc", Name: "hedged", Protocol: "http", LoadBalancerType: "round-robin", Enabled: true}
+	route := &database.Route{ID: "r-hedge", ServiceID: "svc", Paths: []string{"/api"}, Enabled: true}
+	balancers := loadbalancer.NewManager(nil)
+	balancers.Update([]*database.Service{service}, []*database.ServiceTarget{
+		{ID: "t1", ServiceID: "svc", Target: strings.TrimPrefix(slow.URL, "http://"), Enabled: true},
+		{ID: "t2", ServiceID: "svc", Target: strings.TrimPrefix(fast.URL, "http://"), Enabled: true},
+	})
+	px := NewProxy(router.NewRouter([]*database.Route{route}, []*database.Service{service}, nil), nil, balancers)
+	px.SetRetryBudget(RetryBudgetConfig{Ratio: 1, MinPerSecond: 10})
+
+	// Recent latencies put the hedge delay at its 50ms minimum
+	for i := 0; i < hedgeMinSamples; i++ {
+		px.hedgeLatency.get("r-hedge").record(5 * time.Millisecond)
+	}
+
+	serve := func(method string) (string, time.Duration) {
+		r := httptest.NewRequest(method, "/api", nil)
+		w := httptest.NewRecorder()
+		ctx := plugin.NewContext(r, w, route, service, plugin.PhaseBeforeRequest)
+		ctx.Hedge(plugin.HedgePolicy{Percentile: 0.5, MinDelay: 50 * time.Millisecond, MaxDelay: time.Second})
+		start := time.Now()
+		px.ServeHTTP(w, ctx.Request)
+		return w.Body.String(), time.Since(start)
+	}
+
+	// Requests sent to the slow target first are answered by the hedge to
+	// the fast one
+	for i := 0; i < 2; i++ {
+		if body, elapsed := serve("GET"); body != "fast" || elapsed > 400*time.Millisecond {
+			t.Errorf("request %d: body %q after %v, want the fast target's response", i, body, elapsed)
+		}
+	}
+	if got := hedgesTotal.Value("r-hedge", "won"); got < 1 {
+		t.Errorf("hedges won = %v, want at least 1", got)
+	}
+
+	// Only idempotent requests are hedged
+	bodies := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		body, _ := serve("DELETE")
+		bodies[body] = true
+	}
+	if !bodies["slow"] {
+		t.Error("DELETE should not be hedged")
+	}
+
+	// An exhausted budget sends no hedge
+	px.SetRetryBudget(RetryBudgetConfig{})
+	bodies = map[string]bool{}
+	for i := 0; i < 2; i++ {
+		body, _ := serve("GET")
+		bodies[body] = true
+	}
+	if !bodies["slow"] {
+		t.Error("without budget the slow target's response should be used")
+	}
+	if got := hedgesTotal.Value("r-hedge", "budget"); got < 1 {
+		t.Errorf("hedges denied by budget = %v, want at least 1", got)
+	}
+}
+
+func TestProxy_HedgePolicy(t *testing.T) {
+	policy := plugin.HedgePolicy{Percentile: 0.9}
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		hedge  bool // a plugin asked for hedging
+		want   bool
+	}{
+		{name: "get", method: "GET", hedge: true, want: true},
+		{name: "head", method: "HEAD", hedge: true, want: true},
+		{name: "post", method: "POST", hedge: true},
+		{name: "put", method: "PUT", hedge: true},
+		{name: "delete", method: "DELETE", hedge: true},
+		{name: "get with a body", method: "GET", body: "{}", hedge: true},
+		{name: "not requested", method: "GET"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			ctx := plugin.NewContext(httptest.NewRequest(tt.method, "/api", body), httptest.NewRecorder(), &database.Route{}, &database.Service{}, plugin.PhaseBeforeRequest)
+			if tt.hedge {
+				ctx.Hedge(policy)
+			}
+			if _, got := hedgePolicy(ctx.Request); got != tt.want {
+				t.Errorf("hedgePolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newHedgedProxy returns a proxy whose route r-hedge is balanced
+// round-robin across the servers and hedges after 10ms.
+func newHedgedProxy(t *testing.T, servers ...*httptest.Server) (*Proxy, func(method string) *httptest.ResponseRecorder) {
+	t.Helper()
+	service := &database.Service{ID: "svc", Name: "hedged", Protocol: "http", LoadBalancerType: "round-robin", Enabled: true}
+	route := &database.Route{ID: "r-hedge", ServiceID: "svc", Paths: []string{"/api"}, Enabled: true}
+	var targets []*database.ServiceTarget
+	for i, server := range servers {
+		targets = append(targets, &database.ServiceTarget{ID: "t" + strconv.Itoa(i), ServiceID: "svc", Target: strings.TrimPrefix(server.URL, "http://"), Enabled: true})
+	}
+	balancers := loadbalancer.NewManager(nil)
+	balancers.Update([]*database.Service{service}, targets)
+	px := NewProxy(router.NewRouter([]*database.Route{route}, []*database.Service{service}, nil), nil, balancers)
+	px.SetRetryBudget(RetryBudgetConfig{Ratio: 1, MinPerSecond: 1000})
+	for i := 0; i < hedgeMinSamples; i++ {
+		px.hedgeLatency.get("r-hedge").record(time.Millisecond)
+	}
+
+	serve := func(method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx := plugin.NewContext(httptest.NewRequest(method, "/api", nil), w, route, service, plugin.PhaseBeforeRequest)
+		ctx.Hedge(plugin.HedgePolicy{Percentile: 0.5, MinDelay: 10 * time.Millisecond, MaxDelay: time.Second})
+		px.ServeHTTP(w, ctx.Request)
+		return w
+	}
+	return px, serve
+}
+
+func TestProxy_HedgeCancelsLoser(t *testing.T) {
+	cancelled := make(chan struct{}, 2)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+			w.Write([]byte("slow"))
+		case <-r.Context().Done():
+			cancelled <- struct{}{}
+		}
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast"))
+	}))
+	defer fast.Close()
+	_, serve := newHedgedProxy(t, slow, fast)
+
+	// One of the two requests starts on the slow target, which the hedge
+	// beats; the slow attempt is then cancelled, not left running
+	for i := 0; i < 2; i++ {
+		if w := serve("GET"); w.Body.String() != "fast" {
+			t.Fatalf("request %d: body %q, want the fast target's", i, w.Body.String())
+		}
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("losing attempt not cancelled")
+	}
+}
+
+func TestProxy_HedgeAbandonClosesLateResponses(t *testing.T) {
+	closed := make(chan struct{}, 2)
+	results := make(chan hedgeResult, 2)
+	results <- hedgeResult{err: errors.New("connection reset")}
+	results <- hedgeResult{resp: &http.Response{Body: &attemptBody{
+		ReadCloser: io.NopCloser(strings.NewReader("late")),
+		done:       func() { closed <- struct{}{} },
+	}}}
+
+	abandon(results, 2)
+	if len(closed) != 1 {
+		t.Errorf("closed %d late responses, want 1", len(closed))
+	}
+}
+
+// Run with -race: both targets answer at about the same time, so the
+// primary and the hedge race for every request.
+func TestProxy_HedgeConcurrentRequests(t *testing.T) {
+	target := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(30 * time.Millisecond):
+				w.Write([]byte(name))
+			case <-r.Context().Done():
+			}
+		}))
+	}
+	a, b := target("a"), target("b")
+	defer a.Close()
+	defer b.Close()
+	_, serve := newHedgedProxy(t, a, b)
+
+	// Every request is hedged, unless round-robin hands the hedge the
+	// primary's target while other requests interleave
+	decisions := func() (sent, total float64) {
+		sent = hedgesTotal.Value("r-hedge", "won") + hedgesTotal.Value("r-hedge", "lost")
+		return sent, sent + hedgesTotal.Value("r-hedge", "no_target")
+	}
+	sentBefore, totalBefore := decisions()
+	const requests = 20
+	bodies := make(chan string, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := serve("GET")
+			if w.Code != http.StatusOK {
+				t.Errorf("status = %d, want 200", w.Code)
+			}
+			bodies <- w.Body.String()
+		}()
+	}
+	wg.Wait()
+	close(bodies)
+
+	for body := range bodies {
+		if body != "a" && body != "b" {
+			t.Errorf("body = %q, want one target's whole response", body)
+		}
+	}
+	sent, total := decisions()
+	if total-totalBefore != requests || sent == sentBefore {
+		t.Errorf("hedge decisions = %v with %v hedges sent, want %d with at least one sent", total-totalBefore, sent-sentBefore, requests)
+	}
+}
+
+func TestProxy_RequestDeadline(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(300 * time.Millisecond):
+			w.Write([]byte("slow"))
+		case <-r.Context().Done():
+		}
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+	service := &database.Service{ID: "svc", Name: "api", Protocol: "http", Host: u.Hostname(), Port: port, ReadTimeoutMs: 50, Enabled: true}
+	short := &database.Route{ID: "r-short", ServiceID: "svc", Paths: []string{"/short"}, Enabled: true}
+	long := &database.Route{ID: "r-long", ServiceID: "svc", Paths: []string{"/long"}, TimeoutMs: 2000, Enabled: true}
+	px := NewProxy(router.NewRouter([]*database.Route{short, long}, []*database.Service{service}, nil), nil, nil)
+
+	// Without a timeout of its own, the route gets the service's
+	w := httptest.NewRecorder()
+	px.ServeHTTP(w, httptest.NewRequest("GET", "/short", nil))
+	if w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), "gateway timeout") {
+		t.Errorf("/short = %d %q, want 504 gateway timeout", w.Code, w.Body.String())
+	}
+
+	// The route's timeout replaces the service's
+	w = httptest.NewRecorder()
+	px.ServeHTTP(w, httptest.NewRequest("GET", "/long", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "slow" {
+		t.Errorf("/long = %d %q, want 200 slow", w.Code, w.Body.String())
+	}
+
+	// A deadline set at match time counts the time spent before the proxy
+	r := httptest.NewRequest("GET", "/long", nil)
+	ctx, cancel := context.WithTimeoutCause(r.Context(), 20*time.Millisecond, deadline.ErrExceeded)
+	defer cancel()
+	w = httptest.NewRecorder()
+	px.ServeHTTP(w, r.WithContext(ctx))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("/long with an earlier deadline = %d, want 504", w.Code)
+	}
+}
+
+func TestProxy_EgressPolicy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+	service := &database.Service{ID: "svc", Name: "internal", Protocol: "http", Host: "localhost", Port: port, Enabled: true}
+	route := &database.Route{ID: "r-egress", ServiceID: "svc", Paths: []string{"/api"}, Enabled: true}
+
+	serve := func(cfg egress.Config) int {
+		policy, err := egress.NewPolicy(cfg)
+		if err != nil {
+			t.Fatalf("NewPolicy() error = %v", err)
+		}
+		px := NewProxy(router.NewRouter([]*database.Route{route}, []*database.Service{service}, nil), NewTransport(&TransportConfig{Egress: policy}), nil)
+		w := httptest.NewRecorder()
+		px.ServeHTTP(w, httptest.NewRequest("GET", "/api", nil))
+		return w.Code
+	}
+
+	// localhost resolves to loopback, which is refused at dial time
+	if code := serve(egress.Config{}); code != http.StatusForbidden {
+		t.Errorf("loopback upstream: status = %d, want 403", code)
+	}
+	if code := serve(egress.Config{AllowCIDRs: []string{"loopback"}}); code != http.StatusOK {
+		t.Errorf("allowed loopback upstream: status = %d, want 200", code)
+	}
+	if code := serve(egress.Config{AllowCIDRs: []string{"loopback"}, AllowHosts: []string{"api.example.com"}}); code != http.StatusForbidden {
+		t.Errorf("host not allowed: status = %d, want 403", code)
+	}
+}
+
+func TestUpstreamDialer_Order(t *testing.T) {
+	v4, v6 := net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")
+	tests := []struct {
+		family           string
+		ips              []net.IP
+		primary, backups int // addresses dialed first / as fallback
+		firstV4          bool
+	}{
+		{IPFamilyAuto, []net.IP{v6, v4}, 1, 1, false},
+		{IPFamilyIPv4, []net.IP{v6, v4}, 1, 0, true},
+		{IPFamilyIPv6, []net.IP{v6, v4}, 1, 0, false},
+		{IPFamilyPreferIPv4, []net.IP{v6, v4}, 1, 1, true},
+		{IPFamilyPreferIPv6, []net.IP{v4}, 1, 0, true},
+		{IPFamilyIPv6, []net.IP{v4}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		u := &upstreamDialer{family: tt.family}
+		primaries, fallbacks := u.order(tt.ips, tt.family)
+		if len(primaries) != tt.primary || len(fallbacks) != tt.backups {
+			t.Errorf("order(%s) = %v, %v, want %d primary and %d fallback addresses", tt.family, primaries, fallbacks, tt.primary, tt.backups)
+			continue
+		}
+		if tt.primary > 0 && (primaries[0].To4() != nil) != tt.firstV4 {
+			t.Errorf("order(%s) dials %s first", tt.family, primaries[0])
+		}
+	}
+}
+
+func TestUpstreamDialer_HappyEyeballs(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	// 127.0.0.2 stands in for a broken family: it hangs, or fails at once
+	primaries := []net.IP{net.ParseIP("127.0.0.2")}
+	fallbacks := []net.IP{net.ParseIP("127.0.0.1")}
+	dialer := func(broken func() error) *net.Dialer {
+		return &net.Dialer{Control: func(_, address string, _ syscall.RawConn) error {
+			if strings.HasPrefix(address, "127.0.0.2:") {
+				return broken()
+			}
+			return nil
+		}}
+	}
+
+	u := &upstreamDialer{delay: 20 * time.Millisecond}
+	start := time.Now()
+	conn, err := u.race(context.Background(), dialer(func() error {
+		time.Sleep(time.Second)
+		return errors.New("timed out")
+	}), "tcp", port, primaries, fallbacks)
+	if err != nil {
+		t.Fatalf("race() with a hanging primary error = %v", err)
+	}
+	conn.Close()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("race() took %v, want the fallback after the delay", elapsed)
+	}
+
+	u = &upstreamDialer{delay: time.Hour}
+	conn, err = u.race(context.Background(), dialer(func() error {
+		return errors.New("network unreachable")
+	}), "tcp", port, primaries, fallbacks)
+	if err != nil {
+		t.Fatalf("race() with a failing primary error = %v", err)
+	}
+	conn.Close()
+
+	conn, err = u.race(context.Background(), dialer(func() error {
+		return errors.New("network unreachable")
+	}), "tcp", port, primaries, nil)
+	if err == nil {
+		conn.Close()
+		t.Error("race() without fallback addresses succeeded, want the primary's error")
+	}
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/clientip"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// Timing headers. Clients from trusted IPs send TimingRequestHeader: 1 to
+// get TimingHeader and ServerTimingHeader on the response; routes with the
+// upstream-timing plugin always get them.
+const (
+	TimingHeader        = "X-Timing"
+	ServerTimingHeader  = "Server-Timing"
+	TimingRequestHeader = "X-Debug-Timing"
+)
+
+// TimingConfig controls who may request the X-Timing header.
+type TimingConfig struct {
+	// TrustedIPs may request timing with X-Debug-Timing (nil = nobody)
+	TrustedIPs *clientip.Resolver
+}
+
+// SetTimingConfig configures X-Timing requests. Must be called before
+// serving.
+func (p *Proxy) SetTimingConfig(cfg TimingConfig) {
+	p.timing = cfg
+}
+
+// wantsTiming reports whether the response to r gets the timing headers.
+func (p *Proxy) wantsTiming(r *http.Request) bool {
+	if plugin.TimingRequested(r) {
+		return true
+	}
+	if r.Header.Get(TimingRequestHeader) != "1" {
+		return false
+	}
+	return p.timing.TrustedIPs.Trusted(p.clientIP(r))
+}
+
+// timingPhase is a named upstream latency phase.
+type timingPhase struct {
+	name string
+	d    time.Duration
+}
+
+// phases returns the trace's phases that happened, then total.
+func (t *upstreamTrace) phases(total time.Duration) []timingPhase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var phases []timingPhase
+	for _, phase := range []timingPhase{{"dns", t.dns}, {"connect", t.connect}, {"tls", t.tls}, {"ttfb", t.ttfb}} {
+		if phase.d > 0 {
+			phases = append(phases, phase)
+		}
+	}
+	return append(phases, timingPhase{"total", total})
+}
+
+// header formats the trace's phases in milliseconds, e.g.
+// "dns=2;connect=5;tls=12;ttfb=80;total=95". Phases that didn't happen
+// are left out.
+func (t *upstreamTrace) header(total time.Duration) string {
+	var parts []string
+	for _, phase := range t.phases(total) {
+		parts = append(parts, phase.name+"="+strconv.FormatInt(phase.d.Milliseconds(), 10))
+	}
+	return strings.Join(parts, ";")
+}
+
+// serverTiming formats the same phases as Server-Timing metrics, which
+// browser developer tools display, e.g.
+// "upstream-dns;dur=2.1, upstream-ttfb;dur=80.4, upstream-total;dur=95.0".
+func (t *upstreamTrace) serverTiming(total time.Duration) string {
+	var parts []string
+	for _, phase := range t.phases(total) {
+		ms := float64(phase.d) / float64(time.Millisecond)
+		parts = append(parts, "upstream-"+phase.name+";dur="+strconv.FormatFloat(ms, 'f', 1, 64))
+	}
+	return strings.Join(parts, ", ")
+}
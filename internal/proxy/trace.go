@@ -0,0 +1,282 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// Upstream connection metrics, recorded from an httptrace.ClientTrace on
+// every upstream request. Together they split upstream latency into the
+// gateway's share (waiting for a pooled connection, dialing, TLS) and the
+// backend's (time to first byte once the request is written).
+var (
+	upstreamConnections = metrics.NewCounterVec(
+		"gateway_upstream_connections_total",
+		"Upstream connections obtained per request, by service and whether a pooled connection was reused.",
+		"service", "reused",
+	)
+	upstreamConnWait = metrics.NewHistogramVec(
+		"gateway_upstream_conn_wait_seconds",
+		"Time from asking the pool for an upstream connection to getting one, including any dial and TLS handshake.",
+		nil,
+		"service",
+	)
+	upstreamDial = metrics.NewHistogramVec(
+		"gateway_upstream_dial_seconds",
+		"Time to resolve and connect to an upstream for new connections.",
+		nil,
+		"service",
+	)
+	upstreamTLSHandshake = metrics.NewHistogramVec(
+		"gateway_upstream_tls_handshake_seconds",
+		"Upstream TLS handshake time for new connections.",
+		nil,
+		"service",
+	)
+	upstreamTTFB = metrics.NewHistogramVec(
+		"gateway_upstream_ttfb_seconds",
+		"Time from writing the request to the first response byte from the upstream.",
+		nil,
+		"service",
+	)
+	upstreamSlow = metrics.NewCounterVec(
+		"gateway_upstream_slow_total",
+		"Upstream dials and responses over the slow thresholds, by service and phase (dial, ttfb).",
+		"service", "phase",
+	)
+)
+
+// slowLogInterval limits slow-upstream warnings to one per service and
+// phase per interval; the rest are counted and reported with the next one.
+const slowLogInterval = 10 * time.Second
+
+// SlowUpstreamConfig sets when an upstream counts as slow. Zero disables
+// a check.
+type SlowUpstreamConfig struct {
+	// DialThreshold applies to connecting to the upstream (DNS + TCP)
+	DialThreshold time.Duration
+
+	// TTFBThreshold applies to the wait for the first response byte after
+	// the request was written
+	TTFBThreshold time.Duration
+}
+
+// SetSlowUpstreamConfig configures slow-upstream warnings. Must be called
+// before serving.
+func (p *Proxy) SetSlowUpstreamConfig(cfg SlowUpstreamConfig) {
+	p.slow = newSlowUpstream(cfg)
+}
+
+// slowUpstream detects slow dials and responses and logs them, throttled
+// per service and phase.
+type slowUpstream struct {
+	cfg SlowUpstreamConfig
+
+	mu     sync.Mutex
+	warned map[string]*slowWarning // keyed by service + "/" + phase
+}
+
+type slowWarning struct {
+	last       time.Time
+	suppressed int
+}
+
+func newSlowUpstream(cfg SlowUpstreamConfig) *slowUpstream {
+	return &slowUpstream{cfg: cfg, warned: make(map[string]*slowWarning)}
+}
+
+// check reports how long a phase ("dial" or "ttfb") took, warning if it's
+// over the phase's threshold.
+func (s *slowUpstream) check(service, phase, addr string, d time.Duration) {
+	if s == nil {
+		return
+	}
+	threshold := s.cfg.DialThreshold
+	if phase == "ttfb" {
+		threshold = s.cfg.TTFBThreshold
+	}
+	if threshold <= 0 || d <= threshold {
+		return
+	}
+	upstreamSlow.Inc(service, phase)
+
+	key := service + "/" + phase
+	now := time.Now()
+	s.mu.Lock()
+	w, ok := s.warned[key]
+	if !ok {
+		w = &slowWarning{}
+		s.warned[key] = w
+	}
+	if now.Sub(w.last) < slowLogInterval {
+		w.suppressed++
+		s.mu.Unlock()
+		return
+	}
+	suppressed := w.suppressed
+	w.last, w.suppressed = now, 0
+	s.mu.Unlock()
+
+	log.Warn().
+		Str("component", "proxy").
+		Str("service_name", service).
+		Str("phase", phase).
+		Str("upstream_addr", addr).
+		Dur("duration_ms", d).
+		Dur("threshold_ms", threshold).
+		Int("suppressed", suppressed).
+		Msg("Slow upstream")
+}
+
+// upstreamTrace records one upstream request's connection timings.
+//
+// Hooks can run on the transport's dial and read goroutines, and a dial
+// may race several addresses, so all state is guarded by mu.
+type upstreamTrace struct {
+	service string
+	slow    *slowUpstream
+
+	mu           sync.Mutex
+	getConn      time.Time
+	dialStart    time.Time
+	dialed       bool
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	addr         string
+	wrote        time.Time
+
+	// Phase durations for the X-Timing header; zero if the phase didn't
+	// happen (e.g. no dial on a reused connection)
+	dns, connect, tls, ttfb time.Duration
+}
+
+// withUpstreamTrace returns ctx carrying a client trace for service, and
+// the trace.
+func (p *Proxy) withUpstreamTrace(ctx context.Context, service string) (context.Context, *upstreamTrace) {
+	t := &upstreamTrace{service: service, slow: p.slow}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn:              t.onGetConn,
+		GotConn:              t.onGotConn,
+		DNSStart:             t.onDNSStart,
+		DNSDone:              t.onDNSDone,
+		ConnectStart:         t.onConnectStart,
+		ConnectDone:          t.onConnectDone,
+		TLSHandshakeStart:    t.onTLSHandshakeStart,
+		TLSHandshakeDone:     t.onTLSHandshakeDone,
+		WroteRequest:         t.onWroteRequest,
+		GotFirstResponseByte: t.onGotFirstResponseByte,
+	}), t
+}
+
+func (t *upstreamTrace) onGetConn(string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.getConn = time.Now()
+}
+
+func (t *upstreamTrace) onGotConn(info httptrace.GotConnInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	reused := "false"
+	if info.Reused {
+		reused = "true"
+	}
+	upstreamConnections.Inc(t.service, reused)
+	if !t.getConn.IsZero() {
+		upstreamConnWait.Observe(time.Since(t.getConn).Seconds(), t.service)
+	}
+	if info.Conn != nil {
+		t.addr = info.Conn.RemoteAddr().String()
+	}
+}
+
+func (t *upstreamTrace) onDNSStart(httptrace.DNSStartInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dnsStart = time.Now()
+	if t.dialStart.IsZero() {
+		t.dialStart = t.dnsStart
+	}
+}
+
+func (t *upstreamTrace) onDNSDone(httptrace.DNSDoneInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.dnsStart.IsZero() {
+		t.dns = time.Since(t.dnsStart)
+	}
+}
+
+func (t *upstreamTrace) onConnectStart(string, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if t.connectStart.IsZero() {
+		t.connectStart = now
+	}
+	if t.dialStart.IsZero() {
+		t.dialStart = now
+	}
+}
+
+func (t *upstreamTrace) onConnectDone(_, addr string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Only the first successful address of a racing dial counts
+	if err != nil || t.dialed || t.dialStart.IsZero() {
+		return
+	}
+	t.dialed = true
+	t.connect = time.Since(t.connectStart)
+	d := time.Since(t.dialStart)
+	upstreamDial.Observe(d.Seconds(), t.service)
+	t.slow.check(t.service, "dial", addr, d)
+}
+
+func (t *upstreamTrace) onTLSHandshakeStart() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tlsStart = time.Now()
+}
+
+func (t *upstreamTrace) onTLSHandshakeDone(_ tls.ConnectionState, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil || t.tlsStart.IsZero() {
+		return
+	}
+	t.tls = time.Since(t.tlsStart)
+	upstreamTLSHandshake.Observe(t.tls.Seconds(), t.service)
+}
+
+func (t *upstreamTrace) onWroteRequest(info httptrace.WroteRequestInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if info.Err == nil {
+		t.wrote = time.Now()
+	}
+}
+
+func (t *upstreamTrace) onGotFirstResponseByte() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Upstreams may answer before the body is written (e.g. an early 413);
+	// there is no backend time to measure then
+	if t.wrote.IsZero() {
+		return
+	}
+	t.ttfb = time.Since(t.wrote)
+	upstreamTTFB.Observe(t.ttfb.Seconds(), t.service)
+	t.slow.check(t.service, "ttfb", t.addr, t.ttfb)
+}
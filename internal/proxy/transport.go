@@ -5,12 +5,21 @@
 package proxy
 
 import (
+	"context"
 	"crypto/tls"
+	"fmt"
 	"net"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/dnscache"
+	"github.com/saidutt46/switchboard-gateway/internal/egress"
 )
 
 // TransportConfig holds configuration for the HTTP transport.
@@ -30,6 +39,23 @@ type TransportConfig struct {
 
 	// TLS
 	InsecureSkipVerify bool
+
+	// Egress restricts which addresses upstream connections may be opened
+	// to (nil = any)
+	Egress *egress.Policy
+
+	// DNSCache resolves upstream hosts (nil = the system resolver on every
+	// dial)
+	DNSCache *dnscache.Resolver
+
+	// IPFamily picks the address family dialed first: auto, ipv4, ipv6,
+	// prefer_ipv4 or prefer_ipv6. Services may override it.
+	IPFamily string
+
+	// HappyEyeballsDelay is how long a dial waits on the first family
+	// before racing the other (RFC 6555). Zero tries them one after the
+	// other.
+	HappyEyeballsDelay time.Duration
 }
 
 // DefaultTransportConfig returns a production-ready transport configuration.
@@ -50,6 +76,10 @@ func DefaultTransportConfig() *TransportConfig {
 
 		// TLS - verify certificates by default
 		InsecureSkipVerify: false,
+
+		// Dialing
+		IPFamily:           IPFamilyAuto,
+		HappyEyeballsDelay: 300 * time.Millisecond,
 	}
 }
 
@@ -65,6 +95,22 @@ func NewTransport(cfg *TransportConfig) *http.Transport {
 		cfg = DefaultTransportConfig()
 	}
 
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+	family := cfg.IPFamily
+	if family == "" {
+		family = IPFamilyAuto
+	}
+	upstream := &upstreamDialer{family: family, delay: cfg.HappyEyeballsDelay, cache: cfg.DNSCache}
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		return upstream.dial(ctx, dialer, network, address)
+	}
+	if cfg.Egress != nil {
+		dial = cfg.Egress.DialContext(dialer, upstream.dial)
+	}
+
 	transport := &http.Transport{
 		// Connection pool settings
 		MaxIdleConns:        cfg.MaxIdleConns,
@@ -75,10 +121,7 @@ func NewTransport(cfg *TransportConfig) *http.Transport {
 		IdleConnTimeout: cfg.IdleConnTimeout,
 
 		// Dialer for establishing connections
-		DialContext: (&net.Dialer{
-			Timeout:   cfg.DialTimeout,
-			KeepAlive: cfg.KeepAlive,
-		}).DialContext,
+		DialContext: dial,
 
 		// TLS configuration
 		TLSClientConfig: &tls.Config{
@@ -103,7 +146,202 @@ func NewTransport(cfg *TransportConfig) *http.Transport {
 		Int("max_idle_conns", cfg.MaxIdleConns).
 		Int("max_idle_conns_per_host", cfg.MaxIdleConnsPerHost).
 		Dur("idle_conn_timeout", cfg.IdleConnTimeout).
+		Bool("egress_policy", cfg.Egress != nil).
+		Bool("dns_cache", cfg.DNSCache != nil).
+		Str("ip_family", family).
+		Dur("happy_eyeballs_delay", cfg.HappyEyeballsDelay).
 		Msg("HTTP transport configured")
 
 	return transport
 }
+
+// Runtime transport settings (gateway_settings keys) and the
+// TransportConfig fields they override.
+var transportSettings = map[string]func(cfg *TransportConfig, value string) error{
+	"upstream_max_idle_conns":          intSetting(func(c *TransportConfig) *int { return &c.MaxIdleConns }),
+	"upstream_max_idle_conns_per_host": intSetting(func(c *TransportConfig) *int { return &c.MaxIdleConnsPerHost }),
+	"upstream_max_conns_per_host":      intSetting(func(c *TransportConfig) *int { return &c.MaxConnsPerHost }),
+	"upstream_dial_timeout":            durationSetting(func(c *TransportConfig) *time.Duration { return &c.DialTimeout }),
+	"upstream_keep_alive":              durationSetting(func(c *TransportConfig) *time.Duration { return &c.KeepAlive }),
+	"upstream_idle_conn_timeout":       durationSetting(func(c *TransportConfig) *time.Duration { return &c.IdleConnTimeout }),
+	"upstream_tls_handshake_timeout":   durationSetting(func(c *TransportConfig) *time.Duration { return &c.TLSHandshakeTimeout }),
+	"upstream_response_header_timeout": durationSetting(func(c *TransportConfig) *time.Duration { return &c.ResponseHeaderTimeout }),
+	"upstream_expect_continue_timeout": durationSetting(func(c *TransportConfig) *time.Duration { return &c.ExpectContinueTimeout }),
+	"upstream_happy_eyeballs_delay":    durationSetting(func(c *TransportConfig) *time.Duration { return &c.HappyEyeballsDelay }),
+	"upstream_ip_family":               ipFamilySetting,
+}
+
+// WithSettings returns a copy of cfg with runtime settings applied on top.
+//
+// Keys are the lower-cased environment variable names
+// ("upstream_max_idle_conns", "upstream_dial_timeout", ...); counts are
+// integers and timeouts are Go durations ("30s"). TLS verification can't be
+// changed at runtime. Unknown keys are skipped with a warning so settings
+// written for newer gateways don't block a reload.
+func (cfg TransportConfig) WithSettings(settings map[string]string) (*TransportConfig, error) {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		apply, ok := transportSettings[key]
+		if !ok {
+			log.Warn().
+				Str("component", "proxy").
+				Str("setting", key).
+				Msg("Ignoring unknown transport setting")
+			continue
+		}
+		if err := apply(&cfg, settings[key]); err != nil {
+			return nil, fmt.Errorf("invalid setting %s: %w", key, err)
+		}
+	}
+	return &cfg, nil
+}
+
+func intSetting(field func(*TransportConfig) *int) func(*TransportConfig, string) error {
+	return func(cfg *TransportConfig, value string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("not an integer: %q", value)
+		}
+		if n < 0 {
+			return fmt.Errorf("cannot be negative")
+		}
+		*field(cfg) = n
+		return nil
+	}
+}
+
+func durationSetting(field func(*TransportConfig) *time.Duration) func(*TransportConfig, string) error {
+	return func(cfg *TransportConfig, value string) error {
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("not a duration: %q", value)
+		}
+		if d < 0 {
+			return fmt.Errorf("cannot be negative")
+		}
+		*field(cfg) = d
+		return nil
+	}
+}
+
+func ipFamilySetting(cfg *TransportConfig, value string) error {
+	family := strings.ToLower(strings.TrimSpace(value))
+	if !ValidIPFamily(family) {
+		return fmt.Errorf("unknown IP family %q (auto, ipv4, ipv6, prefer_ipv4, prefer_ipv6)", value)
+	}
+	cfg.IPFamily = family
+	return nil
+}
+
+// targetTransports tracks one transport per upstream target address.
+//
+// Giving each target its own connection pool lets the proxy close exactly
+// the keep-alive connections of a target once it's removed, instead of
+// reusing them until the idle timeout expires.
+type targetTransports struct {
+	base *http.Transport
+
+	mu         sync.Mutex
+	transports map[string]*http.Transport // address -> transport
+	h2c        map[string]*http.Transport // address -> h2c transport ("" = no target)
+}
+
+// newTargetTransports creates an empty per-target transport set based on base.
+func newTargetTransports(base *http.Transport) *targetTransports {
+	return &targetTransports{
+		base:       base,
+		transports: make(map[string]*http.Transport),
+		h2c:        make(map[string]*http.Transport),
+	}
+}
+
+// baseTransport returns the transport for services without targets.
+func (tt *targetTransports) baseTransport() *http.Transport {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	return tt.base
+}
+
+// reset replaces the base transport and forgets every target transport, so
+// new requests use base's settings. Returns the replaced transports.
+func (tt *targetTransports) reset(base *http.Transport) []*http.Transport {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	old := []*http.Transport{tt.base}
+	for _, t := range tt.transports {
+		old = append(old, t)
+	}
+	for _, t := range tt.h2c {
+		old = append(old, t)
+	}
+	tt.base = base
+	tt.transports = make(map[string]*http.Transport)
+	tt.h2c = make(map[string]*http.Transport)
+	return old
+}
+
+// get returns the transport for a target address, creating it if needed.
+func (tt *targetTransports) get(address string) *http.Transport {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	if t, ok := tt.transports[address]; ok {
+		return t
+	}
+
+	t := tt.base.Clone()
+	tt.transports[address] = t
+	return t
+}
+
+// getH2C returns the HTTP/2 cleartext transport for a target address (""
+// for services without targets), creating it if needed.
+func (tt *targetTransports) getH2C(address string) *http.Transport {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	if t, ok := tt.h2c[address]; ok {
+		return t
+	}
+
+	t := tt.base.Clone()
+	t.Protocols = new(http.Protocols)
+	t.Protocols.SetUnencryptedHTTP2(true)
+	tt.h2c[address] = t
+	return t
+}
+
+// close closes idle connections of a target and forgets its transports.
+//
+// Returns false if no transport was tracked for the address.
+func (tt *targetTransports) close(address string) bool {
+	tt.mu.Lock()
+	t, ok := tt.transports[address]
+	h2c, h2cOK := tt.h2c[address]
+	delete(tt.transports, address)
+	delete(tt.h2c, address)
+	tt.mu.Unlock()
+
+	if ok {
+		t.CloseIdleConnections()
+	}
+	if h2cOK {
+		h2c.CloseIdleConnections()
+	}
+	return ok || h2cOK
+}
+
+// count returns the number of tracked target transports.
+func (tt *targetTransports) count() int {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	return len(tt.transports) + len(tt.h2c)
+}
@@ -0,0 +1,392 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// ============================================================================
+// Keyspace maintenance
+// ============================================================================
+//
+// Rate limit state is one Redis key per identifier, bounded only by its
+// TTL, so IP-based limits can leave millions of keys behind. The keyspace
+// monitor periodically SCANs the rate limit keys (never KEYS), reports how
+// many there are and roughly how much memory they use per prefix, and can
+// delete keys that have been idle longer than a policy allows.
+//
+// Keys look like <prefix><algorithm>:<identifier type>:<identifier>, e.g.
+// "rate_limit:sliding-window:ip:203.0.113.9"; they are grouped by prefix,
+// algorithm and identifier type ("rate_limit:sliding-window:ip").
+
+var (
+	keyspaceKeys = metrics.NewGaugeVec(
+		"gateway_ratelimit_keys",
+		"Rate limit keys in Redis at the last keyspace scan, by key prefix.",
+		"prefix",
+	)
+	keyspaceMemory = metrics.NewGaugeVec(
+		"gateway_ratelimit_memory_bytes",
+		"Estimated Redis memory used by rate limit keys at the last keyspace scan, by key prefix.",
+		"prefix",
+	)
+	keyspaceExpired = metrics.NewCounterVec(
+		"gateway_ratelimit_keys_expired_total",
+		"Idle rate limit keys deleted by keyspace maintenance, by key prefix.",
+		"prefix",
+	)
+	keyspaceLastScan = metrics.NewGaugeVec(
+		"gateway_ratelimit_keyspace_last_scan_timestamp_seconds",
+		"Unix time of the last completed rate limit keyspace scan.",
+	)
+)
+
+// scanBatch is the COUNT hint for each SCAN call.
+const scanBatch = 1000
+
+// KeyspaceConfig holds rate limit keyspace maintenance settings.
+type KeyspaceConfig struct {
+	// Prefix selects the rate limit keys (the plugins' key_prefix)
+	// Default: "rate_limit:"
+	Prefix string
+
+	// MaxKeys caps the keys scanned per run; a larger keyspace is
+	// reported as incomplete
+	// Default: 1,000,000
+	MaxKeys int
+
+	// MemorySamples is how many keys per prefix have their memory measured
+	// each run; the total is extrapolated from them
+	// Default: 100
+	MemorySamples int
+
+	// ExpireIdle deletes keys not touched for this long (0 = never). Set it
+	// longer than the longest rate limit window, or clients get a fresh
+	// quota early.
+	ExpireIdle time.Duration
+
+	// ExpireTypes limits ExpireIdle to these identifier types (ip, apikey,
+	// consumer); empty = all
+	ExpireTypes []string
+}
+
+// DefaultKeyspaceConfig returns keyspace maintenance defaults: reporting
+// only, no expiry.
+func DefaultKeyspaceConfig() KeyspaceConfig {
+	return KeyspaceConfig{
+		Prefix:        "rate_limit:",
+		MaxKeys:       1000000,
+		MemorySamples: 100,
+	}
+}
+
+// PrefixStats describes the rate limit keys under one prefix.
+type PrefixStats struct {
+	Prefix      string `json:"prefix"`
+	Keys        int64  `json:"keys"`
+	MemoryBytes int64  `json:"memory_bytes"` // estimated from sampled keys
+	Expired     int64  `json:"expired"`      // idle keys deleted by the scan
+}
+
+// KeyspaceReport is the result of a keyspace scan.
+type KeyspaceReport struct {
+	ScannedAt time.Time     `json:"scanned_at"`
+	Duration  string        `json:"duration"`
+	Keys      int64         `json:"keys"`
+	Complete  bool          `json:"complete"` // false if MaxKeys cut the scan short
+	Prefixes  []PrefixStats `json:"prefixes"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// KeyspaceMonitor scans and maintains the rate limit keyspace.
+//
+// A nil KeyspaceMonitor reports nothing.
+type KeyspaceMonitor struct {
+	client *redis.Client
+	config KeyspaceConfig
+
+	mu     sync.RWMutex
+	report *KeyspaceReport
+
+	// idleUnsupported is set once OBJECT IDLETIME fails (LFU eviction
+	// policies don't track idle time); expiry is then skipped
+	idleUnsupported bool
+}
+
+// NewKeyspaceMonitor creates a keyspace monitor on a rate limit store.
+func NewKeyspaceMonitor(store *RedisStore, config KeyspaceConfig) *KeyspaceMonitor {
+	defaults := DefaultKeyspaceConfig()
+	if config.Prefix == "" {
+		config.Prefix = defaults.Prefix
+	}
+	if config.MaxKeys <= 0 {
+		config.MaxKeys = defaults.MaxKeys
+	}
+	if config.MemorySamples <= 0 {
+		config.MemorySamples = defaults.MemorySamples
+	}
+
+	return &KeyspaceMonitor{client: store.client, config: config}
+}
+
+// Run scans the keyspace every interval until ctx is cancelled.
+func (m *KeyspaceMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := m.Scan(ctx); err != nil && ctx.Err() == nil {
+				log.Warn().
+					Err(err).
+					Str("component", "ratelimit_keyspace").
+					Msg("Rate limit keyspace scan failed")
+			}
+		}
+	}
+}
+
+// Report returns the last scan's report, or nil before the first scan.
+func (m *KeyspaceMonitor) Report() *KeyspaceReport {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.report
+}
+
+// prefixScan accumulates one prefix's stats during a scan.
+type prefixScan struct {
+	keys        int64
+	sampled     int64
+	sampleBytes int64
+	expired     int64
+}
+
+// Scan walks the rate limit keys once, updating metrics and the report.
+func (m *KeyspaceMonitor) Scan(ctx context.Context) (*KeyspaceReport, error) {
+	start := time.Now()
+	groups := make(map[string]*prefixScan)
+
+	var scanned int64
+	var cursor uint64
+	var scanErr error
+	for {
+		keys, next, err := m.client.Scan(ctx, cursor, m.config.Prefix+"*", scanBatch).Result()
+		if err != nil {
+			scanErr = fmt.Errorf("redis SCAN failed: %w", err)
+			break
+		}
+
+		if err := m.processBatch(ctx, keys, groups); err != nil {
+			scanErr = err
+			break
+		}
+
+		scanned += int64(len(keys))
+		cursor = next
+		if cursor == 0 || scanned >= int64(m.config.MaxKeys) {
+			break
+		}
+	}
+
+	report := &KeyspaceReport{
+		ScannedAt: start,
+		Duration:  time.Since(start).Round(time.Millisecond).String(),
+		Keys:      scanned,
+		Complete:  scanErr == nil && cursor == 0,
+	}
+	for prefix, g := range groups {
+		stats := PrefixStats{Prefix: prefix, Keys: g.keys, Expired: g.expired}
+		if g.sampled > 0 {
+			stats.MemoryBytes = g.sampleBytes * g.keys / g.sampled
+		}
+		report.Prefixes = append(report.Prefixes, stats)
+	}
+	sort.Slice(report.Prefixes, func(i, j int) bool {
+		return report.Prefixes[i].Keys > report.Prefixes[j].Keys
+	})
+	if scanErr != nil {
+		report.Error = scanErr.Error()
+	}
+
+	m.publish(report)
+
+	log.Info().
+		Str("component", "ratelimit_keyspace").
+		Int64("keys", report.Keys).
+		Int("prefixes", len(report.Prefixes)).
+		Bool("complete", report.Complete).
+		Str("duration", report.Duration).
+		Msg("Rate limit keyspace scanned")
+
+	return report, scanErr
+}
+
+// processBatch counts, samples and (by policy) expires one SCAN page.
+func (m *KeyspaceMonitor) processBatch(ctx context.Context, keys []string, groups map[string]*prefixScan) error {
+	var sampleKeys, idleKeys []string
+	for _, key := range keys {
+		prefix := m.groupOf(key)
+		g, ok := groups[prefix]
+		if !ok {
+			g = &prefixScan{}
+			groups[prefix] = g
+		}
+		g.keys++
+
+		// SCAN order is effectively random, so the first keys seen are a
+		// fair sample
+		if g.keys <= int64(m.config.MemorySamples) {
+			sampleKeys = append(sampleKeys, key)
+		}
+		if m.shouldExpire(key) {
+			idleKeys = append(idleKeys, key)
+		}
+	}
+
+	if len(sampleKeys) == 0 && len(idleKeys) == 0 {
+		return nil
+	}
+
+	pipe := m.client.Pipeline()
+	memory := make([]*redis.IntCmd, len(sampleKeys))
+	for i, key := range sampleKeys {
+		memory[i] = pipe.MemoryUsage(ctx, key)
+	}
+	idle := make([]*redis.DurationCmd, len(idleKeys))
+	for i, key := range idleKeys {
+		idle[i] = pipe.ObjectIdleTime(ctx, key)
+	}
+	// Per-command errors (e.g. a key expiring mid-scan) are checked below
+	if _, err := pipe.Exec(ctx); err != nil && ctx.Err() != nil {
+		return err
+	}
+
+	for i, cmd := range memory {
+		if bytes, err := cmd.Result(); err == nil {
+			g := groups[m.groupOf(sampleKeys[i])]
+			g.sampled++
+			g.sampleBytes += bytes
+		}
+	}
+
+	var stale []string
+	for i, cmd := range idle {
+		d, err := cmd.Result()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) {
+				m.disableExpiry(err)
+				break
+			}
+			continue
+		}
+		if d >= m.config.ExpireIdle {
+			stale = append(stale, idleKeys[i])
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	if err := m.client.Unlink(ctx, stale...).Err(); err != nil {
+		return fmt.Errorf("redis UNLINK failed: %w", err)
+	}
+	for _, key := range stale {
+		prefix := m.groupOf(key)
+		groups[prefix].expired++
+		keyspaceExpired.Inc(prefix)
+	}
+	return nil
+}
+
+// shouldExpire reports whether the expiry policy covers a key.
+func (m *KeyspaceMonitor) shouldExpire(key string) bool {
+	if m.config.ExpireIdle <= 0 || m.idleUnsupported {
+		return false
+	}
+	if len(m.config.ExpireTypes) == 0 {
+		return true
+	}
+
+	idType := identifierType(strings.TrimPrefix(key, m.config.Prefix))
+	for _, t := range m.config.ExpireTypes {
+		if t == idType {
+			return true
+		}
+	}
+	return false
+}
+
+// disableExpiry turns expiry off when the server can't report idle time.
+func (m *KeyspaceMonitor) disableExpiry(err error) {
+	m.idleUnsupported = true
+	log.Warn().
+		Err(err).
+		Str("component", "ratelimit_keyspace").
+		Msg("OBJECT IDLETIME unavailable (LFU maxmemory-policy?) - idle key expiry disabled")
+}
+
+// groupOf returns a key's prefix group: the configured prefix plus the
+// algorithm and identifier type segments.
+func (m *KeyspaceMonitor) groupOf(key string) string {
+	rest := strings.TrimPrefix(key, m.config.Prefix)
+	parts := strings.SplitN(rest, ":", 3)
+	if len(parts) < 3 {
+		// Not <algorithm>:<type>:<identifier>; group by what there is
+		return m.config.Prefix + strings.Join(parts[:len(parts)-1], ":")
+	}
+	return m.config.Prefix + parts[0] + ":" + parts[1]
+}
+
+// identifierType extracts the identifier type from a key without its
+// prefix ("sliding-window:ip:1.2.3.4" -> "ip").
+func identifierType(rest string) string {
+	parts := strings.SplitN(rest, ":", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[1]
+}
+
+// publish stores a report and updates the gauges, clearing prefixes that
+// disappeared since the last scan.
+func (m *KeyspaceMonitor) publish(report *KeyspaceReport) {
+	m.mu.Lock()
+	previous := m.report
+	m.report = report
+	m.mu.Unlock()
+
+	current := make(map[string]bool, len(report.Prefixes))
+	for _, p := range report.Prefixes {
+		current[p.Prefix] = true
+		keyspaceKeys.Set(float64(p.Keys), p.Prefix)
+		keyspaceMemory.Set(float64(p.MemoryBytes), p.Prefix)
+	}
+	if previous != nil {
+		for _, p := range previous.Prefixes {
+			if !current[p.Prefix] {
+				keyspaceKeys.Delete(p.Prefix)
+				keyspaceMemory.Delete(p.Prefix)
+			}
+		}
+	}
+	if report.Error == "" {
+		keyspaceLastScan.Set(float64(report.ScannedAt.Unix()))
+	}
+}
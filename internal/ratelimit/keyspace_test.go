@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestKeyspaceMonitor_Grouping tests prefix grouping and the expiry policy.
+func TestKeyspaceMonitor_Grouping(t *testing.T) {
+	m := &KeyspaceMonitor{config: KeyspaceConfig{
+		Prefix:      "rate_limit:",
+		ExpireIdle:  time.Hour,
+		ExpireTypes: []string{"ip"},
+	}}
+
+	tests := []struct {
+		key        string
+		wantGroup  string
+		wantExpire bool
+	}{
+		{"rate_limit:sliding-window:ip:203.0.113.9", "rate_limit:sliding-window:ip", true},
+		{"rate_limit:token-bucket:ip:2001:db8::1", "rate_limit:token-bucket:ip", true},
+		{"rate_limit:sliding-window:consumer:c-1", "rate_limit:sliding-window:consumer", false},
+		{"rate_limit:odd:key", "rate_limit:odd", false},
+		{"rate_limit:single", "rate_limit:", false},
+	}
+
+	for _, tt := range tests {
+		if got := m.groupOf(tt.key); got != tt.wantGroup {
+			t.Errorf("groupOf(%q) = %q, want %q", tt.key, got, tt.wantGroup)
+		}
+		if got := m.shouldExpire(tt.key); got != tt.wantExpire {
+			t.Errorf("shouldExpire(%q) = %v, want %v", tt.key, got, tt.wantExpire)
+		}
+	}
+
+	// Expiry is off without ExpireIdle
+	m.config.ExpireIdle = 0
+	if m.shouldExpire("rate_limit:sliding-window:ip:203.0.113.9") {
+		t.Error("shouldExpire() = true with ExpireIdle 0")
+	}
+
+	// A nil monitor has no report
+	var none *KeyspaceMonitor
+	if none.Report() != nil {
+		t.Error("nil monitor returned a report")
+	}
+}
+
+// TestKeyspaceMonitor_Scan tests counting keys per prefix in Redis.
+func TestKeyspaceMonitor_Scan(t *testing.T) {
+	config := DefaultRedisConfig()
+	config.URL = "redis://localhost:6379/15" // Use test DB
+	store, err := NewRedisStore(config)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	keys := []string{
+		"test:keyspace:sliding-window:ip:1.1.1.1",
+		"test:keyspace:sliding-window:ip:2.2.2.2",
+		"test:keyspace:sliding-window:ip:3.3.3.3",
+		"test:keyspace:token-bucket:consumer:c-1",
+	}
+	for _, key := range keys {
+		if err := store.client.Set(ctx, key, "1", time.Minute).Err(); err != nil {
+			t.Fatalf("SET failed: %v", err)
+		}
+	}
+	defer store.client.Del(ctx, keys...)
+
+	m := NewKeyspaceMonitor(store, KeyspaceConfig{
+		Prefix: "test:keyspace:",
+		// Keys were just written, so nothing is idle this long
+		ExpireIdle:  time.Hour,
+		ExpireTypes: []string{"ip"},
+	})
+	report, err := m.Scan(ctx)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	if report.Keys != 4 || !report.Complete {
+		t.Errorf("Expected 4 keys in a complete scan, got %d (complete=%v)", report.Keys, report.Complete)
+	}
+	if len(report.Prefixes) != 2 {
+		t.Fatalf("Expected 2 prefixes, got %+v", report.Prefixes)
+	}
+	ip := report.Prefixes[0] // sorted by key count
+	if ip.Prefix != "test:keyspace:sliding-window:ip" || ip.Keys != 3 || ip.Expired != 0 {
+		t.Errorf("Unexpected ip prefix stats: %+v", ip)
+	}
+	if ip.MemoryBytes <= 0 {
+		t.Errorf("Expected a memory estimate, got %d", ip.MemoryBytes)
+	}
+	if m.Report() != report {
+		t.Error("Report() did not return the last scan")
+	}
+}
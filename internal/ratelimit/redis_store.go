@@ -192,6 +192,17 @@ func (s *RedisStore) Set(ctx context.Context, key string, value interface{}, ttl
 	return nil
 }
 
+// SetNX stores a value only if the key does not already exist.
+//
+// Returns true if the key was set. This is used as a lightweight lock.
+func (s *RedisStore) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	ok, err := s.client.SetNX(ctx, key, value, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis SETNX failed: %w", err)
+	}
+	return ok, nil
+}
+
 // Del deletes one or more keys from Redis.
 func (s *RedisStore) Del(ctx context.Context, keys ...string) error {
 	if len(keys) == 0 {
@@ -228,6 +239,17 @@ func (s *RedisStore) TTL(ctx context.Context, key string) (time.Duration, error)
 	return ttl, nil
 }
 
+// Expire sets the time-to-live of an existing key.
+//
+// Keys that don't exist are left alone.
+func (s *RedisStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	err := s.client.Expire(ctx, key, ttl).Err()
+	if err != nil {
+		return fmt.Errorf("redis EXPIRE failed: %w", err)
+	}
+	return nil
+}
+
 // HGetAll retrieves all fields and values from a Redis hash.
 func (s *RedisStore) HGetAll(ctx context.Context, key string) (map[string]string, error) {
 	result, err := s.client.HGetAll(ctx, key).Result()
@@ -0,0 +1,109 @@
+// Package recording captures sampled gateway traffic and replays it
+// against a target environment.
+//
+// Recording:
+//   - The request-recorder plugin samples requests on a route and hands
+//     them to a Recorder
+//   - The Recorder writes them to Postgres in the background so the
+//     request path never waits on the database
+//   - When the write queue is full, the oldest queued recording is dropped
+//     (and counted) rather than applying backpressure to live traffic
+//
+// Replay:
+//   - `gateway replay` loads recordings and re-sends them with a Replayer,
+//     reporting any status code that differs from the original response
+package recording
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/eventqueue"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// writeTimeout bounds a single insert.
+const writeTimeout = 5 * time.Second
+
+var recordedTotal = metrics.NewCounterVec(
+	"gateway_recorded_requests_total",
+	"Sampled requests handed to the recorder, by result (stored, dropped, failed).",
+	"result",
+)
+
+// Store persists recorded requests (implemented by database.Repository).
+type Store interface {
+	InsertRecordedRequest(ctx context.Context, req *database.RecordedRequest) error
+}
+
+// Recorder writes recorded requests asynchronously.
+type Recorder struct {
+	store Store
+	queue *eventqueue.Queue[*database.RecordedRequest]
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewRecorder creates a Recorder with a bounded queue and starts its writer.
+func NewRecorder(store Store, queueSize int) *Recorder {
+	r := &Recorder{
+		store: store,
+		queue: eventqueue.New[*database.RecordedRequest]("recorder", queueSize),
+		done:  make(chan struct{}),
+	}
+	go r.run()
+
+	return r
+}
+
+// Record queues a request for storage. It never blocks; if the queue is
+// full the oldest queued recording is dropped.
+func (r *Recorder) Record(req *database.RecordedRequest) {
+	if !r.queue.Push(req) {
+		recordedTotal.Inc("dropped")
+		log.Debug().
+			Str("component", "recorder").
+			Uint64("dropped", r.queue.Dropped()).
+			Msg("Recording queue full - dropped oldest sampled request")
+	}
+}
+
+// Close stops accepting recordings and waits for queued ones to be written.
+func (r *Recorder) Close() {
+	r.closeOnce.Do(func() {
+		r.queue.Close()
+		<-r.done
+	})
+}
+
+// run drains the queue until Close.
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	for {
+		req, ok := r.queue.Next()
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		err := r.store.InsertRecordedRequest(ctx, req)
+		cancel()
+
+		if err != nil {
+			recordedTotal.Inc("failed")
+			log.Warn().
+				Err(err).
+				Str("component", "recorder").
+				Str("route_id", req.RouteID).
+				Msg("Failed to store recorded request")
+			continue
+		}
+		recordedTotal.Inc("stored")
+	}
+}
@@ -0,0 +1,132 @@
+package recording
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// memoryStore records inserts in memory.
+type memoryStore struct {
+	mu       sync.Mutex
+	requests []*database.RecordedRequest
+	block    chan struct{} // if set, inserts wait on it
+}
+
+func (s *memoryStore) InsertRecordedRequest(ctx context.Context, req *database.RecordedRequest) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	return nil
+}
+
+func (s *memoryStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func TestRecorder_WritesOnClose(t *testing.T) {
+	store := &memoryStore{}
+	r := NewRecorder(store, 10)
+
+	for i := 0; i < 5; i++ {
+		r.Record(&database.RecordedRequest{RouteID: "route", Method: "GET", Path: "/"})
+	}
+	r.Close()
+
+	if got := store.count(); got != 5 {
+		t.Errorf("stored %d requests, want 5", got)
+	}
+}
+
+func TestRecorder_DropsWhenQueueFull(t *testing.T) {
+	store := &memoryStore{block: make(chan struct{})}
+	r := NewRecorder(store, 1)
+
+	// One in the writer (blocked), one queued, the rest dropped
+	for i := 0; i < 10; i++ {
+		r.Record(&database.RecordedRequest{RouteID: "route"})
+		time.Sleep(time.Millisecond)
+	}
+
+	close(store.block)
+	r.Close()
+
+	if got := store.count(); got < 1 || got > 2 {
+		t.Errorf("stored %d requests, want 1-2 with a full queue", got)
+	}
+}
+
+func TestReplayer_Replay(t *testing.T) {
+	var gotPath, gotQuery, gotAuth, gotBody, gotCustom string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotQuery = r.URL.RawQuery
+		gotAuth = r.Header.Get("Authorization")
+		gotCustom = r.Header.Get("X-Custom")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer backend.Close()
+
+	replayer, err := NewReplayer(backend.URL+"/prefix", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer.Headers.Set("Authorization", "Bearer staging")
+
+	rec := &database.RecordedRequest{
+		Method: "POST",
+		Path:   "/orders",
+		Query:  "dry_run=true",
+		Headers: map[string][]string{
+			"Authorization": {RedactedValue},
+			"X-Custom":      {"yes"},
+		},
+		Body:       []byte(`{"sku":"abc"}`),
+		StatusCode: http.StatusCreated,
+	}
+
+	res := replayer.Replay(context.Background(), rec)
+	if res.Err != nil {
+		t.Fatalf("Replay() error = %v", res.Err)
+	}
+	if res.StatusMismatch {
+		t.Errorf("unexpected status mismatch: %d", res.StatusCode)
+	}
+
+	if gotPath != "/prefix/orders" || gotQuery != "dry_run=true" {
+		t.Errorf("replayed to %s?%s", gotPath, gotQuery)
+	}
+	if gotAuth != "Bearer staging" {
+		t.Errorf("Authorization = %q, want override", gotAuth)
+	}
+	if gotCustom != "yes" {
+		t.Errorf("X-Custom = %q, want recorded value", gotCustom)
+	}
+	if gotBody != `{"sku":"abc"}` {
+		t.Errorf("body = %q", gotBody)
+	}
+
+	rec.StatusCode = http.StatusOK
+	if res := replayer.Replay(context.Background(), rec); !res.StatusMismatch {
+		t.Error("expected status mismatch when recorded status differs")
+	}
+}
+
+func TestNewReplayer_InvalidTarget(t *testing.T) {
+	if _, err := NewReplayer("ftp://example.com", time.Second); err == nil {
+		t.Error("expected error for non-http target")
+	}
+}
@@ -0,0 +1,116 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// RedactedValue replaces sensitive header values in recordings.
+// Redacted headers are not replayed; supply them with Replayer.Headers.
+const RedactedValue = "[REDACTED]"
+
+// Replayer re-sends recorded requests against a target environment.
+type Replayer struct {
+	// Target is the base URL requests are sent to (e.g., https://staging.example.com)
+	Target *url.URL
+
+	// Client sends the requests. Redirects are not followed so status
+	// codes compare like-for-like.
+	Client *http.Client
+
+	// Headers are set on every replayed request (e.g., Authorization),
+	// overriding recorded values.
+	Headers http.Header
+}
+
+// Result is the outcome of replaying one recorded request.
+type Result struct {
+	Request        *database.RecordedRequest
+	StatusCode     int // 0 if the request failed
+	Duration       time.Duration
+	Err            error
+	StatusMismatch bool // replayed status differs from the recorded one
+}
+
+// NewReplayer creates a Replayer for the target base URL.
+func NewReplayer(target string, timeout time.Duration) (*Replayer, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid target URL %q: scheme must be http or https", target)
+	}
+
+	return &Replayer{
+		Target: u,
+		Client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Headers: make(http.Header),
+	}, nil
+}
+
+// Replay sends one recorded request and compares the response status.
+func (rp *Replayer) Replay(ctx context.Context, rec *database.RecordedRequest) Result {
+	result := Result{Request: rec}
+
+	req, err := rp.buildRequest(ctx, rec)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	start := time.Now()
+	resp, err := rp.Client.Do(req)
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Err = fmt.Errorf("request failed: %w", err)
+		return result
+	}
+	resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	result.StatusMismatch = resp.StatusCode != rec.StatusCode
+	return result
+}
+
+// buildRequest turns a recording into a request against the target.
+func (rp *Replayer) buildRequest(ctx context.Context, rec *database.RecordedRequest) (*http.Request, error) {
+	u := *rp.Target
+	u.Path = strings.TrimSuffix(rp.Target.Path, "/") + rec.Path
+	u.RawQuery = rec.Query
+
+	req, err := http.NewRequestWithContext(ctx, rec.Method, u.String(), bytes.NewReader(rec.Body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	for name, values := range rec.Headers {
+		if len(values) == 1 && values[0] == RedactedValue {
+			continue
+		}
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	for name, values := range rp.Headers {
+		req.Header[name] = values
+	}
+
+	// Truncated bodies cannot be replayed faithfully; send what we have
+	req.ContentLength = int64(len(rec.Body))
+	req.Header.Del("Content-Length")
+
+	return req, nil
+}
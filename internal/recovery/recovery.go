@@ -0,0 +1,93 @@
+// Package recovery turns panics while serving a request into 500 responses.
+//
+// Handler wraps the whole request path (router, plugins, proxy), so a bug
+// in one plugin or route fails that request instead of killing the
+// connection with net/http's bare stack dump. Every recovered panic is
+// logged with its stack and request ID and counted in gateway_panics_total.
+//
+// The request ID is assigned here and stored in the request context (see
+// logging.RequestIDFromContext), so the 500 body and the panic log carry
+// the same ID as the handler's own logs.
+package recovery
+
+import (
+	"net/http"
+
+	"github.com/saidutt46/switchboard-gateway/internal/logging"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+var panicsTotal = metrics.NewCounterVec(
+	"gateway_panics_total",
+	"Panics recovered while serving requests.",
+)
+
+// Handler recovers panics from next.
+//
+// If the response has already started it cannot become a 500, so the
+// connection is aborted instead and the client sees a truncated response
+// rather than one that looks complete.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := logging.RequestIDFromContext(r.Context())
+		if requestID == "" {
+			requestID = logging.NewRequestID()
+			r = r.WithContext(logging.ContextWithRequestID(r.Context(), requestID))
+		}
+
+		rw := &responseWriter{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// net/http's deliberate abort (e.g. the proxy lost the client)
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			panicsTotal.Inc()
+			logging.LogPanic(recovered, map[string]interface{}{
+				"component":  "recovery",
+				"request_id": requestID,
+				"method":     r.Method,
+				"path":       r.URL.Path,
+			})
+
+			if rw.started {
+				panic(http.ErrAbortHandler)
+			}
+			// Drop whatever the handler set (Content-Length, encodings)
+			clear(w.Header())
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":"internal server error","message":"The gateway failed to handle this request","request_id":"` + requestID + `"}`))
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// responseWriter records whether the response has started.
+type responseWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if statusCode >= 200 { // 1xx informational responses can be followed by a 500
+		w.started = true
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the connection (flushes,
+// upgrades).
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
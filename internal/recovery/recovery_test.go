@@ -0,0 +1,89 @@
+package recovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/logging"
+)
+
+func TestHandler_RecoversPanic(t *testing.T) {
+	var handlerID string
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerID = logging.RequestIDFromContext(r.Context())
+		w.Header().Set("Content-Length", "100")
+		panic("plugin bug")
+	}))
+
+	before := panicsTotal.Value()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/users", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	if w.Header().Get("Content-Length") != "" {
+		t.Error("handler headers should be dropped from the 500")
+	}
+
+	var body struct {
+		Error     string `json:"error"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.RequestID == "" || body.RequestID != handlerID {
+		t.Errorf("request_id = %q, want the handler's %q", body.RequestID, handlerID)
+	}
+	if got := panicsTotal.Value() - before; got != 1 {
+		t.Errorf("panics counted = %v, want 1", got)
+	}
+}
+
+func TestHandler_KeepsRequestID(t *testing.T) {
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := logging.RequestIDFromContext(r.Context()); got != "req_1" {
+			t.Errorf("request ID = %q, want req_1", got)
+		}
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r = r.WithContext(logging.ContextWithRequestID(r.Context(), "req_1"))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestHandler_AbortsStartedResponse(t *testing.T) {
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		panic("mid-stream")
+	}))
+
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("panic = %v, want http.ErrAbortHandler", recovered)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	t.Error("expected the connection to be aborted")
+}
+
+func TestHandler_PassesThroughAbort(t *testing.T) {
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	before := panicsTotal.Value()
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("panic = %v, want http.ErrAbortHandler", recovered)
+		}
+		if panicsTotal.Value() != before {
+			t.Error("deliberate aborts should not be counted")
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
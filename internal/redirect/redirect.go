@@ -0,0 +1,409 @@
+// Package redirect answers requests with configured redirects before they
+// are routed and proxied.
+//
+// Rules live in the redirects table and match like routes: hosts are exact
+// names or "*.example.com" wildcards (none = any host), paths are patterns
+// with ":param" segments and a trailing "*". Every method matches. The
+// first matching rule, oldest first, answers with its status code and a
+// Location rendered from its template:
+//
+//	{host}     request host (without port)
+//	{path}     request path
+//	{id}       value of the ":id" path parameter
+//	{*}        remainder matched by a trailing "*"
+//
+//	paths ["/docs/*"], location "https://docs.example.com/{*}", 308
+//	GET /docs/guides/start?lang=en -> 308 https://docs.example.com/guides/start?lang=en
+//
+// {host} comes from the request's Host header, so templates using it
+// redirect wherever clients ask; restrict such rules with hosts.
+//
+// Rules are reloaded with the rest of the configuration (see Load and
+// Engine.Set); an invalid rule set is rejected as a whole.
+package redirect
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+)
+
+var redirectsTotal = metrics.NewCounterVec(
+	"gateway_redirects_total",
+	"Requests answered by a redirect rule, by rule and status code.",
+	"redirect_id", "status_code",
+)
+
+// ============================================================================
+// Rules
+// ============================================================================
+
+// Rules is a compiled, validated set of redirect rules.
+type Rules struct {
+	rules []*rule
+}
+
+// rule is one compiled redirect.
+type rule struct {
+	redirect *database.Redirect
+	patterns [][]string // split path patterns
+	location []part     // parsed Location template
+}
+
+// part is a literal or a placeholder of a Location template.
+type part struct {
+	literal     string
+	placeholder string // host, path, *, or a parameter name
+}
+
+// Len returns the number of rules.
+func (rs *Rules) Len() int {
+	if rs == nil {
+		return 0
+	}
+	return len(rs.rules)
+}
+
+// Compile validates and compiles redirect rules (disabled ones are
+// skipped), keeping their order.
+//
+// Returns a *router.ValidationError listing every invalid rule.
+func Compile(redirects []*database.Redirect) (*Rules, error) {
+	rs := &Rules{}
+	var problems []string
+
+	for _, redirect := range redirects {
+		if redirect == nil || !redirect.Enabled {
+			continue
+		}
+
+		r, ruleProblems := compileRule(redirect)
+		for _, problem := range ruleProblems {
+			problems = append(problems, fmt.Sprintf("redirect %s: %s", redirect.ID, problem))
+		}
+		if len(ruleProblems) == 0 {
+			rs.rules = append(rs.rules, r)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &router.ValidationError{Problems: problems}
+	}
+	return rs, nil
+}
+
+// Load reads and compiles the enabled redirect rules from a store.
+func Load(ctx context.Context, store database.ConfigStore) (*Rules, error) {
+	redirects, err := store.GetRedirects(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load redirects: %w", err)
+	}
+	return Compile(redirects)
+}
+
+// compileRule checks one redirect and compiles its patterns and template.
+func compileRule(redirect *database.Redirect) (*rule, []string) {
+	var problems []string
+
+	switch redirect.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		problems = append(problems, fmt.Sprintf("invalid status code %d (must be 301, 302, 303, 307 or 308)", redirect.StatusCode))
+	}
+
+	if len(redirect.Paths) == 0 {
+		problems = append(problems, "no paths")
+	}
+
+	r := &rule{redirect: redirect}
+
+	// Placeholders every path provides
+	provided := map[string]int{}
+	for _, path := range redirect.Paths {
+		if !strings.HasPrefix(path, "/") {
+			problems = append(problems, fmt.Sprintf("path %q must start with /", path))
+			continue
+		}
+		segments := splitPath(path)
+		for i, segment := range segments {
+			switch {
+			case segment == "*":
+				if i != len(segments)-1 {
+					problems = append(problems, fmt.Sprintf("path %q: * must be the last segment", path))
+				}
+				provided["*"]++
+			case strings.HasPrefix(segment, ":"):
+				if len(segment) == 1 {
+					problems = append(problems, fmt.Sprintf("path %q: parameter without a name", path))
+				}
+				provided[segment[1:]]++
+			}
+		}
+		r.patterns = append(r.patterns, segments)
+	}
+
+	location, err := parseTemplate(redirect.Location)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, p := range location {
+		if p.placeholder == "" || p.placeholder == "host" || p.placeholder == "path" {
+			continue
+		}
+		if provided[p.placeholder] != len(redirect.Paths) {
+			problems = append(problems, fmt.Sprintf("location uses {%s}, which not every path provides", p.placeholder))
+		}
+	}
+	r.location = location
+
+	return r, problems
+}
+
+// parseTemplate splits a Location template into literals and placeholders.
+func parseTemplate(template string) ([]part, error) {
+	if template == "" {
+		return nil, fmt.Errorf("empty location")
+	}
+
+	var parts []part
+	rest := template
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			parts = append(parts, part{literal: rest})
+			break
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("location %q: unclosed {", template)
+		}
+		name := rest[open+1 : open+end]
+		if name == "" {
+			return nil, fmt.Errorf("location %q: empty placeholder", template)
+		}
+		if open > 0 {
+			parts = append(parts, part{literal: rest[:open]})
+		}
+		parts = append(parts, part{placeholder: name})
+		rest = rest[open+end+1:]
+	}
+	return parts, nil
+}
+
+// match checks the rule against a request host (without port) and path
+// segments, returning the template values.
+func (r *rule) match(host, path string, segments []string) (map[string]string, bool) {
+	if !hostMatches(r.redirect.Hosts, host) {
+		return nil, false
+	}
+
+	for _, pattern := range r.patterns {
+		if values, ok := matchPattern(pattern, segments); ok {
+			values["host"] = host
+			values["path"] = path
+			return values, true
+		}
+	}
+	return nil, false
+}
+
+// render builds the Location header value.
+func (r *rule) render(values map[string]string, rawQuery string) string {
+	var b strings.Builder
+	for _, p := range r.location {
+		if p.placeholder == "" {
+			b.WriteString(p.literal)
+			continue
+		}
+		value := values[p.placeholder]
+		switch p.placeholder {
+		case "host":
+			b.WriteString(value)
+		case "path", "*":
+			b.WriteString((&url.URL{Path: value}).EscapedPath())
+		default:
+			b.WriteString(url.PathEscape(value))
+		}
+	}
+
+	location := b.String()
+	if r.redirect.PreserveQuery && rawQuery != "" {
+		if strings.Contains(location, "?") {
+			location += "&" + rawQuery
+		} else {
+			location += "?" + rawQuery
+		}
+	}
+	return location
+}
+
+// matchPattern matches path segments against a pattern like the router
+// does: static segments exactly, ":name" any one segment, and a trailing
+// "*" one or more segments.
+func matchPattern(pattern, segments []string) (map[string]string, bool) {
+	values := map[string]string{}
+	for i, p := range pattern {
+		if p == "*" {
+			if i >= len(segments) {
+				return nil, false
+			}
+			values["*"] = strings.Join(segments[i:], "/")
+			return values, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		switch {
+		case strings.HasPrefix(p, ":"):
+			values[p[1:]] = segments[i]
+		case p != segments[i]:
+			return nil, false
+		}
+	}
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+	return values, true
+}
+
+// hostMatches checks a host against host patterns (none = any host).
+func hostMatches(patterns []string, host string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if host == pattern {
+			return true
+		}
+		if strings.HasPrefix(pattern, "*.") {
+			suffix := pattern[2:]
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitPath splits a path into non-empty segments.
+func splitPath(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// ============================================================================
+// Engine
+// ============================================================================
+
+// Engine serves the live redirect rules. A nil Engine redirects nothing.
+type Engine struct {
+	mu    sync.RWMutex
+	rules *Rules
+}
+
+// NewEngine creates an engine with no rules.
+func NewEngine() *Engine {
+	return &Engine{rules: &Rules{}}
+}
+
+// Set swaps in a new rule set.
+func (e *Engine) Set(rules *Rules) {
+	if e == nil || rules == nil {
+		return
+	}
+
+	e.mu.Lock()
+	e.rules = rules
+	e.mu.Unlock()
+
+	log.Info().
+		Str("component", "redirect").
+		Int("rules", rules.Len()).
+		Msg("Redirect rules loaded")
+}
+
+// Reload loads the rules from a store and swaps them in. On error the
+// current rules keep serving.
+func (e *Engine) Reload(ctx context.Context, store database.ConfigStore) error {
+	rules, err := Load(ctx, store)
+	if err != nil {
+		return err
+	}
+	e.Set(rules)
+	return nil
+}
+
+// Len returns the number of live rules.
+func (e *Engine) Len() int {
+	if e == nil {
+		return 0
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.rules.Len()
+}
+
+// Match returns the redirect for a request: the rule's ID, its status code
+// and the rendered Location. ok is false if no rule matches.
+func (e *Engine) Match(req *http.Request) (id string, status int, location string, ok bool) {
+	if e == nil {
+		return "", 0, "", false
+	}
+
+	e.mu.RLock()
+	rules := e.rules
+	e.mu.RUnlock()
+	if rules.Len() == 0 {
+		return "", 0, "", false
+	}
+
+	host := req.Host
+	if h, _, found := strings.Cut(host, ":"); found {
+		host = h
+	}
+	segments := splitPath(req.URL.Path)
+
+	for _, r := range rules.rules {
+		if values, matched := r.match(host, req.URL.Path, segments); matched {
+			return r.redirect.ID, r.redirect.StatusCode, r.render(values, req.URL.RawQuery), true
+		}
+	}
+	return "", 0, "", false
+}
+
+// Redirect answers the request if a rule matches it, reporting whether it
+// did.
+func (e *Engine) Redirect(w http.ResponseWriter, req *http.Request) bool {
+	id, status, location, ok := e.Match(req)
+	if !ok {
+		return false
+	}
+
+	redirectsTotal.Inc(id, strconv.Itoa(status))
+	log.Debug().
+		Str("component", "redirect").
+		Str("redirect_id", id).
+		Str("path", req.URL.Path).
+		Int("status_code", status).
+		Str("location", location).
+		Msg("Request redirected")
+
+	http.Redirect(w, req, location, status)
+	return true
+}
@@ -0,0 +1,140 @@
+package redirect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/router"
+)
+
+func testRedirect(id string, status int, location string, paths ...string) *database.Redirect {
+	return &database.Redirect{ID: id, Paths: paths, Location: location, StatusCode: status, PreserveQuery: true, Enabled: true}
+}
+
+func TestEngine_Match(t *testing.T) {
+	docs := testRedirect("docs", 308, "https://docs.example.com/{*}", "/docs/*")
+	user := testRedirect("user", 301, "/v2/users/{id}/profile", "/users/:id/profile")
+	host := testRedirect("host", 302, "https://{host}{path}", "/*")
+	host.Hosts = []string{"*.old.example.com"}
+	noQuery := testRedirect("no-query", 302, "/new?from=old", "/legacy")
+	noQuery.PreserveQuery = false
+	disabled := testRedirect("disabled", 302, "/nowhere", "/docs/*")
+	disabled.Enabled = false
+
+	rules, err := Compile([]*database.Redirect{disabled, docs, user, host, noQuery})
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	engine := NewEngine()
+	engine.Set(rules)
+
+	tests := []struct {
+		target, host string
+		wantID       string
+		wantLocation string
+	}{
+		{target: "/docs/guides/start?lang=en", wantID: "docs", wantLocation: "https://docs.example.com/guides/start?lang=en"},
+		{target: "/docs/a%20b", wantID: "docs", wantLocation: "https://docs.example.com/a%20b"},
+		{target: "/docs"}, // * needs at least one segment, like routes
+		{target: "/users/42/profile/", wantID: "user", wantLocation: "/v2/users/42/profile"},
+		{target: "/users/42"},
+		{target: "/api/x?y=1", host: "eu.old.example.com:8080", wantID: "host", wantLocation: "https://eu.old.example.com/api/x?y=1"},
+		{target: "/api/x", host: "new.example.com"},
+		{target: "/legacy?a=1", wantID: "no-query", wantLocation: "/new?from=old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host+tt.target, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			id, _, location, ok := engine.Match(req)
+			if ok != (tt.wantID != "") || id != tt.wantID || location != tt.wantLocation {
+				t.Errorf("Match() = %q %q %v, want %q %q", id, location, ok, tt.wantID, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestEngine_Redirect(t *testing.T) {
+	rules, err := Compile([]*database.Redirect{testRedirect("docs", 308, "https://docs.example.com/{*}", "/docs/*")})
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	engine := NewEngine()
+	engine.Set(rules)
+
+	rec := httptest.NewRecorder()
+	if !engine.Redirect(rec, httptest.NewRequest("POST", "/docs/x", nil)) {
+		t.Fatal("Redirect() = false, want true")
+	}
+	if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != "https://docs.example.com/x" {
+		t.Errorf("got %d Location %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	if engine.Redirect(httptest.NewRecorder(), httptest.NewRequest("GET", "/api", nil)) {
+		t.Error("Redirect() = true for unmatched request")
+	}
+
+	// A nil engine redirects nothing
+	var none *Engine
+	if none.Redirect(httptest.NewRecorder(), httptest.NewRequest("GET", "/docs/x", nil)) {
+		t.Error("nil Engine redirected")
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	tests := []*database.Redirect{
+		testRedirect("status", 200, "/x", "/a"),
+		testRedirect("no-paths", 301, "/x"),
+		testRedirect("relative", 301, "/x", "a"),
+		testRedirect("mid-wildcard", 301, "/x", "/a/*/b"),
+		testRedirect("empty-location", 301, "", "/a"),
+		testRedirect("unclosed", 301, "/x/{id", "/a/:id"),
+		testRedirect("missing-param", 301, "/x/{id}", "/a/:id", "/b"),
+		testRedirect("missing-wildcard", 301, "/x/{*}", "/a"),
+	}
+
+	for _, redirect := range tests {
+		t.Run(redirect.ID, func(t *testing.T) {
+			_, err := Compile([]*database.Redirect{redirect})
+			var validationErr *router.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Compile() error = %v, want ValidationError", err)
+			}
+		})
+	}
+}
+
+func TestEngine_Reload(t *testing.T) {
+	store := database.NewMemoryStore()
+	now := time.Now()
+	first := testRedirect("first", 301, "/one", "/a")
+	first.CreatedAt = now
+	second := testRedirect("second", 302, "/two", "/a")
+	second.CreatedAt = now.Add(time.Second)
+	store.SetRedirects([]*database.Redirect{second, first})
+
+	engine := NewEngine()
+	if err := engine.Reload(context.Background(), store); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if id, _, _, _ := engine.Match(httptest.NewRequest("GET", "/a", nil)); id != "first" {
+		t.Errorf("Match() = %q, want the oldest rule", id)
+	}
+
+	// An invalid set keeps the current rules
+	store.SetRedirects([]*database.Redirect{testRedirect("bad", 200, "/x", "/b")})
+	if err := engine.Reload(context.Background(), store); err == nil {
+		t.Fatal("Reload() accepted an invalid rule")
+	}
+	if engine.Len() != 2 {
+		t.Errorf("Len() = %d after rejected reload, want 2", engine.Len())
+	}
+}
@@ -0,0 +1,298 @@
+// Package respcache is the gateway's response cache: the cache plugin
+// stores upstream responses here and answers repeated requests from them.
+//
+// One store is shared by every route's cache plugin and held in memory per
+// gateway instance, bounded by CACHE_MAX_BYTES (least recently used
+// entries are evicted first). Keys are chosen by the plugin; each entry
+// also records its route, request path and surrogate keys (tags the
+// upstream set in a Surrogate-Key header) so entries can be purged before
+// they expire:
+//
+//	DELETE /admin/cache?route=<route id>&path=/products/42
+//	DELETE /admin/cache?path=/products/*
+//	DELETE /admin/cache?tag=product-42
+//
+// Expired entries with a stale window are kept until it ends, so the cache
+// plugin can serve them when the upstream fails (stale-if-error).
+//
+// A Purger drops the matching entries locally and publishes the purge on
+// the config change channel, so every instance drops them too.
+package respcache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/saidutt46/switchboard-gateway/internal/config"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// EntityType identifies purge events on the config change channel.
+const EntityType = "cache"
+
+var (
+	cachedBytes = metrics.NewGaugeVec(
+		"gateway_response_cache_bytes",
+		"Bytes of responses held in the response cache",
+	)
+	purgedTotal = metrics.NewCounterVec(
+		"gateway_response_cache_purged_total",
+		"Response cache entries dropped by purges",
+	)
+)
+
+// Entry is a cached response.
+type Entry struct {
+	Key     string
+	RouteID string
+	Path    string
+	Tags    []string
+
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	Stored  time.Time
+	Expires time.Time
+
+	// StaleUntil ends the window in which the expired entry may stand in
+	// for a failed upstream (zero = never)
+	StaleUntil time.Time
+}
+
+// size approximates the memory an entry holds.
+func (e *Entry) size() int64 {
+	n := len(e.Key) + len(e.RouteID) + len(e.Path) + len(e.Body)
+	for _, tag := range e.Tags {
+		n += len(tag)
+	}
+	for name, values := range e.Header {
+		n += len(name)
+		for _, v := range values {
+			n += len(v)
+		}
+	}
+	return int64(n)
+}
+
+// Store holds cached responses.
+type Store struct {
+	maxBytes int64
+	now      func() time.Time
+
+	mu    sync.Mutex
+	lru   *list.List // front = most recently used; values are *Entry
+	items map[string]*list.Element
+	bytes int64
+}
+
+// NewStore creates a store holding up to maxBytes of responses.
+func NewStore(maxBytes int64) *Store {
+	return &Store{
+		maxBytes: maxBytes,
+		now:      time.Now,
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the unexpired entry for key, or nil.
+func (s *Store) Get(key string) *Entry {
+	return s.get(key, false)
+}
+
+// GetStale returns the entry for key if it has expired but is still
+// within its stale window, or nil.
+func (s *Store) GetStale(key string) *Entry {
+	return s.get(key, true)
+}
+
+func (s *Store) get(key string, stale bool) *Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*Entry)
+	now := s.now()
+	if !now.Before(entry.Expires) && !now.Before(entry.StaleUntil) {
+		s.remove(elem)
+		return nil
+	}
+	if now.Before(entry.Expires) == stale {
+		return nil
+	}
+	s.lru.MoveToFront(elem)
+	return entry
+}
+
+// Put stores an entry, replacing any with the same key and evicting the
+// least recently used beyond the size limit. Entries larger than the
+// whole store aren't kept.
+func (s *Store) Put(entry *Entry) {
+	if entry.size() > s.maxBytes {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.items[entry.Key]; ok {
+		s.remove(elem)
+	}
+	s.items[entry.Key] = s.lru.PushFront(entry)
+	s.bytes += entry.size()
+	for s.bytes > s.maxBytes {
+		s.remove(s.lru.Back())
+	}
+	cachedBytes.Set(float64(s.bytes))
+}
+
+// Purge drops the entries matching p and returns how many there were.
+func (s *Store) Purge(p Purge) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for elem := s.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if p.matches(elem.Value.(*Entry)) {
+			s.remove(elem)
+			purged++
+		}
+		elem = next
+	}
+	cachedBytes.Set(float64(s.bytes))
+	purgedTotal.Add(float64(purged))
+	return purged
+}
+
+// Len returns the number of entries held, expired ones included.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// remove drops an entry. The caller holds s.mu.
+func (s *Store) remove(elem *list.Element) {
+	entry := s.lru.Remove(elem).(*Entry)
+	delete(s.items, entry.Key)
+	s.bytes -= entry.size()
+}
+
+// Purge selects cache entries. Every field set must match; at least one
+// must be set.
+type Purge struct {
+	// RouteID matches the entries of one route
+	RouteID string `json:"route,omitempty"`
+
+	// Path matches a request path exactly, or every path under a prefix
+	// when it ends in "*"
+	Path string `json:"path,omitempty"`
+
+	// Tag matches entries whose response listed it in Surrogate-Key
+	Tag string `json:"tag,omitempty"`
+}
+
+// Validate checks that p selects something.
+func (p Purge) Validate() error {
+	if p.RouteID == "" && p.Path == "" && p.Tag == "" {
+		return errors.New("a route, path or tag is required")
+	}
+	if p.Path != "" && !strings.HasPrefix(p.Path, "/") {
+		return errors.New("path must start with /")
+	}
+	if strings.ContainsAny(p.Tag, " \t") {
+		return errors.New("tag cannot contain spaces")
+	}
+	return nil
+}
+
+// matches reports whether p selects e.
+func (p Purge) matches(e *Entry) bool {
+	if p.RouteID != "" && p.RouteID != e.RouteID {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(p.Path, "*"); ok {
+		if !strings.HasPrefix(e.Path, prefix) {
+			return false
+		}
+	} else if p.Path != "" && p.Path != e.Path {
+		return false
+	}
+	if p.Tag != "" {
+		for _, tag := range e.Tags {
+			if tag == p.Tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Event returns the config change event carrying p to other instances.
+func (p Purge) Event() config.ConfigChangeEvent {
+	return config.ConfigChangeEvent{
+		EventType:  "cache_purge",
+		EntityType: EntityType,
+		Action:     "purge",
+		Metadata:   map[string]interface{}{"route": p.RouteID, "path": p.Path, "tag": p.Tag},
+	}
+}
+
+// PurgeFromEvent decodes a purge published by Event.
+func PurgeFromEvent(event config.ConfigChangeEvent) (Purge, error) {
+	field := func(name string) string {
+		value, _ := event.Metadata[name].(string)
+		return value
+	}
+	p := Purge{RouteID: field("route"), Path: field("path"), Tag: field("tag")}
+	return p, p.Validate()
+}
+
+// Purger purges the local store and publishes purges to the other
+// instances.
+type Purger struct {
+	store *Store
+	redis *redis.Client // nil = purges stay on this instance
+}
+
+// NewPurger creates a purger for store, publishing over redisClient.
+func NewPurger(store *Store, redisClient *redis.Client) *Purger {
+	return &Purger{store: store, redis: redisClient}
+}
+
+// Purge drops the entries matching p on this instance and publishes p so
+// the others drop theirs. The publishing instance receives its own event
+// too; purging twice is harmless. propagated is false without Redis.
+func (pg *Purger) Purge(ctx context.Context, p Purge) (purged int, propagated bool, err error) {
+	if err := p.Validate(); err != nil {
+		return 0, false, err
+	}
+	purged = pg.store.Purge(p)
+	if pg.redis == nil {
+		return purged, false, nil
+	}
+
+	payload, err := json.Marshal(p.Event())
+	if err != nil {
+		return purged, false, err
+	}
+	if err := pg.redis.Publish(ctx, config.ChangesChannel, payload).Err(); err != nil {
+		return purged, false, fmt.Errorf("failed to publish purge: %w", err)
+	}
+	return purged, true, nil
+}
@@ -0,0 +1,180 @@
+package respcache
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func entry(key, routeID, path string, tags ...string) *Entry {
+	return &Entry{
+		Key:        key,
+		RouteID:    routeID,
+		Path:       path,
+		Tags:       tags,
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       []byte(`{"id": 1}`),
+		Expires:    time.Now().Add(time.Minute),
+	}
+}
+
+func TestStore_GetPut(t *testing.T) {
+	s := NewStore(1 << 20)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.Put(entry("a", "r1", "/a"))
+	if got := s.Get("a"); got == nil || string(got.Body) != `{"id": 1}` {
+		t.Fatalf("Get(a) = %v, want the stored entry", got)
+	}
+	if s.Get("b") != nil {
+		t.Error("Get(b) returned an entry never stored")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if s.Get("a") != nil {
+		t.Error("Get(a) returned an expired entry")
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len() = %d after expiry, want 0", s.Len())
+	}
+}
+
+func TestStore_GetStale(t *testing.T) {
+	s := NewStore(1 << 20)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	e := entry("a", "r1", "/a")
+	e.StaleUntil = e.Expires.Add(time.Minute)
+	s.Put(e)
+	s.Put(entry("b", "r1", "/b"))
+	if s.GetStale("a") != nil {
+		t.Error("GetStale(a) returned a fresh entry")
+	}
+
+	now = now.Add(90 * time.Second)
+	if s.Get("a") != nil {
+		t.Error("Get(a) returned an expired entry")
+	}
+	if s.GetStale("a") == nil {
+		t.Error("GetStale(a) = nil within the stale window")
+	}
+	if s.GetStale("b") != nil || s.Len() != 1 {
+		t.Error("entry without a stale window kept after expiry")
+	}
+
+	now = now.Add(time.Minute)
+	if s.GetStale("a") != nil || s.Len() != 0 {
+		t.Error("entry kept after its stale window")
+	}
+}
+
+func TestStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	size := entry("a", "r1", "/a").size()
+	s := NewStore(2 * size)
+
+	s.Put(entry("a", "r1", "/a"))
+	s.Put(entry("b", "r1", "/b"))
+	s.Get("a") // b is now the least recently used
+	s.Put(entry("c", "r1", "/c"))
+
+	if s.Get("b") != nil {
+		t.Error("least recently used entry kept")
+	}
+	if s.Get("a") == nil || s.Get("c") == nil {
+		t.Error("recently used entries evicted")
+	}
+
+	// Replacing an entry doesn't count it twice
+	s.Put(entry("c", "r1", "/c"))
+	if s.Len() != 2 || s.bytes != 2*size {
+		t.Errorf("after replace: %d entries, %d bytes; want 2, %d", s.Len(), s.bytes, 2*size)
+	}
+
+	// Larger than the whole store
+	big := entry("big", "r1", "/big")
+	big.Body = make([]byte, 4*size)
+	s.Put(big)
+	if s.Get("big") != nil || s.Len() != 2 {
+		t.Error("entry larger than the store was kept")
+	}
+}
+
+func TestStore_Purge(t *testing.T) {
+	tests := []struct {
+		name  string
+		purge Purge
+		want  []string // keys purged
+	}{
+		{name: "route", purge: Purge{RouteID: "r1"}, want: []string{"a", "b"}},
+		{name: "path", purge: Purge{Path: "/products/1"}, want: []string{"a", "c"}},
+		{name: "path prefix", purge: Purge{Path: "/products/*"}, want: []string{"a", "b", "c"}},
+		{name: "route and path", purge: Purge{RouteID: "r2", Path: "/products/1"}, want: []string{"c"}},
+		{name: "tag", purge: Purge{Tag: "product-1"}, want: []string{"a", "c"}},
+		{name: "tag on a route", purge: Purge{RouteID: "r1", Tag: "products"}, want: []string{"a", "b"}},
+		{name: "no match", purge: Purge{Tag: "orders"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore(1 << 20)
+			s.Put(entry("a", "r1", "/products/1", "products", "product-1"))
+			s.Put(entry("b", "r1", "/products/2", "products", "product-2"))
+			s.Put(entry("c", "r2", "/products/1", "product-1"))
+			s.Put(entry("d", "r2", "/orders/1"))
+
+			if got := s.Purge(tt.purge); got != len(tt.want) {
+				t.Errorf("Purge() = %d, want %d", got, len(tt.want))
+			}
+			purged := make(map[string]bool)
+			for _, key := range tt.want {
+				purged[key] = true
+			}
+			for _, key := range []string{"a", "b", "c", "d"} {
+				if kept := s.Get(key) != nil; kept == purged[key] {
+					t.Errorf("entry %s kept = %v, want %v", key, kept, !purged[key])
+				}
+			}
+		})
+	}
+}
+
+func TestPurge_Validate(t *testing.T) {
+	for _, p := range []Purge{
+		{},
+		{Path: "products/1"},
+		{Tag: "two tags"},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", p)
+		}
+	}
+}
+
+func TestPurge_Event(t *testing.T) {
+	want := Purge{RouteID: "r1", Path: "/products/*", Tag: "products"}
+	event := want.Event()
+	if event.EntityType != EntityType {
+		t.Errorf("EntityType = %q, want %q", event.EntityType, EntityType)
+	}
+	got, err := PurgeFromEvent(event)
+	if err != nil || got != want {
+		t.Errorf("PurgeFromEvent() = %+v, %v; want %+v", got, err, want)
+	}
+}
+
+func TestPurger_WithoutRedis(t *testing.T) {
+	s := NewStore(1 << 20)
+	s.Put(entry("a", "r1", "/a", "t"))
+	purged, propagated, err := NewPurger(s, nil).Purge(context.Background(), Purge{Tag: "t"})
+	if err != nil || purged != 1 || propagated {
+		t.Errorf("Purge() = %d, %v, %v; want 1, false, nil", purged, propagated, err)
+	}
+
+	if _, _, err := NewPurger(s, nil).Purge(context.Background(), Purge{}); err == nil {
+		t.Error("Purge() of nothing succeeded, want error")
+	}
+}
@@ -0,0 +1,266 @@
+// Package router - Route conflict detection
+//
+// Every route whose path matches a request is a candidate, and the first
+// one accepting the request's method and host serves it. Routes sharing a
+// pattern are tried most recently inserted first, and static segments
+// take precedence over parameters, so "/users/me" is tried before
+// "/users/:id".
+//
+// Two routes only conflict when a request can match both: their patterns
+// overlap and so do their methods and hosts. Then the later route on a
+// duplicate pattern (or the static one, when shadowing) serves the
+// overlapping requests and the other never sees them.
+//
+// Neither case fails a load (shadowing is often intended), but both are
+// reported: logged on every load and listed on /status. All workspaces
+// share one routing tree, so routes in different workspaces conflict too;
+// such conflicts carry both workspaces.
+package router
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// Conflict kinds.
+const (
+	// ConflictDuplicate: both routes register the same pattern (up to
+	// parameter names) for overlapping methods and hosts; only Route is
+	// reachable for those.
+	ConflictDuplicate = "duplicate"
+
+	// ConflictShadowed: Route's static segment takes precedence over
+	// Other's parameter, so requests matching both go to Route.
+	ConflictShadowed = "shadowed"
+)
+
+// Conflict is an overlap between the path patterns of two enabled routes
+// with overlapping methods and hosts.
+//
+// Route is the route that serves the overlapping requests; Other is the
+// route that loses them.
+type Conflict struct {
+	Kind           string `json:"kind"`
+	Pattern        string `json:"pattern"`
+	Workspace      string `json:"workspace"`
+	RouteID        string `json:"route_id"`
+	RouteName      string `json:"route_name,omitempty"`
+	OtherPattern   string `json:"other_pattern"`
+	OtherWorkspace string `json:"other_workspace"`
+	OtherRouteID   string `json:"other_route_id"`
+	OtherRouteName string `json:"other_route_name,omitempty"`
+}
+
+// CrossWorkspace reports whether the routes belong to different workspaces.
+func (c Conflict) CrossWorkspace() bool {
+	return c.Workspace != c.OtherWorkspace
+}
+
+// String describes the conflict for logs.
+func (c Conflict) String() string {
+	if c.Kind == ConflictDuplicate {
+		return fmt.Sprintf("route %s and route %s both register %s for the same methods and hosts; only %s is reachable for them",
+			c.OtherRouteID, c.RouteID, c.Pattern, c.RouteID)
+	}
+	return fmt.Sprintf("route %s (%s) shadows route %s (%s) for requests matching both",
+		c.RouteID, c.Pattern, c.OtherRouteID, c.OtherPattern)
+}
+
+// registration is one path pattern of an enabled route, in insertion order.
+type registration struct {
+	route    *database.Route
+	pattern  string
+	segments []string
+}
+
+// FindConflicts reports overlapping path patterns between enabled routes
+// whose methods and hosts also overlap. routes must be in the order they
+// are inserted into the tree (it decides which duplicate wins). Overlaps
+// between the paths of a single route are ignored.
+func FindConflicts(routes []*database.Route) []Conflict {
+	// Only patterns with the same number of segments can overlap
+	var depths []int
+	byDepth := make(map[int][]registration)
+	for _, route := range routes {
+		if route == nil || !route.Enabled {
+			continue
+		}
+		for _, pattern := range route.Paths {
+			pattern = normalizePath(pattern)
+			segments := splitPath(pattern)
+			if _, ok := byDepth[len(segments)]; !ok {
+				depths = append(depths, len(segments))
+			}
+			byDepth[len(segments)] = append(byDepth[len(segments)], registration{route: route, pattern: pattern, segments: segments})
+		}
+	}
+
+	var conflicts []Conflict
+	reported := make(map[string]bool)
+
+	for _, depth := range depths {
+		regs := byDepth[depth]
+		for i := range regs {
+			for j := i + 1; j < len(regs); j++ {
+				a, b := regs[i], regs[j]
+				if a.route == b.route || !methodsOverlap(a.route.Methods, b.route.Methods) || !hostsOverlap(a.route.Hosts, b.route.Hosts) {
+					continue
+				}
+
+				kind, aWins, ok := overlap(a.segments, b.segments)
+				if !ok {
+					continue
+				}
+
+				// Routes on an identical pattern are tried most recent first
+				winner, loser := b, a
+				switch {
+				case kind == ConflictShadowed && aWins:
+					winner, loser = a, b
+				case kind == ConflictDuplicate && a.pattern != b.pattern:
+					// Same shape, different parameter names: separate sibling
+					// nodes, and the earlier one is searched first
+					winner, loser = a, b
+				}
+
+				key := kind + "|" + winner.route.ID + "|" + winner.pattern + "|" + loser.route.ID + "|" + loser.pattern
+				if reported[key] {
+					continue
+				}
+				reported[key] = true
+
+				conflicts = append(conflicts, Conflict{
+					Kind:           kind,
+					Pattern:        winner.pattern,
+					Workspace:      database.WorkspaceName(winner.route.Workspace),
+					RouteID:        winner.route.ID,
+					RouteName:      winner.route.Name.String,
+					OtherPattern:   loser.pattern,
+					OtherWorkspace: database.WorkspaceName(loser.route.Workspace),
+					OtherRouteID:   loser.route.ID,
+					OtherRouteName: loser.route.Name.String,
+				})
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// overlap compares two patterns with the same number of segments.
+//
+// Returns ConflictDuplicate if they match exactly the same paths, or
+// ConflictShadowed if some paths match both (aWins reports which one the
+// tree prefers: the first differing segment that is static). Patterns
+// containing a wildcard are treated as intended catch-alls and never
+// reported.
+func overlap(a, b []string) (kind string, aWins bool, ok bool) {
+	decided := false
+
+	for i := range a {
+		ta, _ := getSegmentType(a[i])
+		tb, _ := getSegmentType(b[i])
+		if ta == wildcard || tb == wildcard {
+			return "", false, false
+		}
+
+		switch {
+		case ta == static && tb == static:
+			if a[i] != b[i] {
+				return "", false, false
+			}
+		case ta == param && tb == param:
+			// Same shape whatever the parameter names
+		default:
+			if !decided {
+				aWins = ta == static
+				decided = true
+			}
+		}
+	}
+
+	if !decided {
+		return ConflictDuplicate, false, true
+	}
+	return ConflictShadowed, aWins, true
+}
+
+// methodsOverlap reports whether some method is accepted by both routes
+// (no methods means any).
+func methodsOverlap(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, ma := range a {
+		for _, mb := range b {
+			if ma == mb {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hostsOverlap reports whether some host is accepted by both routes (no
+// hosts means any). Wildcard patterns like "*.example.com" also cover
+// "example.com", as in hostMatchesPattern.
+func hostsOverlap(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, ha := range a {
+		for _, hb := range b {
+			if hostPatternsOverlap(ha, hb) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hostPatternsOverlap reports whether two host patterns match a common
+// host.
+func hostPatternsOverlap(a, b string) bool {
+	if a == b {
+		return true
+	}
+	coveredBy := func(host, pattern string) bool {
+		if !strings.HasPrefix(pattern, "*.") {
+			return false
+		}
+		suffix := pattern[2:]
+		return host == suffix || strings.HasSuffix(host, "."+suffix)
+	}
+	// A wildcard covers the other pattern's hosts if it covers its base
+	return coveredBy(strings.TrimPrefix(b, "*."), a) || coveredBy(strings.TrimPrefix(a, "*."), b)
+}
+
+// logConflicts warns about every conflict in a loaded route set.
+func logConflicts(conflicts []Conflict) {
+	for _, c := range conflicts {
+		log.Warn().
+			Str("component", "router").
+			Str("kind", c.Kind).
+			Str("workspace", c.Workspace).
+			Str("route_id", c.RouteID).
+			Str("pattern", c.Pattern).
+			Str("other_workspace", c.OtherWorkspace).
+			Str("other_route_id", c.OtherRouteID).
+			Str("other_pattern", c.OtherPattern).
+			Msg("Route conflict: " + c.String())
+	}
+}
+
+// Conflicts returns the route conflicts in the loaded configuration.
+func (r *Router) Conflicts() []Conflict {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	conflicts := make([]Conflict, len(r.conflicts))
+	copy(conflicts, r.conflicts)
+	return conflicts
+}
@@ -0,0 +1,146 @@
+package router
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+func conflictRoute(id string, paths ...string) *database.Route {
+	return &database.Route{ID: id, ServiceID: "svc", Paths: paths, Enabled: true}
+}
+
+func TestFindConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes []*database.Route
+		want   []Conflict
+	}{
+		{
+			name: "exact duplicate - later insert wins",
+			routes: []*database.Route{
+				conflictRoute("a", "/users"),
+				conflictRoute("b", "/users/"),
+			},
+			want: []Conflict{{Kind: ConflictDuplicate, Pattern: "/users", Workspace: "default", RouteID: "b", OtherPattern: "/users", OtherWorkspace: "default", OtherRouteID: "a"}},
+		},
+		{
+			name: "param names differ - earlier sibling wins",
+			routes: []*database.Route{
+				conflictRoute("a", "/users/:id"),
+				conflictRoute("b", "/users/:user_id"),
+			},
+			want: []Conflict{{Kind: ConflictDuplicate, Pattern: "/users/:id", Workspace: "default", RouteID: "a", OtherPattern: "/users/:user_id", OtherWorkspace: "default", OtherRouteID: "b"}},
+		},
+		{
+			name: "static shadows param",
+			routes: []*database.Route{
+				conflictRoute("me", "/users/me"),
+				conflictRoute("byid", "/users/:id"),
+			},
+			want: []Conflict{{Kind: ConflictShadowed, Pattern: "/users/me", Workspace: "default", RouteID: "me", OtherPattern: "/users/:id", OtherWorkspace: "default", OtherRouteID: "byid"}},
+		},
+		{
+			name: "first differing segment decides",
+			routes: []*database.Route{
+				conflictRoute("a", "/:org/repos"),
+				conflictRoute("b", "/acme/:section"),
+			},
+			want: []Conflict{{Kind: ConflictShadowed, Pattern: "/acme/:section", Workspace: "default", RouteID: "b", OtherPattern: "/:org/repos", OtherWorkspace: "default", OtherRouteID: "a"}},
+		},
+		{
+			name: "no overlap",
+			routes: []*database.Route{
+				conflictRoute("a", "/users/:id"),
+				conflictRoute("b", "/orders/:id"),
+				conflictRoute("c", "/users/:id/orders"),
+			},
+		},
+		{
+			name: "wildcards and paths of one route are not conflicts",
+			routes: []*database.Route{
+				conflictRoute("a", "/users/me", "/users/:id"),
+				conflictRoute("b", "/users/*"),
+			},
+		},
+		{
+			name: "disjoint methods or hosts are not conflicts",
+			routes: []*database.Route{
+				{ID: "get", ServiceID: "svc", Paths: []string{"/users"}, Methods: []string{"GET"}, Enabled: true},
+				{ID: "post", ServiceID: "svc", Paths: []string{"/users"}, Methods: []string{"POST"}, Enabled: true},
+				{ID: "api", ServiceID: "svc", Paths: []string{"/orders"}, Hosts: []string{"api.example.com"}, Enabled: true},
+				{ID: "admin", ServiceID: "svc", Paths: []string{"/orders"}, Hosts: []string{"admin.example.com"}, Enabled: true},
+			},
+		},
+		{
+			name: "overlapping methods and wildcard hosts conflict",
+			routes: []*database.Route{
+				{ID: "a", ServiceID: "svc", Paths: []string{"/users"}, Methods: []string{"GET", "PUT"}, Hosts: []string{"*.example.com"}, Enabled: true},
+				{ID: "b", ServiceID: "svc", Paths: []string{"/users"}, Methods: []string{"PUT"}, Hosts: []string{"api.example.com"}, Enabled: true},
+			},
+			want: []Conflict{{Kind: ConflictDuplicate, Pattern: "/users", Workspace: "default", RouteID: "b", OtherPattern: "/users", OtherWorkspace: "default", OtherRouteID: "a"}},
+		},
+		{
+			name: "routes in different workspaces conflict",
+			routes: []*database.Route{
+				{ID: "a", Workspace: "team-a", ServiceID: "svc", Paths: []string{"/users"}, Enabled: true},
+				{ID: "b", Workspace: "team-b", ServiceID: "svc", Paths: []string{"/users"}, Enabled: true},
+			},
+			want: []Conflict{{Kind: ConflictDuplicate, Pattern: "/users", Workspace: "team-b", RouteID: "b", OtherPattern: "/users", OtherWorkspace: "team-a", OtherRouteID: "a"}},
+		},
+		{
+			name: "disabled routes are ignored",
+			routes: []*database.Route{
+				conflictRoute("a", "/users"),
+				{ID: "b", Paths: []string{"/users"}, Enabled: false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FindConflicts(tt.routes)
+			if len(got) != len(tt.want) {
+				t.Fatalf("FindConflicts() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("conflict %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestFindConflicts_WinnerMatchesRouter checks the reported winner is the
+// route the router actually selects.
+func TestFindConflicts_WinnerMatchesRouter(t *testing.T) {
+	cases := [][]*database.Route{
+		{conflictRoute("a", "/users"), conflictRoute("b", "/users")},
+		{conflictRoute("a", "/users/:id"), conflictRoute("b", "/users/:user_id")},
+		{conflictRoute("a", "/users/:id"), conflictRoute("b", "/users/me")},
+		{conflictRoute("a", "/:org/repos"), conflictRoute("b", "/acme/:section")},
+	}
+	requests := []string{"/users", "/users/me", "/users/me", "/acme/repos"}
+	services := []*database.Service{{ID: "svc", Enabled: true}}
+
+	for i, routes := range cases {
+		conflicts := FindConflicts(routes)
+		if len(conflicts) != 1 {
+			t.Fatalf("case %d: expected one conflict, got %+v", i, conflicts)
+		}
+
+		r := NewRouter(routes, services, nil)
+		result, err := r.Match(httptest.NewRequest("GET", requests[i], nil))
+		if err != nil {
+			t.Fatalf("case %d: Match() error = %v", i, err)
+		}
+		if result.Route.ID != conflicts[0].RouteID {
+			t.Errorf("case %d: router served %s, conflict reports %s as winner", i, result.Route.ID, conflicts[0].RouteID)
+		}
+		if got := r.Conflicts(); len(got) != 1 {
+			t.Errorf("case %d: Router.Conflicts() = %+v", i, got)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// Candidate is a route whose path matched a request, in evaluation order.
+type Candidate struct {
+	Route  *database.Route
+	Params map[string]string
+	Reason string // Reject* reason, empty for the selected route
+}
+
+// Explain matches a request like Match but also reports every route whose
+// path matched and why it was or wasn't selected.
+//
+// Used by the admin dry-run endpoint to debug unexpected 404s. Returns a
+// nil result if no route (nor the default service) would serve the
+// request.
+func (r *Router) Explain(req *http.Request) (*MatchResult, []Candidate) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result *MatchResult
+	var candidates []Candidate
+	methodOnly := false // some route would serve another method
+
+	for _, match := range r.matcher.Match(req.URL.Path) {
+		candidate := Candidate{Route: match.Route, Params: match.Params}
+
+		service, reason := r.checkRoute(match.Route, req.Method, req.Host)
+		switch {
+		case reason != "":
+			candidate.Reason = reason
+			if reason == RejectMethod {
+				if _, other := r.checkRouteIgnoringMethod(match.Route, req.Host); other == "" {
+					methodOnly = true
+				}
+			}
+		case result != nil:
+			candidate.Reason = RejectShadowed
+		default:
+			result = &MatchResult{
+				Route:      match.Route,
+				Service:    service,
+				PathParams: match.Params,
+				Chain:      r.chainBuilder.BuildForRoute(match.Route, service),
+			}
+		}
+
+		candidates = append(candidates, candidate)
+	}
+
+	// Routes orphaned by a disabled or missing service aren't in the tree,
+	// but are reported so the reason for a 404 is visible
+	for _, match := range r.orphaned.Match(req.URL.Path) {
+		candidates = append(candidates, Candidate{
+			Route:  match.Route,
+			Params: match.Params,
+			Reason: r.orphanReason(match.Route.ID),
+		})
+	}
+
+	// Like Match, unmatched requests go to the default service unless
+	// the path is served for other methods (405)
+	if result == nil && !methodOnly {
+		result = r.matchDefault(req)
+	}
+
+	return result, candidates
+}
@@ -0,0 +1,62 @@
+package router
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+func TestRouter_Explain(t *testing.T) {
+	service := &database.Service{ID: "svc", Name: "svc", Enabled: true}
+	disabled := &database.Service{ID: "off", Name: "off", Enabled: false}
+
+	routes := []*database.Route{
+		{ID: "orders", ServiceID: service.ID, Paths: []string{"/api/orders"}, Methods: []string{"POST"}, Enabled: true},
+		{ID: "admin", ServiceID: service.ID, Paths: []string{"/admin"}, Hosts: []string{"admin.example.com"}, Enabled: true},
+		{ID: "legacy", ServiceID: disabled.ID, Paths: []string{"/legacy"}, Enabled: true},
+	}
+
+	r := NewRouter(routes, []*database.Service{service, disabled}, []plugin.PluginInstance{})
+
+	tests := []struct {
+		method, path, host string
+		wantRoute          string // selected route, empty for none
+		wantReason         string
+	}{
+		{"POST", "/api/orders", "api.example.com", "orders", ""},
+		{"GET", "/api/orders", "api.example.com", "", RejectMethod},
+		{"GET", "/admin", "api.example.com", "", RejectHost},
+		{"GET", "/admin", "admin.example.com", "admin", ""},
+		{"GET", "/legacy", "api.example.com", "", RejectServiceDisabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.host+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Host = tt.host
+
+			result, candidates := r.Explain(req)
+
+			if tt.wantRoute == "" && result != nil {
+				t.Errorf("expected no match, got route %s", result.Route.ID)
+			}
+			if tt.wantRoute != "" && (result == nil || result.Route.ID != tt.wantRoute) {
+				t.Errorf("expected route %s, got %v", tt.wantRoute, result)
+			}
+
+			if len(candidates) != 1 {
+				t.Fatalf("got %d candidates, want 1", len(candidates))
+			}
+			if candidates[0].Reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", candidates[0].Reason, tt.wantReason)
+			}
+		})
+	}
+
+	// No path match at all: no candidates
+	if result, candidates := r.Explain(httptest.NewRequest("GET", "/missing", nil)); result != nil || len(candidates) != 0 {
+		t.Errorf("expected no result and no candidates for unknown path")
+	}
+}
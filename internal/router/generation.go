@@ -0,0 +1,48 @@
+// Package router - Config generation
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+// configGeneration returns a short content hash of a loaded config set
+// (routes, enabled services and plugin configs).
+//
+// Instances that loaded the same rows report the same generation, so a
+// fleet can tell whether every gateway runs the same configuration. Load
+// order doesn't matter: everything is hashed sorted by ID.
+func configGeneration(routes []*database.Route, services []*database.Service, pluginInstances []plugin.PluginInstance) string {
+	sortedRoutes := append([]*database.Route(nil), routes...)
+	sort.Slice(sortedRoutes, func(i, j int) bool { return sortedRoutes[i].ID < sortedRoutes[j].ID })
+
+	var sortedServices []*database.Service
+	for _, svc := range services {
+		if svc.Enabled {
+			sortedServices = append(sortedServices, svc)
+		}
+	}
+	sort.Slice(sortedServices, func(i, j int) bool { return sortedServices[i].ID < sortedServices[j].ID })
+
+	var plugins []*database.Plugin
+	for _, inst := range pluginInstances {
+		if inst.Config != nil {
+			plugins = append(plugins, inst.Config)
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].ID < plugins[j].ID })
+
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, v := range []interface{}{sortedRoutes, sortedServices, plugins} {
+		if err := enc.Encode(v); err != nil {
+			return "unknown"
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
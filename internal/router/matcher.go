@@ -73,8 +73,10 @@ func (m *Matcher) AddRoute(route *database.Route) {
 
 // Match finds all routes that match the given path.
 //
-// With radix tree, we get the best match directly (O(log n)).
-// Returns matches in priority order (most specific first).
+// Returns matches in priority order (most specific first), so the router
+// can filter them by method, host and schedule and fall through to the
+// next candidate. A route whose several paths match is returned once, for
+// its most specific path.
 //
 // Example:
 //
@@ -86,43 +88,40 @@ func (m *Matcher) Match(path string) []*PathMatch {
 		Str("path", path).
 		Msg("Matching path against radix tree")
 
-	// Search the radix tree (O(log n))
-	route, params := m.tree.Search(path)
+	// Search the radix tree (O(log n) per candidate)
+	found := m.tree.SearchAll(path)
 
-	// No match found
-	if route == nil {
-		log.Debug().
-			Str("component", "matcher").
-			Str("path", path).
-			Msg("No route matched in radix tree")
-		return nil
+	matches := make([]*PathMatch, 0, len(found))
+	seen := make(map[string]bool, len(found))
+	for _, match := range found {
+		route := match.Route
+
+		// Check if route is still enabled (defensive check)
+		if !route.Enabled || seen[route.ID] {
+			continue
+		}
+		seen[route.ID] = true
+		matches = append(matches, match)
 	}
 
-	// Check if route is still enabled (defensive check)
-	if !route.Enabled {
+	if len(matches) == 0 {
 		log.Debug().
 			Str("component", "matcher").
 			Str("path", path).
-			Str("route_id", route.ID).
-			Msg("Matched route is disabled")
+			Msg("No route matched in radix tree")
 		return nil
 	}
 
-	// Return single match (radix tree gives us the best match)
-	match := &PathMatch{
-		Route:  route,
-		Params: params,
-	}
-
 	log.Debug().
 		Str("component", "matcher").
 		Str("path", path).
-		Str("route_id", route.ID).
-		Str("route_name", route.Name.String).
-		Interface("params", params).
+		Str("route_id", matches[0].Route.ID).
+		Str("route_name", matches[0].Route.Name.String).
+		Interface("params", matches[0].Params).
+		Int("candidates", len(matches)).
 		Msg("Path matched successfully via radix tree")
 
-	return []*PathMatch{match}
+	return matches
 }
 
 // Clear removes all routes from the matcher.
@@ -0,0 +1,141 @@
+// Package router - Routes orphaned by disabled or deleted services
+//
+// A route is only live while its service is. Enabled routes whose service
+// is disabled (or no longer exists) are left out of the radix tree on every
+// load, so they never take part in matching: a path they registered is
+// served by the other routes, the default service, or a 404, exactly as if
+// they were disabled too. Re-enabling the service brings them back on the
+// next reload.
+//
+// Orphaned routes are still reported, so a disabled service doesn't
+// silently take its routes with it: as warnings on every load, in
+// orphaned_routes on GET /status and /admin/dashboard, and by Explain
+// with the reason.
+package router
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// Orphan is an enabled route left out of the radix tree because its
+// service is disabled or missing.
+type Orphan struct {
+	RouteID   string `json:"route_id"`
+	RouteName string `json:"route_name,omitempty"`
+	Workspace string `json:"workspace"`
+	ServiceID string `json:"service_id"`
+	Reason    string `json:"reason"` // RejectServiceDisabled or RejectServiceMissing
+}
+
+// String describes the orphan for logs and warnings.
+func (o Orphan) String() string {
+	route := o.RouteID
+	if o.RouteName != "" {
+		route = fmt.Sprintf("%s (%s)", o.RouteName, o.RouteID)
+	}
+	if o.Reason == RejectServiceDisabled {
+		return fmt.Sprintf("route %s is not served: service %s is disabled", route, o.ServiceID)
+	}
+	return fmt.Sprintf("route %s is not served: service %s does not exist", route, o.ServiceID)
+}
+
+// FindOrphans splits the enabled routes into those whose service is
+// enabled and those orphaned by a disabled or missing one. services must
+// include disabled services, or their routes are reported as missing.
+func FindOrphans(routes []*database.Route, services []*database.Service) (live []*database.Route, orphans []Orphan) {
+	enabled := make(map[string]bool, len(services))
+	for _, svc := range services {
+		enabled[svc.ID] = svc.Enabled
+	}
+
+	for _, route := range routes {
+		if !route.Enabled {
+			continue
+		}
+
+		serviceEnabled, exists := enabled[route.ServiceID]
+		if serviceEnabled {
+			live = append(live, route)
+			continue
+		}
+
+		reason := RejectServiceMissing
+		if exists {
+			reason = RejectServiceDisabled
+		}
+		orphans = append(orphans, Orphan{
+			RouteID:   route.ID,
+			RouteName: route.Name.String,
+			Workspace: database.WorkspaceName(route.Workspace),
+			ServiceID: route.ServiceID,
+			Reason:    reason,
+		})
+	}
+
+	return live, orphans
+}
+
+// buildMatchers builds the radix tree of the live routes, and a separate
+// tree of the orphaned ones that only Explain consults.
+func buildMatchers(routes []*database.Route, services []*database.Service) (live []*database.Route, matcher, orphaned *Matcher, orphans []Orphan) {
+	live, orphans = FindOrphans(routes, services)
+
+	matcher = NewMatcher()
+	for _, route := range live {
+		matcher.AddRoute(route)
+	}
+
+	orphaned = NewMatcher()
+	if len(orphans) > 0 {
+		byID := make(map[string]bool, len(orphans))
+		for _, o := range orphans {
+			byID[o.RouteID] = true
+		}
+		for _, route := range routes {
+			if route.Enabled && byID[route.ID] {
+				orphaned.AddRoute(route)
+			}
+		}
+	}
+
+	logOrphans(orphans)
+	return live, matcher, orphaned, orphans
+}
+
+// logOrphans warns about every orphaned route in a loaded route set.
+func logOrphans(orphans []Orphan) {
+	for _, o := range orphans {
+		log.Warn().
+			Str("component", "router").
+			Str("route_id", o.RouteID).
+			Str("service_id", o.ServiceID).
+			Str("reason", o.Reason).
+			Msg("Orphaned route: " + o.String())
+	}
+}
+
+// Orphans returns the enabled routes left out of the radix tree because
+// their service is disabled or missing.
+func (r *Router) Orphans() []Orphan {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	orphans := make([]Orphan, len(r.orphans))
+	copy(orphans, r.orphans)
+	return orphans
+}
+
+// orphanReason returns why a route is orphaned, or "" if it isn't.
+// Caller must hold r.mu.
+func (r *Router) orphanReason(routeID string) string {
+	for _, o := range r.orphans {
+		if o.RouteID == routeID {
+			return o.Reason
+		}
+	}
+	return ""
+}
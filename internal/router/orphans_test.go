@@ -0,0 +1,91 @@
+package router
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+func TestRouter_OrphanedRoutes(t *testing.T) {
+	service := &database.Service{ID: "svc", Name: "svc", Enabled: true}
+	disabled := &database.Service{ID: "off", Name: "off", Enabled: false}
+
+	routes := []*database.Route{
+		{ID: "users", ServiceID: service.ID, Paths: []string{"/users/:id"}, Enabled: true},
+		{ID: "me", ServiceID: disabled.ID, Paths: []string{"/users/me"}, Enabled: true},
+		{ID: "gone", ServiceID: "deleted", Paths: []string{"/gone"}, Enabled: true},
+		{ID: "off-route", ServiceID: disabled.ID, Paths: []string{"/old"}, Enabled: false},
+	}
+
+	r := NewRouter(routes, []*database.Service{service, disabled}, []plugin.PluginInstance{})
+
+	want := []Orphan{
+		{RouteID: "me", Workspace: "default", ServiceID: "off", Reason: RejectServiceDisabled},
+		{RouteID: "gone", Workspace: "default", ServiceID: "deleted", Reason: RejectServiceMissing},
+	}
+	got := r.Orphans()
+	if len(got) != len(want) {
+		t.Fatalf("Orphans() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Orphans()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// An orphaned route neither serves nor shadows its path
+	result, err := r.Match(httptest.NewRequest("GET", "/users/me", nil))
+	if err != nil || result.Route.ID != "users" {
+		t.Fatalf("GET /users/me = %v, %v; want route users", result, err)
+	}
+	if result.PathParams["id"] != "me" {
+		t.Errorf("id = %q, want me", result.PathParams["id"])
+	}
+	if conflicts := r.Conflicts(); len(conflicts) != 0 {
+		t.Errorf("Conflicts() = %v, want none for an orphaned route", conflicts)
+	}
+
+	if _, err := r.Match(httptest.NewRequest("GET", "/gone", nil)); err == nil {
+		t.Error("GET /gone matched, want no route")
+	}
+
+	// Explain still reports orphaned routes, with the reason
+	_, candidates := r.Explain(httptest.NewRequest("GET", "/gone", nil))
+	if len(candidates) != 1 || candidates[0].Route.ID != "gone" || candidates[0].Reason != RejectServiceMissing {
+		t.Errorf("Explain(/gone) candidates = %+v, want gone with %q", candidates, RejectServiceMissing)
+	}
+}
+
+func TestRouter_DisabledServiceRoute(t *testing.T) {
+	disabled := &database.Service{ID: "billing-svc", Name: "billing", Enabled: false}
+	routes := []*database.Route{
+		{ID: "billing", ServiceID: disabled.ID, Paths: []string{"/billing"}, Enabled: true},
+	}
+	r := NewRouter(routes, []*database.Service{disabled}, nil)
+
+	if result, err := r.Match(httptest.NewRequest("GET", "/billing", nil)); err == nil {
+		t.Errorf("GET /billing matched route %s, want no match", result.Route.ID)
+	}
+	if _, _, _, ok := r.ChainForRoute("billing"); ok {
+		t.Error("ChainForRoute(billing) = ok, want false for a disabled service")
+	}
+
+	orphans := r.Orphans()
+	if len(orphans) != 1 || orphans[0].RouteID != "billing" || orphans[0].Reason != RejectServiceDisabled {
+		t.Errorf("Orphans() = %+v, want billing with %q", orphans, RejectServiceDisabled)
+	}
+}
+
+func TestOrphan_String(t *testing.T) {
+	o := Orphan{RouteID: "r1", RouteName: "legacy", ServiceID: "s1", Reason: RejectServiceDisabled}
+	if got, want := o.String(), "route legacy (r1) is not served: service s1 is disabled"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	o = Orphan{RouteID: "r1", ServiceID: "s1", Reason: RejectServiceMissing}
+	if got, want := o.String(), "route r1 is not served: service s1 does not exist"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -28,11 +28,11 @@ const (
 type node struct {
 	// Node properties
 	nType    nodeType
-	label    string          // Path segment label
-	prefix   string          // Common prefix for this node
-	children []*node         // Child nodes
-	route    *database.Route // Route if this is a leaf node
-	priority uint32          // Priority for sorting (higher = checked first)
+	label    string            // Path segment label
+	prefix   string            // Common prefix for this node
+	children []*node           // Child nodes
+	routes   []*database.Route // Routes registered on this path, most recently inserted first
+	priority uint32            // Priority for sorting (higher = checked first)
 
 	// Parameter handling
 	paramName string // Name of parameter if nType == param (e.g., "id" from ":id")
@@ -112,9 +112,17 @@ func (t *RadixTree) Insert(path string, route *database.Route) {
 		}
 	}
 
-	// Set route at leaf node
-	current.route = route
-	t.size++
+	// Register the route at the leaf node. Several routes can share a
+	// path (told apart by method or host); the most recently inserted is
+	// tried first. Re-inserting a route replaces it.
+	routes := []*database.Route{route}
+	for _, existing := range current.routes {
+		if existing.ID != route.ID {
+			routes = append(routes, existing)
+		}
+	}
+	t.size += len(routes) - len(current.routes)
+	current.routes = routes
 
 	log.Debug().
 		Str("component", "radix_tree").
@@ -123,7 +131,7 @@ func (t *RadixTree) Insert(path string, route *database.Route) {
 		Msg("Route inserted successfully")
 }
 
-// Search finds a route matching the given path
+// Search finds the best route matching the given path
 //
 // Returns the route and extracted parameters.
 // Example:
@@ -131,6 +139,20 @@ func (t *RadixTree) Insert(path string, route *database.Route) {
 //	route, params := tree.Search("/api/users/123")
 //	// params = {"id": "123"}
 func (t *RadixTree) Search(path string) (*database.Route, map[string]string) {
+	matches := t.SearchAll(path)
+	if len(matches) == 0 {
+		return nil, map[string]string{}
+	}
+	return matches[0].Route, matches[0].Params
+}
+
+// SearchAll finds every route whose path pattern matches the given path,
+// best match first: static segments before parameters before wildcards,
+// and among routes sharing a pattern the most recently inserted first.
+//
+// Each match has its own parameters. Routes registered on several
+// matching patterns appear once per pattern.
+func (t *RadixTree) SearchAll(path string) []*PathMatch {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
@@ -144,33 +166,28 @@ func (t *RadixTree) Search(path string) (*database.Route, map[string]string) {
 
 	// Split path into segments
 	segments := splitPath(path)
-	params := make(map[string]string)
 
 	// Search from root
-	route := t.search(t.root, segments, 0, params)
-
-	if route != nil {
-		log.Debug().
-			Str("component", "radix_tree").
-			Str("path", path).
-			Str("route_id", route.ID).
-			Interface("params", params).
-			Msg("Route found")
-	} else {
-		log.Debug().
-			Str("component", "radix_tree").
-			Str("path", path).
-			Msg("No route found")
-	}
+	var matches []*PathMatch
+	t.search(t.root, segments, 0, make(map[string]string), &matches)
+
+	log.Debug().
+		Str("component", "radix_tree").
+		Str("path", path).
+		Int("matches", len(matches)).
+		Msg("Route search complete")
 
-	return route, params
+	return matches
 }
 
-// search recursively searches the tree
-func (t *RadixTree) search(n *node, segments []string, index int, params map[string]string) *database.Route {
+// search recursively collects matching routes into matches
+func (t *RadixTree) search(n *node, segments []string, index int, params map[string]string, matches *[]*PathMatch) {
 	// Reached end of path
 	if index >= len(segments) {
-		return n.route
+		for _, route := range n.routes {
+			*matches = append(*matches, &PathMatch{Route: route, Params: copyParams(params)})
+		}
+		return
 	}
 
 	segment := segments[index]
@@ -181,32 +198,37 @@ func (t *RadixTree) search(n *node, segments []string, index int, params map[str
 		case static:
 			// Exact match required
 			if child.label == segment {
-				if route := t.search(child, segments, index+1, params); route != nil {
-					return route
-				}
+				t.search(child, segments, index+1, params, matches)
 			}
 
 		case param:
 			// Parameter matches any segment
 			params[child.paramName] = segment
-			if route := t.search(child, segments, index+1, params); route != nil {
-				return route
-			}
-			// Backtrack: remove param if this path didn't work
+			t.search(child, segments, index+1, params, matches)
+			// Backtrack: remove param before trying siblings
 			delete(params, child.paramName)
 
 		case wildcard:
 			// Wildcard matches remaining path
-			if child.route != nil {
+			if len(child.routes) > 0 {
 				// Capture remaining path
-				remaining := strings.Join(segments[index:], "/")
-				params["*"] = remaining
-				return child.route
+				params["*"] = strings.Join(segments[index:], "/")
+				for _, route := range child.routes {
+					*matches = append(*matches, &PathMatch{Route: route, Params: copyParams(params)})
+				}
+				delete(params, "*")
 			}
 		}
 	}
+}
 
-	return nil
+// copyParams snapshots the parameters captured so far for one match.
+func copyParams(params map[string]string) map[string]string {
+	out := make(map[string]string, len(params))
+	for k, v := range params {
+		out[k] = v
+	}
+	return out
 }
 
 // findChild looks for a child node matching the segment
@@ -0,0 +1,207 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// Fuzz targets for the radix tree. `go test` runs the seed corpus; run a
+// target for longer with e.g.
+//
+//	go test ./internal/router -run '^$' -fuzz FuzzRadixTree_Params -fuzztime 30s
+
+// fuzzSegments splits fuzz input into usable path segments: non-empty,
+// without '/', and not starting with ':' or equal to '*' (so they stay
+// static).
+func fuzzSegments(input string, max int) []string {
+	var segments []string
+	for _, s := range strings.Split(input, "/") {
+		if s == "" || s == "*" || strings.HasPrefix(s, ":") {
+			continue
+		}
+		segments = append(segments, s)
+		if len(segments) == max {
+			break
+		}
+	}
+	return segments
+}
+
+// FuzzRadixTree_NoPanic inserts arbitrary patterns (including empty
+// segments, params and wildcards anywhere) and searches arbitrary paths.
+func FuzzRadixTree_NoPanic(f *testing.F) {
+	f.Add("/api/users/:id", "/api/users/1")
+	f.Add("/api//users", "/api//users")
+	f.Add("//", "/")
+	f.Add("/:", "/x")
+	f.Add("/*/a", "/b/a")
+	f.Add("/a/*", "/a")
+	f.Add(":id", "")
+	f.Add("/a/:id/:id", "/a/1/2")
+
+	f.Fuzz(func(t *testing.T, pattern, path string) {
+		tree := NewRadixTree()
+		route := &database.Route{ID: "r"}
+
+		tree.Insert(pattern, route)
+		tree.Insert(pattern, route)
+		tree.Insert(path, route)
+
+		if got, _ := tree.Search(path); got != route {
+			t.Fatalf("Search(%q) after Insert(%q) = %v, want the route", path, path, got)
+		}
+		tree.Search(pattern)
+
+		if size := tree.Size(); size < 1 || size > 2 {
+			t.Fatalf("Size() = %d after inserting one route on 2 distinct-or-equal paths", size)
+		}
+	})
+}
+
+// FuzzRadixTree_StaticRoundTrip checks a static path is found after
+// insertion, a re-inserted route replaces itself, routes sharing a path
+// are all kept (most recent first), and siblings don't shadow each other.
+func FuzzRadixTree_StaticRoundTrip(f *testing.F) {
+	f.Add("api/users", "api/orders")
+	f.Add("a", "a/b")
+	f.Add("a/b/c", "a")
+	f.Add("x", "x")
+
+	f.Fuzz(func(t *testing.T, a, b string) {
+		segsA, segsB := fuzzSegments(a, 8), fuzzSegments(b, 8)
+		pathA := "/" + strings.Join(segsA, "/")
+		pathB := "/" + strings.Join(segsB, "/")
+
+		tree := NewRadixTree()
+		routeA := &database.Route{ID: "a"}
+		routeB := &database.Route{ID: "b"}
+		tree.Insert(pathA, &database.Route{ID: "a"})
+		tree.Insert(pathA, routeA)
+		tree.Insert(pathB, routeB)
+
+		wantA := []*database.Route{routeA}
+		if pathA == pathB {
+			wantA = []*database.Route{routeB, routeA}
+		}
+		matches := tree.SearchAll(pathA)
+		if len(matches) != len(wantA) {
+			t.Fatalf("SearchAll(%q) returned %d matches, want %d", pathA, len(matches), len(wantA))
+		}
+		for i, m := range matches {
+			if m.Route != wantA[i] || len(m.Params) != 0 {
+				t.Fatalf("SearchAll(%q)[%d] = %v %v, want %s and no params", pathA, i, m.Route, m.Params, wantA[i].ID)
+			}
+		}
+		if got, _ := tree.Search(pathB); got != routeB {
+			t.Fatalf("Search(%q) = %v, want b", pathB, got)
+		}
+
+		// Trailing slashes are ignored
+		if got, _ := tree.Search(pathB + "/"); got != routeB {
+			t.Fatalf("Search(%q) = %v, want b", pathB+"/", got)
+		}
+
+		if tree.Size() != 2 {
+			t.Fatalf("Size() = %d, want 2", tree.Size())
+		}
+
+		tree.Clear()
+		if got, _ := tree.Search(pathA); got != nil || tree.Size() != 0 {
+			t.Fatalf("tree not empty after Clear()")
+		}
+	})
+}
+
+// FuzzRadixTree_Params builds a pattern by turning some segments of a
+// path into params (selected by mask), and checks searching the path
+// returns every param with the segment it replaced.
+func FuzzRadixTree_Params(f *testing.F) {
+	f.Add("api/users/123", uint8(0b100))
+	f.Add("a/b/c/d", uint8(0b1111))
+	f.Add("orgs/acme/repos/gw", uint8(0b1010))
+	f.Add("x", uint8(0))
+
+	f.Fuzz(func(t *testing.T, path string, mask uint8) {
+		segments := fuzzSegments(path, 8)
+		if len(segments) == 0 {
+			return
+		}
+
+		pattern := make([]string, len(segments))
+		want := make(map[string]string)
+		for i, s := range segments {
+			if mask&(1<<i) != 0 {
+				name := fmt.Sprintf("p%d", i)
+				pattern[i] = ":" + name
+				want[name] = s
+			} else {
+				pattern[i] = s
+			}
+		}
+
+		tree := NewRadixTree()
+		route := &database.Route{ID: "r"}
+		tree.Insert("/"+strings.Join(pattern, "/"), route)
+
+		got, params := tree.Search("/" + strings.Join(segments, "/"))
+		if got != route {
+			t.Fatalf("Search did not match pattern %v", pattern)
+		}
+		if len(params) != len(want) {
+			t.Fatalf("params = %v, want %v", params, want)
+		}
+		for k, v := range want {
+			if params[k] != v {
+				t.Fatalf("param %s = %q, want %q", k, params[k], v)
+			}
+		}
+	})
+}
+
+// FuzzRadixTree_Priority checks static segments win over params, params
+// over wildcards, and that a failed static branch falls back to a param
+// (with no params leaking from the abandoned branch).
+func FuzzRadixTree_Priority(f *testing.F) {
+	f.Add("api/users/me")
+	f.Add("a/b")
+	f.Add("x/y/z/w")
+
+	f.Fuzz(func(t *testing.T, path string) {
+		segments := fuzzSegments(path, 6)
+		if len(segments) < 2 {
+			return
+		}
+		head, last := segments[:len(segments)-1], segments[len(segments)-1]
+		base := "/" + strings.Join(head, "/")
+
+		tree := NewRadixTree()
+		static := &database.Route{ID: "static"}
+		param := &database.Route{ID: "param"}
+		wild := &database.Route{ID: "wildcard"}
+
+		// Insert lowest priority first so insertion order doesn't decide
+		tree.Insert(base+"/*", wild)
+		tree.Insert(base+"/:last", param)
+		tree.Insert(base+"/"+last, static)
+
+		if got, params := tree.Search(base + "/" + last); got != static || len(params) != 0 {
+			t.Fatalf("Search(%q) = %v %v, want static route", base+"/"+last, got, params)
+		}
+
+		other := last + "x"
+		if got, params := tree.Search(base + "/" + other); got != param || params["last"] != other {
+			t.Fatalf("Search(%q) = %v %v, want param route with last=%q", base+"/"+other, got, params, other)
+		}
+
+		deeper := base + "/" + last + "/more"
+		if got, params := tree.Search(deeper); got != wild || params["*"] != last+"/more" {
+			t.Fatalf("Search(%q) = %v %v, want wildcard route capturing %q", deeper, got, params, last+"/more")
+		}
+		if _, leaked := tree.Search(deeper); leaked["last"] != "" {
+			t.Fatalf("param from abandoned branch leaked into %v", leaked)
+		}
+	})
+}
@@ -4,6 +4,7 @@
 //   - Request path (with support for parameters and wildcards)
 //   - HTTP method
 //   - Host header (optional)
+//   - Time window (optional, see Schedule)
 //
 // Routes are loaded from the database into memory at startup for
 // fast lookups (< 0.1ms per request).
@@ -11,10 +12,12 @@ package router
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -27,10 +30,27 @@ type Router struct {
 	routes       []*database.Route
 	services     map[string]*database.Service // service_id -> Service
 	matcher      *Matcher
+	schedules    map[string]*Schedule // route_id -> Schedule (scheduled routes only)
+	conflicts    []Conflict           // Overlapping path patterns (see FindConflicts)
+	orphaned     *Matcher             // Routes of disabled or missing services, for Explain only
+	orphans      []Orphan             // Routes left out of matcher (see FindOrphans)
 	mu           sync.RWMutex         // Protects routes, services, and matcher during reload
 	chainBuilder *plugin.ChainBuilder // Plugin chain builder
+	now          func() time.Time     // Clock for schedule evaluation (overridable in tests)
+	autoOptions  bool                 // Answer OPTIONS from route methods (see SetAutoOptions)
+	generation   string               // Content hash of the loaded config (see Generation)
+
+	// defaultService (ID or name) receives requests no route matches;
+	// empty = 404 (see SetDefaultService). defaultTarget is the service it
+	// resolved to when set or on the last Reload (nil = not found)
+	defaultService string
+	defaultTarget  *database.Service
 }
 
+// DefaultRouteID identifies the synthetic route of requests served by the
+// default service (see SetDefaultService).
+const DefaultRouteID = "default"
+
 // MatchResult contains the result of matching a request.
 type MatchResult struct {
 	Route      *database.Route
@@ -45,31 +65,29 @@ type MatchResult struct {
 // Uses a radix tree for O(log n) route lookups.
 // This should be called once at startup.
 func NewRouter(routes []*database.Route, services []*database.Service, pluginInstances []plugin.PluginInstance) *Router {
-	// Build service map for fast lookups
+	// Build service map for fast lookups. Disabled services are only
+	// needed to tell their orphaned routes from dangling references.
 	serviceMap := make(map[string]*database.Service)
 	for _, svc := range services {
-		serviceMap[svc.ID] = svc
-	}
-
-	// Create matcher with radix tree
-	matcher := NewMatcher()
-
-	// Insert all routes into radix tree
-	enabledCount := 0
-	for _, route := range routes {
-		if route.Enabled {
-			matcher.AddRoute(route)
-			enabledCount++
+		if svc.Enabled {
+			serviceMap[svc.ID] = svc
 		}
 	}
 
+	// Build the radix tree from the enabled routes of enabled services
+	live, matcher, orphaned, orphans := buildMatchers(routes, services)
+
 	// Create plugin chain builder
 	chainBuilder := plugin.NewChainBuilder(pluginInstances)
 
+	conflicts := FindConflicts(live)
+	logConflicts(conflicts)
+
 	log.Info().
 		Str("component", "router").
 		Int("routes", len(routes)).
-		Int("enabled_routes", enabledCount).
+		Int("enabled_routes", len(live)).
+		Int("orphaned_routes", len(orphans)).
 		Int("services", len(services)).
 		Int("tree_size", matcher.Size()).
 		Int("plugins", len(pluginInstances)).
@@ -79,7 +97,13 @@ func NewRouter(routes []*database.Route, services []*database.Service, pluginIns
 		routes:       routes,
 		services:     serviceMap,
 		matcher:      matcher,
+		schedules:    buildSchedules(routes),
+		conflicts:    conflicts,
+		orphaned:     orphaned,
+		orphans:      orphans,
 		chainBuilder: chainBuilder,
+		now:          time.Now,
+		generation:   configGeneration(routes, services, pluginInstances),
 	}
 }
 
@@ -111,6 +135,9 @@ func (r *Router) Match(req *http.Request) (*MatchResult, error) {
 	// Find matching routes by path
 	matches := r.matcher.Match(path)
 	if len(matches) == 0 {
+		if result := r.matchDefault(req); result != nil {
+			return result, nil
+		}
 		log.Debug().
 			Str("component", "router").
 			Str("path", path).
@@ -118,37 +145,33 @@ func (r *Router) Match(req *http.Request) (*MatchResult, error) {
 		return nil, fmt.Errorf("no route found for path: %s", path)
 	}
 
-	// Filter by method and host
+	// Filter by method, host, schedule and service state. Every candidate
+	// is considered, so a route registering the same path for other methods
+	// or hosts doesn't hide this one.
+	var allowed []string
 	for _, match := range matches {
 		route := match.Route
 
-		// Check if method is allowed
-		if !r.methodAllowed(route, method) {
-			continue
-		}
-
-		// Check if host matches (if route specifies hosts)
-		if !r.hostMatches(route, host) {
-			continue
-		}
-
-		// Get the service for this route
-		service, ok := r.services[route.ServiceID]
-		if !ok {
-			log.Warn().
-				Str("component", "router").
-				Str("route_id", route.ID).
-				Str("service_id", route.ServiceID).
-				Msg("Service not found for route")
-			continue
+		service, reason := r.checkRoute(route, method, host)
+		if reason == RejectMethod {
+			if _, other := r.checkRouteIgnoringMethod(route, host); other == "" {
+				allowed = appendMethods(allowed, route.Methods)
+			}
 		}
-
-		// Check if service is enabled
-		if !service.Enabled {
-			log.Debug().
-				Str("component", "router").
-				Str("service_id", service.ID).
-				Msg("Service is disabled")
+		if reason != "" {
+			if reason == RejectServiceMissing {
+				log.Warn().
+					Str("component", "router").
+					Str("route_id", route.ID).
+					Str("service_id", route.ServiceID).
+					Msg("Service not found for route")
+			} else {
+				log.Debug().
+					Str("component", "router").
+					Str("route_id", route.ID).
+					Str("reason", reason).
+					Msg("Route rejected")
+			}
 			continue
 		}
 
@@ -172,6 +195,27 @@ func (r *Router) Match(req *http.Request) (*MatchResult, error) {
 		}, nil
 	}
 
+	if len(allowed) > 0 {
+		notAllowed := &MethodNotAllowedError{Method: method, Path: path, Allowed: allowed}
+		if method == http.MethodOptions && r.autoOptions {
+			notAllowed.Allowed = appendMethods(notAllowed.Allowed, []string{http.MethodOptions})
+			notAllowed.AutoOptions = true
+		}
+
+		log.Debug().
+			Str("component", "router").
+			Str("path", path).
+			Str("method", method).
+			Strs("allowed", notAllowed.Allowed).
+			Bool("auto_options", notAllowed.AutoOptions).
+			Msg("Path matched but method not allowed")
+		return nil, notAllowed
+	}
+
+	if result := r.matchDefault(req); result != nil {
+		return result, nil
+	}
+
 	log.Debug().
 		Str("component", "router").
 		Str("path", path).
@@ -181,6 +225,183 @@ func (r *Router) Match(req *http.Request) (*MatchResult, error) {
 	return nil, fmt.Errorf("no route found for %s %s", method, path)
 }
 
+// SetDefaultService sets the service (by ID, or by name as "name" in the
+// default workspace or "workspace/name") that receives
+// requests matching no route, e.g. a legacy monolith behind the gateway
+// during a migration. Empty restores 404s.
+//
+// Such requests get a synthetic route (ID DefaultRouteID, every method and
+// host, path passed through unchanged) so global and service plugins still
+// run. Paths that routes serve for other methods still get 405.
+func (r *Router) SetDefaultService(idOrName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.defaultService = idOrName
+	r.defaultTarget = r.resolveDefaultService()
+}
+
+// resolveDefaultService looks up the default service among the loaded
+// ones. It runs when the default is set and on every Reload, so a missing
+// or disabled default service is reported once per change rather than on
+// every unmatched request. Caller must hold r.mu.
+func (r *Router) resolveDefaultService() *database.Service {
+	if r.defaultService == "" {
+		return nil
+	}
+
+	service, ok := r.services[r.defaultService]
+	if !ok {
+		workspace, name, found := strings.Cut(r.defaultService, "/")
+		if !found {
+			workspace, name = database.DefaultWorkspace, r.defaultService
+		}
+		for _, svc := range r.services {
+			if svc.Name == name && database.WorkspaceName(svc.Workspace) == workspace {
+				service, ok = svc, true
+				break
+			}
+		}
+	}
+	if !ok || !service.Enabled {
+		log.Warn().
+			Str("component", "router").
+			Str("default_service", r.defaultService).
+			Msg("Default service not found or disabled - unmatched requests get 404")
+		return nil
+	}
+	return service
+}
+
+// matchDefault routes an unmatched request to the default service, as
+// resolved by resolveDefaultService. Returns nil if no default service is
+// set, or it is missing or disabled. Caller must hold r.mu.
+func (r *Router) matchDefault(req *http.Request) *MatchResult {
+	service := r.defaultTarget
+	if service == nil {
+		return nil
+	}
+
+	route := &database.Route{
+		ID:        DefaultRouteID,
+		Workspace: service.Workspace,
+		ServiceID: service.ID,
+		Name:      sql.NullString{String: DefaultRouteID, Valid: true},
+		Enabled:   true,
+	}
+
+	log.Debug().
+		Str("component", "router").
+		Str("path", req.URL.Path).
+		Str("method", req.Method).
+		Str("service_id", service.ID).
+		Msg("No route matched - using default service")
+
+	return &MatchResult{
+		Route:      route,
+		Service:    service,
+		PathParams: map[string]string{},
+		Chain:      r.chainBuilder.BuildForRoute(route, service),
+	}
+}
+
+// MethodNotAllowedError is returned by Match when routes serve the path
+// (and host) but none accepts the request method.
+//
+// Handlers answer 405 with an Allow header listing Allowed, or 204 with
+// the same header if AutoOptions is set.
+type MethodNotAllowedError struct {
+	Method  string
+	Path    string
+	Allowed []string // Methods accepted by the matching routes, in route order
+
+	// AutoOptions is set for OPTIONS requests when auto OPTIONS responses
+	// are enabled (Allowed then includes OPTIONS)
+	AutoOptions bool
+}
+
+func (e *MethodNotAllowedError) Error() string {
+	return fmt.Sprintf("method %s not allowed for path: %s (allowed: %s)",
+		e.Method, e.Path, strings.Join(e.Allowed, ", "))
+}
+
+// AllowHeader returns the value for the Allow response header.
+func (e *MethodNotAllowedError) AllowHeader() string {
+	return strings.Join(e.Allowed, ", ")
+}
+
+// appendMethods adds methods not already in list.
+func appendMethods(list, methods []string) []string {
+	for _, m := range methods {
+		found := false
+		for _, existing := range list {
+			if existing == m {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+// SetAutoOptions makes the router answer OPTIONS requests for paths whose
+// routes don't accept OPTIONS themselves (see MethodNotAllowedError).
+//
+// Routes that accept OPTIONS, such as those relying on the cors plugin for
+// preflights, are matched as usual.
+func (r *Router) SetAutoOptions(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.autoOptions = enabled
+}
+
+// Reasons a path-matching route was not selected (see checkRoute).
+const (
+	RejectMethod          = "method_not_allowed"
+	RejectHost            = "host_mismatch"
+	RejectSchedule        = "outside_schedule"
+	RejectServiceMissing  = "service_not_found"
+	RejectServiceDisabled = "service_disabled"
+	RejectShadowed        = "shadowed" // an earlier route already matched (Explain only)
+)
+
+// checkRoute applies the non-path match criteria to a route.
+//
+// Returns the route's service, or a Reject* reason if the route can't serve
+// the request. Caller must hold r.mu.
+func (r *Router) checkRoute(route *database.Route, method, host string) (*database.Service, string) {
+	if !r.methodAllowed(route, method) {
+		return nil, RejectMethod
+	}
+	return r.checkRouteIgnoringMethod(route, host)
+}
+
+// checkRouteIgnoringMethod applies every criterion of checkRoute except
+// the method, to tell whether a method-rejected route would have served
+// the request with another method. Caller must hold r.mu.
+func (r *Router) checkRouteIgnoringMethod(route *database.Route, host string) (*database.Service, string) {
+	if !r.hostMatches(route, host) {
+		return nil, RejectHost
+	}
+	if !r.scheduleActive(route) {
+		return nil, RejectSchedule
+	}
+
+	service, ok := r.services[route.ServiceID]
+	if !ok {
+		return nil, RejectServiceMissing
+	}
+	if !service.Enabled {
+		return nil, RejectServiceDisabled
+	}
+
+	return service, ""
+}
+
 // methodAllowed checks if the HTTP method is allowed for the route.
 func (r *Router) methodAllowed(route *database.Route, method string) bool {
 	// If no methods specified, allow all
@@ -198,6 +419,39 @@ func (r *Router) methodAllowed(route *database.Route, method string) bool {
 	return false
 }
 
+// scheduleActive checks if the route is inside its scheduled time window.
+func (r *Router) scheduleActive(route *database.Route) bool {
+	schedule, ok := r.schedules[route.ID]
+	if !ok {
+		return true
+	}
+	return schedule.Active(r.now())
+}
+
+// buildSchedules compiles the schedules of all scheduled routes.
+//
+// Routes with an invalid schedule are treated as never active, so a typo
+// can't open a restricted route around the clock.
+func buildSchedules(routes []*database.Route) map[string]*Schedule {
+	schedules := make(map[string]*Schedule)
+	for _, route := range routes {
+		schedule, err := NewSchedule(route)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("component", "router").
+				Str("route_id", route.ID).
+				Msg("Invalid route schedule - route disabled")
+			schedules[route.ID] = neverActive
+			continue
+		}
+		if schedule != nil {
+			schedules[route.ID] = schedule
+		}
+	}
+	return schedules
+}
+
 // hostMatches checks if the request host matches the route's host requirements.
 func (r *Router) hostMatches(route *database.Route, requestHost string) bool {
 	// If no hosts specified, match any host
@@ -243,7 +497,12 @@ func (r *Router) hostMatchesPattern(host, pattern string) bool {
 // This is called when routes or plugins are updated via the Admin API.
 // Rebuilds the radix tree and plugin chains.
 // It's safe to call concurrently - uses write lock for atomic swap.
-func (r *Router) Reload(ctx context.Context, repo *database.Repository, pluginInstances []plugin.PluginInstance) error {
+//
+// The loaded set is validated first (see ValidateConfig), together with
+// any pluginErrors from building pluginInstances. On failure nothing is
+// swapped, the previous snapshot keeps serving, and a *ValidationError is
+// returned.
+func (r *Router) Reload(ctx context.Context, repo database.ConfigStore, pluginInstances []plugin.PluginInstance, pluginErrors []error) error {
 	log.Info().
 		Str("component", "router").
 		Msg("Reloading routes and plugins from database")
@@ -254,66 +513,135 @@ func (r *Router) Reload(ctx context.Context, repo *database.Repository, pluginIn
 		return fmt.Errorf("failed to load routes: %w", err)
 	}
 
-	// Load services
-	services, err := repo.GetServices(ctx, false)
+	// Load services (disabled ones too, so validation can tell a disabled
+	// service from a dangling reference)
+	allServices, err := repo.GetServices(ctx, true)
 	if err != nil {
 		return fmt.Errorf("failed to load services: %w", err)
 	}
 
+	// Validate the whole set before touching the live snapshot
+	if err := ValidateConfig(routes, allServices, pluginInstances, pluginErrors); err != nil {
+		return err
+	}
+
 	// Build new service map
 	serviceMap := make(map[string]*database.Service)
-	for _, svc := range services {
-		serviceMap[svc.ID] = svc
+	for _, svc := range allServices {
+		if svc.Enabled {
+			serviceMap[svc.ID] = svc
+		}
 	}
 
-	// Create new matcher with radix tree
-	matcher := NewMatcher()
-
-	// Build radix tree from routes
-	enabledCount := 0
+	// Build the radix tree from the enabled routes of enabled services;
+	// routes orphaned by a disabled service are reported, not served
+	live, matcher, orphaned, orphans := buildMatchers(routes, allServices)
 	totalPaths := 0
-	for _, route := range routes {
-		if route.Enabled {
-			matcher.AddRoute(route)
-			enabledCount++
-			totalPaths += len(route.Paths)
-		}
+	for _, route := range live {
+		totalPaths += len(route.Paths)
 	}
 
 	// Create new plugin chain builder
 	chainBuilder := plugin.NewChainBuilder(pluginInstances)
 
+	// Overlapping patterns don't fail the reload, but are reported
+	conflicts := FindConflicts(live)
+	logConflicts(conflicts)
+
 	// Atomic swap (write lock in router)
 	r.mu.Lock()
 	r.routes = routes
 	r.services = serviceMap
 	r.matcher = matcher
+	r.schedules = buildSchedules(routes)
+	r.conflicts = conflicts
+	r.orphaned = orphaned
+	r.orphans = orphans
 	r.chainBuilder = chainBuilder
+	r.generation = configGeneration(routes, allServices, pluginInstances)
+	r.defaultTarget = r.resolveDefaultService()
 	r.mu.Unlock()
 
 	log.Info().
 		Str("component", "router").
 		Int("routes", len(routes)).
-		Int("enabled_routes", enabledCount).
+		Int("enabled_routes", len(live)).
+		Int("orphaned_routes", len(orphans)).
 		Int("total_paths", totalPaths).
-		Int("services", len(services)).
+		Int("services", len(serviceMap)).
 		Int("tree_size", matcher.Size()).
 		Int("plugins", len(pluginInstances)).
+		Int("conflicts", len(conflicts)).
 		Msg("Routes and plugins reloaded successfully - radix tree rebuilt")
 
 	return nil
 }
 
+// Generation returns a content hash of the loaded routes, services and
+// plugin configs. Gateways serving the same configuration report the same
+// generation.
+func (r *Router) Generation() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.generation
+}
+
+// Routes returns a snapshot of the loaded routes.
+func (r *Router) Routes() []*database.Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	routes := make([]*database.Route, len(r.routes))
+	copy(routes, r.routes)
+	return routes
+}
+
+// Service returns a loaded service by ID.
+func (r *Router) Service(id string) (*database.Service, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	service, ok := r.services[id]
+	return service, ok
+}
+
+// ChainForRoute returns a loaded route, its service, and the plugin chain
+// that would run for it.
+//
+// Returns false if the route is not loaded or its service is disabled or
+// missing: such routes are orphans (see Orphans) and never served.
+func (r *Router) ChainForRoute(id string) (*database.Route, *database.Service, *plugin.Chain, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, route := range r.routes {
+		if route.ID != id {
+			continue
+		}
+		service, ok := r.services[route.ServiceID]
+		if !ok {
+			return nil, nil, nil, false
+		}
+		return route, service, r.chainBuilder.BuildForRoute(route, service), true
+	}
+
+	return nil, nil, nil, false
+}
+
 // Stats returns router statistics including radix tree metrics.
 func (r *Router) Stats() map[string]interface{} {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	return map[string]interface{}{
-		"routes":        len(r.routes),
-		"services":      len(r.services),
-		"tree_size":     r.matcher.Size(),
-		"lookup_method": "radix_tree",
-		"complexity":    "O(log n)",
+		"routes":           len(r.routes),
+		"scheduled_routes": len(r.schedules),
+		"route_conflicts":  len(r.conflicts),
+		"orphaned_routes":  len(r.orphans),
+		"services":         len(r.services),
+		"tree_size":        r.matcher.Size(),
+		"lookup_method":    "radix_tree",
+		"complexity":       "O(log n)",
 	}
 }
@@ -1,6 +1,8 @@
 package router
 
 import (
+	"context"
+	"errors"
 	"net/http/httptest"
 	"testing"
 
@@ -87,3 +89,236 @@ func TestRouter_MatchRequest(t *testing.T) {
 		})
 	}
 }
+
+// TestRouter_MatchAllCandidates checks routes sharing a path are all
+// considered, so method or host filtering on one doesn't hide another.
+func TestRouter_MatchAllCandidates(t *testing.T) {
+	services := []*database.Service{{ID: "svc", Enabled: true}}
+	routes := []*database.Route{
+		{ID: "list", ServiceID: "svc", Paths: []string{"/users"}, Methods: []string{"GET"}, Enabled: true},
+		{ID: "create", ServiceID: "svc", Paths: []string{"/users"}, Methods: []string{"POST"}, Enabled: true},
+		{ID: "by-id", ServiceID: "svc", Paths: []string{"/users/:id"}, Methods: []string{"GET", "PUT"}, Enabled: true},
+		{ID: "me", ServiceID: "svc", Paths: []string{"/users/me"}, Methods: []string{"GET"}, Enabled: true},
+		{ID: "api", ServiceID: "svc", Paths: []string{"/orders"}, Hosts: []string{"api.example.com"}, Enabled: true},
+		{ID: "admin", ServiceID: "svc", Paths: []string{"/orders"}, Hosts: []string{"admin.example.com"}, Methods: []string{"GET"}, Enabled: true},
+	}
+	r := NewRouter(routes, services, nil)
+
+	tests := []struct {
+		method, host, path string
+		wantRoute          string
+		wantAllow          string // MethodNotAllowedError expected if set
+	}{
+		{method: "GET", path: "/users", wantRoute: "list"},
+		{method: "POST", path: "/users", wantRoute: "create"},
+		{method: "DELETE", path: "/users", wantAllow: "POST, GET"}, // latest route on a path is tried first
+		{method: "GET", path: "/users/me", wantRoute: "me"},
+		{method: "PUT", path: "/users/me", wantRoute: "by-id"}, // static route rejects PUT, param route accepts it
+		{method: "DELETE", path: "/users/me", wantAllow: "GET, PUT"},
+		{method: "POST", host: "api.example.com", path: "/orders", wantRoute: "api"},
+		{method: "GET", host: "admin.example.com", path: "/orders", wantRoute: "admin"},
+		{method: "POST", host: "admin.example.com", path: "/orders", wantAllow: "GET"},
+		{method: "GET", host: "other.example.com", path: "/orders"}, // host mismatch is a 404, not a 405
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.host+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			result, err := r.Match(req)
+
+			var notAllowed *MethodNotAllowedError
+			isNotAllowed := errors.As(err, &notAllowed)
+			switch {
+			case tt.wantRoute != "":
+				if err != nil || result.Route.ID != tt.wantRoute {
+					t.Fatalf("Match() = %v, %v, want route %s", result, err, tt.wantRoute)
+				}
+			case tt.wantAllow != "":
+				if !isNotAllowed || notAllowed.AllowHeader() != tt.wantAllow {
+					t.Fatalf("Match() error = %v, want method not allowed (Allow: %s)", err, tt.wantAllow)
+				}
+			default:
+				if err == nil || isNotAllowed {
+					t.Fatalf("Match() error = %v, want not found", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRouter_AutoOptions(t *testing.T) {
+	services := []*database.Service{{ID: "svc", Enabled: true}}
+	routes := []*database.Route{
+		{ID: "users", ServiceID: "svc", Paths: []string{"/users"}, Methods: []string{"GET", "POST"}, Enabled: true},
+		{ID: "cors", ServiceID: "svc", Paths: []string{"/public"}, Methods: []string{"GET", "OPTIONS"}, Enabled: true},
+	}
+	r := NewRouter(routes, services, nil)
+
+	var notAllowed *MethodNotAllowedError
+	if _, err := r.Match(httptest.NewRequest("OPTIONS", "/users", nil)); !errors.As(err, &notAllowed) || notAllowed.AutoOptions {
+		t.Fatalf("OPTIONS with auto options off: error = %v, want plain method not allowed", err)
+	}
+
+	r.SetAutoOptions(true)
+
+	_, err := r.Match(httptest.NewRequest("OPTIONS", "/users", nil))
+	if !errors.As(err, &notAllowed) || !notAllowed.AutoOptions || notAllowed.AllowHeader() != "GET, POST, OPTIONS" {
+		t.Fatalf("OPTIONS /users: error = %v, want auto options with Allow: GET, POST, OPTIONS", err)
+	}
+
+	// Only OPTIONS is answered automatically
+	if _, err := r.Match(httptest.NewRequest("DELETE", "/users", nil)); !errors.As(err, &notAllowed) || notAllowed.AutoOptions {
+		t.Fatalf("DELETE /users: error = %v, want plain method not allowed", err)
+	}
+
+	// Routes accepting OPTIONS handle it themselves
+	if result, err := r.Match(httptest.NewRequest("OPTIONS", "/public", nil)); err != nil || result.Route.ID != "cors" {
+		t.Fatalf("OPTIONS /public: Match() = %v, %v, want route cors", result, err)
+	}
+}
+
+func TestRouter_DefaultService(t *testing.T) {
+	services := []*database.Service{
+		{ID: "svc", Name: "users", Enabled: true},
+		{ID: "legacy-id", Name: "legacy", Enabled: true},
+		{ID: "team-legacy-id", Workspace: "team", Name: "legacy", Enabled: true},
+	}
+	routes := []*database.Route{
+		{ID: "users", ServiceID: "svc", Paths: []string{"/users"}, Methods: []string{"GET"}, Enabled: true},
+		{ID: "admin", ServiceID: "svc", Paths: []string{"/admin"}, Hosts: []string{"admin.example.com"}, Enabled: true},
+	}
+	r := NewRouter(routes, services, nil)
+
+	if _, err := r.Match(httptest.NewRequest("GET", "/orders", nil)); err == nil {
+		t.Fatal("unmatched request without default service: expected error")
+	}
+
+	for _, idOrName := range []string{"legacy-id", "legacy"} {
+		r.SetDefaultService(idOrName)
+
+		for _, path := range []string{"/orders", "/admin"} { // no path match, host mismatch
+			result, err := r.Match(httptest.NewRequest("GET", path, nil))
+			if err != nil {
+				t.Fatalf("default %s, %s: Match() error = %v", idOrName, path, err)
+			}
+			if result.Route.ID != DefaultRouteID || result.Service.ID != "legacy-id" || result.Chain == nil {
+				t.Errorf("default %s, %s: got route %s service %s", idOrName, path, result.Route.ID, result.Service.ID)
+			}
+		}
+
+		// Matched routes still win, and method mismatches stay 405
+		if result, err := r.Match(httptest.NewRequest("GET", "/users", nil)); err != nil || result.Route.ID != "users" {
+			t.Errorf("GET /users: Match() = %v, %v, want route users", result, err)
+		}
+		var notAllowed *MethodNotAllowedError
+		if _, err := r.Match(httptest.NewRequest("POST", "/users", nil)); !errors.As(err, &notAllowed) {
+			t.Errorf("POST /users: error = %v, want method not allowed", err)
+		}
+	}
+
+	// Names outside the default workspace are qualified
+	r.SetDefaultService("team/legacy")
+	result, err := r.Match(httptest.NewRequest("GET", "/orders", nil))
+	if err != nil || result.Service.ID != "team-legacy-id" || result.Route.Workspace != "team" {
+		t.Errorf("default team/legacy: Match() = %v, %v, want service team-legacy-id", result, err)
+	}
+
+	r.SetDefaultService("missing")
+	if _, err := r.Match(httptest.NewRequest("GET", "/orders", nil)); err == nil {
+		t.Error("missing default service: expected error")
+	}
+}
+
+func TestRouter_DefaultServiceReload(t *testing.T) {
+	store := database.NewMemoryStore()
+	store.SetServices([]*database.Service{{ID: "legacy-id", Name: "legacy", Protocol: "http", Host: "localhost", Port: 8081, Enabled: true}})
+
+	r := NewRouter(nil, nil, nil)
+	r.SetDefaultService("legacy")
+	if _, err := r.Match(httptest.NewRequest("GET", "/orders", nil)); err == nil {
+		t.Fatal("default service not loaded yet: expected error")
+	}
+
+	// Resolved again once the service is loaded
+	if err := r.Reload(context.Background(), store, nil, nil); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if result, err := r.Match(httptest.NewRequest("GET", "/orders", nil)); err != nil || result.Service.ID != "legacy-id" {
+		t.Fatalf("after reload: Match() = %v, %v, want service legacy-id", result, err)
+	}
+
+	// ... and dropped once it is disabled
+	store.SetServices([]*database.Service{{ID: "legacy-id", Name: "legacy", Protocol: "http", Host: "localhost", Port: 8081, Enabled: false}})
+	if err := r.Reload(context.Background(), store, nil, nil); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if _, err := r.Match(httptest.NewRequest("GET", "/orders", nil)); err == nil {
+		t.Error("disabled default service: expected error")
+	}
+}
+
+func TestRouter_ReloadFromStore(t *testing.T) {
+	store := database.NewMemoryStore()
+	store.SetServices([]*database.Service{{
+		ID:       "svc",
+		Name:     "svc",
+		Protocol: "http",
+		Host:     "localhost",
+		Port:     8081,
+		Enabled:  true,
+	}})
+	store.SetRoutes([]*database.Route{{
+		ID:        "old",
+		ServiceID: "svc",
+		Paths:     []string{"/old"},
+		Enabled:   true,
+	}})
+
+	r := NewRouter(nil, nil, nil)
+	if err := r.Reload(context.Background(), store, nil, nil); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if _, err := r.Match(httptest.NewRequest("GET", "/old", nil)); err != nil {
+		t.Fatalf("expected /old to match after reload: %v", err)
+	}
+
+	store.SetRoutes([]*database.Route{
+		{ID: "new", ServiceID: "svc", Paths: []string{"/new"}, Enabled: true},
+		{ID: "off", ServiceID: "svc", Paths: []string{"/off"}, Enabled: false},
+	})
+	if err := r.Reload(context.Background(), store, nil, nil); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	if _, err := r.Match(httptest.NewRequest("GET", "/old", nil)); err == nil {
+		t.Error("removed route should no longer match")
+	}
+	if _, err := r.Match(httptest.NewRequest("GET", "/new", nil)); err != nil {
+		t.Errorf("expected /new to match after reload: %v", err)
+	}
+	if _, err := r.Match(httptest.NewRequest("GET", "/off", nil)); err == nil {
+		t.Error("disabled route should not be loaded")
+	}
+}
+
+func TestRouter_Generation(t *testing.T) {
+	svc := &database.Service{ID: "svc", Name: "svc", Host: "localhost", Port: 8081, Enabled: true}
+	a := &database.Route{ID: "a", ServiceID: "svc", Paths: []string{"/a"}, Enabled: true}
+	b := &database.Route{ID: "b", ServiceID: "svc", Paths: []string{"/b"}, Enabled: true}
+
+	r1 := NewRouter([]*database.Route{a, b}, []*database.Service{svc}, nil)
+	r2 := NewRouter([]*database.Route{b, a}, []*database.Service{svc}, nil)
+	if r1.Generation() == "" || r1.Generation() != r2.Generation() {
+		t.Errorf("generations %q and %q, want equal for the same config in another order", r1.Generation(), r2.Generation())
+	}
+
+	changed := *b
+	changed.Paths = []string{"/b2"}
+	r3 := NewRouter([]*database.Route{a, &changed}, []*database.Service{svc}, nil)
+	if r3.Generation() == r1.Generation() {
+		t.Error("generation should change when a route changes")
+	}
+}
@@ -0,0 +1,241 @@
+// Package router - Time-based route scheduling
+//
+// A route can be restricted to a time window:
+//   - schedule_start / schedule_end: Absolute timestamps (either may be unset)
+//   - schedule_cron: A 5-field cron expression describing the minutes in
+//     which the window is open (e.g., "* 9-17 * * 1-5" = business hours)
+//   - schedule_mode: "active" (route only serves inside the cron window) or
+//     "inactive" (route is disabled inside the window, e.g. maintenance)
+//   - schedule_timezone: IANA timezone the cron expression is evaluated in
+//
+// Cron fields: minute hour day-of-month month day-of-week.
+// Supported syntax per field: "*", "5", "1-5", "*/15", "1-30/5", "1,15,30".
+// As in standard cron, when both day-of-month and day-of-week are
+// restricted, a day matches if either field matches.
+package router
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// Schedule modes (matches routes.schedule_mode).
+const (
+	ScheduleModeActive   = "active"
+	ScheduleModeInactive = "inactive"
+)
+
+// Schedule is a compiled route time window.
+type Schedule struct {
+	start    time.Time // zero = no start bound
+	end      time.Time // zero = no end bound
+	cron     *CronExpr // nil = no recurring window
+	inactive bool      // cron window disables instead of enables
+	location *time.Location
+}
+
+// neverActive is used for routes whose schedule failed to compile.
+var neverActive = &Schedule{
+	start: time.Unix(1, 0),
+	end:   time.Unix(2, 0),
+}
+
+// NewSchedule compiles a route's schedule.
+//
+// Returns (nil, nil) if the route has no schedule configured.
+func NewSchedule(route *database.Route) (*Schedule, error) {
+	hasCron := route.ScheduleCron.Valid && strings.TrimSpace(route.ScheduleCron.String) != ""
+	if !route.ScheduleStart.Valid && !route.ScheduleEnd.Valid && !hasCron {
+		return nil, nil
+	}
+
+	s := &Schedule{location: time.UTC}
+
+	if route.ScheduleTimezone != "" {
+		loc, err := time.LoadLocation(route.ScheduleTimezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule timezone %q: %w", route.ScheduleTimezone, err)
+		}
+		s.location = loc
+	}
+
+	if route.ScheduleStart.Valid {
+		s.start = route.ScheduleStart.Time
+	}
+	if route.ScheduleEnd.Valid {
+		s.end = route.ScheduleEnd.Time
+	}
+	if !s.start.IsZero() && !s.end.IsZero() && !s.end.After(s.start) {
+		return nil, fmt.Errorf("schedule end must be after schedule start")
+	}
+
+	if hasCron {
+		cron, err := ParseCron(route.ScheduleCron.String)
+		if err != nil {
+			return nil, err
+		}
+		s.cron = cron
+	}
+
+	switch route.ScheduleMode {
+	case "", ScheduleModeActive:
+	case ScheduleModeInactive:
+		s.inactive = true
+	default:
+		return nil, fmt.Errorf("invalid schedule mode %q (must be active or inactive)", route.ScheduleMode)
+	}
+
+	return s, nil
+}
+
+// Active reports whether the route should serve traffic at time t.
+func (s *Schedule) Active(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+
+	if !s.start.IsZero() && t.Before(s.start) {
+		return false
+	}
+	if !s.end.IsZero() && !t.Before(s.end) {
+		return false
+	}
+
+	if s.cron != nil {
+		inWindow := s.cron.Matches(t.In(s.location))
+		if s.inactive {
+			return !inWindow
+		}
+		return inWindow
+	}
+
+	return true
+}
+
+// ============================================================================
+// Cron expressions
+// ============================================================================
+
+// CronExpr is a parsed 5-field cron expression.
+type CronExpr struct {
+	minute, hour, dom, month, dow uint64 // bit sets
+	domStar, dowStar              bool   // field was "*" (unrestricted)
+}
+
+// cronField describes the valid range of a cron field.
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day-of-month", 1, 31},
+	{"month", 1, 12},
+	{"day-of-week", 0, 7}, // 0 and 7 are both Sunday
+}
+
+// ParseCron parses a 5-field cron expression.
+func ParseCron(expr string) (*CronExpr, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	sets := make([]uint64, 5)
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		sets[i] = set
+	}
+
+	// Fold Sunday=7 into Sunday=0
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return &CronExpr{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField parses one comma-separated cron field into a bit set.
+func parseCronField(field string, spec cronField) (uint64, error) {
+	var set uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if idx := strings.Index(part, "/"); idx != -1 {
+			rangePart = part[:idx]
+			n, err := strconv.Atoi(part[idx+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s: invalid step in %q", spec.name, part)
+			}
+			step = n
+		}
+
+		lo, hi := spec.min, spec.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("%s: invalid range %q", spec.name, part)
+			}
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, fmt.Errorf("%s: invalid range %q", spec.name, part)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("%s: invalid value %q", spec.name, part)
+			}
+			lo, hi = n, n
+			if step > 1 {
+				hi = spec.max // "5/15" means 5-max/15
+			}
+		}
+
+		if lo < spec.min || hi > spec.max || lo > hi {
+			return 0, fmt.Errorf("%s: %q out of range %d-%d", spec.name, part, spec.min, spec.max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+
+	return set, nil
+}
+
+// Matches reports whether t falls within a minute matched by the expression.
+func (c *CronExpr) Matches(t time.Time) bool {
+	if c.minute&(1<<uint(t.Minute())) == 0 ||
+		c.hour&(1<<uint(t.Hour())) == 0 ||
+		c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+
+	// Standard cron: if both day fields are restricted, either may match
+	if !c.domStar && !c.dowStar {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
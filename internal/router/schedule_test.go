@@ -0,0 +1,221 @@
+package router
+
+import (
+	"database/sql"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: "* * * * *"},
+		{expr: "*/15 9-17 * * 1-5"},
+		{expr: "0,30 0 1 1,6,12 0"},
+		{expr: "5/10 * * * 7"},
+		{expr: "* * * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "* 24 * * *", wantErr: true},
+		{expr: "* * 0 * *", wantErr: true},
+		{expr: "* * * * mon", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+		{expr: "5-1 * * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseCron(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCron(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronExpr_Matches(t *testing.T) {
+	// Business hours, Monday-Friday
+	cron, err := ParseCron("* 9-17 * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		time time.Time
+		want bool
+	}{
+		{"monday 9am", time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC), true},
+		{"friday 5:59pm", time.Date(2024, 1, 19, 17, 59, 0, 0, time.UTC), true},
+		{"monday 8:59am", time.Date(2024, 1, 15, 8, 59, 0, 0, time.UTC), false},
+		{"monday 6pm", time.Date(2024, 1, 15, 18, 0, 0, 0, time.UTC), false},
+		{"saturday noon", time.Date(2024, 1, 20, 12, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cron.Matches(tt.time); got != tt.want {
+				t.Errorf("Matches(%v) = %v, want %v", tt.time, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronExpr_DayOfMonthOrWeek(t *testing.T) {
+	// 1st of the month OR any Sunday
+	cron, _ := ParseCron("* * 1 * 0")
+
+	if !cron.Matches(time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)) { // Thursday the 1st
+		t.Error("expected 1st of month to match")
+	}
+	if !cron.Matches(time.Date(2024, 2, 4, 12, 0, 0, 0, time.UTC)) { // Sunday the 4th
+		t.Error("expected Sunday to match")
+	}
+	if cron.Matches(time.Date(2024, 2, 5, 12, 0, 0, 0, time.UTC)) { // Monday the 5th
+		t.Error("expected Monday the 5th not to match")
+	}
+}
+
+func TestSchedule_Active(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		route *database.Route
+		at    time.Time
+		want  bool
+	}{
+		{
+			name:  "before start",
+			route: &database.Route{ScheduleStart: sql.NullTime{Time: start, Valid: true}},
+			at:    start.Add(-time.Minute),
+			want:  false,
+		},
+		{
+			name:  "after start",
+			route: &database.Route{ScheduleStart: sql.NullTime{Time: start, Valid: true}},
+			at:    start.Add(time.Minute),
+			want:  true,
+		},
+		{
+			name:  "at end",
+			route: &database.Route{ScheduleEnd: sql.NullTime{Time: end, Valid: true}},
+			at:    end,
+			want:  false,
+		},
+		{
+			name: "cron in timezone",
+			route: &database.Route{
+				ScheduleCron:     sql.NullString{String: "* 9-17 * * *", Valid: true},
+				ScheduleTimezone: "America/New_York",
+			},
+			at:   time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC), // 10am in New York
+			want: true,
+		},
+		{
+			name: "cron outside window in timezone",
+			route: &database.Route{
+				ScheduleCron:     sql.NullString{String: "* 9-17 * * *", Valid: true},
+				ScheduleTimezone: "America/New_York",
+			},
+			at:   time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC), // 5am in New York
+			want: false,
+		},
+		{
+			name: "maintenance window",
+			route: &database.Route{
+				ScheduleCron: sql.NullString{String: "* 2-3 * * 0", Valid: true},
+				ScheduleMode: ScheduleModeInactive,
+			},
+			at:   time.Date(2024, 1, 14, 2, 30, 0, 0, time.UTC), // Sunday 2:30am
+			want: false,
+		},
+		{
+			name: "outside maintenance window",
+			route: &database.Route{
+				ScheduleCron: sql.NullString{String: "* 2-3 * * 0", Valid: true},
+				ScheduleMode: ScheduleModeInactive,
+			},
+			at:   time.Date(2024, 1, 14, 4, 0, 0, 0, time.UTC),
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := NewSchedule(tt.route)
+			if err != nil {
+				t.Fatalf("NewSchedule() error = %v", err)
+			}
+			if got := schedule.Active(tt.at); got != tt.want {
+				t.Errorf("Active() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSchedule_Invalid(t *testing.T) {
+	routes := []*database.Route{
+		{ScheduleCron: sql.NullString{String: "bad", Valid: true}},
+		{ScheduleCron: sql.NullString{String: "* * * * *", Valid: true}, ScheduleTimezone: "Mars/Olympus"},
+		{ScheduleCron: sql.NullString{String: "* * * * *", Valid: true}, ScheduleMode: "sometimes"},
+		{
+			ScheduleStart: sql.NullTime{Time: time.Unix(100, 0), Valid: true},
+			ScheduleEnd:   sql.NullTime{Time: time.Unix(50, 0), Valid: true},
+		},
+	}
+
+	for i, route := range routes {
+		if _, err := NewSchedule(route); err == nil {
+			t.Errorf("route %d: expected error", i)
+		}
+	}
+}
+
+func TestRouter_ScheduledRoute(t *testing.T) {
+	service := &database.Service{ID: "svc", Name: "svc", Enabled: true}
+	route := &database.Route{
+		ID:           "beta",
+		ServiceID:    service.ID,
+		Paths:        []string{"/beta"},
+		Methods:      []string{"GET"},
+		Enabled:      true,
+		ScheduleCron: sql.NullString{String: "* 9-17 * * 1-5", Valid: true},
+	}
+
+	r := NewRouter([]*database.Route{route}, []*database.Service{service}, []plugin.PluginInstance{})
+
+	r.now = func() time.Time { return time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC) }
+	if _, err := r.Match(httptest.NewRequest("GET", "/beta", nil)); err != nil {
+		t.Errorf("expected match during business hours, got %v", err)
+	}
+
+	r.now = func() time.Time { return time.Date(2024, 1, 15, 22, 0, 0, 0, time.UTC) }
+	if _, err := r.Match(httptest.NewRequest("GET", "/beta", nil)); err == nil {
+		t.Error("expected no match outside business hours")
+	}
+}
+
+func TestRouter_InvalidScheduleDisablesRoute(t *testing.T) {
+	service := &database.Service{ID: "svc", Name: "svc", Enabled: true}
+	route := &database.Route{
+		ID:           "broken",
+		ServiceID:    service.ID,
+		Paths:        []string{"/broken"},
+		Methods:      []string{"GET"},
+		Enabled:      true,
+		ScheduleCron: sql.NullString{String: "not a cron", Valid: true},
+	}
+
+	r := NewRouter([]*database.Route{route}, []*database.Service{service}, []plugin.PluginInstance{})
+
+	if _, err := r.Match(httptest.NewRequest("GET", "/broken", nil)); err == nil {
+		t.Error("expected route with invalid schedule not to match")
+	}
+}
@@ -0,0 +1,228 @@
+// Package router - Config validation before hot reload
+//
+// Reload loads a complete config set (routes, services, plugins) and runs
+// ValidateConfig over it before the atomic swap. If anything is wrong the
+// whole set is rejected and the router keeps serving the previous
+// snapshot, instead of silently dropping the bad row or half-applying it.
+//
+// Every problem names its entity ("service orders (id): invalid port 0"),
+// so one reload reports everything that needs fixing.
+package router
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/loadbalancer"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+	"github.com/saidutt46/switchboard-gateway/internal/slo"
+)
+
+// ValidationError lists every problem found in a config set.
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration (%d problems): %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// ValidateConfig checks a loaded config set for problems that would break
+// or silently change routing: enabled services with an invalid protocol,
+// port or host, enabled routes pointing at missing services or with
+// malformed path patterns, and plugins that failed to build.
+//
+// services must include disabled services, so routes pointing at a
+// disabled service are not mistaken for dangling references. pluginErrors
+// are build failures from the plugin registry (unknown plugin names,
+// invalid plugin configs), reported alongside the rest.
+//
+// Returns a *ValidationError, or nil if the set is valid.
+func ValidateConfig(routes []*database.Route, services []*database.Service, plugins []plugin.PluginInstance, pluginErrors []error) error {
+	var problems []string
+
+	serviceIDs := make(map[string]bool, len(services))
+	for _, svc := range services {
+		serviceIDs[svc.ID] = true
+		if svc.Enabled {
+			problems = append(problems, validateService(svc)...)
+		}
+	}
+
+	for _, route := range routes {
+		if !route.Enabled {
+			continue
+		}
+		problems = append(problems, validateRoute(route, serviceIDs)...)
+	}
+
+	for _, instance := range plugins {
+		if instance.Config == nil {
+			continue
+		}
+		if instance.Scope == database.PluginScopeService && instance.Config.ServiceID.Valid && !serviceIDs[instance.Config.ServiceID.String] {
+			problems = append(problems, fmt.Sprintf("plugin %s (%s): references missing service %s",
+				instance.Config.Name, instance.Config.ID, instance.Config.ServiceID.String))
+		}
+	}
+
+	for _, err := range pluginErrors {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateRoute returns the problems with a single enabled route.
+func validateRoute(route *database.Route, serviceIDs map[string]bool) []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("route %s: ", routeLabel(route))+fmt.Sprintf(format, args...))
+	}
+
+	if !serviceIDs[route.ServiceID] {
+		add("references missing service %s", route.ServiceID)
+	}
+
+	if len(route.Paths) == 0 {
+		add("has no paths")
+	}
+	for _, path := range route.Paths {
+		if err := validatePathPattern(path); err != nil {
+			add("invalid path %q: %v", path, err)
+		}
+	}
+
+	if _, err := NewSchedule(route); err != nil {
+		add("invalid schedule: %v", err)
+	}
+
+	if err := slo.Validate(route.SLO); err != nil {
+		add("invalid slo: %v", err)
+	}
+
+	if route.TimeoutMs < 0 {
+		add("invalid timeout_ms %d (must be 0 or more)", route.TimeoutMs)
+	}
+
+	return problems
+}
+
+// validServiceProtocols are the protocols the proxy speaks to upstreams.
+var validServiceProtocols = map[string]bool{"http": true, "https": true, "grpc": true}
+
+// validateService returns the problems with a single enabled service.
+func validateService(svc *database.Service) []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("service %s: ", serviceLabel(svc))+fmt.Sprintf(format, args...))
+	}
+
+	if !validServiceProtocols[svc.Protocol] {
+		add("invalid protocol %q (must be http, https or grpc)", svc.Protocol)
+	}
+	if svc.Port < 1 || svc.Port > 65535 {
+		add("invalid port %d (must be 1-65535)", svc.Port)
+	}
+	if err := validateHost(svc.Host); err != nil {
+		add("invalid host %q: %v", svc.Host, err)
+	}
+	if svc.Path.Valid && svc.Path.String != "" && !strings.HasPrefix(svc.Path.String, "/") {
+		add("invalid path %q: must start with /", svc.Path.String)
+	}
+	if err := loadbalancer.ValidateHealthCheck(svc); err != nil {
+		add("invalid health_check: %v", err)
+	}
+
+	return problems
+}
+
+// validateHost checks a service host: a DNS name or an IP address, without
+// scheme, port or path.
+func validateHost(host string) error {
+	switch {
+	case host == "":
+		return fmt.Errorf("empty")
+	case strings.Contains(host, "://"):
+		return fmt.Errorf("must not include a scheme")
+	case strings.ContainsAny(host, "/?#"):
+		return fmt.Errorf("must not include a path")
+	}
+
+	if net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")) != nil {
+		return nil
+	}
+	if strings.Contains(host, ":") {
+		return fmt.Errorf("must not include a port (set the service port)")
+	}
+	if len(host) > 253 {
+		return fmt.Errorf("longer than 253 characters")
+	}
+
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("invalid label %q", label)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return fmt.Errorf("invalid character %q", c)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validatePathPattern checks a route path against the matcher's syntax:
+// static segments, ":name" parameters, and a trailing "*" wildcard.
+func validatePathPattern(path string) error {
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("must start with /")
+	}
+
+	params := make(map[string]bool)
+	segments := splitPath(path)
+	for i, segment := range segments {
+		switch {
+		case segment == "" && i < len(segments)-1:
+			return fmt.Errorf("empty segment")
+		case segment == "*" && i != len(segments)-1:
+			return fmt.Errorf("wildcard must be the last segment")
+		case strings.HasPrefix(segment, ":") && len(segment) == 1:
+			return fmt.Errorf("parameter without a name")
+		case strings.Contains(strings.TrimPrefix(segment, ":"), ":"):
+			return fmt.Errorf("':' is only allowed at the start of a segment")
+		case segment != "*" && strings.Contains(segment, "*"):
+			return fmt.Errorf("wildcard must be a whole segment")
+		case strings.HasPrefix(segment, ":"):
+			if params[segment] {
+				return fmt.Errorf("duplicate parameter %q", segment)
+			}
+			params[segment] = true
+		}
+	}
+
+	return nil
+}
+
+// serviceLabel names a service in validation messages.
+func serviceLabel(svc *database.Service) string {
+	if svc.Name != "" {
+		return fmt.Sprintf("%s (%s)", svc.Name, svc.ID)
+	}
+	return svc.ID
+}
+
+// routeLabel names a route in validation messages.
+func routeLabel(route *database.Route) string {
+	if route.Name.Valid && route.Name.String != "" {
+		return fmt.Sprintf("%s (%s)", route.Name.String, route.ID)
+	}
+	return route.ID
+}
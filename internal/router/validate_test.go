@@ -0,0 +1,146 @@
+package router
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/plugin"
+)
+
+func TestValidateConfig(t *testing.T) {
+	services := []*database.Service{
+		{ID: "svc", Name: "svc", Protocol: "http", Host: "svc.internal", Port: 8080, Enabled: true},
+		{ID: "off", Name: "off", Enabled: false}, // disabled services aren't validated
+	}
+
+	route := func(id, serviceID string, paths ...string) *database.Route {
+		return &database.Route{ID: id, ServiceID: serviceID, Paths: paths, Enabled: true}
+	}
+
+	tests := []struct {
+		name         string
+		routes       []*database.Route
+		plugins      []plugin.PluginInstance
+		pluginErrors []error
+		wantProblems int
+	}{
+		{
+			name:   "valid set",
+			routes: []*database.Route{route("a", "svc", "/api/:id", "/files/*"), route("b", "off", "/legacy")},
+		},
+		{
+			name:         "missing service",
+			routes:       []*database.Route{route("a", "gone", "/api")},
+			wantProblems: 1,
+		},
+		{
+			name:         "bad paths",
+			routes:       []*database.Route{route("a", "svc", "api", "/a/*/b", "/x/:"), route("b", "svc")},
+			wantProblems: 4,
+		},
+		{
+			name:         "colon mid-segment and duplicate parameters",
+			routes:       []*database.Route{route("a", "svc", "/users/a:id", "/x/:id:y", "/a/:id/b/:id")},
+			wantProblems: 3,
+		},
+		{
+			name: "invalid schedule",
+			routes: []*database.Route{{
+				ID: "a", ServiceID: "svc", Paths: []string{"/api"}, Enabled: true,
+				ScheduleCron: sql.NullString{String: "not a cron", Valid: true},
+			}},
+			wantProblems: 1,
+		},
+		{
+			name:         "negative timeout",
+			routes:       []*database.Route{{ID: "a", ServiceID: "svc", Paths: []string{"/api"}, TimeoutMs: -1, Enabled: true}},
+			wantProblems: 1,
+		},
+		{
+			name:   "disabled routes are ignored",
+			routes: []*database.Route{{ID: "a", ServiceID: "gone", Enabled: false}},
+		},
+		{
+			name:   "plugin on missing service and plugin build error",
+			routes: []*database.Route{route("a", "svc", "/api")},
+			plugins: []plugin.PluginInstance{{
+				Scope: database.PluginScopeService,
+				Config: &database.Plugin{
+					ID: "p1", Name: "cors", Scope: database.PluginScopeService,
+					ServiceID: sql.NullString{String: "gone", Valid: true},
+				},
+			}},
+			pluginErrors: []error{fmt.Errorf("plugin rate-limit (p2): invalid config")},
+			wantProblems: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(tt.routes, services, tt.plugins, tt.pluginErrors)
+			checkProblems(t, err, tt.wantProblems)
+		})
+	}
+}
+
+func TestValidateConfig_Services(t *testing.T) {
+	service := func(protocol, host string, port int) *database.Service {
+		return &database.Service{ID: "svc", Name: "orders", Protocol: protocol, Host: host, Port: port, Enabled: true}
+	}
+
+	tests := []struct {
+		name         string
+		service      *database.Service
+		wantProblems int
+	}{
+		{name: "http", service: service("http", "orders.internal", 80)},
+		{name: "grpc on an IPv4 address", service: service("grpc", "10.0.0.12", 50051)},
+		{name: "IPv6 address", service: service("https", "[::1]", 8443)},
+		{name: "underscore in host", service: service("http", "orders_api", 8080)},
+		{name: "unknown protocol", service: service("ftp", "orders.internal", 21), wantProblems: 1},
+		{name: "port out of range", service: service("http", "orders.internal", 70000), wantProblems: 1},
+		{name: "no port", service: service("http", "orders.internal", 0), wantProblems: 1},
+		{name: "host with scheme", service: service("http", "http://orders.internal", 80), wantProblems: 1},
+		{name: "host with port", service: service("http", "orders.internal:8080", 8080), wantProblems: 1},
+		{name: "host with path", service: service("http", "orders.internal/api", 80), wantProblems: 1},
+		{name: "empty host", service: service("http", "", 80), wantProblems: 1},
+		{name: "everything wrong", service: service("", "bad host", -1), wantProblems: 3},
+		{
+			name: "relative service path",
+			service: &database.Service{ID: "svc", Protocol: "http", Host: "orders", Port: 80, Enabled: true,
+				Path: sql.NullString{String: "api/v1", Valid: true}},
+			wantProblems: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := []*database.Route{{ID: "r", ServiceID: "svc", Paths: []string{"/orders"}, Enabled: true}}
+			err := ValidateConfig(routes, []*database.Service{tt.service}, nil, nil)
+			checkProblems(t, err, tt.wantProblems)
+		})
+	}
+}
+
+// checkProblems fails unless err is a *ValidationError with want problems
+// (or nil when want is 0).
+func checkProblems(t *testing.T, err error, want int) {
+	t.Helper()
+	if want == 0 {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+	if len(validationErr.Problems) != want {
+		t.Errorf("got %d problems, want %d: %v", len(validationErr.Problems), want, validationErr.Problems)
+	}
+}
@@ -0,0 +1,85 @@
+package signedurl
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSigner_SignAndVerify(t *testing.T) {
+	s, err := NewSigner([]string{"secret-1"}, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	query, err := s.Sign("/files/report.pdf", now.Add(time.Hour), "consumer-1")
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	consumer, err := s.Verify("/files/report.pdf", query, now)
+	if err != nil || consumer != "consumer-1" {
+		t.Fatalf("Verify() = %q, %v", consumer, err)
+	}
+
+	// Unbound links
+	query, _ = s.Sign("/files/a", now.Add(time.Minute), "")
+	if consumer, err := s.Verify("/files/a", query, now); err != nil || consumer != "" {
+		t.Errorf("Verify() unbound = %q, %v", consumer, err)
+	}
+}
+
+func TestSigner_Rejects(t *testing.T) {
+	s, _ := NewSigner([]string{"secret-1"}, time.Hour)
+	now := time.Now()
+	query, _ := s.Sign("/files/a", now.Add(time.Minute), "consumer-1")
+
+	tests := []struct {
+		name   string
+		path   string
+		mutate func()
+		now    time.Time
+		want   error
+	}{
+		{"other path", "/files/b", func() {}, now, ErrInvalid},
+		{"expired", "/files/a", func() {}, now.Add(2 * time.Minute), ErrExpired},
+		{"other consumer", "/files/a", func() { query.Set(ParamConsumer, "consumer-2") }, now, ErrInvalid},
+		{"unbound", "/files/a", func() { query.Del(ParamConsumer) }, now, ErrInvalid},
+		{"extended", "/files/a", func() { query.Set(ParamExpires, "99999999999") }, now, ErrInvalid},
+		{"missing", "/files/a", func() { query.Del(ParamSignature) }, now, ErrMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ = s.Sign("/files/a", now.Add(time.Minute), "consumer-1")
+			tt.mutate()
+			if _, err := s.Verify(tt.path, query, tt.now); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSigner_MaxTTLAndRotation(t *testing.T) {
+	now := time.Now()
+	old, _ := NewSigner([]string{"old"}, 48*time.Hour)
+	query, _ := old.Sign("/files/a", now.Add(36*time.Hour), "")
+
+	// Rotated: the new secret signs, the old one still verifies, but the
+	// lifetime is capped at the verifier's max TTL
+	s, _ := NewSigner([]string{"new", "old"}, 24*time.Hour)
+	if _, err := s.Verify("/files/a", query, now); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected a link beyond MaxTTL to be rejected, got %v", err)
+	}
+	query, _ = old.Sign("/files/a", now.Add(time.Hour), "")
+	if _, err := s.Verify("/files/a", query, now); err != nil {
+		t.Errorf("old secret no longer verifies: %v", err)
+	}
+
+	if _, err := NewSigner(nil, time.Hour); err == nil {
+		t.Error("expected NewSigner without secrets to fail")
+	}
+	var nilSigner *Signer
+	if _, err := nilSigner.Verify("/", query, now); !errors.Is(err, ErrMissing) {
+		t.Errorf("nil signer Verify() error = %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package slo
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Handler serves the SLO report as JSON (mounted at /status).
+func (t *Tracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+
+		if err := json.NewEncoder(w).Encode(t.Report()); err != nil {
+			log.Error().
+				Err(err).
+				Str("component", "slo").
+				Msg("Failed to encode SLO report")
+		}
+	})
+}
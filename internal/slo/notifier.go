@@ -0,0 +1,71 @@
+package slo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Event types sent to notifiers.
+const (
+	EventBudgetExhausted = "slo.budget_exhausted"
+	EventBudgetRecovered = "slo.budget_recovered"
+)
+
+// Event is an SLO budget state change.
+type Event struct {
+	Type      string    `json:"type"`
+	RouteID   string    `json:"route_id"`
+	RouteName string    `json:"route_name,omitempty"`
+	Objective string    `json:"objective"`
+	BurnRate  float64   `json:"burn_rate"`
+	Window    string    `json:"window"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Notifier delivers SLO events (e.g. to an alerting webhook).
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// WebhookNotifier POSTs events as JSON to a URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookNotifier creates a webhook notifier with a 5s timeout.
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		Client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Notify sends one event. Non-2xx responses are errors.
+func (n *WebhookNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,561 @@
+// Package slo tracks per-route service level objectives and their error
+// budget burn rate.
+//
+// Routes declare objectives in routes.slo (see database.RouteSLO):
+//   - latency: Percentile of requests complete within latency_ms
+//   - errors: at most error_budget of responses are 5xx
+//   - request_size / response_size: Percentile of bodies are within the
+//     byte threshold
+//
+// Every request through a route with objectives is counted into per-minute
+// buckets covering the route's window. The burn rate of an objective is
+// the observed bad fraction divided by its budget: 1.0 spends the budget
+// exactly over the window, 10 spends it ten times as fast.
+//
+// Status per objective:
+//   - ok: budget not exhausted and not burning fast
+//   - burning: the last 5 minutes burn faster than FastBurnRate
+//   - exhausted: the whole window's burn rate is >= 1
+//   - no_data: no requests in the window
+//
+// Tracking is in-memory per gateway instance; with several replicas each
+// reports the traffic it served. The report is served at /status and
+// budget exhaustion/recovery is sent to an optional webhook.
+package slo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// Defaults and limits for route objectives.
+const (
+	DefaultWindow     = time.Hour
+	MaxWindow         = 7 * 24 * time.Hour
+	DefaultPercentile = 0.99
+
+	// FastBurnRate marks an objective as burning when the short window
+	// spends budget this many times faster than sustainable.
+	FastBurnRate = 10.0
+
+	bucketWidth = time.Minute
+	shortWindow = 5 * time.Minute
+)
+
+// Objective names.
+const (
+	ObjectiveLatency      = "latency"
+	ObjectiveErrors       = "errors"
+	ObjectiveRequestSize  = "request_size"
+	ObjectiveResponseSize = "response_size"
+)
+
+// Statuses.
+const (
+	StatusOK        = "ok"
+	StatusBurning   = "burning"
+	StatusExhausted = "exhausted"
+	StatusNoData    = "no_data"
+	StatusDegraded  = "degraded" // overall report only
+)
+
+// objectiveOrder is the index of each objective in bucket counters.
+var objectiveOrder = []string{ObjectiveLatency, ObjectiveErrors, ObjectiveRequestSize, ObjectiveResponseSize}
+
+// latencyBounds are the histogram bucket upper bounds in milliseconds.
+var latencyBounds = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+var (
+	burnRateGauge = metrics.NewGaugeVec(
+		"gateway_slo_burn_rate",
+		"Error budget burn rate over the route's SLO window (1 = budget spent exactly at window end)",
+		"route", "objective",
+	)
+	budgetExhaustedGauge = metrics.NewGaugeVec(
+		"gateway_slo_budget_exhausted",
+		"1 if the route objective's error budget is exhausted",
+		"route", "objective",
+	)
+)
+
+// Validate checks a route's objectives.
+func Validate(cfg database.RouteSLO) error {
+	if cfg.LatencyMs < 0 || cfg.RequestSizeBytes < 0 || cfg.ResponseSizeBytes < 0 {
+		return fmt.Errorf("thresholds cannot be negative")
+	}
+	if cfg.ErrorBudget < 0 || cfg.ErrorBudget >= 1 {
+		return fmt.Errorf("error_budget must be between 0 and 1")
+	}
+	if cfg.Percentile < 0 || cfg.Percentile >= 1 {
+		return fmt.Errorf("percentile must be between 0 and 1")
+	}
+	if _, err := window(cfg); err != nil {
+		return err
+	}
+	return nil
+}
+
+// window returns the evaluation window of a route's objectives.
+func window(cfg database.RouteSLO) (time.Duration, error) {
+	if cfg.Window == "" {
+		return DefaultWindow, nil
+	}
+	w, err := time.ParseDuration(cfg.Window)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window %q: %w", cfg.Window, err)
+	}
+	if w < shortWindow || w > MaxWindow {
+		return 0, fmt.Errorf("window must be between %s and %s", shortWindow, MaxWindow)
+	}
+	return w, nil
+}
+
+// percentile returns the objective percentile for latency and size.
+func percentile(cfg database.RouteSLO) float64 {
+	if cfg.Percentile > 0 {
+		return cfg.Percentile
+	}
+	return DefaultPercentile
+}
+
+// budgets returns the error budget per objective index (0 = not declared).
+func budgets(cfg database.RouteSLO) [4]float64 {
+	var b [4]float64
+	p := percentile(cfg)
+	if cfg.LatencyMs > 0 {
+		b[0] = 1 - p
+	}
+	if cfg.ErrorBudget > 0 {
+		b[1] = cfg.ErrorBudget
+	}
+	if cfg.RequestSizeBytes > 0 {
+		b[2] = 1 - p
+	}
+	if cfg.ResponseSizeBytes > 0 {
+		b[3] = 1 - p
+	}
+	return b
+}
+
+// ============================================================================
+// Tracker
+// ============================================================================
+
+// bucket counts one minute of requests.
+type bucket struct {
+	minute  int64
+	total   uint64
+	bad     [4]uint64
+	latency [12]uint64 // len(latencyBounds)+1
+}
+
+// routeState holds a route's rolling buckets.
+type routeState struct {
+	mu        sync.Mutex
+	route     *database.Route
+	window    time.Duration
+	buckets   []bucket
+	lastSeen  time.Time
+	exhausted map[string]bool // objectives reported exhausted (for notifications)
+}
+
+// Tracker records requests against route objectives.
+type Tracker struct {
+	mu        sync.RWMutex
+	routes    map[string]*routeState // route_id -> state
+	notifiers []Notifier
+	now       func() time.Time
+}
+
+// NewTracker creates a tracker that sends budget events to notifiers
+// (nil entries are ignored).
+func NewTracker(notifiers ...Notifier) *Tracker {
+	t := &Tracker{
+		routes: make(map[string]*routeState),
+		now:    time.Now,
+	}
+	for _, n := range notifiers {
+		if n != nil {
+			t.notifiers = append(t.notifiers, n)
+		}
+	}
+	return t
+}
+
+// Record counts a completed request for route.
+//
+// Requests on routes without objectives (or with invalid ones) are
+// ignored. requestBytes may be negative when unknown.
+func (t *Tracker) Record(route *database.Route, latency time.Duration, statusCode int, requestBytes, responseBytes int64) {
+	if route == nil || !route.SLO.Configured() {
+		return
+	}
+	w, err := window(route.SLO)
+	if err != nil {
+		return
+	}
+
+	state := t.state(route.ID)
+	now := t.now()
+	minute := now.Unix() / int64(bucketWidth/time.Second)
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	// Window changed (or first request): start over
+	if state.window != w {
+		state.window = w
+		state.buckets = make([]bucket, int(w/bucketWidth))
+	}
+	state.route = route
+	state.lastSeen = now
+
+	b := &state.buckets[minute%int64(len(state.buckets))]
+	if b.minute != minute {
+		*b = bucket{minute: minute}
+	}
+
+	cfg := route.SLO
+	ms := float64(latency) / float64(time.Millisecond)
+
+	b.total++
+	if cfg.LatencyMs > 0 && ms > float64(cfg.LatencyMs) {
+		b.bad[0]++
+	}
+	if cfg.ErrorBudget > 0 && statusCode >= 500 {
+		b.bad[1]++
+	}
+	if cfg.RequestSizeBytes > 0 && requestBytes > cfg.RequestSizeBytes {
+		b.bad[2]++
+	}
+	if cfg.ResponseSizeBytes > 0 && responseBytes > cfg.ResponseSizeBytes {
+		b.bad[3]++
+	}
+
+	i := sort.SearchFloat64s(latencyBounds, ms)
+	b.latency[i]++
+}
+
+// state returns (creating if needed) the state for a route.
+func (t *Tracker) state(routeID string) *routeState {
+	t.mu.RLock()
+	state, ok := t.routes[routeID]
+	t.mu.RUnlock()
+	if ok {
+		return state
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state, ok = t.routes[routeID]; !ok {
+		state = &routeState{exhausted: make(map[string]bool)}
+		t.routes[routeID] = state
+	}
+	return state
+}
+
+// ============================================================================
+// Reporting
+// ============================================================================
+
+// Report is the SLO status of all tracked routes.
+type Report struct {
+	Status      string        `json:"status"` // ok or degraded
+	GeneratedAt time.Time     `json:"generated_at"`
+	Routes      []RouteStatus `json:"routes"`
+}
+
+// RouteStatus is the SLO status of one route.
+type RouteStatus struct {
+	RouteID    string            `json:"route_id"`
+	RouteName  string            `json:"route_name,omitempty"`
+	Window     string            `json:"window"`
+	Requests   uint64            `json:"requests"`
+	Status     string            `json:"status"`
+	LatencyP99 float64           `json:"observed_latency_p99_ms"` // histogram bucket upper bound
+	Objectives []ObjectiveStatus `json:"objectives"`
+}
+
+// ObjectiveStatus is the budget state of one objective.
+type ObjectiveStatus struct {
+	Name            string  `json:"name"`
+	Budget          float64 `json:"budget"`
+	BadRequests     uint64  `json:"bad_requests"`
+	BurnRate        float64 `json:"burn_rate"`
+	ShortBurnRate   float64 `json:"short_burn_rate"` // last 5 minutes
+	BudgetRemaining float64 `json:"budget_remaining"`
+	Status          string  `json:"status"`
+}
+
+// Report computes the current status of every tracked route.
+func (t *Tracker) Report() Report {
+	now := t.now()
+
+	t.mu.RLock()
+	states := make([]*routeState, 0, len(t.routes))
+	for _, state := range t.routes {
+		states = append(states, state)
+	}
+	t.mu.RUnlock()
+
+	report := Report{Status: StatusOK, GeneratedAt: now.UTC(), Routes: []RouteStatus{}}
+	for _, state := range states {
+		state.mu.Lock()
+		status, ok := state.summarize(now)
+		state.mu.Unlock()
+		if !ok {
+			continue
+		}
+		if status.Status == StatusBurning || status.Status == StatusExhausted {
+			report.Status = StatusDegraded
+		}
+		report.Routes = append(report.Routes, status)
+	}
+
+	sort.Slice(report.Routes, func(i, j int) bool {
+		return report.Routes[i].RouteID < report.Routes[j].RouteID
+	})
+
+	return report
+}
+
+// summarize computes a route's status. Caller must hold s.mu.
+// Returns false if the route has no data yet.
+func (s *routeState) summarize(now time.Time) (RouteStatus, bool) {
+	if s.route == nil {
+		return RouteStatus{}, false
+	}
+
+	minute := now.Unix() / int64(bucketWidth/time.Second)
+	windowMinutes := int64(len(s.buckets))
+	shortMinutes := int64(shortWindow / bucketWidth)
+
+	var total, shortTotal uint64
+	var bad, shortBad [4]uint64
+	var latency [12]uint64
+
+	for i := range s.buckets {
+		b := &s.buckets[i]
+		age := minute - b.minute
+		if b.total == 0 || age < 0 || age >= windowMinutes {
+			continue
+		}
+		total += b.total
+		for j := range bad {
+			bad[j] += b.bad[j]
+		}
+		for j := range latency {
+			latency[j] += b.latency[j]
+		}
+		if age < shortMinutes {
+			shortTotal += b.total
+			for j := range shortBad {
+				shortBad[j] += b.bad[j]
+			}
+		}
+	}
+
+	status := RouteStatus{
+		RouteID:    s.route.ID,
+		RouteName:  s.route.Name.String,
+		Window:     s.window.String(),
+		Requests:   total,
+		Status:     StatusOK,
+		LatencyP99: latencyPercentile(latency, total, 0.99),
+	}
+	if total == 0 {
+		status.Status = StatusNoData
+	}
+
+	for i, budget := range budgets(s.route.SLO) {
+		if budget == 0 {
+			continue
+		}
+
+		obj := ObjectiveStatus{
+			Name:            objectiveOrder[i],
+			Budget:          budget,
+			BadRequests:     bad[i],
+			BurnRate:        burnRate(bad[i], total, budget),
+			ShortBurnRate:   burnRate(shortBad[i], shortTotal, budget),
+			BudgetRemaining: 1,
+			Status:          StatusOK,
+		}
+		obj.BudgetRemaining = 1 - obj.BurnRate
+		if obj.BudgetRemaining < 0 {
+			obj.BudgetRemaining = 0
+		}
+
+		switch {
+		case total == 0:
+			obj.Status = StatusNoData
+		case obj.BurnRate >= 1:
+			obj.Status = StatusExhausted
+		case obj.ShortBurnRate >= FastBurnRate:
+			obj.Status = StatusBurning
+		}
+
+		status.Status = worse(status.Status, obj.Status)
+		status.Objectives = append(status.Objectives, obj)
+	}
+
+	return status, true
+}
+
+// burnRate is the bad fraction relative to the budget.
+func burnRate(bad, total uint64, budget float64) float64 {
+	if total == 0 || budget == 0 {
+		return 0
+	}
+	return (float64(bad) / float64(total)) / budget
+}
+
+// latencyPercentile returns the upper bound of the histogram bucket
+// containing the p-th percentile.
+func latencyPercentile(hist [12]uint64, total uint64, p float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	target := uint64(float64(total)*p + 0.5)
+	var cumulative uint64
+	for i, count := range hist {
+		cumulative += count
+		if cumulative >= target && i < len(latencyBounds) {
+			return latencyBounds[i]
+		}
+	}
+	return latencyBounds[len(latencyBounds)-1]
+}
+
+// statusRank orders statuses by severity.
+var statusRank = map[string]int{StatusNoData: 0, StatusOK: 1, StatusBurning: 2, StatusExhausted: 3}
+
+// worse returns the more severe of two statuses.
+func worse(a, b string) string {
+	if statusRank[b] > statusRank[a] {
+		return b
+	}
+	return a
+}
+
+// ============================================================================
+// Evaluation loop
+// ============================================================================
+
+// Run evaluates objectives every interval until ctx is cancelled:
+// updates burn rate metrics, sends exhaustion/recovery notifications,
+// and forgets routes with no traffic for a full window.
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.evaluate(ctx)
+		}
+	}
+}
+
+// evaluate runs one evaluation pass.
+func (t *Tracker) evaluate(ctx context.Context) {
+	now := t.now()
+
+	t.mu.Lock()
+	for id, state := range t.routes {
+		state.mu.Lock()
+		stale := now.Sub(state.lastSeen) > state.window
+		state.mu.Unlock()
+		if stale {
+			delete(t.routes, id)
+			for _, name := range objectiveOrder {
+				burnRateGauge.Delete(id, name)
+				budgetExhaustedGauge.Delete(id, name)
+			}
+		}
+	}
+	states := make([]*routeState, 0, len(t.routes))
+	for _, state := range t.routes {
+		states = append(states, state)
+	}
+	t.mu.Unlock()
+
+	var events []Event
+	for _, state := range states {
+		state.mu.Lock()
+		status, ok := state.summarize(now)
+		if ok {
+			events = append(events, state.transitions(status, now)...)
+		}
+		state.mu.Unlock()
+	}
+
+	for _, event := range events {
+		log.Warn().
+			Str("component", "slo").
+			Str("event", event.Type).
+			Str("route_id", event.RouteID).
+			Str("objective", event.Objective).
+			Float64("burn_rate", event.BurnRate).
+			Msg("SLO budget state changed")
+
+		for _, notifier := range t.notifiers {
+			if err := notifier.Notify(ctx, event); err != nil {
+				log.Error().
+					Err(err).
+					Str("component", "slo").
+					Str("event", event.Type).
+					Str("route_id", event.RouteID).
+					Msg("Failed to send SLO notification")
+			}
+		}
+	}
+}
+
+// transitions updates metrics and returns exhaustion/recovery events.
+// Caller must hold s.mu.
+func (s *routeState) transitions(status RouteStatus, now time.Time) []Event {
+	var events []Event
+
+	for _, obj := range status.Objectives {
+		burnRateGauge.Set(obj.BurnRate, status.RouteID, obj.Name)
+
+		exhausted := obj.Status == StatusExhausted
+		if exhausted {
+			budgetExhaustedGauge.Set(1, status.RouteID, obj.Name)
+		} else {
+			budgetExhaustedGauge.Set(0, status.RouteID, obj.Name)
+		}
+
+		if exhausted == s.exhausted[obj.Name] {
+			continue
+		}
+		s.exhausted[obj.Name] = exhausted
+
+		eventType := EventBudgetRecovered
+		if exhausted {
+			eventType = EventBudgetExhausted
+		}
+		events = append(events, Event{
+			Type:      eventType,
+			RouteID:   status.RouteID,
+			RouteName: status.RouteName,
+			Objective: obj.Name,
+			BurnRate:  obj.BurnRate,
+			Window:    status.Window,
+			Timestamp: now.UTC(),
+		})
+	}
+
+	return events
+}
@@ -0,0 +1,183 @@
+package slo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+type recordingNotifier struct {
+	events []Event
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, event Event) error {
+	n.events = append(n.events, event)
+	return nil
+}
+
+func newTestTracker(notifier Notifier) (*Tracker, *time.Time) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker(notifier)
+	tracker.now = func() time.Time { return now }
+	return tracker, &now
+}
+
+func testRoute(cfg database.RouteSLO) *database.Route {
+	return &database.Route{
+		ID:   "route-1",
+		Name: sql.NullString{String: "orders", Valid: true},
+		SLO:  cfg,
+	}
+}
+
+func findObjective(t *testing.T, status RouteStatus, name string) ObjectiveStatus {
+	t.Helper()
+	for _, obj := range status.Objectives {
+		if obj.Name == name {
+			return obj
+		}
+	}
+	t.Fatalf("objective %s not reported", name)
+	return ObjectiveStatus{}
+}
+
+func TestTracker_IgnoresRoutesWithoutObjectives(t *testing.T) {
+	tracker, _ := newTestTracker(nil)
+	tracker.Record(testRoute(database.RouteSLO{}), time.Second, 500, 0, 0)
+
+	if report := tracker.Report(); len(report.Routes) != 0 {
+		t.Fatalf("expected no tracked routes, got %d", len(report.Routes))
+	}
+}
+
+func TestTracker_BurnRate(t *testing.T) {
+	tracker, _ := newTestTracker(nil)
+	route := testRoute(database.RouteSLO{LatencyMs: 100, ErrorBudget: 0.01})
+
+	// 2% errors against a 1% budget; 1% slow against a 1% (p99) budget
+	for i := 0; i < 100; i++ {
+		status, latency := 200, 20*time.Millisecond
+		if i < 2 {
+			status = 503
+		}
+		if i == 50 {
+			latency = 300 * time.Millisecond
+		}
+		tracker.Record(route, latency, status, 0, 0)
+	}
+
+	report := tracker.Report()
+	if len(report.Routes) != 1 {
+		t.Fatalf("expected 1 route, got %d", len(report.Routes))
+	}
+	status := report.Routes[0]
+	if status.Requests != 100 {
+		t.Errorf("requests = %d, want 100", status.Requests)
+	}
+	if report.Status != StatusDegraded || status.Status != StatusExhausted {
+		t.Errorf("status = %s/%s, want degraded/exhausted", report.Status, status.Status)
+	}
+
+	errors := findObjective(t, status, ObjectiveErrors)
+	if errors.BadRequests != 2 || errors.BurnRate < 1.99 || errors.BurnRate > 2.01 {
+		t.Errorf("errors = %d bad, burn %.2f; want 2 bad, burn 2", errors.BadRequests, errors.BurnRate)
+	}
+	if errors.Status != StatusExhausted || errors.BudgetRemaining != 0 {
+		t.Errorf("errors status = %s remaining %.2f, want exhausted 0", errors.Status, errors.BudgetRemaining)
+	}
+
+	latency := findObjective(t, status, ObjectiveLatency)
+	if latency.BadRequests != 1 {
+		t.Errorf("latency bad = %d, want 1", latency.BadRequests)
+	}
+}
+
+func TestTracker_WindowExpiry(t *testing.T) {
+	tracker, now := newTestTracker(nil)
+	route := testRoute(database.RouteSLO{ErrorBudget: 0.1, Window: "10m"})
+
+	tracker.Record(route, time.Millisecond, 500, 0, 0)
+	*now = now.Add(11 * time.Minute)
+
+	status := tracker.Report().Routes[0]
+	if status.Requests != 0 || status.Status != StatusNoData {
+		t.Errorf("got %d requests, status %s; want 0, no_data", status.Requests, status.Status)
+	}
+}
+
+func TestTracker_NotifiesOnTransitions(t *testing.T) {
+	notifier := &recordingNotifier{}
+	tracker, now := newTestTracker(notifier)
+	route := testRoute(database.RouteSLO{ErrorBudget: 0.5, Window: "10m"})
+
+	tracker.Record(route, time.Millisecond, 500, 0, 0)
+	tracker.evaluate(context.Background())
+	tracker.evaluate(context.Background()) // no repeat while still exhausted
+
+	if len(notifier.events) != 1 || notifier.events[0].Type != EventBudgetExhausted {
+		t.Fatalf("expected one exhausted event, got %+v", notifier.events)
+	}
+
+	// Healthy traffic in a later minute dilutes the error rate below budget
+	*now = now.Add(2 * time.Minute)
+	for i := 0; i < 3; i++ {
+		tracker.Record(route, time.Millisecond, 200, 0, 0)
+	}
+	tracker.evaluate(context.Background())
+
+	if len(notifier.events) != 2 || notifier.events[1].Type != EventBudgetRecovered {
+		t.Fatalf("expected recovered event, got %+v", notifier.events)
+	}
+
+	// Routes without traffic for a full window are forgotten
+	*now = now.Add(11 * time.Minute)
+	tracker.evaluate(context.Background())
+	if report := tracker.Report(); len(report.Routes) != 0 {
+		t.Errorf("expected stale route to be dropped, got %d routes", len(report.Routes))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     database.RouteSLO
+		wantErr bool
+	}{
+		{"empty", database.RouteSLO{}, false},
+		{"full", database.RouteSLO{LatencyMs: 200, ErrorBudget: 0.001, Percentile: 0.95, Window: "24h"}, false},
+		{"budget too large", database.RouteSLO{ErrorBudget: 1}, true},
+		{"negative latency", database.RouteSLO{LatencyMs: -1}, true},
+		{"bad window", database.RouteSLO{ErrorBudget: 0.01, Window: "soon"}, true},
+		{"window too long", database.RouteSLO{ErrorBudget: 0.01, Window: "720h"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tracker, _ := newTestTracker(nil)
+	tracker.Record(testRoute(database.RouteSLO{LatencyMs: 100}), 10*time.Millisecond, 200, 0, 0)
+
+	rec := httptest.NewRecorder()
+	tracker.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if report.Status != StatusOK || len(report.Routes) != 1 || report.Routes[0].RouteName != "orders" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
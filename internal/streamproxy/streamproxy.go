@@ -0,0 +1,681 @@
+// Package streamproxy proxies raw TCP and UDP traffic (L4) to services, for
+// backends that don't speak HTTP (MQTT brokers, databases, DNS) but still
+// want the gateway's config plane.
+//
+// Listeners are stream_listeners rows: each maps a listen address to a
+// service, and connections are balanced across the service's targets by
+// its load balancer (hash balancers hash the client address), or sent to
+// the service's host and port when it has no targets. Listeners are
+// opened, updated and closed on hot reload (see Load and Server.Set).
+//
+//   - TCP: each accepted connection is dialed through to a target and
+//     bytes are copied both ways until both sides close, or until the
+//     connection has been idle (no bytes either way) for idle_timeout_ms
+//   - UDP: datagrams from one client address form a session with its own
+//     upstream socket, so replies find their way back; a session ends
+//     after idle_timeout_ms without traffic
+//
+// max_connections caps concurrent TCP connections (UDP sessions) per
+// listener; connections beyond it are closed on accept and datagrams
+// opening a new session are dropped.
+//
+// Metrics:
+//
+//	gateway_stream_connections_total{listener,result="accepted|rejected|upstream_error"}
+//	gateway_stream_connections_open{listener}
+//	gateway_stream_bytes_total{listener,direction="upstream|downstream"}
+package streamproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/loadbalancer"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// Stream listener protocols (matches stream_listeners.protocol).
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+// defaultConnectTimeout applies to services without a connect timeout.
+const defaultConnectTimeout = 5 * time.Second
+
+// maxDatagramSize is the largest UDP payload relayed.
+const maxDatagramSize = 64 << 10
+
+var (
+	connectionsTotal = metrics.NewCounterVec(
+		"gateway_stream_connections_total",
+		"Stream proxy connections (UDP: sessions) by listener and result (accepted, rejected = max_connections reached, upstream_error).",
+		"listener", "result",
+	)
+	connectionsOpen = metrics.NewGaugeVec(
+		"gateway_stream_connections_open",
+		"Stream proxy connections (UDP: sessions) currently open, by listener.",
+		"listener",
+	)
+	bytesTotal = metrics.NewCounterVec(
+		"gateway_stream_bytes_total",
+		"Bytes relayed by the stream proxy, by listener and direction (upstream = client to target).",
+		"listener", "direction",
+	)
+)
+
+// Config is a snapshot of the stream listeners and the services they
+// proxy to.
+type Config struct {
+	Listeners []*database.StreamListener
+	Services  map[string]*database.Service // by ID
+}
+
+// Load reads the enabled stream listeners and their services from a store.
+func Load(ctx context.Context, store database.ConfigStore) (*Config, error) {
+	listeners, err := store.GetStreamListeners(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stream listeners: %w", err)
+	}
+	services, err := store.GetServices(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load services: %w", err)
+	}
+
+	config := &Config{
+		Listeners: listeners,
+		Services:  make(map[string]*database.Service, len(services)),
+	}
+	for _, svc := range services {
+		config.Services[svc.ID] = svc
+	}
+	return config, nil
+}
+
+// Server runs the stream listeners. A nil Server ignores configuration.
+type Server struct {
+	balancers *loadbalancer.Manager
+
+	mu        sync.Mutex
+	listeners map[string]*listener // by stream listener ID
+	closed    bool
+}
+
+// NewServer creates a server without listeners. Connections are balanced
+// by balancers (nil = always the service's host and port).
+func NewServer(balancers *loadbalancer.Manager) *Server {
+	return &Server{
+		balancers: balancers,
+		listeners: make(map[string]*listener),
+	}
+}
+
+// Set applies a configuration: listeners that were removed or moved to
+// another address are closed along with their connections, new ones are
+// opened, and the others pick up their new limits and service for new
+// connections. Listeners that fail to bind are logged and skipped.
+func (s *Server) Set(config *Config) {
+	if s == nil || config == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	wanted := make(map[string]*settings, len(config.Listeners))
+	for _, l := range config.Listeners {
+		svc, ok := config.Services[l.ServiceID]
+		if !ok || !l.Enabled {
+			continue
+		}
+		wanted[l.ID] = &settings{listener: l, service: svc}
+	}
+
+	// Close first, so an address freed by one listener can be taken by another
+	for id, ln := range s.listeners {
+		st, ok := wanted[id]
+		if !ok || st.listener.Protocol != ln.protocol || st.listener.ListenAddress != ln.address {
+			ln.close()
+			delete(s.listeners, id)
+		}
+	}
+
+	for _, l := range config.Listeners {
+		st, ok := wanted[l.ID]
+		if !ok {
+			continue
+		}
+		if ln, ok := s.listeners[l.ID]; ok {
+			ln.settings.Store(st)
+			continue
+		}
+
+		ln, err := s.listen(st)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("component", "streamproxy").
+				Str("listener_id", l.ID).
+				Str("address", l.ListenAddress).
+				Msg("Failed to open stream listener")
+			continue
+		}
+		s.listeners[l.ID] = ln
+	}
+
+	log.Info().
+		Str("component", "streamproxy").
+		Int("listeners", len(s.listeners)).
+		Msg("Stream listeners updated")
+}
+
+// Reload loads the configuration from a store and applies it. On error
+// the current listeners keep serving.
+func (s *Server) Reload(ctx context.Context, store database.ConfigStore) error {
+	if s == nil {
+		return nil
+	}
+
+	config, err := Load(ctx, store)
+	if err != nil {
+		return err
+	}
+	s.Set(config)
+	return nil
+}
+
+// Len returns the number of open listeners.
+func (s *Server) Len() int {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.listeners)
+}
+
+// Close closes every listener and connection. Later configurations are
+// ignored.
+func (s *Server) Close() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for id, ln := range s.listeners {
+		ln.close()
+		delete(s.listeners, id)
+	}
+}
+
+// listen opens a listener and starts serving it.
+func (s *Server) listen(st *settings) (*listener, error) {
+	l := st.listener
+	label := l.ID
+	if l.Name.Valid && l.Name.String != "" {
+		label = l.Name.String
+	}
+
+	ln := &listener{
+		label:     label,
+		protocol:  l.Protocol,
+		address:   l.ListenAddress,
+		balancers: s.balancers,
+		conns:     make(map[io.Closer]struct{}),
+		sessions:  make(map[string]*udpSession),
+	}
+	ln.settings.Store(st)
+
+	switch l.Protocol {
+	case ProtocolTCP:
+		tl, err := net.Listen("tcp", l.ListenAddress)
+		if err != nil {
+			return nil, err
+		}
+		ln.tcp = tl
+		ln.wg.Add(1)
+		go ln.serveTCP()
+	case ProtocolUDP:
+		pc, err := net.ListenPacket("udp", l.ListenAddress)
+		if err != nil {
+			return nil, err
+		}
+		ln.udp = pc
+		ln.wg.Add(1)
+		go ln.serveUDP()
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q", l.Protocol)
+	}
+
+	log.Info().
+		Str("component", "streamproxy").
+		Str("listener", label).
+		Str("protocol", l.Protocol).
+		Str("address", ln.addr().String()).
+		Str("service_id", l.ServiceID).
+		Msg("Stream listener opened")
+
+	return ln, nil
+}
+
+// settings are the parts of a listener that can change without reopening it.
+type settings struct {
+	listener *database.StreamListener
+	service  *database.Service
+}
+
+// idleTimeout returns the listener's idle timeout (0 = none).
+func (st *settings) idleTimeout() time.Duration {
+	return time.Duration(st.listener.IdleTimeoutMs) * time.Millisecond
+}
+
+// listener is an open TCP or UDP listener.
+type listener struct {
+	label     string
+	protocol  string
+	address   string
+	balancers *loadbalancer.Manager
+	settings  atomic.Pointer[settings]
+
+	tcp net.Listener
+	udp net.PacketConn
+
+	mu       sync.Mutex
+	conns    map[io.Closer]struct{} // open TCP connections and UDP sessions
+	sessions map[string]*udpSession // by client address
+	closed   bool
+
+	wg sync.WaitGroup
+}
+
+// addr returns the bound address.
+func (l *listener) addr() net.Addr {
+	if l.tcp != nil {
+		return l.tcp.Addr()
+	}
+	return l.udp.LocalAddr()
+}
+
+// track registers a connection, unless the listener is closed or at its
+// connection limit.
+func (l *listener) track(c io.Closer, max int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed || (max > 0 && len(l.conns) >= max) {
+		return false
+	}
+	l.conns[c] = struct{}{}
+	connectionsOpen.Add(1, l.label)
+	return true
+}
+
+// untrack unregisters a connection.
+func (l *listener) untrack(c io.Closer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.conns[c]; ok {
+		delete(l.conns, c)
+		connectionsOpen.Add(-1, l.label)
+	}
+}
+
+// isClosed reports whether close was called.
+func (l *listener) isClosed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closed
+}
+
+// close stops accepting, closes every connection and waits for their
+// goroutines to finish.
+func (l *listener) close() {
+	l.mu.Lock()
+	l.closed = true
+	conns := make([]io.Closer, 0, len(l.conns))
+	for c := range l.conns {
+		conns = append(conns, c)
+	}
+	l.mu.Unlock()
+
+	if l.tcp != nil {
+		l.tcp.Close()
+	}
+	if l.udp != nil {
+		l.udp.Close()
+	}
+	for _, c := range conns {
+		c.Close()
+	}
+	l.wg.Wait()
+	connectionsOpen.Delete(l.label)
+
+	log.Info().
+		Str("component", "streamproxy").
+		Str("listener", l.label).
+		Str("address", l.address).
+		Int("connections_closed", len(conns)).
+		Msg("Stream listener closed")
+}
+
+// dial connects to the target chosen for a client. release must be called
+// once the connection is done with.
+func (l *listener) dial(network string, client net.Addr, st *settings) (conn net.Conn, release func(), err error) {
+	svc := st.service
+	address := net.JoinHostPort(svc.Host, strconv.Itoa(svc.Port))
+	release = func() {}
+
+	var balancer loadbalancer.Balancer
+	var target *loadbalancer.Target
+	if l.balancers != nil {
+		if b, ok := l.balancers.Get(svc.ID); ok {
+			// Balancers pick by request; hash balancers hash its client address
+			req := &http.Request{Header: http.Header{}, URL: &url.URL{}, RemoteAddr: client.String()}
+			t, err := b.Next(req, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			balancer, target, address = b, t, t.Address
+			release = func() { balancer.Done(target) }
+		}
+	}
+
+	timeout := time.Duration(svc.ConnectTimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
+	}
+	start := time.Now()
+	conn, err = net.DialTimeout(network, address, timeout)
+	if network == "tcp" && l.balancers != nil {
+		// Connection failures count towards outlier ejection
+		l.balancers.Report(svc.ID, target, 0, err, time.Since(start))
+	}
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return conn, release, nil
+}
+
+// ============================================================================
+// TCP
+// ============================================================================
+
+// serveTCP accepts connections until the listener is closed.
+func (l *listener) serveTCP() {
+	defer l.wg.Done()
+
+	var backoff time.Duration
+	for {
+		conn, err := l.tcp.Accept()
+		if err != nil {
+			if l.isClosed() {
+				return
+			}
+			// e.g. too many open files: back off like net/http does
+			backoff = min(max(2*backoff, 5*time.Millisecond), time.Second)
+			log.Warn().
+				Err(err).
+				Str("component", "streamproxy").
+				Str("listener", l.label).
+				Dur("retry_in", backoff).
+				Msg("Accept failed")
+			time.Sleep(backoff)
+			continue
+		}
+		backoff = 0
+
+		st := l.settings.Load()
+		if !l.track(conn, st.listener.MaxConnections) {
+			connectionsTotal.Inc(l.label, "rejected")
+			conn.Close()
+			continue
+		}
+		l.wg.Add(1)
+		go l.handleTCP(conn, st)
+	}
+}
+
+// handleTCP proxies one client connection.
+func (l *listener) handleTCP(client net.Conn, st *settings) {
+	defer l.wg.Done()
+	defer l.untrack(client)
+	defer client.Close()
+
+	upstream, release, err := l.dial("tcp", client.RemoteAddr(), st)
+	if err != nil {
+		connectionsTotal.Inc(l.label, "upstream_error")
+		log.Warn().
+			Err(err).
+			Str("component", "streamproxy").
+			Str("listener", l.label).
+			Str("client", client.RemoteAddr().String()).
+			Msg("Failed to connect to upstream")
+		return
+	}
+	defer release()
+	defer upstream.Close()
+
+	connectionsTotal.Inc(l.label, "accepted")
+	l.pipe(client, upstream, st.idleTimeout())
+}
+
+// pipe copies bytes both ways until both sides have closed, either side
+// fails, or no bytes moved in either direction for idle.
+func (l *listener) pipe(client, upstream net.Conn, idle time.Duration) {
+	var lastActivity atomic.Int64
+	lastActivity.Store(time.Now().UnixNano())
+	idleFor := func() time.Duration {
+		return time.Since(time.Unix(0, lastActivity.Load()))
+	}
+	teardown := func() {
+		client.Close()
+		upstream.Close()
+	}
+
+	copyHalf := func(dst, src net.Conn, direction string) {
+		buf := make([]byte, 32<<10)
+		for {
+			if idle > 0 {
+				src.SetReadDeadline(time.Now().Add(idle))
+			}
+			n, err := src.Read(buf)
+			if n > 0 {
+				lastActivity.Store(time.Now().UnixNano())
+				if idle > 0 {
+					dst.SetWriteDeadline(time.Now().Add(idle))
+				}
+				if _, err := dst.Write(buf[:n]); err != nil {
+					teardown()
+					return
+				}
+				bytesTotal.Add(float64(n), l.label, direction)
+			}
+			if err == nil {
+				continue
+			}
+
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && idleFor() < idle {
+				// Only this direction is quiet
+				continue
+			}
+			if errors.Is(err, io.EOF) {
+				closeWrite(dst)
+				return
+			}
+			teardown()
+			return
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		copyHalf(upstream, client, "upstream")
+		close(done)
+	}()
+	copyHalf(client, upstream, "downstream")
+	<-done
+}
+
+// closeWrite half-closes a connection, so the peer sees EOF while replies
+// can still be read.
+func closeWrite(c net.Conn) {
+	if cw, ok := c.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+		return
+	}
+	c.Close()
+}
+
+// ============================================================================
+// UDP
+// ============================================================================
+
+// udpSession relays the datagrams of one client address.
+type udpSession struct {
+	client   net.Addr
+	upstream net.Conn
+	release  func()
+	last     atomic.Int64 // Unix nanos of the last datagram either way
+}
+
+// Close implements io.Closer (ends the session).
+func (s *udpSession) Close() error {
+	return s.upstream.Close()
+}
+
+func (s *udpSession) touch() {
+	s.last.Store(time.Now().UnixNano())
+}
+
+// serveUDP reads client datagrams until the listener is closed.
+func (l *listener) serveUDP() {
+	defer l.wg.Done()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, client, err := l.udp.ReadFrom(buf)
+		if err != nil {
+			if l.isClosed() {
+				return
+			}
+			log.Warn().
+				Err(err).
+				Str("component", "streamproxy").
+				Str("listener", l.label).
+				Msg("Read failed")
+			time.Sleep(5 * time.Millisecond)
+			continue
+		}
+
+		session := l.session(client)
+		if session == nil {
+			continue
+		}
+		session.touch()
+		if _, err := session.upstream.Write(buf[:n]); err == nil {
+			bytesTotal.Add(float64(n), l.label, "upstream")
+		}
+	}
+}
+
+// session returns the session of a client address, opening one for new
+// clients. Returns nil if the datagram must be dropped.
+func (l *listener) session(client net.Addr) *udpSession {
+	key := client.String()
+	l.mu.Lock()
+	session, ok := l.sessions[key]
+	l.mu.Unlock()
+	if ok {
+		return session
+	}
+
+	st := l.settings.Load()
+	session = &udpSession{client: client}
+	if !l.track(session, st.listener.MaxConnections) {
+		connectionsTotal.Inc(l.label, "rejected")
+		return nil
+	}
+
+	upstream, release, err := l.dial("udp", client, st)
+	if err != nil {
+		l.untrack(session)
+		connectionsTotal.Inc(l.label, "upstream_error")
+		log.Warn().
+			Err(err).
+			Str("component", "streamproxy").
+			Str("listener", l.label).
+			Str("client", key).
+			Msg("Failed to connect to upstream")
+		return nil
+	}
+	session.upstream, session.release = upstream, release
+
+	l.mu.Lock()
+	l.sessions[key] = session
+	l.mu.Unlock()
+
+	connectionsTotal.Inc(l.label, "accepted")
+	l.wg.Add(1)
+	go l.serveSession(session, st.idleTimeout())
+	return session
+}
+
+// serveSession relays upstream replies to the client until the session
+// has been idle for idle or is closed.
+func (l *listener) serveSession(s *udpSession, idle time.Duration) {
+	defer l.wg.Done()
+	defer func() {
+		l.mu.Lock()
+		delete(l.sessions, s.client.String())
+		l.mu.Unlock()
+		l.untrack(s)
+		s.upstream.Close()
+		s.release()
+	}()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		if idle > 0 {
+			s.upstream.SetReadDeadline(time.Now().Add(idle))
+		}
+		n, err := s.upstream.Read(buf)
+		if n > 0 {
+			s.touch()
+			if _, err := l.udp.WriteTo(buf[:n], s.client); err == nil {
+				bytesTotal.Add(float64(n), l.label, "downstream")
+			}
+		}
+		if err == nil {
+			continue
+		}
+
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() && time.Since(time.Unix(0, s.last.Load())) < idle {
+			// The client sent more recently than the upstream replied
+			continue
+		}
+		return
+	}
+}
@@ -0,0 +1,296 @@
+package streamproxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/loadbalancer"
+)
+
+// tcpEcho starts a TCP server echoing every connection back.
+func tcpEcho(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln
+}
+
+// udpEcho starts a UDP server echoing every datagram back.
+func udpEcho(t *testing.T) net.PacketConn {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(buf[:n], addr)
+		}
+	}()
+	return pc
+}
+
+func testService(id string, addr net.Addr) *database.Service {
+	host, port, _ := net.SplitHostPort(addr.String())
+	p, _ := strconv.Atoi(port)
+	return &database.Service{ID: id, Host: host, Port: p, ConnectTimeoutMs: 1000, LoadBalancerType: loadbalancer.TypeRoundRobin, Enabled: true}
+}
+
+func testListener(id, serviceID, protocol string) *database.StreamListener {
+	return &database.StreamListener{
+		ID:            id,
+		ServiceID:     serviceID,
+		Protocol:      protocol,
+		ListenAddress: "127.0.0.1:0",
+		IdleTimeoutMs: 5000,
+		Enabled:       true,
+	}
+}
+
+func testConfig(services []*database.Service, listeners ...*database.StreamListener) *Config {
+	config := &Config{Listeners: listeners, Services: make(map[string]*database.Service)}
+	for _, svc := range services {
+		config.Services[svc.ID] = svc
+	}
+	return config
+}
+
+// listenerAddr returns the bound address of an open listener.
+func listenerAddr(t *testing.T, s *Server, id string) string {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ln, ok := s.listeners[id]
+	if !ok {
+		t.Fatalf("listener %q not open", id)
+	}
+	return ln.addr().String()
+}
+
+// roundTrip writes msg and reads the same number of bytes back.
+func roundTrip(t *testing.T, conn net.Conn, msg string) string {
+	t.Helper()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, len(msg))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(buf)
+}
+
+func TestServer_TCP(t *testing.T) {
+	upstream := tcpEcho(t)
+	svc := testService("svc", upstream.Addr())
+
+	// The service balances over a target; its own host and port are unused
+	balancers := loadbalancer.NewManager(nil)
+	balancers.Update([]*database.Service{svc}, []*database.ServiceTarget{
+		{ID: "t1", ServiceID: "svc", Target: upstream.Addr().String(), Weight: 100, Enabled: true},
+	})
+	svc = &database.Service{ID: "svc", Host: "127.0.0.1", Port: 1, Enabled: true}
+
+	s := NewServer(balancers)
+	defer s.Close()
+	s.Set(testConfig([]*database.Service{svc}, testListener("l1", "svc", ProtocolTCP)))
+
+	conn, err := net.Dial("tcp", listenerAddr(t, s, "l1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, msg := range []string{"hello", "world"} {
+		if got := roundTrip(t, conn, msg); got != msg {
+			t.Errorf("echo = %q, want %q", got, msg)
+		}
+	}
+}
+
+func TestServer_TCPMaxConnections(t *testing.T) {
+	upstream := tcpEcho(t)
+	svc := testService("svc", upstream.Addr())
+	l := testListener("l1", "svc", ProtocolTCP)
+	l.MaxConnections = 1
+
+	s := NewServer(nil)
+	defer s.Close()
+	s.Set(testConfig([]*database.Service{svc}, l))
+	addr := listenerAddr(t, s, "l1")
+
+	first, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	roundTrip(t, first, "ping")
+
+	second, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("second connection read error = %v, want EOF (rejected)", err)
+	}
+
+	// Closing the first frees the slot
+	first.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		third, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		third.SetDeadline(time.Now().Add(500 * time.Millisecond))
+		third.Write([]byte("ping"))
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(third, buf)
+		third.Close()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("connection still rejected after the first closed: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestServer_TCPIdleTimeout(t *testing.T) {
+	upstream := tcpEcho(t)
+	svc := testService("svc", upstream.Addr())
+	l := testListener("l1", "svc", ProtocolTCP)
+	l.IdleTimeoutMs = 100
+
+	s := NewServer(nil)
+	defer s.Close()
+	s.Set(testConfig([]*database.Service{svc}, l))
+
+	conn, err := net.Dial("tcp", listenerAddr(t, s, "l1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	roundTrip(t, conn, "ping")
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("idle connection read error = %v, want EOF", err)
+	}
+}
+
+func TestServer_UDP(t *testing.T) {
+	upstream := udpEcho(t)
+	svc := testService("svc", upstream.LocalAddr())
+
+	s := NewServer(nil)
+	defer s.Close()
+	s.Set(testConfig([]*database.Service{svc}, testListener("l1", "svc", ProtocolUDP)))
+
+	conn, err := net.Dial("udp", listenerAddr(t, s, "l1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, msg := range []string{"query-1", "query-2"} {
+		if got := roundTrip(t, conn, msg); got != msg {
+			t.Errorf("echo = %q, want %q", got, msg)
+		}
+	}
+}
+
+func TestServer_Reload(t *testing.T) {
+	upstream := tcpEcho(t)
+	store := database.NewMemoryStore()
+	store.SetServices([]*database.Service{testService("svc", upstream.Addr())})
+	store.SetStreamListeners([]*database.StreamListener{
+		testListener("l1", "svc", ProtocolTCP),
+		testListener("l2", "svc", ProtocolUDP),
+	})
+
+	s := NewServer(nil)
+	defer s.Close()
+	if err := s.Reload(context.Background(), store); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if s.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", s.Len())
+	}
+	addr := listenerAddr(t, s, "l1")
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	roundTrip(t, conn, "ping")
+
+	// Removing a listener closes it along with its connections
+	store.SetStreamListeners([]*database.StreamListener{testListener("l2", "svc", ProtocolUDP)})
+	if err := s.Reload(context.Background(), store); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if s.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", s.Len())
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("expected the connection of a removed listener to be closed")
+	}
+	if c, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		c.Close()
+		t.Error("expected a removed listener to stop accepting")
+	}
+
+	s.Close()
+	if s.Len() != 0 {
+		t.Errorf("Len() after Close = %d, want 0", s.Len())
+	}
+	s.Set(testConfig(nil, testListener("l3", "svc", ProtocolTCP)))
+	if s.Len() != 0 {
+		t.Error("expected a closed server to ignore configuration")
+	}
+}
+
+func TestServer_Nil(t *testing.T) {
+	var s *Server
+	s.Set(&Config{})
+	s.Close()
+	if err := s.Reload(context.Background(), database.NewMemoryStore()); err != nil {
+		t.Errorf("Reload() error = %v", err)
+	}
+	if s.Len() != 0 {
+		t.Error("nil server should have no listeners")
+	}
+}
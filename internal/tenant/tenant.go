@@ -0,0 +1,255 @@
+// Package tenant resolves the tenant a request belongs to and routes it to
+// the tenant's own backend.
+//
+// The tenant ID comes from a request header or from the subdomain of a
+// base domain:
+//
+//	TENANT_SOURCE=header     X-Tenant-ID: acme        -> acme
+//	TENANT_SOURCE=subdomain  Host: acme.example.com   -> acme (base domain example.com)
+//
+// Services whose host or path contain {tenant} are templated per tenant:
+// a service with host "{tenant}.internal.svc" proxies acme's requests to
+// acme.internal.svc. Only tenants in the registry (the tenants table) are
+// substituted, so a client cannot steer requests to arbitrary hosts;
+// requests to templated services without a known tenant are rejected.
+//
+// The registry is held in memory and reloaded with the rest of the
+// configuration (see Load and Registry.Set), so resolving a tenant never
+// queries the database.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// Placeholder is replaced by the tenant ID in templated service hosts and
+// paths.
+const Placeholder = "{tenant}"
+
+// Tenant sources.
+const (
+	SourceHeader    = "header"
+	SourceSubdomain = "subdomain"
+)
+
+var resolutionsTotal = metrics.NewCounterVec(
+	"gateway_tenant_resolutions_total",
+	"Tenant resolutions by result (resolved, missing, unknown).",
+	"result",
+)
+
+var (
+	// ErrMissing means the request names no tenant.
+	ErrMissing = errors.New("no tenant in request")
+
+	// ErrUnknown means the request names a tenant that is not in the
+	// registry (or is disabled).
+	ErrUnknown = errors.New("unknown tenant")
+)
+
+// Config holds tenant resolution settings.
+type Config struct {
+	// Source is "header", "subdomain" or "" (tenant resolution disabled)
+	Source string
+
+	// Header carries the tenant ID when Source is "header"
+	Header string
+
+	// BaseDomain is the domain tenants are subdomains of when Source is
+	// "subdomain", e.g. "example.com" for acme.example.com
+	BaseDomain string
+}
+
+// Registry resolves requests to tenants from an in-memory copy of the
+// tenant registry. A nil Registry resolves nothing.
+type Registry struct {
+	config Config
+
+	mu      sync.RWMutex
+	tenants map[string]*database.Tenant
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry(config Config) *Registry {
+	if config.Header == "" {
+		config.Header = "X-Tenant-ID"
+	}
+	config.BaseDomain = strings.ToLower(strings.Trim(config.BaseDomain, "."))
+
+	return &Registry{
+		config:  config,
+		tenants: make(map[string]*database.Tenant),
+	}
+}
+
+// Load reads the enabled tenants from a store.
+func Load(ctx context.Context, store database.ConfigStore) ([]*database.Tenant, error) {
+	tenants, err := store.GetTenants(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+// Enabled reports whether tenant resolution is configured.
+func (reg *Registry) Enabled() bool {
+	return reg != nil && reg.config.Source != ""
+}
+
+// Set swaps in a new set of tenants. Disabled tenants are skipped.
+func (reg *Registry) Set(tenants []*database.Tenant) {
+	if reg == nil {
+		return
+	}
+
+	byID := make(map[string]*database.Tenant, len(tenants))
+	for _, t := range tenants {
+		if t != nil && t.Enabled {
+			byID[t.ID] = t
+		}
+	}
+
+	reg.mu.Lock()
+	reg.tenants = byID
+	reg.mu.Unlock()
+
+	log.Info().
+		Str("component", "tenant").
+		Int("tenants", len(byID)).
+		Msg("Tenant registry loaded")
+}
+
+// Reload loads the tenants from a store and swaps them in. On error the
+// current tenants keep serving.
+func (reg *Registry) Reload(ctx context.Context, store database.ConfigStore) error {
+	if reg == nil {
+		return nil
+	}
+
+	tenants, err := Load(ctx, store)
+	if err != nil {
+		return err
+	}
+	reg.Set(tenants)
+	return nil
+}
+
+// Len returns the number of known tenants.
+func (reg *Registry) Len() int {
+	if reg == nil {
+		return 0
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return len(reg.tenants)
+}
+
+// Get returns a known tenant by ID.
+func (reg *Registry) Get(id string) (*database.Tenant, bool) {
+	if reg == nil {
+		return nil, false
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	t, ok := reg.tenants[id]
+	return t, ok
+}
+
+// ID extracts the tenant ID a request names, or "" if it names none.
+func (reg *Registry) ID(r *http.Request) string {
+	if !reg.Enabled() {
+		return ""
+	}
+
+	switch reg.config.Source {
+	case SourceHeader:
+		return strings.ToLower(strings.TrimSpace(r.Header.Get(reg.config.Header)))
+
+	case SourceSubdomain:
+		host := strings.ToLower(r.Host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		label, ok := strings.CutSuffix(host, "."+reg.config.BaseDomain)
+		if !ok || label == "" || strings.Contains(label, ".") {
+			return ""
+		}
+		return label
+	}
+
+	return ""
+}
+
+// Resolve returns the registered tenant a request names. The error is
+// ErrMissing if it names none and ErrUnknown if the tenant is not
+// registered.
+func (reg *Registry) Resolve(r *http.Request) (*database.Tenant, error) {
+	id := reg.ID(r)
+	if id == "" {
+		resolutionsTotal.Inc("missing")
+		return nil, ErrMissing
+	}
+
+	t, ok := reg.Get(id)
+	if !ok {
+		resolutionsTotal.Inc("unknown")
+		return nil, fmt.Errorf("%w %q", ErrUnknown, id)
+	}
+
+	resolutionsTotal.Inc("resolved")
+	return t, nil
+}
+
+// ============================================================================
+// Templates
+// ============================================================================
+
+// Templated reports whether a service's host or path is templated per
+// tenant.
+func Templated(service *database.Service) bool {
+	if service == nil {
+		return false
+	}
+	return strings.Contains(service.Host, Placeholder) ||
+		(service.Path.Valid && strings.Contains(service.Path.String, Placeholder))
+}
+
+// Expand substitutes the tenant ID into a template. Templates need a
+// tenant; without one the error is ErrMissing.
+func Expand(template string, t *database.Tenant) (string, error) {
+	if !strings.Contains(template, Placeholder) {
+		return template, nil
+	}
+	if t == nil {
+		return "", ErrMissing
+	}
+	return strings.ReplaceAll(template, Placeholder, t.ID), nil
+}
+
+// ============================================================================
+// Request context
+// ============================================================================
+
+type contextKey struct{}
+
+// WithTenant returns a copy of ctx carrying t.
+func WithTenant(ctx context.Context, t *database.Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the tenant stored by WithTenant, or nil.
+func FromContext(ctx context.Context) *database.Tenant {
+	t, _ := ctx.Value(contextKey{}).(*database.Tenant)
+	return t
+}
@@ -0,0 +1,160 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+func testTenants() []*database.Tenant {
+	return []*database.Tenant{
+		{ID: "acme", Enabled: true},
+		{ID: "globex", Enabled: true},
+		{ID: "initech", Enabled: false},
+	}
+}
+
+func TestRegistry_ResolveHeader(t *testing.T) {
+	reg := NewRegistry(Config{Source: SourceHeader})
+	reg.Set(testTenants())
+
+	tests := []struct {
+		name    string
+		header  string
+		wantID  string
+		wantErr error
+	}{
+		{name: "known", header: "acme", wantID: "acme"},
+		{name: "case and space", header: " Globex ", wantID: "globex"},
+		{name: "missing", wantErr: ErrMissing},
+		{name: "unknown", header: "hooli", wantErr: ErrUnknown},
+		{name: "disabled", header: "initech", wantErr: ErrUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Tenant-ID", tt.header)
+			}
+
+			got, err := reg.Resolve(req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Resolve() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantID != "" && (got == nil || got.ID != tt.wantID) {
+				t.Errorf("Resolve() = %v, want %s", got, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestRegistry_IDSubdomain(t *testing.T) {
+	reg := NewRegistry(Config{Source: SourceSubdomain, BaseDomain: ".Example.com"})
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "acme.example.com", want: "acme"},
+		{host: "ACME.example.com:8443", want: "acme"},
+		{host: "example.com", want: ""},
+		{host: "a.b.example.com", want: ""},
+		{host: "acme.example.org", want: ""},
+		{host: "acmeexample.com", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Host = tt.host
+			if got := reg.ID(req); got != tt.want {
+				t.Errorf("ID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistry_Disabled(t *testing.T) {
+	var nilRegistry *Registry
+	disabled := NewRegistry(Config{})
+	disabled.Set(testTenants())
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+
+	for _, reg := range []*Registry{nilRegistry, disabled} {
+		if reg.Enabled() {
+			t.Error("Enabled() = true for a registry without a source")
+		}
+		if id := reg.ID(req); id != "" {
+			t.Errorf("ID() = %q, want none", id)
+		}
+	}
+}
+
+func TestRegistry_Reload(t *testing.T) {
+	store := database.NewMemoryStore()
+	store.SetTenants(testTenants())
+
+	reg := NewRegistry(Config{Source: SourceHeader})
+	if err := reg.Reload(context.Background(), store); err != nil {
+		t.Fatal(err)
+	}
+	if reg.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2 enabled tenants", reg.Len())
+	}
+
+	store.SetTenants(nil)
+	if err := reg.Reload(context.Background(), store); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reg.Get("acme"); ok {
+		t.Error("removed tenant still resolves after reload")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	acme := &database.Tenant{ID: "acme", Enabled: true}
+
+	got, err := Expand("http://{tenant}.internal.svc:8080/{tenant}", acme)
+	if err != nil || got != "http://acme.internal.svc:8080/acme" {
+		t.Errorf("Expand() = %q, %v", got, err)
+	}
+
+	got, err = Expand("http://shared.internal.svc", nil)
+	if err != nil || got != "http://shared.internal.svc" {
+		t.Errorf("Expand() without placeholder = %q, %v", got, err)
+	}
+
+	if _, err := Expand("http://{tenant}.internal.svc", nil); !errors.Is(err, ErrMissing) {
+		t.Errorf("Expand() without tenant error = %v, want ErrMissing", err)
+	}
+
+	ctx := WithTenant(context.Background(), acme)
+	if FromContext(ctx) != acme || FromContext(context.Background()) != nil {
+		t.Error("FromContext() did not return the stored tenant")
+	}
+}
+
+func TestTemplated(t *testing.T) {
+	tests := []struct {
+		service *database.Service
+		want    bool
+	}{
+		{service: &database.Service{Host: "{tenant}.internal.svc"}, want: true},
+		{service: &database.Service{Host: "api.internal.svc", Path: sql.NullString{String: "/t/{tenant}", Valid: true}}, want: true},
+		{service: &database.Service{Host: "api.internal.svc"}, want: false},
+		{service: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := Templated(tt.service); got != tt.want {
+			t.Errorf("Templated(%+v) = %v, want %v", tt.service, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+// Package tokenmint signs the short-lived internal JWTs the gateway hands
+// to backends (token-exchange plugin), so internal services only need to
+// trust gateway-issued tokens instead of every client credential type.
+//
+// Keys are PEM private keys (Ed25519, ECDSA P-256 or RSA ≥ 2048 bits)
+// read from TOKEN_MINT_KEY_FILES. The first key signs new tokens (EdDSA,
+// ES256 or RS256); every key is published in the JWKS served at
+// /.well-known/gateway-jwks.json, keyed by its RFC 7638 thumbprint. To
+// rotate:
+//
+//  1. Add the new key file second, so backends cache its public key
+//  2. Once their JWKS caches have refreshed, move it first: it now signs
+//  3. Remove the old key once tokens it signed have expired
+//
+// The key files are re-read every TOKEN_MINT_KEY_RELOAD_INTERVAL, so a
+// rotated secret mount takes effect without a restart. A reload that
+// fails keeps the current keys.
+package tokenmint
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// JWKSPath is where the gateway serves its public keys.
+const JWKSPath = "/.well-known/gateway-jwks.json"
+
+// Signing algorithms (JWS "alg").
+const (
+	AlgEdDSA = "EdDSA"
+	AlgES256 = "ES256"
+	AlgRS256 = "RS256"
+)
+
+// minRSABits is the smallest RSA key accepted.
+const minRSABits = 2048
+
+// Key is a signing key.
+type Key struct {
+	// ID is the key's RFC 7638 thumbprint, sent as the token's "kid"
+	ID string
+
+	// Algorithm is the JWS algorithm the key signs with
+	Algorithm string
+
+	signer crypto.Signer
+	jwk    map[string]string // public JWK members
+}
+
+// ParseKey parses a PEM private key: PKCS#8 ("PRIVATE KEY"), SEC 1
+// ("EC PRIVATE KEY") or PKCS#1 ("RSA PRIVATE KEY").
+func ParseKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q (want a private key)", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return NewKey(signer)
+}
+
+// NewKey wraps an Ed25519, ECDSA P-256 or RSA private key.
+func NewKey(signer crypto.Signer) (*Key, error) {
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	key := &Key{signer: signer}
+	switch k := signer.(type) {
+	case ed25519.PrivateKey:
+		key.Algorithm = AlgEdDSA
+		key.jwk = map[string]string{"kty": "OKP", "crv": "Ed25519", "x": b64(k.Public().(ed25519.PublicKey))}
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported ECDSA curve %s (want P-256)", k.Curve.Params().Name)
+		}
+		ecdh, err := k.PublicKey.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		point := ecdh.Bytes() // 0x04 || X || Y
+		key.Algorithm = AlgES256
+		key.jwk = map[string]string{"kty": "EC", "crv": "P-256", "x": b64(point[1:33]), "y": b64(point[33:])}
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key is %d bits (want at least %d)", k.N.BitLen(), minRSABits)
+		}
+		key.Algorithm = AlgRS256
+		key.jwk = map[string]string{"kty": "RSA", "n": b64(k.N.Bytes()), "e": b64(big.NewInt(int64(k.E)).Bytes())}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T (want Ed25519, ECDSA P-256 or RSA)", signer)
+	}
+
+	// RFC 7638: SHA-256 of the required members in lexicographic order.
+	// encoding/json sorts map keys, and the values need no escaping.
+	canonical, err := json.Marshal(key.jwk)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(canonical)
+	key.ID = b64(sum[:])
+
+	return key, nil
+}
+
+// sign returns the JWS signature of input.
+func (k *Key) sign(input []byte) ([]byte, error) {
+	switch priv := k.signer.(type) {
+	case ed25519.PrivateKey:
+		return ed25519.Sign(priv, input), nil
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(input)
+		r, s, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// JWS wants fixed-size R || S, not ASN.1
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	default:
+		digest := sha256.Sum256(input)
+		return k.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+}
+
+// keySet is an immutable set of keys and their rendered JWKS.
+type keySet struct {
+	keys []*Key
+	jwks []byte
+}
+
+func newKeySet(keys []*Key) (*keySet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+
+	type jwk map[string]string
+	doc := struct {
+		Keys []jwk `json:"keys"`
+	}{Keys: make([]jwk, 0, len(keys))}
+
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if seen[k.ID] {
+			return nil, fmt.Errorf("key %s is listed more than once", k.ID)
+		}
+		seen[k.ID] = true
+
+		entry := jwk{"kid": k.ID, "alg": k.Algorithm, "use": "sig"}
+		for name, value := range k.jwk {
+			entry[name] = value
+		}
+		doc.Keys = append(doc.Keys, entry)
+	}
+
+	jwks, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &keySet{keys: keys, jwks: jwks}, nil
+}
+
+// Signer mints tokens with the first of its keys and publishes all of
+// them. A nil Signer has no keys.
+type Signer struct {
+	files []string
+	set   atomic.Pointer[keySet]
+}
+
+// NewSigner creates a signer from keys; the first signs.
+func NewSigner(keys ...*Key) (*Signer, error) {
+	set, err := newKeySet(keys)
+	if err != nil {
+		return nil, err
+	}
+	s := &Signer{}
+	s.set.Store(set)
+	return s, nil
+}
+
+// LoadSigner creates a signer from PEM key files; the first signs. See
+// Reload.
+func LoadSigner(files []string) (*Signer, error) {
+	s := &Signer{files: files}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload re-reads the key files. On error the current keys are kept.
+func (s *Signer) Reload() error {
+	if s == nil || len(s.files) == 0 {
+		return nil
+	}
+
+	keys := make([]*Key, 0, len(s.files))
+	for _, file := range s.files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read token signing key: %w", err)
+		}
+		key, err := ParseKey(data)
+		if err != nil {
+			return fmt.Errorf("invalid token signing key %s: %w", file, err)
+		}
+		keys = append(keys, key)
+	}
+
+	set, err := newKeySet(keys)
+	if err != nil {
+		return err
+	}
+	if old := s.set.Swap(set); old == nil || old.keys[0].ID != set.keys[0].ID || len(old.keys) != len(set.keys) {
+		log.Info().
+			Str("component", "tokenmint").
+			Str("signing_key", set.keys[0].ID).
+			Int("keys", len(set.keys)).
+			Msg("Token signing keys loaded")
+	}
+	return nil
+}
+
+// Run reloads the key files every interval until ctx is done. Failed
+// reloads are logged.
+func (s *Signer) Run(ctx context.Context, interval time.Duration) {
+	if s == nil || interval <= 0 || len(s.files) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(); err != nil {
+				log.Error().
+					Err(err).
+					Str("component", "tokenmint").
+					Msg("Failed to reload token signing keys - keeping the current keys")
+			}
+		}
+	}
+}
+
+// KeyID returns the ID of the signing key ("" for a nil Signer).
+func (s *Signer) KeyID() string {
+	if s == nil {
+		return ""
+	}
+	return s.set.Load().keys[0].ID
+}
+
+// Sign returns a compact JWS (JWT) of claims signed with the first key.
+func (s *Signer) Sign(claims map[string]interface{}) (string, error) {
+	if s == nil {
+		return "", errors.New("no token signing keys configured")
+	}
+	key := s.set.Load().keys[0]
+
+	header, err := json.Marshal(map[string]string{"alg": key.Algorithm, "typ": "JWT", "kid": key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode claims: %w", err)
+	}
+
+	b64 := base64.RawURLEncoding
+	input := make([]byte, 0, b64.EncodedLen(len(header))+1+b64.EncodedLen(len(payload)))
+	input = b64.AppendEncode(input, header)
+	input = append(input, '.')
+	input = b64.AppendEncode(input, payload)
+
+	sig, err := key.sign(input)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return string(input) + "." + b64.EncodeToString(sig), nil
+}
+
+// JWKS returns the public keys as a JSON Web Key Set.
+func (s *Signer) JWKS() []byte {
+	if s == nil {
+		return []byte(`{"keys":[]}`)
+	}
+	return s.set.Load().jwks
+}
+
+// ServeHTTP serves the JWKS. Backends may cache it for five minutes, so
+// a new key must be published (listed second) at least that long before
+// it starts signing.
+func (s *Signer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Write(s.JWKS())
+}
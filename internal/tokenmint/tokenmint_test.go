@@ -0,0 +1,249 @@
+package tokenmint
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKeys(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]crypto.Signer{AlgEdDSA: ed, AlgES256: ec, AlgRS256: rs}
+}
+
+// verify checks a token's signature against the public key in a JWKS and
+// returns its claims.
+func verify(t *testing.T, token string, jwks []byte) map[string]interface{} {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d parts", len(parts))
+	}
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatalf("decode %q: %v", s, err)
+		}
+		return b
+	}
+
+	var header map[string]string
+	if err := json.Unmarshal(decode(parts[0]), &header); err != nil {
+		t.Fatal(err)
+	}
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(jwks, &set); err != nil {
+		t.Fatal(err)
+	}
+	var jwk map[string]string
+	for _, k := range set.Keys {
+		if k["kid"] == header["kid"] {
+			jwk = k
+		}
+	}
+	if jwk == nil {
+		t.Fatalf("kid %q not in JWKS", header["kid"])
+	}
+	if jwk["alg"] != header["alg"] {
+		t.Fatalf("alg = %q, JWKS says %q", header["alg"], jwk["alg"])
+	}
+
+	input := []byte(parts[0] + "." + parts[1])
+	sig := decode(parts[2])
+	digest := sha256.Sum256(input)
+	ok := false
+	switch header["alg"] {
+	case AlgEdDSA:
+		ok = ed25519.Verify(ed25519.PublicKey(decode(jwk["x"])), input, sig)
+	case AlgES256:
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(decode(jwk["x"])), Y: new(big.Int).SetBytes(decode(jwk["y"]))}
+		ok = len(sig) == 64 && ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+	case AlgRS256:
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(decode(jwk["n"])), E: int(new(big.Int).SetBytes(decode(jwk["e"])).Int64())}
+		ok = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil
+	}
+	if !ok {
+		t.Fatalf("%s signature does not verify", header["alg"])
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(decode(parts[1]), &claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestSigner_SignAndVerify(t *testing.T) {
+	for alg, priv := range testKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			key, err := NewKey(priv)
+			if err != nil {
+				t.Fatalf("NewKey() error = %v", err)
+			}
+			if key.Algorithm != alg {
+				t.Errorf("Algorithm = %q, want %q", key.Algorithm, alg)
+			}
+			s, err := NewSigner(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := s.Sign(map[string]interface{}{"sub": "consumer-1", "scope": "orders:read"})
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			claims := verify(t, token, s.JWKS())
+			if claims["sub"] != "consumer-1" || claims["scope"] != "orders:read" {
+				t.Errorf("claims = %v", claims)
+			}
+		})
+	}
+}
+
+func TestNewKey_Thumbprint(t *testing.T) {
+	// RFC 8037 appendix A.3
+	x, _ := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	seed, _ := base64.RawURLEncoding.DecodeString("nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A")
+	priv := ed25519.NewKeyFromSeed(seed)
+	if !priv.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(x)) {
+		t.Fatal("test vector key mismatch")
+	}
+
+	key, err := NewKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"; key.ID != want {
+		t.Errorf("ID = %q, want %q", key.ID, want)
+	}
+}
+
+func TestNewKey_Rejects(t *testing.T) {
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if _, err := NewKey(p384); err == nil {
+		t.Error("expected P-384 to be rejected")
+	}
+	small, _ := rsa.GenerateKey(rand.Reader, 1024)
+	if _, err := NewKey(small); err == nil {
+		t.Error("expected a 1024-bit RSA key to be rejected")
+	}
+	if _, err := ParseKey([]byte("not a key")); err == nil {
+		t.Error("expected non-PEM input to be rejected")
+	}
+}
+
+func writeKey(t *testing.T, dir, name string, priv crypto.Signer) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSigner_Rotation(t *testing.T) {
+	keys := testKeys(t)
+	dir := t.TempDir()
+	current := writeKey(t, dir, "current.pem", keys[AlgEdDSA])
+	next := writeKey(t, dir, "next.pem", keys[AlgES256])
+
+	s, err := LoadSigner([]string{current, next})
+	if err != nil {
+		t.Fatalf("LoadSigner() error = %v", err)
+	}
+	oldToken, err := s.Sign(map[string]interface{}{"sub": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKID := s.KeyID()
+
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	json.Unmarshal(s.JWKS(), &set)
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want 2", len(set.Keys))
+	}
+
+	// Promote the next key: it signs, the old one still verifies
+	writeKey(t, dir, "current.pem", keys[AlgES256])
+	writeKey(t, dir, "next.pem", keys[AlgEdDSA])
+	if err := s.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if s.KeyID() == oldKID {
+		t.Error("expected the signing key to change")
+	}
+	newToken, _ := s.Sign(map[string]interface{}{"sub": "b"})
+	verify(t, oldToken, s.JWKS())
+	verify(t, newToken, s.JWKS())
+
+	// A broken file keeps the current keys
+	os.WriteFile(current, []byte("garbage"), 0o600)
+	if err := s.Reload(); err == nil {
+		t.Error("expected Reload() to fail")
+	}
+	if token, err := s.Sign(map[string]interface{}{"sub": "c"}); err != nil {
+		t.Errorf("Sign() after a failed reload error = %v", err)
+	} else {
+		verify(t, token, s.JWKS())
+	}
+}
+
+func TestSigner_ServeHTTP(t *testing.T) {
+	key, _ := NewKey(testKeys(t)[AlgEdDSA])
+	s, _ := NewSigner(key)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, JWKSPath, nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/jwk-set+json" {
+		t.Fatalf("GET = %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), key.ID) || strings.Contains(w.Body.String(), `"d"`) {
+		t.Errorf("unexpected JWKS %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, JWKSPath, nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want 405", w.Code)
+	}
+
+	var nilSigner *Signer
+	if _, err := nilSigner.Sign(nil); err == nil {
+		t.Error("expected a nil signer to refuse to sign")
+	}
+}
@@ -0,0 +1,499 @@
+package transcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ============================================================================
+// Descriptors
+// ============================================================================
+//
+// Descriptors come from a compiled FileDescriptorSet:
+//
+//	protoc --include_imports --descriptor_set_out=api.pb api.proto
+//
+// which is itself a protobuf message (google/protobuf/descriptor.proto).
+// Only the parts transcoding needs are read: messages, fields, enums,
+// services, and the google.api.http method option.
+
+// Field types (FieldDescriptorProto.Type).
+const (
+	typeDouble   = 1
+	typeFloat    = 2
+	typeInt64    = 3
+	typeUint64   = 4
+	typeInt32    = 5
+	typeFixed64  = 6
+	typeFixed32  = 7
+	typeBool     = 8
+	typeString   = 9
+	typeGroup    = 10
+	typeMessage  = 11
+	typeBytes    = 12
+	typeUint32   = 13
+	typeEnum     = 14
+	typeSfixed32 = 15
+	typeSfixed64 = 16
+	typeSint32   = 17
+	typeSint64   = 18
+)
+
+// labelRepeated is FieldDescriptorProto.Label for repeated fields.
+const labelRepeated = 3
+
+// httpRuleExtension is the field number of google.api.http on MethodOptions.
+const httpRuleExtension = 72295728
+
+// Message describes a protobuf message type.
+type Message struct {
+	FullName string
+	Fields   []*Field // declaration order
+	MapEntry bool     // synthetic map<K, V> entry (key = 1, value = 2)
+
+	byNumber map[int32]*Field
+	byName   map[string]*Field // proto and JSON names
+}
+
+// Field describes a message field.
+type Field struct {
+	Name     string
+	JSONName string
+	Number   int32
+	Type     int
+	Repeated bool
+	TypeName string // message or enum type, fully qualified without the leading dot
+
+	Message *Message // resolved for message fields (nil for well-known types)
+	Enum    *Enum    // resolved for enum fields
+}
+
+// Enum describes an enum type.
+type Enum struct {
+	FullName string
+	Values   []EnumValue
+
+	byName   map[string]int32
+	byNumber map[int32]string
+}
+
+// EnumValue is one enum constant.
+type EnumValue struct {
+	Name   string
+	Number int32
+}
+
+// Method describes a service method.
+type Method struct {
+	Service         string // fully qualified service name
+	Name            string
+	Input           *Message
+	Output          *Message
+	ClientStreaming bool
+	ServerStreaming bool
+	Rules           []HTTPRule // from google.api.http, including additional_bindings
+}
+
+// FullMethod returns the gRPC request path, e.g. "/shop.v1.Orders/GetOrder".
+func (m *Method) FullMethod() string {
+	return "/" + m.Service + "/" + m.Name
+}
+
+// HTTPRule maps a method to an HTTP method and path template.
+type HTTPRule struct {
+	Method       string // GET, POST, ... or a custom verb
+	Path         string
+	Body         string // "*", a field name, or "" (no body)
+	ResponseBody string // response field sent as the body ("" = whole message)
+}
+
+// Descriptors holds the types and methods of a descriptor set.
+type Descriptors struct {
+	Messages map[string]*Message
+	Enums    map[string]*Enum
+	Methods  []*Method
+}
+
+// pendingMethod is a method whose types are resolved after every file is read.
+type pendingMethod struct {
+	method *Method
+	input  string
+	output string
+}
+
+// ParseDescriptorSet reads a serialized FileDescriptorSet.
+func ParseDescriptorSet(data []byte) (*Descriptors, error) {
+	d := &Descriptors{
+		Messages: make(map[string]*Message),
+		Enums:    make(map[string]*Enum),
+	}
+	var methods []pendingMethod
+
+	err := forEachField(data, func(f wireField) error {
+		if f.number != 1 || f.wireType != wireBytes { // FileDescriptorSet.file
+			return nil
+		}
+		found, err := d.parseFile(f.data)
+		if err != nil {
+			return err
+		}
+		methods = append(methods, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+
+	// Resolve field and method types now that every file is known
+	for _, m := range d.Messages {
+		for _, field := range m.Fields {
+			if err := d.resolve(field); err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", m.FullName, field.Name, err)
+			}
+		}
+	}
+	for _, pm := range methods {
+		var ok bool
+		if pm.method.Input, ok = d.Messages[pm.input]; !ok && !isWellKnown(pm.input) {
+			return nil, fmt.Errorf("%s: unknown input type %s", pm.method.FullMethod(), pm.input)
+		}
+		if pm.method.Output, ok = d.Messages[pm.output]; !ok && !isWellKnown(pm.output) {
+			return nil, fmt.Errorf("%s: unknown output type %s", pm.method.FullMethod(), pm.output)
+		}
+		if pm.method.Input == nil {
+			pm.method.Input = wellKnownMessage(pm.input)
+		}
+		if pm.method.Output == nil {
+			pm.method.Output = wellKnownMessage(pm.output)
+		}
+		d.Methods = append(d.Methods, pm.method)
+	}
+
+	return d, nil
+}
+
+// resolve links a message or enum field to its type.
+func (d *Descriptors) resolve(field *Field) error {
+	switch field.Type {
+	case typeMessage:
+		if isWellKnown(field.TypeName) {
+			return nil
+		}
+		m, ok := d.Messages[field.TypeName]
+		if !ok {
+			return fmt.Errorf("unknown message type %s (compile with --include_imports)", field.TypeName)
+		}
+		field.Message = m
+	case typeEnum:
+		e, ok := d.Enums[field.TypeName]
+		if !ok && field.TypeName == nullValueEnum.FullName {
+			e, ok = nullValueEnum, true
+		}
+		if !ok {
+			return fmt.Errorf("unknown enum type %s (compile with --include_imports)", field.TypeName)
+		}
+		field.Enum = e
+	case typeGroup:
+		return fmt.Errorf("groups are not supported")
+	}
+	return nil
+}
+
+// parseFile reads a FileDescriptorProto.
+func (d *Descriptors) parseFile(data []byte) ([]pendingMethod, error) {
+	var pkg string
+	var messages, enums, services [][]byte
+
+	err := forEachField(data, func(f wireField) error {
+		switch f.number {
+		case 2:
+			pkg = string(f.data)
+		case 4:
+			messages = append(messages, f.data)
+		case 5:
+			enums = append(enums, f.data)
+		case 6:
+			services = append(services, f.data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range messages {
+		if err := d.parseMessage(pkg, m); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range enums {
+		if err := d.parseEnum(pkg, e); err != nil {
+			return nil, err
+		}
+	}
+
+	var methods []pendingMethod
+	for _, s := range services {
+		found, err := parseService(pkg, s)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, found...)
+	}
+	return methods, nil
+}
+
+// qualify joins a scope and a name.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// parseMessage reads a DescriptorProto and its nested types.
+func (d *Descriptors) parseMessage(scope string, data []byte) error {
+	m := &Message{
+		byNumber: make(map[int32]*Field),
+		byName:   make(map[string]*Field),
+	}
+	var nested, enums [][]byte
+
+	err := forEachField(data, func(f wireField) error {
+		switch f.number {
+		case 1:
+			m.FullName = qualify(scope, string(f.data))
+		case 2:
+			field, err := parseField(f.data)
+			if err != nil {
+				return err
+			}
+			m.Fields = append(m.Fields, field)
+		case 3:
+			nested = append(nested, f.data)
+		case 4:
+			enums = append(enums, f.data)
+		case 7: // MessageOptions
+			return forEachField(f.data, func(o wireField) error {
+				if o.number == 7 { // map_entry
+					m.MapEntry = o.num != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, field := range m.Fields {
+		m.byNumber[field.Number] = field
+		m.byName[field.Name] = field
+		m.byName[field.JSONName] = field
+	}
+	d.Messages[m.FullName] = m
+
+	for _, n := range nested {
+		if err := d.parseMessage(m.FullName, n); err != nil {
+			return err
+		}
+	}
+	for _, e := range enums {
+		if err := d.parseEnum(m.FullName, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseField reads a FieldDescriptorProto.
+func parseField(data []byte) (*Field, error) {
+	field := &Field{}
+	err := forEachField(data, func(f wireField) error {
+		switch f.number {
+		case 1:
+			field.Name = string(f.data)
+		case 3:
+			field.Number = int32(f.num)
+		case 4:
+			field.Repeated = f.num == labelRepeated
+		case 5:
+			field.Type = int(f.num)
+		case 6:
+			field.TypeName = strings.TrimPrefix(string(f.data), ".")
+		case 10:
+			field.JSONName = string(f.data)
+		}
+		return nil
+	})
+	if field.JSONName == "" {
+		field.JSONName = jsonName(field.Name)
+	}
+	return field, err
+}
+
+// jsonName converts a proto field name to lowerCamelCase, as protoc does.
+func jsonName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, c := range name {
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper && c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// parseEnum reads an EnumDescriptorProto.
+func (d *Descriptors) parseEnum(scope string, data []byte) error {
+	e := &Enum{
+		byName:   make(map[string]int32),
+		byNumber: make(map[int32]string),
+	}
+	err := forEachField(data, func(f wireField) error {
+		switch f.number {
+		case 1:
+			e.FullName = qualify(scope, string(f.data))
+		case 2:
+			var v EnumValue
+			err := forEachField(f.data, func(vf wireField) error {
+				switch vf.number {
+				case 1:
+					v.Name = string(vf.data)
+				case 2:
+					v.Number = int32(vf.num)
+				}
+				return nil
+			})
+			e.Values = append(e.Values, v)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, v := range e.Values {
+		e.byName[v.Name] = v.Number
+		if _, ok := e.byNumber[v.Number]; !ok { // first name wins for aliases
+			e.byNumber[v.Number] = v.Name
+		}
+	}
+	d.Enums[e.FullName] = e
+	return nil
+}
+
+// parseService reads a ServiceDescriptorProto.
+func parseService(pkg string, data []byte) ([]pendingMethod, error) {
+	var name string
+	var rawMethods [][]byte
+	err := forEachField(data, func(f wireField) error {
+		switch f.number {
+		case 1:
+			name = string(f.data)
+		case 2:
+			rawMethods = append(rawMethods, f.data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	service := qualify(pkg, name)
+	var methods []pendingMethod
+	for _, raw := range rawMethods {
+		pm := pendingMethod{method: &Method{Service: service}}
+		err := forEachField(raw, func(f wireField) error {
+			switch f.number {
+			case 1:
+				pm.method.Name = string(f.data)
+			case 2:
+				pm.input = strings.TrimPrefix(string(f.data), ".")
+			case 3:
+				pm.output = strings.TrimPrefix(string(f.data), ".")
+			case 4: // MethodOptions
+				return forEachField(f.data, func(o wireField) error {
+					if o.number != httpRuleExtension {
+						return nil
+					}
+					rules, err := parseHTTPRule(o.data)
+					pm.method.Rules = append(pm.method.Rules, rules...)
+					return err
+				})
+			case 5:
+				pm.method.ClientStreaming = f.num != 0
+			case 6:
+				pm.method.ServerStreaming = f.num != 0
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, pm)
+	}
+	return methods, nil
+}
+
+// parseHTTPRule reads a google.api.HttpRule and its additional bindings.
+func parseHTTPRule(data []byte) ([]HTTPRule, error) {
+	var rule HTTPRule
+	var additional [][]byte
+
+	err := forEachField(data, func(f wireField) error {
+		switch f.number {
+		case 2:
+			rule.Method, rule.Path = "GET", string(f.data)
+		case 3:
+			rule.Method, rule.Path = "PUT", string(f.data)
+		case 4:
+			rule.Method, rule.Path = "POST", string(f.data)
+		case 5:
+			rule.Method, rule.Path = "DELETE", string(f.data)
+		case 6:
+			rule.Method, rule.Path = "PATCH", string(f.data)
+		case 7:
+			rule.Body = string(f.data)
+		case 8: // CustomHttpPattern
+			return forEachField(f.data, func(c wireField) error {
+				switch c.number {
+				case 1:
+					rule.Method = string(c.data)
+				case 2:
+					rule.Path = string(c.data)
+				}
+				return nil
+			})
+		case 11:
+			additional = append(additional, f.data)
+		case 12:
+			rule.ResponseBody = string(f.data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []HTTPRule
+	if rule.Path != "" {
+		rules = append(rules, rule)
+	}
+	for _, a := range additional {
+		more, err := parseHTTPRule(a)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, more...)
+	}
+	return rules, nil
+}
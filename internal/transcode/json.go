@@ -0,0 +1,657 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// JSON mapping
+// ============================================================================
+//
+// Messages are converted following the proto3 JSON mapping:
+//   - fields by JSON name (lowerCamelCase); the proto name is accepted too
+//   - 64-bit integers as strings, bytes as base64, enums by name
+//   - maps as objects, well-known types in their special forms (see
+//     wellknown.go)
+//
+// Unknown JSON fields are rejected, unknown protobuf fields are skipped.
+
+// codec converts between JSON values and encoded messages.
+type codec struct {
+	types           *Descriptors
+	useProtoNames   bool // proto field names in output instead of JSON names
+	emitUnpopulated bool // output fields left at their default value
+}
+
+// ----------------------------------------------------------------------------
+// JSON -> protobuf
+// ----------------------------------------------------------------------------
+
+// decodeJSON parses a JSON document keeping numbers exact.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+// marshal encodes a JSON value as message m.
+func (c *codec) marshal(m *Message, v any) ([]byte, error) {
+	if isWellKnown(m.FullName) {
+		return c.marshalWellKnown(m.FullName, v)
+	}
+
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a JSON object", m.FullName)
+	}
+	for key := range obj {
+		if _, ok := m.byName[key]; !ok {
+			return nil, fmt.Errorf("%s: unknown field %q", m.FullName, key)
+		}
+	}
+
+	var b []byte
+	for _, f := range m.Fields {
+		value, ok := obj[f.JSONName]
+		if !ok {
+			if value, ok = obj[f.Name]; !ok {
+				continue
+			}
+		}
+		var err error
+		if b, err = c.appendField(b, f, value); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendField encodes one field's JSON value.
+func (c *codec) appendField(b []byte, f *Field, v any) ([]byte, error) {
+	// null means "not set", except for google.protobuf.Value
+	if v == nil && f.TypeName != "google.protobuf.Value" {
+		return b, nil
+	}
+
+	switch {
+	case f.Message != nil && f.Message.MapEntry:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("field %s: expected a JSON object", f.Name)
+		}
+		keyField, valueField := f.Message.byNumber[1], f.Message.byNumber[2]
+		if keyField == nil || valueField == nil {
+			return nil, fmt.Errorf("field %s: invalid map entry", f.Name)
+		}
+
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			entry, err := c.appendValue(nil, keyField, k)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: key %q: %w", f.Name, k, err)
+			}
+			if entry, err = c.appendValue(entry, valueField, obj[k]); err != nil {
+				return nil, fmt.Errorf("field %s[%q]: %w", f.Name, k, err)
+			}
+			b = appendLengthDelimited(b, f.Number, entry)
+		}
+		return b, nil
+
+	case f.Repeated:
+		list, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("field %s: expected a JSON array", f.Name)
+		}
+		if packable(f.Type) {
+			var packed []byte
+			for _, item := range list {
+				_, value, err := scalarBytes(f, item)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", f.Name, err)
+				}
+				packed = append(packed, value...)
+			}
+			return appendLengthDelimited(b, f.Number, packed), nil
+		}
+		for _, item := range list {
+			var err error
+			if b, err = c.appendValue(b, f, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+
+	default:
+		return c.appendValue(b, f, v)
+	}
+}
+
+// appendValue encodes a single (non-repeated) value with its tag.
+func (c *codec) appendValue(b []byte, f *Field, v any) ([]byte, error) {
+	switch f.Type {
+	case typeMessage:
+		m := f.Message
+		if m == nil {
+			m = wellKnownMessage(f.TypeName)
+		}
+		data, err := c.marshal(m, v)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		return appendLengthDelimited(b, f.Number, data), nil
+
+	case typeString:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("field %s: expected a string", f.Name)
+		}
+		return appendLengthDelimited(b, f.Number, []byte(s)), nil
+
+	case typeBytes:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("field %s: expected a base64 string", f.Name)
+		}
+		data, err := decodeBase64(s)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		return appendLengthDelimited(b, f.Number, data), nil
+	}
+
+	wireType, value, err := scalarBytes(f, v)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", f.Name, err)
+	}
+	b = appendTag(b, f.Number, wireType)
+	return append(b, value...), nil
+}
+
+// packable reports whether repeated fields of type t use packed encoding.
+func packable(t int) bool {
+	switch t {
+	case typeString, typeBytes, typeMessage, typeGroup:
+		return false
+	}
+	return true
+}
+
+// scalarBytes encodes a numeric, bool or enum value without its tag.
+func scalarBytes(f *Field, v any) (int, []byte, error) {
+	switch f.Type {
+	case typeDouble:
+		x, err := jsonFloat(v, 64)
+		return wireFixed64, appendFixed64(nil, math.Float64bits(x)), err
+	case typeFloat:
+		x, err := jsonFloat(v, 32)
+		return wireFixed32, appendFixed32(nil, math.Float32bits(float32(x))), err
+	case typeInt32:
+		x, err := jsonInt(v, 32)
+		return wireVarint, appendVarint(nil, uint64(x)), err
+	case typeInt64:
+		x, err := jsonInt(v, 64)
+		return wireVarint, appendVarint(nil, uint64(x)), err
+	case typeSint32:
+		x, err := jsonInt(v, 32)
+		return wireVarint, appendVarint(nil, encodeZigZag(x)), err
+	case typeSint64:
+		x, err := jsonInt(v, 64)
+		return wireVarint, appendVarint(nil, encodeZigZag(x)), err
+	case typeSfixed32:
+		x, err := jsonInt(v, 32)
+		return wireFixed32, appendFixed32(nil, uint32(x)), err
+	case typeSfixed64:
+		x, err := jsonInt(v, 64)
+		return wireFixed64, appendFixed64(nil, uint64(x)), err
+	case typeUint32:
+		x, err := jsonUint(v, 32)
+		return wireVarint, appendVarint(nil, x), err
+	case typeUint64:
+		x, err := jsonUint(v, 64)
+		return wireVarint, appendVarint(nil, x), err
+	case typeFixed32:
+		x, err := jsonUint(v, 32)
+		return wireFixed32, appendFixed32(nil, uint32(x)), err
+	case typeFixed64:
+		x, err := jsonUint(v, 64)
+		return wireFixed64, appendFixed64(nil, x), err
+	case typeBool:
+		x, err := jsonBool(v)
+		n := uint64(0)
+		if x {
+			n = 1
+		}
+		return wireVarint, appendVarint(nil, n), err
+	case typeEnum:
+		x, err := jsonEnum(f.Enum, v)
+		return wireVarint, appendVarint(nil, uint64(int64(x))), err
+	}
+	return 0, nil, fmt.Errorf("unsupported field type %d", f.Type)
+}
+
+// numberText returns the text of a JSON number or numeric string (query
+// and path parameters are always strings).
+func numberText(v any) (string, bool) {
+	switch x := v.(type) {
+	case json.Number:
+		return string(x), true
+	case string:
+		return strings.TrimSpace(x), true
+	}
+	return "", false
+}
+
+func jsonFloat(v any, bits int) (float64, error) {
+	s, ok := numberText(v)
+	if !ok {
+		return 0, fmt.Errorf("expected a number, got %T", v)
+	}
+	switch s {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+	x, err := strconv.ParseFloat(s, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return x, nil
+}
+
+func jsonInt(v any, bits int) (int64, error) {
+	s, ok := numberText(v)
+	if !ok {
+		return 0, fmt.Errorf("expected an integer, got %T", v)
+	}
+	x, err := strconv.ParseInt(s, 10, bits)
+	if err != nil {
+		// Integral values written as floats or exponents ("1e3", "5.0")
+		f, ferr := strconv.ParseFloat(s, 64)
+		if ferr != nil || f != math.Trunc(f) || f < math.MinInt64 || f > math.MaxInt64 {
+			return 0, fmt.Errorf("invalid integer %q", s)
+		}
+		if x, err = strconv.ParseInt(strconv.FormatFloat(f, 'f', 0, 64), 10, bits); err != nil {
+			return 0, fmt.Errorf("integer %q out of range", s)
+		}
+	}
+	return x, nil
+}
+
+func jsonUint(v any, bits int) (uint64, error) {
+	s, ok := numberText(v)
+	if !ok {
+		return 0, fmt.Errorf("expected an integer, got %T", v)
+	}
+	x, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		f, ferr := strconv.ParseFloat(s, 64)
+		if ferr != nil || f != math.Trunc(f) || f < 0 {
+			return 0, fmt.Errorf("invalid unsigned integer %q", s)
+		}
+		if x, err = strconv.ParseUint(strconv.FormatFloat(f, 'f', 0, 64), 10, bits); err != nil {
+			return 0, fmt.Errorf("integer %q out of range", s)
+		}
+	}
+	return x, nil
+}
+
+func jsonBool(v any) (bool, error) {
+	switch x := v.(type) {
+	case bool:
+		return x, nil
+	case string:
+		if b, err := strconv.ParseBool(x); err == nil {
+			return b, nil
+		}
+	}
+	return false, fmt.Errorf("expected a boolean, got %v", v)
+}
+
+func jsonEnum(e *Enum, v any) (int32, error) {
+	if s, ok := v.(string); ok && e != nil {
+		if n, ok := e.byName[s]; ok {
+			return n, nil
+		}
+	}
+	x, err := jsonInt(v, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unknown enum value %v", v)
+	}
+	return int32(x), nil
+}
+
+// decodeBase64 accepts standard and URL-safe base64, padded or not.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	data, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid base64")
+	}
+	return data, nil
+}
+
+// ----------------------------------------------------------------------------
+// protobuf -> JSON
+// ----------------------------------------------------------------------------
+
+// unmarshal writes encoded message m as JSON.
+func (c *codec) unmarshal(buf *bytes.Buffer, m *Message, data []byte) error {
+	if isWellKnown(m.FullName) {
+		return c.unmarshalWellKnown(buf, m.FullName, data)
+	}
+
+	values := make(map[int32][]wireField)
+	err := forEachField(data, func(f wireField) error {
+		if _, ok := m.byNumber[f.number]; ok {
+			values[f.number] = append(values[f.number], f)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.FullName, err)
+	}
+
+	buf.WriteByte('{')
+	first := true
+	for _, f := range m.Fields {
+		occurrences := values[f.Number]
+		if len(occurrences) == 0 && !c.emitUnpopulated {
+			continue
+		}
+
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		name := f.JSONName
+		if c.useProtoNames {
+			name = f.Name
+		}
+		writeString(buf, name)
+		buf.WriteByte(':')
+
+		if len(occurrences) == 0 {
+			c.writeDefault(buf, f, true)
+			continue
+		}
+		if err := c.writeField(buf, f, occurrences); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// writeField writes every occurrence of a field as one JSON value.
+func (c *codec) writeField(buf *bytes.Buffer, f *Field, occurrences []wireField) error {
+	switch {
+	case f.Message != nil && f.Message.MapEntry:
+		buf.WriteByte('{')
+		for i, wf := range occurrences {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := c.writeMapEntry(buf, f.Message, wf.data); err != nil {
+				return fmt.Errorf("field %s: %w", f.Name, err)
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+
+	case f.Repeated:
+		buf.WriteByte('[')
+		n := 0
+		for _, wf := range occurrences {
+			values := []wireField{wf}
+			if wf.wireType == wireBytes && packable(f.Type) {
+				var err error
+				if values, err = unpack(f.Type, wf.data); err != nil {
+					return fmt.Errorf("field %s: %w", f.Name, err)
+				}
+			}
+			for _, v := range values {
+				if n > 0 {
+					buf.WriteByte(',')
+				}
+				n++
+				if err := c.writeValue(buf, f, v); err != nil {
+					return err
+				}
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+
+	case f.Type == typeMessage:
+		// Repeated occurrences of a message field merge, which for the
+		// wire format is concatenation
+		var merged []byte
+		for _, wf := range occurrences {
+			merged = append(merged, wf.data...)
+		}
+		return c.writeValue(buf, f, wireField{number: f.Number, wireType: wireBytes, data: merged})
+
+	default:
+		// Last one wins for scalars
+		return c.writeValue(buf, f, occurrences[len(occurrences)-1])
+	}
+}
+
+// writeMapEntry writes `"key":value` for one map entry.
+func (c *codec) writeMapEntry(buf *bytes.Buffer, entry *Message, data []byte) error {
+	keyField, valueField := entry.byNumber[1], entry.byNumber[2]
+	if keyField == nil || valueField == nil {
+		return errors.New("invalid map entry")
+	}
+
+	var key, value *wireField
+	err := forEachField(data, func(f wireField) error {
+		switch f.number {
+		case 1:
+			key = &f
+		case 2:
+			value = &f
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keys are always JSON strings
+	var k bytes.Buffer
+	if key == nil {
+		c.writeDefault(&k, keyField, false)
+	} else if err := c.writeValue(&k, keyField, *key); err != nil {
+		return err
+	}
+	keyText := k.String()
+	if !strings.HasPrefix(keyText, `"`) {
+		keyText = `"` + keyText + `"`
+	}
+	buf.WriteString(keyText)
+	buf.WriteByte(':')
+
+	if value == nil {
+		c.writeDefault(buf, valueField, false)
+		return nil
+	}
+	return c.writeValue(buf, valueField, *value)
+}
+
+// unpack splits a packed repeated field into its values.
+func unpack(t int, data []byte) ([]wireField, error) {
+	var values []wireField
+	for len(data) > 0 {
+		var wf wireField
+		switch t {
+		case typeDouble, typeFixed64, typeSfixed64:
+			if len(data) < 8 {
+				return nil, errTruncated
+			}
+			wf = wireField{wireType: wireFixed64, num: uint64(data[0]) | uint64(data[1])<<8 | uint64(data[2])<<16 | uint64(data[3])<<24 |
+				uint64(data[4])<<32 | uint64(data[5])<<40 | uint64(data[6])<<48 | uint64(data[7])<<56}
+			data = data[8:]
+		case typeFloat, typeFixed32, typeSfixed32:
+			if len(data) < 4 {
+				return nil, errTruncated
+			}
+			wf = wireField{wireType: wireFixed32, num: uint64(data[0]) | uint64(data[1])<<8 | uint64(data[2])<<16 | uint64(data[3])<<24}
+			data = data[4:]
+		default:
+			v, n, err := consumeVarint(data)
+			if err != nil {
+				return nil, err
+			}
+			wf = wireField{wireType: wireVarint, num: v}
+			data = data[n:]
+		}
+		values = append(values, wf)
+	}
+	return values, nil
+}
+
+// writeValue writes a single decoded value.
+func (c *codec) writeValue(buf *bytes.Buffer, f *Field, wf wireField) error {
+	want := wireVarint
+	switch f.Type {
+	case typeDouble, typeFixed64, typeSfixed64:
+		want = wireFixed64
+	case typeFloat, typeFixed32, typeSfixed32:
+		want = wireFixed32
+	case typeString, typeBytes, typeMessage:
+		want = wireBytes
+	}
+	if wf.wireType != want {
+		return fmt.Errorf("field %s: wire type %d, want %d", f.Name, wf.wireType, want)
+	}
+
+	switch f.Type {
+	case typeDouble:
+		writeFloat(buf, math.Float64frombits(wf.num), 64)
+	case typeFloat:
+		writeFloat(buf, float64(math.Float32frombits(uint32(wf.num))), 32)
+	case typeInt32:
+		buf.WriteString(strconv.FormatInt(int64(int32(wf.num)), 10))
+	case typeSint32:
+		buf.WriteString(strconv.FormatInt(int64(int32(decodeZigZag(wf.num))), 10))
+	case typeSfixed32:
+		buf.WriteString(strconv.FormatInt(int64(int32(uint32(wf.num))), 10))
+	case typeUint32, typeFixed32:
+		buf.WriteString(strconv.FormatUint(uint64(uint32(wf.num)), 10))
+	case typeInt64, typeSfixed64:
+		buf.WriteString(`"` + strconv.FormatInt(int64(wf.num), 10) + `"`)
+	case typeSint64:
+		buf.WriteString(`"` + strconv.FormatInt(decodeZigZag(wf.num), 10) + `"`)
+	case typeUint64, typeFixed64:
+		buf.WriteString(`"` + strconv.FormatUint(wf.num, 10) + `"`)
+	case typeBool:
+		buf.WriteString(strconv.FormatBool(wf.num != 0))
+	case typeEnum:
+		c.writeEnum(buf, f.Enum, int32(wf.num))
+	case typeString:
+		if !utf8.Valid(wf.data) {
+			return fmt.Errorf("field %s: invalid UTF-8", f.Name)
+		}
+		writeString(buf, string(wf.data))
+	case typeBytes:
+		writeString(buf, base64.StdEncoding.EncodeToString(wf.data))
+	case typeMessage:
+		m := f.Message
+		if m == nil {
+			m = wellKnownMessage(f.TypeName)
+		}
+		return c.unmarshal(buf, m, wf.data)
+	default:
+		return fmt.Errorf("field %s: unsupported type %d", f.Name, f.Type)
+	}
+	return nil
+}
+
+func (c *codec) writeEnum(buf *bytes.Buffer, e *Enum, n int32) {
+	if e == nullValueEnum {
+		buf.WriteString("null")
+		return
+	}
+	if e != nil {
+		if name, ok := e.byNumber[n]; ok {
+			writeString(buf, name)
+			return
+		}
+	}
+	buf.WriteString(strconv.FormatInt(int64(n), 10))
+}
+
+// writeDefault writes the value of an unset field. Unset messages are null
+// as fields but empty objects as map values.
+func (c *codec) writeDefault(buf *bytes.Buffer, f *Field, asField bool) {
+	switch {
+	case f.Message != nil && f.Message.MapEntry:
+		buf.WriteString("{}")
+	case f.Repeated:
+		buf.WriteString("[]")
+	case f.Type == typeMessage:
+		if asField {
+			buf.WriteString("null")
+		} else {
+			buf.WriteString("{}")
+		}
+	case f.Type == typeString, f.Type == typeBytes:
+		buf.WriteString(`""`)
+	case f.Type == typeBool:
+		buf.WriteString("false")
+	case f.Type == typeEnum:
+		c.writeEnum(buf, f.Enum, 0)
+	case f.Type == typeInt64, f.Type == typeSint64, f.Type == typeSfixed64, f.Type == typeUint64, f.Type == typeFixed64:
+		buf.WriteString(`"0"`)
+	default:
+		buf.WriteString("0")
+	}
+}
+
+// writeFloat writes a float, using the proto3 strings for NaN and infinities.
+func writeFloat(buf *bytes.Buffer, x float64, bits int) {
+	switch {
+	case math.IsNaN(x):
+		buf.WriteString(`"NaN"`)
+	case math.IsInf(x, 1):
+		buf.WriteString(`"Infinity"`)
+	case math.IsInf(x, -1):
+		buf.WriteString(`"-Infinity"`)
+	default:
+		buf.WriteString(strconv.FormatFloat(x, 'g', -1, bits))
+	}
+}
+
+// writeString writes s as a JSON string.
+func writeString(buf *bytes.Buffer, s string) {
+	data, _ := json.Marshal(s)
+	buf.Write(data)
+}
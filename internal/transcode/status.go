@@ -0,0 +1,179 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// Status mapping
+// ============================================================================
+
+// gRPC status codes.
+const (
+	codeOK                 = 0
+	codeCanceled           = 1
+	codeUnknown            = 2
+	codeInvalidArgument    = 3
+	codeDeadlineExceeded   = 4
+	codeNotFound           = 5
+	codeAlreadyExists      = 6
+	codePermissionDenied   = 7
+	codeResourceExhausted  = 8
+	codeFailedPrecondition = 9
+	codeAborted            = 10
+	codeOutOfRange         = 11
+	codeUnimplemented      = 12
+	codeInternal           = 13
+	codeUnavailable        = 14
+	codeDataLoss           = 15
+	codeUnauthenticated    = 16
+)
+
+// httpStatusFromCode maps a gRPC status code to an HTTP status, following
+// google.rpc.Code.
+func httpStatusFromCode(code int) int {
+	switch code {
+	case codeOK:
+		return http.StatusOK
+	case codeCanceled:
+		return 499 // client closed request
+	case codeInvalidArgument, codeFailedPrecondition, codeOutOfRange:
+		return http.StatusBadRequest
+	case codeDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codeNotFound:
+		return http.StatusNotFound
+	case codeAlreadyExists, codeAborted:
+		return http.StatusConflict
+	case codePermissionDenied:
+		return http.StatusForbidden
+	case codeResourceExhausted:
+		return http.StatusTooManyRequests
+	case codeUnimplemented:
+		return http.StatusNotImplemented
+	case codeUnavailable:
+		return http.StatusServiceUnavailable
+	case codeUnauthenticated:
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError // Unknown, Internal, DataLoss
+}
+
+// codeFromHTTPStatus maps the HTTP status of a response that isn't gRPC
+// (e.g. from a proxy in front of the backend), as gRPC clients do.
+func codeFromHTTPStatus(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return codeInternal
+	case http.StatusUnauthorized:
+		return codeUnauthenticated
+	case http.StatusForbidden:
+		return codePermissionDenied
+	case http.StatusNotFound:
+		return codeUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codeUnavailable
+	}
+	return codeUnknown
+}
+
+// Error is a transcoding failure, sent to the client as a google.rpc.Status
+// JSON body with the matching HTTP status.
+type Error struct {
+	HTTPStatus int
+	Code       int
+	Message    string
+
+	details []byte // JSON array of google.protobuf.Any, if known
+}
+
+// newError creates an error whose HTTP status follows from code.
+func newError(code int, message string) *Error {
+	return &Error{HTTPStatus: httpStatusFromCode(code), Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return "rpc error: code = " + strconv.Itoa(e.Code) + " desc = " + e.Message
+}
+
+// Body returns the JSON response body.
+func (e *Error) Body() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"code":`)
+	buf.WriteString(strconv.Itoa(e.Code))
+	buf.WriteString(`,"message":`)
+	writeString(&buf, e.Message)
+	buf.WriteString(`,"details":`)
+	if len(e.details) > 0 {
+		buf.Write(e.details)
+	} else {
+		buf.WriteString("[]")
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+// statusFromResponse reads the gRPC status of a response: the trailers, or
+// the headers of a trailers-only response.
+func (c *codec) statusFromResponse(resp *http.Response) (*Error, bool) {
+	header := resp.Trailer
+	if header.Get("Grpc-Status") == "" {
+		header = resp.Header
+	}
+	raw := header.Get("Grpc-Status")
+	if raw == "" {
+		return nil, false
+	}
+
+	code, err := strconv.Atoi(raw)
+	if err != nil {
+		return newError(codeUnknown, "invalid grpc-status "+strconv.Quote(raw)), true
+	}
+
+	// grpc-message is percent-encoded
+	message := header.Get("Grpc-Message")
+	if decoded, err := url.PathUnescape(message); err == nil {
+		message = decoded
+	}
+
+	e := newError(code, message)
+	if bin := header.Get("Grpc-Status-Details-Bin"); bin != "" {
+		e.details = c.statusDetails(bin)
+	}
+	return e, true
+}
+
+// statusDetails renders the details of a serialized google.rpc.Status.
+// Details whose types aren't in the descriptor set are left out.
+func (c *codec) statusDetails(bin string) []byte {
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(bin, "="))
+	if err != nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	n := 0
+	forEachField(data, func(f wireField) error {
+		if f.number != 3 || f.wireType != wireBytes { // Status.details
+			return nil
+		}
+		var detail bytes.Buffer
+		if c.unmarshalWellKnown(&detail, wktAny, f.data) != nil {
+			return nil
+		}
+		if n > 0 {
+			buf.WriteByte(',')
+		}
+		n++
+		buf.Write(detail.Bytes())
+		return nil
+	})
+	buf.WriteByte(']')
+	return buf.Bytes()
+}
@@ -0,0 +1,234 @@
+package transcode
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ============================================================================
+// Path templates
+// ============================================================================
+//
+// google.api.http paths use the template syntax:
+//
+//	Template = "/" Segments [ Verb ] ;
+//	Segments = Segment { "/" Segment } ;
+//	Segment  = "*" | "**" | LITERAL | Variable ;
+//	Variable = "{" FieldPath [ "=" Segments ] "}" ;
+//	Verb     = ":" LITERAL ;
+//
+// e.g. "/v1/{name=shelves/*/books/*}" or "/v1/orders/{order_id}:cancel".
+// "*" matches one segment, "**" any number of trailing segments, and
+// {field} is short for {field=*}.
+
+// Segment kinds.
+const (
+	segmentLiteral = iota
+	segmentWildcard
+	segmentDeepWildcard
+)
+
+type segment struct {
+	kind    int
+	literal string
+}
+
+// templateVar binds segments [start, end) to a field path; end is -1 when
+// the variable ends in "**".
+type templateVar struct {
+	fieldPath string
+	start     int
+	end       int
+}
+
+// pathTemplate is a parsed path template.
+type pathTemplate struct {
+	raw      string
+	segments []segment
+	verb     string
+	vars     []templateVar
+	literals int // literal segment count, used to rank overlapping templates
+}
+
+// parseTemplate parses a google.api.http path template.
+func parseTemplate(raw string) (*pathTemplate, error) {
+	if !strings.HasPrefix(raw, "/") {
+		return nil, fmt.Errorf("path template %q must start with /", raw)
+	}
+	t := &pathTemplate{raw: raw}
+
+	// The verb follows a ':' outside any variable, after the last '/'
+	body := raw[1:]
+	depth, colon := 0, -1
+	for i, c := range body {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '/':
+			if depth == 0 {
+				colon = -1
+			}
+		case ':':
+			if depth == 0 && colon < 0 {
+				colon = i
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("path template %q has unbalanced braces", raw)
+	}
+	if colon >= 0 {
+		t.verb, body = body[colon+1:], body[:colon]
+		if t.verb == "" {
+			return nil, fmt.Errorf("path template %q has an empty verb", raw)
+		}
+	}
+
+	for len(body) > 0 {
+		var part string
+		if strings.HasPrefix(body, "{") {
+			end := strings.IndexByte(body, '}')
+			if end < 0 {
+				return nil, fmt.Errorf("path template %q has unbalanced braces", raw)
+			}
+			part, body = body[:end+1], body[end+1:]
+		} else if i := strings.IndexByte(body, '/'); i >= 0 {
+			part, body = body[:i], body[i:]
+		} else {
+			part, body = body, ""
+		}
+		if err := t.addPart(part); err != nil {
+			return nil, fmt.Errorf("path template %q: %w", raw, err)
+		}
+		if body != "" {
+			if body[0] != '/' || len(body) == 1 {
+				return nil, fmt.Errorf("path template %q: malformed segment near %q", raw, body)
+			}
+			body = body[1:]
+		}
+	}
+
+	for i, s := range t.segments {
+		if s.kind == segmentDeepWildcard && i != len(t.segments)-1 {
+			return nil, fmt.Errorf("path template %q: ** must be the last segment", raw)
+		}
+	}
+	return t, nil
+}
+
+// addPart adds a segment or a variable and its segments.
+func (t *pathTemplate) addPart(part string) error {
+	if !strings.HasPrefix(part, "{") {
+		return t.addSegment(part)
+	}
+
+	fieldPath, pattern, hasPattern := strings.Cut(part[1:len(part)-1], "=")
+	if fieldPath == "" {
+		return fmt.Errorf("empty variable name")
+	}
+	if !hasPattern {
+		pattern = "*"
+	}
+
+	v := templateVar{fieldPath: fieldPath, start: len(t.segments)}
+	for _, s := range strings.Split(pattern, "/") {
+		if err := t.addSegment(s); err != nil {
+			return fmt.Errorf("variable %s: %w", fieldPath, err)
+		}
+	}
+	v.end = len(t.segments)
+	if t.segments[v.end-1].kind == segmentDeepWildcard {
+		v.end = -1
+	}
+	t.vars = append(t.vars, v)
+	return nil
+}
+
+func (t *pathTemplate) addSegment(s string) error {
+	switch {
+	case s == "":
+		return fmt.Errorf("empty segment")
+	case s == "*":
+		t.segments = append(t.segments, segment{kind: segmentWildcard})
+	case s == "**":
+		t.segments = append(t.segments, segment{kind: segmentDeepWildcard})
+	case strings.ContainsAny(s, "{}*"):
+		return fmt.Errorf("invalid segment %q", s)
+	default:
+		t.segments = append(t.segments, segment{kind: segmentLiteral, literal: s})
+		t.literals++
+	}
+	return nil
+}
+
+// match matches an escaped request path, returning the variable bindings.
+func (t *pathTemplate) match(escapedPath string) (map[string]string, bool) {
+	if !strings.HasPrefix(escapedPath, "/") {
+		return nil, false
+	}
+	path := escapedPath[1:]
+	if t.verb != "" {
+		var ok bool
+		if path, ok = strings.CutSuffix(path, ":"+t.verb); !ok {
+			return nil, false
+		}
+	}
+
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+
+	for i, s := range t.segments {
+		switch s.kind {
+		case segmentDeepWildcard:
+			// matches everything that is left
+		case segmentWildcard:
+			if i >= len(parts) || parts[i] == "" {
+				return nil, false
+			}
+		case segmentLiteral:
+			if i >= len(parts) || unescape(parts[i]) != s.literal {
+				return nil, false
+			}
+		}
+	}
+	deep := len(t.segments) > 0 && t.segments[len(t.segments)-1].kind == segmentDeepWildcard
+	if !deep && len(parts) != len(t.segments) {
+		return nil, false
+	}
+
+	bindings := make(map[string]string, len(t.vars))
+	for _, v := range t.vars {
+		end := v.end
+		if end < 0 {
+			end = len(parts)
+		}
+		if end-v.start == 1 {
+			// A single segment is fully decoded, "%2F" included
+			bindings[v.fieldPath] = unescape(parts[v.start])
+			continue
+		}
+		// Across segments an escaped slash stays escaped, so it can be
+		// told apart from a separator
+		values := make([]string, 0, end-v.start)
+		for _, p := range parts[v.start:end] {
+			values = append(values, unescape(escapedSlash.Replace(p)))
+		}
+		bindings[v.fieldPath] = strings.Join(values, "/")
+	}
+	return bindings, true
+}
+
+var escapedSlash = strings.NewReplacer("%2F", "%252F", "%2f", "%252F")
+
+// unescape percent-decodes a path segment, leaving it as is if malformed.
+func unescape(s string) string {
+	if u, err := url.PathUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
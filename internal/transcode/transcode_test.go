@@ -0,0 +1,541 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Descriptor set for:
+//
+//	package shop.v1;
+//
+//	enum Status { STATUS_UNSPECIFIED = 0; SHIPPED = 1; }
+//	message Item { string sku = 1; int32 quantity = 2; }
+//	message Order {
+//	  int64 order_id = 1;
+//	  Status status = 2;
+//	  repeated Item items = 3;
+//	  map<string, string> labels = 4;
+//	  google.protobuf.Timestamp created_at = 5;
+//	  repeated int32 tags = 6;
+//	}
+//	message GetOrderRequest { int64 order_id = 1; bool include_items = 2; }
+//	message CreateOrderRequest { string parent = 1; Order order = 2; }
+//
+//	service Orders {
+//	  rpc GetOrder(GetOrderRequest) returns (Order) {
+//	    option (google.api.http) = { get: "/v1/orders/{order_id}" };
+//	  }
+//	  rpc CreateOrder(CreateOrderRequest) returns (Order) {
+//	    option (google.api.http) = {
+//	      post: "/v1/{parent=shops/*}/orders" body: "order"
+//	      additional_bindings { put: "/v1/orders" body: "*" }
+//	    };
+//	  }
+//	  rpc Watch(GetOrderRequest) returns (stream Order);
+//	}
+func testDescriptorSet() []byte {
+	str := func(number int32, s string) []byte { return appendLengthDelimited(nil, number, []byte(s)) }
+	num := func(number int32, v uint64) []byte { return appendVarint(appendTag(nil, number, wireVarint), v) }
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	field := func(name string, number int32, typ int, typeName string, repeated bool) []byte {
+		label := uint64(1)
+		if repeated {
+			label = labelRepeated
+		}
+		b := join(str(1, name), num(3, uint64(number)), num(4, label), num(5, uint64(typ)))
+		if typeName != "" {
+			b = append(b, str(6, typeName)...)
+		}
+		return appendLengthDelimited(nil, 2, b)
+	}
+	message := func(name string, parts ...[]byte) []byte {
+		return appendLengthDelimited(nil, 4, join(str(1, name), join(parts...)))
+	}
+	method := func(name, input, output string, serverStreaming bool, rule []byte) []byte {
+		b := join(str(1, name), str(2, input), str(3, output))
+		if rule != nil {
+			b = append(b, appendLengthDelimited(nil, 4, appendLengthDelimited(nil, httpRuleExtension, rule))...)
+		}
+		if serverStreaming {
+			b = append(b, num(6, 1)...)
+		}
+		return appendLengthDelimited(nil, 2, b)
+	}
+
+	labelsEntry := appendLengthDelimited(nil, 3, join(
+		str(1, "LabelsEntry"),
+		field("key", 1, typeString, "", false),
+		field("value", 2, typeString, "", false),
+		appendLengthDelimited(nil, 7, num(7, 1)), // map_entry
+	))
+
+	file := join(
+		str(1, "shop/v1/orders.proto"),
+		str(2, "shop.v1"),
+		message("Item",
+			field("sku", 1, typeString, "", false),
+			field("quantity", 2, typeInt32, "", false),
+		),
+		message("Order",
+			field("order_id", 1, typeInt64, "", false),
+			field("status", 2, typeEnum, ".shop.v1.Status", false),
+			field("items", 3, typeMessage, ".shop.v1.Item", true),
+			field("labels", 4, typeMessage, ".shop.v1.Order.LabelsEntry", true),
+			field("created_at", 5, typeMessage, ".google.protobuf.Timestamp", false),
+			field("tags", 6, typeInt32, "", true),
+			labelsEntry,
+		),
+		message("GetOrderRequest",
+			field("order_id", 1, typeInt64, "", false),
+			field("include_items", 2, typeBool, "", false),
+		),
+		message("CreateOrderRequest",
+			field("parent", 1, typeString, "", false),
+			field("order", 2, typeMessage, ".shop.v1.Order", false),
+		),
+		appendLengthDelimited(nil, 5, join(
+			str(1, "Status"),
+			appendLengthDelimited(nil, 2, join(str(1, "STATUS_UNSPECIFIED"), num(2, 0))),
+			appendLengthDelimited(nil, 2, join(str(1, "SHIPPED"), num(2, 1))),
+		)),
+		appendLengthDelimited(nil, 6, join(
+			str(1, "Orders"),
+			method("GetOrder", ".shop.v1.GetOrderRequest", ".shop.v1.Order", false,
+				str(2, "/v1/orders/{order_id}")),
+			method("CreateOrder", ".shop.v1.CreateOrderRequest", ".shop.v1.Order", false, join(
+				str(4, "/v1/{parent=shops/*}/orders"),
+				str(7, "order"),
+				appendLengthDelimited(nil, 11, join(str(3, "/v1/orders"), str(7, "*"))),
+			)),
+			method("Watch", ".shop.v1.GetOrderRequest", ".shop.v1.Order", true, nil),
+		)),
+	)
+	return appendLengthDelimited(nil, 1, file)
+}
+
+func newTestTranscoder(t *testing.T, config Config) *Transcoder {
+	t.Helper()
+	tc, err := New(testDescriptorSet(), config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return tc
+}
+
+func TestWire_RoundTrip(t *testing.T) {
+	var b []byte
+	b = appendVarint(appendTag(b, 1, wireVarint), 300)
+	b = appendLengthDelimited(b, 2, []byte("hello"))
+	b = appendFixed32(appendTag(b, 3, wireFixed32), 7)
+	b = appendFixed64(appendTag(b, 4, wireFixed64), 1<<40)
+	b = appendVarint(appendTag(b, 5, wireVarint), encodeZigZag(-3))
+
+	var got []wireField
+	if err := forEachField(b, func(f wireField) error {
+		got = append(got, f)
+		return nil
+	}); err != nil {
+		t.Fatalf("forEachField() error = %v", err)
+	}
+
+	if len(got) != 5 {
+		t.Fatalf("got %d fields, want 5", len(got))
+	}
+	if got[0].num != 300 || string(got[1].data) != "hello" || got[2].num != 7 || got[3].num != 1<<40 {
+		t.Errorf("fields = %+v", got)
+	}
+	if decodeZigZag(got[4].num) != -3 {
+		t.Errorf("zigzag = %d, want -3", decodeZigZag(got[4].num))
+	}
+
+	if err := forEachField(b[:len(b)-1], func(wireField) error { return nil }); err == nil {
+		t.Error("truncated message: expected an error")
+	}
+}
+
+func TestParseDescriptorSet(t *testing.T) {
+	d, err := ParseDescriptorSet(testDescriptorSet())
+	if err != nil {
+		t.Fatalf("ParseDescriptorSet() error = %v", err)
+	}
+
+	order := d.Messages["shop.v1.Order"]
+	if order == nil {
+		t.Fatal("shop.v1.Order not found")
+	}
+	if f := order.byName["orderId"]; f == nil || f.Name != "order_id" {
+		t.Errorf("orderId field = %+v", f)
+	}
+	if f := order.byName["labels"]; f.Message == nil || !f.Message.MapEntry {
+		t.Errorf("labels should resolve to a map entry, got %+v", f.Message)
+	}
+	if f := order.byName["status"]; f.Enum == nil || f.Enum.byNumber[1] != "SHIPPED" {
+		t.Errorf("status enum = %+v", f.Enum)
+	}
+
+	if len(d.Methods) != 3 {
+		t.Fatalf("got %d methods, want 3", len(d.Methods))
+	}
+	create := d.Methods[1]
+	if create.FullMethod() != "/shop.v1.Orders/CreateOrder" {
+		t.Errorf("FullMethod() = %q", create.FullMethod())
+	}
+	want := []HTTPRule{
+		{Method: "POST", Path: "/v1/{parent=shops/*}/orders", Body: "order"},
+		{Method: "PUT", Path: "/v1/orders", Body: "*"},
+	}
+	if len(create.Rules) != len(want) || create.Rules[0] != want[0] || create.Rules[1] != want[1] {
+		t.Errorf("rules = %+v, want %+v", create.Rules, want)
+	}
+	if !d.Methods[2].ServerStreaming {
+		t.Error("Watch should be server streaming")
+	}
+}
+
+func TestTemplate_Match(t *testing.T) {
+	tests := []struct {
+		template string
+		path     string
+		want     map[string]string // nil = no match
+	}{
+		{"/v1/orders/{order_id}", "/v1/orders/42", map[string]string{"order_id": "42"}},
+		{"/v1/orders/{order_id}", "/v1/orders/42/items", nil},
+		{"/v1/orders/{order_id}", "/v1/orders/a%2Fb", map[string]string{"order_id": "a/b"}},
+		{"/v1/{name=shelves/*/books/*}", "/v1/shelves/1/books/2", map[string]string{"name": "shelves/1/books/2"}},
+		{"/v1/{name=shelves/*/books/*}", "/v1/shelves/1/authors/2", nil},
+		{"/v1/{name=files/**}", "/v1/files/a/b/c", map[string]string{"name": "files/a/b/c"}},
+		{"/v1/orders/{id}:cancel", "/v1/orders/7:cancel", map[string]string{"id": "7"}},
+		{"/v1/orders/{id}:cancel", "/v1/orders/7", nil},
+		{"/v1/*/status", "/v1/anything/status", map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := parseTemplate(tt.template)
+		if err != nil {
+			t.Fatalf("parseTemplate(%q) error = %v", tt.template, err)
+		}
+		got, ok := tmpl.match(tt.path)
+		if ok != (tt.want != nil) {
+			t.Errorf("%s match %s = %v, want %v", tt.template, tt.path, ok, tt.want != nil)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%s match %s: %s = %q, want %q", tt.template, tt.path, k, got[k], v)
+			}
+		}
+	}
+
+	for _, bad := range []string{"v1/orders", "/v1/{id", "/v1/**/x", "/v1//x", "/v1/x:"} {
+		if _, err := parseTemplate(bad); err == nil {
+			t.Errorf("parseTemplate(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	d, err := ParseDescriptorSet(testDescriptorSet())
+	if err != nil {
+		t.Fatalf("ParseDescriptorSet() error = %v", err)
+	}
+	c := &codec{types: d}
+	order := d.Messages["shop.v1.Order"]
+
+	in := `{"orderId":"9007199254740993","status":"SHIPPED","items":[{"sku":"A-1","quantity":2}],` +
+		`"labels":{"b":"2","a":"1"},"createdAt":"2024-05-01T10:00:00.500Z","tags":[1,-2,3]}`
+	value, err := decodeJSON([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.marshal(order, value)
+	if err != nil {
+		t.Fatalf("marshal() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := c.unmarshal(&out, order, data); err != nil {
+		t.Fatalf("unmarshal() error = %v", err)
+	}
+	want := `{"orderId":"9007199254740993","status":"SHIPPED","items":[{"sku":"A-1","quantity":2}],` +
+		`"labels":{"a":"1","b":"2"},"createdAt":"2024-05-01T10:00:00.500Z","tags":[1,-2,3]}`
+	if out.String() != want {
+		t.Errorf("round trip =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	// Proto names, numbers as strings and enum numbers are accepted too
+	value, _ = decodeJSON([]byte(`{"order_id":5,"status":1,"tags":["4"]}`))
+	if data, err = c.marshal(order, value); err != nil {
+		t.Fatalf("marshal() error = %v", err)
+	}
+	out.Reset()
+	c.useProtoNames = true
+	c.emitUnpopulated = true
+	if err := c.unmarshal(&out, order, data); err != nil {
+		t.Fatalf("unmarshal() error = %v", err)
+	}
+	want = `{"order_id":"5","status":"SHIPPED","items":[],"labels":{},"created_at":null,"tags":[4]}`
+	if out.String() != want {
+		t.Errorf("unmarshal() =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	for _, bad := range []string{`{"unknown":1}`, `{"orderId":"x"}`, `{"status":"LOST"}`, `{"items":{}}`, `[]`} {
+		value, _ := decodeJSON([]byte(bad))
+		if _, err := c.marshal(order, value); err == nil {
+			t.Errorf("marshal(%s): expected an error", bad)
+		}
+	}
+}
+
+func TestCodec_WellKnownTypes(t *testing.T) {
+	c := &codec{}
+	tests := []struct {
+		name string
+		json string
+	}{
+		{wktTimestamp, `"1970-01-01T00:00:01Z"`},
+		{wktDuration, `"-1.500s"`},
+		{wktStruct, `{"a":[1,"x",true,null],"b":{"c":2.5}}`},
+		{wktFieldMask, `"orderId,items.sku"`},
+		{"google.protobuf.Int64Value", `"12"`},
+		{"google.protobuf.StringValue", `"hi"`},
+		{wktEmpty, `{}`},
+	}
+
+	for _, tt := range tests {
+		value, err := decodeJSON([]byte(tt.json))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := c.marshalWellKnown(tt.name, value)
+		if err != nil {
+			t.Errorf("%s: marshal(%s) error = %v", tt.name, tt.json, err)
+			continue
+		}
+		var out bytes.Buffer
+		if err := c.unmarshalWellKnown(&out, tt.name, data); err != nil {
+			t.Errorf("%s: unmarshal error = %v", tt.name, err)
+			continue
+		}
+		if out.String() != tt.json {
+			t.Errorf("%s: round trip = %s, want %s", tt.name, out.String(), tt.json)
+		}
+	}
+}
+
+func TestTranscoder_Prepare(t *testing.T) {
+	tc := newTestTranscoder(t, Config{PathPrefix: "/api"})
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantMethod string
+		wantStatus int    // 0 = success
+		wantInput  string // JSON of the encoded input
+	}{
+		{
+			name: "path and query", method: "GET", target: "/api/v1/orders/42?include_items=true",
+			wantMethod: "/shop.v1.Orders/GetOrder", wantInput: `{"orderId":"42","includeItems":true}`,
+		},
+		{
+			name: "body field and path", method: "POST", target: "/api/v1/shops/s1/orders",
+			body:       `{"orderId":"7","tags":[1]}`,
+			wantMethod: "/shop.v1.Orders/CreateOrder", wantInput: `{"parent":"shops/s1","order":{"orderId":"7","tags":[1]}}`,
+		},
+		{
+			name: "additional binding", method: "PUT", target: "/api/v1/orders",
+			body:       `{"parent":"shops/s2"}`,
+			wantMethod: "/shop.v1.Orders/CreateOrder", wantInput: `{"parent":"shops/s2"}`,
+		},
+		{
+			name: "default binding", method: "POST", target: "/api/shop.v1.Orders/GetOrder",
+			body:       `{"orderId":"3"}`,
+			wantMethod: "/shop.v1.Orders/GetOrder", wantInput: `{"orderId":"3"}`,
+		},
+		{name: "streaming methods are skipped", method: "POST", target: "/api/shop.v1.Orders/Watch", wantStatus: 404},
+		{name: "outside the prefix", method: "GET", target: "/v1/orders/42", wantStatus: 404},
+		{name: "wrong method", method: "DELETE", target: "/api/v1/orders/42", wantStatus: 405},
+		{name: "invalid path value", method: "GET", target: "/api/v1/orders/abc", wantStatus: 400},
+		{name: "unknown query parameter", method: "GET", target: "/api/v1/orders/1?color=red", wantStatus: 400},
+		{name: "invalid JSON", method: "PUT", target: "/api/v1/orders", body: `{`, wantStatus: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			call, err := tc.Prepare(r)
+
+			if tt.wantStatus != 0 {
+				rpcErr, ok := err.(*Error)
+				if !ok {
+					t.Fatalf("Prepare() error = %v, want *Error", err)
+				}
+				if rpcErr.HTTPStatus != tt.wantStatus {
+					t.Errorf("HTTPStatus = %d, want %d (%s)", rpcErr.HTTPStatus, tt.wantStatus, rpcErr.Message)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Prepare() error = %v", err)
+			}
+			if call.Method.FullMethod() != tt.wantMethod {
+				t.Errorf("method = %s, want %s", call.Method.FullMethod(), tt.wantMethod)
+			}
+
+			var input bytes.Buffer
+			if err := tc.codec.unmarshal(&input, call.Method.Input, call.payload); err != nil {
+				t.Fatal(err)
+			}
+			if input.String() != tt.wantInput {
+				t.Errorf("input = %s, want %s", input.String(), tt.wantInput)
+			}
+		})
+	}
+}
+
+// fakeOrders is a unary gRPC server for shop.v1.Orders over h2c.
+func fakeOrders(t *testing.T, handler func(method string, input []byte, w http.ResponseWriter)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.Header.Get("Content-Type") != "application/grpc" {
+			http.Error(w, "not a gRPC request", http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		if len(data) < 5 || int(binary.BigEndian.Uint32(data[1:5])) != len(data)-5 {
+			http.Error(w, "bad frame", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		handler(r.URL.Path, data[5:], w)
+	}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// roundTrip prepares, sends and translates one request like the proxy does.
+func roundTrip(t *testing.T, tc *Transcoder, srv *httptest.Server, method, target, body string) (*http.Response, string) {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	call, err := tc.Prepare(r)
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+
+	upstream, _ := http.NewRequest(method, srv.URL+"/ignored?q=1", nil)
+	if err := call.Rewrite(upstream); err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	defer transport.CloseIdleConnections()
+
+	resp, err := transport.RoundTrip(upstream)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	if err := call.Translate(resp); err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	out, _ := io.ReadAll(resp.Body)
+	return resp, string(out)
+}
+
+func TestTranscoder_EndToEnd(t *testing.T) {
+	tc := newTestTranscoder(t, Config{})
+
+	srv := fakeOrders(t, func(method string, input []byte, w http.ResponseWriter) {
+		if method != "/shop.v1.Orders/GetOrder" {
+			w.Header().Set("Grpc-Status", "12")
+			return
+		}
+		var id uint64
+		forEachField(input, func(f wireField) error {
+			if f.number == 1 {
+				id = f.num
+			}
+			return nil
+		})
+		if id == 404 {
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", url.PathEscape("order 404 not found"))
+			return
+		}
+
+		msg := appendVarint(appendTag(nil, 1, wireVarint), id)
+		msg = appendVarint(appendTag(msg, 2, wireVarint), 1)
+		frame := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg)))
+		w.Write(append(frame, msg...))
+		w.Header().Set("Grpc-Status", "0")
+	})
+
+	resp, body := roundTrip(t, tc, srv, "GET", "/v1/orders/42", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body %s", resp.StatusCode, body)
+	}
+	if body != `{"orderId":"42","status":"SHIPPED"}` {
+		t.Errorf("body = %s", body)
+	}
+	if resp.Header.Get("Content-Type") != "application/json" || resp.Header.Get("Grpc-Status") != "" {
+		t.Errorf("headers = %v", resp.Header)
+	}
+
+	resp, body = roundTrip(t, tc, srv, "GET", "/v1/orders/404", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+	var status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(body), &status); err != nil || status.Code != 5 || status.Message != "order 404 not found" {
+		t.Errorf("error body = %s (%v)", body, err)
+	}
+
+	resp, _ = roundTrip(t, tc, srv, "PUT", "/v1/orders", `{}`)
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("status = %d, want 501", resp.StatusCode)
+	}
+}
+
+func TestCall_TranslateNonGRPCResponse(t *testing.T) {
+	tc := newTestTranscoder(t, Config{})
+	call, err := tc.Prepare(httptest.NewRequest("GET", "/v1/orders/1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &http.Response{
+		StatusCode: http.StatusBadGateway,
+		Header:     http.Header{"Content-Type": []string{"text/html"}},
+		Body:       io.NopCloser(strings.NewReader("<html>bad gateway</html>")),
+	}
+	if err := call.Translate(resp); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusServiceUnavailable || !strings.Contains(string(body), `"code":14`) {
+		t.Errorf("status = %d, body = %s", resp.StatusCode, body)
+	}
+}
+
+func TestNew_UnknownService(t *testing.T) {
+	if _, err := New(testDescriptorSet(), Config{Services: []string{"shop.v1.Missing"}}); err == nil {
+		t.Error("expected an error for an unknown service")
+	}
+}
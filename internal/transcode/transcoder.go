@@ -0,0 +1,509 @@
+// Package transcode translates REST/JSON requests to unary gRPC calls.
+//
+// A Transcoder is built from a compiled descriptor set (protoc
+// --descriptor_set_out). Each method's google.api.http annotation maps an
+// HTTP method and path template to it, and every unary method is also
+// reachable as POST /<package>.<Service>/<Method> with the request message
+// as the JSON body:
+//
+//	rpc GetOrder(GetOrderRequest) returns (Order) {
+//	  option (google.api.http) = { get: "/v1/orders/{order_id}" };
+//	}
+//
+// Prepare matches a request and encodes its JSON body, path variables and
+// query parameters as the input message. The Call it returns rewrites the
+// upstream request as a gRPC call (Rewrite) and turns the gRPC response
+// back into JSON with the HTTP status of its gRPC status (Translate).
+//
+// Everything is implemented on the wire format; no generated code or
+// protobuf runtime is needed. Streaming methods are not supported.
+package transcode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxBodyBytes limits request and response bodies.
+const DefaultMaxBodyBytes = 4 << 20
+
+// Config configures a Transcoder.
+type Config struct {
+	// Services limits transcoding to these fully qualified services
+	// (empty = every service in the descriptor set)
+	Services []string
+
+	// PathPrefix is stripped from request paths before matching, e.g.
+	// "/api" for a route on /api/*
+	PathPrefix string
+
+	// UseProtoNames writes proto field names (order_id) instead of JSON
+	// names (orderId) in responses
+	UseProtoNames bool
+
+	// EmitUnpopulated writes fields left at their default value
+	EmitUnpopulated bool
+
+	// MaxBodyBytes limits request and response bodies
+	// Default: 4 MB
+	MaxBodyBytes int64
+}
+
+// Transcoder maps HTTP requests to the methods of a descriptor set.
+type Transcoder struct {
+	codec      codec
+	bindings   []*binding
+	pathPrefix string
+	maxBody    int64
+}
+
+// binding is one HTTP rule of a method.
+type binding struct {
+	method        *Method
+	httpMethod    string
+	template      *pathTemplate
+	body          string // "*", a field name or ""
+	responseField *Field // nil = whole output message
+}
+
+// New creates a Transcoder from a serialized FileDescriptorSet.
+func New(descriptorSet []byte, config Config) (*Transcoder, error) {
+	types, err := ParseDescriptorSet(descriptorSet)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	t := &Transcoder{
+		codec: codec{
+			types:           types,
+			useProtoNames:   config.UseProtoNames,
+			emitUnpopulated: config.EmitUnpopulated,
+		},
+		pathPrefix: strings.TrimSuffix(config.PathPrefix, "/"),
+		maxBody:    config.MaxBodyBytes,
+	}
+
+	wanted := make(map[string]bool, len(config.Services))
+	for _, s := range config.Services {
+		wanted[s] = false
+	}
+
+	for _, m := range types.Methods {
+		if _, ok := wanted[m.Service]; !ok && len(config.Services) > 0 {
+			continue
+		}
+		wanted[m.Service] = true
+		if m.ClientStreaming || m.ServerStreaming {
+			continue
+		}
+
+		rules := append([]HTTPRule{}, m.Rules...)
+		rules = append(rules, HTTPRule{Method: http.MethodPost, Path: m.FullMethod(), Body: "*"})
+		for _, rule := range rules {
+			b, err := newBinding(m, rule)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", m.FullMethod(), err)
+			}
+			t.bindings = append(t.bindings, b)
+		}
+	}
+
+	for _, s := range config.Services {
+		if !wanted[s] {
+			return nil, fmt.Errorf("service %s not found in descriptor set", s)
+		}
+	}
+	if len(t.bindings) == 0 {
+		return nil, errors.New("descriptor set has no unary methods")
+	}
+
+	// Most specific template first
+	sort.SliceStable(t.bindings, func(i, j int) bool {
+		return t.bindings[i].template.literals > t.bindings[j].template.literals
+	})
+	return t, nil
+}
+
+// newBinding validates an HTTP rule against the method's messages.
+func newBinding(m *Method, rule HTTPRule) (*binding, error) {
+	template, err := parseTemplate(rule.Path)
+	if err != nil {
+		return nil, err
+	}
+	b := &binding{
+		method:     m,
+		httpMethod: strings.ToUpper(rule.Method),
+		template:   template,
+		body:       rule.Body,
+	}
+
+	for _, v := range template.vars {
+		if _, err := lookupField(m.Input, v.fieldPath); err != nil {
+			return nil, fmt.Errorf("path %s: %w", rule.Path, err)
+		}
+	}
+	if b.body != "" && b.body != "*" {
+		if _, err := lookupField(m.Input, b.body); err != nil {
+			return nil, fmt.Errorf("body: %w", err)
+		}
+	}
+	if rule.ResponseBody != "" {
+		f, ok := m.Output.byName[rule.ResponseBody]
+		if !ok {
+			return nil, fmt.Errorf("response_body: unknown field %q", rule.ResponseBody)
+		}
+		b.responseField = f
+	}
+	return b, nil
+}
+
+// lookupField resolves a dotted field path ("order.id") in m.
+func lookupField(m *Message, path string) (*Field, error) {
+	var f *Field
+	for _, name := range strings.Split(path, ".") {
+		if m == nil {
+			return nil, fmt.Errorf("field %q: %s is not a message", path, f.Name)
+		}
+		var ok bool
+		if f, ok = m.byName[name]; !ok {
+			return nil, fmt.Errorf("unknown field %q", path)
+		}
+		m = nil
+		if !f.Repeated {
+			m = f.Message
+		}
+	}
+	return f, nil
+}
+
+// Call is a matched request, encoded for its method.
+type Call struct {
+	// Method is the gRPC method being called
+	Method *Method
+
+	payload []byte
+	binding *binding
+	codec   *codec
+	maxBody int64
+}
+
+// Prepare matches r to a method and encodes its input message. It reads
+// r's body. Failures are *Error values: 404 when nothing matches, 400 for
+// invalid input.
+func (t *Transcoder) Prepare(r *http.Request) (*Call, error) {
+	path := r.URL.EscapedPath()
+	if t.pathPrefix != "" {
+		rest, ok := strings.CutPrefix(path, t.pathPrefix)
+		if !ok || (rest != "" && rest[0] != '/') {
+			return nil, newError(codeNotFound, "no method is mapped to "+r.URL.Path)
+		}
+		path = rest
+	}
+
+	var b *binding
+	var bindings map[string]string
+	pathMatched := false
+	for _, candidate := range t.bindings {
+		vars, ok := candidate.template.match(path)
+		if !ok {
+			continue
+		}
+		pathMatched = true
+		if candidate.httpMethod == r.Method {
+			b, bindings = candidate, vars
+			break
+		}
+	}
+	if b == nil {
+		if pathMatched {
+			e := newError(codeUnimplemented, "method "+r.Method+" is not mapped to "+r.URL.Path)
+			e.HTTPStatus = http.StatusMethodNotAllowed
+			return nil, e
+		}
+		return nil, newError(codeNotFound, "no method is mapped to "+r.URL.Path)
+	}
+
+	input, err := t.requestMessage(r, b, bindings)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := t.codec.marshal(b.method.Input, input)
+	if err != nil {
+		return nil, newError(codeInvalidArgument, err.Error())
+	}
+
+	return &Call{
+		Method:  b.method,
+		payload: payload,
+		binding: b,
+		codec:   &t.codec,
+		maxBody: t.maxBody,
+	}, nil
+}
+
+// requestMessage builds the input message's JSON from the body, path
+// variables and query parameters.
+func (t *Transcoder) requestMessage(r *http.Request, b *binding, vars map[string]string) (any, error) {
+	var body any
+	if b.body != "" && r.Body != nil {
+		data, err := io.ReadAll(io.LimitReader(r.Body, t.maxBody+1))
+		if err != nil {
+			return nil, newError(codeInvalidArgument, "failed to read request body")
+		}
+		if int64(len(data)) > t.maxBody {
+			e := newError(codeInvalidArgument, "request body too large")
+			e.HTTPStatus = http.StatusRequestEntityTooLarge
+			return nil, e
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			if body, err = decodeJSON(data); err != nil {
+				return nil, newError(codeInvalidArgument, "invalid JSON body: "+err.Error())
+			}
+		}
+	}
+
+	input := b.method.Input
+	if isWellKnown(input.FullName) {
+		// e.g. google.protobuf.Empty; there are no fields to bind
+		if body == nil {
+			body = map[string]any{}
+		}
+		return body, nil
+	}
+
+	msg := map[string]any{}
+	switch b.body {
+	case "*":
+		if body != nil {
+			obj, ok := body.(map[string]any)
+			if !ok {
+				return nil, newError(codeInvalidArgument, "request body must be a JSON object")
+			}
+			msg = obj
+		}
+	case "":
+	default:
+		if body != nil {
+			if err := setField(msg, input, b.body, body); err != nil {
+				return nil, newError(codeInvalidArgument, err.Error())
+			}
+		}
+	}
+
+	// With body "*" every field comes from the body; otherwise query
+	// parameters fill the fields not bound by the path or the body
+	if b.body != "*" {
+		query := r.URL.Query()
+		keys := make([]string, 0, len(query))
+		for k := range query {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			f, err := lookupField(input, k)
+			if err != nil {
+				return nil, newError(codeInvalidArgument, "query parameter "+strconv.Quote(k)+": "+err.Error())
+			}
+			values := query[k]
+			var value any = values[len(values)-1]
+			if f.Repeated && (f.Message == nil || !f.Message.MapEntry) {
+				list := make([]any, len(values))
+				for i, v := range values {
+					list[i] = v
+				}
+				value = list
+			}
+			if err := setField(msg, input, k, value); err != nil {
+				return nil, newError(codeInvalidArgument, err.Error())
+			}
+		}
+	}
+
+	// Path variables win over the body and query
+	for fieldPath, value := range vars {
+		if err := setField(msg, input, fieldPath, value); err != nil {
+			return nil, newError(codeInvalidArgument, err.Error())
+		}
+	}
+	return msg, nil
+}
+
+// setField sets a dotted field path in a JSON object, normalizing the
+// field to its proto name so a value the body set under its JSON name is
+// replaced rather than duplicated.
+func setField(obj map[string]any, m *Message, path string, value any) error {
+	name, rest, nested := strings.Cut(path, ".")
+	f, ok := m.byName[name]
+	if !ok {
+		return fmt.Errorf("unknown field %q", path)
+	}
+
+	existing, ok := obj[f.Name]
+	if alt, altOK := obj[f.JSONName]; altOK && f.JSONName != f.Name {
+		if !ok {
+			existing = alt
+		}
+		delete(obj, f.JSONName)
+	}
+
+	if !nested {
+		obj[f.Name] = value
+		return nil
+	}
+	if f.Message == nil || f.Repeated {
+		return fmt.Errorf("field %q: %s is not a message", path, f.Name)
+	}
+	child, _ := existing.(map[string]any)
+	if child == nil {
+		child = map[string]any{}
+	}
+	obj[f.Name] = child
+	return setField(child, f.Message, rest, value)
+}
+
+// Rewrite turns the upstream request into the gRPC call.
+func (c *Call) Rewrite(req *http.Request) error {
+	frame := make([]byte, 5, 5+len(c.payload))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(c.payload)))
+	frame = append(frame, c.payload...)
+
+	req.Method = http.MethodPost
+	req.URL.Path = c.Method.FullMethod()
+	req.URL.RawPath = ""
+	req.URL.RawQuery = ""
+
+	req.Header.Del("Content-Length")
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Accept-Encoding")
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+	req.Header.Set("Grpc-Accept-Encoding", "identity,gzip")
+
+	req.Body = io.NopCloser(bytes.NewReader(frame))
+	req.ContentLength = int64(len(frame))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(frame)), nil
+	}
+	return nil
+}
+
+// Translate replaces the gRPC response with its JSON form. A gRPC error
+// becomes a google.rpc.Status body with the matching HTTP status.
+func (c *Call) Translate(resp *http.Response) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, c.maxBody+1))
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read gRPC response: %w", err)
+	}
+
+	var body []byte
+	status, ok := c.codec.statusFromResponse(resp)
+	switch {
+	case !ok && !isGRPC(resp):
+		// Not from a gRPC server, e.g. a load balancer error page
+		status = newError(codeFromHTTPStatus(resp.StatusCode), "upstream returned HTTP "+strconv.Itoa(resp.StatusCode))
+	case !ok:
+		status = newError(codeInternal, "gRPC response has no grpc-status")
+	case int64(len(data)) > c.maxBody:
+		status = newError(codeResourceExhausted, "gRPC response too large")
+	case status.Code == codeOK:
+		if body, err = c.responseBody(resp, data); err != nil {
+			status = newError(codeInternal, err.Error())
+		}
+	}
+
+	resp.StatusCode = http.StatusOK
+	if status.Code != codeOK {
+		resp.StatusCode = status.HTTPStatus
+		body = status.Body()
+	}
+	resp.Status = strconv.Itoa(resp.StatusCode) + " " + http.StatusText(resp.StatusCode)
+
+	for key := range resp.Header {
+		if strings.HasPrefix(strings.ToLower(key), "grpc-") {
+			resp.Header.Del(key)
+		}
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Trailer")
+	resp.Header.Set("Content-Type", "application/json")
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.Trailer = nil
+	resp.ContentLength = int64(len(body))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// isGRPC reports whether resp came from a gRPC server.
+func isGRPC(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "application/grpc")
+}
+
+// responseBody decodes the single response message as JSON.
+func (c *Call) responseBody(resp *http.Response, data []byte) ([]byte, error) {
+	if len(data) < 5 {
+		return nil, errors.New("gRPC response has no message")
+	}
+	compressed := data[0] == 1
+	length := binary.BigEndian.Uint32(data[1:5])
+	if uint64(length) != uint64(len(data)-5) {
+		return nil, errors.New("gRPC response must contain exactly one message")
+	}
+	message := data[5:]
+
+	if compressed {
+		if encoding := resp.Header.Get("Grpc-Encoding"); encoding != "gzip" {
+			return nil, fmt.Errorf("unsupported grpc-encoding %q", encoding)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(message))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip message: %w", err)
+		}
+		if message, err = io.ReadAll(io.LimitReader(zr, c.maxBody+1)); err != nil {
+			return nil, fmt.Errorf("invalid gzip message: %w", err)
+		}
+		if int64(len(message)) > c.maxBody {
+			return nil, errors.New("gRPC response too large")
+		}
+	}
+
+	var buf bytes.Buffer
+	output := c.Method.Output
+	f := c.binding.responseField
+	if f == nil {
+		if err := c.codec.unmarshal(&buf, output, message); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	// response_body: send just one field of the output message
+	var occurrences []wireField
+	err := forEachField(message, func(wf wireField) error {
+		if wf.number == f.Number {
+			occurrences = append(occurrences, wf)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(occurrences) == 0 {
+		c.codec.writeDefault(&buf, f, true)
+	} else if err := c.codec.writeField(&buf, f, occurrences); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,554 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// Well-known types
+// ============================================================================
+//
+// The google.protobuf types below have special JSON forms (a timestamp is
+// an RFC 3339 string, an Int32Value a bare number, a Struct any object).
+// They are handled here by name rather than through their descriptors, so
+// a descriptor set that references them need not include them.
+
+// wellKnownScalars maps wrapper types to the type of their value field.
+var wellKnownScalars = map[string]int{
+	"google.protobuf.DoubleValue": typeDouble,
+	"google.protobuf.FloatValue":  typeFloat,
+	"google.protobuf.Int64Value":  typeInt64,
+	"google.protobuf.UInt64Value": typeUint64,
+	"google.protobuf.Int32Value":  typeInt32,
+	"google.protobuf.UInt32Value": typeUint32,
+	"google.protobuf.BoolValue":   typeBool,
+	"google.protobuf.StringValue": typeString,
+	"google.protobuf.BytesValue":  typeBytes,
+}
+
+const (
+	wktTimestamp = "google.protobuf.Timestamp"
+	wktDuration  = "google.protobuf.Duration"
+	wktStruct    = "google.protobuf.Struct"
+	wktValue     = "google.protobuf.Value"
+	wktListValue = "google.protobuf.ListValue"
+	wktFieldMask = "google.protobuf.FieldMask"
+	wktAny       = "google.protobuf.Any"
+	wktEmpty     = "google.protobuf.Empty"
+)
+
+// nullValueEnum is google.protobuf.NullValue, written as JSON null.
+var nullValueEnum = &Enum{
+	FullName: "google.protobuf.NullValue",
+	Values:   []EnumValue{{Name: "NULL_VALUE", Number: 0}},
+	byName:   map[string]int32{"NULL_VALUE": 0},
+	byNumber: map[int32]string{0: "NULL_VALUE"},
+}
+
+// maxTimestampSeconds bounds Timestamp to years 1 through 9999.
+const (
+	minTimestampSeconds = -62135596800
+	maxTimestampSeconds = 253402300799
+	maxDurationSeconds  = 315576000000
+)
+
+// isWellKnown reports whether name is handled by this file.
+func isWellKnown(name string) bool {
+	if _, ok := wellKnownScalars[name]; ok {
+		return true
+	}
+	switch name {
+	case wktTimestamp, wktDuration, wktStruct, wktValue, wktListValue, wktFieldMask, wktAny, wktEmpty:
+		return true
+	}
+	return false
+}
+
+// wellKnownMessage returns a placeholder descriptor for a well-known type.
+func wellKnownMessage(name string) *Message {
+	return &Message{
+		FullName: name,
+		byNumber: make(map[int32]*Field),
+		byName:   make(map[string]*Field),
+	}
+}
+
+// Fields of Value, Struct and ListValue used by the codec.
+var (
+	structFieldsField = &Field{Name: "fields", Number: 1, Type: typeMessage, TypeName: wktValue}
+	listValuesField   = &Field{Name: "values", Number: 1, Type: typeMessage, TypeName: wktValue, Repeated: true}
+)
+
+// ----------------------------------------------------------------------------
+// JSON -> protobuf
+// ----------------------------------------------------------------------------
+
+// marshalWellKnown encodes the JSON form of a well-known type.
+func (c *codec) marshalWellKnown(name string, v any) ([]byte, error) {
+	if t, ok := wellKnownScalars[name]; ok {
+		if v == nil {
+			return nil, nil
+		}
+		return c.appendValue(nil, &Field{Name: "value", Number: 1, Type: t}, v)
+	}
+
+	switch name {
+	case wktTimestamp:
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("timestamp must be an RFC 3339 string")
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", s)
+		}
+		seconds := t.Unix()
+		if seconds < minTimestampSeconds || seconds > maxTimestampSeconds {
+			return nil, fmt.Errorf("timestamp %q out of range", s)
+		}
+		return appendSecondsNanos(nil, seconds, int32(t.Nanosecond())), nil
+
+	case wktDuration:
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New(`duration must be a string such as "1.5s"`)
+		}
+		seconds, nanos, err := parseDuration(s)
+		if err != nil {
+			return nil, err
+		}
+		return appendSecondsNanos(nil, seconds, nanos), nil
+
+	case wktStruct:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, errors.New("Struct must be a JSON object")
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var b []byte
+		for _, k := range keys {
+			value, err := c.marshalWellKnown(wktValue, obj[k])
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", k, err)
+			}
+			entry := appendLengthDelimited(nil, 1, []byte(k))
+			entry = appendLengthDelimited(entry, 2, value)
+			b = appendLengthDelimited(b, structFieldsField.Number, entry)
+		}
+		return b, nil
+
+	case wktListValue:
+		list, ok := v.([]any)
+		if !ok {
+			return nil, errors.New("ListValue must be a JSON array")
+		}
+		var b []byte
+		for i, item := range list {
+			value, err := c.marshalWellKnown(wktValue, item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			b = appendLengthDelimited(b, listValuesField.Number, value)
+		}
+		return b, nil
+
+	case wktValue:
+		switch x := v.(type) {
+		case nil:
+			return appendVarint(appendTag(nil, 1, wireVarint), 0), nil
+		case json.Number:
+			f, err := strconv.ParseFloat(string(x), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", x)
+			}
+			return appendFixed64(appendTag(nil, 2, wireFixed64), math.Float64bits(f)), nil
+		case string:
+			return appendLengthDelimited(nil, 3, []byte(x)), nil
+		case bool:
+			n := uint64(0)
+			if x {
+				n = 1
+			}
+			return appendVarint(appendTag(nil, 4, wireVarint), n), nil
+		case map[string]any:
+			data, err := c.marshalWellKnown(wktStruct, x)
+			return appendLengthDelimited(nil, 5, data), err
+		case []any:
+			data, err := c.marshalWellKnown(wktListValue, x)
+			return appendLengthDelimited(nil, 6, data), err
+		}
+		return nil, fmt.Errorf("unsupported JSON value %T", v)
+
+	case wktFieldMask:
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("FieldMask must be a comma-separated string")
+		}
+		var b []byte
+		if s == "" {
+			return b, nil
+		}
+		for _, path := range strings.Split(s, ",") {
+			b = appendLengthDelimited(b, 1, []byte(snakeCase(path)))
+		}
+		return b, nil
+
+	case wktAny:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, errors.New("Any must be a JSON object")
+		}
+		typeURL, _ := obj["@type"].(string)
+		m, err := c.anyType(typeURL)
+		if err != nil {
+			return nil, err
+		}
+
+		var value []byte
+		if isWellKnown(m.FullName) {
+			for k := range obj {
+				if k != "@type" && k != "value" {
+					return nil, fmt.Errorf("Any: unknown field %q", k)
+				}
+			}
+			value, err = c.marshalWellKnown(m.FullName, obj["value"])
+		} else {
+			fields := make(map[string]any, len(obj))
+			for k, fv := range obj {
+				if k != "@type" {
+					fields[k] = fv
+				}
+			}
+			value, err = c.marshal(m, fields)
+		}
+		if err != nil {
+			return nil, err
+		}
+		b := appendLengthDelimited(nil, 1, []byte(typeURL))
+		return appendLengthDelimited(b, 2, value), nil
+
+	case wktEmpty:
+		if obj, ok := v.(map[string]any); !ok || len(obj) > 0 {
+			return nil, errors.New("Empty must be {}")
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported well-known type %s", name)
+}
+
+// anyType resolves an Any type URL ("type.googleapis.com/pkg.Message").
+func (c *codec) anyType(typeURL string) (*Message, error) {
+	if typeURL == "" {
+		return nil, errors.New(`Any: missing "@type"`)
+	}
+	name := typeURL[strings.LastIndex(typeURL, "/")+1:]
+	if isWellKnown(name) {
+		return wellKnownMessage(name), nil
+	}
+	if c.types != nil {
+		if m, ok := c.types.Messages[name]; ok {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("Any: unknown type %q", typeURL)
+}
+
+// appendSecondsNanos encodes a Timestamp or Duration.
+func appendSecondsNanos(b []byte, seconds int64, nanos int32) []byte {
+	if seconds != 0 {
+		b = appendVarint(appendTag(b, 1, wireVarint), uint64(seconds))
+	}
+	if nanos != 0 {
+		b = appendVarint(appendTag(b, 2, wireVarint), uint64(int64(nanos)))
+	}
+	return b
+}
+
+// parseDuration parses the JSON form of a Duration ("-1.5s").
+func parseDuration(s string) (int64, int32, error) {
+	text, ok := strings.CutSuffix(s, "s")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid duration %q", s)
+	}
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(text, "-")
+
+	whole, frac, _ := strings.Cut(text, ".")
+	if whole == "" || len(frac) > 9 || strings.HasPrefix(whole, "+") {
+		return 0, 0, fmt.Errorf("invalid duration %q", s)
+	}
+	seconds, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || seconds > maxDurationSeconds {
+		return 0, 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var nanos int64
+	if frac != "" {
+		if nanos, err = strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 32); err != nil {
+			return 0, 0, fmt.Errorf("invalid duration %q", s)
+		}
+	}
+	if negative {
+		seconds, nanos = -seconds, -nanos
+	}
+	return seconds, int32(nanos), nil
+}
+
+// snakeCase converts a lowerCamelCase field path to proto names.
+func snakeCase(path string) string {
+	var b strings.Builder
+	for _, c := range path {
+		if c >= 'A' && c <= 'Z' {
+			b.WriteByte('_')
+			c += 'a' - 'A'
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// ----------------------------------------------------------------------------
+// protobuf -> JSON
+// ----------------------------------------------------------------------------
+
+// unmarshalWellKnown writes the JSON form of a well-known type.
+func (c *codec) unmarshalWellKnown(buf *bytes.Buffer, name string, data []byte) error {
+	if t, ok := wellKnownScalars[name]; ok {
+		field := &Field{Name: "value", Number: 1, Type: t}
+		var last *wireField
+		err := forEachField(data, func(f wireField) error {
+			if f.number == 1 {
+				last = &f
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if last == nil {
+			c.writeDefault(buf, field, false)
+			return nil
+		}
+		return c.writeValue(buf, field, *last)
+	}
+
+	switch name {
+	case wktTimestamp:
+		seconds, nanos, err := readSecondsNanos(data)
+		if err != nil {
+			return err
+		}
+		if seconds < minTimestampSeconds || seconds > maxTimestampSeconds || nanos < 0 || nanos > 999999999 {
+			return errors.New("timestamp out of range")
+		}
+		t := time.Unix(seconds, int64(nanos)).UTC()
+		writeString(buf, t.Format("2006-01-02T15:04:05")+fraction(nanos)+"Z")
+		return nil
+
+	case wktDuration:
+		seconds, nanos, err := readSecondsNanos(data)
+		if err != nil {
+			return err
+		}
+		sign := ""
+		if seconds < 0 || nanos < 0 {
+			sign = "-"
+		}
+		if seconds < 0 {
+			seconds = -seconds
+		}
+		if nanos < 0 {
+			nanos = -nanos
+		}
+		writeString(buf, sign+strconv.FormatInt(seconds, 10)+fraction(nanos)+"s")
+		return nil
+
+	case wktStruct:
+		buf.WriteByte('{')
+		n := 0
+		err := forEachField(data, func(f wireField) error {
+			if f.number != 1 || f.wireType != wireBytes {
+				return nil
+			}
+			var key string
+			var value []byte
+			err := forEachField(f.data, func(e wireField) error {
+				switch e.number {
+				case 1:
+					key = string(e.data)
+				case 2:
+					value = e.data
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				buf.WriteByte(',')
+			}
+			n++
+			writeString(buf, key)
+			buf.WriteByte(':')
+			return c.unmarshalWellKnown(buf, wktValue, value)
+		})
+		buf.WriteByte('}')
+		return err
+
+	case wktListValue:
+		buf.WriteByte('[')
+		n := 0
+		err := forEachField(data, func(f wireField) error {
+			if f.number != 1 || f.wireType != wireBytes {
+				return nil
+			}
+			if n > 0 {
+				buf.WriteByte(',')
+			}
+			n++
+			return c.unmarshalWellKnown(buf, wktValue, f.data)
+		})
+		buf.WriteByte(']')
+		return err
+
+	case wktValue:
+		var kind *wireField
+		err := forEachField(data, func(f wireField) error {
+			if f.number >= 1 && f.number <= 6 {
+				kind = &f
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if kind == nil {
+			buf.WriteString("null")
+			return nil
+		}
+		switch kind.number {
+		case 1:
+			buf.WriteString("null")
+		case 2:
+			x := math.Float64frombits(kind.num)
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				return errors.New("Value cannot be NaN or Infinity")
+			}
+			writeFloat(buf, x, 64)
+		case 3:
+			writeString(buf, string(kind.data))
+		case 4:
+			buf.WriteString(strconv.FormatBool(kind.num != 0))
+		case 5:
+			return c.unmarshalWellKnown(buf, wktStruct, kind.data)
+		case 6:
+			return c.unmarshalWellKnown(buf, wktListValue, kind.data)
+		}
+		return nil
+
+	case wktFieldMask:
+		var paths []string
+		err := forEachField(data, func(f wireField) error {
+			if f.number == 1 {
+				paths = append(paths, jsonName(string(f.data)))
+			}
+			return nil
+		})
+		writeString(buf, strings.Join(paths, ","))
+		return err
+
+	case wktAny:
+		var typeURL string
+		var value []byte
+		err := forEachField(data, func(f wireField) error {
+			switch f.number {
+			case 1:
+				typeURL = string(f.data)
+			case 2:
+				value = f.data
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if typeURL == "" && len(value) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		m, err := c.anyType(typeURL)
+		if err != nil {
+			return err
+		}
+
+		buf.WriteString(`{"@type":`)
+		writeString(buf, typeURL)
+		if isWellKnown(m.FullName) {
+			buf.WriteString(`,"value":`)
+			if err := c.unmarshalWellKnown(buf, m.FullName, value); err != nil {
+				return err
+			}
+			buf.WriteByte('}')
+			return nil
+		}
+
+		var inner bytes.Buffer
+		if err := c.unmarshal(&inner, m, value); err != nil {
+			return err
+		}
+		// Splice the message's fields in after "@type"
+		if fields := inner.Bytes()[1:]; len(fields) > 1 {
+			buf.WriteByte(',')
+			buf.Write(fields)
+		} else {
+			buf.WriteByte('}')
+		}
+		return nil
+
+	case wktEmpty:
+		buf.WriteString("{}")
+		return nil
+	}
+	return fmt.Errorf("unsupported well-known type %s", name)
+}
+
+// readSecondsNanos decodes a Timestamp or Duration.
+func readSecondsNanos(data []byte) (int64, int32, error) {
+	var seconds int64
+	var nanos int32
+	err := forEachField(data, func(f wireField) error {
+		switch f.number {
+		case 1:
+			seconds = int64(f.num)
+		case 2:
+			nanos = int32(f.num)
+		}
+		return nil
+	})
+	return seconds, nanos, err
+}
+
+// fraction formats nanoseconds with 0, 3, 6 or 9 digits.
+func fraction(nanos int32) string {
+	switch {
+	case nanos == 0:
+		return ""
+	case nanos%1000000 == 0:
+		return fmt.Sprintf(".%03d", nanos/1000000)
+	case nanos%1000 == 0:
+		return fmt.Sprintf(".%06d", nanos/1000)
+	}
+	return fmt.Sprintf(".%09d", nanos)
+}
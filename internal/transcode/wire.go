@@ -0,0 +1,139 @@
+package transcode
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ============================================================================
+// Protobuf wire format
+// ============================================================================
+//
+// Messages are a sequence of (tag, value) pairs, where the tag packs the
+// field number and one of the wire types below. Groups (wire types 3 and
+// 4) are deprecated and not supported.
+
+// Wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// wireField is one decoded field. Varint, fixed32 and fixed64 values are in
+// num; length-delimited values in data.
+type wireField struct {
+	number   int32
+	wireType int
+	num      uint64
+	data     []byte
+}
+
+// appendVarint appends v as a base-128 varint.
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// appendTag appends a field tag.
+func appendTag(b []byte, number int32, wireType int) []byte {
+	return appendVarint(b, uint64(number)<<3|uint64(wireType))
+}
+
+// appendLengthDelimited appends a length-delimited field.
+func appendLengthDelimited(b []byte, number int32, data []byte) []byte {
+	b = appendTag(b, number, wireBytes)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// appendFixed32 and appendFixed64 append little-endian values.
+func appendFixed32(b []byte, v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(b, v)
+}
+
+func appendFixed64(b []byte, v uint64) []byte {
+	return binary.LittleEndian.AppendUint64(b, v)
+}
+
+// consumeVarint decodes a varint, returning it and the bytes used.
+func consumeVarint(b []byte) (uint64, int, error) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * i)
+		if b[i] < 0x80 {
+			return v, i + 1, nil
+		}
+	}
+	if len(b) >= 10 {
+		return 0, 0, errors.New("varint overflows 64 bits")
+	}
+	return 0, 0, errTruncated
+}
+
+// forEachField calls fn for every field of an encoded message.
+func forEachField(b []byte, fn func(f wireField) error) error {
+	for len(b) > 0 {
+		tag, n, err := consumeVarint(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+
+		f := wireField{number: int32(tag >> 3), wireType: int(tag & 7)}
+		if f.number <= 0 {
+			return fmt.Errorf("invalid field number %d", f.number)
+		}
+
+		switch f.wireType {
+		case wireVarint:
+			f.num, n, err = consumeVarint(b)
+			if err != nil {
+				return err
+			}
+		case wireFixed64:
+			if len(b) < 8 {
+				return errTruncated
+			}
+			f.num, n = binary.LittleEndian.Uint64(b), 8
+		case wireFixed32:
+			if len(b) < 4 {
+				return errTruncated
+			}
+			f.num, n = uint64(binary.LittleEndian.Uint32(b)), 4
+		case wireBytes:
+			length, m, err := consumeVarint(b)
+			if err != nil {
+				return err
+			}
+			if length > uint64(len(b)-m) {
+				return errTruncated
+			}
+			f.data, n = b[m:m+int(length)], m+int(length)
+		default:
+			return fmt.Errorf("unsupported wire type %d (field %d)", f.wireType, f.number)
+		}
+		b = b[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zigzag encoding for sint32/sint64.
+func encodeZigZag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func decodeZigZag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
@@ -0,0 +1,252 @@
+// Package usage aggregates per-consumer traffic for billing and reporting.
+//
+// The gateway records every authenticated request (one with a consumer_id
+// set by an auth plugin) into an in-memory Aggregator. The aggregator
+// periodically flushes its counters into the consumer_usage_daily table
+// as additive upserts, so:
+//   - The request path only increments in-memory counters
+//   - Several gateway instances can report into the same daily rows
+//   - A failed flush keeps its counters and retries on the next interval
+//
+// Reads go straight to the rollup table (GET /admin/consumers/{id}/usage),
+// so the current, unflushed interval is not yet visible.
+package usage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+	"github.com/saidutt46/switchboard-gateway/internal/metrics"
+)
+
+// flushTimeout bounds a single flush.
+const flushTimeout = 30 * time.Second
+
+// pruneInterval is how often old rollup rows are deleted.
+const pruneInterval = 24 * time.Hour
+
+var flushesTotal = metrics.NewCounterVec(
+	"gateway_usage_flushes_total",
+	"Usage rollup flushes to Postgres, by result (ok, failed).",
+	"result",
+)
+
+// Store persists usage rollups (implemented by database.Repository).
+type Store interface {
+	UpsertConsumerUsage(ctx context.Context, usage []*database.ConsumerUsage) error
+	DeleteConsumerUsageBefore(ctx context.Context, day time.Time) (int64, error)
+}
+
+// key identifies one consumer-day.
+type key struct {
+	consumerID string
+	day        time.Time // midnight UTC
+}
+
+// counters are the usage totals of one consumer-day.
+type counters struct {
+	requests     int64
+	clientErrors int64
+	serverErrors int64
+	bytesIn      int64
+	bytesOut     int64
+}
+
+// add merges other into c.
+func (c *counters) add(other counters) {
+	c.requests += other.requests
+	c.clientErrors += other.clientErrors
+	c.serverErrors += other.serverErrors
+	c.bytesIn += other.bytesIn
+	c.bytesOut += other.bytesOut
+}
+
+// Aggregator accumulates usage in memory and flushes it to a Store.
+//
+// A nil Aggregator ignores Record calls, so callers need not check
+// whether usage reporting is enabled.
+type Aggregator struct {
+	store Store
+
+	// RetentionDays deletes rollup rows older than this many days (0 =
+	// keep forever). Set before Run.
+	RetentionDays int
+
+	mu      sync.Mutex
+	pending map[key]*counters
+
+	// flushMu serializes flushes (Run vs. Close)
+	flushMu   sync.Mutex
+	lastPrune time.Time
+}
+
+// NewAggregator creates an aggregator writing to store.
+func NewAggregator(store Store) *Aggregator {
+	return &Aggregator{
+		store:   store,
+		pending: make(map[key]*counters),
+	}
+}
+
+// Record counts one request for a consumer. Requests without a consumer
+// are not counted. Negative sizes (unknown Content-Length) count as 0.
+func (a *Aggregator) Record(consumerID string, status int, bytesIn, bytesOut int64, at time.Time) {
+	if a == nil || consumerID == "" {
+		return
+	}
+
+	delta := counters{requests: 1, bytesIn: max(bytesIn, 0), bytesOut: max(bytesOut, 0)}
+	switch {
+	case status >= 500:
+		delta.serverErrors = 1
+	case status >= 400:
+		delta.clientErrors = 1
+	}
+
+	k := key{consumerID: consumerID, day: day(at)}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	c, ok := a.pending[k]
+	if !ok {
+		c = &counters{}
+		a.pending[k] = c
+	}
+	c.add(delta)
+}
+
+// Run flushes every interval until ctx is cancelled.
+func (a *Aggregator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.Flush(ctx)
+			a.prune(ctx)
+		}
+	}
+}
+
+// Flush writes pending counters to the store. On failure the counters are
+// kept and retried on the next flush.
+func (a *Aggregator) Flush(ctx context.Context) error {
+	if a == nil {
+		return nil
+	}
+
+	a.flushMu.Lock()
+	defer a.flushMu.Unlock()
+
+	a.mu.Lock()
+	batch := a.pending
+	a.pending = make(map[key]*counters)
+	a.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	rows := make([]*database.ConsumerUsage, 0, len(batch))
+	for k, c := range batch {
+		rows = append(rows, &database.ConsumerUsage{
+			ConsumerID:   k.consumerID,
+			Day:          k.day,
+			Requests:     c.requests,
+			ClientErrors: c.clientErrors,
+			ServerErrors: c.serverErrors,
+			BytesIn:      c.bytesIn,
+			BytesOut:     c.bytesOut,
+		})
+	}
+	// Stable order keeps concurrent upserts from deadlocking on row locks
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].ConsumerID != rows[j].ConsumerID {
+			return rows[i].ConsumerID < rows[j].ConsumerID
+		}
+		return rows[i].Day.Before(rows[j].Day)
+	})
+
+	flushCtx, cancel := context.WithTimeout(ctx, flushTimeout)
+	defer cancel()
+
+	if err := a.store.UpsertConsumerUsage(flushCtx, rows); err != nil {
+		flushesTotal.Inc("failed")
+		a.restore(batch)
+
+		log.Warn().
+			Err(err).
+			Str("component", "usage").
+			Int("rows", len(rows)).
+			Msg("Failed to flush usage - will retry")
+		return err
+	}
+
+	flushesTotal.Inc("ok")
+	log.Debug().
+		Str("component", "usage").
+		Int("rows", len(rows)).
+		Msg("Usage flushed")
+
+	return nil
+}
+
+// Close flushes whatever is pending (e.g. on shutdown).
+func (a *Aggregator) Close(ctx context.Context) error {
+	return a.Flush(ctx)
+}
+
+// restore merges an unflushed batch back into pending.
+func (a *Aggregator) restore(batch map[key]*counters) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for k, c := range batch {
+		if existing, ok := a.pending[k]; ok {
+			existing.add(*c)
+			continue
+		}
+		a.pending[k] = c
+	}
+}
+
+// prune deletes rows past the retention period, at most once a day.
+func (a *Aggregator) prune(ctx context.Context) {
+	if a.RetentionDays <= 0 || time.Since(a.lastPrune) < pruneInterval {
+		return
+	}
+	a.lastPrune = time.Now()
+
+	cutoff := day(time.Now()).AddDate(0, 0, -a.RetentionDays)
+	deleted, err := a.store.DeleteConsumerUsageBefore(ctx, cutoff)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("component", "usage").
+			Msg("Failed to prune old usage rows")
+		return
+	}
+
+	if deleted > 0 {
+		log.Info().
+			Str("component", "usage").
+			Int64("deleted", deleted).
+			Time("before", cutoff).
+			Msg("Pruned old usage rows")
+	}
+}
+
+// day truncates t to midnight UTC.
+func day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,116 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/saidutt46/switchboard-gateway/internal/database"
+)
+
+// fakeStore records upserts and can be made to fail.
+type fakeStore struct {
+	rows    []*database.ConsumerUsage
+	fail    bool
+	deleted time.Time
+}
+
+func (s *fakeStore) UpsertConsumerUsage(ctx context.Context, usage []*database.ConsumerUsage) error {
+	if s.fail {
+		return errors.New("database unavailable")
+	}
+	s.rows = append(s.rows, usage...)
+	return nil
+}
+
+func (s *fakeStore) DeleteConsumerUsageBefore(ctx context.Context, day time.Time) (int64, error) {
+	s.deleted = day
+	return 0, nil
+}
+
+func TestAggregator_Flush(t *testing.T) {
+	store := &fakeStore{}
+	a := NewAggregator(store)
+
+	morning := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	a.Record("c1", 200, 100, 1000, morning)
+	a.Record("c1", 404, 50, 20, morning.Add(time.Hour))
+	a.Record("c1", 503, -1, 0, morning.Add(2*time.Hour)) // unknown request size
+	a.Record("c1", 200, 10, 10, morning.Add(24*time.Hour))
+	a.Record("c2", 200, 1, 1, morning)
+	a.Record("", 200, 1, 1, morning) // unauthenticated: not counted
+
+	if err := a.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if len(store.rows) != 3 {
+		t.Fatalf("rows = %d, want 3", len(store.rows))
+	}
+
+	first := store.rows[0]
+	if first.ConsumerID != "c1" || !first.Day.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected first row: %+v", first)
+	}
+	if first.Requests != 3 || first.ClientErrors != 1 || first.ServerErrors != 1 {
+		t.Errorf("counts = %+v, want 3 requests, 1 client error, 1 server error", first)
+	}
+	if first.BytesIn != 150 || first.BytesOut != 1020 {
+		t.Errorf("bytes = %d in / %d out, want 150 / 1020", first.BytesIn, first.BytesOut)
+	}
+
+	// Nothing pending after a successful flush
+	store.rows = nil
+	a.Flush(context.Background())
+	if len(store.rows) != 0 {
+		t.Errorf("second flush wrote %d rows, want 0", len(store.rows))
+	}
+}
+
+func TestAggregator_FailedFlushRetries(t *testing.T) {
+	store := &fakeStore{fail: true}
+	a := NewAggregator(store)
+
+	now := time.Now()
+	a.Record("c1", 200, 10, 10, now)
+	if err := a.Flush(context.Background()); err == nil {
+		t.Fatal("Flush succeeded with a failing store")
+	}
+
+	// Counters survive the failure and merge with new traffic
+	a.Record("c1", 200, 10, 10, now)
+	store.fail = false
+	if err := a.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if len(store.rows) != 1 || store.rows[0].Requests != 2 || store.rows[0].BytesIn != 20 {
+		t.Errorf("rows = %+v, want one row with 2 requests", store.rows)
+	}
+}
+
+func TestAggregator_Prune(t *testing.T) {
+	store := &fakeStore{}
+	a := NewAggregator(store)
+	a.RetentionDays = 30
+
+	a.prune(context.Background())
+	want := day(time.Now()).AddDate(0, 0, -30)
+	if !store.deleted.Equal(want) {
+		t.Errorf("pruned before %v, want %v", store.deleted, want)
+	}
+
+	// At most once a day
+	store.deleted = time.Time{}
+	a.prune(context.Background())
+	if !store.deleted.IsZero() {
+		t.Error("pruned twice within a day")
+	}
+}
+
+func TestAggregator_Nil(t *testing.T) {
+	var a *Aggregator
+	a.Record("c1", 200, 1, 1, time.Now())
+	if err := a.Flush(context.Background()); err != nil {
+		t.Errorf("nil Flush = %v", err)
+	}
+}
@@ -0,0 +1,309 @@
+// Package xmlbody parses, edits and converts XML request and response
+// bodies, e.g. SOAP envelopes on their way to or from a legacy backend.
+//
+// A Document keeps the body's structure as written (namespace prefixes,
+// declarations, comments), so serializing an unmodified document yields
+// equivalent XML rather than encoding/xml's re-namespaced output. Paths
+// (see Compile) select the elements or attributes to set or remove, and
+// ToJSON and FromJSON convert between documents and JSON bodies.
+package xmlbody
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Node kinds.
+const (
+	ElementNode = iota
+	TextNode
+	CommentNode
+	ProcInstNode
+	DirectiveNode
+)
+
+// Node is an element, or text, comment, processing instruction or
+// directive content.
+type Node struct {
+	Kind int
+
+	// Name is the element name as written: Space holds the prefix
+	Name xml.Name
+
+	// Namespace is the element's resolved namespace URI
+	Namespace string
+
+	// Attr are the attributes as written, xmlns declarations included
+	Attr []xml.Attr
+
+	// Children of an element, in document order
+	Children []*Node
+
+	// Data holds text, comment and directive content; for processing
+	// instructions it is the instruction and Name.Local the target
+	Data string
+
+	Parent *Node
+}
+
+// Document is a parsed XML body.
+type Document struct {
+	// Nodes are the top-level nodes: prolog, root element, trailing comments
+	Nodes []*Node
+
+	// Root is the document element
+	Root *Node
+}
+
+// xmlnsURI is the namespace of the "xml" prefix, which is always bound.
+const xmlnsURI = "http://www.w3.org/XML/1998/namespace"
+
+// Parse parses an XML document.
+func Parse(data []byte) (*Document, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = true
+
+	doc := &Document{}
+	var stack []*Node
+	scopes := []map[string]string{{"xml": xmlnsURI}}
+
+	add := func(n *Node) {
+		if len(stack) == 0 {
+			doc.Nodes = append(doc.Nodes, n)
+			return
+		}
+		parent := stack[len(stack)-1]
+		n.Parent = parent
+		parent.Children = append(parent.Children, n)
+	}
+
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && doc.Root != nil {
+				return nil, errors.New("multiple root elements")
+			}
+
+			scope := make(map[string]string, len(scopes[len(scopes)-1]))
+			for k, v := range scopes[len(scopes)-1] {
+				scope[k] = v
+			}
+			for _, a := range t.Attr {
+				switch {
+				case a.Name.Space == "" && a.Name.Local == "xmlns":
+					scope[""] = a.Value
+				case a.Name.Space == "xmlns":
+					scope[a.Name.Local] = a.Value
+				}
+			}
+			namespace, ok := scope[t.Name.Space]
+			if !ok && t.Name.Space != "" {
+				return nil, fmt.Errorf("undeclared namespace prefix %q", t.Name.Space)
+			}
+
+			n := &Node{Kind: ElementNode, Name: t.Name, Namespace: namespace, Attr: t.Copy().Attr}
+			add(n)
+			if len(stack) == 0 {
+				doc.Root = n
+			}
+			stack = append(stack, n)
+			scopes = append(scopes, scope)
+
+		case xml.EndElement:
+			if len(stack) == 0 || stack[len(stack)-1].Name != t.Name {
+				return nil, fmt.Errorf("unexpected end element </%s>", qualifiedName(t.Name))
+			}
+			stack = stack[:len(stack)-1]
+			scopes = scopes[:len(scopes)-1]
+
+		case xml.CharData:
+			if len(stack) == 0 {
+				if len(bytes.TrimSpace(t)) > 0 {
+					return nil, errors.New("text outside the root element")
+				}
+			}
+			add(&Node{Kind: TextNode, Data: string(t)})
+
+		case xml.Comment:
+			add(&Node{Kind: CommentNode, Data: string(t)})
+
+		case xml.ProcInst:
+			add(&Node{Kind: ProcInstNode, Name: xml.Name{Local: t.Target}, Data: string(t.Inst)})
+
+		case xml.Directive:
+			add(&Node{Kind: DirectiveNode, Data: string(t)})
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("unclosed element <%s>", qualifiedName(stack[len(stack)-1].Name))
+	}
+	if doc.Root == nil {
+		return nil, errors.New("no root element")
+	}
+	return doc, nil
+}
+
+// Bytes serializes the document.
+func (d *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, n := range d.Nodes {
+		writeNode(&buf, n)
+	}
+	return buf.Bytes()
+}
+
+func writeNode(buf *bytes.Buffer, n *Node) {
+	switch n.Kind {
+	case TextNode:
+		escape(buf, n.Data, false)
+	case CommentNode:
+		buf.WriteString("<!--" + n.Data + "-->")
+	case ProcInstNode:
+		buf.WriteString("<?" + n.Name.Local)
+		if n.Data != "" {
+			buf.WriteString(" " + n.Data)
+		}
+		buf.WriteString("?>")
+	case DirectiveNode:
+		buf.WriteString("<!" + n.Data + ">")
+	case ElementNode:
+		name := qualifiedName(n.Name)
+		buf.WriteString("<" + name)
+		for _, a := range n.Attr {
+			buf.WriteString(" " + qualifiedName(a.Name) + `="`)
+			escape(buf, a.Value, true)
+			buf.WriteByte('"')
+		}
+		if len(n.Children) == 0 {
+			buf.WriteString("/>")
+			return
+		}
+		buf.WriteByte('>')
+		for _, c := range n.Children {
+			writeNode(buf, c)
+		}
+		buf.WriteString("</" + name + ">")
+	}
+}
+
+// escape writes s with XML special characters escaped.
+func escape(buf *bytes.Buffer, s string, attr bool) {
+	for _, c := range s {
+		switch {
+		case c == '&':
+			buf.WriteString("&amp;")
+		case c == '<':
+			buf.WriteString("&lt;")
+		case c == '>':
+			buf.WriteString("&gt;")
+		case c == '"' && attr:
+			buf.WriteString("&quot;")
+		case (c == '\n' || c == '\t' || c == '\r') && attr:
+			fmt.Fprintf(buf, "&#x%X;", c)
+		case c == '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(c)
+		}
+	}
+}
+
+// qualifiedName returns "prefix:local" or "local".
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// Text returns the concatenated text content of n.
+func (n *Node) Text() string {
+	if n.Kind == TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for _, c := range n.Children {
+		if c.Kind == TextNode || c.Kind == ElementNode {
+			b.WriteString(c.Text())
+		}
+	}
+	return b.String()
+}
+
+// SetText replaces the content of element n with text.
+func (n *Node) SetText(text string) {
+	n.Children = []*Node{{Kind: TextNode, Data: text, Parent: n}}
+}
+
+// Elements returns the element children of n.
+func (n *Node) Elements() []*Node {
+	var elements []*Node
+	for _, c := range n.Children {
+		if c.Kind == ElementNode {
+			elements = append(elements, c)
+		}
+	}
+	return elements
+}
+
+// AttrValue returns the value of the attribute with the given local name
+// (ignoring its prefix).
+func (n *Node) AttrValue(local string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Name.Local == local && a.Name.Space != "xmlns" {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// lookupPrefix returns the namespace bound to prefix at n.
+func (n *Node) lookupPrefix(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return xmlnsURI, true
+	}
+	for e := n; e != nil; e = e.Parent {
+		for _, a := range e.Attr {
+			if (prefix == "" && a.Name.Space == "" && a.Name.Local == "xmlns") ||
+				(prefix != "" && a.Name.Space == "xmlns" && a.Name.Local == prefix) {
+				return a.Value, true
+			}
+		}
+	}
+	return "", prefix == ""
+}
+
+// remove detaches n from its parent.
+func (n *Node) remove() {
+	if n.Parent == nil {
+		return
+	}
+	children := n.Parent.Children[:0]
+	for _, c := range n.Parent.Children {
+		if c != n {
+			children = append(children, c)
+		}
+	}
+	n.Parent.Children = children
+	n.Parent = nil
+}
+
+// IsXML reports whether a media type carries XML: application/xml,
+// text/xml, or any "+xml" type such as application/soap+xml.
+func IsXML(mediaType string) bool {
+	mediaType = strings.ToLower(mediaType)
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
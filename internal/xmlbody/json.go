@@ -0,0 +1,287 @@
+package xmlbody
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SOAP envelope namespaces (1.1 and 1.2).
+const (
+	SOAP11Namespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	SOAP12Namespace = "http://www.w3.org/2003/05/soap-envelope"
+)
+
+// SOAPBody returns the first element inside the Body of a SOAP envelope
+// (the operation response or a Fault), or nil if doc isn't a SOAP message
+// or its Body is empty.
+func SOAPBody(doc *Document) *Node {
+	root := doc.Root
+	if root.Name.Local != "Envelope" || (root.Namespace != SOAP11Namespace && root.Namespace != SOAP12Namespace) {
+		return nil
+	}
+	for _, c := range root.Elements() {
+		if c.Name.Local == "Body" && c.Namespace == root.Namespace {
+			if elements := c.Elements(); len(elements) > 0 {
+				return elements[0]
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// IsSOAPFault reports whether n is a SOAP Fault element.
+func IsSOAPFault(n *Node) bool {
+	return n != nil && n.Name.Local == "Fault" && (n.Namespace == SOAP11Namespace || n.Namespace == SOAP12Namespace)
+}
+
+// ToJSON converts element n to JSON, as an object with n's local name as
+// its only key:
+//
+//	<Order id="7"><Item>a</Item><Item>b</Item><Note/></Order>
+//	{"Order":{"@id":"7","Item":["a","b"],"Note":""}}
+//
+// Namespace prefixes are dropped. Attributes become "@name" keys, repeated
+// elements arrays, and text next to attributes or child elements "#text".
+// Every value is a string: XML carries no types.
+func ToJSON(n *Node) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	writeJSONString(&buf, n.Name.Local)
+	buf.WriteByte(':')
+	writeElement(&buf, n)
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+func writeElement(buf *bytes.Buffer, n *Node) {
+	var attrs []int
+	for i, a := range n.Attr {
+		if a.Name.Space != "xmlns" && !(a.Name.Space == "" && a.Name.Local == "xmlns") {
+			attrs = append(attrs, i)
+		}
+	}
+
+	// Group child elements by local name, in order of first appearance
+	var names []string
+	groups := make(map[string][]*Node)
+	var text strings.Builder
+	for _, c := range n.Children {
+		switch c.Kind {
+		case ElementNode:
+			if _, ok := groups[c.Name.Local]; !ok {
+				names = append(names, c.Name.Local)
+			}
+			groups[c.Name.Local] = append(groups[c.Name.Local], c)
+		case TextNode:
+			text.WriteString(c.Data)
+		}
+	}
+
+	if len(attrs) == 0 && len(names) == 0 {
+		writeJSONString(buf, text.String())
+		return
+	}
+
+	buf.WriteByte('{')
+	first := true
+	key := func(k string) {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		writeJSONString(buf, k)
+		buf.WriteByte(':')
+	}
+
+	for _, i := range attrs {
+		key("@" + n.Attr[i].Name.Local)
+		writeJSONString(buf, n.Attr[i].Value)
+	}
+	for _, name := range names {
+		key(name)
+		elements := groups[name]
+		if len(elements) == 1 {
+			writeElement(buf, elements[0])
+			continue
+		}
+		buf.WriteByte('[')
+		for i, e := range elements {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeElement(buf, e)
+		}
+		buf.WriteByte(']')
+	}
+	if t := strings.TrimSpace(text.String()); t != "" {
+		key("#text")
+		writeJSONString(buf, t)
+	}
+	buf.WriteByte('}')
+}
+
+// xmlNamePattern restricts the element and attribute names FromJSON
+// writes: an optional prefix and a local name.
+var xmlNamePattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9._-]*:)?[A-Za-z_][A-Za-z0-9._-]*$`)
+
+// FromJSON converts a JSON body to an XML document, the reverse of ToJSON:
+//
+//	{"Order":{"@id":"7","Item":["a","b"],"Note":null}}
+//	<Order id="7"><Item>a</Item><Item>b</Item><Note/></Order>
+//
+// The body must be an object with a single key, the root element. "@name"
+// keys become attributes, "#text" the element's text, arrays repeated
+// elements, and numbers and booleans text as written. Namespaces are
+// declared with "@xmlns" or "@xmlns:prefix" keys.
+func FromJSON(data []byte) (*Document, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, errors.New("JSON body must be an object")
+	}
+	tok, err := dec.Token()
+	name, ok := tok.(string)
+	if err != nil || !ok {
+		return nil, errors.New("JSON body must have a root element key")
+	}
+	var buf bytes.Buffer
+	if err := writeXMLElement(&buf, dec, name, false); err != nil {
+		return nil, err
+	}
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('}') {
+		return nil, errors.New("JSON body must have a single root element key")
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON body")
+	}
+
+	doc, err := Parse(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("JSON body isn't valid as XML: %w", err)
+	}
+	return doc, nil
+}
+
+// writeXMLElement writes the next JSON value as element name; an array
+// (unless inArray) as repeated elements.
+func writeXMLElement(buf *bytes.Buffer, dec *json.Decoder, name string, inArray bool) error {
+	if !xmlNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid element name %q", name)
+	}
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case nil:
+		buf.WriteString("<" + name + "/>")
+		return nil
+	case json.Delim('['):
+		if inArray {
+			return fmt.Errorf("element %q: nested arrays can't be converted", name)
+		}
+		for dec.More() {
+			if err := writeXMLElement(buf, dec, name, true); err != nil {
+				return err
+			}
+		}
+		_, err := dec.Token()
+		return err
+	case json.Delim('{'):
+		return writeXMLObject(buf, dec, name)
+	}
+
+	text, err := jsonScalarText(tok)
+	if err != nil {
+		return fmt.Errorf("element %q: %w", name, err)
+	}
+	buf.WriteString("<" + name + ">")
+	escape(buf, text, false)
+	buf.WriteString("</" + name + ">")
+	return nil
+}
+
+// writeXMLObject writes the rest of a JSON object as element name.
+func writeXMLObject(buf *bytes.Buffer, dec *json.Decoder, name string) error {
+	var attrs, content bytes.Buffer
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+
+		switch {
+		case strings.HasPrefix(key, "@") || key == "#text":
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			text, err := jsonScalarText(tok)
+			if err != nil {
+				return fmt.Errorf("element %q: %s: %w", name, key, err)
+			}
+			if key == "#text" {
+				escape(&content, text, false)
+				continue
+			}
+			if !xmlNamePattern.MatchString(key[1:]) {
+				return fmt.Errorf("element %q: invalid attribute name %q", name, key[1:])
+			}
+			attrs.WriteString(" " + key[1:] + `="`)
+			escape(&attrs, text, true)
+			attrs.WriteByte('"')
+		default:
+			if err := writeXMLElement(&content, dec, key, false); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	buf.WriteString("<" + name)
+	buf.Write(attrs.Bytes())
+	if content.Len() == 0 {
+		buf.WriteString("/>")
+		return nil
+	}
+	buf.WriteByte('>')
+	buf.Write(content.Bytes())
+	buf.WriteString("</" + name + ">")
+	return nil
+}
+
+// jsonScalarText returns the text of a JSON string, number or boolean.
+func jsonScalarText(tok json.Token) (string, error) {
+	switch v := tok.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case nil:
+		return "", nil
+	}
+	return "", errors.New("objects and arrays can't be converted to text")
+}
+
+// writeJSONString writes s as a JSON string, leaving <, > and & as they
+// are (they are common in XML text and need no escaping in JSON).
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	buf.Truncate(buf.Len() - 1) // Encode's trailing newline
+}
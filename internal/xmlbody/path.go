@@ -0,0 +1,436 @@
+package xmlbody
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// Paths
+// ============================================================================
+//
+// Paths are an XPath 1.0 subset:
+//
+//	/soap:Envelope/soap:Header/Token     child steps from the root
+//	//Order/Item[2]                      descendants, 1-based position
+//	//Item[@type='gift']/Price           attribute predicate
+//	//Order[Status='open']/Id            child text predicate
+//	/Envelope/*/Id                       any element
+//	//Order/@id                          attribute (last step only)
+//
+// A prefixed name matches elements in the namespace the prefix is bound
+// to in the path's namespace map. An unprefixed name matches the local name
+// in any namespace, which is what SOAP integrations usually want (unlike
+// XPath, where it means "no namespace").
+
+// Path is a compiled path expression.
+type Path struct {
+	raw   string
+	steps []step
+	attr  *nameTest // final attribute step, if any
+}
+
+type step struct {
+	descendant bool // preceded by "//"
+	name       nameTest
+	predicates []predicate
+}
+
+type nameTest struct {
+	prefix    string
+	local     string // "*" = any
+	namespace string // bound to prefix
+}
+
+// predicate is [n], [@attr='value'] or [child='value'].
+type predicate struct {
+	position int
+	attr     string
+	child    string
+	value    string
+}
+
+// Compile parses a path. namespaces binds the prefixes the path uses.
+func Compile(expr string, namespaces map[string]string) (*Path, error) {
+	if !strings.HasPrefix(expr, "/") {
+		return nil, fmt.Errorf("path %q must start with / or //", expr)
+	}
+	p := &Path{raw: expr}
+
+	parts, err := splitSteps(expr)
+	if err != nil {
+		return nil, fmt.Errorf("path %q: %w", expr, err)
+	}
+
+	descendant := false
+	for i, part := range parts {
+		if i == 0 && part == "" { // leading "/"
+			continue
+		}
+		if part == "" {
+			if descendant || i == len(parts)-1 {
+				return nil, fmt.Errorf("path %q: empty step", expr)
+			}
+			descendant = true
+			continue
+		}
+
+		if strings.HasPrefix(part, "@") {
+			if i != len(parts)-1 || descendant {
+				return nil, fmt.Errorf("path %q: an attribute must be the last step", expr)
+			}
+			name, err := parseNameTest(part[1:], namespaces)
+			if err != nil {
+				return nil, fmt.Errorf("path %q: %w", expr, err)
+			}
+			p.attr = &name
+			continue
+		}
+
+		s, err := parseStep(part, namespaces)
+		if err != nil {
+			return nil, fmt.Errorf("path %q: %w", expr, err)
+		}
+		s.descendant = descendant
+		descendant = false
+		p.steps = append(p.steps, s)
+	}
+
+	if len(p.steps) == 0 {
+		return nil, fmt.Errorf("path %q selects no element", expr)
+	}
+	return p, nil
+}
+
+// String returns the path as written.
+func (p *Path) String() string {
+	return p.raw
+}
+
+// splitSteps splits on '/' outside predicates and quotes. "//" yields an
+// empty part.
+func splitSteps(expr string) ([]string, error) {
+	var parts []string
+	depth, quote, start := 0, rune(0), 0
+	for i, c := range expr {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced ]")
+			}
+		case c == '/' && depth == 0:
+			parts = append(parts, expr[start:i])
+			start = i + 1
+		}
+	}
+	if depth != 0 || quote != 0 {
+		return nil, fmt.Errorf("unterminated predicate")
+	}
+	return append(parts, expr[start:]), nil
+}
+
+func parseStep(part string, namespaces map[string]string) (step, error) {
+	var s step
+	name, rest, _ := strings.Cut(part, "[")
+	var err error
+	if s.name, err = parseNameTest(name, namespaces); err != nil {
+		return s, err
+	}
+
+	for rest != "" {
+		body, after, ok := strings.Cut(rest, "]")
+		if !ok {
+			return s, fmt.Errorf("unterminated predicate in %q", part)
+		}
+		pred, err := parsePredicate(body)
+		if err != nil {
+			return s, err
+		}
+		s.predicates = append(s.predicates, pred)
+
+		rest = after
+		if rest != "" {
+			if !strings.HasPrefix(rest, "[") {
+				return s, fmt.Errorf("unexpected %q after predicate", rest)
+			}
+			rest = rest[1:]
+		}
+	}
+	return s, nil
+}
+
+func parseNameTest(name string, namespaces map[string]string) (nameTest, error) {
+	prefix, local, prefixed := strings.Cut(name, ":")
+	if !prefixed {
+		prefix, local = "", name
+	}
+	if local == "" || strings.ContainsAny(local, "[]@/'\"=") || (local == "*" && prefixed) {
+		return nameTest{}, fmt.Errorf("invalid name %q", name)
+	}
+
+	t := nameTest{prefix: prefix, local: local}
+	if prefixed {
+		var ok bool
+		if t.namespace, ok = namespaces[prefix]; !ok {
+			if prefix != "xml" {
+				return nameTest{}, fmt.Errorf("namespace prefix %q is not declared", prefix)
+			}
+			t.namespace = xmlnsURI
+		}
+	}
+	return t, nil
+}
+
+func parsePredicate(body string) (predicate, error) {
+	body = strings.TrimSpace(body)
+	if n, err := strconv.Atoi(body); err == nil {
+		if n < 1 {
+			return predicate{}, fmt.Errorf("position must be 1 or more, got %d", n)
+		}
+		return predicate{position: n}, nil
+	}
+
+	name, value, ok := strings.Cut(body, "=")
+	if !ok {
+		return predicate{}, fmt.Errorf("unsupported predicate [%s]", body)
+	}
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if len(value) < 2 || (value[0] != '\'' && value[0] != '"') || value[len(value)-1] != value[0] {
+		return predicate{}, fmt.Errorf("predicate [%s]: value must be quoted", body)
+	}
+	value = value[1 : len(value)-1]
+
+	if attr, ok := strings.CutPrefix(name, "@"); ok {
+		return predicate{attr: attr, value: value}, nil
+	}
+	return predicate{child: name, value: value}, nil
+}
+
+// ----------------------------------------------------------------------------
+// Evaluation
+// ----------------------------------------------------------------------------
+
+// Select returns the elements the path selects, in document order. For an
+// attribute path these are the elements carrying the attribute.
+func (p *Path) Select(doc *Document) []*Node {
+	elements := selectSteps(doc, p.steps)
+	if p.attr == nil {
+		return elements
+	}
+
+	var matched []*Node
+	for _, e := range elements {
+		if p.attrIndex(e) >= 0 {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+// selectSteps evaluates element steps from the document node.
+func selectSteps(doc *Document, steps []step) []*Node {
+	// The document node's only element child is the root
+	context := []*Node{{Kind: ElementNode, Children: []*Node{doc.Root}}}
+	for _, s := range steps {
+		var next []*Node
+		seen := make(map[*Node]bool)
+		for _, c := range context {
+			for _, n := range s.apply(c) {
+				if !seen[n] {
+					seen[n] = true
+					next = append(next, n)
+				}
+			}
+		}
+		context = next
+	}
+	return context
+}
+
+// apply returns the nodes a step selects from context node c.
+func (s step) apply(c *Node) []*Node {
+	parents := []*Node{c}
+	if s.descendant {
+		parents = descendantsOrSelf(c, nil)
+	}
+
+	var selected []*Node
+	for _, parent := range parents {
+		var candidates []*Node
+		for _, child := range parent.Children {
+			if child.Kind == ElementNode && s.name.matchesElement(child) {
+				candidates = append(candidates, child)
+			}
+		}
+		for _, pred := range s.predicates {
+			candidates = pred.filter(candidates)
+		}
+		selected = append(selected, candidates...)
+	}
+	return selected
+}
+
+func descendantsOrSelf(n *Node, out []*Node) []*Node {
+	out = append(out, n)
+	for _, c := range n.Children {
+		if c.Kind == ElementNode {
+			out = descendantsOrSelf(c, out)
+		}
+	}
+	return out
+}
+
+func (t nameTest) matchesElement(n *Node) bool {
+	if t.local != "*" && t.local != n.Name.Local {
+		return false
+	}
+	return t.prefix == "" || n.Namespace == t.namespace
+}
+
+func (p predicate) filter(nodes []*Node) []*Node {
+	if p.position > 0 {
+		if p.position > len(nodes) {
+			return nil
+		}
+		return nodes[p.position-1 : p.position]
+	}
+
+	var kept []*Node
+	for _, n := range nodes {
+		if p.attr != "" {
+			if v, ok := n.AttrValue(p.attr); ok && v == p.value {
+				kept = append(kept, n)
+			}
+			continue
+		}
+		for _, c := range n.Elements() {
+			if c.Name.Local == p.child && c.Text() == p.value {
+				kept = append(kept, n)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// attrIndex returns the index of the path's attribute on e, or -1.
+func (p *Path) attrIndex(e *Node) int {
+	for i, a := range e.Attr {
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			continue
+		}
+		if p.attr.local != "*" && a.Name.Local != p.attr.local {
+			continue
+		}
+		if p.attr.prefix != "" {
+			if a.Name.Space == "" {
+				continue
+			}
+			if ns, ok := e.lookupPrefix(a.Name.Space); !ok || ns != p.attr.namespace {
+				continue
+			}
+		}
+		return i
+	}
+	return -1
+}
+
+// ----------------------------------------------------------------------------
+// Editing
+// ----------------------------------------------------------------------------
+
+// Remove deletes the selected elements or attributes and returns how many
+// were removed. The root element cannot be removed.
+func (p *Path) Remove(doc *Document) int {
+	removed := 0
+	for _, e := range p.Select(doc) {
+		if p.attr != nil {
+			for i := p.attrIndex(e); i >= 0; i = p.attrIndex(e) {
+				e.Attr = append(e.Attr[:i], e.Attr[i+1:]...)
+				removed++
+			}
+			continue
+		}
+		if e != doc.Root {
+			e.remove()
+			removed++
+		}
+	}
+	return removed
+}
+
+// Set sets the text of the selected elements or the value of the selected
+// attributes. If nothing is selected and the last step is a plain name,
+// the element or attribute is created under each element the rest of the
+// path selects. Returns the number of values set.
+func (p *Path) Set(doc *Document, value string) int {
+	if p.attr != nil {
+		if p.attr.local == "*" {
+			return 0
+		}
+		set := 0
+		for _, e := range selectSteps(doc, p.steps) {
+			if i := p.attrIndex(e); i >= 0 {
+				e.Attr[i].Value = value
+			} else {
+				e.Attr = append(e.Attr, xml.Attr{Name: p.newName(e, p.attr), Value: value})
+			}
+			set++
+		}
+		return set
+	}
+
+	selected := p.Select(doc)
+	for _, e := range selected {
+		e.SetText(value)
+	}
+	if len(selected) > 0 {
+		return len(selected)
+	}
+
+	last := p.steps[len(p.steps)-1]
+	if last.descendant || last.name.local == "*" || len(last.predicates) > 0 || len(p.steps) == 1 {
+		return 0
+	}
+	created := 0
+	for _, parent := range selectSteps(doc, p.steps[:len(p.steps)-1]) {
+		n := &Node{Kind: ElementNode, Parent: parent, Namespace: last.name.namespace}
+		n.Name = p.newName(parent, &last.name)
+		if last.name.prefix != "" {
+			if ns, ok := parent.lookupPrefix(last.name.prefix); !ok || ns != last.name.namespace {
+				n.Attr = append(n.Attr, xml.Attr{Name: xml.Name{Space: "xmlns", Local: last.name.prefix}, Value: last.name.namespace})
+			}
+		} else {
+			n.Namespace, _ = parent.lookupPrefix("")
+		}
+		n.SetText(value)
+		parent.Children = append(parent.Children, n)
+		created++
+	}
+	return created
+}
+
+// newName returns the name written for a created element or attribute.
+// Attributes whose prefix isn't bound at e are declared on e.
+func (p *Path) newName(e *Node, t *nameTest) xml.Name {
+	if t.prefix == "" {
+		return xml.Name{Local: t.local}
+	}
+	if t == p.attr {
+		if ns, ok := e.lookupPrefix(t.prefix); !ok || ns != t.namespace {
+			e.Attr = append(e.Attr, xml.Attr{Name: xml.Name{Space: "xmlns", Local: t.prefix}, Value: t.namespace})
+		}
+	}
+	return xml.Name{Space: t.prefix, Local: t.local}
+}
@@ -0,0 +1,217 @@
+package xmlbody
+
+import (
+	"testing"
+)
+
+const envelope = `<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ord="urn:orders">` +
+	`<soap:Header><ord:Debug>on</ord:Debug></soap:Header>` +
+	`<soap:Body><ord:GetOrderResponse>` +
+	`<ord:Order id="7" status="open"><ord:Item type="gift">a &amp; b</ord:Item><ord:Item>c</ord:Item><ord:InternalId>x1</ord:InternalId></ord:Order>` +
+	`</ord:GetOrderResponse></soap:Body></soap:Envelope>`
+
+var testNamespaces = map[string]string{
+	"soap": SOAP11Namespace,
+	"ord":  "urn:orders",
+	"bad":  "urn:other",
+}
+
+func mustParse(t *testing.T, s string) *Document {
+	t.Helper()
+	doc, err := Parse([]byte(s))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return doc
+}
+
+func mustCompile(t *testing.T, expr string) *Path {
+	t.Helper()
+	p, err := Compile(expr, testNamespaces)
+	if err != nil {
+		t.Fatalf("Compile(%q) error = %v", expr, err)
+	}
+	return p
+}
+
+func TestParse_RoundTrip(t *testing.T) {
+	doc := mustParse(t, envelope)
+	if got := string(doc.Bytes()); got != envelope {
+		t.Errorf("Bytes() =\n%s\nwant\n%s", got, envelope)
+	}
+
+	for _, bad := range []string{``, `<a>`, `<a></b>`, `<a/><b/>`, `<p:a/>`, `text<a/>`} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("Parse(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestPath_Select(t *testing.T) {
+	doc := mustParse(t, envelope)
+
+	tests := []struct {
+		expr string
+		want int
+	}{
+		{"/soap:Envelope/soap:Body/ord:GetOrderResponse/ord:Order", 1},
+		{"/Envelope/Body/*/Order/Item", 2},
+		{"//Item", 2},
+		{"//Item[2]", 1},
+		{"//Item[@type='gift']", 1},
+		{"//Order[Item='c']", 1},
+		{"//Order[Item='z']", 0},
+		{"//Order/@id", 1},
+		{"//Order/@missing", 0},
+		{"//bad:Item", 0},
+		{"/Body", 0},
+	}
+
+	for _, tt := range tests {
+		if got := len(mustCompile(t, tt.expr).Select(doc)); got != tt.want {
+			t.Errorf("%s selected %d, want %d", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"Envelope", "/a/@b/c", "//", "/a[", "/a[x=1]", "/undeclared:a", "/a[0]"} {
+		if _, err := Compile(bad, testNamespaces); err == nil {
+			t.Errorf("Compile(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestPath_Edit(t *testing.T) {
+	doc := mustParse(t, envelope)
+
+	if n := mustCompile(t, "//ord:Debug").Remove(doc); n != 1 {
+		t.Errorf("Remove() = %d, want 1", n)
+	}
+	if n := mustCompile(t, "//Order/@status").Remove(doc); n != 1 {
+		t.Errorf("Remove(@status) = %d, want 1", n)
+	}
+	if n := mustCompile(t, "//Item[1]").Set(doc, "<new>"); n != 1 {
+		t.Errorf("Set() = %d, want 1", n)
+	}
+	if n := mustCompile(t, "/soap:Envelope/soap:Header/ord:Consumer").Set(doc, "c-42"); n != 1 {
+		t.Errorf("Set(create) = %d, want 1", n)
+	}
+	if n := mustCompile(t, "/soap:Envelope/soap:Header/bad:Trace").Set(doc, "t"); n != 1 {
+		t.Errorf("Set(create, new namespace) = %d, want 1", n)
+	}
+	if n := mustCompile(t, "//Order/@id").Set(doc, "8"); n != 1 {
+		t.Errorf("Set(@id) = %d, want 1", n)
+	}
+	if n := mustCompile(t, "//Nothing/Here").Set(doc, "x"); n != 0 {
+		t.Errorf("Set() without a parent = %d, want 0", n)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ord="urn:orders">` +
+		`<soap:Header><ord:Consumer>c-42</ord:Consumer><bad:Trace xmlns:bad="urn:other">t</bad:Trace></soap:Header>` +
+		`<soap:Body><ord:GetOrderResponse>` +
+		`<ord:Order id="8"><ord:Item type="gift">&lt;new&gt;</ord:Item><ord:Item>c</ord:Item><ord:InternalId>x1</ord:InternalId></ord:Order>` +
+		`</ord:GetOrderResponse></soap:Body></soap:Envelope>`
+	if got := string(doc.Bytes()); got != want {
+		t.Errorf("edited document =\n%s\nwant\n%s", got, want)
+	}
+
+	// The edited document parses, and created elements resolve their namespace
+	doc = mustParse(t, want)
+	if len(mustCompile(t, "//bad:Trace").Select(doc)) != 1 {
+		t.Error("created element should be in its declared namespace")
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	doc := mustParse(t, envelope)
+
+	body := SOAPBody(doc)
+	if body == nil || body.Name.Local != "GetOrderResponse" {
+		t.Fatalf("SOAPBody() = %v", body)
+	}
+	want := `{"GetOrderResponse":{"Order":{"@id":"7","@status":"open","Item":[{"@type":"gift","#text":"a & b"},"c"],"InternalId":"x1"}}}`
+	if got := string(ToJSON(body)); got != want {
+		t.Errorf("ToJSON() =\n%s\nwant\n%s", got, want)
+	}
+
+	fault := mustParse(t, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body><s:Fault><s:Reason>boom</s:Reason></s:Fault></s:Body></s:Envelope>`)
+	if !IsSOAPFault(SOAPBody(fault)) {
+		t.Error("IsSOAPFault() should detect a SOAP 1.2 fault")
+	}
+	if SOAPBody(mustParse(t, `<Envelope><Body><x/></Body></Envelope>`)) != nil {
+		t.Error("SOAPBody() should require the SOAP namespace")
+	}
+	if got := string(ToJSON(mustParse(t, `<a><b/></a>`).Root)); got != `{"a":{"b":""}}` {
+		t.Errorf("ToJSON(empty element) = %s", got)
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want string
+	}{
+		{
+			json: `{"Order": {"@id": 7, "Item": ["a & b", {"@type": "gift", "#text": "c"}], "Paid": true, "Note": null, "Lines": {}}}`,
+			want: `<Order id="7"><Item>a &amp; b</Item><Item type="gift">c</Item><Paid>true</Paid><Note/><Lines/></Order>`,
+		},
+		{
+			json: `{"soap:Envelope": {"@xmlns:soap": "http://schemas.xmlsoap.org/soap/envelope/", "soap:Body": {"GetOrder": {"OrderId": 12345678901234567890}}}}`,
+			want: `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><GetOrder><OrderId>12345678901234567890</OrderId></GetOrder></soap:Body></soap:Envelope>`,
+		},
+		{json: `{"a": "x\"y"}`, want: `<a>x"y</a>`},
+	}
+	for _, tt := range tests {
+		doc, err := FromJSON([]byte(tt.json))
+		if err != nil {
+			t.Errorf("FromJSON(%s) error = %v", tt.json, err)
+			continue
+		}
+		if got := string(doc.Bytes()); got != tt.want {
+			t.Errorf("FromJSON(%s) =\n%s\nwant\n%s", tt.json, got, tt.want)
+		}
+	}
+
+	// ToJSON reverses it
+	const order = `{"Order":{"@id":"7","Item":[{"@type":"gift","#text":"a & b"},"c"],"Note":""}}`
+	doc, err := FromJSON([]byte(order))
+	if err != nil {
+		t.Fatalf("FromJSON(%s) error = %v", order, err)
+	}
+	if got := string(ToJSON(doc.Root)); got != order {
+		t.Errorf("ToJSON(FromJSON(%s)) = %s", order, got)
+	}
+
+	for _, bad := range []string{
+		``,
+		`[1]`,
+		`{}`,
+		`{"a": 1, "b": 2}`,
+		`{"a": 1} {}`,
+		`{"a": [[1]]}`,
+		`{"a": {"@id": {}}}`,
+		`{"a b": 1}`,
+		`{"a": {"@x=\"1\"": 1}}`,
+		`{"ord:a": 1}`,
+		`{"a": {"b": 1`,
+	} {
+		if _, err := FromJSON([]byte(bad)); err == nil {
+			t.Errorf("FromJSON(%s) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestIsXML(t *testing.T) {
+	for mediaType, want := range map[string]bool{
+		"application/xml":      true,
+		"text/xml":             true,
+		"application/soap+xml": true,
+		"application/json":     false,
+		"text/plain":           false,
+	} {
+		if got := IsXML(mediaType); got != want {
+			t.Errorf("IsXML(%q) = %v, want %v", mediaType, got, want)
+		}
+	}
+}
@@ -7,13 +7,31 @@
 -- Enable UUID extension
 CREATE EXTENSION IF NOT EXISTS "pgcrypto";
 
+-- ============================================================================
+-- TABLE: workspaces
+-- Purpose: Isolation boundary for teams sharing one gateway. Services,
+--          routes, consumers and plugins belong to exactly one workspace;
+--          names are unique per workspace and global plugins apply only
+--          to their own workspace's routes
+-- ============================================================================
+CREATE TABLE workspaces (
+    name VARCHAR(100) PRIMARY KEY CHECK (name ~ '^[a-z0-9][a-z0-9-]*$'),
+    description TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+INSERT INTO workspaces (name, description) VALUES
+('default', 'Default workspace');
+
 -- ============================================================================
 -- TABLE: services
 -- Purpose: Backend microservices/systems that the gateway proxies to
 -- ============================================================================
 CREATE TABLE services (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    name VARCHAR(100) UNIQUE NOT NULL,
+    workspace VARCHAR(100) NOT NULL DEFAULT 'default' REFERENCES workspaces(name),
+    name VARCHAR(100) NOT NULL,
     
     -- Connection details
     protocol VARCHAR(10) NOT NULL CHECK (protocol IN ('http', 'https', 'grpc')),
@@ -29,16 +47,39 @@ CREATE TABLE services (
     
     -- Load balancing
     load_balancer_type VARCHAR(50) DEFAULT 'round-robin' 
-        CHECK (load_balancer_type IN ('round-robin', 'least-connections', 'weighted', 'ip-hash')),
+        CHECK (load_balancer_type IN ('round-robin', 'least-connections', 'weighted', 'ip-hash', 'consistent-hash', 'locality-aware')),
+    hash_on VARCHAR(20) NOT NULL DEFAULT 'ip'
+        CHECK (hash_on IN ('ip', 'header', 'cookie', 'path-param')),
+    hash_on_key VARCHAR(100), -- Header/cookie/path-param name for consistent-hash
+    hash_balance_factor NUMERIC(4,2) NOT NULL DEFAULT 1.25 CHECK (hash_balance_factor >= 1),
+    
+    -- Address family for upstream dials ('default' = UPSTREAM_IP_FAMILY)
+    ip_family VARCHAR(20) NOT NULL DEFAULT 'default'
+        CHECK (ip_family IN ('default', 'auto', 'ipv4', 'ipv6', 'prefer_ipv4', 'prefer_ipv6')),
+    
+    -- Active health checks of the targets (NULL = outlier detection only), e.g.
+    -- {"interval": "10s", "grpc_service": "payments.v1.Payments", "tls": true}
+    health_check JSONB,
+    
+    -- API documentation (OpenAPI 3.x), merged by the gateway at GET /admin/specs
+    openapi_spec JSONB,
+    
+    -- Labels for filtering and per-team metrics (e.g., ["team-payments", "tier-1"])
+    tags TEXT[] NOT NULL DEFAULT '{}',
     
     enabled BOOLEAN DEFAULT true,
     created_at TIMESTAMP DEFAULT NOW(),
-    updated_at TIMESTAMP DEFAULT NOW()
+    updated_at TIMESTAMP DEFAULT NOW(),
+    
+    UNIQUE(workspace, name),
+    UNIQUE(id, workspace) -- target of routes' same-workspace FK
 );
 
 -- Index for fast lookups by name
 CREATE INDEX idx_services_name ON services(name);
+CREATE INDEX idx_services_workspace ON services(workspace);
 CREATE INDEX idx_services_enabled ON services(enabled);
+CREATE INDEX idx_services_tags ON services USING GIN (tags);
 
 -- ============================================================================
 -- TABLE: service_targets
@@ -50,6 +91,7 @@ CREATE TABLE service_targets (
     target VARCHAR(255) NOT NULL, -- Format: "host:port"
     weight INTEGER DEFAULT 100,
     health_check_path VARCHAR(255) DEFAULT '/health',
+    region VARCHAR(100) NOT NULL DEFAULT '', -- e.g. 'us-east-1' or 'us-east-1a'; '' = unknown
     enabled BOOLEAN DEFAULT true,
     created_at TIMESTAMP DEFAULT NOW(),
     
@@ -60,12 +102,41 @@ CREATE TABLE service_targets (
 CREATE INDEX idx_service_targets_service_id ON service_targets(service_id);
 CREATE INDEX idx_service_targets_enabled ON service_targets(enabled);
 
+-- ============================================================================
+-- TABLE: route_groups
+-- Purpose: Named sets of routes sharing a service, a base path and plugins;
+--          member routes' paths are relative to base_path, disabling the
+--          group disables them all, and group-scoped plugins apply to each
+-- ============================================================================
+CREATE TABLE route_groups (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workspace VARCHAR(100) NOT NULL DEFAULT 'default' REFERENCES workspaces(name),
+    service_id UUID NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    base_path VARCHAR(255) NOT NULL DEFAULT '' -- e.g. '/api/orders'; '' = none
+        CHECK (base_path = '' OR base_path LIKE '/%'),
+    enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    
+    UNIQUE(workspace, name),
+    UNIQUE(id, service_id), -- target of routes' same-service FK
+    
+    -- A group belongs to its service's workspace
+    FOREIGN KEY (service_id, workspace) REFERENCES services(id, workspace)
+);
+
+CREATE INDEX idx_route_groups_service_id ON route_groups(service_id);
+CREATE INDEX idx_route_groups_workspace ON route_groups(workspace);
+
 -- ============================================================================
 -- TABLE: routes
 -- Purpose: Maps incoming requests to services based on path/method/host
 -- ============================================================================
 CREATE TABLE routes (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workspace VARCHAR(100) NOT NULL DEFAULT 'default' REFERENCES workspaces(name),
     service_id UUID NOT NULL REFERENCES services(id) ON DELETE CASCADE,
     name VARCHAR(100),
     
@@ -78,16 +149,134 @@ CREATE TABLE routes (
     strip_path BOOLEAN DEFAULT false,
     preserve_host BOOLEAN DEFAULT false,
     
+    -- Admission control: under overload, bulk is queued/shed first, critical last
+    priority_class VARCHAR(20) NOT NULL DEFAULT 'normal'
+        CHECK (priority_class IN ('critical', 'normal', 'bulk')),
+    
+    -- Total time allowed per request, plugins and upstream call included;
+    -- 0 = the service's read_timeout_ms
+    timeout_ms INTEGER NOT NULL DEFAULT 0 CHECK (timeout_ms >= 0),
+    
+    -- Time-based scheduling: route serves only between start/end and, with a
+    -- cron window, only inside (mode 'active') or outside (mode 'inactive') it
+    schedule_start TIMESTAMPTZ,
+    schedule_end TIMESTAMPTZ,
+    schedule_cron VARCHAR(100), -- 5-field cron, e.g. '* 9-17 * * 1-5' (business hours)
+    schedule_mode VARCHAR(10) NOT NULL DEFAULT 'active'
+        CHECK (schedule_mode IN ('active', 'inactive')),
+    schedule_timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    
+    -- Service level objectives, e.g. {"latency_ms": 300, "error_budget": 0.001, "window": "1h"}
+    slo JSONB,
+    
+    -- Labels for filtering and per-team metrics (requests carry route + service tags)
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    
     enabled BOOLEAN DEFAULT true,
     created_at TIMESTAMP DEFAULT NOW(),
-    updated_at TIMESTAMP DEFAULT NOW()
+    updated_at TIMESTAMP DEFAULT NOW(),
+    
+    -- Route group (optional); the group's service is the route's service,
+    -- and moving the group to another service moves its routes along
+    group_id UUID,
+    
+    -- A route belongs to its service's workspace
+    FOREIGN KEY (service_id, workspace) REFERENCES services(id, workspace),
+    FOREIGN KEY (group_id, service_id) REFERENCES route_groups(id, service_id) ON UPDATE CASCADE
 );
 
 -- Indexes for route matching performance
 CREATE INDEX idx_routes_service_id ON routes(service_id);
+CREATE INDEX idx_routes_workspace ON routes(workspace);
+CREATE INDEX idx_routes_group_id ON routes(group_id);
 CREATE INDEX idx_routes_enabled ON routes(enabled);
 CREATE INDEX idx_routes_paths ON routes USING GIN (paths);
 CREATE INDEX idx_routes_methods ON routes USING GIN (methods);
+CREATE INDEX idx_routes_tags ON routes USING GIN (tags);
+
+-- ============================================================================
+-- TABLE: redirects
+-- Purpose: Answers matching requests with a 30x instead of proxying them
+-- ============================================================================
+CREATE TABLE redirects (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) UNIQUE,
+    
+    -- Matching criteria (same patterns as routes)
+    hosts TEXT[], -- e.g. ["old.example.com", "*.example.com"]
+    paths TEXT[] NOT NULL, -- e.g. ["/docs/*", "/users/:id"]
+    
+    -- Response: Location template may use {host}, {path}, {<param>} and {*}
+    location TEXT NOT NULL, -- e.g. 'https://docs.example.com/{*}'
+    status_code INTEGER NOT NULL DEFAULT 301
+        CHECK (status_code IN (301, 302, 303, 307, 308)),
+    preserve_query BOOLEAN NOT NULL DEFAULT true,
+    
+    enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_redirects_enabled ON redirects(enabled);
+
+-- ============================================================================
+-- TABLE: stream_listeners
+-- Purpose: TCP/UDP (L4) listeners whose connections are proxied to a
+--          service's targets, for non-HTTP backends (e.g. MQTT)
+-- ============================================================================
+CREATE TABLE stream_listeners (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workspace VARCHAR(100) NOT NULL DEFAULT 'default' REFERENCES workspaces(name),
+    service_id UUID NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    name VARCHAR(100),
+    
+    protocol VARCHAR(10) NOT NULL DEFAULT 'tcp' CHECK (protocol IN ('tcp', 'udp')),
+    listen_address VARCHAR(255) NOT NULL, -- e.g. ':1883', '0.0.0.0:5353'
+    
+    -- Limits: concurrent connections (UDP: client sessions, 0 = unlimited),
+    -- and how long a connection or session may stay idle
+    max_connections INTEGER NOT NULL DEFAULT 0 CHECK (max_connections >= 0),
+    idle_timeout_ms INTEGER NOT NULL DEFAULT 300000 CHECK (idle_timeout_ms >= 0),
+    
+    enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    
+    UNIQUE(workspace, name),
+    UNIQUE(protocol, listen_address),
+    
+    -- A listener belongs to its service's workspace
+    FOREIGN KEY (service_id, workspace) REFERENCES services(id, workspace)
+);
+
+CREATE INDEX idx_stream_listeners_service_id ON stream_listeners(service_id);
+
+-- ============================================================================
+-- TABLE: tenants
+-- Purpose: Tenant registry for tenant-aware routing. Requests are resolved
+-- to a tenant by header or subdomain; services whose host or path contain
+-- {tenant} are proxied to the tenant's own backend. IDs are DNS labels so
+-- they can be substituted into host names.
+-- ============================================================================
+CREATE TABLE tenants (
+    id VARCHAR(63) PRIMARY KEY CHECK (id ~ '^[a-z0-9]([a-z0-9-]*[a-z0-9])?$'), -- e.g. 'acme'
+    name VARCHAR(255),
+    
+    enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- ============================================================================
+-- TABLE: gateway_settings
+-- Purpose: Runtime overrides of gateway settings (upstream pool sizes and
+-- timeouts), applied on hot reload. Keys are lower-cased env var names.
+-- ============================================================================
+CREATE TABLE gateway_settings (
+    key VARCHAR(100) PRIMARY KEY, -- e.g. 'upstream_max_idle_conns_per_host'
+    value TEXT NOT NULL, -- e.g. '50', '30s'
+    updated_at TIMESTAMP DEFAULT NOW()
+);
 
 -- ============================================================================
 -- TABLE: consumers
@@ -95,17 +284,23 @@ CREATE INDEX idx_routes_methods ON routes USING GIN (methods);
 -- ============================================================================
 CREATE TABLE consumers (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    username VARCHAR(100) UNIQUE NOT NULL,
+    workspace VARCHAR(100) NOT NULL DEFAULT 'default' REFERENCES workspaces(name),
+    username VARCHAR(100) NOT NULL,
     email VARCHAR(255),
     custom_id VARCHAR(100),
     metadata JSONB DEFAULT '{}',
+    owner VARCHAR(255), -- developer who created it via the self-service portal
     created_at TIMESTAMP DEFAULT NOW(),
-    updated_at TIMESTAMP DEFAULT NOW()
+    updated_at TIMESTAMP DEFAULT NOW(),
+    
+    UNIQUE(workspace, username)
 );
 
 -- Indexes for consumer lookups
 CREATE INDEX idx_consumers_username ON consumers(username);
 CREATE INDEX idx_consumers_custom_id ON consumers(custom_id);
+CREATE INDEX idx_consumers_owner ON consumers(owner);
+CREATE INDEX idx_consumers_workspace ON consumers(workspace);
 
 -- ============================================================================
 -- TABLE: api_keys
@@ -118,6 +313,7 @@ CREATE TABLE api_keys (
     key_hash VARCHAR(64) UNIQUE NOT NULL, -- SHA256 hash (64 hex chars)
     name VARCHAR(100),
     enabled BOOLEAN DEFAULT true,
+    scopes TEXT[] DEFAULT '{}',
     created_at TIMESTAMP DEFAULT NOW(),
     last_used_at TIMESTAMP,
     expires_at TIMESTAMP
@@ -134,15 +330,18 @@ CREATE INDEX idx_api_keys_enabled ON api_keys(enabled);
 -- ============================================================================
 CREATE TABLE plugins (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workspace VARCHAR(100) NOT NULL DEFAULT 'default' REFERENCES workspaces(name),
     name VARCHAR(50) NOT NULL,
-    scope VARCHAR(20) NOT NULL CHECK (scope IN ('global', 'service', 'route', 'consumer')),
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('global', 'service', 'route', 'group', 'consumer')),
     
     -- Foreign keys (only one should be set based on scope)
     service_id UUID REFERENCES services(id) ON DELETE CASCADE,
     route_id UUID REFERENCES routes(id) ON DELETE CASCADE,
+    group_id UUID REFERENCES route_groups(id) ON DELETE CASCADE,
     consumer_id UUID REFERENCES consumers(id) ON DELETE CASCADE,
     
     config JSONB NOT NULL DEFAULT '{}',
+    tags TEXT[] NOT NULL DEFAULT '{}', -- Labels for filtering
     enabled BOOLEAN DEFAULT true,
     priority INTEGER DEFAULT 100,
     
@@ -151,20 +350,75 @@ CREATE TABLE plugins (
     
     -- Constraint: Ensure only appropriate FK is set based on scope
     CONSTRAINT plugins_scope_fk_check CHECK (
-        (scope = 'global' AND service_id IS NULL AND route_id IS NULL AND consumer_id IS NULL) OR
-        (scope = 'service' AND service_id IS NOT NULL AND route_id IS NULL AND consumer_id IS NULL) OR
-        (scope = 'route' AND route_id IS NOT NULL AND service_id IS NULL AND consumer_id IS NULL) OR
-        (scope = 'consumer' AND consumer_id IS NOT NULL AND service_id IS NULL AND route_id IS NULL)
+        (scope = 'global' AND service_id IS NULL AND route_id IS NULL AND group_id IS NULL AND consumer_id IS NULL) OR
+        (scope = 'service' AND service_id IS NOT NULL AND route_id IS NULL AND group_id IS NULL AND consumer_id IS NULL) OR
+        (scope = 'route' AND route_id IS NOT NULL AND service_id IS NULL AND group_id IS NULL AND consumer_id IS NULL) OR
+        (scope = 'group' AND group_id IS NOT NULL AND service_id IS NULL AND route_id IS NULL AND consumer_id IS NULL) OR
+        (scope = 'consumer' AND consumer_id IS NOT NULL AND service_id IS NULL AND route_id IS NULL AND group_id IS NULL)
     )
 );
 
 -- Indexes for plugin lookups (critical for request processing!)
 CREATE INDEX idx_plugins_scope ON plugins(scope);
+CREATE INDEX idx_plugins_workspace ON plugins(workspace);
 CREATE INDEX idx_plugins_service_id ON plugins(service_id);
 CREATE INDEX idx_plugins_route_id ON plugins(route_id);
+CREATE INDEX idx_plugins_group_id ON plugins(group_id);
 CREATE INDEX idx_plugins_consumer_id ON plugins(consumer_id);
 CREATE INDEX idx_plugins_enabled ON plugins(enabled);
 CREATE INDEX idx_plugins_priority ON plugins(priority);
+CREATE INDEX idx_plugins_tags ON plugins USING GIN (tags);
+
+-- ============================================================================
+-- TABLE: recorded_requests
+-- Purpose: Sampled requests captured by the request-recorder plugin,
+--          re-sent against other environments with `gateway replay`
+-- Note: Sensitive headers are redacted before storage
+-- ============================================================================
+CREATE TABLE recorded_requests (
+    id BIGSERIAL PRIMARY KEY,
+    route_id UUID NOT NULL REFERENCES routes(id) ON DELETE CASCADE,
+
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    query TEXT,
+    host VARCHAR(255),
+    headers JSONB NOT NULL DEFAULT '{}',
+    body BYTEA,
+    body_truncated BOOLEAN DEFAULT false,
+
+    -- Original response, compared against the replayed response
+    status_code INTEGER NOT NULL,
+    duration_ms BIGINT NOT NULL,
+
+    recorded_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_recorded_requests_route_recorded_at ON recorded_requests(route_id, recorded_at);
+CREATE INDEX idx_recorded_requests_recorded_at ON recorded_requests(recorded_at);
+
+-- ============================================================================
+-- TABLE: consumer_usage_daily
+-- Purpose: Per-consumer daily request counts and bandwidth for billing and
+--          reporting, rolled up by the gateway's usage aggregator
+-- Note: consumer_id is the authenticated identity (consumers.id for API
+--       keys, or an external subject from token/LDAP auth), so no FK
+-- ============================================================================
+CREATE TABLE consumer_usage_daily (
+    consumer_id VARCHAR(255) NOT NULL,
+    day DATE NOT NULL, -- UTC
+
+    requests BIGINT NOT NULL DEFAULT 0,
+    client_errors BIGINT NOT NULL DEFAULT 0, -- 4xx
+    server_errors BIGINT NOT NULL DEFAULT 0, -- 5xx
+    bytes_in BIGINT NOT NULL DEFAULT 0,
+    bytes_out BIGINT NOT NULL DEFAULT 0,
+
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (consumer_id, day)
+);
+
+CREATE INDEX idx_consumer_usage_daily_day ON consumer_usage_daily(day);
 
 -- ============================================================================
 -- TRIGGERS: Auto-update timestamps
@@ -177,9 +431,15 @@ BEGIN
 END;
 $$ LANGUAGE plpgsql;
 
+CREATE TRIGGER update_workspaces_updated_at BEFORE UPDATE ON workspaces
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
 CREATE TRIGGER update_services_updated_at BEFORE UPDATE ON services
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+CREATE TRIGGER update_route_groups_updated_at BEFORE UPDATE ON route_groups
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
 CREATE TRIGGER update_routes_updated_at BEFORE UPDATE ON routes
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
@@ -189,6 +449,85 @@ CREATE TRIGGER update_consumers_updated_at BEFORE UPDATE ON consumers
 CREATE TRIGGER update_plugins_updated_at BEFORE UPDATE ON plugins
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+CREATE TRIGGER update_redirects_updated_at BEFORE UPDATE ON redirects
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_stream_listeners_updated_at BEFORE UPDATE ON stream_listeners
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_tenants_updated_at BEFORE UPDATE ON tenants
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_gateway_settings_updated_at BEFORE UPDATE ON gateway_settings
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- ============================================================================
+-- TRIGGERS: Config change notifications (hot-reload fallback)
+-- ============================================================================
+-- Announces config changes on the gateway_config_changes channel with the
+-- same JSON shape the admin API publishes to Redis. Gateways LISTEN here
+-- when Redis is unavailable. Target changes are reported as service changes;
+-- settings are identified by key.
+CREATE OR REPLACE FUNCTION notify_config_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    row_data RECORD;
+    entity_type TEXT := TG_ARGV[0];
+    entity_id TEXT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        row_data := OLD;
+    ELSE
+        row_data := NEW;
+    END IF;
+
+    IF TG_TABLE_NAME = 'service_targets' THEN
+        entity_id := row_data.service_id::text;
+    ELSIF TG_TABLE_NAME = 'gateway_settings' THEN
+        entity_id := row_data.key;
+    ELSE
+        entity_id := row_data.id::text;
+    END IF;
+
+    PERFORM pg_notify('gateway_config_changes', json_build_object(
+        'event_type', 'config_change',
+        'entity_type', entity_type,
+        'entity_id', entity_id,
+        'action', CASE TG_OP WHEN 'INSERT' THEN 'created' WHEN 'UPDATE' THEN 'updated' ELSE 'deleted' END,
+        'metadata', json_build_object('source', 'postgres')
+    )::text);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notify_services_change AFTER INSERT OR UPDATE OR DELETE ON services
+    FOR EACH ROW EXECUTE FUNCTION notify_config_change('service');
+
+CREATE TRIGGER notify_service_targets_change AFTER INSERT OR UPDATE OR DELETE ON service_targets
+    FOR EACH ROW EXECUTE FUNCTION notify_config_change('service');
+
+CREATE TRIGGER notify_routes_change AFTER INSERT OR UPDATE OR DELETE ON routes
+    FOR EACH ROW EXECUTE FUNCTION notify_config_change('route');
+
+CREATE TRIGGER notify_route_groups_change AFTER INSERT OR UPDATE OR DELETE ON route_groups
+    FOR EACH ROW EXECUTE FUNCTION notify_config_change('route_group');
+
+CREATE TRIGGER notify_plugins_change AFTER INSERT OR UPDATE OR DELETE ON plugins
+    FOR EACH ROW EXECUTE FUNCTION notify_config_change('plugin');
+
+CREATE TRIGGER notify_redirects_change AFTER INSERT OR UPDATE OR DELETE ON redirects
+    FOR EACH ROW EXECUTE FUNCTION notify_config_change('redirect');
+
+CREATE TRIGGER notify_stream_listeners_change AFTER INSERT OR UPDATE OR DELETE ON stream_listeners
+    FOR EACH ROW EXECUTE FUNCTION notify_config_change('stream_listener');
+
+CREATE TRIGGER notify_tenants_change AFTER INSERT OR UPDATE OR DELETE ON tenants
+    FOR EACH ROW EXECUTE FUNCTION notify_config_change('tenant');
+
+CREATE TRIGGER notify_gateway_settings_change AFTER INSERT OR UPDATE OR DELETE ON gateway_settings
+    FOR EACH ROW EXECUTE FUNCTION notify_config_change('setting');
+
 -- ============================================================================
 -- SAMPLE DATA (for development/testing)
 -- ============================================================================
@@ -231,6 +570,7 @@ INSERT INTO plugins (name, scope, config, priority) VALUES
 -- View: Active routes with service info
 CREATE VIEW v_active_routes AS
 SELECT 
+    r.workspace,
     r.id as route_id,
     r.name as route_name,
     r.paths,
@@ -247,6 +587,7 @@ WHERE r.enabled = true AND s.enabled = true;
 -- View: Consumer API key status
 CREATE VIEW v_consumer_keys AS
 SELECT 
+    c.workspace,
     c.id as consumer_id,
     c.username,
     c.email,
@@ -263,7 +604,8 @@ LEFT JOIN api_keys k ON c.id = k.consumer_id;
 -- FUNCTIONS (utility functions)
 -- ============================================================================
 
--- Function: Get all plugins for a route (includes global, service, and route-specific)
+-- Function: Get all plugins for a route (includes the workspace's global
+-- plugins, service, route group, and route-specific)
 CREATE OR REPLACE FUNCTION get_route_plugins(p_route_id UUID)
 RETURNS TABLE (
     plugin_id UUID,
@@ -282,8 +624,9 @@ BEGIN
     LEFT JOIN routes r ON p.route_id = r.id
     WHERE p.enabled = true
       AND (
-          p.scope = 'global'
+          (p.scope = 'global' AND p.workspace = (SELECT workspace FROM routes WHERE id = p_route_id))
           OR (p.scope = 'service' AND p.service_id = (SELECT service_id FROM routes WHERE id = p_route_id))
+          OR (p.scope = 'group' AND p.group_id = (SELECT group_id FROM routes WHERE id = p_route_id))
           OR (p.scope = 'route' AND p.route_id = p_route_id)
       )
     ORDER BY p.priority ASC;
@@ -305,9 +648,14 @@ CREATE INDEX idx_plugins_service_enabled ON plugins(service_id, enabled) WHERE s
 -- Schema created successfully!
 -- 
 -- Tables created:
+--   - workspaces
 --   - services (6 rows with sample data)
 --   - service_targets
+--   - route_groups
 --   - routes
+--   - redirects
+--   - stream_listeners
+--   - tenants
 --   - consumers
 --   - api_keys
 --   - plugins